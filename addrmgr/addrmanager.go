@@ -6,10 +6,12 @@
 package addrmgr
 
 import (
+	"bytes"
 	"container/list"
 	crand "crypto/rand" // for seeding
 	"encoding/base32"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -32,6 +35,8 @@ import (
 type AddrManager struct {
 	mtx            sync.Mutex
 	peersFile      string
+	anchorsFile    string
+	asMap          *ASMap
 	lookupFunc     func(string) ([]net.IP, error)
 	rand           *rand.Rand
 	key            [32]byte
@@ -297,8 +302,8 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.groupKey(netAddr))...)
+	data1 = append(data1, []byte(a.groupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -306,7 +311,7 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.groupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -326,7 +331,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.groupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -432,6 +437,64 @@ func (a *AddrManager) loadPeers() {
 	log.Infof("Loaded %d addresses from file '%s'", a.numAddresses(), a.peersFile)
 }
 
+// SaveAnchors saves the passed addresses of outbound peers to the anchors file
+// so they can be connected to first at next run.  Reconnecting to peers which
+// were known to be good before a restart makes it harder for an attacker to
+// take over all outbound connections while the address tables are refilled.
+func (a *AddrManager) SaveAnchors(addrs []*wire.NetAddress) error {
+	keys := make([]string, 0, len(addrs))
+	for _, na := range addrs {
+		keys = append(keys, NetAddressKey(na))
+	}
+
+	w, err := os.Create(a.anchorsFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// LoadAnchors returns the addresses saved by SaveAnchors and removes the
+// anchors file, so a node which fails to connect to its anchors does not keep
+// retrying them on every restart.  A missing or malformed file results in no
+// anchors.
+func (a *AddrManager) LoadAnchors() []*wire.NetAddress {
+	r, err := os.Open(a.anchorsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error opening file %s: %v", a.anchorsFile, err)
+		}
+		return nil
+	}
+	var keys []string
+	err = json.NewDecoder(r).Decode(&keys)
+	r.Close()
+	if err := os.Remove(a.anchorsFile); err != nil {
+		log.Warnf("Failed to remove anchors file %s: %v",
+			a.anchorsFile, err)
+	}
+	if err != nil {
+		log.Errorf("Failed to decode file %s: %v", a.anchorsFile, err)
+		return nil
+	}
+
+	addrs := make([]*wire.NetAddress, 0, len(keys))
+	for _, key := range keys {
+		na, err := a.DeserializeNetAddress(key)
+		if err != nil {
+			log.Warnf("Skipping anchor %s: %v", key, err)
+			continue
+		}
+		addrs = append(addrs, na)
+	}
+	log.Infof("Loaded %d anchors from file '%s'", len(addrs), a.anchorsFile)
+	return addrs
+}
+
 func (a *AddrManager) deserializePeers(filePath string) error {
 
 	_, err := os.Stat(filePath)
@@ -682,11 +745,99 @@ func (a *AddrManager) reset() {
 	}
 }
 
+// torV3Version is the version byte of Tor v3 onion service addresses.
+const torV3Version = 0x03
+
+// torV3Checksum returns the checksum of the Tor v3 onion service address of
+// the passed public key, which is the first two bytes of
+// SHA3-256(".onion checksum" || pubkey || version).
+func torV3Checksum(pubKey []byte) []byte {
+	data := make([]byte, 0, 15+len(pubKey)+1)
+	data = append(data, ".onion checksum"...)
+	data = append(data, pubKey...)
+	data = append(data, torV3Version)
+	checksum := sha3.Sum256(data)
+	return checksum[:2]
+}
+
+// decodeTorV3 returns the public key of the passed Tor v3 onion service
+// address without the ".onion" suffix, which is the base32 encoding of the
+// public key followed by the checksum and the version.
+func decodeTorV3(host string) ([]byte, error) {
+	// Tor uses lowercase base32 while go uses capitals.
+	data, err := base32.StdEncoding.DecodeString(strings.ToUpper(host))
+	if err != nil {
+		return nil, err
+	}
+	pubKey := data[:32]
+	if data[34] != torV3Version {
+		return nil, fmt.Errorf("unknown tor onion address version %d",
+			data[34])
+	}
+	if !bytes.Equal(data[32:34], torV3Checksum(pubKey)) {
+		return nil, fmt.Errorf("invalid tor onion address checksum")
+	}
+	return pubKey, nil
+}
+
+// encodeTorV3 returns the Tor v3 onion service address of the passed public
+// key.
+func encodeTorV3(pubKey []byte) string {
+	data := make([]byte, 0, 35)
+	data = append(data, pubKey...)
+	data = append(data, torV3Checksum(pubKey)...)
+	data = append(data, torV3Version)
+	return strings.ToLower(base32.StdEncoding.EncodeToString(data)) +
+		".onion"
+}
+
+// i2pSuffix is the suffix of I2P addresses, which are the unpadded base32
+// encoding of the SHA256 hash of an I2P destination followed by the suffix.
+const i2pSuffix = ".b32.i2p"
+
+// i2pEncoding is the base32 encoding of I2P addresses.  I2P uses lowercase
+// base32 while go uses capitals, so addresses are converted when decoded.
+var i2pEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// decodeI2P returns the hash of the passed I2P address without the ".b32.i2p"
+// suffix.
+func decodeI2P(host string) ([]byte, error) {
+	hash, err := i2pEncoding.DecodeString(strings.ToUpper(host))
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("invalid I2P address length %d",
+			len(hash))
+	}
+	return hash, nil
+}
+
 // HostToNetAddress returns a netaddress given a host address. If the address is
-// a tor .onion address this will be taken care of. else if the host is not an
-// IP address it will be resolved (via tor if required).
+// a tor .onion or an I2P .b32.i2p address this will be taken care of. else if
+// the host is not an IP address it will be resolved (via tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
-	// tor address is 16 char base32 + ".onion"
+	// I2P address is 52 char base32 + ".b32.i2p"
+	if len(host) == 60 && host[52:] == i2pSuffix {
+		hash, err := decodeI2P(host[:52])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressNetwork(wire.NetI2P, hash, port,
+			services), nil
+	}
+
+	// tor v3 address is 56 char base32 + ".onion"
+	if len(host) == 62 && host[56:] == ".onion" {
+		pubKey, err := decodeTorV3(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressNetwork(wire.NetTorV3, pubKey, port,
+			services), nil
+	}
+
+	// tor v2 address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
 		// go base32 encoding uses capitals (as does the rfc
@@ -715,13 +866,25 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Tor v3 and I2P addresses are transformed into
+// their .onion and .b32.i2p addresses as well, while the addresses of other
+// networks which are not held by an IP are hex encoded.
 func ipString(na *wire.NetAddress) string {
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enogh.
 		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
 		return strings.ToLower(base32) + ".onion"
 	}
+	if IsTorV3(na) {
+		return encodeTorV3(na.Addr)
+	}
+	if IsI2P(na) {
+		return strings.ToLower(i2pEncoding.EncodeToString(na.Addr)) +
+			i2pSuffix
+	}
+	if na.IsAddrV2Only() {
+		return hex.EncodeToString(na.Addr)
+	}
 
 	return na.IP.String()
 }
@@ -774,37 +937,94 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 			}
 			factor *= 1.2
 		}
-	} else {
-		// new node.
-		// XXX use a closure/function to avoid repeating this.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// Pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrNew))
-			if len(a.addrNew[bucket]) == 0 {
-				continue
-			}
-			// Then, a random entry in it.
-			var ka *KnownAddress
-			nth := a.rand.Intn(len(a.addrNew[bucket]))
-			for _, value := range a.addrNew[bucket] {
-				if nth == 0 {
-					ka = value
-				}
-				nth--
-			}
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
-				return ka
+	}
+	return a.pickNew()
+}
+
+// pickNew picks a random address from the new table with preference given to
+// ones that have not been used recently.
+//
+// This function MUST be called with the address manager lock held and at
+// least one address in the new table.
+func (a *AddrManager) pickNew() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// Pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrNew))
+		if len(a.addrNew[bucket]) == 0 {
+			continue
+		}
+		// Then, a random entry in it.
+		var ka *KnownAddress
+		nth := a.rand.Intn(len(a.addrNew[bucket]))
+		for _, value := range a.addrNew[bucket] {
+			if nth == 0 {
+				ka = value
 			}
-			factor *= 1.2
+			nth--
+		}
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from new bucket",
+				NetAddressKey(ka.na))
+			return ka
 		}
+		factor *= 1.2
 	}
 }
 
+// GetNewAddress returns a single address from the new table, which holds the
+// addresses that have not been connected to successfully yet.  It is used to
+// pick addresses for feeler connections, which test whether those addresses
+// are reachable so they can be moved to the tried table.  nil is returned when
+// the new table is empty.
+func (a *AddrManager) GetNewAddress() *KnownAddress {
+	// Protect concurrent access.
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.nNew == 0 {
+		return nil
+	}
+	return a.pickNew()
+}
+
+// GoodAddresses returns up to maxAddrs addresses from the tried table, which holds
+// the addresses that have been connected to successfully, advertising at least
+// the passed services on the passed port.  Only IPv4 and IPv6 addresses which
+// are routable and not considered bad are returned, in random order.  It is
+// used to answer the queries of the DNS seeder, which can only serve IP
+// addresses on the default port of the network.
+func (a *AddrManager) GoodAddresses(services wire.ServiceFlag, port uint16, maxAddrs int) []*wire.NetAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var addrs []*wire.NetAddress
+	for _, ka := range a.addrIndex {
+		na := ka.na
+		if !ka.tried || ka.isBad() || na.Port != port ||
+			na.Services&services != services {
+			continue
+		}
+		if na.IP == nil || IsOnionCatTor(na) || !IsRoutable(na) {
+			continue
+		}
+		addrs = append(addrs, na)
+	}
+
+	// Fisher-Yates shuffle the addresses, only as far as the ones which
+	// are returned.
+	if maxAddrs > len(addrs) {
+		maxAddrs = len(addrs)
+	}
+	for i := 0; i < maxAddrs; i++ {
+		j := rand.Intn(len(addrs)-i) + i
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	}
+	return addrs[:maxAddrs]
+}
+
 func (a *AddrManager) find(addr *wire.NetAddress) *KnownAddress {
 	return a.addrIndex[NetAddressKey(addr)]
 }
@@ -980,8 +1200,8 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if IsOnionCatTor(remoteAddr) || IsTorV3(remoteAddr) {
+		if IsOnionCatTor(localAddr) || IsTorV3(localAddr) {
 			return Private
 		}
 
@@ -992,6 +1212,14 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	// I2P addresses can only be reached over I2P.
+	if IsI2P(remoteAddr) {
+		if IsI2P(localAddr) {
+			return Private
+		}
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1069,7 +1297,8 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) &&
+			!IsTorV3(remoteAddr) && !IsI2P(remoteAddr) {
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
@@ -1085,6 +1314,7 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
 	am := AddrManager{
 		peersFile:      filepath.Join(dataDir, "peers.json"),
+		anchorsFile:    filepath.Join(dataDir, "anchors.json"),
 		lookupFunc:     lookupFunc,
 		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		quit:           make(chan struct{}),
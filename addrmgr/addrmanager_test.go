@@ -8,7 +8,9 @@ package addrmgr_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -352,6 +354,121 @@ func TestGetAddress(t *testing.T) {
 	}
 }
 
+// TestGetNewAddress ensures only addresses which have not been connected to
+// successfully are returned for feeler connections.
+func TestGetNewAddress(t *testing.T) {
+	n := addrmgr.New("testgetnewaddress", lookupFunc)
+	if ka := n.GetNewAddress(); ka != nil {
+		t.Errorf("GetNewAddress: got %v from an empty set", ka)
+	}
+
+	err := n.AddAddressByIP(someIP + ":8333")
+	if err != nil {
+		t.Fatalf("Adding address failed: %v", err)
+	}
+	ka := n.GetNewAddress()
+	if ka == nil {
+		t.Fatalf("GetNewAddress: got no address from the new table")
+	}
+	if ka.NetAddress().IP.String() != someIP {
+		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP.String(), someIP)
+	}
+
+	// Once the address is good, it is no longer in the new table.
+	n.Good(ka.NetAddress())
+	if ka := n.GetNewAddress(); ka != nil {
+		t.Errorf("GetNewAddress: got tried address %v", ka)
+	}
+}
+
+// TestGoodAddresses ensures only good addresses on the requested port which
+// advertise the requested services are returned for the DNS seeder.
+func TestGoodAddresses(t *testing.T) {
+	n := addrmgr.New("testgoodaddresses", lookupFunc)
+	srcAddr := wire.NewNetAddressIPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
+	network := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, 4), 8333,
+		wire.SFNodeNetwork)
+	cf := wire.NewNetAddressIPPort(net.ParseIP("2001:db9::1"), 8333,
+		wire.SFNodeNetwork|wire.SFNodeCF)
+	otherPort := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, 5), 8334,
+		wire.SFNodeNetwork)
+	private := wire.NewNetAddressIPPort(net.IPv4(10, 0, 0, 1), 8333,
+		wire.SFNodeNetwork)
+	untried := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, 6), 8333,
+		wire.SFNodeNetwork)
+	n.AddAddresses([]*wire.NetAddress{network, cf, otherPort, private,
+		untried}, srcAddr)
+	for _, addr := range []*wire.NetAddress{network, cf, otherPort, private} {
+		n.Good(addr)
+	}
+
+	keys := func(addrs []*wire.NetAddress) map[string]bool {
+		keys := make(map[string]bool, len(addrs))
+		for _, addr := range addrs {
+			keys[addrmgr.NetAddressKey(addr)] = true
+		}
+		return keys
+	}
+	tests := []struct {
+		services wire.ServiceFlag
+		max      int
+		want     []*wire.NetAddress
+	}{
+		{wire.SFNodeNetwork, 10, []*wire.NetAddress{network, cf}},
+		{wire.SFNodeNetwork | wire.SFNodeCF, 10, []*wire.NetAddress{cf}},
+		{wire.SFNodeBloom, 10, nil},
+	}
+	for _, test := range tests {
+		got := n.GoodAddresses(test.services, 8333, test.max)
+		if !reflect.DeepEqual(keys(got), keys(test.want)) {
+			t.Errorf("GoodAddresses(%v): got %v, want %v",
+				test.services, got, test.want)
+		}
+	}
+
+	if got := n.GoodAddresses(wire.SFNodeNetwork, 8333, 1); len(got) != 1 {
+		t.Errorf("GoodAddresses: got %d addresses, want 1", len(got))
+	}
+}
+
+// TestAnchors ensures saved anchors are loaded once.
+func TestAnchors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anchors")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := addrmgr.New(dir, lookupFunc)
+	if addrs := n.LoadAnchors(); len(addrs) != 0 {
+		t.Errorf("LoadAnchors: got %d anchors without file", len(addrs))
+	}
+
+	anchors := []*wire.NetAddress{
+		wire.NewNetAddressIPPort(net.ParseIP(someIP), 8333, 0),
+		wire.NewNetAddressIPPort(net.ParseIP("2001:470::1"), 8334, 0),
+	}
+	if err := n.SaveAnchors(anchors); err != nil {
+		t.Fatalf("SaveAnchors: unexpected error: %v", err)
+	}
+	addrs := n.LoadAnchors()
+	if len(addrs) != len(anchors) {
+		t.Fatalf("LoadAnchors: got %d anchors, want %d", len(addrs),
+			len(anchors))
+	}
+	for i, na := range addrs {
+		got, want := addrmgr.NetAddressKey(na),
+			addrmgr.NetAddressKey(anchors[i])
+		if got != want {
+			t.Errorf("LoadAnchors: got anchor %s, want %s", got, want)
+		}
+	}
+	if addrs := n.LoadAnchors(); len(addrs) != 0 {
+		t.Errorf("LoadAnchors: got %d anchors after loading them",
+			len(addrs))
+	}
+}
+
 func TestGetBestLocalAddress(t *testing.T) {
 	localAddrs := []wire.NetAddress{
 		{IP: net.ParseIP("192.168.0.100")},
@@ -471,3 +588,81 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddressTorV3 ensures Tor v3 onion service addresses are
+// converted to addresses which are only relayed with addrv2 messages, and that
+// their keys are the onion addresses again.
+func TestHostToNetAddressTorV3(t *testing.T) {
+	n := addrmgr.New("testhosttonetaddresstorv3", lookupFunc)
+
+	host := "duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion"
+	na, err := n.HostToNetAddress(host, 8333, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("HostToNetAddress: unexpected error: %v", err)
+	}
+	if !addrmgr.IsTorV3(na) || !na.IsAddrV2Only() {
+		t.Fatalf("HostToNetAddress: got %v, want a Tor v3 address", na)
+	}
+	if !addrmgr.IsRoutable(na) {
+		t.Errorf("IsRoutable: Tor v3 address is not routable")
+	}
+	if want := host + ":8333"; addrmgr.NetAddressKey(na) != want {
+		t.Errorf("NetAddressKey: got %s, want %s",
+			addrmgr.NetAddressKey(na), want)
+	}
+	if key := addrmgr.GroupKey(na); key != "tor:13" {
+		t.Errorf("GroupKey: got %s, want tor:13", key)
+	}
+
+	// Addresses with a bad checksum or version are rejected.
+	badHosts := []string{
+		"euckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion",
+		"duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczae.onion",
+	}
+	for _, badHost := range badHosts {
+		if _, err := n.HostToNetAddress(badHost, 8333, 0); err == nil {
+			t.Errorf("HostToNetAddress: no error for %s", badHost)
+		}
+	}
+
+	// Tor v3 addresses are stored like any other address.
+	n.AddAddress(na, na)
+	if ka := n.GetAddress(); ka == nil ||
+		addrmgr.NetAddressKey(ka.NetAddress()) != host+":8333" {
+		t.Errorf("GetAddress: Tor v3 address was not added")
+	}
+}
+
+// TestHostToNetAddressI2P ensures I2P addresses are converted to addresses
+// which are only relayed with addrv2 messages and belong to the I2P network.
+func TestHostToNetAddressI2P(t *testing.T) {
+	n := addrmgr.New("testhosttonetaddressi2p", lookupFunc)
+
+	host := "udhdrtrcetjm5sxzskjyr5ztpeszydbh4dpl3pl4utgqqw2v4jna.b32.i2p"
+	na, err := n.HostToNetAddress(host, 0, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("HostToNetAddress: unexpected error: %v", err)
+	}
+	if !addrmgr.IsI2P(na) || !na.IsAddrV2Only() {
+		t.Fatalf("HostToNetAddress: got %v, want an I2P address", na)
+	}
+	if !addrmgr.IsRoutable(na) {
+		t.Errorf("IsRoutable: I2P address is not routable")
+	}
+	if want := host + ":0"; addrmgr.NetAddressKey(na) != want {
+		t.Errorf("NetAddressKey: got %s, want %s",
+			addrmgr.NetAddressKey(na), want)
+	}
+	if key := addrmgr.GroupKey(na); key != "i2p:0" {
+		t.Errorf("GroupKey: got %s, want i2p:0", key)
+	}
+	if network := addrmgr.Network(na); network != addrmgr.NetworkI2P {
+		t.Errorf("Network: got %s, want %s", network, addrmgr.NetworkI2P)
+	}
+
+	// Addresses which are not base32 are rejected.
+	badHost := "udhdrtrcetjm5sxzskjyr5ztpeszydbh4dpl3pl4utgqqw2v4jn1.b32.i2p"
+	if _, err := n.HostToNetAddress(badHost, 0, 0); err == nil {
+		t.Errorf("HostToNetAddress: no error for %s", badHost)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// ASMap maps IP addresses to the number of the autonomous system (AS) which
+// announces them.  Grouping addresses by AS rather than by netgroup makes it
+// much harder for an attacker controlling a single provider with many address
+// ranges to occupy all outbound connections of a node.
+type ASMap struct {
+	// prefixes maps the masked IP addresses of the prefixes of each
+	// length to their AS numbers.  IPv4 prefix lengths are stored as the
+	// length in IPv4-mapped IPv6 form, so both families share the maps.
+	prefixes [net.IPv6len*8 + 1]map[string]uint32
+	count    int
+}
+
+// LoadASMap loads an AS map from the passed file.  Every line of the file holds
+// a subnet in CIDR notation followed by the AS number announcing it, such as
+// "192.0.2.0/24 64496".  Empty lines and lines starting with '#' are ignored.
+func LoadASMap(file string) (*ASMap, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := new(ASMap)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected subnet and AS "+
+				"number", file, lineNum)
+		}
+		_, subnet, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, lineNum, err)
+		}
+		asn, err := strconv.ParseUint(strings.TrimPrefix(
+			strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil || asn == 0 {
+			return nil, fmt.Errorf("%s:%d: invalid AS number %q",
+				file, lineNum, fields[1])
+		}
+		m.add(subnet, uint32(asn))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// add maps the passed subnet to the passed AS number.
+func (m *ASMap) add(subnet *net.IPNet, asn uint32) {
+	ones, bits := subnet.Mask.Size()
+	if bits == net.IPv4len*8 {
+		ones += (net.IPv6len - net.IPv4len) * 8
+	}
+	if m.prefixes[ones] == nil {
+		m.prefixes[ones] = make(map[string]uint32)
+	}
+	m.prefixes[ones][string(subnet.IP.To16())] = asn
+	m.count++
+}
+
+// Len returns the number of subnets in the AS map.
+func (m *ASMap) Len() int {
+	return m.count
+}
+
+// Lookup returns the number of the AS announcing the passed IP address, using
+// the longest matching subnet.  0 is returned when no subnet matches.
+func (m *ASMap) Lookup(ip net.IP) uint32 {
+	ip = ip.To16()
+	if m == nil || ip == nil {
+		return 0
+	}
+	for ones := len(m.prefixes) - 1; ones >= 0; ones-- {
+		if m.prefixes[ones] == nil {
+			continue
+		}
+		masked := ip.Mask(net.CIDRMask(ones, net.IPv6len*8))
+		if asn, ok := m.prefixes[ones][string(masked)]; ok {
+			return asn
+		}
+	}
+	return 0
+}
+
+// SetASMap sets the AS map used to group addresses.  Addresses which are
+// mapped to an AS are grouped by the AS, while all other addresses keep being
+// grouped by netgroup as returned by GroupKey.  It must be called before the
+// address manager is started.
+func (a *AddrManager) SetASMap(m *ASMap) {
+	a.mtx.Lock()
+	a.asMap = m
+	a.mtx.Unlock()
+}
+
+// groupKey returns the group of the passed address, taking the AS map into
+// account.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) groupKey(na *wire.NetAddress) string {
+	if a.asMap != nil && IsRoutable(na) && !IsOnionCatTor(na) {
+		if asn := a.asMap.Lookup(na.IP); asn != 0 {
+			return fmt.Sprintf("as%d", asn)
+		}
+	}
+	return GroupKey(na)
+}
+
+// GroupKey returns the group of the passed address which is used to keep
+// outbound connections diverse.  It is the AS announcing the address when an
+// AS map is set and maps the address, and the netgroup returned by the package
+// level GroupKey otherwise.
+//
+// This function is safe for concurrent access.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.groupKey(na)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestASMap ensures AS maps are loaded from files, map addresses to the AS of
+// their longest matching subnet, and are used to group addresses.
+func TestASMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asmap")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	asmapFile := filepath.Join(dir, "asmap.txt")
+	contents := "# Test AS map\n" +
+		"12.0.0.0/8 64496\n" +
+		"12.1.0.0/16 AS64497\n" +
+		"\n" +
+		"173.1.0.0/16 64496\n" +
+		"2001:470::/32 64498\n"
+	if err := ioutil.WriteFile(asmapFile, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	m, err := addrmgr.LoadASMap(asmapFile)
+	if err != nil {
+		t.Fatalf("LoadASMap: unexpected error: %v", err)
+	}
+	if m.Len() != 4 {
+		t.Errorf("Len: got %d, want 4", m.Len())
+	}
+
+	n := addrmgr.New("testasmap", lookupFunc)
+	n.SetASMap(m)
+	tests := []struct {
+		ip    string
+		asn   uint32
+		group string
+	}{
+		{"12.2.3.4", 64496, "as64496"},
+		{"12.1.2.3", 64497, "as64497"},
+		{"173.1.2.3", 64496, "as64496"},
+		{"2001:470:1::1", 64498, "as64498"},
+		{"196.1.2.3", 0, "196.1.0.0"},
+		{"10.1.2.3", 0, "unroutable"},
+	}
+	for _, test := range tests {
+		ip := net.ParseIP(test.ip)
+		if asn := m.Lookup(ip); asn != test.asn {
+			t.Errorf("Lookup(%s): got %d, want %d", test.ip, asn,
+				test.asn)
+		}
+		na := wire.NewNetAddressIPPort(ip, 8333, wire.SFNodeNetwork)
+		if group := n.GroupKey(na); group != test.group {
+			t.Errorf("GroupKey(%s): got %s, want %s", test.ip, group,
+				test.group)
+		}
+	}
+
+	// Malformed lines are rejected.
+	for _, line := range []string{"12.0.0.0/8", "12.0.0.0 64496",
+		"12.0.0.0/8 0", "12.0.0.0/8 ASX"} {
+
+		if err := ioutil.WriteFile(asmapFile, []byte(line), 0600); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+		if _, err := addrmgr.LoadASMap(asmapFile); err == nil {
+			t.Errorf("LoadASMap: no error for %q", line)
+		}
+	}
+}
@@ -8,10 +8,20 @@ package addrmgr
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/bitgo/prova/wire"
 )
 
+// The names of the networks addresses belong to, which are used to configure
+// the transport connecting to the addresses of each network.
+const (
+	NetworkIPv4  = "ipv4"
+	NetworkIPv6  = "ipv6"
+	NetworkOnion = "onion"
+	NetworkI2P   = "i2p"
+)
+
 var (
 	// rfc1918Nets specifies the IPv4 private address blocks as defined by
 	// by RFC1918 (10.0.0.0/8, 172.16.0.0/12, and 192.168.0.0/16).
@@ -118,6 +128,43 @@ func IsOnionCatTor(na *wire.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// IsTorV3 returns whether or not the passed address is a Tor v3 onion service
+// address.  Unlike Tor v2 addresses, these addresses are too long to be
+// encoded in the onioncat range, so they are only relayed with addrv2
+// messages.
+func IsTorV3(na *wire.NetAddress) bool {
+	return na.IsAddrV2Only() && na.NetworkID == wire.NetTorV3
+}
+
+// IsI2P returns whether or not the passed address is an I2P address, which is
+// the SHA256 hash of the destination of an I2P router.  Like Tor v3 addresses,
+// these addresses are only relayed with addrv2 messages.
+func IsI2P(na *wire.NetAddress) bool {
+	return na.IsAddrV2Only() && na.NetworkID == wire.NetI2P
+}
+
+// HostNetwork returns the name of the network the passed host belongs to,
+// which selects the transport used to connect to it.  Hosts which are neither
+// an IPv4 address, a tor .onion address nor an I2P .b32.i2p address are
+// considered IPv6.
+func HostNetwork(host string) string {
+	switch {
+	case strings.HasSuffix(host, ".onion"):
+		return NetworkOnion
+	case strings.HasSuffix(host, i2pSuffix):
+		return NetworkI2P
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		return NetworkIPv4
+	}
+	return NetworkIPv6
+}
+
+// Network returns the name of the network the passed address belongs to.
+func Network(na *wire.NetAddress) string {
+	return HostNetwork(ipString(na))
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -213,7 +260,14 @@ func IsRFC6598(na *wire.NetAddress) bool {
 // considered invalid under the following circumstances:
 // IPv4: It is either a zero or all bits set address.
 // IPv6: It is either a zero or RFC3849 documentation address.
+// Other networks: It is neither a Tor v3 nor an I2P address, since the
+// addresses of the other networks which are not held by an IP can not be
+// connected to.
 func IsValid(na *wire.NetAddress) bool {
+	if na.IsAddrV2Only() {
+		return IsTorV3(na) || IsI2P(na)
+	}
+
 	// IsUnspecified returns if address is 0, so only all bits set, and
 	// RFC3849 need to be explicitly checked.
 	return na.IP != nil && !(na.IP.IsUnspecified() ||
@@ -233,7 +287,8 @@ func IsRoutable(na *wire.NetAddress) bool {
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for tor address, and the string "unroutable" for an unroutable
+// onion address for tor address, the string "i2p:key" where key is the /4 of
+// the hash for I2P addresses, and the string "unroutable" for an unroutable
 // address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
@@ -269,6 +324,15 @@ func GroupKey(na *wire.NetAddress) string {
 		// group is keyed off the first 4 bits of the actual onion key.
 		return fmt.Sprintf("tor:%d", na.IP[6]&((1<<4)-1))
 	}
+	if IsTorV3(na) {
+		// Tor v3 addresses are the public key of the onion service,
+		// so they are grouped the same way.
+		return fmt.Sprintf("tor:%d", na.Addr[0]&((1<<4)-1))
+	}
+	if IsI2P(na) {
+		// I2P addresses are a hash, so they are grouped the same way.
+		return fmt.Sprintf("i2p:%d", na.Addr[0]&((1<<4)-1))
+	}
 
 	// OK, so now we know ourselves to be a IPv6 address.
 	// bitcoind uses /32 for everything, except for Hurricane Electric's
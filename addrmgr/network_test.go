@@ -201,3 +201,27 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestHostNetwork ensures hosts are assigned to the network of their
+// transport.
+func TestHostNetwork(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"12.1.2.3", addrmgr.NetworkIPv4},
+		{"::ffff:12.1.2.3", addrmgr.NetworkIPv4},
+		{"2602:100::1", addrmgr.NetworkIPv6},
+		{"fd87:d87e:eb43:1234::5678", addrmgr.NetworkIPv6},
+		{"aaaaaaaaaaaaaaaa.onion", addrmgr.NetworkOnion},
+		{"udhdrtrcetjm5sxzskjyr5ztpeszydbh4dpl3pl4utgqqw2v4jna.b32.i2p",
+			addrmgr.NetworkI2P},
+	}
+
+	for _, test := range tests {
+		if network := addrmgr.HostNetwork(test.host); network != test.expected {
+			t.Errorf("HostNetwork(%s): got %s, want %s", test.host,
+				network, test.expected)
+		}
+	}
+}
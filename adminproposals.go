@@ -0,0 +1,255 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil/pspt"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// adminProposalsBucketName is the name of the database bucket which houses the
+// admin transactions proposed for co-signing, keyed by transaction hash.
+var adminProposalsBucketName = []byte("adminproposals")
+
+// errProposalExists is returned when a transaction which is already proposed
+// is proposed again.
+var errProposalExists = errors.New("the transaction is already proposed")
+
+// adminProposal is an admin transaction proposed for co-signing by the admin
+// keyholders, along with the signatures collected for it so far.
+type adminProposal struct {
+	description string
+	created     time.Time
+
+	// packet carries the unsigned transaction and the signatures of each
+	// of its inputs, which are finalized once they have the number of
+	// signatures the input requires.
+	packet *pspt.Packet
+
+	// submitted is set once the fully signed transaction was accepted to
+	// the memory pool and relayed.
+	submitted bool
+}
+
+// hash returns the hash of the proposed transaction, which identifies the
+// proposal since signatures do not change it.
+func (p *adminProposal) hash() chainhash.Hash {
+	return p.packet.UnsignedTx.TxHash()
+}
+
+// signers returns the serialized public keys which signed the input at the
+// passed index, whether it is finalized or not.
+func (p *adminProposal) signers(idx int) [][]byte {
+	in := &p.packet.Inputs[idx]
+	if !in.IsFinalized() {
+		pubKeys := make([][]byte, 0, len(in.Sigs))
+		for _, sig := range in.Sigs {
+			pubKeys = append(pubKeys, sig.PubKey)
+		}
+		return pubKeys
+	}
+
+	// Finalized signature scripts are made of public key and signature
+	// pairs.
+	pushes, err := txscript.PushedData(in.FinalScriptSig)
+	if err != nil {
+		return nil
+	}
+	var pubKeys [][]byte
+	for i := 0; i+1 < len(pushes); i += 2 {
+		pubKeys = append(pubKeys, pushes[i])
+	}
+	return pubKeys
+}
+
+// serializeAdminProposal returns the serialization of the passed proposal
+// which is stored in the database.  It is made of the creation time as
+// seconds since the epoch, a byte flagging whether it was submitted, the
+// description as a variable length string and the serialized packet.
+func serializeAdminProposal(p *adminProposal) ([]byte, error) {
+	var buf bytes.Buffer
+	var header [9]byte
+	binary.LittleEndian.PutUint64(header[:8], uint64(p.created.Unix()))
+	if p.submitted {
+		header[8] = 1
+	}
+	buf.Write(header[:])
+	if err := wire.WriteVarString(&buf, 0, p.description); err != nil {
+		return nil, err
+	}
+	if err := p.packet.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializeAdminProposal decodes a proposal serialized by
+// serializeAdminProposal.
+func deserializeAdminProposal(serialized []byte) (*adminProposal, error) {
+	if len(serialized) < 9 {
+		return nil, errors.New("truncated admin proposal")
+	}
+	p := &adminProposal{
+		created: time.Unix(int64(binary.LittleEndian.Uint64(
+			serialized[:8])), 0),
+		submitted: serialized[8] != 0,
+		packet:    &pspt.Packet{},
+	}
+	r := bytes.NewReader(serialized[9:])
+	var err error
+	p.description, err = wire.ReadVarString(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.packet.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// adminProposals houses the admin transactions proposed for co-signing, which
+// are persisted in the database so the signatures collected from the admin
+// keyholders survive restarts.
+type adminProposals struct {
+	db database.DB
+
+	// mtx serializes the updates of proposals, which read and then write
+	// them back.
+	mtx sync.Mutex
+}
+
+// newAdminProposals returns the proposals persisted in the passed database,
+// creating their bucket when needed.
+func newAdminProposals(db database.DB) (*adminProposals, error) {
+	err := db.Update(func(dbTx database.Tx) error {
+		_, err := dbTx.Metadata().CreateBucketIfNotExists(
+			adminProposalsBucketName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &adminProposals{db: db}, nil
+}
+
+// put stores the passed proposal.
+func (ap *adminProposals) put(dbTx database.Tx, p *adminProposal) error {
+	serialized, err := serializeAdminProposal(p)
+	if err != nil {
+		return err
+	}
+	hash := p.hash()
+	bucket := dbTx.Metadata().Bucket(adminProposalsBucketName)
+	return bucket.Put(hash[:], serialized)
+}
+
+// Add stores a new proposal.  errProposalExists is returned when a proposal
+// for the same transaction exists.
+func (ap *adminProposals) Add(p *adminProposal) error {
+	ap.mtx.Lock()
+	defer ap.mtx.Unlock()
+
+	return ap.db.Update(func(dbTx database.Tx) error {
+		hash := p.hash()
+		bucket := dbTx.Metadata().Bucket(adminProposalsBucketName)
+		if bucket.Get(hash[:]) != nil {
+			return errProposalExists
+		}
+		return ap.put(dbTx, p)
+	})
+}
+
+// Fetch returns the proposal of the transaction with the passed hash, or nil
+// when there is none.
+func (ap *adminProposals) Fetch(hash *chainhash.Hash) (*adminProposal, error) {
+	var p *adminProposal
+	err := ap.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(adminProposalsBucketName)
+		serialized := bucket.Get(hash[:])
+		if serialized == nil {
+			return nil
+		}
+		var err error
+		p, err = deserializeAdminProposal(serialized)
+		return err
+	})
+	return p, err
+}
+
+// All returns every proposal, oldest first.
+func (ap *adminProposals) All() ([]*adminProposal, error) {
+	var proposals []*adminProposal
+	err := ap.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(adminProposalsBucketName)
+		return bucket.ForEach(func(k, v []byte) error {
+			p, err := deserializeAdminProposal(v)
+			if err != nil {
+				return err
+			}
+			proposals = append(proposals, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(proposals, func(i, j int) bool {
+		return proposals[i].created.Before(proposals[j].created)
+	})
+	return proposals, nil
+}
+
+// Update invokes the passed function with the proposal of the transaction with
+// the passed hash and stores the proposal as modified by it, unless it returns
+// an error.  Updates are serialized, so the function may submit the
+// transaction without racing other updates.  A nil proposal is returned when
+// there is none.
+func (ap *adminProposals) Update(hash *chainhash.Hash, update func(p *adminProposal) error) (*adminProposal, error) {
+	ap.mtx.Lock()
+	defer ap.mtx.Unlock()
+
+	p, err := ap.Fetch(hash)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	if err := update(p); err != nil {
+		return nil, err
+	}
+	err = ap.db.Update(func(dbTx database.Tx) error {
+		return ap.put(dbTx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Remove deletes the proposal of the transaction with the passed hash and
+// returns whether there was one.
+func (ap *adminProposals) Remove(hash *chainhash.Hash) (bool, error) {
+	ap.mtx.Lock()
+	defer ap.mtx.Unlock()
+
+	var found bool
+	err := ap.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(adminProposalsBucketName)
+		found = bucket.Get(hash[:]) != nil
+		if !found {
+			return nil
+		}
+		return bucket.Delete(hash[:])
+	})
+	return found, err
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/pspt"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAdminProposalSerialization ensures proposals round trip through their
+// database serialization along with their signatures, and their signers are
+// reported before and after their inputs are finalized.
+func TestAdminProposalSerialization(t *testing.T) {
+	threadScript, err := txscript.ProvaThreadScript(provautil.ProvisionThread)
+	if err != nil {
+		t.Fatalf("ProvaThreadScript: unexpected error: %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(0, threadScript))
+	packet, err := pspt.New(tx, []*wire.TxOut{wire.NewTxOut(0, threadScript)})
+	if err != nil {
+		t.Fatalf("pspt.New: unexpected error: %v", err)
+	}
+
+	// Sign the input with two keys.
+	sigHash, err := packet.SigHash(0)
+	if err != nil {
+		t.Fatalf("SigHash: unexpected error: %v", err)
+	}
+	var pubKeys [][]byte
+	for i := 0; i < 2; i++ {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+		}
+		sig, err := privKey.Sign(sigHash)
+		if err != nil {
+			t.Fatalf("Sign: unexpected error: %v", err)
+		}
+		pubKey := privKey.PubKey().SerializeCompressed()
+		err = packet.AddSignature(0, pubKey, append(sig.Serialize(),
+			byte(txscript.SigHashAll)))
+		if err != nil {
+			t.Fatalf("AddSignature: unexpected error: %v", err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	// checkSigners ensures the proposal reports both keys as signers.
+	checkSigners := func(name string, p *adminProposal) {
+		signers := p.signers(0)
+		if len(signers) != len(pubKeys) {
+			t.Fatalf("%s: got %d signers, want %d", name,
+				len(signers), len(pubKeys))
+		}
+		for i := range signers {
+			if !bytes.Equal(signers[i], pubKeys[i]) {
+				t.Errorf("%s: got signer %x, want %x", name,
+					signers[i], pubKeys[i])
+			}
+		}
+	}
+
+	p := &adminProposal{
+		description: "provision validate key",
+		created:     time.Unix(1500000000, 0),
+		packet:      packet,
+	}
+	for _, submitted := range []bool{false, true} {
+		p.submitted = submitted
+		serialized, err := serializeAdminProposal(p)
+		if err != nil {
+			t.Fatalf("serializeAdminProposal: unexpected error: %v", err)
+		}
+		got, err := deserializeAdminProposal(serialized)
+		if err != nil {
+			t.Fatalf("deserializeAdminProposal: unexpected error: %v",
+				err)
+		}
+		if got.description != p.description ||
+			!got.created.Equal(p.created) ||
+			got.submitted != p.submitted || got.hash() != p.hash() {
+
+			t.Errorf("deserializeAdminProposal: got %+v, want %+v",
+				got, p)
+		}
+		checkSigners("deserialized", got)
+	}
+	if _, err := deserializeAdminProposal([]byte{1, 2, 3}); err == nil {
+		t.Errorf("deserializeAdminProposal: no error for truncated data")
+	}
+
+	complete, err := p.packet.Finalize()
+	if err != nil || !complete {
+		t.Fatalf("Finalize: got complete %v and error %v, want complete",
+			complete, err)
+	}
+	checkSigners("finalized", p)
+}
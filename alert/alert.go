@@ -0,0 +1,258 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitWindow is the window over which the number of alerts sent
+	// is limited.
+	rateLimitWindow = time.Hour
+
+	// queueSize is the number of alerts which may wait to be sent before
+	// further alerts are dropped.
+	queueSize = 100
+)
+
+// Kind identifies the anomaly an alert reports.
+type Kind uint8
+
+// These constants define the kinds of alerts which are raised.
+const (
+	// NoBlock indicates no block has been connected to the main chain for
+	// longer than expected.
+	NoBlock Kind = iota + 1
+
+	// DeepReorg indicates a reorganization disconnected more blocks from
+	// the main chain than expected.
+	DeepReorg
+
+	// InvalidValidatorBlock indicates a block signed by a key of the
+	// validate key set was rejected as invalid.
+	InvalidValidatorBlock
+
+	// MempoolOverflow indicates the memory pool holds more transactions
+	// than expected.
+	MempoolOverflow
+
+	// IndexBehind indicates an optional index fell behind the main chain.
+	IndexBehind
+)
+
+// kindStrings is a map of alert kinds back to their constant names for pretty
+// printing.
+var kindStrings = map[Kind]string{
+	NoBlock:               "noblock",
+	DeepReorg:             "deepreorg",
+	InvalidValidatorBlock: "invalidvalidatorblock",
+	MempoolOverflow:       "mempooloverflow",
+	IndexBehind:           "indexbehind",
+}
+
+// String returns the Kind in human-readable form.
+func (k Kind) String() string {
+	if s, ok := kindStrings[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Kind (%d)", uint8(k))
+}
+
+// Alert describes an anomaly noticed by the node.
+type Alert struct {
+	// Kind is the kind of the anomaly.
+	Kind Kind
+
+	// Subject identifies what the alert is about, such as the name of an
+	// index or a validate key, for the alerts which may be raised about
+	// several things at once.  Alerts of the same kind with different
+	// subjects are deduplicated separately.
+	Subject string
+
+	// Message describes the anomaly for the operator.
+	Message string
+
+	// Height is the height of the main chain when the alert was raised.
+	Height int32
+
+	// Time is the time the alert was raised.  It is set by Raise when it
+	// is zero.
+	Time time.Time
+}
+
+// String returns a description of the alert suitable for logging.
+func (a *Alert) String() string {
+	if a.Subject == "" {
+		return fmt.Sprintf("%v: %s", a.Kind, a.Message)
+	}
+	return fmt.Sprintf("%v (%s): %s", a.Kind, a.Subject, a.Message)
+}
+
+// Target is a destination alerts are sent to.
+type Target interface {
+	// Send delivers the passed alert.
+	Send(a *Alert) error
+
+	// String returns a description of the target suitable for logging.
+	String() string
+}
+
+// Config houses the parameters of an alert manager.
+type Config struct {
+	// Targets are the destinations every alert is sent to.
+	Targets []Target
+
+	// DedupInterval is the interval during which further alerts of the
+	// kind and subject of an alert which was sent are suppressed.  Zero
+	// disables deduplication.
+	DedupInterval time.Duration
+
+	// RateLimit is the maximum number of alerts sent per hour.  Zero
+	// disables the limit.
+	RateLimit int
+
+	// Now returns the current time.  It defaults to time.Now.
+	Now func() time.Time
+}
+
+// dedupKey identifies the alerts which are deduplicated together.
+type dedupKey struct {
+	kind    Kind
+	subject string
+}
+
+// Manager deduplicates and rate limits the alerts raised by the node, and
+// sends the remaining ones to the configured targets in the background.
+type Manager struct {
+	cfg Config
+
+	mtx        sync.Mutex
+	lastSent   map[dedupKey]time.Time
+	sent       []time.Time
+	suppressed uint64
+
+	queue chan *Alert
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New returns a new alert manager using the passed config.
+func New(cfg *Config) *Manager {
+	m := &Manager{
+		cfg:      *cfg,
+		lastSent: make(map[dedupKey]time.Time),
+		queue:    make(chan *Alert, queueSize),
+		quit:     make(chan struct{}),
+	}
+	if m.cfg.Now == nil {
+		m.cfg.Now = time.Now
+	}
+	return m
+}
+
+// Start begins sending the raised alerts to the targets.
+func (m *Manager) Start() {
+	m.wg.Add(1)
+	go m.sendHandler()
+}
+
+// Stop stops sending alerts and waits for the manager to exit.  The alerts
+// which are still queued are dropped.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// admit returns whether the passed alert passes deduplication and the rate
+// limit, and records it as sent when it does.
+func (m *Manager) admit(a *Alert) (bool, string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key := dedupKey{kind: a.Kind, subject: a.Subject}
+	if last, ok := m.lastSent[key]; ok && m.cfg.DedupInterval > 0 &&
+		a.Time.Sub(last) < m.cfg.DedupInterval {
+
+		m.suppressed++
+		return false, "duplicate"
+	}
+
+	// Forget the alerts sent before the rate limit window.
+	start := a.Time.Add(-rateLimitWindow)
+	var i int
+	for i < len(m.sent) && !m.sent[i].After(start) {
+		i++
+	}
+	m.sent = m.sent[i:]
+	if m.cfg.RateLimit > 0 && len(m.sent) >= m.cfg.RateLimit {
+		m.suppressed++
+		return false, "rate limited"
+	}
+
+	m.lastSent[key] = a.Time
+	m.sent = append(m.sent, a.Time)
+	return true, ""
+}
+
+// Raise logs the passed alert and queues it to be sent to the targets unless
+// an alert of the same kind and subject was sent within the deduplication
+// interval or the rate limit is exceeded.  It returns whether the alert was
+// queued.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Raise(a *Alert) bool {
+	if a.Time.IsZero() {
+		a.Time = m.cfg.Now()
+	}
+
+	ok, reason := m.admit(a)
+	if !ok {
+		log.Debugf("Not sending %s alert: %v", reason, a)
+		return false
+	}
+	log.Warnf("Alert %v", a)
+
+	select {
+	case m.queue <- a:
+		return true
+	default:
+		log.Errorf("Dropping alert %v: %d alerts are waiting to be "+
+			"sent", a, queueSize)
+		return false
+	}
+}
+
+// Suppressed returns the number of alerts which were not sent because of
+// deduplication or the rate limit.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Suppressed() uint64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.suppressed
+}
+
+// sendHandler sends the queued alerts to every target.  It must be run as a
+// goroutine.
+func (m *Manager) sendHandler() {
+	defer m.wg.Done()
+	for {
+		select {
+		case a := <-m.queue:
+			for _, target := range m.cfg.Targets {
+				if err := target.Send(a); err != nil {
+					log.Errorf("Unable to send alert %v to "+
+						"%v: %v", a.Kind, target, err)
+				}
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
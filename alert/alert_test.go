@@ -0,0 +1,122 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// chanTarget is a target which passes the alerts it is sent to a channel.
+type chanTarget chan *Alert
+
+func (t chanTarget) Send(a *Alert) error { t <- a; return nil }
+func (t chanTarget) String() string      { return "chan" }
+
+// TestManager ensures alerts are deduplicated by kind and subject, rate
+// limited, and sent to the targets.
+func TestManager(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	target := make(chanTarget, 10)
+	m := New(&Config{
+		Targets:       []Target{target},
+		DedupInterval: 10 * time.Minute,
+		RateLimit:     3,
+		Now:           func() time.Time { return now },
+	})
+	m.Start()
+	defer m.Stop()
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		alert   Alert
+		sent    bool
+	}{
+		{"first", 0, Alert{Kind: NoBlock}, true},
+		{"duplicate", time.Minute, Alert{Kind: NoBlock}, false},
+		{"other subject", 0, Alert{Kind: IndexBehind, Subject: "txindex"}, true},
+		{"other kind", 0, Alert{Kind: DeepReorg}, true},
+		{"rate limited", 0, Alert{Kind: MempoolOverflow}, false},
+		{"after dedup interval", 10 * time.Minute, Alert{Kind: NoBlock}, false},
+		{"after rate limit window", time.Hour, Alert{Kind: NoBlock}, true},
+	}
+	for _, test := range tests {
+		now = now.Add(test.advance)
+		a := test.alert
+		if sent := m.Raise(&a); sent != test.sent {
+			t.Errorf("%s: unexpected result - got %v, want %v",
+				test.name, sent, test.sent)
+			continue
+		}
+		if !test.sent {
+			continue
+		}
+		select {
+		case got := <-target:
+			if got.Kind != test.alert.Kind || !got.Time.Equal(now) {
+				t.Errorf("%s: unexpected alert %v at %v",
+					test.name, got, got.Time)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: alert was not sent", test.name)
+		}
+	}
+	if got := m.Suppressed(); got != 3 {
+		t.Errorf("Suppressed: got %d, want 3", got)
+	}
+}
+
+// TestWebhookTarget ensures the webhook target posts alerts as JSON objects
+// and fails on unsuccessful responses.
+func TestWebhookTarget(t *testing.T) {
+	var received payload
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &received)
+			w.WriteHeader(status)
+		}))
+	defer server.Close()
+
+	target := NewWebhookTarget(server.URL, time.Second)
+	a := &Alert{
+		Kind:    IndexBehind,
+		Subject: "txindex",
+		Message: "behind",
+		Height:  100,
+		Time:    time.Unix(1500000000, 0),
+	}
+	if err := target.Send(a); err != nil {
+		t.Fatalf("Send: unexpected error: %v", err)
+	}
+	want := payload{
+		Kind:    "indexbehind",
+		Subject: "txindex",
+		Message: "behind",
+		Height:  100,
+		Time:    1500000000,
+	}
+	if received != want {
+		t.Errorf("Send: got payload %+v, want %+v", received, want)
+	}
+
+	status = http.StatusInternalServerError
+	if err := target.Send(a); err == nil {
+		t.Errorf("Send: expected error for failed response")
+	}
+}
+
+// TestCommandTarget ensures empty commands are rejected.
+func TestCommandTarget(t *testing.T) {
+	if _, err := NewCommandTarget("  ", time.Second); err == nil {
+		t.Errorf("NewCommandTarget: expected error for empty command")
+	}
+}
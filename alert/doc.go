@@ -0,0 +1,34 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package alert delivers alerts about consensus and operational anomalies of a
+node to operators.
+
+Overview
+
+The node raises an alert when it notices something an operator should look
+into, such as no block being connected for a long time, a deep reorganization
+of the main chain, an invalid block signed by a key of the validate key set,
+an overfull memory pool or an optional index falling behind the chain.  The
+manager sends every alert to all of its targets in the background, so raising
+an alert never blocks the caller.
+
+Targets
+
+A webhook target posts the alert as a JSON object to a URL, and a command
+target runs a command with the JSON object on its standard input and the kind,
+subject and message of the alert in the PROVA_ALERT_KIND, PROVA_ALERT_SUBJECT
+and PROVA_ALERT_MESSAGE environment variables.  Further targets implement the
+Target interface.
+
+Deduplication and Rate Limiting
+
+A condition which persists is noticed again and again.  Alerts of the same
+kind about the same subject are therefore only sent once per deduplication
+interval.  In addition, the number of alerts sent per hour is limited, so a
+flood of anomalies does not overwhelm the targets.  Alerts which are
+deduplicated or rate limited are still logged.
+*/
+package alert
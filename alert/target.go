@@ -0,0 +1,144 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// payload is the JSON object describing an alert which is sent to the
+// targets.
+type payload struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+	Height  int32  `json:"height"`
+	Time    int64  `json:"time"`
+}
+
+// marshalAlert returns the JSON object describing the passed alert.
+func marshalAlert(a *Alert) ([]byte, error) {
+	return json.Marshal(&payload{
+		Kind:    a.Kind.String(),
+		Subject: a.Subject,
+		Message: a.Message,
+		Height:  a.Height,
+		Time:    a.Time.Unix(),
+	})
+}
+
+// webhookTarget posts alerts to a URL.
+type webhookTarget struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookTarget returns a target which posts every alert as a JSON object
+// to the passed URL, giving up after the passed timeout.
+func NewWebhookTarget(url string, timeout time.Duration) Target {
+	return &webhookTarget{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts the passed alert to the URL of the webhook.  A response with a
+// status other than 2xx is treated as a failure.
+//
+// This is part of the Target interface.
+func (t *webhookTarget) Send(a *Alert) error {
+	body, err := marshalAlert(a)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s",
+			resp.Status)
+	}
+	return nil
+}
+
+// String returns the URL of the webhook.
+//
+// This is part of the Target interface.
+func (t *webhookTarget) String() string {
+	return "webhook " + t.url
+}
+
+// commandTarget runs a command for every alert.
+type commandTarget struct {
+	args    []string
+	timeout time.Duration
+}
+
+// NewCommandTarget returns a target which runs the passed command for every
+// alert, killing it after the passed timeout.  The command is split into its
+// arguments at whitespace and run without a shell.  It receives the alert as
+// a JSON object on its standard input and in environment variables.
+func NewCommandTarget(command string, timeout time.Duration) (Target, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty alert command")
+	}
+	return &commandTarget{args: args, timeout: timeout}, nil
+}
+
+// Send runs the command with the passed alert.  A command which exits with a
+// non-zero status is treated as a failure.
+//
+// This is part of the Target interface.
+func (t *commandTarget) Send(a *Alert) error {
+	body, err := marshalAlert(a)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, t.args[0], t.args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"PROVA_ALERT_KIND="+a.Kind.String(),
+		"PROVA_ALERT_SUBJECT="+a.Subject,
+		"PROVA_ALERT_MESSAGE="+a.Message)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		output = bytes.TrimSpace(output)
+		if len(output) > 0 {
+			return fmt.Errorf("%v: %s", err, output)
+		}
+		return err
+	}
+	return nil
+}
+
+// String returns the command.
+//
+// This is part of the Target interface.
+func (t *commandTarget) String() string {
+	return "command " + strings.Join(t.args, " ")
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/alert"
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+)
+
+const (
+	// alertCheckInterval is the interval between the checks for the
+	// conditions which are not noticed through chain notifications.
+	alertCheckInterval = time.Minute
+
+	// alertTargetTimeout is the time alert webhooks and commands are given
+	// to complete.
+	alertTargetTimeout = 30 * time.Second
+
+	// defaultAlertNoBlockFactor is the number of expected block intervals
+	// without a connected block after which an alert is raised, unless
+	// --alertnoblock is set.
+	defaultAlertNoBlockFactor = 30
+)
+
+// alertMonitorConfig houses the dependencies and thresholds of an alert
+// monitor.  A zero threshold disables the associated alert.
+type alertMonitorConfig struct {
+	// Alerts is the manager the alerts are raised with.
+	Alerts *alert.Manager
+
+	// Chain is the chain the monitor watches.
+	Chain *blockchain.BlockChain
+
+	// IndexTips returns the tips of the optional indexes.
+	IndexTips func() ([]indexers.IndexStatus, error)
+
+	// MempoolCount returns the number of transactions in the memory pool.
+	MempoolCount func() int
+
+	// IsCurrent returns whether the node believes it is synced with its
+	// peers.  The conditions which are expected while syncing are not
+	// alerted about before.
+	IsCurrent func() bool
+
+	// NoBlock is the time without a connected block after which an alert
+	// is raised.
+	NoBlock time.Duration
+
+	// ReorgDepth is the number of disconnected blocks from which a
+	// reorganization is alerted about.
+	ReorgDepth uint32
+
+	// MempoolTxs is the number of memory pool transactions above which an
+	// alert is raised.
+	MempoolTxs int
+
+	// IndexLag is the number of blocks an optional index may be behind the
+	// main chain before an alert is raised.
+	IndexLag uint32
+}
+
+// alertMonitor watches the node for consensus and operational anomalies and
+// raises alerts about them.  Reorganizations and invalid blocks are reported
+// by the block manager as they happen, while the other conditions are checked
+// periodically.
+type alertMonitor struct {
+	cfg alertMonitorConfig
+
+	mtx           sync.Mutex
+	lastConnected time.Time
+	disconnected  uint32
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newAlertMonitor returns a new alert monitor using the passed config.
+func newAlertMonitor(cfg *alertMonitorConfig) *alertMonitor {
+	return &alertMonitor{
+		cfg:           *cfg,
+		lastConnected: time.Now(),
+		quit:          make(chan struct{}),
+	}
+}
+
+// Start begins sending alerts and checking for anomalies periodically.
+func (m *alertMonitor) Start() {
+	m.cfg.Alerts.Start()
+
+	m.wg.Add(1)
+	go m.checkHandler()
+}
+
+// Stop stops the periodic checks and sending alerts.
+func (m *alertMonitor) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+	m.cfg.Alerts.Stop()
+}
+
+// raise raises an alert of the passed kind about the passed subject at the
+// current height of the main chain.
+func (m *alertMonitor) raise(kind alert.Kind, subject, format string, args ...interface{}) {
+	m.cfg.Alerts.Raise(&alert.Alert{
+		Kind:    kind,
+		Subject: subject,
+		Message: fmt.Sprintf(format, args...),
+		Height:  int32(m.cfg.Chain.BestSnapshot().Height),
+	})
+}
+
+// BlockConnected notes the passed block was connected to the main chain, and
+// raises an alert when it ends a reorganization which disconnected at least
+// the configured number of blocks.
+//
+// This function is safe for concurrent access.
+func (m *alertMonitor) BlockConnected(block *provautil.Block) {
+	m.mtx.Lock()
+	disconnected := m.disconnected
+	m.disconnected = 0
+	m.lastConnected = time.Now()
+	m.mtx.Unlock()
+
+	if m.cfg.ReorgDepth == 0 || disconnected < m.cfg.ReorgDepth {
+		return
+	}
+	m.raise(alert.DeepReorg, "", "Reorganization disconnected %d "+
+		"blocks, connecting block %v at height %d", disconnected,
+		block.Hash(), block.Height())
+}
+
+// BlockDisconnected notes the passed block was disconnected from the main
+// chain.
+//
+// This function is safe for concurrent access.
+func (m *alertMonitor) BlockDisconnected(block *provautil.Block) {
+	m.mtx.Lock()
+	m.disconnected++
+	m.mtx.Unlock()
+}
+
+// BlockRejected raises an alert when the passed block, which was rejected for
+// the passed reason, is signed by a key of the current validate key set, since
+// validators are expected to only sign valid blocks.
+//
+// This function is safe for concurrent access.
+func (m *alertMonitor) BlockRejected(block *provautil.Block, err error) {
+	rerr, ok := err.(blockchain.RuleError)
+	if !ok || rerr.ErrorCode == blockchain.ErrDuplicateBlock {
+		return
+	}
+
+	header := &block.MsgBlock().Header
+	pubKey, perr := btcec.ParsePubKey(header.ValidatingPubKey[:],
+		btcec.S256())
+	if perr != nil {
+		return
+	}
+	validateKeySet := m.cfg.Chain.AdminKeySets()[btcec.ValidateKeySet]
+	if validateKeySet.Pos(pubKey) == -1 {
+		return
+	}
+	m.raise(alert.InvalidValidatorBlock, header.ValidatingPubKey.String(),
+		"Validate key %v signed invalid block %v at height %d: %v",
+		header.ValidatingPubKey, block.Hash(), header.Height, err)
+}
+
+// check checks for the anomalies which are not noticed through chain
+// notifications.
+func (m *alertMonitor) check() {
+	if !m.cfg.IsCurrent() {
+		return
+	}
+
+	if m.cfg.NoBlock > 0 {
+		m.mtx.Lock()
+		since := time.Since(m.lastConnected).Truncate(time.Second)
+		m.mtx.Unlock()
+		if since >= m.cfg.NoBlock {
+			m.raise(alert.NoBlock, "", "No block has been "+
+				"connected for %v", since)
+		}
+	}
+
+	if m.cfg.MempoolTxs > 0 {
+		if count := m.cfg.MempoolCount(); count > m.cfg.MempoolTxs {
+			m.raise(alert.MempoolOverflow, "", "The memory pool "+
+				"holds %d transactions, more than the maximum "+
+				"of %d", count, m.cfg.MempoolTxs)
+		}
+	}
+
+	if m.cfg.IndexLag > 0 {
+		statuses, err := m.cfg.IndexTips()
+		if err != nil {
+			alrtLog.Errorf("Unable to check the index tips for "+
+				"alerts: %v", err)
+			return
+		}
+		height := int32(m.cfg.Chain.BestSnapshot().Height)
+		for _, status := range statuses {
+			lag := height - status.Height
+			if lag <= int32(m.cfg.IndexLag) {
+				continue
+			}
+			reason := "catching up"
+			if status.Err != nil {
+				reason = status.Err.Error()
+			}
+			m.raise(alert.IndexBehind, status.Name, "The %s is %d "+
+				"blocks behind the main chain (%s)", status.Name,
+				lag, reason)
+		}
+	}
+}
+
+// checkHandler periodically checks for anomalies.  It must be run as a
+// goroutine.
+func (m *alertMonitor) checkHandler() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(alertCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// newAlertTargets returns the alert targets configured by --alertwebhook and
+// --alertcmd.
+func newAlertTargets(cfg *config) ([]alert.Target, error) {
+	var targets []alert.Target
+	for _, url := range cfg.AlertWebhooks {
+		targets = append(targets, alert.NewWebhookTarget(url,
+			alertTargetTimeout))
+	}
+	for _, command := range cfg.AlertCommands {
+		target, err := alert.NewCommandTarget(command,
+			alertTargetTimeout)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
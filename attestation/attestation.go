@@ -0,0 +1,153 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package attestation implements signed attestations of the state of a prova
+chain, which auditors collect from several validators to cross-check that they
+agree on it.
+
+An attestation states the best block of a node, the hash committing to its
+utxo set, the number and total amount of the unspent outputs, and the supply
+of the chain, along with the time it was made.  It is signed by the validate
+key of the node or a dedicated attestation key.  The signed fields are
+prefixed with a magic string, so an attestation can never be passed off as a
+block or any other message signed by the same key.
+*/
+package attestation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// maxSignatureSize is the maximum size of a DER encoded signature.
+const maxSignatureSize = 72
+
+// signingMagic is prepended to the signed fields of attestations.
+var signingMagic = []byte("Prova chain state attestation:\n")
+
+// Attestation is a signed statement of the state of a chain as of a block.
+type Attestation struct {
+	// Net identifies the network of the chain.
+	Net wire.BitcoinNet
+
+	// Height and BlockHash identify the best block of the node.
+	Height    uint32
+	BlockHash chainhash.Hash
+
+	// UtxoSetHash commits to every unspent output as of the block, see
+	// blockchain.UtxoSetStats.
+	UtxoSetHash chainhash.Hash
+
+	// UtxoCount is the number of unspent outputs, and UtxoAmount is their
+	// total amount in atoms.
+	UtxoCount  uint64
+	UtxoAmount uint64
+
+	// Supply is the supply of the chain in atoms as of the block.
+	Supply uint64
+
+	// Timestamp is the time the attestation was made (serialized as an
+	// int64).
+	Timestamp time.Time
+
+	// PubKey is the compressed public key of the key which signed the
+	// attestation, and Signature is its DER encoded signature.
+	PubKey    [btcec.PubKeyBytesLenCompressed]byte
+	Signature []byte
+}
+
+// writeSigned writes the fields of the attestation covered by its signature
+// to w.
+func (a *Attestation) writeSigned(w io.Writer) error {
+	fields := []interface{}{uint32(a.Net), a.Height, a.BlockHash,
+		a.UtxoSetHash, a.UtxoCount, a.UtxoAmount, a.Supply,
+		a.Timestamp.Unix(), a.PubKey}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SigningHash returns the hash of the attestation which is signed.
+func (a *Attestation) SigningHash() chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Write(signingMagic)
+	// Writing to a bytes.Buffer never fails.
+	_ = a.writeSigned(&buf)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Sign sets the public key of the attestation to the public key of the passed
+// signer and signs the attestation with it.
+func (a *Attestation) Sign(key btcec.Signer) error {
+	copy(a.PubKey[:], key.PubKey().SerializeCompressed())
+
+	hash := a.SigningHash()
+	signature, err := key.Sign(hash[:])
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(hash[:], key.PubKey()) {
+		return errors.New("signature does not verify against the " +
+			"public key of the signer")
+	}
+	a.Signature = signature.Serialize()
+	return nil
+}
+
+// Verify returns whether the attestation is signed by its public key.
+func (a *Attestation) Verify() bool {
+	pubKey, err := btcec.ParsePubKey(a.PubKey[:], btcec.S256())
+	if err != nil {
+		return false
+	}
+	sig, err := btcec.ParseDERSignature(a.Signature, btcec.S256())
+	if err != nil {
+		return false
+	}
+	hash := a.SigningHash()
+	return sig.Verify(hash[:], pubKey)
+}
+
+// Serialize encodes the attestation along with its signature to w.
+func (a *Attestation) Serialize(w io.Writer) error {
+	if err := a.writeSigned(w); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, a.Signature)
+}
+
+// Deserialize decodes an attestation encoded by Serialize from r into the
+// receiver.
+func (a *Attestation) Deserialize(r io.Reader) error {
+	var net uint32
+	var timestamp int64
+	fields := []interface{}{&net, &a.Height, &a.BlockHash, &a.UtxoSetHash,
+		&a.UtxoCount, &a.UtxoAmount, &a.Supply, &timestamp, &a.PubKey}
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	a.Net = wire.BitcoinNet(net)
+	a.Timestamp = time.Unix(timestamp, 0)
+
+	signature, err := wire.ReadVarBytes(r, 0, maxSignatureSize,
+		"signature")
+	if err != nil {
+		return err
+	}
+	a.Signature = signature
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAttestation ensures attestations survive serialization along with their
+// signature, and altering any signed field invalidates the signature.
+func TestAttestation(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	a := &Attestation{
+		Net:         wire.TestNet,
+		Height:      1234,
+		BlockHash:   chainhash.Hash{0x01},
+		UtxoSetHash: chainhash.Hash{0x02},
+		UtxoCount:   56,
+		UtxoAmount:  7800000,
+		Supply:      7800000,
+		Timestamp:   time.Unix(1500000000, 0),
+	}
+	if a.Verify() {
+		t.Fatalf("Verify: unsigned attestation verified")
+	}
+	if err := a.Sign(key); err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	if !bytes.Equal(a.PubKey[:], key.PubKey().SerializeCompressed()) {
+		t.Errorf("Sign: got public key %x, want %x", a.PubKey,
+			key.PubKey().SerializeCompressed())
+	}
+	if !a.Verify() {
+		t.Fatalf("Verify: signed attestation did not verify")
+	}
+
+	var buf bytes.Buffer
+	if err := a.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	var decoded Attestation
+	if err := decoded.Deserialize(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, a) {
+		t.Fatalf("Deserialize: got %+v, want %+v", decoded, a)
+	}
+	if !decoded.Verify() {
+		t.Fatalf("Verify: deserialized attestation did not verify")
+	}
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if err := decoded.Deserialize(bytes.NewReader(truncated)); err == nil {
+		t.Errorf("Deserialize: no error for a truncated attestation")
+	}
+
+	alterations := []struct {
+		name  string
+		alter func(a *Attestation)
+	}{
+		{"net", func(a *Attestation) { a.Net = wire.MainNet }},
+		{"height", func(a *Attestation) { a.Height++ }},
+		{"utxo set hash", func(a *Attestation) { a.UtxoSetHash[0] ^= 1 }},
+		{"supply", func(a *Attestation) { a.Supply++ }},
+		{"timestamp", func(a *Attestation) {
+			a.Timestamp = a.Timestamp.Add(time.Second)
+		}},
+		{"public key", func(a *Attestation) {
+			other, _ := btcec.NewPrivateKey(btcec.S256())
+			copy(a.PubKey[:], other.PubKey().SerializeCompressed())
+		}},
+	}
+	for _, test := range alterations {
+		altered := *a
+		test.alter(&altered)
+		if altered.Verify() {
+			t.Errorf("%s: altered attestation verified", test.name)
+		}
+	}
+}
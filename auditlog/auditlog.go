@@ -0,0 +1,370 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package auditlog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when the log is used after it was closed.
+	ErrClosed = errors.New("the audit log is closed")
+
+	// zeroHash is the previous hash of the first entry of a log.
+	zeroHash = strings.Repeat("0", sha256.Size*2)
+)
+
+// Kind identifies the kind of privileged operation an entry records.
+type Kind uint8
+
+// These constants define the kinds of operations which are recorded.
+const (
+	// AdminRPC indicates an RPC method requiring the admin permission was
+	// called.
+	AdminRPC Kind = iota + 1
+
+	// BlockSigning indicates a block was signed with a validate key of the
+	// node.
+	BlockSigning
+
+	// ConfigChange indicates the configuration of the node was changed.
+	ConfigChange
+
+	// ChainIntervention indicates an RPC method intervening in the view of
+	// the node on the chain, such as invalidateblock, was called.
+	ChainIntervention
+)
+
+// kindStrings is a map of kinds back to their names for pretty printing and
+// encoding.
+var kindStrings = map[Kind]string{
+	AdminRPC:          "adminrpc",
+	BlockSigning:      "blocksigning",
+	ConfigChange:      "configchange",
+	ChainIntervention: "chainintervention",
+}
+
+// String returns the Kind in human-readable form.
+func (k Kind) String() string {
+	if s, ok := kindStrings[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Kind (%d)", uint8(k))
+}
+
+// MarshalText encodes the Kind as its name.
+func (k Kind) MarshalText() ([]byte, error) {
+	s, ok := kindStrings[k]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %d", uint8(k))
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText decodes the Kind from its name.
+func (k *Kind) UnmarshalText(text []byte) error {
+	for kind, s := range kindStrings {
+		if s == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown kind %q", text)
+}
+
+// Entry is a privileged operation recorded in the log.
+type Entry struct {
+	Seq  uint64 `json:"seq"`
+	Time int64  `json:"time"`
+	Kind Kind   `json:"kind"`
+
+	// Action names the operation, such as the RPC method called.
+	Action string `json:"action"`
+
+	// Details describe the operation, such as the user who called the RPC
+	// method and the parameters passed.
+	Details map[string]string `json:"details,omitempty"`
+
+	PrevHash string `json:"prevhash"`
+	Hash     string `json:"hash"`
+}
+
+// computeHash returns the hash of the entry, which is the hex encoded SHA-256
+// of its JSON encoding with the hash set to the empty string.
+func (e *Entry) computeHash() (string, error) {
+	unhashed := *e
+	unhashed.Hash = ""
+	encoded, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyError describes the first entry of a log which breaks its hash chain.
+type VerifyError struct {
+	// Seq is the sequence number the invalid entry was expected to have.
+	Seq uint64
+
+	// Reason describes how the entry is invalid.
+	Reason string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("entry %d: %s", e.Seq, e.Reason)
+}
+
+// scanState is the state of a log after its entries were scanned.
+type scanState struct {
+	seq      uint64
+	lastHash string
+
+	// size is the size in bytes of the complete lines scanned.
+	size int64
+
+	// torn is whether a partial line follows the complete lines.
+	torn bool
+}
+
+// scan reads the entries from the passed reader, verifying the hash chain, and
+// passes each of them to the passed function until it returns false.
+func scan(r io.Reader, visit func(*Entry) bool) (*scanState, error) {
+	state := &scanState{lastHash: zeroHash}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			state.torn = len(line) > 0
+			return state, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		next := state.seq + 1
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, &VerifyError{Seq: next, Reason: fmt.Sprintf(
+				"malformed entry: %v", err)}
+		}
+		if e.Seq != next {
+			return nil, &VerifyError{Seq: next, Reason: fmt.Sprintf(
+				"unexpected sequence number %d", e.Seq)}
+		}
+		if e.PrevHash != state.lastHash {
+			return nil, &VerifyError{Seq: next, Reason: fmt.Sprintf(
+				"previous hash %s does not match the hash %s of "+
+					"the previous entry", e.PrevHash,
+				state.lastHash)}
+		}
+		hash, err := e.computeHash()
+		if err != nil {
+			return nil, &VerifyError{Seq: next, Reason: err.Error()}
+		}
+		if e.Hash != hash {
+			return nil, &VerifyError{Seq: next, Reason: fmt.Sprintf(
+				"hash %s does not match the computed hash %s",
+				e.Hash, hash)}
+		}
+
+		state.seq = e.Seq
+		state.lastHash = e.Hash
+		state.size += int64(len(line))
+		if visit != nil && !visit(&e) {
+			return state, nil
+		}
+	}
+}
+
+// Config houses the parameters of a log.
+type Config struct {
+	// Path is the path of the log file, which is created when it does not
+	// exist.
+	Path string
+
+	// Now returns the current time.  It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Log is an append-only, hash-chained log of privileged operations.
+type Log struct {
+	now func() time.Time
+
+	mtx      sync.Mutex
+	file     *os.File
+	seq      uint64
+	lastHash string
+	size     int64
+}
+
+// New opens the log file of the passed config and verifies its hash chain.
+// An entry which was only partially written is discarded, while any other
+// invalid entry results in an error.
+func New(cfg *Config) (*Log, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_APPEND|os.O_CREATE,
+		0600)
+	if err != nil {
+		return nil, err
+	}
+	state, err := scan(file, nil)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit log %s is invalid: %v", cfg.Path,
+			err)
+	}
+	if state.torn {
+		log.Warnf("Discarding the partially written last entry of audit "+
+			"log %s", cfg.Path)
+		if err := file.Truncate(state.size); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	l := &Log{
+		now:      cfg.Now,
+		file:     file,
+		seq:      state.seq,
+		lastHash: state.lastHash,
+		size:     state.size,
+	}
+	if l.now == nil {
+		l.now = time.Now
+	}
+	log.Infof("Opened audit log %s with %d entries", cfg.Path, state.seq)
+	return l, nil
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Record appends an entry for the passed operation to the log and syncs it to
+// disk.
+//
+// This function is safe for concurrent access.
+func (l *Log) Record(kind Kind, action string, details map[string]string) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.file == nil {
+		return ErrClosed
+	}
+	e := Entry{
+		Seq:      l.seq + 1,
+		Time:     l.now().Unix(),
+		Kind:     kind,
+		Action:   action,
+		Details:  details,
+		PrevHash: l.lastHash,
+	}
+	hash, err := e.computeHash()
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := l.file.Write(line); err != nil {
+		// Discard what was written of the entry so the next one
+		// extends the chain.
+		l.file.Truncate(l.size)
+		return err
+	}
+	l.seq = e.Seq
+	l.lastHash = e.Hash
+	l.size += int64(len(line))
+	return l.file.Sync()
+}
+
+// Last returns the sequence number and the hash of the last entry, which are
+// zero and 64 zeros when the log is empty.
+//
+// This function is safe for concurrent access.
+func (l *Log) Last() (uint64, string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.seq, l.lastHash
+}
+
+// read scans the entries recorded in the log file, verifying the hash chain.
+// The log must be locked.
+func (l *Log) read(visit func(*Entry) bool) (*scanState, error) {
+	if l.file == nil {
+		return nil, ErrClosed
+	}
+	return scan(io.NewSectionReader(l.file, 0, l.size), visit)
+}
+
+// Entries returns at most count entries starting from the one with the passed
+// sequence number.  The entries before them are verified as well, so an error
+// is returned when the hash chain is broken before the last entry returned.
+//
+// This function is safe for concurrent access.
+func (l *Log) Entries(start uint64, count int) ([]Entry, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	var entries []Entry
+	if count <= 0 {
+		return entries, nil
+	}
+	_, err := l.read(func(e *Entry) bool {
+		if e.Seq >= start {
+			entries = append(entries, *e)
+		}
+		return len(entries) < count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Verify verifies the hash chain of the entries in the log file and that it
+// still ends with the last entry recorded, and returns the number of entries.
+// A *VerifyError describing the first invalid entry is returned when the log
+// was tampered with.
+//
+// This function is safe for concurrent access.
+func (l *Log) Verify() (uint64, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	state, err := l.read(nil)
+	if err != nil {
+		return 0, err
+	}
+	if state.torn || state.seq != l.seq || state.lastHash != l.lastHash {
+		return state.seq, &VerifyError{Seq: state.seq + 1, Reason: fmt.Sprintf(
+			"the log ends before the last entry %d recorded", l.seq)}
+	}
+	return state.seq, nil
+}
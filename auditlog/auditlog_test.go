@@ -0,0 +1,160 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package auditlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLog ensures entries are numbered and chained across reopening the log,
+// are exported from a sequence number, and partially written entries are
+// discarded.
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	now := time.Unix(1500000000, 0)
+	cfg := &Config{Path: path, Now: func() time.Time { return now }}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if seq, hash := l.Last(); seq != 0 || hash != zeroHash {
+		t.Errorf("Last: got %d %s for an empty log", seq, hash)
+	}
+	err = l.Record(AdminRPC, "setgenerate", map[string]string{
+		"user": "admin",
+	})
+	if err != nil {
+		t.Fatalf("Record: unexpected error: %v", err)
+	}
+	if err := l.Record(ConfigChange, "reload", nil); err != nil {
+		t.Fatalf("Record: unexpected error: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	// A partially written entry is discarded when the log is reopened.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: unexpected error: %v", err)
+	}
+	f.Write([]byte(`{"seq":3,"ti`))
+	f.Close()
+	l, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	defer l.Close()
+	err = l.Record(BlockSigning, "signblock", map[string]string{
+		"height": "7",
+	})
+	if err != nil {
+		t.Fatalf("Record: unexpected error: %v", err)
+	}
+	if n, err := l.Verify(); err != nil || n != 3 {
+		t.Fatalf("Verify: got %d entries, error %v, want 3 entries",
+			n, err)
+	}
+
+	entries, err := l.Entries(2, 10)
+	if err != nil {
+		t.Fatalf("Entries: unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Entries: got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Seq != 2 || e.Kind != ConfigChange ||
+		e.Action != "reload" || e.Time != now.Unix() {
+
+		t.Errorf("Entries: unexpected entry %+v", e)
+	}
+	if e := entries[1]; e.Seq != 3 || e.Kind != BlockSigning ||
+		e.PrevHash != entries[0].Hash || e.Details["height"] != "7" {
+
+		t.Errorf("Entries: unexpected entry %+v", e)
+	}
+	if seq, hash := l.Last(); seq != 3 || hash != entries[1].Hash {
+		t.Errorf("Last: got %d %s, want 3 %s", seq, hash,
+			entries[1].Hash)
+	}
+	if entries, _ := l.Entries(1, 1); len(entries) != 1 ||
+		entries[0].Action != "setgenerate" {
+
+		t.Errorf("Entries: got %+v, want the first entry", entries)
+	}
+}
+
+// TestTamper ensures altered and removed entries break the hash chain.
+func TestTamper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		err := l.Record(AdminRPC, "stop", map[string]string{
+			"user": user,
+		})
+		if err != nil {
+			t.Fatalf("Record: unexpected error: %v", err)
+		}
+	}
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	lines := bytes.SplitAfter(original, []byte("\n"))
+
+	tests := []struct {
+		name     string
+		contents []byte
+		seq      uint64
+	}{
+		{"altered", bytes.Replace(original, []byte("bob"),
+			[]byte("eve"), 1), 2},
+		{"removed", append(append([]byte(nil), lines[0]...),
+			lines[2]...), 2},
+		{"truncated", append(append([]byte(nil), lines[0]...),
+			lines[1]...), 3},
+	}
+	for _, test := range tests {
+		err := ioutil.WriteFile(path, test.contents, 0600)
+		if err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+		_, err = l.Verify()
+		verr, ok := err.(*VerifyError)
+		if !ok || verr.Seq != test.seq {
+			t.Errorf("%s: got error %v, want invalid entry %d",
+				test.name, err, test.seq)
+		}
+
+		// Tampered logs are not extended.
+		if test.name == "truncated" {
+			continue
+		}
+		if _, err := New(&Config{Path: path}); err == nil {
+			t.Errorf("%s: New: no error", test.name)
+		}
+	}
+	l.Close()
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package auditlog provides an append-only, hash-chained log of the privileged
+operations performed on a node, which regulated operators keep as evidence.
+
+Overview
+
+The node records every call of an RPC method requiring the admin permission,
+every block it signs with one of its validate keys, every change of its
+configuration and every manual intervention in its view of the chain.  Each
+entry is assigned a sequence number which is one higher than that of the
+previous entry, also across restarts of the node, and is written to the log
+file and synced to disk before the operation proceeds.
+
+Hash Chain
+
+The log file holds one JSON object per line.  The hash of an entry is the hex
+encoded SHA-256 of its line with the hash set to the empty string, and every
+entry holds the hash of the previous entry, or 64 zeros for the first entry.
+Removing, reordering or altering an entry therefore breaks the chain at that
+entry, which is detected by verifying the log.  An entry which was only
+partially written when the node stopped is discarded when the log is opened,
+while any other damage makes the log fail to open, so it is never extended
+past an invalid entry.
+
+Exporting the hash of the last entry to a separate system, for instance with
+every export of the log, also makes the truncation of the log detectable.
+*/
+package auditlog
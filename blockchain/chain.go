@@ -7,6 +7,7 @@ package blockchain
 
 import (
 	"container/list"
+	"context"
 	"fmt"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
@@ -22,9 +23,13 @@ import (
 )
 
 const (
-	// maxOrphanBlocks is the maximum number of orphan blocks that can be
-	// queued.
+	// maxOrphanBlocks is the default maximum number of orphan blocks that
+	// can be queued.
 	maxOrphanBlocks = 1000
+
+	// defaultOrphanExpiry is the default time an orphan block is kept for
+	// while its parent is unknown.
+	defaultOrphanExpiry = time.Hour
 )
 
 // blockNode represents a block within the block chain and is primarily used to
@@ -124,6 +129,7 @@ func (node *blockNode) Header() wire.BlockHeader {
 // forever.
 type orphanBlock struct {
 	block      *provautil.Block
+	received   time.Time
 	expiration time.Time
 }
 
@@ -225,6 +231,8 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	hashCache           *txscript.HashCache
 	indexManager        IndexManager
+	forcedDeployments   [numDeployments]bool
+	scriptConsistency   bool
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -269,6 +277,9 @@ type BlockChain struct {
 	adminKeySets map[btcec.KeySetType]btcec.PublicKeySet
 	// a mapping of all keyIDs and related ASP public keys.
 	aspKeyIdMap btcec.KeyIdMap
+	// a mapping of frozen keyIDs and the ASP public keys they referred to
+	// when they were frozen.
+	frozenKeyIDs btcec.KeyIdMap
 
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
@@ -276,6 +287,8 @@ type BlockChain struct {
 	orphans      map[chainhash.Hash]*orphanBlock
 	prevOrphans  map[chainhash.Hash][]*orphanBlock
 	oldestOrphan *orphanBlock
+	maxOrphans   int
+	orphanExpiry time.Duration
 
 	// These fields are related to checkpoint handling.  They are protected
 	// by the chain lock.
@@ -372,6 +385,65 @@ func (b *BlockChain) GetOrphanRoot(hash *chainhash.Hash) *chainhash.Hash {
 	return orphanRoot
 }
 
+// OrphanBlockInfo describes a block in the orphan pool.
+type OrphanBlockInfo struct {
+	Hash     chainhash.Hash
+	PrevHash chainhash.Hash
+	Height   uint32
+	Size     int
+
+	// Root is the hash of the first block of the chain of orphans the
+	// block is part of, and Missing the hash of its parent, which is the
+	// missing ancestor.
+	Root    chainhash.Hash
+	Missing chainhash.Hash
+
+	// Received and Expiration are the times the block was added to the
+	// pool and is dropped from it unless its parent arrives first.
+	Received   time.Time
+	Expiration time.Time
+}
+
+// OrphanBlocks returns the blocks in the orphan pool which did not expire yet,
+// in the order they were received.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) OrphanBlocks() []OrphanBlockInfo {
+	b.orphanLock.RLock()
+	defer b.orphanLock.RUnlock()
+
+	now := time.Now()
+	infos := make([]OrphanBlockInfo, 0, len(b.orphans))
+	for hash, orphan := range b.orphans {
+		if now.After(orphan.expiration) {
+			continue
+		}
+		header := &orphan.block.MsgBlock().Header
+		root, missing := hash, header.PrevBlock
+		for {
+			parent, exists := b.orphans[missing]
+			if !exists {
+				break
+			}
+			root, missing = missing, parent.block.MsgBlock().Header.PrevBlock
+		}
+		infos = append(infos, OrphanBlockInfo{
+			Hash:       hash,
+			PrevHash:   header.PrevBlock,
+			Height:     header.Height,
+			Size:       orphan.block.MsgBlock().SerializeSize(),
+			Root:       root,
+			Missing:    missing,
+			Received:   orphan.received,
+			Expiration: orphan.expiration,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Received.Before(infos[j].Received)
+	})
+	return infos
+}
+
 // removeOrphanBlock removes the passed orphan block from the orphan pool and
 // previous orphan index.
 func (b *BlockChain) removeOrphanBlock(orphan *orphanBlock) {
@@ -410,7 +482,7 @@ func (b *BlockChain) removeOrphanBlock(orphan *orphanBlock) {
 // addOrphanBlock adds the passed block (which is already determined to be
 // an orphan prior calling this function) to the orphan pool.  It lazily cleans
 // up any expired blocks so a separate cleanup poller doesn't need to be run.
-// It also imposes a maximum limit on the number of outstanding orphan
+// It also imposes the configured limit on the number of outstanding orphan
 // blocks and will remove the oldest received orphan block if the limit is
 // exceeded.
 func (b *BlockChain) addOrphanBlock(block *provautil.Block) {
@@ -429,7 +501,7 @@ func (b *BlockChain) addOrphanBlock(block *provautil.Block) {
 	}
 
 	// Limit orphan blocks to prevent memory exhaustion.
-	if len(b.orphans)+1 > maxOrphanBlocks {
+	if len(b.orphans)+1 > b.maxOrphans {
 		// Remove the oldest orphan to make room for the new one.
 		b.removeOrphanBlock(b.oldestOrphan)
 		b.oldestOrphan = nil
@@ -441,12 +513,13 @@ func (b *BlockChain) addOrphanBlock(block *provautil.Block) {
 	b.orphanLock.Lock()
 	defer b.orphanLock.Unlock()
 
-	// Insert the block into the orphan map with an expiration time
-	// 1 hour from now.
-	expiration := time.Now().Add(time.Hour)
+	// Insert the block into the orphan map with the configured expiration
+	// time.
+	now := time.Now()
 	oBlock := &orphanBlock{
 		block:      block,
-		expiration: expiration,
+		received:   now,
+		expiration: now.Add(b.orphanExpiry),
 	}
 	b.orphans[*block.Hash()] = oBlock
 
@@ -996,6 +1069,10 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 		if err != nil {
 			return err
 		}
+		err = dbPutFrozenKeyIDs(dbTx, keyView.FrozenKeyIDs())
+		if err != nil {
+			return err
+		}
 
 		// Update the transaction spend journal by adding a record for
 		// the block that contains all txos spent by it.
@@ -1040,6 +1117,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 	b.lastKeyID = keyView.LastKeyID()
 	b.adminKeySets = keyView.Keys()
 	b.aspKeyIdMap = keyView.KeyIDs()
+	b.frozenKeyIDs = keyView.FrozenKeyIDs()
 	b.stateLock.Unlock()
 
 	// Update the state for the best block.  Notice how this replaces the
@@ -1122,6 +1200,10 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *provautil.Block, ut
 		if err != nil {
 			return err
 		}
+		err = dbPutFrozenKeyIDs(dbTx, keyView.FrozenKeyIDs())
+		if err != nil {
+			return err
+		}
 
 		// Remove the block hash and height from the block index which
 		// tracks the main chain.
@@ -1237,7 +1319,9 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 	keyView.SetLastKeyID(b.lastKeyID)
 	keyView.SetTotalSupply(b.totalSupply)
 	keyView.SetKeys(b.adminKeySets)
+	keyView.SetAdminThresholds(b.chainParams.AdminThresholds)
 	keyView.SetKeyIDs(b.aspKeyIdMap)
+	keyView.SetFrozenKeyIDs(b.frozenKeyIDs)
 	for e := detachNodes.Front(); e != nil; e = e.Next() {
 		n := e.Value.(*blockNode)
 		var block *provautil.Block
@@ -1324,7 +1408,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 		// thus will not be generated.  This is done because the state
 		// is not being immediately written to the database, so it is
 		// not needed.
-		err = b.checkConnectBlock(n, block, utxoView, keyView, nil)
+		err = b.checkConnectBlock(context.Background(), n, block, utxoView, keyView, nil)
 		if err != nil {
 			return err
 		}
@@ -1455,10 +1539,12 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *provautil.Block, f
 		keyView.SetLastKeyID(b.lastKeyID)
 		keyView.SetTotalSupply(b.totalSupply)
 		keyView.SetKeys(b.adminKeySets)
+		keyView.SetAdminThresholds(b.chainParams.AdminThresholds)
 		keyView.SetKeyIDs(b.aspKeyIdMap)
+		keyView.SetFrozenKeyIDs(b.frozenKeyIDs)
 		stxos := make([]spentTxOut, 0, countSpentOutputs(block))
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, utxoView, keyView, &stxos)
+			err := b.checkConnectBlock(context.Background(), node, block, utxoView, keyView, &stxos)
 			if err != nil {
 				return false, err
 			}
@@ -1678,6 +1764,19 @@ func (b *BlockChain) KeyIDs() btcec.KeyIdMap {
 	return aspKeyIdMap
 }
 
+// FrozenKeyIDs returns the frozen keyIDs of the best chain mapped to the ASP
+// keys they referred to when they were frozen.
+// The returned instance must be treated as immutable since it is shared by all
+// callers.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FrozenKeyIDs() btcec.KeyIdMap {
+	b.stateLock.RLock()
+	frozenKeyIDs := b.frozenKeyIDs
+	b.stateLock.RUnlock()
+	return frozenKeyIDs
+}
+
 // IndexManager provides a generic interface that the is called when blocks are
 // connected and disconnected to and from the tip of the main chain for the
 // purpose of supporting optional indexes.
@@ -1759,6 +1858,33 @@ type Config struct {
 	// This field can be nil if the caller does not wish to make use of an
 	// index manager.
 	IndexManager IndexManager
+
+	// ForcedDeployments lists consensus rule changes which are treated as
+	// active for every block regardless of the block versions.  It is
+	// intended for rehearsing upgrades against historical chains and must
+	// not be set for nodes which participate in the network.
+	//
+	// This field can be nil.
+	ForcedDeployments []Deployment
+
+	// ScriptConsistency enables cross-checking the script engine while
+	// connecting blocks.  Every script is additionally validated with a
+	// simplified reference interpreter, and a ScriptDivergenceError is
+	// returned instead of connecting the block when the two disagree.
+	ScriptConsistency bool
+
+	// MaxOrphanBlocks is the maximum number of orphan blocks kept while
+	// their parents are unknown.  The oldest orphan is dropped to make
+	// room for a new one beyond it.
+	//
+	// This field can be zero to keep up to 1000 orphan blocks.
+	MaxOrphanBlocks int
+
+	// OrphanExpiry is the time an orphan block is kept for while its
+	// parent is unknown.
+	//
+	// This field can be zero to keep orphan blocks for an hour.
+	OrphanExpiry time.Duration
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1776,13 +1902,15 @@ func New(config *Config) (*BlockChain, error) {
 
 	// Generate a checkpoint by height map from the provided checkpoints
 	// and assert the provided checkpoints are sorted by height as required.
+	// Only the first checkpoint may be at the genesis block, as is the
+	// case for networks created by chaincfg.NewNetwork.
 	var checkpointsByHeight map[uint32]*chaincfg.Checkpoint
 	var prevCheckpointHeight uint32
 	if len(config.Checkpoints) > 0 {
 		checkpointsByHeight = make(map[uint32]*chaincfg.Checkpoint)
 		for i := range config.Checkpoints {
 			checkpoint := &config.Checkpoints[i]
-			if checkpoint.Height <= prevCheckpointHeight {
+			if i > 0 && checkpoint.Height <= prevCheckpointHeight {
 				return nil, AssertError("blockchain.New " +
 					"checkpoints are not sorted by height")
 			}
@@ -1801,6 +1929,7 @@ func New(config *Config) (*BlockChain, error) {
 		sigCache:            config.SigCache,
 		hashCache:           config.HashCache,
 		indexManager:        config.IndexManager,
+		scriptConsistency:   config.ScriptConsistency,
 		blocksPerRetarget:   int32(config.ChainParams.PowAveragingWindow),
 		minMemoryNodes:      int32(config.ChainParams.PowAveragingWindow),
 		bestNode:            nil,
@@ -1809,10 +1938,26 @@ func New(config *Config) (*BlockChain, error) {
 		totalSupply:         uint64(0),
 		adminKeySets:        make(map[btcec.KeySetType]btcec.PublicKeySet),
 		aspKeyIdMap:         make(map[btcec.KeyID]*btcec.PublicKey),
+		frozenKeyIDs:        make(map[btcec.KeyID]*btcec.PublicKey),
 		index:               make(map[chainhash.Hash]*blockNode),
 		depNodes:            make(map[chainhash.Hash][]*blockNode),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
+		maxOrphans:          config.MaxOrphanBlocks,
+		orphanExpiry:        config.OrphanExpiry,
+	}
+	if b.maxOrphans <= 0 {
+		b.maxOrphans = maxOrphanBlocks
+	}
+	if b.orphanExpiry <= 0 {
+		b.orphanExpiry = defaultOrphanExpiry
+	}
+	for _, d := range config.ForcedDeployments {
+		if d >= numDeployments {
+			return nil, AssertError(fmt.Sprintf("blockchain.New "+
+				"unknown forced deployment %v", d))
+		}
+		b.forcedDeployments[d] = true
 	}
 
 	// Initialize the chain state from the passed database.  When the db
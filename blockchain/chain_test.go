@@ -9,7 +9,10 @@ import (
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/provautil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -107,3 +110,42 @@ func TestHaveBlock(t *testing.T) {
 		}
 	}
 }
+
+// TestBackupChainState ensures a backup of the chain state can be opened as a
+// database of its own and has the best block of the chain.
+func TestBackupChainState(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("backupchainstate",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	backupPath := filepath.Join(os.TempDir(), "backupchainstate-backup")
+	_ = os.RemoveAll(backupPath)
+	defer os.RemoveAll(backupPath)
+	hash, height, err := chain.BackupChainState(backupPath)
+	if err != nil {
+		t.Fatalf("BackupChainState: unexpected error: %v", err)
+	}
+	best := chain.BestSnapshot()
+	if *hash != *best.Hash || height != best.Height {
+		t.Fatalf("BackupChainState: got best block %v (height %d), "+
+			"want %v (height %d)", hash, height, best.Hash,
+			best.Height)
+	}
+
+	db, err := database.Open(testDbType, backupPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer db.Close()
+	err = db.View(func(dbTx database.Tx) error {
+		_, err := dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		t.Errorf("FetchBlock: unexpected error for the best block: %v",
+			err)
+	}
+}
@@ -44,6 +44,16 @@ var (
 	// admin key sets.
 	keySetBucketName = []byte("keyset")
 
+	// frozenKeyIDsKeyName is the name of the db key used to store the
+	// frozen keyIDs.  Chain states which never froze a keyID may not have
+	// it.
+	frozenKeyIDsKeyName = []byte("frozenkeyids")
+
+	// reindexTipKeyName is the name of the db key used to house the hash of
+	// the block the chain state is being rebuilt up to while a chain state
+	// reindex is in progress.
+	reindexTipKeyName = []byte("reindextip")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -1148,6 +1158,86 @@ func deserializeKeySet(serializedData []byte) (
 	return adminKeys, aspKeyIdMap, threadTips, lastKeyID, totalSupply, nil
 }
 
+// serializeFrozenKeyIDs returns the serialization of the passed frozen keyIDs,
+// which is their number followed by each keyID and the ASP key it referred to
+// when it was frozen, in keyID order.
+func serializeFrozenKeyIDs(frozenKeyIDs btcec.KeyIdMap) []byte {
+	serializedData := make([]byte, 4+len(frozenKeyIDs)*
+		(btcec.KeyIDSize+btcec.PubKeyBytesLenCompressed))
+	byteOrder.PutUint32(serializedData, uint32(len(frozenKeyIDs)))
+	offset := 4
+
+	// Serialize in keyID order, so the serialization is deterministic.
+	var keyIDs []int
+	for k := range frozenKeyIDs {
+		keyIDs = append(keyIDs, int(k))
+	}
+	sort.Ints(keyIDs)
+	for _, keyID := range keyIDs {
+		pubKey := frozenKeyIDs[btcec.KeyID(keyID)]
+		byteOrder.PutUint32(serializedData[offset:], uint32(keyID))
+		offset += btcec.KeyIDSize
+		copy(serializedData[offset:], pubKey.SerializeCompressed())
+		offset += btcec.PubKeyBytesLenCompressed
+	}
+	return serializedData
+}
+
+// deserializeFrozenKeyIDs deserializes the passed serialized frozen keyIDs.
+func deserializeFrozenKeyIDs(serializedData []byte) (btcec.KeyIdMap, error) {
+	if len(serializedData) < 4 {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt frozen keyIDs, no keyIDs can be read",
+		}
+	}
+	numKeyIDs := byteOrder.Uint32(serializedData)
+	offset := 4
+	if uint32(len(serializedData[offset:])) != numKeyIDs*
+		(btcec.KeyIDSize+btcec.PubKeyBytesLenCompressed) {
+
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt frozen keyIDs, not all keyIDs can be read",
+		}
+	}
+	frozenKeyIDs := make(map[btcec.KeyID]*btcec.PublicKey)
+	for i := 0; i < int(numKeyIDs); i++ {
+		keyID := btcec.KeyID(byteOrder.Uint32(serializedData[offset:]))
+		offset += btcec.KeyIDSize
+		pubKey, err := btcec.ParsePubKey(
+			serializedData[offset:offset+btcec.PubKeyBytesLenCompressed], btcec.S256())
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt frozen keyIDs, "+
+					"invalid key of keyID %v: %v", keyID, err),
+			}
+		}
+		offset += btcec.PubKeyBytesLenCompressed
+		frozenKeyIDs[keyID] = pubKey
+	}
+	return frozenKeyIDs, nil
+}
+
+// dbPutFrozenKeyIDs uses an existing database transaction to update the frozen
+// keyIDs of the admin chain state.
+func dbPutFrozenKeyIDs(dbTx database.Tx, frozenKeyIDs btcec.KeyIdMap) error {
+	serializedData := serializeFrozenKeyIDs(frozenKeyIDs)
+	return dbTx.Metadata().Put(frozenKeyIDsKeyName, serializedData)
+}
+
+// dbFetchFrozenKeyIDs uses an existing database transaction to fetch the
+// frozen keyIDs of the admin chain state.  No keyIDs are frozen when none were
+// ever stored.
+func dbFetchFrozenKeyIDs(dbTx database.Tx) (btcec.KeyIdMap, error) {
+	serializedData := dbTx.Metadata().Get(frozenKeyIDsKeyName)
+	if serializedData == nil {
+		return make(map[btcec.KeyID]*btcec.PublicKey), nil
+	}
+	return deserializeFrozenKeyIDs(serializedData)
+}
+
 // dbPutKeySet uses an existing database transaction to update the admin chain
 // state with the given parameters.
 func dbPutKeySet(dbTx database.Tx,
@@ -1288,9 +1378,6 @@ func (b *BlockChain) createChainState() error {
 	b.stateSnapshot = newBestState(b.bestNode, blockSize, numTxns, numTxns,
 		time.Unix(b.bestNode.timestamp, 0))
 
-	b.adminKeySets = b.chainParams.AdminKeySets
-	b.aspKeyIdMap = b.chainParams.ASPKeyIdMap
-
 	// Initiate the utxo set with the admin thread tips from the genesis
 	// coinbase.
 	// !!! NOTICE:
@@ -1301,19 +1388,14 @@ func (b *BlockChain) createChainState() error {
 	var stxos *[]spentTxOut
 	utxoView.connectTransaction(genesisBlock.Transactions()[0], 0, stxos)
 
-	// Initiate admin thread tips
-	b.threadTips[provautil.RootThread] = wire.NewOutPoint(genesisBlock.Transactions()[0].Hash(), 0)
-	b.threadTips[provautil.ProvisionThread] = wire.NewOutPoint(genesisBlock.Transactions()[0].Hash(), 1)
-	b.threadTips[provautil.IssueThread] = wire.NewOutPoint(genesisBlock.Transactions()[0].Hash(), 2)
-
-	// Set the last key id to the highest key id in the wsp key map.
-	var lastKeyID btcec.KeyID
-	for keyID := range b.aspKeyIdMap {
-		if keyID > lastKeyID {
-			lastKeyID = keyID
-		}
-	}
-	b.lastKeyID = btcec.KeyID(lastKeyID)
+	// Initiate the admin state including the admin thread tips and the
+	// last key id from the genesis block.
+	keyView := b.genesisKeyView(genesisBlock)
+	b.threadTips = keyView.ThreadTips()
+	b.lastKeyID = keyView.LastKeyID()
+	b.adminKeySets = keyView.Keys()
+	b.aspKeyIdMap = keyView.KeyIDs()
+	b.frozenKeyIDs = keyView.FrozenKeyIDs()
 
 	// Create the initial the database chain state including creating the
 	// necessary index buckets and inserting the genesis block.
@@ -1368,9 +1450,14 @@ func (b *BlockChain) createChainState() error {
 		if err != nil {
 			return err
 		}
+		err = dbPutFrozenKeyIDs(dbTx, b.frozenKeyIDs)
+		if err != nil {
+			return err
+		}
 
-		// Store the genesis block into the database.
-		return dbTx.StoreBlock(genesisBlock)
+		// Store the genesis block into the database unless the chain
+		// state is being rebuilt from the blocks already stored in it.
+		return dbMaybeStoreBlock(dbTx, genesisBlock)
 	})
 	return err
 }
@@ -1407,6 +1494,10 @@ func (b *BlockChain) initChainState() error {
 		if err != nil {
 			return err
 		}
+		frozenKeyIDs, err := dbFetchFrozenKeyIDs(dbTx)
+		if err != nil {
+			return err
+		}
 
 		// Load the raw block bytes for the best block.
 		blockBytes, err := dbTx.FetchBlock(&state.hash)
@@ -1434,6 +1525,7 @@ func (b *BlockChain) initChainState() error {
 		b.totalSupply = totalSupply
 		b.adminKeySets = adminKeySets
 		b.aspKeyIdMap = aspKeyIdMap
+		b.frozenKeyIDs = frozenKeyIDs
 
 		// Add the new node to the indices for faster lookups.
 		prevHash := node.parentHash
@@ -1548,6 +1640,37 @@ func dbMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
 	return hashIndex.Get(hash[:]) != nil
 }
 
+// BackupChainState writes a consistent copy of the database of the chain,
+// including the blocks and all of the metadata such as the optional indexes, to
+// the passed directory, which must not exist yet, while the chain remains in
+// use.  It returns the hash and height of the best block of the main chain in
+// the copy.  The transactions of the database must implement the
+// database.Backuper interface.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BackupChainState(destPath string) (*chainhash.Hash, uint32, error) {
+	var state bestChainState
+	err := b.db.View(func(dbTx database.Tx) error {
+		backuper, ok := dbTx.(database.Backuper)
+		if !ok {
+			return fmt.Errorf("the %s database does not support "+
+				"backups", b.db.Type())
+		}
+
+		var err error
+		serializedData := dbTx.Metadata().Get(chainStateKeyName)
+		state, err = deserializeBestChainState(serializedData)
+		if err != nil {
+			return err
+		}
+		return backuper.Backup(destPath)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &state.hash, state.height, nil
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
@@ -1616,6 +1739,64 @@ func (b *BlockChain) BlockByHash(hash *chainhash.Hash) (*provautil.Block, error)
 	return block, err
 }
 
+// FetchSpentTxOuts returns the outputs spent by the transactions of the passed
+// block, excluding the coinbase, in the order of their inputs.  They are loaded
+// from the spend journal, which is only kept for blocks in the main chain, so
+// an error is returned for blocks which are not.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchSpentTxOuts(block *provautil.Block) ([]*wire.TxOut, error) {
+	// Grab a lock on the chain to prevent the spend journal entry from
+	// being removed due to a reorg while it is loaded.
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	blockTxns := block.MsgBlock().Transactions[1:]
+	var numStxos int
+	for _, tx := range blockTxns {
+		numStxos += len(tx.TxIn)
+	}
+
+	txOuts := make([]*wire.TxOut, numStxos)
+	err := b.db.View(func(dbTx database.Tx) error {
+		spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+		serialized := spendBucket.Get(block.Hash()[:])
+		if len(serialized) == 0 && numStxos != 0 {
+			return fmt.Errorf("no spend journal entry for block %v",
+				block.Hash())
+		}
+
+		// The entries are serialized in reverse order.  The version of
+		// the transaction creating a spent output is only serialized
+		// along with the spend of its final output, otherwise it would
+		// have to be loaded from the utxo set as of the block.  Since
+		// decompressing the outputs does not depend on the version,
+		// any version is passed for the entries without one.
+		var offset int
+		for i := numStxos - 1; i >= 0; i-- {
+			var stxo spentTxOut
+			n, err := decodeSpentTxOut(serialized[offset:], &stxo, 1)
+			offset += n
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt spend "+
+						"information for %v: %v",
+						block.Hash(), err),
+				}
+			}
+			amount := decompressTxOutAmount(uint64(stxo.amount))
+			pkScript := decompressScript(stxo.pkScript, stxo.version)
+			txOuts[i] = wire.NewTxOut(int64(amount), pkScript)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txOuts, nil
+}
+
 // HeightRange returns a range of block hashes for the given start and end
 // heights.  It is inclusive of the start height and exclusive of the end
 // height.  The end height will be limited to the current main chain height.
@@ -0,0 +1,457 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// ConsistencyReport details the result of checking the chain state against the
+// block data it was derived from.
+type ConsistencyReport struct {
+	// Hash and Height identify the end of the main chain at the time the
+	// checks were performed.
+	Hash   chainhash.Hash
+	Height uint32
+
+	// KeyViewBlocks is the number of blocks replayed in order to re-derive
+	// the admin key state.
+	KeyViewBlocks uint32
+
+	// UndoBlocks is the number of blocks at the end of the main chain whose
+	// spend journal entries were loaded and checked.
+	UndoBlocks uint32
+
+	// SampledOutputs is the number of spent outputs from the loaded spend
+	// journal entries which were verified against the transactions that
+	// created them.
+	SampledOutputs uint32
+
+	// Mismatches describes each inconsistency which was detected.
+	Mismatches []string
+}
+
+// Consistent returns whether or not the checks completed without detecting any
+// inconsistencies.
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Mismatches) == 0
+}
+
+// addMismatch records an inconsistency with the report.
+func (r *ConsistencyReport) addMismatch(format string, args ...interface{}) {
+	r.Mismatches = append(r.Mismatches, fmt.Sprintf(format, args...))
+}
+
+// isDbCorruptionErr returns whether or not the passed error is a database error
+// with the ErrCorruption error code.
+func isDbCorruptionErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrCorruption
+}
+
+// genesisKeyView returns a key view which represents the admin state right
+// after the passed genesis block has been connected.
+func (b *BlockChain) genesisKeyView(genesisBlock *provautil.Block) *KeyViewpoint {
+	keyView := NewKeyViewpoint()
+	keyView.SetKeys(b.chainParams.AdminKeySets)
+	keyView.SetAdminThresholds(b.chainParams.AdminThresholds)
+	keyView.SetKeyIDs(b.chainParams.ASPKeyIdMap)
+
+	// The admin thread tips are the outputs of the genesis coinbase.
+	coinbaseHash := genesisBlock.Transactions()[0].Hash()
+	keyView.threadTips[provautil.RootThread] = wire.NewOutPoint(coinbaseHash, 0)
+	keyView.threadTips[provautil.ProvisionThread] = wire.NewOutPoint(coinbaseHash, 1)
+	keyView.threadTips[provautil.IssueThread] = wire.NewOutPoint(coinbaseHash, 2)
+
+	// The last key id is the highest key id in the genesis asp key map.
+	for keyID := range keyView.aspKeyIdMap {
+		if keyID > keyView.lastKeyID {
+			keyView.lastKeyID = keyID
+		}
+	}
+	return keyView
+}
+
+// compareKeyViews records a mismatch with the report for each part of the
+// admin state which differs between the two passed key views.
+func compareKeyViews(report *ConsistencyReport, source string, want, got *KeyViewpoint) {
+	for _, threadID := range []provautil.ThreadID{provautil.RootThread,
+		provautil.ProvisionThread, provautil.IssueThread} {
+
+		wantTip, gotTip := want.threadTips[threadID], got.threadTips[threadID]
+		if wantTip == nil || gotTip == nil || *wantTip != *gotTip {
+			report.addMismatch("%s thread tip for thread %d is %v, "+
+				"expected %v", source, threadID, gotTip, wantTip)
+		}
+	}
+	if want.lastKeyID != got.lastKeyID {
+		report.addMismatch("%s last key id is %d, expected %d", source,
+			got.lastKeyID, want.lastKeyID)
+	}
+	if want.totalSupply != got.totalSupply {
+		report.addMismatch("%s total supply is %d, expected %d", source,
+			got.totalSupply, want.totalSupply)
+	}
+	for keySetType := btcec.RootKeySet; keySetType <= btcec.ValidateKeySet; keySetType++ {
+		if !want.adminKeySets[keySetType].Equal(got.adminKeySets[keySetType]) {
+			report.addMismatch("%s admin key set %v does not match",
+				source, keySetType)
+		}
+	}
+	if !want.aspKeyIdMap.Equal(got.aspKeyIdMap) {
+		report.addMismatch("%s asp key id map does not match", source)
+	}
+	if !want.frozenKeyIDs.Equal(got.frozenKeyIDs) {
+		report.addMismatch("%s frozen key ids do not match", source)
+	}
+}
+
+// sampledOutput is a spent output loaded from the spend journal along with the
+// details needed to verify it against the transaction that created it.
+type sampledOutput struct {
+	outPoint       wire.OutPoint
+	creationHeight uint32
+	spendHeight    uint32
+	stxo           spentTxOut
+}
+
+// checkCreatedOutputs verifies the utxo set entries for the transactions of the
+// passed main chain block against the transactions themselves.  Entries which
+// no longer exist were fully spent and are not checked.
+func checkCreatedOutputs(dbTx database.Tx, block *provautil.Block, report *ConsistencyReport) error {
+	for txIdx, tx := range block.Transactions() {
+		entry, err := dbFetchUtxoEntry(dbTx, tx.Hash())
+		if err != nil {
+			if isDbCorruptionErr(err) {
+				report.addMismatch("%v", err)
+				continue
+			}
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		if entry.BlockHeight() != block.Height() {
+			report.addMismatch("utxo entry for %v has height %d, "+
+				"expected %d", tx.Hash(), entry.BlockHeight(),
+				block.Height())
+		}
+		if entry.IsCoinBase() != (txIdx == 0) {
+			report.addMismatch("utxo entry for %v has coinbase flag "+
+				"%v, expected %v", tx.Hash(), entry.IsCoinBase(),
+				txIdx == 0)
+		}
+		for outIdx := range entry.sparseOutputs {
+			if int(outIdx) >= len(tx.MsgTx().TxOut) {
+				report.addMismatch("utxo entry for %v contains "+
+					"nonexistent output %d", tx.Hash(), outIdx)
+				continue
+			}
+			txOut := tx.MsgTx().TxOut[outIdx]
+			if entry.AmountByIndex(outIdx) != txOut.Value ||
+				!bytes.Equal(entry.PkScriptByIndex(outIdx), txOut.PkScript) {
+
+				report.addMismatch("utxo entry for %v:%d does not "+
+					"match the transaction output", tx.Hash(),
+					outIdx)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSampledOutput verifies a spent output loaded from the spend journal
+// against the transaction that created it.
+func checkSampledOutput(dbTx database.Tx, sample *sampledOutput, report *ConsistencyReport) error {
+	block, err := dbFetchBlockByHeight(dbTx, sample.creationHeight)
+	if err != nil {
+		return err
+	}
+	for _, tx := range block.Transactions() {
+		if !tx.Hash().IsEqual(&sample.outPoint.Hash) {
+			continue
+		}
+		if int(sample.outPoint.Index) >= len(tx.MsgTx().TxOut) {
+			break
+		}
+
+		amount := sample.stxo.amount
+		pkScript := sample.stxo.pkScript
+		if sample.stxo.compressed {
+			amount = int64(decompressTxOutAmount(uint64(amount)))
+			pkScript = decompressScript(pkScript, sample.stxo.version)
+		}
+		txOut := tx.MsgTx().TxOut[sample.outPoint.Index]
+		if amount != txOut.Value || !bytes.Equal(pkScript, txOut.PkScript) {
+			report.addMismatch("spend journal entry for %v spent at "+
+				"height %d does not match the transaction output",
+				sample.outPoint, sample.spendHeight)
+		}
+		return nil
+	}
+
+	report.addMismatch("spend journal entry for %v spent at height %d "+
+		"references an output not found at height %d", sample.outPoint,
+		sample.spendHeight, sample.creationHeight)
+	return nil
+}
+
+// checkUndoData loads the spend journal entries of up to undoDepth blocks at
+// the end of the main chain, disconnecting each block from a temporary view so
+// the entries can be decoded, and verifies up to numSamples randomly chosen
+// spent outputs against the transactions that created them.  The utxo set
+// entries for the transactions of every loaded block are checked as well.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkUndoData(ctx context.Context, undoDepth, numSamples int, report *ConsistencyReport) error {
+	utxoView := NewUtxoViewpoint()
+	utxoView.SetBestHash(b.bestNode.hash)
+
+	// Unlike in Bitcoin, the genesis coinbase outputs are spendable.  Since
+	// the genesis block is at height zero, the spend journal is unable to
+	// record the version of the genesis coinbase along with the spend of
+	// its final output, so make sure an entry is always available for it.
+	genesisTx := b.chainParams.GenesisBlock.Transactions[0]
+	genesisHash := genesisTx.TxHash()
+	err := utxoView.fetchUtxosMain(b.db, map[chainhash.Hash]struct{}{
+		genesisHash: {},
+	})
+	if err != nil {
+		return err
+	}
+	if utxoView.LookupEntry(&genesisHash) == nil {
+		utxoView.entries[genesisHash] = newUtxoEntry(genesisTx.Version,
+			true, 0)
+	}
+
+	samples := make([]sampledOutput, 0, numSamples)
+	var numSeen int
+	for node := b.bestNode; node != nil && node.height > 0 &&
+		int(report.UndoBlocks) < undoDepth; {
+
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
+		var block *provautil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHash(dbTx, node.hash)
+			if err != nil {
+				return err
+			}
+			return checkCreatedOutputs(dbTx, block, report)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = utxoView.fetchInputUtxos(b.db, block)
+		if err != nil {
+			return err
+		}
+		var stxos []spentTxOut
+		err = b.db.View(func(dbTx database.Tx) error {
+			stxos, err = dbFetchSpendJournalEntry(dbTx, block, utxoView)
+			return err
+		})
+		if err != nil {
+			// The remaining blocks can't be disconnected without
+			// the spend journal entry, so stop here.
+			if isDbCorruptionErr(err) {
+				report.addMismatch("%v", err)
+				break
+			}
+			return err
+		}
+		report.UndoBlocks++
+
+		// Choose the samples uniformly from all of the spent outputs
+		// loaded so far.  The height of the creating transaction is only
+		// stored with the spend of its final output, otherwise it is
+		// available from the remaining utxo entry.
+		var stxoIdx int
+		for _, tx := range block.Transactions()[1:] {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := stxos[stxoIdx]
+				stxoIdx++
+
+				creationHeight := stxo.height
+				if creationHeight == 0 {
+					entry := utxoView.LookupEntry(&txIn.PreviousOutPoint.Hash)
+					if entry == nil {
+						continue
+					}
+					creationHeight = entry.BlockHeight()
+				}
+				sample := sampledOutput{
+					outPoint:       txIn.PreviousOutPoint,
+					creationHeight: creationHeight,
+					spendHeight:    node.height,
+					stxo:           stxo,
+				}
+
+				numSeen++
+				if len(samples) < numSamples {
+					samples = append(samples, sample)
+				} else if j := rand.Intn(numSeen); j < numSamples {
+					samples[j] = sample
+				}
+			}
+		}
+
+		err = utxoView.disconnectTransactions(block, stxos)
+		if err != nil {
+			return err
+		}
+
+		node, err = b.getPrevNodeFromNode(node)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		for i := range samples {
+			err := checkSampledOutput(dbTx, &samples[i], report)
+			if err != nil {
+				return err
+			}
+			report.SampledOutputs++
+		}
+		return nil
+	})
+}
+
+// replayKeyView re-derives the admin key state by replaying the admin
+// transactions of every main chain block from the genesis block up to and
+// including the block with the passed hash and height.
+//
+// This function is safe for concurrent access without holding the chain state
+// lock since it only relies on the database.  An error is returned when the
+// blocks loaded by height do not link together, which happens when the main
+// chain is reorganized while the blocks are being replayed.
+func (b *BlockChain) replayKeyView(ctx context.Context, hash *chainhash.Hash, height uint32) (*KeyViewpoint, error) {
+	genesisBlock := provautil.NewBlock(b.chainParams.GenesisBlock)
+	keyView := b.genesisKeyView(genesisBlock)
+	prevHash := genesisBlock.Hash()
+	for h := uint32(1); h <= height; h++ {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		var block *provautil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHeight(dbTx, h)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if block.MsgBlock().Header.PrevBlock != *prevHash {
+			return nil, fmt.Errorf("block %v at height %d does not "+
+				"connect to %v", block.Hash(), h, prevHash)
+		}
+
+		keyView.connectTransactions(block)
+		prevHash = block.Hash()
+	}
+	if !prevHash.IsEqual(hash) {
+		return nil, fmt.Errorf("block at height %d is %v, expected %v",
+			height, prevHash, hash)
+	}
+
+	return keyView, nil
+}
+
+// CheckConsistency checks the chain state against the block data it was
+// derived from in order to detect silent database corruption.  It performs the
+// following checks:
+//
+//  - The admin key state held in memory is compared against the state stored
+//    in the database
+//  - The admin key state is re-derived by replaying all main chain blocks and
+//    compared against the state held in memory
+//  - The spend journal entries of up to undoDepth blocks at the end of the main
+//    chain are loaded and up to numSamples of the spent outputs they contain
+//    are verified against the transactions which created them
+//  - The utxo set entries for the transactions of those same blocks are
+//    verified against the transactions themselves
+//
+// Inconsistencies are recorded in the returned report, while an error is only
+// returned when the checks could not be performed, such as when the passed
+// context is canceled.
+//
+// This function is safe for concurrent access.  The chain state lock is only
+// held while the in-memory state is captured and the undo data is checked, so
+// the replay of the admin key state does not stall block processing.
+func (b *BlockChain) CheckConsistency(ctx context.Context, undoDepth, numSamples int) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{}
+
+	b.chainLock.Lock()
+	report.Hash = *b.bestNode.hash
+	report.Height = b.bestNode.height
+	memKeyView := NewKeyViewpoint()
+	memKeyView.SetThreadTips(b.threadTips)
+	memKeyView.SetLastKeyID(b.lastKeyID)
+	memKeyView.SetTotalSupply(b.totalSupply)
+	memKeyView.SetKeys(b.adminKeySets)
+	memKeyView.SetKeyIDs(b.aspKeyIdMap)
+	memKeyView.SetFrozenKeyIDs(b.frozenKeyIDs)
+	err := b.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Get(keySetBucketName)
+		if serialized == nil {
+			report.addMismatch("stored admin state is missing")
+			return nil
+		}
+		adminKeySets, aspKeyIdMap, threadTips, lastKeyID, totalSupply,
+			err := deserializeKeySet(serialized)
+		if err != nil {
+			report.addMismatch("stored admin state is corrupt: %v", err)
+			return nil
+		}
+		frozenKeyIDs, err := dbFetchFrozenKeyIDs(dbTx)
+		if err != nil {
+			report.addMismatch("stored frozen keyIDs are corrupt: %v", err)
+			return nil
+		}
+		dbKeyView := NewKeyViewpoint()
+		dbKeyView.SetThreadTips(threadTips)
+		dbKeyView.SetLastKeyID(lastKeyID)
+		dbKeyView.SetTotalSupply(totalSupply)
+		dbKeyView.SetKeys(adminKeySets)
+		dbKeyView.SetKeyIDs(aspKeyIdMap)
+		dbKeyView.SetFrozenKeyIDs(frozenKeyIDs)
+		compareKeyViews(report, "stored", memKeyView, dbKeyView)
+		return nil
+	})
+	if err == nil {
+		err = b.checkUndoData(ctx, undoDepth, numSamples, report)
+	}
+	b.chainLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	keyView, err := b.replayKeyView(ctx, &report.Hash, report.Height)
+	if err != nil {
+		return nil, err
+	}
+	report.KeyViewBlocks = report.Height
+	compareKeyViews(report, "in-memory", keyView, memKeyView)
+
+	return report, nil
+}
@@ -7,8 +7,11 @@
 package blockchain
 
 import (
+	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
 	"math/big"
+	"sort"
 	"time"
 )
 
@@ -162,8 +165,14 @@ func (b *BlockChain) calcEasiestDifficulty(bits uint32, duration time.Duration)
 	return b.chainParams.PowLimitBits
 }
 
+// emaMaxSolveTimeFactor is the maximum solve time of a block, as a multiple of
+// the target time per block, taken into account by the EMA difficulty
+// algorithm.
+const emaMaxSolveTimeFactor = 6
+
 // calcNextRequiredDifficulty calculates the required difficulty for the block
-// after the passed previous block node based on the difficulty retarget rules.
+// after the passed previous block node based on the difficulty retarget rules
+// selected by the DifficultyAlgorithm of the chain parameters.
 // This function differs from the exported CalcNextRequiredDifficulty in that
 // the exported version uses the current best chain as the previous block node
 // while this function accepts any block node.
@@ -175,6 +184,24 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode) (uint32, er
 		return b.chainParams.PowLimitBits, nil
 	}
 
+	switch b.chainParams.DifficultyAlgorithm {
+	case chaincfg.FixedDifficulty:
+		return b.chainParams.PowLimitBits, nil
+
+	case chaincfg.EMADifficulty:
+		prevNode, err := b.getPrevNodeFromNode(lastNode)
+		if err != nil {
+			return 0, err
+		}
+		if prevNode == nil {
+			return b.chainParams.PowLimitBits, nil
+		}
+		solveTime := time.Duration(lastNode.timestamp-prevNode.timestamp) *
+			time.Second
+		return nextEMADifficulty(b.chainParams, lastNode.bits,
+			solveTime), nil
+	}
+
 	// Find the first node in the averaging interval, sum the total bits
 	// to use when averaging the difficulty over the interval.
 	firstNode := lastNode
@@ -213,42 +240,134 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode) (uint32, er
 		return 0, err
 	}
 
-	return b.nextRequiredDifficulty(medianFirstNodeTime, medianLastNodeTime, avgDifficulty), nil
+	return nextRequiredDifficulty(b.chainParams, medianFirstNodeTime,
+		medianLastNodeTime, avgDifficulty), nil
 }
 
 // nextRequiredDifficulty calculates the required difficulty for the block
 // after the passed previous block node based on a moving difficulty window.
-func (b *BlockChain) nextRequiredDifficulty(firstNodeTime time.Time, lastNodeTime time.Time, avgDifficulty *big.Int) uint32 {
+func nextRequiredDifficulty(chainParams *chaincfg.Params, firstNodeTime time.Time, lastNodeTime time.Time, avgDifficulty *big.Int) uint32 {
 	// Limit adjustment step
 	// Make sure to use medians to prevent time-warp attacks
 	timespan := time.Duration(lastNodeTime.UnixNano() - firstNodeTime.UnixNano())
 
 	// Limit the amount of adjustment that can occur to the previous
 	// difficulty.
-	timespan = b.chainParams.AveragingWindowTimespan() +
-		(timespan-b.chainParams.AveragingWindowTimespan())/4
-	if timespan < b.chainParams.MinActualTimespan() {
-		timespan = b.chainParams.MinActualTimespan()
-	} else if timespan > b.chainParams.MaxActualTimespan() {
-		timespan = b.chainParams.MaxActualTimespan()
+	timespan = chainParams.AveragingWindowTimespan() +
+		(timespan-chainParams.AveragingWindowTimespan())/4
+	if timespan < chainParams.MinActualTimespan() {
+		timespan = chainParams.MinActualTimespan()
+	} else if timespan > chainParams.MaxActualTimespan() {
+		timespan = chainParams.MaxActualTimespan()
 	}
 
 	// Calculate new target difficulty as:
 	//  averageDifficulty / averagingWindowTimespan * timespan
 	// The result uses integer division which means it will be slightly
 	// rounded down.
-	avgWindowTimespan := big.NewInt(int64(b.chainParams.AveragingWindowTimespan() / time.Millisecond))
+	avgWindowTimespan := big.NewInt(int64(chainParams.AveragingWindowTimespan() / time.Millisecond))
 	avgDifficulty.Div(avgDifficulty, avgWindowTimespan)
 	avgDifficulty.Mul(avgDifficulty, big.NewInt(int64(timespan/time.Millisecond)))
 
 	// Limit new value to the proof of work limit.
-	if avgDifficulty.Cmp(b.chainParams.PowLimit) > 0 {
-		avgDifficulty.Set(b.chainParams.PowLimit)
+	if avgDifficulty.Cmp(chainParams.PowLimit) > 0 {
+		avgDifficulty.Set(chainParams.PowLimit)
 	}
 
 	return BigToCompact(avgDifficulty)
 }
 
+// nextEMADifficulty calculates the required difficulty for the block after a
+// block with the passed difficulty bits which took the passed time to solve,
+// based on an exponential moving average of the solve times.
+func nextEMADifficulty(chainParams *chaincfg.Params, bits uint32, solveTime time.Duration) uint32 {
+	// Bound the solve time, which may even be negative since timestamps
+	// only have to be after the median time of the previous blocks, so a
+	// single block with a skewed timestamp can't move the difficulty much.
+	targetTime := chainParams.TargetTimePerBlock
+	if solveTime < 0 {
+		solveTime = 0
+	} else if solveTime > emaMaxSolveTimeFactor*targetTime {
+		solveTime = emaMaxSolveTimeFactor * targetTime
+	}
+
+	// Calculate new target as:
+	//  target * (smoothing + solveTime - targetTime) / smoothing
+	// where the smoothing is the target timespan of PowAveragingWindow
+	// blocks.
+	smoothing := chainParams.AveragingWindowTimespan()
+	target := CompactToBig(bits)
+	target.Mul(target, big.NewInt(int64(
+		(smoothing+solveTime-targetTime)/time.Millisecond)))
+	target.Div(target, big.NewInt(int64(smoothing/time.Millisecond)))
+
+	// Limit new value to the proof of work limit, and keep it positive
+	// as a target of zero can't be met.
+	if target.Cmp(chainParams.PowLimit) > 0 {
+		target.Set(chainParams.PowLimit)
+	} else if target.Sign() <= 0 {
+		target.SetInt64(1)
+	}
+
+	return BigToCompact(target)
+}
+
+// PastMedianTime returns the median time of the passed headers, which must be
+// the consecutive headers ending with a block, from the oldest to the most
+// recent one, just like the median time of the block is calculated by the
+// chain: only the last medianTimeBlocks headers are taken into account.
+func PastMedianTime(headers []*wire.BlockHeader) time.Time {
+	if len(headers) > medianTimeBlocks {
+		headers = headers[len(headers)-medianTimeBlocks:]
+	}
+	timestamps := make([]int64, len(headers))
+	for i, header := range headers {
+		timestamps[i] = header.Timestamp.Unix()
+	}
+	sort.Sort(timeSorter(timestamps))
+	return time.Unix(timestamps[len(timestamps)/2], 0)
+}
+
+// CalcNextRequiredDifficultyFromHeaders calculates the required difficulty for
+// the block after the last of the passed headers, which must be the consecutive
+// headers ending with the previous block, from the oldest to the most recent
+// one.  Since it applies the same retarget rules as the chain without block
+// nodes, light clients can check the difficulty of headers they are served.
+// The headers must start with the genesis block unless there are at least
+// PowAveragingWindow+medianTimeBlocks of them.
+func CalcNextRequiredDifficultyFromHeaders(headers []*wire.BlockHeader, chainParams *chaincfg.Params) uint32 {
+	switch chainParams.DifficultyAlgorithm {
+	case chaincfg.FixedDifficulty:
+		return chainParams.PowLimitBits
+
+	case chaincfg.EMADifficulty:
+		if len(headers) < 2 {
+			return chainParams.PowLimitBits
+		}
+		last := headers[len(headers)-1]
+		prev := headers[len(headers)-2]
+		return nextEMADifficulty(chainParams, last.Bits,
+			last.Timestamp.Sub(prev.Timestamp))
+	}
+
+	// The first block of the averaging window is PowAveragingWindow blocks
+	// before the last one, and there is no retarget until it exists.
+	window := chainParams.PowAveragingWindow
+	first := len(headers) - 1 - window
+	if first < 0 {
+		return chainParams.PowLimitBits
+	}
+
+	avgDifficulty := big.NewInt(0)
+	for _, header := range headers[first+1:] {
+		avgDifficulty.Add(avgDifficulty, CompactToBig(header.Bits))
+	}
+	avgDifficulty.Div(avgDifficulty, big.NewInt(int64(window)))
+
+	return nextRequiredDifficulty(chainParams, PastMedianTime(headers[:first+1]),
+		PastMedianTime(headers), avgDifficulty)
+}
+
 // CalcNextRequiredDifficulty calculates the required difficulty for the block
 // after the end of the current best chain based on the difficulty retarget
 // rules.
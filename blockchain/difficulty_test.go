@@ -8,8 +8,11 @@ package blockchain_test
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/wire"
 )
 
 func TestBigToCompact(t *testing.T) {
@@ -70,3 +73,95 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// TestCalcNextRequiredDifficultyAlgorithms ensures the difficulty algorithm
+// selected by the chain parameters is applied to headers.
+func TestCalcNextRequiredDifficultyAlgorithms(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.TargetTimePerBlock = 10 * time.Second
+	params.PowAveragingWindow = 17
+	bits := blockchain.BigToCompact(new(big.Int).Rsh(params.PowLimit, 8))
+
+	// headers returns a chain of two headers with the passed bits whose
+	// last header took the passed time to solve.
+	start := time.Unix(1500000000, 0)
+	headers := func(solveTime time.Duration) []*wire.BlockHeader {
+		return []*wire.BlockHeader{
+			{Height: 0, Bits: bits, Timestamp: start},
+			{Height: 1, Bits: bits, Timestamp: start.Add(solveTime)},
+		}
+	}
+	// scaled returns the bits scaled by num/170, the smoothing of the
+	// window in seconds.
+	scaled := func(num int64) uint32 {
+		target := blockchain.CompactToBig(bits)
+		target.Mul(target, big.NewInt(num*1000))
+		target.Div(target, big.NewInt(170*1000))
+		return blockchain.BigToCompact(target)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm chaincfg.DifficultyAlgorithm
+		headers   []*wire.BlockHeader
+		want      uint32
+	}{
+		{
+			name:      "fixed",
+			algorithm: chaincfg.FixedDifficulty,
+			headers:   headers(time.Second),
+			want:      params.PowLimitBits,
+		},
+		{
+			name:      "ema after genesis",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(time.Second)[:1],
+			want:      params.PowLimitBits,
+		},
+		{
+			name:      "ema on target",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(10 * time.Second),
+			want:      bits,
+		},
+		{
+			name:      "ema slow block",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(30 * time.Second),
+			want:      scaled(190),
+		},
+		{
+			name:      "ema fast block",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(2 * time.Second),
+			want:      scaled(162),
+		},
+		{
+			name:      "ema bounded solve time",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(time.Hour),
+			want:      scaled(220),
+		},
+		{
+			name:      "ema timestamp before parent",
+			algorithm: chaincfg.EMADifficulty,
+			headers:   headers(-time.Minute),
+			want:      scaled(160),
+		},
+		{
+			name:      "window without full window",
+			algorithm: chaincfg.WindowDifficulty,
+			headers:   headers(10 * time.Second),
+			want:      params.PowLimitBits,
+		},
+	}
+	for _, test := range tests {
+		params.DifficultyAlgorithm = test.algorithm
+		got := blockchain.CalcNextRequiredDifficultyFromHeaders(
+			test.headers, &params)
+		if got != test.want {
+			t.Errorf("%s: got bits %08x, want %08x", test.name, got,
+				test.want)
+		}
+	}
+}
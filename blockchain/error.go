@@ -19,6 +19,18 @@ func (e AssertError) Error() string {
 	return "assertion failed: " + string(e)
 }
 
+// ScriptDivergenceError identifies an error where the script engine and the
+// reference interpreter disagree on the validity of the scripts of a block.
+// It indicates a defect in one of them rather than an invalid block, and
+// should be treated as a critical and unrecoverable error.
+type ScriptDivergenceError string
+
+// Error returns the divergence error as a human-readable string and satisfies
+// the error interface.
+func (e ScriptDivergenceError) Error() string {
+	return "script divergence: " + string(e)
+}
+
 // ErrorCode identifies a kind of error.
 type ErrorCode int
 
@@ -177,6 +189,15 @@ const (
 	// not match the expected value of the subsidy plus the sum of all fees.
 	ErrBadCoinbaseValue
 
+	// ErrBadCoinbaseHeight indicates the block height committed to in the
+	// lock time of a coinbase transaction does not match the height of the
+	// block which contains it.
+	ErrBadCoinbaseHeight
+
+	// ErrDuplicateCoinbase indicates a block contains a coinbase transaction
+	// with the same hash as a coinbase that is already known to the chain.
+	ErrDuplicateCoinbase
+
 	// ErrScriptMalformed indicates a transaction script is malformed in
 	// some way.  For example, it might be longer than the maximum allowed
 	// length or fail to parse.
@@ -223,6 +244,18 @@ const (
 	// ErrFeeTooHigh indicates a transaction fee exceeds the limit for
 	// fee paid.
 	ErrFeeTooHigh
+
+	// ErrPrematureTimeLock indicates a transaction creates a timelocked
+	// output before timelocks are enforced.
+	ErrPrematureTimeLock
+
+	// ErrPrematureFreeze indicates a transaction freezes or unfreezes
+	// keyIDs before keyID freezes are enabled.
+	ErrPrematureFreeze
+
+	// ErrFrozenOutput indicates a transaction other than an issue thread
+	// transaction spends an output co-signed by a frozen keyID.
+	ErrFrozenOutput
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -263,6 +296,8 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrMultipleCoinbases:    "ErrMultipleCoinbases",
 	ErrBadCoinbaseScriptLen: "ErrBadCoinbaseScriptLen",
 	ErrBadCoinbaseValue:     "ErrBadCoinbaseValue",
+	ErrBadCoinbaseHeight:    "ErrBadCoinbaseHeight",
+	ErrDuplicateCoinbase:    "ErrDuplicateCoinbase",
 	ErrScriptMalformed:      "ErrScriptMalformed",
 	ErrScriptValidation:     "ErrScriptValidation",
 	ErrExcessiveChainShare:  "ErrExcessiveChainShare",
@@ -274,6 +309,9 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrInvalidAdminTx:       "ErrInvalidAdminTx",
 	ErrInvalidAdminOp:       "ErrInvalidAdminOp",
 	ErrFeeTooHigh:           "ErrFeeTooHigh",
+	ErrPrematureTimeLock:    "ErrPrematureTimeLock",
+	ErrPrematureFreeze:      "ErrPrematureFreeze",
+	ErrFrozenOutput:         "ErrFrozenOutput",
 }
 
 // String returns the ErrorCode as a human-readable name.
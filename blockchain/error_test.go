@@ -54,6 +54,8 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrMultipleCoinbases, "ErrMultipleCoinbases"},
 		{blockchain.ErrBadCoinbaseScriptLen, "ErrBadCoinbaseScriptLen"},
 		{blockchain.ErrBadCoinbaseValue, "ErrBadCoinbaseValue"},
+		{blockchain.ErrBadCoinbaseHeight, "ErrBadCoinbaseHeight"},
+		{blockchain.ErrDuplicateCoinbase, "ErrDuplicateCoinbase"},
 		{blockchain.ErrScriptMalformed, "ErrScriptMalformed"},
 		{blockchain.ErrScriptValidation, "ErrScriptValidation"},
 		{blockchain.ErrExcessiveChainShare, "ErrExcessiveChainShare"},
@@ -61,6 +63,9 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrInconsistentBlkSize, "ErrInconsistentBlkSize"},
 		{blockchain.ErrInvalidValidateKey, "ErrInvalidValidateKey"},
 		{blockchain.ErrFeeTooHigh, "ErrFeeTooHigh"},
+		{blockchain.ErrPrematureTimeLock, "ErrPrematureTimeLock"},
+		{blockchain.ErrPrematureFreeze, "ErrPrematureFreeze"},
+		{blockchain.ErrFrozenOutput, "ErrFrozenOutput"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -8,6 +8,7 @@ package blockchain_test
 
 import (
 	"bytes"
+	"context"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/blockchain/fullblocktests"
 	"github.com/bitgo/prova/btcec"
@@ -246,4 +247,18 @@ func TestFullBlocks(t *testing.T) {
 			}
 		}
 	}
+
+	// Ensure the resulting chain state is consistent with the block data
+	// it was derived from.
+	report, err := chain.CheckConsistency(context.Background(), 20, 100)
+	if err != nil {
+		t.Fatalf("CheckConsistency: unexpected error: %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("CheckConsistency: unexpected mismatches: %v",
+			report.Mismatches)
+	}
+	if report.UndoBlocks == 0 || report.SampledOutputs == 0 {
+		t.Fatalf("CheckConsistency: nothing was checked: %+v", report)
+	}
 }
@@ -294,8 +294,8 @@ func opReturnScript() []byte {
 }
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate
-// subsidy based on the passed block height.  The coinbase signature script
-// conforms to the requirements of version 2 blocks.
+// subsidy based on the passed block height.  The coinbase lock time commits to
+// the block height as required by the consensus rules.
 func (g *testGenerator) createCoinbaseTx(blockHeight uint32) *wire.MsgTx {
 	coinbaseScript, err := standardCoinbaseScript()
 	if err != nil {
@@ -325,6 +325,7 @@ func (g *testGenerator) createCoinbaseTx(blockHeight uint32) *wire.MsgTx {
 		Value:    blockchain.CalcBlockSubsidy(blockHeight, g.params),
 		PkScript: scriptPkScript,
 	})
+	tx.LockTime = blockHeight
 	return tx
 }
 
@@ -424,6 +425,14 @@ func changeCoinbaseValue(delta int64) func(*wire.MsgBlock) {
 	}
 }
 
+// changeCoinbaseHeight returns a function that itself takes a block and
+// changes the block height committed to by its coinbase.
+func changeCoinbaseHeight(height uint32) func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		b.Transactions[0].LockTime = height
+	}
+}
+
 func makeAddr(priv *btcec.PrivateKey, kids *[2]uint) provautil.Address {
 	// Create an Prova address that has:
 	//   - a random pkHash address, so transaction hashes don't collide
@@ -1151,5 +1160,15 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	g.nextBlock("b31", outs[12], changeCoinbaseValue(1))
 	rejected(blockchain.ErrBadCoinbaseValue)
 
+	// ---------------------------------------------------------------------
+	// Coinbase height commitment tests.
+	// ---------------------------------------------------------------------
+
+	// Attempt to progress the chain past b27 with a coinbase which commits
+	// to the wrong block height.
+	g.setTip("b27")
+	g.nextBlock("b32", outs[12], changeCoinbaseHeight(0))
+	rejected(blockchain.ErrBadCoinbaseHeight)
+
 	return tests, nil
 }
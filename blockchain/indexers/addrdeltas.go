@@ -0,0 +1,686 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// addrDeltaKeySize is the number of bytes a key in the address delta
+	// bucket consumes.  It consists of the address key + 4 bytes block
+	// height + 4 bytes transaction index + 1 byte spending flag + 4 bytes
+	// input or output index.
+	addrDeltaKeySize = addrKeySize + 4 + 4 + 1 + 4
+
+	// addrDeltaValueSize is the number of bytes a value in the address
+	// delta bucket consumes.  It consists of the transaction hash + 8 bytes
+	// amount.
+	addrDeltaValueSize = chainhash.HashSize + 8
+
+	// addrUtxoKeySize is the number of bytes a key in the address utxo
+	// bucket consumes.  It consists of the address key + the transaction
+	// hash + 4 bytes output index.
+	addrUtxoKeySize = addrKeySize + chainhash.HashSize + 4
+)
+
+var (
+	// addrDeltaIndexKey is the key of the db bucket used to house the
+	// balance changes of each address.
+	addrDeltaIndexKey = []byte("addrdeltaidx")
+
+	// addrUtxoIndexKey is the key of the db bucket used to house the
+	// unspent outputs of each address.
+	addrUtxoIndexKey = []byte("addrutxoidx")
+
+	// keyOrder is the byte order used for the numeric fields of the keys
+	// of the address delta and utxo buckets.  It is big endian so the
+	// keys of an address sort by block height and position in the block.
+	keyOrder = binary.BigEndian
+)
+
+// -----------------------------------------------------------------------------
+// Along with the transactions involving each address, the address index keeps
+// the changes to the balance of each address (deltas) and the unspent outputs
+// paying to each address in two additional buckets.
+//
+// Every input spending from an address and every output paying to an address
+// is a delta.  The serialized key format of the delta bucket is:
+//
+//   <addr key><block height><tx index><spending><index>
+//
+//   Field           Type      Size
+//   addr key        [21]byte  21 bytes
+//   block height    uint32    4 bytes (big endian)
+//   tx index        uint32    4 bytes (big endian)
+//   spending        uint8     1 byte
+//   index           uint32    4 bytes (big endian)
+//   -----
+//   Total: 34 bytes
+//
+// The serialized value format is:
+//
+//   <tx hash><amount>
+//
+//   Field           Type           Size
+//   tx hash         chainhash.Hash 32 bytes
+//   amount          int64          8 bytes
+//   -----
+//   Total: 40 bytes
+//
+// The amount is negative for inputs.  The serialized key format of the utxo
+// bucket is:
+//
+//   <addr key><tx hash><index>
+//
+//   Field           Type           Size
+//   addr key        [21]byte       21 bytes
+//   tx hash         chainhash.Hash 32 bytes
+//   index           uint32         4 bytes (big endian)
+//   -----
+//   Total: 57 bytes
+//
+// The serialized value format is:
+//
+//   <amount><block height><pk script>
+//
+//   Field           Type      Size
+//   amount          int64     8 bytes
+//   block height    uint32    4 bytes
+//   pk script       []byte    variable
+// -----------------------------------------------------------------------------
+
+// AddrDelta describes the change to the balance of an address by a single
+// input or output of a transaction.
+type AddrDelta struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Height and TxIndex are the height of the block containing the
+	// transaction and the index of the transaction in the block.  They are
+	// zero for unconfirmed transactions.
+	Height  uint32
+	TxIndex uint32
+
+	// Index is the index of the input or output, depending on Spending.
+	Index    uint32
+	Spending bool
+
+	// Amount is the change to the balance, which is negative for inputs.
+	Amount int64
+
+	// PrevOut is the output spent by an input of an unconfirmed
+	// transaction.  It is nil otherwise.
+	PrevOut *wire.OutPoint
+}
+
+// AddrUtxo describes an unspent output paying to an address.
+type AddrUtxo struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	PkScript []byte
+	Height   uint32
+}
+
+// addrDeltaKey returns the key of the delta bucket for the passed address key
+// and delta.
+func addrDeltaKey(addrKey [addrKeySize]byte, delta *AddrDelta) []byte {
+	key := make([]byte, addrDeltaKeySize)
+	copy(key, addrKey[:])
+	offset := addrKeySize
+	keyOrder.PutUint32(key[offset:], delta.Height)
+	offset += 4
+	keyOrder.PutUint32(key[offset:], delta.TxIndex)
+	offset += 4
+	if delta.Spending {
+		key[offset] = 1
+	}
+	offset++
+	keyOrder.PutUint32(key[offset:], delta.Index)
+	return key
+}
+
+// serializeAddrDelta returns the value of the delta bucket for the passed
+// delta.
+func serializeAddrDelta(delta *AddrDelta) []byte {
+	serialized := make([]byte, addrDeltaValueSize)
+	copy(serialized, delta.TxHash[:])
+	byteOrder.PutUint64(serialized[chainhash.HashSize:],
+		uint64(delta.Amount))
+	return serialized
+}
+
+// deserializeAddrDelta decodes the passed key and value of the delta bucket
+// into the passed delta.
+func deserializeAddrDelta(key, serialized []byte, delta *AddrDelta) error {
+	if len(key) != addrDeltaKeySize || len(serialized) != addrDeltaValueSize {
+		return errDeserialize("unexpected address delta size")
+	}
+
+	offset := addrKeySize
+	delta.Height = keyOrder.Uint32(key[offset:])
+	offset += 4
+	delta.TxIndex = keyOrder.Uint32(key[offset:])
+	offset += 4
+	delta.Spending = key[offset] != 0
+	offset++
+	delta.Index = keyOrder.Uint32(key[offset:])
+	copy(delta.TxHash[:], serialized)
+	delta.Amount = int64(byteOrder.Uint64(serialized[chainhash.HashSize:]))
+	return nil
+}
+
+// addrUtxoKey returns the key of the utxo bucket for the passed address key
+// and outpoint.
+func addrUtxoKey(addrKey [addrKeySize]byte, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, addrUtxoKeySize)
+	copy(key, addrKey[:])
+	copy(key[addrKeySize:], outPoint.Hash[:])
+	keyOrder.PutUint32(key[addrKeySize+chainhash.HashSize:], outPoint.Index)
+	return key
+}
+
+// serializeAddrUtxo returns the value of the utxo bucket for the passed
+// unspent output.
+func serializeAddrUtxo(utxo *AddrUtxo) []byte {
+	serialized := make([]byte, 12+len(utxo.PkScript))
+	byteOrder.PutUint64(serialized, uint64(utxo.Amount))
+	byteOrder.PutUint32(serialized[8:], utxo.Height)
+	copy(serialized[12:], utxo.PkScript)
+	return serialized
+}
+
+// deserializeAddrUtxo decodes the passed key and value of the utxo bucket into
+// the passed unspent output.
+func deserializeAddrUtxo(key, serialized []byte, utxo *AddrUtxo) error {
+	if len(key) != addrUtxoKeySize || len(serialized) < 12 {
+		return errDeserialize("unexpected address utxo size")
+	}
+
+	copy(utxo.OutPoint.Hash[:], key[addrKeySize:])
+	utxo.OutPoint.Index = keyOrder.Uint32(key[addrKeySize+chainhash.HashSize:])
+	utxo.Amount = int64(byteOrder.Uint64(serialized))
+	utxo.Height = byteOrder.Uint32(serialized[8:])
+	utxo.PkScript = make([]byte, len(serialized)-12)
+	copy(utxo.PkScript, serialized[12:])
+	return nil
+}
+
+// addrKeysForPkScript returns the address keys of the supported addresses the
+// passed public key script pays to.
+func (idx *AddrIndex) addrKeysForPkScript(pkScript []byte) [][addrKeySize]byte {
+	// Nothing to index if the script is non-standard or otherwise doesn't
+	// contain any addresses.
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil {
+		return nil
+	}
+
+	addrKeys := make([][addrKeySize]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		addrKey, err := addrToKey(addr)
+		if err != nil {
+			// Ignore unsupported address types.
+			continue
+		}
+		addrKeys = append(addrKeys, addrKey)
+	}
+	return addrKeys
+}
+
+// connectBlockDeltas adds the deltas of the transactions of the passed block
+// to the delta bucket and updates the utxo bucket for the outputs they create
+// and spend.
+func (idx *AddrIndex) connectBlockDeltas(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	meta := dbTx.Metadata()
+	deltaBucket := meta.Bucket(addrDeltaIndexKey)
+	utxoBucket := meta.Bucket(addrUtxoIndexKey)
+	height := block.Height()
+	for txIdx, tx := range block.Transactions() {
+		delta := AddrDelta{
+			TxHash:  *tx.Hash(),
+			Height:  height,
+			TxIndex: uint32(txIdx),
+		}
+
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			delta.Spending = true
+			for txInIdx, txIn := range tx.MsgTx().TxIn {
+				// The view should always have the input since
+				// the index contract requires it, however, be
+				// safe and simply ignore any missing entries.
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					continue
+				}
+
+				pkScript := entry.PkScriptByIndex(origin.Index)
+				delta.Index = uint32(txInIdx)
+				delta.Amount = -entry.AmountByIndex(origin.Index)
+				for _, addrKey := range idx.addrKeysForPkScript(pkScript) {
+					err := deltaBucket.Put(addrDeltaKey(addrKey,
+						&delta), serializeAddrDelta(&delta))
+					if err != nil {
+						return err
+					}
+					err = utxoBucket.Delete(addrUtxoKey(addrKey,
+						origin))
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		delta.Spending = false
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			delta.Index = uint32(txOutIdx)
+			delta.Amount = txOut.Value
+			outPoint := wire.OutPoint{Hash: delta.TxHash,
+				Index: delta.Index}
+			utxo := AddrUtxo{
+				Amount:   txOut.Value,
+				PkScript: txOut.PkScript,
+				Height:   height,
+			}
+			for _, addrKey := range idx.addrKeysForPkScript(txOut.PkScript) {
+				err := deltaBucket.Put(addrDeltaKey(addrKey, &delta),
+					serializeAddrDelta(&delta))
+				if err != nil {
+					return err
+				}
+				err = utxoBucket.Put(addrUtxoKey(addrKey, &outPoint),
+					serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// disconnectBlockDeltas removes the deltas of the transactions of the passed
+// block from the delta bucket and reverts the changes to the utxo bucket made
+// when the block was connected.  The transactions are processed in reverse
+// order so outputs which are both created and spent in the block are removed.
+func (idx *AddrIndex) disconnectBlockDeltas(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	meta := dbTx.Metadata()
+	deltaBucket := meta.Bucket(addrDeltaIndexKey)
+	utxoBucket := meta.Bucket(addrUtxoIndexKey)
+	height := block.Height()
+	txns := block.Transactions()
+	for txIdx := len(txns) - 1; txIdx >= 0; txIdx-- {
+		tx := txns[txIdx]
+		delta := AddrDelta{
+			TxHash:  *tx.Hash(),
+			Height:  height,
+			TxIndex: uint32(txIdx),
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			delta.Index = uint32(txOutIdx)
+			outPoint := wire.OutPoint{Hash: delta.TxHash,
+				Index: delta.Index}
+			for _, addrKey := range idx.addrKeysForPkScript(txOut.PkScript) {
+				err := deltaBucket.Delete(addrDeltaKey(addrKey, &delta))
+				if err != nil {
+					return err
+				}
+				err = utxoBucket.Delete(addrUtxoKey(addrKey, &outPoint))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		delta.Spending = true
+		for txInIdx, txIn := range tx.MsgTx().TxIn {
+			origin := &txIn.PreviousOutPoint
+			entry := view.LookupEntry(&origin.Hash)
+			if entry == nil {
+				continue
+			}
+
+			delta.Index = uint32(txInIdx)
+			utxo := AddrUtxo{
+				Amount:   entry.AmountByIndex(origin.Index),
+				PkScript: entry.PkScriptByIndex(origin.Index),
+				Height:   entry.BlockHeight(),
+			}
+			for _, addrKey := range idx.addrKeysForPkScript(utxo.PkScript) {
+				err := deltaBucket.Delete(addrDeltaKey(addrKey, &delta))
+				if err != nil {
+					return err
+				}
+				err = utxoBucket.Put(addrUtxoKey(addrKey, origin),
+					serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeltasForAddress returns the changes to the balance of the passed address
+// made by the transactions in the blocks from the start height through the
+// end height, ordered by their position in the chain.  An end height of zero
+// includes every block after the start height.
+//
+// NOTE: These results only include transactions confirmed in blocks.  See the
+// UnconfirmedDeltasForAddress method for obtaining the changes made by
+// unconfirmed transactions.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) DeltasForAddress(addr provautil.Address, start, end uint32) ([]AddrDelta, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltas []AddrDelta
+	err = idx.db.View(func(dbTx database.Tx) error {
+		seek := make([]byte, addrKeySize+4)
+		copy(seek, addrKey[:])
+		keyOrder.PutUint32(seek[addrKeySize:], start)
+
+		cursor := dbTx.Metadata().Bucket(addrDeltaIndexKey).Cursor()
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, addrKey[:]) {
+				break
+			}
+
+			var delta AddrDelta
+			err := deserializeAddrDelta(key, cursor.Value(), &delta)
+			if err != nil {
+				return err
+			}
+			if end != 0 && delta.Height > end {
+				break
+			}
+			deltas = append(deltas, delta)
+		}
+		return nil
+	})
+	return deltas, err
+}
+
+// AddrTx describes the change to the balance of an address by a transaction of
+// the main chain.
+type AddrTx struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Height and TxIndex are the height of the block containing the
+	// transaction and the index of the transaction in the block.
+	Height  uint32
+	TxIndex uint32
+
+	// Received is the total amount of the outputs of the transaction
+	// paying to the address, and Sent the total amount of its inputs
+	// spending from the address.
+	Received int64
+	Sent     int64
+}
+
+// TxsForAddressBefore returns at most the passed number of the transactions of
+// the main chain changing the balance of the passed address, newest first,
+// starting right before the transaction at the passed height and index in its
+// block.  Passing the maximum height and index starts with the newest
+// transaction.  It also returns whether there are older transactions, which
+// are returned by a call starting before the last transaction returned.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) TxsForAddressBefore(addr provautil.Address, height, txIndex uint32, limit int) ([]AddrTx, bool, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var txs []AddrTx
+	var more bool
+	err = idx.db.View(func(dbTx database.Tx) error {
+		// Position the cursor at the first delta at or after the
+		// passed position and step back to the delta before it, which
+		// is the newest delta before the position when the address
+		// has any.
+		seek := make([]byte, addrKeySize+8)
+		copy(seek, addrKey[:])
+		keyOrder.PutUint32(seek[addrKeySize:], height)
+		keyOrder.PutUint32(seek[addrKeySize+4:], txIndex)
+		cursor := dbTx.Metadata().Bucket(addrDeltaIndexKey).Cursor()
+		var ok bool
+		if !cursor.Seek(seek) {
+			ok = cursor.Last()
+		} else {
+			ok = cursor.Prev()
+		}
+
+		// The deltas of a transaction are adjacent, so they are added
+		// up until the deltas of another transaction are reached.
+		for ; ok; ok = cursor.Prev() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, addrKey[:]) {
+				break
+			}
+
+			var delta AddrDelta
+			err := deserializeAddrDelta(key, cursor.Value(), &delta)
+			if err != nil {
+				return err
+			}
+			last := len(txs) - 1
+			if last < 0 || txs[last].Height != delta.Height ||
+				txs[last].TxIndex != delta.TxIndex {
+
+				if len(txs) == limit {
+					more = true
+					break
+				}
+				txs = append(txs, AddrTx{
+					TxHash:  delta.TxHash,
+					Height:  delta.Height,
+					TxIndex: delta.TxIndex,
+				})
+				last++
+			}
+			if delta.Spending {
+				txs[last].Sent -= delta.Amount
+			} else {
+				txs[last].Received += delta.Amount
+			}
+		}
+		return nil
+	})
+	return txs, more, err
+}
+
+// BalanceForAddress returns the balance of the passed address along with the
+// total amount it has received, as of the current best block.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) BalanceForAddress(addr provautil.Address) (int64, int64, error) {
+	deltas, err := idx.DeltasForAddress(addr, 0, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var balance, received int64
+	for _, delta := range deltas {
+		balance += delta.Amount
+		if !delta.Spending {
+			received += delta.Amount
+		}
+	}
+	return balance, received, nil
+}
+
+// UtxosForAddress returns the unspent outputs paying to the passed address as
+// of the current best block.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) UtxosForAddress(addr provautil.Address) ([]AddrUtxo, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []AddrUtxo
+	err = idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrUtxoIndexKey).Cursor()
+		for ok := cursor.Seek(addrKey[:]); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, addrKey[:]) {
+				break
+			}
+
+			var utxo AddrUtxo
+			err := deserializeAddrUtxo(key, cursor.Value(), &utxo)
+			if err != nil {
+				return err
+			}
+			utxos = append(utxos, utxo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the outputs in the order they were created.
+	sort.SliceStable(utxos, func(i, j int) bool {
+		return utxos[i].Height < utxos[j].Height
+	})
+	return utxos, nil
+}
+
+// indexUnconfirmedDeltas adds the changes to the balances of the addresses
+// involved in the passed unconfirmed transaction to the unconfirmed
+// (memory-only) address index.
+//
+// This function MUST be called with the unconfirmed lock held for writes.
+func (idx *AddrIndex) indexUnconfirmedDeltas(tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	addDelta := func(pkScript []byte, delta AddrDelta) {
+		for _, addrKey := range idx.addrKeysForPkScript(pkScript) {
+			addrDeltas := idx.deltasByAddr[addrKey]
+			if addrDeltas == nil {
+				addrDeltas = make(map[chainhash.Hash][]AddrDelta)
+				idx.deltasByAddr[addrKey] = addrDeltas
+			}
+			addrDeltas[delta.TxHash] = append(addrDeltas[delta.TxHash],
+				delta)
+		}
+	}
+
+	for txInIdx, txIn := range tx.MsgTx().TxIn {
+		origin := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(&origin.Hash)
+		if entry == nil {
+			continue
+		}
+		addDelta(entry.PkScriptByIndex(origin.Index), AddrDelta{
+			TxHash:   *tx.Hash(),
+			Index:    uint32(txInIdx),
+			Spending: true,
+			Amount:   -entry.AmountByIndex(origin.Index),
+			PrevOut:  &origin,
+		})
+	}
+	for txOutIdx, txOut := range tx.MsgTx().TxOut {
+		addDelta(txOut.PkScript, AddrDelta{
+			TxHash: *tx.Hash(),
+			Index:  uint32(txOutIdx),
+			Amount: txOut.Value,
+		})
+	}
+}
+
+// UnconfirmedDeltasForAddress returns the changes to the balance of the passed
+// address made by the transactions currently in the unconfirmed (memory-only)
+// address index.  Unsupported address types are ignored and will result in no
+// results.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) UnconfirmedDeltasForAddress(addr provautil.Address) []AddrDelta {
+	// Ignore unsupported address types.
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil
+	}
+
+	idx.unconfirmedLock.RLock()
+	defer idx.unconfirmedLock.RUnlock()
+
+	var deltas []AddrDelta
+	for _, txDeltas := range idx.deltasByAddr[addrKey] {
+		deltas = append(deltas, txDeltas...)
+	}
+	return deltas
+}
+
+// createAddrDeltaBuckets creates the buckets for the address deltas and utxos.
+func createAddrDeltaBuckets(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	if _, err := meta.CreateBucket(addrDeltaIndexKey); err != nil {
+		return err
+	}
+	_, err := meta.CreateBucket(addrUtxoIndexKey)
+	return err
+}
+
+// checkAddrDeltaBuckets returns an error when the address index exists without
+// the buckets for the address deltas and utxos, which is the case for address
+// indexes created before they were added.
+func checkAddrDeltaBuckets(db database.DB) error {
+	return db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(addrIndexKey) == nil ||
+			meta.Bucket(addrDeltaIndexKey) != nil {
+
+			return nil
+		}
+		return fmt.Errorf("the %s was created by an older version "+
+			"and must be rebuilt -- drop it with --dropaddrindex "+
+			"and restart with --addrindex", addrIndexName)
+	})
+}
+
+// dropAddrDeltaBuckets drops the buckets for the address deltas and utxos when
+// they exist.
+func dropAddrDeltaBuckets(db database.DB) error {
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		for _, key := range [][]byte{addrDeltaIndexKey, addrUtxoIndexKey} {
+			if meta.Bucket(key) == nil {
+				continue
+			}
+			if err := meta.DeleteBucket(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
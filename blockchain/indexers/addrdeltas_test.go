@@ -0,0 +1,181 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAddrDeltas ensures the address deltas and utxos follow the blocks which
+// are connected and disconnected, and that the balance of an address is
+// derived from its deltas.
+func TestAddrDeltas(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "addrdeltas")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewAddrIndex(db, params)
+	addr, err := provautil.NewAddressProva(bytes.Repeat([]byte{0x42}, 20),
+		[]btcec.KeyID{1, 2}, params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	// The first block pays 50 to the address and the second spends it,
+	// paying 20 back to the address.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(50, pkScript))
+	block1 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase}})
+	block1.SetHeight(1)
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: coinbase.TxHash()},
+		nil))
+	spend.AddTxOut(wire.NewTxOut(20, pkScript))
+	coinbase2 := wire.NewMsgTx(1)
+	coinbase2.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x01}))
+	block2 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase2, spend}})
+	block2.SetHeight(2)
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(provautil.NewTx(coinbase), 1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.connectBlockDeltas(dbTx, block1, view); err != nil {
+			return err
+		}
+		return idx.connectBlockDeltas(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("connectBlockDeltas: unexpected error: %v", err)
+	}
+
+	deltas, err := idx.DeltasForAddress(addr, 0, 0)
+	if err != nil {
+		t.Fatalf("DeltasForAddress: unexpected error: %v", err)
+	}
+	wantDeltas := []AddrDelta{
+		{TxHash: coinbase.TxHash(), Height: 1, Amount: 50},
+		{TxHash: spend.TxHash(), Height: 2, TxIndex: 1, Amount: 20},
+		{TxHash: spend.TxHash(), Height: 2, TxIndex: 1, Spending: true,
+			Amount: -50},
+	}
+	if len(deltas) != len(wantDeltas) {
+		t.Fatalf("DeltasForAddress: got %d deltas, want %d",
+			len(deltas), len(wantDeltas))
+	}
+	for i := range deltas {
+		if deltas[i] != wantDeltas[i] {
+			t.Errorf("DeltasForAddress #%d: got %+v, want %+v", i,
+				deltas[i], wantDeltas[i])
+		}
+	}
+	deltas, err = idx.DeltasForAddress(addr, 2, 2)
+	if err != nil {
+		t.Fatalf("DeltasForAddress: unexpected error: %v", err)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("DeltasForAddress: got %d deltas for block 2, want 2",
+			len(deltas))
+	}
+
+	balance, received, err := idx.BalanceForAddress(addr)
+	if err != nil {
+		t.Fatalf("BalanceForAddress: unexpected error: %v", err)
+	}
+	if balance != 20 || received != 70 {
+		t.Errorf("BalanceForAddress: got %d/%d, want 20/70", balance,
+			received)
+	}
+
+	utxos, err := idx.UtxosForAddress(addr)
+	if err != nil {
+		t.Fatalf("UtxosForAddress: unexpected error: %v", err)
+	}
+	if len(utxos) != 1 || utxos[0].OutPoint.Hash != spend.TxHash() ||
+		utxos[0].Amount != 20 || utxos[0].Height != 2 ||
+		!bytes.Equal(utxos[0].PkScript, pkScript) {
+
+		t.Fatalf("UtxosForAddress: got %+v, want the output of the "+
+			"spending transaction", utxos)
+	}
+
+	// Disconnecting the second block restores the spent output.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.disconnectBlockDeltas(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("disconnectBlockDeltas: unexpected error: %v", err)
+	}
+	utxos, err = idx.UtxosForAddress(addr)
+	if err != nil {
+		t.Fatalf("UtxosForAddress: unexpected error: %v", err)
+	}
+	wantOutPoint := wire.OutPoint{Hash: coinbase.TxHash()}
+	if len(utxos) != 1 || utxos[0].OutPoint != wantOutPoint ||
+		utxos[0].Amount != 50 || utxos[0].Height != 1 {
+
+		t.Fatalf("UtxosForAddress: got %+v, want the coinbase output",
+			utxos)
+	}
+	balance, received, err = idx.BalanceForAddress(addr)
+	if err != nil {
+		t.Fatalf("BalanceForAddress: unexpected error: %v", err)
+	}
+	if balance != 50 || received != 50 {
+		t.Errorf("BalanceForAddress: got %d/%d, want 50/50", balance,
+			received)
+	}
+
+	// The unconfirmed deltas follow the memory pool.
+	idx.AddUnconfirmedTx(provautil.NewTx(spend), view)
+	unconfirmed := idx.UnconfirmedDeltasForAddress(addr)
+	if len(unconfirmed) != 2 {
+		t.Fatalf("UnconfirmedDeltasForAddress: got %d deltas, want 2",
+			len(unconfirmed))
+	}
+	idx.RemoveUnconfirmedTx(&chainhash.Hash{})
+	spendHash := spend.TxHash()
+	idx.RemoveUnconfirmedTx(&spendHash)
+	if unconfirmed := idx.UnconfirmedDeltasForAddress(addr); len(unconfirmed) != 0 {
+		t.Errorf("UnconfirmedDeltasForAddress: got %d deltas after "+
+			"removal, want 0", len(unconfirmed))
+	}
+}
@@ -573,6 +573,14 @@ func addrToKey(addr provautil.Address) ([addrKeySize]byte, error) {
 		result[0] = addrKeyTypePubKeyHash
 		copy(result[1:], addr.ScriptAddress()[:])
 		return result, nil
+
+	case *provautil.AddressGeneralProva:
+		// Generalized addresses are not identified by a single hash,
+		// so they are keyed by the hash of their encoded bytes.
+		var result [addrKeySize]byte
+		result[0] = addrKeyTypeScriptHash
+		copy(result[1:], provautil.Hash160(addr.ScriptAddress()))
+		return result, nil
 	}
 
 	return [addrKeySize]byte{}, errUnsupportedAddressType
@@ -607,9 +615,13 @@ type AddrIndex struct {
 	// keep an index of all addresses which a given transaction involves.
 	// This allows fairly efficient updates when transactions are removed
 	// once they are included into a block.
+	//
+	// The deltasByAddr field keeps the changes to the balance of a given
+	// address made by each transaction keyed by the address.
 	unconfirmedLock sync.RWMutex
 	txnsByAddr      map[[addrKeySize]byte]map[chainhash.Hash]*provautil.Tx
 	addrsByTx       map[chainhash.Hash]map[[addrKeySize]byte]struct{}
+	deltasByAddr    map[[addrKeySize]byte]map[chainhash.Hash][]AddrDelta
 }
 
 // Ensure the AddrIndex type implements the Indexer interface.
@@ -626,13 +638,12 @@ func (idx *AddrIndex) NeedsInputs() bool {
 	return true
 }
 
-// Init is only provided to satisfy the Indexer interface as there is nothing to
-// initialize for this index.
+// Init ensures an existing index includes the address deltas and utxos, since
+// indexes created before they were added need to be rebuilt.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) Init() error {
-	// Nothing to do.
-	return nil
+	return checkAddrDeltaBuckets(idx.db)
 }
 
 // Key returns the database key to use for the index as a byte slice.
@@ -650,13 +661,16 @@ func (idx *AddrIndex) Name() string {
 }
 
 // Create is invoked when the indexer manager determines the index needs
-// to be created for the first time.  It creates the bucket for the address
-// index.
+// to be created for the first time.  It creates the buckets for the address
+// index along with the address deltas and utxos.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) Create(dbTx database.Tx) error {
 	_, err := dbTx.Metadata().CreateBucket(addrIndexKey)
-	return err
+	if err != nil {
+		return err
+	}
+	return createAddrDeltaBuckets(dbTx)
 }
 
 // writeIndexData represents the address index data to be written for one block.
@@ -731,7 +745,8 @@ func (idx *AddrIndex) indexBlock(data writeIndexData, block *provautil.Block, vi
 
 // ConnectBlock is invoked by the index manager when a new block has been
 // connected to the main chain.  This indexer adds a mapping for each address
-// the transactions in the block involve.
+// the transactions in the block involve, along with the changes they make to
+// the balance and unspent outputs of each address.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
@@ -764,7 +779,7 @@ func (idx *AddrIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, vie
 		}
 	}
 
-	return nil
+	return idx.connectBlockDeltas(dbTx, block, view)
 }
 
 // DisconnectBlock is invoked by the index manager when a block has been
@@ -786,7 +801,7 @@ func (idx *AddrIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block,
 		}
 	}
 
-	return nil
+	return idx.disconnectBlockDeltas(dbTx, block, view)
 }
 
 // BoundedTxRegionsForAddress returns a slice of block regions which identify
@@ -930,6 +945,11 @@ func (idx *AddrIndex) AddUnconfirmedTx(tx *provautil.Tx, utxoView *blockchain.Ut
 	for _, txOut := range tx.MsgTx().TxOut {
 		idx.indexUnconfirmedAddresses(txOut.PkScript, tx)
 	}
+
+	// Index the changes to the balances of the addresses.
+	idx.unconfirmedLock.Lock()
+	idx.indexUnconfirmedDeltas(tx, utxoView)
+	idx.unconfirmedLock.Unlock()
 }
 
 // RemoveUnconfirmedTx removes the passed transaction from the unconfirmed
@@ -948,6 +968,10 @@ func (idx *AddrIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
 		if len(idx.txnsByAddr[addrKey]) == 0 {
 			delete(idx.txnsByAddr, addrKey)
 		}
+		delete(idx.deltasByAddr[addrKey], *hash)
+		if len(idx.deltasByAddr[addrKey]) == 0 {
+			delete(idx.deltasByAddr, addrKey)
+		}
 	}
 
 	// Remove the entry from the transaction to address lookup map as well.
@@ -992,15 +1016,16 @@ func (idx *AddrIndex) UnconfirmedTxnsForAddress(addr provautil.Address) []*prova
 // seamlessly maintained along with the chain.
 func NewAddrIndex(db database.DB, chainParams *chaincfg.Params) *AddrIndex {
 	return &AddrIndex{
-		db:          db,
-		chainParams: chainParams,
-		txnsByAddr:  make(map[[addrKeySize]byte]map[chainhash.Hash]*provautil.Tx),
-		addrsByTx:   make(map[chainhash.Hash]map[[addrKeySize]byte]struct{}),
+		db:           db,
+		chainParams:  chainParams,
+		txnsByAddr:   make(map[[addrKeySize]byte]map[chainhash.Hash]*provautil.Tx),
+		addrsByTx:    make(map[chainhash.Hash]map[[addrKeySize]byte]struct{}),
+		deltasByAddr: make(map[[addrKeySize]byte]map[chainhash.Hash][]AddrDelta),
 	}
 }
 
 // DropAddrIndex drops the address index from the provided database if it
 // exists.
 func DropAddrIndex(db database.DB) error {
-	return dropIndex(db, addrIndexKey, addrIndexName)
+	return dropIndex(db, addrIndexKey, addrIndexName, nil)
 }
@@ -10,6 +10,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -275,3 +278,45 @@ nextTest:
 		}
 	}
 }
+
+// TestAddrToKeyEncodings ensures addresses map to the same address index key
+// regardless of their string encoding.
+func TestAddrToKeyEncodings(t *testing.T) {
+	pkHash := bytes.Repeat([]byte{0x42}, 20)
+	provaAddr, err := provautil.NewAddressProva(pkHash,
+		[]btcec.KeyID{1, 2}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	generalAddr, err := provautil.NewAddressGeneralProva(2,
+		[][]byte{pkHash}, []btcec.KeyID{1, 2}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressGeneralProva: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		base58 provautil.Address
+		bech32 provautil.Address
+	}{
+		{provaAddr, provaAddr.Bech32(&chaincfg.TestNetParams)},
+		{generalAddr, generalAddr.Bech32(&chaincfg.TestNetParams)},
+	}
+	for i, test := range tests {
+		decoded, err := provautil.DecodeAddress(
+			test.bech32.EncodeAddress(), &chaincfg.TestNetParams)
+		if err != nil {
+			t.Fatalf("DecodeAddress #%d: unexpected error: %v", i, err)
+		}
+		want, err := addrToKey(test.base58)
+		if err != nil {
+			t.Fatalf("addrToKey #%d: unexpected error: %v", i, err)
+		}
+		got, err := addrToKey(decoded)
+		if err != nil {
+			t.Fatalf("addrToKey #%d: unexpected error: %v", i, err)
+		}
+		if got != want {
+			t.Fatalf("addrToKey #%d: got %x, want %x", i, got, want)
+		}
+	}
+}
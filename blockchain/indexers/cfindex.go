@@ -0,0 +1,263 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+)
+
+const (
+	// cfIndexName is the human-readable name for the index.
+	cfIndexName = "committed filter index"
+)
+
+var (
+	// cfIndexKey is the key of the committed filter index and the db
+	// bucket used to house it.
+	cfIndexKey = []byte("cfbyhashidx")
+)
+
+// -----------------------------------------------------------------------------
+// The committed filter index consists of an entry for every block in the main
+// chain which holds the regular compact filter of the block (BIP0158) along
+// with its hash and its filter header.  The filter header commits to the
+// filter and to the filter header of the previous block, so light clients can
+// verify the filters they are served against the filter headers (BIP0157).
+//
+// The serialized format for keys and values in the committed filter index
+// bucket is:
+//
+//   <block hash> = <filter header><filter hash><filter>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   filter header   chainhash.Hash    32 bytes
+//   filter hash     chainhash.Hash    32 bytes
+//   filter          []byte            variable
+// -----------------------------------------------------------------------------
+
+// cfEntryFilterOffset is the offset of the serialized filter in an entry of
+// the committed filter index.
+const cfEntryFilterOffset = 2 * chainhash.HashSize
+
+// dbFetchCfIndexEntry uses an existing database transaction to fetch the
+// serialized committed filter index entry of the block with the passed hash.
+// When there is no entry for the provided hash, nil will be returned for the
+// both the entry and the error.
+func dbFetchCfIndexEntry(dbTx database.Tx, hash *chainhash.Hash) ([]byte, error) {
+	entry := dbTx.Metadata().Bucket(cfIndexKey).Get(hash[:])
+	if entry == nil {
+		return nil, nil
+	}
+
+	// Ensure the serialized data has enough bytes to properly deserialize.
+	if len(entry) < cfEntryFilterOffset {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt committed filter "+
+				"index entry for %s", hash),
+		}
+	}
+	return entry, nil
+}
+
+// CfIndex implements a committed filter by block hash index.  That is to say,
+// it supports querying the compact filter, filter hash and filter header of
+// every block in the main chain by the hash of the block.
+type CfIndex struct {
+	db database.DB
+}
+
+// Ensure the CfIndex type implements the Indexer interface.
+var _ Indexer = (*CfIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Key() []byte {
+	return cfIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Name() string {
+	return cfIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the committed
+// filter index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(cfIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer builds the compact filter of the
+// block and stores it along with its hash and filter header.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	filter, err := builder.BuildBasicFilter(block.MsgBlock())
+	if err != nil {
+		return err
+	}
+	filterBytes, err := filter.NBytes()
+	if err != nil {
+		return err
+	}
+	filterHash := chainhash.DoubleHashH(filterBytes)
+
+	// The filter header of the genesis block commits to an all zero
+	// previous filter header.
+	var prevHeader chainhash.Hash
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	if *prevHash != (chainhash.Hash{}) {
+		prevEntry, err := dbFetchCfIndexEntry(dbTx, prevHash)
+		if err != nil {
+			return err
+		}
+		if prevEntry == nil {
+			return AssertError(fmt.Sprintf("connecting block %s "+
+				"without committed filter of previous block %s",
+				block.Hash(), prevHash))
+		}
+		copy(prevHeader[:], prevEntry[:chainhash.HashSize])
+	}
+	header := builder.MakeHeaderForFilterHash(filterHash, prevHeader)
+
+	entry := make([]byte, cfEntryFilterOffset+len(filterBytes))
+	copy(entry, header[:])
+	copy(entry[chainhash.HashSize:], filterHash[:])
+	copy(entry[cfEntryFilterOffset:], filterBytes)
+	return dbTx.Metadata().Bucket(cfIndexKey).Put(block.Hash()[:], entry)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the committed filter
+// of the block.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return dbTx.Metadata().Bucket(cfIndexKey).Delete(block.Hash()[:])
+}
+
+// entriesByBlockHashes returns the part of the committed filter index entries
+// of the blocks with the passed hashes which the passed function selects.  The
+// result for blocks which are not indexed is nil.
+func (idx *CfIndex) entriesByBlockHashes(hashes []*chainhash.Hash, selectFn func(entry []byte) []byte) ([][]byte, error) {
+	results := make([][]byte, len(hashes))
+	err := idx.db.View(func(dbTx database.Tx) error {
+		for i, hash := range hashes {
+			entry, err := dbFetchCfIndexEntry(dbTx, hash)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				continue
+			}
+
+			// The entry is only valid during the database
+			// transaction, so it has to be copied.
+			selected := selectFn(entry)
+			results[i] = make([]byte, len(selected))
+			copy(results[i], selected)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// FiltersByBlockHashes returns the serialized compact filters of the blocks
+// with the passed hashes.  The filter of a block which is not indexed is nil.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) FiltersByBlockHashes(hashes []*chainhash.Hash) ([][]byte, error) {
+	return idx.entriesByBlockHashes(hashes, func(entry []byte) []byte {
+		return entry[cfEntryFilterOffset:]
+	})
+}
+
+// FilterHashesByBlockHashes returns the filter hashes of the blocks with the
+// passed hashes.  The filter hash of a block which is not indexed is nil.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) FilterHashesByBlockHashes(hashes []*chainhash.Hash) ([][]byte, error) {
+	return idx.entriesByBlockHashes(hashes, func(entry []byte) []byte {
+		return entry[chainhash.HashSize:cfEntryFilterOffset]
+	})
+}
+
+// FilterHeadersByBlockHashes returns the filter headers of the blocks with
+// the passed hashes.  The filter header of a block which is not indexed is
+// nil.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) FilterHeadersByBlockHashes(hashes []*chainhash.Hash) ([][]byte, error) {
+	return idx.entriesByBlockHashes(hashes, func(entry []byte) []byte {
+		return entry[:chainhash.HashSize]
+	})
+}
+
+// FilterByBlockHash returns the serialized compact filter of the block with
+// the passed hash.  When the block is not indexed, nil will be returned for
+// both the filter and the error.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) FilterByBlockHash(hash *chainhash.Hash) ([]byte, error) {
+	filters, err := idx.FiltersByBlockHashes([]*chainhash.Hash{hash})
+	if err != nil {
+		return nil, err
+	}
+	return filters[0], nil
+}
+
+// FilterHeaderByBlockHash returns the filter header of the block with the
+// passed hash.  When the block is not indexed, nil will be returned for both
+// the header and the error.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) FilterHeaderByBlockHash(hash *chainhash.Hash) ([]byte, error) {
+	headers, err := idx.FilterHeadersByBlockHashes([]*chainhash.Hash{hash})
+	if err != nil {
+		return nil, err
+	}
+	return headers[0], nil
+}
+
+// NewCfIndex returns a new instance of an indexer that is used to create a
+// mapping of the hashes of all blocks in the blockchain to their compact
+// filters, filter hashes and filter headers.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewCfIndex(db database.DB) *CfIndex {
+	return &CfIndex{db: db}
+}
+
+// DropCfIndex drops the committed filter index from the provided database if
+// it exists.
+func DropCfIndex(db database.DB) error {
+	return dropIndex(db, cfIndexKey, cfIndexName, nil)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestCfIndex ensures the committed filter index stores the filters of
+// connected blocks with filter headers which chain to the previous block, and
+// removes them again when the blocks are disconnected.
+func TestCfIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "cfindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewCfIndex(db)
+	genesis := provautil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	childMsg := wire.NewMsgBlock(wire.NewBlockHeader(genesis.Hash(),
+		&chainhash.Hash{}, 0, 0))
+	childMsg.AddTransaction(genesis.MsgBlock().Transactions[0])
+	child := provautil.NewBlock(childMsg)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.ConnectBlock(dbTx, genesis, nil); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, child, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	// The stored filters and headers match the filters of the blocks.
+	var prevHeader chainhash.Hash
+	for _, block := range []*provautil.Block{genesis, child} {
+		filter, err := builder.BuildBasicFilter(block.MsgBlock())
+		if err != nil {
+			t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+		}
+		wantFilter, _ := filter.NBytes()
+		wantHeader, _ := builder.MakeHeaderForFilter(filter, prevHeader)
+		prevHeader = wantHeader
+
+		gotFilter, err := idx.FilterByBlockHash(block.Hash())
+		if err != nil {
+			t.Fatalf("FilterByBlockHash: unexpected error: %v", err)
+		}
+		if !bytes.Equal(gotFilter, wantFilter) {
+			t.Errorf("FilterByBlockHash: got %x, want %x", gotFilter,
+				wantFilter)
+		}
+		gotHeader, err := idx.FilterHeaderByBlockHash(block.Hash())
+		if err != nil {
+			t.Fatalf("FilterHeaderByBlockHash: unexpected error: %v",
+				err)
+		}
+		if !bytes.Equal(gotHeader, wantHeader[:]) {
+			t.Errorf("FilterHeaderByBlockHash: got %x, want %v",
+				gotHeader, wantHeader)
+		}
+	}
+
+	// Disconnected blocks are no longer indexed.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, child, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	filters, err := idx.FiltersByBlockHashes([]*chainhash.Hash{
+		genesis.Hash(), child.Hash()})
+	if err != nil {
+		t.Fatalf("FiltersByBlockHashes: unexpected error: %v", err)
+	}
+	if filters[0] == nil || filters[1] != nil {
+		t.Errorf("FiltersByBlockHashes: got %x, want only the genesis "+
+			"filter", filters)
+	}
+}
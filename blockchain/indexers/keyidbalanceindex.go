@@ -0,0 +1,511 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// keyIDBalanceIndexName is the human-readable name for the index.
+	keyIDBalanceIndexName = "keyID balance index"
+
+	// keyIDBalanceKeySize is the number of bytes a key in the keyID balance
+	// index consumes.  It consists of 4 bytes keyID + 4 bytes block height.
+	keyIDBalanceKeySize = 4 + 4
+
+	// keyIDBalanceValueSize is the number of bytes a value in the keyID
+	// balance index consumes.  It consists of 8 bytes balance.
+	keyIDBalanceValueSize = 8
+
+	// keyIDUtxoKeySize is the number of bytes a key in the keyID utxo
+	// bucket consumes.  It consists of 4 bytes keyID + the transaction hash
+	// + 4 bytes output index.
+	keyIDUtxoKeySize = 4 + chainhash.HashSize + 4
+)
+
+var (
+	// keyIDBalanceIndexKey is the key of the keyID balance index and the
+	// db bucket used to house it.
+	keyIDBalanceIndexKey = []byte("keyidbalanceidx")
+
+	// keyIDUtxoIndexKey is the key of the db bucket used to house the
+	// unspent outputs co-signed by each keyID.
+	keyIDUtxoIndexKey = []byte("keyidutxoidx")
+)
+
+// -----------------------------------------------------------------------------
+// The keyID balance index keeps the confirmed balance of the outputs co-signed
+// by each keyID, that is to say the outputs in the main chain which can only
+// be spent with the signature of the account service provider key the keyID
+// refers to.  The value of an output co-signed by more than one keyID counts
+// towards the balance of each of them.
+//
+// There is an entry for every block which changes the balance of a keyID,
+// holding the balance after the block.  The balance of a keyID at a height is
+// the balance of its entry with the greatest height not above it, so historic
+// balances are available without replaying the chain.  The numeric fields of
+// the keys are big endian so the entries of a keyID sort by height.
+//
+// The serialized format for keys in the keyID balance index bucket is:
+//
+//   <keyID><block height>
+//
+//   Field           Type      Size
+//   keyID           uint32    4 bytes
+//   block height    uint32    4 bytes
+//   -----
+//   Total: 8 bytes
+//
+// The serialized value format is:
+//
+//   <balance>
+//
+//   Field      Type     Size
+//   balance    int64    8 bytes
+//   -----
+//   Total: 8 bytes
+//
+// The index also keeps the unspent outputs co-signed by each keyID in an
+// additional bucket, so the funds bound to a keyID can be found when its key
+// is rotated.  The serialized key format of the utxo bucket is:
+//
+//   <keyID><tx hash><index>
+//
+//   Field           Type           Size
+//   keyID           uint32         4 bytes
+//   tx hash         chainhash.Hash 32 bytes
+//   index           uint32         4 bytes
+//   -----
+//   Total: 40 bytes
+//
+// The serialized value format is the same as the one of the address utxo
+// bucket:
+//
+//   <amount><block height><pk script>
+// -----------------------------------------------------------------------------
+
+// keyIDBalanceKey returns the key of the keyID balance index for the passed
+// keyID and block height.
+func keyIDBalanceKey(keyID btcec.KeyID, height uint32) []byte {
+	key := make([]byte, keyIDBalanceKeySize)
+	keyOrder.PutUint32(key, uint32(keyID))
+	keyOrder.PutUint32(key[4:], height)
+	return key
+}
+
+// dbFetchKeyIDBalance uses an existing database transaction to fetch the
+// balance of the passed keyID after the block at the passed height.  Zero is
+// returned for keyIDs which have no balance at that height.
+func dbFetchKeyIDBalance(dbTx database.Tx, keyID btcec.KeyID, height uint32) (int64, error) {
+	// Position the cursor at the first entry above the height and step
+	// back to the entry before it, which is the last entry of the keyID
+	// not above the height when the keyID has any.
+	cursor := dbTx.Metadata().Bucket(keyIDBalanceIndexKey).Cursor()
+	var ok bool
+	if height == ^uint32(0) || !cursor.Seek(keyIDBalanceKey(keyID, height+1)) {
+		ok = cursor.Last()
+	} else {
+		ok = cursor.Prev()
+	}
+	if !ok {
+		return 0, nil
+	}
+	prefix := keyIDBalanceKey(keyID, 0)[:4]
+	if !bytes.HasPrefix(cursor.Key(), prefix) {
+		return 0, nil
+	}
+
+	// Ensure the serialized data has enough bytes to properly deserialize.
+	value := cursor.Value()
+	if len(value) < keyIDBalanceValueSize {
+		return 0, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt keyID balance index "+
+				"entry for keyID %d", keyID),
+		}
+	}
+	return int64(byteOrder.Uint64(value)), nil
+}
+
+// keyIDUtxoKey returns the key of the utxo bucket for the passed keyID and
+// outpoint.
+func keyIDUtxoKey(keyID btcec.KeyID, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, keyIDUtxoKeySize)
+	keyOrder.PutUint32(key, uint32(keyID))
+	copy(key[4:], outPoint.Hash[:])
+	keyOrder.PutUint32(key[4+chainhash.HashSize:], outPoint.Index)
+	return key
+}
+
+// keyIDsForPkScript returns the distinct keyIDs co-signing the passed Prova
+// output script.  Nil is returned for any other kind of script, such as the
+// scripts of the admin threads.
+func keyIDsForPkScript(pkScript []byte) []btcec.KeyID {
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return nil
+	}
+	switch txscript.TypeOfScript(pops) {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy, txscript.GeneralProvaTy:
+	default:
+		return nil
+	}
+	keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+	if err != nil {
+		return nil
+	}
+
+	// A keyID which co-signs an output more than once only holds its value
+	// once.
+	distinct := keyIDs[:0]
+	for i, keyID := range keyIDs {
+		seen := false
+		for _, prev := range keyIDs[:i] {
+			if prev == keyID {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			distinct = append(distinct, keyID)
+		}
+	}
+	return distinct
+}
+
+// KeyIDBalanceIndex implements a confirmed balance by keyID index.  That is to
+// say, it supports querying the value of the outputs in the main chain which
+// are co-signed by an account service provider key, both at the best height
+// and at any past height, which is needed for compliance reporting.
+type KeyIDBalanceIndex struct {
+	db database.DB
+}
+
+// Ensure the KeyIDBalanceIndex type implements the Indexer interface.
+var _ Indexer = (*KeyIDBalanceIndex)(nil)
+
+// Ensure the KeyIDBalanceIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*KeyIDBalanceIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *KeyIDBalanceIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init ensures an existing index includes the keyID utxos, since indexes
+// created before they were added need to be rebuilt.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) Init() error {
+	return idx.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(keyIDBalanceIndexKey) == nil ||
+			meta.Bucket(keyIDUtxoIndexKey) != nil {
+
+			return nil
+		}
+		return fmt.Errorf("the %s was created by an older version "+
+			"and must be rebuilt -- drop it with "+
+			"--dropkeyidbalanceindex and restart with "+
+			"--keyidbalanceindex", keyIDBalanceIndexName)
+	})
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) Key() []byte {
+	return keyIDBalanceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) Name() string {
+	return keyIDBalanceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the keyID
+// balances and utxos.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	if _, err := meta.CreateBucket(keyIDBalanceIndexKey); err != nil {
+		return err
+	}
+	_, err := meta.CreateBucket(keyIDUtxoIndexKey)
+	return err
+}
+
+// blockKeyIDDeltas returns the change to the balance of every keyID co-signing
+// an output spent or created by the transactions in the passed block.  The
+// passed view must contain the outputs spent by the block.
+func blockKeyIDDeltas(block *provautil.Block, view *blockchain.UtxoViewpoint) map[btcec.KeyID]int64 {
+	deltas := make(map[btcec.KeyID]int64)
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				// The view should always have the input since
+				// the index contract requires it, however, be
+				// safe and simply ignore any missing entries.
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					continue
+				}
+
+				pkScript := entry.PkScriptByIndex(origin.Index)
+				amount := entry.AmountByIndex(origin.Index)
+				for _, keyID := range keyIDsForPkScript(pkScript) {
+					deltas[keyID] -= amount
+				}
+			}
+		}
+
+		for _, txOut := range tx.MsgTx().TxOut {
+			for _, keyID := range keyIDsForPkScript(txOut.PkScript) {
+				deltas[keyID] += txOut.Value
+			}
+		}
+	}
+	return deltas
+}
+
+// connectKeyIDUtxos updates the utxo bucket for the outputs created and spent
+// by the transactions of the passed block.
+func connectKeyIDUtxos(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDUtxoIndexKey)
+	height := uint32(block.Height())
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					continue
+				}
+
+				pkScript := entry.PkScriptByIndex(origin.Index)
+				for _, keyID := range keyIDsForPkScript(pkScript) {
+					err := bucket.Delete(keyIDUtxoKey(keyID,
+						origin))
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outPoint := wire.OutPoint{Hash: *tx.Hash(),
+				Index: uint32(txOutIdx)}
+			utxo := AddrUtxo{
+				Amount:   txOut.Value,
+				PkScript: txOut.PkScript,
+				Height:   height,
+			}
+			for _, keyID := range keyIDsForPkScript(txOut.PkScript) {
+				err := bucket.Put(keyIDUtxoKey(keyID, &outPoint),
+					serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// disconnectKeyIDUtxos reverts the changes to the utxo bucket made when the
+// passed block was connected.  The transactions are processed in reverse
+// order so outputs which are both created and spent in the block are removed.
+func disconnectKeyIDUtxos(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDUtxoIndexKey)
+	txns := block.Transactions()
+	for txIdx := len(txns) - 1; txIdx >= 0; txIdx-- {
+		tx := txns[txIdx]
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outPoint := wire.OutPoint{Hash: *tx.Hash(),
+				Index: uint32(txOutIdx)}
+			for _, keyID := range keyIDsForPkScript(txOut.PkScript) {
+				err := bucket.Delete(keyIDUtxoKey(keyID, &outPoint))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			origin := &txIn.PreviousOutPoint
+			entry := view.LookupEntry(&origin.Hash)
+			if entry == nil {
+				continue
+			}
+
+			utxo := AddrUtxo{
+				Amount:   entry.AmountByIndex(origin.Index),
+				PkScript: entry.PkScriptByIndex(origin.Index),
+				Height:   entry.BlockHeight(),
+			}
+			for _, keyID := range keyIDsForPkScript(utxo.PkScript) {
+				err := bucket.Put(keyIDUtxoKey(keyID, origin),
+					serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry with the new
+// balance of every keyID whose balance is changed by the block, and updates
+// the unspent outputs of the keyIDs.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDBalanceIndexKey)
+	height := uint32(block.Height())
+	for keyID, delta := range blockKeyIDDeltas(block, view) {
+		if delta == 0 {
+			continue
+		}
+
+		balance, err := dbFetchKeyIDBalance(dbTx, keyID, height)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, keyIDBalanceValueSize)
+		byteOrder.PutUint64(value, uint64(balance+delta))
+		err = bucket.Put(keyIDBalanceKey(keyID, height), value)
+		if err != nil {
+			return err
+		}
+	}
+	return connectKeyIDUtxos(dbTx, block, view)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries of the
+// keyIDs whose balance was changed by the block, and reverts the changes to
+// the unspent outputs of the keyIDs.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDBalanceIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDBalanceIndexKey)
+	height := uint32(block.Height())
+	for keyID := range blockKeyIDDeltas(block, view) {
+		if err := bucket.Delete(keyIDBalanceKey(keyID, height)); err != nil {
+			return err
+		}
+	}
+	return disconnectKeyIDUtxos(dbTx, block, view)
+}
+
+// BalanceForKeyID returns the confirmed balance, in atoms, of the outputs
+// co-signed by the passed keyID after the block at the passed height of the
+// main chain.  Passing the best height returns the current balance.
+//
+// This function is safe for concurrent access.
+func (idx *KeyIDBalanceIndex) BalanceForKeyID(keyID btcec.KeyID, height uint32) (int64, error) {
+	var balance int64
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		balance, err = dbFetchKeyIDBalance(dbTx, keyID, height)
+		return err
+	})
+	return balance, err
+}
+
+// UtxosForKeyID returns the unspent outputs co-signed by the passed keyID as of
+// the current best block, in the order they were created.
+//
+// This function is safe for concurrent access.
+func (idx *KeyIDBalanceIndex) UtxosForKeyID(keyID btcec.KeyID) ([]AddrUtxo, error) {
+	prefix := keyIDUtxoKey(keyID, &wire.OutPoint{})[:4]
+	var utxos []AddrUtxo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(keyIDUtxoIndexKey).Cursor()
+		for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, prefix) {
+				break
+			}
+			value := cursor.Value()
+			if len(key) != keyIDUtxoKeySize || len(value) < 12 {
+				return errDeserialize("unexpected keyID utxo size")
+			}
+
+			var utxo AddrUtxo
+			copy(utxo.OutPoint.Hash[:], key[4:])
+			utxo.OutPoint.Index = keyOrder.Uint32(key[4+chainhash.HashSize:])
+			utxo.Amount = int64(byteOrder.Uint64(value))
+			utxo.Height = byteOrder.Uint32(value[8:])
+			utxo.PkScript = make([]byte, len(value)-12)
+			copy(utxo.PkScript, value[12:])
+			utxos = append(utxos, utxo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(utxos, func(i, j int) bool {
+		return utxos[i].Height < utxos[j].Height
+	})
+	return utxos, nil
+}
+
+// NewKeyIDBalanceIndex returns a new instance of an indexer that is used to
+// maintain the confirmed balance of the outputs co-signed by every keyID at
+// every height of the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewKeyIDBalanceIndex(db database.DB) *KeyIDBalanceIndex {
+	return &KeyIDBalanceIndex{db: db}
+}
+
+// dropKeyIDUtxoBucket drops the bucket for the keyID utxos when it exists.
+func dropKeyIDUtxoBucket(db database.DB) error {
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(keyIDUtxoIndexKey) == nil {
+			return nil
+		}
+		return meta.DeleteBucket(keyIDUtxoIndexKey)
+	})
+}
+
+// DropKeyIDBalanceIndex drops the keyID balance index from the provided
+// database if it exists.
+func DropKeyIDBalanceIndex(db database.DB) error {
+	return dropIndex(db, keyIDBalanceIndexKey, keyIDBalanceIndexName, nil)
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestKeyIDBalanceIndex ensures the keyID balance index follows the blocks
+// which are connected and disconnected, and keeps the balances at past
+// heights.
+func TestKeyIDBalanceIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "keyidbalanceindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewKeyIDBalanceIndex(db)
+	payTo := func(keyIDs ...btcec.KeyID) []byte {
+		addr, err := provautil.NewAddressProva(
+			bytes.Repeat([]byte{0x42}, 20), keyIDs, params)
+		if err != nil {
+			t.Fatalf("NewAddressProva: unexpected error: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		return pkScript
+	}
+
+	// The first block pays 50 to keyIDs 1 and 2.  The second spends it,
+	// paying 20 to keyIDs 1 and 3, and 30 to keyIDs 2 and 3.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(50, payTo(1, 2)))
+	block1 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase}})
+	block1.SetHeight(1)
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: coinbase.TxHash()},
+		nil))
+	spend.AddTxOut(wire.NewTxOut(20, payTo(1, 3)))
+	spend.AddTxOut(wire.NewTxOut(30, payTo(2, 3)))
+	coinbase2 := wire.NewMsgTx(1)
+	coinbase2.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x01}))
+	block2 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase2, spend}})
+	block2.SetHeight(2)
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(provautil.NewTx(coinbase), 1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.ConnectBlock(dbTx, block1, view); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		keyID  btcec.KeyID
+		height uint32
+		want   int64
+	}{
+		{keyID: 1, height: 0, want: 0},
+		{keyID: 1, height: 1, want: 50},
+		{keyID: 2, height: 1, want: 50},
+		{keyID: 3, height: 1, want: 0},
+		{keyID: 1, height: 2, want: 20},
+		{keyID: 2, height: 2, want: 30},
+		{keyID: 3, height: 2, want: 50},
+		{keyID: 3, height: 1000, want: 50},
+		{keyID: 4, height: 2, want: 0},
+	}
+	for _, test := range tests {
+		balance, err := idx.BalanceForKeyID(test.keyID, test.height)
+		if err != nil {
+			t.Fatalf("BalanceForKeyID: unexpected error: %v", err)
+		}
+		if balance != test.want {
+			t.Errorf("BalanceForKeyID: keyID %d at height %d has "+
+				"balance %d, want %d", test.keyID, test.height,
+				balance, test.want)
+		}
+	}
+
+	// checkUtxos ensures the unspent outputs of the passed keyID are the
+	// passed outpoints.
+	checkUtxos := func(keyID btcec.KeyID, want ...wire.OutPoint) {
+		utxos, err := idx.UtxosForKeyID(keyID)
+		if err != nil {
+			t.Fatalf("UtxosForKeyID: unexpected error: %v", err)
+		}
+		if len(utxos) != len(want) {
+			t.Fatalf("UtxosForKeyID: keyID %d has %d utxos, want %d",
+				keyID, len(utxos), len(want))
+		}
+		for i, utxo := range utxos {
+			if utxo.OutPoint != want[i] {
+				t.Errorf("UtxosForKeyID: keyID %d utxo %d is %v, "+
+					"want %v", keyID, i, utxo.OutPoint, want[i])
+			}
+		}
+	}
+	spendHash := spend.TxHash()
+	checkUtxos(1, wire.OutPoint{Hash: spendHash, Index: 0})
+	checkUtxos(3, wire.OutPoint{Hash: spendHash, Index: 0},
+		wire.OutPoint{Hash: spendHash, Index: 1})
+	checkUtxos(4)
+
+	// The balances after the first block are current again once the
+	// second block is disconnected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	for keyID, want := range map[btcec.KeyID]int64{1: 50, 2: 50, 3: 0} {
+		balance, err := idx.BalanceForKeyID(keyID, 2)
+		if err != nil {
+			t.Fatalf("BalanceForKeyID: unexpected error: %v", err)
+		}
+		if balance != want {
+			t.Errorf("BalanceForKeyID: keyID %d has balance %d "+
+				"after disconnect, want %d", keyID, balance, want)
+		}
+	}
+	checkUtxos(1, wire.OutPoint{Hash: coinbase.TxHash()})
+	checkUtxos(3)
+}
@@ -8,6 +8,8 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
@@ -16,6 +18,13 @@ import (
 	"github.com/bitgo/prova/wire"
 )
 
+const (
+	// backfillRetryInterval is the time the background catch-up of the
+	// indexes waits before retrying when the next block to index is not
+	// available, such as during a reorganization.
+	backfillRetryInterval = time.Second
+)
+
 var (
 	// indexTipsBucketName is the name of the db bucket used to house the
 	// current tip of each index.
@@ -126,9 +135,29 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *provautil.
 // Manager defines an index manager that manages multiple optional indexes and
 // implements the blockchain.IndexManager interface so it can be seamlessly
 // plugged into normal chain processing.
+//
+// Indexes which are behind the main chain when the manager is initialized, or
+// which are added while it is running, are caught up in the background.  Until
+// an index reaches the tip of the main chain, blocks connected to the chain are
+// only passed to it when they extend its tip.
 type Manager struct {
-	db             database.DB
-	enabledIndexes []Indexer
+	db       database.DB
+	readOnly bool
+	chain    *blockchain.BlockChain
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	// The following fields are protected by the mutex.  When the mutex is
+	// acquired along with a database transaction, the transaction is
+	// always opened first, which ensures the tip of the main chain agrees
+	// with the database.
+	mtx             sync.Mutex
+	enabledIndexes  []Indexer
+	liveTip         chainhash.Hash
+	backfilling     []bool
+	backfillRunning bool
+	backfillErr     error
+	dropping        map[string]struct{}
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -180,7 +209,7 @@ func (m *Manager) maybeFinishDrops() error {
 		}
 
 		log.Infof("Resuming %s drop", indexer.Name())
-		err := dropIndex(m.db, indexer.Key(), indexer.Name())
+		err := dropIndex(m.db, indexer.Key(), indexer.Name(), nil)
 		if err != nil {
 			return err
 		}
@@ -219,19 +248,31 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 }
 
 // Init initializes the enabled indexes.  This is called during chain
-// initialization and primarily consists of catching up all indexes to the
-// current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// initialization and primarily consists of starting to catch up all indexes to
+// the current best chain tip.  This is necessary since each index can be
+// disabled and re-enabled at any time.  Catching up can take a long time, so it
+// continues in the background after Init returns while new blocks are indexed
+// as they are connected.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain) error {
+	// Keep track of the chain and its tip so indexes can be added later.
+	best := chain.BestSnapshot()
+	m.mtx.Lock()
+	m.chain = chain
+	m.liveTip = *best.Hash
+	m.mtx.Unlock()
+
 	// Nothing to do when no indexes are enabled.
 	if len(m.enabledIndexes) == 0 {
 		return nil
 	}
 
+	// The indexes of a database opened read-only are used as they are.
+	if m.readOnly {
+		return m.initReadOnly(best)
+	}
+
 	// Finish and drops that were previously interrupted.
 	if err := m.maybeFinishDrops(); err != nil {
 		return err
@@ -346,13 +387,38 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 				"(heights %d to %d)", initialHeight-height,
 				indexer.Name(), height+1, initialHeight)
 		}
+
+		// Rebuild the index from scratch when the height of its tip
+		// disagrees with the height of the block in the main chain,
+		// which means the index has been damaged, such as by an
+		// unclean shutdown.  Its entries are derived from the blocks,
+		// so nothing is lost.
+		mainHeight, err := chain.BlockHeightByHash(hash)
+		if err != nil {
+			return err
+		}
+		if int32(mainHeight) != height {
+			log.Warnf("The tip of the %s is %v at height %d, but the "+
+				"block is at height %d in the main chain -- "+
+				"rebuilding the index", indexer.Name(), hash,
+				height, mainHeight)
+			err := dropIndex(m.db, indexer.Key(), indexer.Name(), nil)
+			if err != nil {
+				return err
+			}
+			err = m.db.Update(func(dbTx database.Tx) error {
+				return m.maybeCreateIndexes(dbTx)
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// Fetch the current tip heights for each index along with tracking the
-	// lowest one so the catchup code only needs to start at the earliest
-	// block and is able to skip connecting the block for the indexes that
-	// don't need it.
-	bestHeight := int32(chain.BestSnapshot().Height)
+	// lowest one so it is known whether any of the indexes need to be
+	// caught up.
+	bestHeight := int32(best.Height)
 	lowestHeight := bestHeight
 	indexerHeights := make([]int32, len(m.enabledIndexes))
 	err = m.db.View(func(dbTx database.Tx) error {
@@ -381,60 +447,528 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		return nil
 	}
 
+	// At this point, one or more indexes are behind the current best chain
+	// tip and need to be caught up.  This is done in the background so the
+	// node does not have to wait for it before serving traffic, while the
+	// indexes which are caught up keep following the chain.
+	log.Infof("Catching up indexes from height %d to %d in the "+
+		"background", lowestHeight, bestHeight)
+	m.mtx.Lock()
+	for i, height := range indexerHeights {
+		m.backfilling[i] = height < bestHeight
+	}
+	m.startBackfill()
+	m.mtx.Unlock()
+	return nil
+}
+
+// initReadOnly ensures each of the enabled indexes exists in a database opened
+// read-only, and initializes them without writing to the database.  The indexes
+// which are not at the passed tip of the main chain are reported as not synced,
+// since they are never caught up.
+func (m *Manager) initReadOnly(best *blockchain.BestState) error {
+	err := m.db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		for i, indexer := range m.enabledIndexes {
+			idxKey := indexer.Key()
+			if indexesBucket == nil || indexesBucket.Get(idxKey) == nil {
+				return fmt.Errorf("the %s does not exist in the "+
+					"read-only database", indexer.Name())
+			}
+			if indexesBucket.Get(indexDropKey(idxKey)) != nil {
+				return fmt.Errorf("the %s is being dropped in the "+
+					"read-only database", indexer.Name())
+			}
+
+			hash, _, err := dbFetchIndexerTip(dbTx, idxKey)
+			if err != nil {
+				return err
+			}
+			m.backfilling[i] = !hash.IsEqual(best.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, indexer := range m.enabledIndexes {
+		if err := indexer.Init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startBackfill starts catching up the indexes which are behind the main chain
+// in the background unless it is already in progress.
+//
+// This function MUST be called with the manager mutex held.
+func (m *Manager) startBackfill() {
+	if m.backfillRunning {
+		return
+	}
+	m.backfillRunning = true
+	m.backfillErr = nil
+	m.wg.Add(1)
+	go m.backfillHandler()
+}
+
+// backfillFailed records the passed error which stopped catching up the
+// indexes in the background.  It is reported with the state of the indexes
+// which are not caught up until one is added, which retries.
+func (m *Manager) backfillFailed(err error) {
+	log.Errorf("Unable to catch up indexes: %v", err)
+	m.mtx.Lock()
+	m.backfillRunning = false
+	m.backfillErr = err
+	m.mtx.Unlock()
+}
+
+// backfillHandler connects the blocks of the main chain to the indexes which
+// are behind it until all of them have caught up with the tip of the main
+// chain.  It must be run as a goroutine.
+func (m *Manager) backfillHandler() {
+	defer m.wg.Done()
+
 	// Create a progress logger for the indexing process below.
 	progressLogger := newBlockProgressLogger("Indexed", log)
 
-	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
-	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
-		bestHeight)
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
-		// Load the block for the height since it is required to index
-		// it.
-		block, err := chain.BlockByHeight(uint32(height))
+	for {
+		select {
+		case <-m.quit:
+			return
+		default:
+		}
+
+		height, done, err := m.nextBackfillHeight()
 		if err != nil {
-			return err
+			m.backfillFailed(err)
+			return
+		}
+		if done {
+			log.Infof("Indexes caught up")
+			return
+		}
+
+		// The block at the height might not be available when the
+		// chain is being reorganized, and the block might not extend
+		// the tips of the indexes when it was replaced after it was
+		// loaded, so both are retried after a while.
+		connected := false
+		block, err := m.chain.BlockByHeight(uint32(height))
+		if err == nil {
+			connected, err = m.backfillBlock(block)
+			if err != nil {
+				m.backfillFailed(err)
+				return
+			}
+		}
+		if connected {
+			progressLogger.LogBlockHeight(block)
+			continue
+		}
+
+		select {
+		case <-m.quit:
+			return
+		case <-time.After(backfillRetryInterval):
 		}
+	}
+}
+
+// nextBackfillHeight returns the height of the next block to connect to the
+// indexes which are being caught up in the background, which is the block
+// after the lowest tip of them, or whether all of them have caught up.  Once
+// they have, catching up is no longer in progress.
+func (m *Manager) nextBackfillHeight() (int32, bool, error) {
+	var height int32
+	done := true
+	err := m.db.View(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		for i, indexer := range m.enabledIndexes {
+			if !m.backfilling[i] {
+				continue
+			}
+			_, tipHeight, err := dbFetchIndexerTip(dbTx,
+				indexer.Key())
+			if err != nil {
+				return err
+			}
+			if done || tipHeight+1 < height {
+				height = tipHeight + 1
+			}
+			done = false
+		}
+		if done {
+			m.backfillRunning = false
+		}
+		return nil
+	})
+	return height, done, err
+}
+
+// backfillBlock connects the passed block of the main chain to the indexes
+// which are being caught up in the background and whose tip it extends, and
+// returns whether it was connected to any of them.  Indexes which reach the
+// tip of the main chain follow the chain from then on.
+func (m *Manager) backfillBlock(block *provautil.Block) (bool, error) {
+	connected := false
+	err := m.db.Update(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
 
-		// Connect the block for all indexes that need it.
 		var view *blockchain.UtxoViewpoint
 		for i, indexer := range m.enabledIndexes {
-			// Skip indexes that don't need to be updated with this
-			// block.
-			if indexerHeights[i] >= height {
+			if !m.backfilling[i] {
+				continue
+			}
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(&block.MsgBlock().Header.PrevBlock) {
 				continue
 			}
 
-			err := m.db.Update(func(dbTx database.Tx) error {
-				// When the index requires all of the referenced
-				// txouts and they haven't been loaded yet, they
-				// need to be retrieved from the transaction
-				// index.
-				if view == nil && indexNeedsInputs(indexer) {
-					var err error
-					view, err = makeUtxoView(dbTx, block)
-					if err != nil {
-						return err
-					}
+			// When the index requires all of the referenced txouts
+			// and they haven't been loaded yet, they need to be
+			// retrieved from the transaction index.
+			if view == nil && indexNeedsInputs(indexer) {
+				view, err = makeUtxoView(dbTx, block)
+				if err != nil {
+					return err
 				}
-				return dbIndexConnectBlock(dbTx, indexer, block,
-					view)
-			})
+			}
+			err = dbIndexConnectBlock(dbTx, indexer, block, view)
 			if err != nil {
 				return err
 			}
-			indexerHeights[i] = height
+			connected = true
+
+			if block.Hash().IsEqual(&m.liveTip) {
+				m.backfilling[i] = false
+				log.Infof("%s caught up to height %d",
+					indexer.Name(), block.Height())
+			}
+		}
+		return nil
+	})
+	return connected, err
+}
+
+// Stop stops catching up and dropping indexes in the background, if either is
+// in progress, and waits for it to finish.  Indexes which are not caught up
+// yet resume catching up the next time the manager is initialized, and drops
+// which are interrupted are finished the next time the index is enabled or
+// dropped.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// indexPosition returns the position of the enabled index with the passed key,
+// or -1 when it is not enabled.
+//
+// This function MUST be called with the manager mutex held.
+func (m *Manager) indexPosition(idxKey []byte) int {
+	for i, indexer := range m.enabledIndexes {
+		if bytes.Equal(indexer.Key(), idxKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddIndex enables the passed index while the manager is running.  The index
+// is created when it does not exist yet, and it is caught up with the main
+// chain in the background, after which it follows the chain like the indexes
+// enabled when the manager was created.
+//
+// Indexes which depend on other indexes may only be added after the indexes
+// they depend on.
+//
+// This function is safe for concurrent access.
+func (m *Manager) AddIndex(indexer Indexer) error {
+	if m.readOnly {
+		return fmt.Errorf("%s can't be enabled on a read-only "+
+			"database", indexer.Name())
+	}
+
+	idxKey := indexer.Key()
+	m.mtx.Lock()
+	_, dropping := m.dropping[string(idxKey)]
+	enabled := m.indexPosition(idxKey) != -1
+	m.mtx.Unlock()
+	if dropping {
+		return fmt.Errorf("%s is being dropped", indexer.Name())
+	}
+	if enabled {
+		return fmt.Errorf("%s is already enabled", indexer.Name())
+	}
+
+	// Finish dropping the index first when a previous drop of it was
+	// interrupted.
+	var needsDrop bool
+	err := m.db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		needsDrop = indexesBucket != nil &&
+			indexesBucket.Get(indexDropKey(idxKey)) != nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if needsDrop {
+		log.Infof("Resuming %s drop", indexer.Name())
+		err := dropIndex(m.db, idxKey, indexer.Name(), m.quit)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the index when it does not exist yet.
+	err = m.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		indexesBucket, err := meta.CreateBucketIfNotExists(
+			indexTipsBucketName)
+		if err != nil {
+			return err
+		}
+		if indexesBucket.Get(idxKey) != nil {
+			return nil
+		}
+		if err := indexer.Create(dbTx); err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, idxKey, &chainhash.Hash{}, -1)
+	})
+	if err != nil {
+		return err
+	}
+	if err := indexer.Init(); err != nil {
+		return err
+	}
+
+	// Enable the index and catch it up in the background unless it is
+	// already at the tip of the main chain.  Since the chain might have
+	// been reorganized while the index was disabled, an index whose tip is
+	// not in the main chain is dropped rather than rolled back.
+	return m.db.Update(func(dbTx database.Tx) error {
+		tipHash, height, err := dbFetchIndexerTip(dbTx, idxKey)
+		if err != nil {
+			return err
+		}
+		if height != -1 {
+			exists, err := m.chain.MainChainHasBlock(tipHash)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("the tip of %s is not in the "+
+					"main chain, so it must be dropped first",
+					indexer.Name())
+			}
+		}
+
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		if m.indexPosition(idxKey) != -1 {
+			return fmt.Errorf("%s is already enabled",
+				indexer.Name())
+		}
+		synced := tipHash.IsEqual(&m.liveTip)
+		m.enabledIndexes = append(m.enabledIndexes, indexer)
+		m.backfilling = append(m.backfilling, !synced)
+		log.Infof("%s is enabled", indexer.Name())
+		if !synced {
+			m.startBackfill()
 		}
+		return nil
+	})
+}
 
-		// Log indexing progress.
-		progressLogger.LogBlockHeight(block)
+// DropIndex disables the passed index, if it is enabled, and removes it from
+// the database in the background while the manager is running.  Indexes which
+// other enabled indexes depend on can't be dropped.
+//
+// This function is safe for concurrent access.
+func (m *Manager) DropIndex(indexer Indexer) error {
+	if m.readOnly {
+		return fmt.Errorf("%s can't be dropped from a read-only "+
+			"database", indexer.Name())
 	}
 
-	log.Infof("Indexes caught up to height %d", bestHeight)
+	idxKey := indexer.Key()
+	var exists bool
+	err := m.db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		exists = indexesBucket != nil && indexesBucket.Get(idxKey) != nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	if _, ok := m.dropping[string(idxKey)]; ok {
+		m.mtx.Unlock()
+		return fmt.Errorf("%s is already being dropped", indexer.Name())
+	}
+	if !exists {
+		m.mtx.Unlock()
+		return fmt.Errorf("%s does not exist", indexer.Name())
+	}
+	if bytes.Equal(idxKey, txIndexKey) &&
+		m.indexPosition(addrIndexKey) != -1 {
+
+		m.mtx.Unlock()
+		return fmt.Errorf("%s is required by the %s", txIndexName,
+			addrIndexName)
+	}
+	if i := m.indexPosition(idxKey); i != -1 {
+		m.enabledIndexes = append(m.enabledIndexes[:i:i],
+			m.enabledIndexes[i+1:]...)
+		m.backfilling = append(m.backfilling[:i:i],
+			m.backfilling[i+1:]...)
+		log.Infof("%s is disabled", indexer.Name())
+	}
+	m.dropping[string(idxKey)] = struct{}{}
+	m.mtx.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		// The address index depends on the transaction index, so it
+		// is dropped along with it like DropTxIndex does.
+		var err error
+		if bytes.Equal(idxKey, txIndexKey) {
+			err = dropIndex(m.db, addrIndexKey, addrIndexName, m.quit)
+		}
+		if err == nil {
+			err = dropIndex(m.db, idxKey, indexer.Name(), m.quit)
+		}
+		if err != nil && err != errInterruptRequested {
+			log.Errorf("Unable to drop %s: %v", indexer.Name(), err)
+		}
+
+		m.mtx.Lock()
+		delete(m.dropping, string(idxKey))
+		m.mtx.Unlock()
+	}()
 	return nil
 }
 
+// IndexStatus describes the state of an index managed by the index manager.
+type IndexStatus struct {
+	// Name is the human-readable name of the index.
+	Name string
+
+	// Height is the height of the tip of the index, which is -1 when no
+	// block has been indexed yet.
+	Height int32
+
+	// Synced is whether the index follows the main chain, rather than
+	// being caught up in the background.
+	Synced bool
+
+	// Size is the approximate number of bytes taken by the entries of the
+	// index, not accounting for the compression of the database.
+	Size int64
+
+	// Err is the error which stopped catching up the index in the
+	// background, if any.
+	Err error
+}
+
+// bucketSize returns the number of bytes taken by the keys and values of the
+// passed bucket and of the buckets nested in it.
+func bucketSize(bucket database.Bucket) (int64, error) {
+	var size int64
+	err := bucket.ForEach(func(k, v []byte) error {
+		size += int64(len(k) + len(v))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = bucket.ForEachBucket(func(k []byte) error {
+		nestedSize, err := bucketSize(bucket.Bucket(k))
+		size += int64(len(k)) + nestedSize
+		return err
+	})
+	return size, err
+}
+
+// IndexStatuses returns the state of each of the indexes managed by the index
+// manager.  Determining the size of the indexes requires reading all of their
+// entries, so it takes time proportional to their size.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexStatuses() ([]IndexStatus, error) {
+	return m.indexStatuses(true)
+}
+
+// IndexTips returns the state of each of the indexes managed by the index
+// manager like IndexStatuses, but without determining their sizes, so it is
+// cheap enough to be called periodically.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexTips() ([]IndexStatus, error) {
+	return m.indexStatuses(false)
+}
+
+// indexStatuses returns the state of each of the indexes managed by the index
+// manager, including their sizes when withSizes is set.
+func (m *Manager) indexStatuses(withSizes bool) ([]IndexStatus, error) {
+	var statuses []IndexStatus
+	err := m.db.View(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		enabledIndexes := m.enabledIndexes
+		statuses = make([]IndexStatus, 0, len(enabledIndexes))
+		for i, indexer := range enabledIndexes {
+			_, height, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				m.mtx.Unlock()
+				return err
+			}
+			status := IndexStatus{
+				Name:   indexer.Name(),
+				Height: height,
+				Synced: !m.backfilling[i],
+			}
+			if !status.Synced {
+				status.Err = m.backfillErr
+			}
+			statuses = append(statuses, status)
+		}
+		m.mtx.Unlock()
+		if !withSizes {
+			return nil
+		}
+
+		// The sizes are determined without holding the mutex since
+		// the database transaction provides a consistent view.
+		for i, indexer := range enabledIndexes {
+			bucket := dbTx.Metadata().Bucket(indexer.Key())
+			if bucket == nil {
+				continue
+			}
+			size, err := bucketSize(bucket)
+			if err != nil {
+				return err
+			}
+			statuses[i].Size = size
+		}
+		return nil
+	})
+	return statuses, err
+}
+
 // indexNeedsInputs returns whether or not the index needs access to the txouts
 // referenced by the transaction inputs being indexed.
 func indexNeedsInputs(index Indexer) bool {
@@ -511,14 +1045,33 @@ func makeUtxoView(dbTx database.Tx, block *provautil.Block) (*blockchain.UtxoVie
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
-	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being connected so they can update accordingly.  Indexes which are
+	// being caught up in the background follow the chain from the block
+	// which extends their tip on.
+	for i, index := range m.enabledIndexes {
+		if m.backfilling[i] {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(&block.MsgBlock().Header.PrevBlock) {
+				continue
+			}
+			m.backfilling[i] = false
+			log.Infof("%s caught up to height %d", index.Name(),
+				block.Height())
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, view)
 		if err != nil {
 			return err
 		}
 	}
+	m.liveTip = *block.Hash()
 	return nil
 }
 
@@ -529,14 +1082,30 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *b
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
-	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being disconnected so they can update accordingly.  Indexes which
+	// are being caught up in the background only need to be updated when
+	// they have already indexed the block.
+	for i, index := range m.enabledIndexes {
+		if m.backfilling[i] {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(block.Hash()) {
+				continue
+			}
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, view)
 		if err != nil {
 			return err
 		}
 	}
+	m.liveTip = block.MsgBlock().Header.PrevBlock
 	return nil
 }
 
@@ -548,15 +1117,30 @@ func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		quit:           make(chan struct{}),
+		backfilling:    make([]bool, len(enabledIndexes)),
+		dropping:       make(map[string]struct{}),
 	}
 }
 
+// NewReadOnlyManager returns a new index manager with the provided indexes
+// enabled for a database opened read-only.  The indexes must already exist in
+// the database.  They are served as they are, without ever being caught up,
+// and no indexes can be enabled or dropped.
+func NewReadOnlyManager(db database.DB, enabledIndexes []Indexer) *Manager {
+	m := NewManager(db, enabledIndexes)
+	m.readOnly = true
+	return m
+}
+
 // dropIndex drops the passed index from the database.  Since indexes can be
 // massive, it deletes the index in multiple database transactions in order to
 // keep memory usage to reasonable levels.  It also marks the drop in progress
 // so the drop can be resumed if it is stopped before it is done before the
-// index can be used again.
-func dropIndex(db database.DB, idxKey []byte, idxName string) error {
+// index can be used again.  The drop is stopped with errInterruptRequested
+// between the database transactions once the passed channel is closed, which
+// may be nil.
+func dropIndex(db database.DB, idxKey []byte, idxName string, interrupt <-chan struct{}) error {
 	// Nothing to do if the index doesn't already exist.
 	var needsDelete bool
 	err := db.View(func(dbTx database.Tx) error {
@@ -595,6 +1179,10 @@ func dropIndex(db database.DB, idxKey []byte, idxName string) error {
 	const maxDeletions = 2000000
 	var totalDeleted uint64
 	for numDeleted := maxDeletions; numDeleted == maxDeletions; {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
 		numDeleted = 0
 		err := db.Update(func(dbTx database.Tx) error {
 			bucket := dbTx.Metadata().Bucket(idxKey)
@@ -627,6 +1215,29 @@ func dropIndex(db database.DB, idxKey []byte, idxName string) error {
 		}
 	}
 
+	// Call extra index specific deinitialization for the address index.
+	if idxName == addrIndexName {
+		if err := dropAddrDeltaBuckets(db); err != nil {
+			return err
+		}
+	}
+
+	// Call extra index specific deinitialization for the keyID balance
+	// index.
+	if idxName == keyIDBalanceIndexName {
+		if err := dropKeyIDUtxoBucket(db); err != nil {
+			return err
+		}
+	}
+
+	// Call extra index specific deinitialization for the daily statistics
+	// index.
+	if idxName == statsIndexName {
+		if err := dropStatsKeyIDBucket(db); err != nil {
+			return err
+		}
+	}
+
 	// Remove the index tip, index bucket, and in-progress drop flag now
 	// that all index entries have been removed.
 	err = db.Update(func(dbTx database.Tx) error {
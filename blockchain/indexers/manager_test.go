@@ -0,0 +1,290 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+)
+
+// waitForIndexStatuses polls the state of the indexes managed by the passed
+// manager until the passed function accepts it or a timeout expires, and
+// returns the last state.
+func waitForIndexStatuses(t *testing.T, m *Manager, done func([]IndexStatus) bool) []IndexStatus {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		statuses, err := m.IndexStatuses()
+		if err != nil {
+			t.Fatalf("IndexStatuses: unexpected error: %v", err)
+		}
+		if done(statuses) || time.Now().After(deadline) {
+			return statuses
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestManagerBackfill ensures an index which is behind the main chain when the
+// manager is initialized is caught up in the background and reported as synced
+// once it reaches the tip of the main chain.
+func TestManagerBackfill(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "indexmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	// The chain initializes the manager with the genesis block in the main
+	// chain, which the new index has not indexed yet.
+	idx := NewTimestampIndex(db)
+	manager := NewManager(db, []Indexer{idx})
+	defer manager.Stop()
+	_, err = blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  params,
+		TimeSource:   blockchain.NewMedianTime(),
+		IndexManager: manager,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	statuses := waitForIndexStatuses(t, manager, func(s []IndexStatus) bool {
+		return len(s) != 1 || s[0].Synced
+	})
+	want := IndexStatus{Name: timestampIndexName, Height: 0, Synced: true,
+		Size: timestampKeySize + chainhash.HashSize}
+	if len(statuses) != 1 || statuses[0] != want {
+		t.Fatalf("IndexStatuses: got %+v, want %+v", statuses, want)
+	}
+
+	genesis := params.GenesisBlock
+	blockTime, err := idx.BlockByTime(genesis.Header.Timestamp.Unix())
+	if err != nil || blockTime == nil || blockTime.Hash != genesis.BlockHash() {
+		t.Errorf("BlockByTime: got block %+v (%v), want the genesis "+
+			"block", blockTime, err)
+	}
+}
+
+// TestManagerAddDropIndex ensures indexes can be added to and dropped from a
+// running manager, and that added indexes are caught up in the background.
+func TestManagerAddDropIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "indexmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	manager := NewManager(db, nil)
+	defer manager.Stop()
+	_, err = blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  params,
+		TimeSource:   blockchain.NewMedianTime(),
+		IndexManager: manager,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	// The index is caught up once it is added, and can't be added twice.
+	idx := NewTimestampIndex(db)
+	if err := manager.AddIndex(idx); err != nil {
+		t.Fatalf("AddIndex: unexpected error: %v", err)
+	}
+	if err := manager.AddIndex(NewTimestampIndex(db)); err == nil {
+		t.Fatalf("AddIndex: added the index twice")
+	}
+	statuses := waitForIndexStatuses(t, manager, func(s []IndexStatus) bool {
+		return len(s) != 1 || s[0].Synced
+	})
+	if len(statuses) != 1 || !statuses[0].Synced || statuses[0].Height != 0 {
+		t.Fatalf("IndexStatuses: got %+v, want the synced index",
+			statuses)
+	}
+
+	// The index is no longer managed once it is dropped, and it can be
+	// added again once the drop is finished.
+	if err := manager.DropIndex(idx); err != nil {
+		t.Fatalf("DropIndex: unexpected error: %v", err)
+	}
+	statuses, err = manager.IndexStatuses()
+	if err != nil || len(statuses) != 0 {
+		t.Fatalf("IndexStatuses: got %+v (%v) after drop, want none",
+			statuses, err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := manager.AddIndex(NewTimestampIndex(db))
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("AddIndex: unexpected error after drop: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := manager.DropIndex(NewSpentIndex(db)); err == nil {
+		t.Fatalf("DropIndex: dropped an index which does not exist")
+	}
+}
+
+// TestManagerReadOnly ensures a read-only manager serves the indexes which
+// exist in a database opened read-only, refuses indexes which do not exist, and
+// refuses to add or drop indexes.
+func TestManagerReadOnly(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "indexmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	// Create the chain along with the timestamp index, and wait for the
+	// index to catch up before closing the database.
+	manager := NewManager(db, []Indexer{NewTimestampIndex(db)})
+	_, err = blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  params,
+		TimeSource:   blockchain.NewMedianTime(),
+		IndexManager: manager,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	waitForIndexStatuses(t, manager, func(s []IndexStatus) bool {
+		return len(s) != 1 || s[0].Synced
+	})
+	manager.Stop()
+	db.Close()
+
+	db, err = database.OpenReadOnly("ffldb", filepath.Join(dbPath, "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: unexpected error: %v", err)
+	}
+	defer db.Close()
+	newChain := func(manager *Manager) error {
+		_, err := blockchain.New(&blockchain.Config{
+			DB:           db,
+			ChainParams:  params,
+			TimeSource:   blockchain.NewMedianTime(),
+			IndexManager: manager,
+		})
+		return err
+	}
+
+	// An index which does not exist in the database can't be served.
+	manager = NewReadOnlyManager(db, []Indexer{NewSupplyIndex(db)})
+	if err := newChain(manager); err == nil {
+		t.Fatalf("New: served an index which does not exist")
+	}
+
+	manager = NewReadOnlyManager(db, []Indexer{NewTimestampIndex(db)})
+	defer manager.Stop()
+	if err := newChain(manager); err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	statuses, err := manager.IndexStatuses()
+	if err != nil || len(statuses) != 1 || !statuses[0].Synced {
+		t.Fatalf("IndexStatuses: got %+v (%v), want the synced index",
+			statuses, err)
+	}
+	if err := manager.AddIndex(NewSpentIndex(db)); err == nil {
+		t.Errorf("AddIndex: added an index to a read-only database")
+	}
+	if err := manager.DropIndex(NewTimestampIndex(db)); err == nil {
+		t.Errorf("DropIndex: dropped an index from a read-only database")
+	}
+}
+
+// TestManagerRebuildIndex ensures an index whose tip disagrees with the height
+// of the block in the main chain is rebuilt when the manager is initialized.
+func TestManagerRebuildIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "indexmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+	newChain := func(manager *Manager) {
+		_, err := blockchain.New(&blockchain.Config{
+			DB:           db,
+			ChainParams:  params,
+			TimeSource:   blockchain.NewMedianTime(),
+			IndexManager: manager,
+		})
+		if err != nil {
+			t.Fatalf("New: unexpected error: %v", err)
+		}
+		waitForIndexStatuses(t, manager, func(s []IndexStatus) bool {
+			return len(s) != 1 || s[0].Synced
+		})
+	}
+
+	// Index the genesis block, then damage the tip of the index so it
+	// claims the genesis block is at height 5.
+	manager := NewManager(db, []Indexer{NewTimestampIndex(db)})
+	newChain(manager)
+	manager.Stop()
+	genesisHash := params.GenesisBlock.BlockHash()
+	err = db.Update(func(dbTx database.Tx) error {
+		return dbPutIndexerTip(dbTx, timestampIndexKey, &genesisHash, 5)
+	})
+	if err != nil {
+		t.Fatalf("dbPutIndexerTip: unexpected error: %v", err)
+	}
+
+	idx := NewTimestampIndex(db)
+	manager = NewManager(db, []Indexer{idx})
+	defer manager.Stop()
+	newChain(manager)
+	statuses, err := manager.IndexStatuses()
+	if err != nil || len(statuses) != 1 || !statuses[0].Synced ||
+		statuses[0].Height != 0 {
+
+		t.Fatalf("IndexStatuses: got %+v (%v), want the rebuilt index",
+			statuses, err)
+	}
+	blockTime, err := idx.BlockByTime(params.GenesisBlock.Header.Timestamp.Unix())
+	if err != nil || blockTime == nil || blockTime.Hash != genesisHash {
+		t.Errorf("BlockByTime: got block %+v (%v), want the genesis "+
+			"block", blockTime, err)
+	}
+}
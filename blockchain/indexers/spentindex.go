@@ -0,0 +1,246 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent index"
+
+	// spentKeySize is the number of bytes a key in the spent index
+	// consumes.  It consists of the hash of the transaction of the spent
+	// output + 4 bytes output index.
+	spentKeySize = chainhash.HashSize + 4
+
+	// spentValueSize is the number of bytes a value in the spent index
+	// consumes.  It consists of the hash of the spending transaction + 4
+	// bytes input index + 4 bytes block height.
+	spentValueSize = chainhash.HashSize + 4 + 4
+)
+
+var (
+	// spentIndexKey is the key of the spent index and the db bucket used
+	// to house it.
+	spentIndexKey = []byte("spentbyoutpointidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent index consists of an entry for every output spent by a transaction
+// in the main chain, which refers to the input spending it.  Outputs which are
+// unspent have no entry.
+//
+// The serialized format for keys in the spent index bucket is:
+//
+//   <tx hash><output index>
+//
+//   Field           Type              Size
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   -----
+//   Total: 36 bytes
+//
+// The serialized value format is:
+//
+//   <spending tx hash><input index><block height>
+//
+//   Field              Type              Size
+//   spending tx hash   chainhash.Hash    32 bytes
+//   input index        uint32            4 bytes
+//   block height       uint32            4 bytes
+//   -----
+//   Total: 40 bytes
+// -----------------------------------------------------------------------------
+
+// SpendInfo describes the input of a transaction in the main chain which
+// spends an output.
+type SpendInfo struct {
+	// TxHash is the hash of the spending transaction.
+	TxHash chainhash.Hash
+
+	// Index is the index of the spending input.
+	Index uint32
+
+	// Height is the height of the block containing the spending
+	// transaction.
+	Height uint32
+}
+
+// spentIndexKeyFor returns the key of the spent index for the passed outpoint.
+func spentIndexKeyFor(outPoint *wire.OutPoint) []byte {
+	key := make([]byte, spentKeySize)
+	copy(key, outPoint.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], outPoint.Index)
+	return key
+}
+
+// dbPutSpendInfo uses an existing database transaction to store the passed
+// spend of the output referenced by the passed outpoint.
+func dbPutSpendInfo(dbTx database.Tx, outPoint *wire.OutPoint, spend *SpendInfo) error {
+	value := make([]byte, spentValueSize)
+	copy(value, spend.TxHash[:])
+	byteOrder.PutUint32(value[chainhash.HashSize:], spend.Index)
+	byteOrder.PutUint32(value[chainhash.HashSize+4:], spend.Height)
+	return dbTx.Metadata().Bucket(spentIndexKey).Put(
+		spentIndexKeyFor(outPoint), value)
+}
+
+// dbFetchSpendInfo uses an existing database transaction to fetch the spend of
+// the output referenced by the passed outpoint.  When the output is not spent
+// in the main chain, nil will be returned for both the spend and the error.
+func dbFetchSpendInfo(dbTx database.Tx, outPoint *wire.OutPoint) (*SpendInfo, error) {
+	value := dbTx.Metadata().Bucket(spentIndexKey).Get(
+		spentIndexKeyFor(outPoint))
+	if value == nil {
+		return nil, nil
+	}
+
+	// Ensure the serialized data has enough bytes to properly deserialize.
+	if len(value) < spentValueSize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt spent index entry "+
+				"for %v", outPoint),
+		}
+	}
+	var spend SpendInfo
+	copy(spend.TxHash[:], value[:chainhash.HashSize])
+	spend.Index = byteOrder.Uint32(value[chainhash.HashSize:])
+	spend.Height = byteOrder.Uint32(value[chainhash.HashSize+4:])
+	return &spend, nil
+}
+
+// SpentIndex implements a spending input by outpoint index.  That is to say,
+// it supports querying which input of which transaction in the main chain
+// spends an output, so outputs can be followed to their spends without
+// scanning the blocks after them.
+type SpentIndex struct {
+	db database.DB
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the spent
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every output
+// spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	for _, tx := range block.Transactions() {
+		// The coinbase does not spend any outputs.
+		if blockchain.IsCoinBase(tx) {
+			continue
+		}
+
+		spend := SpendInfo{
+			TxHash: *tx.Hash(),
+			Height: uint32(block.Height()),
+		}
+		for i, txIn := range tx.MsgTx().TxIn {
+			spend.Index = uint32(i)
+			err := dbPutSpendInfo(dbTx, &txIn.PreviousOutPoint,
+				&spend)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries of the
+// outputs spent by the transactions in the block, which are unspent again.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions() {
+		if blockchain.IsCoinBase(tx) {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			key := spentIndexKeyFor(&txIn.PreviousOutPoint)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SpendByOutPoint returns the input of a transaction in the main chain which
+// spends the output referenced by the passed outpoint.  When the output is
+// not spent in the main chain, nil will be returned for both the spend and the
+// error.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpendByOutPoint(outPoint *wire.OutPoint) (*SpendInfo, error) {
+	var spend *SpendInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		spend, err = dbFetchSpendInfo(dbTx, outPoint)
+		return err
+	})
+	return spend, err
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to create a
+// mapping of every output spent in the blockchain to the input spending it.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{db: db}
+}
+
+// DropSpentIndex drops the spent index from the provided database if it
+// exists.
+func DropSpentIndex(db database.DB) error {
+	return dropIndex(db, spentIndexKey, spentIndexName, nil)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSpentIndex ensures the spent index refers the outputs spent by the
+// transactions of connected blocks to their spending inputs, and forgets the
+// spends again when the blocks are disconnected.
+func TestSpentIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "spentindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	// The child block spends the second and first output of the coinbase
+	// of the genesis block, in that order.
+	idx := NewSpentIndex(db)
+	genesis := provautil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	genesis.SetHeight(0)
+	coinbaseHash := genesis.Transactions()[0].Hash()
+	spendTx := wire.NewMsgTx(1)
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(coinbaseHash, 1), nil))
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(coinbaseHash, 0), nil))
+	spendTx.AddTxOut(wire.NewTxOut(1, nil))
+	childMsg := wire.NewMsgBlock(wire.NewBlockHeader(genesis.Hash(),
+		&chainhash.Hash{}, 0, 0))
+	childMsg.AddTransaction(genesis.MsgBlock().Transactions[0])
+	childMsg.AddTransaction(spendTx)
+	child := provautil.NewBlock(childMsg)
+	child.SetHeight(1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.ConnectBlock(dbTx, genesis, nil); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, child, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	spendHash := spendTx.TxHash()
+	for i, outIndex := range []uint32{1, 0} {
+		spend, err := idx.SpendByOutPoint(wire.NewOutPoint(coinbaseHash,
+			outIndex))
+		if err != nil {
+			t.Fatalf("SpendByOutPoint: unexpected error: %v", err)
+		}
+		want := SpendInfo{TxHash: spendHash, Index: uint32(i), Height: 1}
+		if spend == nil || *spend != want {
+			t.Errorf("SpendByOutPoint: output %d has spend %+v, "+
+				"want %+v", outIndex, spend, want)
+		}
+	}
+
+	// The outputs of the spending transaction and the prevout of the
+	// coinbase are not spent.
+	for _, outPoint := range []*wire.OutPoint{
+		wire.NewOutPoint(&spendHash, 0),
+		&genesis.MsgBlock().Transactions[0].TxIn[0].PreviousOutPoint,
+	} {
+		spend, err := idx.SpendByOutPoint(outPoint)
+		if err != nil || spend != nil {
+			t.Errorf("SpendByOutPoint: got spend %+v (%v) of %v, "+
+				"want none", spend, err, outPoint)
+		}
+	}
+
+	// The outputs are unspent again once the child is disconnected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, child, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	spend, err := idx.SpendByOutPoint(wire.NewOutPoint(coinbaseHash, 1))
+	if err != nil || spend != nil {
+		t.Errorf("SpendByOutPoint: got spend %+v (%v) after "+
+			"disconnect, want none", spend, err)
+	}
+}
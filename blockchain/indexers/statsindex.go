@@ -0,0 +1,425 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// statsIndexName is the human-readable name for the index.
+	statsIndexName = "daily statistics index"
+
+	// secondsPerDay is the number of seconds in a day of the index.
+	secondsPerDay = 24 * 60 * 60
+
+	// statsValueSize is the number of bytes a value in the daily
+	// statistics index consumes.  It consists of 4 bytes blocks + 8 bytes
+	// transactions + 8 bytes fees + 4 bytes active keyIDs.
+	statsValueSize = 4 + 8 + 8 + 4
+
+	// statsKeyIDKeySize is the number of bytes a key in the active keyID
+	// bucket consumes.  It consists of 4 bytes day + 4 bytes keyID.
+	statsKeyIDKeySize = 4 + 4
+)
+
+var (
+	// statsIndexKey is the key of the daily statistics index and the db
+	// bucket used to house it.
+	statsIndexKey = []byte("statsidx")
+
+	// statsKeyIDIndexKey is the key of the db bucket used to house the
+	// keyIDs active on each day.
+	statsKeyIDIndexKey = []byte("statskeyididx")
+)
+
+// -----------------------------------------------------------------------------
+// The daily statistics index keeps aggregates of the blocks of the main chain
+// for every UTC day, so explorers can chart the activity of the chain without
+// replaying it.  A block counts towards the day of its timestamp.
+//
+// The serialized format for keys in the daily statistics index bucket is:
+//
+//   <day>
+//
+//   Field    Type      Size
+//   day      uint32    4 bytes (days since 1 Jan 1970, big endian)
+//   -----
+//   Total: 4 bytes
+//
+// The serialized value format is:
+//
+//   <blocks><txs><fees><active keyIDs>
+//
+//   Field            Type      Size
+//   blocks           uint32    4 bytes
+//   txs              uint64    8 bytes
+//   fees             int64     8 bytes
+//   active keyIDs    uint32    4 bytes
+//   -----
+//   Total: 24 bytes
+//
+// The transactions are those other than the coinbases, and the fees those
+// paid by the transactions other than the admin transactions.  A keyID is
+// active on a day when it co-signs an output created or spent by a block of
+// the day.  The index keeps the number of such blocks of each active keyID in
+// an additional bucket, so the active keyIDs can be counted once and the
+// count reverted when blocks are disconnected.  The serialized key format of
+// the active keyID bucket is:
+//
+//   <day><keyID>
+//
+//   Field    Type      Size
+//   day      uint32    4 bytes (big endian)
+//   keyID    uint32    4 bytes (big endian)
+//   -----
+//   Total: 8 bytes
+//
+// The serialized value format is:
+//
+//   <blocks>
+//
+//   Field     Type      Size
+//   blocks    uint32    4 bytes
+//   -----
+//   Total: 4 bytes
+// -----------------------------------------------------------------------------
+
+// DayStats describes the activity of the main chain during a UTC day.
+type DayStats struct {
+	// Timestamp is the start of the day in seconds since the Unix epoch.
+	Timestamp int64
+
+	// Blocks is the number of blocks with a timestamp during the day.
+	Blocks uint32
+
+	// Txs is the number of transactions in the blocks other than the
+	// coinbases.
+	Txs uint64
+
+	// Fees is the total fees in atoms paid by the transactions in the
+	// blocks.
+	Fees int64
+
+	// ActiveKeyIDs is the number of distinct keyIDs co-signing an output
+	// created or spent by the blocks.
+	ActiveKeyIDs uint32
+}
+
+// statsDay returns the day of the daily statistics index of the passed
+// timestamp in seconds since the Unix epoch.
+func statsDay(timestamp int64) uint32 {
+	if timestamp < 0 {
+		return 0
+	}
+	return uint32(timestamp / secondsPerDay)
+}
+
+// statsKey returns the key of the daily statistics index for the passed day.
+func statsKey(day uint32) []byte {
+	key := make([]byte, 4)
+	keyOrder.PutUint32(key, day)
+	return key
+}
+
+// statsKeyIDKey returns the key of the active keyID bucket for the passed day
+// and keyID.
+func statsKeyIDKey(day uint32, keyID btcec.KeyID) []byte {
+	key := make([]byte, statsKeyIDKeySize)
+	keyOrder.PutUint32(key, day)
+	keyOrder.PutUint32(key[4:], uint32(keyID))
+	return key
+}
+
+// serializeDayStats returns the serialization of the passed day statistics
+// for the daily statistics index.
+func serializeDayStats(stats *DayStats) []byte {
+	serialized := make([]byte, statsValueSize)
+	byteOrder.PutUint32(serialized, stats.Blocks)
+	byteOrder.PutUint64(serialized[4:], stats.Txs)
+	byteOrder.PutUint64(serialized[12:], uint64(stats.Fees))
+	byteOrder.PutUint32(serialized[20:], stats.ActiveKeyIDs)
+	return serialized
+}
+
+// deserializeDayStats decodes the passed serialized day statistics of the
+// passed day into the passed struct.
+func deserializeDayStats(day uint32, serialized []byte, stats *DayStats) error {
+	if len(serialized) < statsValueSize {
+		return database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt daily statistics "+
+				"index entry for day %d", day),
+		}
+	}
+	stats.Timestamp = int64(day) * secondsPerDay
+	stats.Blocks = byteOrder.Uint32(serialized)
+	stats.Txs = byteOrder.Uint64(serialized[4:])
+	stats.Fees = int64(byteOrder.Uint64(serialized[12:]))
+	stats.ActiveKeyIDs = byteOrder.Uint32(serialized[20:])
+	return nil
+}
+
+// blockStats returns the number of transactions other than the coinbase in the
+// passed block, the fees they pay, and the distinct keyIDs co-signing the
+// outputs the block creates or spends.  The passed view must contain the
+// outputs spent by the block.
+func blockStats(block *provautil.Block, view *blockchain.UtxoViewpoint) (uint64, int64, []btcec.KeyID) {
+	var fees int64
+	var keyIDs []btcec.KeyID
+	seen := make(map[btcec.KeyID]struct{})
+	addKeyIDs := func(pkScript []byte) {
+		for _, keyID := range keyIDsForPkScript(pkScript) {
+			if _, ok := seen[keyID]; ok {
+				continue
+			}
+			seen[keyID] = struct{}{}
+			keyIDs = append(keyIDs, keyID)
+		}
+	}
+
+	txns := block.Transactions()
+	for txIdx, tx := range txns {
+		msgTx := tx.MsgTx()
+		for _, txOut := range msgTx.TxOut {
+			addKeyIDs(txOut.PkScript)
+		}
+
+		// Coinbases do not reference any inputs.
+		if txIdx == 0 {
+			continue
+		}
+
+		// Admin transactions pay no fees, and issue or destroy funds
+		// which must not be mistaken for fees.
+		threadInt, _ := txscript.GetAdminDetailsMsgTx(msgTx)
+		isAdmin := threadInt >= 0
+		var in, out int64
+		for _, txIn := range msgTx.TxIn {
+			// The view should always have the input since the index
+			// contract requires it, however, be safe and simply
+			// ignore any missing entries.
+			origin := &txIn.PreviousOutPoint
+			entry := view.LookupEntry(&origin.Hash)
+			if entry == nil {
+				continue
+			}
+			addKeyIDs(entry.PkScriptByIndex(origin.Index))
+			in += entry.AmountByIndex(origin.Index)
+		}
+		for _, txOut := range msgTx.TxOut {
+			out += txOut.Value
+		}
+		if !isAdmin && in > out {
+			fees += in - out
+		}
+	}
+	return uint64(len(txns) - 1), fees, keyIDs
+}
+
+// StatsIndex implements a daily statistics index.  That is to say, it supports
+// querying the number of blocks, transactions, fees and active keyIDs of every
+// day of the main chain, which block explorers chart.
+type StatsIndex struct {
+	db database.DB
+}
+
+// Ensure the StatsIndex type implements the Indexer interface.
+var _ Indexer = (*StatsIndex)(nil)
+
+// Ensure the StatsIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*StatsIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *StatsIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Key() []byte {
+	return statsIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Name() string {
+	return statsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the day
+// statistics and the active keyIDs.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	if _, err := meta.CreateBucket(statsIndexKey); err != nil {
+		return err
+	}
+	_, err := meta.CreateBucket(statsKeyIDIndexKey)
+	return err
+}
+
+// updateDayStats adds the statistics of the passed block to the day of its
+// timestamp when connect is set, and removes them otherwise.
+func updateDayStats(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, connect bool) error {
+	meta := dbTx.Metadata()
+	bucket := meta.Bucket(statsIndexKey)
+	keyIDBucket := meta.Bucket(statsKeyIDIndexKey)
+
+	day := statsDay(block.MsgBlock().Header.Timestamp.Unix())
+	var stats DayStats
+	if serialized := bucket.Get(statsKey(day)); serialized != nil {
+		err := deserializeDayStats(day, serialized, &stats)
+		if err != nil {
+			return err
+		}
+	}
+
+	txs, fees, keyIDs := blockStats(block, view)
+	for _, keyID := range keyIDs {
+		key := statsKeyIDKey(day, keyID)
+		var blocks uint32
+		if serialized := keyIDBucket.Get(key); len(serialized) >= 4 {
+			blocks = byteOrder.Uint32(serialized)
+		}
+		if connect {
+			if blocks == 0 {
+				stats.ActiveKeyIDs++
+			}
+			blocks++
+		} else {
+			if blocks == 0 {
+				continue
+			}
+			blocks--
+			if blocks == 0 {
+				stats.ActiveKeyIDs--
+				if err := keyIDBucket.Delete(key); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		value := make([]byte, 4)
+		byteOrder.PutUint32(value, blocks)
+		if err := keyIDBucket.Put(key, value); err != nil {
+			return err
+		}
+	}
+
+	if connect {
+		stats.Blocks++
+		stats.Txs += txs
+		stats.Fees += fees
+	} else {
+		stats.Blocks--
+		stats.Txs -= txs
+		stats.Fees -= fees
+	}
+	if stats.Blocks == 0 {
+		return bucket.Delete(statsKey(day))
+	}
+	return bucket.Put(statsKey(day), serializeDayStats(&stats))
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the statistics of the block
+// to the day of its timestamp.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return updateDayStats(dbTx, block, view, true)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the statistics of
+// the block from the day of its timestamp.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return updateDayStats(dbTx, block, view, false)
+}
+
+// StatsForDays returns the statistics of the days from the one containing the
+// start time to the one containing the end time, both in seconds since the
+// Unix epoch, in the order of the days.  Days without blocks are omitted.
+//
+// This function is safe for concurrent access.
+func (idx *StatsIndex) StatsForDays(start, end int64) ([]DayStats, error) {
+	startDay, endDay := statsDay(start), statsDay(end)
+	var days []DayStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(statsIndexKey).Cursor()
+		for ok := cursor.Seek(statsKey(startDay)); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(key) != 4 {
+				return errDeserialize("unexpected daily statistics " +
+					"key size")
+			}
+			day := keyOrder.Uint32(key)
+			if day > endDay {
+				break
+			}
+
+			var stats DayStats
+			err := deserializeDayStats(day, cursor.Value(), &stats)
+			if err != nil {
+				return err
+			}
+			days = append(days, stats)
+		}
+		return nil
+	})
+	return days, err
+}
+
+// NewStatsIndex returns a new instance of an indexer that is used to maintain
+// the statistics of every day of the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewStatsIndex(db database.DB) *StatsIndex {
+	return &StatsIndex{db: db}
+}
+
+// dropStatsKeyIDBucket drops the bucket for the active keyIDs when it exists.
+func dropStatsKeyIDBucket(db database.DB) error {
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(statsKeyIDIndexKey) == nil {
+			return nil
+		}
+		return meta.DeleteBucket(statsKeyIDIndexKey)
+	})
+}
+
+// DropStatsIndex drops the daily statistics index from the provided database
+// if it exists.
+func DropStatsIndex(db database.DB) error {
+	return dropIndex(db, statsIndexKey, statsIndexName, nil)
+}
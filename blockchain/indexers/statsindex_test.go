@@ -0,0 +1,138 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestStatsIndex ensures the daily statistics index adds up the blocks of
+// each day, counts the keyIDs active during a day once, and removes the
+// statistics of disconnected blocks.
+func TestStatsIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "statsindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewStatsIndex(db)
+	payTo := func(keyIDs ...btcec.KeyID) []byte {
+		addr, err := provautil.NewAddressProva(
+			bytes.Repeat([]byte{0x42}, 20), keyIDs, params)
+		if err != nil {
+			t.Fatalf("NewAddressProva: unexpected error: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		return pkScript
+	}
+	newCoinbase := func(height uint32, txOuts ...*wire.TxOut) *wire.MsgTx {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+			Index: wire.MaxPrevOutIndex}, []byte{byte(height)}))
+		for _, txOut := range txOuts {
+			coinbase.AddTxOut(txOut)
+		}
+		return coinbase
+	}
+	newBlock := func(height uint32, timestamp int64, txns ...*wire.MsgTx) *provautil.Block {
+		block := provautil.NewBlock(&wire.MsgBlock{Transactions: txns})
+		block.MsgBlock().Header.Timestamp = time.Unix(timestamp, 0)
+		block.SetHeight(height)
+		return block
+	}
+
+	// The first two blocks are on the first day.  The first pays 50 to
+	// keyIDs 1 and 2 and the second spends it, paying 45 to keyIDs 1 and
+	// 3.  The third block is two days later and pays 10 to keyIDs 4 and 5.
+	coinbase := newCoinbase(1, wire.NewTxOut(50, payTo(1, 2)))
+	block1 := newBlock(1, 100, coinbase)
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: coinbase.TxHash()},
+		nil))
+	spend.AddTxOut(wire.NewTxOut(45, payTo(1, 3)))
+	block2 := newBlock(2, 200, newCoinbase(2), spend)
+
+	block3 := newBlock(3, 2*secondsPerDay+5,
+		newCoinbase(3, wire.NewTxOut(10, payTo(4, 5))))
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(provautil.NewTx(coinbase), 1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		for _, block := range []*provautil.Block{block1, block2, block3} {
+			if err := idx.ConnectBlock(dbTx, block, view); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	checkStats := func(start, end int64, want []DayStats) {
+		days, err := idx.StatsForDays(start, end)
+		if err != nil {
+			t.Fatalf("StatsForDays: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(days, want) {
+			t.Errorf("StatsForDays(%d, %d): got %+v, want %+v", start,
+				end, days, want)
+		}
+	}
+	day0 := DayStats{Timestamp: 0, Blocks: 2, Txs: 1, Fees: 5,
+		ActiveKeyIDs: 3}
+	day2 := DayStats{Timestamp: 2 * secondsPerDay, Blocks: 1,
+		ActiveKeyIDs: 2}
+	checkStats(0, 3*secondsPerDay, []DayStats{day0, day2})
+	checkStats(secondsPerDay, secondsPerDay+5, nil)
+	checkStats(2*secondsPerDay+100, 2*secondsPerDay+200,
+		[]DayStats{day2})
+
+	// Disconnecting the second block removes its transaction, fee and the
+	// keyID only it made active, and disconnecting the third block removes
+	// its day.
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.DisconnectBlock(dbTx, block3, view); err != nil {
+			return err
+		}
+		return idx.DisconnectBlock(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	checkStats(0, 3*secondsPerDay, []DayStats{{Timestamp: 0, Blocks: 1,
+		ActiveKeyIDs: 2}})
+}
@@ -0,0 +1,448 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// supplyIndexName is the human-readable name for the index.
+	supplyIndexName = "supply index"
+
+	// supplyKeySize is the number of bytes a key in the supply index
+	// consumes.  It consists of 4 bytes block height + 4 bytes transaction
+	// index within the block.
+	supplyKeySize = 4 + 4
+
+	// supplyValueMinSize is the minimum number of bytes a value in the
+	// supply index consumes.  It consists of the hash of the transaction +
+	// 8 bytes amount + 8 bytes supply + 1 byte number of keys, followed by
+	// the keys.
+	supplyValueMinSize = chainhash.HashSize + 8 + 8 + 1
+)
+
+var (
+	// supplyIndexKey is the key of the supply index and the db bucket used
+	// to house it.
+	supplyIndexKey = []byte("supplyidx")
+)
+
+// -----------------------------------------------------------------------------
+// The supply index consists of an entry for every issue thread transaction in
+// the main chain, that is to say for every issuance and destruction of funds.
+// Each entry holds the total supply after the transaction, so the supply at
+// any height is the supply of the last entry not above it.  The numeric fields
+// of the keys are big endian so the entries sort by their position in the
+// chain.
+//
+// The serialized format for keys in the supply index bucket is:
+//
+//   <block height><tx index>
+//
+//   Field           Type      Size
+//   block height    uint32    4 bytes
+//   tx index        uint32    4 bytes
+//   -----
+//   Total: 8 bytes
+//
+// The serialized value format is:
+//
+//   <tx hash><amount><supply><num keys><key>...
+//
+//   Field           Type              Size
+//   tx hash         chainhash.Hash    32 bytes
+//   amount          int64             8 bytes
+//   supply          uint64            8 bytes
+//   num keys        uint8             1 byte
+//   key             [33]byte          33 bytes each
+//   -----
+//   Total: 49 bytes + 33 bytes per key
+//
+// The amount is positive for issuances and negative for destructions.  The
+// keys are the compressed issue keys which signed the spend of the issue
+// thread.
+// -----------------------------------------------------------------------------
+
+// SupplyChange describes an issuance or destruction of funds by an issue
+// thread transaction in the main chain.
+type SupplyChange struct {
+	// TxHash is the hash of the issue thread transaction.
+	TxHash chainhash.Hash
+
+	// Height is the height of the block containing the transaction.
+	Height uint32
+
+	// TxIndex is the index of the transaction within its block.
+	TxIndex uint32
+
+	// Amount is the amount in atoms issued by the transaction, which is
+	// negative when the transaction destroys funds.
+	Amount int64
+
+	// Supply is the total supply in atoms after the transaction.
+	Supply uint64
+
+	// Keys are the issue keys which authorized the transaction.
+	Keys []*btcec.PublicKey
+}
+
+// supplyKey returns the key of the supply index for the passed block height
+// and transaction index.
+func supplyKey(height, txIndex uint32) []byte {
+	key := make([]byte, supplyKeySize)
+	keyOrder.PutUint32(key, height)
+	keyOrder.PutUint32(key[4:], txIndex)
+	return key
+}
+
+// serializeSupplyChange returns the serialized value of the passed change for
+// the supply index.
+func serializeSupplyChange(change *SupplyChange) []byte {
+	value := make([]byte, supplyValueMinSize,
+		supplyValueMinSize+len(change.Keys)*btcec.PubKeyBytesLenCompressed)
+	copy(value, change.TxHash[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint64(value[offset:], uint64(change.Amount))
+	offset += 8
+	byteOrder.PutUint64(value[offset:], change.Supply)
+	offset += 8
+	value[offset] = uint8(len(change.Keys))
+	for _, key := range change.Keys {
+		value = append(value, key.SerializeCompressed()...)
+	}
+	return value
+}
+
+// deserializeSupplyChange decodes the passed key and value of the supply index
+// into the passed change.
+func deserializeSupplyChange(key, value []byte, change *SupplyChange) error {
+	corrupt := func() error {
+		return database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt supply index entry "+
+				"for key %x", key),
+		}
+	}
+
+	// Ensure the serialized data has enough bytes to properly deserialize.
+	if len(key) < supplyKeySize || len(value) < supplyValueMinSize {
+		return corrupt()
+	}
+	numKeys := int(value[supplyValueMinSize-1])
+	if len(value) < supplyValueMinSize+
+		numKeys*btcec.PubKeyBytesLenCompressed {
+
+		return corrupt()
+	}
+
+	change.Height = keyOrder.Uint32(key)
+	change.TxIndex = keyOrder.Uint32(key[4:])
+	copy(change.TxHash[:], value[:chainhash.HashSize])
+	offset := chainhash.HashSize
+	change.Amount = int64(byteOrder.Uint64(value[offset:]))
+	offset += 8
+	change.Supply = byteOrder.Uint64(value[offset:])
+	offset = supplyValueMinSize
+	change.Keys = make([]*btcec.PublicKey, 0, numKeys)
+	for i := 0; i < numKeys; i++ {
+		end := offset + btcec.PubKeyBytesLenCompressed
+		pubKey, err := btcec.ParsePubKey(value[offset:end], btcec.S256())
+		if err != nil {
+			return corrupt()
+		}
+		change.Keys = append(change.Keys, pubKey)
+		offset = end
+	}
+	return nil
+}
+
+// issueTxAmount returns the amount in atoms issued by the passed transaction
+// and whether it is an issue thread transaction at all.  Like the chain state,
+// an issue thread transaction spending only the thread issues the value of all
+// of its other outputs, while one spending more inputs destroys the value of
+// its nulldata outputs, for which a negative amount is returned.
+func issueTxAmount(tx *provautil.Tx) (int64, bool) {
+	threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+	if threadInt < 0 || provautil.ThreadID(threadInt) != provautil.IssueThread {
+		return 0, false
+	}
+
+	msgTx := tx.MsgTx()
+	var amount int64
+	if len(msgTx.TxIn) > 1 {
+		for i, pops := range adminOutputs {
+			if txscript.TypeOfScript(pops) == txscript.NullDataTy {
+				amount -= msgTx.TxOut[i+1].Value
+			}
+		}
+		return amount, true
+	}
+	for _, txOut := range msgTx.TxOut[1:] {
+		amount += txOut.Value
+	}
+	return amount, true
+}
+
+// issueTxKeys returns the keys which signed the spend of the issue thread by
+// the passed issue thread transaction.  The signature script of the thread
+// input pushes the signing keys along with their signatures.
+func issueTxKeys(tx *provautil.Tx) []*btcec.PublicKey {
+	pushes, err := txscript.PushedData(tx.MsgTx().TxIn[0].SignatureScript)
+	if err != nil {
+		return nil
+	}
+	var keys []*btcec.PublicKey
+	for _, data := range pushes {
+		if len(data) != btcec.PubKeyBytesLenCompressed {
+			continue
+		}
+		pubKey, err := btcec.ParsePubKey(data, btcec.S256())
+		if err != nil {
+			continue
+		}
+		keys = append(keys, pubKey)
+	}
+	return keys
+}
+
+// dbFetchSupply uses an existing database transaction to fetch the total
+// supply after the block at the passed height.
+func dbFetchSupply(dbTx database.Tx, height uint32) (uint64, error) {
+	// Position the cursor at the first entry above the height and step
+	// back to the entry before it, which is the last entry not above the
+	// height when there is any.
+	cursor := dbTx.Metadata().Bucket(supplyIndexKey).Cursor()
+	var ok bool
+	if height == ^uint32(0) || !cursor.Seek(supplyKey(height+1, 0)) {
+		ok = cursor.Last()
+	} else {
+		ok = cursor.Prev()
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	var change SupplyChange
+	err := deserializeSupplyChange(cursor.Key(), cursor.Value(), &change)
+	if err != nil {
+		return 0, err
+	}
+	return change.Supply, nil
+}
+
+// SupplyIndex implements a supply history index.  That is to say, it records
+// every issuance and destruction of funds in the main chain along with the
+// keys which authorized it and the resulting total supply, so the supply can
+// be audited over time.
+type SupplyIndex struct {
+	db database.DB
+}
+
+// Ensure the SupplyIndex type implements the Indexer interface.
+var _ Indexer = (*SupplyIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) Key() []byte {
+	return supplyIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) Name() string {
+	return supplyIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the supply
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(supplyIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every issue
+// thread transaction in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(supplyIndexKey)
+	height := uint32(block.Height())
+	var supply uint64
+	loaded := false
+	for txIdx, tx := range block.Transactions() {
+		amount, ok := issueTxAmount(tx)
+		if !ok {
+			continue
+		}
+
+		// Load the supply before the block once it is needed.
+		if !loaded {
+			var err error
+			supply, err = dbFetchSupply(dbTx, height)
+			if err != nil {
+				return err
+			}
+			loaded = true
+		}
+
+		supply = uint64(int64(supply) + amount)
+		change := SupplyChange{
+			TxHash: *tx.Hash(),
+			Amount: amount,
+			Supply: supply,
+			Keys:   issueTxKeys(tx),
+		}
+		err := bucket.Put(supplyKey(height, uint32(txIdx)),
+			serializeSupplyChange(&change))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries of the
+// issue thread transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SupplyIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(supplyIndexKey)
+	height := uint32(block.Height())
+	for txIdx, tx := range block.Transactions() {
+		if _, ok := issueTxAmount(tx); !ok {
+			continue
+		}
+		if err := bucket.Delete(supplyKey(height, uint32(txIdx))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SupplyHistory returns the issuances and destructions of funds in the blocks
+// of the main chain between the passed start and end heights, inclusive, in
+// the order of the chain.  An end height of zero returns the changes up to the
+// best height.
+//
+// This function is safe for concurrent access.
+func (idx *SupplyIndex) SupplyHistory(start, end uint32) ([]SupplyChange, error) {
+	var changes []SupplyChange
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(supplyIndexKey).Cursor()
+		for ok := cursor.Seek(supplyKey(start, 0)); ok; ok = cursor.Next() {
+			var change SupplyChange
+			err := deserializeSupplyChange(cursor.Key(),
+				cursor.Value(), &change)
+			if err != nil {
+				return err
+			}
+			if end != 0 && change.Height > end {
+				break
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// SupplyTotals describes the issuances and destructions of funds in the main
+// chain as a whole.
+type SupplyTotals struct {
+	// Issued and Destroyed are the total amounts in atoms issued and
+	// destroyed by issue thread transactions.
+	Issued    uint64
+	Destroyed uint64
+
+	// LastIssueHeight is the height of the block containing the latest
+	// issuance, or zero when there was none.
+	LastIssueHeight uint32
+}
+
+// Totals returns the total amounts issued and destroyed in the main chain and
+// the height of the latest issuance.  Issue thread transactions are rare, so
+// this walks the whole index.
+//
+// This function is safe for concurrent access.
+func (idx *SupplyIndex) Totals() (*SupplyTotals, error) {
+	var totals SupplyTotals
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(supplyIndexKey).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			var change SupplyChange
+			err := deserializeSupplyChange(cursor.Key(),
+				cursor.Value(), &change)
+			if err != nil {
+				return err
+			}
+			if change.Amount < 0 {
+				totals.Destroyed += uint64(-change.Amount)
+				continue
+			}
+			totals.Issued += uint64(change.Amount)
+			totals.LastIssueHeight = change.Height
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
+// SupplyAtHeight returns the total supply in atoms after the block at the
+// passed height of the main chain.
+//
+// This function is safe for concurrent access.
+func (idx *SupplyIndex) SupplyAtHeight(height uint32) (uint64, error) {
+	var supply uint64
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		supply, err = dbFetchSupply(dbTx, height)
+		return err
+	})
+	return supply, err
+}
+
+// NewSupplyIndex returns a new instance of an indexer that is used to record
+// every issuance and destruction of funds in the main chain along with the
+// resulting total supply.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSupplyIndex(db database.DB) *SupplyIndex {
+	return &SupplyIndex{db: db}
+}
+
+// DropSupplyIndex drops the supply index from the provided database if it
+// exists.
+func DropSupplyIndex(db database.DB) error {
+	return dropIndex(db, supplyIndexKey, supplyIndexName, nil)
+}
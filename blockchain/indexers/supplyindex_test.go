@@ -0,0 +1,169 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txrules/adminbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSupplyIndex ensures the supply index records the issuances and
+// destructions of connected blocks along with the resulting supply, and
+// forgets them again when the blocks are disconnected.
+func TestSupplyIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "supplyindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.MainNetParams
+	idx := NewSupplyIndex(db)
+	addr, err := provautil.NewAddressProva(bytes.Repeat([]byte{0x42}, 20),
+		[]btcec.KeyID{1, 2}, params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	pubKey := privKey.PubKey()
+
+	// The first block issues 100 authorized by the issue key, and the
+	// second destroys 30 of it, paying the remaining 70 back as change.
+	threadTips := map[provautil.ThreadID]*wire.OutPoint{
+		provautil.IssueThread: {Index: 0},
+	}
+	issueTx, err := adminbuilder.NewIssueTx(threadTips,
+		[]*wire.TxOut{wire.NewTxOut(100, pkScript)})
+	if err != nil {
+		t.Fatalf("NewIssueTx: unexpected error: %v", err)
+	}
+	issueTx.TxIn[0].SignatureScript, err = txscript.NewScriptBuilder().
+		AddData(pubKey.SerializeCompressed()).
+		AddData(bytes.Repeat([]byte{0x30}, 70)).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	block1 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, issueTx}})
+	block1.SetHeight(1)
+
+	issueHash := issueTx.TxHash()
+	threadTips[provautil.IssueThread] = wire.NewOutPoint(&issueHash, 0)
+	destroyTx, err := adminbuilder.NewDestroyTx(threadTips,
+		[]*wire.OutPoint{wire.NewOutPoint(&issueHash, 1)}, 30,
+		[]*wire.TxOut{wire.NewTxOut(70, pkScript)})
+	if err != nil {
+		t.Fatalf("NewDestroyTx: unexpected error: %v", err)
+	}
+	coinbase2 := wire.NewMsgTx(1)
+	coinbase2.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x01}))
+	block2 := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase2, destroyTx}})
+	block2.SetHeight(2)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.ConnectBlock(dbTx, block1, nil); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, block2, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	changes, err := idx.SupplyHistory(0, 0)
+	if err != nil {
+		t.Fatalf("SupplyHistory: unexpected error: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("SupplyHistory: got %d changes, want 2", len(changes))
+	}
+	issue, destroy := changes[0], changes[1]
+	if issue.TxHash != issueHash || issue.Height != 1 ||
+		issue.TxIndex != 1 || issue.Amount != 100 ||
+		issue.Supply != 100 || len(issue.Keys) != 1 ||
+		!issue.Keys[0].IsEqual(pubKey) {
+
+		t.Errorf("SupplyHistory: got issuance %+v", issue)
+	}
+	if destroy.TxHash != destroyTx.TxHash() || destroy.Height != 2 ||
+		destroy.Amount != -30 || destroy.Supply != 70 ||
+		len(destroy.Keys) != 0 {
+
+		t.Errorf("SupplyHistory: got destruction %+v", destroy)
+	}
+	changes, err = idx.SupplyHistory(2, 2)
+	if err != nil || len(changes) != 1 {
+		t.Errorf("SupplyHistory: got %d changes (%v) for block 2, "+
+			"want 1", len(changes), err)
+	}
+
+	totals, err := idx.Totals()
+	if err != nil {
+		t.Fatalf("Totals: unexpected error: %v", err)
+	}
+	if totals.Issued != 100 || totals.Destroyed != 30 ||
+		totals.LastIssueHeight != 1 {
+
+		t.Errorf("Totals: got %+v, want 100 issued at height 1 and 30 "+
+			"destroyed", totals)
+	}
+
+	for height, want := range map[uint32]uint64{0: 0, 1: 100, 2: 70, 9: 70} {
+		supply, err := idx.SupplyAtHeight(height)
+		if err != nil {
+			t.Fatalf("SupplyAtHeight: unexpected error: %v", err)
+		}
+		if supply != want {
+			t.Errorf("SupplyAtHeight: got supply %d at height %d, "+
+				"want %d", supply, height, want)
+		}
+	}
+
+	// The destruction is forgotten once the second block is disconnected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block2, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	supply, err := idx.SupplyAtHeight(2)
+	if err != nil || supply != 100 {
+		t.Errorf("SupplyAtHeight: got supply %d (%v) after disconnect, "+
+			"want 100", supply, err)
+	}
+}
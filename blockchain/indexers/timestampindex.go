@@ -0,0 +1,208 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+const (
+	// timestampIndexName is the human-readable name for the index.
+	timestampIndexName = "timestamp index"
+
+	// timestampKeySize is the number of bytes a key in the timestamp index
+	// consumes.  It consists of 8 bytes block timestamp + 4 bytes block
+	// height.
+	timestampKeySize = 8 + 4
+)
+
+var (
+	// timestampIndexKey is the key of the timestamp index and the db bucket
+	// used to house it.
+	timestampIndexKey = []byte("timestampidx")
+)
+
+// -----------------------------------------------------------------------------
+// The timestamp index consists of an entry for every block in the main chain
+// which maps the timestamp of the block to its hash.  Block timestamps are not
+// strictly increasing, so the height of the block is part of the key in order
+// to keep the entries of blocks with the same timestamp apart.  The numeric
+// fields of the keys are big endian so the entries sort by time.
+//
+// The serialized format for keys in the timestamp index bucket is:
+//
+//   <timestamp><block height>
+//
+//   Field           Type      Size
+//   timestamp       uint64    8 bytes
+//   block height    uint32    4 bytes
+//   -----
+//   Total: 12 bytes
+//
+// The serialized value format is:
+//
+//   <block hash>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   -----
+//   Total: 32 bytes
+// -----------------------------------------------------------------------------
+
+// BlockTime describes a block of the main chain found by its timestamp.
+type BlockTime struct {
+	// Hash is the hash of the block.
+	Hash chainhash.Hash
+
+	// Height is the height of the block.
+	Height uint32
+
+	// Timestamp is the timestamp of the block in seconds since the Unix
+	// epoch.
+	Timestamp int64
+}
+
+// timestampKey returns the key of the timestamp index for the passed block
+// timestamp and height.
+func timestampKey(timestamp int64, height uint32) []byte {
+	key := make([]byte, timestampKeySize)
+	keyOrder.PutUint64(key, uint64(timestamp))
+	keyOrder.PutUint32(key[8:], height)
+	return key
+}
+
+// TimestampIndex implements a block hash by timestamp index.  That is to say,
+// it supports querying the block of the main chain which was created at or
+// just before a point in time, so external records can be reconciled against
+// the chain state at that time.
+type TimestampIndex struct {
+	db database.DB
+}
+
+// Ensure the TimestampIndex type implements the Indexer interface.
+var _ Indexer = (*TimestampIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Key() []byte {
+	return timestampIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Name() string {
+	return timestampIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the timestamp
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(timestampIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for the block.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	timestamp := block.MsgBlock().Header.Timestamp.Unix()
+	key := timestampKey(timestamp, uint32(block.Height()))
+	return dbTx.Metadata().Bucket(timestampIndexKey).Put(key,
+		block.Hash()[:])
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entry of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	timestamp := block.MsgBlock().Header.Timestamp.Unix()
+	key := timestampKey(timestamp, uint32(block.Height()))
+	return dbTx.Metadata().Bucket(timestampIndexKey).Delete(key)
+}
+
+// BlockByTime returns the block of the main chain with the latest timestamp at
+// or before the passed time in seconds since the Unix epoch.  Of several blocks
+// with that timestamp, the one with the greatest height is returned.  When all
+// blocks are later than the passed time, nil will be returned for both the
+// block and the error.
+//
+// This function is safe for concurrent access.
+func (idx *TimestampIndex) BlockByTime(timestamp int64) (*BlockTime, error) {
+	var blockTime *BlockTime
+	err := idx.db.View(func(dbTx database.Tx) error {
+		// Position the cursor at the first entry after the time and
+		// step back to the entry before it, which is the last entry at
+		// or before the time when there is any.
+		if timestamp < 0 {
+			return nil
+		}
+		cursor := dbTx.Metadata().Bucket(timestampIndexKey).Cursor()
+		var ok bool
+		if !cursor.Seek(timestampKey(timestamp+1, 0)) {
+			ok = cursor.Last()
+		} else {
+			ok = cursor.Prev()
+		}
+		if !ok {
+			return nil
+		}
+
+		// Ensure the serialized data has enough bytes to properly
+		// deserialize.
+		key, value := cursor.Key(), cursor.Value()
+		if len(key) < timestampKeySize || len(value) < chainhash.HashSize {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt timestamp index "+
+					"entry for key %x", key),
+			}
+		}
+		blockTime = &BlockTime{
+			Height:    keyOrder.Uint32(key[8:]),
+			Timestamp: int64(keyOrder.Uint64(key)),
+		}
+		copy(blockTime.Hash[:], value)
+		return nil
+	})
+	return blockTime, err
+}
+
+// NewTimestampIndex returns a new instance of an indexer that is used to create
+// a mapping of the timestamps of all blocks in the main chain to their hashes.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTimestampIndex(db database.DB) *TimestampIndex {
+	return &TimestampIndex{db: db}
+}
+
+// DropTimestampIndex drops the timestamp index from the provided database if
+// it exists.
+func DropTimestampIndex(db database.DB) error {
+	return dropIndex(db, timestampIndexKey, timestampIndexName, nil)
+}
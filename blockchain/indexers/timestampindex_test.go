@@ -0,0 +1,110 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestTimestampIndex ensures the timestamp index finds the block at or before
+// a time among the connected blocks, including blocks whose timestamps are
+// not increasing, and forgets blocks which are disconnected.
+func TestTimestampIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "timestampindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	// The blocks at heights 1 to 4 have the timestamps 100, 200, 150 and
+	// 200.
+	idx := NewTimestampIndex(db)
+	var blocks []*provautil.Block
+	for i, timestamp := range []int64{100, 200, 150, 200} {
+		block := provautil.NewBlock(wire.NewMsgBlock(wire.NewBlockHeader(
+			&chainhash.Hash{byte(i)}, &chainhash.Hash{}, 0, 0)))
+		block.MsgBlock().Header.Timestamp = time.Unix(timestamp, 0)
+		block.SetHeight(uint32(i + 1))
+		blocks = append(blocks, block)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			if err := idx.ConnectBlock(dbTx, block, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		timestamp  int64
+		wantHeight uint32 // 0 when there is no block
+	}{
+		{timestamp: 99, wantHeight: 0},
+		{timestamp: 100, wantHeight: 1},
+		{timestamp: 149, wantHeight: 1},
+		{timestamp: 150, wantHeight: 3},
+		{timestamp: 199, wantHeight: 3},
+		{timestamp: 200, wantHeight: 4},
+		{timestamp: 5000, wantHeight: 4},
+	}
+	for _, test := range tests {
+		blockTime, err := idx.BlockByTime(test.timestamp)
+		if err != nil {
+			t.Fatalf("BlockByTime: unexpected error: %v", err)
+		}
+		if test.wantHeight == 0 {
+			if blockTime != nil {
+				t.Errorf("BlockByTime(%d): got block %+v, want "+
+					"none", test.timestamp, blockTime)
+			}
+			continue
+		}
+		want := blocks[test.wantHeight-1]
+		if blockTime == nil || blockTime.Height != test.wantHeight ||
+			blockTime.Hash != *want.Hash() ||
+			blockTime.Timestamp != want.MsgBlock().Header.Timestamp.Unix() {
+
+			t.Errorf("BlockByTime(%d): got block %+v, want height %d",
+				test.timestamp, blockTime, test.wantHeight)
+		}
+	}
+
+	// The block at height 2 is found again once the last block is
+	// disconnected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[3], nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	blockTime, err := idx.BlockByTime(200)
+	if err != nil || blockTime == nil || blockTime.Height != 2 {
+		t.Errorf("BlockByTime: got block %+v (%v) after disconnect, "+
+			"want height 2", blockTime, err)
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/utxocommit"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// utxoCommitIndexName is the human-readable name for the index.
+	utxoCommitIndexName = "utxo commitment index"
+
+	// The prefixes of the keys of the bins, the nodes of the tree over the
+	// bins and the commitments of the blocks.
+	utxoCommitBinPrefix  = 'b'
+	utxoCommitNodePrefix = 'n'
+	utxoCommitRootPrefix = 'r'
+)
+
+var (
+	// utxoCommitIndexKey is the key of the utxo commitment index and the db
+	// bucket used to house it.
+	utxoCommitIndexKey = []byte("utxocommitidx")
+)
+
+// -----------------------------------------------------------------------------
+// The utxo commitment index maintains the commitment to the utxo set of the
+// utxocommit package as blocks are connected and disconnected, and keeps the
+// commitment of every block of the main chain.  All entries are kept in the
+// index bucket and told apart by the prefix of their keys.  The numeric fields
+// of the keys are big endian.
+//
+// The multiset hash of every bin which ever held an output is stored as:
+//
+//   key:   <'b'><bin>                  (1 + 2 bytes)
+//   value: <multiset hash>             (64 bytes)
+//
+// The nodes of the tree over the bins, with level 0 holding the digests of the
+// bins and level 12 the root, are stored as:
+//
+//   key:   <'n'><level><position>      (1 + 1 + 2 bytes)
+//   value: <node hash>                 (32 bytes)
+//
+// The commitment of each block of the main chain is stored as:
+//
+//   key:   <'r'><block height>         (1 + 4 bytes)
+//   value: <root><block hash>          (32 + 32 bytes)
+// -----------------------------------------------------------------------------
+
+// utxoCommitBinKey returns the key of the multiset hash of the passed bin.
+func utxoCommitBinKey(bin uint32) []byte {
+	key := make([]byte, 3)
+	key[0] = utxoCommitBinPrefix
+	keyOrder.PutUint16(key[1:], uint16(bin))
+	return key
+}
+
+// utxoCommitNodeKey returns the key of the node of the tree at the passed
+// level and position.
+func utxoCommitNodeKey(level uint8, pos uint32) []byte {
+	key := make([]byte, 4)
+	key[0] = utxoCommitNodePrefix
+	key[1] = level
+	keyOrder.PutUint16(key[2:], uint16(pos))
+	return key
+}
+
+// utxoCommitRootKey returns the key of the commitment of the block at the
+// passed height.
+func utxoCommitRootKey(height uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = utxoCommitRootPrefix
+	keyOrder.PutUint32(key[1:], height)
+	return key
+}
+
+// utxoCommitNodeStore stores the nodes of the tree over the bins in the index
+// bucket.
+type utxoCommitNodeStore struct {
+	bucket database.Bucket
+}
+
+// FetchNode returns the node at the passed level and position, or nil when it
+// was never stored.
+//
+// This is part of the utxocommit.NodeStore interface.
+func (s utxoCommitNodeStore) FetchNode(level uint8, pos uint32) (*chainhash.Hash, error) {
+	serialized := s.bucket.Get(utxoCommitNodeKey(level, pos))
+	if serialized == nil {
+		return nil, nil
+	}
+	return chainhash.NewHash(serialized)
+}
+
+// PutNode stores the node at the passed level and position.
+//
+// This is part of the utxocommit.NodeStore interface.
+func (s utxoCommitNodeStore) PutNode(level uint8, pos uint32, hash *chainhash.Hash) error {
+	return s.bucket.Put(utxoCommitNodeKey(level, pos), hash[:])
+}
+
+// UtxoCommitment is the commitment to the utxo set as of a block of the main
+// chain.
+type UtxoCommitment struct {
+	// Hash and Height identify the block.
+	Hash   chainhash.Hash
+	Height uint32
+
+	// Root is the root of the tree over the bins of the utxo set.
+	Root chainhash.Hash
+}
+
+// deserializeUtxoCommitment decodes the commitment stored under the passed key.
+func deserializeUtxoCommitment(key, value []byte) (*UtxoCommitment, error) {
+	if len(key) != 5 || len(value) != 2*chainhash.HashSize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt utxo commitment "+
+				"index entry for key %x", key),
+		}
+	}
+	commitment := &UtxoCommitment{Height: keyOrder.Uint32(key[1:])}
+	copy(commitment.Root[:], value)
+	copy(commitment.Hash[:], value[chainhash.HashSize:])
+	return commitment, nil
+}
+
+// UtxoCommitIndex implements an incremental commitment to the utxo set.  That
+// is to say, it maintains the commitment of the utxocommit package as of every
+// block of the main chain, and the branches of the bins as of the best block,
+// so it supports proving to auditors that outputs are unspent without a full
+// node.
+type UtxoCommitIndex struct {
+	db database.DB
+}
+
+// Ensure the UtxoCommitIndex type implements the Indexer interface.
+var _ Indexer = (*UtxoCommitIndex)(nil)
+
+// Ensure the UtxoCommitIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*UtxoCommitIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *UtxoCommitIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) Key() []byte {
+	return utxoCommitIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) Name() string {
+	return utxoCommitIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the utxo
+// commitment index.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(utxoCommitIndexKey)
+	return err
+}
+
+// blockUtxoChanges returns the outputs added to the utxo set by the passed
+// block and the outputs it spends.  Provably unspendable outputs are never
+// added to the utxo set, so they are left out.  The passed view must contain
+// the outputs spent by the block.
+func blockUtxoChanges(block *provautil.Block, view *blockchain.UtxoViewpoint) ([]utxocommit.Output, []utxocommit.Output, error) {
+	var created, spent []utxocommit.Output
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.  Unlike other indexes,
+		// the commitment can't skip missing inputs without going out of
+		// step with the utxo set.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					return nil, nil, fmt.Errorf("missing "+
+						"input %v of transaction %v",
+						origin, tx.Hash())
+				}
+				spent = append(spent, utxocommit.Output{
+					OutPoint: *origin,
+					Amount:   entry.AmountByIndex(origin.Index),
+					PkScript: entry.PkScriptByIndex(origin.Index),
+				})
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			created = append(created, utxocommit.Output{
+				OutPoint: wire.OutPoint{Hash: *tx.Hash(),
+					Index: uint32(txOutIdx)},
+				Amount:   txOut.Value,
+				PkScript: txOut.PkScript,
+			})
+		}
+	}
+	return created, spent, nil
+}
+
+// updateCommitment adds the passed outputs to and removes the other passed
+// outputs from the bins they belong to, and updates the tree over the bins.
+func updateCommitment(bucket database.Bucket, added, removed []utxocommit.Output) error {
+	bins := make(map[uint32]*utxocommit.Multiset)
+	fetchBin := func(bin uint32) (*utxocommit.Multiset, error) {
+		if m, ok := bins[bin]; ok {
+			return m, nil
+		}
+		m := new(utxocommit.Multiset)
+		serialized := bucket.Get(utxoCommitBinKey(bin))
+		if serialized != nil {
+			var err error
+			m, err = utxocommit.DeserializeMultiset(serialized)
+			if err != nil {
+				return nil, database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt utxo "+
+						"commitment of bin %d: %v",
+						bin, err),
+				}
+			}
+		}
+		bins[bin] = m
+		return m, nil
+	}
+
+	for i := range added {
+		m, err := fetchBin(utxocommit.Bin(&added[i].OutPoint.Hash))
+		if err != nil {
+			return err
+		}
+		m.Add(&added[i])
+	}
+	for i := range removed {
+		m, err := fetchBin(utxocommit.Bin(&removed[i].OutPoint.Hash))
+		if err != nil {
+			return err
+		}
+		m.Remove(&removed[i])
+	}
+
+	store := utxoCommitNodeStore{bucket: bucket}
+	for bin, m := range bins {
+		err := bucket.Put(utxoCommitBinKey(bin), m.Serialize())
+		if err != nil {
+			return err
+		}
+		digest := m.Digest()
+		if err := utxocommit.SetBinDigest(store, bin, &digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the outputs created by the
+// block to the commitment, removes the outputs it spends, and stores the
+// resulting commitment of the block.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	created, spent, err := blockUtxoChanges(block, view)
+	if err != nil {
+		return err
+	}
+	bucket := dbTx.Metadata().Bucket(utxoCommitIndexKey)
+	if err := updateCommitment(bucket, created, spent); err != nil {
+		return err
+	}
+
+	root, err := utxocommit.Root(utxoCommitNodeStore{bucket: bucket})
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 2*chainhash.HashSize)
+	copy(value, root[:])
+	copy(value[chainhash.HashSize:], block.Hash()[:])
+	return bucket.Put(utxoCommitRootKey(uint32(block.Height())), value)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the outputs created
+// by the block from the commitment, adds back the outputs it spent, and
+// removes the commitment of the block.
+//
+// This is part of the Indexer interface.
+func (idx *UtxoCommitIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	created, spent, err := blockUtxoChanges(block, view)
+	if err != nil {
+		return err
+	}
+	bucket := dbTx.Metadata().Bucket(utxoCommitIndexKey)
+	if err := updateCommitment(bucket, spent, created); err != nil {
+		return err
+	}
+	return bucket.Delete(utxoCommitRootKey(uint32(block.Height())))
+}
+
+// Commitment returns the commitment to the utxo set as of the block of the
+// main chain at the passed height.  Nil is returned for both the commitment
+// and the error when the index holds no commitment for the height.
+//
+// This function is safe for concurrent access.
+func (idx *UtxoCommitIndex) Commitment(height uint32) (*UtxoCommitment, error) {
+	var commitment *UtxoCommitment
+	err := idx.db.View(func(dbTx database.Tx) error {
+		key := utxoCommitRootKey(height)
+		value := dbTx.Metadata().Bucket(utxoCommitIndexKey).Get(key)
+		if value == nil {
+			return nil
+		}
+		var err error
+		commitment, err = deserializeUtxoCommitment(key, value)
+		return err
+	})
+	return commitment, err
+}
+
+// Branch returns the siblings of the branch of the passed bin from the bin up
+// to the children of the root, along with the commitment of the tip of the
+// index they lead to.  The bins and their branches are only kept as of the
+// tip of the index.
+//
+// This function is safe for concurrent access.
+func (idx *UtxoCommitIndex) Branch(bin uint32) (*UtxoCommitment, []chainhash.Hash, error) {
+	if bin >= utxocommit.NumBins {
+		return nil, nil, fmt.Errorf("bin %d is out of range", bin)
+	}
+
+	var commitment *UtxoCommitment
+	var branch []chainhash.Hash
+	err := idx.db.View(func(dbTx database.Tx) error {
+		// The commitments sort after the bins and nodes, so the last
+		// entry of the bucket is the commitment of the tip.
+		bucket := dbTx.Metadata().Bucket(utxoCommitIndexKey)
+		cursor := bucket.Cursor()
+		if !cursor.Last() || cursor.Key()[0] != utxoCommitRootPrefix {
+			return fmt.Errorf("the %s holds no blocks",
+				utxoCommitIndexName)
+		}
+		var err error
+		commitment, err = deserializeUtxoCommitment(cursor.Key(),
+			cursor.Value())
+		if err != nil {
+			return err
+		}
+		branch, err = utxocommit.Branch(utxoCommitNodeStore{
+			bucket: bucket}, bin)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return commitment, branch, nil
+}
+
+// NewUtxoCommitIndex returns a new instance of an indexer that is used to
+// maintain a commitment to the utxo set of the main chain as of every block.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewUtxoCommitIndex(db database.DB) *UtxoCommitIndex {
+	return &UtxoCommitIndex{db: db}
+}
+
+// DropUtxoCommitIndex drops the utxo commitment index from the provided
+// database if it exists.
+func DropUtxoCommitIndex(db database.DB) error {
+	return dropIndex(db, utxoCommitIndexKey, utxoCommitIndexName, nil)
+}
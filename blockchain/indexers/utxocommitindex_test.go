@@ -0,0 +1,172 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/utxocommit"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestUtxoCommitIndex ensures the utxo commitment index commits to the outputs
+// which are unspent as of each block, so proofs of the bins of the utxo set
+// verify against it, and disconnecting a block restores the commitment of its
+// parent.
+func TestUtxoCommitIndex(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "utxocommitindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewUtxoCommitIndex(db)
+	newBlock := func(height uint32, txns ...*wire.MsgTx) *provautil.Block {
+		block := provautil.NewBlock(&wire.MsgBlock{Transactions: txns})
+		block.MsgBlock().Header.Nonce = uint64(height)
+		block.SetHeight(height)
+		return block
+	}
+
+	// The first block pays 50 and 25 along with an unspendable output,
+	// and the second block spends the first output, paying 45.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x01}))
+	coinbase.AddTxOut(wire.NewTxOut(50, []byte{0x51}))
+	coinbase.AddTxOut(wire.NewTxOut(25, []byte{0x52}))
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{0x6a, 0x01, 0x00}))
+	block1 := newBlock(1, coinbase)
+
+	coinbase2 := wire.NewMsgTx(1)
+	coinbase2.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x02}))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: coinbase.TxHash()},
+		nil))
+	spend.AddTxOut(wire.NewTxOut(45, []byte{0x53}))
+	block2 := newBlock(2, coinbase2, spend)
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(provautil.NewTx(coinbase), 1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.ConnectBlock(dbTx, block1, view); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+
+	// checkUtxos ensures the proofs of the bins of the passed unspent
+	// outputs verify against the commitment of the tip of the index, which
+	// is the passed block.
+	checkUtxos := func(block *provautil.Block, utxos []utxocommit.Output) {
+		bins := make(map[uint32][]utxocommit.Output)
+		for _, utxo := range utxos {
+			bin := utxocommit.Bin(&utxo.OutPoint.Hash)
+			bins[bin] = append(bins[bin], utxo)
+		}
+		for bin, outputs := range bins {
+			sort.Slice(outputs, func(i, j int) bool {
+				cmp := bytes.Compare(outputs[i].OutPoint.Hash[:],
+					outputs[j].OutPoint.Hash[:])
+				return cmp < 0 || cmp == 0 &&
+					outputs[i].OutPoint.Index <
+						outputs[j].OutPoint.Index
+			})
+			commitment, branch, err := idx.Branch(bin)
+			if err != nil {
+				t.Fatalf("Branch: unexpected error: %v", err)
+			}
+			if commitment.Hash != *block.Hash() ||
+				commitment.Height != uint32(block.Height()) {
+				t.Fatalf("Branch: got tip %v (%d), want %v (%d)",
+					commitment.Hash, commitment.Height,
+					block.Hash(), block.Height())
+			}
+			proof := utxocommit.Proof{
+				Height:    commitment.Height,
+				BlockHash: commitment.Hash,
+				Root:      commitment.Root,
+				Bin:       bin,
+				Outputs:   outputs,
+				Branch:    branch,
+			}
+			if err := proof.Verify(); err != nil {
+				t.Errorf("Verify: bin %d at height %d: %v", bin,
+					block.Height(), err)
+			}
+		}
+	}
+	out := func(tx *wire.MsgTx, index uint32) utxocommit.Output {
+		return utxocommit.Output{
+			OutPoint: wire.OutPoint{Hash: tx.TxHash(), Index: index},
+			Amount:   tx.TxOut[index].Value,
+			PkScript: tx.TxOut[index].PkScript,
+		}
+	}
+	checkUtxos(block2, []utxocommit.Output{out(coinbase, 1),
+		out(spend, 0)})
+
+	commitment1, err := idx.Commitment(1)
+	if err != nil {
+		t.Fatalf("Commitment: unexpected error: %v", err)
+	}
+	commitment2, err := idx.Commitment(2)
+	if err != nil {
+		t.Fatalf("Commitment: unexpected error: %v", err)
+	}
+	if commitment1 == nil || commitment2 == nil ||
+		commitment1.Hash != *block1.Hash() ||
+		commitment1.Root == commitment2.Root {
+
+		t.Fatalf("Commitment: got %+v and %+v", commitment1,
+			commitment2)
+	}
+
+	// Disconnecting the second block restores the commitment of the first
+	// and removes its own.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block2, view)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	checkUtxos(block1, []utxocommit.Output{out(coinbase, 0),
+		out(coinbase, 1)})
+	if commitment, err := idx.Commitment(2); err != nil || commitment != nil {
+		t.Errorf("Commitment: got %+v, %v for a disconnected block",
+			commitment, err)
+	}
+	_, branch, err := idx.Branch(0)
+	if err != nil {
+		t.Fatalf("Branch: unexpected error: %v", err)
+	}
+	proof := utxocommit.Proof{Root: commitment1.Root, Branch: branch}
+	if err := proof.Verify(); err != nil {
+		t.Errorf("Verify: empty bin: %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// IntegrityProblem describes an inconsistency in the database of the chain
+// which was detected by an integrity check.
+type IntegrityProblem struct {
+	// Height is the height of the first block of the main chain which is
+	// affected by the problem.  It is zero when the problem affects the
+	// database as a whole and can't be repaired by reconnecting blocks.
+	Height uint32
+
+	// Description describes the problem.
+	Description string
+}
+
+// IntegrityReport details the result of checking the database of the chain for
+// the damage an unclean shutdown or a failing disk can leave behind.
+type IntegrityReport struct {
+	// Hash and Height identify the end of the main chain at the time the
+	// checks were performed.
+	Hash   chainhash.Hash
+	Height uint32
+
+	// CheckedBlocks is the number of blocks at the end of the main chain
+	// which were checked.
+	CheckedBlocks uint32
+
+	// Problems describes each inconsistency which was detected.
+	Problems []IntegrityProblem
+}
+
+// Consistent returns whether or not the checks completed without detecting any
+// problems.
+func (r *IntegrityReport) Consistent() bool {
+	return len(r.Problems) == 0
+}
+
+// RepairHeight returns the height of the last block of the main chain below all
+// of the detected problems, which is the height RepairChainState needs to
+// rewind the chain state to in order to repair it.  It returns false when the
+// problems can't be repaired that way.
+func (r *IntegrityReport) RepairHeight() (uint32, bool) {
+	repairHeight := r.Height
+	for _, problem := range r.Problems {
+		if problem.Height == 0 {
+			return 0, false
+		}
+		if problem.Height-1 < repairHeight {
+			repairHeight = problem.Height - 1
+		}
+	}
+	return repairHeight, true
+}
+
+// addProblem records a problem affecting the block at the passed height with
+// the report.
+func (r *IntegrityReport) addProblem(height uint32, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, IntegrityProblem{
+		Height:      height,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkStateIntegrity verifies the buckets and the best chain and admin state
+// the chain keeps in the database exist and can be decoded, and that the best
+// chain state agrees with the end of the main chain held in memory.
+func checkStateIntegrity(dbTx database.Tx, report *IntegrityReport) {
+	meta := dbTx.Metadata()
+	for _, bucketName := range [][]byte{hashIndexBucketName,
+		heightIndexBucketName, spendJournalBucketName,
+		utxoSetBucketName} {
+
+		if meta.Bucket(bucketName) == nil {
+			report.addProblem(0, "bucket %s is missing", bucketName)
+		}
+	}
+
+	state, err := deserializeBestChainState(meta.Get(chainStateKeyName))
+	if err != nil {
+		report.addProblem(report.Height, "stored chain state is "+
+			"corrupt: %v", err)
+	} else if state.hash != report.Hash || state.height != report.Height {
+		report.addProblem(report.Height, "stored chain state ends at "+
+			"%v (height %d) instead of %v (height %d)", state.hash,
+			state.height, report.Hash, report.Height)
+	}
+
+	serialized := meta.Get(keySetBucketName)
+	if serialized == nil {
+		report.addProblem(report.Height, "stored admin state is missing")
+	} else if _, _, _, _, _, err := deserializeKeySet(serialized); err != nil {
+		report.addProblem(report.Height, "stored admin state is "+
+			"corrupt: %v", err)
+	}
+}
+
+// checkBlockIntegrity verifies the entries the chain keeps in the database for
+// the main chain block represented by the passed node: the block index entries
+// in both directions, the block data itself, which is checksummed by the
+// database, its link to the previous block, and the spend journal entry.
+func checkBlockIntegrity(dbTx database.Tx, node *blockNode, report *IntegrityReport) {
+	meta := dbTx.Metadata()
+	height := node.height
+	hash, err := dbFetchHashByHeight(dbTx, height)
+	if err != nil {
+		report.addProblem(height, "height index entry for height %d is "+
+			"missing", height)
+	} else if !hash.IsEqual(node.hash) {
+		report.addProblem(height, "height index maps height %d to %v "+
+			"instead of %v", height, hash, node.hash)
+	}
+
+	serializedHeight := meta.Bucket(hashIndexBucketName).Get(node.hash[:])
+	switch {
+	case serializedHeight == nil:
+		report.addProblem(height, "hash index entry for block %v is "+
+			"missing", node.hash)
+	case len(serializedHeight) != 4:
+		report.addProblem(height, "hash index entry for block %v is "+
+			"corrupt", node.hash)
+	case byteOrder.Uint32(serializedHeight) != height:
+		report.addProblem(height, "hash index maps block %v to height "+
+			"%d instead of %d", node.hash,
+			byteOrder.Uint32(serializedHeight), height)
+	}
+
+	blockBytes, err := dbTx.FetchBlock(node.hash)
+	if err != nil {
+		report.addProblem(height, "unable to load block %v: %v",
+			node.hash, err)
+		return
+	}
+	block, err := provautil.NewBlockFromBytes(blockBytes)
+	if err != nil {
+		report.addProblem(height, "unable to decode block %v: %v",
+			node.hash, err)
+		return
+	}
+	if !block.Hash().IsEqual(node.hash) {
+		report.addProblem(height, "block data stored for %v hashes to "+
+			"%v", node.hash, block.Hash())
+		return
+	}
+	if !block.MsgBlock().Header.PrevBlock.IsEqual(node.parentHash) {
+		report.addProblem(height, "block %v does not connect to %v",
+			node.hash, node.parentHash)
+	}
+
+	if countSpentOutputs(block) > 0 &&
+		meta.Bucket(spendJournalBucketName).Get(node.hash[:]) == nil {
+
+		report.addProblem(height, "spend journal entry for block %v is "+
+			"missing", node.hash)
+	}
+}
+
+// CheckIntegrity checks the database of the chain for the damage an unclean
+// shutdown or a failing disk can leave behind, which would otherwise surface as
+// obscure errors later on.  It performs the following checks:
+//
+//  - The chain buckets exist and the stored best chain and admin state can be
+//    decoded and agree with the main chain held in memory
+//  - For up to numBlocks blocks at the end of the main chain, the block index
+//    entries agree with each other, the block data can be loaded and passes
+//    its checksum, the blocks link together, and the spend journal entries
+//    exist
+//
+// Problems are recorded in the returned report, which also provides the height
+// the chain state needs to be rewound to in order to repair them with
+// RepairChainState, while an error is only returned when the checks could not
+// be performed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckIntegrity(numBlocks uint32) (*IntegrityReport, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	report := &IntegrityReport{
+		Hash:   *b.bestNode.hash,
+		Height: b.bestNode.height,
+	}
+	err := b.db.View(func(dbTx database.Tx) error {
+		checkStateIntegrity(dbTx, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The block entries can't be checked without the buckets.
+	if _, ok := report.RepairHeight(); !ok {
+		return report, nil
+	}
+
+	for node := b.bestNode; node != nil && node.height > 0 &&
+		report.CheckedBlocks < numBlocks; {
+
+		err := b.db.View(func(dbTx database.Tx) error {
+			checkBlockIntegrity(dbTx, node, report)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		report.CheckedBlocks++
+
+		prevNode, err := b.getPrevNodeFromNode(node)
+		if err != nil {
+			// The remaining blocks can't be found without the
+			// previous block, so stop here.
+			report.addProblem(node.height-1, "unable to load block "+
+				"%v: %v", node.parentHash, err)
+			break
+		}
+		node = prevNode
+	}
+
+	return report, nil
+}
+
+// RepairChainState repairs the chain state derived from the blocks of the main
+// chain after the passed height, such as the block index, the utxo set, and
+// the optional indexes, by disconnecting those blocks and connecting them again
+// from the block data.  It is intended to be called with the height reported by
+// CheckIntegrity.  The spend journal entries and block data of the blocks must
+// be intact for the repair to succeed, otherwise the chain state has to be
+// restored from a backup or synced from scratch.  They are all loaded before
+// the chain state is modified, so it is left untouched when they are not.
+//
+// Unlike a reorganization, the blocks are not validated again since they were
+// validated when they were first connected, and connecting the same blocks
+// again would be refused since their coinbases are already known.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) RepairChainState(height uint32) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// Nothing to do when there are no blocks after the height.
+	if height >= b.bestNode.height {
+		return nil
+	}
+
+	// Load the blocks after the height along with their spend journal
+	// entries, disconnecting each block from a temporary view so the
+	// entries can be decoded.
+	var nodes []*blockNode
+	var blocks []*provautil.Block
+	var blockStxos [][]spentTxOut
+	utxoView := NewUtxoViewpoint()
+	utxoView.SetBestHash(b.bestNode.hash)
+	for node := b.bestNode; node != nil && node.height > height; {
+		var block *provautil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHash(dbTx, node.hash)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to repair the chain state: %v",
+				err)
+		}
+		err = utxoView.fetchInputUtxos(b.db, block)
+		if err != nil {
+			return err
+		}
+		var stxos []spentTxOut
+		err = b.db.View(func(dbTx database.Tx) error {
+			stxos, err = dbFetchSpendJournalEntry(dbTx, block, utxoView)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to repair the chain state: %v",
+				err)
+		}
+		err = utxoView.disconnectTransactions(block, stxos)
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, node)
+		blocks = append(blocks, block)
+		blockStxos = append(blockStxos, stxos)
+		node, err = b.getPrevNodeFromNode(node)
+		if err != nil {
+			return fmt.Errorf("unable to repair the chain state: %v",
+				err)
+		}
+	}
+
+	log.Infof("Repairing the chain state by reconnecting the blocks at "+
+		"heights %d to %d", height+1, b.bestNode.height)
+
+	// Disconnect the blocks from the end of the main chain.
+	utxoView = NewUtxoViewpoint()
+	utxoView.SetBestHash(b.bestNode.hash)
+	keyView := NewKeyViewpoint()
+	keyView.SetThreadTips(b.threadTips)
+	keyView.SetLastKeyID(b.lastKeyID)
+	keyView.SetTotalSupply(b.totalSupply)
+	keyView.SetKeys(b.adminKeySets)
+	keyView.SetAdminThresholds(b.chainParams.AdminThresholds)
+	keyView.SetKeyIDs(b.aspKeyIdMap)
+	keyView.SetFrozenKeyIDs(b.frozenKeyIDs)
+	for i, node := range nodes {
+		block := blocks[i]
+		err := utxoView.fetchInputUtxos(b.db, block)
+		if err != nil {
+			return err
+		}
+		err = utxoView.disconnectTransactions(block, blockStxos[i])
+		if err != nil {
+			return err
+		}
+		if err := keyView.disconnectTransactions(block); err != nil {
+			return err
+		}
+		err = b.disconnectBlock(node, block, utxoView, keyView)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Connect them again in forwards order.
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node, block := nodes[i], blocks[i]
+		err := utxoView.fetchInputUtxos(b.db, block)
+		if err != nil {
+			return err
+		}
+		stxos := make([]spentTxOut, 0, countSpentOutputs(block))
+		err = utxoView.connectTransactions(block, &stxos)
+		if err != nil {
+			return err
+		}
+		keyView.connectTransactions(block)
+		err = b.connectBlock(node, block, utxoView, keyView, stxos)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Repaired the chain state at height %d", b.bestNode.height)
+	return nil
+}
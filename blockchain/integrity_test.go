@@ -0,0 +1,126 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestCheckIntegrity ensures damaged block index entries are detected by the
+// integrity checks and repaired by reconnecting the blocks after them.
+func TestCheckIntegrity(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	dbPath := filepath.Join(testDbRoot, "integrity")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(testDbRoot)
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			block.SetHeight(accepted.Height)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("block %q was not accepted: %v",
+					accepted.Name, err)
+			}
+		}
+	}
+	best := chain.BestSnapshot()
+	if best.Height < 3 {
+		t.Fatalf("the full block tests only produced %d blocks",
+			best.Height)
+	}
+
+	report, err := chain.CheckIntegrity(best.Height)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: unexpected error: %v", err)
+	}
+	if !report.Consistent() || report.CheckedBlocks != best.Height {
+		t.Fatalf("CheckIntegrity: got report %+v for the intact chain",
+			report)
+	}
+
+	// Map the block two below the tip to the wrong height and remove the
+	// height index entry of the tip, like an unclean shutdown could.
+	corruptHash, err := chain.BlockHashByHeight(best.Height - 2)
+	if err != nil {
+		t.Fatalf("BlockHashByHeight: unexpected error: %v", err)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		var serialized [4]byte
+		binary.LittleEndian.PutUint32(serialized[:], best.Height)
+		err := meta.Bucket([]byte("hashidx")).Put(corruptHash[:],
+			serialized[:])
+		if err != nil {
+			return err
+		}
+		return meta.Bucket([]byte("heightidx")).Delete(serialized[:])
+	})
+	if err != nil {
+		t.Fatalf("failed to corrupt the block index: %v", err)
+	}
+
+	report, err = chain.CheckIntegrity(best.Height)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: unexpected error: %v", err)
+	}
+	if len(report.Problems) != 2 {
+		t.Fatalf("CheckIntegrity: got problems %+v, want 2",
+			report.Problems)
+	}
+	repairHeight, ok := report.RepairHeight()
+	if !ok || repairHeight != best.Height-3 {
+		t.Fatalf("RepairHeight: got %d (%v), want %d", repairHeight, ok,
+			best.Height-3)
+	}
+
+	if err := chain.RepairChainState(repairHeight); err != nil {
+		t.Fatalf("RepairChainState: unexpected error: %v", err)
+	}
+	report, err = chain.CheckIntegrity(best.Height)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: unexpected error: %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("CheckIntegrity: got problems %+v after the repair",
+			report.Problems)
+	}
+	if got := chain.BestSnapshot(); *got.Hash != *best.Hash {
+		t.Fatalf("RepairChainState: best block is %v, want %v",
+			got.Hash, best.Hash)
+	}
+}
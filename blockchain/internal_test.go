@@ -15,6 +15,8 @@ package blockchain
 
 import (
 	"sort"
+
+	"github.com/bitgo/prova/provautil"
 )
 
 // TstSetCoinbaseMaturity makes the ability to set the coinbase maturity
@@ -42,3 +44,10 @@ var TstCheckBlockScripts = checkBlockScripts
 // TstDeserializeUtxoEntry makes the internal deserializeUtxoEntry function
 // available to the test package.
 var TstDeserializeUtxoEntry = deserializeUtxoEntry
+
+// TstCheckBIP0030 makes the internal checkBIP0030 function available to the
+// test package for a block at the height in its header.
+func (b *BlockChain) TstCheckBIP0030(block *provautil.Block, view *UtxoViewpoint) error {
+	node := newBlockNode(&block.MsgBlock().Header, block.Hash())
+	return b.checkBIP0030(node, block, view)
+}
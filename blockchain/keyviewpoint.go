@@ -8,6 +8,7 @@ package blockchain
 import (
 	"bytes"
 	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
@@ -17,11 +18,13 @@ import (
 // point of view in the chain. For example, it could be for the end of the main
 // chain, some point in the history of the main chain, or down a side chain.
 type KeyViewpoint struct {
-	threadTips   map[provautil.ThreadID]*wire.OutPoint
-	lastKeyID    btcec.KeyID
-	totalSupply  uint64
-	adminKeySets map[btcec.KeySetType]btcec.PublicKeySet
-	aspKeyIdMap  btcec.KeyIdMap
+	threadTips      map[provautil.ThreadID]*wire.OutPoint
+	lastKeyID       btcec.KeyID
+	totalSupply     uint64
+	adminKeySets    map[btcec.KeySetType]btcec.PublicKeySet
+	adminThresholds map[btcec.KeySetType]int
+	aspKeyIdMap     btcec.KeyIdMap
+	frozenKeyIDs    btcec.KeyIdMap
 }
 
 // ThreadTips returns
@@ -80,6 +83,23 @@ func (view *KeyViewpoint) GetAdminKeyHashes(threadID provautil.ThreadID) [][]byt
 	return hashes
 }
 
+// SetAdminThresholds sets the numbers of signatures from the keys of each
+// admin key set required to spend the tip of its thread, which are chain
+// parameters.
+func (view *KeyViewpoint) SetAdminThresholds(thresholds map[btcec.KeySetType]int) {
+	view.adminThresholds = thresholds
+}
+
+// AdminThreshold returns the number of signatures from the keys of the passed
+// admin key set required to spend the tip of its thread.  Key sets without a
+// threshold require chaincfg.DefaultAdminThreshold signatures.
+func (view *KeyViewpoint) AdminThreshold(setType btcec.KeySetType) int {
+	if threshold, ok := view.adminThresholds[setType]; ok {
+		return threshold
+	}
+	return chaincfg.DefaultAdminThreshold
+}
+
 // SetKeyIDs sets the mapping of keyIDs to ASP keys.
 func (view *KeyViewpoint) SetKeyIDs(aspKeyIdMap btcec.KeyIdMap) {
 	if aspKeyIdMap != nil {
@@ -93,6 +113,22 @@ func (view *KeyViewpoint) KeyIDs() btcec.KeyIdMap {
 	return view.aspKeyIdMap
 }
 
+// SetFrozenKeyIDs sets the mapping of frozen keyIDs to the ASP keys they
+// referred to when they were frozen.
+func (view *KeyViewpoint) SetFrozenKeyIDs(frozenKeyIDs btcec.KeyIdMap) {
+	if frozenKeyIDs != nil {
+		view.frozenKeyIDs = frozenKeyIDs.DeepCopy()
+	}
+}
+
+// FrozenKeyIDs returns a mapping of the frozen keyIDs to the ASP keys they
+// referred to when they were frozen, at the position in the chain the view
+// currently represents.  Outputs co-signed by a frozen keyID may only be spent
+// by issue thread transactions.
+func (view *KeyViewpoint) FrozenKeyIDs() btcec.KeyIdMap {
+	return view.frozenKeyIDs
+}
+
 // LookupKeyIDs returns pubKeyHashes for all registered KeyIDs
 func (view *KeyViewpoint) LookupKeyIDs(keyIDs []btcec.KeyID) map[btcec.KeyID][]byte {
 	keyIdMap := make(map[btcec.KeyID][]byte)
@@ -118,7 +154,15 @@ func (view *KeyViewpoint) ProcessAdminOuts(tx *provautil.Tx, blockHeight uint32)
 	}
 	if provautil.ThreadID(threadInt) == provautil.IssueThread {
 		isDestruction := len(tx.MsgTx().TxIn) > 1
-		if isDestruction {
+		if len(adminOutputs) > 0 && txscript.IsKeyIDFreezeOp(adminOutputs[0]) {
+			// if this is a keyID freeze operation, the supply
+			// does not change.
+			for i := 0; i < len(adminOutputs); i++ {
+				isFreeze, pubKey,
+					keyID := txscript.ExtractKeyIDFreezeOpData(adminOutputs[i])
+				view.applyFreezeOp(isFreeze, pubKey, keyID)
+			}
+		} else if isDestruction {
 			// if this is a destruction operation
 			// look over all non-prova outputs and sum them up.
 			for i := 0; i < len(adminOutputs); i++ {
@@ -172,6 +216,16 @@ func (view *KeyViewpoint) applyAdminOp(isAddOp bool,
 	}
 }
 
+// applyFreezeOp takes a single keyID freeze op and applies it to the view.
+func (view *KeyViewpoint) applyFreezeOp(isFreeze bool,
+	pubKey *btcec.PublicKey, keyID btcec.KeyID) {
+	if isFreeze {
+		view.frozenKeyIDs[keyID] = pubKey
+	} else {
+		delete(view.frozenKeyIDs, keyID)
+	}
+}
+
 // connectTransaction updates the view by processing all new admin operations in
 // the passed transaction.
 func (view *KeyViewpoint) connectTransaction(tx *provautil.Tx, blockHeight uint32) {
@@ -204,7 +258,14 @@ func (view *KeyViewpoint) disconnectTransactions(block *provautil.Block) error {
 			threadId := provautil.ThreadID(threadInt)
 			if threadId == provautil.IssueThread {
 				isDestruction := len(tx.MsgTx().TxIn) > 1
-				if isDestruction {
+				if len(adminOutputs) > 0 && txscript.IsKeyIDFreezeOp(adminOutputs[0]) {
+					for i := 0; i < len(adminOutputs); i++ {
+						isFreeze, pubKey,
+							keyID := txscript.ExtractKeyIDFreezeOpData(adminOutputs[i])
+						// isFreeze is negated, to revert the action
+						view.applyFreezeOp(!isFreeze, pubKey, keyID)
+					}
+				} else if isDestruction {
 					for i := 0; i < len(adminOutputs); i++ {
 						// if this output pk script is a NullDataTy, then,
 						// according to previous validation, it must be
@@ -256,5 +317,6 @@ func NewKeyViewpoint() *KeyViewpoint {
 		totalSupply:  uint64(0),
 		adminKeySets: make(map[btcec.KeySetType]btcec.PublicKeySet),
 		aspKeyIdMap:  make(map[btcec.KeyID]*btcec.PublicKey),
+		frozenKeyIDs: make(map[btcec.KeyID]*btcec.PublicKey),
 	}
 }
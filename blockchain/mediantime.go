@@ -217,3 +217,64 @@ func NewMedianTime() MedianTimeSource {
 		offsets:  make([]int64, 0, maxMedianTimeEntries),
 	}
 }
+
+// MockableTime is a MedianTimeSource whose adjusted time can be fixed to a mock
+// time, which lets test networks control the timestamps of new blocks and thus
+// the median time of the chain.  It behaves like the wrapped time source while
+// no mock time is set.
+type MockableTime struct {
+	MedianTimeSource
+
+	mtx      sync.Mutex
+	mockTime time.Time
+}
+
+// Ensure the MockableTime type implements the MedianTimeSource interface.
+var _ MedianTimeSource = (*MockableTime)(nil)
+
+// AdjustedTime returns the mock time when one is set and the adjusted time of
+// the wrapped time source otherwise.
+//
+// This function is safe for concurrent access and is part of the
+// MedianTimeSource interface implementation.
+func (m *MockableTime) AdjustedTime() time.Time {
+	m.mtx.Lock()
+	mockTime := m.mockTime
+	m.mtx.Unlock()
+
+	if !mockTime.IsZero() {
+		return mockTime
+	}
+	return m.MedianTimeSource.AdjustedTime()
+}
+
+// SetMockTime fixes the adjusted time to the passed time, limited to 1 second
+// precision.  The zero time removes the mock time.
+//
+// This function is safe for concurrent access.
+func (m *MockableTime) SetMockTime(mockTime time.Time) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if mockTime.IsZero() {
+		m.mockTime = time.Time{}
+		return
+	}
+	m.mockTime = time.Unix(mockTime.Unix(), 0)
+}
+
+// MockTime returns the mock time, which is the zero time when none is set.
+//
+// This function is safe for concurrent access.
+func (m *MockableTime) MockTime() time.Time {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.mockTime
+}
+
+// NewMockableTime returns a new MockableTime which wraps the passed time source
+// and has no mock time set.
+func NewMockableTime(source MedianTimeSource) *MockableTime {
+	return &MockableTime{MedianTimeSource: source}
+}
@@ -105,3 +105,37 @@ func TestMedianTime(t *testing.T) {
 		}
 	}
 }
+
+// TestMockableTime ensures a mock time overrides the adjusted time of the
+// wrapped time source until it is removed.
+func TestMockableTime(t *testing.T) {
+	source := blockchain.NewMedianTime()
+	mockable := blockchain.NewMockableTime(source)
+
+	// Allow the same fudge factor as above without a mock time.
+	before := source.AdjustedTime()
+	if got := mockable.AdjustedTime(); got.Before(before) ||
+		got.After(before.Add(time.Second)) {
+
+		t.Errorf("AdjustedTime: got %v without a mock time, want %v",
+			got, before)
+	}
+
+	mockTime := time.Unix(1500000000, 500)
+	mockable.SetMockTime(mockTime)
+	want := time.Unix(1500000000, 0)
+	if got := mockable.AdjustedTime(); !got.Equal(want) {
+		t.Errorf("AdjustedTime: got %v, want mock time %v", got, want)
+	}
+	if got := mockable.MockTime(); !got.Equal(want) {
+		t.Errorf("MockTime: got %v, want %v", got, want)
+	}
+
+	mockable.SetMockTime(time.Time{})
+	if got := mockable.AdjustedTime(); got.Equal(want) {
+		t.Errorf("AdjustedTime: mock time %v not removed", got)
+	}
+	if got := mockable.MockTime(); !got.IsZero() {
+		t.Errorf("MockTime: got %v after removing it", got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestOrphanBlocks ensures the orphan pool reports the chains of orphans it
+// holds along with their missing ancestors, drops the oldest orphan beyond its
+// limit, and does not report expired orphans.
+func TestOrphanBlocks(t *testing.T) {
+	b := &BlockChain{
+		orphans:      make(map[chainhash.Hash]*orphanBlock),
+		prevOrphans:  make(map[chainhash.Hash][]*orphanBlock),
+		maxOrphans:   3,
+		orphanExpiry: time.Hour,
+	}
+	newBlock := func(prevHash chainhash.Hash, height uint32) *provautil.Block {
+		return provautil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{
+				PrevBlock: prevHash,
+				Height:    height,
+				Timestamp: time.Unix(1500000000, 0),
+			},
+		})
+	}
+
+	// Two consecutive orphans share the root of their chain, which is the
+	// first of them.
+	missing := chainhash.Hash{0x01}
+	first := newBlock(missing, 10)
+	second := newBlock(*first.Hash(), 11)
+	b.addOrphanBlock(first)
+	b.addOrphanBlock(second)
+	infos := b.OrphanBlocks()
+	if len(infos) != 2 {
+		t.Fatalf("OrphanBlocks: got %d orphans, want 2", len(infos))
+	}
+	for _, info := range infos {
+		if info.Root != *first.Hash() || info.Missing != missing {
+			t.Errorf("OrphanBlocks: got root %v missing %v for "+
+				"orphan at height %d, want %v missing %v",
+				info.Root, info.Missing, info.Height, first.Hash(),
+				missing)
+		}
+	}
+	if infos[0].Hash != *first.Hash() || infos[0].PrevHash != missing ||
+		infos[0].Expiration.Sub(infos[0].Received) != time.Hour {
+
+		t.Errorf("OrphanBlocks: got %+v for the first orphan", infos[0])
+	}
+
+	// The oldest orphan is dropped to make room beyond the limit.
+	b.orphans[*first.Hash()].expiration = time.Now().Add(time.Minute)
+	b.addOrphanBlock(newBlock(chainhash.Hash{0x02}, 20))
+	b.addOrphanBlock(newBlock(chainhash.Hash{0x03}, 30))
+	if len(b.orphans) != 3 || b.IsKnownOrphan(first.Hash()) {
+		t.Fatalf("addOrphanBlock: got %d orphans, oldest kept %v",
+			len(b.orphans), b.IsKnownOrphan(first.Hash()))
+	}
+
+	// Expired orphans are not reported.
+	b.orphans[*second.Hash()].expiration = time.Now().Add(-time.Second)
+	for _, info := range b.OrphanBlocks() {
+		if info.Hash == *second.Hash() {
+			t.Error("OrphanBlocks: expired orphan reported")
+		}
+	}
+}
@@ -6,6 +6,7 @@
 package blockchain
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
@@ -116,6 +117,19 @@ func (b *BlockChain) processOrphans(hash *chainhash.Hash, flags BehaviorFlags) e
 	return nil
 }
 
+// checkContext returns the error associated with the passed context when it has
+// been canceled or its deadline has been exceeded, and nil otherwise.  It is
+// used by the context-aware variants of the public API to abort at points where
+// no chain state has been modified yet.
+func checkContext(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
 // ProcessBlock is the main workhorse for handling insertion of new blocks into
 // the block chain.  It includes functionality such as rejecting duplicate
 // blocks, ensuring blocks follow all rules, orphan handling, and insertion into
@@ -127,9 +141,26 @@ func (b *BlockChain) processOrphans(hash *chainhash.Hash, flags BehaviorFlags) e
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (bool, bool, error) {
+	return b.ProcessBlockContext(context.Background(), block, flags)
+}
+
+// ProcessBlockContext is identical to ProcessBlock except it accepts a context
+// which allows the caller to abort processing.  The context is checked once the
+// chain state lock has been acquired, after the context free sanity checks, and
+// immediately before the block is accepted into the chain.  The error from the
+// context is returned when it is done at any of those points.  Once acceptance
+// of the block has started, processing always runs to completion so the chain
+// state is never left partially updated.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockContext(ctx context.Context, block *provautil.Block, flags BehaviorFlags) (bool, bool, error) {
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	if err := checkContext(ctx); err != nil {
+		return false, false, err
+	}
+
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	dryRun := flags&BFDryRun == BFDryRun
 
@@ -199,6 +230,12 @@ func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (
 		}
 	}
 
+	// The sanity checks can be expensive for large blocks, so give the
+	// caller a chance to abort before doing any further work.
+	if err := checkContext(ctx); err != nil {
+		return false, false, err
+	}
+
 	// Handle orphan blocks.
 	prevHash := &blockHeader.PrevBlock
 	prevHashExists, err := b.blockExists(prevHash)
@@ -215,6 +252,12 @@ func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (
 		return false, true, nil
 	}
 
+	// Abort before any state is modified if the caller is no longer
+	// interested in the result.
+	if err := checkContext(ctx); err != nil {
+		return false, false, err
+	}
+
 	// The block has passed all context independent checks and appears sane
 	// enough to potentially accept it into the block chain.
 	isMainChain, err := b.maybeAcceptBlock(block, flags)
@@ -43,3 +43,31 @@ func IsGenerationShareRateLimited(pubKey wire.BlockValidatingPubKey, prevPubKeys
 	}
 	return false
 }
+
+// BlocksUntilEligible returns the number of blocks which have to be signed by
+// other validate keys before a block signed by the passed key no longer
+// violates the rate limits, given the keys which signed the blocks preceding
+// the next block, most recent first, and the size of the rate limiting window.
+// It is zero when the key may sign the next block.
+func BlocksUntilEligible(pubKey wire.BlockValidatingPubKey, prevPubKeys []wire.BlockValidatingPubKey, window, maxTrailing, maxShare int) int {
+	// Blocks signed by other keys push the blocks of the key out of the
+	// window, so the key is eligible at the latest once the whole window
+	// was signed by others.
+	var other wire.BlockValidatingPubKey
+	for n := 0; n < window; n++ {
+		keys := make([]wire.BlockValidatingPubKey, n, window)
+		for i := range keys {
+			keys[i] = other
+		}
+		keys = append(keys, prevPubKeys...)
+		if len(keys) > window {
+			keys = keys[:window]
+		}
+		if !IsGenerationTrailingRateLimited(pubKey, keys, maxTrailing) &&
+			!IsGenerationShareRateLimited(pubKey, keys, maxShare) {
+
+			return n
+		}
+	}
+	return window
+}
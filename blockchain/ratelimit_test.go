@@ -91,3 +91,33 @@ func TestIsGenerationShareRateLimited(t *testing.T) {
 		t.Fatalf("Expected no rate limit when mining is diverse")
 	}
 }
+
+// TestBlocksUntilEligible tests the number of blocks other validate keys have
+// to sign before a key is no longer rate limited.
+func TestBlocksUntilEligible(t *testing.T) {
+	key := wire.BlockValidatingPubKey{0x02, 0x01}
+	other := wire.BlockValidatingPubKey{0x02, 0x02}
+	k, o := key, other
+
+	tests := []struct {
+		name        string
+		prevPubKeys []wire.BlockValidatingPubKey
+		maxTrailing int
+		maxShare    int
+		want        int
+	}{
+		{"chain start", nil, 2, 30, 0},
+		{"no limits", []wire.BlockValidatingPubKey{k, k, k, k}, 0, 0, 0},
+		{"under limits", []wire.BlockValidatingPubKey{k, o, o, o, o, o, o, o, o, o}, 2, 30, 0},
+		{"trailing", []wire.BlockValidatingPubKey{k, k, o, o, o, o, o, o, o, o}, 2, 30, 1},
+		{"share", []wire.BlockValidatingPubKey{o, k, k, o, k, o, k, o, o, o}, 2, 30, 4},
+	}
+	for _, test := range tests {
+		got := BlocksUntilEligible(key, test.prevPubKeys, 10,
+			test.maxTrailing, test.maxShare)
+		if got != test.want {
+			t.Errorf("%s: got %d blocks, want %d", test.name, got,
+				test.want)
+		}
+	}
+}
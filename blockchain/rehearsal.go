@@ -0,0 +1,225 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// Deployment identifies a consensus rule change which is normally activated
+// once the majority of the network has upgraded its block version, and which
+// may instead be forced active in order to rehearse the upgrade.
+type Deployment uint8
+
+const (
+	// DeploymentStrictDER identifies the strict DER signature encoding
+	// rules defined by BIP0066.
+	DeploymentStrictDER Deployment = iota
+
+	// DeploymentCheckLockTimeVerify identifies the OP_CHECKLOCKTIMEVERIFY
+	// rules defined by BIP0065.
+	DeploymentCheckLockTimeVerify
+
+	// numDeployments is the number of known deployments.  It must be the
+	// last item in the definitions.
+	numDeployments
+)
+
+// Map of deployments back to their names for pretty printing.
+var deploymentNames = map[Deployment]string{
+	DeploymentStrictDER:           "strictder",
+	DeploymentCheckLockTimeVerify: "cltv",
+}
+
+// String returns the Deployment as a human-readable name.
+func (d Deployment) String() string {
+	if s := deploymentNames[d]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown Deployment (%d)", uint8(d))
+}
+
+// ParseDeployment returns the deployment with the passed name.  The names are
+// case insensitive.
+func ParseDeployment(name string) (Deployment, error) {
+	for d, s := range deploymentNames {
+		if strings.EqualFold(s, name) {
+			return d, nil
+		}
+	}
+
+	names := make([]string, 0, len(deploymentNames))
+	for _, s := range deploymentNames {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown deployment %q -- supported "+
+		"deployments are %v", name, names)
+}
+
+// isDeploymentForced returns whether or not the passed deployment has been
+// forced active for all blocks via the chain configuration.
+func (b *BlockChain) isDeploymentForced(d Deployment) bool {
+	return d < numDeployments && b.forcedDeployments[d]
+}
+
+// RehearsalConfig is a descriptor which specifies the upgrade rehearsal
+// configuration.
+type RehearsalConfig struct {
+	// SourceDB is the database which houses the historical main chain to
+	// replay.  It is only read from.
+	//
+	// This field is required.
+	SourceDB database.DB
+
+	// DB is an empty database used to house the chain state which is
+	// built while replaying the historical chain.
+	//
+	// This field is required.
+	DB database.DB
+
+	// ChainParams identifies which chain parameters the historical chain
+	// is replayed under.
+	//
+	// This field is required.
+	ChainParams *chaincfg.Params
+
+	// ForcedDeployments lists the rule changes to rehearse.  They are
+	// treated as active for every replayed block regardless of the block
+	// versions.
+	ForcedDeployments []Deployment
+
+	// SigCache defines a signature cache to use when validating
+	// signatures.
+	//
+	// This field can be nil.
+	SigCache *txscript.SigCache
+
+	// Progress, when set, is invoked with each replayed block once it has
+	// been accepted.
+	//
+	// This field can be nil.
+	Progress func(block *provautil.Block)
+}
+
+// RehearsalResult details the outcome of an upgrade rehearsal.
+type RehearsalResult struct {
+	// Height and Hash identify the end of the historical main chain which
+	// was replayed.
+	Height uint32
+	Hash   chainhash.Hash
+
+	// BlocksReplayed is the number of blocks which were accepted under the
+	// rehearsed rules.
+	BlocksReplayed uint32
+
+	// DivergenceHeight, DivergenceHash and DivergenceErr identify the first
+	// historical block rejected under the rehearsed rules along with the
+	// reason.  They are only set when the rehearsal diverged from history.
+	DivergenceHeight uint32
+	DivergenceHash   *chainhash.Hash
+	DivergenceErr    error
+}
+
+// Diverged returns whether or not a historical block was rejected under the
+// rehearsed rules.
+func (r *RehearsalResult) Diverged() bool {
+	return r.DivergenceHash != nil
+}
+
+// Rehearse replays the historical main chain in the source database under the
+// rehearsed rules and reports the first block which is rejected.  A chain
+// instance housed in the passed empty database processes every block with
+// full validation, including script validation of blocks before the latest
+// checkpoint, so the outcome reflects exactly what a node running the rehearsed
+// rules would have done with the same history.
+//
+// An error is only returned when the rehearsal could not be performed, such as
+// when the passed context is canceled.  Blocks rejected under the rehearsed
+// rules are reported via the result instead.
+func Rehearse(ctx context.Context, config *RehearsalConfig) (*RehearsalResult, error) {
+	if config.SourceDB == nil {
+		return nil, AssertError("blockchain.Rehearse source database is nil")
+	}
+
+	// Determine the end of the historical main chain.
+	var result RehearsalResult
+	err := config.SourceDB.View(func(dbTx database.Tx) error {
+		serializedData := dbTx.Metadata().Get(chainStateKeyName)
+		if serializedData == nil {
+			return AssertError("blockchain.Rehearse source database " +
+				"does not contain a chain")
+		}
+		state, err := deserializeBestChainState(serializedData)
+		if err != nil {
+			return err
+		}
+		result.Height = state.height
+		result.Hash = state.hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := New(&Config{
+		DB:                config.DB,
+		ChainParams:       config.ChainParams,
+		TimeSource:        NewMedianTime(),
+		SigCache:          config.SigCache,
+		ForcedDeployments: config.ForcedDeployments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chain.bestNode.height != 0 {
+		return nil, AssertError("blockchain.Rehearse database is not empty")
+	}
+
+	for height := uint32(1); height <= result.Height; height++ {
+		var block *provautil.Block
+		err := config.SourceDB.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHeight(dbTx, height)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		isMainChain, isOrphan, err := chain.ProcessBlockContext(ctx,
+			block, BFNone)
+		if err != nil {
+			if _, ok := err.(RuleError); !ok {
+				return nil, err
+			}
+		} else if isOrphan || !isMainChain {
+			err = fmt.Errorf("block was not connected to the main "+
+				"chain (orphan %v)", isOrphan)
+		}
+		if err != nil {
+			result.DivergenceHeight = height
+			result.DivergenceHash = block.Hash()
+			result.DivergenceErr = err
+			return &result, nil
+		}
+
+		result.BlocksReplayed++
+		if config.Progress != nil {
+			config.Progress(block)
+		}
+	}
+
+	return &result, nil
+}
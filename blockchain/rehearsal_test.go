@@ -0,0 +1,106 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestParseDeployment ensures deployments round trip through their names.
+func TestParseDeployment(t *testing.T) {
+	for _, d := range []blockchain.Deployment{blockchain.DeploymentStrictDER,
+		blockchain.DeploymentCheckLockTimeVerify} {
+
+		got, err := blockchain.ParseDeployment(d.String())
+		if err != nil || got != d {
+			t.Errorf("ParseDeployment(%q): got %v (err %v), want %v",
+				d.String(), got, err, d)
+		}
+	}
+	if _, err := blockchain.ParseDeployment("bogus"); err == nil {
+		t.Error("ParseDeployment: did not fail on unknown deployment")
+	}
+}
+
+// TestRehearse ensures replaying the main chain produced by the full block
+// tests under the current rules does not diverge.
+func TestRehearse(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	// The source chain is populated with the full block tests, while the
+	// rehearsal is performed against a separate empty database.
+	createDB := func(dbName string) database.DB {
+		dbPath := filepath.Join(testDbRoot, dbName)
+		_ = os.RemoveAll(dbPath)
+		db, err := database.Create(testDbType, dbPath, blockDataNet)
+		if err != nil {
+			t.Fatalf("error creating db: %v", err)
+		}
+		return db
+	}
+	defer os.RemoveAll(testDbRoot)
+	sourceDB := createDB("rehearsesource")
+	defer sourceDB.Close()
+	db := createDB("rehearse")
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	source, err := blockchain.New(&blockchain.Config{
+		DB:          sourceDB,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			block.SetHeight(accepted.Height)
+			_, _, err := source.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("block %q was not accepted: %v",
+					accepted.Name, err)
+			}
+		}
+	}
+
+	result, err := blockchain.Rehearse(context.Background(),
+		&blockchain.RehearsalConfig{
+			SourceDB:    sourceDB,
+			DB:          db,
+			ChainParams: &params,
+		})
+	if err != nil {
+		t.Fatalf("Rehearse: unexpected error: %v", err)
+	}
+	if result.Diverged() {
+		t.Fatalf("Rehearse: unexpected divergence at height %d: %v",
+			result.DivergenceHeight, result.DivergenceErr)
+	}
+	best := source.BestSnapshot()
+	if result.Height != best.Height || result.Hash != *best.Hash ||
+		result.BlocksReplayed != best.Height {
+
+		t.Fatalf("Rehearse: unexpected result %+v, want height %d "+
+			"hash %v", result, best.Height, best.Hash)
+	}
+}
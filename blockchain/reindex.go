@@ -0,0 +1,292 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// reindexMaxDeletions is the maximum number of chain state entries deleted in
+// a single database transaction while resetting the chain state, which keeps
+// the memory usage of large utxo sets at reasonable levels.
+const reindexMaxDeletions = 500000
+
+// ReindexConfig is a descriptor which specifies the chain state reindex
+// configuration.
+type ReindexConfig struct {
+	// DB is the database which houses the blocks and the chain state to
+	// rebuild.
+	//
+	// This field is required.
+	DB database.DB
+
+	// ChainParams identifies which chain parameters the chain is associated
+	// with.
+	//
+	// This field is required.
+	ChainParams *chaincfg.Params
+
+	// Progress, when set, is invoked with each block once it has been
+	// reconnected.
+	//
+	// This field can be nil.
+	Progress func(block *provautil.Block)
+}
+
+// ReindexPending returns whether or not the passed database houses the partial
+// chain state of a chain state reindex which was interrupted.  Such a chain
+// state must not be used until ReindexChainState finishes rebuilding it, since
+// the blocks after its best block are already stored and would be refused when
+// they are received again.
+func ReindexPending(db database.DB) (bool, error) {
+	var pending bool
+	err := db.View(func(dbTx database.Tx) error {
+		pending = dbTx.Metadata().Get(reindexTipKeyName) != nil
+		return nil
+	})
+	return pending, err
+}
+
+// dbFetchMainChainHashes uses an existing database transaction to load the
+// hashes of the blocks from the passed tip back to, but not including, the
+// passed genesis block by following the previous block hashes of the stored
+// block headers, which do not depend on the chain state.  The hash of the tip
+// is the first entry.
+func dbFetchMainChainHashes(dbTx database.Tx, tip *chainhash.Hash, genesisHash *chainhash.Hash) ([]chainhash.Hash, error) {
+	var hashes []chainhash.Hash
+	hash := *tip
+	for {
+		header, err := dbFetchHeaderByHash(dbTx, &hash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load block %v of the "+
+				"main chain: %v", hash, err)
+		}
+		if header.Height == 0 {
+			if !hash.IsEqual(genesisHash) {
+				return nil, fmt.Errorf("the chain leading to "+
+					"block %v does not start with the "+
+					"genesis block", tip)
+			}
+			return hashes, nil
+		}
+		hashes = append(hashes, hash)
+		hash = header.PrevBlock
+	}
+}
+
+// dbDeleteBucketChunked deletes the bucket with the passed name along with all
+// of its entries.  Since the buckets of the chain state can be so large, the
+// entries are deleted in several database transactions, and ctx is checked
+// for cancellation between them.
+func dbDeleteBucketChunked(ctx context.Context, db database.DB, bucketName []byte) error {
+	for numDeleted := reindexMaxDeletions; numDeleted == reindexMaxDeletions; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		numDeleted = 0
+		err := db.Update(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket(bucketName)
+			if bucket == nil {
+				return nil
+			}
+			cursor := bucket.Cursor()
+			for ok := cursor.First(); ok; ok = cursor.Next() &&
+				numDeleted < reindexMaxDeletions {
+
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				numDeleted++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(bucketName) == nil {
+			return nil
+		}
+		return meta.DeleteBucket(bucketName)
+	})
+}
+
+// ReindexChainState rebuilds the chain state in the passed database, such as
+// the block index, the utxo set, the spend journal, and the admin key sets, by
+// resetting it to the genesis block and reconnecting the blocks of the main
+// chain from the blocks already stored in the database.  The main chain is
+// found by following the stored block headers back from the best block of the
+// chain state, so the other parts of the chain state may be damaged.  The
+// blocks are not validated again since they were validated when they were first
+// connected, and the optional indexes must be rebuilt by the caller.
+//
+// The block the chain state is rebuilt up to is recorded in the database, so a
+// reindex which is stopped by canceling the passed context, or by a crash, is
+// resumed from the best block of the partial chain state by calling this
+// function again.  Use ReindexPending to detect such a partial chain state.
+func ReindexChainState(ctx context.Context, config *ReindexConfig) (*BestState, error) {
+	if config.DB == nil {
+		return nil, AssertError("blockchain.ReindexChainState database " +
+			"is nil")
+	}
+	if config.ChainParams == nil {
+		return nil, AssertError("blockchain.ReindexChainState chain " +
+			"parameters nil")
+	}
+	db := config.DB
+
+	// Determine the block to rebuild the chain state up to, which is the
+	// block recorded by an interrupted reindex, or otherwise the best block
+	// of the chain state.  Then load the main chain leading to it.
+	var tip chainhash.Hash
+	var hashes []chainhash.Hash
+	var resuming, hasChainState bool
+	err := db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		serializedState := meta.Get(chainStateKeyName)
+		hasChainState = serializedState != nil
+		if serializedTip := meta.Get(reindexTipKeyName); serializedTip != nil {
+			if len(serializedTip) != chainhash.HashSize {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: "corrupt chain state reindex " +
+						"tip",
+				}
+			}
+			copy(tip[:], serializedTip)
+			resuming = true
+		} else {
+			if serializedState == nil {
+				return AssertError("blockchain.ReindexChainState " +
+					"database does not contain a chain")
+			}
+			state, err := deserializeBestChainState(serializedState)
+			if err != nil {
+				return fmt.Errorf("unable to load the best block "+
+					"of the chain state: %v", err)
+			}
+			tip = state.hash
+		}
+
+		var err error
+		genesisHash := config.ChainParams.GenesisBlock.BlockHash()
+		hashes, err = dbFetchMainChainHashes(dbTx, &tip, &genesisHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reset the chain state unless a previous reindex already did so.  The
+	// best chain state is removed first, so a reset which is interrupted is
+	// started over.
+	if !resuming || !hasChainState {
+		log.Infof("Resetting the chain state to rebuild it up to block "+
+			"%v (height %d)", tip, len(hashes))
+		err := db.Update(func(dbTx database.Tx) error {
+			meta := dbTx.Metadata()
+			if err := meta.Put(reindexTipKeyName, tip[:]); err != nil {
+				return err
+			}
+			if err := meta.Delete(chainStateKeyName); err != nil {
+				return err
+			}
+			return meta.Delete(keySetBucketName)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		bucketNames := [][]byte{hashIndexBucketName,
+			heightIndexBucketName, spendJournalBucketName,
+			utxoSetBucketName}
+		for _, bucketName := range bucketNames {
+			err := dbDeleteBucketChunked(ctx, db, bucketName)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Load the chain state, which is initialized to the genesis block once
+	// it has been reset.
+	chain, err := New(&Config{
+		DB:          db,
+		ChainParams: config.ChainParams,
+		TimeSource:  NewMedianTime(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The best block of a partial chain state must be in the main chain
+	// being rebuilt.
+	bestHeight := chain.bestNode.height
+	if int(bestHeight) > len(hashes) || (bestHeight > 0 &&
+		!hashes[len(hashes)-int(bestHeight)].IsEqual(chain.bestNode.hash)) {
+
+		return nil, fmt.Errorf("the best block %v of the partial chain "+
+			"state is not in the main chain leading to block %v",
+			chain.bestNode.hash, tip)
+	}
+	if bestHeight > 0 {
+		log.Infof("Resuming the chain state reindex at height %d",
+			bestHeight+1)
+	}
+
+	// Reconnect the remaining blocks in forwards order.
+	for i := len(hashes) - int(bestHeight) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var block *provautil.Block
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHash(dbTx, &hashes[i])
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to load block %v: %v",
+				hashes[i], err)
+		}
+
+		chain.chainLock.Lock()
+		isMainChain, err := chain.maybeAcceptBlock(block, BFFastAdd)
+		chain.chainLock.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("unable to reconnect block %v "+
+				"(height %d): %v", hashes[i], len(hashes)-i, err)
+		}
+		if !isMainChain {
+			return nil, AssertError(fmt.Sprintf("reconnected block "+
+				"%v is not in the main chain", hashes[i]))
+		}
+
+		if config.Progress != nil {
+			config.Progress(block)
+		}
+	}
+
+	// The chain state is complete, so it can be used again.
+	err = db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Delete(reindexTipKeyName)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chain.BestSnapshot(), nil
+}
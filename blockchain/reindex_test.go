@@ -0,0 +1,178 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestReindexChainState ensures a damaged chain state is rebuilt from the
+// stored blocks, and that an interrupted reindex is resumed.
+func TestReindexChainState(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	dbPath := filepath.Join(testDbRoot, "reindex")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(testDbRoot)
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			block.SetHeight(accepted.Height)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("block %q was not accepted: %v",
+					accepted.Name, err)
+			}
+		}
+	}
+	best := chain.BestSnapshot()
+	if best.Height < 3 {
+		t.Fatalf("the full block tests only produced %d blocks",
+			best.Height)
+	}
+
+	// Save the utxo set and the admin key sets and then damage them.
+	utxoSet := make(map[string][]byte)
+	var keySet []byte
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		keySet = append(keySet, meta.Get([]byte("keyset"))...)
+		bucket := meta.Bucket([]byte("utxoset"))
+		err := bucket.ForEach(func(k, v []byte) error {
+			utxoSet[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k := range utxoSet {
+			if err := bucket.Delete([]byte(k)); err != nil {
+				return err
+			}
+			break
+		}
+		return meta.Put([]byte("keyset"), []byte{0x01})
+	})
+	if err != nil {
+		t.Fatalf("failed to damage the chain state: %v", err)
+	}
+
+	// Interrupt the reindex after the first block, which must leave the
+	// partial chain state marked.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = blockchain.ReindexChainState(ctx, &blockchain.ReindexConfig{
+		DB:          db,
+		ChainParams: &params,
+		Progress:    func(*provautil.Block) { cancel() },
+	})
+	if err != context.Canceled {
+		t.Fatalf("ReindexChainState: got error %v, want %v", err,
+			context.Canceled)
+	}
+	pending, err := blockchain.ReindexPending(db)
+	if err != nil || !pending {
+		t.Fatalf("ReindexPending: got %v (err %v) after an interrupted "+
+			"reindex", pending, err)
+	}
+
+	// Resume the reindex.
+	var reconnected uint32
+	state, err := blockchain.ReindexChainState(context.Background(),
+		&blockchain.ReindexConfig{
+			DB:          db,
+			ChainParams: &params,
+			Progress:    func(*provautil.Block) { reconnected++ },
+		})
+	if err != nil {
+		t.Fatalf("ReindexChainState: unexpected error: %v", err)
+	}
+	if *state.Hash != *best.Hash || state.Height != best.Height {
+		t.Fatalf("ReindexChainState: best block is %v (height %d), "+
+			"want %v (height %d)", state.Hash, state.Height,
+			best.Hash, best.Height)
+	}
+	if reconnected != best.Height-1 {
+		t.Fatalf("ReindexChainState: reconnected %d blocks when "+
+			"resuming, want %d", reconnected, best.Height-1)
+	}
+	pending, err = blockchain.ReindexPending(db)
+	if err != nil || pending {
+		t.Fatalf("ReindexPending: got %v (err %v) after the reindex",
+			pending, err)
+	}
+
+	// The rebuilt chain state must match the one before the damage.
+	err = db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if got := meta.Get([]byte("keyset")); !bytes.Equal(got, keySet) {
+			t.Errorf("keyset: got %x, want %x", got, keySet)
+		}
+		numEntries := 0
+		err := meta.Bucket([]byte("utxoset")).ForEach(func(k, v []byte) error {
+			numEntries++
+			if want := utxoSet[string(k)]; !bytes.Equal(v, want) {
+				t.Errorf("utxo %x: got %x, want %x", k, v, want)
+			}
+			return nil
+		})
+		if numEntries != len(utxoSet) {
+			t.Errorf("utxo set: got %d entries, want %d",
+				numEntries, len(utxoSet))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to load the chain state: %v", err)
+	}
+
+	chain, err = blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to load the rebuilt chain: %v", err)
+	}
+	report, err := chain.CheckIntegrity(best.Height)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: unexpected error: %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("CheckIntegrity: got problems %+v after the reindex",
+			report.Problems)
+	}
+}
@@ -7,6 +7,7 @@ package blockchain
 
 import (
 	"fmt"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
@@ -22,6 +23,60 @@ type txValidateItem struct {
 	sigHashes *txscript.TxSigHashes // sighashes, as introduced with BIP0143, to be re-used with other inputs
 }
 
+// ResolvePkScript returns the script which is executed in order to spend an
+// output with the passed public key script.  The keyIDs of Prova scripts are
+// replaced with the hashes of the ASP keys they refer to, and admin thread
+// scripts are replaced with a script requiring the threshold of signatures from
+// the admin keys of the thread, both as of the point in the chain the passed key view
+// represents.  The keyIDs of generalized m-of-n Prova scripts are only resolved
+// when the passed flags include ScriptVerifyGeneralProva.  Scripts of any other
+// class are returned unmodified.
+func ResolvePkScript(pkScript []byte, keyView *KeyViewpoint, flags txscript.ScriptFlags) ([]byte, error) {
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse script: %v", err)
+	}
+
+	class := txscript.TypeOfScript(pops)
+	if class == txscript.GeneralProvaTy &&
+		flags&txscript.ScriptVerifyGeneralProva == 0 {
+
+		return pkScript, nil
+	}
+	switch class {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy, txscript.GeneralProvaTy:
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract keyIDs: %v", err)
+		}
+		keyIdMap := keyView.LookupKeyIDs(keyIDs)
+		err = txscript.ReplaceGeneralKeyIDs(pops, keyIdMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace keyIDs %v: %v",
+				keyIDs, err)
+		}
+		pkScript, err = txscript.UnparseScript(pops)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unparse script: %v", err)
+		}
+
+	case txscript.ProvaAdminTy:
+		threadID, err := txscript.ExtractThreadID(pops)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract threadID: %v", err)
+		}
+		keyHashes := keyView.GetAdminKeyHashes(threadID)
+		threshold := keyView.AdminThreshold(btcec.KeySetType(threadID))
+		pkScript, err = txscript.ThreadPkScript(threshold, keyHashes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace threadID %v: %v",
+				threadID, err)
+		}
+	}
+
+	return pkScript, nil
+}
+
 // txValidator provides a type which asynchronously validates transaction
 // inputs.  It provides several channels for communication and a processing
 // function that is intended to be in run multiple goroutines.
@@ -34,6 +89,7 @@ type txValidator struct {
 	flags        txscript.ScriptFlags
 	sigCache     *txscript.SigCache
 	hashCache    *txscript.HashCache
+	sigBatch     *txscript.SigBatch
 }
 
 // sendResult sends the result of a script pair validation on the internal
@@ -84,61 +140,36 @@ out:
 				break out
 			}
 
-			// Before passing the script to the VM, we check whether it is an Prova script.
-			pops, err := txscript.ParseScript(pkScript)
+			// Signature verification can only be deferred to the
+			// batch for the Prova scripts since their outcome is
+			// decided by their final signature check, and only when
+			// the signature script can't execute any opcodes of its
+			// own.
+			sigScript := txIn.SignatureScript
+			deferSigs := false
+			switch txscript.GetScriptClass(pkScript) {
+			case txscript.ProvaTy, txscript.ProvaTimeLockTy,
+				txscript.ProvaAdminTy:
+
+				deferSigs = txscript.IsPushOnlyScript(sigScript)
+
+			case txscript.GeneralProvaTy:
+				deferSigs = v.flags&txscript.ScriptVerifyGeneralProva != 0 &&
+					txscript.IsPushOnlyScript(sigScript)
+			}
+
+			// Before passing the script to the VM, resolve any keyIDs
+			// and admin threads it refers to.
+			pkScript, err := ResolvePkScript(pkScript, v.keyView, v.flags)
 			if err != nil {
-				str := fmt.Sprintf("failed to parse script %s: %v", originTxHash, err)
+				str := fmt.Sprintf("failed to resolve script %s: %v",
+					originTxHash, err)
 				err := ruleError(ErrScriptMalformed, str)
 				v.sendResult(err)
 				break out
 			}
-			// If script is Prova script, we replace all keyIDs with pubKeyHashes.
-			if txscript.TypeOfScript(pops) == txscript.ProvaTy {
-				keyIDs, err := txscript.ExtractKeyIDs(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to extract keyIDs %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				keyIdMap := v.keyView.LookupKeyIDs(keyIDs)
-				err = txscript.ReplaceKeyIDs(pops, keyIdMap)
-				if err != nil {
-					str := fmt.Sprintf("failed to replace keyIDs %v, %v in %s", keyIDs[0], keyIDs[1], originTxHash)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				pkScript, err = txscript.UnparseScript(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to unparse script %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-			}
-
-			// If script is Prova admin script, we replace the threadID with pubKeyHashes.
-			if txscript.TypeOfScript(pops) == txscript.ProvaAdminTy {
-				threadID, err := txscript.ExtractThreadID(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to extract threadID %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				keyHashes := v.keyView.GetAdminKeyHashes(threadID)
-				pkScript, err = txscript.ThreadPkScript(keyHashes)
-				if err != nil {
-					str := fmt.Sprintf("failed to replace threadID %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-			}
 
 			// Create a new script engine for the script pair.
-			sigScript := txIn.SignatureScript
 			inputAmount := txEntry.AmountByIndex(originTxIndex)
 			vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
 				txVI.txInIndex, v.flags, v.sigCache, txVI.sigHashes, inputAmount)
@@ -153,6 +184,12 @@ out:
 				v.sendResult(err)
 				break out
 			}
+			if deferSigs {
+				vm.DeferSignatures(v.sigBatch)
+			}
+			if v.hashCache != nil {
+				vm.UseHashCache(v.hashCache, txVI.tx.Hash())
+			}
 
 			// Execute the script pair.
 			if err := vm.Execute(); err != nil {
@@ -177,7 +214,8 @@ out:
 }
 
 // Validate validates the scripts for all of the passed transaction inputs using
-// multiple goroutines.
+// multiple goroutines.  The signatures deferred while executing the scripts
+// are then verified together in a batch.
 func (v *txValidator) Validate(items []*txValidateItem) error {
 	if len(items) == 0 {
 		return nil
@@ -231,6 +269,13 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 	}
 
 	close(v.quitChan)
+
+	// Verify the signatures which were deferred to the batch.
+	if err := v.sigBatch.Verify(); err != nil {
+		str := fmt.Sprintf("failed to validate batched signatures - %v",
+			err)
+		return ruleError(ErrScriptValidation, str)
+	}
 	return nil
 }
 
@@ -245,26 +290,40 @@ func newTxValidator(utxoView *UtxoViewpoint, keyView *KeyViewpoint, flags txscri
 		keyView:      keyView,
 		sigCache:     sigCache,
 		hashCache:    hashCache,
+		sigBatch:     txscript.NewSigBatch(sigCache),
 		flags:        flags,
 	}
 }
 
+// cachedSigHashes returns the partial sighashes of the passed transaction.
+// When the HashCache is present and it doesn't yet contain the partial
+// sighashes for the transaction, they are added to it so they, along with the
+// signature hash digests calculated while validating the transaction, can be
+// re-used when the transaction is validated again, such as when the block
+// which includes a transaction accepted to the mempool is connected.
+func cachedSigHashes(tx *provautil.Tx, hashCache *txscript.HashCache) *txscript.TxSigHashes {
+	if hashCache != nil {
+		if !hashCache.ContainsHashes(tx.Hash()) {
+			hashCache.AddSigHashes(tx.MsgTx())
+		}
+		if sigHashes, ok := hashCache.GetSigHashes(tx.Hash()); ok {
+			return sigHashes
+		}
+	}
+
+	// The sighashes are not cached, such as when the cache is disabled.
+	return txscript.NewTxSigHashes(tx.MsgTx())
+}
+
 // ValidateTransactionScripts validates the scripts for the passed transaction
 // using multiple goroutines.
 func ValidateTransactionScripts(tx *provautil.Tx, utxoView *UtxoViewpoint, keyView *KeyViewpoint, flags txscript.ScriptFlags, sigCache *txscript.SigCache, hashCache *txscript.HashCache) error {
 
-	// If the hashcache doesn't yet has the sighash midstate for this
-	// transaction, then we'll compute them now so we can re-use them
-	// amongst all worker validation goroutines.
-	if !hashCache.ContainsHashes(tx.Hash()) {
-		hashCache.AddSigHashes(tx.MsgTx())
-	}
-
 	// The same pointer to the transaction's sighash midstate will be
 	// re-used amongst all validation goroutines. By pre-computing the
 	// sighash here instead of during validation, we ensure the sighashes
 	// are only computed once.
-	cachedHashes, _ := hashCache.GetSigHashes(tx.Hash())
+	cachedHashes := cachedSigHashes(tx, hashCache)
 
 	// Collect all of the transaction inputs and required information for
 	// validation.
@@ -301,22 +360,7 @@ func checkBlockScripts(block *provautil.Block, utxoView *UtxoViewpoint, keyView
 	}
 	txValItems := make([]*txValidateItem, 0, numInputs)
 	for _, tx := range block.Transactions() {
-		sha := tx.Hash()
-
-		// If the HashCache is present, and it doesn't yet contain the
-		// partial sighashes for this transaction, then we add the
-		// sighashes for the transaction. This allows us to take
-		// advantage of the potential speed savings due to the new
-		// digest algorithm (BIP0143).
-		var cachedHashes *txscript.TxSigHashes
-		if hashCache != nil {
-			if !hashCache.ContainsHashes(sha) {
-				hashCache.AddSigHashes(tx.MsgTx())
-			}
-			cachedHashes, _ = hashCache.GetSigHashes(sha)
-		} else {
-			cachedHashes = txscript.NewTxSigHashes(tx.MsgTx())
-		}
+		cachedHashes := cachedSigHashes(tx, hashCache)
 
 		for txInIdx, txIn := range tx.MsgTx().TxIn {
 			// Skip coinbases.
@@ -338,3 +382,63 @@ func checkBlockScripts(block *provautil.Block, utxoView *UtxoViewpoint, keyView
 	validator := newTxValidator(utxoView, keyView, scriptFlags, sigCache, hashCache)
 	return validator.Validate(txValItems)
 }
+
+// checkScriptConsistency validates the scripts for all transactions in the
+// passed block with the reference interpreter and compares the outcome with the
+// passed result of validating them with the script engine.  A
+// ScriptDivergenceError is returned when the engine accepted an input the
+// reference interpreter rejects, or rejected the block although the reference
+// interpreter accepts every input.  Inputs the reference interpreter does not
+// support are only validated by the engine.
+func checkScriptConsistency(block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, scriptFlags txscript.ScriptFlags, engineErr error) error {
+	allAccepted := true
+	for _, tx := range block.Transactions() {
+		for txInIdx, txIn := range tx.MsgTx().TxIn {
+			// Skip coinbases.
+			if txIn.PreviousOutPoint.Index == math.MaxUint32 {
+				continue
+			}
+
+			// Inputs which can't be looked up or resolved are
+			// rejected by the engine as well.
+			originTxIndex := txIn.PreviousOutPoint.Index
+			txEntry := utxoView.LookupEntry(&txIn.PreviousOutPoint.Hash)
+			if txEntry == nil || txEntry.PkScriptByIndex(originTxIndex) == nil {
+				allAccepted = false
+				continue
+			}
+			pkScript, err := ResolvePkScript(
+				txEntry.PkScriptByIndex(originTxIndex), keyView,
+				scriptFlags)
+			if err != nil {
+				allAccepted = false
+				continue
+			}
+
+			supported, err := txscript.ReferenceVerify(pkScript,
+				tx.MsgTx(), txInIdx, scriptFlags,
+				txEntry.AmountByIndex(originTxIndex))
+			if !supported {
+				allAccepted = false
+				continue
+			}
+			if err != nil {
+				if engineErr == nil {
+					str := fmt.Sprintf("engine accepted input "+
+						"%s:%d which the reference "+
+						"interpreter rejects: %v", tx.Hash(),
+						txInIdx, err)
+					return ScriptDivergenceError(str)
+				}
+				allAccepted = false
+			}
+		}
+	}
+
+	if engineErr != nil && allAccepted {
+		str := fmt.Sprintf("engine rejected block %v whose inputs the "+
+			"reference interpreter accepts: %v", block.Hash(), engineErr)
+		return ScriptDivergenceError(str)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+// UtxoSetStats summarizes the unspent transaction outputs of the main chain as
+// of its best block.
+type UtxoSetStats struct {
+	// Hash and Height identify the best block the statistics are as of.
+	Hash   chainhash.Hash
+	Height uint32
+
+	// Transactions is the number of transactions with unspent outputs, and
+	// Outputs is the number of unspent outputs.
+	Transactions uint64
+	Outputs      uint64
+
+	// TotalAmount is the total amount of the unspent outputs in atoms.
+	TotalAmount uint64
+
+	// TotalSupply is the supply of the chain as of the best block.
+	TotalSupply uint64
+
+	// SetHash commits to every unspent output.  It is the SHA-256 of the
+	// outputs ordered by the hash of their transaction and their index,
+	// each serialized as the transaction hash, the index as a little-endian
+	// uint32, the amount as a little-endian int64, and the public key
+	// script prefixed with its length as a varint.
+	SetHash chainhash.Hash
+}
+
+// FetchUtxoSetStats walks the whole utxo set to compute its statistics.  Since
+// the utxo set is committed to by the set hash, nodes with the same best block
+// compute the same statistics regardless of how their databases were built.
+// No blocks are connected while the utxo set is walked, which takes a while on
+// a large chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchUtxoSetStats() (*UtxoSetStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	stats := &UtxoSetStats{
+		Hash:        *b.bestNode.hash,
+		Height:      b.bestNode.height,
+		TotalSupply: b.TotalSupply(),
+	}
+	hasher := sha256.New()
+	var buf [12]byte
+	err := b.db.View(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		cursor := utxoBucket.Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			var txHash chainhash.Hash
+			copy(txHash[:], cursor.Key())
+			entry, err := deserializeUtxoEntry(cursor.Value())
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt utxo "+
+						"entry for %v: %v", txHash, err),
+				}
+			}
+
+			indexes := make([]int, 0, len(entry.sparseOutputs))
+			for index, output := range entry.sparseOutputs {
+				if !output.spent {
+					indexes = append(indexes, int(index))
+				}
+			}
+			if len(indexes) == 0 {
+				continue
+			}
+			sort.Ints(indexes)
+
+			stats.Transactions++
+			for _, index := range indexes {
+				output := entry.sparseOutputs[uint32(index)]
+				output.maybeDecompress(entry.version)
+				stats.Outputs++
+				stats.TotalAmount += uint64(output.amount)
+
+				hasher.Write(txHash[:])
+				binary.LittleEndian.PutUint32(buf[:4], uint32(index))
+				binary.LittleEndian.PutUint64(buf[4:],
+					uint64(output.amount))
+				hasher.Write(buf[:])
+				// Writing to a hash never fails.
+				_ = wire.WriteVarBytes(hasher, 0, output.pkScript)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	copy(stats.SetHash[:], hasher.Sum(nil))
+	return stats, nil
+}
+
+// UtxoSetOutput is an unspent output of the utxo set.
+type UtxoSetOutput struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	PkScript []byte
+}
+
+// FetchUtxoSetRange returns the unspent outputs of the transactions whose
+// hashes, as raw bytes, are at least start and less than end, ordered by the
+// hash of their transaction and their index like for the set hash.  A nil end
+// means there is no upper bound.  The hash and height of the best block the
+// outputs are as of are returned along with them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchUtxoSetRange(start, end []byte) ([]UtxoSetOutput, *chainhash.Hash, uint32, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var outputs []UtxoSetOutput
+	err := b.db.View(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		cursor := utxoBucket.Cursor()
+		for ok := cursor.Seek(start); ok; ok = cursor.Next() {
+			if end != nil && bytes.Compare(cursor.Key(), end) >= 0 {
+				break
+			}
+			var txHash chainhash.Hash
+			copy(txHash[:], cursor.Key())
+			entry, err := deserializeUtxoEntry(cursor.Value())
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt utxo "+
+						"entry for %v: %v", txHash, err),
+				}
+			}
+
+			indexes := make([]int, 0, len(entry.sparseOutputs))
+			for index, output := range entry.sparseOutputs {
+				if !output.spent {
+					indexes = append(indexes, int(index))
+				}
+			}
+			sort.Ints(indexes)
+			for _, index := range indexes {
+				output := entry.sparseOutputs[uint32(index)]
+				output.maybeDecompress(entry.version)
+				outputs = append(outputs, UtxoSetOutput{
+					OutPoint: wire.OutPoint{Hash: txHash,
+						Index: uint32(index)},
+					Amount:   output.amount,
+					PkScript: output.pkScript,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	hash := *b.bestNode.hash
+	return outputs, &hash, b.bestNode.height, nil
+}
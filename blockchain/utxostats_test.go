@@ -0,0 +1,140 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestFetchUtxoSetStats ensures the utxo set statistics are as of the best
+// block, and the set hash is deterministic and commits to every entry.
+func TestFetchUtxoSetStats(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	dbPath := filepath.Join(testDbRoot, "utxostats")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(testDbRoot)
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			block.SetHeight(accepted.Height)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("block %q was not accepted: %v",
+					accepted.Name, err)
+			}
+		}
+	}
+
+	stats, err := chain.FetchUtxoSetStats()
+	if err != nil {
+		t.Fatalf("FetchUtxoSetStats: unexpected error: %v", err)
+	}
+	best := chain.BestSnapshot()
+	if stats.Hash != *best.Hash || stats.Height != best.Height ||
+		stats.TotalSupply != chain.TotalSupply() {
+
+		t.Errorf("FetchUtxoSetStats: got block %v (%d) with supply %d, "+
+			"want %v (%d) with supply %d", stats.Hash, stats.Height,
+			stats.TotalSupply, best.Hash, best.Height,
+			chain.TotalSupply())
+	}
+	var numEntries uint64
+	var removedKey, removedValue []byte
+	err = db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket([]byte("utxoset"))
+		return bucket.ForEach(func(k, v []byte) error {
+			if removedKey == nil {
+				removedKey = append([]byte(nil), k...)
+				removedValue = append([]byte(nil), v...)
+			}
+			numEntries++
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to count the utxo entries: %v", err)
+	}
+	if stats.Transactions != numEntries ||
+		stats.Outputs < stats.Transactions {
+
+		t.Errorf("FetchUtxoSetStats: got %d transactions and %d "+
+			"outputs, want %d transactions", stats.Transactions,
+			stats.Outputs, numEntries)
+	}
+
+	again, err := chain.FetchUtxoSetStats()
+	if err != nil {
+		t.Fatalf("FetchUtxoSetStats: unexpected error: %v", err)
+	}
+	if *again != *stats {
+		t.Errorf("FetchUtxoSetStats: got %+v, then %+v", stats, again)
+	}
+
+	// Removing an entry changes the set hash, and restoring it restores
+	// the set hash.
+	setEntry := func(value []byte) {
+		err := db.Update(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket([]byte("utxoset"))
+			if value == nil {
+				return bucket.Delete(removedKey)
+			}
+			return bucket.Put(removedKey, value)
+		})
+		if err != nil {
+			t.Fatalf("failed to update the utxo set: %v", err)
+		}
+	}
+	setEntry(nil)
+	removed, err := chain.FetchUtxoSetStats()
+	if err != nil {
+		t.Fatalf("FetchUtxoSetStats: unexpected error: %v", err)
+	}
+	if removed.SetHash == stats.SetHash ||
+		removed.Transactions != stats.Transactions-1 {
+
+		t.Errorf("FetchUtxoSetStats: got %+v after removing an entry",
+			removed)
+	}
+	setEntry(removedValue)
+	restored, err := chain.FetchUtxoSetStats()
+	if err != nil {
+		t.Fatalf("FetchUtxoSetStats: unexpected error: %v", err)
+	}
+	if restored.SetHash != stats.SetHash {
+		t.Errorf("FetchUtxoSetStats: got set hash %v after restoring "+
+			"the entry, want %v", restored.SetHash, stats.SetHash)
+	}
+}
@@ -6,6 +6,7 @@
 package blockchain
 
 import (
+	"context"
 	"fmt"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
@@ -585,9 +586,23 @@ func NewUtxoViewpoint() *UtxoViewpoint {
 //
 // This function is safe for concurrent access however the returned view is NOT.
 func (b *BlockChain) FetchUtxoView(tx *provautil.Tx) (*UtxoViewpoint, error) {
+	return b.FetchUtxoViewContext(context.Background(), tx)
+}
+
+// FetchUtxoViewContext is identical to FetchUtxoView except it accepts a
+// context which allows the caller to abort the fetch.  The context is checked
+// once the chain state lock has been acquired, which allows callers to give up
+// waiting on a chain that is busy processing blocks.
+//
+// This function is safe for concurrent access however the returned view is NOT.
+func (b *BlockChain) FetchUtxoViewContext(ctx context.Context, tx *provautil.Tx) (*UtxoViewpoint, error) {
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
 	// Create a set of needed transactions based on those referenced by the
 	// inputs of the passed transaction.  Also, add the passed transaction
 	// itself as a way for the caller to detect duplicates that are not
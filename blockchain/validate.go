@@ -6,6 +6,7 @@
 package blockchain
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/big"
@@ -43,10 +44,6 @@ const (
 	// coinbases to start with the serialized block height.
 	serializedHeightVersion = 2
 
-	// baseSubsidy is the starting subsidy amount for mined blocks.  This
-	// value is halved every SubsidyHalvingInterval blocks.
-	baseSubsidy = 0 * provautil.AtomsPerGram
-
 	// MaxAdminKeySetSize sets a limit for the size of admin key sets.
 	// When admin transactions are validated, the pubKeyScript is generated
 	// from all active keys of that thread. The limit is needed to not exceed
@@ -110,6 +107,69 @@ func IsCoinBase(tx *provautil.Tx) bool {
 	return IsCoinBaseTx(tx.MsgTx())
 }
 
+// ExtractCoinbaseHeight returns the block height committed to by the passed
+// coinbase transaction.  Since signature scripts are not part of the
+// transaction hash in Prova, the height is committed to in the lock time field
+// of the coinbase instead of being serialized at the start of the coinbase
+// script as described by BIP0034.  This gives every coinbase a unique hash and
+// therefore closes the duplicate coinbase edge cases addressed by BIP0030.
+func ExtractCoinbaseHeight(coinbaseTx *provautil.Tx) uint32 {
+	return coinbaseTx.MsgTx().LockTime
+}
+
+// checkCoinbaseHeight ensures the block height committed to by the coinbase
+// transaction matches the provided block height.
+func checkCoinbaseHeight(coinbaseTx *provautil.Tx, wantHeight uint32) error {
+	coinbaseHeight := ExtractCoinbaseHeight(coinbaseTx)
+	if coinbaseHeight != wantHeight {
+		str := fmt.Sprintf("the coinbase commits to block height %d "+
+			"instead of the expected height %d", coinbaseHeight,
+			wantHeight)
+		return ruleError(ErrBadCoinbaseHeight, str)
+	}
+
+	return nil
+}
+
+// CheckTransactionTimeLocks ensures the passed transaction does not create any
+// timelocked Prova outputs in a block at the passed height unless the height
+// is at or after the activation height of OP_CHECKLOCKTIMEVERIFY.  Prior to
+// activation the lock times would not be enforced, so the outputs would be
+// spendable by anyone able to spend the underlying Prova script at any time.
+func CheckTransactionTimeLocks(tx *provautil.Tx, blockHeight uint32, chainParams *chaincfg.Params) error {
+	if blockHeight >= chainParams.CLTVActivationHeight {
+		return nil
+	}
+
+	for txOutIndex, txOut := range tx.MsgTx().TxOut {
+		scriptClass := txscript.GetScriptClass(txOut.PkScript)
+		if scriptClass == txscript.ProvaTimeLockTy {
+			str := fmt.Sprintf("transaction %v output %d is "+
+				"timelocked before activation height %d", tx.Hash(),
+				txOutIndex, chainParams.CLTVActivationHeight)
+			return ruleError(ErrPrematureTimeLock, str)
+		}
+	}
+	return nil
+}
+
+// CheckTransactionFreezes ensures the passed transaction does not freeze or
+// unfreeze any keyIDs in a block at the passed height unless the height is at
+// or after the activation height of keyID freezes.
+func CheckTransactionFreezes(tx *provautil.Tx, blockHeight uint32, chainParams *chaincfg.Params) error {
+	if blockHeight >= chainParams.FreezeActivationHeight {
+		return nil
+	}
+
+	if txscript.IsKeyIDFreezeTx(tx) {
+		str := fmt.Sprintf("transaction %v freezes keyIDs before "+
+			"activation height %d", tx.Hash(),
+			chainParams.FreezeActivationHeight)
+		return ruleError(ErrPrematureFreeze, str)
+	}
+	return nil
+}
+
 // SequenceLockActive determines if a transaction's sequence locks have been
 // met, meaning that all the inputs of a given transaction have reached a
 // height or time sufficient for their relative lock-time maturity.
@@ -162,23 +222,96 @@ func IsFinalizedTransaction(tx *provautil.Tx, blockHeight uint32, blockTime time
 	return true
 }
 
+// scheduledSubsidy returns the subsidy of a block at the provided height
+// according to the subsidy mode of the network, ignoring its supply cap.  The
+// genesis block pays no subsidy.
+func scheduledSubsidy(height uint32, chainParams *chaincfg.Params) int64 {
+	if height == 0 {
+		return 0
+	}
+
+	switch chainParams.SubsidyMode {
+	case chaincfg.FixedSubsidy:
+		return chainParams.BaseSubsidy
+
+	case chaincfg.DecayingSubsidy:
+		if chainParams.SubsidyReductionInterval == 0 {
+			return chainParams.BaseSubsidy
+		}
+		halvings := height / chainParams.SubsidyReductionInterval
+		if halvings >= 63 {
+			return 0
+		}
+
+		// Equivalent to: BaseSubsidy / 2^(height/SubsidyReductionInterval)
+		return chainParams.BaseSubsidy >> halvings
+	}
+	return 0
+}
+
+// calcSubsidySupply returns the total subsidy paid by the blocks below the
+// provided height according to the subsidy mode of the network, ignoring its
+// supply cap.  The result saturates at limit.
+func calcSubsidySupply(height uint32, chainParams *chaincfg.Params, limit int64) int64 {
+	if height <= 1 {
+		return 0
+	}
+
+	// The blocks from height 1 up to the provided height are grouped into
+	// runs paying the same subsidy, which is all of them for a fixed
+	// subsidy and the blocks between two reductions for a decaying one.
+	var supply int64
+	start := uint32(1)
+	for start < height {
+		end := height
+		if chainParams.SubsidyMode == chaincfg.DecayingSubsidy &&
+			chainParams.SubsidyReductionInterval != 0 {
+
+			interval := chainParams.SubsidyReductionInterval
+			nextReduction := uint64(start/interval+1) * uint64(interval)
+			if nextReduction < uint64(end) {
+				end = uint32(nextReduction)
+			}
+		}
+		subsidy := scheduledSubsidy(start, chainParams)
+		if subsidy == 0 {
+			break
+		}
+		count := int64(end - start)
+		if count > (limit-supply)/subsidy {
+			return limit
+		}
+		supply += count * subsidy
+		start = end
+	}
+	return supply
+}
+
 // CalcBlockSubsidy returns the subsidy amount a block at the provided height
 // should have. This is mainly used for determining how much the coinbase for
 // newly generated blocks awards as well as validating the coinbase for blocks
 // has the expected value.
 //
-// The subsidy is halved every SubsidyReductionInterval blocks.  Mathematically
-// this is: baseSubsidy / 2^(height/SubsidyReductionInterval)
+// The subsidy follows the schedule defined by the network parameters.  Blocks
+// pay no subsidy with NoSubsidy, BaseSubsidy with FixedSubsidy, and with
+// DecayingSubsidy the subsidy is halved every SubsidyReductionInterval blocks.
+// Mathematically this is: BaseSubsidy / 2^(height/SubsidyReductionInterval)
 //
-// At the target block generation rate for the main network, this is
-// approximately every 4 years.
+// When the network defines a MaxSubsidySupply, the subsidy of the block
+// reaching it is reduced so the total subsidy never exceeds it, and later
+// blocks pay no subsidy.
 func CalcBlockSubsidy(height uint32, chainParams *chaincfg.Params) int64 {
-	if chainParams.SubsidyReductionInterval == 0 {
-		return baseSubsidy
+	subsidy := scheduledSubsidy(height, chainParams)
+	if subsidy == 0 || chainParams.MaxSubsidySupply == 0 {
+		return subsidy
 	}
 
-	// Equivalent to: baseSubsidy / 2^(height/subsidyHalvingInterval)
-	return baseSubsidy >> uint(height/chainParams.SubsidyReductionInterval)
+	supply := calcSubsidySupply(height, chainParams,
+		chainParams.MaxSubsidySupply)
+	if remaining := chainParams.MaxSubsidySupply - supply; subsidy > remaining {
+		return remaining
+	}
+	return subsidy
 }
 
 // CheckTransactionSanity performs some preliminary checks on a transaction to
@@ -216,6 +349,9 @@ func CheckTransactionSanity(tx *provautil.Tx) error {
 	var totalAtoms int64
 	threadInt, adminOutputs := txscript.GetAdminDetails(tx)
 	hasAdminOut := (threadInt >= 0)
+	isFreeze := hasAdminOut &&
+		provautil.ThreadID(threadInt) == provautil.IssueThread &&
+		len(adminOutputs) > 0 && txscript.IsKeyIDFreezeOp(adminOutputs[0])
 	for txOutIndex, txOut := range msgTx.TxOut {
 		atoms := txOut.Value
 		if atoms < 0 {
@@ -266,6 +402,16 @@ func CheckTransactionSanity(tx *provautil.Tx) error {
 						"output #%d.", txOutIndex)
 					return ruleError(ErrInvalidAdminTx, str)
 				}
+			} else if isFreeze {
+				// If keyID freeze tx, all outputs but the thread
+				// must be 0 value freeze operations
+				if txOutIndex > 0 && (txOut.Value != 0 ||
+					!txscript.IsKeyIDFreezeOp(adminOutputs[txOutIndex-1])) {
+					str := fmt.Sprintf("admin freeze transaction %v "+
+						"with invalid freeze operation at output "+
+						"#%d.", tx.Hash(), txOutIndex)
+					return ruleError(ErrInvalidAdminTx, str)
+				}
 			} else {
 				// take care of issue thread
 				// If issuance/destruction tx, any non-nulldata outputs must be valid Prova scripts
@@ -362,6 +508,14 @@ func CheckTransactionSanity(tx *provautil.Tx) error {
 				}
 			}
 		}
+		if isFreeze {
+			// Freeze tx may not have any other inputs, as frozen
+			// funds are moved by destruction transactions.
+			if len(msgTx.TxIn) > 1 {
+				str := fmt.Sprintf("admin freeze transaction with more than 1 input.")
+				return ruleError(ErrInvalidAdminTx, str)
+			}
+		}
 	}
 
 	if !(threadInt >= 0) && !txscript.IsProvaTx(tx) {
@@ -547,6 +701,13 @@ func checkBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSou
 	return nil
 }
 
+// CheckBlockHeaderSanity performs some preliminary checks on a block header to
+// ensure it is sane before continuing with processing.  These checks are
+// context free, so light clients can apply them to headers alone.
+func CheckBlockHeaderSanity(header *wire.BlockHeader, powLimit *big.Int, timeSource MedianTimeSource) error {
+	return checkBlockHeaderSanity(header, powLimit, timeSource, BFNone)
+}
+
 // checkBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
 //
@@ -793,8 +954,9 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 // on its position within the block chain.
 //
 // The flags modify the behavior of this function as follows:
-//  - BFFastAdd: The transaction are not checked to see if they are finalized
-//    and the somewhat expensive BIP0034 validation is not performed.
+//  - BFFastAdd: The transaction are not checked to see if they are finalized,
+//    the height committed to by the coinbase is not checked and the somewhat
+//    expensive BIP0034 validation is not performed.
 //
 // The flags are also passed to checkBlockHeaderContext.  See its documentation
 // for how the flags modify its behavior.
@@ -819,6 +981,16 @@ func (b *BlockChain) checkBlockContext(block *provautil.Block, prevNode *blockNo
 		// previous block.
 		blockHeight := prevNode.height + 1
 
+		// Once activated, the coinbase must commit to the height of the
+		// block which contains it.
+		if blockHeight >= b.chainParams.CoinbaseActivationHeight {
+			coinbaseTx := block.Transactions()[0]
+			err := checkCoinbaseHeight(coinbaseTx, blockHeight)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Ensure all transactions in the block are finalized.
 		for _, tx := range block.Transactions() {
 			if !IsFinalizedTransaction(tx, blockHeight,
@@ -828,6 +1000,22 @@ func (b *BlockChain) checkBlockContext(block *provautil.Block, prevNode *blockNo
 					"transaction %v", tx.Hash())
 				return ruleError(ErrUnfinalizedTx, str)
 			}
+
+			// Ensure no timelocked outputs are created prior to
+			// the activation of CHECKLOCKTIMEVERIFY.
+			err := CheckTransactionTimeLocks(tx, blockHeight,
+				b.chainParams)
+			if err != nil {
+				return err
+			}
+
+			// Ensure no keyIDs are frozen prior to the activation
+			// of keyID freezes.
+			err = CheckTransactionFreezes(tx, blockHeight,
+				b.chainParams)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -857,9 +1045,18 @@ func (b *BlockChain) checkBIP0030(node *blockNode, block *provautil.Block, view
 	}
 
 	// Duplicate transactions are only allowed if the previous transaction
-	// is fully spent.
-	for _, tx := range block.Transactions() {
+	// is fully spent.  Once activated, coinbases commit to the block height,
+	// so a coinbase with a hash that is already known to the chain is never
+	// allowed.
+	coinbaseActive := node.height >= b.chainParams.CoinbaseActivationHeight
+	for i, tx := range block.Transactions() {
 		txEntry := view.LookupEntry(tx.Hash())
+		if i == 0 && txEntry != nil && coinbaseActive {
+			str := fmt.Sprintf("coinbase transaction %v duplicates "+
+				"the coinbase of block height %d", tx.Hash(),
+				txEntry.blockHeight)
+			return ruleError(ErrDuplicateCoinbase, str)
+		}
 		if txEntry != nil && !txEntry.IsFullySpent() {
 			str := fmt.Sprintf("tried to overwrite transaction %v "+
 				"at block height %d that is not fully spent",
@@ -1048,6 +1245,58 @@ func CheckTransactionInputs(tx *provautil.Tx, txHeight uint32, utxoView *UtxoVie
 	return txFeeInAtoms, nil
 }
 
+// CheckTransactionFrozenInputs ensures the passed transaction does not spend
+// any outputs co-signed by a keyID which is frozen in the passed key view,
+// unless it is an issue thread transaction.  Issue thread transactions, which
+// require the signatures of the issue keys, are the recovery path for frozen
+// funds: a destruction transaction may destroy them or move them to new
+// outputs.  The scripts of the frozen outputs still need to be satisfied.
+//
+// The keyIDs of generalized m-of-n Prova scripts with several pubkey hashes are
+// only recognized in blocks at or after the activation height of these scripts.
+//
+// NOTE: The transaction MUST have already been checked with the
+// CheckTransactionInputs function prior to calling this function.
+func CheckTransactionFrozenInputs(tx *provautil.Tx, blockHeight uint32, utxoView *UtxoViewpoint, keyView *KeyViewpoint, chainParams *chaincfg.Params) error {
+	if len(keyView.frozenKeyIDs) == 0 || IsCoinBase(tx) {
+		return nil
+	}
+	threadInt, _ := txscript.GetAdminDetails(tx)
+	if threadInt >= 0 && provautil.ThreadID(threadInt) == provautil.IssueThread {
+		return nil
+	}
+
+	extractKeyIDs := txscript.ExtractKeyIDs
+	if blockHeight >= chainParams.GeneralProvaActivationHeight {
+		extractKeyIDs = txscript.ExtractGeneralKeyIDs
+	}
+	for txInIndex, txIn := range tx.MsgTx().TxIn {
+		utxoEntry := utxoView.LookupEntry(&txIn.PreviousOutPoint.Hash)
+		if utxoEntry == nil {
+			continue
+		}
+		pkScript := utxoEntry.PkScriptByIndex(txIn.PreviousOutPoint.Index)
+		pops, err := txscript.ParseScript(pkScript)
+		if err != nil {
+			continue
+		}
+		// Only Prova scripts are co-signed by keyIDs.
+		keyIDs, err := extractKeyIDs(pops)
+		if err != nil {
+			continue
+		}
+		for _, keyID := range keyIDs {
+			if keyView.frozenKeyIDs[keyID] != nil {
+				str := fmt.Sprintf("transaction %v input %d spends "+
+					"output %v of frozen keyID %v.", tx.Hash(),
+					txInIndex, txIn.PreviousOutPoint, keyID)
+				return ruleError(ErrFrozenOutput, str)
+			}
+		}
+	}
+	return nil
+}
+
 // CheckProvaOutput checks that all keyIDs in the pkScript are known in
 // the chain state.
 //
@@ -1066,11 +1315,18 @@ func CheckProvaOutput(tx *provautil.Tx, txOutIndex int, keyIDs []btcec.KeyID,
 }
 
 // CheckTransactionOutputs performs a series of checks on the outputs to ensure
-// that they are valid in the context of the chain state.
+// that they are valid in the context of the chain state.  Outputs to
+// generalized m-of-n Prova scripts with several pubkey hashes may only be
+// created in blocks at or after the activation height of these scripts.
 //
 // NOTE: The transaction MUST have already been sanity checked with the
 // CheckTransactionSanity function prior to calling this function.
-func CheckTransactionOutputs(tx *provautil.Tx, keyView *KeyViewpoint) error {
+func CheckTransactionOutputs(tx *provautil.Tx, blockHeight uint32, keyView *KeyViewpoint, chainParams *chaincfg.Params) error {
+	extractKeyIDs := txscript.ExtractKeyIDs
+	if blockHeight >= chainParams.GeneralProvaActivationHeight {
+		extractKeyIDs = txscript.ExtractGeneralKeyIDs
+	}
+
 	threadInt, adminOutputs := txscript.GetAdminDetails(tx)
 	hasAdminOut := (threadInt >= 0)
 	if !hasAdminOut {
@@ -1094,7 +1350,7 @@ func CheckTransactionOutputs(tx *provautil.Tx, keyView *KeyViewpoint) error {
 				}
 				continue
 			}
-			keyIDs, err := txscript.ExtractKeyIDs(output)
+			keyIDs, err := extractKeyIDs(output)
 			if err != nil {
 				return ruleError(ErrInvalidTx, fmt.Sprintf("%v", err))
 			}
@@ -1106,10 +1362,50 @@ func CheckTransactionOutputs(tx *provautil.Tx, keyView *KeyViewpoint) error {
 		return nil
 	}
 	threadId := provautil.ThreadID(threadInt)
+	if threadId == provautil.IssueThread &&
+		len(adminOutputs) > 0 && txscript.IsKeyIDFreezeOp(adminOutputs[0]) {
+		// changedMap prevents 2 operations on the same keyID in one tx
+		changedMap := make(map[btcec.KeyID]bool)
+		for i := 0; i < len(adminOutputs); i++ {
+			isFreeze, pubKey,
+				keyID := txscript.ExtractKeyIDFreezeOpData(adminOutputs[i])
+			if changedMap[keyID] {
+				str := fmt.Sprintf("keyID %v is frozen or unfrozen "+
+					"more than once in transaction %v.", keyID,
+					tx.Hash())
+				return ruleError(ErrInvalidAdminOp, str)
+			}
+			changedMap[keyID] = true
+			frozenKey := keyView.frozenKeyIDs[keyID]
+			if isFreeze {
+				if frozenKey != nil {
+					str := fmt.Sprintf("keyID %v can not be frozen "+
+						"in transaction %v. It is frozen already.",
+						keyID, tx.Hash())
+					return ruleError(ErrInvalidAdminOp, str)
+				}
+				aspKey := keyView.aspKeyIdMap[keyID]
+				if aspKey == nil || !aspKey.IsEqual(pubKey) {
+					str := fmt.Sprintf("keyID %v can not be frozen "+
+						"in transaction %v. It does not match admin "+
+						"state.", keyID, tx.Hash())
+					return ruleError(ErrInvalidAdminOp, str)
+				}
+			} else {
+				if frozenKey == nil || !frozenKey.IsEqual(pubKey) {
+					str := fmt.Sprintf("keyID %v can not be unfrozen "+
+						"in transaction %v. It is not frozen with "+
+						"the given key.", keyID, tx.Hash())
+					return ruleError(ErrInvalidAdminOp, str)
+				}
+			}
+		}
+		return nil
+	}
 	if threadId == provautil.IssueThread {
 		for i, output := range adminOutputs {
 			if len(output) > 2 {
-				keyIDs, err := txscript.ExtractKeyIDs(output)
+				keyIDs, err := extractKeyIDs(output)
 				if err != nil {
 					return ruleError(ErrInvalidTx, fmt.Sprintf("%v", err))
 				}
@@ -1129,6 +1425,8 @@ func CheckTransactionOutputs(tx *provautil.Tx, keyView *KeyViewpoint) error {
 	// revokedMap is holding intra-tx state changes
 	// revokedMap prevents 2 operations on the same keyID in one tx
 	revokedMap := make(map[btcec.KeyID]bool)
+	// removedKeys is counting the keys removed from each admin key set
+	removedKeys := make(map[btcec.KeySetType]int)
 	for i := 0; i < len(adminOutputs); i++ {
 		isAddOp, keySetType, pubKey,
 			keyID := txscript.ExtractAdminOpData(adminOutputs[i])
@@ -1185,15 +1483,24 @@ func CheckTransactionOutputs(tx *provautil.Tx, keyView *KeyViewpoint) error {
 					return ruleError(ErrInvalidAdminOp, str)
 				}
 				// minLen describes the min amount of active admin keys
-				// to keep in a set. This seems only critical for root keys,
-				minLen := 0 // but root key set is fixed.
-				if keySetType == btcec.ValidateKeySet {
+				// to keep in a set. Sets controlling a thread have to keep
+				// enough keys to meet the signature threshold of the
+				// thread, also after several removals in this transaction.
+				minLen := 0
+				removed := 1
+				switch keySetType {
+				case btcec.RootKeySet, btcec.ProvisionKeySet, btcec.IssueKeySet:
+					removedKeys[keySetType]++
+					removed = removedKeys[keySetType]
+					minLen = keyView.AdminThreshold(keySetType)
+				case btcec.ValidateKeySet:
 					minLen = MinValidateKeySetSize
 				}
-				if len(keySet) <= minLen {
+				if len(keySet)-removed < minLen {
 					str := fmt.Sprintf("admin transaction %v tries to remove "+
-						"key from admin key set with length 2. At least 2 keys "+
-						"have to stay provisioned.", tx.Hash())
+						"key from %v key set with length %v. At least %v "+
+						"keys have to stay provisioned.", tx.Hash(),
+						keySetType, len(keySet), minLen)
 					return ruleError(ErrInvalidAdminOp, str)
 				}
 			}
@@ -1210,6 +1517,42 @@ func (b *BlockChain) IsValidateKeyRateLimited(validatePubKey wire.BlockValidatin
 	return b.isValidateKeyRateLimited(b.bestNode, validatePubKey, true)
 }
 
+// CheckHeaderSignature checks that the passed block header is signed by its
+// validating public key.  When the header extends the end of the current best
+// chain, the key must also be in the current validate key set.  This allows a
+// block announced by its header to be vetted before the block is requested.
+//
+// Note that a block may add the key which signs it to the validate key set,
+// so ErrInvalidValidateKey only means the block can not be vetted by its
+// header alone.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckHeaderSignature(header *wire.BlockHeader) error {
+	pubKey, err := btcec.ParsePubKey(header.ValidatingPubKey[:], btcec.S256())
+	if err != nil {
+		str := fmt.Sprintf("invalid validating public key %v: %v",
+			header.ValidatingPubKey, err)
+		return ruleError(ErrBadBlockSignature, str)
+	}
+	if !header.Verify(pubKey) {
+		return ruleError(ErrBadBlockSignature, "unable to validate block signature")
+	}
+
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	// The validate key set is only known for the end of the best chain.
+	if !header.PrevBlock.IsEqual(b.bestNode.hash) {
+		return nil
+	}
+	validateKeySet := b.adminKeySets[btcec.ValidateKeySet]
+	if len(validateKeySet) > 0 && validateKeySet.Pos(pubKey) == -1 {
+		str := fmt.Sprintf("invalid validate key %v", pubKey.SerializeCompressed())
+		return ruleError(ErrInvalidValidateKey, str)
+	}
+	return nil
+}
+
 // isValidateKeyRateLimited determines whether or not a rate limiting violation
 // is present with a given validate key.
 func (b *BlockChain) isValidateKeyRateLimited(node *blockNode, validatePubKey wire.BlockValidatingPubKey, prospectiveInclusion bool) (bool, error) {
@@ -1259,8 +1602,12 @@ func (b *BlockChain) isValidateKeyRateLimited(node *blockNode, validatePubKey wi
 // See the comments for CheckConnectBlock for some examples of the type of
 // checks performed by this function.
 //
+// The passed context is checked before the expensive script validation is
+// performed.  Callers that modify chain state based on the result must pass a
+// context which is never canceled.
+//
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, stxos *[]spentTxOut) error {
+func (b *BlockChain) checkConnectBlock(ctx context.Context, node *blockNode, block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, stxos *[]spentTxOut) error {
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -1356,6 +1703,14 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 			return err
 		}
 
+		// Ensure the transaction does not spend frozen outputs unless
+		// it is recovering them.
+		err = CheckTransactionFrozenInputs(tx, node.height, utxoView,
+			keyView, b.chainParams)
+		if err != nil {
+			return err
+		}
+
 		// Sum the total fees and ensure we don't overflow the
 		// accumulator.
 		lastTotalFees := totalFees
@@ -1366,7 +1721,8 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		}
 
 		// CheckTransactionOutputs checks outputs for state violations.
-		err = CheckTransactionOutputs(tx, keyView)
+		err = CheckTransactionOutputs(tx, node.height, keyView,
+			b.chainParams)
 		if err != nil {
 			return err
 		}
@@ -1433,11 +1789,12 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 	}
 
 	// Enforce DER signatures for block versions 3+ once the majority of the
-	// network has upgraded to the enforcement threshold.  This is part of
-	// BIP0066.
+	// network has upgraded to the enforcement threshold, or for all blocks
+	// when the deployment is forced active.  This is part of BIP0066.
 	blockHeader := &block.MsgBlock().Header
-	if blockHeader.Version >= 3 && b.isMajorityVersion(3, prevNode,
-		b.chainParams.BlockEnforceNumRequired) {
+	if b.isDeploymentForced(DeploymentStrictDER) ||
+		blockHeader.Version >= 3 && b.isMajorityVersion(3, prevNode,
+			b.chainParams.BlockEnforceNumRequired) {
 
 		scriptFlags |= txscript.ScriptVerifyDERSignatures
 	}
@@ -1454,15 +1811,30 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		return ruleError(ErrInvalidValidateKey, str)
 	}
 
-	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the majority
-	// of the network has upgraded to the enforcement threshold.  This is
-	// part of BIP0065.
-	if blockHeader.Version >= 4 && b.isMajorityVersion(4, prevNode,
-		b.chainParams.BlockEnforceNumRequired) {
+	// Enforce CHECKLOCKTIMEVERIFY for all blocks at or after the activation
+	// height, for block versions 4+ once the majority of the network has
+	// upgraded to the enforcement threshold, or for all blocks when the
+	// deployment is forced active.  This is part of BIP0065.
+	if node.height >= b.chainParams.CLTVActivationHeight ||
+		b.isDeploymentForced(DeploymentCheckLockTimeVerify) ||
+		blockHeader.Version >= 4 && b.isMajorityVersion(4, prevNode,
+			b.chainParams.BlockEnforceNumRequired) {
 
 		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
 	}
 
+	// Validate 64-byte signatures as Schnorr signatures for all blocks at or
+	// after the activation height.
+	if node.height >= b.chainParams.SchnorrActivationHeight {
+		scriptFlags |= txscript.ScriptVerifySchnorr
+	}
+
+	// Resolve the keyIDs of generalized m-of-n Prova scripts for all blocks
+	// at or after the activation height.
+	if node.height >= b.chainParams.GeneralProvaActivationHeight {
+		scriptFlags |= txscript.ScriptVerifyGeneralProva
+	}
+
 	// Check to see if there is a validate key rate limit breach.
 	isRateLimited, err := b.isValidateKeyRateLimited(node, blockHeader.ValidatingPubKey, false)
 	if err != nil {
@@ -1478,7 +1850,18 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 	// expensive ECDSA signature check scripts.  Doing this last helps
 	// prevent CPU exhaustion attacks.
 	if runScripts {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
 		err := checkBlockScripts(block, utxoView, keyView, scriptFlags, b.sigCache, b.hashCache)
+		if b.scriptConsistency {
+			divergeErr := checkScriptConsistency(block, utxoView,
+				keyView, scriptFlags, err)
+			if divergeErr != nil {
+				return divergeErr
+			}
+		}
 		if err != nil {
 			return err
 		}
@@ -1501,9 +1884,24 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) CheckConnectBlock(block *provautil.Block) error {
+	return b.CheckConnectBlockContext(context.Background(), block)
+}
+
+// CheckConnectBlockContext is identical to CheckConnectBlock except it accepts
+// a context which allows the caller to abort the checks.  The context is
+// checked once the chain state lock has been acquired and again before the
+// transaction scripts are validated.  Since the checks never modify the chain
+// state, it is always safe to abort them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckConnectBlockContext(ctx context.Context, block *provautil.Block) error {
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
 	prevNode := b.bestNode
 	newNode := newBlockNode(&block.MsgBlock().Header, block.Hash())
 	newNode.parent = prevNode
@@ -1525,6 +1923,8 @@ func (b *BlockChain) CheckConnectBlock(block *provautil.Block) error {
 	keyView.SetLastKeyID(b.lastKeyID)
 	keyView.SetTotalSupply(b.totalSupply)
 	keyView.SetKeys(b.adminKeySets)
+	keyView.SetAdminThresholds(b.chainParams.AdminThresholds)
 	keyView.SetKeyIDs(b.aspKeyIdMap)
-	return b.checkConnectBlock(newNode, block, utxoView, keyView, nil)
+	keyView.SetFrozenKeyIDs(b.frozenKeyIDs)
+	return b.checkConnectBlock(ctx, newNode, block, utxoView, keyView, nil)
 }
@@ -7,6 +7,7 @@ package blockchain_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/btcec"
@@ -15,6 +16,7 @@ import (
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
+	"math"
 	"testing"
 	"time"
 )
@@ -28,6 +30,56 @@ func TestCalcBlockSubsidy(t *testing.T) {
 		t.Errorf("TestCalcBlockSubsidy: inconsistent initial block "+
 			"subsidy %v", subsidy)
 	}
+
+	params := func(mode chaincfg.SubsidyMode, base int64, interval uint32,
+		maxSupply int64) *chaincfg.Params {
+
+		params := chaincfg.RegressionNetParams
+		params.SubsidyMode = mode
+		params.BaseSubsidy = base
+		params.SubsidyReductionInterval = interval
+		params.MaxSubsidySupply = maxSupply
+		return &params
+	}
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+		height uint32
+		want   int64
+	}{
+		{"no subsidy", params(chaincfg.NoSubsidy, 50, 10, 0), 5, 0},
+		{"fixed genesis", params(chaincfg.FixedSubsidy, 50, 0, 0), 0, 0},
+		{"fixed", params(chaincfg.FixedSubsidy, 50, 0, 0), 1000000, 50},
+		{"decaying first", params(chaincfg.DecayingSubsidy, 50, 10, 0), 9, 50},
+		{"decaying halved", params(chaincfg.DecayingSubsidy, 50, 10, 0), 10, 25},
+		{"decaying twice", params(chaincfg.DecayingSubsidy, 50, 10, 0), 25, 12},
+		{"decaying exhausted", params(chaincfg.DecayingSubsidy, 50, 10, 0), 1000, 0},
+		// Heights 1 to 3 pay 150 atoms, leaving 20 for height 4.
+		{"fixed below cap", params(chaincfg.FixedSubsidy, 50, 0, 170), 3, 50},
+		{"fixed reaching cap", params(chaincfg.FixedSubsidy, 50, 0, 170), 4, 20},
+		{"fixed past cap", params(chaincfg.FixedSubsidy, 50, 0, 170), 5, 0},
+		// Heights 1 to 9 pay 450 atoms and heights 10 to 19 pay 250.
+		{"decaying reaching cap", params(chaincfg.DecayingSubsidy, 50, 10, 710), 20, 10},
+		{"decaying past cap", params(chaincfg.DecayingSubsidy, 50, 10, 710), 21, 0},
+	}
+	for _, test := range tests {
+		got := blockchain.CalcBlockSubsidy(test.height, test.params)
+		if got != test.want {
+			t.Errorf("CalcBlockSubsidy (%s): got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+
+	// The total subsidy must reach the cap exactly.
+	capped := params(chaincfg.DecayingSubsidy, 1e8, 1000, 123456789012)
+	var supply int64
+	for height := uint32(1); height < 100000; height++ {
+		supply += blockchain.CalcBlockSubsidy(height, capped)
+	}
+	if supply != capped.MaxSubsidySupply {
+		t.Errorf("CalcBlockSubsidy: total subsidy %d, want %d", supply,
+			capped.MaxSubsidySupply)
+	}
 }
 
 // TestSequenceLocksActive tests the SequenceLockActive function to ensure it
@@ -97,6 +149,110 @@ func TestCheckConnectBlock(t *testing.T) {
 	}
 }
 
+// TestCheckHeaderSignature ensures block headers are checked for a valid
+// signature by a key of the current validate key set.
+func TestCheckHeaderSignature(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("checkheadersignature",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Errorf("Failed to setup chain instance: %v", err)
+		return
+	}
+	defer teardownFunc()
+
+	// Sign a header extending the genesis block with a key which is not
+	// in the validate key set.
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	header := wire.NewBlockHeader(genesisHash, &chainhash.Hash{}, 0, 0)
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	if err := header.Sign(privKey); err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		prevBlock chainhash.Hash
+		corrupt   bool
+		code      blockchain.ErrorCode
+		valid     bool
+	}{
+		{"unknown validate key", *genesisHash, false,
+			blockchain.ErrInvalidValidateKey, false},
+		{"not extending best chain", chainhash.Hash{0x01}, false, 0,
+			true},
+		{"bad signature", chainhash.Hash{0x01}, true,
+			blockchain.ErrBadBlockSignature, false},
+	}
+
+	for _, test := range tests {
+		h := *header
+		if test.prevBlock != *genesisHash {
+			// Changing the header invalidates the signature.
+			h.PrevBlock = test.prevBlock
+			if err := h.Sign(privKey); err != nil {
+				t.Fatalf("Sign: unexpected error: %v", err)
+			}
+		}
+		if test.corrupt {
+			h.MerkleRoot[0] ^= 0xff
+		}
+
+		err := chain.CheckHeaderSignature(&h)
+		if test.valid {
+			if err != nil {
+				t.Errorf("CheckHeaderSignature (%s): unexpected "+
+					"error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Errorf("CheckHeaderSignature (%s): unexpected error "+
+				"type - got %T", test.name, err)
+			continue
+		}
+		if rerr.ErrorCode != test.code {
+			t.Errorf("CheckHeaderSignature (%s): unexpected error "+
+				"code - got %v, want %v", test.name,
+				rerr.ErrorCode, test.code)
+		}
+	}
+}
+
+// TestContextCanceled ensures the context-aware variants of the public API
+// return the context error when invoked with a context that is already done.
+func TestContextCanceled(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("contextcanceled",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Errorf("Failed to setup chain instance: %v", err)
+		return
+	}
+	defer teardownFunc()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	block := provautil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	_, _, err = chain.ProcessBlockContext(ctx, block, blockchain.BFNone)
+	if err != context.Canceled {
+		t.Errorf("ProcessBlockContext: unexpected error - got %v, "+
+			"want %v", err, context.Canceled)
+	}
+
+	err = chain.CheckConnectBlockContext(ctx, block)
+	if err != context.Canceled {
+		t.Errorf("CheckConnectBlockContext: unexpected error - got "+
+			"%v, want %v", err, context.Canceled)
+	}
+
+	_, err = chain.FetchUtxoViewContext(ctx, block.Transactions()[0])
+	if err != context.Canceled {
+		t.Errorf("FetchUtxoViewContext: unexpected error - got %v, "+
+			"want %v", err, context.Canceled)
+	}
+}
+
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
@@ -549,6 +705,25 @@ func TestCheckTransactionOutputs(t *testing.T) {
 		Value:    0, // 0 RMG
 		PkScript: adminOpAspRevPkScript,
 	}
+	// Create admin op to freeze keyID.
+	data = make([]byte, 1+btcec.PubKeyBytesLenCompressed+btcec.KeyIDSize)
+	data[0] = txscript.AdminOpKeyIDFreeze
+	copy(data[1:], pubKey.SerializeCompressed())
+	keyID.ToAddressFormat(data[1+btcec.PubKeyBytesLenCompressed:])
+	adminOpFreezePkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+	adminOpFreezeTxOut := wire.TxOut{
+		Value:    0,
+		PkScript: adminOpFreezePkScript,
+	}
+	// Create admin op to unfreeze keyID.
+	data[0] = txscript.AdminOpKeyIDUnfreeze
+	adminOpUnfreezePkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+	adminOpUnfreezeTxOut := wire.TxOut{
+		Value:    0,
+		PkScript: adminOpUnfreezePkScript,
+	}
 	// create root tx out
 	rootPkScript, _ := txscript.ProvaThreadScript(provautil.RootThread)
 	rootTxOut := wire.TxOut{
@@ -563,14 +738,16 @@ func TestCheckTransactionOutputs(t *testing.T) {
 	}
 
 	tests := []struct {
-		name         string
-		tx           wire.MsgTx
-		lastKeyID    btcec.KeyID
-		adminKeySets map[btcec.KeySetType]btcec.PublicKeySet
-		aspKeyIdMap  btcec.KeyIdMap
-		isCoinbase   bool
-		isValid      bool
-		code         blockchain.ErrorCode
+		name            string
+		tx              wire.MsgTx
+		lastKeyID       btcec.KeyID
+		adminKeySets    map[btcec.KeySetType]btcec.PublicKeySet
+		adminThresholds map[btcec.KeySetType]int
+		aspKeyIdMap     btcec.KeyIdMap
+		frozenKeyIDs    btcec.KeyIdMap
+		isCoinbase      bool
+		isValid         bool
+		code            blockchain.ErrorCode
 	}{
 		{
 			name: "Spend to regular Prova output.",
@@ -627,8 +804,51 @@ func TestCheckTransactionOutputs(t *testing.T) {
 				)
 				return keySets
 			}(),
+			isValid: false,
+			code:    blockchain.ErrInvalidAdminOp,
+		},
+		{
+			name: "Revoking key from 2-of-3 provision set.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&rootTxOut, &adminOpRevokeTxOut},
+				LockTime: 0,
+			},
+			adminKeySets: func() map[btcec.KeySetType]btcec.PublicKeySet {
+				keySets := make(map[btcec.KeySetType]btcec.PublicKeySet)
+				keySets[btcec.ProvisionKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+					"038364914c537fc6c6a675166aea88abf7a2c83b0955b2e6b0611dacfad6242288",
+					"0353cc1a8e6fcb764349bce68a56a285316bcea950a6f667fee4c95d5ad2f72815",
+					"038ef4a121bcaf1b1f175557a12896f8bc93b095e84817f90e9a901cd2113a8202",
+				)
+				return keySets
+			}(),
 			isValid: true,
 		},
+		{
+			name: "Revoking key from 3-of-3 provision set.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&rootTxOut, &adminOpRevokeTxOut},
+				LockTime: 0,
+			},
+			adminKeySets: func() map[btcec.KeySetType]btcec.PublicKeySet {
+				keySets := make(map[btcec.KeySetType]btcec.PublicKeySet)
+				keySets[btcec.ProvisionKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+					"038364914c537fc6c6a675166aea88abf7a2c83b0955b2e6b0611dacfad6242288",
+					"0353cc1a8e6fcb764349bce68a56a285316bcea950a6f667fee4c95d5ad2f72815",
+					"038ef4a121bcaf1b1f175557a12896f8bc93b095e84817f90e9a901cd2113a8202",
+				)
+				return keySets
+			}(),
+			adminThresholds: map[btcec.KeySetType]int{
+				btcec.ProvisionKeySet: 3,
+			},
+			isValid: false,
+			code:    blockchain.ErrInvalidAdminOp,
+		},
 		{
 			name: "Adding existing key to set.",
 			tx: wire.MsgTx{
@@ -813,6 +1033,78 @@ func TestCheckTransactionOutputs(t *testing.T) {
 			isValid: false,
 			code:    blockchain.ErrInvalidAdminOp,
 		},
+		{
+			name: "Freeze an active keyID.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&issueTxOut, &adminOpFreezeTxOut},
+				LockTime: 0,
+			},
+			aspKeyIdMap: map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			isValid:     true,
+		},
+		{
+			name: "Freeze a frozen keyID.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&issueTxOut, &adminOpFreezeTxOut},
+				LockTime: 0,
+			},
+			aspKeyIdMap:  map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			frozenKeyIDs: map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			isValid:      false,
+			code:         blockchain.ErrInvalidAdminOp,
+		},
+		{
+			name: "Freeze unknown keyID.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&issueTxOut, &adminOpFreezeTxOut},
+				LockTime: 0,
+			},
+			isValid: false,
+			code:    blockchain.ErrInvalidAdminOp,
+		},
+		{
+			name: "Freeze and unfreeze keyID in same tx.",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{&issueTxOut, &adminOpFreezeTxOut,
+					&adminOpUnfreezeTxOut},
+				LockTime: 0,
+			},
+			aspKeyIdMap: map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			isValid:     false,
+			code:        blockchain.ErrInvalidAdminOp,
+		},
+		{
+			name: "Unfreeze a frozen keyID.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&issueTxOut, &adminOpUnfreezeTxOut},
+				LockTime: 0,
+			},
+			aspKeyIdMap:  map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			frozenKeyIDs: map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			isValid:      true,
+		},
+		{
+			name: "Unfreeze a keyID which is not frozen.",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&issueTxOut, &adminOpUnfreezeTxOut},
+				LockTime: 0,
+			},
+			aspKeyIdMap: map[btcec.KeyID]*btcec.PublicKey{keyID: pubKey},
+			isValid:     false,
+			code:        blockchain.ErrInvalidAdminOp,
+		},
 		{
 			name: "Issue to prova output with unknown keyID.",
 			tx: wire.MsgTx{
@@ -856,13 +1148,16 @@ func TestCheckTransactionOutputs(t *testing.T) {
 	for _, test := range tests {
 		keyView := blockchain.NewKeyViewpoint()
 		keyView.SetKeys(test.adminKeySets)
+		keyView.SetAdminThresholds(test.adminThresholds)
 		keyView.SetLastKeyID(test.lastKeyID)
 		keyView.SetKeyIDs(test.aspKeyIdMap)
+		keyView.SetFrozenKeyIDs(test.frozenKeyIDs)
 		tx := provautil.NewTx(&test.tx)
 		if test.isCoinbase {
 			tx.SetIndex(0)
 		}
-		err := blockchain.CheckTransactionOutputs(tx, keyView)
+		err := blockchain.CheckTransactionOutputs(tx, 1, keyView,
+			&chaincfg.RegressionNetParams)
 		if err == nil && test.isValid {
 			// Test passes since function returned valid for a
 			// transaction which is intended to be valid.
@@ -896,6 +1191,223 @@ func TestCheckTransactionOutputs(t *testing.T) {
 	}
 }
 
+// TestCheckTransactionTimeLocks ensures timelocked outputs are only allowed at
+// or after the activation height of OP_CHECKLOCKTIMEVERIFY.
+func TestCheckTransactionTimeLocks(t *testing.T) {
+	payAddr, err := provautil.NewAddressProva(make([]byte, 20),
+		[]btcec.KeyID{1, 2}, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	provaPkScript, err := txscript.PayToAddrScript(payAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	timeLockPkScript, err := txscript.PayToAddrTimeLockScript(payAddr,
+		1000)
+	if err != nil {
+		t.Fatalf("PayToAddrTimeLockScript: unexpected error: %v", err)
+	}
+
+	params := chaincfg.RegressionNetParams
+	params.CLTVActivationHeight = 100
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		height   uint32
+		isErr    bool
+	}{
+		{"prova output before activation", provaPkScript, 99, false},
+		{"timelocked output before activation", timeLockPkScript, 99, true},
+		{"timelocked output at activation", timeLockPkScript, 100, false},
+		{"timelocked output after activation", timeLockPkScript, 101, false},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxOut(wire.NewTxOut(1000, provaPkScript))
+		tx.AddTxOut(wire.NewTxOut(1000, test.pkScript))
+		err := blockchain.CheckTransactionTimeLocks(provautil.NewTx(tx),
+			test.height, &params)
+		if !test.isErr {
+			if err != nil {
+				t.Errorf("CheckTransactionTimeLocks (%s): "+
+					"unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Errorf("CheckTransactionTimeLocks (%s): unexpected "+
+				"error type - got %T (%v)", test.name, err, err)
+			continue
+		}
+		if rerr.ErrorCode != blockchain.ErrPrematureTimeLock {
+			t.Errorf("CheckTransactionTimeLocks (%s): unexpected "+
+				"error code - got %v, want %v", test.name,
+				rerr.ErrorCode, blockchain.ErrPrematureTimeLock)
+		}
+	}
+}
+
+// TestGeneralProvaActivation ensures outputs to generalized m-of-n Prova
+// scripts keep their previous verdict in blocks before the activation height
+// of these scripts, and are only resolved once the script flag is set.
+func TestGeneralProvaActivation(t *testing.T) {
+	_, pubKey := btcec.PrivKeyFromBytes(btcec.S256(), []byte{
+		0x2b, 0x8c, 0x52, 0xb7, 0x7b, 0x32, 0x7c, 0x75,
+		0x5b, 0x9b, 0x37, 0x55, 0x00, 0xd3, 0xf4, 0xb2,
+		0xda, 0x9b, 0x0a, 0x1f, 0xf6, 0x5f, 0x68, 0x91,
+		0xd3, 0x11, 0xfe, 0x94, 0x29, 0x5b, 0xc2, 0x6a,
+	})
+	keyIDs := []btcec.KeyID{1, 2, 3}
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(btcec.KeyIdMap{1: pubKey, 2: pubKey, 3: pubKey})
+
+	payAddr, err := provautil.NewAddressGeneralProva(3,
+		[][]byte{make([]byte, 20), bytes.Repeat([]byte{0x01}, 20)},
+		keyIDs, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressGeneralProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(payAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	params := chaincfg.RegressionNetParams
+	params.GeneralProvaActivationHeight = 100
+
+	tests := []struct {
+		name   string
+		height uint32
+		isErr  bool
+	}{
+		{"general output before activation", 99, true},
+		{"general output at activation", 100, false},
+		{"general output after activation", 101, false},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		err := blockchain.CheckTransactionOutputs(provautil.NewTx(tx),
+			test.height, keyView, &params)
+		if !test.isErr {
+			if err != nil {
+				t.Errorf("CheckTransactionOutputs (%s): "+
+					"unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Errorf("CheckTransactionOutputs (%s): unexpected "+
+				"error type - got %T (%v)", test.name, err, err)
+			continue
+		}
+		if rerr.ErrorCode != blockchain.ErrInvalidTx {
+			t.Errorf("CheckTransactionOutputs (%s): unexpected "+
+				"error code - got %v, want %v", test.name,
+				rerr.ErrorCode, blockchain.ErrInvalidTx)
+		}
+	}
+
+	// Before activation the keyIDs of the script are left unresolved.
+	resolved, err := blockchain.ResolvePkScript(pkScript, keyView,
+		txscript.StandardVerifyFlags)
+	if err != nil {
+		t.Fatalf("ResolvePkScript: unexpected error: %v", err)
+	}
+	if !bytes.Equal(resolved, pkScript) {
+		t.Errorf("ResolvePkScript: script resolved before activation")
+	}
+
+	// Once active, every keyID is replaced by the hash of its key.
+	resolved, err = blockchain.ResolvePkScript(pkScript, keyView,
+		txscript.StandardVerifyFlags|txscript.ScriptVerifyGeneralProva)
+	if err != nil {
+		t.Fatalf("ResolvePkScript: unexpected error: %v", err)
+	}
+	keyHash := provautil.Hash160(pubKey.SerializeCompressed())
+	if bytes.Equal(resolved, pkScript) ||
+		bytes.Count(resolved, keyHash) != len(keyIDs) {
+
+		t.Errorf("ResolvePkScript: keyIDs %v left unresolved", keyIDs)
+	}
+}
+
+// TestCheckDuplicateCoinbase ensures a coinbase which duplicates a coinbase
+// already known to the chain is rejected once coinbase height commitments are
+// active, and only when not fully spent before.
+func TestCheckDuplicateCoinbase(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.CoinbaseActivationHeight = 100
+	chain, teardownFunc, err := chainSetup("checkduplicatecoinbase",
+		&params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	coinbaseTx := wire.NewMsgTx(1)
+	coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		math.MaxUint32), nil))
+	coinbaseTx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	coinbaseTx.LockTime = 50
+	coinbaseHash := coinbaseTx.TxHash()
+
+	tests := []struct {
+		name    string
+		height  uint32
+		spent   bool
+		errCode blockchain.ErrorCode
+		isErr   bool
+	}{
+		{"unspent before activation", 99, false,
+			blockchain.ErrOverwriteTx, true},
+		{"spent before activation", 99, true, 0, false},
+		{"unspent at activation", 100, false,
+			blockchain.ErrDuplicateCoinbase, true},
+		{"spent after activation", 101, true,
+			blockchain.ErrDuplicateCoinbase, true},
+	}
+
+	for _, test := range tests {
+		view := blockchain.NewUtxoViewpoint()
+		view.AddTxOuts(provautil.NewTx(coinbaseTx), 50)
+		if test.spent {
+			view.LookupEntry(&coinbaseHash).SpendOutput(0)
+		}
+		var msgBlock wire.MsgBlock
+		msgBlock.Header.Height = test.height
+		msgBlock.AddTransaction(coinbaseTx)
+		err := chain.TstCheckBIP0030(provautil.NewBlock(&msgBlock), view)
+		if !test.isErr {
+			if err != nil {
+				t.Errorf("checkBIP0030 (%s): unexpected error: %v",
+					test.name, err)
+			}
+			continue
+		}
+
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Errorf("checkBIP0030 (%s): unexpected error type - "+
+				"got %T (%v)", test.name, err, err)
+			continue
+		}
+		if rerr.ErrorCode != test.errCode {
+			t.Errorf("checkBIP0030 (%s): unexpected error code - "+
+				"got %v, want %v", test.name, rerr.ErrorCode,
+				test.errCode)
+		}
+	}
+}
+
 // TestCheckTransactionInputs tests the CheckTransactionInputs API.
 func TestCheckTransactionInputs(t *testing.T) {
 	// Create some dummy, but otherwise standard, data for transactions.
@@ -1062,3 +1574,112 @@ func TestCheckTransactionInputs(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckTransactionFrozenInputs ensures outputs co-signed by a frozen keyID
+// may only be spent by issue thread transactions.
+func TestCheckTransactionFrozenInputs(t *testing.T) {
+	_, pubKey := btcec.PrivKeyFromBytes(btcec.S256(), []byte{
+		0x2b, 0x8c, 0x52, 0xb7, 0x7b, 0x32, 0x7c, 0x75,
+		0x5b, 0x9b, 0x37, 0x55, 0x00, 0xd3, 0xf4, 0xb2,
+		0xda, 0x9b, 0x0a, 0x1f, 0xf6, 0x5f, 0x68, 0x91,
+		0xd3, 0x11, 0xfe, 0x94, 0x29, 0x5b, 0xc2, 0x6a,
+	})
+	payAddr, err := provautil.NewAddressProva(make([]byte, 20),
+		[]btcec.KeyID{1, 2}, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	provaPkScript, err := txscript.PayToAddrScript(payAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	prevTx := provautil.NewTx(&wire.MsgTx{
+		Version:  1,
+		TxOut:    []*wire.TxOut{{Value: 400000000, PkScript: provaPkScript}},
+		LockTime: 0,
+	})
+	utxoView := blockchain.NewUtxoViewpoint()
+	utxoView.AddTxOuts(prevTx, 100)
+	txIn := wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *prevTx.Hash(), Index: 0},
+		Sequence:         wire.MaxTxInSequenceNum,
+	}
+	issuePkScript, _ := txscript.ProvaThreadScript(provautil.IssueThread)
+
+	tests := []struct {
+		name         string
+		tx           wire.MsgTx
+		frozenKeyIDs btcec.KeyIdMap
+		isValid      bool
+	}{
+		{
+			name: "spend output without frozen keyIDs",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&txIn},
+				TxOut:    []*wire.TxOut{{Value: 300000000, PkScript: provaPkScript}},
+				LockTime: 0,
+			},
+			frozenKeyIDs: map[btcec.KeyID]*btcec.PublicKey{3: pubKey},
+			isValid:      true,
+		},
+		{
+			name: "spend output of frozen keyID",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&txIn},
+				TxOut:    []*wire.TxOut{{Value: 300000000, PkScript: provaPkScript}},
+				LockTime: 0,
+			},
+			frozenKeyIDs: map[btcec.KeyID]*btcec.PublicKey{2: pubKey},
+			isValid:      false,
+		},
+		{
+			name: "destroy output of frozen keyID",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&txIn},
+				TxOut: []*wire.TxOut{{Value: 0, PkScript: issuePkScript}, {
+					Value:    400000000,
+					PkScript: []byte{txscript.OP_RETURN},
+				}},
+				LockTime: 0,
+			},
+			frozenKeyIDs: map[btcec.KeyID]*btcec.PublicKey{2: pubKey},
+			isValid:      true,
+		},
+	}
+
+	for _, test := range tests {
+		keyView := blockchain.NewKeyViewpoint()
+		keyView.SetFrozenKeyIDs(test.frozenKeyIDs)
+		err := blockchain.CheckTransactionFrozenInputs(
+			provautil.NewTx(&test.tx), 1, utxoView, keyView,
+			&chaincfg.RegressionNetParams)
+		if err == nil && test.isValid {
+			continue
+		}
+		if err == nil && !test.isValid {
+			t.Errorf("CheckTransactionFrozenInputs (%s): valid when "+
+				"it should not be", test.name)
+			continue
+		}
+		if err != nil && test.isValid {
+			t.Errorf("CheckTransactionFrozenInputs (%s): invalid "+
+				"when it should not be: %v", test.name, err)
+			continue
+		}
+
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Errorf("CheckTransactionFrozenInputs (%s): unexpected "+
+				"error type - got %T (%v)", test.name, err, err)
+			continue
+		}
+		if rerr.ErrorCode != blockchain.ErrFrozenOutput {
+			t.Errorf("CheckTransactionFrozenInputs (%s): unexpected "+
+				"error code - got %v, want %v", test.name,
+				rerr.ErrorCode, blockchain.ErrFrozenOutput)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+// MaxValidateKeyStatsWindow is the largest window of recent blocks the number
+// of blocks signed by each validate key is reported for by ValidateKeyStats.
+const MaxValidateKeyStatsWindow = 10000
+
+// signedBlock is the height of a block along with the validate key which
+// signed it.
+type signedBlock struct {
+	height uint32
+	pubKey wire.BlockValidatingPubKey
+}
+
+// recentSignedBlocks returns up to the passed number of blocks at the end of
+// the main chain, most recent first.  Unlike getPrevNodeFromNode, the blocks
+// which are not in memory are read from the database without being added to
+// the block index, so it only needs the chain state lock held for reads.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) recentSignedBlocks(count int) ([]signedBlock, error) {
+	var recent []signedBlock
+	node := b.bestNode
+	for ; node != nil && len(recent) < count; node = node.parent {
+		recent = append(recent, signedBlock{node.height,
+			node.validatingPubKey})
+	}
+	if len(recent) == count || len(recent) == 0 {
+		return recent, nil
+	}
+
+	height := recent[len(recent)-1].height
+	err := b.db.View(func(dbTx database.Tx) error {
+		for height > 0 && len(recent) < count {
+			height--
+			header, err := dbFetchHeaderByHeight(dbTx, height)
+			if err != nil {
+				return err
+			}
+			recent = append(recent, signedBlock{height,
+				header.ValidatingPubKey})
+		}
+		return nil
+	})
+	return recent, err
+}
+
+// ValidateKeyStats houses block generation statistics for a single validate
+// key from the point of view of the end of the main chain.
+type ValidateKeyStats struct {
+	// PubKey is the validate key the statistics apply to.
+	PubKey wire.BlockValidatingPubKey
+
+	// Active indicates whether the key is part of the current validate
+	// key set.  Keys which have been revoked are still reported while
+	// they signed blocks within the scanned range.
+	Active bool
+
+	// Seen indicates whether the key signed any block within the scanned
+	// range.  LastSeenHeight is only meaningful when it is set.
+	Seen bool
+
+	// LastSeenHeight is the height of the most recent block signed by the
+	// key within the scanned range.
+	LastSeenHeight uint32
+
+	// WindowCounts holds the number of blocks signed by the key within each
+	// of the requested windows, in the order the windows were requested.
+	WindowCounts []uint32
+
+	// TrailingCount is the number of consecutive blocks at the end of the
+	// main chain signed by the key.
+	TrailingCount uint32
+
+	// TrailingHeadroom is the number of additional consecutive blocks the
+	// key may sign before it violates the trailing rate limit.  It is
+	// math.MaxUint32 when the limit is disabled.
+	TrailingHeadroom uint32
+
+	// ShareHeadroom is the number of additional blocks the key may sign
+	// within the current rate limiting window before it violates the share
+	// rate limit.  It is math.MaxUint32 when the limit is disabled.
+	ShareHeadroom uint32
+
+	// RateLimited indicates whether a block signed by the key extending the
+	// end of the main chain would violate the rate limiting rules.
+	RateLimited bool
+}
+
+// headroom returns the number of additional blocks which may be signed before
+// the passed limit is reached given the current count.  A limit of zero
+// indicates the limit is disabled.
+func headroom(limit, count int) uint32 {
+	if limit <= 0 {
+		return math.MaxUint32
+	}
+	if count >= limit {
+		return 0
+	}
+	return uint32(limit - count)
+}
+
+// ValidateKeyStats returns block generation statistics for all keys in the
+// current validate key set as well as any other key which signed a block
+// within the scanned range.  The scanned range covers the largest of the
+// passed windows and the window used for validate key rate limiting, and the
+// number of blocks signed within each passed window is reported per key.
+// Windows are limited to MaxValidateKeyStatsWindow blocks.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ValidateKeyStats(windows []uint32) ([]ValidateKeyStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	rateWindow := b.chainParams.PowAveragingWindow
+	depth := rateWindow
+	for _, window := range windows {
+		if int(window) > depth {
+			depth = int(window)
+		}
+	}
+	if depth > MaxValidateKeyStatsWindow {
+		depth = MaxValidateKeyStatsWindow
+	}
+
+	// Collect the validate keys of the most recent blocks starting with
+	// the end of the main chain.
+	recent, err := b.recentSignedBlocks(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start with the active validate keys so they are reported even when
+	// they have not signed any of the recent blocks.
+	var stats []ValidateKeyStats
+	statsIdx := make(map[wire.BlockValidatingPubKey]int)
+	addKey := func(pubKey wire.BlockValidatingPubKey) *ValidateKeyStats {
+		if i, ok := statsIdx[pubKey]; ok {
+			return &stats[i]
+		}
+		statsIdx[pubKey] = len(stats)
+		stats = append(stats, ValidateKeyStats{
+			PubKey:       pubKey,
+			WindowCounts: make([]uint32, len(windows)),
+		})
+		return &stats[len(stats)-1]
+	}
+	for _, key := range b.adminKeySets[btcec.ValidateKeySet] {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], key.SerializeCompressed())
+		addKey(pubKey).Active = true
+	}
+
+	trailing := true
+	for i, block := range recent {
+		keyStats := addKey(block.pubKey)
+		if !keyStats.Seen {
+			keyStats.Seen = true
+			keyStats.LastSeenHeight = block.height
+		}
+		for j, window := range windows {
+			if i < int(window) {
+				keyStats.WindowCounts[j]++
+			}
+		}
+
+		// Track the run of blocks at the end of the chain signed by
+		// the same key.
+		if trailing && block.pubKey == recent[0].pubKey {
+			keyStats.TrailingCount++
+		} else {
+			trailing = false
+		}
+	}
+
+	// Calculate the remaining headroom for each key according to the rate
+	// limiting rules, which are evaluated against the blocks in the rate
+	// limiting window preceding a new block.
+	shareWindow := recent
+	if len(shareWindow) > rateWindow {
+		shareWindow = shareWindow[:rateWindow]
+	}
+	maxShare := len(shareWindow) * b.chainParams.ChainWindowShareLimit / 100
+	maxTrailing := b.chainParams.ChainTrailingSigKeyLimit
+
+	// A block signed by a key extending the end of the main chain is rate
+	// limited like isValidateKeyRateLimited evaluates it for the end of the
+	// main chain: the key itself followed by the keys of the blocks before
+	// the end of the main chain, within the rate limiting window.
+	var prevPubKeys []wire.BlockValidatingPubKey
+	for i := 1; i < len(recent) && i < rateWindow; i++ {
+		prevPubKeys = append(prevPubKeys, recent[i].pubKey)
+	}
+	isRateLimited := func(pubKey wire.BlockValidatingPubKey) bool {
+		keys := append([]wire.BlockValidatingPubKey{pubKey},
+			prevPubKeys...)
+		return IsGenerationTrailingRateLimited(pubKey, keys,
+			maxTrailing) || IsGenerationShareRateLimited(pubKey,
+			keys, b.chainParams.ChainWindowShareLimit)
+	}
+	for i := range stats {
+		keyStats := &stats[i]
+		var shareCount int
+		for _, block := range shareWindow {
+			if block.pubKey == keyStats.PubKey {
+				shareCount++
+			}
+		}
+		keyStats.TrailingHeadroom = headroom(maxTrailing,
+			int(keyStats.TrailingCount))
+		keyStats.ShareHeadroom = math.MaxUint32
+		if maxShare > 0 {
+			keyStats.ShareHeadroom = headroom(maxShare+1, shareCount)
+		}
+
+		keyStats.RateLimited = isRateLimited(keyStats.PubKey)
+	}
+
+	return stats, nil
+}
+
+// BlocksUntilEligible returns the number of blocks which have to be signed by
+// other validate keys on top of the end of the main chain before each of the
+// passed keys may sign a block without violating the rate limiting rules.  It
+// is zero for the keys which may sign the next block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlocksUntilEligible(pubKeys []wire.BlockValidatingPubKey) ([]int, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	// Collect the validate keys of the blocks in the rate limiting window
+	// preceding a new block, starting with the end of the main chain.
+	window := b.chainParams.PowAveragingWindow
+	recent, err := b.recentSignedBlocks(window)
+	if err != nil {
+		return nil, err
+	}
+	prevPubKeys := make([]wire.BlockValidatingPubKey, len(recent))
+	for i, block := range recent {
+		prevPubKeys[i] = block.pubKey
+	}
+
+	blocks := make([]int, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		blocks[i] = BlocksUntilEligible(pubKey, prevPubKeys, window,
+			b.chainParams.ChainTrailingSigKeyLimit,
+			b.chainParams.ChainWindowShareLimit)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+)
+
+// TestValidateKeyStats ensures the validate key statistics report every key
+// of the current validate key set along with the signer of the genesis block.
+func TestValidateKeyStats(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("validatekeystats", params)
+	if err != nil {
+		t.Errorf("Failed to setup chain instance: %v", err)
+		return
+	}
+	defer teardownFunc()
+
+	stats, err := chain.ValidateKeyStats([]uint32{1, 10})
+	if err != nil {
+		t.Fatalf("ValidateKeyStats: unexpected error: %v", err)
+	}
+
+	validateKeys := chain.AdminKeySets()[btcec.ValidateKeySet]
+	genesisKey := params.GenesisBlock.Header.ValidatingPubKey
+	var numActive, numSeen int
+	for _, keyStats := range stats {
+		if keyStats.Active {
+			numActive++
+		}
+		if len(keyStats.WindowCounts) != 2 {
+			t.Fatalf("ValidateKeyStats: unexpected number of window "+
+				"counts - got %d, want 2", len(keyStats.WindowCounts))
+		}
+		if !keyStats.Seen {
+			continue
+		}
+		numSeen++
+		if keyStats.PubKey != genesisKey {
+			t.Errorf("ValidateKeyStats: unexpected signer %v", keyStats.PubKey)
+		}
+		if keyStats.LastSeenHeight != 0 || keyStats.TrailingCount != 1 ||
+			keyStats.WindowCounts[0] != 1 || keyStats.WindowCounts[1] != 1 {
+
+			t.Errorf("ValidateKeyStats: unexpected genesis signer "+
+				"stats %+v", keyStats)
+		}
+	}
+	if numActive != len(validateKeys) {
+		t.Errorf("ValidateKeyStats: unexpected number of active keys - "+
+			"got %d, want %d", numActive, len(validateKeys))
+	}
+	if numSeen != 1 {
+		t.Errorf("ValidateKeyStats: unexpected number of signers - got "+
+			"%d, want 1", numSeen)
+	}
+}
+
+// TestValidateKeyStatsLargeWindow ensures windows beyond the chain are limited
+// to the blocks of the chain rather than scanned or allocated in full.
+func TestValidateKeyStatsLargeWindow(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("validatekeystatslarge",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Errorf("Failed to setup chain instance: %v", err)
+		return
+	}
+	defer teardownFunc()
+
+	stats, err := chain.ValidateKeyStats([]uint32{math.MaxUint32})
+	if err != nil {
+		t.Fatalf("ValidateKeyStats: unexpected error: %v", err)
+	}
+	var signed uint32
+	for _, keyStats := range stats {
+		signed += keyStats.WindowCounts[0]
+	}
+	if signed != 1 {
+		t.Errorf("ValidateKeyStats: got %d signed blocks, want 1", signed)
+	}
+}
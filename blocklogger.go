@@ -62,7 +62,11 @@ func (b *blockProgressLogger) LogBlockHeight(block *provautil.Block) {
 	if b.receivedLogTx == 1 {
 		txStr = "transaction"
 	}
-	b.subsystemLogger.Infof("%s %d %s in the last %s (%d %s, height %d, %s)",
+	log := withLogFields(b.subsystemLogger, logFields{
+		"block":  block.Hash(),
+		"height": block.Height(),
+	})
+	log.Infof("%s %d %s in the last %s (%d %s, height %d, %s)",
 		b.progressAction, b.receivedLogBlocks, blockStr, tDuration, b.receivedLogTx,
 		txStr, block.Height(), block.MsgBlock().Header.Timestamp)
 
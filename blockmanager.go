@@ -7,12 +7,15 @@ package main
 
 import (
 	"container/list"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg"
@@ -40,6 +43,25 @@ const (
 	// maxRequestedTxns is the maximum number of requested transactions
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
+
+	// maxOrphanAncestorPeers is the maximum number of peers besides the
+	// one which sent an orphan block its missing ancestors are requested
+	// from.
+	maxOrphanAncestorPeers = 2
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks requested
+	// from a single peer at once while the chain is downloaded from
+	// several peers in parallel.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockRequestTimeout is the amount of time a peer has to deliver a
+	// block requested from it while the chain is downloaded before the
+	// block is requested from another peer instead.
+	blockRequestTimeout = 15 * time.Second
+
+	// blockStallTickInterval is the interval of time between each check
+	// for block requests which timed out.
+	blockStallTickInterval = 5 * time.Second
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -57,6 +79,27 @@ type blockMsg struct {
 	peer  *serverPeer
 }
 
+// cmpctBlockMsg packages a bitcoin cmpctblock message and the peer it came
+// from together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	block *wire.MsgCmpctBlock
+	peer  *serverPeer
+}
+
+// blockTxnMsg packages a bitcoin blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *serverPeer
+}
+
+// headersMsg packages a bitcoin headers message and the peer it came from
+// together so the block handler has access to that information.
+type headersMsg struct {
+	headers *wire.MsgHeaders
+	peer    *serverPeer
+}
+
 // invMsg packages a bitcoin inv message and the peer it came from together
 // so the block handler has access to that information.
 type invMsg struct {
@@ -115,6 +158,35 @@ type pauseMsg struct {
 	unpause <-chan struct{}
 }
 
+// peerSyncState tracks the blocks requested from a sync candidate peer while
+// the chain is downloaded from several peers in parallel.
+type peerSyncState struct {
+	// blocksInFlight is the number of blocks requested from the peer which
+	// it has not delivered yet.
+	blocksInFlight int
+
+	// stalled is set when a block request to the peer timed out, and
+	// cleared once it delivers a block again.  Stalled peers are only
+	// requested blocks when no other peer can be.
+	stalled bool
+}
+
+// queuedBlock is a block announced by the sync peer which is waiting to be
+// requested from one of the sync candidate peers.
+type queuedBlock struct {
+	hash chainhash.Hash
+
+	// peer is the only peer the block is requested from when it is set.
+	peer *serverPeer
+}
+
+// blockRequest is a block requested from a peer while the chain is
+// downloaded, along with when it was requested.
+type blockRequest struct {
+	peer      *serverPeer
+	requested time.Time
+}
+
 // blockManager provides a concurrency safe block manager for handling all
 // incoming blocks.
 type blockManager struct {
@@ -130,6 +202,20 @@ type blockManager struct {
 	msgChan         chan interface{}
 	wg              sync.WaitGroup
 	quit            chan struct{}
+
+	// peers are the connected peers which are candidates to sync from,
+	// which the missing ancestors of orphan blocks and the blocks of the
+	// chain being downloaded are requested from.
+	peers map[*serverPeer]*peerSyncState
+
+	// blockQueue holds the blocks announced by the sync peer while the
+	// chain is not current which have not been requested yet, in the
+	// order they are requested, and queuedBlocks the set of their hashes.
+	// blockRequests tracks the blocks requested from them until they are
+	// delivered.
+	blockQueue    []queuedBlock
+	queuedBlocks  map[chainhash.Hash]struct{}
+	blockRequests map[chainhash.Hash]*blockRequest
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -160,6 +246,14 @@ func (b *blockManager) startSync(peers *list.List) {
 			continue
 		}
 
+		// Fellow federation members are authenticated, so they are
+		// preferred over anonymous peers.
+		if bestPeer != nil && bestPeer.isFederationMember() &&
+			!sp.isFederationMember() {
+
+			continue
+		}
+
 		// TODO(davec): Use a better algorithm to choose the best peer.
 		// For now, just pick the first available candidate.
 		bestPeer = sp
@@ -179,7 +273,10 @@ func (b *blockManager) startSync(peers *list.List) {
 			return
 		}
 
-		bmgrLog.Infof("Syncing to block height %d from peer %v",
+		withLogFields(bmgrLog, logFields{
+			"peer":   bestPeer.Addr(),
+			"height": bestPeer.LastBlock(),
+		}).Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
 		bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		b.syncPeer = bestPeer
@@ -225,7 +322,8 @@ func (b *blockManager) handleNewPeerMsg(peers *list.List, sp *serverPeer) {
 		return
 	}
 
-	bmgrLog.Infof("New valid peer %s (%s)", sp, sp.UserAgent())
+	withLogFields(bmgrLog, logFields{"peer": sp.Addr()}).Infof(
+		"New valid peer %s (%s)", sp, sp.UserAgent())
 
 	// Ignore the peer if it's not a sync candidate.
 	if !b.isSyncCandidate(sp) {
@@ -234,6 +332,7 @@ func (b *blockManager) handleNewPeerMsg(peers *list.List, sp *serverPeer) {
 
 	// Add the peer as a candidate to sync from.
 	peers.PushBack(sp)
+	b.peers[sp] = &peerSyncState{}
 
 	// Start syncing by choosing the best candidate if needed.
 	b.startSync(peers)
@@ -252,7 +351,10 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 		}
 	}
 
-	bmgrLog.Infof("Lost peer %s", sp)
+	delete(b.peers, sp)
+
+	withLogFields(bmgrLog, logFields{"peer": sp.Addr()}).Infof(
+		"Lost peer %s", sp)
 
 	// Remove requested transactions from the global map so that they will
 	// be fetched from elsewhere next time we get an inv.
@@ -268,12 +370,181 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 		delete(b.requestedBlocks, k)
 	}
 
+	// Request the blocks of the chain being downloaded which the peer has
+	// not delivered from the remaining peers.
+	var requeue []queuedBlock
+	for hash, req := range b.blockRequests {
+		if req.peer == sp {
+			delete(b.blockRequests, hash)
+			requeue = append(requeue, queuedBlock{hash: hash})
+		}
+	}
+	for i := range b.blockQueue {
+		if b.blockQueue[i].peer == sp {
+			b.blockQueue[i].peer = nil
+		}
+	}
+	b.requeueBlocks(requeue)
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
 	// sync peer.
 	if b.syncPeer != nil && b.syncPeer == sp {
 		b.syncPeer = nil
 		b.startSync(peers)
 	}
+	b.scheduleBlockDownloads()
+}
+
+// queueBlock queues the passed block announced by the sync peer to be
+// requested from one of the sync candidate peers, unless it is queued or
+// requested already.  A block with a peer set is only requested from that
+// peer.
+func (b *blockManager) queueBlock(hash *chainhash.Hash, sp *serverPeer) {
+	if _, exists := b.queuedBlocks[*hash]; exists {
+		return
+	}
+	if _, exists := b.blockRequests[*hash]; exists {
+		return
+	}
+	if _, exists := b.requestedBlocks[*hash]; exists {
+		return
+	}
+	b.queuedBlocks[*hash] = struct{}{}
+	b.blockQueue = append(b.blockQueue, queuedBlock{hash: *hash, peer: sp})
+}
+
+// requeueBlocks puts the passed blocks whose requests failed at the front of
+// the block queue so they are requested again before any other.
+func (b *blockManager) requeueBlocks(blocks []queuedBlock) {
+	if len(blocks) == 0 {
+		return
+	}
+	for _, qb := range blocks {
+		b.queuedBlocks[qb.hash] = struct{}{}
+	}
+	b.blockQueue = append(blocks, b.blockQueue...)
+}
+
+// isBlockScheduled returns whether the passed block is queued or requested
+// while the chain is downloaded from several peers.
+func (b *blockManager) isBlockScheduled(hash *chainhash.Hash) bool {
+	if _, exists := b.queuedBlocks[*hash]; exists {
+		return true
+	}
+	_, exists := b.blockRequests[*hash]
+	return exists
+}
+
+// assignQueuedBlocks assigns the passed queued blocks to the peers with free
+// request slots in the passed map, which is updated as slots are used.  Each
+// block goes to the peer with the most free slots, so the blocks are spread
+// evenly, except for blocks which are only requested from a given peer.  It
+// returns the blocks assigned to each peer in queue order along with the blocks
+// which could not be assigned.
+func assignQueuedBlocks(queue []queuedBlock, slots map[*serverPeer]int) (map[*serverPeer][]chainhash.Hash, []queuedBlock) {
+	assigned := make(map[*serverPeer][]chainhash.Hash)
+	var remaining []queuedBlock
+	for _, qb := range queue {
+		sp := qb.peer
+		if sp == nil {
+			for peer, free := range slots {
+				if sp == nil || free > slots[sp] {
+					sp = peer
+				}
+			}
+		}
+		if sp == nil || slots[sp] <= 0 {
+			remaining = append(remaining, qb)
+			continue
+		}
+		slots[sp]--
+		assigned[sp] = append(assigned[sp], qb.hash)
+	}
+	return assigned, remaining
+}
+
+// scheduleBlockDownloads requests the queued blocks of the chain being
+// downloaded from the sync candidate peers which are ahead of this node, up to
+// maxBlocksInFlightPerPeer blocks from each of them at once.  Peers whose
+// requests timed out are only used when no other peer can be.
+func (b *blockManager) scheduleBlockDownloads() {
+	if len(b.blockQueue) == 0 {
+		return
+	}
+
+	best := b.chain.BestSnapshot()
+	freeSlots := func(includeStalled bool) map[*serverPeer]int {
+		slots := make(map[*serverPeer]int)
+		for sp, state := range b.peers {
+			if state.stalled && !includeStalled {
+				continue
+			}
+			if sp != b.syncPeer && sp.LastBlock() <= best.Height {
+				continue
+			}
+			free := maxBlocksInFlightPerPeer - state.blocksInFlight
+			if free > 0 {
+				slots[sp] = free
+			}
+		}
+		return slots
+	}
+	slots := freeSlots(false)
+	if len(slots) == 0 {
+		slots = freeSlots(true)
+	}
+	assigned, remaining := assignQueuedBlocks(b.blockQueue, slots)
+	b.blockQueue = remaining
+
+	now := time.Now()
+	for sp, hashes := range assigned {
+		gdmsg := wire.NewMsgGetData()
+		for i := range hashes {
+			hash := &hashes[i]
+			delete(b.queuedBlocks, *hash)
+			b.blockRequests[*hash] = &blockRequest{
+				peer:      sp,
+				requested: now,
+			}
+			b.requestedBlocks[*hash] = struct{}{}
+			b.limitMap(b.requestedBlocks, maxRequestedBlocks)
+			sp.requestedBlocks[*hash] = struct{}{}
+			gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+		}
+		b.peers[sp].blocksInFlight += len(hashes)
+		sp.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleStalledBlockRequests requests the blocks of the chain being downloaded
+// whose requests timed out from other peers, and marks the peers which did not
+// deliver them as stalled.  The blocks stay requested from the stalled peers as
+// well, so they are not punished for delivering them late.
+func (b *blockManager) handleStalledBlockRequests() {
+	now := time.Now()
+	var requeue []queuedBlock
+	for hash, req := range b.blockRequests {
+		if now.Sub(req.requested) < blockRequestTimeout {
+			continue
+		}
+		delete(b.blockRequests, hash)
+		if state, ok := b.peers[req.peer]; ok {
+			state.blocksInFlight--
+			state.stalled = true
+		}
+		withLogFields(bmgrLog, logFields{
+			"peer":  req.peer.Addr(),
+			"block": hash,
+		}).Debugf("Request for block %v from %s timed out", hash,
+			req.peer)
+		requeue = append(requeue, queuedBlock{hash: hash})
+	}
+	if len(requeue) > 0 {
+		bmgrLog.Infof("Requesting %d stalled blocks from other peers",
+			len(requeue))
+	}
+	b.requeueBlocks(requeue)
+	b.scheduleBlockDownloads()
 }
 
 // handleTxMsg handles transaction messages from all peers.
@@ -287,6 +558,7 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 	// to disconnect peers for sending unsolicited transactions to provide
 	// interoperability.
 	txHash := tmsg.tx.Hash()
+	atomic.AddUint64(&tmsg.peer.txsRecv, 1)
 
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
@@ -320,11 +592,15 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 		// simply rejected as opposed to something actually going wrong,
 		// so log it as such.  Otherwise, something really did go wrong,
 		// so log it as an actual error.
+		log := withLogFields(bmgrLog, logFields{
+			"peer": tmsg.peer.Addr(),
+			"txid": txHash,
+		})
 		if _, ok := err.(mempool.RuleError); ok {
-			bmgrLog.Debugf("Rejected transaction %v from %s: %v",
+			log.Debugf("Rejected transaction %v from %s: %v",
 				txHash, tmsg.peer, err)
 		} else {
-			bmgrLog.Errorf("Failed to process transaction %v: %v",
+			log.Errorf("Failed to process transaction %v: %v",
 				txHash, err)
 		}
 
@@ -336,6 +612,13 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 		return
 	}
 
+	// The transaction is accepted unless it was added as an orphan.
+	for _, txD := range acceptedTxs {
+		if txD.Tx.Hash().IsEqual(txHash) {
+			atomic.AddUint64(&tmsg.peer.txsAccepted, 1)
+			break
+		}
+	}
 	b.server.AnnounceNewTransactions(acceptedTxs)
 }
 
@@ -360,18 +643,37 @@ func (b *blockManager) current() bool {
 	return true
 }
 
+// checkScriptDivergence requests a shutdown of the node when the passed error
+// from processing a block reports that the script engine and the reference
+// interpreter disagree on its validity, since the script engine can no longer
+// be trusted to enforce the consensus rules.
+func (b *blockManager) checkScriptDivergence(err error) {
+	if _, ok := err.(blockchain.ScriptDivergenceError); !ok {
+		return
+	}
+	bmgrLog.Criticalf("Shutting down due to script divergence: %v", err)
+	go func() {
+		shutdownRequestChannel <- struct{}{}
+	}()
+}
+
 // handleBlockMsg handles block messages from all peers.
 func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
-	if _, exists := bmsg.peer.requestedBlocks[*blockHash]; !exists {
+	atomic.AddUint64(&bmsg.peer.blocksRecv, 1)
+	_, requested := bmsg.peer.requestedBlocks[*blockHash]
+	if !requested {
 		// The regression test intentionally sends some blocks twice
 		// to test duplicate block insertion fails.  Don't disconnect
 		// the peer or ignore the block when we're in regression test
 		// mode in this case so the chain code is actually fed the
 		// duplicate blocks.
 		if !cfg.RegressionTest {
-			bmgrLog.Warnf("Got unrequested block %v from %s -- "+
+			withLogFields(bmgrLog, logFields{
+				"peer":  bmsg.peer.Addr(),
+				"block": blockHash,
+			}).Warnf("Got unrequested block %v from %s -- "+
 				"disconnecting", blockHash, bmsg.peer.Addr())
 			bmsg.peer.Disconnect()
 			return
@@ -383,9 +685,34 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
+	_, pending := b.requestedBlocks[*blockHash]
 	delete(bmsg.peer.requestedBlocks, *blockHash)
 	delete(b.requestedBlocks, *blockHash)
 
+	// Free the request slot of the peer the block was requested from while
+	// the chain is downloaded, and request more blocks with it.
+	if req, ok := b.blockRequests[*blockHash]; ok {
+		delete(b.blockRequests, *blockHash)
+		if state, ok := b.peers[req.peer]; ok {
+			state.blocksInFlight--
+		}
+	}
+	if state, ok := b.peers[bmsg.peer]; ok {
+		state.stalled = false
+	}
+	b.scheduleBlockDownloads()
+
+	// A block whose request timed out may be delivered by the stalled peer
+	// after another peer delivered it already, which is not an error.
+	if requested && !pending {
+		haveBlock, err := b.chain.HaveBlock(blockHash)
+		if err == nil && haveBlock {
+			bmgrLog.Debugf("Ignoring block %v from %s which was "+
+				"delivered by another peer", blockHash, bmsg.peer)
+			return
+		}
+	}
+
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
 	_, isOrphan, err := b.chain.ProcessBlock(bmsg.block, behaviorFlags)
@@ -394,17 +721,25 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		// rejected as opposed to something actually going wrong, so log
 		// it as such.  Otherwise, something really did go wrong, so log
 		// it as an actual error.
+		log := withLogFields(bmgrLog, logFields{
+			"peer":  bmsg.peer.Addr(),
+			"block": blockHash,
+		})
 		if _, ok := err.(blockchain.RuleError); ok {
-			bmgrLog.Infof("Rejected block %v from %s: %v", blockHash,
+			log.Infof("Rejected block %v from %s: %v", blockHash,
 				bmsg.peer, err)
 		} else {
-			bmgrLog.Errorf("Failed to process block %v: %v",
+			log.Errorf("Failed to process block %v: %v",
 				blockHash, err)
 		}
 		if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
 			database.ErrCorruption {
 			panic(dbErr)
 		}
+		b.checkScriptDivergence(err)
+		if m := b.server.alertMonitor; m != nil {
+			m.BlockRejected(bmsg.block, err)
+		}
 
 		// Convert the error into an appropriate reject message and
 		// send it.
@@ -426,6 +761,10 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	var heightUpdate uint32
 	var blkHashUpdate *chainhash.Hash
 
+	if !isOrphan {
+		atomic.AddUint64(&bmsg.peer.blocksAccepted, 1)
+	}
+
 	// Request the parents for the orphan block from the peer that sent it.
 	if isOrphan {
 		// We've just received an orphan block from a peer. In order
@@ -437,13 +776,10 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		heightUpdate := header.Height
 		bmgrLog.Debugf("Extracted height of %v from orphan block", heightUpdate)
 
-		orphanRoot := b.chain.GetOrphanRoot(blockHash)
-		locator, err := b.chain.LatestBlockLocator()
-		if err != nil {
-			bmgrLog.Warnf("Failed to get block locator for the "+
-				"latest block: %v", err)
-		} else {
-			bmsg.peer.PushGetBlocksMsg(locator, orphanRoot)
+		// Blocks downloaded from several peers arrive out of order, so
+		// the parent is only requested when it was not already.
+		if !b.isBlockScheduled(&header.PrevBlock) {
+			b.requestOrphanAncestors(bmsg.peer, blockHash)
 		}
 	} else {
 		// When the block is not an orphan, log information about it and
@@ -480,6 +816,160 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	}
 }
 
+// requestOrphanAncestors requests the blocks from the latest block of the main
+// chain up to the root of the chain of orphans the passed block is part of from
+// the peer which sent it.  Once the chain is current, they are also requested
+// from up to maxOrphanAncestorPeers other peers which are ahead of this node,
+// so the missing ancestors arrive even when the sending peer is slow to serve
+// them.  Each block the peers announce is only requested from the first of
+// them.
+func (b *blockManager) requestOrphanAncestors(sp *serverPeer, hash *chainhash.Hash) {
+	orphanRoot := b.chain.GetOrphanRoot(hash)
+	locator, err := b.chain.LatestBlockLocator()
+	if err != nil {
+		bmgrLog.Warnf("Failed to get block locator for the latest "+
+			"block: %v", err)
+		return
+	}
+	sp.PushGetBlocksMsg(locator, orphanRoot)
+
+	// The inventory announced by peers other than the sync peer is ignored
+	// until the chain is current.
+	if !b.current() {
+		return
+	}
+	best := b.chain.BestSnapshot()
+	requested := 0
+	for peer := range b.peers {
+		if requested == maxOrphanAncestorPeers {
+			break
+		}
+		if peer == sp || peer.LastBlock() <= best.Height {
+			continue
+		}
+		peer.PushGetBlocksMsg(locator, orphanRoot)
+		requested++
+	}
+	if requested > 0 {
+		bmgrLog.Debugf("Requested the ancestors of orphan block %v "+
+			"from %d more peers", hash, requested)
+	}
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  The block
+// is reconstructed from the transactions in the memory pool, and transactions
+// which could not be found are requested from the peer with a getblocktxn
+// message.
+func (b *blockManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	blockHash := cmsg.block.BlockHash()
+	_, requested := cmsg.peer.requestedBlocks[blockHash]
+	if !requested {
+		// Only outbound peers are asked to push compact blocks without
+		// announcing them first.
+		if cmsg.peer.Inbound() && !cfg.RegressionTest {
+			bmgrLog.Warnf("Got unrequested compact block %v from "+
+				"%s -- disconnecting", blockHash, cmsg.peer.Addr())
+			cmsg.peer.Disconnect()
+			return
+		}
+
+		// Ignore pushed blocks while syncing since they are most
+		// likely orphans.
+		if !b.current() {
+			return
+		}
+	}
+
+	haveBlock, err := b.chain.HaveBlock(&blockHash)
+	if err != nil {
+		bmgrLog.Warnf("Unexpected failure when checking for existing "+
+			"block %v: %v", blockHash, err)
+		return
+	}
+	if haveBlock {
+		delete(cmsg.peer.requestedBlocks, blockHash)
+		delete(b.requestedBlocks, blockHash)
+		return
+	}
+
+	// Track pushed blocks as requested so the missing transactions or the
+	// full block are accepted from the peer.
+	if !requested {
+		b.requestedBlocks[blockHash] = struct{}{}
+		b.limitMap(b.requestedBlocks, maxRequestedBlocks)
+		cmsg.peer.requestedBlocks[blockHash] = struct{}{}
+	}
+
+	txDescs := b.server.txMemPool.TxDescs()
+	txns := make([]*provautil.Tx, 0, len(txDescs))
+	for _, txDesc := range txDescs {
+		txns = append(txns, txDesc.Tx)
+	}
+	pb, err := newPartialBlock(cmsg.block, txns)
+	if err != nil {
+		bmgrLog.Debugf("Unable to reconstruct compact block %v from "+
+			"%s: %v", blockHash, cmsg.peer, err)
+		b.requestFullBlock(cmsg.peer, &blockHash)
+		return
+	}
+
+	if len(pb.missing) > 0 {
+		bmgrLog.Debugf("Requesting %d of %d transactions of compact "+
+			"block %v from %s", len(pb.missing), len(pb.txns),
+			blockHash, cmsg.peer)
+		cmsg.peer.partialBlock = pb
+		getBlockTxn := wire.NewMsgGetBlockTxn(&blockHash, pb.missing)
+		cmsg.peer.QueueMessage(getBlockTxn, nil)
+		return
+	}
+	b.processPartialBlock(cmsg.peer, pb)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers, which complete
+// the block of a compact block previously received from the peer.
+func (b *blockManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	pb := bmsg.peer.partialBlock
+	if pb == nil || pb.header.BlockHash() != bmsg.blockTxn.BlockHash {
+		bmgrLog.Debugf("Ignoring unrequested block transactions of "+
+			"block %v from %s", bmsg.blockTxn.BlockHash, bmsg.peer)
+		return
+	}
+	bmsg.peer.partialBlock = nil
+
+	err := pb.fill(bmsg.blockTxn.Transactions)
+	if err != nil {
+		blockHash := pb.header.BlockHash()
+		bmgrLog.Debugf("Unable to complete compact block %v from "+
+			"%s: %v", blockHash, bmsg.peer, err)
+		b.requestFullBlock(bmsg.peer, &blockHash)
+		return
+	}
+	b.processPartialBlock(bmsg.peer, pb)
+}
+
+// processPartialBlock processes the reconstructed block of a compact block
+// from the passed peer like a block received in full.  The full block is
+// requested when the reconstructed block does not match its merkle root.
+func (b *blockManager) processPartialBlock(sp *serverPeer, pb *partialBlock) {
+	block, err := pb.block()
+	if err != nil {
+		blockHash := pb.header.BlockHash()
+		bmgrLog.Debugf("Unable to reconstruct compact block %v from "+
+			"%s: %v", blockHash, sp, err)
+		b.requestFullBlock(sp, &blockHash)
+		return
+	}
+	b.handleBlockMsg(&blockMsg{block: block, peer: sp})
+}
+
+// requestFullBlock requests the passed block in full from the passed peer.  It
+// is used when a compact block can not be reconstructed.
+func (b *blockManager) requestFullBlock(sp *serverPeer, blockHash *chainhash.Hash) {
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, blockHash))
+	sp.QueueMessage(gdmsg, nil)
+}
+
 // haveInventory returns whether or not the inventory represented by the passed
 // inventory vector is known.  This includes checking all of the various places
 // inventory can be when it is in different states such as blocks that are part
@@ -551,6 +1041,10 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		}
 	}
 
+	// While the chain is downloaded, the blocks announced by the sync peer
+	// are requested from several peers in parallel.
+	syncing := !b.current()
+
 	// Request the advertised inventory if we don't already have it.  Also,
 	// request parent blocks of orphans if we receive one we already have.
 	// Finally, attempt to detect potential stalls due to long side chains
@@ -582,6 +1076,19 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 				}
 			}
 
+			// Queue the block to be requested from any of the
+			// peers while syncing.  The final block is requested
+			// from the sync peer itself, since requesting it is
+			// what makes the sync peer announce the next blocks.
+			if iv.Type == wire.InvTypeBlock && syncing {
+				var sp *serverPeer
+				if i == lastBlock {
+					sp = imsg.peer
+				}
+				b.queueBlock(&iv.Hash, sp)
+				continue
+			}
+
 			// Add it to the request queue.
 			imsg.peer.requestQueue = append(imsg.peer.requestQueue, iv)
 			continue
@@ -628,6 +1135,11 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		}
 	}
 
+	// Request new blocks as compact blocks from peers which support them
+	// once the chain is current, since their transactions are most likely
+	// in the memory pool already.
+	requestCmpct := b.current() && imsg.peer.SupportsCompactBlocks()
+
 	// Request as much as possible at once.  Anything that won't fit into
 	// the request will be requested on the next inv message.
 	numRequested := 0
@@ -646,6 +1158,10 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 				b.requestedBlocks[iv.Hash] = struct{}{}
 				b.limitMap(b.requestedBlocks, maxRequestedBlocks)
 				imsg.peer.requestedBlocks[iv.Hash] = struct{}{}
+				if requestCmpct {
+					iv = wire.NewInvVect(wire.InvTypeCmpctBlock,
+						&iv.Hash)
+				}
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -670,6 +1186,119 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 	if len(gdmsg.InvList) > 0 {
 		imsg.peer.QueueMessage(gdmsg, nil)
 	}
+	b.scheduleBlockDownloads()
+}
+
+// handleHeadersMsg handles headers messages from all peers, which announce new
+// blocks to peers that asked for headers with a sendheaders message.  Every
+// header is checked to be signed by a validate key before its block is
+// requested, so blocks with invalid signatures are never downloaded.
+func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
+	headers := hmsg.headers.Headers
+	if len(headers) == 0 {
+		return
+	}
+
+	// Update the last announced block for this peer like a block
+	// announced by an inv message.
+	lastHash := headers[len(headers)-1].BlockHash()
+	if hmsg.peer != b.syncPeer || b.current() {
+		hmsg.peer.UpdateLastAnnouncedBlock(&lastHash)
+	}
+
+	// Ignore announcements from peers that aren't the sync peer if we are
+	// not current.  Helps prevent fetching a mass of orphans.
+	if hmsg.peer != b.syncPeer && !b.current() {
+		return
+	}
+
+	// The headers only connect to the chain when the parent of the first
+	// one is known.  Otherwise more than one block was found since the
+	// last announcement, so request the blocks in between.
+	prevHash := headers[0].PrevBlock
+	haveParent, err := b.chain.HaveBlock(&prevHash)
+	if err != nil {
+		bmgrLog.Warnf("Unexpected failure when checking for existing "+
+			"block %v: %v", prevHash, err)
+		return
+	}
+	if !haveParent {
+		locator, err := b.chain.LatestBlockLocator()
+		if err != nil {
+			bmgrLog.Errorf("Failed to get block locator for the "+
+				"latest block: %v", err)
+			return
+		}
+		hmsg.peer.PushGetBlocksMsg(locator, &lastHash)
+		return
+	}
+
+	// Request new blocks as compact blocks from peers which support them
+	// once the chain is current, like blocks announced by inv messages.
+	requestCmpct := b.current() && hmsg.peer.SupportsCompactBlocks()
+
+	gdmsg := wire.NewMsgGetData()
+	for _, header := range headers {
+		if !header.PrevBlock.IsEqual(&prevHash) {
+			bmgrLog.Warnf("Received block header that does not "+
+				"properly connect to the chain from peer %s "+
+				"-- disconnecting", hmsg.peer.Addr())
+			hmsg.peer.Disconnect()
+			return
+		}
+		blockHash := header.BlockHash()
+		prevHash = blockHash
+
+		iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+		hmsg.peer.AddKnownInventory(iv)
+
+		haveBlock, err := b.chain.HaveBlock(&blockHash)
+		if err != nil {
+			bmgrLog.Warnf("Unexpected failure when checking for "+
+				"existing block %v: %v", blockHash, err)
+			return
+		}
+		if haveBlock {
+			continue
+		}
+
+		// Check the signature of the header before requesting its
+		// block.  The validate key set may be changed by the block
+		// itself, so a block signed by an unknown key is not requested
+		// but the peer is not punished either.  The block is fetched
+		// with getblocks once a later block is announced.
+		err = b.chain.CheckHeaderSignature(header)
+		if err != nil {
+			rerr, ok := err.(blockchain.RuleError)
+			if ok && rerr.ErrorCode == blockchain.ErrInvalidValidateKey {
+				bmgrLog.Debugf("Not requesting block %v from "+
+					"%s: %v", blockHash, hmsg.peer, err)
+				break
+			}
+			bmgrLog.Infof("Rejected block header %v from %s: %v",
+				blockHash, hmsg.peer, err)
+			hmsg.peer.addBanScore(100, 0, misbehaviorInvalidHeaders,
+				fmt.Sprintf("header %v with invalid signature",
+					blockHash))
+			return
+		}
+
+		// Request the block if there is not already a pending
+		// request.
+		if _, exists := b.requestedBlocks[blockHash]; exists {
+			continue
+		}
+		b.requestedBlocks[blockHash] = struct{}{}
+		b.limitMap(b.requestedBlocks, maxRequestedBlocks)
+		hmsg.peer.requestedBlocks[blockHash] = struct{}{}
+		if requestCmpct {
+			iv = wire.NewInvVect(wire.InvTypeCmpctBlock, &blockHash)
+		}
+		gdmsg.AddInvVect(iv)
+	}
+	if len(gdmsg.InvList) > 0 {
+		hmsg.peer.QueueMessage(gdmsg, nil)
+	}
 }
 
 // limitMap is a helper function for maps that require a maximum limit by
@@ -698,6 +1327,8 @@ func (b *blockManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 // the fetching should proceed.
 func (b *blockManager) blockHandler() {
 	candidatePeers := list.New()
+	stallTicker := time.NewTicker(blockStallTickInterval)
+	defer stallTicker.Stop()
 out:
 	for {
 		select {
@@ -714,6 +1345,17 @@ out:
 				b.handleBlockMsg(msg)
 				msg.peer.blockProcessed <- struct{}{}
 
+			case *cmpctBlockMsg:
+				b.handleCmpctBlockMsg(msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *blockTxnMsg:
+				b.handleBlockTxnMsg(msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *headersMsg:
+				b.handleHeadersMsg(msg)
+
 			case *invMsg:
 				b.handleInvMsg(msg)
 
@@ -727,6 +1369,7 @@ out:
 				_, isOrphan, err := b.chain.ProcessBlock(
 					msg.block, msg.flags)
 				if err != nil {
+					b.checkScriptDivergence(err)
 					msg.reply <- processBlockResponse{
 						isOrphan: false,
 						err:      err,
@@ -758,6 +1401,9 @@ out:
 					"handler: %T", msg)
 			}
 
+		case <-stallTicker.C:
+			b.handleStalledBlockRequests()
+
 		case <-b.quit:
 			break out
 		}
@@ -788,7 +1434,7 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
-		b.server.RelayInventory(iv, block.MsgBlock().Header)
+		b.server.RelayInventory(iv, block)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -798,21 +1444,52 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			break
 		}
 
+		// Record the block in the event log before the removal of its
+		// transactions from the transaction pool.
+		if l := b.server.eventLog; l != nil {
+			if err := l.BlockConnected(block); err != nil {
+				bmgrLog.Errorf("Unable to record connected block "+
+					"%v in the event log: %v", block.Hash(), err)
+			}
+		}
+
+		if m := b.server.alertMonitor; m != nil {
+			m.BlockConnected(block)
+		}
+		b.server.broadcasts.BlockConnected(block)
+
+		// Record the wallet transactions of the block before the
+		// memory pool transactions it confirms are removed.
+		if w := b.server.wallet; w != nil {
+			if err := w.ConnectBlock(block); err != nil {
+				bmgrLog.Errorf("Unable to record connected block "+
+					"%v in the wallet: %v", block.Hash(), err)
+			}
+		}
+
 		// Remove all of the transactions (except the coinbase) in the
 		// connected block from the transaction pool.  Secondly, remove any
 		// transactions which are now double spends as a result of these
-		// new transactions.  Finally, remove any transaction that is
-		// no longer an orphan. Transactions which depend on a confirmed
-		// transaction are NOT removed recursively because they are still
-		// valid.
+		// new transactions.  Also remove any transactions spending
+		// outputs of keyIDs frozen by them.  Finally, remove any
+		// transaction that is no longer an orphan. Transactions which
+		// depend on a confirmed transaction are NOT removed recursively
+		// because they are still valid.
 		for _, tx := range block.Transactions()[1:] {
 			b.server.txMemPool.RemoveTransaction(tx, false)
 			b.server.txMemPool.RemoveDoubleSpends(tx)
+			b.server.txMemPool.RemoveFrozenSpends(tx)
 			b.server.txMemPool.RemoveOrphan(tx)
 			acceptedTxs := b.server.txMemPool.ProcessOrphans(tx)
 			b.server.AnnounceNewTransactions(acceptedTxs)
 		}
 
+		// The signature hashes of the transactions in the connected
+		// block are no longer needed once the block has been validated.
+		for _, tx := range block.Transactions() {
+			b.server.hashCache.PurgeSigHashes(tx.Hash())
+		}
+
 		if r := b.server.rpcServer; r != nil {
 			// Now that this block is in the blockchain we can mark
 			// all the transactions (except the coinbase) as no
@@ -825,6 +1502,9 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			// Notify registered websocket clients of incoming block.
 			r.ntfnMgr.NotifyBlockConnected(block)
 		}
+		if g := b.server.grpcServer; g != nil {
+			g.NotifyBlockConnected(block)
+		}
 
 	// A block has been disconnected from the main block chain.
 	case blockchain.NTBlockDisconnected:
@@ -834,6 +1514,29 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			break
 		}
 
+		// Record the block in the event log before its transactions are
+		// reinserted into the transaction pool.
+		if l := b.server.eventLog; l != nil {
+			if err := l.BlockDisconnected(block); err != nil {
+				bmgrLog.Errorf("Unable to record disconnected "+
+					"block %v in the event log: %v",
+					block.Hash(), err)
+			}
+		}
+
+		if m := b.server.alertMonitor; m != nil {
+			m.BlockDisconnected(block)
+		}
+		b.server.broadcasts.BlockDisconnected(block)
+
+		if w := b.server.wallet; w != nil {
+			if err := w.DisconnectBlock(block); err != nil {
+				bmgrLog.Errorf("Unable to record disconnected "+
+					"block %v in the wallet: %v", block.Hash(),
+					err)
+			}
+		}
+
 		// Reinsert all of the transactions (except the coinbase) into
 		// the transaction pool.
 		for _, tx := range block.Transactions()[1:] {
@@ -851,6 +1554,9 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 		if r := b.server.rpcServer; r != nil {
 			r.ntfnMgr.NotifyBlockDisconnected(block)
 		}
+		if g := b.server.grpcServer; g != nil {
+			g.NotifyBlockDisconnected(block)
+		}
 	}
 }
 
@@ -886,6 +1592,42 @@ func (b *blockManager) QueueBlock(block *provautil.Block, sp *serverPeer) {
 	b.msgChan <- &blockMsg{block: block, peer: sp}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.
+func (b *blockManager) QueueCmpctBlock(block *wire.MsgCmpctBlock, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &cmpctBlockMsg{block: block, peer: sp}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block
+// handling queue.
+func (b *blockManager) QueueBlockTxn(blockTxn *wire.MsgBlockTxn, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &blockTxnMsg{blockTxn: blockTxn, peer: sp}
+}
+
+// QueueHeaders adds the passed headers message and peer to the block handling
+// queue.
+func (b *blockManager) QueueHeaders(headers *wire.MsgHeaders, sp *serverPeer) {
+	// No channel handling here because peers do not need to block on
+	// headers messages.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		return
+	}
+
+	b.msgChan <- &headersMsg{headers: headers, peer: sp}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (b *blockManager) QueueInv(inv *wire.MsgInv, sp *serverPeer) {
 	// No channel handling here because peers do not need to block on inv
@@ -1032,6 +1774,9 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 		progressLogger:  newBlockProgressLogger("Processed", bmgrLog),
 		msgChan:         make(chan interface{}, cfg.MaxPeers*3),
+		peers:           make(map[*serverPeer]*peerSyncState),
+		queuedBlocks:    make(map[chainhash.Hash]struct{}),
+		blockRequests:   make(map[chainhash.Hash]*blockRequest),
 		quit:            make(chan struct{}),
 	}
 
@@ -1042,13 +1787,16 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	bm.chain, err = blockchain.New(&blockchain.Config{
-		DB:            s.db,
-		ChainParams:   s.chainParams,
-		Checkpoints:   checkpoints,
-		TimeSource:    s.timeSource,
-		Notifications: bm.handleNotifyMsg,
-		SigCache:      s.sigCache,
-		IndexManager:  indexManager,
+		DB:                s.db,
+		ChainParams:       s.chainParams,
+		Checkpoints:       checkpoints,
+		TimeSource:        s.timeSource,
+		Notifications:     bm.handleNotifyMsg,
+		SigCache:          s.sigCache,
+		IndexManager:      indexManager,
+		ScriptConsistency: cfg.ScriptConsistency,
+		MaxOrphanBlocks:   cfg.MaxOrphanBlocks,
+		OrphanExpiry:      cfg.OrphanBlockExpiry,
 	})
 	if err != nil {
 		return nil, err
@@ -1057,6 +1805,57 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 	return &bm, nil
 }
 
+// checkChainIntegrity checks the entries the chain keeps in the block database
+// for the blocks at the end of the main chain for the damage an unclean shutdown
+// can leave behind.  Damage is repaired by reconnecting the blocks after the
+// last undamaged one when --repair is set, otherwise an error which explains
+// how to repair it is returned.
+func checkChainIntegrity(chain *blockchain.BlockChain) error {
+	if cfg.CheckBlocks == 0 {
+		return nil
+	}
+
+	report, err := chain.CheckIntegrity(cfg.CheckBlocks)
+	if err != nil {
+		return err
+	}
+	if report.Consistent() {
+		return nil
+	}
+	for _, problem := range report.Problems {
+		btcdLog.Errorf("Block database damage at height %d: %s",
+			problem.Height, problem.Description)
+	}
+	repairHeight, ok := report.RepairHeight()
+	if !ok {
+		return errors.New("the block database is damaged beyond " +
+			"repair -- restore a backup made with the " +
+			"backupchainstate RPC or remove it to sync the chain " +
+			"from scratch")
+	}
+	if !cfg.Repair {
+		return fmt.Errorf("the block database is damaged after height "+
+			"%d -- restart with --repair to reconnect the blocks "+
+			"after it", repairHeight)
+	}
+
+	if err := chain.RepairChainState(repairHeight); err != nil {
+		return fmt.Errorf("%v -- restore a backup made with the "+
+			"backupchainstate RPC or remove the block database to "+
+			"sync the chain from scratch", err)
+	}
+	report, err = chain.CheckIntegrity(cfg.CheckBlocks)
+	if err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		return fmt.Errorf("the block database is still damaged after "+
+			"the repair: %s", report.Problems[0].Description)
+	}
+	btcdLog.Info("Block database repaired")
+	return nil
+}
+
 // removeRegressionDB removes the existing regression test database if running
 // in regression test mode and it already exists.
 func removeRegressionDB(dbPath string) error {
@@ -1103,7 +1902,7 @@ func warnMultipleDBs() {
 	// This is intentionally not using the known db types which depend
 	// on the database types compiled into the binary since we want to
 	// detect legacy db types as well.
-	dbTypes := []string{"ffldb", "leveldb", "sqlite"}
+	dbTypes := []string{"ffldb", "badgerdb", "leveldb", "sqlite"}
 	duplicateDbPaths := make([]string, 0, len(dbTypes)-1)
 	for _, dbType := range dbTypes {
 		if dbType == cfg.DbType {
@@ -1152,12 +1951,40 @@ func loadBlockDB() (database.DB, error) {
 	// The database name is based on the database type.
 	dbPath := blockDbPath(cfg.DbType)
 
+	// Open the database without writing to it when the node only serves
+	// queries from it.  It must exist since it can't be created.
+	if cfg.ReadOnly {
+		btcdLog.Infof("Loading block database read-only from '%s'",
+			dbPath)
+		db, err := database.OpenReadOnly(cfg.DbType, dbPath,
+			activeNetParams.Net)
+		if err != nil {
+			return nil, err
+		}
+		btcdLog.Info("Block database loaded")
+		return db, nil
+	}
+
 	// The regression test is special in that it needs a clean database for
 	// each run, so remove it now if it already exists.
 	removeRegressionDB(dbPath)
 
 	btcdLog.Infof("Loading block database from '%s'", dbPath)
 	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+		database.ErrCorruption {
+
+		// The files of the database were damaged, such as by an
+		// unclean shutdown, so recover them when requested.
+		if !cfg.Repair {
+			return nil, fmt.Errorf("the block database is damaged: "+
+				"%v -- restart with --repair to recover it", err)
+		}
+		btcdLog.Warnf("The block database is damaged: %v", err)
+		btcdLog.Infof("Repairing block database at '%s'", dbPath)
+		db, err = database.Repair(cfg.DbType, dbPath,
+			activeNetParams.Net)
+	}
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -0,0 +1,60 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// TestAssignQueuedBlocks ensures queued blocks are spread over the peers with
+// free request slots, blocks for a given peer only go to it, and blocks which
+// do not fit are left queued in order.
+func TestAssignQueuedBlocks(t *testing.T) {
+	syncPeer, other := &serverPeer{}, &serverPeer{}
+	queue := make([]queuedBlock, 6)
+	for i := range queue {
+		queue[i].hash = chainhash.Hash{byte(i)}
+	}
+	queue[5].peer = syncPeer
+
+	slots := map[*serverPeer]int{syncPeer: 2, other: 3}
+	assigned, remaining := assignQueuedBlocks(queue, slots)
+	if len(assigned[syncPeer]) != 2 || len(assigned[other]) != 3 {
+		t.Fatalf("assignQueuedBlocks: got %d blocks for the sync peer "+
+			"and %d for the other peer, want 2 and 3",
+			len(assigned[syncPeer]), len(assigned[other]))
+	}
+	if slots[syncPeer] != 0 || slots[other] != 0 {
+		t.Fatalf("assignQueuedBlocks: got %v free slots left, want none",
+			slots)
+	}
+	// The final block is left queued since the sync peer has no free
+	// slots left for it.
+	if !reflect.DeepEqual(remaining, queue[5:]) {
+		t.Fatalf("assignQueuedBlocks: got remaining %v, want %v",
+			remaining, queue[5:])
+	}
+
+	// The block for the sync peer is assigned once it has a free slot,
+	// even though the other peer has more.
+	slots = map[*serverPeer]int{syncPeer: 1, other: 5}
+	assigned, remaining = assignQueuedBlocks(remaining, slots)
+	if len(remaining) != 0 || len(assigned[syncPeer]) != 1 ||
+		assigned[syncPeer][0] != queue[5].hash {
+
+		t.Fatalf("assignQueuedBlocks: got assigned %v remaining %v",
+			assigned, remaining)
+	}
+
+	// Nothing is assigned without peers.
+	assigned, remaining = assignQueuedBlocks(queue, nil)
+	if len(assigned) != 0 || !reflect.DeepEqual(remaining, queue) {
+		t.Fatalf("assignQueuedBlocks: got assigned %v remaining %v "+
+			"without peers", assigned, remaining)
+	}
+}
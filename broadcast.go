@@ -0,0 +1,361 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// maxFinishedBroadcasts is the number of finished broadcast campaigns whose
+// status is kept for queries.  The oldest ones are forgotten first.
+const maxFinishedBroadcasts = 1000
+
+// The statuses of broadcast campaigns.  Campaigns are final once confirmed or
+// conflicted.
+const (
+	// broadcastPending is the status of a campaign whose transaction is
+	// in the memory pool but was neither requested nor announced by peers.
+	broadcastPending = "pending"
+
+	// broadcastPropagated is the status of a campaign whose unconfirmed
+	// transaction was requested or announced by peers.
+	broadcastPropagated = "propagated"
+
+	// broadcastConfirming is the status of a campaign whose transaction is
+	// in the main chain with fewer than the target confirmations.
+	broadcastConfirming = "confirming"
+
+	// broadcastConfirmed is the final status of a campaign whose
+	// transaction reached the target confirmations.
+	broadcastConfirmed = "confirmed"
+
+	// broadcastConflicted is the final status of a campaign whose inputs
+	// are spent by another transaction which reached the target
+	// confirmations.
+	broadcastConflicted = "conflicted"
+)
+
+// broadcastTrackerConfig houses the dependencies of a broadcast tracker.
+type broadcastTrackerConfig struct {
+	// BestHeight returns the height of the main chain.
+	BestHeight func() uint32
+
+	// Finished is invoked with the final status of every campaign.
+	Finished func(status *btcjson.BroadcastStatusResult)
+}
+
+// broadcastCampaign tracks the propagation and confirmation of a transaction.
+type broadcastCampaign struct {
+	id          uint64
+	tx          *provautil.Tx
+	targetConfs uint32
+	started     time.Time
+	finished    time.Time
+	final       string
+
+	// peersRequested and peersAnnounced are the IDs of the peers which
+	// requested the transaction from this node and which announced it to
+	// this node respectively.
+	peersRequested map[int32]struct{}
+	peersAnnounced map[int32]struct{}
+
+	// block and height identify the main chain block containing the
+	// transaction.  block is nil while it is unconfirmed.
+	block  *chainhash.Hash
+	height uint32
+
+	// unconfirmations is the number of times the block containing the
+	// transaction was disconnected from the main chain.
+	unconfirmations uint32
+
+	// conflict, conflictBlock and conflictHeight identify the main chain
+	// transaction spending an input of the transaction, if any.
+	conflict       *chainhash.Hash
+	conflictBlock  *chainhash.Hash
+	conflictHeight uint32
+}
+
+// confirmations returns the number of confirmations of a transaction included
+// in the main chain at the passed height when the main chain is at height
+// best.
+func confirmations(height, best uint32) uint32 {
+	if best < height {
+		return 0
+	}
+	return best - height + 1
+}
+
+// broadcastTracker tracks broadcast campaigns, which follow transactions
+// submitted to the network from peer acceptance to a target number of
+// confirmations, through reorganizations and until they are either confirmed
+// or conflicted.  The final status of each campaign is reported to the
+// configured callback.
+type broadcastTracker struct {
+	cfg broadcastTrackerConfig
+
+	mtx       sync.Mutex
+	nextID    uint64
+	campaigns map[uint64]*broadcastCampaign
+	finished  []uint64
+
+	// active and spends index the campaigns which are not final by the
+	// hash of their transaction and by the outputs it spends.
+	active map[chainhash.Hash]*broadcastCampaign
+	spends map[wire.OutPoint]*broadcastCampaign
+}
+
+// newBroadcastTracker returns a new broadcast tracker using the passed config.
+func newBroadcastTracker(cfg *broadcastTrackerConfig) *broadcastTracker {
+	return &broadcastTracker{
+		cfg:       *cfg,
+		campaigns: make(map[uint64]*broadcastCampaign),
+		active:    make(map[chainhash.Hash]*broadcastCampaign),
+		spends:    make(map[wire.OutPoint]*broadcastCampaign),
+	}
+}
+
+// Track begins a campaign for the passed transaction, which is final once the
+// transaction or a conflicting one has the passed number of confirmations.
+// Only one campaign may track a transaction at a time.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) Track(tx *provautil.Tx, targetConfs uint32) (uint64, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if c, ok := t.active[*tx.Hash()]; ok {
+		return 0, fmt.Errorf("transaction %v is already tracked by "+
+			"broadcast campaign %d", tx.Hash(), c.id)
+	}
+
+	t.nextID++
+	c := &broadcastCampaign{
+		id:             t.nextID,
+		tx:             tx,
+		targetConfs:    targetConfs,
+		started:        time.Now(),
+		peersRequested: make(map[int32]struct{}),
+		peersAnnounced: make(map[int32]struct{}),
+	}
+	t.campaigns[c.id] = c
+	t.active[*tx.Hash()] = c
+	for _, txIn := range tx.MsgTx().TxIn {
+		t.spends[txIn.PreviousOutPoint] = c
+	}
+	return c.id, nil
+}
+
+// Remove forgets the campaign with the passed ID, such as when its
+// transaction could not be submitted.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) Remove(id uint64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	c, ok := t.campaigns[id]
+	if !ok {
+		return
+	}
+	t.deactivate(c)
+	delete(t.campaigns, id)
+}
+
+// deactivate removes the passed campaign from the indexes of active campaigns.
+//
+// This function MUST be called with the tracker lock held.
+func (t *broadcastTracker) deactivate(c *broadcastCampaign) {
+	if t.active[*c.tx.Hash()] == c {
+		delete(t.active, *c.tx.Hash())
+	}
+	for _, txIn := range c.tx.MsgTx().TxIn {
+		if t.spends[txIn.PreviousOutPoint] == c {
+			delete(t.spends, txIn.PreviousOutPoint)
+		}
+	}
+}
+
+// Status returns the status of the campaign with the passed ID, or nil when
+// it is unknown.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) Status(id uint64) *btcjson.BroadcastStatusResult {
+	best := t.cfg.BestHeight()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	c, ok := t.campaigns[id]
+	if !ok {
+		return nil
+	}
+	return c.status(best)
+}
+
+// status returns the status of the campaign when the main chain is at the
+// passed height.
+func (c *broadcastCampaign) status(best uint32) *btcjson.BroadcastStatusResult {
+	status := &btcjson.BroadcastStatusResult{
+		ID:              c.id,
+		TxID:            c.tx.Hash().String(),
+		Status:          broadcastPending,
+		TargetConfs:     c.targetConfs,
+		PeersRequested:  len(c.peersRequested),
+		PeersAnnounced:  len(c.peersAnnounced),
+		Unconfirmations: c.unconfirmations,
+		Started:         c.started.Unix(),
+	}
+	if len(c.peersRequested) != 0 || len(c.peersAnnounced) != 0 {
+		status.Status = broadcastPropagated
+	}
+	if c.block != nil {
+		status.Status = broadcastConfirming
+		status.Confirmations = confirmations(c.height, best)
+		status.BlockHash = c.block.String()
+		status.BlockHeight = c.height
+	}
+	if c.conflict != nil {
+		status.ConflictTxID = c.conflict.String()
+		status.ConflictConfirmations = confirmations(c.conflictHeight,
+			best)
+	}
+	if c.final != "" {
+		status.Status = c.final
+		status.Final = true
+		status.Finished = c.finished.Unix()
+	}
+	return status
+}
+
+// PeerRequested notes the peer with the passed ID requested the transaction
+// with the passed hash.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) PeerRequested(hash *chainhash.Hash, peerID int32) {
+	t.mtx.Lock()
+	if c, ok := t.active[*hash]; ok {
+		c.peersRequested[peerID] = struct{}{}
+	}
+	t.mtx.Unlock()
+}
+
+// PeerAnnounced notes the peer with the passed ID announced the transactions
+// in the passed inventory.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) PeerAnnounced(invList []*wire.InvVect, peerID int32) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if len(t.active) == 0 {
+		return
+	}
+	for _, iv := range invList {
+		if iv.Type != wire.InvTypeTx {
+			continue
+		}
+		if c, ok := t.active[iv.Hash]; ok {
+			c.peersAnnounced[peerID] = struct{}{}
+		}
+	}
+}
+
+// BlockConnected notes the campaign transactions and conflicting transactions
+// in the passed block connected to the main chain, and finishes the campaigns
+// which reach their target confirmations.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) BlockConnected(block *provautil.Block) {
+	t.mtx.Lock()
+
+	if len(t.active) == 0 {
+		t.mtx.Unlock()
+		return
+	}
+	height := block.Height()
+	for _, tx := range block.Transactions() {
+		if c, ok := t.active[*tx.Hash()]; ok {
+			c.block = block.Hash()
+			c.height = height
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			if c, ok := t.spends[txIn.PreviousOutPoint]; ok {
+				c.conflict = tx.Hash()
+				c.conflictBlock = block.Hash()
+				c.conflictHeight = height
+			}
+		}
+	}
+
+	var finished []*btcjson.BroadcastStatusResult
+	now := time.Now()
+	for _, c := range t.active {
+		switch {
+		case c.block != nil &&
+			confirmations(c.height, height) >= c.targetConfs:
+			c.final = broadcastConfirmed
+
+		case c.conflict != nil &&
+			confirmations(c.conflictHeight, height) >= c.targetConfs:
+			c.final = broadcastConflicted
+
+		default:
+			continue
+		}
+		c.finished = now
+		t.finish(c)
+		finished = append(finished, c.status(height))
+	}
+	t.mtx.Unlock()
+
+	for _, status := range finished {
+		t.cfg.Finished(status)
+	}
+}
+
+// finish deactivates the passed campaign which became final, and forgets the
+// oldest finished campaigns beyond the maximum kept.
+//
+// This function MUST be called with the tracker lock held.
+func (t *broadcastTracker) finish(c *broadcastCampaign) {
+	t.deactivate(c)
+	t.finished = append(t.finished, c.id)
+	for len(t.finished) > maxFinishedBroadcasts {
+		delete(t.campaigns, t.finished[0])
+		t.finished = t.finished[1:]
+	}
+}
+
+// BlockDisconnected notes the campaign transactions and conflicting
+// transactions in the passed block disconnected from the main chain are no
+// longer confirmed.
+//
+// This function is safe for concurrent access.
+func (t *broadcastTracker) BlockDisconnected(block *provautil.Block) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	hash := block.Hash()
+	for _, c := range t.active {
+		if c.block != nil && *c.block == *hash {
+			c.block = nil
+			c.height = 0
+			c.unconfirmations++
+		}
+		if c.conflictBlock != nil && *c.conflictBlock == *hash {
+			c.conflict = nil
+			c.conflictBlock = nil
+			c.conflictHeight = 0
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestBroadcastTracker ensures broadcast campaigns follow the propagation,
+// confirmation and unconfirmation of their transaction, and become final once
+// the transaction or a conflicting one has the target confirmations.
+func TestBroadcastTracker(t *testing.T) {
+	var best uint32
+	var finished []*btcjson.BroadcastStatusResult
+	tracker := newBroadcastTracker(&broadcastTrackerConfig{
+		BestHeight: func() uint32 { return best },
+		Finished: func(status *btcjson.BroadcastStatusResult) {
+			finished = append(finished, status)
+		},
+	})
+
+	spending := func(prevHash byte, value int64) *provautil.Tx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(
+			&chainhash.Hash{prevHash}, 0), nil))
+		tx.AddTxOut(wire.NewTxOut(value, nil))
+		return provautil.NewTx(tx)
+	}
+	block := func(height uint32, txs ...*provautil.Tx) *provautil.Block {
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), []byte{byte(height)}))
+		msgBlock := &wire.MsgBlock{
+			Header:       wire.BlockHeader{Height: height},
+			Transactions: []*wire.MsgTx{coinbase},
+		}
+		for _, tx := range txs {
+			msgBlock.Transactions = append(msgBlock.Transactions,
+				tx.MsgTx())
+		}
+		b := provautil.NewBlock(msgBlock)
+		b.SetHeight(height)
+		best = height
+		return b
+	}
+	wantStatus := func(id uint64, status string, confs uint32) {
+		got := tracker.Status(id)
+		if got == nil || got.Status != status ||
+			got.Confirmations != confs {
+
+			t.Fatalf("Status: got %+v, want status %s with %d "+
+				"confirmations", got, status, confs)
+		}
+	}
+
+	tx := spending(0x01, 100)
+	id, err := tracker.Track(tx, 2)
+	if err != nil {
+		t.Fatalf("Track: unexpected error: %v", err)
+	}
+	if _, err := tracker.Track(tx, 1); err == nil {
+		t.Fatalf("Track: tracked transaction was tracked again")
+	}
+	wantStatus(id, broadcastPending, 0)
+	tracker.PeerRequested(tx.Hash(), 1)
+	tracker.PeerAnnounced([]*wire.InvVect{
+		wire.NewInvVect(wire.InvTypeTx, tx.Hash()),
+	}, 2)
+	wantStatus(id, broadcastPropagated, 0)
+
+	// The campaign survives the disconnection of the block confirming the
+	// transaction, and is final once it has the target confirmations.
+	block10 := block(10, tx)
+	tracker.BlockConnected(block10)
+	wantStatus(id, broadcastConfirming, 1)
+	tracker.BlockDisconnected(block10)
+	best = 9
+	wantStatus(id, broadcastPropagated, 0)
+	if status := tracker.Status(id); status.Unconfirmations != 1 {
+		t.Fatalf("Status: got %d unconfirmations, want 1",
+			status.Unconfirmations)
+	}
+	tracker.BlockConnected(block(10, tx))
+	tracker.BlockConnected(block(11))
+	wantStatus(id, broadcastConfirmed, 2)
+	if len(finished) != 1 || finished[0].ID != id || !finished[0].Final ||
+		finished[0].PeersRequested != 1 || finished[0].PeersAnnounced != 1 {
+
+		t.Fatalf("Finished: unexpected final statuses %+v", finished)
+	}
+
+	// A campaign is conflicted once a transaction spending one of its
+	// inputs has the target confirmations.
+	tx = spending(0x02, 100)
+	id, err = tracker.Track(tx, 1)
+	if err != nil {
+		t.Fatalf("Track: unexpected error: %v", err)
+	}
+	conflict := spending(0x02, 90)
+	tracker.BlockConnected(block(12, conflict))
+	wantStatus(id, broadcastConflicted, 0)
+	if status := tracker.Status(id); status.ConflictTxID !=
+		conflict.Hash().String() {
+
+		t.Fatalf("Status: got conflicting transaction %v, want %v",
+			status.ConflictTxID, conflict.Hash())
+	}
+	if len(finished) != 2 {
+		t.Fatalf("Finished: got %d final statuses, want 2",
+			len(finished))
+	}
+
+	// Removed campaigns are forgotten.
+	id, err = tracker.Track(spending(0x03, 100), 1)
+	if err != nil {
+		t.Fatalf("Track: unexpected error: %v", err)
+	}
+	tracker.Remove(id)
+	if status := tracker.Status(id); status != nil {
+		t.Fatalf("Status: removed campaign has status %+v", status)
+	}
+}
@@ -7,13 +7,10 @@ package main
 
 import (
 	"fmt"
-	"net"
-	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"runtime"
 	"runtime/debug"
-	"runtime/pprof"
+	"time"
 
 	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/limits"
@@ -51,35 +48,42 @@ func btcdMain(serverChan chan<- *server) error {
 	// Show version at startup.
 	btcdLog.Infof("Version %s", version())
 
-	// Enable http profiling server if requested.
+	// Enable http profiling server if requested.  It can also be started
+	// and stopped later with the setprofileserver RPC.
 	if cfg.Profile != "" {
-		go func() {
-			listenAddr := net.JoinHostPort("", cfg.Profile)
-			btcdLog.Infof("Profile server listening on %s", listenAddr)
-			profileRedirect := http.RedirectHandler("/debug/pprof",
-				http.StatusSeeOther)
-			http.Handle("/", profileRedirect)
-			btcdLog.Errorf("%v", http.ListenAndServe(listenAddr, nil))
-		}()
+		if _, err := profiler.StartServer(cfg.Profile); err != nil {
+			btcdLog.Errorf("Unable to start profile server: %v", err)
+			return err
+		}
 	}
+	defer profiler.StopServer()
 
-	// Write cpu profile if requested.
+	// Write cpu profile if requested.  The profile may also be started and
+	// stopped with the startcpuprofile and stopcpuprofile RPCs, so any
+	// profile still being written is finished on shutdown.
 	if cfg.CPUProfile != "" {
 		f, err := os.Create(cfg.CPUProfile)
 		if err != nil {
 			btcdLog.Errorf("Unable to create cpu profile: %v", err)
 			return err
 		}
-		pprof.StartCPUProfile(f)
-		defer f.Close()
-		defer pprof.StopCPUProfile()
+		if err := profiler.StartCPUProfile(f); err != nil {
+			btcdLog.Errorf("Unable to create cpu profile: %v", err)
+			return err
+		}
 	}
+	defer profiler.StopCPUProfile()
 
 	// Return now if an interrupt signal was triggered.
 	if interruptRequested(interruptedChan) {
 		return nil
 	}
 
+	// Run as a light client when requested.
+	if cfg.SPV {
+		return spvMain(interruptedChan)
+	}
+
 	// Load the block database.
 	db, err := loadBlockDB()
 	if err != nil {
@@ -87,9 +91,17 @@ func btcdMain(serverChan chan<- *server) error {
 		return err
 	}
 	defer func() {
-		// Ensure the database is sync'd and closed on shutdown.
+		// Ensure the database is sync'd and closed on shutdown.  Closing
+		// flushes the cached chain state, such as the utxo set, so it is
+		// waited for regardless of the shutdown deadline since giving up
+		// would leave the chain state to be recovered on the next start.
 		btcdLog.Infof("Gracefully shutting down the database...")
-		db.Close()
+		shutdownStage("the database", time.Time{}, func() {
+			if err := db.Close(); err != nil {
+				btcdLog.Errorf("Unable to close the database: %v",
+					err)
+			}
+		}, nil)
 	}()
 
 	// Return now if an interrupt signal was triggered.
@@ -117,6 +129,91 @@ func btcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropCfIndex {
+		if err := indexers.DropCfIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropSpentIndex {
+		if err := indexers.DropSpentIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropKeyIDBalIndex {
+		if err := indexers.DropKeyIDBalanceIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropSupplyIndex {
+		if err := indexers.DropSupplyIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTimestampIndex {
+		if err := indexers.DropTimestampIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropStatsIndex {
+		if err := indexers.DropStatsIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropUtxoCommitIndex {
+		if err := indexers.DropUtxoCommitIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+
+	// Rehearse the requested upgrade against the chain and exit if
+	// requested.
+	if len(cfg.rehearseDeployments) > 0 {
+		if err := rehearseUpgrade(db, interruptedChan); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+
+	// Rebuild the chain state from the stored blocks if requested.  A chain
+	// state left behind by an interrupted reindex can't be used otherwise.
+	if cfg.ReindexChainState {
+		if err := reindexChainState(db, interruptedChan); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+	}
+	if err := checkReindexPending(db); err != nil {
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+
+	// Return now if an interrupt signal was triggered.
+	if interruptRequested(interruptedChan) {
+		return nil
+	}
 
 	// Create server and start it.
 	server, err := newServer(cfg.Listeners, db, activeNetParams.Params)
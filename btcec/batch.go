@@ -0,0 +1,209 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// minBatchChunk is the minimum number of signatures verified by each goroutine
+// in a batch.  Smaller batches are not split since the overhead of spinning up
+// goroutines would outweigh the gains.
+const minBatchChunk = 16
+
+// batchEntry houses a single signature queued for batch verification.
+type batchEntry struct {
+	sig    *Signature
+	hash   []byte
+	pubKey *PublicKey
+}
+
+// BatchVerifier verifies a batch of ECDSA signatures together.  The result for
+// each signature is identical to that of Signature.Verify, however the batch is
+// verified considerably faster than verifying each signature on its own since
+// the modular inversions of the s values are aggregated into a single inversion
+// per chunk of signatures, the points are never converted back to affine
+// coordinates, and the chunks are verified concurrently.
+type BatchVerifier struct {
+	mtx     sync.Mutex
+	entries []batchEntry
+}
+
+// NewBatchVerifier returns a new empty batch verifier.
+func NewBatchVerifier() *BatchVerifier {
+	return &BatchVerifier{}
+}
+
+// Add queues the signature of hash using the public key for verification and
+// returns the index of the signature within the batch.
+//
+// This function is safe for concurrent access.
+func (b *BatchVerifier) Add(sig *Signature, hash []byte, pubKey *PublicKey) int {
+	b.mtx.Lock()
+	b.entries = append(b.entries, batchEntry{sig, hash, pubKey})
+	idx := len(b.entries) - 1
+	b.mtx.Unlock()
+	return idx
+}
+
+// Len returns the number of signatures queued for verification.
+//
+// This function is safe for concurrent access.
+func (b *BatchVerifier) Len() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.entries)
+}
+
+// Verify verifies all of the queued signatures and returns the indices of the
+// invalid ones in ascending order.  An empty result indicates every signature
+// in the batch is valid.
+//
+// This function is safe for concurrent access.
+func (b *BatchVerifier) Verify() []int {
+	b.mtx.Lock()
+	entries := b.entries
+	b.mtx.Unlock()
+
+	// Split the batch into chunks which are verified concurrently.
+	numWorkers := runtime.NumCPU()
+	if maxWorkers := (len(entries) + minBatchChunk - 1) / minBatchChunk; numWorkers > maxWorkers {
+		numWorkers = maxWorkers
+	}
+	invalid := make([]bool, len(entries))
+	if numWorkers <= 1 {
+		verifyBatchChunk(entries, invalid)
+		return invalidIndices(invalid)
+	}
+
+	chunkSize := (len(entries) + numWorkers - 1) / numWorkers
+	var wg sync.WaitGroup
+	for start := 0; start < len(entries); start += chunkSize {
+		end := start + chunkSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			verifyBatchChunk(entries[start:end], invalid[start:end])
+		}(start, end)
+	}
+	wg.Wait()
+
+	return invalidIndices(invalid)
+}
+
+// invalidIndices returns the indices of the passed flags which are set.
+func invalidIndices(invalid []bool) []int {
+	var indices []int
+	for i, bad := range invalid {
+		if bad {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// verifyBatchChunk verifies the passed signatures and flags the invalid ones.
+func verifyBatchChunk(entries []batchEntry, invalid []bool) {
+	curve := S256()
+	N := curve.N
+
+	// Reject signatures with values outside of [1, N-1] and collect the s
+	// values of the remaining ones so they can be inverted together.
+	inverses := make([]*big.Int, len(entries))
+	valid := make([]int, 0, len(entries))
+	for i := range entries {
+		sig := entries[i].sig
+		if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 ||
+			sig.R.Cmp(N) >= 0 || sig.S.Cmp(N) >= 0 {
+
+			invalid[i] = true
+			continue
+		}
+		valid = append(valid, i)
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	// Invert all of the s values with a single modular inversion using
+	// Montgomery's trick.  The prefix products are accumulated, the final
+	// product is inverted, and the individual inverses are then recovered
+	// by walking back through the prefix products.
+	prefix := make([]*big.Int, len(valid))
+	acc := big.NewInt(1)
+	for j, i := range valid {
+		acc = new(big.Int).Mul(acc, entries[i].sig.S)
+		acc.Mod(acc, N)
+		prefix[j] = acc
+	}
+	accInv := new(big.Int).ModInverse(acc, N)
+	for j := len(valid) - 1; j >= 0; j-- {
+		i := valid[j]
+		w := new(big.Int).Set(accInv)
+		if j > 0 {
+			w.Mul(w, prefix[j-1])
+			w.Mod(w, N)
+		}
+		inverses[i] = w
+		accInv.Mul(accInv, entries[i].sig.S)
+		accInv.Mod(accInv, N)
+	}
+
+	for _, i := range valid {
+		invalid[i] = !verifyJacobian(&entries[i], inverses[i])
+	}
+}
+
+// verifyJacobian verifies the signature in the passed entry given the inverse
+// of its s value modulo the curve order.  It follows the ECDSA verification
+// algorithm, but compares the x coordinate of the resulting point against r
+// while the point is still in Jacobian coordinates in order to avoid the field
+// inversion needed to convert it to affine coordinates.
+func verifyJacobian(entry *batchEntry, w *big.Int) bool {
+	curve := S256()
+	N := curve.N
+
+	// u1 = e * w mod N, u2 = r * w mod N
+	e := hashToInt(entry.hash, curve)
+	u1 := new(big.Int).Mul(e, w)
+	u1.Mod(u1, N)
+	u2 := new(big.Int).Mul(entry.sig.R, w)
+	u2.Mod(u2, N)
+
+	// X = u1*G + u2*Q
+	x1, y1, z1 := new(fieldVal), new(fieldVal), new(fieldVal)
+	curve.scalarBaseMultJacobian(u1.Bytes(), x1, y1, z1)
+	x2, y2, z2 := new(fieldVal), new(fieldVal), new(fieldVal)
+	curve.scalarMultJacobian(entry.pubKey.X, entry.pubKey.Y, u2.Bytes(),
+		x2, y2, z2)
+	curve.addJacobian(x1, y1, z1, x2, y2, z2, x1, y1, z1)
+
+	// The signature is invalid when X is the point at infinity.
+	if z1.Normalize().IsZero() {
+		return false
+	}
+
+	// The signature is valid when the affine x coordinate of X, x = X/Z^2,
+	// is congruent to r modulo N.  Since x < P < 2N, that is the case when
+	// either x = r or x = r + N, which are checked as X = r*Z^2 and
+	// X = (r+N)*Z^2 respectively.
+	zz := new(fieldVal).SquareVal(z1)
+	x1.Normalize()
+	candidate := new(big.Int).Set(entry.sig.R)
+	for candidate.Cmp(curve.P) < 0 {
+		rzz := new(fieldVal).SetByteSlice(candidate.Bytes())
+		rzz.Mul(zz).Normalize()
+		if x1.Equals(rzz) {
+			return true
+		}
+		candidate.Add(candidate, N)
+	}
+	return false
+}
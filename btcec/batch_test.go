@@ -0,0 +1,73 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// TestBatchVerifier ensures the batch verifier agrees with Signature.Verify for
+// valid signatures as well as a variety of invalid ones.
+func TestBatchVerifier(t *testing.T) {
+	// Batches both below and above the size which is split across
+	// goroutines are tested.
+	for _, numSigs := range []int{1, minBatchChunk - 1, minBatchChunk * 5} {
+		batch := NewBatchVerifier()
+		var wantInvalid []int
+		for i := 0; i < numSigs; i++ {
+			privKey, err := NewPrivateKey(S256())
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+			hash := sha256.Sum256([]byte(fmt.Sprintf("msg %d", i)))
+			sig, err := privKey.Sign(hash[:])
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			pubKey := privKey.PubKey()
+
+			// Corrupt some of the signatures in different ways.
+			switch i % 7 {
+			case 1:
+				hash[0] ^= 0x01
+			case 2:
+				sig.S = new(big.Int).Add(sig.S, big.NewInt(1))
+			case 3:
+				sig.R = new(big.Int)
+			case 4:
+				sig.S = new(big.Int).Set(S256().N)
+			case 5:
+				otherKey, err := NewPrivateKey(S256())
+				if err != nil {
+					t.Fatalf("failed to generate key: %v", err)
+				}
+				pubKey = otherKey.PubKey()
+			case 6:
+				// A high s value is still a valid signature.
+				sig.S = new(big.Int).Sub(S256().N, sig.S)
+			}
+
+			if !sig.Verify(hash[:], pubKey) {
+				wantInvalid = append(wantInvalid, i)
+			}
+			if idx := batch.Add(sig, hash[:], pubKey); idx != i {
+				t.Fatalf("Add: unexpected index %d, want %d", idx, i)
+			}
+		}
+
+		if batch.Len() != numSigs {
+			t.Fatalf("Len: got %d, want %d", batch.Len(), numSigs)
+		}
+		invalid := batch.Verify()
+		if !reflect.DeepEqual(invalid, wantInvalid) {
+			t.Errorf("Verify (%d signatures): got invalid %v, want %v",
+				numSigs, invalid, wantInvalid)
+		}
+	}
+}
@@ -112,3 +112,32 @@ func BenchmarkSigVerify(b *testing.B) {
 		sig.Verify(msgHash.Bytes(), &pubKey)
 	}
 }
+
+// BenchmarkBatchVerify benchmarks how long it takes the secp256k1 curve to
+// verify signatures in batches.
+func BenchmarkBatchVerify(b *testing.B) {
+	b.StopTimer()
+	const batchSize = 256
+	batch := NewBatchVerifier()
+	for i := 0; i < batchSize; i++ {
+		privKey, err := NewPrivateKey(S256())
+		if err != nil {
+			b.Fatalf("failed to generate key: %v", err)
+		}
+		msgHash := fromHex("8de472e2399610baaa7f84840547cd409434e31f5d3bd71e4d947f283874f9c0")
+		sig, err := privKey.Sign(msgHash.Bytes())
+		if err != nil {
+			b.Fatalf("failed to sign: %v", err)
+		}
+		batch.Add(sig, msgHash.Bytes(), privKey.PubKey())
+	}
+	if invalid := batch.Verify(); len(invalid) != 0 {
+		b.Errorf("Signatures %v failed to verify", invalid)
+		return
+	}
+	b.StartTimer()
+
+	for i := 0; i < b.N; i += batchSize {
+		batch.Verify()
+	}
+}
@@ -758,6 +758,18 @@ func NAF(k []byte) ([]byte, []byte) {
 func (curve *KoblitzCurve) ScalarMult(Bx, By *big.Int, k []byte) (*big.Int, *big.Int) {
 	// Point Q = ∞ (point at infinity).
 	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+	curve.scalarMultJacobian(Bx, By, k, qx, qy, qz)
+
+	// Convert the Jacobian coordinate field values back to affine big.Ints.
+	return curve.fieldJacobianToBigAffine(qx, qy, qz)
+}
+
+// scalarMultJacobian calculates k*(Bx, By) where k is a big endian integer and
+// stores the result in Jacobian coordinates in (qx, qy, qz), which must be the
+// point at infinity on entry.  Leaving the result in Jacobian coordinates
+// avoids the costly field inversion needed to convert it to affine coordinates
+// when the caller does not require them.
+func (curve *KoblitzCurve) scalarMultJacobian(Bx, By *big.Int, k []byte, qx, qy, qz *fieldVal) {
 
 	// Decompose K into k1 and k2 in order to halve the number of EC ops.
 	// See Algorithm 3.74 in [GECC].
@@ -852,20 +864,24 @@ func (curve *KoblitzCurve) ScalarMult(Bx, By *big.Int, k []byte) (*big.Int, *big
 			k2ByteNeg <<= 1
 		}
 	}
-
-	// Convert the Jacobian coordinate field values back to affine big.Ints.
-	return curve.fieldJacobianToBigAffine(qx, qy, qz)
 }
 
 // ScalarBaseMult returns k*G where G is the base point of the group and k is a
 // big endian integer.
 // Part of the elliptic.Curve interface.
 func (curve *KoblitzCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
-	newK := curve.moduloReduce(k)
-	diff := len(curve.bytePoints) - len(newK)
-
 	// Point Q = ∞ (point at infinity).
 	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+	curve.scalarBaseMultJacobian(k, qx, qy, qz)
+	return curve.fieldJacobianToBigAffine(qx, qy, qz)
+}
+
+// scalarBaseMultJacobian calculates k*G where G is the base point of the group
+// and k is a big endian integer and stores the result in Jacobian coordinates
+// in (qx, qy, qz), which must be the point at infinity on entry.
+func (curve *KoblitzCurve) scalarBaseMultJacobian(k []byte, qx, qy, qz *fieldVal) {
+	newK := curve.moduloReduce(k)
+	diff := len(curve.bytePoints) - len(newK)
 
 	// curve.bytePoints has all 256 byte points for each 8-bit window. The
 	// strategy is to add up the byte points. This is best understood by
@@ -876,7 +892,6 @@ func (curve *KoblitzCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
 		p := curve.bytePoints[diff+i][byteVal]
 		curve.addJacobian(qx, qy, qz, &p[0], &p[1], &p[2], qx, qy, qz)
 	}
-	return curve.fieldJacobianToBigAffine(qx, qy, qz)
 }
 
 // QPlus1Div4 returns the Q+1/4 constant for the curve for use in calculating
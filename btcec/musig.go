@@ -0,0 +1,176 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// muSigKeysTag and muSigCoefTag separate the domains of the hashes used to
+// aggregate keys.
+var (
+	muSigKeysTag = []byte("Prova/MuSigKeys")
+	muSigCoefTag = []byte("Prova/MuSigCoefficient")
+)
+
+// ErrNoKeys describes an error where keys are aggregated without any keys.
+var ErrNoKeys = errors.New("no keys to aggregate")
+
+// sortedKeys returns the compressed encodings of the passed keys in ascending
+// order, so the aggregate of a set of keys doesn't depend on their order.
+func sortedKeys(pubKeys []*PublicKey) [][]byte {
+	keys := make([][]byte, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		keys[i] = pubKey.SerializeCompressed()
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return keys
+}
+
+// keyAggCoefficients returns the coefficient of each of the passed sorted keys
+// in their aggregate.  Each coefficient commits to the full set of keys, which
+// prevents a signer from choosing its key so as to cancel out the keys of the
+// other signers.
+func keyAggCoefficients(keys [][]byte) []*big.Int {
+	keySetHash := taggedHash(muSigKeysTag, keys...)
+	coefs := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		a := new(big.Int).SetBytes(taggedHash(muSigCoefTag, keySetHash, key))
+		coefs[i] = a.Mod(a, S256().N)
+	}
+	return coefs
+}
+
+// AggregatePubKeys returns the MuSig aggregate of the passed public keys, that
+// is the sum of the keys each weighted by a coefficient which commits to the
+// whole set of keys.  A Schnorr signature for the aggregate key can only be
+// produced by all of the signers together, using MuSigPartialSign and
+// CombineMuSigSigs, and is indistinguishable from the signature of a single
+// key.  The order of the keys does not matter.
+func AggregatePubKeys(pubKeys []*PublicKey) (*PublicKey, error) {
+	if len(pubKeys) == 0 {
+		return nil, ErrNoKeys
+	}
+	curve := S256()
+	keys := sortedKeys(pubKeys)
+	coefs := keyAggCoefficients(keys)
+
+	var x, y *big.Int
+	for i, key := range keys {
+		pubKey, err := ParsePubKey(key, curve)
+		if err != nil {
+			return nil, err
+		}
+		ax, ay := curve.ScalarMult(pubKey.X, pubKey.Y, coefs[i].Bytes())
+		if x == nil {
+			x, y = ax, ay
+			continue
+		}
+		x, y = curve.Add(x, y, ax, ay)
+	}
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("aggregate key is the point at infinity")
+	}
+	return &PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// AggregatePubKeyPairs returns the aggregate key of every pair of the passed
+// keys, in the order (0, 1), (0, 2), ..., (1, 2), ....  For the keys of a 2-of-3
+// Prova output, the three aggregates cover every pair of signers able to spend
+// it, so a spend by any two of them can be authorized by a single Schnorr
+// signature for the aggregate of their keys.
+func AggregatePubKeyPairs(pubKeys []*PublicKey) ([]*PublicKey, error) {
+	var aggKeys []*PublicKey
+	for i := 0; i < len(pubKeys); i++ {
+		for j := i + 1; j < len(pubKeys); j++ {
+			aggKey, err := AggregatePubKeys([]*PublicKey{pubKeys[i],
+				pubKeys[j]})
+			if err != nil {
+				return nil, err
+			}
+			aggKeys = append(aggKeys, aggKey)
+		}
+	}
+	return aggKeys, nil
+}
+
+// AggregateNonces returns the sum of the public nonces of the signers of a
+// MuSig signature.
+func AggregateNonces(nonces []*PublicKey) (*PublicKey, error) {
+	if len(nonces) == 0 {
+		return nil, ErrNoKeys
+	}
+	curve := S256()
+	x, y := nonces[0].X, nonces[0].Y
+	for _, nonce := range nonces[1:] {
+		x, y = curve.Add(x, y, nonce.X, nonce.Y)
+	}
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("aggregate nonce is the point at infinity")
+	}
+	return &PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// MuSigPartialSign returns the partial signature of the passed hash by the
+// private key for the aggregate of the passed public keys, which must include
+// the public key of the private key.  The secret nonce must be freshly
+// generated, such as with NewPrivateKey, for every signature, and aggNonce is
+// the aggregate of the public nonces of all signers.  Reusing a secret nonce
+// reveals the private key.
+//
+// The public nonces must be exchanged before the partial signatures are made,
+// and each signer should commit to its nonce before learning the nonces of the
+// other signers.
+func MuSigPartialSign(privKey, secNonce *PrivateKey, pubKeys []*PublicKey, aggNonce *PublicKey, hash []byte) (*big.Int, error) {
+	curve := S256()
+	aggKey, err := AggregatePubKeys(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(pubKeys)
+	coefs := keyAggCoefficients(keys)
+	ownKey := privKey.PubKey().SerializeCompressed()
+	var coef *big.Int
+	for i, key := range keys {
+		if bytes.Equal(key, ownKey) {
+			coef = coefs[i]
+			break
+		}
+	}
+	if coef == nil {
+		return nil, errors.New("private key is not one of the aggregated " +
+			"keys")
+	}
+
+	// The signers negate their nonces when the aggregate nonce has an odd y
+	// coordinate, since the signature implies the even one.
+	k := new(big.Int).Set(secNonce.D)
+	if isOdd(aggNonce.Y) {
+		k.Sub(curve.N, k)
+	}
+
+	// s_i = k_i + e*a_i*x_i mod N
+	e := schnorrChallenge(aggNonce.X, aggKey, hash)
+	s := new(big.Int).Mul(e, coef)
+	s.Mul(s, privKey.D)
+	s.Add(s, k)
+	return s.Mod(s, curve.N), nil
+}
+
+// CombineMuSigSigs combines the partial signatures of all signers made with
+// the passed aggregate nonce into a Schnorr signature for their aggregate key.
+func CombineMuSigSigs(aggNonce *PublicKey, partialSigs []*big.Int) *SchnorrSignature {
+	s := new(big.Int)
+	for _, partialSig := range partialSigs {
+		s.Add(s, partialSig)
+	}
+	s.Mod(s, S256().N)
+	return &SchnorrSignature{R: new(big.Int).Set(aggNonce.X), S: s}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// SchnorrSigLen is the length in bytes of a serialized Schnorr signature.
+const SchnorrSigLen = 64
+
+var (
+	// schnorrChallengeTag and schnorrNonceTag separate the domains of the
+	// hashes used for Schnorr signatures from any other use of the hash
+	// function.  The nonce in particular must never coincide with the
+	// RFC6979 nonce of an ECDSA signature of the same hash by the same key,
+	// or the private key could be recovered from the two signatures.
+	schnorrChallengeTag = []byte("Prova/SchnorrChallenge")
+	schnorrNonceTag     = []byte("Prova/SchnorrNonce")
+)
+
+// SchnorrSignature is a type representing a Schnorr signature.  R is the x
+// coordinate of the nonce point, which always has an even y coordinate, so the
+// point is implied by it.
+//
+// A signature (R, S) of a hash by the key P is valid when S*G = R + e*P, where
+// e is the hash of R, the compressed encoding of P and the signed hash.
+// Unlike ECDSA signatures, Schnorr signatures are linear in the keys, which
+// allows signatures to be verified in batches and the keys of several signers
+// to be aggregated into a single key.
+type SchnorrSignature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// taggedHash returns the SHA-256 hash of the passed data under the passed tag,
+// that is SHA256(SHA256(tag) || SHA256(tag) || data...).
+func taggedHash(tag []byte, data ...[]byte) []byte {
+	tagHash := sha256.Sum256(tag)
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// schnorrChallenge returns the challenge e committing to the x coordinate of
+// the nonce point, the public key and the signed hash.
+func schnorrChallenge(r *big.Int, pubKey *PublicKey, hash []byte) *big.Int {
+	rBytes := paddedAppend(32, nil, r.Bytes())
+	e := new(big.Int).SetBytes(taggedHash(schnorrChallengeTag, rBytes,
+		pubKey.SerializeCompressed(), hash))
+	return e.Mod(e, S256().N)
+}
+
+// liftX returns the point with the passed x coordinate and an even y
+// coordinate, or an error when there is no such point on the curve.
+func liftX(x *big.Int) (*big.Int, error) {
+	curve := S256()
+	if x.Sign() < 0 || x.Cmp(curve.P) >= 0 {
+		return nil, errors.New("x coordinate out of range")
+	}
+	y, err := decompressPoint(curve, x, false)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("x coordinate is not on the curve")
+	}
+	return y, nil
+}
+
+// Serialize returns the signature as the 32-byte big-endian R followed by the
+// 32-byte big-endian S.
+func (sig *SchnorrSignature) Serialize() []byte {
+	b := make([]byte, 0, SchnorrSigLen)
+	b = paddedAppend(32, b, sig.R.Bytes())
+	return paddedAppend(32, b, sig.S.Bytes())
+}
+
+// Verify verifies the Schnorr signature of hash using the public key.  It
+// returns true if the signature is valid, false otherwise.
+func (sig *SchnorrSignature) Verify(hash []byte, pubKey *PublicKey) bool {
+	curve := S256()
+	if sig.R.Sign() < 0 || sig.R.Cmp(curve.P) >= 0 ||
+		sig.S.Sign() < 0 || sig.S.Cmp(curve.N) >= 0 {
+
+		return false
+	}
+
+	// R' = s*G - e*P, which must be the nonce point.
+	e := schnorrChallenge(sig.R, pubKey, hash)
+	negE := new(big.Int).Sub(curve.N, e)
+	sx, sy := curve.ScalarBaseMult(sig.S.Bytes())
+	ex, ey := curve.ScalarMult(pubKey.X, pubKey.Y, negE.Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return !isOdd(ry) && rx.Cmp(sig.R) == 0
+}
+
+// IsEqual compares this SchnorrSignature instance to the one passed, returning
+// true if both signatures are equivalent.
+func (sig *SchnorrSignature) IsEqual(otherSig *SchnorrSignature) bool {
+	return sig.R.Cmp(otherSig.R) == 0 && sig.S.Cmp(otherSig.S) == 0
+}
+
+// ParseSchnorrSignature parses a serialized Schnorr signature and ensures its
+// values are in range.  The validity of the signature is only established by
+// verifying it.
+func ParseSchnorrSignature(sigStr []byte) (*SchnorrSignature, error) {
+	if len(sigStr) != SchnorrSigLen {
+		return nil, errors.New("malformed schnorr signature: wrong size")
+	}
+	curve := S256()
+	sig := &SchnorrSignature{
+		R: new(big.Int).SetBytes(sigStr[:32]),
+		S: new(big.Int).SetBytes(sigStr[32:]),
+	}
+	if sig.R.Cmp(curve.P) >= 0 {
+		return nil, errors.New("schnorr signature R is >= curve.P")
+	}
+	if sig.S.Cmp(curve.N) >= 0 {
+		return nil, errors.New("schnorr signature S is >= curve.N")
+	}
+	return sig, nil
+}
+
+// schnorrSign signs the hash with the private key using the passed nonce and
+// returns the signature.  The nonce is negated when its point has an odd y
+// coordinate.
+func schnorrSign(privKey *PrivateKey, pubKey *PublicKey, k *big.Int, hash []byte) (*SchnorrSignature, error) {
+	curve := S256()
+	if k.Sign() == 0 {
+		return nil, errors.New("schnorr nonce is zero")
+	}
+	rx, ry := curve.ScalarBaseMult(k.Bytes())
+	if isOdd(ry) {
+		k = new(big.Int).Sub(curve.N, k)
+	}
+
+	// s = k + e*d mod N
+	e := schnorrChallenge(rx, pubKey, hash)
+	s := new(big.Int).Mul(e, privKey.D)
+	s.Add(s, k)
+	s.Mod(s, curve.N)
+	return &SchnorrSignature{R: rx, S: s}, nil
+}
+
+// SignSchnorr generates a Schnorr signature for the passed hash using the
+// private key.  The nonce is derived deterministically from the private key
+// and the hash.
+func (p *PrivateKey) SignSchnorr(hash []byte) (*SchnorrSignature, error) {
+	k := new(big.Int).SetBytes(taggedHash(schnorrNonceTag, p.Serialize(),
+		hash))
+	k.Mod(k, S256().N)
+	return schnorrSign(p, p.PubKey(), k, hash)
+}
+
+// schnorrBatchEntry houses a single Schnorr signature queued for batch
+// verification.
+type schnorrBatchEntry struct {
+	sig    *SchnorrSignature
+	hash   []byte
+	pubKey *PublicKey
+}
+
+// SchnorrBatchVerifier verifies a batch of Schnorr signatures together.  The
+// batch is valid when a random linear combination of the verification
+// equations of its signatures holds, which takes a single comparison of two
+// points instead of one per signature.  When the batch fails, the signatures
+// are verified individually to identify the invalid ones.
+type SchnorrBatchVerifier struct {
+	entries []schnorrBatchEntry
+}
+
+// NewSchnorrBatchVerifier returns a new empty Schnorr batch verifier.
+func NewSchnorrBatchVerifier() *SchnorrBatchVerifier {
+	return &SchnorrBatchVerifier{}
+}
+
+// Add queues the signature of hash using the public key for verification and
+// returns the index of the signature within the batch.
+func (b *SchnorrBatchVerifier) Add(sig *SchnorrSignature, hash []byte, pubKey *PublicKey) int {
+	b.entries = append(b.entries, schnorrBatchEntry{sig, hash, pubKey})
+	return len(b.entries) - 1
+}
+
+// Len returns the number of signatures queued for verification.
+func (b *SchnorrBatchVerifier) Len() int {
+	return len(b.entries)
+}
+
+// Verify verifies all of the queued signatures and returns the indices of the
+// invalid ones in ascending order.  An empty result indicates every signature
+// in the batch is valid.
+func (b *SchnorrBatchVerifier) Verify() []int {
+	if len(b.entries) == 0 || b.verifyCombined() {
+		return nil
+	}
+	invalid := make([]bool, len(b.entries))
+	for i := range b.entries {
+		entry := &b.entries[i]
+		invalid[i] = !entry.sig.Verify(entry.hash, entry.pubKey)
+	}
+	return invalidIndices(invalid)
+}
+
+// verifyCombined returns whether the random linear combination of the
+// verification equations of the queued signatures holds, that is
+// (sum a_i*s_i)*G = sum a_i*R_i + sum (a_i*e_i)*P_i for random a_i.  The first
+// coefficient is fixed to one.
+func (b *SchnorrBatchVerifier) verifyCombined() bool {
+	curve := S256()
+	N := curve.N
+
+	sumS := new(big.Int)
+	x, y, z := new(fieldVal), new(fieldVal), new(fieldVal)
+	var coef [16]byte
+	for i := range b.entries {
+		entry := &b.entries[i]
+		sig := entry.sig
+		if sig.R.Sign() < 0 || sig.R.Cmp(curve.P) >= 0 ||
+			sig.S.Sign() < 0 || sig.S.Cmp(N) >= 0 {
+
+			return false
+		}
+		ry, err := liftX(sig.R)
+		if err != nil {
+			return false
+		}
+
+		a := big.NewInt(1)
+		if i > 0 {
+			if _, err := rand.Read(coef[:]); err != nil {
+				return false
+			}
+			a.SetBytes(coef[:])
+		}
+		sumS.Add(sumS, new(big.Int).Mul(a, sig.S))
+
+		// Accumulate a*R + (a*e)*P.
+		ae := schnorrChallenge(sig.R, entry.pubKey, entry.hash)
+		ae.Mul(ae, a)
+		ae.Mod(ae, N)
+		px, py, pz := new(fieldVal), new(fieldVal), new(fieldVal)
+		curve.scalarMultJacobian(sig.R, ry, a.Bytes(), px, py, pz)
+		curve.addJacobian(x, y, z, px, py, pz, x, y, z)
+		px, py, pz = new(fieldVal), new(fieldVal), new(fieldVal)
+		curve.scalarMultJacobian(entry.pubKey.X, entry.pubKey.Y,
+			ae.Bytes(), px, py, pz)
+		curve.addJacobian(x, y, z, px, py, pz, x, y, z)
+	}
+	sumS.Mod(sumS, N)
+
+	lx, ly := curve.ScalarBaseMult(sumS.Bytes())
+	rx, ry := curve.fieldJacobianToBigAffine(x, y, z)
+	return lx.Cmp(rx) == 0 && ly.Cmp(ry) == 0
+}
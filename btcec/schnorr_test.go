@@ -0,0 +1,157 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// TestSchnorr ensures Schnorr signatures verify for the signing key and hash,
+// survive a serialization round trip, and fail for any other key or hash.
+func TestSchnorr(t *testing.T) {
+	privKey, _ := NewPrivateKey(S256())
+	otherKey, _ := NewPrivateKey(S256())
+	hash := sha256.Sum256([]byte("schnorr"))
+	otherHash := sha256.Sum256([]byte("other"))
+
+	sig, err := privKey.SignSchnorr(hash[:])
+	if err != nil {
+		t.Fatalf("SignSchnorr: unexpected error: %v", err)
+	}
+	if !sig.Verify(hash[:], privKey.PubKey()) {
+		t.Fatal("Verify: valid signature rejected")
+	}
+	if sig.Verify(otherHash[:], privKey.PubKey()) {
+		t.Fatal("Verify: signature accepted for other hash")
+	}
+	if sig.Verify(hash[:], otherKey.PubKey()) {
+		t.Fatal("Verify: signature accepted for other key")
+	}
+
+	serialized := sig.Serialize()
+	if len(serialized) != SchnorrSigLen {
+		t.Fatalf("Serialize: got %d bytes, want %d", len(serialized),
+			SchnorrSigLen)
+	}
+	parsed, err := ParseSchnorrSignature(serialized)
+	if err != nil {
+		t.Fatalf("ParseSchnorrSignature: unexpected error: %v", err)
+	}
+	if !parsed.IsEqual(sig) {
+		t.Fatalf("ParseSchnorrSignature: got %v, want %v", parsed, sig)
+	}
+	if _, err := ParseSchnorrSignature(serialized[1:]); err == nil {
+		t.Fatal("ParseSchnorrSignature: did not fail on short signature")
+	}
+
+	// The ECDSA and Schnorr signatures of the same hash must not share a
+	// nonce.
+	ecdsaSig, _ := privKey.Sign(hash[:])
+	if ecdsaSig.R.Cmp(sig.R) == 0 {
+		t.Fatal("SignSchnorr: nonce shared with ECDSA signature")
+	}
+}
+
+// TestSchnorrBatchVerifier ensures the Schnorr batch verifier agrees with
+// SchnorrSignature.Verify.
+func TestSchnorrBatchVerifier(t *testing.T) {
+	batch := NewSchnorrBatchVerifier()
+	var wantInvalid []int
+	for i := 0; i < 8; i++ {
+		privKey, _ := NewPrivateKey(S256())
+		hash := sha256.Sum256([]byte(fmt.Sprintf("msg %d", i)))
+		sig, err := privKey.SignSchnorr(hash[:])
+		if err != nil {
+			t.Fatalf("SignSchnorr: unexpected error: %v", err)
+		}
+		if i%3 == 1 {
+			sig.S = new(big.Int).Add(sig.S, big.NewInt(1))
+			wantInvalid = append(wantInvalid, i)
+		}
+		batch.Add(sig, hash[:], privKey.PubKey())
+	}
+	if got := batch.Verify(); !reflect.DeepEqual(got, wantInvalid) {
+		t.Fatalf("Verify: got invalid %v, want %v", got, wantInvalid)
+	}
+
+	// A batch of valid signatures passes the combined check.
+	batch = NewSchnorrBatchVerifier()
+	for i := 0; i < 4; i++ {
+		privKey, _ := NewPrivateKey(S256())
+		hash := sha256.Sum256([]byte(fmt.Sprintf("msg %d", i)))
+		sig, _ := privKey.SignSchnorr(hash[:])
+		batch.Add(sig, hash[:], privKey.PubKey())
+	}
+	if !batch.verifyCombined() {
+		t.Fatal("verifyCombined: valid batch rejected")
+	}
+}
+
+// TestMuSig ensures two of the three keys of a 2-of-3 scheme can jointly sign
+// for the aggregate of their keys, regardless of the order of the keys.
+func TestMuSig(t *testing.T) {
+	var privKeys []*PrivateKey
+	var pubKeys []*PublicKey
+	for i := 0; i < 3; i++ {
+		privKey, _ := NewPrivateKey(S256())
+		privKeys = append(privKeys, privKey)
+		pubKeys = append(pubKeys, privKey.PubKey())
+	}
+	aggKeys, err := AggregatePubKeyPairs(pubKeys)
+	if err != nil {
+		t.Fatalf("AggregatePubKeyPairs: unexpected error: %v", err)
+	}
+	if len(aggKeys) != 3 {
+		t.Fatalf("AggregatePubKeyPairs: got %d keys, want 3", len(aggKeys))
+	}
+	reversed, err := AggregatePubKeys([]*PublicKey{pubKeys[2], pubKeys[0]})
+	if err != nil {
+		t.Fatalf("AggregatePubKeys: unexpected error: %v", err)
+	}
+	if !reversed.IsEqual(aggKeys[1]) {
+		t.Fatal("AggregatePubKeys: aggregate depends on key order")
+	}
+
+	// Keys 0 and 2 sign together.
+	hash := sha256.Sum256([]byte("musig"))
+	signers := []*PrivateKey{privKeys[0], privKeys[2]}
+	signerKeys := []*PublicKey{pubKeys[0], pubKeys[2]}
+	var secNonces []*PrivateKey
+	var pubNonces []*PublicKey
+	for range signers {
+		nonce, _ := NewPrivateKey(S256())
+		secNonces = append(secNonces, nonce)
+		pubNonces = append(pubNonces, nonce.PubKey())
+	}
+	aggNonce, err := AggregateNonces(pubNonces)
+	if err != nil {
+		t.Fatalf("AggregateNonces: unexpected error: %v", err)
+	}
+	var partialSigs []*big.Int
+	for i, signer := range signers {
+		s, err := MuSigPartialSign(signer, secNonces[i], signerKeys,
+			aggNonce, hash[:])
+		if err != nil {
+			t.Fatalf("MuSigPartialSign: unexpected error: %v", err)
+		}
+		partialSigs = append(partialSigs, s)
+	}
+	sig := CombineMuSigSigs(aggNonce, partialSigs)
+	if !sig.Verify(hash[:], aggKeys[1]) {
+		t.Fatal("Verify: combined signature rejected")
+	}
+	if sig.Verify(hash[:], aggKeys[0]) {
+		t.Fatal("Verify: combined signature accepted for other pair")
+	}
+
+	if _, err := MuSigPartialSign(privKeys[1], secNonces[0], signerKeys,
+		aggNonce, hash[:]); err == nil {
+		t.Fatal("MuSigPartialSign: did not fail for key outside the set")
+	}
+}
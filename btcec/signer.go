@@ -0,0 +1,21 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+// Signer is implemented by holders of a private key which produce ECDSA
+// signatures without necessarily exposing the key itself, such as hardware
+// security modules and remote signing services.  PrivateKey is the in-memory
+// implementation.
+type Signer interface {
+	// PubKey returns the public key of the private key held by the signer.
+	PubKey() *PublicKey
+
+	// Sign returns the signature of the passed hash by the private key
+	// held by the signer.
+	Sign(hash []byte) (*Signature, error)
+}
+
+// Enforce PrivateKey implements the Signer interface.
+var _ Signer = (*PrivateKey)(nil)
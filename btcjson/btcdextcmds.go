@@ -72,6 +72,44 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+func NewGenerateToAddressCmd(numBlocks uint32, address string) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+	}
+}
+
+// GenerateWithValidateKeyCmd defines the generatewithvalidatekey JSON-RPC
+// command.
+type GenerateWithValidateKeyCmd struct {
+	NumBlocks   uint32
+	ValidateKey string
+	Address     *string
+}
+
+// NewGenerateWithValidateKeyCmd returns a new instance which can be used to
+// issue a generatewithvalidatekey JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateWithValidateKeyCmd(numBlocks uint32, validateKey string,
+	address *string) *GenerateWithValidateKeyCmd {
+
+	return &GenerateWithValidateKeyCmd{
+		NumBlocks:   numBlocks,
+		ValidateKey: validateKey,
+		Address:     address,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -90,6 +128,16 @@ func NewGetCurrentNetCmd() *GetCurrentNetCmd {
 	return &GetCurrentNetCmd{}
 }
 
+// GetLogLevelsCmd defines the getloglevels JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for Prova.
+type GetLogLevelsCmd struct{}
+
+// NewGetLogLevelsCmd returns a new instance which can be used to issue a
+// getloglevels JSON-RPC command.
+func NewGetLogLevelsCmd() *GetLogLevelsCmd {
+	return &GetLogLevelsCmd{}
+}
+
 // GetHeadersCmd defines the getheaders JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -111,6 +159,19 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// SetMockTimeCmd defines the setmocktime JSON-RPC command.
+type SetMockTimeCmd struct {
+	Timestamp int64
+}
+
+// NewSetMockTimeCmd returns a new instance which can be used to issue a
+// setmocktime JSON-RPC command.
+func NewSetMockTimeCmd(timestamp int64) *SetMockTimeCmd {
+	return &SetMockTimeCmd{
+		Timestamp: timestamp,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
@@ -118,7 +179,11 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
+	MustRegisterCmd("generatewithvalidatekey", (*GenerateWithValidateKeyCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("getloglevels", (*GetLogLevelsCmd)(nil), flags)
+	MustRegisterCmd("setmocktime", (*SetMockTimeCmd)(nil), flags)
 }
@@ -43,6 +43,17 @@ func TestBtcdExtCmds(t *testing.T) {
 				LevelSpec: "trace",
 			},
 		},
+		{
+			name: "getloglevels",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getloglevels")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetLogLevelsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getloglevels","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetLogLevelsCmd{},
+		},
 		{
 			name: "node",
 			newCmd: func() (interface{}, error) {
@@ -114,6 +125,49 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 2, "TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(2, "TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[2,"TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 2,
+				Address:   "TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm",
+			},
+		},
+		{
+			name: "generatewithvalidatekey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatewithvalidatekey", 1, "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateWithValidateKeyCmd(1, "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatewithvalidatekey","params":[1,"035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3"],"id":1}`,
+			unmarshalled: &btcjson.GenerateWithValidateKeyCmd{
+				NumBlocks:   1,
+				ValidateKey: "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3",
+			},
+		},
+		{
+			name: "generatewithvalidatekey optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatewithvalidatekey", 1, "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3", "TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateWithValidateKeyCmd(1, "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3", btcjson.String("TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatewithvalidatekey","params":[1,"035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3","TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm"],"id":1}`,
+			unmarshalled: &btcjson.GenerateWithValidateKeyCmd{
+				NumBlocks:   1,
+				ValidateKey: "035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3",
+				Address:     btcjson.String("TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm"),
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -176,6 +230,19 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "setmocktime",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setmocktime", 1500000000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetMockTimeCmd(1500000000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setmocktime","params":[1500000000],"id":1}`,
+			unmarshalled: &btcjson.SetMockTimeCmd{
+				Timestamp: 1500000000,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
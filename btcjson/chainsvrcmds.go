@@ -45,6 +45,33 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// BroadcastTransactionCmd defines the broadcasttransaction JSON-RPC command.
+type BroadcastTransactionCmd struct {
+	HexTx       string
+	TargetConfs *uint32 `jsonrpcdefault:"1"`
+}
+
+// NewBroadcastTransactionCmd returns a new instance which can be used to issue
+// a broadcasttransaction JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewBroadcastTransactionCmd(hexTx string, targetConfs *uint32) *BroadcastTransactionCmd {
+	return &BroadcastTransactionCmd{
+		HexTx:       hexTx,
+		TargetConfs: targetConfs,
+	}
+}
+
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -52,6 +79,132 @@ type TransactionInput struct {
 	Vout uint32 `json:"vout"`
 }
 
+// AdminKeyOp describes an operation on a key of an admin key set.  The keyID
+// is only used for ASP keys.
+type AdminKeyOp struct {
+	KeySet string  `json:"keyset"`
+	PubKey string  `json:"pubkey"`
+	KeyID  *uint32 `json:"keyid,omitempty"`
+}
+
+// CombinePSPTCmd defines the combinepspt JSON-RPC command.
+type CombinePSPTCmd struct {
+	PSPTs []string
+}
+
+// NewCombinePSPTCmd returns a new instance which can be used to issue a
+// combinepspt JSON-RPC command.
+func NewCombinePSPTCmd(pspts []string) *CombinePSPTCmd {
+	return &CombinePSPTCmd{
+		PSPTs: pspts,
+	}
+}
+
+// CreateConsolidateTxCmd defines the createconsolidatetx JSON-RPC command.
+type CreateConsolidateTxCmd struct {
+	Addresses   []string
+	Threshold   float64 // In RMG
+	Destination string
+	FeeRate     *float64 // In RMG/kB
+	MaxInputs   *int     `jsonrpcdefault:"100"`
+	MinConf     *int32   `jsonrpcdefault:"1"`
+}
+
+// NewCreateConsolidateTxCmd returns a new instance which can be used to issue
+// a createconsolidatetx JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCreateConsolidateTxCmd(addresses []string, threshold float64,
+	destination string, feeRate *float64, maxInputs *int,
+	minConf *int32) *CreateConsolidateTxCmd {
+
+	return &CreateConsolidateTxCmd{
+		Addresses:   addresses,
+		Threshold:   threshold,
+		Destination: destination,
+		FeeRate:     feeRate,
+		MaxInputs:   maxInputs,
+		MinConf:     minConf,
+	}
+}
+
+// CreateDestroyTxCmd defines the createdestroytx JSON-RPC command.
+type CreateDestroyTxCmd struct {
+	Inputs        []TransactionInput
+	Amount        float64 // In RMG
+	ChangeAddress *string
+}
+
+// NewCreateDestroyTxCmd returns a new instance which can be used to issue a
+// createdestroytx JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCreateDestroyTxCmd(inputs []TransactionInput, amount float64,
+	changeAddress *string) *CreateDestroyTxCmd {
+
+	return &CreateDestroyTxCmd{
+		Inputs:        inputs,
+		Amount:        amount,
+		ChangeAddress: changeAddress,
+	}
+}
+
+// CreateIssueTxCmd defines the createissuetx JSON-RPC command.
+type CreateIssueTxCmd struct {
+	Amounts map[string]float64 `jsonrpcusage:"{\"address\":amount,...}"` // In RMG
+}
+
+// NewCreateIssueTxCmd returns a new instance which can be used to issue a
+// createissuetx JSON-RPC command.
+//
+// Amounts are in RMG.
+func NewCreateIssueTxCmd(amounts map[string]float64) *CreateIssueTxCmd {
+	return &CreateIssueTxCmd{
+		Amounts: amounts,
+	}
+}
+
+// CreateKeyRevokeTxCmd defines the createkeyrevoketx JSON-RPC command.
+type CreateKeyRevokeTxCmd struct {
+	KeyOps []AdminKeyOp
+}
+
+// NewCreateKeyRevokeTxCmd returns a new instance which can be used to issue a
+// createkeyrevoketx JSON-RPC command.
+func NewCreateKeyRevokeTxCmd(keyOps []AdminKeyOp) *CreateKeyRevokeTxCmd {
+	return &CreateKeyRevokeTxCmd{
+		KeyOps: keyOps,
+	}
+}
+
+// CreateProvisionTxCmd defines the createprovisiontx JSON-RPC command.
+type CreateProvisionTxCmd struct {
+	KeyOps []AdminKeyOp
+}
+
+// NewCreateProvisionTxCmd returns a new instance which can be used to issue a
+// createprovisiontx JSON-RPC command.
+func NewCreateProvisionTxCmd(keyOps []AdminKeyOp) *CreateProvisionTxCmd {
+	return &CreateProvisionTxCmd{
+		KeyOps: keyOps,
+	}
+}
+
+// CreatePSPTCmd defines the createpspt JSON-RPC command.
+type CreatePSPTCmd struct {
+	HexTx string
+}
+
+// NewCreatePSPTCmd returns a new instance which can be used to issue a
+// createpspt JSON-RPC command.
+func NewCreatePSPTCmd(hexTx string) *CreatePSPTCmd {
+	return &CreatePSPTCmd{
+		HexTx: hexTx,
+	}
+}
+
 // CreateRawTransactionCmd defines the createrawtransaction JSON-RPC command.
 type CreateRawTransactionCmd struct {
 	Inputs   []TransactionInput
@@ -73,6 +226,84 @@ func NewCreateRawTransactionCmd(inputs []TransactionInput, amounts map[string]fl
 	}
 }
 
+// CreateSplitTxCmd defines the createsplittx JSON-RPC command.
+type CreateSplitTxCmd struct {
+	Txid    string
+	Vout    uint32
+	Address string
+	Count   int
+	Amount  *float64 // In RMG
+	FeeRate *float64 // In RMG/kB
+}
+
+// NewCreateSplitTxCmd returns a new instance which can be used to issue a
+// createsplittx JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCreateSplitTxCmd(txid string, vout uint32, address string, count int,
+	amount, feeRate *float64) *CreateSplitTxCmd {
+
+	return &CreateSplitTxCmd{
+		Txid:    txid,
+		Vout:    vout,
+		Address: address,
+		Count:   count,
+		Amount:  amount,
+		FeeRate: feeRate,
+	}
+}
+
+// CreateSweepTxCmd defines the createsweeptx JSON-RPC command.
+type CreateSweepTxCmd struct {
+	Address     string
+	Destination string
+	FeeRate     *float64 // In RMG/kB
+	MinConf     *int32   `jsonrpcdefault:"1"`
+}
+
+// NewCreateSweepTxCmd returns a new instance which can be used to issue a
+// createsweeptx JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCreateSweepTxCmd(address, destination string, feeRate *float64,
+	minConf *int32) *CreateSweepTxCmd {
+
+	return &CreateSweepTxCmd{
+		Address:     address,
+		Destination: destination,
+		FeeRate:     feeRate,
+		MinConf:     minConf,
+	}
+}
+
+// DebugScriptCmd defines the debugscript JSON-RPC command.
+type DebugScriptCmd struct {
+	HexTx      string
+	InputIndex uint32
+	PkScript   string
+	Amount     *float64
+}
+
+// NewDebugScriptCmd returns a new instance which can be used to issue a
+// debugscript JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// The amount is in RMG.
+func NewDebugScriptCmd(hexTx string, inputIndex uint32, pkScript string,
+	amount *float64) *DebugScriptCmd {
+
+	return &DebugScriptCmd{
+		HexTx:      hexTx,
+		InputIndex: inputIndex,
+		PkScript:   pkScript,
+		Amount:     amount,
+	}
+}
+
 // DecodeRawTransactionCmd defines the decoderawtransaction JSON-RPC command.
 type DecodeRawTransactionCmd struct {
 	HexTx string
@@ -99,6 +330,48 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// FinalizePSPTCmd defines the finalizepspt JSON-RPC command.
+type FinalizePSPTCmd struct {
+	PSPT    string
+	Extract *bool `jsonrpcdefault:"true"`
+}
+
+// NewFinalizePSPTCmd returns a new instance which can be used to issue a
+// finalizepspt JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewFinalizePSPTCmd(pspt string, extract *bool) *FinalizePSPTCmd {
+	return &FinalizePSPTCmd{
+		PSPT:    pspt,
+		Extract: extract,
+	}
+}
+
+// FundRawTransactionOpts are the options of the fundrawtransaction command.
+type FundRawTransactionOpts struct {
+	Addresses     []string `json:"addresses"`
+	ChangeAddress *string  `json:"changeaddress,omitempty"`
+	Strategy      *string  `json:"strategy,omitempty"`
+	FeeRate       *float64 `json:"feerate,omitempty"` // In RMG/kB
+	MinConf       *int32   `json:"minconf,omitempty"`
+}
+
+// FundRawTransactionCmd defines the fundrawtransaction JSON-RPC command.
+type FundRawTransactionCmd struct {
+	HexTx   string
+	Options FundRawTransactionOpts
+}
+
+// NewFundRawTransactionCmd returns a new instance which can be used to issue a
+// fundrawtransaction JSON-RPC command.
+func NewFundRawTransactionCmd(hexTx string, opts FundRawTransactionOpts) *FundRawTransactionCmd {
+	return &FundRawTransactionCmd{
+		HexTx:   hexTx,
+		Options: opts,
+	}
+}
+
 // GetAddedNodeInfoCmd defines the getaddednodeinfo JSON-RPC command.
 type GetAddedNodeInfoCmd struct {
 	DNS  bool
@@ -138,19 +411,20 @@ func NewGetBestBlockHashCmd() *GetBestBlockHashCmd {
 // GetBlockCmd defines the getblock JSON-RPC command.
 type GetBlockCmd struct {
 	Hash      string
-	Verbose   *bool `jsonrpcdefault:"true"`
+	Verbosity *int  `jsonrpcdefault:"1"`
 	VerboseTx *bool `jsonrpcdefault:"false"`
 }
 
 // NewGetBlockCmd returns a new instance which can be used to issue a getblock
-// JSON-RPC command.
+// JSON-RPC command.  A verbosity of 0 returns the serialized block, 1 the
+// decoded block and 2 the decoded block along with its decoded transactions.
 //
 // The parameters which are pointers indicate they are optional.  Passing nil
 // for optional parameters will use the default value.
-func NewGetBlockCmd(hash string, verbose, verboseTx *bool) *GetBlockCmd {
+func NewGetBlockCmd(hash string, verbosity *int, verboseTx *bool) *GetBlockCmd {
 	return &GetBlockCmd{
 		Hash:      hash,
-		Verbose:   verbose,
+		Verbosity: verbosity,
 		VerboseTx: verboseTx,
 	}
 }
@@ -186,6 +460,19 @@ func NewGetBlockHashCmd(index int64) *GetBlockHashCmd {
 	}
 }
 
+// GetBlockHashByTimeCmd defines the getblockhashbytime JSON-RPC command.
+type GetBlockHashByTimeCmd struct {
+	Timestamp int64
+}
+
+// NewGetBlockHashByTimeCmd returns a new instance which can be used to issue a
+// getblockhashbytime JSON-RPC command.
+func NewGetBlockHashByTimeCmd(timestamp int64) *GetBlockHashByTimeCmd {
+	return &GetBlockHashByTimeCmd{
+		Timestamp: timestamp,
+	}
+}
+
 // GetBlockHeaderCmd defines the getblockheader JSON-RPC command.
 type GetBlockHeaderCmd struct {
 	Hash    string
@@ -201,6 +488,24 @@ func NewGetBlockHeaderCmd(hash string, verbose *bool) *GetBlockHeaderCmd {
 	}
 }
 
+// GetBlockHeadersCmd defines the getblockheaders JSON-RPC command.
+type GetBlockHeadersCmd struct {
+	Start   string
+	Count   int
+	Verbose *bool `jsonrpcdefault:"true"`
+}
+
+// NewGetBlockHeadersCmd returns a new instance which can be used to issue a
+// getblockheaders JSON-RPC command.  The start block may be given either as a
+// block hash or as a block height.
+func NewGetBlockHeadersCmd(start string, count int, verbose *bool) *GetBlockHeadersCmd {
+	return &GetBlockHeadersCmd{
+		Start:   start,
+		Count:   count,
+		Verbose: verbose,
+	}
+}
+
 // TemplateRequest is a request object as defined in BIP22
 // (https://en.bitcoin.it/wiki/BIP_0022), it is optionally provided as an
 // pointer argument to GetBlockTemplateCmd.
@@ -297,6 +602,43 @@ func NewGetBlockTemplateCmd(request *TemplateRequest) *GetBlockTemplateCmd {
 	}
 }
 
+// GetBroadcastStatusCmd defines the getbroadcaststatus JSON-RPC command.
+type GetBroadcastStatusCmd struct {
+	ID uint64
+}
+
+// NewGetBroadcastStatusCmd returns a new instance which can be used to issue a
+// getbroadcaststatus JSON-RPC command.
+func NewGetBroadcastStatusCmd(id uint64) *GetBroadcastStatusCmd {
+	return &GetBroadcastStatusCmd{ID: id}
+}
+
+// GetCFilterCmd defines the getcfilter JSON-RPC command.
+type GetCFilterCmd struct {
+	Hash string
+}
+
+// NewGetCFilterCmd returns a new instance which can be used to issue a
+// getcfilter JSON-RPC command.
+func NewGetCFilterCmd(hash string) *GetCFilterCmd {
+	return &GetCFilterCmd{
+		Hash: hash,
+	}
+}
+
+// GetCFilterHeaderCmd defines the getcfilterheader JSON-RPC command.
+type GetCFilterHeaderCmd struct {
+	Hash string
+}
+
+// NewGetCFilterHeaderCmd returns a new instance which can be used to issue a
+// getcfilterheader JSON-RPC command.
+func NewGetCFilterHeaderCmd(hash string) *GetCFilterHeaderCmd {
+	return &GetCFilterHeaderCmd{
+		Hash: hash,
+	}
+}
+
 // GetChainTipsCmd defines the getchaintips JSON-RPC command.
 type GetChainTipsCmd struct{}
 
@@ -306,6 +648,31 @@ func NewGetChainTipsCmd() *GetChainTipsCmd {
 	return &GetChainTipsCmd{}
 }
 
+// GetCirculatingSupplyCmd defines the getcirculatingsupply JSON-RPC command.
+type GetCirculatingSupplyCmd struct {
+	Height *uint32
+}
+
+// NewGetCirculatingSupplyCmd returns a new instance which can be used to issue
+// a getcirculatingsupply JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCirculatingSupplyCmd(height *uint32) *GetCirculatingSupplyCmd {
+	return &GetCirculatingSupplyCmd{
+		Height: height,
+	}
+}
+
+// GetClusterInfoCmd defines the getclusterinfo JSON-RPC command.
+type GetClusterInfoCmd struct{}
+
+// NewGetClusterInfoCmd returns a new instance which can be used to issue a
+// getclusterinfo JSON-RPC command.
+func NewGetClusterInfoCmd() *GetClusterInfoCmd {
+	return &GetClusterInfoCmd{}
+}
+
 // GetConnectionCountCmd defines the getconnectioncount JSON-RPC command.
 type GetConnectionCountCmd struct{}
 
@@ -315,6 +682,15 @@ func NewGetConnectionCountCmd() *GetConnectionCountCmd {
 	return &GetConnectionCountCmd{}
 }
 
+// GetConsistencyStatusCmd defines the getconsistencystatus JSON-RPC command.
+type GetConsistencyStatusCmd struct{}
+
+// NewGetConsistencyStatusCmd returns a new instance which can be used to issue
+// a getconsistencystatus JSON-RPC command.
+func NewGetConsistencyStatusCmd() *GetConsistencyStatusCmd {
+	return &GetConsistencyStatusCmd{}
+}
+
 // GetDifficultyCmd defines the getdifficulty JSON-RPC command.
 type GetDifficultyCmd struct{}
 
@@ -324,6 +700,92 @@ func NewGetDifficultyCmd() *GetDifficultyCmd {
 	return &GetDifficultyCmd{}
 }
 
+// GetEventLogCmd defines the geteventlog JSON-RPC command.
+type GetEventLogCmd struct {
+	Cursor uint64
+	Count  *int `jsonrpcdefault:"1000"`
+}
+
+// NewGetEventLogCmd returns a new instance which can be used to issue a
+// geteventlog JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetEventLogCmd(cursor uint64, count *int) *GetEventLogCmd {
+	return &GetEventLogCmd{
+		Cursor: cursor,
+		Count:  count,
+	}
+}
+
+// GetExplorerBlocksCmd defines the getexplorerblocks JSON-RPC command.
+type GetExplorerBlocksCmd struct {
+	Count  *int `jsonrpcdefault:"20"`
+	Height *uint32
+}
+
+// NewGetExplorerBlocksCmd returns a new instance which can be used to issue a
+// getexplorerblocks JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetExplorerBlocksCmd(count *int, height *uint32) *GetExplorerBlocksCmd {
+	return &GetExplorerBlocksCmd{
+		Count:  count,
+		Height: height,
+	}
+}
+
+// GetExplorerTransactionCmd defines the getexplorertransaction JSON-RPC
+// command.
+type GetExplorerTransactionCmd struct {
+	Txid string
+}
+
+// NewGetExplorerTransactionCmd returns a new instance which can be used to
+// issue a getexplorertransaction JSON-RPC command.
+func NewGetExplorerTransactionCmd(txHash string) *GetExplorerTransactionCmd {
+	return &GetExplorerTransactionCmd{
+		Txid: txHash,
+	}
+}
+
+// GetExplorerAddressCmd defines the getexploreraddress JSON-RPC command.
+type GetExplorerAddressCmd struct {
+	Address string
+	Count   *int `jsonrpcdefault:"20"`
+	Token   *string
+}
+
+// NewGetExplorerAddressCmd returns a new instance which can be used to issue a
+// getexploreraddress JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetExplorerAddressCmd(address string, count *int, token *string) *GetExplorerAddressCmd {
+	return &GetExplorerAddressCmd{
+		Address: address,
+		Count:   count,
+		Token:   token,
+	}
+}
+
+// GetExplorerChartsCmd defines the getexplorercharts JSON-RPC command.
+type GetExplorerChartsCmd struct {
+	Days *int `jsonrpcdefault:"30"`
+}
+
+// NewGetExplorerChartsCmd returns a new instance which can be used to issue a
+// getexplorercharts JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetExplorerChartsCmd(days *int) *GetExplorerChartsCmd {
+	return &GetExplorerChartsCmd{
+		Days: days,
+	}
+}
+
 // GetGenerateCmd defines the getgenerate JSON-RPC command.
 type GetGenerateCmd struct{}
 
@@ -333,6 +795,24 @@ func NewGetGenerateCmd() *GetGenerateCmd {
 	return &GetGenerateCmd{}
 }
 
+// GetHashCacheInfoCmd defines the gethashcacheinfo JSON-RPC command.
+type GetHashCacheInfoCmd struct{}
+
+// NewGetHashCacheInfoCmd returns a new instance which can be used to issue a
+// gethashcacheinfo JSON-RPC command.
+func NewGetHashCacheInfoCmd() *GetHashCacheInfoCmd {
+	return &GetHashCacheInfoCmd{}
+}
+
+// GetHealthCmd defines the gethealth JSON-RPC command.
+type GetHealthCmd struct{}
+
+// NewGetHealthCmd returns a new instance which can be used to issue a
+// gethealth JSON-RPC command.
+func NewGetHealthCmd() *GetHealthCmd {
+	return &GetHealthCmd{}
+}
+
 // GetHashesPerSecCmd defines the gethashespersec JSON-RPC command.
 type GetHashesPerSecCmd struct{}
 
@@ -351,6 +831,40 @@ func NewGetInfoCmd() *GetInfoCmd {
 	return &GetInfoCmd{}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct {
+	IndexName *string
+}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetIndexInfoCmd(indexName *string) *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{
+		IndexName: indexName,
+	}
+}
+
+// GetKeyIDBalanceCmd defines the getkeyidbalance JSON-RPC command.
+type GetKeyIDBalanceCmd struct {
+	KeyID  uint32
+	Height *uint32
+}
+
+// NewGetKeyIDBalanceCmd returns a new instance which can be used to issue a
+// getkeyidbalance JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetKeyIDBalanceCmd(keyID uint32, height *uint32) *GetKeyIDBalanceCmd {
+	return &GetKeyIDBalanceCmd{
+		KeyID:  keyID,
+		Height: height,
+	}
+}
+
 // GetMempoolEntryCmd defines the getmempoolentry JSON-RPC command.
 type GetMempoolEntryCmd struct {
 	TxID string
@@ -418,6 +932,15 @@ func NewGetNetworkHashPSCmd(numBlocks, height *int) *GetNetworkHashPSCmd {
 	}
 }
 
+// GetOrphanBlocksCmd defines the getorphanblocks JSON-RPC command.
+type GetOrphanBlocksCmd struct{}
+
+// NewGetOrphanBlocksCmd returns a new instance which can be used to issue a
+// getorphanblocks JSON-RPC command.
+func NewGetOrphanBlocksCmd() *GetOrphanBlocksCmd {
+	return &GetOrphanBlocksCmd{}
+}
+
 // GetPeerInfoCmd defines the getpeerinfo JSON-RPC command.
 type GetPeerInfoCmd struct{}
 
@@ -448,8 +971,9 @@ func NewGetRawMempoolCmd(verbose *bool) *GetRawMempoolCmd {
 // NOTE: This field is an int versus a bool to remain compatible with Bitcoin
 // Core even though it really should be a bool.
 type GetRawTransactionCmd struct {
-	Txid    string
-	Verbose *int `jsonrpcdefault:"0"`
+	Txid     string
+	Verbose  *int `jsonrpcdefault:"0"`
+	VinExtra *int `jsonrpcdefault:"0"`
 }
 
 // NewGetRawTransactionCmd returns a new instance which can be used to issue a
@@ -457,10 +981,53 @@ type GetRawTransactionCmd struct {
 //
 // The parameters which are pointers indicate they are optional.  Passing nil
 // for optional parameters will use the default value.
-func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd {
+func NewGetRawTransactionCmd(txHash string, verbose, vinExtra *int) *GetRawTransactionCmd {
 	return &GetRawTransactionCmd{
-		Txid:    txHash,
-		Verbose: verbose,
+		Txid:     txHash,
+		Verbose:  verbose,
+		VinExtra: vinExtra,
+	}
+}
+
+// SpentInfoRequest is a request object identifying an output as defined by
+// bitcore.
+// (https://bitcore.io/guides/bitcoin/)
+type SpentInfoRequest struct {
+	Txid  string `json:"txid"`
+	Index uint32 `json:"index"`
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	Request SpentInfoRequest
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txHash string, index uint32) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		Request: SpentInfoRequest{
+			Txid:  txHash,
+			Index: index,
+		},
+	}
+}
+
+// GetSupplyHistoryCmd defines the getsupplyhistory JSON-RPC command.
+type GetSupplyHistoryCmd struct {
+	Start *uint32 `jsonrpcdefault:"0"`
+	End   *uint32 `jsonrpcdefault:"0"`
+}
+
+// NewGetSupplyHistoryCmd returns a new instance which can be used to issue a
+// getsupplyhistory JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetSupplyHistoryCmd(start, end *uint32) *GetSupplyHistoryCmd {
+	return &GetSupplyHistoryCmd{
+		Start: start,
+		End:   end,
 	}
 }
 
@@ -511,6 +1078,71 @@ func NewGetTxOutSetInfoCmd() *GetTxOutSetInfoCmd {
 	return &GetTxOutSetInfoCmd{}
 }
 
+// GetUtxoCommitmentCmd defines the getutxocommitment JSON-RPC command.
+type GetUtxoCommitmentCmd struct {
+	Height *uint32
+}
+
+// NewGetUtxoCommitmentCmd returns a new instance which can be used to issue a
+// getutxocommitment JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetUtxoCommitmentCmd(height *uint32) *GetUtxoCommitmentCmd {
+	return &GetUtxoCommitmentCmd{
+		Height: height,
+	}
+}
+
+// GetUtxoProofCmd defines the getutxoproof JSON-RPC command.
+type GetUtxoProofCmd struct {
+	Txid string
+	Vout uint32
+}
+
+// NewGetUtxoProofCmd returns a new instance which can be used to issue a
+// getutxoproof JSON-RPC command.
+func NewGetUtxoProofCmd(txHash string, vout uint32) *GetUtxoProofCmd {
+	return &GetUtxoProofCmd{
+		Txid: txHash,
+		Vout: vout,
+	}
+}
+
+// GetValidatorInfoCmd defines the getvalidatorinfo JSON-RPC command.
+type GetValidatorInfoCmd struct {
+	Windows *[]uint32
+}
+
+// NewGetValidatorInfoCmd returns a new instance which can be used to issue a
+// getvalidatorinfo JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetValidatorInfoCmd(windows *[]uint32) *GetValidatorInfoCmd {
+	return &GetValidatorInfoCmd{
+		Windows: windows,
+	}
+}
+
+// GetValidatorHeartbeatsCmd defines the getvalidatorheartbeats JSON-RPC command.
+type GetValidatorHeartbeatsCmd struct{}
+
+// NewGetValidatorHeartbeatsCmd returns a new instance which can be used to
+// issue a getvalidatorheartbeats JSON-RPC command.
+func NewGetValidatorHeartbeatsCmd() *GetValidatorHeartbeatsCmd {
+	return &GetValidatorHeartbeatsCmd{}
+}
+
+// GetValidatorScheduleCmd defines the getvalidatorschedule JSON-RPC command.
+type GetValidatorScheduleCmd struct{}
+
+// NewGetValidatorScheduleCmd returns a new instance which can be used to
+// issue a getvalidatorschedule JSON-RPC command.
+func NewGetValidatorScheduleCmd() *GetValidatorScheduleCmd {
+	return &GetValidatorScheduleCmd{}
+}
+
 // GetWorkCmd defines the getwork JSON-RPC command.
 type GetWorkCmd struct {
 	Data *string
@@ -556,6 +1188,15 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -591,11 +1232,83 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ResetSignGuardCmd defines the resetsignguard JSON-RPC command.
+type ResetSignGuardCmd struct {
+	PubKey string
+	Height uint32
+}
+
+// NewResetSignGuardCmd returns a new instance which can be used to issue a
+// resetsignguard JSON-RPC command.
+func NewResetSignGuardCmd(pubKey string, height uint32) *ResetSignGuardCmd {
+	return &ResetSignGuardCmd{
+		PubKey: pubKey,
+		Height: height,
+	}
+}
+
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Request *AddressTxRequest
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(addresses []string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Request: &AddressTxRequest{Addresses: addresses},
+	}
+}
+
+// GetAddressDeltasCmd defines the getaddressdeltas JSON-RPC command.
+type GetAddressDeltasCmd struct {
+	Request *AddressTxRequest
+}
+
+// NewGetAddressDeltasCmd returns a new instance which can be used to issue a
+// getaddressdeltas JSON-RPC command.  A start or end height of zero leaves the
+// range unbounded on that side.
+func NewGetAddressDeltasCmd(addresses []string, start, end uint32) *GetAddressDeltasCmd {
+	return &GetAddressDeltasCmd{
+		Request: &AddressTxRequest{
+			Addresses: addresses,
+			Start:     start,
+			End:       end,
+		},
+	}
+}
+
+// GetAddressMempoolCmd defines the getaddressmempool JSON-RPC command.
+type GetAddressMempoolCmd struct {
+	Request *AddressTxRequest
+}
+
+// NewGetAddressMempoolCmd returns a new instance which can be used to issue a
+// getaddressmempool JSON-RPC command.
+func NewGetAddressMempoolCmd(addresses []string) *GetAddressMempoolCmd {
+	return &GetAddressMempoolCmd{
+		Request: &AddressTxRequest{Addresses: addresses},
+	}
+}
+
 // GetAddressTxIdsCmd defines the getaddresstxids JSON-RPC command.
 type GetAddressTxIdsCmd struct {
 	Request *AddressTxRequest
 }
 
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Request *AddressTxRequest
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+func NewGetAddressUtxosCmd(addresses []string) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Request: &AddressTxRequest{Addresses: addresses},
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -642,6 +1355,41 @@ func NewSendRawTransactionCmd(hexTx string, allowHighFees *bool) *SendRawTransac
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified IP address or subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified IP address or subnet
+	// should be removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	Subnet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subnet string, subCmd SetBanSubCmd, banTime *int64, absolute *bool) *SetBanCmd {
+	return &SetBanCmd{
+		Subnet:   subnet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -660,6 +1408,24 @@ func NewSetGenerateCmd(generate bool, genProcLimit *int) *SetGenerateCmd {
 	}
 }
 
+// SignMessageWithPrivKeyCmd defines the signmessagewithprivkey JSON-RPC
+// command.
+type SignMessageWithPrivKeyCmd struct {
+	PrivKey string
+	Address string
+	Message string
+}
+
+// NewSignMessageWithPrivKeyCmd returns a new instance which can be used to
+// issue a signmessagewithprivkey JSON-RPC command.
+func NewSignMessageWithPrivKeyCmd(privKey, address, message string) *SignMessageWithPrivKeyCmd {
+	return &SignMessageWithPrivKeyCmd{
+		PrivKey: privKey,
+		Address: address,
+		Message: message,
+	}
+}
+
 // StopCmd defines the stop JSON-RPC command.
 type StopCmd struct{}
 
@@ -694,6 +1460,27 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// UpdatePSPTCmd defines the updatepspt JSON-RPC command.
+type UpdatePSPTCmd struct {
+	PSPT       string
+	InputIndex uint32
+	PubKey     string
+	Signature  string
+}
+
+// NewUpdatePSPTCmd returns a new instance which can be used to issue an
+// updatepspt JSON-RPC command.
+func NewUpdatePSPTCmd(pspt string, inputIndex uint32, pubKey,
+	signature string) *UpdatePSPTCmd {
+
+	return &UpdatePSPTCmd{
+		PSPT:       pspt,
+		InputIndex: inputIndex,
+		PubKey:     pubKey,
+		Signature:  signature,
+	}
+}
+
 // ValidateAddressCmd defines the validateaddress JSON-RPC command.
 type ValidateAddressCmd struct {
 	Address string
@@ -730,15 +1517,20 @@ type VerifyMessageCmd struct {
 	Address   string
 	Signature string
 	Message   string
+	Verbose   *bool `jsonrpcdefault:"false"`
 }
 
 // NewVerifyMessageCmd returns a new instance which can be used to issue a
 // verifymessage JSON-RPC command.
-func NewVerifyMessageCmd(address, signature, message string) *VerifyMessageCmd {
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewVerifyMessageCmd(address, signature, message string, verbose *bool) *VerifyMessageCmd {
 	return &VerifyMessageCmd{
 		Address:   address,
 		Signature: signature,
 		Message:   message,
+		Verbose:   verbose,
 	}
 }
 
@@ -755,15 +1547,50 @@ func NewVerifyTxOutProofCmd(proof string) *VerifyTxOutProofCmd {
 	}
 }
 
+// VerifyUtxoProofCmd defines the verifyutxoproof JSON-RPC command.
+type VerifyUtxoProofCmd struct {
+	Proof string
+	Txid  string
+	Vout  uint32
+}
+
+// NewVerifyUtxoProofCmd returns a new instance which can be used to issue a
+// verifyutxoproof JSON-RPC command.
+func NewVerifyUtxoProofCmd(proof, txHash string, vout uint32) *VerifyUtxoProofCmd {
+	return &VerifyUtxoProofCmd{
+		Proof: proof,
+		Txid:  txHash,
+		Vout:  vout,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("broadcasttransaction", (*BroadcastTransactionCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("combinepspt", (*CombinePSPTCmd)(nil), flags)
+	MustRegisterCmd("createconsolidatetx", (*CreateConsolidateTxCmd)(nil), flags)
+	MustRegisterCmd("createdestroytx", (*CreateDestroyTxCmd)(nil), flags)
+	MustRegisterCmd("createissuetx", (*CreateIssueTxCmd)(nil), flags)
+	MustRegisterCmd("createkeyrevoketx", (*CreateKeyRevokeTxCmd)(nil), flags)
+	MustRegisterCmd("createprovisiontx", (*CreateProvisionTxCmd)(nil), flags)
+	MustRegisterCmd("createpspt", (*CreatePSPTCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("createsplittx", (*CreateSplitTxCmd)(nil), flags)
+	MustRegisterCmd("createsweeptx", (*CreateSweepTxCmd)(nil), flags)
+	MustRegisterCmd("debugscript", (*DebugScriptCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("finalizepspt", (*FinalizePSPTCmd)(nil), flags)
+	MustRegisterCmd("fundrawtransaction", (*FundRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressdeltas", (*GetAddressDeltasCmd)(nil), flags)
+	MustRegisterCmd("getaddressmempool", (*GetAddressMempoolCmd)(nil), flags)
 	MustRegisterCmd("getaddresstxids", (*GetAddressTxIdsCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
 	MustRegisterCmd("getadmininfo", (*GetAdminInfoCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
@@ -771,39 +1598,70 @@ func init() {
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getblockhashbytime", (*GetBlockHashByTimeCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
+	MustRegisterCmd("getblockheaders", (*GetBlockHeadersCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
+	MustRegisterCmd("getbroadcaststatus", (*GetBroadcastStatusCmd)(nil), flags)
+	MustRegisterCmd("getcfilter", (*GetCFilterCmd)(nil), flags)
+	MustRegisterCmd("getcfilterheader", (*GetCFilterHeaderCmd)(nil), flags)
 	MustRegisterCmd("getchaintips", (*GetChainTipsCmd)(nil), flags)
+	MustRegisterCmd("getcirculatingsupply", (*GetCirculatingSupplyCmd)(nil), flags)
+	MustRegisterCmd("getclusterinfo", (*GetClusterInfoCmd)(nil), flags)
 	MustRegisterCmd("getconnectioncount", (*GetConnectionCountCmd)(nil), flags)
+	MustRegisterCmd("getconsistencystatus", (*GetConsistencyStatusCmd)(nil), flags)
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
+	MustRegisterCmd("geteventlog", (*GetEventLogCmd)(nil), flags)
+	MustRegisterCmd("getexploreraddress", (*GetExplorerAddressCmd)(nil), flags)
+	MustRegisterCmd("getexplorerblocks", (*GetExplorerBlocksCmd)(nil), flags)
+	MustRegisterCmd("getexplorercharts", (*GetExplorerChartsCmd)(nil), flags)
+	MustRegisterCmd("getexplorertransaction", (*GetExplorerTransactionCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
+	MustRegisterCmd("gethashcacheinfo", (*GetHashCacheInfoCmd)(nil), flags)
+	MustRegisterCmd("gethealth", (*GetHealthCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
+	MustRegisterCmd("getkeyidbalance", (*GetKeyIDBalanceCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
 	MustRegisterCmd("getmempoolinfo", (*GetMempoolInfoCmd)(nil), flags)
 	MustRegisterCmd("getmininginfo", (*GetMiningInfoCmd)(nil), flags)
 	MustRegisterCmd("getnetworkinfo", (*GetNetworkInfoCmd)(nil), flags)
 	MustRegisterCmd("getnettotals", (*GetNetTotalsCmd)(nil), flags)
 	MustRegisterCmd("getnetworkhashps", (*GetNetworkHashPSCmd)(nil), flags)
+	MustRegisterCmd("getorphanblocks", (*GetOrphanBlocksCmd)(nil), flags)
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
+	MustRegisterCmd("getsupplyhistory", (*GetSupplyHistoryCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
+	MustRegisterCmd("getutxocommitment", (*GetUtxoCommitmentCmd)(nil), flags)
+	MustRegisterCmd("getutxoproof", (*GetUtxoProofCmd)(nil), flags)
+	MustRegisterCmd("getvalidatorheartbeats", (*GetValidatorHeartbeatsCmd)(nil), flags)
+	MustRegisterCmd("getvalidatorschedule", (*GetValidatorScheduleCmd)(nil), flags)
+	MustRegisterCmd("getvalidatorinfo", (*GetValidatorInfoCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("resetsignguard", (*ResetSignGuardCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
+	MustRegisterCmd("signmessagewithprivkey", (*SignMessageWithPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("updatepspt", (*UpdatePSPTCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
 	MustRegisterCmd("verifytxoutproof", (*VerifyTxOutProofCmd)(nil), flags)
+	MustRegisterCmd("verifyutxoproof", (*VerifyUtxoProofCmd)(nil), flags)
 }
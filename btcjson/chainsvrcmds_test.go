@@ -41,6 +41,190 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "broadcasttransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("broadcasttransaction", "1122")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBroadcastTransactionCmd("1122", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"broadcasttransaction","params":["1122"],"id":1}`,
+			unmarshalled: &btcjson.BroadcastTransactionCmd{
+				HexTx:       "1122",
+				TargetConfs: btcjson.Uint32(1),
+			},
+		},
+		{
+			name: "broadcasttransaction optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("broadcasttransaction", "1122", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBroadcastTransactionCmd("1122", btcjson.Uint32(6))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"broadcasttransaction","params":["1122",6],"id":1}`,
+			unmarshalled: &btcjson.BroadcastTransactionCmd{
+				HexTx:       "1122",
+				TargetConfs: btcjson.Uint32(6),
+			},
+		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "combinepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("combinepspt", []string{"a", "b"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCombinePSPTCmd([]string{"a", "b"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"combinepspt","params":[["a","b"]],"id":1}`,
+			unmarshalled: &btcjson.CombinePSPTCmd{
+				PSPTs: []string{"a", "b"},
+			},
+		},
+		{
+			name: "createconsolidatetx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createconsolidatetx", `["a","b"]`, 0.5, "c")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateConsolidateTxCmd([]string{"a", "b"}, 0.5, "c", nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createconsolidatetx","params":[["a","b"],0.5,"c"],"id":1}`,
+			unmarshalled: &btcjson.CreateConsolidateTxCmd{
+				Addresses:   []string{"a", "b"},
+				Threshold:   0.5,
+				Destination: "c",
+				MaxInputs:   btcjson.Int(100),
+				MinConf:     btcjson.Int32(1),
+			},
+		},
+		{
+			name: "createconsolidatetx optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createconsolidatetx", `["a"]`, 0.5, "c", 0.001, 20, 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateConsolidateTxCmd([]string{"a"}, 0.5, "c",
+					btcjson.Float64(0.001), btcjson.Int(20), btcjson.Int32(6))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createconsolidatetx","params":[["a"],0.5,"c",0.001,20,6],"id":1}`,
+			unmarshalled: &btcjson.CreateConsolidateTxCmd{
+				Addresses:   []string{"a"},
+				Threshold:   0.5,
+				Destination: "c",
+				FeeRate:     btcjson.Float64(0.001),
+				MaxInputs:   btcjson.Int(20),
+				MinConf:     btcjson.Int32(6),
+			},
+		},
+		{
+			name: "createdestroytx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createdestroytx", `[{"txid":"123","vout":1}]`, 0.5)
+			},
+			staticCmd: func() interface{} {
+				txInputs := []btcjson.TransactionInput{
+					{Txid: "123", Vout: 1},
+				}
+				return btcjson.NewCreateDestroyTxCmd(txInputs, 0.5, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createdestroytx","params":[[{"txid":"123","vout":1}],0.5],"id":1}`,
+			unmarshalled: &btcjson.CreateDestroyTxCmd{
+				Inputs: []btcjson.TransactionInput{{Txid: "123", Vout: 1}},
+				Amount: 0.5,
+			},
+		},
+		{
+			name: "createdestroytx optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createdestroytx", `[{"txid":"123","vout":1}]`, 0.5, "456")
+			},
+			staticCmd: func() interface{} {
+				txInputs := []btcjson.TransactionInput{
+					{Txid: "123", Vout: 1},
+				}
+				return btcjson.NewCreateDestroyTxCmd(txInputs, 0.5, btcjson.String("456"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createdestroytx","params":[[{"txid":"123","vout":1}],0.5,"456"],"id":1}`,
+			unmarshalled: &btcjson.CreateDestroyTxCmd{
+				Inputs:        []btcjson.TransactionInput{{Txid: "123", Vout: 1}},
+				Amount:        0.5,
+				ChangeAddress: btcjson.String("456"),
+			},
+		},
+		{
+			name: "createissuetx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createissuetx", `{"456":0.0123}`)
+			},
+			staticCmd: func() interface{} {
+				amounts := map[string]float64{"456": .0123}
+				return btcjson.NewCreateIssueTxCmd(amounts)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createissuetx","params":[{"456":0.0123}],"id":1}`,
+			unmarshalled: &btcjson.CreateIssueTxCmd{
+				Amounts: map[string]float64{"456": .0123},
+			},
+		},
+		{
+			name: "createkeyrevoketx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createkeyrevoketx", `[{"keyset":"ASP","pubkey":"02ab","keyid":2}]`)
+			},
+			staticCmd: func() interface{} {
+				keyOps := []btcjson.AdminKeyOp{
+					{KeySet: "ASP", PubKey: "02ab", KeyID: btcjson.Uint32(2)},
+				}
+				return btcjson.NewCreateKeyRevokeTxCmd(keyOps)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createkeyrevoketx","params":[[{"keyset":"ASP","pubkey":"02ab","keyid":2}]],"id":1}`,
+			unmarshalled: &btcjson.CreateKeyRevokeTxCmd{
+				KeyOps: []btcjson.AdminKeyOp{
+					{KeySet: "ASP", PubKey: "02ab", KeyID: btcjson.Uint32(2)},
+				},
+			},
+		},
+		{
+			name: "createprovisiontx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createprovisiontx", `[{"keyset":"VALIDATE","pubkey":"02ab"}]`)
+			},
+			staticCmd: func() interface{} {
+				keyOps := []btcjson.AdminKeyOp{
+					{KeySet: "VALIDATE", PubKey: "02ab"},
+				}
+				return btcjson.NewCreateProvisionTxCmd(keyOps)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createprovisiontx","params":[[{"keyset":"VALIDATE","pubkey":"02ab"}]],"id":1}`,
+			unmarshalled: &btcjson.CreateProvisionTxCmd{
+				KeyOps: []btcjson.AdminKeyOp{
+					{KeySet: "VALIDATE", PubKey: "02ab"},
+				},
+			},
+		},
+		{
+			name: "createpspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createpspt", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreatePSPTCmd("123")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"createpspt","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.CreatePSPTCmd{HexTx: "123"},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -80,6 +264,105 @@ func TestChainSvrCmds(t *testing.T) {
 				LockTime: btcjson.Int64(12312333333),
 			},
 		},
+		{
+			name: "createsplittx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createsplittx", "123", 1, "a", 4)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateSplitTxCmd("123", 1, "a", 4, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createsplittx","params":["123",1,"a",4],"id":1}`,
+			unmarshalled: &btcjson.CreateSplitTxCmd{
+				Txid:    "123",
+				Vout:    1,
+				Address: "a",
+				Count:   4,
+			},
+		},
+		{
+			name: "createsplittx optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createsplittx", "123", 1, "a", 4, 0.25, 0.001)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateSplitTxCmd("123", 1, "a", 4,
+					btcjson.Float64(0.25), btcjson.Float64(0.001))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createsplittx","params":["123",1,"a",4,0.25,0.001],"id":1}`,
+			unmarshalled: &btcjson.CreateSplitTxCmd{
+				Txid:    "123",
+				Vout:    1,
+				Address: "a",
+				Count:   4,
+				Amount:  btcjson.Float64(0.25),
+				FeeRate: btcjson.Float64(0.001),
+			},
+		},
+		{
+			name: "createsweeptx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createsweeptx", "a", "b")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateSweepTxCmd("a", "b", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createsweeptx","params":["a","b"],"id":1}`,
+			unmarshalled: &btcjson.CreateSweepTxCmd{
+				Address:     "a",
+				Destination: "b",
+				MinConf:     btcjson.Int32(1),
+			},
+		},
+		{
+			name: "createsweeptx optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createsweeptx", "a", "b", 0.001, 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreateSweepTxCmd("a", "b",
+					btcjson.Float64(0.001), btcjson.Int32(6))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createsweeptx","params":["a","b",0.001,6],"id":1}`,
+			unmarshalled: &btcjson.CreateSweepTxCmd{
+				Address:     "a",
+				Destination: "b",
+				FeeRate:     btcjson.Float64(0.001),
+				MinConf:     btcjson.Int32(6),
+			},
+		},
+		{
+			name: "debugscript",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "123", 1, "00")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("123", 1, "00", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["123",1,"00"],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{
+				HexTx:      "123",
+				InputIndex: 1,
+				PkScript:   "00",
+			},
+		},
+		{
+			name: "debugscript optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "123", 1, "00", 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("123", 1, "00",
+					btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["123",1,"00",0.5],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{
+				HexTx:      "123",
+				InputIndex: 1,
+				PkScript:   "00",
+				Amount:     btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -102,6 +385,56 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "finalizepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("finalizepspt", "a")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFinalizePSPTCmd("a", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"finalizepspt","params":["a"],"id":1}`,
+			unmarshalled: &btcjson.FinalizePSPTCmd{
+				PSPT:    "a",
+				Extract: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "finalizepspt optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("finalizepspt", "a", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFinalizePSPTCmd("a", btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"finalizepspt","params":["a",false],"id":1}`,
+			unmarshalled: &btcjson.FinalizePSPTCmd{
+				PSPT:    "a",
+				Extract: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "fundrawtransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("fundrawtransaction", "a", `{"addresses":["b"],"strategy":"privacy","feerate":0.001}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFundRawTransactionCmd("a", btcjson.FundRawTransactionOpts{
+					Addresses: []string{"b"},
+					Strategy:  btcjson.String("privacy"),
+					FeeRate:   btcjson.Float64(0.001),
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"fundrawtransaction","params":["a",{"addresses":["b"],"strategy":"privacy","feerate":0.001}],"id":1}`,
+			unmarshalled: &btcjson.FundRawTransactionCmd{
+				HexTx: "a",
+				Options: btcjson.FundRawTransactionOpts{
+					Addresses: []string{"b"},
+					Strategy:  btcjson.String("privacy"),
+					FeeRate:   btcjson.Float64(0.001),
+				},
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -127,6 +460,68 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance", `{"addresses":["a"]}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd([]string{"a"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":[{"addresses":["a"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{
+				Request: &btcjson.AddressTxRequest{
+					Addresses: []string{"a"},
+				},
+			},
+		},
+		{
+			name: "getaddressdeltas",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressdeltas", `{"addresses":["a"],"start":1,"end":2}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressDeltasCmd([]string{"a"}, 1, 2)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":[{"addresses":["a"],"start":1,"end":2}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressDeltasCmd{
+				Request: &btcjson.AddressTxRequest{
+					Addresses: []string{"a"},
+					Start:     1,
+					End:       2,
+				},
+			},
+		},
+		{
+			name: "getaddressmempool",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressmempool", `{"addresses":["a"]}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressMempoolCmd([]string{"a"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressmempool","params":[{"addresses":["a"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressMempoolCmd{
+				Request: &btcjson.AddressTxRequest{
+					Addresses: []string{"a"},
+				},
+			},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos", `{"addresses":["a"]}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd([]string{"a"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":[{"addresses":["a"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{
+				Request: &btcjson.AddressTxRequest{
+					Addresses: []string{"a"},
+				},
+			},
+		},
 		{
 			name: "getadmininfo",
 			newCmd: func() (interface{}, error) {
@@ -160,7 +555,7 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123"],"id":1}`,
 			unmarshalled: &btcjson.GetBlockCmd{
 				Hash:      "123",
-				Verbose:   btcjson.Bool(true),
+				Verbosity: btcjson.Int(1),
 				VerboseTx: btcjson.Bool(false),
 			},
 		},
@@ -170,34 +565,49 @@ func TestChainSvrCmds(t *testing.T) {
 				// Intentionally use a source param that is
 				// more pointers than the destination to
 				// exercise that path.
-				verbosePtr := btcjson.Bool(true)
-				return btcjson.NewCmd("getblock", "123", &verbosePtr)
+				verbosityPtr := btcjson.Int(1)
+				return btcjson.NewCmd("getblock", "123", &verbosityPtr)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetBlockCmd("123", btcjson.Bool(true), nil)
+				return btcjson.NewGetBlockCmd("123", btcjson.Int(1), nil)
 			},
-			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",true],"id":1}`,
+			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",1],"id":1}`,
 			unmarshalled: &btcjson.GetBlockCmd{
 				Hash:      "123",
-				Verbose:   btcjson.Bool(true),
+				Verbosity: btcjson.Int(1),
 				VerboseTx: btcjson.Bool(false),
 			},
 		},
 		{
 			name: "getblock required optional2",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getblock", "123", true, true)
+				return btcjson.NewCmd("getblock", "123", 1, true)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetBlockCmd("123", btcjson.Bool(true), btcjson.Bool(true))
+				return btcjson.NewGetBlockCmd("123", btcjson.Int(1), btcjson.Bool(true))
 			},
-			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",true,true],"id":1}`,
+			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",1,true],"id":1}`,
 			unmarshalled: &btcjson.GetBlockCmd{
 				Hash:      "123",
-				Verbose:   btcjson.Bool(true),
+				Verbosity: btcjson.Int(1),
 				VerboseTx: btcjson.Bool(true),
 			},
 		},
+		{
+			name: "getblock verbosity 2",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblock", "123", 2)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockCmd("123", btcjson.Int(2), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",2],"id":1}`,
+			unmarshalled: &btcjson.GetBlockCmd{
+				Hash:      "123",
+				Verbosity: btcjson.Int(2),
+				VerboseTx: btcjson.Bool(false),
+			},
+		},
 		{
 			name: "getblockchaininfo",
 			newCmd: func() (interface{}, error) {
@@ -231,6 +641,19 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockhash","params":[123],"id":1}`,
 			unmarshalled: &btcjson.GetBlockHashCmd{Index: 123},
 		},
+		{
+			name: "getblockhashbytime",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockhashbytime", 1500000000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHashByTimeCmd(1500000000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashbytime","params":[1500000000],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHashByTimeCmd{
+				Timestamp: 1500000000,
+			},
+		},
 		{
 			name: "getblockheader",
 			newCmd: func() (interface{}, error) {
@@ -245,6 +668,36 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Bool(true),
 			},
 		},
+		{
+			name: "getblockheaders",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockheaders", "100", 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHeadersCmd("100", 10, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockheaders","params":["100",10],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHeadersCmd{
+				Start:   "100",
+				Count:   10,
+				Verbose: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getblockheaders not verbose",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockheaders", "123", 2000, false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHeadersCmd("123", 2000, btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockheaders","params":["123",2000,false],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHeadersCmd{
+				Start:   "123",
+				Count:   2000,
+				Verbose: btcjson.Bool(false),
+			},
+		},
 		{
 			name: "getblocktemplate",
 			newCmd: func() (interface{}, error) {
@@ -268,98 +721,297 @@ func TestChainSvrCmds(t *testing.T) {
 				}
 				return btcjson.NewGetBlockTemplateCmd(&template)
 			},
-			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"]}],"id":1}`,
-			unmarshalled: &btcjson.GetBlockTemplateCmd{
-				Request: &btcjson.TemplateRequest{
-					Mode:         "template",
-					Capabilities: []string{"longpoll", "coinbasetxn"},
-				},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"]}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTemplateCmd{
+				Request: &btcjson.TemplateRequest{
+					Mode:         "template",
+					Capabilities: []string{"longpoll", "coinbasetxn"},
+				},
+			},
+		},
+		{
+			name: "getblocktemplate optional - template request with tweaks",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktemplate", `{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":500,"sizelimit":100000000,"maxversion":2}`)
+			},
+			staticCmd: func() interface{} {
+				template := btcjson.TemplateRequest{
+					Mode:         "template",
+					Capabilities: []string{"longpoll", "coinbasetxn"},
+					SigOpLimit:   500,
+					SizeLimit:    100000000,
+					MaxVersion:   2,
+				}
+				return btcjson.NewGetBlockTemplateCmd(&template)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":500,"sizelimit":100000000,"maxversion":2}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTemplateCmd{
+				Request: &btcjson.TemplateRequest{
+					Mode:         "template",
+					Capabilities: []string{"longpoll", "coinbasetxn"},
+					SigOpLimit:   int64(500),
+					SizeLimit:    int64(100000000),
+					MaxVersion:   2,
+				},
+			},
+		},
+		{
+			name: "getblocktemplate optional - template request with tweaks 2",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktemplate", `{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":true,"sizelimit":100000000,"maxversion":2}`)
+			},
+			staticCmd: func() interface{} {
+				template := btcjson.TemplateRequest{
+					Mode:         "template",
+					Capabilities: []string{"longpoll", "coinbasetxn"},
+					SigOpLimit:   true,
+					SizeLimit:    100000000,
+					MaxVersion:   2,
+				}
+				return btcjson.NewGetBlockTemplateCmd(&template)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":true,"sizelimit":100000000,"maxversion":2}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTemplateCmd{
+				Request: &btcjson.TemplateRequest{
+					Mode:         "template",
+					Capabilities: []string{"longpoll", "coinbasetxn"},
+					SigOpLimit:   true,
+					SizeLimit:    int64(100000000),
+					MaxVersion:   2,
+				},
+			},
+		},
+		{
+			name: "getbroadcaststatus",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getbroadcaststatus", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBroadcastStatusCmd(3)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getbroadcaststatus","params":[3],"id":1}`,
+			unmarshalled: &btcjson.GetBroadcastStatusCmd{ID: 3},
+		},
+		{
+			name: "getcfilter",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcfilter", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCFilterCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcfilter","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetCFilterCmd{
+				Hash: "123",
+			},
+		},
+		{
+			name: "getcfilterheader",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcfilterheader", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCFilterHeaderCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcfilterheader","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetCFilterHeaderCmd{
+				Hash: "123",
+			},
+		},
+		{
+			name: "getchaintips",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getchaintips")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetChainTipsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetChainTipsCmd{},
+		},
+		{
+			name: "getcirculatingsupply",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcirculatingsupply")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCirculatingSupplyCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcirculatingsupply","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetCirculatingSupplyCmd{
+				Height: nil,
+			},
+		},
+		{
+			name: "getcirculatingsupply optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcirculatingsupply", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCirculatingSupplyCmd(btcjson.Uint32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcirculatingsupply","params":[100],"id":1}`,
+			unmarshalled: &btcjson.GetCirculatingSupplyCmd{
+				Height: btcjson.Uint32(100),
+			},
+		},
+		{
+			name: "getclusterinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getclusterinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetClusterInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getclusterinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetClusterInfoCmd{},
+		},
+		{
+			name: "getconnectioncount",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getconnectioncount")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetConnectionCountCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getconnectioncount","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetConnectionCountCmd{},
+		},
+		{
+			name: "getconsistencystatus",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getconsistencystatus")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetConsistencyStatusCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getconsistencystatus","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetConsistencyStatusCmd{},
+		},
+		{
+			name: "getdifficulty",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdifficulty")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDifficultyCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdifficulty","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetDifficultyCmd{},
+		},
+		{
+			name: "geteventlog",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("geteventlog", 42)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetEventLogCmd(42, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"geteventlog","params":[42],"id":1}`,
+			unmarshalled: &btcjson.GetEventLogCmd{
+				Cursor: 42,
+				Count:  btcjson.Int(1000),
+			},
+		},
+		{
+			name: "geteventlog count",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("geteventlog", 0, 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetEventLogCmd(0, btcjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"geteventlog","params":[0,10],"id":1}`,
+			unmarshalled: &btcjson.GetEventLogCmd{
+				Cursor: 0,
+				Count:  btcjson.Int(10),
+			},
+		},
+		{
+			name: "getexplorerblocks",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getexplorerblocks")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetExplorerBlocksCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getexplorerblocks","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerBlocksCmd{
+				Count: btcjson.Int(20),
 			},
 		},
 		{
-			name: "getblocktemplate optional - template request with tweaks",
+			name: "getexplorerblocks optional",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getblocktemplate", `{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":500,"sizelimit":100000000,"maxversion":2}`)
+				return btcjson.NewCmd("getexplorerblocks", 10, 500)
 			},
 			staticCmd: func() interface{} {
-				template := btcjson.TemplateRequest{
-					Mode:         "template",
-					Capabilities: []string{"longpoll", "coinbasetxn"},
-					SigOpLimit:   500,
-					SizeLimit:    100000000,
-					MaxVersion:   2,
-				}
-				return btcjson.NewGetBlockTemplateCmd(&template)
+				return btcjson.NewGetExplorerBlocksCmd(btcjson.Int(10),
+					btcjson.Uint32(500))
 			},
-			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":500,"sizelimit":100000000,"maxversion":2}],"id":1}`,
-			unmarshalled: &btcjson.GetBlockTemplateCmd{
-				Request: &btcjson.TemplateRequest{
-					Mode:         "template",
-					Capabilities: []string{"longpoll", "coinbasetxn"},
-					SigOpLimit:   int64(500),
-					SizeLimit:    int64(100000000),
-					MaxVersion:   2,
-				},
+			marshalled: `{"jsonrpc":"1.0","method":"getexplorerblocks","params":[10,500],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerBlocksCmd{
+				Count:  btcjson.Int(10),
+				Height: btcjson.Uint32(500),
 			},
 		},
 		{
-			name: "getblocktemplate optional - template request with tweaks 2",
+			name: "getexplorertransaction",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getblocktemplate", `{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":true,"sizelimit":100000000,"maxversion":2}`)
+				return btcjson.NewCmd("getexplorertransaction", "123")
 			},
 			staticCmd: func() interface{} {
-				template := btcjson.TemplateRequest{
-					Mode:         "template",
-					Capabilities: []string{"longpoll", "coinbasetxn"},
-					SigOpLimit:   true,
-					SizeLimit:    100000000,
-					MaxVersion:   2,
-				}
-				return btcjson.NewGetBlockTemplateCmd(&template)
+				return btcjson.NewGetExplorerTransactionCmd("123")
 			},
-			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"mode":"template","capabilities":["longpoll","coinbasetxn"],"sigoplimit":true,"sizelimit":100000000,"maxversion":2}],"id":1}`,
-			unmarshalled: &btcjson.GetBlockTemplateCmd{
-				Request: &btcjson.TemplateRequest{
-					Mode:         "template",
-					Capabilities: []string{"longpoll", "coinbasetxn"},
-					SigOpLimit:   true,
-					SizeLimit:    int64(100000000),
-					MaxVersion:   2,
-				},
+			marshalled: `{"jsonrpc":"1.0","method":"getexplorertransaction","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerTransactionCmd{
+				Txid: "123",
 			},
 		},
 		{
-			name: "getchaintips",
+			name: "getexploreraddress",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getchaintips")
+				return btcjson.NewCmd("getexploreraddress", "1Address")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetChainTipsCmd()
+				return btcjson.NewGetExplorerAddressCmd("1Address",
+					nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getexploreraddress","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerAddressCmd{
+				Address: "1Address",
+				Count:   btcjson.Int(20),
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetChainTipsCmd{},
 		},
 		{
-			name: "getconnectioncount",
+			name: "getexploreraddress optional",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getconnectioncount")
+				return btcjson.NewCmd("getexploreraddress", "1Address",
+					50, "0102")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetConnectionCountCmd()
+				return btcjson.NewGetExplorerAddressCmd("1Address",
+					btcjson.Int(50), btcjson.String("0102"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getexploreraddress","params":["1Address",50,"0102"],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerAddressCmd{
+				Address: "1Address",
+				Count:   btcjson.Int(50),
+				Token:   btcjson.String("0102"),
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"getconnectioncount","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetConnectionCountCmd{},
 		},
 		{
-			name: "getdifficulty",
+			name: "getexplorercharts",
 			newCmd: func() (interface{}, error) {
-				return btcjson.NewCmd("getdifficulty")
+				return btcjson.NewCmd("getexplorercharts")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetDifficultyCmd()
+				return btcjson.NewGetExplorerChartsCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getexplorercharts","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetExplorerChartsCmd{
+				Days: btcjson.Int(30),
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"getdifficulty","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetDifficultyCmd{},
 		},
 		{
 			name: "getgenerate",
@@ -372,6 +1024,28 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getgenerate","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetGenerateCmd{},
 		},
+		{
+			name: "gethashcacheinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gethashcacheinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetHashCacheInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"gethashcacheinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetHashCacheInfoCmd{},
+		},
+		{
+			name: "gethealth",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gethealth")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetHealthCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"gethealth","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetHealthCmd{},
+		},
 		{
 			name: "gethashespersec",
 			newCmd: func() (interface{}, error) {
@@ -383,6 +1057,32 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gethashespersec","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetHashesPerSecCmd{},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: nil,
+			},
+		},
+		{
+			name: "getindexinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo", "spent index")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(btcjson.String("spent index"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["spent index"],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: btcjson.String("spent index"),
+			},
+		},
 		{
 			name: "getinfo",
 			newCmd: func() (interface{}, error) {
@@ -394,6 +1094,34 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetInfoCmd{},
 		},
+		{
+			name: "getkeyidbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidbalance", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDBalanceCmd(1, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidbalance","params":[1],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDBalanceCmd{
+				KeyID:  1,
+				Height: nil,
+			},
+		},
+		{
+			name: "getkeyidbalance optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidbalance", 1, 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDBalanceCmd(1, btcjson.Uint32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidbalance","params":[1,100],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDBalanceCmd{
+				KeyID:  1,
+				Height: btcjson.Uint32(100),
+			},
+		},
 		{
 			name: "getmempoolentry",
 			newCmd: func() (interface{}, error) {
@@ -493,6 +1221,17 @@ func TestChainSvrCmds(t *testing.T) {
 				Height: btcjson.Int(123),
 			},
 		},
+		{
+			name: "getorphanblocks",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getorphanblocks")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetOrphanBlocksCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getorphanblocks","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetOrphanBlocksCmd{},
+		},
 		{
 			name: "getpeerinfo",
 			newCmd: func() (interface{}, error) {
@@ -536,12 +1275,13 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getrawtransaction", "123")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetRawTransactionCmd("123", nil)
+				return btcjson.NewGetRawTransactionCmd("123", nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawtransaction","params":["123"],"id":1}`,
 			unmarshalled: &btcjson.GetRawTransactionCmd{
-				Txid:    "123",
-				Verbose: btcjson.Int(0),
+				Txid:     "123",
+				Verbose:  btcjson.Int(0),
+				VinExtra: btcjson.Int(0),
 			},
 		},
 		{
@@ -550,12 +1290,73 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getrawtransaction", "123", 1)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetRawTransactionCmd("123", btcjson.Int(1))
+				return btcjson.NewGetRawTransactionCmd("123", btcjson.Int(1), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawtransaction","params":["123",1],"id":1}`,
 			unmarshalled: &btcjson.GetRawTransactionCmd{
-				Txid:    "123",
-				Verbose: btcjson.Int(1),
+				Txid:     "123",
+				Verbose:  btcjson.Int(1),
+				VinExtra: btcjson.Int(0),
+			},
+		},
+		{
+			name: "getrawtransaction optional2",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrawtransaction", "123", 1, 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRawTransactionCmd("123", btcjson.Int(1), btcjson.Int(1))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getrawtransaction","params":["123",1,1],"id":1}`,
+			unmarshalled: &btcjson.GetRawTransactionCmd{
+				Txid:     "123",
+				Verbose:  btcjson.Int(1),
+				VinExtra: btcjson.Int(1),
+			},
+		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspentinfo", `{"txid":"123","index":1}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSpentInfoCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":[{"txid":"123","index":1}],"id":1}`,
+			unmarshalled: &btcjson.GetSpentInfoCmd{
+				Request: btcjson.SpentInfoRequest{
+					Txid:  "123",
+					Index: 1,
+				},
+			},
+		},
+		{
+			name: "getsupplyhistory",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getsupplyhistory")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSupplyHistoryCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getsupplyhistory","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetSupplyHistoryCmd{
+				Start: btcjson.Uint32(0),
+				End:   btcjson.Uint32(0),
+			},
+		},
+		{
+			name: "getsupplyhistory optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getsupplyhistory", 10, 20)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSupplyHistoryCmd(btcjson.Uint32(10),
+					btcjson.Uint32(20))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getsupplyhistory","params":[10,20],"id":1}`,
+			unmarshalled: &btcjson.GetSupplyHistoryCmd{
+				Start: btcjson.Uint32(10),
+				End:   btcjson.Uint32(20),
 			},
 		},
 		{
@@ -629,6 +1430,95 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
 		},
+		{
+			name: "getutxocommitment",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxocommitment")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoCommitmentCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxocommitment","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoCommitmentCmd{
+				Height: nil,
+			},
+		},
+		{
+			name: "getutxocommitment optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxocommitment", 123)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoCommitmentCmd(btcjson.Uint32(123))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxocommitment","params":[123],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoCommitmentCmd{
+				Height: btcjson.Uint32(123),
+			},
+		},
+		{
+			name: "getutxoproof",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxoproof", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoProofCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxoproof","params":["123",1],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoProofCmd{
+				Txid: "123",
+				Vout: 1,
+			},
+		},
+
+		{
+			name: "getvalidatorheartbeats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidatorheartbeats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidatorHeartbeatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvalidatorheartbeats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidatorHeartbeatsCmd{},
+		},
+		{
+			name: "getvalidatorschedule",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidatorschedule")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidatorScheduleCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvalidatorschedule","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidatorScheduleCmd{},
+		},
+		{
+			name: "getvalidatorinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidatorinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidatorInfoCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getvalidatorinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidatorInfoCmd{
+				Windows: nil,
+			},
+		},
+		{
+			name: "getvalidatorinfo optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidatorinfo", []uint32{10, 100})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidatorInfoCmd(&[]uint32{10, 100})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getvalidatorinfo","params":[[10,100]],"id":1}`,
+			unmarshalled: &btcjson.GetValidatorInfoCmd{
+				Windows: &[]uint32{10, 100},
+			},
+		},
 		{
 			name: "getwork",
 			newCmd: func() (interface{}, error) {
@@ -694,6 +1584,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -731,6 +1632,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "resetsignguard",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("resetsignguard", "02ab", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewResetSignGuardCmd("02ab", 100)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"resetsignguard","params":["02ab",100],"id":1}`,
+			unmarshalled: &btcjson.ResetSignGuardCmd{
+				PubKey: "02ab",
+				Height: 100,
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -898,6 +1813,41 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil,
+					nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "1.2.3.4", "add", 1500000000,
+					true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("1.2.3.4", btcjson.SBAdd,
+					btcjson.Int64(1500000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["1.2.3.4","add",1500000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "1.2.3.4",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1500000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -926,6 +1876,21 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: btcjson.Int(6),
 			},
 		},
+		{
+			name: "signmessagewithprivkey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("signmessagewithprivkey", "5Key", "1Address", "test")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSignMessageWithPrivKeyCmd("5Key", "1Address", "test")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"signmessagewithprivkey","params":["5Key","1Address","test"],"id":1}`,
+			unmarshalled: &btcjson.SignMessageWithPrivKeyCmd{
+				PrivKey: "5Key",
+				Address: "1Address",
+				Message: "test",
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -970,6 +1935,22 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "updatepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("updatepspt", "a", 1, "02", "30")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewUpdatePSPTCmd("a", 1, "02", "30")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"updatepspt","params":["a",1,"02","30"],"id":1}`,
+			unmarshalled: &btcjson.UpdatePSPTCmd{
+				PSPT:       "a",
+				InputIndex: 1,
+				PubKey:     "02",
+				Signature:  "30",
+			},
+		},
 		{
 			name: "validateaddress",
 			newCmd: func() (interface{}, error) {
@@ -1031,13 +2012,30 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("verifymessage", "1Address", "301234", "test")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewVerifyMessageCmd("1Address", "301234", "test")
+				return btcjson.NewVerifyMessageCmd("1Address", "301234", "test", nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"verifymessage","params":["1Address","301234","test"],"id":1}`,
 			unmarshalled: &btcjson.VerifyMessageCmd{
 				Address:   "1Address",
 				Signature: "301234",
 				Message:   "test",
+				Verbose:   btcjson.Bool(false),
+			},
+		},
+		{
+			name: "verifymessage optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifymessage", "1Address", "301234", "test", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyMessageCmd("1Address", "301234", "test", btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifymessage","params":["1Address","301234","test",true],"id":1}`,
+			unmarshalled: &btcjson.VerifyMessageCmd{
+				Address:   "1Address",
+				Signature: "301234",
+				Message:   "test",
+				Verbose:   btcjson.Bool(true),
 			},
 		},
 		{
@@ -1053,6 +2051,21 @@ func TestChainSvrCmds(t *testing.T) {
 				Proof: "test",
 			},
 		},
+		{
+			name: "verifyutxoproof",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifyutxoproof", "test", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyUtxoProofCmd("test", "123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifyutxoproof","params":["test","123",1],"id":1}`,
+			unmarshalled: &btcjson.VerifyUtxoProofCmd{
+				Proof: "test",
+				Txid:  "123",
+				Vout:  1,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -48,6 +48,35 @@ type GetBlockVerboseResult struct {
 	Signature        string        `json:"signature,omitempty"`
 }
 
+// GetBlockVerboseTxResult models the data from the getblock command when the
+// verbosity is 2.  It is like GetBlockVerboseResult except the transactions
+// are fully decoded, including the previous outputs they spend.
+type GetBlockVerboseTxResult struct {
+	Hash             string                        `json:"hash"`
+	Confirmations    uint64                        `json:"confirmations"`
+	Size             int32                         `json:"size"`
+	Height           int64                         `json:"height"`
+	Version          uint32                        `json:"version"`
+	MerkleRoot       string                        `json:"merkleroot"`
+	Tx               []SearchRawTransactionsResult `json:"tx"`
+	Time             int64                         `json:"time"`
+	Nonce            uint64                        `json:"nonce"`
+	Bits             string                        `json:"bits"`
+	Difficulty       float64                       `json:"difficulty"`
+	PreviousHash     string                        `json:"previousblockhash"`
+	NextHash         string                        `json:"nextblockhash,omitempty"`
+	ValidatingPubKey string                        `json:"validatingpubkey"`
+	Signature        string                        `json:"signature,omitempty"`
+}
+
+// GetBlockHashByTimeResult models the data from the getblockhashbytime
+// command.
+type GetBlockHashByTimeResult struct {
+	Hash   string `json:"hash"`
+	Height uint32 `json:"height"`
+	Time   int64  `json:"time"`
+}
+
 // CreateMultiSigResult models the data returned from the createmultisig
 // command.
 type CreateMultiSigResult struct {
@@ -55,13 +84,51 @@ type CreateMultiSigResult struct {
 	RedeemScript string `json:"redeemScript"`
 }
 
+// DebugScriptStep models a single step of the script execution trace returned
+// from the debugscript command.
+type DebugScriptStep struct {
+	Script    string   `json:"script"`
+	Offset    int      `json:"offset"`
+	Opcode    string   `json:"opcode"`
+	Executed  bool     `json:"executed"`
+	Stack     []string `json:"stack"`
+	AltStack  []string `json:"altstack"`
+	CondStack []string `json:"condstack"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// DebugScriptResult models the data returned from the debugscript command.
+type DebugScriptResult struct {
+	SigScript string            `json:"sigscript"`
+	PkScript  string            `json:"pkscript"`
+	Amount    float64           `json:"amount"`
+	Valid     bool              `json:"valid"`
+	Error     string            `json:"error,omitempty"`
+	Steps     []DebugScriptStep `json:"steps"`
+}
+
 // DecodeScriptResult models the data returned from the decodescript command.
 type DecodeScriptResult struct {
-	Asm       string   `json:"asm"`
-	ReqSigs   int32    `json:"reqSigs,omitempty"`
-	Type      string   `json:"type"`
-	Addresses []string `json:"addresses,omitempty"`
-	P2sh      string   `json:"p2sh"`
+	Asm          string   `json:"asm"`
+	ReqSigs      int32    `json:"reqSigs,omitempty"`
+	Type         string   `json:"type"`
+	AdminOp      string   `json:"adminOp,omitempty"`
+	LockTime     uint32   `json:"lockTime,omitempty"`
+	PubKeyHashes []string `json:"pubKeyHashes,omitempty"`
+	KeyIDs       []uint32 `json:"keyIDs,omitempty"`
+	Thread       string   `json:"thread,omitempty"`
+	Operation    string   `json:"operation,omitempty"`
+	KeySet       string   `json:"keySet,omitempty"`
+	PubKey       string   `json:"pubKey,omitempty"`
+	KeyID        uint32   `json:"keyID,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"`
+}
+
+// FinalizePSPTResult models the data returned from the finalizepspt command.
+type FinalizePSPTResult struct {
+	PSPT     string `json:"pspt,omitempty"`
+	Hex      string `json:"hex,omitempty"`
+	Complete bool   `json:"complete"`
 }
 
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
@@ -78,11 +145,96 @@ type GetAddedNodeInfoResult struct {
 	Addresses *[]GetAddedNodeInfoResultAddr `json:"addresses,omitempty"`
 }
 
+// GetAddressBalanceResult models the data from the getaddressbalance command.
+// The amounts are in atoms.
+type GetAddressBalanceResult struct {
+	Balance  int64 `json:"balance"`
+	Received int64 `json:"received"`
+}
+
+// AddressDeltaResult models the data of a single change to the balance of an
+// address returned by the getaddressdeltas command.
+type AddressDeltaResult struct {
+	Address    string `json:"address"`
+	Txid       string `json:"txid"`
+	Index      uint32 `json:"index"`
+	BlockIndex uint32 `json:"blockindex"`
+	Height     uint32 `json:"height"`
+	Atoms      int64  `json:"atoms"`
+}
+
+// AddressMempoolResult models the data of a single change to the balance of an
+// address returned by the getaddressmempool command.
+type AddressMempoolResult struct {
+	Address  string `json:"address"`
+	Txid     string `json:"txid"`
+	Index    uint32 `json:"index"`
+	Atoms    int64  `json:"atoms"`
+	PrevTxid string `json:"prevtxid,omitempty"`
+	PrevOut  uint32 `json:"prevout,omitempty"`
+}
+
+// AddressUtxoResult models the data of a single unspent output returned by the
+// getaddressutxos command.
+type AddressUtxoResult struct {
+	Address     string `json:"address"`
+	Txid        string `json:"txid"`
+	OutputIndex uint32 `json:"outputIndex"`
+	Script      string `json:"script"`
+	Atoms       int64  `json:"atoms"`
+	Height      uint32 `json:"height"`
+}
+
+// GetSpentInfoResult models the data from the getspentinfo command.
+type GetSpentInfoResult struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height uint32 `json:"height"`
+}
+
+// SupplyChangeResult models the data of a single issuance or destruction of
+// funds returned by the getsupplyhistory command.  The amounts are in atoms.
+type SupplyChangeResult struct {
+	Txid       string   `json:"txid"`
+	Height     uint32   `json:"height"`
+	BlockIndex uint32   `json:"blockindex"`
+	Type       string   `json:"type"`
+	Atoms      int64    `json:"atoms"`
+	Supply     uint64   `json:"supply"`
+	Keys       []string `json:"keys"`
+}
+
+// GetCirculatingSupplyResult models the data from the getcirculatingsupply
+// command.  The supply is in atoms.
+type GetCirculatingSupplyResult struct {
+	Height uint32 `json:"height"`
+	Supply uint64 `json:"supply"`
+}
+
+// IndexInfoResult models the data of a single index returned by the
+// getindexinfo command.
+type IndexInfoResult struct {
+	Synced          bool   `json:"synced"`
+	BestBlockHeight int32  `json:"best_block_height"`
+	SizeOnDisk      int64  `json:"size_on_disk"`
+	Health          string `json:"health"`
+	Error           string `json:"error,omitempty"`
+}
+
+// GetKeyIDBalanceResult models the data from the getkeyidbalance command.
+// The balance is in atoms.
+type GetKeyIDBalanceResult struct {
+	KeyID   uint32 `json:"keyid"`
+	Height  uint32 `json:"height"`
+	Balance int64  `json:"balance"`
+}
+
 // ASPKeyIdResult models the data of the ASPKeys portion of the
 // GetAdminInfoResult command.
 type ASPKeyIdResult struct {
 	PubKey string `json:"pubkey"`
 	KeyID  uint32 `json:"keyid"`
+	Frozen bool   `json:"frozen,omitempty"`
 }
 
 // ThreadTipResult
@@ -106,16 +258,565 @@ type GetAdminInfoResult struct {
 	ASPKeys       []ASPKeyIdResult  `json:"aspkeys,omitempty"`
 }
 
+// ValidatorWindowResult models the number of blocks signed by a validate key
+// within a window of the most recent blocks.
+type ValidatorWindowResult struct {
+	Window uint32 `json:"window"`
+	Blocks uint32 `json:"blocks"`
+}
+
+// ValidatorInfoResult models the data of a single validate key in the
+// GetValidatorInfoResult command.
+type ValidatorInfoResult struct {
+	PubKey           string                  `json:"pubkey"`
+	Active           bool                    `json:"active"`
+	LastSeenHeight   *uint32                 `json:"lastseenheight,omitempty"`
+	Windows          []ValidatorWindowResult `json:"windows"`
+	TrailingBlocks   uint32                  `json:"trailingblocks"`
+	TrailingHeadroom uint32                  `json:"trailingheadroom"`
+	ShareHeadroom    uint32                  `json:"shareheadroom"`
+	RateLimited      bool                    `json:"ratelimited"`
+}
+
+// GetValidatorInfoResult models the data from the getvalidatorinfo command.
+type GetValidatorInfoResult struct {
+	Hash          string                `json:"hash"`
+	Height        uint32                `json:"height"`
+	RateWindow    int                   `json:"ratewindow"`
+	TrailingLimit int                   `json:"trailinglimit"`
+	ShareLimit    int                   `json:"sharelimit"`
+	Validators    []ValidatorInfoResult `json:"validators"`
+}
+
+// HeartbeatResult models the data of a heartbeat in the
+// ValidatorHeartbeatResult command.
+type HeartbeatResult struct {
+	Height   uint32 `json:"height"`
+	Hash     string `json:"hash"`
+	Time     int64  `json:"time"`
+	Received int64  `json:"received"`
+	From     string `json:"from,omitempty"`
+}
+
+// ValidatorHeartbeatResult models the data of a single validate key in the
+// getvalidatorheartbeats command.
+type ValidatorHeartbeatResult struct {
+	PubKey        string           `json:"pubkey"`
+	Active        bool             `json:"active"`
+	Local         bool             `json:"local"`
+	Stalled       bool             `json:"stalled"`
+	LastHeartbeat *HeartbeatResult `json:"lastheartbeat,omitempty"`
+}
+
+// ValidatorScheduleResult models the data of a single validate key in the
+// getvalidatorschedule command.
+type ValidatorScheduleResult struct {
+	PubKey              string `json:"pubkey"`
+	Local               bool   `json:"local"`
+	Eligible            bool   `json:"eligible"`
+	BlocksUntilEligible int    `json:"blocksuntileligible"`
+	EligibleHeight      uint32 `json:"eligibleheight"`
+	EstimatedTime       int64  `json:"estimatedtime"`
+}
+
+// RPCActiveCommandResult models the data of a call in progress in the
+// GetRPCInfoResult.
+type RPCActiveCommandResult struct {
+	Method   string `json:"method"`
+	User     string `json:"user"`
+	Duration int64  `json:"duration"`
+}
+
+// RPCMethodStatsResult models the statistics of the calls of a method in the
+// GetRPCInfoResult.
+type RPCMethodStatsResult struct {
+	Method      string `json:"method"`
+	Calls       uint64 `json:"calls"`
+	Errors      uint64 `json:"errors"`
+	RateLimited uint64 `json:"ratelimited"`
+	AvgTime     int64  `json:"avgtime"`
+	MaxTime     int64  `json:"maxtime"`
+}
+
+// GetRPCInfoResult models the data from the getrpcinfo command.
+type GetRPCInfoResult struct {
+	ActiveCommands []RPCActiveCommandResult `json:"activecommands"`
+	Methods        []RPCMethodStatsResult   `json:"methods"`
+}
+
+// BackupChainStateResult models the data from the backupchainstate command.
+type BackupChainStateResult struct {
+	Destination string `json:"destination"`
+	Hash        string `json:"hash"`
+	Height      uint32 `json:"height"`
+}
+
+// DBLatencyResult models the distribution of the latencies of a database
+// operation in the GetDBInfoResult.  The durations are in microseconds.
+type DBLatencyResult struct {
+	Count   uint64   `json:"count"`
+	AvgTime int64    `json:"avgtime"`
+	MaxTime int64    `json:"maxtime"`
+	Buckets []uint64 `json:"buckets"`
+}
+
+// GetDBInfoResult models the data from the getdbinfo command.
+type GetDBInfoResult struct {
+	MetadataReads     uint64          `json:"metadatareads"`
+	CacheHits         uint64          `json:"cachehits"`
+	CacheHitRate      float64         `json:"cachehitrate"`
+	MetadataWrites    uint64          `json:"metadatawrites"`
+	CacheFlushes      uint64          `json:"cacheflushes"`
+	FlushLatency      DBLatencyResult `json:"flushlatency"`
+	WriteStalls       uint64          `json:"writestalls"`
+	WriteStallTime    int64           `json:"writestalltime"`
+	StorageReadBytes  uint64          `json:"storagereadbytes"`
+	StorageWriteBytes uint64          `json:"storagewritebytes"`
+	BlockReads        uint64          `json:"blockreads"`
+	BlockReadBytes    uint64          `json:"blockreadbytes"`
+	BlockReadLatency  DBLatencyResult `json:"blockreadlatency"`
+	BlockWrites       uint64          `json:"blockwrites"`
+	BlockWriteBytes   uint64          `json:"blockwritebytes"`
+	BlockWriteLatency DBLatencyResult `json:"blockwritelatency"`
+	LatencyBuckets    []int64         `json:"latencybuckets"`
+}
+
+// DiagnosticsMemoryResult models the memory statistics of the
+// GetDiagnosticsResult.  The sizes are in bytes.
+type DiagnosticsMemoryResult struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"totalalloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heapalloc"`
+	HeapInuse    uint64 `json:"heapinuse"`
+	HeapIdle     uint64 `json:"heapidle"`
+	HeapReleased uint64 `json:"heapreleased"`
+	HeapObjects  uint64 `json:"heapobjects"`
+	StackInuse   uint64 `json:"stackinuse"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+}
+
+// DiagnosticsGCResult models the garbage collection statistics of the
+// GetDiagnosticsResult.  The pauses are in microseconds.
+type DiagnosticsGCResult struct {
+	NumGC         int64   `json:"numgc"`
+	LastGC        int64   `json:"lastgc"`
+	NextGC        uint64  `json:"nextgc"`
+	PauseTotal    int64   `json:"pausetotal"`
+	RecentPauses  []int64 `json:"recentpauses"`
+	GCCPUFraction float64 `json:"gccpufraction"`
+}
+
+// GetDiagnosticsResult models the data from the getdiagnostics command.
+type GetDiagnosticsResult struct {
+	GoVersion     string                  `json:"goversion"`
+	NumCPU        int                     `json:"numcpu"`
+	GoMaxProcs    int                     `json:"gomaxprocs"`
+	Goroutines    int                     `json:"goroutines"`
+	Memory        DiagnosticsMemoryResult `json:"memory"`
+	GC            DiagnosticsGCResult     `json:"gc"`
+	CPUProfile    string                  `json:"cpuprofile,omitempty"`
+	ProfileServer string                  `json:"profileserver,omitempty"`
+}
+
+// RotateRPCAuthResult models the data from the rotaterpcauth command.
+type RotateRPCAuthResult struct {
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	CookieFile string `json:"cookiefile,omitempty"`
+}
+
+// KeyIDVelocityWindowResult models the volume spent by a keyID within the
+// window of a velocity limit in the GetKeyIDVelocityResult.  The window is in
+// seconds and the amounts are in RMG.
+type KeyIDVelocityWindowResult struct {
+	Window    int64   `json:"window"`
+	MaxAmount float64 `json:"maxamount"`
+	Spent     float64 `json:"spent"`
+	Remaining float64 `json:"remaining"`
+}
+
+// GetKeyIDVelocityResult models the data from the getkeyidvelocity command.
+type GetKeyIDVelocityResult struct {
+	KeyID         uint32                      `json:"keyid"`
+	Windows       []KeyIDVelocityWindowResult `json:"windows"`
+	OverrideUntil int64                       `json:"overrideuntil,omitempty"`
+}
+
+// AuditLogEntryResult models a single entry of the audit log, as returned by
+// the getauditlog command.
+type AuditLogEntryResult struct {
+	Seq      uint64            `json:"seq"`
+	Time     int64             `json:"time"`
+	Kind     string            `json:"kind"`
+	Action   string            `json:"action"`
+	Details  map[string]string `json:"details,omitempty"`
+	PrevHash string            `json:"prevhash"`
+	Hash     string            `json:"hash"`
+}
+
+// GetAuditLogResult models the data from the getauditlog command.
+type GetAuditLogResult struct {
+	Last     uint64                `json:"last"`
+	LastHash string                `json:"lasthash"`
+	Entries  []AuditLogEntryResult `json:"entries"`
+}
+
+// VerifyAuditLogResult models the data from the verifyauditlog command.
+type VerifyAuditLogResult struct {
+	Valid        bool   `json:"valid"`
+	Entries      uint64 `json:"entries"`
+	LastHash     string `json:"lasthash"`
+	InvalidEntry uint64 `json:"invalidentry,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// AttestationResult models a signed attestation of the chain state, as
+// returned by the getattestation command.
+type AttestationResult struct {
+	Network     string `json:"network"`
+	Height      uint32 `json:"height"`
+	Hash        string `json:"hash"`
+	UtxoSetHash string `json:"utxosethash"`
+	Utxos       uint64 `json:"utxos"`
+	UtxoAmount  uint64 `json:"utxoamount"`
+	Supply      uint64 `json:"supply"`
+	Time        int64  `json:"time"`
+	PubKey      string `json:"pubkey"`
+	Signature   string `json:"signature"`
+	Hex         string `json:"hex"`
+}
+
+// VerifyAttestationResult models the data from the verifyattestation command.
+type VerifyAttestationResult struct {
+	Attestation AttestationResult `json:"attestation"`
+	Valid       bool              `json:"valid"`
+	Validator   bool              `json:"validator"`
+	MainChain   bool              `json:"mainchain"`
+}
+
+// SPVWatchedAddressResult models a watched address of the light client, as
+// returned by the getspvinfo command.
+type SPVWatchedAddressResult struct {
+	Address string `json:"address"`
+	Height  uint32 `json:"height"`
+}
+
+// GetSPVInfoResult models the data from the getspvinfo command.
+type GetSPVInfoResult struct {
+	Height         uint32                    `json:"height"`
+	Hash           string                    `json:"hash"`
+	VerifiedHeight uint32                    `json:"verifiedheight"`
+	VerifiedHash   string                    `json:"verifiedhash"`
+	Peers          int32                     `json:"peers"`
+	ValidateKeys   []string                  `json:"validatekeys"`
+	Watched        []SPVWatchedAddressResult `json:"watched"`
+}
+
+// AdminListKeySetsResult models the data from the admin.listkeysets command.
+type AdminListKeySetsResult struct {
+	Hash      string           `json:"hash"`
+	Height    uint32           `json:"height"`
+	LastKeyID uint32           `json:"lastkeyid"`
+	Root      []string         `json:"root"`
+	Provision []string         `json:"provision"`
+	Issue     []string         `json:"issue"`
+	Validate  []string         `json:"validate"`
+	ASP       []ASPKeyIdResult `json:"asp"`
+}
+
+// AdminKeyIDInfoResult models the data from the admin.getkeyidinfo command.
+type AdminKeyIDInfoResult struct {
+	KeyID  uint32 `json:"keyid"`
+	Active bool   `json:"active"`
+	Frozen bool   `json:"frozen"`
+	PubKey string `json:"pubkey,omitempty"`
+}
+
+// AdminTxResult models the data from the admin.* commands creating admin
+// transactions.
+type AdminTxResult struct {
+	TxID      string                    `json:"txid"`
+	Hex       string                    `json:"hex"`
+	Complete  bool                      `json:"complete"`
+	Submitted bool                      `json:"submitted"`
+	Errors    []SignRawTransactionError `json:"errors,omitempty"`
+}
+
+// AdminRotateKeyIDResult models the data from the admin.rotatekeyid command.
+type AdminRotateKeyIDResult struct {
+	KeyID     uint32              `json:"keyid"`
+	NewKeyID  uint32              `json:"newkeyid"`
+	Provision *AdminTxResult      `json:"provision"`
+	Sweeps    []TemplateTxResult  `json:"sweeps"`
+	Skipped   []AddressUtxoResult `json:"skipped"`
+}
+
+// AdminProposalInputResult models the signatures collected for an input of a
+// proposed admin transaction.
+type AdminProposalInputResult struct {
+	RequiredSigs int      `json:"requiredsigs"`
+	Signers      []string `json:"signers"`
+	Finalized    bool     `json:"finalized"`
+}
+
+// AdminProposalResult models the data from the admin.proposetx,
+// admin.getproposal, admin.signproposal and admin.addproposalsigs commands.
+type AdminProposalResult struct {
+	TxID        string                     `json:"txid"`
+	Description string                     `json:"description"`
+	Created     int64                      `json:"created"`
+	PSPT        string                     `json:"pspt"`
+	Inputs      []AdminProposalInputResult `json:"inputs"`
+	Complete    bool                       `json:"complete"`
+	Submitted   bool                       `json:"submitted"`
+	Hex         string                     `json:"hex,omitempty"`
+	SubmitError string                     `json:"submiterror,omitempty"`
+}
+
+// ConsistencyCheckResult models the data of a single consistency check in the
+// GetConsistencyStatusResult command.
+type ConsistencyCheckResult struct {
+	Hash           string   `json:"hash"`
+	Height         uint32   `json:"height"`
+	KeyViewBlocks  uint32   `json:"keyviewblocks"`
+	UndoBlocks     uint32   `json:"undoblocks"`
+	SampledOutputs uint32   `json:"sampledoutputs"`
+	Consistent     bool     `json:"consistent"`
+	Mismatches     []string `json:"mismatches"`
+}
+
+// GetClusterInfoResult models the data from the getclusterinfo command.
+type GetClusterInfoResult struct {
+	Role          string `json:"role"`
+	State         string `json:"state"`
+	Lockout       int64  `json:"lockout"`
+	ActiveSince   int64  `json:"activesince,omitempty"`
+	LastHeartbeat int64  `json:"lastheartbeat,omitempty"`
+	PeerHeight    uint32 `json:"peerheight"`
+	PeerPubKey    string `json:"peerpubkey,omitempty"`
+}
+
+// SignGuardRecordResult models the last block released for a validate key as
+// returned by the resetsignguard command.
+type SignGuardRecordResult struct {
+	Height    uint32 `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Hash      string `json:"hash"`
+}
+
+// GetConsistencyStatusResult models the data from the getconsistencystatus
+// command.
+type GetConsistencyStatusResult struct {
+	Enabled        bool                    `json:"enabled"`
+	Interval       int64                   `json:"interval"`
+	HaltOnMismatch bool                    `json:"haltonmismatch"`
+	Runs           uint64                  `json:"runs"`
+	FailedRuns     uint64                  `json:"failedruns"`
+	MismatchRuns   uint64                  `json:"mismatchruns"`
+	LastRun        int64                   `json:"lastrun,omitempty"`
+	LastDuration   float64                 `json:"lastduration,omitempty"`
+	LastError      string                  `json:"lasterror,omitempty"`
+	LastCheck      *ConsistencyCheckResult `json:"lastcheck,omitempty"`
+}
+
+// EventLogEntryResult models a single event of the event log, as returned by
+// the geteventlog command and the eventlogged notification.
+type EventLogEntryResult struct {
+	Seq    uint64 `json:"seq"`
+	Type   string `json:"type"`
+	Time   int64  `json:"time"`
+	Hash   string `json:"hash"`
+	Height uint32 `json:"height,omitempty"`
+	KeySet string `json:"keyset,omitempty"`
+	PubKey string `json:"pubkey,omitempty"`
+	KeyID  uint32 `json:"keyid,omitempty"`
+}
+
+// GetEventLogResult models the data from the geteventlog command.
+type GetEventLogResult struct {
+	First  uint64                `json:"first"`
+	Last   uint64                `json:"last"`
+	Next   uint64                `json:"next"`
+	Events []EventLogEntryResult `json:"events"`
+}
+
+// ExplorerBlockResult models a summary of a block, as returned by the
+// getexplorerblocks command.
+type ExplorerBlockResult struct {
+	Hash             string  `json:"hash"`
+	Height           uint32  `json:"height"`
+	Time             int64   `json:"time"`
+	Txs              int     `json:"txs"`
+	Size             int     `json:"size"`
+	Atoms            int64   `json:"atoms"`
+	Fees             int64   `json:"fees"`
+	Difficulty       float64 `json:"difficulty"`
+	ValidatingPubKey string  `json:"validatingpubkey"`
+}
+
+// ExplorerVinResult models an input of a transaction along with the output it
+// spends, as returned by the getexplorertransaction command.
+type ExplorerVinResult struct {
+	Coinbase  bool     `json:"coinbase,omitempty"`
+	Txid      string   `json:"txid,omitempty"`
+	Vout      uint32   `json:"vout"`
+	Type      string   `json:"type,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	KeyIDs    []uint32 `json:"keyids,omitempty"`
+	Atoms     int64    `json:"atoms"`
+}
+
+// ExplorerVoutResult models an output of a transaction along with the input
+// spending it, as returned by the getexplorertransaction command.
+type ExplorerVoutResult struct {
+	N           uint32   `json:"n"`
+	Type        string   `json:"type"`
+	Addresses   []string `json:"addresses,omitempty"`
+	KeyIDs      []uint32 `json:"keyids,omitempty"`
+	Atoms       int64    `json:"atoms"`
+	SpentTxid   string   `json:"spenttxid,omitempty"`
+	SpentIndex  uint32   `json:"spentindex,omitempty"`
+	SpentHeight uint32   `json:"spentheight,omitempty"`
+}
+
+// ExplorerTxResult models the data from the getexplorertransaction command.
+type ExplorerTxResult struct {
+	Txid          string               `json:"txid"`
+	Version       int32                `json:"version"`
+	LockTime      uint32               `json:"locktime"`
+	Size          int                  `json:"size"`
+	BlockHash     string               `json:"blockhash,omitempty"`
+	Height        uint32               `json:"height,omitempty"`
+	Time          int64                `json:"time,omitempty"`
+	Confirmations uint32               `json:"confirmations"`
+	Admin         bool                 `json:"admin"`
+	Fee           int64                `json:"fee"`
+	Vin           []ExplorerVinResult  `json:"vin"`
+	Vout          []ExplorerVoutResult `json:"vout"`
+}
+
+// ExplorerAddressTxResult models a transaction changing the balance of an
+// address, as returned by the getexploreraddress command.
+type ExplorerAddressTxResult struct {
+	Txid     string `json:"txid"`
+	Height   uint32 `json:"height"`
+	Time     int64  `json:"time"`
+	Received int64  `json:"received"`
+	Sent     int64  `json:"sent"`
+}
+
+// ExplorerAddressResult models the data from the getexploreraddress command.
+type ExplorerAddressResult struct {
+	Address string                    `json:"address"`
+	Txs     []ExplorerAddressTxResult `json:"txs"`
+	Next    string                    `json:"next,omitempty"`
+}
+
+// ExplorerDayResult models the activity of the chain during a day, as returned
+// by the getexplorercharts command.
+type ExplorerDayResult struct {
+	Date         string `json:"date"`
+	Time         int64  `json:"time"`
+	Blocks       uint32 `json:"blocks"`
+	Txs          uint64 `json:"txs"`
+	Fees         int64  `json:"fees"`
+	ActiveKeyIDs uint32 `json:"activekeyids"`
+}
+
+// GetUtxoCommitmentResult models the data from the getutxocommitment command.
+type GetUtxoCommitmentResult struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+	Root   string `json:"root"`
+}
+
+// GetUtxoProofResult models the data from the getutxoproof command.
+type GetUtxoProofResult struct {
+	Height       uint32 `json:"height"`
+	Hash         string `json:"hash"`
+	Root         string `json:"root"`
+	Bin          uint32 `json:"bin"`
+	BinOutputs   int    `json:"binoutputs"`
+	Unspent      bool   `json:"unspent"`
+	Amount       int64  `json:"amount,omitempty"`
+	ScriptPubKey string `json:"scriptpubkey,omitempty"`
+	Proof        string `json:"proof"`
+}
+
+// VerifyUtxoProofResult models the data from the verifyutxoproof command.
+type VerifyUtxoProofResult struct {
+	Valid        bool   `json:"valid"`
+	Error        string `json:"error,omitempty"`
+	Height       uint32 `json:"height"`
+	Hash         string `json:"hash"`
+	Root         string `json:"root"`
+	Unspent      bool   `json:"unspent"`
+	Amount       int64  `json:"amount,omitempty"`
+	ScriptPubKey string `json:"scriptpubkey,omitempty"`
+	MainChain    bool   `json:"mainchain"`
+}
+
+// GetHashCacheInfoResult models the data from the gethashcacheinfo command.
+type GetHashCacheInfoResult struct {
+	Entries    uint64  `json:"entries"`
+	MaxEntries uint64  `json:"maxentries"`
+	Digests    uint64  `json:"digests"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	HitRate    float64 `json:"hitrate"`
+}
+
 // GetBlockChainInfoResult models the data returned from the getblockchaininfo
 // command.
 type GetBlockChainInfoResult struct {
-	Chain                string  `json:"chain"`
-	Blocks               int32   `json:"blocks"`
-	Headers              int32   `json:"headers"`
-	BestBlockHash        string  `json:"bestblockhash"`
-	Difficulty           float64 `json:"difficulty"`
-	VerificationProgress float64 `json:"verificationprogress"`
-	ChainWork            string  `json:"chainwork"`
+	Chain           string                `json:"chain"`
+	Blocks          int32                 `json:"blocks"`
+	Headers         int32                 `json:"headers"`
+	BestBlockHash   string                `json:"bestblockhash"`
+	Difficulty      float64               `json:"difficulty"`
+	AdminThresholds AdminThresholdsResult `json:"adminthresholds"`
+	ThreadTips      []ThreadTipResult     `json:"threadtips"`
+	KeyCounts       KeyCountsResult       `json:"keycounts"`
+	TotalSupply     uint64                `json:"totalsupply"`
+	Issuance        *IssuanceResult       `json:"issuance,omitempty"`
+	SoftForks       []SoftForkResult      `json:"softforks"`
+}
+
+// KeyCountsResult models the numbers of keys of each type in the
+// getblockchaininfo result.
+type KeyCountsResult struct {
+	Root      int `json:"root"`
+	Provision int `json:"provision"`
+	Issue     int `json:"issue"`
+	Validate  int `json:"validate"`
+	ASP       int `json:"asp"`
+	Frozen    int `json:"frozen"`
+}
+
+// IssuanceResult models the issuances and destructions of funds in the
+// getblockchaininfo result.
+type IssuanceResult struct {
+	LastIssueHeight uint32 `json:"lastissueheight"`
+	Issued          uint64 `json:"issued"`
+	Destroyed       uint64 `json:"destroyed"`
+}
+
+// SoftForkResult models a consensus rule change activated at a height in the
+// getblockchaininfo result.
+type SoftForkResult struct {
+	ID               string  `json:"id"`
+	ActivationHeight *uint32 `json:"activationheight,omitempty"`
+	Active           bool    `json:"active"`
+}
+
+// AdminThresholdsResult models the numbers of signatures the admin threads
+// require in the getblockchaininfo result.
+type AdminThresholdsResult struct {
+	Root      int `json:"root"`
+	Provision int `json:"provision"`
+	Issue     int `json:"issue"`
 }
 
 // GetBlockTemplateResultTx models the transactions field of the
@@ -210,29 +911,68 @@ type GetNetworkInfoResult struct {
 	LocalAddresses  []LocalAddressesResult `json:"localaddresses"`
 }
 
+// OrphanBlockResult models the data of a single block in the getorphanblocks
+// command.
+type OrphanBlockResult struct {
+	Hash             string `json:"hash"`
+	PreviousHash     string `json:"previousblockhash"`
+	Height           uint32 `json:"height"`
+	Size             int32  `json:"size"`
+	Root             string `json:"root"`
+	MissingBlockHash string `json:"missingblockhash"`
+	Received         int64  `json:"received"`
+	Expires          int64  `json:"expires"`
+}
+
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
-	ID             int32   `json:"id"`
-	Addr           string  `json:"addr"`
-	AddrLocal      string  `json:"addrlocal,omitempty"`
-	Services       string  `json:"services"`
-	RelayTxes      bool    `json:"relaytxes"`
-	LastSend       int64   `json:"lastsend"`
-	LastRecv       int64   `json:"lastrecv"`
-	BytesSent      uint64  `json:"bytessent"`
-	BytesRecv      uint64  `json:"bytesrecv"`
-	ConnTime       int64   `json:"conntime"`
-	TimeOffset     int64   `json:"timeoffset"`
-	PingTime       float64 `json:"pingtime"`
-	PingWait       float64 `json:"pingwait,omitempty"`
-	Version        uint32  `json:"version"`
-	SubVer         string  `json:"subver"`
-	Inbound        bool    `json:"inbound"`
-	StartingHeight uint32  `json:"startingheight"`
-	CurrentHeight  uint32  `json:"currentheight,omitempty"`
-	BanScore       int32   `json:"banscore"`
-	FeeFilter      int64   `json:"feefilter"`
-	SyncNode       bool    `json:"syncnode"`
+	ID             int32             `json:"id"`
+	Addr           string            `json:"addr"`
+	AddrLocal      string            `json:"addrlocal,omitempty"`
+	Services       string            `json:"services"`
+	RelayTxes      bool              `json:"relaytxes"`
+	LastSend       int64             `json:"lastsend"`
+	LastRecv       int64             `json:"lastrecv"`
+	BytesSent      uint64            `json:"bytessent"`
+	BytesRecv      uint64            `json:"bytesrecv"`
+	BytesSentMsg   map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvMsg   map[string]uint64 `json:"bytesrecv_per_msg"`
+	SendRate       int64             `json:"sendrate"`
+	RecvRate       int64             `json:"recvrate"`
+	SendLimit      int64             `json:"sendlimit"`
+	RecvLimit      int64             `json:"recvlimit"`
+	ConnTime       int64             `json:"conntime"`
+	TimeOffset     int64             `json:"timeoffset"`
+	PingTime       float64           `json:"pingtime"`
+	PingWait       float64           `json:"pingwait,omitempty"`
+	PingHistory    []float64         `json:"pinghistory"`
+	PingVariance   float64           `json:"pingvariance"`
+	Version        uint32            `json:"version"`
+	SubVer         string            `json:"subver"`
+	Inbound        bool              `json:"inbound"`
+	StartingHeight uint32            `json:"startingheight"`
+	CurrentHeight  uint32            `json:"currentheight,omitempty"`
+	BanScore       int32             `json:"banscore"`
+	FeeFilter      int64             `json:"feefilter"`
+	SyncNode       bool              `json:"syncnode"`
+	BlocksRecv     uint64            `json:"blocksrecv"`
+	BlocksAccepted uint64            `json:"blocksaccepted"`
+	TxsRecv        uint64            `json:"txsrecv"`
+	TxsAccepted    uint64            `json:"txsaccepted"`
+	SendHeaders    bool              `json:"sendheaders"`
+	CmpctBlocks    bool              `json:"cmpctblocks"`
+	CmpctBlocksHB  bool              `json:"cmpctblockshb"`
+	AddrV2         bool              `json:"addrv2"`
+	Validator      bool              `json:"validator"`
+	FedMember      string            `json:"federationmember,omitempty"`
+}
+
+// ListBannedResult models the data returned from the listbanned command.
+type ListBannedResult struct {
+	Address     string `json:"address"`
+	BannedUntil int64  `json:"banned_until"`
+	BanCreated  int64  `json:"ban_created"`
+	BanReason   string `json:"ban_reason"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -251,12 +991,20 @@ type GetRawMempoolVerboseResult struct {
 // ScriptPubKeyResult models the scriptPubKey data of a tx script.  It is
 // defined separately since it is used by multiple commands.
 type ScriptPubKeyResult struct {
-	Asm       string   `json:"asm"`
-	Hex       string   `json:"hex,omitempty"`
-	ReqSigs   int32    `json:"reqSigs,omitempty"`
-	Type      string   `json:"type"`
-	AdminOp   string   `json:"adminOp,omitempty"`
-	Addresses []string `json:"addresses,omitempty"`
+	Asm          string   `json:"asm"`
+	Hex          string   `json:"hex,omitempty"`
+	ReqSigs      int32    `json:"reqSigs,omitempty"`
+	Type         string   `json:"type"`
+	AdminOp      string   `json:"adminOp,omitempty"`
+	LockTime     uint32   `json:"lockTime,omitempty"`
+	PubKeyHashes []string `json:"pubKeyHashes,omitempty"`
+	KeyIDs       []uint32 `json:"keyIDs,omitempty"`
+	Thread       string   `json:"thread,omitempty"`
+	Operation    string   `json:"operation,omitempty"`
+	KeySet       string   `json:"keySet,omitempty"`
+	PubKey       string   `json:"pubKey,omitempty"`
+	KeyID        uint32   `json:"keyID,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"`
 }
 
 // GetTxOutResult models the data from the gettxout command.
@@ -331,6 +1079,8 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 type PrevOut struct {
 	Addresses []string `json:"addresses,omitempty"`
 	Value     float64  `json:"value"`
+	Type      string   `json:"type,omitempty"`
+	KeyIDs    []uint32 `json:"keyIDs,omitempty"`
 }
 
 // VinPrevOut is like Vin except it includes PrevOut.  It is used by searchrawtransaction
@@ -482,3 +1232,92 @@ type ValidateAddressChainResult struct {
 	IsValid bool   `json:"isvalid"`
 	Address string `json:"address,omitempty"`
 }
+
+// HealthCheckResult models a single health check of the gethealth command.
+type HealthCheckResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// GetHealthResult models the data from the gethealth command, which is also
+// served by the health endpoints.
+type GetHealthResult struct {
+	Live   bool                `json:"live"`
+	Ready  bool                `json:"ready"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// GetLogLevelsResult models the data from the getloglevels command.
+type GetLogLevelsResult struct {
+	Format string            `json:"format"`
+	Levels map[string]string `json:"levels"`
+}
+
+// MessageSignerResult models the key of a Prova address which signed a
+// message.  The message was signed either by the holder key at index Holder
+// among the keys the address commits to, or by the ASP key with key id KeyID.
+type MessageSignerResult struct {
+	PubKey string  `json:"pubkey"`
+	Holder *int    `json:"holder,omitempty"`
+	KeyID  *uint32 `json:"keyid,omitempty"`
+}
+
+// SignMessageWithPrivKeyResult models the data from the signmessagewithprivkey
+// command.
+type SignMessageWithPrivKeyResult struct {
+	Signature string              `json:"signature"`
+	Signer    MessageSignerResult `json:"signer"`
+}
+
+// VerifyMessageResult models the data from the verifymessage command when the
+// verbose flag is set.
+type VerifyMessageResult struct {
+	Verified bool                 `json:"verified"`
+	Signer   *MessageSignerResult `json:"signer,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// BroadcastTransactionResult models the data from the broadcasttransaction
+// command.
+type BroadcastTransactionResult struct {
+	ID   uint64 `json:"id"`
+	TxID string `json:"txid"`
+}
+
+// BroadcastStatusResult models the status of a broadcast campaign, as returned
+// by the getbroadcaststatus command and the broadcastfinished notification.
+type BroadcastStatusResult struct {
+	ID                    uint64 `json:"id"`
+	TxID                  string `json:"txid"`
+	Status                string `json:"status"`
+	Final                 bool   `json:"final"`
+	TargetConfs           uint32 `json:"targetconfs"`
+	Confirmations         uint32 `json:"confirmations"`
+	BlockHash             string `json:"blockhash,omitempty"`
+	BlockHeight           uint32 `json:"blockheight,omitempty"`
+	PeersRequested        int    `json:"peersrequested"`
+	PeersAnnounced        int    `json:"peersannounced"`
+	Unconfirmations       uint32 `json:"unconfirmations"`
+	ConflictTxID          string `json:"conflicttxid,omitempty"`
+	ConflictConfirmations uint32 `json:"conflictconfirmations,omitempty"`
+	Started               int64  `json:"started"`
+	Finished              int64  `json:"finished,omitempty"`
+}
+
+// FundRawTransactionResult models the data from the fundrawtransaction
+// command.
+type FundRawTransactionResult struct {
+	Hex       string              `json:"hex"`
+	Fee       float64             `json:"fee"`
+	ChangePos int                 `json:"changepos"`
+	PrevOuts  []AddressUtxoResult `json:"prevouts"`
+}
+
+// TemplateTxResult models the data from the createsweeptx,
+// createconsolidatetx and createsplittx commands.
+type TemplateTxResult struct {
+	Hex      string              `json:"hex"`
+	Fee      float64             `json:"fee"`
+	PrevOuts []AddressUtxoResult `json:"prevouts"`
+}
@@ -23,6 +23,42 @@ func NewAuthenticateCmd(username, passphrase string) *AuthenticateCmd {
 	}
 }
 
+// NotifyEventsCmd defines the notifyevents JSON-RPC command.
+type NotifyEventsCmd struct{}
+
+// NewNotifyEventsCmd returns a new instance which can be used to issue a
+// notifyevents JSON-RPC command.
+func NewNotifyEventsCmd() *NotifyEventsCmd {
+	return &NotifyEventsCmd{}
+}
+
+// StopNotifyEventsCmd defines the stopnotifyevents JSON-RPC command.
+type StopNotifyEventsCmd struct{}
+
+// NewStopNotifyEventsCmd returns a new instance which can be used to issue a
+// stopnotifyevents JSON-RPC command.
+func NewStopNotifyEventsCmd() *StopNotifyEventsCmd {
+	return &StopNotifyEventsCmd{}
+}
+
+// NotifyBroadcastsCmd defines the notifybroadcasts JSON-RPC command.
+type NotifyBroadcastsCmd struct{}
+
+// NewNotifyBroadcastsCmd returns a new instance which can be used to issue a
+// notifybroadcasts JSON-RPC command.
+func NewNotifyBroadcastsCmd() *NotifyBroadcastsCmd {
+	return &NotifyBroadcastsCmd{}
+}
+
+// StopNotifyBroadcastsCmd defines the stopnotifybroadcasts JSON-RPC command.
+type StopNotifyBroadcastsCmd struct{}
+
+// NewStopNotifyBroadcastsCmd returns a new instance which can be used to issue
+// a stopnotifybroadcasts JSON-RPC command.
+func NewStopNotifyBroadcastsCmd() *StopNotifyBroadcastsCmd {
+	return &StopNotifyBroadcastsCmd{}
+}
+
 // NotifyBlocksCmd defines the notifyblocks JSON-RPC command.
 type NotifyBlocksCmd struct{}
 
@@ -83,15 +119,20 @@ func NewStopNotifyNewTransactionsCmd() *StopNotifyNewTransactionsCmd {
 // NOTE: Deprecated. Use LoadTxFilterCmd instead.
 type NotifyReceivedCmd struct {
 	Addresses []string
+	KeyIDs    *[]uint32
 }
 
 // NewNotifyReceivedCmd returns a new instance which can be used to issue a
 // notifyreceived JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: Deprecated. Use NewLoadTxFilterCmd instead.
-func NewNotifyReceivedCmd(addresses []string) *NotifyReceivedCmd {
+func NewNotifyReceivedCmd(addresses []string, keyIDs *[]uint32) *NotifyReceivedCmd {
 	return &NotifyReceivedCmd{
 		Addresses: addresses,
+		KeyIDs:    keyIDs,
 	}
 }
 
@@ -111,18 +152,25 @@ type LoadTxFilterCmd struct {
 	Reload    bool
 	Addresses []string
 	OutPoints []OutPoint
+	KeyIDs    *[]uint32
 }
 
 // NewLoadTxFilterCmd returns a new instance which can be used to issue a
 // loadtxfilter JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: This is a btcd extension ported from github.com/decred/dcrd/dcrjson
 // and requires a websocket connection.
-func NewLoadTxFilterCmd(reload bool, addresses []string, outPoints []OutPoint) *LoadTxFilterCmd {
+func NewLoadTxFilterCmd(reload bool, addresses []string, outPoints []OutPoint,
+	keyIDs *[]uint32) *LoadTxFilterCmd {
+
 	return &LoadTxFilterCmd{
 		Reload:    reload,
 		Addresses: addresses,
 		OutPoints: outPoints,
+		KeyIDs:    keyIDs,
 	}
 }
 
@@ -131,15 +179,20 @@ func NewLoadTxFilterCmd(reload bool, addresses []string, outPoints []OutPoint) *
 // NOTE: Deprecated. Use LoadTxFilterCmd instead.
 type NotifySpentCmd struct {
 	OutPoints []OutPoint
+	KeyIDs    *[]uint32
 }
 
 // NewNotifySpentCmd returns a new instance which can be used to issue a
 // notifyspent JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: Deprecated. Use NewLoadTxFilterCmd instead.
-func NewNotifySpentCmd(outPoints []OutPoint) *NotifySpentCmd {
+func NewNotifySpentCmd(outPoints []OutPoint, keyIDs *[]uint32) *NotifySpentCmd {
 	return &NotifySpentCmd{
 		OutPoints: outPoints,
+		KeyIDs:    keyIDs,
 	}
 }
 
@@ -148,15 +201,20 @@ func NewNotifySpentCmd(outPoints []OutPoint) *NotifySpentCmd {
 // NOTE: Deprecated. Use LoadTxFilterCmd instead.
 type StopNotifyReceivedCmd struct {
 	Addresses []string
+	KeyIDs    *[]uint32
 }
 
 // NewStopNotifyReceivedCmd returns a new instance which can be used to issue a
 // stopnotifyreceived JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: Deprecated. Use NewLoadTxFilterCmd instead.
-func NewStopNotifyReceivedCmd(addresses []string) *StopNotifyReceivedCmd {
+func NewStopNotifyReceivedCmd(addresses []string, keyIDs *[]uint32) *StopNotifyReceivedCmd {
 	return &StopNotifyReceivedCmd{
 		Addresses: addresses,
+		KeyIDs:    keyIDs,
 	}
 }
 
@@ -165,15 +223,20 @@ func NewStopNotifyReceivedCmd(addresses []string) *StopNotifyReceivedCmd {
 // NOTE: Deprecated. Use LoadTxFilterCmd instead.
 type StopNotifySpentCmd struct {
 	OutPoints []OutPoint
+	KeyIDs    *[]uint32
 }
 
 // NewStopNotifySpentCmd returns a new instance which can be used to issue a
 // stopnotifyspent JSON-RPC command.
 //
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
 // NOTE: Deprecated. Use NewLoadTxFilterCmd instead.
-func NewStopNotifySpentCmd(outPoints []OutPoint) *StopNotifySpentCmd {
+func NewStopNotifySpentCmd(outPoints []OutPoint, keyIDs *[]uint32) *StopNotifySpentCmd {
 	return &StopNotifySpentCmd{
 		OutPoints: outPoints,
+		KeyIDs:    keyIDs,
 	}
 }
 
@@ -221,6 +284,26 @@ func NewRescanBlocksCmd(blockHashes []string) *RescanBlocksCmd {
 	return &RescanBlocksCmd{BlockHashes: blockHashes}
 }
 
+// WatchCmd defines the watch JSON-RPC command.
+type WatchCmd struct {
+	Addresses []string
+	KeyIDs    *[]uint32
+	Birthday  *uint32 `jsonrpcdefault:"0"`
+}
+
+// NewWatchCmd returns a new instance which can be used to issue a watch
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewWatchCmd(addresses []string, keyIDs *[]uint32, birthday *uint32) *WatchCmd {
+	return &WatchCmd{
+		Addresses: addresses,
+		KeyIDs:    keyIDs,
+		Birthday:  birthday,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets.
 	flags := UFWebsocketOnly
@@ -228,14 +311,19 @@ func init() {
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifybroadcasts", (*NotifyBroadcastsCmd)(nil), flags)
+	MustRegisterCmd("notifyevents", (*NotifyEventsCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifybroadcasts", (*StopNotifyBroadcastsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyevents", (*StopNotifyEventsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("rescan", (*RescanCmd)(nil), flags)
 	MustRegisterCmd("rescanblocks", (*RescanBlocksCmd)(nil), flags)
+	MustRegisterCmd("watch", (*WatchCmd)(nil), flags)
 }
@@ -63,6 +63,50 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyblocks","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyBlocksCmd{},
 		},
+		{
+			name: "notifybroadcasts",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifybroadcasts")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyBroadcastsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifybroadcasts","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyBroadcastsCmd{},
+		},
+		{
+			name: "stopnotifybroadcasts",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifybroadcasts")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyBroadcastsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifybroadcasts","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyBroadcastsCmd{},
+		},
+		{
+			name: "notifyevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifyevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyEventsCmd{},
+		},
+		{
+			name: "stopnotifyevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyEventsCmd{},
+		},
 		{
 			name: "notifynewtransactions",
 			newCmd: func() (interface{}, error) {
@@ -106,20 +150,34 @@ func TestChainSvrWsCmds(t *testing.T) {
 				return btcjson.NewCmd("notifyreceived", []string{"1Address"})
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewNotifyReceivedCmd([]string{"1Address"})
+				return btcjson.NewNotifyReceivedCmd([]string{"1Address"}, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"notifyreceived","params":[["1Address"]],"id":1}`,
 			unmarshalled: &btcjson.NotifyReceivedCmd{
 				Addresses: []string{"1Address"},
 			},
 		},
+		{
+			name: "notifyreceived keyIDs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyreceived", []string{}, `[1,2]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyReceivedCmd([]string{}, &[]uint32{1, 2})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyreceived","params":[[],[1,2]],"id":1}`,
+			unmarshalled: &btcjson.NotifyReceivedCmd{
+				Addresses: []string{},
+				KeyIDs:    &[]uint32{1, 2},
+			},
+		},
 		{
 			name: "stopnotifyreceived",
 			newCmd: func() (interface{}, error) {
 				return btcjson.NewCmd("stopnotifyreceived", []string{"1Address"})
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewStopNotifyReceivedCmd([]string{"1Address"})
+				return btcjson.NewStopNotifyReceivedCmd([]string{"1Address"}, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"stopnotifyreceived","params":[["1Address"]],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyReceivedCmd{
@@ -133,13 +191,27 @@ func TestChainSvrWsCmds(t *testing.T) {
 			},
 			staticCmd: func() interface{} {
 				ops := []btcjson.OutPoint{{Hash: "123", Index: 0}}
-				return btcjson.NewNotifySpentCmd(ops)
+				return btcjson.NewNotifySpentCmd(ops, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"notifyspent","params":[[{"hash":"123","index":0}]],"id":1}`,
 			unmarshalled: &btcjson.NotifySpentCmd{
 				OutPoints: []btcjson.OutPoint{{Hash: "123", Index: 0}},
 			},
 		},
+		{
+			name: "notifyspent keyIDs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyspent", `[]`, `[3]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifySpentCmd([]btcjson.OutPoint{}, &[]uint32{3})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyspent","params":[[],[3]],"id":1}`,
+			unmarshalled: &btcjson.NotifySpentCmd{
+				OutPoints: []btcjson.OutPoint{},
+				KeyIDs:    &[]uint32{3},
+			},
+		},
 		{
 			name: "stopnotifyspent",
 			newCmd: func() (interface{}, error) {
@@ -147,7 +219,7 @@ func TestChainSvrWsCmds(t *testing.T) {
 			},
 			staticCmd: func() interface{} {
 				ops := []btcjson.OutPoint{{Hash: "123", Index: 0}}
-				return btcjson.NewStopNotifySpentCmd(ops)
+				return btcjson.NewStopNotifySpentCmd(ops, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"stopnotifyspent","params":[[{"hash":"123","index":0}]],"id":1}`,
 			unmarshalled: &btcjson.StopNotifySpentCmd{
@@ -204,7 +276,7 @@ func TestChainSvrWsCmds(t *testing.T) {
 					Hash:  "0000000000000000000000000000000000000000000000000000000000000123",
 					Index: 0,
 				}}
-				return btcjson.NewLoadTxFilterCmd(false, addrs, ops)
+				return btcjson.NewLoadTxFilterCmd(false, addrs, ops, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"loadtxfilter","params":[false,["1Address"],[{"hash":"0000000000000000000000000000000000000000000000000000000000000123","index":0}]],"id":1}`,
 			unmarshalled: &btcjson.LoadTxFilterCmd{
@@ -213,6 +285,23 @@ func TestChainSvrWsCmds(t *testing.T) {
 				OutPoints: []btcjson.OutPoint{{Hash: "0000000000000000000000000000000000000000000000000000000000000123", Index: 0}},
 			},
 		},
+		{
+			name: "loadtxfilter keyIDs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("loadtxfilter", true, `[]`, `[]`, `[1,3]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewLoadTxFilterCmd(true, []string{},
+					[]btcjson.OutPoint{}, &[]uint32{1, 3})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"loadtxfilter","params":[true,[],[],[1,3]],"id":1}`,
+			unmarshalled: &btcjson.LoadTxFilterCmd{
+				Reload:    true,
+				Addresses: []string{},
+				OutPoints: []btcjson.OutPoint{},
+				KeyIDs:    &[]uint32{1, 3},
+			},
+		},
 		{
 			name: "rescanblocks",
 			newCmd: func() (interface{}, error) {
@@ -227,6 +316,36 @@ func TestChainSvrWsCmds(t *testing.T) {
 				BlockHashes: []string{"0000000000000000000000000000000000000000000000000000000000000123"},
 			},
 		},
+		{
+			name: "watch",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("watch", []string{"1Address"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewWatchCmd([]string{"1Address"}, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watch","params":[["1Address"]],"id":1}`,
+			unmarshalled: &btcjson.WatchCmd{
+				Addresses: []string{"1Address"},
+				Birthday:  btcjson.Uint32(0),
+			},
+		},
+		{
+			name: "watch optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("watch", []string{}, []uint32{1, 2}, 1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewWatchCmd([]string{}, &[]uint32{1, 2},
+					btcjson.Uint32(1000))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watch","params":[[],[1,2],1000],"id":1}`,
+			unmarshalled: &btcjson.WatchCmd{
+				Addresses: []string{},
+				KeyIDs:    &[]uint32{1, 2},
+				Birthday:  btcjson.Uint32(1000),
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
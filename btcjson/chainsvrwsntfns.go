@@ -75,6 +75,24 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// WatchRescanProgressNtfnMethod is the method used for notifications
+	// from the chain server that the rescan of a watch has made progress.
+	WatchRescanProgressNtfnMethod = "watchrescanprogress"
+
+	// WatchRescanFinishedNtfnMethod is the method used for notifications
+	// from the chain server that the rescan of a watch has finished and
+	// its live notifications have been registered.
+	WatchRescanFinishedNtfnMethod = "watchrescanfinished"
+
+	// EventLoggedNtfnMethod is the method used for notifications from the
+	// chain server that an event has been recorded in the event log.
+	EventLoggedNtfnMethod = "eventlogged"
+
+	// BroadcastFinishedNtfnMethod is the method used for notifications
+	// from the chain server that a broadcast campaign reached its final
+	// status.
+	BroadcastFinishedNtfnMethod = "broadcastfinished"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +303,70 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// EventLoggedNtfn defines the eventlogged JSON-RPC notification.
+type EventLoggedNtfn struct {
+	Event EventLogEntryResult
+}
+
+// NewEventLoggedNtfn returns a new instance which can be used to issue an
+// eventlogged JSON-RPC notification.
+func NewEventLoggedNtfn(event EventLogEntryResult) *EventLoggedNtfn {
+	return &EventLoggedNtfn{Event: event}
+}
+
+// BroadcastFinishedNtfn defines the broadcastfinished JSON-RPC notification.
+type BroadcastFinishedNtfn struct {
+	Status BroadcastStatusResult
+}
+
+// NewBroadcastFinishedNtfn returns a new instance which can be used to issue a
+// broadcastfinished JSON-RPC notification.
+func NewBroadcastFinishedNtfn(status BroadcastStatusResult) *BroadcastFinishedNtfn {
+	return &BroadcastFinishedNtfn{Status: status}
+}
+
+// WatchRescanProgressNtfn defines the watchrescanprogress JSON-RPC
+// notification.
+type WatchRescanProgressNtfn struct {
+	WatchID uint64
+	Hash    string
+	Height  int32
+	Time    int64
+}
+
+// NewWatchRescanProgressNtfn returns a new instance which can be used to issue
+// a watchrescanprogress JSON-RPC notification.
+func NewWatchRescanProgressNtfn(watchID uint64, hash string, height int32, time int64) *WatchRescanProgressNtfn {
+	return &WatchRescanProgressNtfn{
+		WatchID: watchID,
+		Hash:    hash,
+		Height:  height,
+		Time:    time,
+	}
+}
+
+// WatchRescanFinishedNtfn defines the watchrescanfinished JSON-RPC
+// notification.  Error is only set when the rescan failed.
+type WatchRescanFinishedNtfn struct {
+	WatchID uint64
+	Hash    string
+	Height  int32
+	Time    int64
+	Error   *string
+}
+
+// NewWatchRescanFinishedNtfn returns a new instance which can be used to issue
+// a watchrescanfinished JSON-RPC notification.
+func NewWatchRescanFinishedNtfn(watchID uint64, hash string, height int32, time int64, err *string) *WatchRescanFinishedNtfn {
+	return &WatchRescanFinishedNtfn{
+		WatchID: watchID,
+		Hash:    hash,
+		Height:  height,
+		Time:    time,
+		Error:   err,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +383,8 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(EventLoggedNtfnMethod, (*EventLoggedNtfn)(nil), flags)
+	MustRegisterCmd(WatchRescanProgressNtfnMethod, (*WatchRescanProgressNtfn)(nil), flags)
+	MustRegisterCmd(WatchRescanFinishedNtfnMethod, (*WatchRescanFinishedNtfn)(nil), flags)
+	MustRegisterCmd(BroadcastFinishedNtfnMethod, (*BroadcastFinishedNtfn)(nil), flags)
 }
@@ -225,6 +225,110 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "eventlogged",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("eventlogged", `{"seq":7,"type":"txaccepted","time":123,"hash":"456"}`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewEventLoggedNtfn(btcjson.EventLogEntryResult{
+					Seq:  7,
+					Type: "txaccepted",
+					Time: 123,
+					Hash: "456",
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"eventlogged","params":[{"seq":7,"type":"txaccepted","time":123,"hash":"456"}],"id":null}`,
+			unmarshalled: &btcjson.EventLoggedNtfn{
+				Event: btcjson.EventLogEntryResult{
+					Seq:  7,
+					Type: "txaccepted",
+					Time: 123,
+					Hash: "456",
+				},
+			},
+		},
+		{
+			name: "broadcastfinished",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("broadcastfinished", `{"id":2,"txid":"456","status":"confirmed","final":true,"targetconfs":1,"confirmations":1,"peersrequested":3,"peersannounced":0,"unconfirmations":0,"started":123}`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewBroadcastFinishedNtfn(btcjson.BroadcastStatusResult{
+					ID:             2,
+					TxID:           "456",
+					Status:         "confirmed",
+					Final:          true,
+					TargetConfs:    1,
+					Confirmations:  1,
+					PeersRequested: 3,
+					Started:        123,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"broadcastfinished","params":[{"id":2,"txid":"456","status":"confirmed","final":true,"targetconfs":1,"confirmations":1,"peersrequested":3,"peersannounced":0,"unconfirmations":0,"started":123}],"id":null}`,
+			unmarshalled: &btcjson.BroadcastFinishedNtfn{
+				Status: btcjson.BroadcastStatusResult{
+					ID:             2,
+					TxID:           "456",
+					Status:         "confirmed",
+					Final:          true,
+					TargetConfs:    1,
+					Confirmations:  1,
+					PeersRequested: 3,
+					Started:        123,
+				},
+			},
+		},
+		{
+			name: "watchrescanprogress",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("watchrescanprogress", 3, "123", 100000, 12345678)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewWatchRescanProgressNtfn(3, "123", 100000, 12345678)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watchrescanprogress","params":[3,"123",100000,12345678],"id":null}`,
+			unmarshalled: &btcjson.WatchRescanProgressNtfn{
+				WatchID: 3,
+				Hash:    "123",
+				Height:  100000,
+				Time:    12345678,
+			},
+		},
+		{
+			name: "watchrescanfinished",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("watchrescanfinished", 3, "123", 100000, 12345678)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewWatchRescanFinishedNtfn(3, "123", 100000, 12345678, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watchrescanfinished","params":[3,"123",100000,12345678],"id":null}`,
+			unmarshalled: &btcjson.WatchRescanFinishedNtfn{
+				WatchID: 3,
+				Hash:    "123",
+				Height:  100000,
+				Time:    12345678,
+			},
+		},
+		{
+			name: "watchrescanfinished error",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("watchrescanfinished", 3, "123", 100000, 12345678, "failed")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewWatchRescanFinishedNtfn(3, "123", 100000,
+					12345678, btcjson.String("failed"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watchrescanfinished","params":[3,"123",100000,12345678,"failed"],"id":null}`,
+			unmarshalled: &btcjson.WatchRescanFinishedNtfn{
+				WatchID: 3,
+				Hash:    "123",
+				Height:  100000,
+				Time:    12345678,
+				Error:   btcjson.String("failed"),
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
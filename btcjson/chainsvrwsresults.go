@@ -20,3 +20,8 @@ type RescannedBlock struct {
 	Hash         string   `json:"hash"`
 	Transactions []string `json:"transactions"`
 }
+
+// WatchResult models the data from the watch command.
+type WatchResult struct {
+	WatchID uint64 `json:"watchid"`
+}
@@ -458,6 +458,48 @@ func isValidResultType(kind reflect.Kind) bool {
 	return false
 }
 
+// checkResultTypes returns an error unless each of the passed result types is
+// nil or a pointer to one of the acceptable types for results.
+func checkResultTypes(resultTypes []interface{}) error {
+	for i, resultType := range resultTypes {
+		if resultType == nil {
+			continue
+		}
+
+		rtp := reflect.TypeOf(resultType)
+		if rtp.Kind() != reflect.Ptr {
+			str := fmt.Sprintf("result #%d (%v) is not a pointer",
+				i, rtp.Kind())
+			return makeError(ErrInvalidType, str)
+		}
+
+		elemKind := rtp.Elem().Kind()
+		if !isValidResultType(elemKind) {
+			str := fmt.Sprintf("result #%d (%v) is not an allowed "+
+				"type", i, elemKind)
+			return makeError(ErrInvalidType, str)
+		}
+	}
+	return nil
+}
+
+// descLookup returns a description lookup function for the provided
+// descriptions map which falls back to the base help descriptions map for
+// unrecognized keys, and stores any missing key in the passed string.
+func descLookup(descs map[string]string, missingKey *string) descLookupFunc {
+	return func(key string) string {
+		if desc, ok := descs[key]; ok {
+			return desc
+		}
+		if desc, ok := baseHelpDescs[key]; ok {
+			return desc
+		}
+
+		*missingKey = key
+		return key
+	}
+}
+
 // GenerateHelp generates and returns help output for the provided method and
 // result types given a map to provide the appropriate keys for the method
 // synopsis, field descriptions, conditions, and result descriptions.  The
@@ -516,43 +558,13 @@ func GenerateHelp(method string, descs map[string]string, resultTypes ...interfa
 	}
 
 	// Validate each result type is a pointer to a supported type (or nil).
-	for i, resultType := range resultTypes {
-		if resultType == nil {
-			continue
-		}
-
-		rtp := reflect.TypeOf(resultType)
-		if rtp.Kind() != reflect.Ptr {
-			str := fmt.Sprintf("result #%d (%v) is not a pointer",
-				i, rtp.Kind())
-			return "", makeError(ErrInvalidType, str)
-		}
-
-		elemKind := rtp.Elem().Kind()
-		if !isValidResultType(elemKind) {
-			str := fmt.Sprintf("result #%d (%v) is not an allowed "+
-				"type", i, elemKind)
-			return "", makeError(ErrInvalidType, str)
-		}
-	}
-
-	// Create a closure for the description lookup function which falls back
-	// to the base help descritptions map for unrecognized keys and tracks
-	// and missing keys.
-	var missingKey string
-	xT := func(key string) string {
-		if desc, ok := descs[key]; ok {
-			return desc
-		}
-		if desc, ok := baseHelpDescs[key]; ok {
-			return desc
-		}
-
-		missingKey = key
-		return key
+	if err := checkResultTypes(resultTypes); err != nil {
+		return "", err
 	}
 
 	// Generate and return the help for the method.
+	var missingKey string
+	xT := descLookup(descs, &missingKey)
 	help := methodHelp(xT, rtp, info.defaults, method, resultTypes)
 	if missingKey != "" {
 		return help, makeError(ErrMissingDescription, missingKey)
@@ -46,6 +46,7 @@ const (
 const (
 	ErrRPCClientNotConnected      RPCErrorCode = -9
 	ErrRPCClientInInitialDownload RPCErrorCode = -10
+	ErrRPCClientNodeAlreadyAdded  RPCErrorCode = -23
 	ErrRPCClientNodeNotAdded      RPCErrorCode = -24
 )
 
@@ -82,4 +83,6 @@ const (
 const (
 	ErrRPCNoWallet      RPCErrorCode = -1
 	ErrRPCUnimplemented RPCErrorCode = -1
+	ErrRPCRateLimited   RPCErrorCode = -30
+	ErrRPCEventCursor   RPCErrorCode = -31
 )
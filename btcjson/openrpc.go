@@ -0,0 +1,254 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification the documents
+// returned by the rpc.discover command conform to.
+const OpenRPCVersion = "1.2.6"
+
+// JSONSchema describes the JSON value of a command parameter or result, using
+// the subset of JSON Schema needed for the types of this package.
+type JSONSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+// OpenRPCContentDescriptor describes a parameter or the result of a method.
+type OpenRPCContentDescriptor struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+// OpenRPCMethod describes a method of an OpenRPC document.  Methods which are
+// only available via websockets are marked with the x-websocket-only
+// extension field.
+type OpenRPCMethod struct {
+	Name           string                     `json:"name"`
+	Description    string                     `json:"description"`
+	ParamStructure string                     `json:"paramStructure"`
+	Params         []OpenRPCContentDescriptor `json:"params"`
+	Result         OpenRPCContentDescriptor   `json:"result"`
+	WebsocketOnly  bool                       `json:"x-websocket-only,omitempty"`
+}
+
+// OpenRPCInfo describes the API of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCDocument models the OpenRPC document returned by the rpc.discover
+// command.
+type OpenRPCDocument struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+// reflectTypeToJSONSchema returns the JSON schema of the provided Go type.
+// The descriptions of object fields are looked up the same way as for the
+// help output, while fieldDescKey is used for the entries of maps.
+func reflectTypeToJSONSchema(xT descLookupFunc, rt reflect.Type, fieldDescKey string) *JSONSchema {
+	// Indirect pointer if needed.
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	kind := rt.Kind()
+	if isNumeric(kind) {
+		if kind == reflect.Float32 || kind == reflect.Float64 {
+			return &JSONSchema{Type: "number"}
+		}
+		return &JSONSchema{Type: "integer"}
+	}
+
+	switch kind {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+
+	case reflect.Array, reflect.Slice:
+		return &JSONSchema{
+			Type:  "array",
+			Items: reflectTypeToJSONSchema(xT, rt.Elem(), fieldDescKey),
+		}
+
+	case reflect.Struct:
+		typeName := strings.ToLower(rt.Name())
+		schema := &JSONSchema{
+			Type:       "object",
+			Properties: make(map[string]*JSONSchema, rt.NumField()),
+		}
+		for i := 0; i < rt.NumField(); i++ {
+			rtf := rt.Field(i)
+
+			// The property name is the json name when it's
+			// available, otherwise the lowercase field name.
+			var fieldName string
+			if tag := rtf.Tag.Get("json"); tag != "" {
+				fieldName = strings.Split(tag, ",")[0]
+			} else {
+				fieldName = strings.ToLower(rtf.Name)
+			}
+			if fieldName == "-" {
+				continue
+			}
+
+			key := typeName + "-" + fieldName
+			property := reflectTypeToJSONSchema(xT, rtf.Type, key)
+			property.Description = xT(key)
+			schema.Properties[fieldName] = property
+		}
+		return schema
+
+	case reflect.Map:
+		value := reflectTypeToJSONSchema(xT, rt.Elem(), fieldDescKey)
+		value.Description = xT(fieldDescKey + "--desc")
+		return &JSONSchema{
+			Type:                 "object",
+			AdditionalProperties: value,
+		}
+	}
+
+	// Any JSON value is accepted for other types such as interfaces.
+	return &JSONSchema{}
+}
+
+// methodDescriptor generates and returns the OpenRPC method description for
+// the provided command and method info.  This is the main work horse for the
+// exported GenerateMethodDescriptor function.
+func methodDescriptor(xT descLookupFunc, rtp reflect.Type, info methodInfo, method string, resultTypes []interface{}) *OpenRPCMethod {
+	desc := &OpenRPCMethod{
+		Name:           method,
+		Description:    xT(method + "--synopsis"),
+		ParamStructure: "by-position",
+		WebsocketOnly:  info.flags&UFWebsocketOnly != 0,
+	}
+
+	// Describe each parameter of the command.  Optional parameters are
+	// the pointer fields, which are only allowed to follow the required
+	// ones by RegisterCmd.
+	rt := rtp.Elem()
+	desc.Params = make([]OpenRPCContentDescriptor, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		rtf := rt.Field(i)
+		fieldName := strings.ToLower(rtf.Name)
+		fieldDescKey := method + "-" + fieldName
+		schema := reflectTypeToJSONSchema(xT, rtf.Type, fieldDescKey)
+		if defaultVal, ok := info.defaults[i]; ok {
+			schema.Default = defaultVal.Elem().Interface()
+		}
+		desc.Params = append(desc.Params, OpenRPCContentDescriptor{
+			Name:        fieldName,
+			Description: xT(fieldDescKey),
+			Required:    rtf.Type.Kind() != reflect.Ptr,
+			Schema:      schema,
+		})
+	}
+
+	// Describe each result type.  When there is more than one result type,
+	// the result is one of them depending on the condition which triggers
+	// it.
+	results := make([]*JSONSchema, 0, len(resultTypes))
+	for i, resultType := range resultTypes {
+		fieldDescKey := fmt.Sprintf("%s--result%d", method, i)
+		var schema *JSONSchema
+		if resultType == nil {
+			schema = &JSONSchema{
+				Type:        "null",
+				Description: xT("help-result-nothing"),
+			}
+		} else {
+			// Like the help output, only primitive results and
+			// arrays of them have a description of their own.
+			elem := reflect.TypeOf(resultType).Elem()
+			schema = reflectTypeToJSONSchema(xT, elem, fieldDescKey)
+			for elem.Kind() == reflect.Array ||
+				elem.Kind() == reflect.Slice {
+
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if kind := elem.Kind(); kind != reflect.Struct &&
+				kind != reflect.Map {
+
+				schema.Description = xT(fieldDescKey)
+			}
+		}
+		if len(resultTypes) > 1 {
+			condKey := fmt.Sprintf("%s--condition%d", method, i)
+			schema.Description = strings.TrimSpace(xT(condKey) + ": " +
+				schema.Description)
+		}
+		results = append(results, schema)
+	}
+	desc.Result = OpenRPCContentDescriptor{Name: "result"}
+	switch len(results) {
+	case 0:
+		desc.Result.Schema = &JSONSchema{
+			Type:        "null",
+			Description: xT("help-result-nothing"),
+		}
+	case 1:
+		desc.Result.Schema = results[0]
+	default:
+		desc.Result.Schema = &JSONSchema{OneOf: results}
+	}
+	return desc
+}
+
+// GenerateMethodDescriptor generates and returns the OpenRPC description of
+// the provided method and result types, given a map to provide the appropriate
+// keys for the method synopsis, field descriptions, conditions, and result
+// descriptions.  The method must be associated with a registered type, and
+// the result types and descriptions are the same as those required by
+// GenerateHelp.
+//
+// The parameter schemas are derived from the registered command type, so the
+// description always matches the parameters accepted by the method.  The
+// schema of a result with more than one result type is one of the schemas of
+// each result type.
+func GenerateMethodDescriptor(method string, descs map[string]string, resultTypes ...interface{}) (*OpenRPCMethod, error) {
+	// Look up details about the provided method and error out if not
+	// registered.
+	registerLock.RLock()
+	rtp, ok := methodToConcreteType[method]
+	info := methodToInfo[method]
+	registerLock.RUnlock()
+	if !ok {
+		str := fmt.Sprintf("%q is not registered", method)
+		return nil, makeError(ErrUnregisteredMethod, str)
+	}
+
+	// Validate each result type is a pointer to a supported type (or nil).
+	if err := checkResultTypes(resultTypes); err != nil {
+		return nil, err
+	}
+
+	// Generate and return the description of the method.
+	var missingKey string
+	xT := descLookup(descs, &missingKey)
+	desc := methodDescriptor(xT, rtp, info, method, resultTypes)
+	if missingKey != "" {
+		return desc, makeError(ErrMissingDescription, missingKey)
+	}
+	return desc, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bitgo/prova/btcjson"
+)
+
+// TestGenerateMethodDescriptor ensures the OpenRPC method descriptions are
+// generated from the registered command types and the result types.
+func TestGenerateMethodDescriptor(t *testing.T) {
+	t.Parallel()
+
+	type headerResult struct {
+		Hash   string `json:"hash"`
+		Height int32  `json:"height"`
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		descs       map[string]string
+		resultTypes []interface{}
+		want        string
+	}{
+		{
+			name:   "no result",
+			method: "help",
+			descs: map[string]string{
+				"help--synopsis": "test",
+				"help-command":   "cmd",
+			},
+			want: `{"name":"help","description":"test",` +
+				`"paramStructure":"by-position","params":[` +
+				`{"name":"command","description":"cmd",` +
+				`"schema":{"type":"string"}}],"result":{"name":` +
+				`"result","schema":{"type":"null","description":` +
+				`"Nothing"}}}`,
+		},
+		{
+			name:   "defaults and conditional results",
+			method: "getblockheaders",
+			descs: map[string]string{
+				"getblockheaders--synopsis":   "test",
+				"getblockheaders-start":       "start",
+				"getblockheaders-count":       "count",
+				"getblockheaders-verbose":     "verbose",
+				"getblockheaders--condition0": "verbose=false",
+				"getblockheaders--condition1": "verbose=true",
+				"getblockheaders--result0":    "hashes",
+				"headerresult-hash":           "hash",
+				"headerresult-height":         "height",
+			},
+			resultTypes: []interface{}{(*[]string)(nil),
+				(*[]headerResult)(nil)},
+			want: `{"name":"getblockheaders","description":"test",` +
+				`"paramStructure":"by-position","params":[` +
+				`{"name":"start","description":"start",` +
+				`"required":true,"schema":{"type":"string"}},` +
+				`{"name":"count","description":"count",` +
+				`"required":true,"schema":{"type":"integer"}},` +
+				`{"name":"verbose","description":"verbose",` +
+				`"schema":{"type":"boolean","default":true}}],` +
+				`"result":{"name":"result","schema":{"oneOf":[` +
+				`{"type":"array","description":"verbose=false: ` +
+				`hashes","items":{"type":"string"}},` +
+				`{"type":"array","description":"verbose=true:",` +
+				`"items":{"type":"object","properties":{` +
+				`"hash":{"type":"string","description":"hash"},` +
+				`"height":{"type":"integer","description":` +
+				`"height"}}}}]}}}`,
+		},
+		{
+			name:   "websocket only",
+			method: "notifyblocks",
+			descs: map[string]string{
+				"notifyblocks--synopsis": "test",
+			},
+			want: `{"name":"notifyblocks","description":"test",` +
+				`"paramStructure":"by-position","params":[],` +
+				`"result":{"name":"result","schema":{"type":` +
+				`"null","description":"Nothing"}},` +
+				`"x-websocket-only":true}`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		desc, err := btcjson.GenerateMethodDescriptor(test.method,
+			test.descs, test.resultTypes...)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		marshalled, err := json.Marshal(desc)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		if string(marshalled) != test.want {
+			t.Errorf("Test #%d (%s) unexpected description - got "+
+				"%s, want %s", i, test.name, marshalled, test.want)
+		}
+	}
+}
+
+// TestGenerateMethodDescriptorErrors ensures the GenerateMethodDescriptor
+// function returns the expected errors.
+func TestGenerateMethodDescriptorErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		method      string
+		resultTypes []interface{}
+		err         btcjson.Error
+	}{
+		{
+			name:   "unregistered command",
+			method: "boguscommand",
+			err:    btcjson.Error{ErrorCode: btcjson.ErrUnregisteredMethod},
+		},
+		{
+			name:        "non-pointer result type",
+			method:      "help",
+			resultTypes: []interface{}{0},
+			err:         btcjson.Error{ErrorCode: btcjson.ErrInvalidType},
+		},
+		{
+			name:   "missing description",
+			method: "help",
+			err:    btcjson.Error{ErrorCode: btcjson.ErrMissingDescription},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := btcjson.GenerateMethodDescriptor(test.method, nil,
+			test.resultTypes...)
+		gotErrorCode := err.(btcjson.Error).ErrorCode
+		if gotErrorCode != test.err.ErrorCode {
+			t.Errorf("Test #%d (%s) mismatched error code - got "+
+				"%v (%v), want %v", i, test.name, gotErrorCode,
+				err, test.err.ErrorCode)
+			continue
+		}
+	}
+}
@@ -24,9 +24,621 @@ func NewSetValidateKeysCmd(privKeys []string) *SetValidateKeysCmd {
 	}
 }
 
+// GetRPCInfoCmd defines the getrpcinfo JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type GetRPCInfoCmd struct{}
+
+// NewGetRPCInfoCmd returns a new GetRPCInfoCmd which can be used to issue a
+// getrpcinfo JSON-RPC command.  This command is not a standard command.  It is
+// an extension for prova.
+func NewGetRPCInfoCmd() *GetRPCInfoCmd {
+	return &GetRPCInfoCmd{}
+}
+
+// RPCDiscoverCmd defines the rpc.discover JSON-RPC command.
+// This command is not a standard command, it is an extension for prova which
+// follows the service discovery method of the OpenRPC specification.
+type RPCDiscoverCmd struct{}
+
+// NewRPCDiscoverCmd returns a new RPCDiscoverCmd which can be used to issue an
+// rpc.discover JSON-RPC command.  This command is not a standard command.  It
+// is an extension for prova.
+func NewRPCDiscoverCmd() *RPCDiscoverCmd {
+	return &RPCDiscoverCmd{}
+}
+
+// RotateRPCAuthCmd defines the rotaterpcauth JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type RotateRPCAuthCmd struct {
+	User *string
+}
+
+// NewRotateRPCAuthCmd returns a new RotateRPCAuthCmd which can be used to
+// issue a rotaterpcauth JSON-RPC command.  The password of the RPC
+// authentication cookie is rotated when no user is passed.  This command is
+// not a standard command. It is an extension for prova.
+func NewRotateRPCAuthCmd(user *string) *RotateRPCAuthCmd {
+	return &RotateRPCAuthCmd{
+		User: user,
+	}
+}
+
+// BackupChainStateCmd defines the backupchainstate JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type BackupChainStateCmd struct {
+	Destination string
+}
+
+// NewBackupChainStateCmd returns a new BackupChainStateCmd which can be used
+// to issue a backupchainstate JSON-RPC command.  This command is not a
+// standard command. It is an extension for prova.
+func NewBackupChainStateCmd(destination string) *BackupChainStateCmd {
+	return &BackupChainStateCmd{
+		Destination: destination,
+	}
+}
+
+// CompactDBCmd defines the compactdb JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type CompactDBCmd struct{}
+
+// NewCompactDBCmd returns a new CompactDBCmd which can be used to issue a
+// compactdb JSON-RPC command.  This command is not a standard command. It is
+// an extension for prova.
+func NewCompactDBCmd() *CompactDBCmd {
+	return &CompactDBCmd{}
+}
+
+// GetDBInfoCmd defines the getdbinfo JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type GetDBInfoCmd struct{}
+
+// NewGetDBInfoCmd returns a new GetDBInfoCmd which can be used to issue a
+// getdbinfo JSON-RPC command.  This command is not a standard command. It is
+// an extension for prova.
+func NewGetDBInfoCmd() *GetDBInfoCmd {
+	return &GetDBInfoCmd{}
+}
+
+// EnableIndexCmd defines the enableindex JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type EnableIndexCmd struct {
+	Index string
+}
+
+// NewEnableIndexCmd returns a new EnableIndexCmd which can be used to issue an
+// enableindex JSON-RPC command.  The index is identified by the name of the
+// option which enables it, such as txindex.  This command is not a standard
+// command. It is an extension for prova.
+func NewEnableIndexCmd(index string) *EnableIndexCmd {
+	return &EnableIndexCmd{
+		Index: index,
+	}
+}
+
+// DropIndexCmd defines the dropindex JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type DropIndexCmd struct {
+	Index string
+}
+
+// NewDropIndexCmd returns a new DropIndexCmd which can be used to issue a
+// dropindex JSON-RPC command.  The index is identified by the name of the
+// option which enables it, such as txindex.  This command is not a standard
+// command. It is an extension for prova.
+func NewDropIndexCmd(index string) *DropIndexCmd {
+	return &DropIndexCmd{
+		Index: index,
+	}
+}
+
+// AdminListKeySetsCmd defines the admin.listkeysets JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminListKeySetsCmd struct{}
+
+// NewAdminListKeySetsCmd returns a new AdminListKeySetsCmd which can be used
+// to issue an admin.listkeysets JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+func NewAdminListKeySetsCmd() *AdminListKeySetsCmd {
+	return &AdminListKeySetsCmd{}
+}
+
+// AdminGetKeyIDInfoCmd defines the admin.getkeyidinfo JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminGetKeyIDInfoCmd struct {
+	KeyID uint32
+}
+
+// NewAdminGetKeyIDInfoCmd returns a new AdminGetKeyIDInfoCmd which can be used
+// to issue an admin.getkeyidinfo JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+func NewAdminGetKeyIDInfoCmd(keyID uint32) *AdminGetKeyIDInfoCmd {
+	return &AdminGetKeyIDInfoCmd{
+		KeyID: keyID,
+	}
+}
+
+// AdminProvisionValidateKeyCmd defines the admin.provisionvalidatekey
+// JSON-RPC command.  This command is not a standard command, it is an
+// extension for operating prova.
+type AdminProvisionValidateKeyCmd struct {
+	PubKey string
+	Submit *bool `jsonrpcdefault:"false"`
+}
+
+// NewAdminProvisionValidateKeyCmd returns a new AdminProvisionValidateKeyCmd
+// which can be used to issue an admin.provisionvalidatekey JSON-RPC command.
+// This command is not a standard command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminProvisionValidateKeyCmd(pubKey string, submit *bool) *AdminProvisionValidateKeyCmd {
+	return &AdminProvisionValidateKeyCmd{
+		PubKey: pubKey,
+		Submit: submit,
+	}
+}
+
+// AdminRevokeKeyCmd defines the admin.revokekey JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminRevokeKeyCmd struct {
+	KeyOp  AdminKeyOp
+	Submit *bool `jsonrpcdefault:"false"`
+}
+
+// NewAdminRevokeKeyCmd returns a new AdminRevokeKeyCmd which can be used to
+// issue an admin.revokekey JSON-RPC command.  This command is not a standard
+// command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminRevokeKeyCmd(keyOp AdminKeyOp, submit *bool) *AdminRevokeKeyCmd {
+	return &AdminRevokeKeyCmd{
+		KeyOp:  keyOp,
+		Submit: submit,
+	}
+}
+
+// AdminIssueTokensCmd defines the admin.issuetokens JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminIssueTokensCmd struct {
+	Amounts map[string]float64 `jsonrpcusage:"{\"address\":amount,...}"` // In RMG
+	Submit  *bool              `jsonrpcdefault:"false"`
+}
+
+// NewAdminIssueTokensCmd returns a new AdminIssueTokensCmd which can be used to
+// issue an admin.issuetokens JSON-RPC command.  This command is not a standard
+// command.  It is an extension for prova.
+//
+// Amounts are in RMG.  The parameters which are pointers indicate they are
+// optional.  Passing nil for optional parameters will use the default value.
+func NewAdminIssueTokensCmd(amounts map[string]float64, submit *bool) *AdminIssueTokensCmd {
+	return &AdminIssueTokensCmd{
+		Amounts: amounts,
+		Submit:  submit,
+	}
+}
+
+// AdminDestroyTokensCmd defines the admin.destroytokens JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminDestroyTokensCmd struct {
+	Inputs        []TransactionInput
+	Amount        float64 // In RMG
+	ChangeAddress *string
+	Submit        *bool `jsonrpcdefault:"false"`
+}
+
+// NewAdminDestroyTokensCmd returns a new AdminDestroyTokensCmd which can be
+// used to issue an admin.destroytokens JSON-RPC command.  This command is not
+// a standard command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminDestroyTokensCmd(inputs []TransactionInput, amount float64,
+	changeAddress *string, submit *bool) *AdminDestroyTokensCmd {
+
+	return &AdminDestroyTokensCmd{
+		Inputs:        inputs,
+		Amount:        amount,
+		ChangeAddress: changeAddress,
+		Submit:        submit,
+	}
+}
+
+// AdminFreezeKeyIDCmd defines the admin.freezekeyid JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminFreezeKeyIDCmd struct {
+	KeyID  uint32
+	Submit *bool `jsonrpcdefault:"false"`
+}
+
+// NewAdminFreezeKeyIDCmd returns a new AdminFreezeKeyIDCmd which can be used
+// to issue an admin.freezekeyid JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminFreezeKeyIDCmd(keyID uint32, submit *bool) *AdminFreezeKeyIDCmd {
+	return &AdminFreezeKeyIDCmd{
+		KeyID:  keyID,
+		Submit: submit,
+	}
+}
+
+// AdminUnfreezeKeyIDCmd defines the admin.unfreezekeyid JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminUnfreezeKeyIDCmd struct {
+	KeyID  uint32
+	Submit *bool `jsonrpcdefault:"false"`
+}
+
+// NewAdminUnfreezeKeyIDCmd returns a new AdminUnfreezeKeyIDCmd which can be
+// used to issue an admin.unfreezekeyid JSON-RPC command.  This command is not
+// a standard command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminUnfreezeKeyIDCmd(keyID uint32, submit *bool) *AdminUnfreezeKeyIDCmd {
+	return &AdminUnfreezeKeyIDCmd{
+		KeyID:  keyID,
+		Submit: submit,
+	}
+}
+
+// AdminRotateKeyIDCmd defines the admin.rotatekeyid JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminRotateKeyIDCmd struct {
+	KeyID   uint32
+	PubKey  string
+	FeeRate *float64 // In RMG/kB
+	Submit  *bool    `jsonrpcdefault:"false"`
+}
+
+// NewAdminRotateKeyIDCmd returns a new AdminRotateKeyIDCmd which can be used
+// to issue an admin.rotatekeyid JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminRotateKeyIDCmd(keyID uint32, pubKey string, feeRate *float64,
+	submit *bool) *AdminRotateKeyIDCmd {
+
+	return &AdminRotateKeyIDCmd{
+		KeyID:   keyID,
+		PubKey:  pubKey,
+		FeeRate: feeRate,
+		Submit:  submit,
+	}
+}
+
+// AdminProposeTxCmd defines the admin.proposetx JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminProposeTxCmd struct {
+	HexTx       string
+	Description *string
+}
+
+// NewAdminProposeTxCmd returns a new AdminProposeTxCmd which can be used to
+// issue an admin.proposetx JSON-RPC command.  This command is not a standard
+// command.  It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAdminProposeTxCmd(hexTx string, description *string) *AdminProposeTxCmd {
+	return &AdminProposeTxCmd{
+		HexTx:       hexTx,
+		Description: description,
+	}
+}
+
+// AdminGetProposalCmd defines the admin.getproposal JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminGetProposalCmd struct {
+	TxID string
+}
+
+// NewAdminGetProposalCmd returns a new AdminGetProposalCmd which can be used
+// to issue an admin.getproposal JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+func NewAdminGetProposalCmd(txID string) *AdminGetProposalCmd {
+	return &AdminGetProposalCmd{
+		TxID: txID,
+	}
+}
+
+// AdminListProposalsCmd defines the admin.listproposals JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminListProposalsCmd struct{}
+
+// NewAdminListProposalsCmd returns a new AdminListProposalsCmd which can be
+// used to issue an admin.listproposals JSON-RPC command.  This command is not
+// a standard command.  It is an extension for prova.
+func NewAdminListProposalsCmd() *AdminListProposalsCmd {
+	return &AdminListProposalsCmd{}
+}
+
+// AdminSignProposalCmd defines the admin.signproposal JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminSignProposalCmd struct {
+	TxID string
+}
+
+// NewAdminSignProposalCmd returns a new AdminSignProposalCmd which can be
+// used to issue an admin.signproposal JSON-RPC command.  This command is not
+// a standard command.  It is an extension for prova.
+func NewAdminSignProposalCmd(txID string) *AdminSignProposalCmd {
+	return &AdminSignProposalCmd{
+		TxID: txID,
+	}
+}
+
+// AdminAddProposalSigsCmd defines the admin.addproposalsigs JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type AdminAddProposalSigsCmd struct {
+	TxID string
+	PSPT string
+}
+
+// NewAdminAddProposalSigsCmd returns a new AdminAddProposalSigsCmd which can
+// be used to issue an admin.addproposalsigs JSON-RPC command.  This command is
+// not a standard command.  It is an extension for prova.
+func NewAdminAddProposalSigsCmd(txID, pspt string) *AdminAddProposalSigsCmd {
+	return &AdminAddProposalSigsCmd{
+		TxID: txID,
+		PSPT: pspt,
+	}
+}
+
+// AdminDropProposalCmd defines the admin.dropproposal JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type AdminDropProposalCmd struct {
+	TxID string
+}
+
+// NewAdminDropProposalCmd returns a new AdminDropProposalCmd which can be
+// used to issue an admin.dropproposal JSON-RPC command.  This command is not
+// a standard command.  It is an extension for prova.
+func NewAdminDropProposalCmd(txID string) *AdminDropProposalCmd {
+	return &AdminDropProposalCmd{
+		TxID: txID,
+	}
+}
+
+// StartCPUProfileCmd defines the startcpuprofile JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type StartCPUProfileCmd struct {
+	File string
+}
+
+// NewStartCPUProfileCmd returns a new StartCPUProfileCmd which can be used to
+// issue a startcpuprofile JSON-RPC command.  This command is not a standard
+// command. It is an extension for prova.
+func NewStartCPUProfileCmd(file string) *StartCPUProfileCmd {
+	return &StartCPUProfileCmd{
+		File: file,
+	}
+}
+
+// StopCPUProfileCmd defines the stopcpuprofile JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type StopCPUProfileCmd struct{}
+
+// NewStopCPUProfileCmd returns a new StopCPUProfileCmd which can be used to
+// issue a stopcpuprofile JSON-RPC command.  This command is not a standard
+// command. It is an extension for prova.
+func NewStopCPUProfileCmd() *StopCPUProfileCmd {
+	return &StopCPUProfileCmd{}
+}
+
+// WriteProfileCmd defines the writeprofile JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type WriteProfileCmd struct {
+	Profile string `jsonrpcusage:"\"heap|goroutine|threadcreate|block|mutex\""`
+	File    string
+}
+
+// NewWriteProfileCmd returns a new WriteProfileCmd which can be used to issue
+// a writeprofile JSON-RPC command.  The profile is identified by its runtime
+// name, such as heap or goroutine.  This command is not a standard command.
+// It is an extension for prova.
+func NewWriteProfileCmd(profile, file string) *WriteProfileCmd {
+	return &WriteProfileCmd{
+		Profile: profile,
+		File:    file,
+	}
+}
+
+// SetProfileServerCmd defines the setprofileserver JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type SetProfileServerCmd struct {
+	Enable bool
+	Port   *string
+}
+
+// NewSetProfileServerCmd returns a new SetProfileServerCmd which can be used
+// to issue a setprofileserver JSON-RPC command.  This command is not a
+// standard command. It is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetProfileServerCmd(enable bool, port *string) *SetProfileServerCmd {
+	return &SetProfileServerCmd{
+		Enable: enable,
+		Port:   port,
+	}
+}
+
+// GetDiagnosticsCmd defines the getdiagnostics JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type GetDiagnosticsCmd struct{}
+
+// NewGetDiagnosticsCmd returns a new GetDiagnosticsCmd which can be used to
+// issue a getdiagnostics JSON-RPC command.  This command is not a standard
+// command. It is an extension for prova.
+func NewGetDiagnosticsCmd() *GetDiagnosticsCmd {
+	return &GetDiagnosticsCmd{}
+}
+
+// GetKeyIDVelocityCmd defines the getkeyidvelocity JSON-RPC command.
+// This command is not a standard command, it is an extension for operating
+// prova.
+type GetKeyIDVelocityCmd struct {
+	KeyID uint32
+}
+
+// NewGetKeyIDVelocityCmd returns a new GetKeyIDVelocityCmd which can be used
+// to issue a getkeyidvelocity JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+func NewGetKeyIDVelocityCmd(keyID uint32) *GetKeyIDVelocityCmd {
+	return &GetKeyIDVelocityCmd{
+		KeyID: keyID,
+	}
+}
+
+// SetKeyIDVelocityOverrideCmd defines the setkeyidvelocityoverride JSON-RPC
+// command.  This command is not a standard command, it is an extension for
+// operating prova.
+type SetKeyIDVelocityOverrideCmd struct {
+	KeyID    uint32
+	Duration int64
+}
+
+// NewSetKeyIDVelocityOverrideCmd returns a new SetKeyIDVelocityOverrideCmd
+// which can be used to issue a setkeyidvelocityoverride JSON-RPC command.  The
+// duration is in seconds, and a duration of 0 restores the limits of the
+// keyID.  This command is not a standard command.  It is an extension for
+// prova.
+func NewSetKeyIDVelocityOverrideCmd(keyID uint32, duration int64) *SetKeyIDVelocityOverrideCmd {
+	return &SetKeyIDVelocityOverrideCmd{
+		KeyID:    keyID,
+		Duration: duration,
+	}
+}
+
+// GetAuditLogCmd defines the getauditlog JSON-RPC command.  This command is
+// not a standard command, it is an extension for operating prova.
+type GetAuditLogCmd struct {
+	Start *uint64 `jsonrpcdefault:"1"`
+	Count *int    `jsonrpcdefault:"1000"`
+}
+
+// NewGetAuditLogCmd returns a new GetAuditLogCmd which can be used to issue a
+// getauditlog JSON-RPC command.  This command is not a standard command.  It
+// is an extension for prova.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAuditLogCmd(start *uint64, count *int) *GetAuditLogCmd {
+	return &GetAuditLogCmd{
+		Start: start,
+		Count: count,
+	}
+}
+
+// VerifyAuditLogCmd defines the verifyauditlog JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type VerifyAuditLogCmd struct{}
+
+// NewVerifyAuditLogCmd returns a new VerifyAuditLogCmd which can be used to
+// issue a verifyauditlog JSON-RPC command.  This command is not a standard
+// command.  It is an extension for prova.
+func NewVerifyAuditLogCmd() *VerifyAuditLogCmd {
+	return &VerifyAuditLogCmd{}
+}
+
+// GetAttestationCmd defines the getattestation JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type GetAttestationCmd struct{}
+
+// NewGetAttestationCmd returns a new GetAttestationCmd which can be used to
+// issue a getattestation JSON-RPC command.  This command is not a standard
+// command.  It is an extension for prova.
+func NewGetAttestationCmd() *GetAttestationCmd {
+	return &GetAttestationCmd{}
+}
+
+// VerifyAttestationCmd defines the verifyattestation JSON-RPC command.  This
+// command is not a standard command, it is an extension for operating prova.
+type VerifyAttestationCmd struct {
+	Hex string
+}
+
+// NewVerifyAttestationCmd returns a new VerifyAttestationCmd which can be used
+// to issue a verifyattestation JSON-RPC command.  This command is not a
+// standard command.  It is an extension for prova.
+func NewVerifyAttestationCmd(hexAttestation string) *VerifyAttestationCmd {
+	return &VerifyAttestationCmd{
+		Hex: hexAttestation,
+	}
+}
+
+// GetSPVInfoCmd defines the getspvinfo JSON-RPC command.  This command is not
+// a standard command, it is an extension for operating prova.
+type GetSPVInfoCmd struct{}
+
+// NewGetSPVInfoCmd returns a new GetSPVInfoCmd which can be used to issue a
+// getspvinfo JSON-RPC command.  This command is not a standard command.  It is
+// an extension for prova.
+func NewGetSPVInfoCmd() *GetSPVInfoCmd {
+	return &GetSPVInfoCmd{}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("admin.addproposalsigs", (*AdminAddProposalSigsCmd)(nil), flags)
+	MustRegisterCmd("admin.destroytokens", (*AdminDestroyTokensCmd)(nil), flags)
+	MustRegisterCmd("admin.dropproposal", (*AdminDropProposalCmd)(nil), flags)
+	MustRegisterCmd("admin.freezekeyid", (*AdminFreezeKeyIDCmd)(nil), flags)
+	MustRegisterCmd("admin.getkeyidinfo", (*AdminGetKeyIDInfoCmd)(nil), flags)
+	MustRegisterCmd("admin.getproposal", (*AdminGetProposalCmd)(nil), flags)
+	MustRegisterCmd("admin.issuetokens", (*AdminIssueTokensCmd)(nil), flags)
+	MustRegisterCmd("admin.listkeysets", (*AdminListKeySetsCmd)(nil), flags)
+	MustRegisterCmd("admin.listproposals", (*AdminListProposalsCmd)(nil), flags)
+	MustRegisterCmd("admin.proposetx", (*AdminProposeTxCmd)(nil), flags)
+	MustRegisterCmd("admin.provisionvalidatekey", (*AdminProvisionValidateKeyCmd)(nil), flags)
+	MustRegisterCmd("admin.revokekey", (*AdminRevokeKeyCmd)(nil), flags)
+	MustRegisterCmd("admin.rotatekeyid", (*AdminRotateKeyIDCmd)(nil), flags)
+	MustRegisterCmd("admin.signproposal", (*AdminSignProposalCmd)(nil), flags)
+	MustRegisterCmd("admin.unfreezekeyid", (*AdminUnfreezeKeyIDCmd)(nil), flags)
+	MustRegisterCmd("backupchainstate", (*BackupChainStateCmd)(nil), flags)
+	MustRegisterCmd("compactdb", (*CompactDBCmd)(nil), flags)
+	MustRegisterCmd("dropindex", (*DropIndexCmd)(nil), flags)
+	MustRegisterCmd("enableindex", (*EnableIndexCmd)(nil), flags)
+	MustRegisterCmd("getattestation", (*GetAttestationCmd)(nil), flags)
+	MustRegisterCmd("getauditlog", (*GetAuditLogCmd)(nil), flags)
+	MustRegisterCmd("getdbinfo", (*GetDBInfoCmd)(nil), flags)
+	MustRegisterCmd("getdiagnostics", (*GetDiagnosticsCmd)(nil), flags)
+	MustRegisterCmd("getkeyidvelocity", (*GetKeyIDVelocityCmd)(nil), flags)
+	MustRegisterCmd("getrpcinfo", (*GetRPCInfoCmd)(nil), flags)
+	MustRegisterCmd("getspvinfo", (*GetSPVInfoCmd)(nil), flags)
+	MustRegisterCmd("rotaterpcauth", (*RotateRPCAuthCmd)(nil), flags)
+	MustRegisterCmd("rpc.discover", (*RPCDiscoverCmd)(nil), flags)
+	MustRegisterCmd("setkeyidvelocityoverride", (*SetKeyIDVelocityOverrideCmd)(nil), flags)
+	MustRegisterCmd("setprofileserver", (*SetProfileServerCmd)(nil), flags)
 	MustRegisterCmd("setvalidatekeys", (*SetValidateKeysCmd)(nil), flags)
+	MustRegisterCmd("startcpuprofile", (*StartCPUProfileCmd)(nil), flags)
+	MustRegisterCmd("stopcpuprofile", (*StopCPUProfileCmd)(nil), flags)
+	MustRegisterCmd("verifyattestation", (*VerifyAttestationCmd)(nil), flags)
+	MustRegisterCmd("verifyauditlog", (*VerifyAuditLogCmd)(nil), flags)
+	MustRegisterCmd("writeprofile", (*WriteProfileCmd)(nil), flags)
 }
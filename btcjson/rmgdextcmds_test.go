@@ -30,6 +30,474 @@ func TestRmgdExtCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "admin.destroytokens",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.destroytokens", `[{"txid":"123","vout":1}]`, 0.5, "change", true)
+			},
+			staticCmd: func() interface{} {
+				txInputs := []btcjson.TransactionInput{{Txid: "123", Vout: 1}}
+				return btcjson.NewAdminDestroyTokensCmd(txInputs, 0.5,
+					btcjson.String("change"), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.destroytokens","params":[[{"txid":"123","vout":1}],0.5,"change",true],"id":1}`,
+			unmarshalled: &btcjson.AdminDestroyTokensCmd{
+				Inputs:        []btcjson.TransactionInput{{Txid: "123", Vout: 1}},
+				Amount:        0.5,
+				ChangeAddress: btcjson.String("change"),
+				Submit:        btcjson.Bool(true),
+			},
+		},
+		{
+			name: "admin.freezekeyid",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.freezekeyid", 3, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminFreezeKeyIDCmd(3, btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.freezekeyid","params":[3,true],"id":1}`,
+			unmarshalled: &btcjson.AdminFreezeKeyIDCmd{
+				KeyID:  3,
+				Submit: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "admin.getkeyidinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.getkeyidinfo", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminGetKeyIDInfoCmd(3)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.getkeyidinfo","params":[3],"id":1}`,
+			unmarshalled: &btcjson.AdminGetKeyIDInfoCmd{
+				KeyID: 3,
+			},
+		},
+		{
+			name: "admin.issuetokens",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.issuetokens", `{"addr":1.5}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminIssueTokensCmd(
+					map[string]float64{"addr": 1.5}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.issuetokens","params":[{"addr":1.5}],"id":1}`,
+			unmarshalled: &btcjson.AdminIssueTokensCmd{
+				Amounts: map[string]float64{"addr": 1.5},
+				Submit:  btcjson.Bool(false),
+			},
+		},
+		{
+			name: "admin.listkeysets",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.listkeysets")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminListKeySetsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"admin.listkeysets","params":[],"id":1}`,
+			unmarshalled: &btcjson.AdminListKeySetsCmd{},
+		},
+		{
+			name: "admin.provisionvalidatekey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.provisionvalidatekey", "02ab", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminProvisionValidateKeyCmd("02ab",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.provisionvalidatekey","params":["02ab",true],"id":1}`,
+			unmarshalled: &btcjson.AdminProvisionValidateKeyCmd{
+				PubKey: "02ab",
+				Submit: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "admin.revokekey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.revokekey", `{"keyset":"asp","pubkey":"02ab","keyid":4}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminRevokeKeyCmd(btcjson.AdminKeyOp{
+					KeySet: "asp",
+					PubKey: "02ab",
+					KeyID:  btcjson.Uint32(4),
+				}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.revokekey","params":[{"keyset":"asp","pubkey":"02ab","keyid":4}],"id":1}`,
+			unmarshalled: &btcjson.AdminRevokeKeyCmd{
+				KeyOp: btcjson.AdminKeyOp{
+					KeySet: "asp",
+					PubKey: "02ab",
+					KeyID:  btcjson.Uint32(4),
+				},
+				Submit: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "admin.rotatekeyid",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.rotatekeyid", 3, "02ab", 0.0001)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminRotateKeyIDCmd(3, "02ab",
+					btcjson.Float64(0.0001), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.rotatekeyid","params":[3,"02ab",0.0001],"id":1}`,
+			unmarshalled: &btcjson.AdminRotateKeyIDCmd{
+				KeyID:   3,
+				PubKey:  "02ab",
+				FeeRate: btcjson.Float64(0.0001),
+				Submit:  btcjson.Bool(false),
+			},
+		},
+		{
+			name: "admin.unfreezekeyid",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.unfreezekeyid", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminUnfreezeKeyIDCmd(3, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.unfreezekeyid","params":[3],"id":1}`,
+			unmarshalled: &btcjson.AdminUnfreezeKeyIDCmd{
+				KeyID:  3,
+				Submit: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "admin.proposetx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.proposetx", "0100", "provision")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminProposeTxCmd("0100",
+					btcjson.String("provision"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.proposetx","params":["0100","provision"],"id":1}`,
+			unmarshalled: &btcjson.AdminProposeTxCmd{
+				HexTx:       "0100",
+				Description: btcjson.String("provision"),
+			},
+		},
+		{
+			name: "admin.signproposal",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.signproposal", "abcd")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminSignProposalCmd("abcd")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.signproposal","params":["abcd"],"id":1}`,
+			unmarshalled: &btcjson.AdminSignProposalCmd{
+				TxID: "abcd",
+			},
+		},
+		{
+			name: "admin.addproposalsigs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("admin.addproposalsigs", "abcd", "cHNwdP8=")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAdminAddProposalSigsCmd("abcd",
+					"cHNwdP8=")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admin.addproposalsigs","params":["abcd","cHNwdP8="],"id":1}`,
+			unmarshalled: &btcjson.AdminAddProposalSigsCmd{
+				TxID: "abcd",
+				PSPT: "cHNwdP8=",
+			},
+		},
+		{
+			name: "getrpcinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrpcinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRPCInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrpcinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRPCInfoCmd{},
+		},
+		{
+			name: "rotaterpcauth",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rotaterpcauth")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRotateRPCAuthCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"rotaterpcauth","params":[],"id":1}`,
+			unmarshalled: &btcjson.RotateRPCAuthCmd{},
+		},
+		{
+			name: "rotaterpcauth user",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rotaterpcauth", "explorer")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRotateRPCAuthCmd(btcjson.String("explorer"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rotaterpcauth","params":["explorer"],"id":1}`,
+			unmarshalled: &btcjson.RotateRPCAuthCmd{
+				User: btcjson.String("explorer"),
+			},
+		},
+		{
+			name: "backupchainstate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("backupchainstate", "/backups/prova")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBackupChainStateCmd("/backups/prova")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"backupchainstate","params":["/backups/prova"],"id":1}`,
+			unmarshalled: &btcjson.BackupChainStateCmd{
+				Destination: "/backups/prova",
+			},
+		},
+		{
+			name: "compactdb",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("compactdb")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCompactDBCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"compactdb","params":[],"id":1}`,
+			unmarshalled: &btcjson.CompactDBCmd{},
+		},
+		{
+			name: "getdbinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdbinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDBInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdbinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetDBInfoCmd{},
+		},
+		{
+			name: "getkeyidvelocity",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidvelocity", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDVelocityCmd(3)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidvelocity","params":[3],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDVelocityCmd{
+				KeyID: 3,
+			},
+		},
+		{
+			name: "setkeyidvelocityoverride",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setkeyidvelocityoverride", 3, 3600)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetKeyIDVelocityOverrideCmd(3, 3600)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setkeyidvelocityoverride","params":[3,3600],"id":1}`,
+			unmarshalled: &btcjson.SetKeyIDVelocityOverrideCmd{
+				KeyID:    3,
+				Duration: 3600,
+			},
+		},
+		{
+			name: "getauditlog",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getauditlog")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAuditLogCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getauditlog","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetAuditLogCmd{
+				Start: btcjson.Uint64(1),
+				Count: btcjson.Int(1000),
+			},
+		},
+		{
+			name: "getauditlog optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getauditlog", 42, 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAuditLogCmd(btcjson.Uint64(42),
+					btcjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getauditlog","params":[42,10],"id":1}`,
+			unmarshalled: &btcjson.GetAuditLogCmd{
+				Start: btcjson.Uint64(42),
+				Count: btcjson.Int(10),
+			},
+		},
+		{
+			name: "verifyauditlog",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifyauditlog")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyAuditLogCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"verifyauditlog","params":[],"id":1}`,
+			unmarshalled: &btcjson.VerifyAuditLogCmd{},
+		},
+		{
+			name: "getattestation",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getattestation")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAttestationCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getattestation","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetAttestationCmd{},
+		},
+		{
+			name: "verifyattestation",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifyattestation", "0102")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyAttestationCmd("0102")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifyattestation","params":["0102"],"id":1}`,
+			unmarshalled: &btcjson.VerifyAttestationCmd{
+				Hex: "0102",
+			},
+		},
+		{
+			name: "getspvinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspvinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSPVInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getspvinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetSPVInfoCmd{},
+		},
+		{
+			name: "startcpuprofile",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("startcpuprofile", "cpu.prof")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStartCPUProfileCmd("cpu.prof")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"startcpuprofile","params":["cpu.prof"],"id":1}`,
+			unmarshalled: &btcjson.StartCPUProfileCmd{
+				File: "cpu.prof",
+			},
+		},
+		{
+			name: "stopcpuprofile",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopcpuprofile")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopCPUProfileCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopcpuprofile","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopCPUProfileCmd{},
+		},
+		{
+			name: "writeprofile",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("writeprofile", "goroutine", "stacks.txt")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewWriteProfileCmd("goroutine", "stacks.txt")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"writeprofile","params":["goroutine","stacks.txt"],"id":1}`,
+			unmarshalled: &btcjson.WriteProfileCmd{
+				Profile: "goroutine",
+				File:    "stacks.txt",
+			},
+		},
+		{
+			name: "setprofileserver",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setprofileserver", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetProfileServerCmd(false, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setprofileserver","params":[false],"id":1}`,
+			unmarshalled: &btcjson.SetProfileServerCmd{
+				Enable: false,
+			},
+		},
+		{
+			name: "setprofileserver optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setprofileserver", true, "6060")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetProfileServerCmd(true,
+					btcjson.String("6060"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setprofileserver","params":[true,"6060"],"id":1}`,
+			unmarshalled: &btcjson.SetProfileServerCmd{
+				Enable: true,
+				Port:   btcjson.String("6060"),
+			},
+		},
+		{
+			name: "getdiagnostics",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdiagnostics")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDiagnosticsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdiagnostics","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetDiagnosticsCmd{},
+		},
+		{
+			name: "enableindex",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("enableindex", "spentindex")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEnableIndexCmd("spentindex")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"enableindex","params":["spentindex"],"id":1}`,
+			unmarshalled: &btcjson.EnableIndexCmd{
+				Index: "spentindex",
+			},
+		},
+		{
+			name: "dropindex",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dropindex", "spentindex")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDropIndexCmd("spentindex")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dropindex","params":["spentindex"],"id":1}`,
+			unmarshalled: &btcjson.DropIndexCmd{
+				Index: "spentindex",
+			},
+		},
+		{
+			name: "rpc.discover",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rpc.discover")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRPCDiscoverCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"rpc.discover","params":[],"id":1}`,
+			unmarshalled: &btcjson.RPCDiscoverCmd{},
+		},
 		{
 			name: "setvalidatekeys",
 			newCmd: func() (interface{}, error) {
@@ -578,6 +578,11 @@ type RawTxInput struct {
 	Vout         uint32 `json:"vout"`
 	ScriptPubKey string `json:"scriptPubKey"`
 	RedeemScript string `json:"redeemScript"`
+
+	// Amount is the value of the output in RMG.  Prova signatures commit
+	// to it, so it is looked up in the unspent outputs of the main chain
+	// when omitted.
+	Amount *float64 `json:"amount,omitempty"`
 }
 
 // SignRawTransactionCmd defines the signrawtransaction JSON-RPC command.
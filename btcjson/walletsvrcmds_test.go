@@ -1116,6 +1116,38 @@ func TestWalletSvrCmds(t *testing.T) {
 				Flags:    btcjson.String("ALL"),
 			},
 		},
+		{
+			name: "signrawtransaction amount",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("signrawtransaction", "001122", `[{"txid":"123","vout":1,"scriptPubKey":"00","redeemScript":"","amount":0.5}]`)
+			},
+			staticCmd: func() interface{} {
+				txInputs := []btcjson.RawTxInput{
+					{
+						Txid:         "123",
+						Vout:         1,
+						ScriptPubKey: "00",
+						Amount:       btcjson.Float64(0.5),
+					},
+				}
+
+				return btcjson.NewSignRawTransactionCmd("001122", &txInputs, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"signrawtransaction","params":["001122",[{"txid":"123","vout":1,"scriptPubKey":"00","redeemScript":"","amount":0.5}]],"id":1}`,
+			unmarshalled: &btcjson.SignRawTransactionCmd{
+				RawTx: "001122",
+				Inputs: &[]btcjson.RawTxInput{
+					{
+						Txid:         "123",
+						Vout:         1,
+						ScriptPubKey: "00",
+						Amount:       btcjson.Float64(0.5),
+					},
+				},
+				PrivKeys: nil,
+				Flags:    btcjson.String("ALL"),
+			},
+		},
 		{
 			name: "signrawtransaction optional2",
 			newCmd: func() (interface{}, error) {
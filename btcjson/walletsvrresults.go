@@ -109,15 +109,16 @@ type ListSinceBlockResult struct {
 
 // ListUnspentResult models a successful response from the listunspent request.
 type ListUnspentResult struct {
-	TxID          string  `json:"txid"`
-	Vout          uint32  `json:"vout"`
-	Address       string  `json:"address"`
-	Account       string  `json:"account"`
-	ScriptPubKey  string  `json:"scriptPubKey"`
-	RedeemScript  string  `json:"redeemScript,omitempty"`
-	Amount        float64 `json:"amount"`
-	Confirmations int64   `json:"confirmations"`
-	Spendable     bool    `json:"spendable"`
+	TxID          string   `json:"txid"`
+	Vout          uint32   `json:"vout"`
+	Address       string   `json:"address"`
+	Account       string   `json:"account"`
+	ScriptPubKey  string   `json:"scriptPubKey"`
+	RedeemScript  string   `json:"redeemScript,omitempty"`
+	Amount        float64  `json:"amount"`
+	Confirmations int64    `json:"confirmations"`
+	Spendable     bool     `json:"spendable"`
+	KeyIDs        []uint32 `json:"keyids,omitempty"`
 }
 
 // SignRawTransactionError models the data that contains script verification
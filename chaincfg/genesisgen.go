@@ -0,0 +1,223 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// maxGenesisMessageLen is the maximum length of the message in the signature
+// script of a generated genesis coinbase.  The script also holds the 32 byte
+// commitment to the admin keys and must not exceed the 100 bytes allowed for
+// coinbase scripts.
+const maxGenesisMessageLen = 100 - chainhash.HashSize
+
+// NetworkConfig describes a new network created by NewNetwork.
+type NetworkConfig struct {
+	// Base is the default network the parameters which are not defined
+	// here, such as the proof of work limit, are taken from.
+	Base *Params
+
+	// Name, Net and DefaultPort identify the new network.
+	Name        string
+	Net         wire.BitcoinNet
+	DefaultPort string
+
+	// AdminKeySets are the initial admin key sets of the network.  The
+	// root and validate key sets must not be empty.
+	AdminKeySets map[btcec.KeySetType]btcec.PublicKeySet
+
+	// ASPKeyIdMap maps the initial ASP key ids to their keys.
+	ASPKeyIdMap btcec.KeyIdMap
+
+	// AdminThresholds are the numbers of signatures the root, provision
+	// and issue threads of the network require, keyed by key set.  Key
+	// sets without a threshold require DefaultAdminThreshold signatures.
+	AdminThresholds map[btcec.KeySetType]int
+
+	// Timestamp is the time of the genesis block.
+	Timestamp time.Time
+
+	// Message is committed to in the signature script of the genesis
+	// coinbase, usually a recent headline or block hash proving the network
+	// was not created earlier.
+	Message string
+}
+
+// adminKeysCommitment returns the hash committing to the passed admin key sets
+// and ASP key ids.  The keys are serialized compressed, ordered by key set type
+// and key id respectively.
+func adminKeysCommitment(keySets map[btcec.KeySetType]btcec.PublicKeySet,
+	aspKeyIDs btcec.KeyIdMap) chainhash.Hash {
+
+	var buf bytes.Buffer
+	setTypes := make([]int, 0, len(keySets))
+	for setType := range keySets {
+		setTypes = append(setTypes, int(setType))
+	}
+	sort.Ints(setTypes)
+	for _, setType := range setTypes {
+		keySet := keySets[btcec.KeySetType(setType)]
+		buf.WriteByte(byte(setType))
+		wire.WriteVarInt(&buf, 0, uint64(len(keySet)))
+		for i := range keySet {
+			buf.Write(keySet[i].SerializeCompressed())
+		}
+	}
+
+	keyIDs := make([]int, 0, len(aspKeyIDs))
+	for keyID := range aspKeyIDs {
+		keyIDs = append(keyIDs, int(keyID))
+	}
+	sort.Ints(keyIDs)
+	wire.WriteVarInt(&buf, 0, uint64(len(keyIDs)))
+	for _, keyID := range keyIDs {
+		wire.WriteVarInt(&buf, 0, uint64(keyID))
+		buf.Write(aspKeyIDs[btcec.KeyID(keyID)].SerializeCompressed())
+	}
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// hashToBig converts a block hash into a big integer which can be compared to
+// a proof of work limit.
+func hashToBig(hash *chainhash.Hash) *big.Int {
+	// A Hash is in little-endian, but the big package wants the bytes in
+	// big-endian, so reverse them.
+	buf := *hash
+	blen := len(buf)
+	for i := 0; i < blen/2; i++ {
+		buf[i], buf[blen-1-i] = buf[blen-1-i], buf[i]
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// NewGenesisBlock returns a genesis block with the passed timestamp and
+// difficulty bits, whose coinbase commits to the passed message and admin
+// keys.  The coinbase creates the root, provision and issue threads like the
+// genesis blocks of the default networks.  The nonce is searched for until the
+// block hash does not exceed powLimit.
+func NewGenesisBlock(timestamp time.Time, bits uint32, powLimit *big.Int,
+	message string, keySets map[btcec.KeySetType]btcec.PublicKeySet,
+	aspKeyIDs btcec.KeyIdMap) (*wire.MsgBlock, error) {
+
+	if len(message) > maxGenesisMessageLen {
+		return nil, fmt.Errorf("the genesis message must not be longer "+
+			"than %d bytes", maxGenesisMessageLen)
+	}
+
+	commitment := adminKeysCommitment(keySets, aspKeyIDs)
+	sigScript := make([]byte, 0, chainhash.HashSize+len(message))
+	sigScript = append(sigScript, commitment[:]...)
+	sigScript = append(sigScript, message...)
+	coinbase := wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{
+			{
+				PreviousOutPoint: wire.OutPoint{
+					Hash:  chainhash.Hash{},
+					Index: 0xffffffff,
+				},
+				SignatureScript: sigScript,
+				Sequence:        0xffffffff,
+			},
+		},
+		TxOut: []*wire.TxOut{
+			{
+				PkScript: []byte{
+					0x00, 0xbb, // Root Thread Id, OP_CHECKTHREAD
+				},
+			},
+			{
+				PkScript: []byte{
+					0x51, 0xbb, // Provision Thread, OP_CHECKTHREAD
+				},
+			},
+			{
+				PkScript: []byte{
+					0x52, 0xbb, // Issue Thread, OP_CHECKTHREAD
+				},
+			},
+		},
+	}
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    4,
+			MerkleRoot: coinbaseMerkleRoot(coinbase),
+			Timestamp:  time.Unix(timestamp.Unix(), 0),
+			Bits:       bits,
+		},
+		Transactions: []*wire.MsgTx{&coinbase},
+	}
+	block.Header.Size = uint32(block.SerializeSize())
+
+	for nonce := uint64(0); nonce <= math.MaxUint32; nonce++ {
+		block.Header.Nonce = nonce
+		hash := block.Header.BlockHash()
+		if hashToBig(&hash).Cmp(powLimit) <= 0 {
+			return block, nil
+		}
+	}
+	return nil, errors.New("no nonce solves the genesis block")
+}
+
+// NewNetwork returns the parameters of a new network described by the passed
+// configuration, including a new genesis block committing to its admin keys
+// and a checkpoint at the genesis block.  The parameters can be written to a
+// parameters file with EncodeParams, which is how operators bootstrap a
+// private network.
+func NewNetwork(config *NetworkConfig) (*Params, error) {
+	if config.Base == nil {
+		return nil, errors.New("a base network is required")
+	}
+	for _, setType := range []btcec.KeySetType{btcec.RootKeySet,
+		btcec.ValidateKeySet} {
+
+		if len(config.AdminKeySets[setType]) == 0 {
+			return nil, fmt.Errorf("the %v key set must not be empty",
+				setType)
+		}
+	}
+
+	genesis, err := NewGenesisBlock(config.Timestamp,
+		config.Base.PowLimitBits, config.Base.PowLimit, config.Message,
+		config.AdminKeySets, config.ASPKeyIdMap)
+	if err != nil {
+		return nil, err
+	}
+	genesisHash := genesis.BlockHash()
+
+	params := *config.Base
+	params.Name = config.Name
+	params.Net = config.Net
+	params.DefaultPort = config.DefaultPort
+	params.DNSSeeds = nil
+	params.GenesisBlock = genesis
+	params.GenesisHash = &genesisHash
+	params.AdminKeySets = btcec.DeepCopy(config.AdminKeySets)
+	params.ASPKeyIdMap = make(btcec.KeyIdMap)
+	for keyID, pubKey := range config.ASPKeyIdMap {
+		params.ASPKeyIdMap[keyID] = pubKey
+	}
+	params.AdminThresholds = make(map[btcec.KeySetType]int)
+	for setType, threshold := range config.AdminThresholds {
+		params.AdminThresholds[setType] = threshold
+	}
+	params.Checkpoints = []Checkpoint{{Height: 0, Hash: &genesisHash}}
+	if err := validateParams(&params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
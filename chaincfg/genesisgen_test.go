@@ -0,0 +1,129 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	. "github.com/bitgo/prova/chaincfg"
+)
+
+// TestNewNetwork ensures a new network commits to its admin keys in the
+// genesis block and survives a round trip through a parameters file.
+func TestNewNetwork(t *testing.T) {
+	rootKeys, err := btcec.ParsePubKeySet(btcec.S256(),
+		"025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1")
+	if err != nil {
+		t.Fatalf("ParsePubKeySet: unexpected error: %v", err)
+	}
+	validateKeys, err := btcec.ParsePubKeySet(btcec.S256(),
+		"035f5103852bd7d9c9c28e44caf1f7188941e16295062ca4c89928a8ccff993cd3")
+	if err != nil {
+		t.Fatalf("ParsePubKeySet: unexpected error: %v", err)
+	}
+	config := NetworkConfig{
+		Base:        &RegressionNetParams,
+		Name:        "privnet",
+		Net:         0x12345678,
+		DefaultPort: "19979",
+		AdminKeySets: map[btcec.KeySetType]btcec.PublicKeySet{
+			btcec.RootKeySet:     rootKeys,
+			btcec.ValidateKeySet: validateKeys,
+		},
+		Timestamp: time.Unix(0x5a000000, 0),
+		Message:   "privnet genesis",
+	}
+	params, err := NewNetwork(&config)
+	if err != nil {
+		t.Fatalf("NewNetwork: unexpected error: %v", err)
+	}
+
+	genesis := params.GenesisBlock
+	if *params.GenesisHash != genesis.BlockHash() {
+		t.Errorf("NewNetwork: genesis hash %v does not match the block",
+			params.GenesisHash)
+	}
+	if genesis.Header.Size != uint32(genesis.SerializeSize()) {
+		t.Errorf("NewNetwork: genesis size %d, want %d",
+			genesis.Header.Size, genesis.SerializeSize())
+	}
+	if len(params.Checkpoints) != 1 || params.Checkpoints[0].Height != 0 ||
+		*params.Checkpoints[0].Hash != *params.GenesisHash {
+
+		t.Errorf("NewNetwork: got checkpoints %v, want the genesis block",
+			params.Checkpoints)
+	}
+	if !reflect.DeepEqual(params.AdminKeySets, config.AdminKeySets) {
+		t.Errorf("NewNetwork: admin key sets not taken from the config")
+	}
+	if params.PowLimitBits != RegressionNetParams.PowLimitBits {
+		t.Errorf("NewNetwork: fields not taken from the base")
+	}
+
+	// The genesis block must be reproducible, and change with the keys.
+	again, err := NewNetwork(&config)
+	if err != nil {
+		t.Fatalf("NewNetwork: unexpected error: %v", err)
+	}
+	if *again.GenesisHash != *params.GenesisHash {
+		t.Errorf("NewNetwork: genesis hash %v is not reproducible, "+
+			"want %v", again.GenesisHash, params.GenesisHash)
+	}
+	config.AdminKeySets = map[btcec.KeySetType]btcec.PublicKeySet{
+		btcec.RootKeySet:     validateKeys,
+		btcec.ValidateKeySet: validateKeys,
+	}
+	other, err := NewNetwork(&config)
+	if err != nil {
+		t.Fatalf("NewNetwork: unexpected error: %v", err)
+	}
+	if *other.GenesisHash == *params.GenesisHash {
+		t.Errorf("NewNetwork: genesis block does not commit to the " +
+			"admin keys")
+	}
+
+	var encoded bytes.Buffer
+	if err := EncodeParams(&encoded, params); err != nil {
+		t.Fatalf("EncodeParams: unexpected error: %v", err)
+	}
+	decoded, err := DecodeParams(&encoded)
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error: %v", err)
+	}
+	if *decoded.GenesisHash != *params.GenesisHash {
+		t.Errorf("DecodeParams: got genesis %v, want %v",
+			decoded.GenesisHash, params.GenesisHash)
+	}
+
+	// Invalid configurations.
+	config.AdminKeySets = map[btcec.KeySetType]btcec.PublicKeySet{
+		btcec.RootKeySet: rootKeys,
+	}
+	if _, err := NewNetwork(&config); err == nil {
+		t.Errorf("NewNetwork: no error without validate keys")
+	}
+	config.AdminKeySets[btcec.ValidateKeySet] = validateKeys
+	config.Message = strings.Repeat("x", 69)
+	if _, err := NewNetwork(&config); err == nil {
+		t.Errorf("NewNetwork: no error with a too long message")
+	}
+	config.Message = ""
+	config.AdminThresholds = map[btcec.KeySetType]int{btcec.RootKeySet: 2}
+	if _, err := NewNetwork(&config); err == nil {
+		t.Errorf("NewNetwork: no error with a root threshold of 2 " +
+			"with a single root key")
+	}
+	config.AdminThresholds = nil
+	config.Net = RegressionNetParams.Net
+	if _, err := NewNetwork(&config); err == nil {
+		t.Errorf("NewNetwork: no error with the magic of a default " +
+			"network")
+	}
+}
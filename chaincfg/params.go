@@ -9,10 +9,13 @@ package chaincfg
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/wire"
+	"math"
 	"math/big"
+	"strings"
 	"time"
 )
 
@@ -52,6 +55,84 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// SubsidyMode identifies how the block subsidy of a network evolves with the
+// block height.
+type SubsidyMode uint8
+
+const (
+	// NoSubsidy means blocks pay no subsidy, so coinbase transactions only
+	// collect the transaction fees.
+	NoSubsidy SubsidyMode = iota
+
+	// FixedSubsidy means every block pays BaseSubsidy.
+	FixedSubsidy
+
+	// DecayingSubsidy means the subsidy starts at BaseSubsidy and is
+	// halved every SubsidyReductionInterval blocks.
+	DecayingSubsidy
+)
+
+// subsidyModeStrings is a map of subsidy modes back to their constant names
+// for pretty printing.
+var subsidyModeStrings = map[SubsidyMode]string{
+	NoSubsidy:       "none",
+	FixedSubsidy:    "fixed",
+	DecayingSubsidy: "decaying",
+}
+
+// String returns the SubsidyMode in human-readable form.
+func (mode SubsidyMode) String() string {
+	if s, ok := subsidyModeStrings[mode]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown SubsidyMode (%d)", uint8(mode))
+}
+
+// DifficultyAlgorithm identifies how the proof of work difficulty of a network
+// is retargeted from block to block.
+type DifficultyAlgorithm uint8
+
+const (
+	// WindowDifficulty means the difficulty is the average difficulty of
+	// the last PowAveragingWindow blocks, adjusted by the ratio of the
+	// target timespan of the window to the time they actually took,
+	// dampened and bounded by PowMaxAdjustDown and PowMaxAdjustUp.
+	WindowDifficulty DifficultyAlgorithm = iota
+
+	// FixedDifficulty means every block has the PowLimitBits difficulty,
+	// so blocks are produced as fast as validators sign them.  It suits
+	// private networks where the validate keys and their rate limits
+	// rather than the proof of work pace block production.
+	FixedDifficulty
+
+	// EMADifficulty means the difficulty is adjusted after every block by
+	// the time its parent took to solve, as an exponential moving average
+	// with a smoothing of PowAveragingWindow blocks.  It reacts to a
+	// single slow or fast block without waiting for a whole window, which
+	// suits networks targeting short block intervals.
+	EMADifficulty
+)
+
+// difficultyAlgorithmStrings is a map of difficulty algorithms back to their
+// constant names for pretty printing.
+var difficultyAlgorithmStrings = map[DifficultyAlgorithm]string{
+	WindowDifficulty: "window",
+	FixedDifficulty:  "fixed",
+	EMADifficulty:    "ema",
+}
+
+// String returns the DifficultyAlgorithm in human-readable form.
+func (algorithm DifficultyAlgorithm) String() string {
+	if s, ok := difficultyAlgorithmStrings[algorithm]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown DifficultyAlgorithm (%d)", uint8(algorithm))
+}
+
+// DefaultAdminThreshold is the number of signatures required to spend the tip
+// of an admin thread when the network does not set a threshold for its key set.
+const DefaultAdminThreshold = 2
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -91,6 +172,12 @@ type Params struct {
 	// ASPKeyIdMap are the provisioned keyIDs and respective pubKeys
 	ASPKeyIdMap btcec.KeyIdMap
 
+	// AdminThresholds are the numbers of signatures from the root,
+	// provision and issue keys required to spend the tip of the respective
+	// admin thread, keyed by key set.  Key sets without a threshold
+	// require DefaultAdminThreshold signatures.
+	AdminThresholds map[btcec.KeySetType]int
+
 	// PowLimit defines the highest allowed proof of work value for a block
 	// as a uint256.
 	PowLimit *big.Int
@@ -103,10 +190,24 @@ type Params struct {
 	// coins (coinbase transactions) can be spent.
 	CoinbaseMaturity uint16
 
+	// SubsidyMode defines how the block subsidy evolves with the block
+	// height.
+	SubsidyMode SubsidyMode
+
+	// BaseSubsidy is the subsidy of the first block after the genesis
+	// block, in atoms.
+	BaseSubsidy int64
+
 	// SubsidyReductionInterval is the interval of blocks before the subsidy
-	// is reduced.
+	// is reduced when SubsidyMode is DecayingSubsidy.
 	SubsidyReductionInterval uint32
 
+	// MaxSubsidySupply is the hard cap on the total amount of atoms created
+	// by block subsidies, or zero when there is none.  The subsidy of the
+	// block reaching the cap is reduced accordingly and later blocks pay no
+	// subsidy.
+	MaxSubsidySupply int64
+
 	// TargetTimePerBlock is the desired amount of time to generate each
 	// block.
 	TargetTimePerBlock time.Duration
@@ -128,13 +229,48 @@ type Params struct {
 	// The number of nodes to check.  This is part of BIP0034.
 	BlockUpgradeNumToCheck uint64
 
+	// CLTVActivationHeight is the height at which OP_CHECKLOCKTIMEVERIFY
+	// is enforced for all blocks and timelocked Prova scripts may first be
+	// created.
+	CLTVActivationHeight uint32
+
+	// SchnorrActivationHeight is the height at which 64-byte signatures
+	// checked by OP_CHECKSAFEMULTISIG and OP_CHECKTHREAD are validated as
+	// Schnorr signatures for all blocks.
+	SchnorrActivationHeight uint32
+
+	// FreezeActivationHeight is the height at which the issue thread may
+	// first freeze and unfreeze keyIDs.  Outputs co-signed by a frozen
+	// keyID may only be spent by issue thread transactions.
+	FreezeActivationHeight uint32
+
+	// CoinbaseActivationHeight is the height at which coinbase
+	// transactions must commit to the height of the block containing them
+	// in their lock time and may no longer duplicate an earlier coinbase.
+	CoinbaseActivationHeight uint32
+
+	// GeneralProvaActivationHeight is the height at which outputs to
+	// generalized m-of-n Prova scripts with several pubkey hashes may
+	// first be created and the keyIDs of generalized scripts are resolved
+	// when spending them.
+	GeneralProvaActivationHeight uint32
+
 	// Mempool parameters
 	RelayNonStdTxs bool
 
+	// RelayGeneralProvaTxs defines whether transactions creating outputs
+	// to generalized m-of-n Prova scripts other than the standard 2-of-3
+	// form are accepted to the mempool and mined.
+	RelayGeneralProvaTxs bool
+
 	// Address encoding magics
 	ProvaAddrID  byte // First byte of an Prova address
 	PrivateKeyID byte // First byte of a WIF private key
 
+	// Bech32HRPProva is the human-readable part of Bech32 encoded Prova
+	// addresses.
+	Bech32HRPProva string
+
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID [4]byte
 	HDPublicKeyID  [4]byte
@@ -143,6 +279,9 @@ type Params struct {
 	// address generation.
 	HDCoinType uint32
 
+	// DifficultyAlgorithm defines how the difficulty is retargeted.
+	DifficultyAlgorithm DifficultyAlgorithm
+
 	// Number of blocks for the moving window of difficulty adjustment.
 	PowAveragingWindow int
 
@@ -160,6 +299,20 @@ type Params struct {
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount int64
+
+	// AmountDecimals is the number of decimal places of the amounts in RMG
+	// which are issued and accepted on the network, at most the 6 decimal
+	// places of an atom.
+	AmountDecimals uint8
+}
+
+// AdminThreshold returns the number of signatures from the keys of the passed
+// admin key set required to spend the tip of its thread.
+func (p Params) AdminThreshold(setType btcec.KeySetType) int {
+	if threshold, ok := p.AdminThresholds[setType]; ok {
+		return threshold
+	}
+	return DefaultAdminThreshold
 }
 
 // MaxActualTimespan returns a timespan with the down-dampening factor applied.
@@ -230,6 +383,7 @@ var MainNetParams = Params{
 	PowLimit:                 mainPowLimit,
 	PowLimitBits:             0x1f07ffff,
 	CoinbaseMaturity:         100,
+	SubsidyMode:              NoSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimePerBlock:       time.Second * 150, // 2.5 minutes
 	GenerateSupported:        false,
@@ -247,12 +401,29 @@ var MainNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// OP_CHECKLOCKTIMEVERIFY activation.  Not yet scheduled.
+	CLTVActivationHeight: math.MaxUint32,
+
+	// Schnorr signature activation.  Not yet scheduled.
+	SchnorrActivationHeight: math.MaxUint32,
+
+	// KeyID freeze activation.  Not yet scheduled.
+	FreezeActivationHeight: math.MaxUint32,
+
+	// Coinbase height commitment activation.  Not yet scheduled.
+	CoinbaseActivationHeight: math.MaxUint32,
+
+	// Generalized m-of-n Prova script activation.  Not yet scheduled.
+	GeneralProvaActivationHeight: math.MaxUint32,
+
 	// Mempool parameters
-	RelayNonStdTxs: false,
+	RelayNonStdTxs:       false,
+	RelayGeneralProvaTxs: false,
 
 	// Address encoding magics
-	PrivateKeyID: 0x80, // starts with 5 (uncompressed) or K (compressed)
-	ProvaAddrID:  0x33, // starts with G
+	PrivateKeyID:   0x80,    // starts with 5 (uncompressed) or K (compressed)
+	ProvaAddrID:    0x33,    // starts with G
+	Bech32HRPProva: "prova", // starts with prova1
 
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID: [4]byte{0x04, 0x88, 0xad, 0xe4}, // starts with xprv
@@ -262,6 +433,9 @@ var MainNetParams = Params{
 	// address generation.
 	HDCoinType: 0,
 
+	// Algorithm used to retarget the difficulty.
+	DifficultyAlgorithm: WindowDifficulty,
+
 	// Number of blocks for the moving window of difficulty adjustment.
 	PowAveragingWindow: 17,
 
@@ -279,6 +453,9 @@ var MainNetParams = Params{
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount: 5000000,
+
+	// Amounts are accepted down to the atom.
+	AmountDecimals: 6,
 }
 
 // RegressionNetParams defines the network parameters for the regression test
@@ -319,6 +496,7 @@ var RegressionNetParams = Params{
 	PowLimit:                 regressionPowLimit,
 	PowLimitBits:             0x200f0f0f,
 	CoinbaseMaturity:         100,
+	SubsidyMode:              NoSubsidy,
 	SubsidyReductionInterval: 150,
 	TargetTimePerBlock:       time.Minute, // 1 minute
 	GenerateSupported:        true,
@@ -333,12 +511,29 @@ var RegressionNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// OP_CHECKLOCKTIMEVERIFY activation.  Always active.
+	CLTVActivationHeight: 0,
+
+	// Schnorr signature activation.  Always active.
+	SchnorrActivationHeight: 0,
+
+	// KeyID freeze activation.  Always active.
+	FreezeActivationHeight: 0,
+
+	// Coinbase height commitment activation.  Always active.
+	CoinbaseActivationHeight: 0,
+
+	// Generalized m-of-n Prova script activation.  Always active.
+	GeneralProvaActivationHeight: 0,
+
 	// Mempool parameters
-	RelayNonStdTxs: true,
+	RelayNonStdTxs:       true,
+	RelayGeneralProvaTxs: true,
 
 	// Address encoding magics
-	ProvaAddrID:  0x58, // starts with T
-	PrivateKeyID: 0xef, // starts with 9 (uncompressed) or c (compressed)
+	ProvaAddrID:    0x58,     // starts with T
+	PrivateKeyID:   0xef,     // starts with 9 (uncompressed) or c (compressed)
+	Bech32HRPProva: "rprova", // starts with rprova1
 
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
@@ -348,6 +543,9 @@ var RegressionNetParams = Params{
 	// address generation.
 	HDCoinType: 1,
 
+	// Algorithm used to retarget the difficulty.
+	DifficultyAlgorithm: WindowDifficulty,
+
 	// Number of blocks for the moving window of difficulty adjustment
 	PowAveragingWindow: 17,
 
@@ -359,6 +557,9 @@ var RegressionNetParams = Params{
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount: 5000000,
+
+	// Amounts are accepted down to the atom.
+	AmountDecimals: 6,
 }
 
 // TestNetParams defines the network parameters for the test network.
@@ -402,6 +603,7 @@ var TestNetParams = Params{
 	PowLimit:                 testNetPowLimit,
 	PowLimitBits:             0x2007ffff,
 	CoinbaseMaturity:         100,
+	SubsidyMode:              NoSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimePerBlock:       time.Second * 150, // 2.5 minutes
 	GenerateSupported:        false,
@@ -419,12 +621,29 @@ var TestNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// OP_CHECKLOCKTIMEVERIFY activation.  Not yet scheduled.
+	CLTVActivationHeight: math.MaxUint32,
+
+	// Schnorr signature activation.  Not yet scheduled.
+	SchnorrActivationHeight: math.MaxUint32,
+
+	// KeyID freeze activation.  Not yet scheduled.
+	FreezeActivationHeight: math.MaxUint32,
+
+	// Coinbase height commitment activation.  Not yet scheduled.
+	CoinbaseActivationHeight: math.MaxUint32,
+
+	// Generalized m-of-n Prova script activation.  Not yet scheduled.
+	GeneralProvaActivationHeight: math.MaxUint32,
+
 	// Mempool parameters
-	RelayNonStdTxs: true,
+	RelayNonStdTxs:       true,
+	RelayGeneralProvaTxs: true,
 
 	// Address encoding magics
-	PrivateKeyID: 0xef, // starts with 9 (uncompressed) or c (compressed)
-	ProvaAddrID:  0x58, // starts with T
+	PrivateKeyID:   0xef,     // starts with 9 (uncompressed) or c (compressed)
+	ProvaAddrID:    0x58,     // starts with T
+	Bech32HRPProva: "tprova", // starts with tprova1
 
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
@@ -434,6 +653,9 @@ var TestNetParams = Params{
 	// address generation.
 	HDCoinType: 1,
 
+	// Algorithm used to retarget the difficulty.
+	DifficultyAlgorithm: WindowDifficulty,
+
 	// Number of blocks for the moving window of difficulty adjustment.
 	PowAveragingWindow: 17,
 
@@ -451,6 +673,9 @@ var TestNetParams = Params{
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount: 5000000,
+
+	// Amounts are accepted down to the atom.
+	AmountDecimals: 6,
 }
 
 // SimNetParams defines the network parameters for the simulation test Bitcoin
@@ -472,6 +697,7 @@ var SimNetParams = Params{
 	PowLimit:                 simNetPowLimit,
 	PowLimitBits:             0x207fffff,
 	CoinbaseMaturity:         100,
+	SubsidyMode:              NoSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimePerBlock:       time.Second * 150, // 2.5 minutes
 	GenerateSupported:        true,
@@ -489,11 +715,28 @@ var SimNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// OP_CHECKLOCKTIMEVERIFY activation.  Always active.
+	CLTVActivationHeight: 0,
+
+	// Schnorr signature activation.  Always active.
+	SchnorrActivationHeight: 0,
+
+	// KeyID freeze activation.  Always active.
+	FreezeActivationHeight: 0,
+
+	// Coinbase height commitment activation.  Always active.
+	CoinbaseActivationHeight: 0,
+
+	// Generalized m-of-n Prova script activation.  Always active.
+	GeneralProvaActivationHeight: 0,
+
 	// Mempool parameters
-	RelayNonStdTxs: true,
+	RelayNonStdTxs:       true,
+	RelayGeneralProvaTxs: true,
 
 	// Address encoding magics
-	PrivateKeyID: 0x64, // starts with 4 (uncompressed) or F (compressed)
+	PrivateKeyID:   0x64,     // starts with 4 (uncompressed) or F (compressed)
+	Bech32HRPProva: "sprova", // starts with sprova1
 
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID: [4]byte{0x04, 0x20, 0xb9, 0x00}, // starts with sprv
@@ -503,6 +746,9 @@ var SimNetParams = Params{
 	// address generation.
 	HDCoinType: 115, // ASCII for s
 
+	// Algorithm used to retarget the difficulty.
+	DifficultyAlgorithm: WindowDifficulty,
+
 	// Number of blocks for the moving window of difficulty adjustment
 	PowAveragingWindow: 17,
 
@@ -520,6 +766,9 @@ var SimNetParams = Params{
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount: 5000000,
+
+	// Amounts are accepted down to the atom.
+	AmountDecimals: 6,
 }
 
 var (
@@ -539,6 +788,7 @@ var (
 	pubKeyHashAddrIDs = make(map[byte]struct{})
 	scriptHashAddrIDs = make(map[byte]struct{})
 	provaAddrIDs      = make(map[byte]struct{})
+	bech32ProvaHRPs   = make(map[string]byte)
 	hdPrivToPubKeyIDs = make(map[[4]byte][]byte)
 )
 
@@ -564,6 +814,10 @@ func Register(params *Params) error {
 	if params.ProvaAddrID != 0 {
 		provaAddrIDs[params.ProvaAddrID] = struct{}{}
 	}
+	if params.Bech32HRPProva != "" {
+		hrp := strings.ToLower(params.Bech32HRPProva)
+		bech32ProvaHRPs[hrp] = params.ProvaAddrID
+	}
 	hdPrivToPubKeyIDs[params.HDPrivateKeyID] = params.HDPublicKeyID[:]
 	return nil
 }
@@ -606,6 +860,16 @@ func IsProvaAddrID(id byte) bool {
 	return ok
 }
 
+// Bech32ProvaAddrID returns the identifier prefixing base58 encoded Prova
+// addresses on the default or registered network whose Bech32 encoded Prova
+// addresses are prefixed by the passed human-readable part, and whether there
+// is such a network.  The comparison is case insensitive.  This is used when
+// decoding an address string into a specific address type.
+func Bech32ProvaAddrID(hrp string) (byte, bool) {
+	id, ok := bech32ProvaHRPs[strings.ToLower(hrp)]
+	return id, ok
+}
+
 // HDPrivateKeyToPublicKeyID accepts a private hierarchical deterministic
 // extended key id and returns the associated public key id.  When the provided
 // id is not registered, the ErrUnknownHDKeyID error will be returned.
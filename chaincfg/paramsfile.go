@@ -0,0 +1,537 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// keySetNames maps the names of the admin key sets in a parameters file to
+// their types.
+var keySetNames = map[string]btcec.KeySetType{
+	"root":      btcec.RootKeySet,
+	"provision": btcec.ProvisionKeySet,
+	"issue":     btcec.IssueKeySet,
+	"validate":  btcec.ValidateKeySet,
+	"asp":       btcec.ASPKeySet,
+}
+
+// maxAmountDecimals is the number of decimal places of an atom in RMG, which
+// the amounts of a network may not exceed.
+const maxAmountDecimals = 6
+
+// maxAdminThreshold is the maximum number of signatures an admin thread may
+// require, which is the maximum number of keys of its script.
+const maxAdminThreshold = 16
+
+// defaultNets are the networks which are defined by this package, which a
+// parameters file may be based on.
+var defaultNets = []*Params{&MainNetParams, &TestNetParams,
+	&RegressionNetParams, &SimNetParams}
+
+// jsonDNSSeed is the representation of a DNSSeed in a parameters file.
+type jsonDNSSeed struct {
+	Host         string `json:"host"`
+	HasFiltering bool   `json:"hasfiltering"`
+}
+
+// jsonCheckpoint is the representation of a Checkpoint in a parameters file.
+type jsonCheckpoint struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// jsonParams is the representation of Params in a parameters file.  Byte
+// strings, such as the serialized genesis block and the public keys, are hex
+// encoded, the admin key sets and thresholds are keyed by the names in
+// keySetNames, and the target time per block is a duration such as "150s".
+type jsonParams struct {
+	Base                         string              `json:"base,omitempty"`
+	Name                         string              `json:"name"`
+	Net                          uint32              `json:"net"`
+	DefaultPort                  string              `json:"defaultport"`
+	DNSSeeds                     []jsonDNSSeed       `json:"dnsseeds"`
+	GenesisBlock                 string              `json:"genesisblock"`
+	AdminKeySets                 map[string][]string `json:"adminkeysets"`
+	ASPKeyIDs                    map[string]string   `json:"aspkeyids"`
+	AdminThresholds              map[string]int      `json:"adminthresholds"`
+	PowLimit                     string              `json:"powlimit"`
+	PowLimitBits                 uint32              `json:"powlimitbits"`
+	CoinbaseMaturity             uint16              `json:"coinbasematurity"`
+	SubsidyMode                  string              `json:"subsidymode"`
+	BaseSubsidy                  int64               `json:"basesubsidy"`
+	SubsidyReductionInterval     uint32              `json:"subsidyreductioninterval"`
+	MaxSubsidySupply             int64               `json:"maxsubsidysupply"`
+	TargetTimePerBlock           string              `json:"targettimeperblock"`
+	GenerateSupported            bool                `json:"generatesupported"`
+	Checkpoints                  []jsonCheckpoint    `json:"checkpoints"`
+	BlockEnforceNumRequired      uint64              `json:"blockenforcenumrequired"`
+	BlockRejectNumRequired       uint64              `json:"blockrejectnumrequired"`
+	BlockUpgradeNumToCheck       uint64              `json:"blockupgradenumtocheck"`
+	CLTVActivationHeight         uint32              `json:"cltvactivationheight"`
+	SchnorrActivationHeight      uint32              `json:"schnorractivationheight"`
+	FreezeActivationHeight       uint32              `json:"freezeactivationheight"`
+	CoinbaseActivationHeight     uint32              `json:"coinbaseactivationheight"`
+	GeneralProvaActivationHeight uint32              `json:"generalprovaactivationheight"`
+	RelayNonStdTxs               bool                `json:"relaynonstdtxs"`
+	RelayGeneralProvaTxs         bool                `json:"relaygeneralprovatxs"`
+	ProvaAddrID                  byte                `json:"provaaddrid"`
+	PrivateKeyID                 byte                `json:"privatekeyid"`
+	Bech32HRPProva               string              `json:"bech32hrpprova"`
+	HDPrivateKeyID               string              `json:"hdprivatekeyid"`
+	HDPublicKeyID                string              `json:"hdpublickeyid"`
+	HDCoinType                   uint32              `json:"hdcointype"`
+	DifficultyAlgorithm          string              `json:"difficultyalgorithm"`
+	PowAveragingWindow           int                 `json:"powaveragingwindow"`
+	PowMaxAdjustDown             int64               `json:"powmaxadjustdown"`
+	PowMaxAdjustUp               int64               `json:"powmaxadjustup"`
+	ChainTrailingSigKeyLimit     int                 `json:"chaintrailingsigkeylimit"`
+	ChainWindowShareLimit        int                 `json:"chainwindowsharelimit"`
+	MaximumFeeAmount             int64               `json:"maximumfeeamount"`
+	AmountDecimals               uint8               `json:"amountdecimals"`
+}
+
+// newJSONParams returns the representation of the passed parameters in a
+// parameters file.
+func newJSONParams(params *Params) (*jsonParams, error) {
+	var genesis bytes.Buffer
+	if err := params.GenesisBlock.Serialize(&genesis); err != nil {
+		return nil, err
+	}
+
+	jp := &jsonParams{
+		Name:                         params.Name,
+		Net:                          uint32(params.Net),
+		DefaultPort:                  params.DefaultPort,
+		DNSSeeds:                     make([]jsonDNSSeed, 0, len(params.DNSSeeds)),
+		GenesisBlock:                 hex.EncodeToString(genesis.Bytes()),
+		AdminKeySets:                 make(map[string][]string),
+		ASPKeyIDs:                    make(map[string]string),
+		AdminThresholds:              make(map[string]int),
+		PowLimit:                     params.PowLimit.Text(16),
+		PowLimitBits:                 params.PowLimitBits,
+		CoinbaseMaturity:             params.CoinbaseMaturity,
+		SubsidyMode:                  params.SubsidyMode.String(),
+		BaseSubsidy:                  params.BaseSubsidy,
+		SubsidyReductionInterval:     params.SubsidyReductionInterval,
+		MaxSubsidySupply:             params.MaxSubsidySupply,
+		TargetTimePerBlock:           params.TargetTimePerBlock.String(),
+		GenerateSupported:            params.GenerateSupported,
+		Checkpoints:                  make([]jsonCheckpoint, 0, len(params.Checkpoints)),
+		BlockEnforceNumRequired:      params.BlockEnforceNumRequired,
+		BlockRejectNumRequired:       params.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:       params.BlockUpgradeNumToCheck,
+		CLTVActivationHeight:         params.CLTVActivationHeight,
+		SchnorrActivationHeight:      params.SchnorrActivationHeight,
+		FreezeActivationHeight:       params.FreezeActivationHeight,
+		CoinbaseActivationHeight:     params.CoinbaseActivationHeight,
+		GeneralProvaActivationHeight: params.GeneralProvaActivationHeight,
+		RelayNonStdTxs:               params.RelayNonStdTxs,
+		RelayGeneralProvaTxs:         params.RelayGeneralProvaTxs,
+		ProvaAddrID:                  params.ProvaAddrID,
+		PrivateKeyID:                 params.PrivateKeyID,
+		Bech32HRPProva:               params.Bech32HRPProva,
+		HDPrivateKeyID:               hex.EncodeToString(params.HDPrivateKeyID[:]),
+		HDPublicKeyID:                hex.EncodeToString(params.HDPublicKeyID[:]),
+		HDCoinType:                   params.HDCoinType,
+		DifficultyAlgorithm:          params.DifficultyAlgorithm.String(),
+		PowAveragingWindow:           params.PowAveragingWindow,
+		PowMaxAdjustDown:             params.PowMaxAdjustDown,
+		PowMaxAdjustUp:               params.PowMaxAdjustUp,
+		ChainTrailingSigKeyLimit:     params.ChainTrailingSigKeyLimit,
+		ChainWindowShareLimit:        params.ChainWindowShareLimit,
+		MaximumFeeAmount:             params.MaximumFeeAmount,
+		AmountDecimals:               params.AmountDecimals,
+	}
+	for _, seed := range params.DNSSeeds {
+		jp.DNSSeeds = append(jp.DNSSeeds, jsonDNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+	for name, setType := range keySetNames {
+		keySet, ok := params.AdminKeySets[setType]
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(keySet))
+		for i := range keySet {
+			keys = append(keys, hex.EncodeToString(
+				keySet[i].SerializeCompressed()))
+		}
+		jp.AdminKeySets[name] = keys
+	}
+	for name, setType := range keySetNames {
+		if threshold, ok := params.AdminThresholds[setType]; ok {
+			jp.AdminThresholds[name] = threshold
+		}
+	}
+	for keyID, pubKey := range params.ASPKeyIdMap {
+		jp.ASPKeyIDs[strconv.FormatUint(uint64(keyID), 10)] =
+			hex.EncodeToString(pubKey.SerializeCompressed())
+	}
+	for _, checkpoint := range params.Checkpoints {
+		jp.Checkpoints = append(jp.Checkpoints, jsonCheckpoint{
+			Height: checkpoint.Height,
+			Hash:   checkpoint.Hash.String(),
+		})
+	}
+	return jp, nil
+}
+
+// decodeHexID decodes the hex encoded 4 byte identifier of the passed field.
+func decodeHexID(field, s string) ([4]byte, error) {
+	var id [4]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("%s must be 4 hex encoded bytes", field)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// params converts the representation of parameters in a parameters file to
+// the parameters.  The subsidy mode is one of none, fixed and decaying, and the
+// difficulty algorithm one of window, fixed and ema.  An omitted difficulty
+// algorithm is window, so files written before it could be chosen still decode
+// to the same network.
+func (jp *jsonParams) params() (*Params, error) {
+	params := &Params{
+		Name:                         jp.Name,
+		Net:                          wire.BitcoinNet(jp.Net),
+		DefaultPort:                  jp.DefaultPort,
+		AdminKeySets:                 make(map[btcec.KeySetType]btcec.PublicKeySet),
+		ASPKeyIdMap:                  make(btcec.KeyIdMap),
+		AdminThresholds:              make(map[btcec.KeySetType]int),
+		PowLimitBits:                 jp.PowLimitBits,
+		CoinbaseMaturity:             jp.CoinbaseMaturity,
+		BaseSubsidy:                  jp.BaseSubsidy,
+		SubsidyReductionInterval:     jp.SubsidyReductionInterval,
+		MaxSubsidySupply:             jp.MaxSubsidySupply,
+		GenerateSupported:            jp.GenerateSupported,
+		BlockEnforceNumRequired:      jp.BlockEnforceNumRequired,
+		BlockRejectNumRequired:       jp.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:       jp.BlockUpgradeNumToCheck,
+		CLTVActivationHeight:         jp.CLTVActivationHeight,
+		SchnorrActivationHeight:      jp.SchnorrActivationHeight,
+		FreezeActivationHeight:       jp.FreezeActivationHeight,
+		CoinbaseActivationHeight:     jp.CoinbaseActivationHeight,
+		GeneralProvaActivationHeight: jp.GeneralProvaActivationHeight,
+		RelayNonStdTxs:               jp.RelayNonStdTxs,
+		RelayGeneralProvaTxs:         jp.RelayGeneralProvaTxs,
+		ProvaAddrID:                  jp.ProvaAddrID,
+		PrivateKeyID:                 jp.PrivateKeyID,
+		Bech32HRPProva:               jp.Bech32HRPProva,
+		HDCoinType:                   jp.HDCoinType,
+		PowAveragingWindow:           jp.PowAveragingWindow,
+		PowMaxAdjustDown:             jp.PowMaxAdjustDown,
+		PowMaxAdjustUp:               jp.PowMaxAdjustUp,
+		ChainTrailingSigKeyLimit:     jp.ChainTrailingSigKeyLimit,
+		ChainWindowShareLimit:        jp.ChainWindowShareLimit,
+		MaximumFeeAmount:             jp.MaximumFeeAmount,
+		AmountDecimals:               jp.AmountDecimals,
+	}
+	for _, seed := range jp.DNSSeeds {
+		params.DNSSeeds = append(params.DNSSeeds, DNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+
+	// Decode the genesis block.  Its hash is always calculated rather than
+	// taken from the file so the two can't disagree.
+	genesisBytes, err := hex.DecodeString(jp.GenesisBlock)
+	if err != nil {
+		return nil, fmt.Errorf("genesisblock is not hex encoded: %v",
+			err)
+	}
+	var genesis wire.MsgBlock
+	if err := genesis.Deserialize(bytes.NewReader(genesisBytes)); err != nil {
+		return nil, fmt.Errorf("genesisblock is not a serialized "+
+			"block: %v", err)
+	}
+	genesisHash := genesis.BlockHash()
+	params.GenesisBlock = &genesis
+	params.GenesisHash = &genesisHash
+
+	for name, keys := range jp.AdminKeySets {
+		setType, ok := keySetNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown admin key set %q", name)
+		}
+		keySet, err := btcec.ParsePubKeySet(btcec.S256(), keys...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key: %v", name, err)
+		}
+		params.AdminKeySets[setType] = keySet
+	}
+	for name, threshold := range jp.AdminThresholds {
+		setType, ok := keySetNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown admin key set %q", name)
+		}
+		params.AdminThresholds[setType] = threshold
+	}
+	for keyIDStr, pubKeyStr := range jp.ASPKeyIDs {
+		keyID, err := strconv.ParseUint(keyIDStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASP key id %q", keyIDStr)
+		}
+		pubKeyBytes, err := hex.DecodeString(pubKeyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of ASP key id %d: "+
+				"%v", keyID, err)
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of ASP key id %d: "+
+				"%v", keyID, err)
+		}
+		params.ASPKeyIdMap[btcec.KeyID(keyID)] = pubKey
+	}
+
+	modeFound := false
+	for mode, name := range subsidyModeStrings {
+		if name == jp.SubsidyMode {
+			params.SubsidyMode = mode
+			modeFound = true
+			break
+		}
+	}
+	if !modeFound {
+		return nil, fmt.Errorf("unknown subsidymode %q", jp.SubsidyMode)
+	}
+
+	algorithmFound := jp.DifficultyAlgorithm == ""
+	for algorithm, name := range difficultyAlgorithmStrings {
+		if name == jp.DifficultyAlgorithm {
+			params.DifficultyAlgorithm = algorithm
+			algorithmFound = true
+			break
+		}
+	}
+	if !algorithmFound {
+		return nil, fmt.Errorf("unknown difficultyalgorithm %q",
+			jp.DifficultyAlgorithm)
+	}
+
+	powLimit, ok := new(big.Int).SetString(jp.PowLimit, 16)
+	if !ok {
+		return nil, fmt.Errorf("powlimit %q is not a hex encoded number",
+			jp.PowLimit)
+	}
+	params.PowLimit = powLimit
+
+	params.TargetTimePerBlock, err = time.ParseDuration(jp.TargetTimePerBlock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targettimeperblock: %v", err)
+	}
+
+	for _, checkpoint := range jp.Checkpoints {
+		hash, err := chainhash.NewHashFromStr(checkpoint.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash of checkpoint %d: "+
+				"%v", checkpoint.Height, err)
+		}
+		params.Checkpoints = append(params.Checkpoints, Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   hash,
+		})
+	}
+
+	params.HDPrivateKeyID, err = decodeHexID("hdprivatekeyid",
+		jp.HDPrivateKeyID)
+	if err != nil {
+		return nil, err
+	}
+	params.HDPublicKeyID, err = decodeHexID("hdpublickeyid",
+		jp.HDPublicKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// validateParams returns an error when the passed parameters do not define a
+// usable network.
+func validateParams(params *Params) error {
+	switch {
+	case params.Name == "":
+		return fmt.Errorf("name is required")
+	case params.Net == 0:
+		return fmt.Errorf("net is required")
+	case params.DefaultPort == "":
+		return fmt.Errorf("defaultport is required")
+	case params.GenesisBlock.Header.Height != 0 ||
+		len(params.GenesisBlock.Transactions) == 0:
+		return fmt.Errorf("genesisblock must be a block at height 0 " +
+			"with a coinbase transaction")
+	case params.PowLimit.Sign() <= 0:
+		return fmt.Errorf("powlimit must be positive")
+	case params.TargetTimePerBlock <= 0:
+		return fmt.Errorf("targettimeperblock must be positive")
+	case params.DifficultyAlgorithm == EMADifficulty &&
+		params.TargetTimePerBlock < time.Second:
+		// Block timestamps have a resolution of a second, so shorter
+		// solve times can't be measured.
+		return fmt.Errorf("targettimeperblock must be at least 1s " +
+			"for the ema difficulty algorithm")
+	case params.PowAveragingWindow <= 0:
+		return fmt.Errorf("powaveragingwindow must be positive")
+	case params.PowMaxAdjustDown < 0 || params.PowMaxAdjustDown >= 100 ||
+		params.PowMaxAdjustUp < 0 || params.PowMaxAdjustUp >= 100:
+		return fmt.Errorf("powmaxadjustdown and powmaxadjustup must " +
+			"be percentages below 100")
+	case params.AmountDecimals > maxAmountDecimals:
+		return fmt.Errorf("amountdecimals must not exceed %d",
+			maxAmountDecimals)
+	case params.BaseSubsidy < 0 || params.MaxSubsidySupply < 0:
+		return fmt.Errorf("basesubsidy and maxsubsidysupply must not " +
+			"be negative")
+	case params.SubsidyMode == DecayingSubsidy &&
+		params.SubsidyReductionInterval == 0:
+		return fmt.Errorf("subsidyreductioninterval must be positive " +
+			"for a decaying subsidy")
+	case params.BlockUpgradeNumToCheck == 0 ||
+		params.BlockEnforceNumRequired > params.BlockUpgradeNumToCheck ||
+		params.BlockRejectNumRequired > params.BlockUpgradeNumToCheck:
+		return fmt.Errorf("blockenforcenumrequired and " +
+			"blockrejectnumrequired must not exceed a positive " +
+			"blockupgradenumtocheck")
+	}
+	for _, net := range defaultNets {
+		if strings.EqualFold(params.Name, net.Name) {
+			return fmt.Errorf("name %q is the name of a default "+
+				"network", params.Name)
+		}
+		if params.Net == net.Net {
+			return fmt.Errorf("net %d is the magic of the %s "+
+				"network", uint32(params.Net), net.Name)
+		}
+	}
+	for setType, threshold := range params.AdminThresholds {
+		switch setType {
+		case btcec.RootKeySet, btcec.ProvisionKeySet, btcec.IssueKeySet:
+		default:
+			return fmt.Errorf("the %v key set has no admin thread "+
+				"threshold", setType)
+		}
+		if threshold < 1 || threshold > maxAdminThreshold {
+			return fmt.Errorf("the %v threshold must be between 1 "+
+				"and %d", setType, maxAdminThreshold)
+		}
+		// Key sets which are empty at genesis may reach their
+		// threshold as keys are added.
+		numKeys := len(params.AdminKeySets[setType])
+		if numKeys > 0 && threshold > numKeys {
+			return fmt.Errorf("the %v threshold of %d exceeds the %d "+
+				"genesis keys of the set", setType, threshold,
+				numKeys)
+		}
+	}
+	for i := 1; i < len(params.Checkpoints); i++ {
+		if params.Checkpoints[i].Height <= params.Checkpoints[i-1].Height {
+			return fmt.Errorf("checkpoints are not sorted by height")
+		}
+	}
+	return nil
+}
+
+// DecodeParams decodes the parameters of a custom network from the JSON
+// parameters file read from r, so private networks can be defined without
+// modifying this package.  The field names are the lowercase names of the
+// fields of Params.  Byte strings, such as the serialized genesis block and the
+// public keys, are hex encoded, and the admin key sets are keyed by the names
+// root, provision, issue, validate and asp.  See EncodeParams for the exact
+// format.
+//
+// A file may set the base field to the name of one of the default networks, in
+// which case the fields it omits are taken from that network.  The decoded
+// network still has to be registered with Register before it is used.
+func DecodeParams(r io.Reader) (*Params, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the base network when one is requested.  The admin key
+	// sets and the ASP key ids in the file replace those of the base
+	// network rather than being merged with them.
+	var base struct {
+		Base         string          `json:"base"`
+		AdminKeySets json.RawMessage `json:"adminkeysets"`
+		ASPKeyIDs    json.RawMessage `json:"aspkeyids"`
+	}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+	jp := &jsonParams{}
+	if base.Base != "" {
+		var baseParams *Params
+		for _, net := range defaultNets {
+			if net.Name == base.Base {
+				baseParams = net
+				break
+			}
+		}
+		if baseParams == nil {
+			return nil, fmt.Errorf("unknown base network %q",
+				base.Base)
+		}
+		jp, err = newJSONParams(baseParams)
+		if err != nil {
+			return nil, err
+		}
+		if base.AdminKeySets != nil {
+			jp.AdminKeySets = nil
+		}
+		if base.ASPKeyIDs != nil {
+			jp.ASPKeyIDs = nil
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(jp); err != nil {
+		return nil, err
+	}
+	params, err := jp.params()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// EncodeParams writes the passed parameters to w in the format read by
+// DecodeParams.
+func EncodeParams(w io.Writer, params *Params) error {
+	jp, err := newJSONParams(params)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(jp, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	. "github.com/bitgo/prova/chaincfg"
+)
+
+// TestDecodeParams ensures a custom network based on a default network takes
+// the omitted fields from it and round trips through EncodeParams.
+func TestDecodeParams(t *testing.T) {
+	const file = `{
+		"base": "regtest",
+		"name": "privnet",
+		"net": 305419896,
+		"defaultport": "19979",
+		"targettimeperblock": "30s",
+		"subsidymode": "fixed",
+		"basesubsidy": 5000,
+		"difficultyalgorithm": "ema",
+		"adminthresholds": {"provision": 3},
+		"aspkeyids": {
+			"7": "02bb4f88d0fa509aae16679dea651a5abda750515dc334c4b4f5cc271885535db9"
+		}
+	}`
+	params, err := DecodeParams(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error: %v", err)
+	}
+
+	base := &RegressionNetParams
+	if params.Name != "privnet" || params.Net != 305419896 ||
+		params.DefaultPort != "19979" ||
+		params.TargetTimePerBlock.Seconds() != 30 ||
+		params.SubsidyMode != FixedSubsidy || params.BaseSubsidy != 5000 ||
+		params.DifficultyAlgorithm != EMADifficulty {
+
+		t.Errorf("DecodeParams: fields from the file not set: %+v",
+			params)
+	}
+	if *params.GenesisHash != *base.GenesisHash {
+		t.Errorf("DecodeParams: got genesis %v, want %v",
+			params.GenesisHash, base.GenesisHash)
+	}
+	if !reflect.DeepEqual(params.AdminKeySets, base.AdminKeySets) {
+		t.Errorf("DecodeParams: admin key sets not taken from the base")
+	}
+	if params.AdminThreshold(btcec.ProvisionKeySet) != 3 ||
+		params.AdminThreshold(btcec.RootKeySet) != DefaultAdminThreshold {
+
+		t.Errorf("DecodeParams: got admin thresholds %v, want 3 "+
+			"provision signatures", params.AdminThresholds)
+	}
+	if len(params.ASPKeyIdMap) != 1 || params.ASPKeyIdMap[7] == nil {
+		t.Errorf("DecodeParams: got ASP key ids %v, want only key id 7",
+			params.ASPKeyIdMap)
+	}
+	if params.PowLimit.Cmp(base.PowLimit) != 0 ||
+		params.PowLimitBits != base.PowLimitBits ||
+		params.PowAveragingWindow != base.PowAveragingWindow ||
+		params.HDPrivateKeyID != base.HDPrivateKeyID ||
+		params.Bech32HRPProva != base.Bech32HRPProva {
+
+		t.Errorf("DecodeParams: fields not taken from the base: %+v",
+			params)
+	}
+
+	var encoded bytes.Buffer
+	if err := EncodeParams(&encoded, params); err != nil {
+		t.Fatalf("EncodeParams: unexpected error: %v", err)
+	}
+	decoded, err := DecodeParams(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeParams: unexpected error decoding encoded "+
+			"params: %v", err)
+	}
+	var reencoded bytes.Buffer
+	if err := EncodeParams(&reencoded, decoded); err != nil {
+		t.Fatalf("EncodeParams: unexpected error: %v", err)
+	}
+	if !bytes.Equal(encoded.Bytes(), reencoded.Bytes()) {
+		t.Errorf("EncodeParams: params changed after round trip:\n%s\n"+
+			"want:\n%s", reencoded.Bytes(), encoded.Bytes())
+	}
+}
+
+// TestDecodeParamsErrors ensures invalid parameters files are rejected.
+func TestDecodeParamsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{
+			name: "unknown field",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"bogus": 1}`,
+		},
+		{
+			name: "unknown base",
+			file: `{"base": "bogus", "name": "privnet", "net": 1}`,
+		},
+		{
+			name: "default network name",
+			file: `{"base": "simnet", "net": 1}`,
+		},
+		{
+			name: "default network magic",
+			file: `{"base": "simnet", "name": "privnet"}`,
+		},
+		{
+			name: "no genesis block",
+			file: `{"name": "privnet", "net": 1, "defaultport": "1"}`,
+		},
+		{
+			name: "invalid admin key",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"adminkeysets": {"root": ["0011"]}}`,
+		},
+		{
+			name: "unknown admin key set",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"adminkeysets": {"bogus": []}}`,
+		},
+		{
+			name: "threshold beyond the genesis keys",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"adminthresholds": {"root": 3}}`,
+		},
+		{
+			name: "zero threshold",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"adminthresholds": {"issue": 0}}`,
+		},
+		{
+			name: "threshold of a key set without thread",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"adminthresholds": {"validate": 1}}`,
+		},
+		{
+			name: "no averaging window",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"powaveragingwindow": 0}`,
+		},
+		{
+			name: "unknown subsidy mode",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"subsidymode": "bogus"}`,
+		},
+		{
+			name: "unknown difficulty algorithm",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"difficultyalgorithm": "bogus"}`,
+		},
+		{
+			name: "ema difficulty below a second per block",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"difficultyalgorithm": "ema",
+				"targettimeperblock": "500ms"}`,
+		},
+		{
+			name: "decaying subsidy without interval",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"subsidymode": "decaying", "basesubsidy": 100,
+				"subsidyreductioninterval": 0}`,
+		},
+		{
+			name: "negative subsidy cap",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"subsidymode": "fixed", "maxsubsidysupply": -1}`,
+		},
+		{
+			name: "amounts beyond an atom",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"amountdecimals": 7}`,
+		},
+		{
+			name: "unsorted checkpoints",
+			file: `{"base": "regtest", "name": "privnet", "net": 1,
+				"checkpoints": [
+				{"height": 2, "hash": "00"},
+				{"height": 1, "hash": "00"}]}`,
+		},
+	}
+	for _, test := range tests {
+		_, err := DecodeParams(strings.NewReader(test.file))
+		if err == nil {
+			t.Errorf("%s: DecodeParams did not fail", test.name)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// clusterRolePrimary and clusterRoleStandby are the roles of the
+	// members of a validator cluster.
+	clusterRolePrimary = "primary"
+	clusterRoleStandby = "standby"
+
+	// clusterCheckInterval is the interval at which a cluster member checks
+	// whether its lockout has expired.
+	clusterCheckInterval = time.Second
+)
+
+// clusterState describes whether a cluster member may sign blocks.
+type clusterState int
+
+const (
+	// clusterStandby is the state of a member which saw another member
+	// sign a heartbeat with a shared validate key within the lockout.
+	clusterStandby clusterState = iota
+
+	// clusterLockout is the state of a member which saw no other member
+	// sign within the lockout so far, and waits for it to expire before
+	// signing.
+	clusterLockout
+
+	// clusterActive is the state of the member signing blocks.
+	clusterActive
+)
+
+// String returns the name of the cluster state as reported by the
+// getclusterinfo RPC.
+func (s clusterState) String() string {
+	switch s {
+	case clusterStandby:
+		return "standby"
+	case clusterLockout:
+		return "lockout"
+	case clusterActive:
+		return "active"
+	}
+	return "unknown"
+}
+
+// clusterConfig houses the dependencies of a cluster manager.
+type clusterConfig struct {
+	// Role is the configured role of this node, either clusterRolePrimary
+	// or clusterRoleStandby.
+	Role string
+
+	// Lockout is the duration without heartbeats of the shared validate
+	// keys signed by another member after which a standby takes over.  The
+	// primary takes over after half of it.
+	Lockout time.Duration
+
+	// LocalKeys returns the validate keys held by this node, which are
+	// shared with the other members of the cluster.
+	LocalKeys func() []btcec.Signer
+
+	// BestHeight returns the height of the best block of the main chain.
+	BestHeight func() uint32
+
+	// Activated is called when this node takes over signing, to announce
+	// it to the other members and to fetch their memory pool.  It may be
+	// nil.
+	Activated func()
+}
+
+// clusterManager coordinates block production between the members of a
+// validator cluster, which hold the same validate keys.  Exactly one member
+// signs blocks while the others stand by, and the heartbeats of the shared keys
+// tell the members apart from the signing one: a member only takes over once
+// no heartbeat of a shared key signed by another member was seen for the whole
+// lockout, and the signing member steps down as soon as it sees one.
+//
+// The heartbeats have to reach the members for the lockout to prevent both of
+// them from signing, so the members should be connected to each other over
+// authenticated federation connections, which are also relayed transactions
+// first so the memory pools of the members stay alike.
+type clusterManager struct {
+	cfg     clusterConfig
+	started time.Time
+
+	mtx   sync.Mutex
+	state clusterState
+
+	// activeSince is the time this node took over signing.
+	activeSince time.Time
+
+	// lastPeer, peerHeight and peerKey describe the latest heartbeat of a
+	// shared validate key signed by another member.
+	lastPeer   time.Time
+	peerHeight uint32
+	peerKey    wire.BlockValidatingPubKey
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newClusterManager returns a new cluster manager using the passed config.  A
+// member starts out in the lockout, as another member may be signing.
+func newClusterManager(cfg *clusterConfig) *clusterManager {
+	return &clusterManager{
+		cfg:     *cfg,
+		started: time.Now(),
+		state:   clusterLockout,
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start begins checking for the expiry of the lockout.
+func (m *clusterManager) Start() {
+	m.wg.Add(1)
+	go m.clusterHandler()
+}
+
+// Stop stops the manager and waits for it to exit.
+func (m *clusterManager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// lockout returns the lockout of this node, which is shorter for the primary
+// so it takes over first when all members start together.
+func (m *clusterManager) lockout() time.Duration {
+	if m.cfg.Role == clusterRolePrimary {
+		return m.cfg.Lockout / 2
+	}
+	return m.cfg.Lockout
+}
+
+// isLocal returns whether the passed validating public key is held by this
+// node.
+func (m *clusterManager) isLocal(pubKey wire.BlockValidatingPubKey) bool {
+	for _, key := range m.cfg.LocalKeys() {
+		var localKey wire.BlockValidatingPubKey
+		copy(localKey[:], key.PubKey().SerializeCompressed())
+		if localKey == pubKey {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessHeartbeat records the passed heartbeat, which was received from a
+// peer and is newer than any heartbeat known for its validate key.  A
+// heartbeat of a shared validate key was signed by another member, which
+// either keeps this node standing by or makes it step down.
+//
+// This function is safe for concurrent access.
+func (m *clusterManager) ProcessHeartbeat(msg *wire.MsgHeartbeat) {
+	if !m.isLocal(msg.ValidatingPubKey) {
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.lastPeer = time.Now()
+	m.peerKey = msg.ValidatingPubKey
+	if msg.Height > m.peerHeight {
+		m.peerHeight = msg.Height
+	}
+	if m.state == clusterActive {
+		srvrLog.Warnf("Another cluster member signed a heartbeat of "+
+			"validate key %v at height %d -- no longer signing "+
+			"blocks", msg.ValidatingPubKey, msg.Height)
+		m.activeSince = time.Time{}
+	}
+	m.state = clusterStandby
+}
+
+// update takes over signing when the lockout expired at the passed time, and
+// returns whether it did.
+func (m *clusterManager) update(now time.Time) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.state == clusterActive {
+		return false
+	}
+	since := m.started
+	if m.lastPeer.After(since) {
+		since = m.lastPeer
+	}
+	if now.Sub(since) < m.lockout() {
+		m.state = clusterStandby
+		if m.lastPeer.IsZero() {
+			m.state = clusterLockout
+		}
+		return false
+	}
+
+	// Blocks are only signed on top of the best block the previous
+	// signer announced, so a lagging member does not sign a competing
+	// block at a height the previous signer may have signed already.
+	if m.cfg.BestHeight() < m.peerHeight {
+		m.state = clusterLockout
+		return false
+	}
+	m.state = clusterActive
+	m.activeSince = now
+	return true
+}
+
+// CanSign returns whether this node may sign a block at the passed height.
+//
+// This function is safe for concurrent access.
+func (m *clusterManager) CanSign(height uint32) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.state == clusterActive && height > m.peerHeight
+}
+
+// Active returns whether this node is the member signing blocks.
+//
+// This function is safe for concurrent access.
+func (m *clusterManager) Active() bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.state == clusterActive
+}
+
+// clusterStatus is a snapshot of the state of a cluster member.
+type clusterStatus struct {
+	State       clusterState
+	ActiveSince time.Time
+	LastPeer    time.Time
+	PeerHeight  uint32
+	PeerKey     wire.BlockValidatingPubKey
+}
+
+// Status returns a snapshot of the state of this node.
+//
+// This function is safe for concurrent access.
+func (m *clusterManager) Status() clusterStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return clusterStatus{
+		State:       m.state,
+		ActiveSince: m.activeSince,
+		LastPeer:    m.lastPeer,
+		PeerHeight:  m.peerHeight,
+		PeerKey:     m.peerKey,
+	}
+}
+
+// clusterHandler takes over signing once the lockout expires, until the
+// manager is stopped.  It must be run as a goroutine.
+func (m *clusterManager) clusterHandler() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(clusterCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if !m.update(now) {
+				continue
+			}
+			srvrLog.Infof("No other cluster member signed within the "+
+				"lockout of %v -- signing blocks as %s", m.lockout(),
+				m.cfg.Role)
+			if m.cfg.Activated != nil {
+				m.cfg.Activated()
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestClusterManager ensures a cluster member only takes over signing once no
+// other member signed a heartbeat of a shared validate key for the lockout,
+// only signs above the height announced by the previous signer, and steps
+// down when another member signs.
+func TestClusterManager(t *testing.T) {
+	sharedKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	heartbeat := func(key *btcec.PrivateKey, height uint32) *wire.MsgHeartbeat {
+		msg := wire.NewMsgHeartbeat(height, &chainhash.Hash{0x01})
+		if err := msg.Sign(key); err != nil {
+			t.Fatalf("Sign: unexpected error: %v", err)
+		}
+		return msg
+	}
+
+	const lockout = 3 * time.Minute
+	bestHeight := uint32(9)
+	newManager := func(role string) *clusterManager {
+		return newClusterManager(&clusterConfig{
+			Role:    role,
+			Lockout: lockout,
+			LocalKeys: func() []btcec.Signer {
+				return []btcec.Signer{sharedKey}
+			},
+			BestHeight: func() uint32 {
+				return bestHeight
+			},
+		})
+	}
+
+	m := newManager(clusterRoleStandby)
+	if m.update(m.started.Add(lockout/2)) ||
+		m.Status().State != clusterLockout {
+
+		t.Fatalf("update: standby took over within the lockout, state %v",
+			m.Status().State)
+	}
+
+	// Heartbeats of keys which are not shared do not concern the cluster.
+	m.ProcessHeartbeat(heartbeat(otherKey, 20))
+	if status := m.Status(); status.State != clusterLockout ||
+		!status.LastPeer.IsZero() {
+
+		t.Fatalf("ProcessHeartbeat: got state %v after a heartbeat of "+
+			"another key", status.State)
+	}
+
+	// A heartbeat of a shared key signed by another member keeps the
+	// standby standing by for the lockout after it.
+	m.ProcessHeartbeat(heartbeat(sharedKey, 10))
+	status := m.Status()
+	if status.State != clusterStandby || status.PeerHeight != 10 {
+		t.Fatalf("ProcessHeartbeat: got state %v at peer height %d",
+			status.State, status.PeerHeight)
+	}
+	if m.update(status.LastPeer.Add(lockout - time.Second)) {
+		t.Fatal("update: standby took over within the lockout after a " +
+			"heartbeat")
+	}
+
+	// The standby does not take over before it caught up with the best
+	// block announced by the previous signer.
+	expired := status.LastPeer.Add(lockout + time.Second)
+	if m.update(expired) || m.Active() {
+		t.Fatal("update: standby took over behind the previous signer")
+	}
+	bestHeight = 10
+	if !m.update(expired) || !m.Active() {
+		t.Fatal("update: standby did not take over after the lockout")
+	}
+	if m.CanSign(10) || !m.CanSign(11) {
+		t.Errorf("CanSign: got %v at the peer height and %v above it",
+			m.CanSign(10), m.CanSign(11))
+	}
+
+	// The active member steps down once another member signs.
+	m.ProcessHeartbeat(heartbeat(sharedKey, 11))
+	if m.Active() || m.CanSign(12) {
+		t.Fatal("ProcessHeartbeat: active member did not step down")
+	}
+
+	// The primary takes over after half of the lockout.
+	primary := newManager(clusterRolePrimary)
+	if primary.update(primary.started.Add(lockout/2 - time.Second)) {
+		t.Fatal("update: primary took over within its lockout")
+	}
+	if !primary.update(primary.started.Add(lockout/2)) || !primary.CanSign(11) {
+		t.Fatal("update: primary did not take over after its lockout")
+	}
+}
@@ -33,6 +33,7 @@ var (
 	defaultConfigFile     = filepath.Join(provactlHomeDir, "provactl.conf")
 	defaultRPCServer      = "localhost"
 	defaultRPCCertFile    = filepath.Join(provaHomeDir, "rpc.cert")
+	defaultDataDir        = filepath.Join(provaHomeDir, "data")
 	defaultWalletCertFile = filepath.Join(btcwalletHomeDir, "rpc.cert")
 )
 
@@ -98,8 +99,12 @@ type config struct {
 	ConfigFile    string `short:"C" long:"configfile" description:"Path to configuration file"`
 	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username"`
 	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
+	RPCCookieFile string `long:"rpccookiefile" description:"RPC authentication cookie written by the server, used when no rpcuser is specified (default: .cookie in the data directory of the network)"`
 	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
+	RPCUnixSocket string `long:"rpcunixsocket" description:"Unix domain socket of the RPC server to connect to instead of rpcserver"`
 	RPCCert       string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	RPCClientCert string `long:"rpcclientcert" description:"Client certificate to authenticate with instead of rpcuser and rpcpass when the server is configured with --rpcclientca"`
+	RPCClientKey  string `long:"rpcclientkey" description:"Key of the client certificate"`
 	NoTLS         bool   `long:"notls" description:"Disable TLS"`
 	Proxy         string `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser     string `long:"proxyuser" description:"Username for proxy server"`
@@ -268,8 +273,37 @@ func loadConfig() (*config, []string, error) {
 		cfg.RPCCert = defaultWalletCertFile
 	}
 
-	// Handle environment variable expansion in the RPC certificate path.
+	// Handle environment variable expansion in the RPC certificate and Unix
+	// domain socket paths.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
+	if cfg.RPCUnixSocket != "" {
+		cfg.RPCUnixSocket = cleanAndExpandPath(cfg.RPCUnixSocket)
+	}
+
+	// Both a client certificate and its key are needed to authenticate
+	// with a client certificate.
+	if (cfg.RPCClientCert == "") != (cfg.RPCClientKey == "") {
+		str := "%s: --rpcclientcert and --rpcclientkey must be " +
+			"specified together"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.RPCClientCert != "" {
+		cfg.RPCClientCert = cleanAndExpandPath(cfg.RPCClientCert)
+		cfg.RPCClientKey = cleanAndExpandPath(cfg.RPCClientKey)
+	}
+
+	// Authenticate with the cookie written by the server when no
+	// credentials were specified.
+	if cfg.RPCUser == "" && cfg.RPCPassword == "" &&
+		cfg.RPCClientCert == "" && !cfg.Wallet {
+
+		if err := loadRPCCookie(&cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
 
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
@@ -279,6 +313,38 @@ func loadConfig() (*config, []string, error) {
 	return &cfg, remainingArgs, nil
 }
 
+// loadRPCCookie sets the RPC credentials of the passed config to the ones in
+// the RPC authentication cookie of the server.  It is not an error for the
+// cookie file to not exist, since the server might not write one.
+func loadRPCCookie(cfg *config) error {
+	cookieFile := cfg.RPCCookieFile
+	if cookieFile == "" {
+		netName := "mainnet"
+		switch {
+		case cfg.TestNet:
+			netName = "testnet"
+		case cfg.SimNet:
+			netName = "simnet"
+		}
+		cookieFile = filepath.Join(defaultDataDir, netName, ".cookie")
+	}
+	cookie, err := ioutil.ReadFile(cleanAndExpandPath(cookieFile))
+	if err != nil {
+		if os.IsNotExist(err) && cfg.RPCCookieFile == "" {
+			return nil
+		}
+		return fmt.Errorf("loadRPCCookie: %v", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(string(cookie)), ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("loadRPCCookie: malformed cookie file %s",
+			cookieFile)
+	}
+	cfg.RPCUser, cfg.RPCPassword = parts[0], parts[1]
+	return nil
+}
+
 // createDefaultConfig creates a basic config file at the given destination path.
 // For this it tries to read the btcd config file at its default path, and extract
 // the RPC user and password from it.
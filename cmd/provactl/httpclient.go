@@ -34,9 +34,17 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 		}
 	}
 
+	// Connect to the Unix domain socket of the server instead of its
+	// address if needed.
+	if cfg.RPCUnixSocket != "" {
+		dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", cfg.RPCUnixSocket)
+		}
+	}
+
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
-	if !cfg.NoTLS && cfg.RPCCert != "" {
+	if !cfg.NoTLS && cfg.RPCUnixSocket == "" && cfg.RPCCert != "" {
 		pem, err := ioutil.ReadFile(cfg.RPCCert)
 		if err != nil {
 			return nil, err
@@ -50,6 +58,21 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 		}
 	}
 
+	// Present the client certificate to authenticate with if needed.
+	if !cfg.NoTLS && cfg.RPCUnixSocket == "" && cfg.RPCClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RPCClientCert,
+			cfg.RPCClientKey)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{
+				InsecureSkipVerify: cfg.TLSSkipVerify,
+			}
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
 	// Create and return the new HTTP client potentially configured with a
 	// proxy and TLS.
 	client := http.Client{
@@ -68,7 +91,7 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 func sendPostRequest(marshalledJSON []byte, cfg *config) ([]byte, error) {
 	// Generate a request to the configured RPC server.
 	protocol := "http"
-	if !cfg.NoTLS {
+	if !cfg.NoTLS && cfg.RPCUnixSocket == "" {
 		protocol = "https"
 	}
 	url := protocol + "://" + cfg.RPCServer
@@ -80,8 +103,11 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) ([]byte, error) {
 	httpRequest.Close = true
 	httpRequest.Header.Set("Content-Type", "application/json")
 
-	// Configure basic access authorization.
-	httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	// Configure basic access authorization unless the client certificate
+	// authenticates the request.
+	if cfg.RPCUser != "" || cfg.RPCPassword != "" {
+		httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	}
 
 	// Create the new HTTP client that is configured according to the user-
 	// specified options and submit the request.
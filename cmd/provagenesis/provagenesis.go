@@ -0,0 +1,181 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/wire"
+	flags "github.com/btcsuite/go-flags"
+)
+
+// config defines the configuration options for provagenesis.
+type config struct {
+	Base               string   `long:"base" description:"Default network {mainnet, testnet3, regtest, simnet} the remaining parameters are taken from"`
+	Name               string   `long:"name" description:"Name of the new network"`
+	Net                uint32   `long:"net" description:"Magic identifying the messages of the new network"`
+	Port               string   `long:"port" description:"Default peer port of the new network"`
+	RootKeys           []string `long:"rootkey" description:"Hex encoded root public key -- may be repeated"`
+	ProvisionKeys      []string `long:"provisionkey" description:"Hex encoded provision public key -- may be repeated"`
+	IssueKeys          []string `long:"issuekey" description:"Hex encoded issue public key -- may be repeated"`
+	ValidateKeys       []string `long:"validatekey" description:"Hex encoded validate public key -- may be repeated"`
+	ASPKeys            []string `long:"aspkey" description:"ASP key id and hex encoded public key in the form <keyid>:<pubkey> -- may be repeated"`
+	RootThreshold      int      `long:"rootthreshold" description:"Number of root key signatures required by the root thread (default: 2)"`
+	ProvisionThreshold int      `long:"provisionthreshold" description:"Number of provision key signatures required by the provision thread (default: 2)"`
+	IssueThreshold     int      `long:"issuethreshold" description:"Number of issue key signatures required by the issue thread (default: 2)"`
+	Timestamp          string   `long:"timestamp" description:"Time of the genesis block in RFC3339 format (default: now)"`
+	Message            string   `long:"message" description:"Message committed to in the genesis coinbase, such as a recent headline"`
+	OutFile            string   `short:"o" long:"out" description:"File to write the parameters to (default: stdout)"`
+	Force              bool     `short:"f" long:"force" description:"Force overwriting of an existing parameters file"`
+}
+
+// parseASPKeys parses the ASP key ids and public keys in the form
+// <keyid>:<pubkey>.
+func parseASPKeys(aspKeys []string) (btcec.KeyIdMap, error) {
+	keyIDMap := make(btcec.KeyIdMap)
+	for _, aspKey := range aspKeys {
+		parts := strings.SplitN(aspKey, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("ASP key %q is not in the form "+
+				"<keyid>:<pubkey>", aspKey)
+		}
+		keyID, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASP key id %q", parts[0])
+		}
+		pubKeyBytes, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of ASP key id %d: %v",
+				keyID, err)
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of ASP key id %d: %v",
+				keyID, err)
+		}
+		keyIDMap[btcec.KeyID(keyID)] = pubKey
+	}
+	return keyIDMap, nil
+}
+
+// networkConfig converts the options to the description of the new network.
+func networkConfig(cfg *config) (*chaincfg.NetworkConfig, error) {
+	netConfig := &chaincfg.NetworkConfig{
+		Name:            cfg.Name,
+		Net:             wire.BitcoinNet(cfg.Net),
+		DefaultPort:     cfg.Port,
+		AdminKeySets:    make(map[btcec.KeySetType]btcec.PublicKeySet),
+		AdminThresholds: make(map[btcec.KeySetType]int),
+		Timestamp:       time.Now(),
+		Message:         cfg.Message,
+	}
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams,
+		&chaincfg.TestNetParams, &chaincfg.RegressionNetParams,
+		&chaincfg.SimNetParams} {
+
+		if params.Name == cfg.Base {
+			netConfig.Base = params
+			break
+		}
+	}
+	if netConfig.Base == nil {
+		return nil, fmt.Errorf("unknown base network %q", cfg.Base)
+	}
+
+	keySets := []struct {
+		setType   btcec.KeySetType
+		keys      []string
+		threshold int
+	}{
+		{btcec.RootKeySet, cfg.RootKeys, cfg.RootThreshold},
+		{btcec.ProvisionKeySet, cfg.ProvisionKeys, cfg.ProvisionThreshold},
+		{btcec.IssueKeySet, cfg.IssueKeys, cfg.IssueThreshold},
+		{btcec.ValidateKeySet, cfg.ValidateKeys, 0},
+	}
+	for _, keySet := range keySets {
+		if keySet.threshold != 0 {
+			netConfig.AdminThresholds[keySet.setType] = keySet.threshold
+		}
+		if len(keySet.keys) == 0 {
+			continue
+		}
+		keys, err := btcec.ParsePubKeySet(btcec.S256(), keySet.keys...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v key: %v",
+				keySet.setType, err)
+		}
+		netConfig.AdminKeySets[keySet.setType] = keys
+	}
+
+	var err error
+	netConfig.ASPKeyIdMap, err = parseASPKeys(cfg.ASPKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Timestamp != "" {
+		netConfig.Timestamp, err = time.Parse(time.RFC3339, cfg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %v", err)
+		}
+	}
+	return netConfig, nil
+}
+
+func main() {
+	cfg := config{
+		Base: chaincfg.RegressionNetParams.Name,
+	}
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	if cfg.OutFile != "" && !cfg.Force {
+		if _, err := os.Stat(cfg.OutFile); err == nil {
+			fmt.Fprintf(os.Stderr, "%v: parameters file exists; use -f "+
+				"to force\n", cfg.OutFile)
+			os.Exit(1)
+		}
+	}
+
+	netConfig, err := networkConfig(&cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	params, err := chaincfg.NewNetwork(netConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create the network: %v\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	if err := chaincfg.EncodeParams(&buf, params); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot encode the parameters: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.OutFile == "" {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := ioutil.WriteFile(cfg.OutFile, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write the parameters: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Created network %s with genesis block %v\n",
+		params.Name, params.GenesisHash)
+}
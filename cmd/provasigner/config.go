@@ -0,0 +1,94 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitgo/prova/provautil"
+	flags "github.com/btcsuite/go-flags"
+)
+
+const (
+	// validateKeysEnvironmentKey is the name of the environment variable
+	// holding the hex encoded validate private keys, separated by commas.
+	validateKeysEnvironmentKey = "PROVA_VALIDATE_KEYS"
+
+	defaultListen = "127.0.0.1:7080"
+)
+
+var (
+	signerHomeDir       = provautil.AppDataDir("provasigner", false)
+	defaultCertFile     = filepath.Join(signerHomeDir, "signer.cert")
+	defaultKeyFile      = filepath.Join(signerHomeDir, "signer.key")
+	defaultClientCAFile = filepath.Join(signerHomeDir, "clients.cert")
+)
+
+// config defines the configuration options for provasigner.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	Listen       string   `short:"l" long:"listen" description:"Interface/port to listen for connections from nodes"`
+	CertFile     string   `long:"cert" description:"File containing the certificate of the signing service"`
+	KeyFile      string   `long:"key" description:"File containing the certificate key of the signing service"`
+	ClientCAFile string   `long:"clientca" description:"File containing the certificate authorities trusted to identify nodes"`
+	PKCS11Module string   `long:"pkcs11module" description:"Path to the PKCS#11 library of a hardware security module holding validate keys -- Requires a build with the pkcs11 build tag"`
+	PKCS11Slot   uint     `long:"pkcs11slot" description:"Slot of the hardware security module token holding the validate keys"`
+	PKCS11PIN    string   `long:"pkcs11pin" default-mask:"-" description:"User PIN of the hardware security module token"`
+	PKCS11Keys   []string `long:"pkcs11key" description:"Label of a validate key held by the hardware security module"`
+}
+
+// cleanAndExpandPath expands environment variables and leading ~ in the
+// passed path, cleans the result, and returns it.
+func cleanAndExpandPath(path string) string {
+	// Expand initial ~ to OS specific home directory.
+	if strings.HasPrefix(path, "~") {
+		homeDir := filepath.Dir(signerHomeDir)
+		path = strings.Replace(path, "~", homeDir, 1)
+	}
+
+	// NOTE: The os.ExpandEnv doesn't work with Windows-style %VARIABLE%,
+	// but they variables can still be expanded via POSIX-style $VARIABLE.
+	return filepath.Clean(os.ExpandEnv(path))
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, error) {
+	// Default config.
+	cfg := config{
+		Listen:       defaultListen,
+		CertFile:     defaultCertFile,
+		KeyFile:      defaultKeyFile,
+		ClientCAFile: defaultClientCAFile,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, err
+	}
+
+	if cfg.PKCS11Module != "" && len(cfg.PKCS11Keys) == 0 {
+		str := "loadConfig: the pkcs11module option is set, but there " +
+			"are no pkcs11key labels specified"
+		err := errors.New(str)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, err
+	}
+
+	cfg.CertFile = cleanAndExpandPath(cfg.CertFile)
+	cfg.KeyFile = cleanAndExpandPath(cfg.KeyFile)
+	cfg.ClientCAFile = cleanAndExpandPath(cfg.ClientCAFile)
+	return &cfg, nil
+}
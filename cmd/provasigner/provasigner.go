@@ -0,0 +1,96 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/signer"
+)
+
+// loadSigners returns the signers of the validate keys passed in the
+// environment and held by the configured hardware security module.
+func loadSigners(cfg *config) ([]btcec.Signer, *signer.PKCS11Module, error) {
+	var signers []btcec.Signer
+	if keys := os.Getenv(validateKeysEnvironmentKey); keys != "" {
+		for _, keyStr := range strings.Split(keys, ",") {
+			keyBytes, err := hex.DecodeString(keyStr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed parsing validate "+
+					"key: %v", err)
+			}
+			privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+			signers = append(signers, privKey)
+		}
+	}
+
+	if cfg.PKCS11Module == "" {
+		return signers, nil, nil
+	}
+	module, err := signer.OpenPKCS11(&signer.PKCS11Config{
+		Module: cfg.PKCS11Module,
+		Slot:   cfg.PKCS11Slot,
+		PIN:    cfg.PKCS11PIN,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, label := range cfg.PKCS11Keys {
+		s, err := module.Signer(label)
+		if err != nil {
+			module.Close()
+			return nil, nil, err
+		}
+		signers = append(signers, s)
+	}
+	return signers, module, nil
+}
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	signers, module, err := loadSigners(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load validate keys: %v\n", err)
+		os.Exit(1)
+	}
+	if module != nil {
+		defer module.Close()
+	}
+	if len(signers) == 0 {
+		fmt.Fprintf(os.Stderr, "No validate keys -- set %s or use the "+
+			"pkcs11 options\n", validateKeysEnvironmentKey)
+		os.Exit(1)
+	}
+	for _, s := range signers {
+		fmt.Printf("Signing with validate key %x\n",
+			s.PubKey().SerializeCompressed())
+	}
+
+	tlsConfig, err := signer.ServerTLSConfig(cfg.CertFile, cfg.KeyFile,
+		cfg.ClientCAFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load TLS config: %v\n", err)
+		os.Exit(1)
+	}
+	listener, err := tls.Listen("tcp", cfg.Listen, tlsConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to listen on %s: %v\n", cfg.Listen,
+			err)
+		os.Exit(1)
+	}
+	fmt.Printf("Signing service listening on %s\n", listener.Addr())
+	if err := signer.Serve(listener, signer.NewService(signers...)); err != nil {
+		fmt.Fprintf(os.Stderr, "Signing service failed: %v\n", err)
+	}
+}
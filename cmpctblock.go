@@ -0,0 +1,124 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// errShortIDCollision indicates that two transactions of a compact block have
+// the same short id, so the block can only be fetched in full.
+var errShortIDCollision = errors.New("short transaction id collision")
+
+// partialBlock is a block which is being reconstructed from a compact block
+// and the transactions known to the node.  Transactions which could not be
+// found are requested from the peer which sent the compact block.
+type partialBlock struct {
+	header  wire.BlockHeader
+	txns    []*wire.MsgTx
+	missing []uint32
+}
+
+// newPartialBlock reconstructs as much of the block of the passed compact
+// block as possible from the passed transactions, which are typically the
+// transactions in the memory pool.  Transactions whose short id matches more
+// than one of the passed transactions are treated as missing.
+func newPartialBlock(msg *wire.MsgCmpctBlock, txns []*provautil.Tx) (*partialBlock, error) {
+	txCount := msg.TxCount()
+	if txCount == 0 {
+		return nil, errors.New("compact block has no transactions")
+	}
+	blockTxns := make([]*wire.MsgTx, txCount)
+	for _, ptx := range msg.PrefilledTxs {
+		if int(ptx.Index) >= txCount || ptx.Tx == nil ||
+			blockTxns[ptx.Index] != nil {
+			return nil, fmt.Errorf("invalid prefilled transaction "+
+				"at index %d", ptx.Index)
+		}
+		blockTxns[ptx.Index] = ptx.Tx
+	}
+
+	// The short ids fill the slots which are not prefilled in order.
+	slots := make(map[uint64]int, len(msg.ShortIDs))
+	next := 0
+	for _, shortID := range msg.ShortIDs {
+		for blockTxns[next] != nil {
+			next++
+		}
+		if _, exists := slots[shortID]; exists {
+			return nil, errShortIDCollision
+		}
+		slots[shortID] = next
+		next++
+	}
+
+	k0, k1 := msg.ShortIDKeys()
+	collisions := make(map[int]struct{})
+	for _, tx := range txns {
+		shortID := wire.ShortTxID(k0, k1, tx.HashWithSig())
+		slot, ok := slots[shortID]
+		if !ok {
+			continue
+		}
+		if _, collided := collisions[slot]; collided {
+			continue
+		}
+		if blockTxns[slot] != nil {
+			collisions[slot] = struct{}{}
+			blockTxns[slot] = nil
+			continue
+		}
+		blockTxns[slot] = tx.MsgTx()
+	}
+
+	pb := &partialBlock{header: msg.Header, txns: blockTxns}
+	for i, tx := range blockTxns {
+		if tx == nil {
+			pb.missing = append(pb.missing, uint32(i))
+		}
+	}
+	return pb, nil
+}
+
+// fill adds the missing transactions of the block, which must be in the order
+// of the missing indexes.
+func (pb *partialBlock) fill(txns []*wire.MsgTx) error {
+	if len(txns) != len(pb.missing) {
+		return fmt.Errorf("got %d transactions, %d are missing",
+			len(txns), len(pb.missing))
+	}
+	for i, index := range pb.missing {
+		pb.txns[index] = txns[i]
+	}
+	pb.missing = nil
+	return nil
+}
+
+// block returns the reconstructed block once no transactions are missing.
+// An error is returned when the transactions do not match the merkle root of
+// the header, which means a short id matched the wrong transaction.
+func (pb *partialBlock) block() (*provautil.Block, error) {
+	if len(pb.missing) != 0 {
+		return nil, fmt.Errorf("%d transactions are missing",
+			len(pb.missing))
+	}
+	msgBlock := wire.NewMsgBlock(&pb.header)
+	for _, tx := range pb.txns {
+		msgBlock.AddTransaction(tx)
+	}
+	block := provautil.NewBlock(msgBlock)
+
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	if !pb.header.MerkleRoot.IsEqual(merkles[len(merkles)-1]) {
+		return nil, errors.New("reconstructed block does not match " +
+			"the merkle root")
+	}
+	return block, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// testCmpctBlock returns a block with the passed number of distinct
+// transactions and a valid merkle root.
+func testCmpctBlock(numTxns int) *wire.MsgBlock {
+	block := wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{},
+		&chainhash.Hash{}, 1, 0))
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			uint32(i)), []byte{byte(i)}))
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		block.AddTransaction(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(
+		provautil.NewBlock(block).Transactions())
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+// TestPartialBlock ensures blocks are reconstructed from compact blocks and
+// the known transactions, and missing transactions are filled in.
+func TestPartialBlock(t *testing.T) {
+	block := testCmpctBlock(5)
+	msg := wire.NewMsgCmpctBlock(block, 42)
+
+	// All transactions are known.
+	known := make([]*provautil.Tx, 0, len(block.Transactions))
+	for _, tx := range block.Transactions[1:] {
+		known = append(known, provautil.NewTx(tx))
+	}
+	pb, err := newPartialBlock(msg, known)
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if len(pb.missing) != 0 {
+		t.Fatalf("newPartialBlock: got missing %v, want none", pb.missing)
+	}
+	reconstructed, err := pb.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(reconstructed.MsgBlock(), block) {
+		t.Fatalf("block: reconstructed block does not match")
+	}
+
+	// Unknown transactions are missing and filled in.
+	pb, err = newPartialBlock(msg, known[1:3])
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	wantMissing := []uint32{1, 4}
+	if !reflect.DeepEqual(pb.missing, wantMissing) {
+		t.Fatalf("newPartialBlock: got missing %v, want %v", pb.missing,
+			wantMissing)
+	}
+	if _, err := pb.block(); err == nil {
+		t.Fatal("block: no error with missing transactions")
+	}
+	if err := pb.fill(block.Transactions[1:2]); err == nil {
+		t.Fatal("fill: no error with too few transactions")
+	}
+	err = pb.fill([]*wire.MsgTx{block.Transactions[1], block.Transactions[4]})
+	if err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	reconstructed, err = pb.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(reconstructed.MsgBlock(), block) {
+		t.Fatalf("block: reconstructed block does not match")
+	}
+
+	// Wrong transactions do not match the merkle root.
+	pb, err = newPartialBlock(msg, known[1:])
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	err = pb.fill([]*wire.MsgTx{block.Transactions[2]})
+	if err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	if _, err := pb.block(); err == nil {
+		t.Fatal("block: no error with wrong transactions")
+	}
+
+	// Duplicate short ids can not be reconstructed.
+	msg.ShortIDs[1] = msg.ShortIDs[0]
+	if _, err := newPartialBlock(msg, known); err != errShortIDCollision {
+		t.Fatalf("newPartialBlock: got error %v, want %v", err,
+			errShortIDCollision)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"bufio"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -21,13 +22,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
 	_ "github.com/bitgo/prova/database/ffldb"
 	"github.com/bitgo/prova/mempool"
+	"github.com/bitgo/prova/peer"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/hdkeychain"
 	"github.com/bitgo/prova/wire"
 	flags "github.com/btcsuite/go-flags"
 	"github.com/btcsuite/go-socks/socks"
@@ -42,6 +48,11 @@ const (
 	defaultMaxPeers              = 125
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
+	defaultMsgLimitBanScore      = 25
+	defaultHeartbeatInterval     = time.Minute
+	defaultClusterLockout        = 5 * time.Minute
+	defaultHealthMinPeers        = 1
+	defaultShutdownTimeout       = time.Minute
 	defaultConnectTimeout        = time.Second * 30
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
@@ -55,10 +66,30 @@ const (
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = mempool.MaxStandardTxSize
+	defaultMaxOrphanBlocks       = 1000
+	defaultOrphanBlockExpiry     = time.Hour
 	defaultSigCacheMaxSize       = 100000
+	defaultHashCacheMaxSize      = 50000
 	sampleConfigFilename         = "sample-prova.conf"
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
+	defaultCfIndex               = false
+	defaultSpentIndex            = false
+	defaultKeyIDBalIndex         = false
+	defaultSupplyIndex           = false
+	defaultTimestampIndex        = false
+	defaultStatsIndex            = false
+	defaultUtxoCommitIndex       = false
+	defaultEventLogSize          = 100000
+	defaultAlertReorgDepth       = 6
+	defaultAlertMempoolTxs       = 50000
+	defaultAlertIndexLag         = 100
+	defaultAlertDedup            = 30 * time.Minute
+	defaultAlertRateLimit        = 20
+	defaultScreenTimeout         = 5 * time.Second
+	defaultScreenCacheTTL        = 10 * time.Minute
+	defaultI2PKeyFilename        = "i2p_private_key"
+	defaultCheckBlocks           = 6
 )
 
 var (
@@ -97,23 +128,57 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	ASMap                string        `long:"asmap" description:"File mapping IP subnets to the autonomous systems announcing them, used to spread outbound peers across networks"`
+	MaxUploadRate        uint32        `long:"maxuploadrate" description:"Maximum rate in kB/s data is sent to all peers combined -- 0 for unlimited"`
+	MaxDownloadRate      uint32        `long:"maxdownloadrate" description:"Maximum rate in kB/s data is received from all peers combined -- 0 for unlimited"`
+	InboundUploadRate    uint32        `long:"inbounduploadrate" description:"Maximum rate in kB/s data is sent to each inbound peer -- 0 for unlimited"`
+	InboundDownloadRate  uint32        `long:"inbounddownloadrate" description:"Maximum rate in kB/s data is received from each inbound peer -- 0 for unlimited"`
+	OutboundUploadRate   uint32        `long:"outbounduploadrate" description:"Maximum rate in kB/s data is sent to each outbound peer -- 0 for unlimited"`
+	OutboundDownloadRate uint32        `long:"outbounddownloadrate" description:"Maximum rate in kB/s data is received from each outbound peer -- 0 for unlimited"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
+	BanWarnThreshold     uint32        `long:"banwarnthreshold" description:"Ban score above which the misbehavior of peers is logged as a warning (default: half of banthreshold)"`
+	MsgLimits            []string      `long:"msglimit" description:"Limit the rate of the entries of a message type accepted from each peer in the form command=rate/burst, such as addr=10/2000 -- The entries of addr, addrv2, inv and getdata messages are the addresses and inventory vectors they carry -- A rate of 0 removes the limit -- May be specified multiple times"`
+	MsgLimitBanScore     uint32        `long:"msglimitbanscore" description:"Decaying ban score added for each message a peer sends over the limit of its type"`
+	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
+	RPCCookieFile        string        `long:"rpccookiefile" description:"File to write the credentials of the RPC authentication cookie to (default: .cookie in the data directory)"`
+	NoRPCCookie          bool          `long:"norpccookie" description:"Disable authenticating local RPC clients with a cookie file"`
+	RPCAuth              []string      `long:"rpcauth" default-mask:"-" description:"Add an RPC user as user:password:permissions, where permissions is a comma separated list of {read, wallet, mining, admin}; may be repeated"`
+	RPCClientCA          string        `long:"rpcclientca" description:"File containing the PEM encoded certificate authorities which issue the client certificates RPC clients may authenticate with instead of a password"`
+	RPCCertAuth          []string      `long:"rpccertauth" description:"Add an RPC user authenticated by client certificates issued by --rpcclientca as commonname:permissions, where commonname is the common name of the certificates and permissions is a comma separated list of {read, wallet, mining, admin}; may be repeated"`
+	RPCRequireClientCert bool          `long:"rpcrequireclientcert" description:"Reject RPC connections over TLS which don't present a client certificate issued by --rpcclientca"`
 	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
+	RPCUnixListeners     []string      `long:"rpclistenunix" description:"Add a Unix domain socket path to listen for RPC connections, which don't use TLS and are restricted by the permissions of the socket file"`
+	RPCUnixSocketMode    string        `long:"rpcunixsocketmode" description:"File mode of the RPC Unix domain sockets in octal"`
+	GRPCListeners        []string      `long:"grpclisten" description:"Add an interface/port to listen for gRPC connections, which use the RPC credentials and certificate (default port: 8335, testnet: 18335) -- NOTE: Requires a build with the grpc tag"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCLimits            []string      `long:"rpclimit" description:"Limit the rate of the calls each RPC user may make to the methods of a class in the form class=rate/burst, such as read=50/100 -- Valid classes are the permissions {read, wallet, mining, admin} -- A rate of 0 removes the limit -- May be specified multiple times"`
+	RPCSlowQuery         time.Duration `long:"rpcslowquery" description:"Log RPC calls taking at least this long along with their parameters.  Valid time units are {ms, s, m, h}.  0 disables logging slow calls"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
-	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	AdminKeys            []string      `long:"adminkey" default-mask:"-" description:"WIF-encoded private key of an admin key set used to sign the transactions created by the admin.* RPCs -- May be specified multiple times"`
+	WalletKey            string        `long:"walletkey" default-mask:"-" description:"Extended private key the holder keys of the addresses of the built-in wallet are derived from, which enables the wallet RPCs -- NOTE: Requires a build with the wallet tag"`
+	WalletKeyIDs         []uint32      `long:"walletkeyid" description:"KeyID of an ASP key of every address of the built-in wallet -- Must be specified exactly twice along with --walletkey"`
+	WalletASPKeys        []string      `long:"walletaspkey" default-mask:"-" description:"WIF-encoded private ASP key the built-in wallet co-signs its spends with -- May be specified multiple times"`
+	REST                 bool          `long:"rest" description:"Serve read-only chain data without authentication through the REST interface of the RPC server"`
+	Health               bool          `long:"health" description:"Serve the /healthz liveness and /readyz readiness endpoints without authentication on the RPC listeners for orchestrators and load balancers"`
+	HealthMinPeers       int           `long:"healthminpeers" description:"Minimum number of connected peers for the node to be reported as ready"`
+	HealthMaxBlockAge    time.Duration `long:"healthmaxblockage" description:"Maximum age of the best block for the node to be reported as ready.  Valid time units are {s, m, h}.  0 uses 10 times the expected block interval of the network"`
+	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass, rpclimituser/rpclimitpass rpcauth or rpccertauth is specified and the RPC cookie is disabled"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	DisableDNSSeed       bool          `long:"nodnsseed" description:"Disable DNS seeding for peers"`
+	DNSSeeds             []string      `long:"dnsseed" description:"Add a DNS seed to query for peers in addition to the DNS seeds of the network -- NOTE: The seed must support filtering by services when --dnsseedservice is used"`
+	DNSSeedServices      []string      `long:"dnsseedservice" description:"Only ask DNS seeds supporting filtering for peers advertising the service; may be repeated.  Valid services are {getutxo, bloom, cf}"`
+	DNSSeeder            string        `long:"dnsseeder" description:"Act as a DNS seed for the specified zone by answering DNS queries with the addresses of good peers learned from the network"`
+	DNSSeederListeners   []string      `long:"dnsseederlisten" description:"Add an interface/port to listen for DNS seed queries on (default all interfaces port: 53)"`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
@@ -123,19 +188,40 @@ type config struct {
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	I2PSAM               string        `long:"i2psam" description:"Connect to I2P peers through the SAM bridge of an I2P router (eg. 127.0.0.1:7656)"`
+	I2PListen            bool          `long:"i2plisten" description:"Accept connections from I2P peers through the SAM bridge -- Requires --i2psam"`
+	NetProxies           []string      `long:"netproxy" description:"Connect to the addresses of a network through a SOCKS5 proxy, such as the client of a pluggable transport, in the form network=host:port -- May be specified multiple times {ipv4, ipv6, onion, i2p}"`
 	TestNet              bool          `long:"testnet" description:"Use the test network"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	NetParams            string        `long:"netparams" description:"Use the custom network defined by the chain parameters in the passed JSON file, such as a private network"`
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	ReadOnly             bool          `long:"readonly" description:"Open the block database read-only, such as a replicated snapshot of the data directory of another node, and only serve RPC queries from it without connecting to peers"`
+	SPV                  bool          `long:"spv" description:"Run as a light client which validates the signed headers and the validate key set rules with the committed filters and blocks fetched from the full peers specified with --connect or --addpeer, without storing the utxo set, and serves the wallet oriented RPCs for the addresses specified with --spvwatchaddress"`
+	SPVWatchAddrs        []string      `long:"spvwatchaddress" description:"Add an address whose outputs and transactions are tracked in light client mode from the next block to verify on -- May be specified multiple times"`
+	CheckBlocks          uint32        `long:"checkblocks" description:"Number of blocks at the end of the main chain whose database entries are checked for damage, such as from an unclean shutdown, on start up -- 0 disables the checks"`
+	Repair               bool          `long:"repair" description:"Repair a damaged block database on start up by recovering its files and reconnecting the blocks after the last undamaged one"`
+	ReindexChainState    bool          `long:"reindexchainstate" description:"Rebuild the chain state, such as the utxo set and the admin key sets, and the enabled indexes on start up from the blocks already stored in the block database"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	ShutdownTimeout      time.Duration `long:"shutdowntimeout" description:"Maximum time to wait on shutdown for RPC calls to finish and for the miner, peers and other subsystems to stop before the state is saved anyway.  Valid time units are {s, m, h}.  0 waits indefinitely"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
+	LogFormat            string        `long:"logformat" description:"Format of the log messages {text, json} -- json writes each message as an object on its own line with the subsystem and, where known, the peer, block hash, txid and height it is about"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in RMG/kB to be considered a non-zero fee."`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	RelayPriority        bool          `long:"relaypriority" description:"Require free or low-fee transactions to have high priority for relaying"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxOrphanBlocks      int           `long:"maxorphanblocks" description:"Max number of orphan blocks to keep in memory while their parents are requested"`
+	OrphanBlockExpiry    time.Duration `long:"orphanblockexpiry" description:"Drop orphan blocks whose parents did not arrive within this time.  Valid time units are {s, m, h}"`
+	NoPersistMempool     bool          `long:"nopersistmempool" description:"Do not save the transaction memory pool on shutdown and restore it on start up"`
+	ScreenList           string        `long:"screenlist" description:"File listing the addresses and keyIDs to screen transactions against, one per line optionally followed by the decision {flag, exclude} and a reason -- Read again when the configuration is reloaded"`
+	ScreenURL            string        `long:"screenurl" description:"Post every transaction and the addresses and keyIDs it touches as a JSON object to the http or https URL of a screening service, which responds with the decision {allow, flag, exclude}"`
+	ScreenTimeout        time.Duration `long:"screentimeout" description:"Give up on the screening service after this long.  Valid time units are {s, m, h}"`
+	ScreenFailClosed     bool          `long:"screenfailclosed" description:"Refuse to relay and mine the transactions which could not be screened instead of allowing them"`
+	ScreenCacheTTL       time.Duration `long:"screencachettl" description:"Reuse the screening decision about a transaction for this long.  Valid time units are {s, m, h}.  0 screens transactions every time"`
+	KeyIDVelocityLimits  []string      `long:"keyidvelocitylimit" description:"Refuse to relay and mine transactions which would make a keyID spend more than the given amount in RMG within the given window, formatted as <window>:<amount> such as 24h:1000 -- May be repeated for several windows.  Valid time units are {s, m, h}"`
 	Generate             bool          `long:"generate" description:"Generate (mine) blocks using the CPU"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	BlockMinSize         uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
@@ -143,19 +229,87 @@ type config struct {
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	HashCacheMaxSize     uint          `long:"hashcachemaxsize" description:"The maximum number of transactions in the signature hash cache"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
-	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
+	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions and getaddress* RPCs available"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	CfIndex              bool          `long:"cfindex" description:"Maintain an index of committed filters for every block which are served to light clients (BIP0157) and made available via the getcfilter RPC"`
+	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the committed filter index from the database on start up and then exits."`
+	SpentIndex           bool          `long:"spentindex" description:"Maintain an index of the input spending every spent output which makes the getspentinfo RPC available"`
+	DropSpentIndex       bool          `long:"dropspentindex" description:"Deletes the spent index from the database on start up and then exits."`
+	KeyIDBalIndex        bool          `long:"keyidbalanceindex" description:"Maintain an index of the confirmed balance co-signed by every keyID at every height, along with its unspent outputs, which makes the getkeyidbalance and admin.rotatekeyid RPCs available"`
+	DropKeyIDBalIndex    bool          `long:"dropkeyidbalanceindex" description:"Deletes the keyID balance index from the database on start up and then exits."`
+	SupplyIndex          bool          `long:"supplyindex" description:"Maintain an index of every issuance and destruction of funds along with the resulting supply which makes the getsupplyhistory RPC available"`
+	DropSupplyIndex      bool          `long:"dropsupplyindex" description:"Deletes the supply index from the database on start up and then exits."`
+	TimestampIndex       bool          `long:"timestampindex" description:"Maintain an index of the timestamps of all blocks which makes the getblockhashbytime RPC available"`
+	DropTimestampIndex   bool          `long:"droptimestampindex" description:"Deletes the timestamp index from the database on start up and then exits."`
+	StatsIndex           bool          `long:"statsindex" description:"Maintain an index of the blocks, transactions, fees and active keyIDs of every day which makes the getexplorercharts RPC available"`
+	DropStatsIndex       bool          `long:"dropstatsindex" description:"Deletes the daily statistics index from the database on start up and then exits."`
+	UtxoCommitIndex      bool          `long:"utxocommitindex" description:"Maintain an incremental commitment to the utxo set as of every block which makes the getutxocommitment, getutxoproof and verifyutxoproof RPCs available"`
+	DropUtxoCommitIndex  bool          `long:"droputxocommitindex" description:"Deletes the utxo commitment index from the database on start up and then exits."`
+	EventLog             bool          `long:"eventlog" description:"Record connected and disconnected blocks, mempool transactions and admin key changes with sequence numbers in the database so clients can replay them from a cursor via the geteventlog RPC"`
+	EventLogSize         uint64        `long:"eventlogsize" description:"Maximum number of the most recent events kept in the event log"`
+	AuditLog             bool          `long:"auditlog" description:"Record admin RPC calls, blocks signed with the validate keys, configuration reloads and manual chain interventions in a hash-chained audit log in the data directory, which is exported and verified via the getauditlog and verifyauditlog RPCs"`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
+	RehearseUpgrade      []string      `long:"rehearseupgrade" description:"Replay the block chain in the database with the named consensus rule change forced active, report the first block which violates it, and exit -- May be specified multiple times {strictder, cltv}"`
+	ConsistencyInterval  time.Duration `long:"consistencycheckinterval" description:"Interval between background checks of the chain state against the block data to detect database corruption.  Valid time units are {s, m, h}.  0 disables the checks"`
+	ConsistencyHalt      bool          `long:"consistencycheckhalt" description:"Shut down when a background consistency check detects a mismatch"`
+	ScriptConsistency    bool          `long:"scriptconsistency" description:"Validate every script of connected blocks with a simplified reference interpreter in addition to the script engine, and shut down when they disagree"`
+	PKCS11Module         string        `long:"pkcs11module" description:"Path to the PKCS#11 library of a hardware security module holding validate keys -- Requires a build with the pkcs11 build tag"`
+	PKCS11Slot           uint          `long:"pkcs11slot" description:"Slot of the hardware security module token holding the validate keys"`
+	PKCS11PIN            string        `long:"pkcs11pin" default-mask:"-" description:"User PIN of the hardware security module token"`
+	PKCS11Keys           []string      `long:"pkcs11key" description:"Label of a validate key held by the hardware security module to sign generated blocks with"`
+	PKCS11AdminKeys      []string      `long:"pkcs11adminkey" description:"Label of an admin key held by the hardware security module to sign the transactions created by the admin.* RPCs with, like --adminkey -- May be specified multiple times"`
+	RemoteSigner         string        `long:"remotesigner" description:"Address of a remote signing service holding validate keys to sign generated blocks with"`
+	RemoteSignerCert     string        `long:"remotesignercert" description:"File containing the client certificate to authenticate with the remote signing service"`
+	RemoteSignerKey      string        `long:"remotesignerkey" description:"File containing the client certificate key to authenticate with the remote signing service"`
+	RemoteSignerCA       string        `long:"remotesignerca" description:"File containing the certificate authorities trusted to identify the remote signing service"`
+	RemoteAdminKeys      []string      `long:"remotesigneradminkey" description:"Hex-encoded public key of an admin key held by the remote signing service to sign the transactions created by the admin.* RPCs with, like --adminkey, instead of generated blocks -- May be specified multiple times"`
+	HeartbeatInterval    time.Duration `long:"heartbeatinterval" description:"Interval between the heartbeats announcing the active validate keys held by this node to the network.  Valid time units are {s, m, h}.  0 disables sending heartbeats"`
+	NoSignGuard          bool          `long:"nosignguard" description:"Disable refusing to sign a block at the height of a block already signed by the same validate key, which is tracked in the data directory"`
+	ClusterRole          string        `long:"clusterrole" description:"Run as a member of a validator cluster holding the same validate keys as the other members, with the given role {primary, standby} -- Only one member signs blocks, and a standby takes over when the signing member stops sending heartbeats"`
+	ClusterLockout       time.Duration `long:"clusterlockout" description:"Duration without heartbeats of the validate keys signed by another cluster member after which a standby takes over signing blocks, and half of which for the primary.  Valid time units are {s, m, h}.  Must be at least 3 times the heartbeat interval"`
+	AttestationKey       string        `long:"attestationkey" default-mask:"-" description:"Private key in WIF format to sign the attestations of the chain state made via the getattestation RPC with, instead of a validate key of the node"`
+	AlertWebhooks        []string      `long:"alertwebhook" description:"Post alerts about consensus and operational anomalies as JSON objects to the URL -- May be specified multiple times"`
+	AlertCommands        []string      `long:"alertcmd" description:"Run the command for every alert about consensus and operational anomalies, passing the alert as a JSON object on its standard input and in PROVA_ALERT_* environment variables -- May be specified multiple times"`
+	AlertNoBlock         time.Duration `long:"alertnoblock" description:"Alert when no block has been connected for this long.  Valid time units are {s, m, h}.  0 uses 30 times the expected block interval of the network"`
+	AlertReorgDepth      uint32        `long:"alertreorgdepth" description:"Alert when a reorganization disconnects at least this many blocks -- 0 disables the alert"`
+	AlertMempoolTxs      int           `long:"alertmempooltxs" description:"Alert when the memory pool holds more than this many transactions -- 0 disables the alert"`
+	AlertIndexLag        uint32        `long:"alertindexlag" description:"Alert when an optional index is more than this many blocks behind the main chain -- 0 disables the alert"`
+	AlertDedup           time.Duration `long:"alertdedup" description:"Only send one alert of a kind about the same subject within this interval.  Valid time units are {s, m, h}.  0 disables deduplication"`
+	AlertRateLimit       int           `long:"alertratelimit" description:"Maximum number of alerts sent per hour -- 0 disables the limit"`
+	FederationListeners  []string      `long:"federationlisten" description:"Add an interface/port to listen for authenticated connections from fellow federation members"`
+	FederationPeers      []string      `long:"federationpeer" description:"Add a fellow federation member to connect with over an authenticated connection at startup"`
+	FederationCert       string        `long:"federationcert" description:"File containing the certificate to authenticate with fellow federation members"`
+	FederationKey        string        `long:"federationkey" description:"File containing the certificate key to authenticate with fellow federation members"`
+	FederationMembers    string        `long:"federationmembers" description:"File containing the pinned certificates of the fellow federation members"`
 	lookup               func(string) ([]net.IP, error)
-	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
+	netDials             map[string]func(string, string, time.Duration) (net.Conn, error)
+	i2pSession           *connmgr.I2PSession
+	msgLimits            map[string]peer.MessageLimit
+	dnsSeeds             []chaincfg.DNSSeed
+	rpcUsers             []*rpcUser
+	rpcCertUsers         []*rpcUser
+	rpcUnixSocketMode    os.FileMode
+	rpcLimits            map[rpcPermission]peer.MessageLimit
+	dnsSeedServices      wire.ServiceFlag
 	addCheckpoints       []chaincfg.Checkpoint
+	whitelists           []*net.IPNet
+	rehearseDeployments  []blockchain.Deployment
 	miningAddrs          []provautil.Address
+	adminKeys            map[string]btcec.Signer
+	remoteAdminKeys      map[string]struct{}
+	walletKey            *hdkeychain.ExtendedKey
+	walletKeyIDs         []btcec.KeyID
+	walletASPKeys        []*btcec.PrivateKey
+	attestationKey       *btcec.PrivateKey
+	spvWatchAddrs        []provautil.Address
 	minRelayTxFee        provautil.Amount
+	velocityLimits       []mempool.VelocityLimit
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -215,28 +369,45 @@ func supportedSubsystems() []string {
 // the levels accordingly.  An appropriate error is returned if anything is
 // invalid.
 func parseAndSetDebugLevels(debugLevel string) error {
+	levels, err := parseDebugLevels(debugLevel)
+	if err != nil {
+		return err
+	}
+	for subsysID, logLevel := range levels {
+		setLogLevel(subsysID, logLevel)
+	}
+	return nil
+}
+
+// parseDebugLevels attempts to parse the specified debug level and returns the
+// level of each subsystem it sets.  An appropriate error is returned if
+// anything is invalid.
+func parseDebugLevels(debugLevel string) (map[string]string, error) {
 	// When the specified string doesn't have any delimters, treat it as
 	// the log level for all subsystems.
 	if !strings.Contains(debugLevel, ",") && !strings.Contains(debugLevel, "=") {
 		// Validate debug log level.
 		if !validLogLevel(debugLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, debugLevel)
+			return nil, fmt.Errorf(str, debugLevel)
 		}
 
 		// Change the logging level for all subsystems.
-		setLogLevels(debugLevel)
-
-		return nil
+		levels := make(map[string]string, len(subsystemLoggers))
+		for subsysID := range subsystemLoggers {
+			levels[subsysID] = debugLevel
+		}
+		return levels, nil
 	}
 
 	// Split the specified string into subsystem/level pairs while detecting
-	// issues and update the log levels accordingly.
+	// issues.
+	levels := make(map[string]string)
 	for _, logLevelPair := range strings.Split(debugLevel, ",") {
 		if !strings.Contains(logLevelPair, "=") {
 			str := "The specified debug level contains an invalid " +
 				"subsystem/level pair [%v]"
-			return fmt.Errorf(str, logLevelPair)
+			return nil, fmt.Errorf(str, logLevelPair)
 		}
 
 		// Extract the specified subsystem and log level.
@@ -247,19 +418,30 @@ func parseAndSetDebugLevels(debugLevel string) error {
 		if _, exists := subsystemLoggers[subsysID]; !exists {
 			str := "The specified subsystem [%v] is invalid -- " +
 				"supported subsytems %v"
-			return fmt.Errorf(str, subsysID, supportedSubsystems())
+			return nil, fmt.Errorf(str, subsysID, supportedSubsystems())
 		}
 
 		// Validate log level.
 		if !validLogLevel(logLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, logLevel)
+			return nil, fmt.Errorf(str, logLevel)
 		}
 
-		setLogLevel(subsysID, logLevel)
+		levels[subsysID] = logLevel
 	}
 
-	return nil
+	return levels, nil
+}
+
+// validLogFormat returns whether or not format is a supported log format.
+func validLogFormat(format string) bool {
+	for _, knownFormat := range knownLogFormats {
+		if format == knownFormat {
+			return true
+		}
+	}
+
+	return false
 }
 
 // validDbType returns whether or not dbType is a supported database type.
@@ -355,6 +537,78 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// parseMessageLimit parses message limits in the '<command>=<rate>/<burst>'
+// format.  A limit with a rate of 0, which may be given without a burst,
+// removes the limit of the message type.
+func parseMessageLimit(msgLimit string) (string, peer.MessageLimit, error) {
+	parts := strings.SplitN(msgLimit, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", peer.MessageLimit{}, errors.New("use the syntax " +
+			"<command>=<rate>/<burst>")
+	}
+	cmd := strings.ToLower(parts[0])
+	if parts[1] == "0" {
+		return cmd, peer.MessageLimit{}, nil
+	}
+
+	values := strings.Split(parts[1], "/")
+	if len(values) != 2 {
+		return "", peer.MessageLimit{}, errors.New("use the syntax " +
+			"<command>=<rate>/<burst>")
+	}
+	rate, err := strconv.ParseFloat(values[0], 64)
+	if err != nil || rate < 0 {
+		return "", peer.MessageLimit{}, errors.New("malformed rate")
+	}
+	burst, err := strconv.ParseFloat(values[1], 64)
+	if err != nil || burst < 1 {
+		return "", peer.MessageLimit{}, errors.New("malformed burst")
+	}
+	return cmd, peer.MessageLimit{Rate: rate, Burst: burst}, nil
+}
+
+// parseVelocityLimit parses keyID velocity limits in the '<window>:<amount>'
+// format, where the amount is in RMG.
+func parseVelocityLimit(limit string) (mempool.VelocityLimit, error) {
+	parts := strings.Split(limit, ":")
+	if len(parts) != 2 {
+		return mempool.VelocityLimit{}, errors.New("use the syntax " +
+			"<window>:<amount>")
+	}
+	window, err := time.ParseDuration(parts[0])
+	if err != nil || window <= 0 {
+		return mempool.VelocityLimit{}, errors.New("malformed window")
+	}
+	rmg, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || rmg < 0 {
+		return mempool.VelocityLimit{}, errors.New("malformed amount")
+	}
+	amount, err := provautil.NewAmount(rmg)
+	if err != nil {
+		return mempool.VelocityLimit{}, err
+	}
+	return mempool.VelocityLimit{Window: window, MaxAmount: amount}, nil
+}
+
+// serviceFlags maps the names of the services which can be requested from DNS
+// seeds to their service flags.
+var serviceFlags = map[string]wire.ServiceFlag{
+	"network": wire.SFNodeNetwork,
+	"getutxo": wire.SFNodeGetUTXO,
+	"bloom":   wire.SFNodeBloom,
+	"cf":      wire.SFNodeCF,
+}
+
+// parseServiceFlag returns the service flag of the service with the passed
+// name.
+func parseServiceFlag(name string) (wire.ServiceFlag, error) {
+	flag, ok := serviceFlags[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.New("unknown service")
+	}
+	return flag, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -378,25 +632,48 @@ func newConfigParser(cfg *config, so *serviceOptions, options flags.Options) *fl
 // line options.
 //
 // The configuration proceeds as follows:
-// 	1) Start with a default config with sane settings
-// 	2) Pre-parse the command line to check for an alternative config file
-// 	3) Load configuration file overwriting defaults with any specified options
-// 	4) Parse CLI options and overwrite/add any specified options
+//  1. Start with a default config with sane settings
+//  2. Pre-parse the command line to check for an alternative config file
+//  3. Load configuration file overwriting defaults with any specified options
+//  4. Parse CLI options and overwrite/add any specified options
 //
 // The above results in btcd functioning properly without any config settings
 // while still allowing the user to override settings with config files and
 // command line options.  Command line options always take precedence.
 func loadConfig() (*config, []string, error) {
+	return parseConfig(false)
+}
+
+// parseConfig parses the config as described by loadConfig.  When reload is
+// set, the config is parsed again for a node which is already running, so the
+// logging is left as it is and the network parameters are not changed, and
+// the debug levels are validated without being set.
+func parseConfig(reload bool) (*config, []string, error) {
 	// Default config.
 	cfg := config{
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
+		LogFormat:            logFormatText,
 		MaxPeers:             defaultMaxPeers,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
+		MsgLimitBanScore:     defaultMsgLimitBanScore,
+		HeartbeatInterval:    defaultHeartbeatInterval,
+		ClusterLockout:       defaultClusterLockout,
+		AlertReorgDepth:      defaultAlertReorgDepth,
+		AlertMempoolTxs:      defaultAlertMempoolTxs,
+		AlertIndexLag:        defaultAlertIndexLag,
+		AlertDedup:           defaultAlertDedup,
+		AlertRateLimit:       defaultAlertRateLimit,
+		ScreenTimeout:        defaultScreenTimeout,
+		ScreenCacheTTL:       defaultScreenCacheTTL,
+		HealthMinPeers:       defaultHealthMinPeers,
+		ShutdownTimeout:      defaultShutdownTimeout,
 		RPCMaxClients:        defaultMaxRPCClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
+		RPCUnixSocketMode:    defaultRPCUnixSocketMode,
+		RPCSlowQuery:         defaultRPCSlowQuery,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
 		DbType:               defaultDbType,
@@ -408,10 +685,22 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxSize:         defaultBlockMaxSize,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxOrphanBlocks:      defaultMaxOrphanBlocks,
+		OrphanBlockExpiry:    defaultOrphanBlockExpiry,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		HashCacheMaxSize:     defaultHashCacheMaxSize,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
+		CfIndex:              defaultCfIndex,
+		SpentIndex:           defaultSpentIndex,
+		KeyIDBalIndex:        defaultKeyIDBalIndex,
+		SupplyIndex:          defaultSupplyIndex,
+		TimestampIndex:       defaultTimestampIndex,
+		StatsIndex:           defaultStatsIndex,
+		UtxoCommitIndex:      defaultUtxoCommitIndex,
+		EventLogSize:         defaultEventLogSize,
+		CheckBlocks:          defaultCheckBlocks,
 	}
 
 	// Service options which are only added on Windows.
@@ -457,7 +746,9 @@ func loadConfig() (*config, []string, error) {
 	if !(preCfg.RegressionTest || preCfg.SimNet) || preCfg.ConfigFile !=
 		defaultConfigFile {
 
-		if _, err := os.Stat(preCfg.ConfigFile); os.IsNotExist(err) {
+		// The default config file is only created on startup.
+		_, err := os.Stat(preCfg.ConfigFile)
+		if os.IsNotExist(err) && !reload {
 			err := createDefaultConfigFile(preCfg.ConfigFile)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating a "+
@@ -465,7 +756,7 @@ func loadConfig() (*config, []string, error) {
 			}
 		}
 
-		err := flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
+		err = flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
 		if err != nil {
 			if _, ok := err.(*os.PathError); !ok {
 				fmt.Fprintf(os.Stderr, "Error parsing config "+
@@ -513,30 +804,52 @@ func loadConfig() (*config, []string, error) {
 
 	// Multiple networks can't be selected simultaneously.
 	numNets := 0
-	// Count number of network flags passed; assign active network params
+	// Count number of network flags passed; select the network params
 	// while we're at it
+	netParams := activeNetParams
 	if cfg.TestNet {
 		numNets++
-		activeNetParams = &testNetParams
+		netParams = &testNetParams
 	}
 	if cfg.RegressionTest {
 		numNets++
-		activeNetParams = &regressionNetParams
+		netParams = &regressionNetParams
 	}
 	if cfg.SimNet {
 		numNets++
 		// Also disable dns seeding on the simulation test network.
-		activeNetParams = &simNetParams
+		netParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.NetParams != "" {
+		numNets++
+		// The network of a running node can't change, so the parameters
+		// file is not loaded again when the config is reloaded.
+		if !reload {
+			path := cleanAndExpandPath(cfg.NetParams)
+			fileParams, err := loadNetParams(path)
+			if err != nil {
+				str := "%s: Failed to load the network " +
+					"parameters from %s: %v"
+				err := fmt.Errorf(str, funcName, cfg.NetParams,
+					err)
+				fmt.Fprintln(os.Stderr, err)
+				return nil, nil, err
+			}
+			netParams = fileParams
+		}
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: The testnet, regtest, simnet, and netparams params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
+	if !reload {
+		activeNetParams = netParams
+	}
 
 	// Set the default policy for relaying non-standard transactions
 	// according to the default of the active network. The set
@@ -572,18 +885,46 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, activeNetParams.Name)
 
+	// The RPC cookie is written to the data directory by default, since it
+	// is specific to the network of the server.
+	if cfg.RPCCookieFile == "" {
+		cfg.RPCCookieFile = filepath.Join(cfg.DataDir, ".cookie")
+	} else {
+		cfg.RPCCookieFile = cleanAndExpandPath(cfg.RPCCookieFile)
+	}
+
 	// Special show command to list supported subsystems and exit.
-	if cfg.DebugLevel == "show" {
+	if cfg.DebugLevel == "show" && !reload {
 		fmt.Println("Supported subsystems", supportedSubsystems())
 		os.Exit(0)
 	}
 
-	// Initialize logging at the default logging level.
-	initSeelogLogger(filepath.Join(cfg.LogDir, defaultLogFilename))
-	setLogLevels(defaultLogLevel)
+	// Validate the log format.
+	if !validLogFormat(cfg.LogFormat) {
+		str := "%s: The specified log format [%v] is invalid -- " +
+			"supported formats %v"
+		err := fmt.Errorf(str, funcName, cfg.LogFormat, knownLogFormats)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Initialize logging at the default logging level.  The logging of a
+	// running node is left as it is on reload, since the debug levels are
+	// set by the caller once the whole config is valid.
+	if !reload {
+		initSeelogLogger(filepath.Join(cfg.LogDir, defaultLogFilename),
+			cfg.LogFormat)
+		setLogLevels(defaultLogLevel)
+	}
 
 	// Parse, validate, and set debug log level(s).
-	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
+	if reload {
+		_, err = parseDebugLevels(cfg.DebugLevel)
+	} else {
+		err = parseAndSetDebugLevels(cfg.DebugLevel)
+	}
+	if err != nil {
 		err := fmt.Errorf("%s: %v", funcName, err.Error())
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -602,10 +943,8 @@ func loadConfig() (*config, []string, error) {
 
 	// Validate profile port number
 	if cfg.Profile != "" {
-		profilePort, err := strconv.Atoi(cfg.Profile)
-		if err != nil || profilePort < 1024 || profilePort > 65535 {
-			str := "%s: The profile port must be between 1024 and 65535"
-			err := fmt.Errorf(str, funcName)
+		if err := validProfilePort(cfg.Profile); err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
 			fmt.Fprintln(os.Stderr, err)
 			fmt.Fprintln(os.Stderr, usageMessage)
 			return nil, nil, err
@@ -621,6 +960,223 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Warn about misbehaving peers from half of the ban threshold unless
+	// the warn threshold is set.
+	if cfg.BanWarnThreshold == 0 {
+		cfg.BanWarnThreshold = cfg.BanThreshold >> 1
+	}
+	if cfg.BanWarnThreshold > cfg.BanThreshold {
+		str := "%s: The banwarnthreshold option may not be greater " +
+			"than banthreshold -- parsed [%d > %d]"
+		err := fmt.Errorf(str, funcName, cfg.BanWarnThreshold,
+			cfg.BanThreshold)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Parse the message limits, which override the default limit of their
+	// message type.
+	cfg.msgLimits = peer.DefaultMessageLimits()
+	for _, msgLimit := range cfg.MsgLimits {
+		cmd, limit, err := parseMessageLimit(msgLimit)
+		if err != nil {
+			str := "%s: The msglimit value of '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, msgLimit, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if limit.Rate == 0 {
+			delete(cfg.msgLimits, cmd)
+			continue
+		}
+		cfg.msgLimits[cmd] = limit
+	}
+
+	// Parse the limits of the rate of RPC calls to each method class.
+	cfg.rpcLimits = make(map[rpcPermission]peer.MessageLimit)
+	for _, rpcLimit := range cfg.RPCLimits {
+		class, limit, err := parseRPCLimit(rpcLimit)
+		if err != nil {
+			str := "%s: The rpclimit value of '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, rpcLimit, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if limit.Rate == 0 {
+			delete(cfg.rpcLimits, class)
+			continue
+		}
+		cfg.rpcLimits[class] = limit
+	}
+	if cfg.RPCSlowQuery < 0 {
+		str := "%s: The rpcslowquery option may not be negative -- " +
+			"parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.RPCSlowQuery)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Peers are always required to serve the full block chain.  Seeds
+	// supporting filtering are only asked for peers which also advertise
+	// the requested services.
+	cfg.dnsSeedServices = defaultRequiredServices
+	for _, name := range cfg.DNSSeedServices {
+		flag, err := parseServiceFlag(name)
+		if err != nil {
+			str := "%s: The dnsseedservice value of '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, name, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.dnsSeedServices |= flag
+	}
+
+	// Seeds added with --dnsseed are queried along with the seeds of the
+	// network.  They are assumed to support filtering by services, as the
+	// built-in DNS seeder does.
+	cfg.dnsSeeds = append(cfg.dnsSeeds, activeNetParams.DNSSeeds...)
+	for _, host := range cfg.DNSSeeds {
+		if host == "" || strings.ContainsAny(host, ":/ ") {
+			str := "%s: The dnsseed value of '%s' is not a host name"
+			err := fmt.Errorf(str, funcName, host)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.dnsSeeds = append(cfg.dnsSeeds, chaincfg.DNSSeed{
+			Host:         host,
+			HasFiltering: true,
+		})
+	}
+
+	// --dnsseederlisten requires --dnsseeder.
+	if cfg.DNSSeeder == "" && len(cfg.DNSSeederListeners) > 0 {
+		str := "%s: the --dnsseederlisten option requires the " +
+			"--dnsseeder option"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.DNSSeeder != "" && len(cfg.DNSSeederListeners) == 0 {
+		cfg.DNSSeederListeners = []string{net.JoinHostPort("", "53")}
+	}
+
+	// Validate any given whitelisted IP addresses and networks.
+	for _, addr := range cfg.Whitelists {
+		ipnet, err := connmgr.ParseSubnet(addr)
+		if err != nil {
+			str := "%s: The whitelist value of '%s' is invalid"
+			err = fmt.Errorf(str, funcName, addr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.whitelists = append(cfg.whitelists, ipnet)
+	}
+
+	if cfg.ConsistencyInterval < 0 {
+		str := "%s: The consistencycheckinterval option may not be negative -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.ConsistencyInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.HeartbeatInterval < 0 {
+		str := "%s: The heartbeatinterval option may not be negative -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.HeartbeatInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validator cluster members tell the signing member by its heartbeats,
+	// so the lockout has to outlast a few of them.
+	switch cfg.ClusterRole {
+	case "", clusterRolePrimary, clusterRoleStandby:
+	default:
+		str := "%s: The clusterrole option must be one of %s or %s -- " +
+			"parsed [%v]"
+		err := fmt.Errorf(str, funcName, clusterRolePrimary,
+			clusterRoleStandby, cfg.ClusterRole)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.ClusterRole != "" && (cfg.HeartbeatInterval == 0 ||
+		cfg.ClusterLockout < 3*cfg.HeartbeatInterval) {
+
+		str := "%s: The clusterrole option requires heartbeats and a " +
+			"clusterlockout of at least 3 times the heartbeatinterval " +
+			"-- parsed [%v] and [%v]"
+		err := fmt.Errorf(str, funcName, cfg.ClusterLockout,
+			cfg.HeartbeatInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.AlertNoBlock < 0 || cfg.AlertDedup < 0 ||
+		cfg.AlertMempoolTxs < 0 || cfg.AlertRateLimit < 0 {
+
+		str := "%s: --alertnoblock, --alertdedup, --alertmempooltxs " +
+			"and --alertratelimit may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	for _, url := range cfg.AlertWebhooks {
+		if !strings.HasPrefix(url, "http://") &&
+			!strings.HasPrefix(url, "https://") {
+
+			str := "%s: The alertwebhook option must be an http " +
+				"or https URL -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, url)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	for _, command := range cfg.AlertCommands {
+		if strings.TrimSpace(command) == "" {
+			str := "%s: The alertcmd option may not be empty"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Validate the screening options.
+	if cfg.ScreenTimeout <= 0 || cfg.ScreenCacheTTL < 0 {
+		str := "%s: --screentimeout must be positive and " +
+			"--screencachettl may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.ScreenURL != "" && !strings.HasPrefix(cfg.ScreenURL, "http://") &&
+		!strings.HasPrefix(cfg.ScreenURL, "https://") {
+
+		str := "%s: The screenurl option must be an http or https " +
+			"URL -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.ScreenURL)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.ScreenList != "" {
+		cfg.ScreenList = cleanAndExpandPath(cfg.ScreenList)
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -671,14 +1227,95 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// The RPC server is disabled if no username or password is provided.
-	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
+	// Collect the RPC users.  The users configured with --rpcuser and
+	// --rpclimituser have the admin permission and all but the admin
+	// permission respectively.
+	if cfg.RPCUser != "" && cfg.RPCPass != "" {
+		cfg.rpcUsers = append(cfg.rpcUsers, newRPCUser(cfg.RPCUser,
+			cfg.RPCPass, rpcPermAll))
+	}
+	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
+		cfg.rpcUsers = append(cfg.rpcUsers, newRPCUser(cfg.RPCLimitUser,
+			cfg.RPCLimitPass, rpcPermLimited))
+	}
+	for _, auth := range cfg.RPCAuth {
+		user, err := parseRPCAuth(auth)
+		if err != nil {
+			str := "%s: invalid --rpcauth: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.rpcUsers = append(cfg.rpcUsers, user)
+	}
+	for _, auth := range cfg.RPCCertAuth {
+		user, err := parseRPCCertAuth(auth)
+		if err != nil {
+			str := "%s: invalid --rpccertauth: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.rpcCertUsers = append(cfg.rpcCertUsers, user)
+	}
+	if (len(cfg.RPCCertAuth) != 0 || cfg.RPCRequireClientCert) &&
+		cfg.RPCClientCA == "" {
+
+		str := "%s: --rpccertauth and --rpcrequireclientcert require " +
+			"--rpcclientca"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RPCClientCA != "" && cfg.DisableTLS {
+		str := "%s: --rpcclientca requires TLS and may not be used " +
+			"with --notls"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RPCClientCA != "" {
+		cfg.RPCClientCA = cleanAndExpandPath(cfg.RPCClientCA)
+	}
+
+	// Users authenticated by passwords and by client certificates share
+	// the namespace of names, so calls can be traced back to their user.
+	allRPCUsers := make([]*rpcUser, 0,
+		len(cfg.rpcUsers)+len(cfg.rpcCertUsers))
+	allRPCUsers = append(allRPCUsers, cfg.rpcUsers...)
+	allRPCUsers = append(allRPCUsers, cfg.rpcCertUsers...)
+	rpcUserNames := make(map[string]struct{}, len(allRPCUsers))
+	if !cfg.NoRPCCookie {
+		rpcUserNames[rpcCookieUser] = struct{}{}
+	}
+	for _, user := range allRPCUsers {
+		if _, ok := rpcUserNames[user.name]; ok {
+			str := "%s: the RPC username %q is specified more than " +
+				"once"
+			err := fmt.Errorf(str, funcName, user.name)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		rpcUserNames[user.name] = struct{}{}
+	}
+
+	// The RPC server is disabled if no username or password is provided
+	// and clients can't authenticate with the cookie or a client
+	// certificate either.
+	if len(cfg.rpcUsers) == 0 && len(cfg.rpcCertUsers) == 0 &&
+		cfg.NoRPCCookie {
 		cfg.DisableRPC = true
 	}
 
-	// Default RPC to listen on localhost only.
-	if !cfg.DisableRPC && len(cfg.RPCListeners) == 0 {
+	// Default RPC to listen on localhost only, unless it only listens on
+	// Unix domain sockets.
+	if !cfg.DisableRPC && len(cfg.RPCListeners) == 0 &&
+		len(cfg.RPCUnixListeners) == 0 {
 		addrs, err := net.LookupHost("localhost")
 		if err != nil {
 			return nil, nil, err
@@ -690,6 +1327,45 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// The REST interface is served by the RPC server.
+	if cfg.DisableRPC && cfg.REST {
+		str := "%s: --rest requires the RPC server, which is " +
+			"disabled by --norpc or missing RPC credentials"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The health endpoints are served by the RPC server.
+	if cfg.DisableRPC && cfg.Health {
+		str := "%s: --health requires the RPC server, which is " +
+			"disabled by --norpc or missing RPC credentials"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.HealthMinPeers < 0 || cfg.HealthMaxBlockAge < 0 {
+		str := "%s: --healthminpeers and --healthmaxblockage may not " +
+			"be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The gRPC server authenticates clients with the RPC credentials, so it
+	// can't run without the RPC server.
+	if cfg.DisableRPC && len(cfg.GRPCListeners) > 0 {
+		str := "%s: --grpclisten requires the RPC server, which is " +
+			"disabled by --norpc or missing RPC credentials"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate the the minrelaytxfee.
 	cfg.minRelayTxFee, err = provautil.NewAmount(cfg.MinRelayTxFee)
 	if err != nil {
@@ -700,6 +1376,19 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Parse the keyID velocity limits.
+	for _, limit := range cfg.KeyIDVelocityLimits {
+		velocityLimit, err := parseVelocityLimit(limit)
+		if err != nil {
+			str := "%s: invalid keyidvelocitylimit %q: %v"
+			err := fmt.Errorf(str, funcName, limit, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.velocityLimits = append(cfg.velocityLimits, velocityLimit)
+	}
+
 	// Limit the max block size to a sane value.
 	if cfg.BlockMaxSize < blockMaxSizeMin || cfg.BlockMaxSize >
 		blockMaxSizeMax {
@@ -713,6 +1402,15 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.ShutdownTimeout < 0 {
+		str := "%s: The shutdowntimeout option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.ShutdownTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max orphan count to a sane vlue.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -723,6 +1421,25 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The orphan block pool needs room for at least one block, which
+	// has to be kept long enough for its parents to be requested.
+	if cfg.MaxOrphanBlocks < 1 {
+		str := "%s: The maxorphanblocks option may not be less than 1 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxOrphanBlocks)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.OrphanBlockExpiry < time.Second {
+		str := "%s: The orphanblockexpiry option may not be less than " +
+			"1s -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.OrphanBlockExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -759,6 +1476,209 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --cfindex and --dropcfindex do not mix.
+	if cfg.CfIndex && cfg.DropCfIndex {
+		err := fmt.Errorf("%s: the --cfindex and --dropcfindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --spentindex and --dropspentindex do not mix.
+	if cfg.SpentIndex && cfg.DropSpentIndex {
+		err := fmt.Errorf("%s: the --spentindex and --dropspentindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --keyidbalanceindex and --dropkeyidbalanceindex do not mix.
+	if cfg.KeyIDBalIndex && cfg.DropKeyIDBalIndex {
+		err := fmt.Errorf("%s: the --keyidbalanceindex and "+
+			"--dropkeyidbalanceindex options may not be activated "+
+			"at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --supplyindex and --dropsupplyindex do not mix.
+	if cfg.SupplyIndex && cfg.DropSupplyIndex {
+		err := fmt.Errorf("%s: the --supplyindex and --dropsupplyindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --timestampindex and --droptimestampindex do not mix.
+	if cfg.TimestampIndex && cfg.DropTimestampIndex {
+		err := fmt.Errorf("%s: the --timestampindex and "+
+			"--droptimestampindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --statsindex and --dropstatsindex do not mix.
+	if cfg.StatsIndex && cfg.DropStatsIndex {
+		err := fmt.Errorf("%s: the --statsindex and --dropstatsindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --utxocommitindex and --droputxocommitindex do not mix.
+	if cfg.UtxoCommitIndex && cfg.DropUtxoCommitIndex {
+		err := fmt.Errorf("%s: the --utxocommitindex and "+
+			"--droputxocommitindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --readonly only serves the data in the block database, so it does not
+	// mix with the options which write to it or connect to peers.
+	if cfg.ReadOnly {
+		conflicting := []struct {
+			option string
+			set    bool
+		}{
+			{"--generate", cfg.Generate},
+			{"--clusterrole", cfg.ClusterRole != ""},
+			{"--connect", len(cfg.ConnectPeers) > 0},
+			{"--addpeer", len(cfg.AddPeers) > 0},
+			{"--federationcert", cfg.FederationCert != ""},
+			{"--i2plisten", cfg.I2PListen},
+			{"--dnsseeder", cfg.DNSSeeder != ""},
+			{"--eventlog", cfg.EventLog},
+			{"--walletkey", cfg.WalletKey != ""},
+			{"--regtest", cfg.RegressionTest},
+			{"--rehearseupgrade", len(cfg.RehearseUpgrade) > 0},
+			{"--repair", cfg.Repair},
+			{"--reindexchainstate", cfg.ReindexChainState},
+			{"--dropaddrindex", cfg.DropAddrIndex},
+			{"--droptxindex", cfg.DropTxIndex},
+			{"--dropcfindex", cfg.DropCfIndex},
+			{"--dropspentindex", cfg.DropSpentIndex},
+			{"--dropkeyidbalanceindex", cfg.DropKeyIDBalIndex},
+			{"--dropsupplyindex", cfg.DropSupplyIndex},
+			{"--droptimestampindex", cfg.DropTimestampIndex},
+			{"--dropstatsindex", cfg.DropStatsIndex},
+			{"--droputxocommitindex", cfg.DropUtxoCommitIndex},
+		}
+		for _, c := range conflicting {
+			if !c.set {
+				continue
+			}
+			err := fmt.Errorf("%s: the --readonly and %s options "+
+				"may not be activated at the same time",
+				funcName, c.option)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+
+		// A read-only node neither accepts nor looks for peers.
+		cfg.DisableListen = true
+		cfg.DisableDNSSeed = true
+	}
+
+	// --spv does not store the blocks and the utxo set, so it does not mix
+	// with the options which need them, and it needs full peers to fetch
+	// the headers and filters from.
+	if cfg.SPV {
+		conflicting := []struct {
+			option string
+			set    bool
+		}{
+			{"--readonly", cfg.ReadOnly},
+			{"--generate", cfg.Generate},
+			{"--clusterrole", cfg.ClusterRole != ""},
+			{"--federationcert", cfg.FederationCert != ""},
+			{"--i2plisten", cfg.I2PListen},
+			{"--dnsseeder", cfg.DNSSeeder != ""},
+			{"--grpclisten", len(cfg.GRPCListeners) > 0},
+			{"--walletkey", cfg.WalletKey != ""},
+			{"--txindex", cfg.TxIndex},
+			{"--addrindex", cfg.AddrIndex},
+			{"--cfindex", cfg.CfIndex},
+			{"--spentindex", cfg.SpentIndex},
+			{"--keyidbalanceindex", cfg.KeyIDBalIndex},
+			{"--supplyindex", cfg.SupplyIndex},
+			{"--timestampindex", cfg.TimestampIndex},
+			{"--statsindex", cfg.StatsIndex},
+			{"--utxocommitindex", cfg.UtxoCommitIndex},
+			{"--eventlog", cfg.EventLog},
+			{"--rest", cfg.REST},
+			{"--health", cfg.Health},
+			{"--rehearseupgrade", len(cfg.RehearseUpgrade) > 0},
+			{"--repair", cfg.Repair},
+			{"--reindexchainstate", cfg.ReindexChainState},
+		}
+		for _, c := range conflicting {
+			if !c.set {
+				continue
+			}
+			err := fmt.Errorf("%s: the --spv and %s options may "+
+				"not be activated at the same time", funcName,
+				c.option)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if len(cfg.ConnectPeers) == 0 && len(cfg.AddPeers) == 0 {
+			str := "%s: the --spv option requires the full peers " +
+				"to sync from to be specified with --connect or " +
+				"--addpeer"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+
+		// A light client neither accepts nor looks for peers.
+		cfg.DisableListen = true
+		cfg.DisableDNSSeed = true
+	}
+	if len(cfg.SPVWatchAddrs) > 0 && !cfg.SPV {
+		str := "%s: the --spvwatchaddress option requires --spv"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Check the watched addresses are valid and save parsed versions.
+	cfg.spvWatchAddrs = make([]provautil.Address, 0, len(cfg.SPVWatchAddrs))
+	for _, strAddr := range cfg.SPVWatchAddrs {
+		addr, err := provautil.DecodeAddress(strAddr, activeNetParams.Params)
+		if err != nil {
+			str := "%s: watched address '%s' failed to decode: %v"
+			err := fmt.Errorf(str, funcName, strAddr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if !addr.IsForNet(activeNetParams.Params) {
+			str := "%s: watched address '%s' is on the wrong network"
+			err := fmt.Errorf(str, funcName, strAddr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.spvWatchAddrs = append(cfg.spvWatchAddrs, addr)
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]provautil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -780,6 +1700,94 @@ func loadConfig() (*config, []string, error) {
 		cfg.miningAddrs = append(cfg.miningAddrs, addr)
 	}
 
+	// Check the admin keys are valid and index them by the hash of their
+	// public keys, which is what admin scripts reference them by.
+	cfg.adminKeys = make(map[string]btcec.Signer, len(cfg.AdminKeys))
+	for _, encoded := range cfg.AdminKeys {
+		wif, err := provautil.DecodeWIF(encoded)
+		if err != nil {
+			str := "%s: admin key failed to decode: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if !wif.IsForNet(activeNetParams.Params) {
+			str := "%s: admin key is for the wrong network"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		keyHash := provautil.Hash160(wif.SerializePubKey())
+		cfg.adminKeys[string(keyHash)] = wif.PrivKey
+	}
+
+	// Check the keys of the built-in wallet are valid.  Every address of
+	// the wallet uses the same two keyIDs.
+	if cfg.WalletKey != "" {
+		cfg.walletKey, err = hdkeychain.NewKeyFromString(cfg.WalletKey)
+		if err == nil && !cfg.walletKey.IsPrivate() {
+			err = errors.New("not an extended private key")
+		}
+		if err == nil && !cfg.walletKey.IsForNet(activeNetParams.Params) {
+			err = errors.New("the key is for the wrong network")
+		}
+		if err != nil {
+			str := "%s: invalid --walletkey: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if len(cfg.WalletKeyIDs) != 2 {
+			str := "%s: --walletkey requires exactly two --walletkeyid " +
+				"options"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		for _, keyID := range cfg.WalletKeyIDs {
+			cfg.walletKeyIDs = append(cfg.walletKeyIDs,
+				btcec.KeyID(keyID))
+		}
+	}
+	for _, encoded := range cfg.WalletASPKeys {
+		wif, err := provautil.DecodeWIF(encoded)
+		if err != nil {
+			str := "%s: wallet ASP key failed to decode: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if !wif.IsForNet(activeNetParams.Params) {
+			str := "%s: wallet ASP key is for the wrong network"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.walletASPKeys = append(cfg.walletASPKeys, wif.PrivKey)
+	}
+
+	// Check the attestation key is valid.
+	if cfg.AttestationKey != "" {
+		wif, err := provautil.DecodeWIF(cfg.AttestationKey)
+		if err == nil && !wif.IsForNet(activeNetParams.Params) {
+			err = errors.New("the key is for the wrong network")
+		}
+		if err != nil {
+			str := "%s: invalid --attestationkey: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.attestationKey = wif.PrivKey
+	}
+
 	// Ensure there is at least one mining address when the generate flag is
 	// set.
 	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
@@ -791,6 +1799,94 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the hardware security module and the remote signing service
+	// are fully specified when used.
+	if cfg.PKCS11Module != "" && len(cfg.PKCS11Keys) == 0 &&
+		len(cfg.PKCS11AdminKeys) == 0 {
+
+		str := "%s: the pkcs11module option is set, but there are no " +
+			"pkcs11key or pkcs11adminkey labels specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.PKCS11Module == "" && len(cfg.PKCS11AdminKeys) != 0 {
+		str := "%s: the pkcs11adminkey option requires the " +
+			"pkcs11module option"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RemoteSigner == "" && len(cfg.RemoteAdminKeys) != 0 {
+		str := "%s: the remotesigneradminkey option requires the " +
+			"remotesigner option"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RemoteSigner != "" && (cfg.RemoteSignerCert == "" ||
+		cfg.RemoteSignerKey == "" || cfg.RemoteSignerCA == "") {
+
+		str := "%s: the remotesigner option requires the " +
+			"remotesignercert, remotesignerkey and remotesignerca options"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RemoteSigner != "" {
+		cfg.RemoteSignerCert = cleanAndExpandPath(cfg.RemoteSignerCert)
+		cfg.RemoteSignerKey = cleanAndExpandPath(cfg.RemoteSignerKey)
+		cfg.RemoteSignerCA = cleanAndExpandPath(cfg.RemoteSignerCA)
+	}
+
+	// Check the public keys of the admin keys held by the remote signing
+	// service are valid.  They are indexed by their compressed form, in
+	// which the signing service reports its keys.
+	cfg.remoteAdminKeys = make(map[string]struct{}, len(cfg.RemoteAdminKeys))
+	for _, encoded := range cfg.RemoteAdminKeys {
+		serialized, err := hex.DecodeString(encoded)
+		var pubKey *btcec.PublicKey
+		if err == nil {
+			pubKey, err = btcec.ParsePubKey(serialized, btcec.S256())
+		}
+		if err != nil {
+			str := "%s: remote signer admin key %q is invalid: %v"
+			err := fmt.Errorf(str, funcName, encoded, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.remoteAdminKeys[string(pubKey.SerializeCompressed())] = struct{}{}
+	}
+
+	// Ensure authenticated connections between federation members are
+	// fully specified when used.
+	if (len(cfg.FederationListeners) != 0 || len(cfg.FederationPeers) != 0) &&
+		(cfg.FederationCert == "" || cfg.FederationKey == "" ||
+			cfg.FederationMembers == "") {
+
+		str := "%s: the federationlisten and federationpeer options " +
+			"require the federationcert, federationkey and " +
+			"federationmembers options"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.FederationCert != "" {
+		cfg.FederationCert = cleanAndExpandPath(cfg.FederationCert)
+		cfg.FederationKey = cleanAndExpandPath(cfg.FederationKey)
+		cfg.FederationMembers = cleanAndExpandPath(cfg.FederationMembers)
+	}
+
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -800,6 +1896,22 @@ func loadConfig() (*config, []string, error) {
 	// duplicate addresses.
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
+	cfg.GRPCListeners = normalizeAddresses(cfg.GRPCListeners,
+		activeNetParams.grpcPort)
+
+	// Handle environment variable expansion in the RPC Unix domain socket
+	// paths and validate the file mode of the sockets.
+	for i, path := range cfg.RPCUnixListeners {
+		cfg.RPCUnixListeners[i] = cleanAndExpandPath(path)
+	}
+	cfg.rpcUnixSocketMode, err = parseRPCUnixSocketMode(cfg.RPCUnixSocketMode)
+	if err != nil {
+		str := "%s: invalid --rpcunixsocketmode: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
 
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
@@ -809,7 +1921,9 @@ func loadConfig() (*config, []string, error) {
 			"127.0.0.1": {},
 			"::1":       {},
 		}
-		for _, addr := range cfg.RPCListeners {
+		listeners := append(cfg.RPCListeners[:len(cfg.RPCListeners):len(cfg.RPCListeners)],
+			cfg.GRPCListeners...)
+		for _, addr := range listeners {
 			host, _, err := net.SplitHostPort(addr)
 			if err != nil {
 				str := "%s: RPC listen interface '%s' is " +
@@ -838,6 +1952,11 @@ func loadConfig() (*config, []string, error) {
 	cfg.ConnectPeers = normalizeAddresses(cfg.ConnectPeers,
 		activeNetParams.DefaultPort)
 
+	// Add the default DNS port to all DNS seeder listener addresses if
+	// needed and remove duplicate addresses.
+	cfg.DNSSeederListeners = normalizeAddresses(cfg.DNSSeederListeners,
+		"53")
+
 	// --noonion and --onion do not mix.
 	if cfg.NoOnion && cfg.OnionProxy != "" {
 		err := fmt.Errorf("%s: the --noonion and --onion options may "+
@@ -857,6 +1976,20 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Check the rule changes to rehearse.
+	for _, name := range cfg.RehearseUpgrade {
+		deployment, err := blockchain.ParseDeployment(name)
+		if err != nil {
+			str := "%s: Error parsing rehearseupgrade: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.rehearseDeployments = append(cfg.rehearseDeployments,
+			deployment)
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
@@ -875,6 +2008,7 @@ func loadConfig() (*config, []string, error) {
 	// specified in which case the system DNS resolver is used).
 	cfg.dial = net.DialTimeout
 	cfg.lookup = net.LookupIP
+	cfg.netDials = make(map[string]func(string, string, time.Duration) (net.Conn, error))
 	if cfg.Proxy != "" {
 		_, _, err := net.SplitHostPort(cfg.Proxy)
 		if err != nil {
@@ -940,7 +2074,7 @@ func loadConfig() (*config, []string, error) {
 				"credentials ")
 		}
 
-		cfg.oniondial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		cfg.netDials[addrmgr.NetworkOnion] = func(network, addr string, timeout time.Duration) (net.Conn, error) {
 			proxy := &socks.Proxy{
 				Addr:         cfg.OnionProxy,
 				Username:     cfg.OnionProxyUser,
@@ -959,14 +2093,80 @@ func loadConfig() (*config, []string, error) {
 				return connmgr.TorLookupIP(host, cfg.OnionProxy)
 			}
 		}
-	} else {
-		cfg.oniondial = cfg.dial
+	}
+
+	// Setup the dial functions of the networks which are routed through a
+	// proxy of their own, such as the client of a pluggable transport.
+	// These override the dial functions selected above for the network.
+	for _, netProxy := range cfg.NetProxies {
+		parts := strings.SplitN(netProxy, "=", 2)
+		var network string
+		if len(parts) == 2 {
+			network = strings.ToLower(parts[0])
+		}
+		switch network {
+		case addrmgr.NetworkIPv4, addrmgr.NetworkIPv6,
+			addrmgr.NetworkOnion, addrmgr.NetworkI2P:
+		default:
+			str := "%s: The netproxy value of '%s' does not name a " +
+				"network {ipv4, ipv6, onion, i2p}"
+			err := fmt.Errorf(str, funcName, netProxy)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if _, _, err := net.SplitHostPort(parts[1]); err != nil {
+			str := "%s: Proxy address '%s' of network %s is invalid: %v"
+			err := fmt.Errorf(str, funcName, parts[1], network, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		proxy := &socks.Proxy{Addr: parts[1]}
+		cfg.netDials[network] = proxy.DialTimeout
+	}
+
+	// Setup the I2P dial function to make connections through the SAM
+	// bridge unless a proxy was configured for I2P above.  The private key
+	// of the I2P destination is only kept when accepting connections, so
+	// the address can be advertised.
+	if cfg.I2PListen && cfg.I2PSAM == "" {
+		str := "%s: the i2plisten option requires the i2psam option"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.I2PSAM != "" {
+		if _, _, err := net.SplitHostPort(cfg.I2PSAM); err != nil {
+			str := "%s: I2P SAM address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.I2PSAM, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		var keyFile string
+		if cfg.I2PListen {
+			keyFile = filepath.Join(cfg.DataDir, defaultI2PKeyFilename)
+		}
+		cfg.i2pSession = connmgr.NewI2PSession(cfg.I2PSAM, keyFile)
+		if _, ok := cfg.netDials[addrmgr.NetworkI2P]; !ok {
+			cfg.netDials[addrmgr.NetworkI2P] = cfg.i2pSession.Dial
+		}
+	}
+
+	// Addresses of I2P can not be reached without a transport of their
+	// own.
+	if _, ok := cfg.netDials[addrmgr.NetworkI2P]; !ok {
+		cfg.netDials[addrmgr.NetworkI2P] = func(a, b string, t time.Duration) (net.Conn, error) {
+			return nil, errors.New("i2p has not been configured")
+		}
 	}
 
 	// Specifying --noonion means the onion address dial function results in
 	// an error.
 	if cfg.NoOnion {
-		cfg.oniondial = func(a, b string, t time.Duration) (net.Conn, error) {
+		cfg.netDials[addrmgr.NetworkOnion] = func(a, b string, t time.Duration) (net.Conn, error) {
 			return nil, errors.New("tor has been disabled")
 		}
 	}
@@ -1051,12 +2251,17 @@ func createDefaultConfigFile(destinationPath string) error {
 // btcdDial connects to the address on the named network using the appropriate
 // dial function depending on the address and configuration options.  For
 // example, .onion addresses will be dialed using the onion specific proxy if
-// one was specified, but will otherwise use the normal dial function (which
-// could itself use a proxy or not).
+// one was specified, .b32.i2p addresses through the I2P SAM bridge, and
+// addresses of networks with a proxy of their own through that proxy, but
+// will otherwise use the normal dial function (which could itself use a proxy
+// or not).
 func btcdDial(addr net.Addr) (net.Conn, error) {
-	if strings.Contains(addr.String(), ".onion:") {
-		return cfg.oniondial(addr.Network(), addr.String(),
-			defaultConnectTimeout)
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		dial, ok := cfg.netDials[addrmgr.HostNetwork(host)]
+		if ok {
+			return dial(addr.Network(), addr.String(),
+				defaultConnectTimeout)
+		}
 	}
 	return cfg.dial(addr.Network(), addr.String(), defaultConnectTimeout)
 }
@@ -7,6 +7,12 @@ import (
 	"regexp"
 	"runtime"
 	"testing"
+	"time"
+
+	"github.com/bitgo/prova/mempool"
+	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
 )
 
 var (
@@ -70,3 +76,177 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestParseMessageLimit ensures message limits are parsed, including limits
+// removing the limit of a message type, and malformed limits are rejected.
+func TestParseMessageLimit(t *testing.T) {
+	tests := []struct {
+		msgLimit string
+		cmd      string
+		limit    peer.MessageLimit
+		valid    bool
+	}{
+		{"addr=10/2000", "addr", peer.MessageLimit{Rate: 10, Burst: 2000}, true},
+		{"MemPool=0.05/2", "mempool", peer.MessageLimit{Rate: 0.05, Burst: 2}, true},
+		{"inv=0", "inv", peer.MessageLimit{}, true},
+		{"inv", "", peer.MessageLimit{}, false},
+		{"=1/1", "", peer.MessageLimit{}, false},
+		{"inv=10", "", peer.MessageLimit{}, false},
+		{"inv=-1/10", "", peer.MessageLimit{}, false},
+		{"inv=10/0", "", peer.MessageLimit{}, false},
+		{"inv=a/10", "", peer.MessageLimit{}, false},
+	}
+	for _, test := range tests {
+		cmd, limit, err := parseMessageLimit(test.msgLimit)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("parseMessageLimit(%q): no error", test.msgLimit)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMessageLimit(%q): unexpected error: %v",
+				test.msgLimit, err)
+			continue
+		}
+		if cmd != test.cmd || limit != test.limit {
+			t.Errorf("parseMessageLimit(%q): got %s %v, want %s %v",
+				test.msgLimit, cmd, limit, test.cmd, test.limit)
+		}
+	}
+}
+
+// TestParseVelocityLimit ensures keyID velocity limits are parsed and
+// malformed limits are rejected.
+func TestParseVelocityLimit(t *testing.T) {
+	tests := []struct {
+		limit string
+		want  mempool.VelocityLimit
+		valid bool
+	}{
+		{"24h:1000", mempool.VelocityLimit{Window: 24 * time.Hour,
+			MaxAmount: 1000 * provautil.AtomsPerGram}, true},
+		{"90m:0.5", mempool.VelocityLimit{Window: 90 * time.Minute,
+			MaxAmount: provautil.AtomsPerGram / 2}, true},
+		{"24h", mempool.VelocityLimit{}, false},
+		{"24h:1000:1", mempool.VelocityLimit{}, false},
+		{"0s:1000", mempool.VelocityLimit{}, false},
+		{"day:1000", mempool.VelocityLimit{}, false},
+		{"24h:-1", mempool.VelocityLimit{}, false},
+	}
+	for _, test := range tests {
+		limit, err := parseVelocityLimit(test.limit)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("parseVelocityLimit(%q): no error", test.limit)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseVelocityLimit(%q): unexpected error: %v",
+				test.limit, err)
+			continue
+		}
+		if limit != test.want {
+			t.Errorf("parseVelocityLimit(%q): got %v, want %v",
+				test.limit, limit, test.want)
+		}
+	}
+}
+
+// TestParseDebugLevels ensures debug levels are parsed to the level of each
+// subsystem they set without setting them.
+func TestParseDebugLevels(t *testing.T) {
+	levels, err := parseDebugLevels("debug")
+	if err != nil {
+		t.Fatalf("parseDebugLevels: unexpected error: %v", err)
+	}
+	if len(levels) != len(subsystemLoggers) || levels["PEER"] != "debug" {
+		t.Errorf("parseDebugLevels: got %v, want debug for every "+
+			"subsystem", levels)
+	}
+
+	levels, err = parseDebugLevels("PEER=trace,RPCS=warn")
+	if err != nil {
+		t.Fatalf("parseDebugLevels: unexpected error: %v", err)
+	}
+	if len(levels) != 2 || levels["PEER"] != "trace" ||
+		levels["RPCS"] != "warn" {
+
+		t.Errorf("parseDebugLevels: got %v", levels)
+	}
+
+	for _, debugLevel := range []string{"loud", "PEER", "NOPE=info",
+		"PEER=loud"} {
+
+		if _, err := parseDebugLevels(debugLevel); err == nil {
+			t.Errorf("parseDebugLevels(%q): no error", debugLevel)
+		}
+	}
+}
+
+// TestParseServiceFlag ensures the services requested from DNS seeds are
+// parsed to their service flags.
+func TestParseServiceFlag(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  wire.ServiceFlag
+		valid bool
+	}{
+		{"cf", wire.SFNodeCF, true},
+		{"Bloom", wire.SFNodeBloom, true},
+		{"getutxo", wire.SFNodeGetUTXO, true},
+		{"witness", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		flag, err := parseServiceFlag(test.name)
+		if (err == nil) != test.valid {
+			t.Errorf("parseServiceFlag(%q): unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		if flag != test.flag {
+			t.Errorf("parseServiceFlag(%q): got %v, want %v", test.name,
+				flag, test.flag)
+		}
+	}
+}
+
+// TestLoadNetParams ensures the ports of a custom network are separated from
+// its chain parameters and that the network is registered.
+func TestLoadNetParams(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "prova")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "privnet.json")
+	file := `{"base": "regtest", "name": "privnet", "net": 3735928559,
+		"defaultport": "19979", "rpcport": "19980"}`
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatalf("Failed writing the parameters file: %v", err)
+	}
+
+	netParams, err := loadNetParams(path)
+	if err != nil {
+		t.Fatalf("loadNetParams: unexpected error: %v", err)
+	}
+	if netParams.Name != "privnet" || netParams.DefaultPort != "19979" {
+		t.Errorf("loadNetParams: got network %q on port %s, want "+
+			"privnet on port 19979", netParams.Name,
+			netParams.DefaultPort)
+	}
+	if netParams.rpcPort != "19980" ||
+		netParams.grpcPort != regressionNetParams.grpcPort {
+
+		t.Errorf("loadNetParams: got rpc port %s and grpc port %s",
+			netParams.rpcPort, netParams.grpcPort)
+	}
+
+	// The network was registered, so it can't be loaded again.
+	if _, err := loadNetParams(path); err == nil {
+		t.Errorf("loadNetParams: loaded a registered network again")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BanReasonManual is the reason of bans which were added manually.
+	BanReasonManual = "manually added"
+
+	// BanReasonMisbehaving is the reason of bans of misbehaving peers.
+	BanReasonMisbehaving = "node misbehaving"
+)
+
+// Ban is a ban of an IP address or subnet until a point in time.
+type Ban struct {
+	Subnet  *net.IPNet
+	Created time.Time
+	Until   time.Time
+	Reason  string
+}
+
+// serializedBan is the format bans are persisted in.
+type serializedBan struct {
+	Subnet  string `json:"subnet"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+	Reason  string `json:"reason"`
+}
+
+// BanManager keeps track of banned IP addresses and subnets, and persists them
+// to a file so they survive restarts.
+type BanManager struct {
+	mtx     sync.Mutex
+	banFile string
+	bans    map[string]*Ban
+}
+
+// ParseSubnet parses an IP address or a subnet in CIDR notation.  A single IP
+// address is returned as a subnet which only contains the address.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if _, subnet, err := net.ParseCIDR(s); err == nil {
+		return subnet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or subnet: %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// NewBanManager returns a new ban manager which persists the bans to the
+// passed file.  The bans which were persisted before are loaded.  A missing or
+// malformed file is logged and starts out without bans.
+func NewBanManager(banFile string) *BanManager {
+	bm := &BanManager{
+		banFile: banFile,
+		bans:    make(map[string]*Ban),
+	}
+	bm.load()
+	return bm
+}
+
+// load loads the unexpired bans from the ban file.
+func (bm *BanManager) load() {
+	r, err := os.Open(bm.banFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error opening file %s: %v", bm.banFile, err)
+		}
+		return
+	}
+	defer r.Close()
+
+	var sbs []serializedBan
+	if err := json.NewDecoder(r).Decode(&sbs); err != nil {
+		log.Errorf("Failed to decode file %s: %v", bm.banFile, err)
+		return
+	}
+	now := time.Now()
+	for _, sb := range sbs {
+		subnet, err := ParseSubnet(sb.Subnet)
+		if err != nil {
+			log.Warnf("Skipping ban in %s: %v", bm.banFile, err)
+			continue
+		}
+		ban := &Ban{
+			Subnet:  subnet,
+			Created: time.Unix(sb.Created, 0),
+			Until:   time.Unix(sb.Until, 0),
+			Reason:  sb.Reason,
+		}
+		if ban.Until.After(now) {
+			bm.bans[subnet.String()] = ban
+		}
+	}
+	log.Infof("Loaded %d bans from file '%s'", len(bm.bans), bm.banFile)
+}
+
+// save persists the bans to the ban file.
+//
+// This function MUST be called with the ban manager lock held.
+func (bm *BanManager) save() error {
+	sbs := make([]serializedBan, 0, len(bm.bans))
+	for _, ban := range bm.bans {
+		sbs = append(sbs, serializedBan{
+			Subnet:  ban.Subnet.String(),
+			Created: ban.Created.Unix(),
+			Until:   ban.Until.Unix(),
+			Reason:  ban.Reason,
+		})
+	}
+
+	// Write to a temporary file first so the bans are not lost when
+	// writing fails.
+	tmpFile := bm.banFile + ".tmp"
+	w, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(sbs); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, bm.banFile)
+}
+
+// removeExpired removes the bans which expired before the passed time.
+//
+// This function MUST be called with the ban manager lock held.
+func (bm *BanManager) removeExpired(now time.Time) {
+	for key, ban := range bm.bans {
+		if !ban.Until.After(now) {
+			log.Infof("Ban of %s expired", ban.Subnet)
+			delete(bm.bans, key)
+		}
+	}
+}
+
+// Ban bans the passed subnet until the passed time for the passed reason,
+// replacing an existing ban of the subnet.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) Ban(subnet *net.IPNet, until time.Time, reason string) error {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	bm.removeExpired(now)
+	bm.bans[subnet.String()] = &Ban{
+		Subnet:  subnet,
+		Created: now,
+		Until:   until,
+		Reason:  reason,
+	}
+	return bm.save()
+}
+
+// Unban removes the ban of the passed subnet.  It returns whether the subnet
+// was banned.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) Unban(subnet *net.IPNet) (bool, error) {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	bm.removeExpired(time.Now())
+	key := subnet.String()
+	if _, ok := bm.bans[key]; !ok {
+		return false, nil
+	}
+	delete(bm.bans, key)
+	return true, bm.save()
+}
+
+// IsSubnetBanned returns whether exactly the passed subnet is banned.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) IsSubnetBanned(subnet *net.IPNet) bool {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	ban, ok := bm.bans[subnet.String()]
+	return ok && ban.Until.After(time.Now())
+}
+
+// IsBanned returns the ban with the latest expiry which covers the passed IP
+// address, or nil when the address is not banned.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) IsBanned(ip net.IP) *Ban {
+	if ip == nil {
+		return nil
+	}
+
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	var match *Ban
+	for _, ban := range bm.bans {
+		if !ban.Until.After(now) || !ban.Subnet.Contains(ip) {
+			continue
+		}
+		if match == nil || ban.Until.After(match.Until) {
+			match = ban
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	banCopy := *match
+	return &banCopy
+}
+
+// Bans returns the unexpired bans sorted by subnet.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) Bans() []Ban {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(bm.bans))
+	for _, ban := range bm.bans {
+		if ban.Until.After(now) {
+			bans = append(bans, *ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Subnet.String() < bans[j].Subnet.String()
+	})
+	return bans
+}
+
+// Clear removes all bans.
+//
+// This function is safe for concurrent access.
+func (bm *BanManager) Clear() error {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	bm.bans = make(map[string]*Ban)
+	return bm.save()
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures IP addresses and subnets are parsed as subnets.
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.2.3.4", "1.2.3.4/32"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"::1", "::1/128"},
+		{"fd00::1/16", "fd00::/16"},
+	}
+	for _, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if err != nil {
+			t.Errorf("ParseSubnet(%s): unexpected error: %v", test.in,
+				err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet(%s): got %s, want %s", test.in,
+				subnet, test.want)
+		}
+	}
+	if _, err := ParseSubnet("example.com"); err == nil {
+		t.Error("ParseSubnet: no error for a host name")
+	}
+}
+
+// TestBanManager ensures bans cover the addresses of their subnets, expire,
+// can be removed, and are persisted.
+func TestBanManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	banFile := filepath.Join(dir, "banlist.json")
+
+	bm := NewBanManager(banFile)
+	subnet, _ := ParseSubnet("10.0.0.0/8")
+	host, _ := ParseSubnet("192.168.1.1")
+	expired, _ := ParseSubnet("172.16.0.1")
+	until := time.Now().Add(time.Hour)
+	if err := bm.Ban(subnet, until, BanReasonManual); err != nil {
+		t.Fatalf("Ban: unexpected error: %v", err)
+	}
+	if err := bm.Ban(host, until, BanReasonMisbehaving); err != nil {
+		t.Fatalf("Ban: unexpected error: %v", err)
+	}
+	err = bm.Ban(expired, time.Now().Add(-time.Second), BanReasonManual)
+	if err != nil {
+		t.Fatalf("Ban: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"10.20.30.40", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"172.16.0.1", false},
+	}
+	check := func(bm *BanManager) {
+		for _, test := range tests {
+			ban := bm.IsBanned(net.ParseIP(test.ip))
+			if (ban != nil) != test.banned {
+				t.Errorf("IsBanned(%s): got %v, want %v", test.ip,
+					ban != nil, test.banned)
+			}
+		}
+	}
+	check(bm)
+	if bm.IsBanned(nil) != nil {
+		t.Error("IsBanned: nil address is banned")
+	}
+
+	// The bans are loaded again from the file.
+	bm = NewBanManager(banFile)
+	check(bm)
+	bans := bm.Bans()
+	if len(bans) != 2 || bans[0].Subnet.String() != "10.0.0.0/8" ||
+		bans[0].Reason != BanReasonManual ||
+		bans[0].Until.Unix() != until.Unix() ||
+		bans[1].Reason != BanReasonMisbehaving {
+
+		t.Fatalf("Bans: unexpected bans %v", bans)
+	}
+	if !bm.IsSubnetBanned(subnet) || bm.IsSubnetBanned(expired) {
+		t.Error("IsSubnetBanned: unexpected result")
+	}
+
+	// Removed bans no longer apply.
+	removed, err := bm.Unban(subnet)
+	if err != nil || !removed {
+		t.Fatalf("Unban: got %v, %v, want removed", removed, err)
+	}
+	if removed, _ := bm.Unban(subnet); removed {
+		t.Error("Unban: removed a ban twice")
+	}
+	if bm.IsBanned(net.ParseIP("10.20.30.40")) != nil {
+		t.Error("IsBanned: address of removed ban is banned")
+	}
+
+	if err := bm.Clear(); err != nil {
+		t.Fatalf("Clear: unexpected error: %v", err)
+	}
+	if bans := NewBanManager(banFile).Bans(); len(bans) != 0 {
+		t.Errorf("Bans: got %d bans after Clear, want 0", len(bans))
+	}
+}
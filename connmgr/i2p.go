@@ -0,0 +1,516 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// i2pSignatureType is the signature type of the destinations created
+	// for sessions, which is EdDSA_SHA512_Ed25519.
+	i2pSignatureType = 7
+
+	// i2pSessionTimeout is the time allowed to create a session, which
+	// includes building the tunnels of the session.
+	i2pSessionTimeout = 3 * time.Minute
+
+	// i2pReplyTimeout is the time allowed for the SAM bridge to reply to
+	// commands other than creating a session.
+	i2pReplyTimeout = time.Minute
+
+	// i2pAcceptRetryInterval is the time to wait before accepting
+	// connections again after accepting failed.
+	i2pAcceptRetryInterval = 5 * time.Second
+
+	// i2pMaxLineLength is the maximum length of a line sent by the SAM
+	// bridge.
+	i2pMaxLineLength = 64 * 1024
+)
+
+var (
+	// ErrI2PSessionClosed indicates the I2P session was closed.
+	ErrI2PSessionClosed = errors.New("i2p session closed")
+
+	// i2pBase64 is the base64 encoding of I2P destinations, which uses
+	// '-' and '~' instead of '+' and '/'.
+	i2pBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz0123456789-~")
+
+	// i2pBase32 is the base32 encoding of I2P addresses.
+	i2pBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// I2PAddr implements the net.Addr interface and represents the address of an
+// I2P destination, which is the base32 encoding of its hash followed by
+// ".b32.i2p".  I2P streams have no ports, so the port is always 0.
+type I2PAddr struct {
+	Host string
+}
+
+// Network returns the network of the address.
+//
+// This is part of the net.Addr interface.
+func (a *I2PAddr) Network() string {
+	return "i2p"
+}
+
+// String returns the address in host:port form.
+//
+// This is part of the net.Addr interface.
+func (a *I2PAddr) String() string {
+	return net.JoinHostPort(a.Host, "0")
+}
+
+// Ensure I2PAddr implements the net.Addr interface.
+var _ net.Addr = (*I2PAddr)(nil)
+
+// i2pConn is a stream to or from an I2P destination.
+type i2pConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+// LocalAddr returns the address of the local destination.
+func (c *i2pConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the address of the remote destination.
+func (c *i2pConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// i2pDestAddr returns the address of the passed base64 encoded destination.
+func i2pDestAddr(dest string) (string, error) {
+	data, err := i2pBase64.DecodeString(dest)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return strings.ToLower(i2pBase32.EncodeToString(hash[:])) + ".b32.i2p",
+		nil
+}
+
+// i2pPublicDest returns the base64 encoded public destination of the passed
+// base64 encoded private key, which starts with the public destination.  The
+// destination is a 384 byte public and signing key followed by a certificate
+// with a 1 byte type and a 2 byte length.
+func i2pPublicDest(priv string) (string, error) {
+	data, err := i2pBase64.DecodeString(priv)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < 387 {
+		return "", errors.New("i2p private key too short")
+	}
+	destLen := 387 + int(binary.BigEndian.Uint16(data[385:387]))
+	if len(data) < destLen {
+		return "", errors.New("i2p private key too short")
+	}
+	return i2pBase64.EncodeToString(data[:destLen]), nil
+}
+
+// samReadLine reads a line sent by the SAM bridge.  The line is read one byte
+// at a time, since the data of a stream directly follows the reply which
+// opened it.
+func samReadLine(r io.Reader) (string, error) {
+	var line []byte
+	var b [1]byte
+	for len(line) < i2pMaxLineLength {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", errors.New("i2p SAM line too long")
+}
+
+// parseSAMReply parses the key=value pairs of a reply of the SAM bridge, and
+// returns an error when the reply reports a result other than OK.  Values may
+// be quoted to contain spaces.
+func parseSAMReply(line string) (map[string]string, error) {
+	reply := make(map[string]string)
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+		eq := strings.IndexByte(line[:end], '=')
+		if eq < 0 {
+			// The words naming the reply have no values.
+			line = line[end:]
+			continue
+		}
+		key := line[:eq]
+		value := line[eq+1:]
+		if strings.HasPrefix(value, "\"") {
+			quote := strings.IndexByte(value[1:], '"')
+			if quote < 0 {
+				return nil, fmt.Errorf("unterminated quote in "+
+					"i2p SAM reply %q", line)
+			}
+			reply[key] = value[1 : quote+1]
+			line = value[quote+2:]
+			continue
+		}
+		end = strings.IndexByte(value, ' ')
+		if end < 0 {
+			end = len(value)
+		}
+		reply[key] = value[:end]
+		line = value[end:]
+	}
+	if result, ok := reply["RESULT"]; ok && result != "OK" {
+		if msg := reply["MESSAGE"]; msg != "" {
+			return nil, fmt.Errorf("i2p SAM %s: %s", result, msg)
+		}
+		return nil, fmt.Errorf("i2p SAM %s", result)
+	}
+	return reply, nil
+}
+
+// samCommand sends the passed command to the SAM bridge and returns its reply.
+func samCommand(conn net.Conn, cmd string) (map[string]string, error) {
+	if _, err := io.WriteString(conn, cmd+"\n"); err != nil {
+		return nil, err
+	}
+	line, err := samReadLine(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseSAMReply(line)
+}
+
+// I2PSession is a stream session of the SAM bridge of an I2P router, through
+// which connections to and from I2P destinations are made.  The session is
+// created when it is first used, and created again when the SAM bridge closed
+// it.
+//
+// I2PSession implements the net.Listener interface to accept connections to
+// the destination of the session.
+type I2PSession struct {
+	samAddr string
+	keyFile string
+	quit    chan struct{}
+
+	mtx       sync.Mutex
+	closed    bool
+	control   net.Conn
+	accepting net.Conn
+	id        string
+	addr      *I2PAddr
+}
+
+// NewI2PSession returns a new I2P session using the SAM bridge at the passed
+// address.  The private key of the destination of the session is kept in the
+// passed file, which is created when missing, so the destination stays the
+// same across restarts.  When no file is passed, a transient destination is
+// used, which is sufficient to only make outbound connections.
+func NewI2PSession(samAddr, keyFile string) *I2PSession {
+	return &I2PSession{
+		samAddr: samAddr,
+		keyFile: keyFile,
+		quit:    make(chan struct{}),
+	}
+}
+
+// samConnect opens a connection to the SAM bridge and negotiates the protocol
+// version.  The deadline of the connection is set to the passed timeout.
+func (s *I2PSession) samConnect(timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", s.samAddr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := samCommand(conn, "HELLO VERSION MIN=3.1 MAX=3.1"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// privateKey returns the private key of the destination of the session, which
+// is loaded from the key file or generated and saved to it.
+func (s *I2PSession) privateKey(conn net.Conn) (string, error) {
+	if s.keyFile == "" {
+		return "TRANSIENT", nil
+	}
+	if data, err := ioutil.ReadFile(s.keyFile); err == nil {
+		return i2pBase64.EncodeToString(data), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	reply, err := samCommand(conn, fmt.Sprintf("DEST GENERATE "+
+		"SIGNATURE_TYPE=%d", i2pSignatureType))
+	if err != nil {
+		return "", err
+	}
+	priv := reply["PRIV"]
+	data, err := i2pBase64.DecodeString(priv)
+	if err != nil {
+		return "", fmt.Errorf("invalid i2p private key: %v", err)
+	}
+	if err := ioutil.WriteFile(s.keyFile, data, 0600); err != nil {
+		return "", err
+	}
+	log.Infof("Generated I2P private key %s", s.keyFile)
+	return priv, nil
+}
+
+// session returns the ID of the session, creating the session first when it
+// does not exist.
+func (s *I2PSession) session() (string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return "", ErrI2PSessionClosed
+	}
+	if s.control != nil {
+		return s.id, nil
+	}
+
+	conn, err := s.samConnect(i2pSessionTimeout)
+	if err != nil {
+		return "", err
+	}
+	priv, err := s.privateKey(conn)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	var idBytes [8]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		conn.Close()
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes[:])
+	reply, err := samCommand(conn, fmt.Sprintf("SESSION CREATE "+
+		"STYLE=STREAM ID=%s DESTINATION=%s SIGNATURE_TYPE=%d", id, priv,
+		i2pSignatureType))
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	if reply["DESTINATION"] != "" {
+		priv = reply["DESTINATION"]
+	}
+	pub, err := i2pPublicDest(priv)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	host, err := i2pDestAddr(pub)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.control = conn
+	s.id = id
+	s.addr = &I2PAddr{Host: host}
+	log.Infof("Created I2P session %s with address %s", id, host)
+
+	// The session lasts as long as the control connection, so the session
+	// is created again once the SAM bridge closes it.
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		s.mtx.Lock()
+		if s.control == conn {
+			log.Infof("I2P session %s closed", id)
+			s.control = nil
+		}
+		s.mtx.Unlock()
+	}()
+	return id, nil
+}
+
+// Dial connects to the passed I2P address in host:port form.  The port is
+// ignored since I2P streams have no ports.
+//
+// The signature matches the dial functions of the server, so it can be used
+// as the dial function of the I2P network.
+func (s *I2PSession) Dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.samConnect(timeout)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := samCommand(conn, "NAMING LOOKUP NAME="+host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = samCommand(conn, fmt.Sprintf("STREAM CONNECT ID=%s "+
+		"DESTINATION=%s SILENT=false", id, reply["VALUE"]))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &i2pConn{
+		Conn:   conn,
+		local:  s.Addr(),
+		remote: &I2PAddr{Host: host},
+	}, nil
+}
+
+// Listen creates the session so the address of its destination is known and
+// returns the session to accept connections to the destination.
+func (s *I2PSession) Listen() (net.Listener, error) {
+	if _, err := s.session(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// accept waits for a connection to the destination of the session.
+func (s *I2PSession) accept() (net.Conn, error) {
+	id, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.samConnect(i2pReplyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	_, err = samCommand(conn, fmt.Sprintf("STREAM ACCEPT ID=%s "+
+		"SILENT=false", id))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		conn.Close()
+		return nil, ErrI2PSessionClosed
+	}
+	s.accepting = conn
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		s.accepting = nil
+		s.mtx.Unlock()
+	}()
+
+	// The SAM bridge sends the destination of the remote peer once it
+	// connected, followed by the data of the stream.
+	line, err := samReadLine(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		conn.Close()
+		return nil, errors.New("i2p SAM sent no destination")
+	}
+	host, err := i2pDestAddr(fields[0])
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("invalid i2p destination: %v", err)
+	}
+	return &i2pConn{
+		Conn:   conn,
+		local:  s.Addr(),
+		remote: &I2PAddr{Host: host},
+	}, nil
+}
+
+// Accept waits for and returns the next connection to the destination of the
+// session.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Accept() (net.Conn, error) {
+	for {
+		conn, err := s.accept()
+		if err == nil {
+			return conn, nil
+		}
+		if err == ErrI2PSessionClosed {
+			return nil, err
+		}
+		log.Debugf("Failed to accept I2P connection: %v", err)
+
+		select {
+		case <-time.After(i2pAcceptRetryInterval):
+		case <-s.quit:
+			return nil, ErrI2PSessionClosed
+		}
+	}
+}
+
+// Close closes the session.  Connections which were made through the session
+// are closed by the I2P router.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	if s.accepting != nil {
+		s.accepting.Close()
+	}
+	if s.control != nil {
+		s.control.Close()
+		s.control = nil
+	}
+	return nil
+}
+
+// Addr returns the address of the destination of the session, or nil when
+// the session was not created yet.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Addr() net.Addr {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.addr == nil {
+		return nil
+	}
+	return s.addr
+}
+
+// Ensure I2PSession implements the net.Listener interface.
+var _ net.Listener = (*I2PSession)(nil)
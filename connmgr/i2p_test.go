@@ -0,0 +1,201 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockSAMBridge is a minimal SAM bridge with a single destination, which
+// connects the streams connecting to the destination with the streams
+// accepting connections to it.
+type mockSAMBridge struct {
+	listener net.Listener
+	priv     string
+	pub      string
+	host     string
+	connects chan net.Conn
+}
+
+// newMockSAMBridge starts a new mock SAM bridge listening on a loopback port.
+func newMockSAMBridge(t *testing.T) *mockSAMBridge {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+
+	// The destination is a 384 byte key, a certificate without data and
+	// a private key.
+	dest := bytes.Repeat([]byte{0x42}, 387)
+	dest[384], dest[385], dest[386] = 0, 0, 0
+	priv := append(append([]byte{}, dest...), bytes.Repeat([]byte{1}, 32)...)
+	pub := i2pBase64.EncodeToString(dest)
+	host, err := i2pDestAddr(pub)
+	if err != nil {
+		t.Fatalf("i2pDestAddr: unexpected error: %v", err)
+	}
+
+	b := &mockSAMBridge{
+		listener: listener,
+		priv:     i2pBase64.EncodeToString(priv),
+		pub:      pub,
+		host:     host,
+		connects: make(chan net.Conn),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.handle(conn)
+		}
+	}()
+	return b
+}
+
+// handle serves the commands sent over a connection to the bridge.
+func (b *mockSAMBridge) handle(conn net.Conn) {
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\n", args...)
+	}
+	for {
+		line, err := samReadLine(conn)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "HELLO VERSION"):
+			reply("HELLO REPLY RESULT=OK VERSION=3.1")
+		case strings.HasPrefix(line, "DEST GENERATE"):
+			reply("DEST REPLY PUB=%s PRIV=%s", b.pub, b.priv)
+		case strings.HasPrefix(line, "SESSION CREATE"):
+			reply("SESSION STATUS RESULT=OK DESTINATION=%s", b.priv)
+		case strings.HasPrefix(line, "NAMING LOOKUP"):
+			if line != "NAMING LOOKUP NAME="+b.host {
+				reply("NAMING REPLY RESULT=KEY_NOT_FOUND")
+				continue
+			}
+			reply("NAMING REPLY RESULT=OK NAME=%s VALUE=%s", b.host,
+				b.pub)
+		case strings.HasPrefix(line, "STREAM CONNECT"):
+			reply("STREAM STATUS RESULT=OK")
+			b.connects <- conn
+			return
+		case strings.HasPrefix(line, "STREAM ACCEPT"):
+			reply("STREAM STATUS RESULT=OK")
+			other := <-b.connects
+			reply("%s FROM_PORT=0 TO_PORT=0", b.pub)
+			go io.Copy(conn, other)
+			io.Copy(other, conn)
+			return
+		default:
+			reply("UNKNOWN RESULT=I2P_ERROR MESSAGE=\"unknown command\"")
+		}
+	}
+}
+
+// TestParseSAMReply ensures replies of the SAM bridge are parsed, including
+// quoted values, and replies reporting errors are rejected.
+func TestParseSAMReply(t *testing.T) {
+	reply, err := parseSAMReply("NAMING REPLY RESULT=OK NAME=a VALUE=b~c")
+	if err != nil {
+		t.Fatalf("parseSAMReply: unexpected error: %v", err)
+	}
+	if reply["NAME"] != "a" || reply["VALUE"] != "b~c" {
+		t.Errorf("parseSAMReply: unexpected reply %v", reply)
+	}
+
+	_, err = parseSAMReply("STREAM STATUS RESULT=CANT_REACH_PEER " +
+		"MESSAGE=\"peer not found\"")
+	if err == nil || !strings.Contains(err.Error(), "peer not found") {
+		t.Errorf("parseSAMReply: got error %v, want peer not found", err)
+	}
+}
+
+// TestI2PSession ensures sessions save their private key, and connect to and
+// accept connections from destinations.
+func TestI2PSession(t *testing.T) {
+	bridge := newMockSAMBridge(t)
+	defer bridge.listener.Close()
+
+	dir, err := ioutil.TempDir("", "i2p")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "i2p_private_key")
+
+	session := NewI2PSession(bridge.listener.Addr().String(), keyFile)
+	listener, err := session.Listen()
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Errorf("Listen: private key was not saved: %v", err)
+	}
+	if addr := listener.Addr().String(); addr != bridge.host+":0" {
+		t.Errorf("Addr: got %s, want %s:0", addr, bridge.host)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := listener.Accept()
+		accepted <- result{conn, err}
+	}()
+
+	conn, err := session.Dial("tcp", bridge.host+":0", time.Second)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	r := <-accepted
+	if r.err != nil {
+		t.Fatalf("Accept: unexpected error: %v", r.err)
+	}
+	defer r.conn.Close()
+	if addr := r.conn.RemoteAddr().String(); addr != bridge.host+":0" {
+		t.Errorf("RemoteAddr: got %s, want %s:0", addr, bridge.host)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r.conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("Read: got %q, %v, want ping", buf, err)
+	}
+
+	// Unknown destinations can not be connected to.
+	unknown := strings.Repeat("a", 52) + ".b32.i2p:0"
+	if _, err := session.Dial("tcp", unknown, time.Second); err == nil {
+		t.Errorf("Dial: no error for unknown destination")
+	}
+
+	// Closing the session stops accepting connections.
+	go func() {
+		conn, err := listener.Accept()
+		accepted <- result{conn, err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	session.Close()
+	if r := <-accepted; r.err != ErrI2PSessionClosed {
+		t.Errorf("Accept: got error %v after Close, want %v", r.err,
+			ErrI2PSessionClosed)
+	}
+}
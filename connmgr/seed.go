@@ -33,15 +33,28 @@ type LookupFunc func(string) ([]net.IP, error)
 func SeedFromDNS(chainParams *chaincfg.Params, reqServices wire.ServiceFlag,
 	lookupFn LookupFunc, seedFn OnSeed) {
 
-	for _, dnsseed := range chainParams.DNSSeeds {
+	SeedFromDNSSeeds(chainParams.DNSSeeds, chainParams.DefaultPort,
+		reqServices, lookupFn, seedFn)
+}
+
+// SeedFromDNSSeeds queries the passed DNS seeds for peers listening on the
+// passed default port.  Seeds supporting filtering are only asked for peers
+// advertising the required services, which are then assumed to be advertised
+// by the returned peers.
+func SeedFromDNSSeeds(seeds []chaincfg.DNSSeed, defaultPort string,
+	reqServices wire.ServiceFlag, lookupFn LookupFunc, seedFn OnSeed) {
+
+	for _, dnsseed := range seeds {
 		var host string
+		var services wire.ServiceFlag
 		if !dnsseed.HasFiltering || reqServices == wire.SFNodeNetwork {
 			host = dnsseed.Host
 		} else {
 			host = fmt.Sprintf("x%x.%s", uint64(reqServices), dnsseed.Host)
+			services = reqServices
 		}
 
-		go func(host string) {
+		go func(host string, services wire.ServiceFlag) {
 			randSource := mrand.New(mrand.NewSource(time.Now().UnixNano()))
 
 			seedpeers, err := lookupFn(host)
@@ -58,7 +71,7 @@ func SeedFromDNS(chainParams *chaincfg.Params, reqServices wire.ServiceFlag,
 			}
 			addresses := make([]*wire.NetAddress, len(seedpeers))
 			// if this errors then we have *real* problems
-			intPort, _ := strconv.Atoi(chainParams.DefaultPort)
+			intPort, _ := strconv.Atoi(defaultPort)
 			for i, peer := range seedpeers {
 				addresses[i] = wire.NewNetAddressTimestamp(
 					// bitcoind seeds with addresses from
@@ -66,10 +79,10 @@ func SeedFromDNS(chainParams *chaincfg.Params, reqServices wire.ServiceFlag,
 					// and 7 days ago.
 					time.Now().Add(-1*time.Second*time.Duration(secondsIn3Days+
 						randSource.Int31n(secondsIn4Days))),
-					0, peer, uint16(intPort))
+					services, peer, uint16(intPort))
 			}
 
 			seedFn(addresses)
-		}(host)
+		}(host, services)
 	}
 }
@@ -0,0 +1,329 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// maxDNSMessageSize is the maximum size of a DNS message sent over UDP
+	// without extensions, which bounds the number of addresses in an
+	// answer.
+	maxDNSMessageSize = 512
+
+	// seederSampleSize is the number of good addresses requested for each
+	// query, from which the addresses of the queried family are answered.
+	seederSampleSize = 256
+
+	// defaultSeederTTL is the time to live of the answers of the seeder
+	// when none is configured.  It is short so resolvers query the seeder
+	// again soon and spread peers across the addresses known to it.
+	defaultSeederTTL = time.Minute
+)
+
+// DNS header flags, response codes, record types and classes used by the
+// seeder.
+const (
+	dnsFlagResponse      = 1 << 15
+	dnsFlagAuthoritative = 1 << 10
+	dnsFlagRecursion     = 1 << 8
+
+	dnsRcodeFormatError    = 1
+	dnsRcodeNameError      = 3
+	dnsRcodeNotImplemented = 4
+	dnsRcodeRefused        = 5
+
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsTypeANY  = 255
+
+	dnsClassIN  = 1
+	dnsClassANY = 255
+)
+
+// errMalformedQuery indicates a DNS query could not be parsed.
+var errMalformedQuery = errors.New("malformed DNS query")
+
+// SeederConfig houses the configuration of a DNS seeder.
+type SeederConfig struct {
+	// Zone is the domain name the seeder is authoritative for.  Queries for
+	// the zone itself are answered with peers of the network, while
+	// queries for x<services>.<zone>, where services are the hex encoded
+	// service flags, are answered with peers advertising those services.
+	Zone string
+
+	// Port is the port peers must listen on to be served.  Since DNS
+	// answers only carry IP addresses, this is the default port of the
+	// network.
+	Port uint16
+
+	// TTL is the time to live of the answers.  It defaults to one minute.
+	TTL time.Duration
+
+	// Addresses returns up to max addresses of peers which are known to be
+	// good, advertise at least the passed services and listen on the
+	// passed port, in random order.
+	Addresses func(services wire.ServiceFlag, port uint16, max int) []*wire.NetAddress
+}
+
+// DNSSeeder is an authoritative DNS server for a single zone answering queries
+// with the addresses of peers known to be good, so new nodes can bootstrap
+// their peers from it the same way they do from the DNS seeds of the network.
+// Only A and AAAA queries over UDP are supported, which is what resolvers use
+// to query DNS seeds.
+type DNSSeeder struct {
+	cfg  SeederConfig
+	zone string
+
+	mtx   sync.Mutex
+	conns []net.PacketConn
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewDNSSeeder returns a new DNS seeder using the passed config.
+func NewDNSSeeder(cfg *SeederConfig) *DNSSeeder {
+	s := DNSSeeder{
+		cfg:  *cfg,
+		zone: canonicalName(cfg.Zone),
+		quit: make(chan struct{}),
+	}
+	if s.cfg.TTL <= 0 {
+		s.cfg.TTL = defaultSeederTTL
+	}
+	return &s
+}
+
+// canonicalName returns the passed domain name in lower case and without a
+// trailing dot, which is how names are compared by the seeder.
+func canonicalName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// Serve answers the queries received on the passed connection until the seeder
+// is stopped.  The connection is closed when the seeder is stopped.
+func (s *DNSSeeder) Serve(conn net.PacketConn) {
+	s.mtx.Lock()
+	s.conns = append(s.conns, conn)
+	s.mtx.Unlock()
+
+	log.Infof("DNS seeder for %s listening on %s", s.zone, conn.LocalAddr())
+
+	s.wg.Add(1)
+	go s.serve(conn)
+}
+
+// serve reads and answers queries received on the passed connection.  It must
+// be run as a goroutine.
+func (s *DNSSeeder) serve(conn net.PacketConn) {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxDNSMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+
+			// Only a closed connection is permanent, so keep
+			// serving after any other error.
+			log.Debugf("DNS seeder failed to read from %s: %v",
+				conn.LocalAddr(), err)
+			if netErr, ok := err.(net.Error); ok &&
+				(netErr.Temporary() || netErr.Timeout()) {
+				continue
+			}
+			return
+		}
+
+		resp, err := s.handleQuery(buf[:n])
+		if err != nil {
+			log.Debugf("DNS seeder ignoring query from %s: %v", addr,
+				err)
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			log.Debugf("DNS seeder failed to answer %s: %v", addr, err)
+		}
+	}
+}
+
+// Stop stops answering queries and closes the connections of the seeder.
+func (s *DNSSeeder) Stop() {
+	close(s.quit)
+
+	s.mtx.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+}
+
+// parseQuestion parses the name, type and class of the question which starts
+// at the passed offset of the passed message, and returns them along with the
+// offset of the end of the question.  Compressed names are not supported since
+// they are never used in the question of a query.
+func parseQuestion(msg []byte, offset int) (string, uint16, uint16, int, error) {
+	var labels []string
+	nameLen := 0
+	for {
+		if offset >= len(msg) {
+			return "", 0, 0, 0, errMalformedQuery
+		}
+		labelLen := int(msg[offset])
+		offset++
+		if labelLen == 0 {
+			break
+		}
+		if labelLen > 63 || offset+labelLen > len(msg) {
+			return "", 0, 0, 0, errMalformedQuery
+		}
+		nameLen += labelLen + 1
+		if nameLen > 255 {
+			return "", 0, 0, 0, errMalformedQuery
+		}
+		labels = append(labels, string(msg[offset:offset+labelLen]))
+		offset += labelLen
+	}
+	if offset+4 > len(msg) {
+		return "", 0, 0, 0, errMalformedQuery
+	}
+	qtype := binary.BigEndian.Uint16(msg[offset:])
+	qclass := binary.BigEndian.Uint16(msg[offset+2:])
+	name := canonicalName(strings.Join(labels, "."))
+	return name, qtype, qclass, offset + 4, nil
+}
+
+// queriedServices returns the services requested by a query for the passed
+// name, and whether the name exists in the zone of the seeder.
+func (s *DNSSeeder) queriedServices(name string) (wire.ServiceFlag, bool) {
+	if name == s.zone {
+		return wire.SFNodeNetwork, true
+	}
+
+	sub := strings.TrimSuffix(name, "."+s.zone)
+	if len(sub) < 2 || sub[0] != 'x' {
+		return 0, false
+	}
+	services, err := strconv.ParseUint(sub[1:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return wire.ServiceFlag(services), true
+}
+
+// handleQuery returns the response to the passed query.  An error is returned
+// when the message is too malformed to be answered at all.
+func (s *DNSSeeder) handleQuery(req []byte) ([]byte, error) {
+	if len(req) < 12 {
+		return nil, errMalformedQuery
+	}
+	flags := binary.BigEndian.Uint16(req[2:])
+	if flags&dnsFlagResponse != 0 {
+		return nil, errMalformedQuery
+	}
+
+	// The response echoes the ID and question of the query.
+	resp := make([]byte, 12, maxDNSMessageSize)
+	copy(resp, req[:2])
+	respFlags := uint16(dnsFlagResponse | dnsFlagAuthoritative)
+	respFlags |= flags & dnsFlagRecursion
+	reply := func(rcode uint16, question []byte, answers uint16) []byte {
+		binary.BigEndian.PutUint16(resp[2:], respFlags|rcode)
+		if question != nil {
+			binary.BigEndian.PutUint16(resp[4:], 1)
+		}
+		binary.BigEndian.PutUint16(resp[6:], answers)
+		return resp
+	}
+
+	opcode := (flags >> 11) & 0xf
+	if opcode != 0 {
+		return reply(dnsRcodeNotImplemented, nil, 0), nil
+	}
+	if binary.BigEndian.Uint16(req[4:]) != 1 {
+		return reply(dnsRcodeFormatError, nil, 0), nil
+	}
+	name, qtype, qclass, end, err := parseQuestion(req, 12)
+	if err != nil {
+		return reply(dnsRcodeFormatError, nil, 0), nil
+	}
+	question := req[12:end]
+	resp = append(resp, question...)
+
+	if name != s.zone && !strings.HasSuffix(name, "."+s.zone) {
+		return reply(dnsRcodeRefused, question, 0), nil
+	}
+	services, ok := s.queriedServices(name)
+	if !ok {
+		return reply(dnsRcodeNameError, question, 0), nil
+	}
+
+	// Names without addresses of the queried family have no records of
+	// the type, which is a successful empty answer.
+	var ipLen int
+	var rrType uint16
+	switch {
+	case qclass != dnsClassIN && qclass != dnsClassANY:
+		return reply(0, question, 0), nil
+	case qtype == dnsTypeA || qtype == dnsTypeANY:
+		ipLen, rrType = net.IPv4len, dnsTypeA
+	case qtype == dnsTypeAAAA:
+		ipLen, rrType = net.IPv6len, dnsTypeAAAA
+	default:
+		return reply(0, question, 0), nil
+	}
+
+	// Answer with as many addresses of the queried family as fit in the
+	// response.  Each record refers to the name in the question with a
+	// compression pointer.
+	rrLen := 12 + ipLen
+	maxAnswers := (maxDNSMessageSize - len(resp)) / rrLen
+	ttl := uint32(s.cfg.TTL / time.Second)
+	var answers uint16
+	for _, na := range s.cfg.Addresses(services, s.cfg.Port, seederSampleSize) {
+		if int(answers) == maxAnswers {
+			break
+		}
+		ip := na.IP.To4()
+		if ipLen == net.IPv6len {
+			if ip != nil {
+				continue
+			}
+			ip = na.IP.To16()
+		}
+		if ip == nil {
+			continue
+		}
+
+		var rr [12]byte
+		binary.BigEndian.PutUint16(rr[0:], 0xc000|12)
+		binary.BigEndian.PutUint16(rr[2:], rrType)
+		binary.BigEndian.PutUint16(rr[4:], dnsClassIN)
+		binary.BigEndian.PutUint32(rr[6:], ttl)
+		binary.BigEndian.PutUint16(rr[10:], uint16(ipLen))
+		resp = append(resp, rr[:]...)
+		resp = append(resp, ip...)
+		answers++
+	}
+
+	log.Tracef("DNS seeder answering %s with %d addresses advertising %v",
+		name, answers, services)
+	return reply(0, question, answers), nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// dnsQuery returns a DNS query for the passed name and type.
+func dnsQuery(id uint16, name string, qtype uint16) []byte {
+	query := make([]byte, 12)
+	binary.BigEndian.PutUint16(query[0:], id)
+	binary.BigEndian.PutUint16(query[2:], dnsFlagRecursion)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, byte(qtype>>8), byte(qtype), 0, dnsClassIN)
+	return query
+}
+
+// dnsAnswers returns the response code and the addresses answered in the
+// passed response to the passed query.
+func dnsAnswers(t *testing.T, query, resp []byte) (uint16, []net.IP) {
+	if len(resp) < len(query) {
+		t.Fatalf("response too short: %x", resp)
+	}
+	if resp[0] != query[0] || resp[1] != query[1] {
+		t.Fatalf("response ID %x does not match query ID %x", resp[:2],
+			query[:2])
+	}
+	flags := binary.BigEndian.Uint16(resp[2:])
+	if flags&dnsFlagResponse == 0 || flags&dnsFlagAuthoritative == 0 {
+		t.Fatalf("unexpected response flags %x", flags)
+	}
+
+	var ips []net.IP
+	numAnswers := int(binary.BigEndian.Uint16(resp[6:]))
+	offset := len(query)
+	for i := 0; i < numAnswers; i++ {
+		rdLen := int(binary.BigEndian.Uint16(resp[offset+10:]))
+		ips = append(ips, net.IP(resp[offset+12:offset+12+rdLen]))
+		offset += 12 + rdLen
+	}
+	if offset != len(resp) {
+		t.Fatalf("response has %d trailing bytes", len(resp)-offset)
+	}
+	return flags & 0xf, ips
+}
+
+// TestDNSSeeder ensures the DNS seeder answers queries with the good addresses
+// advertising the queried services.
+func TestDNSSeeder(t *testing.T) {
+	ipv4 := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, 4), 8333,
+		wire.SFNodeNetwork)
+	ipv6 := wire.NewNetAddressIPPort(net.ParseIP("2001:db9::1"), 8333,
+		wire.SFNodeNetwork|wire.SFNodeCF)
+	var gotServices wire.ServiceFlag
+	seeder := NewDNSSeeder(&SeederConfig{
+		Zone: "Seed.Example.com.",
+		Port: 8333,
+		Addresses: func(services wire.ServiceFlag, port uint16, max int) []*wire.NetAddress {
+			if port != 8333 {
+				t.Errorf("Addresses: got port %d, want 8333", port)
+			}
+			gotServices = services
+			if services&wire.SFNodeCF != 0 {
+				return []*wire.NetAddress{ipv6}
+			}
+			return []*wire.NetAddress{ipv4, ipv6}
+		},
+	})
+
+	tests := []struct {
+		name     string
+		qtype    uint16
+		rcode    uint16
+		services wire.ServiceFlag
+		want     []net.IP
+	}{
+		{"seed.example.com", dnsTypeA, 0, wire.SFNodeNetwork,
+			[]net.IP{ipv4.IP.To4()}},
+		{"SEED.example.com", dnsTypeAAAA, 0, wire.SFNodeNetwork,
+			[]net.IP{ipv6.IP}},
+		{"x41.seed.example.com", dnsTypeAAAA, 0,
+			wire.SFNodeNetwork | wire.SFNodeCF, []net.IP{ipv6.IP}},
+		{"x41.seed.example.com", dnsTypeA, 0,
+			wire.SFNodeNetwork | wire.SFNodeCF, nil},
+		{"seed.example.com", 16, 0, 0, nil},
+		{"xzz.seed.example.com", dnsTypeA, dnsRcodeNameError, 0, nil},
+		{"www.seed.example.com", dnsTypeA, dnsRcodeNameError, 0, nil},
+		{"example.com", dnsTypeA, dnsRcodeRefused, 0, nil},
+	}
+	for i, test := range tests {
+		gotServices = 0
+		query := dnsQuery(uint16(i), test.name, test.qtype)
+		resp, err := seeder.handleQuery(query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		rcode, ips := dnsAnswers(t, query, resp)
+		if rcode != test.rcode {
+			t.Errorf("%s: got rcode %d, want %d", test.name, rcode,
+				test.rcode)
+		}
+		if gotServices != test.services {
+			t.Errorf("%s: got services %v, want %v", test.name,
+				gotServices, test.services)
+		}
+		if len(ips) != len(test.want) {
+			t.Errorf("%s: got addresses %v, want %v", test.name, ips,
+				test.want)
+			continue
+		}
+		for j := range ips {
+			if !ips[j].Equal(test.want[j]) {
+				t.Errorf("%s: got addresses %v, want %v",
+					test.name, ips, test.want)
+			}
+		}
+	}
+
+	// Malformed queries are not answered.
+	if _, err := seeder.handleQuery([]byte{0x01}); err == nil {
+		t.Error("handleQuery: answered truncated header")
+	}
+	truncated := dnsQuery(1, "seed.example.com", dnsTypeA)
+	resp, err := seeder.handleQuery(truncated[:20])
+	if err != nil {
+		t.Fatalf("handleQuery: unexpected error: %v", err)
+	}
+	if rcode, _ := dnsAnswers(t, truncated[:12], resp); rcode != dnsRcodeFormatError {
+		t.Errorf("handleQuery: got rcode %d for truncated question, "+
+			"want %d", rcode, dnsRcodeFormatError)
+	}
+}
+
+// TestDNSSeederServe ensures the DNS seeder answers queries received over UDP
+// and stops serving them once stopped.
+func TestDNSSeederServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: unexpected error: %v", err)
+	}
+	addr := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, 4), 8333,
+		wire.SFNodeNetwork)
+	seeder := NewDNSSeeder(&SeederConfig{
+		Zone: "seed.example.com",
+		Port: 8333,
+		Addresses: func(wire.ServiceFlag, uint16, int) []*wire.NetAddress {
+			return []*wire.NetAddress{addr}
+		},
+	})
+	seeder.Serve(conn)
+	defer seeder.Stop()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	query := dnsQuery(0x1234, "seed.example.com", dnsTypeA)
+	if _, err := client.Write(query); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxDNSMessageSize)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: unexpected error: %v", err)
+	}
+	_, ips := dnsAnswers(t, query, buf[:n])
+	if len(ips) != 1 || !ips[0].Equal(addr.IP) {
+		t.Errorf("got addresses %v, want %v", ips, addr.IP)
+	}
+	ttl := binary.BigEndian.Uint32(buf[len(query)+6:])
+	if ttl != uint32(defaultSeederTTL/time.Second) {
+		t.Errorf("got TTL %d, want %d", ttl, defaultSeederTTL/time.Second)
+	}
+}
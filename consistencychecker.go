@@ -0,0 +1,165 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+)
+
+const (
+	// consistencyCheckUndoDepth is the number of blocks at the end of the
+	// main chain whose spend journal entries are checked on each run.
+	consistencyCheckUndoDepth = 144
+
+	// consistencyCheckSamples is the maximum number of spent outputs from
+	// the spend journal entries which are verified on each run.
+	consistencyCheckSamples = 256
+)
+
+// consistencyStatus houses the results of the background consistency checks
+// performed so far.
+type consistencyStatus struct {
+	Enabled        bool
+	Interval       time.Duration
+	HaltOnMismatch bool
+	Runs           uint64
+	FailedRuns     uint64
+	MismatchRuns   uint64
+	LastRun        time.Time
+	LastDuration   time.Duration
+	LastErr        error
+	LastReport     *blockchain.ConsistencyReport
+}
+
+// consistencyChecker periodically checks the chain state against the block data
+// it was derived from in order to detect silent database corruption.  The
+// results are logged and made available through the getconsistencystatus RPC.
+type consistencyChecker struct {
+	chain          *blockchain.BlockChain
+	interval       time.Duration
+	haltOnMismatch bool
+
+	mtx    sync.Mutex
+	status consistencyStatus
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newConsistencyChecker returns a new consistency checker for the passed chain.
+// An interval of zero disables the checks.
+func newConsistencyChecker(chain *blockchain.BlockChain, interval time.Duration, haltOnMismatch bool) *consistencyChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &consistencyChecker{
+		chain:          chain,
+		interval:       interval,
+		haltOnMismatch: haltOnMismatch,
+		status: consistencyStatus{
+			Enabled:        interval > 0,
+			Interval:       interval,
+			HaltOnMismatch: haltOnMismatch,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins the periodic checks when they are enabled.
+func (c *consistencyChecker) Start() {
+	if c.interval <= 0 {
+		return
+	}
+
+	srvrLog.Infof("Checking chain state consistency every %v", c.interval)
+	c.wg.Add(1)
+	go c.checkHandler()
+}
+
+// Stop aborts any check in progress and waits for the checker to exit.
+func (c *consistencyChecker) Stop() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+// Status returns a snapshot of the results of the checks performed so far.
+//
+// This function is safe for concurrent access.
+func (c *consistencyChecker) Status() consistencyStatus {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.status
+}
+
+// checkHandler runs the checks every interval until the checker is stopped or a
+// mismatch causes the node to be halted.  It must be run as a goroutine.
+func (c *consistencyChecker) checkHandler() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !c.check() && c.haltOnMismatch {
+				srvrLog.Criticalf("Shutting down due to chain " +
+					"state inconsistency")
+				shutdownRequestChannel <- struct{}{}
+				return
+			}
+
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// check performs a single run of the checks, logs and records the results, and
+// returns false when an inconsistency was detected.
+func (c *consistencyChecker) check() bool {
+	start := time.Now()
+	report, err := c.chain.CheckConsistency(c.ctx,
+		consistencyCheckUndoDepth, consistencyCheckSamples)
+	duration := time.Since(start)
+	if err == context.Canceled {
+		return true
+	}
+
+	c.mtx.Lock()
+	c.status.Runs++
+	c.status.LastRun = start
+	c.status.LastDuration = duration
+	c.status.LastErr = err
+	if err != nil {
+		c.status.FailedRuns++
+	} else {
+		c.status.LastReport = report
+		if !report.Consistent() {
+			c.status.MismatchRuns++
+		}
+	}
+	c.mtx.Unlock()
+
+	if err != nil {
+		srvrLog.Warnf("Unable to check chain state consistency: %v", err)
+		return true
+	}
+	if !report.Consistent() {
+		for _, mismatch := range report.Mismatches {
+			srvrLog.Errorf("Chain state inconsistency at height "+
+				"%d: %s", report.Height, mismatch)
+		}
+		return false
+	}
+
+	srvrLog.Debugf("Chain state is consistent at height %d (hash %v, "+
+		"%d undo blocks, %d sampled outputs, took %v)", report.Height,
+		report.Hash, report.UndoBlocks, report.SampledOutputs, duration)
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bitgo/prova/database"
+	"github.com/dgraph-io/badger"
+)
+
+// Enforce transaction implements the database.Backuper interface.
+var _ database.Backuper = (*transaction)(nil)
+
+// Backup writes a copy of the database, as seen by the transaction, to the
+// passed directory, which must not exist yet.  Every key in the snapshot of the
+// transaction, including the blocks, is copied into a new Badger database, so
+// the backup leaves out the stale values the source still keeps in its value
+// log.
+//
+// This function is part of the database.Backuper interface implementation.
+func (tx *transaction) Backup(destPath string) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		str := fmt.Sprintf("backup destination %q already exists",
+			destPath)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+	if err := os.MkdirAll(destPath, 0700); err != nil {
+		str := fmt.Sprintf("unable to create backup destination %q: %v",
+			destPath, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	bdb, err := badger.Open(badgerOptions(destPath))
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+	batch := bdb.NewWriteBatch()
+	iter := tx.snapshot.NewIterator(badger.DefaultIteratorOptions)
+	var numKeys int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		item := iter.Item()
+		value, err := item.ValueCopy(nil)
+		if err == nil {
+			err = batch.Set(item.KeyCopy(nil), value)
+		}
+		if err != nil {
+			iter.Close()
+			batch.Cancel()
+			bdb.Close()
+			return convertErr(err.Error(), err)
+		}
+		numKeys++
+	}
+	iter.Close()
+	if err := batch.Flush(); err != nil {
+		bdb.Close()
+		return convertErr(err.Error(), err)
+	}
+	if err := bdb.Close(); err != nil {
+		return convertErr(err.Error(), err)
+	}
+
+	log.Infof("Backed up the database to %s (%d keys)", destPath, numKeys)
+	return nil
+}
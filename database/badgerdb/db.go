@@ -0,0 +1,1979 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/database/internal/treap"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	// blockHdrSize is the size of a block header.  This is simply the
+	// constant from wire and is only provided here for convenience since
+	// wire.MaxBlockHeaderPayload is quite long.
+	blockHdrSize = wire.MaxBlockHeaderPayload
+
+	// valueThreshold is the size from which values are stored in the
+	// value log of Badger rather than in its LSM tree.  It keeps the
+	// metadata in the LSM tree while the blocks go to the value log.
+	valueThreshold = 1024
+)
+
+var (
+	// byteOrder is the preferred byte order used through the database.
+	// Sometimes big endian will be used to allow ordered byte sortable
+	// integer values.
+	byteOrder = binary.LittleEndian
+
+	// bucketIndexPrefix is the prefix used for all entries in the bucket
+	// index.
+	bucketIndexPrefix = []byte("bidx")
+
+	// curBucketIDKeyName is the name of the key used to keep track of the
+	// current bucket ID counter.
+	curBucketIDKeyName = []byte("bidx-cbid")
+
+	// metadataBucketID is the ID of the top-level metadata bucket.
+	// It is the value 0 encoded as an unsigned big-endian uint32.
+	metadataBucketID = [4]byte{}
+
+	// blockIdxBucketID is the ID of the internal bucket which maps the
+	// hashes of the blocks to their headers.  It is the value 1 encoded as
+	// an unsigned big-endian uint32.
+	blockIdxBucketID = [4]byte{0x00, 0x00, 0x00, 0x01}
+
+	// blockIdxBucketName is the name of the internal block header bucket.
+	blockIdxBucketName = []byte("badgerdb-blockidx")
+
+	// blocksBucketID is the ID of the internal bucket which maps the hashes
+	// of the blocks to the serialized blocks.  It is the value 2 encoded as
+	// an unsigned big-endian uint32.
+	blocksBucketID = [4]byte{0x00, 0x00, 0x00, 0x02}
+
+	// blocksBucketName is the name of the internal block bucket.
+	blocksBucketName = []byte("badgerdb-blocks")
+
+	// networkKeyName is the key used to store the block network the
+	// database was created for.
+	networkKeyName = []byte("badgerdb-network")
+)
+
+// Common error strings.
+const (
+	// errDbNotOpenStr is the text to use for the database.ErrDbNotOpen
+	// error code.
+	errDbNotOpenStr = "database is not open"
+
+	// errTxClosedStr is the text to use for the database.ErrTxClosed error
+	// code.
+	errTxClosedStr = "database tx is closed"
+)
+
+// makeDbErr creates a database.Error given a set of arguments.
+func makeDbErr(c database.ErrorCode, desc string, err error) database.Error {
+	return database.Error{ErrorCode: c, Description: desc, Err: err}
+}
+
+// convertErr converts the passed Badger error into a database error with an
+// equivalent error code and the passed description.  It also sets the passed
+// error as the underlying error.
+func convertErr(desc string, bdbErr error) database.Error {
+	// Use the driver-specific error code by default.  The code below will
+	// update this with the converted error if it's recognized.
+	var code = database.ErrDriverSpecific
+
+	switch bdbErr {
+	// Database corruption errors.
+	case badger.ErrTruncateNeeded:
+		code = database.ErrCorruption
+
+	// Transaction errors.
+	case badger.ErrDiscardedTxn:
+		code = database.ErrTxClosed
+	}
+
+	return database.Error{ErrorCode: code, Description: desc, Err: bdbErr}
+}
+
+// copySlice returns a copy of the passed slice.
+func copySlice(slice []byte) []byte {
+	ret := make([]byte, len(slice))
+	copy(ret, slice)
+	return ret
+}
+
+// cursor is an internal type used to represent a cursor over key/value pairs
+// and nested buckets of a bucket and implements the database.Cursor interface.
+//
+// The cursor merges iterators over the snapshot of the transaction with
+// iterators over its pending keys, skipping the keys of the snapshot which the
+// transaction updated or deleted.
+type cursor struct {
+	bucket      *bucket
+	dbIters     []iterator
+	iters       []iterator
+	currentIter iterator
+	forwards    bool
+}
+
+// Enforce cursor implements the database.Cursor interface.
+var _ database.Cursor = (*cursor)(nil)
+
+// Bucket returns the bucket the cursor was created for.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Bucket() database.Bucket {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	return c.bucket
+}
+
+// Delete removes the current key/value pair the cursor is at without
+// invalidating the cursor.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrIncompatibleValue if attempted when the cursor points to a nested
+//     bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Delete() error {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !c.bucket.tx.writable {
+		str := "deleting a value requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Error if the cursor is exhausted.
+	if c.currentIter == nil {
+		str := "cursor is exhausted"
+		return makeDbErr(database.ErrIncompatibleValue, str, nil)
+	}
+
+	// Do not allow buckets to be deleted via the cursor.
+	key := c.currentIter.Key()
+	if bytes.HasPrefix(key, bucketIndexPrefix) {
+		str := "buckets may not be deleted from a cursor"
+		return makeDbErr(database.ErrIncompatibleValue, str, nil)
+	}
+
+	c.bucket.tx.deleteKey(copySlice(key), true)
+	return nil
+}
+
+// skipPendingUpdates skips any keys at the current position of the passed
+// database iterator that are being updated by the transaction.  The forwards
+// flag indicates the direction the cursor is moving.
+func (c *cursor) skipPendingUpdates(iter iterator, forwards bool) {
+	for iter.Valid() {
+		key := iter.Key()
+		if !c.bucket.tx.pendingRemove.Has(key) &&
+			!c.bucket.tx.pendingKeys.Has(key) {
+
+			break
+		}
+
+		if forwards {
+			iter.Next()
+		} else {
+			iter.Prev()
+		}
+	}
+}
+
+// chooseIterator first skips any entries in the database iterators that are
+// being updated by the transaction and sets the current iterator to the valid
+// iterator with the smallest key when the cursor is being moved forwards, or
+// the largest key when it is being moved backwards.
+func (c *cursor) chooseIterator(forwards bool) bool {
+	for _, iter := range c.dbIters {
+		c.skipPendingUpdates(iter, forwards)
+	}
+
+	c.forwards = forwards
+	c.currentIter = nil
+	for _, iter := range c.iters {
+		if !iter.Valid() {
+			continue
+		}
+		if c.currentIter == nil {
+			c.currentIter = iter
+			continue
+		}
+		compare := bytes.Compare(iter.Key(), c.currentIter.Key())
+		if (forwards && compare < 0) || (!forwards && compare > 0) {
+			c.currentIter = iter
+		}
+	}
+	return c.currentIter != nil
+}
+
+// First positions the cursor at the first key/value pair and returns whether or
+// not the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) First() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return false
+	}
+
+	for _, iter := range c.iters {
+		iter.First()
+	}
+	return c.chooseIterator(true)
+}
+
+// Last positions the cursor at the last key/value pair and returns whether or
+// not the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Last() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return false
+	}
+
+	for _, iter := range c.iters {
+		iter.Last()
+	}
+	return c.chooseIterator(false)
+}
+
+// Next moves the cursor one key/value pair forward and returns whether or not
+// the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Next() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return false
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return false
+	}
+
+	// The other iterators point before the current key when the cursor
+	// was moving backwards, so move them after it.
+	if !c.forwards {
+		key := copySlice(c.currentIter.Key())
+		for _, iter := range c.iters {
+			if iter == c.currentIter {
+				continue
+			}
+			if iter.Seek(key) && bytes.Equal(iter.Key(), key) {
+				iter.Next()
+			}
+		}
+	}
+
+	c.currentIter.Next()
+	return c.chooseIterator(true)
+}
+
+// Prev moves the cursor one key/value pair backward and returns whether or not
+// the pair exists.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Prev() bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return false
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return false
+	}
+
+	// The other iterators point after the current key when the cursor was
+	// moving forwards, so move them before it.
+	if c.forwards {
+		key := copySlice(c.currentIter.Key())
+		for _, iter := range c.iters {
+			if iter == c.currentIter {
+				continue
+			}
+			if iter.Seek(key) {
+				iter.Prev()
+			} else {
+				iter.Last()
+			}
+		}
+	}
+
+	c.currentIter.Prev()
+	return c.chooseIterator(false)
+}
+
+// Seek positions the cursor at the first key/value pair that is greater than or
+// equal to the passed seek key.  Returns false if no suitable key was found.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Seek(seek []byte) bool {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return false
+	}
+
+	seekKey := bucketizedKey(c.bucket.id, seek)
+	for _, iter := range c.iters {
+		iter.Seek(seekKey)
+	}
+	return c.chooseIterator(true)
+}
+
+// rawKey returns the current key the cursor is pointing to without stripping
+// the current bucket prefix or bucket index prefix.
+func (c *cursor) rawKey() []byte {
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return nil
+	}
+
+	return copySlice(c.currentIter.Key())
+}
+
+// Key returns the current key the cursor is pointing to.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Key() []byte {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return nil
+	}
+
+	// The key is after the bucket index prefix and parent ID when the
+	// cursor is pointing to a nested bucket, and after the bucket ID when
+	// it is pointing to a normal entry.
+	key := c.currentIter.Key()
+	if bytes.HasPrefix(key, bucketIndexPrefix) {
+		return copySlice(key[len(bucketIndexPrefix)+4:])
+	}
+	return copySlice(key[len(c.bucket.id):])
+}
+
+// rawValue returns the current value the cursor is pointing to without
+// filtering bucket index values.
+func (c *cursor) rawValue() []byte {
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return nil
+	}
+
+	return copySlice(c.currentIter.Value())
+}
+
+// Value returns the current value the cursor is pointing to.  This will be nil
+// for nested buckets.
+//
+// This function is part of the database.Cursor interface implementation.
+func (c *cursor) Value() []byte {
+	// Ensure transaction state is valid.
+	if err := c.bucket.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	// Nothing to return if cursor is exhausted.
+	if c.currentIter == nil {
+		return nil
+	}
+
+	// Return nil for the value when the cursor is pointing to a nested
+	// bucket.
+	if bytes.HasPrefix(c.currentIter.Key(), bucketIndexPrefix) {
+		return nil
+	}
+
+	return copySlice(c.currentIter.Value())
+}
+
+// cursorType defines the type of cursor to create.
+type cursorType int
+
+// The following constants define the allowed cursor types.
+const (
+	// ctKeys iterates through all of the keys in a given bucket.
+	ctKeys cursorType = iota
+
+	// ctBuckets iterates through all directly nested buckets in a given
+	// bucket.
+	ctBuckets
+
+	// ctFull iterates through both the keys and the directly nested buckets
+	// in a given bucket.
+	ctFull
+)
+
+// cursorFinalizer is either invoked when a cursor is being garbage collected or
+// called manually to ensure the underlying cursor iterators are released.
+func cursorFinalizer(c *cursor) {
+	for _, iter := range c.iters {
+		iter.Release()
+	}
+}
+
+// newCursor returns a new cursor for the given bucket, bucket ID, and cursor
+// type.
+//
+// NOTE: The caller is responsible for calling the cursorFinalizer function on
+// the returned cursor.
+func newCursor(b *bucket, bucketID []byte, cursorTyp cursorType) *cursor {
+	// The serialized bucket index key format is:
+	//   <bucketindexprefix><parentbucketid><bucketname>
+	bucketPrefix := make([]byte, len(bucketIndexPrefix)+4)
+	copy(bucketPrefix, bucketIndexPrefix)
+	copy(bucketPrefix[len(bucketIndexPrefix):], bucketID)
+
+	var prefixes [][]byte
+	switch cursorTyp {
+	case ctKeys:
+		prefixes = [][]byte{bucketID}
+	case ctBuckets:
+		prefixes = [][]byte{bucketPrefix}
+	case ctFull:
+		fallthrough
+	default:
+		prefixes = [][]byte{bucketID, bucketPrefix}
+	}
+
+	// Create an iterator over both the snapshot and the pending keys for
+	// each of the prefixes.
+	c := &cursor{bucket: b}
+	for _, prefix := range prefixes {
+		iter := newSnapshotIter(b.tx.snapshot, prefix)
+		c.dbIters = append(c.dbIters, iter)
+		c.iters = append(c.iters, iter)
+	}
+	for _, prefix := range prefixes {
+		c.iters = append(c.iters, newPendingIter(b.tx, prefix))
+	}
+	return c
+}
+
+// bucket is an internal type used to represent a collection of key/value pairs
+// and implements the database.Bucket interface.
+type bucket struct {
+	tx *transaction
+	id [4]byte
+}
+
+// Enforce bucket implements the database.Bucket interface.
+var _ database.Bucket = (*bucket)(nil)
+
+// bucketIndexKey returns the actual key to use for storing and retrieving a
+// child bucket in the bucket index.  This is required because additional
+// information is needed to distinguish nested buckets with the same name.
+func bucketIndexKey(parentID [4]byte, key []byte) []byte {
+	// The serialized bucket index key format is:
+	//   <bucketindexprefix><parentbucketid><bucketname>
+	indexKey := make([]byte, len(bucketIndexPrefix)+4+len(key))
+	copy(indexKey, bucketIndexPrefix)
+	copy(indexKey[len(bucketIndexPrefix):], parentID[:])
+	copy(indexKey[len(bucketIndexPrefix)+4:], key)
+	return indexKey
+}
+
+// bucketizedKey returns the actual key to use for storing and retrieving a key
+// for the provided bucket ID.  This is required because bucketizing is handled
+// through the use of a unique prefix per bucket.
+func bucketizedKey(bucketID [4]byte, key []byte) []byte {
+	// The serialized block index key format is:
+	//   <bucketid><key>
+	bKey := make([]byte, 4+len(key))
+	copy(bKey, bucketID[:])
+	copy(bKey[4:], key)
+	return bKey
+}
+
+// Bucket retrieves a nested bucket with the given key.  Returns nil if
+// the bucket does not exist.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Bucket(key []byte) database.Bucket {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	// Attempt to fetch the ID for the child bucket.  The bucket does not
+	// exist if the bucket index entry does not exist.
+	childID := b.tx.fetchKey(bucketIndexKey(b.id, key))
+	if childID == nil {
+		return nil
+	}
+
+	childBucket := &bucket{tx: b.tx}
+	copy(childBucket.id[:], childID)
+	return childBucket
+}
+
+// CreateBucket creates and returns a new nested bucket with the given key.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketExists if the bucket already exists
+//   - ErrBucketNameRequired if the key is empty
+//   - ErrIncompatibleValue if the key is otherwise invalid for the particular
+//     implementation
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) CreateBucket(key []byte) (database.Bucket, error) {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "create bucket requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Ensure a key was provided.
+	if len(key) == 0 {
+		str := "create bucket requires a key"
+		return nil, makeDbErr(database.ErrBucketNameRequired, str, nil)
+	}
+
+	// Ensure bucket does not already exist.
+	bidxKey := bucketIndexKey(b.id, key)
+	if b.tx.hasKey(bidxKey) {
+		str := "bucket already exists"
+		return nil, makeDbErr(database.ErrBucketExists, str, nil)
+	}
+
+	// Find the appropriate next bucket ID to use for the new bucket.  In
+	// the case of the special internal block buckets, keep the fixed IDs.
+	var childID [4]byte
+	switch {
+	case b.id == metadataBucketID && bytes.Equal(key, blockIdxBucketName):
+		childID = blockIdxBucketID
+	case b.id == metadataBucketID && bytes.Equal(key, blocksBucketName):
+		childID = blocksBucketID
+	default:
+		childID = b.tx.nextBucketID()
+	}
+
+	// Add the new bucket to the bucket index.
+	b.tx.putKey(bidxKey, childID[:])
+	return &bucket{tx: b.tx, id: childID}, nil
+}
+
+// CreateBucketIfNotExists creates and returns a new nested bucket with the
+// given key if it does not already exist.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketNameRequired if the key is empty
+//   - ErrIncompatibleValue if the key is otherwise invalid for the particular
+//     implementation
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) CreateBucketIfNotExists(key []byte) (database.Bucket, error) {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "create bucket requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Return existing bucket if it already exists, otherwise create it.
+	if bucket := b.Bucket(key); bucket != nil {
+		return bucket, nil
+	}
+	return b.CreateBucket(key)
+}
+
+// DeleteBucket removes a nested bucket with the given key.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBucketNotFound if the specified bucket does not exist
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) DeleteBucket(key []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "delete bucket requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Attempt to fetch the ID for the child bucket.  The bucket does not
+	// exist if the bucket index entry does not exist.
+	bidxKey := bucketIndexKey(b.id, key)
+	childID := b.tx.fetchKey(bidxKey)
+	if childID == nil {
+		str := fmt.Sprintf("bucket %q does not exist", key)
+		return makeDbErr(database.ErrBucketNotFound, str, nil)
+	}
+
+	// Remove all nested buckets and their keys.
+	childIDs := [][]byte{childID}
+	for len(childIDs) > 0 {
+		childID = childIDs[len(childIDs)-1]
+		childIDs = childIDs[:len(childIDs)-1]
+
+		// Delete all keys in the nested bucket.
+		keyCursor := newCursor(b, childID, ctKeys)
+		for ok := keyCursor.First(); ok; ok = keyCursor.Next() {
+			b.tx.deleteKey(keyCursor.rawKey(), false)
+		}
+		cursorFinalizer(keyCursor)
+
+		// Iterate through all nested buckets.
+		bucketCursor := newCursor(b, childID, ctBuckets)
+		for ok := bucketCursor.First(); ok; ok = bucketCursor.Next() {
+			// Push the id of the nested bucket onto the stack for
+			// the next iteration.
+			childIDs = append(childIDs, bucketCursor.rawValue())
+
+			// Remove the nested bucket from the bucket index.
+			b.tx.deleteKey(bucketCursor.rawKey(), false)
+		}
+		cursorFinalizer(bucketCursor)
+	}
+
+	// Remove the nested bucket from the bucket index.  Any buckets nested
+	// under it were already removed above.
+	b.tx.deleteKey(bidxKey, true)
+	return nil
+}
+
+// Cursor returns a new cursor, allowing for iteration over the bucket's
+// key/value pairs and nested buckets in forward or backward order.
+//
+// You must seek to a position using the First, Last, or Seek functions before
+// calling the Next, Prev, Key, or Value functions.  Failure to do so will
+// result in the same return values as an exhausted cursor, which is false for
+// the Prev and Next functions and nil for Key and Value functions.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Cursor() database.Cursor {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return &cursor{bucket: b}
+	}
+
+	// Create the cursor and setup a runtime finalizer to ensure the
+	// iterators are released when the cursor is garbage collected.  The
+	// iterators over the snapshot are also released when the transaction
+	// is closed, since Badger requires that before discarding it.
+	c := newCursor(b, b.id[:], ctFull)
+	b.tx.addCursor(c)
+	runtime.SetFinalizer(c, cursorFinalizer)
+	return c
+}
+
+// ForEach invokes the passed function with every key/value pair in the bucket.
+// This does not include nested buckets or the key/value pairs within those
+// nested buckets.
+//
+// WARNING: It is not safe to mutate data while iterating with this method.
+// Doing so may cause the underlying cursor to be invalidated and return
+// unexpected keys and/or values.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// NOTE: The values returned by this function are only valid during a
+// transaction.  Attempting to access them after a transaction has ended will
+// likely result in an access violation.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) ForEach(fn func(k, v []byte) error) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Invoke the callback for each cursor item.  Return the error returned
+	// from the callback when it is non-nil.
+	c := newCursor(b, b.id[:], ctKeys)
+	defer cursorFinalizer(c)
+	for ok := c.First(); ok; ok = c.Next() {
+		err := fn(c.Key(), c.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachBucket invokes the passed function with the key of every nested bucket
+// in the current bucket.  This does not include any nested buckets within those
+// nested buckets.
+//
+// WARNING: It is not safe to mutate data while iterating with this method.
+// Doing so may cause the underlying cursor to be invalidated and return
+// unexpected keys.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// NOTE: The values returned by this function are only valid during a
+// transaction.  Attempting to access them after a transaction has ended will
+// likely result in an access violation.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) ForEachBucket(fn func(k []byte) error) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Invoke the callback for each cursor item.  Return the error returned
+	// from the callback when it is non-nil.
+	c := newCursor(b, b.id[:], ctBuckets)
+	defer cursorFinalizer(c)
+	for ok := c.First(); ok; ok = c.Next() {
+		err := fn(c.Key())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writable returns whether or not the bucket is writable.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Writable() bool {
+	return b.tx.writable
+}
+
+// Put saves the specified key/value pair to the bucket.  Keys that do not
+// already exist are added and keys that already exist are overwritten.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrKeyRequired if the key is empty
+//   - ErrIncompatibleValue if the key is the same as an existing bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Put(key, value []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "setting a key requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Ensure a key was provided.
+	if len(key) == 0 {
+		str := "put requires a key"
+		return makeDbErr(database.ErrKeyRequired, str, nil)
+	}
+
+	b.tx.putKey(bucketizedKey(b.id, key), value)
+	return nil
+}
+
+// Get returns the value for the given key.  Returns nil if the key does not
+// exist in this bucket.  An empty slice is returned for keys that exist but
+// have no value assigned.
+//
+// NOTE: The value returned by this function is only valid during a transaction.
+// Attempting to access it after a transaction has ended results in undefined
+// behavior.  Additionally, the value must NOT be modified by the caller.
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Get(key []byte) []byte {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return nil
+	}
+
+	// Nothing to return if there is no key.
+	if len(key) == 0 {
+		return nil
+	}
+
+	return b.tx.fetchKey(bucketizedKey(b.id, key))
+}
+
+// Delete removes the specified key from the bucket.  Deleting a key that does
+// not exist does not return an error.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrKeyRequired if the key is empty
+//   - ErrIncompatibleValue if the key is the same as an existing bucket
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Bucket interface implementation.
+func (b *bucket) Delete(key []byte) error {
+	// Ensure transaction state is valid.
+	if err := b.tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !b.tx.writable {
+		str := "deleting a value requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Nothing to do if there is no key.
+	if len(key) == 0 {
+		return nil
+	}
+
+	b.tx.deleteKey(bucketizedKey(b.id, key), true)
+	return nil
+}
+
+// pendingBlock houses a block that will be written to the database when the
+// transaction is committed.
+type pendingBlock struct {
+	hash  *chainhash.Hash
+	bytes []byte
+}
+
+// transaction represents a database transaction.  It can either be read-only or
+// read-write and implements the database.Bucket interface.  The transaction
+// provides a root bucket against which all read and writes occur.
+//
+// All reads are served from a read-only Badger transaction, which provides a
+// consistent snapshot of the database, while the changes of the transaction
+// are kept in memory until they are written to the database in a single Badger
+// transaction on commit.
+type transaction struct {
+	managed    bool        // Is the transaction managed?
+	closed     bool        // Is the transaction closed?
+	writable   bool        // Is the transaction writable?
+	db         *db         // DB instance the tx was created from.
+	snapshot   *badger.Txn // Underlying snapshot for txns.
+	metaBucket *bucket     // The root metadata bucket.
+
+	// Blocks that need to be stored on commit.  The pendingBlocks map is
+	// kept to allow quick lookups of pending data by block hash.
+	pendingBlocks    map[chainhash.Hash]int
+	pendingBlockData []pendingBlock
+
+	// Keys that need to be stored or deleted on commit.
+	pendingKeys   *treap.Mutable
+	pendingRemove *treap.Mutable
+
+	// Active iterators that need to be notified when the pending keys have
+	// been updated so the cursors can properly handle updates to the
+	// transaction state.
+	activeIterLock sync.RWMutex
+	activeIters    []*treap.Iterator
+
+	// Cursors handed out by the transaction, whose iterators have to be
+	// released before the snapshot is discarded.
+	cursors []*cursor
+}
+
+// Enforce transaction implements the database.Tx interface.
+var _ database.Tx = (*transaction)(nil)
+
+// removeActiveIter removes the passed iterator from the list of active
+// iterators against the pending keys treap.
+func (tx *transaction) removeActiveIter(iter *treap.Iterator) {
+	// An indexing for loop is intentionally used over a range here as range
+	// does not reevaluate the slice on each iteration nor does it adjust
+	// the index for the modified slice.
+	tx.activeIterLock.Lock()
+	for i := 0; i < len(tx.activeIters); i++ {
+		if tx.activeIters[i] == iter {
+			copy(tx.activeIters[i:], tx.activeIters[i+1:])
+			tx.activeIters[len(tx.activeIters)-1] = nil
+			tx.activeIters = tx.activeIters[:len(tx.activeIters)-1]
+		}
+	}
+	tx.activeIterLock.Unlock()
+}
+
+// addActiveIter adds the passed iterator to the list of active iterators for
+// the pending keys treap.
+func (tx *transaction) addActiveIter(iter *treap.Iterator) {
+	tx.activeIterLock.Lock()
+	tx.activeIters = append(tx.activeIters, iter)
+	tx.activeIterLock.Unlock()
+}
+
+// notifyActiveIters notifies all of the active iterators for the pending keys
+// treap that it has been updated.
+func (tx *transaction) notifyActiveIters() {
+	tx.activeIterLock.RLock()
+	for _, iter := range tx.activeIters {
+		iter.ForceReseek()
+	}
+	tx.activeIterLock.RUnlock()
+}
+
+// addCursor adds the passed cursor to the cursors which are released when the
+// transaction is closed.
+func (tx *transaction) addCursor(c *cursor) {
+	tx.activeIterLock.Lock()
+	tx.cursors = append(tx.cursors, c)
+	tx.activeIterLock.Unlock()
+}
+
+// checkClosed returns an error if the the database or transaction is closed.
+func (tx *transaction) checkClosed() error {
+	// The transaction is no longer valid if it has been closed.
+	if tx.closed {
+		return makeDbErr(database.ErrTxClosed, errTxClosedStr, nil)
+	}
+
+	return nil
+}
+
+// snapshotGet returns the value of the provided key in the snapshot of the
+// transaction, or nil if it does not exist.  An empty slice is returned for
+// keys that exist but have no value.
+func (tx *transaction) snapshotGet(key []byte) ([]byte, error) {
+	item, err := tx.snapshot.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to read key %x", key)
+		return nil, convertErr(str, err)
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		str := fmt.Sprintf("failed to read the value of key %x", key)
+		return nil, convertErr(str, err)
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+// hasKey returns whether or not the provided key exists in the database while
+// taking into account the current transaction state.
+func (tx *transaction) hasKey(key []byte) bool {
+	// When the transaction is writable, check the pending transaction
+	// state first.
+	if tx.writable {
+		if tx.pendingRemove.Has(key) {
+			return false
+		}
+		if tx.pendingKeys.Has(key) {
+			return true
+		}
+	}
+
+	// Consult the snapshot.  The value is not needed, so it isn't read
+	// from the value log.
+	_, err := tx.snapshot.Get(key)
+	return err == nil
+}
+
+// putKey adds the provided key to the list of keys to be updated in the
+// database when the transaction is committed.
+//
+// NOTE: This function must only be called on a writable transaction.  Since it
+// is an internal helper function, it does not check.
+func (tx *transaction) putKey(key, value []byte) {
+	// Prevent the key from being deleted if it was previously scheduled
+	// to be deleted on transaction commit.
+	tx.pendingRemove.Delete(key)
+
+	// Add the key/value pair to the list to be written on transaction
+	// commit.  Keys without a value are stored with an empty value, so
+	// they can be told apart from keys which don't exist.
+	if value == nil {
+		value = []byte{}
+	}
+	tx.pendingKeys.Put(key, value)
+	tx.notifyActiveIters()
+}
+
+// fetchKey attempts to fetch the provided key from the database while taking
+// into account the current transaction state.  Returns nil if the key does not
+// exist.
+func (tx *transaction) fetchKey(key []byte) []byte {
+	// When the transaction is writable, check the pending transaction
+	// state first.
+	if tx.writable {
+		if tx.pendingRemove.Has(key) {
+			return nil
+		}
+		if value := tx.pendingKeys.Get(key); value != nil {
+			return value
+		}
+	}
+
+	tx.db.metrics.metadataRead()
+	value, err := tx.snapshotGet(key)
+	if err != nil {
+		log.Errorf("Unable to fetch key %x: %v", key, err)
+		return nil
+	}
+	return value
+}
+
+// deleteKey adds the provided key to the list of keys to be deleted from the
+// database when the transaction is committed.  The notify iterators flag is
+// useful to delay notifying iterators about the changes during bulk deletes.
+//
+// NOTE: This function must only be called on a writable transaction.  Since it
+// is an internal helper function, it does not check.
+func (tx *transaction) deleteKey(key []byte, notifyIterators bool) {
+	// Remove the key from the list of pendings keys to be written on
+	// transaction commit if needed.
+	tx.pendingKeys.Delete(key)
+
+	// Add the key to the list to be deleted on transaction commit.
+	tx.pendingRemove.Put(key, nil)
+
+	// Notify the active iterators about the change if the flag is set.
+	if notifyIterators {
+		tx.notifyActiveIters()
+	}
+}
+
+// nextBucketID returns the next bucket ID to use for creating a new bucket.
+//
+// NOTE: This function must only be called on a writable transaction.  Since it
+// is an internal helper function, it does not check.
+func (tx *transaction) nextBucketID() [4]byte {
+	// Load the currently highest used bucket ID.
+	curIDBytes := tx.fetchKey(curBucketIDKeyName)
+	curBucketNum := binary.BigEndian.Uint32(curIDBytes)
+
+	// Increment and update the current bucket ID and return it.
+	var nextBucketID [4]byte
+	binary.BigEndian.PutUint32(nextBucketID[:], curBucketNum+1)
+	tx.putKey(curBucketIDKeyName, nextBucketID[:])
+	return nextBucketID
+}
+
+// Metadata returns the top-most bucket for all metadata storage.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Metadata() database.Bucket {
+	return tx.metaBucket
+}
+
+// hasBlock returns whether or not a block with the given hash exists.
+func (tx *transaction) hasBlock(hash *chainhash.Hash) bool {
+	// Return true if the block is pending to be written on commit since
+	// it exists from the viewpoint of this transaction.
+	if _, exists := tx.pendingBlocks[*hash]; exists {
+		return true
+	}
+
+	return tx.hasKey(bucketizedKey(blockIdxBucketID, hash[:]))
+}
+
+// StoreBlock stores the provided block into the database.  There are no checks
+// to ensure the block connects to a previous block, contains double spends, or
+// any additional functionality such as transaction indexing.  It simply stores
+// the block in the database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockExists when the block hash already exists
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) StoreBlock(block *provautil.Block) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "store block requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Reject the block if it already exists.
+	blockHash := block.Hash()
+	if tx.hasBlock(blockHash) {
+		str := fmt.Sprintf("block %s already exists", blockHash)
+		return makeDbErr(database.ErrBlockExists, str, nil)
+	}
+
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		str := fmt.Sprintf("failed to get serialized bytes for block %s",
+			blockHash)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	// Add the block to be stored to the list of pending blocks to store
+	// when the transaction is committed.  Also, add it to pending blocks
+	// map so it is easy to determine the block is pending based on the
+	// block hash.
+	if tx.pendingBlocks == nil {
+		tx.pendingBlocks = make(map[chainhash.Hash]int)
+	}
+	tx.pendingBlocks[*blockHash] = len(tx.pendingBlockData)
+	tx.pendingBlockData = append(tx.pendingBlockData, pendingBlock{
+		hash:  blockHash,
+		bytes: blockBytes,
+	})
+	log.Tracef("Added block %s to pending blocks", blockHash)
+
+	return nil
+}
+
+// HasBlock returns whether or not a block with the given hash exists in the
+// database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) HasBlock(hash *chainhash.Hash) (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	return tx.hasBlock(hash), nil
+}
+
+// HasBlocks returns whether or not the blocks with the provided hashes
+// exist in the database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) HasBlocks(hashes []chainhash.Hash) ([]bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	results := make([]bool, len(hashes))
+	for i := range hashes {
+		results[i] = tx.hasBlock(&hashes[i])
+	}
+
+	return results, nil
+}
+
+// fetchBlockHeader returns the header of the block with the provided hash,
+// taking into account the pending blocks.  It will return ErrBlockNotFound if
+// there is no such block.
+func (tx *transaction) fetchBlockHeader(hash *chainhash.Hash) ([]byte, error) {
+	// When the block is pending to be written on commit return the bytes
+	// from there.  Notice the use of the cap on the subslice to prevent
+	// the caller from accidentally appending into the block data.
+	if idx, exists := tx.pendingBlocks[*hash]; exists {
+		blockBytes := tx.pendingBlockData[idx].bytes
+		return blockBytes[0:blockHdrSize:blockHdrSize], nil
+	}
+
+	header, err := tx.snapshotGet(bucketizedKey(blockIdxBucketID, hash[:]))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		str := fmt.Sprintf("block %s does not exist", hash)
+		return nil, makeDbErr(database.ErrBlockNotFound, str, nil)
+	}
+	return header, nil
+}
+
+// FetchBlockHeader returns the raw serialized bytes for the block header
+// identified by the given hash.  The raw bytes are in the format returned by
+// Serialize on a wire.BlockHeader.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a
+// database transaction.  Attempting to access it after a transaction
+// has ended results in undefined behavior.  This constraint prevents
+// additional data copies and allows support for memory-mapped database
+// implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockHeader(hash *chainhash.Hash) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return tx.fetchBlockHeader(hash)
+}
+
+// FetchBlockHeaders returns the raw serialized bytes for the block headers
+// identified by the given hashes.  The raw bytes are in the format returned by
+// Serialize on a wire.BlockHeader.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the any of the requested block hashes do not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockHeaders(hashes []chainhash.Hash) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	headers := make([][]byte, len(hashes))
+	for i := range hashes {
+		var err error
+		headers[i], err = tx.fetchBlockHeader(&hashes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return headers, nil
+}
+
+// fetchStoredBlock calls the passed function with the serialized bytes of the
+// stored block with the provided hash, which are only valid during the call.
+// It will return ErrBlockNotFound if there is no such block.
+func (tx *transaction) fetchStoredBlock(hash *chainhash.Hash, fn func([]byte) error) error {
+	item, err := tx.snapshot.Get(bucketizedKey(blocksBucketID, hash[:]))
+	if err == badger.ErrKeyNotFound {
+		str := fmt.Sprintf("block %s does not exist", hash)
+		return makeDbErr(database.ErrBlockNotFound, str, nil)
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to read block %s", hash)
+		return convertErr(str, err)
+	}
+
+	start := time.Now()
+	var numBytes int
+	err = item.Value(func(blockBytes []byte) error {
+		numBytes = len(blockBytes)
+		return fn(blockBytes)
+	})
+	if err != nil {
+		if _, ok := err.(database.Error); ok {
+			return err
+		}
+		str := fmt.Sprintf("failed to read block %s", hash)
+		return convertErr(str, err)
+	}
+	tx.db.metrics.blockRead(numBytes, time.Since(start))
+	return nil
+}
+
+// FetchBlock returns the raw serialized bytes for the block identified by the
+// given hash.  The raw bytes are in the format returned by Serialize on a
+// wire.MsgBlock.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlock(hash *chainhash.Hash) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// When the block is pending to be written on commit return the bytes
+	// from there.
+	if idx, exists := tx.pendingBlocks[*hash]; exists {
+		return tx.pendingBlockData[idx].bytes, nil
+	}
+
+	var blockBytes []byte
+	err := tx.fetchStoredBlock(hash, func(storedBytes []byte) error {
+		blockBytes = copySlice(storedBytes)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blockBytes, nil
+}
+
+// FetchBlocks returns the raw serialized bytes for the blocks identified by the
+// given hashes.  The raw bytes are in the format returned by Serialize on a
+// wire.MsgBlock.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if any of the requested block hashed do not exist
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlocks(hashes []chainhash.Hash) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	blocks := make([][]byte, len(hashes))
+	for i := range hashes {
+		var err error
+		blocks[i], err = tx.FetchBlock(&hashes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return blocks, nil
+}
+
+// regionBytes returns the bytes of the passed region of the passed serialized
+// block.  It returns ErrBlockRegionInvalid when the region exceeds the bounds
+// of the block.
+func regionBytes(region *database.BlockRegion, blockBytes []byte) ([]byte, error) {
+	blockLen := uint32(len(blockBytes))
+	endOffset := region.Offset + region.Len
+	if endOffset < region.Offset || endOffset > blockLen {
+		str := fmt.Sprintf("block %s region offset %d, length %d "+
+			"exceeds block length of %d", region.Hash,
+			region.Offset, region.Len, blockLen)
+		return nil, makeDbErr(database.ErrBlockRegionInvalid, str, nil)
+	}
+
+	return blockBytes[region.Offset:endOffset:endOffset], nil
+}
+
+// fetchBlockRegion returns the bytes of the passed block region, taking into
+// account the pending blocks.  Only the region is copied out of a stored
+// block.
+func (tx *transaction) fetchBlockRegion(region *database.BlockRegion) ([]byte, error) {
+	if idx, exists := tx.pendingBlocks[*region.Hash]; exists {
+		return regionBytes(region, tx.pendingBlockData[idx].bytes)
+	}
+
+	var regionData []byte
+	err := tx.fetchStoredBlock(region.Hash, func(blockBytes []byte) error {
+		data, err := regionBytes(region, blockBytes)
+		if err != nil {
+			return err
+		}
+		regionData = copySlice(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return regionData, nil
+}
+
+// FetchBlockRegion returns the raw serialized bytes for the given block region.
+//
+// For example, it is possible to directly extract Bitcoin transactions and/or
+// scripts from a block with this function.  Depending on the backend
+// implementation, this can provide significant savings by avoiding the need to
+// load entire blocks.
+//
+// The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
+// the Offset field in the provided BlockRegion is zero-based and relative to
+// the start of the block (byte 0).
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrBlockRegionInvalid if the region exceeds the bounds of the associated
+//     block
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return tx.fetchBlockRegion(region)
+}
+
+// FetchBlockRegions returns the raw serialized bytes for the given block
+// regions.
+//
+// For example, it is possible to directly extract Bitcoin transactions and/or
+// scripts from various blocks with this function.  Depending on the backend
+// implementation, this can provide significant savings by avoiding the need to
+// load entire blocks.
+//
+// The raw bytes are in the format returned by Serialize on a wire.MsgBlock and
+// the Offset fields in the provided BlockRegions are zero-based and relative to
+// the start of the block (byte 0).
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if any of the request block hashes do not exist
+//   - ErrBlockRegionInvalid if one or more region exceed the bounds of the
+//     associated block
+//   - ErrTxClosed if the transaction has already been closed
+//   - ErrCorruption if the database has somehow become corrupted
+//
+// NOTE: The data returned by this function is only valid during a database
+// transaction.  Attempting to access it after a transaction has ended results
+// in undefined behavior.  This constraint prevents additional data copies and
+// allows support for memory-mapped database implementations.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]byte, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	blockRegions := make([][]byte, len(regions))
+	for i := range regions {
+		var err error
+		blockRegions[i], err = tx.fetchBlockRegion(&regions[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return blockRegions, nil
+}
+
+// close marks the transaction closed then releases any pending data, the
+// iterators of its cursors, the underlying snapshot, the transaction read lock,
+// and the write lock when the transaction is writable.
+func (tx *transaction) close() {
+	tx.closed = true
+
+	// Clear pending blocks that would have been written on commit.
+	tx.pendingBlocks = nil
+	tx.pendingBlockData = nil
+
+	// Clear pending keys that would have been written or deleted on commit.
+	tx.pendingKeys = nil
+	tx.pendingRemove = nil
+
+	// Release the iterators of the cursors and then the snapshot.
+	for _, c := range tx.cursors {
+		cursorFinalizer(c)
+	}
+	tx.cursors = nil
+	if tx.snapshot != nil {
+		tx.snapshot.Discard()
+		tx.snapshot = nil
+	}
+
+	tx.db.closeLock.RUnlock()
+
+	// Release the writer lock for writable transactions to unblock any
+	// other write transaction which are possibly waiting.
+	if tx.writable {
+		tx.db.writeLock.Unlock()
+	}
+}
+
+// writePending writes the pending blocks and keys to the database in a single
+// Badger transaction, so either all or none of them are written.
+func (tx *transaction) writePending() error {
+	// Add the pending blocks to the pending keys.  The headers are stored
+	// separately since they are so commonly needed, which avoids loading
+	// the blocks from the value log to read them.
+	var numBlockBytes int
+	for _, blockData := range tx.pendingBlockData {
+		log.Tracef("Storing block %s", blockData.hash)
+		tx.putKey(bucketizedKey(blockIdxBucketID, blockData.hash[:]),
+			blockData.bytes[0:blockHdrSize:blockHdrSize])
+		tx.putKey(bucketizedKey(blocksBucketID, blockData.hash[:]),
+			blockData.bytes)
+		numBlockBytes += len(blockData.bytes)
+	}
+
+	start := time.Now()
+	err := tx.db.bdb.Update(func(txn *badger.Txn) error {
+		var err error
+		tx.pendingKeys.ForEach(func(k, v []byte) bool {
+			err = txn.Set(k, v)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		tx.pendingRemove.ForEach(func(k, v []byte) bool {
+			err = txn.Delete(k)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		str := fmt.Sprintf("failed to commit transaction with %d keys "+
+			"and %d blocks", tx.pendingKeys.Len()+
+			tx.pendingRemove.Len(), len(tx.pendingBlockData))
+		return convertErr(str, err)
+	}
+
+	tx.db.metrics.committed(tx.pendingKeys.Len()+tx.pendingRemove.Len(),
+		len(tx.pendingBlockData), numBlockBytes, time.Since(start))
+	return nil
+}
+
+// Commit commits all changes that have been made to the root metadata bucket
+// and all of its sub-buckets, along with the new blocks, to the database.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Commit() error {
+	// Prevent commits on managed transactions.
+	if tx.managed {
+		tx.close()
+		panic("managed transaction commit not allowed")
+	}
+
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Regardless of whether the commit succeeds, the transaction is closed
+	// on return.
+	defer tx.close()
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "Commit requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	return tx.writePending()
+}
+
+// Rollback undoes all changes that have been made to the root bucket and all of
+// its sub-buckets.
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) Rollback() error {
+	// Prevent rollbacks on managed transactions.
+	if tx.managed {
+		tx.close()
+		panic("managed transaction rollback not allowed")
+	}
+
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	tx.close()
+	return nil
+}
+
+// db represents a collection of namespaces which are persisted and implements
+// the database.DB interface.  All database access is performed through
+// transactions which are obtained through the specific Namespace.
+type db struct {
+	writeLock sync.Mutex   // Limit to one write transaction at a time.
+	closeLock sync.RWMutex // Make database close block while txns active.
+	closed    bool         // Is the database closed?
+	readOnly  bool         // Is the database opened read-only?
+	bdb       *badger.DB   // Underlying Badger database.
+	metrics   *dbMetrics   // Operation metrics of the database.
+}
+
+// Enforce db implements the database.DB interface.
+var _ database.DB = (*db)(nil)
+
+// Type returns the database driver type the current database instance was
+// created with.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Type() string {
+	return dbType
+}
+
+// begin is the implementation function for the Begin database method.  See its
+// documentation for more details.
+//
+// This function is only separate because it returns the internal transaction
+// which is used by the managed transaction code while the database method
+// returns the interface.
+func (db *db) begin(writable bool) (*transaction, error) {
+	// Nothing can be written to a database opened read-only.
+	if writable && db.readOnly {
+		str := "cannot begin a writable transaction on a database " +
+			"opened read-only"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Whenever a new writable transaction is started, grab the write lock
+	// to ensure only a single write transaction can be active at the same
+	// time.  This lock will not be released until the transaction is
+	// closed (via Rollback or Commit).
+	if writable {
+		db.writeLock.Lock()
+	}
+
+	// Whenever a new transaction is started, grab a read lock against the
+	// database to ensure Close will wait for the transaction to finish.
+	// This lock will not be released until the transaction is closed (via
+	// Rollback or Commit).
+	db.closeLock.RLock()
+	if db.closed {
+		db.closeLock.RUnlock()
+		if writable {
+			db.writeLock.Unlock()
+		}
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr,
+			nil)
+	}
+
+	// The snapshot is a read-only Badger transaction, which allows any
+	// number of iterators to be open at the same time.  The metadata
+	// bucket is an internal-only bucket, so it has a defined ID.
+	tx := &transaction{
+		writable:      writable,
+		db:            db,
+		snapshot:      db.bdb.NewTransaction(false),
+		pendingKeys:   treap.NewMutable(),
+		pendingRemove: treap.NewMutable(),
+	}
+	tx.metaBucket = &bucket{tx: tx, id: metadataBucketID}
+	return tx, nil
+}
+
+// Begin starts a transaction which is either read-only or read-write depending
+// on the specified flag.  Multiple read-only transactions can be started
+// simultaneously while only a single read-write transaction can be started at a
+// time.  The call will block when starting a read-write transaction when one is
+// already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so will result in unclaimed memory.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Begin(writable bool) (database.Tx, error) {
+	return db.begin(writable)
+}
+
+// rollbackOnPanic rolls the passed transaction back if the code in the calling
+// function panics.  This is needed since the mutex on a transaction must be
+// released and a panic in called code would prevent that from happening.
+//
+// NOTE: This can only be handled manually for managed transactions since they
+// control the life-cycle of the transaction.  As the documentation on Begin
+// calls out, callers opting to use manual transactions will have to ensure the
+// transaction is rolled back on panic if it desires that functionality as well
+// or the database will fail to close since the read-lock will never be
+// released.
+func rollbackOnPanic(tx *transaction) {
+	if err := recover(); err != nil {
+		tx.managed = false
+		_ = tx.Rollback()
+		panic(err)
+	}
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction with the root bucket for the namespace.  Any errors returned from
+// the user-supplied function are returned from this function.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) View(fn func(database.Tx) error) error {
+	// Start a read-only transaction.
+	tx, err := db.begin(false)
+	if err != nil {
+		return err
+	}
+
+	// Since the user-provided function might panic, ensure the transaction
+	// releases all mutexes and resources.  There is no guarantee the caller
+	// won't use recover and keep going.  Thus, the database must still be
+	// in a usable state on panics due to caller issues.
+	defer rollbackOnPanic(tx)
+
+	tx.managed = true
+	err = fn(tx)
+	tx.managed = false
+	if err != nil {
+		// The error is ignored here because nothing was written yet
+		// and regardless of a rollback failure, the tx is closed now
+		// anyways.
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Rollback()
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction with the root bucket for the namespace.  Any errors returned from
+// the user-supplied function will cause the transaction to be rolled back and
+// are returned from this function.  Otherwise, the transaction is committed
+// when the user-supplied function returns a nil error.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Update(fn func(database.Tx) error) error {
+	// Start a read-write transaction.
+	tx, err := db.begin(true)
+	if err != nil {
+		return err
+	}
+
+	// Since the user-provided function might panic, ensure the transaction
+	// releases all mutexes and resources.  There is no guarantee the caller
+	// won't use recover and keep going.  Thus, the database must still be
+	// in a usable state on panics due to caller issues.
+	defer rollbackOnPanic(tx)
+
+	tx.managed = true
+	err = fn(tx)
+	tx.managed = false
+	if err != nil {
+		// The error is ignored here because nothing was written yet
+		// and regardless of a rollback failure, the tx is closed now
+		// anyways.
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close cleanly shuts down the database and syncs all data.  It will block
+// until all database transactions have been finalized (rolled back or
+// committed).
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Close() error {
+	// Since all transactions have a read lock on this mutex, this will
+	// cause Close to wait for all readers to complete.
+	db.closeLock.Lock()
+	defer db.closeLock.Unlock()
+
+	if db.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	db.closed = true
+
+	if err := db.bdb.Close(); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	return nil
+}
+
+// filesExists reports whether the named file or directory exists.
+func fileExists(name string) bool {
+	if _, err := os.Stat(name); err != nil {
+		if os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// initDB creates the initial buckets and values used by the package.
+func initDB(bdb *badger.DB, network wire.BitcoinNet) error {
+	// NOTE: Since buckets are virtualized through the use of prefixes,
+	// there is no need to store the bucket index data for the metadata
+	// bucket in the database.  However, the first bucket ID to use does
+	// need to account for it to ensure there are no key collisions.
+	var serializedNet [4]byte
+	byteOrder.PutUint32(serializedNet[:], uint32(network))
+	err := bdb.Update(func(txn *badger.Txn) error {
+		entries := []struct{ key, value []byte }{
+			{bucketIndexKey(metadataBucketID, blockIdxBucketName),
+				blockIdxBucketID[:]},
+			{bucketIndexKey(metadataBucketID, blocksBucketName),
+				blocksBucketID[:]},
+			{curBucketIDKeyName, blocksBucketID[:]},
+			{bucketizedKey(metadataBucketID, networkKeyName),
+				serializedNet[:]},
+		}
+		for _, entry := range entries {
+			if err := txn.Set(entry.key, entry.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		str := fmt.Sprintf("failed to initialize database: %v", err)
+		return convertErr(str, err)
+	}
+
+	return nil
+}
+
+// checkNetwork ensures the database was created for the passed block network.
+func checkNetwork(bdb *badger.DB, network wire.BitcoinNet) error {
+	return bdb.View(func(txn *badger.Txn) error {
+		key := bucketizedKey(metadataBucketID, networkKeyName)
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			str := "block network of the database does not exist"
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+		if err != nil {
+			return convertErr("failed to read the block network", err)
+		}
+		serializedNet, err := item.ValueCopy(nil)
+		if err != nil {
+			return convertErr("failed to read the block network", err)
+		}
+		if len(serializedNet) != 4 {
+			str := "block network of the database is malformed"
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+
+		dbNet := wire.BitcoinNet(byteOrder.Uint32(serializedNet))
+		if dbNet != network {
+			str := fmt.Sprintf("database is for block network %v, "+
+				"not %v", dbNet, network)
+			return makeDbErr(database.ErrDriverSpecific, str, nil)
+		}
+		return nil
+	})
+}
+
+// badgerOptions returns the options used to open the Badger database at the
+// provided path.
+func badgerOptions(dbPath string) badger.Options {
+	// Writes are not synced to disk on every commit, since the chain
+	// commits a transaction per block.  Badger recovers the writes which
+	// made it to the value log when it is opened after a crash, and the
+	// most recent transactions which didn't are lost completely, which
+	// matches what the write cache of ffldb does.
+	return badger.DefaultOptions(dbPath).
+		WithLogger(badgerLogger{}).
+		WithEventLogging(false).
+		WithSyncWrites(false).
+		WithValueThreshold(valueThreshold)
+}
+
+// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
+// is returned if the database doesn't exist and the create flag is not set.
+//
+// When the read-only flag is set, the database is opened without writing
+// anything to it, so several processes can open it read-only at the same time.
+func openDB(dbPath string, network wire.BitcoinNet, create, readOnly bool) (database.DB, error) {
+	// Error if the database doesn't exist and the create flag is not set,
+	// or if it exists and the create flag is set.
+	dbExists := fileExists(filepath.Join(dbPath, badger.ManifestFilename))
+	if !create && !dbExists {
+		str := fmt.Sprintf("database %q does not exist", dbPath)
+		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+	if create && dbExists {
+		str := fmt.Sprintf("database %q already exists", dbPath)
+		return nil, makeDbErr(database.ErrDbExists, str, nil)
+	}
+
+	// Ensure the full path to the database exists.
+	if !dbExists {
+		// The error can be ignored here since the call to badger.Open
+		// will fail if the directory couldn't be created.
+		_ = os.MkdirAll(dbPath, 0700)
+	}
+
+	bdb, err := badger.Open(badgerOptions(dbPath).WithReadOnly(readOnly))
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+
+	if create {
+		err = initDB(bdb, network)
+	} else {
+		err = checkNetwork(bdb, network)
+	}
+	if err != nil {
+		_ = bdb.Close()
+		return nil, err
+	}
+
+	return &db{bdb: bdb, readOnly: readOnly, metrics: newDbMetrics()}, nil
+}
+
+// repairDB recovers the database at the provided path, which can't be opened
+// when its value log was damaged, such as by an unclean shutdown, and then
+// opens the database as usual.  The value log is truncated at the first damaged
+// entry, so the most recent writes may be lost.
+func repairDB(dbPath string, network wire.BitcoinNet) (database.DB, error) {
+	if !fileExists(filepath.Join(dbPath, badger.ManifestFilename)) {
+		str := fmt.Sprintf("database %q does not exist", dbPath)
+		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+
+	log.Infof("Recovering database %s", dbPath)
+	bdb, err := badger.Open(badgerOptions(dbPath).WithTruncate(true))
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	if err := bdb.Close(); err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+
+	return openDB(dbPath, network, false, false)
+}
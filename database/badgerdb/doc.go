@@ -0,0 +1,48 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package badgerdb implements a driver for the database package that uses the
+Badger LSM key/value store for both the metadata and the blocks.
+
+Unlike ffldb, which keeps the blocks in flat files next to a leveldb database,
+this driver stores everything in a single Badger database.  Badger separates
+large values from the LSM tree into a value log, so the blocks don't slow down
+the compactions of the metadata, and every transaction, including the blocks it
+stores, is committed atomically.
+
+Building
+
+The driver is only compiled in when building with the badger build tag, so the
+Badger package is not required otherwise:
+
+  go build -tags badger
+
+Usage
+
+This package is a driver to the database package and provides the database type
+of "badgerdb".  The parameters the Open and Create functions take are the
+database path as a string and the block network:
+
+	db, err := database.Open("badgerdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+
+	db, err := database.Create("badgerdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+
+Prova uses the driver when started with --dbtype=badgerdb, and the migrate
+command of dbtool copies an existing ffldb database into a new badgerdb one.
+
+Limitations
+
+Badger limits the number of entries and the size of a single transaction.  A
+transaction exceeding the limits fails to commit with ErrDriverSpecific, so
+callers writing a large amount of metadata have to split the writes over
+several transactions.
+*/
+package badgerdb
@@ -0,0 +1,135 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/btclog"
+)
+
+var log = btclog.Disabled
+
+const (
+	dbType = "badgerdb"
+)
+
+// badgerLogger passes the log messages of Badger on to the logger of the
+// package.  Badger logs every file it opens or replays at the info level, so
+// those messages are demoted to debug.
+type badgerLogger struct{}
+
+// Errorf logs an error message of Badger.
+func (badgerLogger) Errorf(format string, args ...interface{}) {
+	log.Errorf(format, args...)
+}
+
+// Warningf logs a warning message of Badger.
+func (badgerLogger) Warningf(format string, args ...interface{}) {
+	log.Warnf(format, args...)
+}
+
+// Infof logs an informational message of Badger.
+func (badgerLogger) Infof(format string, args ...interface{}) {
+	log.Debugf(format, args...)
+}
+
+// Debugf logs a debug message of Badger.
+func (badgerLogger) Debugf(format string, args ...interface{}) {
+	log.Tracef(format, args...)
+}
+
+// parseArgs parses the arguments from the database Open/Create methods.
+func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, error) {
+	if len(args) != 2 {
+		return "", 0, fmt.Errorf("invalid arguments to %s.%s -- "+
+			"expected database path and block network", dbType,
+			funcName)
+	}
+
+	dbPath, ok := args[0].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("first argument to %s.%s is invalid -- "+
+			"expected database path string", dbType, funcName)
+	}
+
+	network, ok := args[1].(wire.BitcoinNet)
+	if !ok {
+		return "", 0, fmt.Errorf("second argument to %s.%s is invalid -- "+
+			"expected block network", dbType, funcName)
+	}
+
+	return dbPath, network, nil
+}
+
+// openDBDriver is the callback provided during driver registration that opens
+// an existing database for use.
+func openDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("Open", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDB(dbPath, network, false, false)
+}
+
+// openDBReadOnlyDriver is the callback provided during driver registration that
+// opens an existing database for read-only use.
+func openDBReadOnlyDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("OpenReadOnly", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDB(dbPath, network, false, true)
+}
+
+// repairDBDriver is the callback provided during driver registration that
+// recovers an existing database which can't be opened and opens it.
+func repairDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("Repair", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return repairDB(dbPath, network)
+}
+
+// createDBDriver is the callback provided during driver registration that
+// creates, initializes, and opens a database for use.
+func createDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("Create", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDB(dbPath, network, true, false)
+}
+
+// useLogger is the callback provided during driver registration that sets the
+// current logger to the provided one.
+func useLogger(logger btclog.Logger) {
+	log = logger
+}
+
+func init() {
+	// Register the driver.
+	driver := database.Driver{
+		DbType:       dbType,
+		Create:       createDBDriver,
+		Open:         openDBDriver,
+		OpenReadOnly: openDBReadOnlyDriver,
+		Repair:       repairDBDriver,
+		UseLogger:    useLogger,
+	}
+	if err := database.RegisterDriver(driver); err != nil {
+		panic(fmt.Sprintf("Failed to register database driver '%s': %v",
+			dbType, err))
+	}
+}
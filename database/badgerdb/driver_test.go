@@ -0,0 +1,695 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/badgerdb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// dbType is the database type name for this driver.
+	dbType = "badgerdb"
+
+	// blockDataNet is the expected network in the test block data.
+	blockDataNet = wire.MainNet
+)
+
+// checkDbError ensures the passed error is a database.Error with an error code
+// that matches the passed  error code.
+func checkDbError(t *testing.T, testName string, gotErr error, wantErrCode database.ErrorCode) bool {
+	dbErr, ok := gotErr.(database.Error)
+	if !ok {
+		t.Errorf("%s: unexpected error type - got %T, want %T",
+			testName, gotErr, database.Error{})
+		return false
+	}
+	if dbErr.ErrorCode != wantErrCode {
+		t.Errorf("%s: unexpected error code - got %s (%s), want %s",
+			testName, dbErr.ErrorCode, dbErr.Description,
+			wantErrCode)
+		return false
+	}
+
+	return true
+}
+
+// createTestDB creates a new database in a temporary directory and returns it
+// along with a function which closes and removes it.
+func createTestDB(t *testing.T, name string) (database.DB, string, func()) {
+	dir, err := ioutil.TempDir("", name)
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	dbPath := filepath.Join(dir, "db")
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	return db, dbPath, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// testBlocks returns the passed number of distinct blocks chained onto the
+// genesis block of the main network.
+func testBlocks(n int) []*provautil.Block {
+	blocks := make([]*provautil.Block, 0, n)
+	prevHash := chaincfg.MainNetParams.GenesisHash
+	for i := 1; i <= n; i++ {
+		var msgBlock wire.MsgBlock
+		genesis := chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header = genesis.Header
+		msgBlock.Header.PrevBlock = *prevHash
+		msgBlock.Header.Height = uint32(i)
+		for _, tx := range genesis.Transactions {
+			msgBlock.AddTransaction(tx.Copy())
+		}
+		block := provautil.NewBlock(&msgBlock)
+		blocks = append(blocks, block)
+		prevHash = block.Hash()
+	}
+	return blocks
+}
+
+// TestCreateOpenFail ensures that errors related to creating and opening a
+// database are handled properly.
+func TestCreateOpenFail(t *testing.T) {
+	t.Parallel()
+
+	// Ensure that attempting to open a database that doesn't exist returns
+	// the expected error.
+	_, err := database.Open(dbType, "noexist", blockDataNet)
+	if !checkDbError(t, "Open", err, database.ErrDbDoesNotExist) {
+		return
+	}
+
+	// Ensure that attempting to open a database with the wrong number of
+	// parameters returns the expected error.
+	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
+		"database path and block network", dbType)
+	_, err = database.Open(dbType, 1, 2, 3)
+	if err == nil || err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with an invalid type
+	// for the second parameter returns the expected error.
+	wantErr = fmt.Errorf("second argument to %s.Create is invalid -- "+
+		"expected block network", dbType)
+	_, err = database.Create(dbType, "noexist", "invalid")
+	if err == nil || err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure creating a database which already exists and opening it for
+	// another network fail.
+	db, dbPath, teardown := createTestDB(t, "badgerdb-createfail")
+	defer teardown()
+	db.Close()
+	_, err = database.Create(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Create existing", err, database.ErrDbExists) {
+		return
+	}
+	_, err = database.Open(dbType, dbPath, wire.TestNet)
+	if !checkDbError(t, "Open other network", err,
+		database.ErrDriverSpecific) {
+
+		return
+	}
+
+	// Ensure operations against a closed database return the expected
+	// error.
+	err = db.View(func(tx database.Tx) error {
+		return nil
+	})
+	if !checkDbError(t, "View", err, database.ErrDbNotOpen) {
+		return
+	}
+	err = db.Close()
+	if !checkDbError(t, "Close", err, database.ErrDbNotOpen) {
+		return
+	}
+}
+
+// TestPersistence ensures that values stored are still valid after closing and
+// reopening the database.
+func TestPersistence(t *testing.T) {
+	t.Parallel()
+
+	db, dbPath, teardown := createTestDB(t, "badgerdb-persistence")
+	defer teardown()
+
+	// Store a few key/value pairs in the metadata and a nested bucket
+	// along with a few blocks.
+	storeValues := map[string]string{
+		"b1key1": "foo1",
+		"b1key2": "foo2",
+		"b1key3": "",
+	}
+	blocks := testBlocks(3)
+	err := db.Update(func(tx database.Tx) error {
+		bucket1, err := tx.Metadata().CreateBucket([]byte("bucket1"))
+		if err != nil {
+			return err
+		}
+		for k, v := range storeValues {
+			if err := bucket1.Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Close and reopen the database to ensure the values persist.
+	db.Close()
+	db, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx database.Tx) error {
+		bucket1 := tx.Metadata().Bucket([]byte("bucket1"))
+		if bucket1 == nil {
+			return fmt.Errorf("Bucket1: unexpected nil bucket")
+		}
+		for k, v := range storeValues {
+			gotVal := bucket1.Get([]byte(k))
+			if gotVal == nil || !bytes.Equal(gotVal, []byte(v)) {
+				return fmt.Errorf("Get: key '%s' does not match "+
+					"expected value - got %q, want %q", k,
+					gotVal, v)
+			}
+		}
+
+		for _, block := range blocks {
+			wantBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock: stored block %s "+
+					"does not match", block.Hash())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// collectKeys returns the keys the passed cursor visits when moved with the
+// passed function after positioning it with the passed function.
+func collectKeys(c database.Cursor, position func() bool, move func() bool) []string {
+	var keys []string
+	for ok := position(); ok; ok = move() {
+		keys = append(keys, string(c.Key()))
+	}
+	return keys
+}
+
+// TestCursor ensures the cursors merge the stored keys with the pending changes
+// of a transaction in both directions, including when changing direction.
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := createTestDB(t, "badgerdb-cursor")
+	defer teardown()
+
+	// Store the keys a, c, e and g along with a nested bucket d.
+	err := db.Update(func(tx database.Tx) error {
+		bucket, err := tx.Metadata().CreateBucket([]byte("cursor"))
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "c", "e", "g"} {
+			if err := bucket.Put([]byte(k), []byte("v"+k)); err != nil {
+				return err
+			}
+		}
+		_, err = bucket.CreateBucket([]byte("d"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	err = db.Update(func(tx database.Tx) error {
+		// Add b and f, overwrite c, and delete e in the transaction.
+		bucket := tx.Metadata().Bucket([]byte("cursor"))
+		if err := bucket.Put([]byte("b"), []byte("vb")); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("f"), []byte("vf")); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("c"), []byte("vc2")); err != nil {
+			return err
+		}
+		if err := bucket.Delete([]byte("e")); err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		tests := []struct {
+			name     string
+			position func() bool
+			move     func() bool
+			want     []string
+		}{
+			{"forwards", c.First, c.Next,
+				[]string{"a", "b", "c", "f", "g", "d"}},
+			{"backwards", c.Last, c.Prev,
+				[]string{"d", "g", "f", "c", "b", "a"}},
+			{"seek", func() bool { return c.Seek([]byte("bb")) },
+				c.Next, []string{"c", "f", "g", "d"}},
+		}
+		for _, test := range tests {
+			got := collectKeys(c, test.position, test.move)
+			if !reflect.DeepEqual(got, test.want) {
+				return fmt.Errorf("%s: unexpected keys - got %v, "+
+					"want %v", test.name, got, test.want)
+			}
+		}
+
+		// Ensure the overwritten value is returned and nested buckets
+		// have no value.
+		if !c.Seek([]byte("c")) || string(c.Value()) != "vc2" {
+			return fmt.Errorf("Seek: unexpected value %q", c.Value())
+		}
+		if !c.Last() || c.Value() != nil {
+			return fmt.Errorf("Last: unexpected bucket value %q",
+				c.Value())
+		}
+
+		// Ensure changing direction returns the neighbouring keys both
+		// when the current key is stored and when it is pending.
+		moves := []struct {
+			move func() bool
+			want string
+		}{
+			{c.First, "a"}, {c.Next, "b"}, {c.Next, "c"},
+			{c.Prev, "b"}, {c.Prev, "a"}, {c.Next, "b"},
+			{c.Next, "c"}, {c.Next, "f"}, {c.Prev, "c"},
+			{c.Next, "f"}, {c.Next, "g"}, {c.Prev, "f"},
+		}
+		for i, move := range moves {
+			if !move.move() || string(c.Key()) != move.want {
+				return fmt.Errorf("move #%d: unexpected key - "+
+					"got %q, want %q", i, c.Key(), move.want)
+			}
+		}
+
+		// Ensure deleting the current key through the cursor works and
+		// the cursor moves on to the next key.
+		if !c.Seek([]byte("f")) {
+			return fmt.Errorf("Seek: key f not found")
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+		if !c.Next() || string(c.Key()) != "g" {
+			return fmt.Errorf("Next: unexpected key %q after "+
+				"delete", c.Key())
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+		if !c.Last() {
+			return fmt.Errorf("Last: bucket d not found")
+		}
+		err := c.Delete()
+		if !checkDbError(t, "Delete bucket", err,
+			database.ErrIncompatibleValue) {
+
+			return fmt.Errorf("Delete: deleted a bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Ensure the committed keys match the changes of the transaction.
+	err = db.View(func(tx database.Tx) error {
+		var keys []string
+		bucket := tx.Metadata().Bucket([]byte("cursor"))
+		err := bucket.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k)+"="+string(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		want := []string{"a=va", "b=vb", "c=vc2"}
+		if !reflect.DeepEqual(keys, want) {
+			return fmt.Errorf("ForEach: unexpected keys - got %v, "+
+				"want %v", keys, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestDeleteBucket ensures deleting a bucket removes all of its nested buckets
+// and keys, both stored and pending, without touching other buckets.
+func TestDeleteBucket(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := createTestDB(t, "badgerdb-deletebucket")
+	defer teardown()
+
+	err := db.Update(func(tx database.Tx) error {
+		meta := tx.Metadata()
+		parent, err := meta.CreateBucket([]byte("parent"))
+		if err != nil {
+			return err
+		}
+		child, err := parent.CreateBucket([]byte("child"))
+		if err != nil {
+			return err
+		}
+		if err := child.Put([]byte("key"), []byte("value")); err != nil {
+			return err
+		}
+		other, err := meta.CreateBucket([]byte("other"))
+		if err != nil {
+			return err
+		}
+		return other.Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	err = db.Update(func(tx database.Tx) error {
+		child := tx.Metadata().Bucket([]byte("parent")).
+			Bucket([]byte("child"))
+		grandchild, err := child.CreateBucket([]byte("grandchild"))
+		if err != nil {
+			return err
+		}
+		if err := grandchild.Put([]byte("key"), nil); err != nil {
+			return err
+		}
+		return tx.Metadata().DeleteBucket([]byte("parent"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	err = db.View(func(tx database.Tx) error {
+		if tx.Metadata().Bucket([]byte("parent")) != nil {
+			return fmt.Errorf("Bucket: deleted bucket still exists")
+		}
+		other := tx.Metadata().Bucket([]byte("other"))
+		if other == nil || other.Get([]byte("key")) == nil {
+			return fmt.Errorf("Bucket: other bucket was modified")
+		}
+		err := tx.Metadata().DeleteBucket([]byte("parent"))
+		if !checkDbError(t, "DeleteBucket", err,
+			database.ErrTxNotWritable) {
+
+			return fmt.Errorf("DeleteBucket: unexpected error %v",
+				err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+
+	// Ensure a recreated bucket doesn't see the keys of the deleted one.
+	err = db.Update(func(tx database.Tx) error {
+		parent, err := tx.Metadata().CreateBucket([]byte("parent"))
+		if err != nil {
+			return err
+		}
+		return parent.ForEach(func(k, v []byte) error {
+			return fmt.Errorf("ForEach: unexpected key %q", k)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+}
+
+// TestBlocks ensures blocks, their headers and their regions are fetched
+// correctly both while they are pending and after they were committed, and that
+// rolled back blocks are not stored.
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := createTestDB(t, "badgerdb-blocks")
+	defer teardown()
+
+	blocks := testBlocks(4)
+	checkBlocks := func(tx database.Tx, blocks []*provautil.Block) error {
+		for _, block := range blocks {
+			hash := block.Hash()
+			wantBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			if ok, err := tx.HasBlock(hash); err != nil || !ok {
+				return fmt.Errorf("HasBlock %s: got %v, %v",
+					hash, ok, err)
+			}
+			header, err := tx.FetchBlockHeader(hash)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(header, wantBytes[:len(header)]) ||
+				len(header) != wire.MaxBlockHeaderPayload {
+
+				return fmt.Errorf("FetchBlockHeader %s: "+
+					"unexpected header", hash)
+			}
+			region := database.BlockRegion{Hash: hash, Offset: 10,
+				Len: 20}
+			regions, err := tx.FetchBlockRegions(
+				[]database.BlockRegion{region})
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(regions[0], wantBytes[10:30]) {
+				return fmt.Errorf("FetchBlockRegions %s: "+
+					"unexpected region", hash)
+			}
+			region.Len = uint32(len(wantBytes))
+			_, err = tx.FetchBlockRegion(&region)
+			if !checkDbError(t, "FetchBlockRegion", err,
+				database.ErrBlockRegionInvalid) {
+
+				return fmt.Errorf("FetchBlockRegion %s: "+
+					"region out of bounds accepted", hash)
+			}
+		}
+		return nil
+	}
+
+	err := db.Update(func(tx database.Tx) error {
+		for _, block := range blocks[:2] {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		err := tx.StoreBlock(blocks[0])
+		if !checkDbError(t, "StoreBlock", err, database.ErrBlockExists) {
+			return fmt.Errorf("StoreBlock: duplicate block stored")
+		}
+		return checkBlocks(tx, blocks[:2])
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Store the other blocks in a transaction which is rolled back.
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	for _, block := range blocks[2:] {
+		if err := tx.StoreBlock(block); err != nil {
+			tx.Rollback()
+			t.Fatalf("StoreBlock: unexpected error: %v", err)
+		}
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: unexpected error: %v", err)
+	}
+
+	err = db.View(func(tx database.Tx) error {
+		if err := checkBlocks(tx, blocks[:2]); err != nil {
+			return err
+		}
+		hashes := []chainhash.Hash{*blocks[1].Hash(), *blocks[2].Hash()}
+		have, err := tx.HasBlocks(hashes)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(have, []bool{true, false}) {
+			return fmt.Errorf("HasBlocks: unexpected result %v", have)
+		}
+		_, err = tx.FetchBlock(blocks[2].Hash())
+		if !checkDbError(t, "FetchBlock", err, database.ErrBlockNotFound) {
+			return fmt.Errorf("FetchBlock: rolled back block found")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestOpenReadOnly ensures a database opened read-only serves reads and rejects
+// writable transactions and compactions.
+func TestOpenReadOnly(t *testing.T) {
+	t.Parallel()
+
+	db, dbPath, teardown := createTestDB(t, "badgerdb-readonly")
+	defer teardown()
+	err := db.Update(func(tx database.Tx) error {
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	db.Close()
+
+	roDB, err := database.OpenReadOnly(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: unexpected error: %v", err)
+	}
+	defer roDB.Close()
+
+	err = roDB.View(func(tx database.Tx) error {
+		if !bytes.Equal(tx.Metadata().Get([]byte("key")), []byte("value")) {
+			return fmt.Errorf("Get: unexpected value")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	_, err = roDB.Begin(true)
+	checkDbError(t, "Begin", err, database.ErrTxNotWritable)
+	err = roDB.(database.Maintainer).Compact()
+	checkDbError(t, "Compact", err, database.ErrInvalid)
+}
+
+// TestMaintenance ensures the statistics count the operations on the database,
+// that it can be compacted, and that backups contain its contents.
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	db, dbPath, teardown := createTestDB(t, "badgerdb-maintenance")
+	defer teardown()
+
+	blocks := testBlocks(2)
+	err := db.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	backupPath := dbPath + "-backup"
+	err = db.View(func(tx database.Tx) error {
+		if _, err := tx.FetchBlock(blocks[0].Hash()); err != nil {
+			return err
+		}
+		tx.Metadata().Get([]byte("key"))
+		if err := tx.(database.Backuper).Backup(backupPath); err != nil {
+			return err
+		}
+		err := tx.(database.Backuper).Backup(backupPath)
+		if !checkDbError(t, "Backup", err, database.ErrDbExists) {
+			return fmt.Errorf("Backup: existing destination accepted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(backupPath)
+
+	maintainer := db.(database.Maintainer)
+	if err := maintainer.Compact(); err != nil {
+		t.Fatalf("Compact: unexpected error: %v", err)
+	}
+	stats, err := maintainer.Stats()
+	if err != nil {
+		t.Fatalf("Stats: unexpected error: %v", err)
+	}
+	if stats.BlockWrites != 2 || stats.BlockReads != 1 ||
+		stats.MetadataReads == 0 || stats.MetadataWrites == 0 ||
+		len(stats.BlockReadLatency.Buckets) !=
+			len(database.LatencyBuckets)+1 {
+
+		t.Fatalf("Stats: unexpected statistics %+v", stats)
+	}
+
+	backup, err := database.Open(dbType, backupPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Open backup: unexpected error: %v", err)
+	}
+	defer backup.Close()
+	err = backup.View(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if _, err := tx.FetchBlock(block.Hash()); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(tx.Metadata().Get([]byte("key")), []byte("value")) {
+			return fmt.Errorf("Get: unexpected value in backup")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View backup: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb
+
+import (
+	"bytes"
+
+	"github.com/bitgo/prova/database/internal/treap"
+	"github.com/dgraph-io/badger"
+)
+
+// iterator is a bidirectional iterator over the keys which share a prefix.  It
+// is implemented both over the snapshot of a transaction and over its pending
+// keys, so cursors can merge the two.
+type iterator interface {
+	First() bool
+	Last() bool
+	Next() bool
+	Prev() bool
+	Seek(key []byte) bool
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Release()
+}
+
+// prefixLimit returns the smallest key which is greater than all keys with the
+// passed prefix, or nil when there is no such key since the prefix is empty or
+// consists of 0xff bytes only.
+func prefixLimit(prefix []byte) []byte {
+	limit := copySlice(prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] != 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
+}
+
+// snapshotIter iterates over the keys with a prefix in the read-only Badger
+// transaction which serves as the snapshot of a database transaction.  Badger
+// iterators only move in one direction, so a forward and a reverse iterator
+// are created as needed and the other one is repositioned when the direction
+// changes.
+type snapshotIter struct {
+	txn    *badger.Txn
+	prefix []byte
+	limit  []byte
+
+	fwd *badger.Iterator
+	rev *badger.Iterator
+	cur *badger.Iterator
+
+	// key and value are copies of the current key and value.  The value
+	// is only loaded when it is requested, since it might be stored in
+	// the value log.
+	key   []byte
+	value []byte
+}
+
+// Enforce snapshotIter implements the iterator interface.
+var _ iterator = (*snapshotIter)(nil)
+
+// newSnapshotIter returns a new iterator over the keys with the passed prefix
+// in the passed Badger transaction.
+func newSnapshotIter(txn *badger.Txn, prefix []byte) *snapshotIter {
+	return &snapshotIter{
+		txn:    txn,
+		prefix: prefix,
+		limit:  prefixLimit(prefix),
+	}
+}
+
+// forward returns the forward Badger iterator, creating it when needed.
+func (iter *snapshotIter) forward() *badger.Iterator {
+	if iter.fwd == nil {
+		iter.fwd = iter.txn.NewIterator(badger.IteratorOptions{})
+	}
+	return iter.fwd
+}
+
+// reverse returns the reverse Badger iterator, creating it when needed.
+func (iter *snapshotIter) reverse() *badger.Iterator {
+	if iter.rev == nil {
+		iter.rev = iter.txn.NewIterator(badger.IteratorOptions{
+			Reverse: true,
+		})
+	}
+	return iter.rev
+}
+
+// update makes the passed Badger iterator the current one and loads its key.
+// It returns whether the iterator points to a key with the prefix.
+func (iter *snapshotIter) update(it *badger.Iterator) bool {
+	iter.cur = it
+	iter.value = nil
+	if !it.ValidForPrefix(iter.prefix) {
+		iter.key = nil
+		return false
+	}
+	iter.key = it.Item().KeyCopy(nil)
+	return true
+}
+
+// First moves the iterator to the first key with the prefix.
+func (iter *snapshotIter) First() bool {
+	it := iter.forward()
+	it.Seek(iter.prefix)
+	return iter.update(it)
+}
+
+// Last moves the iterator to the last key with the prefix.
+func (iter *snapshotIter) Last() bool {
+	it := iter.reverse()
+	if iter.limit == nil {
+		it.Rewind()
+		return iter.update(it)
+	}
+
+	// A reverse seek lands on the limit itself when it exists, which is
+	// the first key after the prefix.
+	it.Seek(iter.limit)
+	if it.Valid() && bytes.Equal(it.Item().Key(), iter.limit) {
+		it.Next()
+	}
+	return iter.update(it)
+}
+
+// Next moves the iterator to the next key.
+func (iter *snapshotIter) Next() bool {
+	if iter.key == nil {
+		return false
+	}
+
+	// Reposition the forward iterator after the current key when the
+	// iterator was moving backwards.
+	it := iter.forward()
+	if iter.cur != it {
+		it.Seek(iter.key)
+		if it.Valid() && bytes.Equal(it.Item().Key(), iter.key) {
+			it.Next()
+		}
+		return iter.update(it)
+	}
+	it.Next()
+	return iter.update(it)
+}
+
+// Prev moves the iterator to the previous key.
+func (iter *snapshotIter) Prev() bool {
+	if iter.key == nil {
+		return false
+	}
+
+	// Reposition the reverse iterator before the current key when the
+	// iterator was moving forwards.
+	it := iter.reverse()
+	if iter.cur != it {
+		it.Seek(iter.key)
+		if it.Valid() && bytes.Equal(it.Item().Key(), iter.key) {
+			it.Next()
+		}
+		return iter.update(it)
+	}
+	it.Next()
+	return iter.update(it)
+}
+
+// Seek moves the iterator to the first key with the prefix which is greater
+// than or equal to the passed key.
+func (iter *snapshotIter) Seek(key []byte) bool {
+	if bytes.Compare(key, iter.prefix) < 0 {
+		key = iter.prefix
+	}
+	it := iter.forward()
+	it.Seek(key)
+	return iter.update(it)
+}
+
+// Valid returns whether the iterator points to a key.
+func (iter *snapshotIter) Valid() bool {
+	return iter.key != nil
+}
+
+// Key returns the current key.
+func (iter *snapshotIter) Key() []byte {
+	return iter.key
+}
+
+// Value returns the current value, or nil if it can't be read.  An empty slice
+// is returned for keys which have no value.
+func (iter *snapshotIter) Value() []byte {
+	if iter.key == nil {
+		return nil
+	}
+	if iter.value == nil {
+		value, err := iter.cur.Item().ValueCopy(nil)
+		if err != nil {
+			log.Errorf("Unable to read the value of key %x: %v",
+				iter.key, err)
+			return nil
+		}
+		if value == nil {
+			value = []byte{}
+		}
+		iter.value = value
+	}
+	return iter.value
+}
+
+// Release closes the Badger iterators.  They must be closed before the
+// transaction is discarded.
+func (iter *snapshotIter) Release() {
+	if iter.fwd != nil {
+		iter.fwd.Close()
+		iter.fwd = nil
+	}
+	if iter.rev != nil {
+		iter.rev.Close()
+		iter.rev = nil
+	}
+	iter.cur = nil
+	iter.key = nil
+	iter.value = nil
+}
+
+// pendingIter iterates over the pending keys of a transaction which have a
+// prefix.
+type pendingIter struct {
+	*treap.Iterator
+	tx       *transaction
+	start    []byte
+	released bool
+}
+
+// Enforce pendingIter implements the iterator interface.
+var _ iterator = (*pendingIter)(nil)
+
+// newPendingIter returns a new iterator over the pending keys of the passed
+// transaction which have the passed prefix.  The iterator is added to the list
+// of active iterators of the transaction, so it is notified of changes to the
+// pending keys.
+func newPendingIter(tx *transaction, prefix []byte) *pendingIter {
+	iter := tx.pendingKeys.Iterator(prefix, prefixLimit(prefix))
+	tx.addActiveIter(iter)
+	return &pendingIter{Iterator: iter, tx: tx, start: prefix}
+}
+
+// Seek moves the iterator to the first pending key with the prefix which is
+// greater than or equal to the passed key.  The treap iterator does not find
+// any key when the seek key is before the start of its range, so such seeks
+// move to the first key instead.
+func (iter *pendingIter) Seek(key []byte) bool {
+	if bytes.Compare(key, iter.start) < 0 {
+		return iter.First()
+	}
+	return iter.Iterator.Seek(key)
+}
+
+// Release removes the iterator from the list of active iterators of the
+// transaction.
+func (iter *pendingIter) Release() {
+	if !iter.released {
+		iter.tx.removeActiveIter(iter.Iterator)
+		iter.released = true
+	}
+}
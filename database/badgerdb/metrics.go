@@ -0,0 +1,200 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package badgerdb
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/database"
+	"github.com/dgraph-io/badger"
+)
+
+// Enforce db implements the database.Maintainer interface.
+var _ database.Maintainer = (*db)(nil)
+
+// gcDiscardRatio is the fraction of a value log file which has to be stale
+// before the file is rewritten by the value log garbage collection.
+const gcDiscardRatio = 0.5
+
+// latencyHistogram collects the distribution of the latencies of an operation.
+// The counters are updated atomically, so it is safe for concurrent access.
+type latencyHistogram struct {
+	count   uint64
+	total   int64
+	max     int64
+	buckets []uint64
+}
+
+// newLatencyHistogram returns a new latency histogram with a bucket for each of
+// the database.LatencyBuckets plus one for the latencies above them.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		buckets: make([]uint64, len(database.LatencyBuckets)+1),
+	}
+}
+
+// observe records an operation which took the passed duration.
+func (h *latencyHistogram) observe(d time.Duration) {
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.total, int64(d))
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&h.max, max,
+			int64(d)) {
+
+			break
+		}
+	}
+
+	bucket := len(database.LatencyBuckets)
+	for i, bound := range database.LatencyBuckets {
+		if d <= bound {
+			bucket = i
+			break
+		}
+	}
+	atomic.AddUint64(&h.buckets[bucket], 1)
+}
+
+// snapshot returns the current state of the histogram.
+func (h *latencyHistogram) snapshot() database.LatencyHistogram {
+	buckets := make([]uint64, len(h.buckets))
+	for i := range h.buckets {
+		buckets[i] = atomic.LoadUint64(&h.buckets[i])
+	}
+	return database.LatencyHistogram{
+		Count:   atomic.LoadUint64(&h.count),
+		Total:   time.Duration(atomic.LoadInt64(&h.total)),
+		Max:     time.Duration(atomic.LoadInt64(&h.max)),
+		Buckets: buckets,
+	}
+}
+
+// dbMetrics houses the operation metrics of the database.  The counters are
+// updated atomically, so it is safe for concurrent access.
+//
+// Every commit writes the metadata and the blocks of a transaction at once,
+// so the commits are counted as the flushes of the database and the writes of
+// its blocks share their latencies.
+type dbMetrics struct {
+	metadataReads   uint64
+	metadataWrites  uint64
+	commits         uint64
+	blockReads      uint64
+	blockReadBytes  uint64
+	blockWrites     uint64
+	blockWriteBytes uint64
+
+	commitLatency     *latencyHistogram
+	blockReadLatency  *latencyHistogram
+	blockWriteLatency *latencyHistogram
+}
+
+// newDbMetrics returns new database metrics with all counters set to zero.
+func newDbMetrics() *dbMetrics {
+	return &dbMetrics{
+		commitLatency:     newLatencyHistogram(),
+		blockReadLatency:  newLatencyHistogram(),
+		blockWriteLatency: newLatencyHistogram(),
+	}
+}
+
+// metadataRead records a metadata read from the database.
+func (m *dbMetrics) metadataRead() {
+	atomic.AddUint64(&m.metadataReads, 1)
+}
+
+// blockRead records a read of a block with the passed number of bytes which
+// took the passed duration.
+func (m *dbMetrics) blockRead(numBytes int, d time.Duration) {
+	atomic.AddUint64(&m.blockReads, 1)
+	atomic.AddUint64(&m.blockReadBytes, uint64(numBytes))
+	m.blockReadLatency.observe(d)
+}
+
+// committed records a commit of the passed number of metadata keys and blocks
+// with the passed total number of bytes which took the passed duration.
+func (m *dbMetrics) committed(numKeys, numBlocks, numBlockBytes int, d time.Duration) {
+	atomic.AddUint64(&m.metadataWrites, uint64(numKeys))
+	atomic.AddUint64(&m.commits, 1)
+	m.commitLatency.observe(d)
+	if numBlocks == 0 {
+		return
+	}
+	atomic.AddUint64(&m.blockWrites, uint64(numBlocks))
+	atomic.AddUint64(&m.blockWriteBytes, uint64(numBlockBytes))
+	for i := 0; i < numBlocks; i++ {
+		m.blockWriteLatency.observe(d)
+	}
+}
+
+// snapshot returns the current values of the metrics.
+func (m *dbMetrics) snapshot() *database.Stats {
+	return &database.Stats{
+		MetadataReads:     atomic.LoadUint64(&m.metadataReads),
+		MetadataWrites:    atomic.LoadUint64(&m.metadataWrites),
+		CacheFlushes:      atomic.LoadUint64(&m.commits),
+		FlushLatency:      m.commitLatency.snapshot(),
+		BlockReads:        atomic.LoadUint64(&m.blockReads),
+		BlockReadBytes:    atomic.LoadUint64(&m.blockReadBytes),
+		BlockReadLatency:  m.blockReadLatency.snapshot(),
+		BlockWrites:       atomic.LoadUint64(&m.blockWrites),
+		BlockWriteBytes:   atomic.LoadUint64(&m.blockWriteBytes),
+		BlockWriteLatency: m.blockWriteLatency.snapshot(),
+	}
+}
+
+// Stats returns the operation metrics collected since the database was opened.
+// Badger has no write cache and doesn't report write stalls or the disk I/O of
+// its compactions, so those statistics are always zero.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) Stats() (*database.Stats, error) {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	return db.metrics.snapshot(), nil
+}
+
+// Compact compacts all levels of the LSM tree of the Badger database into one
+// and then garbage collects the value log, which rewrites the value log files
+// holding mostly deleted or overwritten values.  The database can't be closed
+// until the compaction is done.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) Compact() error {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	if db.readOnly {
+		str := "cannot compact a database opened read-only"
+		return makeDbErr(database.ErrInvalid, str, nil)
+	}
+
+	log.Info("Compacting the database")
+	start := time.Now()
+	if err := db.bdb.Flatten(2); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	for {
+		err := db.bdb.RunValueLogGC(gcDiscardRatio)
+		if err == badger.ErrNoRewrite {
+			break
+		}
+		if err != nil {
+			return convertErr(err.Error(), err)
+		}
+	}
+	log.Infof("Compacted the database in %v", time.Since(start))
+	return nil
+}
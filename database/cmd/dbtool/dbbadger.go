@@ -0,0 +1,13 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build badger
+
+package main
+
+import (
+	// Register the badgerdb driver, which is only available when building
+	// with the badger build tag.
+	_ "github.com/bitgo/prova/database/badgerdb"
+)
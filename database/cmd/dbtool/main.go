@@ -90,6 +90,12 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("migrate",
+		"Migrate the block database to another database backend",
+		"Copy the main chain blocks and all metadata of the block "+
+			"database to a new block database which uses the "+
+			"backend given by --desttype.  The node must not be "+
+			"running during the migration.", &migrateCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
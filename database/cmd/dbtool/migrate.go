@@ -0,0 +1,333 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+const (
+	// migrateBatchSize is the number of bytes of metadata or blocks written
+	// to the destination database in a single transaction.  It is kept well
+	// below the transaction size limit of badgerdb.
+	migrateBatchSize = 4 * 1024 * 1024
+
+	// migrateBatchKeys is the number of metadata changes written to the
+	// destination database in a single transaction, since badgerdb also
+	// limits the number of entries of a transaction.
+	migrateBatchKeys = 20000
+)
+
+var (
+	// heightIndexBucketName is the name of the bucket the chain uses to map
+	// the heights of all blocks in the main chain to their hashes.  The
+	// migration relies on it to find the blocks to copy, since the database
+	// interface provides no way to list the stored blocks.
+	heightIndexBucketName = []byte("heightidx")
+)
+
+// migrateCmd defines the configuration options for the migrate command.
+type migrateCmd struct {
+	DestType string `long:"desttype" description:"Database backend to migrate the block database to"`
+}
+
+var (
+	// migrateCfg defines the configuration options for the command.
+	migrateCfg = migrateCmd{}
+)
+
+// metadataWrite describes a single change to the metadata of the destination
+// database, which either stores a key/value pair in the bucket at the path or
+// creates a nested bucket in it.
+type metadataWrite struct {
+	path   [][]byte
+	key    []byte
+	value  []byte
+	bucket bool
+}
+
+// migrator copies the contents of one block database into another one which
+// uses a different backend.
+type migrator struct {
+	dest database.DB
+
+	// internal houses the top-level keys and buckets the destination
+	// backend created for its own use, which must not be overwritten.
+	// srcPrefix is the prefix of the top-level keys and buckets the source
+	// backend created for its own use, which must not be copied.
+	internal  map[string]struct{}
+	srcPrefix string
+
+	pending     []metadataWrite
+	pendingSize int
+	numKeys     int
+}
+
+// flush writes all pending metadata changes to the destination database in a
+// single transaction.
+func (m *migrator) flush() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	err := m.dest.Update(func(tx database.Tx) error {
+		for _, w := range m.pending {
+			bucket := tx.Metadata()
+			for _, name := range w.path {
+				bucket = bucket.Bucket(name)
+			}
+			if w.bucket {
+				if _, err := bucket.CreateBucket(w.key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bucket.Put(w.key, w.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.pending = m.pending[:0]
+	m.pendingSize = 0
+	return nil
+}
+
+// isInternal returns whether the passed top-level key or bucket of the source
+// database is internal to either of the backends.
+func (m *migrator) isInternal(key []byte) bool {
+	if _, ok := m.internal[string(key)]; ok {
+		return true
+	}
+	return strings.HasPrefix(string(key), m.srcPrefix)
+}
+
+// queue adds a metadata change to the pending changes, copying its key and
+// value since they are only valid during the source transaction, and flushes
+// the changes once they exceed the batch size or number of keys.
+func (m *migrator) queue(w metadataWrite) error {
+	w.key = append([]byte(nil), w.key...)
+	w.value = append([]byte(nil), w.value...)
+	if !w.bucket {
+		m.numKeys++
+	}
+	m.pending = append(m.pending, w)
+	m.pendingSize += len(w.key) + len(w.value)
+	if m.pendingSize < migrateBatchSize &&
+		len(m.pending) < migrateBatchKeys {
+
+		return nil
+	}
+	return m.flush()
+}
+
+// copyBucket queues the key/value pairs and nested buckets of the passed source
+// bucket, which is located at the passed path, for writing to the destination.
+func (m *migrator) copyBucket(bucket database.Bucket, path [][]byte) error {
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(path) == 0 && m.isInternal(k) {
+			return nil
+		}
+		return m.queue(metadataWrite{path: path, key: k, value: v})
+	})
+	if err != nil {
+		return err
+	}
+
+	var names [][]byte
+	err = bucket.ForEachBucket(func(k []byte) error {
+		if len(path) == 0 && m.isInternal(k) {
+			return nil
+		}
+		names = append(names, append([]byte(nil), k...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err := m.queue(metadataWrite{path: path, key: name, bucket: true})
+		if err != nil {
+			return err
+		}
+		childPath := make([][]byte, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = name
+		if err := m.copyBucket(bucket.Bucket(name), childPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlocks stores all blocks of the main chain of the source database in the
+// destination database and returns the number of copied blocks.
+func (m *migrator) copyBlocks(tx database.Tx) (int, error) {
+	heightIndex := tx.Metadata().Bucket(heightIndexBucketName)
+	if heightIndex == nil {
+		return 0, errors.New("the source database does not contain " +
+			"a block chain")
+	}
+
+	var blocks []*provautil.Block
+	var batchSize, numBlocks int
+	storeBlocks := func() error {
+		err := m.dest.Update(func(destTx database.Tx) error {
+			for _, block := range blocks {
+				if err := destTx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		numBlocks += len(blocks)
+		blocks = blocks[:0]
+		batchSize = 0
+		return err
+	}
+	err := heightIndex.ForEach(func(k, v []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], v)
+		blockBytes, err := tx.FetchBlock(&hash)
+		if err != nil {
+			return err
+		}
+		block, err := provautil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+		batchSize += len(blockBytes)
+		if batchSize < migrateBatchSize {
+			return nil
+		}
+		return storeBlocks()
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := storeBlocks(); err != nil {
+		return 0, err
+	}
+	return numBlocks, nil
+}
+
+// migrate copies the main chain blocks and all metadata of the source database
+// to the destination database.  The destination must have been created just
+// before, so its top-level keys and buckets are all internal to its backend.
+// The backends prefix the names of their internal top-level keys and buckets
+// with their database type, so those of the source are skipped by the prefix.
+func migrate(src, dest database.DB) error {
+	m := &migrator{
+		dest:      dest,
+		internal:  make(map[string]struct{}),
+		srcPrefix: src.Type() + "-",
+	}
+	err := dest.View(func(tx database.Tx) error {
+		err := tx.Metadata().ForEach(func(k, v []byte) error {
+			m.internal[string(k)] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Metadata().ForEachBucket(func(k []byte) error {
+			m.internal[string(k)] = struct{}{}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return src.View(func(tx database.Tx) error {
+		log.Info("Copying blocks...")
+		startTime := time.Now()
+		numBlocks, err := m.copyBlocks(tx)
+		if err != nil {
+			return err
+		}
+		log.Infof("Copied %d blocks in %v", numBlocks,
+			time.Since(startTime))
+
+		log.Info("Copying metadata...")
+		startTime = time.Now()
+		if err := m.copyBucket(tx.Metadata(), nil); err != nil {
+			return err
+		}
+		if err := m.flush(); err != nil {
+			return err
+		}
+		log.Infof("Copied %d metadata entries in %v", m.numKeys,
+			time.Since(startTime))
+		return nil
+	})
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *migrateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if !validDbType(cmd.DestType) {
+		str := "The specified destination database type [%v] is " +
+			"invalid -- supported types %v"
+		return fmt.Errorf(str, cmd.DestType, knownDbTypes)
+	}
+	if cmd.DestType == cfg.DbType {
+		return errors.New("the destination database type must differ " +
+			"from the source database type")
+	}
+
+	// Open the source database, which must exist, and create the
+	// destination database next to it where prova looks for it when
+	// started with the destination type as --dbtype.
+	srcPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	log.Infof("Loading block database from '%s'", srcPath)
+	src, err := database.Open(cfg.DbType, srcPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	destPath := filepath.Join(cfg.DataDir,
+		blockDbNamePrefix+"_"+cmd.DestType)
+	if fileExists(destPath) {
+		return fmt.Errorf("the destination database '%s' already exists",
+			destPath)
+	}
+	log.Infof("Creating block database at '%s'", destPath)
+	dest, err := database.Create(cmd.DestType, destPath,
+		activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+
+	// Remove the partially migrated destination database on failure so the
+	// migration can simply be run again.
+	err = migrate(src, dest)
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(destPath)
+		return err
+	}
+	log.Infof("Migrated the block database to '%s'", destPath)
+	return nil
+}
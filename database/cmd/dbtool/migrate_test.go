@@ -0,0 +1,196 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/btclog"
+)
+
+// migrateTestBlocks returns the passed number of distinct blocks chained onto
+// the genesis block of the main network.
+func migrateTestBlocks(n int) []*provautil.Block {
+	blocks := make([]*provautil.Block, 0, n)
+	prevHash := chaincfg.MainNetParams.GenesisHash
+	for i := 1; i <= n; i++ {
+		var msgBlock wire.MsgBlock
+		genesis := chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header = genesis.Header
+		msgBlock.Header.PrevBlock = *prevHash
+		msgBlock.Header.Height = uint32(i)
+		for _, tx := range genesis.Transactions {
+			msgBlock.AddTransaction(tx.Copy())
+		}
+		block := provautil.NewBlock(&msgBlock)
+		blocks = append(blocks, block)
+		prevHash = block.Hash()
+	}
+	return blocks
+}
+
+// dumpMetadata returns all keys of the passed bucket and its nested buckets,
+// other than the top-level keys and buckets with the passed prefix, mapped to
+// their values.  Nested buckets are mapped to a nil value.
+func dumpMetadata(bucket database.Bucket, path string, skipPrefix []byte, dump map[string][]byte) error {
+	err := bucket.ForEach(func(k, v []byte) error {
+		if path == "" && bytes.HasPrefix(k, skipPrefix) {
+			return nil
+		}
+		dump[path+"/"+string(k)] = append([]byte{}, v...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bucket.ForEachBucket(func(k []byte) error {
+		if path == "" && bytes.HasPrefix(k, skipPrefix) {
+			return nil
+		}
+		childPath := path + "/" + string(k) + "/"
+		dump[childPath] = nil
+		return dumpMetadata(bucket.Bucket(k), childPath, skipPrefix, dump)
+	})
+}
+
+// TestMigrate ensures the migrate command copies the blocks of the main chain
+// and all metadata of a database to every supported database type, without
+// copying the internal metadata of the source.
+func TestMigrate(t *testing.T) {
+	log = btclog.Disabled
+
+	dir, err := ioutil.TempDir("", "dbtool-migrate")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the source database with a height index for the blocks, a few
+	// nested buckets and enough keys to need several destination
+	// transactions.
+	src, err := database.Create("ffldb", filepath.Join(dir, "src"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer src.Close()
+	blocks := migrateTestBlocks(5)
+	err = src.Update(func(tx database.Tx) error {
+		heightIndex, err := tx.Metadata().CreateBucket(
+			heightIndexBucketName)
+		if err != nil {
+			return err
+		}
+		for i, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+			var height [4]byte
+			binary.BigEndian.PutUint32(height[:], uint32(i+1))
+			err := heightIndex.Put(height[:], block.Hash()[:])
+			if err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Metadata()
+		if err := meta.Put([]byte("bestchain"), []byte("tip")); err != nil {
+			return err
+		}
+		parent, err := meta.CreateBucket([]byte("parent"))
+		if err != nil {
+			return err
+		}
+		child, err := parent.CreateBucket([]byte("child"))
+		if err != nil {
+			return err
+		}
+		if err := child.Put([]byte("empty"), nil); err != nil {
+			return err
+		}
+		for i := 0; i < migrateBatchKeys+10; i++ {
+			var key [4]byte
+			binary.BigEndian.PutUint32(key[:], uint32(i))
+			if err := parent.Put(key[:], key[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	srcDump := make(map[string][]byte)
+	err = src.View(func(tx database.Tx) error {
+		return dumpMetadata(tx.Metadata(), "", []byte("ffldb-"), srcDump)
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+
+	for _, destType := range database.SupportedDrivers() {
+		destPath := filepath.Join(dir, destType)
+		dest, err := database.Create(destType, destPath, wire.MainNet)
+		if err != nil {
+			t.Errorf("Create %s: unexpected error: %v", destType, err)
+			continue
+		}
+		if err := migrate(src, dest); err != nil {
+			dest.Close()
+			t.Errorf("migrate %s: unexpected error: %v", destType,
+				err)
+			continue
+		}
+
+		destDump := make(map[string][]byte)
+		err = dest.View(func(tx database.Tx) error {
+			for _, block := range blocks {
+				wantBytes, err := block.Bytes()
+				if err != nil {
+					return err
+				}
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotBytes, wantBytes) {
+					t.Errorf("migrate %s: block %s does not "+
+						"match", destType, block.Hash())
+				}
+			}
+			return dumpMetadata(tx.Metadata(), "",
+				[]byte(destType+"-"), destDump)
+		})
+		dest.Close()
+		if err != nil {
+			t.Errorf("View %s: unexpected error: %v", destType, err)
+			continue
+		}
+		if !reflect.DeepEqual(destDump, srcDump) {
+			t.Errorf("migrate %s: metadata does not match - got %d "+
+				"entries, want %d", destType, len(destDump),
+				len(srcDump))
+		}
+		for k := range destDump {
+			if bytes.HasPrefix([]byte(k), []byte("/ffldb-")) &&
+				destType != "ffldb" {
+
+				t.Errorf("migrate %s: internal key %q of the "+
+					"source was copied", destType, k)
+			}
+		}
+	}
+}
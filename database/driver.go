@@ -28,6 +28,20 @@ type Driver struct {
 	// ErrDbDoesNotExist if the database has not already been created.
 	Open func(args ...interface{}) (DB, error)
 
+	// OpenReadOnly is the function that will be invoked with all
+	// user-specified arguments to open the database without ever writing to
+	// it.  It is optional and may be nil when the driver does not support
+	// read-only access.  Writable transactions on the returned database must
+	// fail with ErrTxNotWritable.
+	OpenReadOnly func(args ...interface{}) (DB, error)
+
+	// Repair is the function that will be invoked with all user-specified
+	// arguments to recover a database which can't be opened because its
+	// files were damaged, such as by an unclean shutdown, and to open it.
+	// It is optional and may be nil when the driver does not support
+	// repairs.
+	Repair func(args ...interface{}) (DB, error)
+
 	// UseLogger uses a specified Logger to output package logging info.
 	UseLogger func(logger btclog.Logger)
 }
@@ -88,3 +102,48 @@ func Open(dbType string, args ...interface{}) (DB, error) {
 
 	return drv.Open(args...)
 }
+
+// OpenReadOnly opens an existing database for the specified type without ever
+// writing to it, such as a snapshot of the data directory of another node on a
+// replicated file system.  The arguments are specific to the database type
+// driver.  See the documentation for the database driver for further details.
+//
+// ErrDbUnknownType will be returned if the the database type is not registered
+// and ErrInvalid if the driver does not support read-only access.
+func OpenReadOnly(dbType string, args ...interface{}) (DB, error) {
+	drv, exists := drivers[dbType]
+	if !exists {
+		str := fmt.Sprintf("driver %q is not registered", dbType)
+		return nil, makeError(ErrDbUnknownType, str, nil)
+	}
+	if drv.OpenReadOnly == nil {
+		str := fmt.Sprintf("driver %q does not support read-only access",
+			dbType)
+		return nil, makeError(ErrInvalid, str, nil)
+	}
+
+	return drv.OpenReadOnly(args...)
+}
+
+// Repair recovers an existing database for the specified type which can't be
+// opened because its files were damaged, such as by an unclean shutdown, and
+// opens it.  Data which could not be recovered is discarded, so the caller is
+// expected to check the integrity of what it stored in the database afterwards.
+// The arguments are specific to the database type driver.  See the
+// documentation for the database driver for further details.
+//
+// ErrDbUnknownType will be returned if the the database type is not registered
+// and ErrInvalid if the driver does not support repairs.
+func Repair(dbType string, args ...interface{}) (DB, error) {
+	drv, exists := drivers[dbType]
+	if !exists {
+		str := fmt.Sprintf("driver %q is not registered", dbType)
+		return nil, makeError(ErrDbUnknownType, str, nil)
+	}
+	if drv.Repair == nil {
+		str := fmt.Sprintf("driver %q does not support repairs", dbType)
+		return nil, makeError(ErrInvalid, str, nil)
+	}
+
+	return drv.Repair(args...)
+}
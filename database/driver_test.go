@@ -113,6 +113,22 @@ func TestCreateOpenFail(t *testing.T) {
 			openError)
 		return
 	}
+
+	// Ensure opening a database with the new type read-only fails since
+	// the driver does not support it.
+	testName := "open read-only without driver support"
+	_, err = database.OpenReadOnly(dbType)
+	if !checkDbError(t, testName, err, database.ErrInvalid) {
+		return
+	}
+
+	// Ensure repairing a database with the new type fails since the driver
+	// does not support it.
+	testName = "repair without driver support"
+	_, err = database.Repair(dbType)
+	if !checkDbError(t, testName, err, database.ErrInvalid) {
+		return
+	}
 }
 
 // TestCreateOpenUnsupported ensures that attempting to create or open an
@@ -134,4 +150,20 @@ func TestCreateOpenUnsupported(t *testing.T) {
 	if !checkDbError(t, testName, err, database.ErrDbUnknownType) {
 		return
 	}
+
+	// Ensure opening a database read-only with the an unsupported type
+	// fails with the expected error.
+	testName = "open read-only with unsupported database type"
+	_, err = database.OpenReadOnly(dbType)
+	if !checkDbError(t, testName, err, database.ErrDbUnknownType) {
+		return
+	}
+
+	// Ensure repairing a database with an unsupported type fails with the
+	// expected error.
+	testName = "repair with unsupported database type"
+	_, err = database.Repair(dbType)
+	if !checkDbError(t, testName, err, database.ErrDbUnknownType) {
+		return
+	}
 }
@@ -0,0 +1,175 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bitgo/prova/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/filter"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// backupBatchSize is the number of bytes of metadata written to the
+	// backup in a single leveldb batch.
+	backupBatchSize = 16 * 1024 * 1024
+)
+
+// Enforce transaction implements the database.Backuper interface.
+var _ database.Backuper = (*transaction)(nil)
+
+// copyFile copies the first n bytes of the file at the source path to a new
+// file at the destination path and syncs it to disk.  The source file is not
+// opened when no bytes are copied, since the current block file might not have
+// been created yet.
+func copyFile(srcPath, destPath string, n int64) error {
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		src, err := os.Open(srcPath)
+		if err != nil {
+			dest.Close()
+			return err
+		}
+		_, err = io.CopyN(dest, src, n)
+		src.Close()
+		if err != nil {
+			dest.Close()
+			return err
+		}
+	}
+	if err := dest.Sync(); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// backupMetadata writes all of the metadata in the snapshot of the passed
+// transaction to a new leveldb database at the passed path.
+func (tx *transaction) backupMetadata(destPath string) error {
+	opts := opt.Options{
+		ErrorIfExist: true,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+	ldb, err := leveldb.OpenFile(destPath, &opts)
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+
+	iter := tx.snapshot.NewIterator(&util.Range{})
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	var batchSize int
+	for ok := iter.First(); ok; ok = iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		batch.Put(key, value)
+		batchSize += len(key) + len(value)
+		if batchSize < backupBatchSize {
+			continue
+		}
+
+		if err := ldb.Write(batch, nil); err != nil {
+			ldb.Close()
+			return convertErr(err.Error(), err)
+		}
+		batch.Reset()
+		batchSize = 0
+	}
+	if err := iter.Error(); err != nil {
+		ldb.Close()
+		return convertErr(err.Error(), err)
+	}
+	if err := ldb.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		ldb.Close()
+		return convertErr(err.Error(), err)
+	}
+	if err := ldb.Close(); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	return nil
+}
+
+// Backup writes a copy of the database, as seen by the transaction, to the
+// passed directory, which must not exist yet.  The metadata is copied from the
+// snapshot of the transaction into a new leveldb database.  The flat block
+// files before the one the write cursor of the snapshot points into are never
+// modified again, so they are hard linked into the backup when possible.  The
+// current file is copied up to the write cursor, which leaves out any blocks
+// stored after the snapshot was taken.
+//
+// This function is part of the database.Backuper interface implementation.
+func (tx *transaction) Backup(destPath string) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Load the write cursor from the snapshot so the block files are
+	// copied exactly up to the data the metadata refers to.
+	writeRow := tx.Metadata().Get(writeLocKeyName)
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		str := fmt.Sprintf("backup destination %q already exists",
+			destPath)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+	if err := os.MkdirAll(destPath, 0700); err != nil {
+		str := fmt.Sprintf("unable to create backup destination %q: %v",
+			destPath, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	err = tx.backupMetadata(filepath.Join(destPath, metadataDbName))
+	if err != nil {
+		return err
+	}
+
+	basePath := tx.db.store.basePath
+	for fileNum := uint32(0); fileNum <= curFileNum; fileNum++ {
+		srcPath := blockFilePath(basePath, fileNum)
+		backupPath := blockFilePath(destPath, fileNum)
+		if fileNum == curFileNum {
+			err = copyFile(srcPath, backupPath, int64(curOffset))
+		} else if err = os.Link(srcPath, backupPath); err != nil {
+			// Fall back to copying the file when it can't be
+			// linked, such as when the backup is on another file
+			// system.
+			var st os.FileInfo
+			st, err = os.Stat(srcPath)
+			if err == nil {
+				err = copyFile(srcPath, backupPath, st.Size())
+			}
+		}
+		if err != nil {
+			str := fmt.Sprintf("unable to back up block file %d: %v",
+				fileNum, err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+
+	log.Infof("Backed up the database to %s (%d block files)", destPath,
+		curFileNum+1)
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
@@ -175,6 +176,10 @@ type blockStore struct {
 	openFileFunc      func(fileNum uint32) (*lockableFile, error)
 	openWriteFileFunc func(fileNum uint32) (filer, error)
 	deleteFileFunc    func(fileNum uint32) error
+
+	// metrics houses the operation metrics of the database, which are
+	// shared with the database cache.
+	metrics *dbMetrics
 }
 
 // blockLocation identifies a particular block file and location.
@@ -406,6 +411,7 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 	// length of raw block + 4 bytes for checksum.
 	blockLen := uint32(len(rawBlock))
 	fullLen := blockLen + 12
+	start := time.Now()
 
 	// Move to the next block file if adding the new block would exceed the
 	// max allowed size for the current block file.  Also detect overflow
@@ -490,6 +496,7 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		fileOffset:   origOffset,
 		blockLen:     fullLen,
 	}
+	s.metrics.blockWrite(int(fullLen), time.Since(start))
 	return loc, nil
 }
 
@@ -515,9 +522,11 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 		return nil, err
 	}
 
+	start := time.Now()
 	serializedData := make([]byte, loc.blockLen)
 	n, err := blockFile.file.ReadAt(serializedData, int64(loc.fileOffset))
 	blockFile.RUnlock()
+	s.metrics.blockRead(n, time.Since(start))
 	if err != nil {
 		str := fmt.Sprintf("failed to read block %s from file %d, "+
 			"offset %d: %v", hash, loc.blockFileNum, loc.fileOffset,
@@ -575,9 +584,11 @@ func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32)
 	// data for a block includes an initial 4 bytes for network + 4 bytes
 	// for block length.  Thus, add 8 bytes to adjust.
 	readOffset := loc.fileOffset + 8 + offset
+	start := time.Now()
 	serializedData := make([]byte, numBytes)
-	_, err = blockFile.file.ReadAt(serializedData, int64(readOffset))
+	n, err := blockFile.file.ReadAt(serializedData, int64(readOffset))
 	blockFile.RUnlock()
+	s.metrics.blockRead(n, time.Since(start))
 	if err != nil {
 		str := fmt.Sprintf("failed to read region from block file %d, "+
 			"offset %d, len %d: %v", loc.blockFileNum, readOffset,
@@ -763,6 +774,7 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 			curFileNum: uint32(fileNum),
 			curOffset:  fileOff,
 		},
+		metrics: newDbMetrics(),
 	}
 	store.openFileFunc = store.openFile
 	store.openWriteFileFunc = store.openWriteFile
@@ -1789,6 +1789,7 @@ type db struct {
 	writeLock sync.Mutex   // Limit to one write transaction at a time.
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
+	readOnly  bool         // Is the database opened read-only?
 	store     *blockStore  // Handles read/writing blocks to flat files.
 	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
 }
@@ -1811,6 +1812,13 @@ func (db *db) Type() string {
 // which is used by the managed transaction code while the database method
 // returns the interface.
 func (db *db) begin(writable bool) (*transaction, error) {
+	// Nothing can be written to a database opened read-only.
+	if writable && db.readOnly {
+		str := "cannot begin a writable transaction on a database " +
+			"opened read-only"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
 	// Whenever a new writable transaction is started, grab the write lock
 	// to ensure only a single write transaction can be active at the same
 	// time.  This lock will not be released until the transaction is
@@ -2041,7 +2049,10 @@ func initDB(ldb *leveldb.DB) error {
 
 // openDB opens the database at the provided path.  database.ErrDbDoesNotExist
 // is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, error) {
+//
+// When the read-only flag is set, the database is opened without writing
+// anything to it, so several processes can open it read-only at the same time.
+func openDB(dbPath string, network wire.BitcoinNet, create, readOnly bool) (database.DB, error) {
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
@@ -2064,6 +2075,7 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 		Strict:       opt.DefaultStrict,
 		Compression:  opt.NoCompression,
 		Filter:       filter.NewBloomFilter(10),
+		ReadOnly:     readOnly,
 	}
 	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
 	if err != nil {
@@ -2077,9 +2089,39 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 	// write caching.
 	store := newBlockStore(dbPath, network)
 	cache := newDbCache(ldb, store, defaultCacheSize, defaultFlushSecs)
-	pdb := &db{store: store, cache: cache}
+	pdb := &db{store: store, cache: cache, readOnly: readOnly}
 
 	// Perform any reconciliation needed between the block and metadata as
 	// well as database initialization, if needed.
 	return reconcileDB(pdb, create)
 }
+
+// repairDB recovers the metadata database at the provided path, which can't be
+// opened when its files were damaged, such as by an unclean shutdown, and then
+// opens the database as usual.  The leveldb manifest is rebuilt from the table
+// files and damaged journal records are skipped instead of failing, so the most
+// recent metadata writes may be lost.  Block data written after the recovered
+// write cursor is rolled back when the database is opened.
+func repairDB(dbPath string, network wire.BitcoinNet) (database.DB, error) {
+	metadataDbPath := filepath.Join(dbPath, metadataDbName)
+	if !fileExists(metadataDbPath) {
+		str := fmt.Sprintf("database %q does not exist", metadataDbPath)
+		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+
+	log.Infof("Recovering metadata database %s", metadataDbPath)
+	opts := opt.Options{
+		Strict:      opt.NoStrict,
+		Compression: opt.NoCompression,
+		Filter:      filter.NewBloomFilter(10),
+	}
+	ldb, err := leveldb.RecoverFile(metadataDbPath, &opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	if err := ldb.Close(); err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+
+	return openDB(dbPath, network, false, false)
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitgo/prova/database/internal/treap"
@@ -289,19 +290,23 @@ type dbCacheSnapshot struct {
 	dbSnapshot    *leveldb.Snapshot
 	pendingKeys   *treap.Immutable
 	pendingRemove *treap.Immutable
+	metrics       *dbMetrics
 }
 
 // Has returns whether or not the passed key exists.
 func (snap *dbCacheSnapshot) Has(key []byte) bool {
 	// Check the cached entries first.
 	if snap.pendingRemove.Has(key) {
+		snap.metrics.metadataRead(true)
 		return false
 	}
 	if snap.pendingKeys.Has(key) {
+		snap.metrics.metadataRead(true)
 		return true
 	}
 
 	// Consult the database.
+	snap.metrics.metadataRead(false)
 	hasKey, _ := snap.dbSnapshot.Has(key, nil)
 	return hasKey
 }
@@ -311,13 +316,16 @@ func (snap *dbCacheSnapshot) Has(key []byte) bool {
 func (snap *dbCacheSnapshot) Get(key []byte) []byte {
 	// Check the cached entries first.
 	if snap.pendingRemove.Has(key) {
+		snap.metrics.metadataRead(true)
 		return nil
 	}
 	if value := snap.pendingKeys.Get(key); value != nil {
+		snap.metrics.metadataRead(true)
 		return value
 	}
 
 	// Consult the database.
+	snap.metrics.metadataRead(false)
 	value, err := snap.dbSnapshot.Get(key, nil)
 	if err != nil {
 		return nil
@@ -411,6 +419,7 @@ func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
 		dbSnapshot:    dbSnapshot,
 		pendingKeys:   c.cachedKeys,
 		pendingRemove: c.cachedRemove,
+		metrics:       c.store.metrics,
 	}
 	c.cacheLock.RUnlock()
 	return cacheSnapshot, nil
@@ -422,11 +431,14 @@ func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
 // Otherwise, the transaction is committed when the user-supplied function
 // returns a nil error.
 func (c *dbCache) updateDB(fn func(ldbTx *leveldb.Transaction) error) error {
-	// Start a leveldb transaction.
+	// Start a leveldb transaction.  Opening it waits for any compactions
+	// the writes have to wait for, so its duration is tracked as a stall.
+	start := time.Now()
 	ldbTx, err := c.ldb.OpenTransaction()
 	if err != nil {
 		return convertErr("failed to open ldb transaction", err)
 	}
+	c.store.metrics.transactionOpened(time.Since(start))
 
 	if err := fn(ldbTx); err != nil {
 		ldbTx.Discard()
@@ -511,9 +523,12 @@ func (c *dbCache) flush() error {
 	}
 
 	// Perform all leveldb updates using an atomic transaction.
+	start := time.Now()
 	if err := c.commitTreaps(cachedKeys, cachedRemove); err != nil {
 		return err
 	}
+	atomic.AddUint64(&c.store.metrics.cacheFlushes, 1)
+	c.store.metrics.flushLatency.observe(time.Since(start))
 
 	// Clear the cache since it has been flushed.
 	c.cacheLock.Lock()
@@ -566,6 +581,8 @@ func (c *dbCache) needsFlush(tx *transaction) bool {
 // This function MUST be called during a database write transaction which in
 // turn implies the database write lock will be held.
 func (c *dbCache) commitTx(tx *transaction) error {
+	numWrites := uint64(tx.pendingKeys.Len() + tx.pendingRemove.Len())
+
 	// Flush the cache and write the current transaction directly to the
 	// database if a flush is needed.
 	if c.needsFlush(tx) {
@@ -582,6 +599,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 		// Clear the transaction entries since they have been committed.
 		tx.pendingKeys = nil
 		tx.pendingRemove = nil
+		atomic.AddUint64(&c.store.metrics.metadataWrites, numWrites)
 		return nil
 	}
 
@@ -618,6 +636,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 	c.cachedKeys = newCachedKeys
 	c.cachedRemove = newCachedRemove
 	c.cacheLock.Unlock()
+	atomic.AddUint64(&c.store.metrics.metadataWrites, numWrites)
 	return nil
 }
 
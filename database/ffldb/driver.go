@@ -50,7 +50,29 @@ func openDBDriver(args ...interface{}) (database.DB, error) {
 		return nil, err
 	}
 
-	return openDB(dbPath, network, false)
+	return openDB(dbPath, network, false, false)
+}
+
+// openDBReadOnlyDriver is the callback provided during driver registration that
+// opens an existing database for read-only use.
+func openDBReadOnlyDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("OpenReadOnly", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDB(dbPath, network, false, true)
+}
+
+// repairDBDriver is the callback provided during driver registration that
+// recovers an existing database which can't be opened and opens it.
+func repairDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, err := parseArgs("Repair", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return repairDB(dbPath, network)
 }
 
 // createDBDriver is the callback provided during driver registration that
@@ -61,7 +83,7 @@ func createDBDriver(args ...interface{}) (database.DB, error) {
 		return nil, err
 	}
 
-	return openDB(dbPath, network, true)
+	return openDB(dbPath, network, true, false)
 }
 
 // useLogger is the callback provided during driver registration that sets the
@@ -73,10 +95,12 @@ func useLogger(logger btclog.Logger) {
 func init() {
 	// Register the driver.
 	driver := database.Driver{
-		DbType:    dbType,
-		Create:    createDBDriver,
-		Open:      openDBDriver,
-		UseLogger: useLogger,
+		DbType:       dbType,
+		Create:       createDBDriver,
+		Open:         openDBDriver,
+		OpenReadOnly: openDBReadOnlyDriver,
+		Repair:       repairDBDriver,
+		UseLogger:    useLogger,
 	}
 	if err := database.RegisterDriver(driver); err != nil {
 		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
@@ -7,6 +7,7 @@ package ffldb_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -14,9 +15,11 @@ import (
 	"testing"
 
 	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/database/ffldb"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
 )
 
 // dbType is the database type name for this driver.
@@ -287,3 +290,348 @@ func TestInterface(t *testing.T) {
 		testInterface(t, db)
 	})
 }
+
+// TestBackup ensures a backup taken while the database is in use contains the
+// metadata and blocks as of the transaction it was taken with, and can be
+// opened as a database of its own.
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-backuptest")
+	backupPath := filepath.Join(os.TempDir(), "ffldb-backuptest-backup")
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer db.Close()
+
+	// Each block is large enough that a few of them fill a block file.
+	var blocks []*provautil.Block
+	for i := 0; i < 20; i++ {
+		msgBlock := wire.NewMsgBlock(wire.NewBlockHeader(
+			&chainhash.Hash{byte(i)}, &chainhash.Hash{}, 0, 0))
+		tx := wire.NewMsgTx(1)
+		tx.AddTxOut(wire.NewTxOut(int64(i), make([]byte, 500)))
+		msgBlock.AddTransaction(tx)
+		blocks = append(blocks, provautil.NewBlock(msgBlock))
+	}
+	backedUp, later := blocks[:len(blocks)/2], blocks[len(blocks)/2:]
+	storeBlocks := func(blocks []*provautil.Block, key []byte) error {
+		return db.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return tx.Metadata().Put(key, []byte("value"))
+		})
+	}
+
+	// Store half of the blocks across several block files, take a backup,
+	// and store the other half.
+	ffldb.TstRunWithMaxBlockFileSize(db, 2048, func() {
+		if err := storeBlocks(backedUp, []byte("backedup")); err != nil {
+			t.Errorf("StoreBlock: unexpected error: %v", err)
+			return
+		}
+		err = db.View(func(tx database.Tx) error {
+			return tx.(database.Backuper).Backup(backupPath)
+		})
+		if err != nil {
+			t.Errorf("Backup: unexpected error: %v", err)
+			return
+		}
+		if err := storeBlocks(later, []byte("later")); err != nil {
+			t.Errorf("StoreBlock: unexpected error: %v", err)
+		}
+	})
+	if t.Failed() {
+		return
+	}
+
+	// Ensure a backup is not written over an existing directory.
+	err = db.View(func(tx database.Tx) error {
+		return tx.(database.Backuper).Backup(backupPath)
+	})
+	if !checkDbError(t, "Backup", err, database.ErrDbExists) {
+		return
+	}
+
+	// Ensure the backup only has the data stored before it was taken.
+	backupDB, err := database.Open(dbType, backupPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to open backup database (%s) %v", dbType, err)
+		return
+	}
+	defer backupDB.Close()
+	err = backupDB.View(func(tx database.Tx) error {
+		if tx.Metadata().Get([]byte("backedup")) == nil {
+			return fmt.Errorf("Get: missing key stored before the " +
+				"backup")
+		}
+		if tx.Metadata().Get([]byte("later")) != nil {
+			return fmt.Errorf("Get: found key stored after the " +
+				"backup")
+		}
+		for i, block := range blocks {
+			wantBlock := i < len(backedUp)
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if !wantBlock {
+				if err == nil {
+					return fmt.Errorf("FetchBlock: found "+
+						"block %d stored after the "+
+						"backup", i)
+				}
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("FetchBlock: unexpected "+
+					"error for block %d: %v", i, err)
+			}
+			wantBytes, _ := block.Bytes()
+			if !reflect.DeepEqual(gotBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock: block %d "+
+					"mismatch", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+	}
+}
+
+// TestOpenReadOnly ensures a database opened read-only serves the data stored
+// in it, refuses writes, and can be opened read-only by several handles at the
+// same time.
+func TestOpenReadOnly(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database with a block and a key stored in it.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-readonlytest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	block := provautil.NewBlock(wire.NewMsgBlock(wire.NewBlockHeader(
+		&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)))
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(block); err != nil {
+			return err
+		}
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	db.Close()
+	if err != nil {
+		t.Errorf("Update: unexpected error: %v", err)
+		return
+	}
+
+	// Ensure the database can be opened read-only twice.
+	db, err = database.OpenReadOnly(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("OpenReadOnly: unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+	db2, err := database.OpenReadOnly(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("OpenReadOnly: unexpected error opening a second "+
+			"handle: %v", err)
+		return
+	}
+	db2.Close()
+
+	// Ensure the stored data is served.
+	err = db.View(func(tx database.Tx) error {
+		if tx.Metadata().Get([]byte("key")) == nil {
+			return fmt.Errorf("Get: missing key")
+		}
+		_, err := tx.FetchBlock(block.Hash())
+		return err
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+		return
+	}
+
+	// Ensure writes are refused.
+	err = db.Update(func(tx database.Tx) error {
+		return tx.Metadata().Put([]byte("key2"), []byte("value"))
+	})
+	if !checkDbError(t, "Update", err, database.ErrTxNotWritable) {
+		return
+	}
+}
+
+// TestRepair ensures a database whose metadata can't be opened after its
+// manifest was damaged is recovered with the data stored in it.
+func TestRepair(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database with a block and a key stored in it.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-repairtest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	block := provautil.NewBlock(wire.NewMsgBlock(wire.NewBlockHeader(
+		&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)))
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(block); err != nil {
+			return err
+		}
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	db.Close()
+	if err != nil {
+		t.Errorf("Update: unexpected error: %v", err)
+		return
+	}
+
+	// Damage the manifest of the metadata database.
+	manifests, err := filepath.Glob(filepath.Join(dbPath, "metadata",
+		"MANIFEST-*"))
+	if err != nil || len(manifests) == 0 {
+		t.Errorf("Glob: no manifest found (%v)", err)
+		return
+	}
+	for _, manifest := range manifests {
+		err := ioutil.WriteFile(manifest, []byte("garbage"), 0600)
+		if err != nil {
+			t.Errorf("WriteFile: unexpected error: %v", err)
+			return
+		}
+	}
+
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open", err, database.ErrCorruption) {
+		return
+	}
+	db, err = database.Repair(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Repair: unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+	err = db.View(func(tx database.Tx) error {
+		if tx.Metadata().Get([]byte("key")) == nil {
+			return fmt.Errorf("Get: missing key")
+		}
+		_, err := tx.FetchBlock(block.Hash())
+		return err
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+	}
+}
+
+// TestStatsAndCompact ensures the operation metrics of the database account for
+// the blocks and metadata written and read, and that the database can be
+// compacted while it remains usable.
+func TestStatsAndCompact(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(os.TempDir(), "ffldb-statstest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+	maintainer, ok := db.(database.Maintainer)
+	if !ok {
+		t.Errorf("database does not implement database.Maintainer")
+		return
+	}
+
+	block := provautil.NewBlock(wire.NewMsgBlock(wire.NewBlockHeader(
+		&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)))
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(block); err != nil {
+			return err
+		}
+		return tx.Metadata().Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Errorf("Update: unexpected error: %v", err)
+		return
+	}
+	before, err := maintainer.Stats()
+	if err != nil {
+		t.Errorf("Stats: unexpected error: %v", err)
+		return
+	}
+
+	// The key is served from the cache until the compaction flushes it.
+	readKey := func() error {
+		return db.View(func(tx database.Tx) error {
+			if tx.Metadata().Get([]byte("key")) == nil {
+				return fmt.Errorf("Get: missing key")
+			}
+			_, err := tx.FetchBlock(block.Hash())
+			return err
+		})
+	}
+	if err := readKey(); err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+		return
+	}
+	if err := maintainer.Compact(); err != nil {
+		t.Errorf("Compact: unexpected error: %v", err)
+		return
+	}
+	if err := readKey(); err != nil {
+		t.Errorf("View: unexpected error after compaction: %v", err)
+		return
+	}
+
+	stats, err := maintainer.Stats()
+	if err != nil {
+		t.Errorf("Stats: unexpected error: %v", err)
+		return
+	}
+	if stats.BlockWrites != 1 || stats.BlockWriteLatency.Count != 1 ||
+		stats.BlockWriteBytes != uint64(block.MsgBlock().SerializeSize()+12) {
+
+		t.Errorf("Stats: got %d block writes of %d bytes, want 1",
+			stats.BlockWrites, stats.BlockWriteBytes)
+	}
+	if stats.BlockReads-before.BlockReads != 2 {
+		t.Errorf("Stats: got %d block reads, want 2",
+			stats.BlockReads-before.BlockReads)
+	}
+	if stats.CacheHits-before.CacheHits < 1 ||
+		stats.MetadataReads-before.MetadataReads < 2 {
+
+		t.Errorf("Stats: got %d metadata reads with %d cache hits, "+
+			"want at least 2 with 1 cache hit",
+			stats.MetadataReads-before.MetadataReads,
+			stats.CacheHits-before.CacheHits)
+	}
+	if stats.CacheFlushes == before.CacheFlushes {
+		t.Errorf("Stats: the compaction did not flush the cache")
+	}
+	if stats.MetadataWrites == 0 {
+		t.Errorf("Stats: no metadata writes recorded")
+	}
+	if len(stats.BlockReadLatency.Buckets) != len(database.LatencyBuckets)+1 {
+		t.Errorf("Stats: got %d latency buckets, want %d",
+			len(stats.BlockReadLatency.Buckets),
+			len(database.LatencyBuckets)+1)
+	}
+}
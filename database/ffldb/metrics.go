@@ -0,0 +1,252 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/database"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+// writeStallThreshold is the amount of time opening a leveldb transaction may
+// take before the write is considered stalled.  Opening a transaction waits
+// for the memory table to be compacted into a table file, and for the table
+// files of the first level to be compacted when there are too many of them.
+const writeStallThreshold = 10 * time.Millisecond
+
+// Enforce db implements the database.Maintainer interface.
+var _ database.Maintainer = (*db)(nil)
+
+// latencyHistogram collects the distribution of the latencies of an operation.
+// The counters are updated atomically, so it is safe for concurrent access.
+type latencyHistogram struct {
+	count   uint64
+	total   int64
+	max     int64
+	buckets []uint64
+}
+
+// newLatencyHistogram returns a new latency histogram with a bucket for each of
+// the database.LatencyBuckets plus one for the latencies above them.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		buckets: make([]uint64, len(database.LatencyBuckets)+1),
+	}
+}
+
+// observe records an operation which took the passed duration.
+func (h *latencyHistogram) observe(d time.Duration) {
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.total, int64(d))
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&h.max, max,
+			int64(d)) {
+
+			break
+		}
+	}
+
+	bucket := len(database.LatencyBuckets)
+	for i, bound := range database.LatencyBuckets {
+		if d <= bound {
+			bucket = i
+			break
+		}
+	}
+	atomic.AddUint64(&h.buckets[bucket], 1)
+}
+
+// snapshot returns the current state of the histogram.
+func (h *latencyHistogram) snapshot() database.LatencyHistogram {
+	buckets := make([]uint64, len(h.buckets))
+	for i := range h.buckets {
+		buckets[i] = atomic.LoadUint64(&h.buckets[i])
+	}
+	return database.LatencyHistogram{
+		Count:   atomic.LoadUint64(&h.count),
+		Total:   time.Duration(atomic.LoadInt64(&h.total)),
+		Max:     time.Duration(atomic.LoadInt64(&h.max)),
+		Buckets: buckets,
+	}
+}
+
+// dbMetrics houses the operation metrics of the database which are collected
+// by the block store and the database cache.  The counters are updated
+// atomically, so it is safe for concurrent access.
+type dbMetrics struct {
+	metadataReads   uint64
+	cacheHits       uint64
+	metadataWrites  uint64
+	cacheFlushes    uint64
+	writeStalls     uint64
+	writeStallTime  int64
+	blockReads      uint64
+	blockReadBytes  uint64
+	blockWrites     uint64
+	blockWriteBytes uint64
+
+	flushLatency      *latencyHistogram
+	blockReadLatency  *latencyHistogram
+	blockWriteLatency *latencyHistogram
+}
+
+// newDbMetrics returns new database metrics with all counters set to zero.
+func newDbMetrics() *dbMetrics {
+	return &dbMetrics{
+		flushLatency:      newLatencyHistogram(),
+		blockReadLatency:  newLatencyHistogram(),
+		blockWriteLatency: newLatencyHistogram(),
+	}
+}
+
+// metadataRead records a metadata read and whether it was served from the
+// database cache.
+func (m *dbMetrics) metadataRead(cacheHit bool) {
+	atomic.AddUint64(&m.metadataReads, 1)
+	if cacheHit {
+		atomic.AddUint64(&m.cacheHits, 1)
+	}
+}
+
+// transactionOpened records the opening of a leveldb transaction which took
+// the passed duration, which is a write stall when it exceeds the
+// writeStallThreshold.
+func (m *dbMetrics) transactionOpened(d time.Duration) {
+	if d < writeStallThreshold {
+		return
+	}
+	atomic.AddUint64(&m.writeStalls, 1)
+	atomic.AddInt64(&m.writeStallTime, int64(d))
+}
+
+// blockRead records a read of the passed number of bytes from the flat block
+// files which took the passed duration.
+func (m *dbMetrics) blockRead(numBytes int, d time.Duration) {
+	atomic.AddUint64(&m.blockReads, 1)
+	atomic.AddUint64(&m.blockReadBytes, uint64(numBytes))
+	m.blockReadLatency.observe(d)
+}
+
+// blockWrite records a write of the passed number of bytes to the flat block
+// files which took the passed duration.
+func (m *dbMetrics) blockWrite(numBytes int, d time.Duration) {
+	atomic.AddUint64(&m.blockWrites, 1)
+	atomic.AddUint64(&m.blockWriteBytes, uint64(numBytes))
+	m.blockWriteLatency.observe(d)
+}
+
+// snapshot returns the current values of the metrics.
+func (m *dbMetrics) snapshot() *database.Stats {
+	return &database.Stats{
+		MetadataReads:     atomic.LoadUint64(&m.metadataReads),
+		CacheHits:         atomic.LoadUint64(&m.cacheHits),
+		MetadataWrites:    atomic.LoadUint64(&m.metadataWrites),
+		CacheFlushes:      atomic.LoadUint64(&m.cacheFlushes),
+		FlushLatency:      m.flushLatency.snapshot(),
+		WriteStalls:       atomic.LoadUint64(&m.writeStalls),
+		WriteStallTime:    time.Duration(atomic.LoadInt64(&m.writeStallTime)),
+		BlockReads:        atomic.LoadUint64(&m.blockReads),
+		BlockReadBytes:    atomic.LoadUint64(&m.blockReadBytes),
+		BlockReadLatency:  m.blockReadLatency.snapshot(),
+		BlockWrites:       atomic.LoadUint64(&m.blockWrites),
+		BlockWriteBytes:   atomic.LoadUint64(&m.blockWriteBytes),
+		BlockWriteLatency: m.blockWriteLatency.snapshot(),
+	}
+}
+
+// parseCompactionStats returns the number of bytes read and written by the
+// compactions of all levels in the passed value of the leveldb.stats property,
+// which reports them in megabytes for each level in a table such as:
+//
+//	Compactions
+//	 Level |   Tables   |    Size(MB)   |    Time(sec)  |    Read(MB)   |   Write(MB)
+//	-------+------------+---------------+---------------+---------------+---------------
+//	   0   |          1 |       0.00105 |       0.01234 |       0.00000 |       0.00105
+func parseCompactionStats(stats string) (uint64, uint64) {
+	var read, write float64
+	scanner := bufio.NewScanner(strings.NewReader(stats))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 6 {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(fields[0])); err != nil {
+			continue
+		}
+		levelRead, err := strconv.ParseFloat(strings.TrimSpace(fields[4]), 64)
+		if err != nil {
+			continue
+		}
+		levelWrite, err := strconv.ParseFloat(strings.TrimSpace(fields[5]), 64)
+		if err != nil {
+			continue
+		}
+		read += levelRead
+		write += levelWrite
+	}
+	return uint64(read * 1048576), uint64(write * 1048576)
+}
+
+// Stats returns the operation metrics collected since the database was opened.
+// The disk I/O of the metadata is taken from the compaction statistics
+// leveldb reports.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) Stats() (*database.Stats, error) {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	compactionStats, err := db.cache.ldb.GetProperty("leveldb.stats")
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	stats := db.store.metrics.snapshot()
+	stats.StorageReadBytes, stats.StorageWriteBytes =
+		parseCompactionStats(compactionStats)
+	return stats, nil
+}
+
+// Compact flushes the database cache and then compacts all levels of the
+// leveldb database which houses the metadata.  The flat block files are never
+// modified once written, so they don't need to be compacted.  The database
+// can't be closed until the compaction is done.
+//
+// This function is part of the database.Maintainer interface implementation.
+func (db *db) Compact() error {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+	if db.readOnly {
+		str := "cannot compact a database opened read-only"
+		return makeDbErr(database.ErrInvalid, str, nil)
+	}
+
+	// Flush the cache so the cached metadata is compacted as well.
+	db.writeLock.Lock()
+	err := db.cache.flush()
+	db.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Compacting the metadata database")
+	start := time.Now()
+	if err := db.cache.ldb.CompactRange(util.Range{}); err != nil {
+		return convertErr(err.Error(), err)
+	}
+	log.Infof("Compacted the metadata database in %v", time.Since(start))
+	return nil
+}
@@ -83,9 +83,12 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// the middle of being written.  Since the metadata isn't updated until
 	// after the block data is written, this is effectively just a rollback
 	// to the known good point before the unclean shutdown.
+	//
+	// A database opened read-only is left alone, since the block data past
+	// the position in the metadata is never read anyways.
 	wc := pdb.store.writeCursor
-	if wc.curFileNum > curFileNum || (wc.curFileNum == curFileNum &&
-		wc.curOffset > curOffset) {
+	if !pdb.readOnly && (wc.curFileNum > curFileNum ||
+		(wc.curFileNum == curFileNum && wc.curOffset > curOffset)) {
 
 		log.Info("Detected unclean shutdown - Repairing...")
 		log.Debugf("Metadata claims file %d, offset %d. Block data is "+
@@ -161,6 +161,29 @@ func TestConvertErr(t *testing.T) {
 	}
 }
 
+// TestParseCompactionStats ensures the compaction disk I/O is summed over all
+// levels of the leveldb.stats property.
+func TestParseCompactionStats(t *testing.T) {
+	t.Parallel()
+
+	stats := "Compactions\n" +
+		" Level |   Tables   |    Size(MB)   |    Time(sec)  |    Read(MB)   |   Write(MB)\n" +
+		"-------+------------+---------------+---------------+---------------+---------------\n" +
+		"   0   |          2 |       1.50000 |       0.25000 |       0.00000 |       1.50000\n" +
+		"   1   |          4 |       6.00000 |       1.00000 |       2.00000 |       2.50000\n"
+	read, write := parseCompactionStats(stats)
+	if read != 2*1048576 || write != 4*1048576 {
+		t.Fatalf("parseCompactionStats: got read %d write %d, want "+
+			"%d and %d", read, write, 2*1048576, 4*1048576)
+	}
+
+	read, write = parseCompactionStats("Compactions\n")
+	if read != 0 || write != 0 {
+		t.Fatalf("parseCompactionStats: got read %d write %d without "+
+			"compactions, want 0", read, write)
+	}
+}
+
 // TestCornerCases ensures several corner cases which can happen when opening
 // a database and/or block files work as expected.
 func TestCornerCases(t *testing.T) {
@@ -180,7 +203,7 @@ func TestCornerCases(t *testing.T) {
 	// directory is needed.
 	testName := "openDB: fail due to file at target location"
 	wantErrCode := database.ErrDriverSpecific
-	idb, err := openDB(dbPath, blockDataNet, true)
+	idb, err := openDB(dbPath, blockDataNet, true, false)
 	if !checkDbError(t, testName, err, wantErrCode) {
 		if err == nil {
 			idb.Close()
@@ -192,7 +215,7 @@ func TestCornerCases(t *testing.T) {
 	// Remove the file and create the database to run tests against.  It
 	// should be successful this time.
 	_ = os.RemoveAll(dbPath)
-	idb, err = openDB(dbPath, blockDataNet, true)
+	idb, err = openDB(dbPath, blockDataNet, true, false)
 	if err != nil {
 		t.Errorf("openDB: unexpected error: %v", err)
 		return
@@ -465,3 +465,42 @@ type DB interface {
 	// back or committed).
 	Close() error
 }
+
+// Backuper is an optional interface implemented by the transactions of
+// databases which support taking a backup while the database is in use.  A
+// read-only transaction can be type asserted to a Backuper to determine
+// whether backups are supported.
+type Backuper interface {
+	// Backup writes a copy of the database, as seen by the transaction,
+	// to the passed directory, which must not exist yet.  Other
+	// transactions can be used while the copy is made.  The copy can be
+	// opened with the same driver as the database.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	Backup(destPath string) error
+}
+
+// Maintainer is an optional interface implemented by databases which report
+// operation metrics and support compacting their storage on demand.  A database
+// can be type asserted to a Maintainer to determine whether they are supported.
+type Maintainer interface {
+	// Stats returns the operation metrics collected since the database was
+	// opened.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrDbNotOpen if the database is not open
+	Stats() (*Stats, error)
+
+	// Compact writes any cached data to storage and then compacts the
+	// storage of the metadata, which reclaims the space of deleted and
+	// overwritten entries and speeds up reads at the cost of heavy disk
+	// I/O while it runs.  Other transactions can be used meanwhile.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrDbNotOpen if the database is not open
+	Compact() error
+}
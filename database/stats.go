@@ -0,0 +1,94 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of latency histograms.
+// Latencies above the last bound are counted in an additional bucket.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram describes the distribution of the latencies of a database
+// operation.
+type LatencyHistogram struct {
+	// Count is the number of operations.
+	Count uint64
+
+	// Total and Max are the total and the highest latency of the
+	// operations.
+	Total time.Duration
+	Max   time.Duration
+
+	// Buckets are the number of operations with a latency up to each of
+	// the LatencyBuckets, and above the last of them in the final entry.
+	Buckets []uint64
+}
+
+// Mean returns the mean latency of the operations.
+func (h *LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// Stats houses the operation metrics of a database collected since it was
+// opened.  See the Maintainer interface.
+type Stats struct {
+	// MetadataReads is the number of metadata keys read, of which
+	// CacheHits were served from the write cache of the database rather
+	// than from storage.
+	MetadataReads uint64
+	CacheHits     uint64
+
+	// MetadataWrites is the number of metadata keys stored or deleted by
+	// committed transactions.
+	MetadataWrites uint64
+
+	// CacheFlushes is the number of times the write cache was written to
+	// storage, and FlushLatency the distribution of their durations.
+	CacheFlushes uint64
+	FlushLatency LatencyHistogram
+
+	// WriteStalls is the number of times writes to the metadata storage
+	// were delayed because compactions fell behind, and WriteStallTime the
+	// total time they were delayed.  Drivers which can't tell why a write
+	// was delayed count the writes which took noticeably long.
+	WriteStalls    uint64
+	WriteStallTime time.Duration
+
+	// StorageReadBytes and StorageWriteBytes are the number of bytes the
+	// compactions of the metadata storage read from and wrote to disk.
+	StorageReadBytes  uint64
+	StorageWriteBytes uint64
+
+	// BlockReads and BlockWrites are the number of block reads and writes
+	// of the flat block files, BlockReadBytes and BlockWriteBytes the
+	// number of bytes they transferred, and BlockReadLatency and
+	// BlockWriteLatency the distributions of their durations.
+	BlockReads        uint64
+	BlockReadBytes    uint64
+	BlockReadLatency  LatencyHistogram
+	BlockWrites       uint64
+	BlockWriteBytes   uint64
+	BlockWriteLatency LatencyHistogram
+}
+
+// CacheHitRate returns the fraction of the metadata reads which were served
+// from the write cache.
+func (s *Stats) CacheHitRate() float64 {
+	if s.MetadataReads == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.MetadataReads)
+}
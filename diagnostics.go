@@ -0,0 +1,421 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+)
+
+const (
+	// maxDiagnosticsGCPauses is the maximum number of recent garbage
+	// collection pauses reported by getdiagnostics.
+	maxDiagnosticsGCPauses = 16
+
+	// profileDirname is the directory under the data directory the profiles
+	// requested through the RPC server are written to.
+	profileDirname = "profiles"
+)
+
+var (
+	// errCPUProfileActive describes an error in which a CPU profile is
+	// started while another one is being written.
+	errCPUProfileActive = errors.New("a CPU profile is already being " +
+		"written")
+
+	// errNoCPUProfile describes an error in which a CPU profile is stopped
+	// while none is being written.
+	errNoCPUProfile = errors.New("no CPU profile is being written")
+)
+
+// profiler controls the profiles written and the HTTP profiling server run by
+// the node.  They are started from the command line with --cpuprofile and
+// --profile, and can be started and stopped at runtime through the RPC
+// server.
+var profiler profileController
+
+// profileController writes CPU profiles and runs the HTTP profiling server
+// while the node is running.  It is safe for concurrent access.
+type profileController struct {
+	mtx            sync.Mutex
+	cpuProfile     *os.File
+	serverListener net.Listener
+}
+
+// validProfilePort returns an error when the passed HTTP profiling server port
+// is not between 1024 and 65535.
+func validProfilePort(port string) error {
+	profilePort, err := strconv.Atoi(port)
+	if err != nil || profilePort < 1024 || profilePort > 65535 {
+		return errors.New("the profile port must be between 1024 and " +
+			"65535")
+	}
+	return nil
+}
+
+// newProfileServeMux returns the handler of the HTTP profiling server, which
+// serves the pprof endpoints under /debug/pprof/ and redirects everything else
+// there.
+func newProfileServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	mux.Handle("/", http.RedirectHandler("/debug/pprof/",
+		http.StatusSeeOther))
+	return mux
+}
+
+// StartServer starts the HTTP profiling server on the passed port of all
+// interfaces and returns the address it listens on.
+func (p *profileController) StartServer(port string) (string, error) {
+	if err := validProfilePort(port); err != nil {
+		return "", err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.serverListener != nil {
+		return "", fmt.Errorf("the profile server is already listening "+
+			"on %s", p.serverListener.Addr())
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("", port))
+	if err != nil {
+		return "", err
+	}
+	p.serverListener = listener
+
+	addr := listener.Addr().String()
+	btcdLog.Infof("Profile server listening on %s", addr)
+	go func() {
+		// Serve returns once the listener is closed by StopServer.
+		err := http.Serve(listener, newProfileServeMux())
+		p.mtx.Lock()
+		stopped := p.serverListener != listener
+		p.mtx.Unlock()
+		if !stopped {
+			btcdLog.Errorf("Profile server: %v", err)
+		}
+	}()
+	return addr, nil
+}
+
+// StopServer stops the HTTP profiling server.  It does nothing when the server
+// is not running.
+func (p *profileController) StopServer() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.serverListener == nil {
+		return nil
+	}
+	listener := p.serverListener
+	p.serverListener = nil
+	btcdLog.Infof("Profile server on %s stopped", listener.Addr())
+	return listener.Close()
+}
+
+// ServerAddr returns the address the HTTP profiling server listens on, or an
+// empty string when it is not running.
+func (p *profileController) ServerAddr() string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.serverListener == nil {
+		return ""
+	}
+	return p.serverListener.Addr().String()
+}
+
+// StartCPUProfile starts writing a CPU profile to the passed file, which is
+// closed when the profile is stopped.  The file is closed and removed when the
+// profile can not be started.
+func (p *profileController) StartCPUProfile(f *os.File) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.cpuProfile != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errCPUProfileActive
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	p.cpuProfile = f
+	btcdLog.Infof("Writing CPU profile to %s", f.Name())
+	return nil
+}
+
+// StopCPUProfile stops writing the CPU profile and returns the file it was
+// written to.
+func (p *profileController) StopCPUProfile() (string, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.cpuProfile == nil {
+		return "", errNoCPUProfile
+	}
+	pprof.StopCPUProfile()
+	path := p.cpuProfile.Name()
+	err := p.cpuProfile.Close()
+	p.cpuProfile = nil
+	btcdLog.Infof("Wrote CPU profile to %s", path)
+	return path, err
+}
+
+// CPUProfilePath returns the file the CPU profile is being written to, or an
+// empty string when none is.
+func (p *profileController) CPUProfilePath() string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.cpuProfile == nil {
+		return ""
+	}
+	return p.cpuProfile.Name()
+}
+
+// profileNames returns the sorted names of the runtime profiles which can be
+// written with writeProfile.
+func profileNames() []string {
+	profiles := pprof.Profiles()
+	names := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		names = append(names, profile.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// createProfileFile creates the passed profile file for writing.  It fails when
+// the file exists already, so existing files are never overwritten.
+func createProfileFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+}
+
+// writeProfile writes the named runtime profile, such as heap or goroutine, to
+// the passed file, which must not exist yet.  Goroutine profiles are written as
+// the text dump of the stacks of all goroutines, as on a crash, while the
+// others are written in the binary format read by go tool pprof.
+func writeProfile(name, path string) error {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q -- valid profiles are %v",
+			name, profileNames())
+	}
+	debugLevel := 0
+	if name == "goroutine" {
+		debugLevel = 2
+	}
+	f, err := createProfileFile(path)
+	if err != nil {
+		return err
+	}
+	if err := profile.WriteTo(f, debugLevel); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// diagnosticsResult returns the memory, goroutine and garbage collection
+// statistics of the node along with the state of the profiler.
+func diagnosticsResult() *btcjson.GetDiagnosticsResult {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	var gcStats debug.GCStats
+	debug.ReadGCStats(&gcStats)
+
+	// The pauses are reported most recent first.
+	numPauses := len(gcStats.Pause)
+	if numPauses > maxDiagnosticsGCPauses {
+		numPauses = maxDiagnosticsGCPauses
+	}
+	recentPauses := make([]int64, 0, numPauses)
+	for _, pause := range gcStats.Pause[:numPauses] {
+		recentPauses = append(recentPauses, int64(pause/time.Microsecond))
+	}
+	var lastGC int64
+	if !gcStats.LastGC.IsZero() {
+		lastGC = gcStats.LastGC.Unix()
+	}
+
+	return &btcjson.GetDiagnosticsResult{
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GoMaxProcs: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: btcjson.DiagnosticsMemoryResult{
+			Alloc:        memStats.Alloc,
+			TotalAlloc:   memStats.TotalAlloc,
+			Sys:          memStats.Sys,
+			HeapAlloc:    memStats.HeapAlloc,
+			HeapInuse:    memStats.HeapInuse,
+			HeapIdle:     memStats.HeapIdle,
+			HeapReleased: memStats.HeapReleased,
+			HeapObjects:  memStats.HeapObjects,
+			StackInuse:   memStats.StackInuse,
+			Mallocs:      memStats.Mallocs,
+			Frees:        memStats.Frees,
+		},
+		GC: btcjson.DiagnosticsGCResult{
+			NumGC:         gcStats.NumGC,
+			LastGC:        lastGC,
+			NextGC:        memStats.NextGC,
+			PauseTotal:    int64(gcStats.PauseTotal / time.Microsecond),
+			RecentPauses:  recentPauses,
+			GCCPUFraction: memStats.GCCPUFraction,
+		},
+		CPUProfile:    profiler.CPUProfilePath(),
+		ProfileServer: profiler.ServerAddr(),
+	}
+}
+
+// profileFilePath returns the path of the passed profile file, which is
+// relative to the profile directory under the data directory.  Absolute paths
+// and paths leaving the profile directory are rejected, so RPC clients can only
+// write profiles to that directory.  The directories of the file are created
+// when needed.
+func profileFilePath(file string) (string, error) {
+	if file == "" || filepath.IsAbs(file) {
+		return "", errors.New("the file must be a path relative to the " +
+			"profile directory")
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(file), "/") {
+		if elem == ".." {
+			return "", errors.New("the file must not leave the " +
+				"profile directory")
+		}
+	}
+	path := filepath.Join(cfg.DataDir, profileDirname, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// handleStartCPUProfile implements the startcpuprofile command.
+func handleStartCPUProfile(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.StartCPUProfileCmd)
+
+	path, err := profileFilePath(c.File)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid file: " + err.Error(),
+		}
+	}
+	f, err := createProfileFile(path)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to start the CPU profile: " + err.Error(),
+		}
+	}
+	if err := profiler.StartCPUProfile(f); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to start the CPU profile: " + err.Error(),
+		}
+	}
+	return path, nil
+}
+
+// handleStopCPUProfile implements the stopcpuprofile command.
+func handleStopCPUProfile(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	path, err := profiler.StopCPUProfile()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to stop the CPU profile: " + err.Error(),
+		}
+	}
+	return path, nil
+}
+
+// handleWriteProfile implements the writeprofile command.
+func handleWriteProfile(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.WriteProfileCmd)
+
+	if pprof.Lookup(c.Profile) == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unknown profile %q -- valid "+
+				"profiles are %v", c.Profile, profileNames()),
+		}
+	}
+	path, err := profileFilePath(c.File)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid file: " + err.Error(),
+		}
+	}
+	if err := writeProfile(c.Profile, path); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to write the profile: " + err.Error(),
+		}
+	}
+	return path, nil
+}
+
+// handleSetProfileServer implements the setprofileserver command.
+func handleSetProfileServer(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetProfileServerCmd)
+
+	if !c.Enable {
+		if err := profiler.StopServer(); err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCMisc,
+				Message: "Failed to stop the profile server: " +
+					err.Error(),
+			}
+		}
+		return "", nil
+	}
+
+	// The port defaults to the one configured with --profile.
+	port := cfg.Profile
+	if c.Port != nil {
+		port = *c.Port
+	}
+	if err := validProfilePort(port); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid port: " + err.Error(),
+		}
+	}
+	addr, err := profiler.StartServer(port)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "Failed to start the profile server: " +
+				err.Error(),
+		}
+	}
+	return addr, nil
+}
+
+// handleGetDiagnostics implements the getdiagnostics command.
+func handleGetDiagnostics(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return diagnosticsResult(), nil
+}
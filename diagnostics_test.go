@@ -0,0 +1,144 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProfileController ensures CPU profiles and runtime profiles are written
+// to the requested files and that only one CPU profile runs at a time.
+func TestProfileController(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var p profileController
+	if _, err := p.StopCPUProfile(); err != errNoCPUProfile {
+		t.Errorf("StopCPUProfile: got error %v, want %v", err,
+			errNoCPUProfile)
+	}
+	cpuPath := filepath.Join(dir, "cpu.prof")
+	f, err := createProfileFile(cpuPath)
+	if err != nil {
+		t.Fatalf("createProfileFile: unexpected error: %v", err)
+	}
+	if err := p.StartCPUProfile(f); err != nil {
+		t.Fatalf("StartCPUProfile: unexpected error: %v", err)
+	}
+	if p.CPUProfilePath() != cpuPath {
+		t.Errorf("CPUProfilePath: got %q, want %q", p.CPUProfilePath(),
+			cpuPath)
+	}
+	otherPath := filepath.Join(dir, "other.prof")
+	f, err = createProfileFile(otherPath)
+	if err != nil {
+		t.Fatalf("createProfileFile: unexpected error: %v", err)
+	}
+	err = p.StartCPUProfile(f)
+	if err != errCPUProfileActive {
+		t.Errorf("StartCPUProfile: got error %v, want %v", err,
+			errCPUProfileActive)
+	}
+	if _, err := os.Stat(otherPath); !os.IsNotExist(err) {
+		t.Errorf("StartCPUProfile: file of rejected profile was kept")
+	}
+	path, err := p.StopCPUProfile()
+	if err != nil || path != cpuPath {
+		t.Errorf("StopCPUProfile: got %q and error %v, want %q", path,
+			err, cpuPath)
+	}
+	if p.CPUProfilePath() != "" {
+		t.Errorf("CPUProfilePath: got %q after stop", p.CPUProfilePath())
+	}
+
+	stacksPath := filepath.Join(dir, "stacks.txt")
+	if err := writeProfile("goroutine", stacksPath); err != nil {
+		t.Fatalf("writeProfile: unexpected error: %v", err)
+	}
+	stacks, err := ioutil.ReadFile(stacksPath)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	if !strings.Contains(string(stacks), "TestProfileController") {
+		t.Errorf("writeProfile: goroutine dump misses the test stack")
+	}
+	if err := writeProfile("heap", filepath.Join(dir, "heap.prof")); err != nil {
+		t.Errorf("writeProfile: unexpected error: %v", err)
+	}
+	if err := writeProfile("unknown", filepath.Join(dir, "x")); err == nil {
+		t.Errorf("writeProfile: unknown profile was written")
+	}
+	if err := writeProfile("heap", cpuPath); err == nil {
+		t.Errorf("writeProfile: existing file was overwritten")
+	}
+
+	for _, port := range []string{"", "80", "65536", "x"} {
+		if validProfilePort(port) == nil {
+			t.Errorf("validProfilePort: port %q accepted", port)
+		}
+	}
+	if err := validProfilePort("6060"); err != nil {
+		t.Errorf("validProfilePort: unexpected error: %v", err)
+	}
+}
+
+// TestProfileFilePath ensures the profile files requested through the RPC
+// server are confined to the profile directory under the data directory.
+func TestProfileFilePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config{DataDir: dir}
+
+	tests := []struct {
+		file string
+		path string
+	}{
+		{"cpu.prof", filepath.Join(dir, profileDirname, "cpu.prof")},
+		{"node/heap.prof", filepath.Join(dir, profileDirname, "node",
+			"heap.prof")},
+		{"a/./b.prof", filepath.Join(dir, profileDirname, "a", "b.prof")},
+		{"", ""},
+		{"/etc/passwd", ""},
+		{"..", ""},
+		{"../prova.conf", ""},
+		{"node/../../prova.conf", ""},
+	}
+	for _, test := range tests {
+		path, err := profileFilePath(test.file)
+		if test.path == "" {
+			if err == nil {
+				t.Errorf("profileFilePath(%q): got %q, want error",
+					test.file, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("profileFilePath(%q): unexpected error: %v",
+				test.file, err)
+			continue
+		}
+		if path != test.path {
+			t.Errorf("profileFilePath(%q): got %q, want %q",
+				test.file, path, test.path)
+		}
+		if _, err := os.Stat(filepath.Dir(path)); err != nil {
+			t.Errorf("profileFilePath(%q): directory not created: %v",
+				test.file, err)
+		}
+	}
+}
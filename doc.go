@@ -35,31 +35,119 @@ Application Options:
       --listen=             Add an interface/port to listen for connections
                             (default all interfaces port: 8333, testnet: 18333)
       --maxpeers=           Max number of inbound and outbound peers (125)
+      --asmap=              File mapping IP subnets to the autonomous systems
+                            announcing them, used to spread outbound peers
+                            across networks
       --nobanning           Disable banning of misbehaving peers
       --banthreshold=       Maximum allowed ban score before disconnecting and
                             banning misbehaving peers.
+      --banwarnthreshold=   Ban score above which the misbehavior of peers is
+                            logged as a warning (default: half of
+                            banthreshold)
+      --msglimit=           Limit the rate of the entries of a message type
+                            accepted from each peer in the form
+                            command=rate/burst, such as addr=10/2000 -- The
+                            entries of addr, addrv2, inv and getdata messages
+                            are the addresses and inventory vectors they carry
+                            -- A rate of 0 removes the limit -- May be
+                            specified multiple times
+      --msglimitbanscore=   Decaying ban score added for each message a peer
+                            sends over the limit of its type (25)
       --banduration=        How long to ban misbehaving peers.  Valid time units
                             are {s, m, h}.  Minimum 1 second (24h0m0s)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
       --rpclimitpass=       Password for limited RPC connections
+      --rpcauth=            Add an RPC user as user:password:permissions, where
+                            permissions is a comma separated list of {read,
+                            wallet, mining, admin}; may be repeated
+      --rpccookiefile=      File to write the credentials of the RPC
+                            authentication cookie to (default: .cookie in the
+                            data directory)
+      --norpccookie         Disable authenticating local RPC clients with a
+                            cookie file
+      --rpcclientca=        File containing the PEM encoded certificate
+                            authorities which issue the client certificates RPC
+                            clients may authenticate with instead of a password
+      --rpccertauth=        Add an RPC user authenticated by client
+                            certificates issued by --rpcclientca as
+                            commonname:permissions, where commonname is the
+                            common name of the certificates and permissions is
+                            a comma separated list of {read, wallet, mining,
+                            admin}; may be repeated
+      --rpcrequireclientcert
+                            Reject RPC connections over TLS which don't present
+                            a client certificate issued by --rpcclientca
       --rpclisten=          Add an interface/port to listen for RPC connections
                             (default port: 8334, testnet: 18334)
+      --rpclistenunix=      Add a Unix domain socket path to listen for RPC
+                            connections, which don't use TLS and are restricted
+                            by the permissions of the socket file
+      --rpcunixsocketmode=  File mode of the RPC Unix domain sockets in octal
+                            (0600)
+      --grpclisten=         Add an interface/port to listen for gRPC
+                            connections, which use the RPC credentials and
+                            certificate (default port: 8335, testnet: 18335)
+                            -- NOTE: Requires a build with the grpc tag
       --rpccert=            File containing the certificate file
       --rpckey=             File containing the certificate key
       --rpcmaxclients=      Max number of RPC clients for standard connections
                             (10)
       --rpcmaxwebsockets=   Max number of RPC websocket connections (25)
+      --rpclimit=           Limit the rate of the calls each RPC user may make
+                            to the methods of a class in the form
+                            class=rate/burst, such as read=50/100 -- Valid
+                            classes are the permissions {read, wallet, mining,
+                            admin} -- A rate of 0 removes the limit -- May be
+                            specified multiple times
+      --rpcslowquery=       Log RPC calls taking at least this long along with
+                            their parameters.  Valid time units are {ms, s, m,
+                            h}.  0 disables logging slow calls (5s)
       --rpcquirks           Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE:
                             Discouraged unless interoperability issues need to
                             be worked around
+      --adminkey=           WIF-encoded private key of an admin key set used to
+                            sign the transactions created by the admin.* RPCs
+                            -- May be specified multiple times
+      --pkcs11adminkey=     Label of an admin key held by the hardware security
+                            module to sign the transactions created by the
+                            admin.* RPCs with, like --adminkey -- May be
+                            specified multiple times
+      --remotesigneradminkey= Hex-encoded public key of an admin key held by the
+                            remote signing service to sign the transactions
+                            created by the admin.* RPCs with, like --adminkey,
+                            instead of generated blocks -- May be specified
+                            multiple times
+      --walletkey=          Extended private key the holder keys of the addresses
+                            of the built-in wallet are derived from, which
+                            enables the wallet RPCs -- NOTE: Requires a build
+                            with the wallet tag
+      --walletkeyid=        KeyID of an ASP key of every address of the
+                            built-in wallet -- Must be specified exactly twice
+                            along with --walletkey
+      --walletaspkey=       WIF-encoded private ASP key the built-in wallet
+                            co-signs its spends with -- May be specified
+                            multiple times
+      --rest                Serve read-only chain data without authentication
+                            through the REST interface of the RPC server
       --norpc               Disable built-in RPC server -- NOTE: The RPC server
-                            is disabled by default if no rpcuser/rpcpass or
-                            rpclimituser/rpclimitpass is specified
+                            is disabled by default if no rpcuser/rpcpass,
+                            rpclimituser/rpclimitpass, rpcauth or rpccertauth
+                            is specified and the RPC cookie is disabled
       --notls               Disable TLS for the RPC server -- NOTE: This is only
                             allowed if the RPC server is bound to localhost
       --nodnsseed           Disable DNS seeding for peers
+      --dnsseed=            Add a DNS seed to query for peers in addition to
+                            the DNS seeds of the network
+      --dnsseedservice=     Only ask DNS seeds supporting filtering for peers
+                            advertising the service; may be repeated.  Valid
+                            services are {getutxo, bloom, cf}
+      --dnsseeder=          Act as a DNS seed for the specified zone by
+                            answering DNS queries with the addresses of good
+                            peers learned from the network
+      --dnsseederlisten=    Add an interface/port to listen for DNS seed
+                            queries on (default all interfaces port: 53)
       --externalip=         Add an ip to the list of local addresses we claim to
                             listen on to peers
       --proxy=              Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
@@ -72,6 +160,14 @@ Application Options:
       --noonion             Disable connecting to tor hidden services
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
+      --i2psam=             Connect to I2P peers through the SAM bridge of an
+                            I2P router (eg. 127.0.0.1:7656)
+      --i2plisten           Accept connections from I2P peers through the SAM
+                            bridge -- Requires --i2psam
+      --netproxy=           Connect to the addresses of a network through a
+                            SOCKS5 proxy, such as the client of a pluggable
+                            transport, in the form network=host:port -- May be
+                            specified multiple times {ipv4, ipv6, onion, i2p}
       --testnet             Use the test network
       --regtest             Use the regression test network
       --simnet              Use the simulation test network
@@ -79,6 +175,17 @@ Application Options:
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
       --dbtype=             Database backend to use for the Block Chain (ffldb)
+                            -- badgerdb requires building with -tags badger
+      --spv                 Run as a light client which validates the signed
+                            headers and the validate key set rules with the
+                            committed filters and blocks fetched from the full
+                            peers specified with --connect or --addpeer,
+                            without storing the utxo set, and serves the wallet
+                            oriented RPCs for the addresses specified with
+                            --spvwatchaddress
+      --spvwatchaddress=    Add an address whose outputs and transactions are
+                            tracked in light client mode from the next block to
+                            verify on -- May be specified multiple times
       --profile=            Enable HTTP profiling on given port -- NOTE port
                             must be between 1024 and 65536
       --cpuprofile=         Write CPU profile to the specified file
@@ -97,6 +204,17 @@ Application Options:
                             high priority for relaying
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --maxorphanblocks=    Max number of orphan blocks to keep in memory while
+                            their parents are requested (1000)
+      --orphanblockexpiry=  Drop orphan blocks whose parents did not arrive
+                            within this time.  Valid time units are {s, m, h}
+                            (1h0m0s)
+      --keyidvelocitylimit= Refuse to relay and mine transactions which would
+                            make a keyID spend more than the given amount in
+                            RMG within the given window, formatted as
+                            <window>:<amount> such as 24h:1000 -- May be
+                            repeated for several windows.  Valid time units
+                            are {s, m, h}
       --generate            Generate (mine) blocks using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
@@ -108,14 +226,83 @@ Application Options:
                             a block (750000)
       --blockprioritysize=  Size in bytes for high-priority/low-fee transactions
                             when creating a block (50000)
+      --heartbeatinterval=  Interval between the heartbeats announcing the
+                            active validate keys held by this node; 0 disables
+                            sending heartbeats (1m)
+      --nosignguard         Disable refusing to sign a block at the height of a
+                            block already signed by the same validate key,
+                            which is tracked in the data directory
+      --clusterrole=        Run as a member of a validator cluster holding the
+                            same validate keys as the other members, with the
+                            given role {primary, standby} -- Only one member
+                            signs blocks, and a standby takes over when the
+                            signing member stops sending heartbeats
+      --clusterlockout=     Duration without heartbeats of the validate keys
+                            signed by another cluster member after which a
+                            standby takes over signing blocks, and half of
+                            which for the primary (5m)
+      --attestationkey=     Private key in WIF format to sign the attestations
+                            of the chain state made via the getattestation RPC
+                            with, instead of a validate key of the node
+      --alertwebhook=       Post alerts about consensus and operational
+                            anomalies as JSON objects to the URL -- May be
+                            specified multiple times
+      --alertcmd=           Run the command for every alert about consensus
+                            and operational anomalies, passing the alert as a
+                            JSON object on its standard input and in
+                            PROVA_ALERT_* environment variables -- May be
+                            specified multiple times
+      --alertnoblock=       Alert when no block has been connected for this
+                            long; 0 uses 30 times the expected block interval
+                            of the network
+      --alertreorgdepth=    Alert when a reorganization disconnects at least
+                            this many blocks; 0 disables the alert (6)
+      --alertmempooltxs=    Alert when the memory pool holds more than this
+                            many transactions; 0 disables the alert (50000)
+      --alertindexlag=      Alert when an optional index is more than this
+                            many blocks behind the main chain; 0 disables the
+                            alert (100)
+      --alertdedup=         Only send one alert of a kind about the same
+                            subject within this interval (30m)
+      --alertratelimit=     Maximum number of alerts sent per hour; 0 disables
+                            the limit (20)
+      --screenlist=         File listing the addresses and keyIDs to screen
+                            transactions against, one per line optionally
+                            followed by the decision {flag, exclude} and a
+                            reason -- Read again when the configuration is
+                            reloaded
+      --screenurl=          Post every transaction and the addresses and
+                            keyIDs it touches as a JSON object to the http or
+                            https URL of a screening service, which responds
+                            with the decision {allow, flag, exclude}
+      --screentimeout=      Give up on the screening service after this long
+                            (5s)
+      --screenfailclosed    Refuse to relay and mine the transactions which
+                            could not be screened instead of allowing them
+      --screencachettl=     Reuse the screening decision about a transaction
+                            for this long; 0 screens transactions every time
+                            (10m)
       --nopeerbloomfilters  Disable bloom filtering support.
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
+      --hashcachemaxsize=   The maximum number of transactions in the signature
+                            hash cache (50000)
       --blocksonly          Do not accept transactions from remote peers.
       --relaynonstd         Relay non-standard transactions regardless of the
                             default settings for the active network.
       --rejectnonstd        Reject non-standard transactions regardless of the
                             default settings for the active network.
+      --eventlog            Record connected and disconnected blocks, mempool
+                            transactions and admin key changes with sequence
+                            numbers in the database so clients can replay them
+                            from a cursor via the geteventlog RPC
+      --eventlogsize=       Maximum number of the most recent events kept in
+                            the event log (100000)
+      --auditlog            Record admin RPC calls, blocks signed with the
+                            validate keys, configuration reloads and manual
+                            chain interventions in a hash-chained audit log in
+                            the data directory, which is exported and verified
+                            via the getauditlog and verifyauditlog RPCs
 
 Help Options:
   -h, --help           Show this help message
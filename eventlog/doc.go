@@ -0,0 +1,30 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package eventlog provides a persisted log of chain and mempool events which
+clients can replay from a cursor.
+
+Overview
+
+Websocket notifications are only delivered while a client is connected, so a
+client which reconnects has no way of learning what it missed.  The event log
+records every block connected to and disconnected from the main chain, every
+transaction accepted into and removed from the memory pool, and every admin key
+added or revoked by the connected blocks.  Each event is assigned a sequence
+number which is one higher than that of the previous event, also across
+restarts of the node.
+
+Clients remember the sequence number of the last event they have processed and
+pass it as the cursor when they ask for further events.  A cursor of zero
+returns the log from the oldest event kept.
+
+Pruning
+
+Only the configured number of most recent events is kept.  Asking for events
+after a cursor whose following events have been pruned results in
+ErrCursorPruned, which tells the client that it has to resynchronize its state
+in full before it can continue from the oldest event kept.
+*/
+package eventlog
@@ -0,0 +1,439 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package eventlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+var (
+	// bucketName is the name of the metadata bucket which houses the
+	// events keyed by their big endian sequence numbers, so a cursor over
+	// the bucket visits them in order.
+	bucketName = []byte("eventlog")
+
+	// ErrCursorPruned is returned when the events following a cursor have
+	// been pruned from the log.
+	ErrCursorPruned = errors.New("the events following the cursor have " +
+		"been pruned")
+
+	// ErrCursorUnknown is returned when a cursor is beyond the most recent
+	// event of the log, which happens when the log has been reset since
+	// the cursor was handed out.
+	ErrCursorUnknown = errors.New("the cursor is beyond the most recent " +
+		"event")
+)
+
+// EventType identifies the kind of an event.
+type EventType uint8
+
+// These constants define the kinds of events which are recorded.
+const (
+	// BlockConnected indicates a block was connected to the main chain.
+	BlockConnected EventType = iota + 1
+
+	// BlockDisconnected indicates a block was disconnected from the main
+	// chain.
+	BlockDisconnected
+
+	// TxAccepted indicates a transaction was accepted into the memory
+	// pool.
+	TxAccepted
+
+	// TxRemoved indicates a transaction was removed from the memory pool,
+	// either because it was mined or because it is no longer valid.
+	TxRemoved
+
+	// KeyAdded indicates an admin key was added by a connected block, or a
+	// revocation was undone by a disconnected block.
+	KeyAdded
+
+	// KeyRevoked indicates an admin key was revoked by a connected block,
+	// or an addition was undone by a disconnected block.
+	KeyRevoked
+)
+
+// eventTypeStrings is a map of event types back to their constant names for
+// pretty printing.
+var eventTypeStrings = map[EventType]string{
+	BlockConnected:    "blockconnected",
+	BlockDisconnected: "blockdisconnected",
+	TxAccepted:        "txaccepted",
+	TxRemoved:         "txremoved",
+	KeyAdded:          "keyadded",
+	KeyRevoked:        "keyrevoked",
+}
+
+// String returns the EventType in human-readable form.
+func (t EventType) String() string {
+	if s, ok := eventTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown EventType (%d)", uint8(t))
+}
+
+// Event is a single entry of the event log.
+type Event struct {
+	// Seq is the sequence number of the event.
+	Seq uint64
+
+	// Type is the kind of the event.
+	Type EventType
+
+	// Time is the time the event was recorded.
+	Time time.Time
+
+	// Hash is the hash of the block for block events, and the hash of the
+	// transaction for transaction and admin key events.
+	Hash chainhash.Hash
+
+	// Height is the height of the block for block and admin key events.
+	Height uint32
+
+	// KeySet, PubKey and KeyID describe the key of admin key events.  The
+	// KeyID is only set for ASP keys.
+	KeySet btcec.KeySetType
+	PubKey *btcec.PublicKey
+	KeyID  btcec.KeyID
+}
+
+// isKeyEvent returns whether the passed event type describes an admin key.
+func isKeyEvent(t EventType) bool {
+	return t == KeyAdded || t == KeyRevoked
+}
+
+// serializeEvent returns the serialized event, which is:
+//
+//   <type><time><hash><height>[<key set><pubkey><keyID>]
+//
+//   Field     Type       Size
+//   type      uint8      1
+//   time      int64      8
+//   hash      [32]byte   32
+//   height    uint32     4
+//   key set   uint8      1 (admin key events only)
+//   pubkey    [33]byte   33 (admin key events only)
+//   keyID     uint32     4 (admin key events only)
+func serializeEvent(e *Event) []byte {
+	size := 1 + 8 + chainhash.HashSize + 4
+	if isKeyEvent(e.Type) {
+		size += 1 + btcec.PubKeyBytesLenCompressed + 4
+	}
+	buf := make([]byte, size)
+	buf[0] = byte(e.Type)
+	binary.LittleEndian.PutUint64(buf[1:], uint64(e.Time.Unix()))
+	copy(buf[9:], e.Hash[:])
+	offset := 9 + chainhash.HashSize
+	binary.LittleEndian.PutUint32(buf[offset:], e.Height)
+	offset += 4
+	if isKeyEvent(e.Type) {
+		buf[offset] = byte(e.KeySet)
+		copy(buf[offset+1:], e.PubKey.SerializeCompressed())
+		offset += 1 + btcec.PubKeyBytesLenCompressed
+		binary.LittleEndian.PutUint32(buf[offset:], uint32(e.KeyID))
+	}
+	return buf
+}
+
+// deserializeEvent decodes the passed serialized event with the passed
+// sequence number.
+func deserializeEvent(seq uint64, serialized []byte) (*Event, error) {
+	const baseSize = 1 + 8 + chainhash.HashSize + 4
+	if len(serialized) < baseSize {
+		return nil, fmt.Errorf("corrupt event %d: %d bytes", seq,
+			len(serialized))
+	}
+	e := &Event{
+		Seq:  seq,
+		Type: EventType(serialized[0]),
+		Time: time.Unix(int64(binary.LittleEndian.Uint64(serialized[1:])), 0),
+	}
+	copy(e.Hash[:], serialized[9:9+chainhash.HashSize])
+	e.Height = binary.LittleEndian.Uint32(serialized[9+chainhash.HashSize:])
+	if !isKeyEvent(e.Type) {
+		return e, nil
+	}
+
+	if len(serialized) != baseSize+1+btcec.PubKeyBytesLenCompressed+4 {
+		return nil, fmt.Errorf("corrupt admin key event %d: %d bytes",
+			seq, len(serialized))
+	}
+	offset := baseSize
+	e.KeySet = btcec.KeySetType(serialized[offset])
+	pubKey, err := btcec.ParsePubKey(serialized[offset+1:offset+1+
+		btcec.PubKeyBytesLenCompressed], btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("corrupt admin key event %d: %v", seq, err)
+	}
+	e.PubKey = pubKey
+	offset += 1 + btcec.PubKeyBytesLenCompressed
+	e.KeyID = btcec.KeyID(binary.LittleEndian.Uint32(serialized[offset:]))
+	return e, nil
+}
+
+// seqKey returns the database key of the event with the passed sequence
+// number.
+func seqKey(seq uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], seq)
+	return key[:]
+}
+
+// Config houses the parameters of an event log.
+type Config struct {
+	// DB is the database the events are persisted in.
+	DB database.DB
+
+	// MaxEvents is the number of most recent events which are kept.
+	MaxEvents uint64
+
+	// Notify, if non-nil, is invoked with every event once it has been
+	// persisted, in the order of the sequence numbers.
+	Notify func(*Event)
+}
+
+// Log is a persisted log of chain and mempool events with monotonically
+// increasing sequence numbers.
+type Log struct {
+	cfg Config
+
+	// mtx protects the sequence numbers of the oldest and the most recent
+	// event kept, and serializes appending events.  Both are zero while
+	// the log is empty.
+	mtx   sync.Mutex
+	first uint64
+	last  uint64
+}
+
+// New returns an event log persisted in the configured database, creating
+// its bucket when the log is used for the first time.
+func New(cfg *Config) (*Log, error) {
+	l := &Log{cfg: *cfg}
+	err := cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		cursor := bucket.Cursor()
+		if cursor.First() {
+			l.first = binary.BigEndian.Uint64(cursor.Key())
+		}
+		if cursor.Last() {
+			l.last = binary.BigEndian.Uint64(cursor.Key())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Bounds returns the sequence numbers of the oldest and the most recent event
+// kept by the log, which are both zero while the log is empty.
+//
+// This function is safe for concurrent access.
+func (l *Log) Bounds() (uint64, uint64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.first, l.last
+}
+
+// Events returns up to max events following the passed cursor, which is the
+// sequence number of the last event processed by the caller.  ErrCursorPruned
+// is returned when some of the events following the cursor are no longer
+// kept.
+//
+// This function is safe for concurrent access.
+func (l *Log) Events(cursor uint64, max int) ([]*Event, error) {
+	l.mtx.Lock()
+	first, last := l.first, l.last
+	l.mtx.Unlock()
+
+	if cursor > last {
+		return nil, ErrCursorUnknown
+	}
+	if first != 0 && cursor+1 < first {
+		return nil, ErrCursorPruned
+	}
+	if cursor == last || max <= 0 {
+		return nil, nil
+	}
+
+	var events []*Event
+	err := l.cfg.DB.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for seq := cursor + 1; seq <= last && len(events) < max; seq++ {
+			serialized := bucket.Get(seqKey(seq))
+			if serialized == nil {
+				// The event has been pruned since the bounds
+				// were read.
+				return ErrCursorPruned
+			}
+			e, err := deserializeEvent(seq, serialized)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// append assigns sequence numbers to the passed events, persists them along
+// with pruning the events which are no longer kept, and passes them to the
+// configured notification callback.
+//
+// This function is safe for concurrent access.
+func (l *Log) append(events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	first, last := l.first, l.last
+	for _, e := range events {
+		last++
+		e.Seq = last
+		e.Time = now
+	}
+	if first == 0 {
+		first = 1
+	}
+	pruneTo := first
+	if l.cfg.MaxEvents != 0 && last-first+1 > l.cfg.MaxEvents {
+		pruneTo = last - l.cfg.MaxEvents + 1
+	}
+
+	err := l.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for _, e := range events {
+			err := bucket.Put(seqKey(e.Seq), serializeEvent(e))
+			if err != nil {
+				return err
+			}
+		}
+		for seq := first; seq < pruneTo; seq++ {
+			if err := bucket.Delete(seqKey(seq)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.first, l.last = pruneTo, last
+
+	if l.cfg.Notify != nil {
+		for _, e := range events {
+			l.cfg.Notify(e)
+		}
+	}
+	return nil
+}
+
+// keyEvents returns the admin key events for the key operations of the passed
+// block.  When the block is disconnected, the operations are undone in reverse
+// order, so additions become revocations and vice versa.
+func keyEvents(block *provautil.Block, disconnected bool) []*Event {
+	var events []*Event
+	for _, tx := range block.Transactions() {
+		threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+		if threadInt < 0 ||
+			provautil.ThreadID(threadInt) == provautil.IssueThread {
+			continue
+		}
+		for _, pops := range adminOutputs {
+			isAddOp, keySet, pubKey, keyID :=
+				txscript.ExtractAdminOpData(pops)
+			if pubKey == nil {
+				continue
+			}
+			if disconnected {
+				isAddOp = !isAddOp
+			}
+			e := &Event{
+				Type:   KeyRevoked,
+				Hash:   *tx.Hash(),
+				Height: block.Height(),
+				KeySet: keySet,
+				PubKey: pubKey,
+			}
+			if isAddOp {
+				e.Type = KeyAdded
+			}
+			if keySet == btcec.ASPKeySet {
+				e.KeyID = keyID
+			}
+			events = append(events, e)
+		}
+	}
+	if disconnected {
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+	}
+	return events
+}
+
+// BlockConnected records the connection of the passed block to the main chain,
+// followed by the admin key changes it makes.
+//
+// This function is safe for concurrent access.
+func (l *Log) BlockConnected(block *provautil.Block) error {
+	events := []*Event{{
+		Type:   BlockConnected,
+		Hash:   *block.Hash(),
+		Height: block.Height(),
+	}}
+	return l.append(append(events, keyEvents(block, false)...))
+}
+
+// BlockDisconnected records the undoing of the admin key changes made by the
+// passed block, followed by its disconnection from the main chain.
+//
+// This function is safe for concurrent access.
+func (l *Log) BlockDisconnected(block *provautil.Block) error {
+	events := keyEvents(block, true)
+	return l.append(append(events, &Event{
+		Type:   BlockDisconnected,
+		Hash:   *block.Hash(),
+		Height: block.Height(),
+	}))
+}
+
+// TxAccepted records the acceptance of the passed transaction into the memory
+// pool.
+//
+// This function is safe for concurrent access.
+func (l *Log) TxAccepted(tx *provautil.Tx) error {
+	return l.append([]*Event{{Type: TxAccepted, Hash: *tx.Hash()}})
+}
+
+// TxRemoved records the removal of the passed transaction from the memory
+// pool.
+//
+// This function is safe for concurrent access.
+func (l *Log) TxRemoved(tx *provautil.Tx) error {
+	return l.append([]*Event{{Type: TxRemoved, Hash: *tx.Hash()}})
+}
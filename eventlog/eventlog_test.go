@@ -0,0 +1,160 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package eventlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txrules/adminbuilder"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestEventLog ensures events are numbered in order, including the admin key
+// changes of connected and disconnected blocks, are pruned beyond the
+// configured size, and are persisted.
+func TestEventLog(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "eventlog")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	var notified []uint64
+	log, err := New(&Config{
+		DB:        db,
+		MaxEvents: 6,
+		Notify: func(e *Event) {
+			notified = append(notified, e.Seq)
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	// The block provisions two ASP keys.
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	privKey2, _ := btcec.NewPrivateKey(btcec.S256())
+	threadTips := map[provautil.ThreadID]*wire.OutPoint{
+		provautil.ProvisionThread: {Hash: chainhash.Hash{0x01}},
+	}
+	provisionTx, err := adminbuilder.NewProvisionTx(threadTips, 4,
+		[]adminbuilder.KeyOp{
+			{KeySet: btcec.ASPKeySet, PubKey: privKey.PubKey()},
+			{KeySet: btcec.ASPKeySet, PubKey: privKey2.PubKey()},
+		})
+	if err != nil {
+		t.Fatalf("NewProvisionTx: unexpected error: %v", err)
+	}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	block := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, provisionTx}})
+	block.SetHeight(7)
+	tx := provautil.NewTx(provisionTx)
+
+	if err := log.TxAccepted(tx); err != nil {
+		t.Fatalf("TxAccepted: unexpected error: %v", err)
+	}
+	if err := log.BlockConnected(block); err != nil {
+		t.Fatalf("BlockConnected: unexpected error: %v", err)
+	}
+	if err := log.TxRemoved(tx); err != nil {
+		t.Fatalf("TxRemoved: unexpected error: %v", err)
+	}
+	events, err := log.Events(0, 10)
+	if err != nil {
+		t.Fatalf("Events: unexpected error: %v", err)
+	}
+	wantTypes := []EventType{TxAccepted, BlockConnected, KeyAdded,
+		KeyAdded, TxRemoved}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("Events: got %d events, want %d", len(events),
+			len(wantTypes))
+	}
+	for i, e := range events {
+		if e.Seq != uint64(i+1) || e.Type != wantTypes[i] {
+			t.Errorf("Events: event %d is %v with seq %d, want %v "+
+				"with seq %d", i, e.Type, e.Seq, wantTypes[i], i+1)
+		}
+	}
+	if events[1].Hash != *block.Hash() || events[1].Height != 7 {
+		t.Errorf("Events: got block %v at height %d, want %v at "+
+			"height 7", events[1].Hash, events[1].Height, block.Hash())
+	}
+	if events[2].KeyID != 5 || events[3].KeyID != 6 ||
+		!events[3].PubKey.IsEqual(privKey2.PubKey()) {
+		t.Errorf("Events: got ASP keyIDs %d and %d, want 5 and 6",
+			events[2].KeyID, events[3].KeyID)
+	}
+
+	// Disconnecting the block undoes the key additions in reverse order
+	// before the block itself, which prunes the oldest event.
+	if err := log.BlockDisconnected(block); err != nil {
+		t.Fatalf("BlockDisconnected: unexpected error: %v", err)
+	}
+	first, last := log.Bounds()
+	if first != 3 || last != 8 {
+		t.Fatalf("Bounds: got %d-%d, want 3-8", first, last)
+	}
+	events, err = log.Events(4, 10)
+	if err != nil {
+		t.Fatalf("Events: unexpected error: %v", err)
+	}
+	if len(events) != 4 || events[1].Type != KeyRevoked ||
+		events[1].KeyID != 6 || events[2].KeyID != 5 ||
+		events[3].Type != BlockDisconnected {
+		t.Errorf("Events: unexpected events after disconnect: %v",
+			events)
+	}
+	if events, _ := log.Events(4, 2); len(events) != 2 {
+		t.Errorf("Events: got %d events, want 2", len(events))
+	}
+	if _, err := log.Events(1, 10); err != ErrCursorPruned {
+		t.Errorf("Events: got error %v, want ErrCursorPruned", err)
+	}
+	if _, err := log.Events(9, 10); err != ErrCursorUnknown {
+		t.Errorf("Events: got error %v, want ErrCursorUnknown", err)
+	}
+	if events, err := log.Events(2, 10); err != nil || len(events) != 6 {
+		t.Errorf("Events: got %d events (%v), want 6", len(events),
+			err)
+	}
+	if len(notified) != 8 || notified[7] != 8 {
+		t.Errorf("Notify: got notified of %v, want 1-8", notified)
+	}
+
+	// The bounds are restored when the log is loaded again.
+	log, err = New(&Config{DB: db, MaxEvents: 6})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if first, last := log.Bounds(); first != 3 || last != 8 {
+		t.Fatalf("Bounds: got %d-%d after reload, want 3-8", first,
+			last)
+	}
+	if err := log.TxAccepted(tx); err != nil {
+		t.Fatalf("TxAccepted: unexpected error: %v", err)
+	}
+	events, err = log.Events(8, 10)
+	if err != nil || len(events) != 1 || events[0].Seq != 9 {
+		t.Errorf("Events: got %v (%v), want a single event 9", events,
+			err)
+	}
+}
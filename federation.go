@@ -0,0 +1,160 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// federationHandshakeTimeout is the maximum duration of the TLS handshake of
+// a connection between federation members.
+const federationHandshakeTimeout = 30 * time.Second
+
+// federationMember is a member of the federation which is identified by its
+// pinned certificate.
+type federationMember struct {
+	name string
+	cert []byte
+}
+
+// loadFederationMembers returns the federation members whose PEM encoded
+// certificates are in the passed file.
+func loadFederationMembers(membersFile string) ([]federationMember, error) {
+	data, err := ioutil.ReadFile(membersFile)
+	if err != nil {
+		return nil, err
+	}
+	var members []federationMember
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, federationMember{
+			name: federationMemberName(cert),
+			cert: cert.Raw,
+		})
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", membersFile)
+	}
+	return members, nil
+}
+
+// federationMemberName returns the name a federation member is logged with,
+// which is the common name of its certificate followed by the start of the
+// fingerprint of the certificate.
+func federationMemberName(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("%s/%s", cert.Subject.CommonName,
+		hex.EncodeToString(fingerprint[:8]))
+}
+
+// federationTLSConfig returns the TLS config of the connections between
+// federation members, which authenticate with the passed certificate and key,
+// and only accept the remote end when it presents one of the certificates in
+// membersFile.  Both ends of a connection are authenticated, and since the
+// certificates are pinned, no certificate authorities or host names are
+// involved.
+func federationTLSConfig(certFile, keyFile, membersFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	members, err := loadFederationMembers(membersFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.RequireAnyClientCert,
+
+		// The chains are not verified against authorities, instead the
+		// certificate of the remote end is required to be pinned.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no certificate presented")
+			}
+			for _, member := range members {
+				if bytes.Equal(rawCerts[0], member.cert) {
+					return nil
+				}
+			}
+			return errors.New("certificate is not of a federation member")
+		},
+	}, nil
+}
+
+// federationHandshake performs the TLS handshake of the passed connection
+// between federation members and returns the name of the remote member.  The
+// connection is closed when the handshake fails.
+func federationHandshake(conn *tls.Conn) (string, error) {
+	conn.SetDeadline(time.Now().Add(federationHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return "", err
+	}
+	conn.SetDeadline(time.Time{})
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		conn.Close()
+		return "", errors.New("no certificate presented")
+	}
+	return federationMemberName(certs[0]), nil
+}
+
+// federationDial returns a dial function which connects to the passed
+// addresses of federation members over TLS with the passed config, and to all
+// other addresses with the passed dial function.
+func federationDial(dial func(net.Addr) (net.Conn, error), tlsConfig *tls.Config, addrs map[string]struct{}) func(net.Addr) (net.Conn, error) {
+	return func(addr net.Addr) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := addrs[addr.String()]; !ok {
+			return conn, nil
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if _, err := federationHandshake(tlsConn); err != nil {
+			return nil, fmt.Errorf("federation handshake with %s "+
+				"failed: %v", addr, err)
+		}
+		return tlsConn, nil
+	}
+}
+
+// federationConnMember returns the name of the federation member at the remote
+// end of the passed connection, performing the TLS handshake first when
+// needed.  An empty name is returned for connections which are not between
+// federation members.
+func federationConnMember(conn net.Conn) (string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", nil
+	}
+	return federationHandshake(tlsConn)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/provautil"
+)
+
+// writeFederationCert writes a new certificate pair for the named member to
+// the passed directory and returns the paths of the certificate and the key.
+func writeFederationCert(t *testing.T, dir, name string) (string, string) {
+	cert, key, err := provautil.NewTLSCertPair(name,
+		time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("NewTLSCertPair: unexpected error: %v", err)
+	}
+	certFile := filepath.Join(dir, name+".cert")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// federationPipe performs the federation handshake of both ends of a loopback
+// connection with the passed configs and returns the member names each end
+// authenticated along with the errors of the handshakes.
+func federationPipe(t *testing.T, serverConfig, clientConfig *tls.Config) (string, string, error, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	type result struct {
+		member string
+		err    error
+	}
+	serverResult := make(chan result, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverResult <- result{"", err}
+			return
+		}
+		defer conn.Close()
+		member, err := federationConnMember(tls.Server(conn,
+			serverConfig))
+		serverResult <- result{member, err}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer conn.Close()
+	clientMember, clientErr := federationConnMember(tls.Client(conn,
+		clientConfig))
+	r := <-serverResult
+	return r.member, clientMember, r.err, clientErr
+}
+
+// TestFederationTLSConfig ensures connections between federation members are
+// mutually authenticated with the pinned certificates, and connections with
+// other nodes are refused.
+func TestFederationTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "federation")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certA, keyA := writeFederationCert(t, dir, "alpha")
+	certB, keyB := writeFederationCert(t, dir, "beta")
+	certC, keyC := writeFederationCert(t, dir, "outsider")
+
+	// The members file holds the certificates of alpha and beta.
+	var members []byte
+	for _, certFile := range []string{certA, certB} {
+		cert, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			t.Fatalf("ReadFile: unexpected error: %v", err)
+		}
+		members = append(members, cert...)
+	}
+	membersFile := filepath.Join(dir, "members.cert")
+	if err := ioutil.WriteFile(membersFile, members, 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+
+	configA, err := federationTLSConfig(certA, keyA, membersFile)
+	if err != nil {
+		t.Fatalf("federationTLSConfig: unexpected error: %v", err)
+	}
+	configB, err := federationTLSConfig(certB, keyB, membersFile)
+	if err != nil {
+		t.Fatalf("federationTLSConfig: unexpected error: %v", err)
+	}
+	configC, err := federationTLSConfig(certC, keyC, membersFile)
+	if err != nil {
+		t.Fatalf("federationTLSConfig: unexpected error: %v", err)
+	}
+
+	// Members authenticate each other.
+	serverMember, clientMember, serverErr, clientErr :=
+		federationPipe(t, configA, configB)
+	if serverErr != nil || clientErr != nil {
+		t.Fatalf("federation handshake: unexpected errors: %v, %v",
+			serverErr, clientErr)
+	}
+	memberList, err := loadFederationMembers(membersFile)
+	if err != nil {
+		t.Fatalf("loadFederationMembers: unexpected error: %v", err)
+	}
+	if serverMember != memberList[1].name {
+		t.Errorf("federation handshake: server authenticated %q, want "+
+			"%q", serverMember, memberList[1].name)
+	}
+	if clientMember != memberList[0].name {
+		t.Errorf("federation handshake: client authenticated %q, want "+
+			"%q", clientMember, memberList[0].name)
+	}
+
+	// A node without a pinned certificate is refused in both directions.
+	_, _, serverErr, _ = federationPipe(t, configA, configC)
+	if serverErr == nil {
+		t.Error("federation handshake: accepted client which is not a " +
+			"member")
+	}
+	_, _, _, clientErr = federationPipe(t, configC, configA)
+	if clientErr == nil {
+		t.Error("federation handshake: accepted server which is not a " +
+			"member")
+	}
+
+	// Connections which are not between federation members have no
+	// member.
+	conn, other := net.Pipe()
+	defer conn.Close()
+	defer other.Close()
+	if member, err := federationConnMember(conn); member != "" || err != nil {
+		t.Errorf("federationConnMember: got %q, %v, want no member",
+			member, err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package grpcapi holds the protobuf definitions of the gRPC API of prova, which
+is served alongside the JSON-RPC API for internal services that want typed
+clients and streaming notifications without parsing websocket JSON.
+
+The API is described by the ProvaNode service in api.proto.  It covers chain
+queries, the memory pool, transaction submission, block templates and the admin
+keys, and streams connected and disconnected blocks, transactions accepted into
+the memory pool and new block templates.
+
+Building
+
+The Go bindings are generated from api.proto with protoc and the protoc-gen-go
+plugin, and the gRPC server of prova is only compiled in when building with the
+grpc build tag, so the protobuf and gRPC packages are not required otherwise:
+
+  go generate github.com/bitgo/prova/grpcapi
+  go build -tags grpc
+
+Clients in other languages generate their bindings from api.proto with the
+protoc plugin of their language.
+
+Authentication
+
+The server listens on the addresses given with the --grpclisten option.  It
+uses the TLS certificate of the JSON-RPC server, and clients authenticate with
+the credentials of a JSON-RPC user, sent as HTTP basic authentication in the
+authorization metadata of every call.  SendTransaction requires the wallet
+permission and the block template calls require the mining permission, like
+the equivalent JSON-RPC methods.
+*/
+package grpcapi
+
+//go:generate protoc -I. --go_out=plugins=grpc:. api.proto
@@ -0,0 +1,694 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build grpc
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/grpcapi"
+	"github.com/bitgo/prova/mempool"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcSubscriptionBuffer is the number of notifications queued for a streaming
+// call.  Calls which fall further behind are ended, since they would otherwise
+// miss notifications.
+const grpcSubscriptionBuffer = 1000
+
+// grpcBlockNtfn is the notification of a block connected to or disconnected
+// from the main chain.
+type grpcBlockNtfn struct {
+	block     *provautil.Block
+	connected bool
+}
+
+// grpcSubscription queues the notifications of a streaming call.
+type grpcSubscription struct {
+	ntfns chan interface{}
+
+	// coalesce marks subscriptions which only need to know that a
+	// notification happened, so notifications are skipped rather than the
+	// call ended when the queue is full.
+	coalesce bool
+
+	// dropped is closed when the call fell behind and must be ended.
+	dropped chan struct{}
+}
+
+// grpcServer serves the gRPC API of the node described in the grpcapi package.
+// It authenticates clients with the credentials of the JSON-RPC server and
+// shares its TLS certificate and block template generator.
+type grpcServer struct {
+	started  int32
+	shutdown int32
+
+	rpc       *rpcServer
+	server    *grpc.Server
+	listeners []net.Listener
+	wg        sync.WaitGroup
+	quit      chan struct{}
+
+	mtx          sync.Mutex
+	blockSubs    map[*grpcSubscription]struct{}
+	txSubs       map[*grpcSubscription]struct{}
+	templateSubs map[*grpcSubscription]struct{}
+}
+
+// Ensure grpcServer implements the grpcapi.ProvaNodeServer interface.
+var _ grpcapi.ProvaNodeServer = (*grpcServer)(nil)
+
+// newGRPCServer returns a new gRPC server listening on the passed addresses,
+// which uses the credentials, TLS certificate and block template generator of
+// the passed JSON-RPC server.
+func newGRPCServer(listenAddrs []string, rpc *rpcServer) (*grpcServer, error) {
+	g := grpcServer{
+		rpc:          rpc,
+		quit:         make(chan struct{}),
+		blockSubs:    make(map[*grpcSubscription]struct{}),
+		txSubs:       make(map[*grpcSubscription]struct{}),
+		templateSubs: make(map[*grpcSubscription]struct{}),
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(g.authUnary),
+		grpc.StreamInterceptor(g.authStream),
+	}
+	if !cfg.DisableTLS {
+		// The certificate was generated by the JSON-RPC server when it
+		// did not exist yet.
+		keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+		if err != nil {
+			return nil, err
+		}
+		creds := credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{keypair},
+			MinVersion:   tls.VersionTLS12,
+		})
+		opts = append(opts, grpc.Creds(creds))
+	}
+	g.server = grpc.NewServer(opts...)
+	grpcapi.RegisterProvaNodeServer(g.server, &g)
+
+	ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners(listenAddrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range ipv4ListenAddrs {
+		listener, err := net.Listen("tcp4", addr)
+		if err != nil {
+			rpcsLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		g.listeners = append(g.listeners, listener)
+	}
+	for _, addr := range ipv6ListenAddrs {
+		listener, err := net.Listen("tcp6", addr)
+		if err != nil {
+			rpcsLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		g.listeners = append(g.listeners, listener)
+	}
+	if len(g.listeners) == 0 {
+		return nil, errors.New("gRPC: No valid listen address")
+	}
+
+	return &g, nil
+}
+
+// Start begins serving gRPC calls on the listeners of the server.
+func (g *grpcServer) Start() {
+	if atomic.AddInt32(&g.started, 1) != 1 {
+		return
+	}
+
+	for _, listener := range g.listeners {
+		g.wg.Add(1)
+		go func(listener net.Listener) {
+			rpcsLog.Infof("gRPC server listening on %s", listener.Addr())
+			g.server.Serve(listener)
+			rpcsLog.Tracef("gRPC listener done for %s", listener.Addr())
+			g.wg.Done()
+		}(listener)
+	}
+}
+
+// Stop ends the subscriptions, waits for the other calls in progress to finish
+// and stops the server.
+func (g *grpcServer) Stop() {
+	if atomic.AddInt32(&g.shutdown, 1) != 1 {
+		rpcsLog.Infof("gRPC server is already in the process of shutting down")
+		return
+	}
+	rpcsLog.Warnf("gRPC server shutting down")
+
+	// Closing the quit channel ends the subscriptions, so the calls in
+	// progress can be finished before the server stops.
+	close(g.quit)
+	g.server.GracefulStop()
+	g.wg.Wait()
+	rpcsLog.Infof("gRPC server shutdown complete")
+}
+
+// grpcMethodPermissions maps the gRPC methods which need more than the read
+// permission to the permission of the equivalent JSON-RPC method.
+var grpcMethodPermissions = map[string]string{
+	"/provarpc.ProvaNode/SendTransaction":         "sendrawtransaction",
+	"/provarpc.ProvaNode/GetBlockTemplate":        "getblocktemplate",
+	"/provarpc.ProvaNode/SubscribeBlockTemplates": "getblocktemplate",
+}
+
+// checkAuth ensures the call of the passed method with the passed context
+// carries the credentials of an RPC user with the permission the method
+// requires, within the rate limit of the user.
+func (g *grpcServer) checkAuth(ctx context.Context, fullMethod string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	user := g.rpc.authenticate(md["authorization"][0])
+	if user == nil {
+		rpcsLog.Warnf("gRPC authentication failure")
+		return status.Error(codes.Unauthenticated, "auth failure")
+	}
+	method, ok := grpcMethodPermissions[fullMethod]
+	if !ok {
+		// Calls which only need the read permission are limited along
+		// with the read methods of the JSON-RPC API.
+		if !g.rpc.metrics.allow(user, rpcPermRead, "", time.Now()) {
+			return status.Error(codes.ResourceExhausted,
+				"rate limit of read methods exceeded")
+		}
+		return nil
+	}
+
+	if !user.authorized(method) {
+		return status.Error(codes.PermissionDenied,
+			rpcUnauthorizedError(method).Message)
+	}
+	if !g.rpc.metrics.allow(user, rpcMethodPermission(method), method,
+		time.Now()) {
+
+		return status.Error(codes.ResourceExhausted,
+			rpcRateLimitedError(method).Message)
+	}
+	remoteAddr := "unknown address"
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	g.rpc.auditCall(user, method, nil, remoteAddr)
+	return nil
+}
+
+// authUnary authenticates unary calls.
+func (g *grpcServer) authUnary(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	if err := g.checkAuth(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream authenticates streaming calls.
+func (g *grpcServer) authStream(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	if err := g.checkAuth(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// subscribe adds a new subscription to the passed set of subscriptions.
+func (g *grpcServer) subscribe(subs map[*grpcSubscription]struct{}, coalesce bool) *grpcSubscription {
+	sub := &grpcSubscription{
+		ntfns:    make(chan interface{}, grpcSubscriptionBuffer),
+		coalesce: coalesce,
+		dropped:  make(chan struct{}),
+	}
+	g.mtx.Lock()
+	subs[sub] = struct{}{}
+	g.mtx.Unlock()
+	return sub
+}
+
+// unsubscribe removes the passed subscription from the passed set.
+func (g *grpcServer) unsubscribe(subs map[*grpcSubscription]struct{}, sub *grpcSubscription) {
+	g.mtx.Lock()
+	delete(subs, sub)
+	g.mtx.Unlock()
+}
+
+// notify queues the passed notification for every subscription of the passed
+// set.  Subscriptions with a full queue are removed and their calls ended,
+// unless they coalesce notifications.
+func (g *grpcServer) notify(subs map[*grpcSubscription]struct{}, ntfn interface{}) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	for sub := range subs {
+		select {
+		case sub.ntfns <- ntfn:
+		default:
+			if sub.coalesce {
+				continue
+			}
+			delete(subs, sub)
+			close(sub.dropped)
+		}
+	}
+}
+
+// NotifyBlockConnected notifies the streaming calls of a block connected to
+// the main chain.
+func (g *grpcServer) NotifyBlockConnected(block *provautil.Block) {
+	ntfn := &grpcBlockNtfn{block: block, connected: true}
+	g.notify(g.blockSubs, ntfn)
+	g.notify(g.templateSubs, ntfn)
+}
+
+// NotifyBlockDisconnected notifies the streaming calls of a block disconnected
+// from the main chain.
+func (g *grpcServer) NotifyBlockDisconnected(block *provautil.Block) {
+	ntfn := &grpcBlockNtfn{block: block}
+	g.notify(g.blockSubs, ntfn)
+	g.notify(g.templateSubs, ntfn)
+}
+
+// NotifyMempoolTx notifies the streaming calls of a transaction accepted into
+// the memory pool.
+func (g *grpcServer) NotifyMempoolTx(tx *provautil.Tx) {
+	g.notify(g.txSubs, tx)
+	g.notify(g.templateSubs, tx)
+}
+
+// parseHash returns the hash in the passed raw bytes.
+func parseHash(b []byte) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return hash, nil
+}
+
+// GetBestBlock returns the best block of the main chain.
+func (g *grpcServer) GetBestBlock(ctx context.Context, req *grpcapi.GetBestBlockRequest) (*grpcapi.GetBestBlockResponse, error) {
+	best := g.rpc.chain.BestSnapshot()
+	return &grpcapi.GetBestBlockResponse{
+		Hash:       best.Hash[:],
+		Height:     best.Height,
+		MedianTime: best.MedianTime.Unix(),
+	}, nil
+}
+
+// GetBlock returns a block of the main chain by hash or height.
+func (g *grpcServer) GetBlock(ctx context.Context, req *grpcapi.GetBlockRequest) (*grpcapi.GetBlockResponse, error) {
+	var hash *chainhash.Hash
+	var err error
+	switch id := req.Id.(type) {
+	case *grpcapi.GetBlockRequest_Hash:
+		hash, err = parseHash(id.Hash)
+		if err != nil {
+			return nil, err
+		}
+	case *grpcapi.GetBlockRequest_Height:
+		hash, err = g.rpc.chain.BlockHashByHeight(id.Height)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound,
+				"no block at height %d", id.Height)
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument,
+			"a block hash or height is required")
+	}
+
+	height, err := g.rpc.chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound,
+			"block %v is not in the main chain", hash)
+	}
+	var blkBytes []byte
+	err = g.rpc.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		blkBytes, err = dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "block %v not found",
+			hash)
+	}
+
+	best := g.rpc.chain.BestSnapshot()
+	return &grpcapi.GetBlockResponse{
+		Hash:          hash[:],
+		Height:        height,
+		Confirmations: best.Height - height + 1,
+		Block:         blkBytes,
+	}, nil
+}
+
+// GetTransaction returns a transaction of the memory pool, or of the main chain
+// when the transaction index is enabled.
+func (g *grpcServer) GetTransaction(ctx context.Context, req *grpcapi.GetTransactionRequest) (*grpcapi.GetTransactionResponse, error) {
+	txHash, err := parseHash(req.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx, err := g.rpc.server.txMemPool.FetchTransaction(txHash); err == nil {
+		var buf bytes.Buffer
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &grpcapi.GetTransactionResponse{
+			Transaction: buf.Bytes(),
+		}, nil
+	}
+
+	txIndex := g.rpc.server.indexes().txIndex
+	if txIndex == nil {
+		return nil, status.Error(codes.FailedPrecondition,
+			"the transaction index must be enabled to query the "+
+				"blockchain (specify --txindex)")
+	}
+	blockRegion, err := txIndex.TxBlockRegion(txHash)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if blockRegion == nil {
+		return nil, status.Errorf(codes.NotFound,
+			"no information available about transaction %v", txHash)
+	}
+	var txBytes []byte
+	err = g.rpc.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound,
+			"no information available about transaction %v", txHash)
+	}
+	height, err := g.rpc.chain.BlockHeightByHash(blockRegion.Hash)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	best := g.rpc.chain.BestSnapshot()
+	return &grpcapi.GetTransactionResponse{
+		Transaction:   txBytes,
+		BlockHash:     blockRegion.Hash[:],
+		BlockHeight:   height,
+		Confirmations: best.Height - height + 1,
+	}, nil
+}
+
+// GetMempool returns the transactions of the memory pool.
+func (g *grpcServer) GetMempool(ctx context.Context, req *grpcapi.GetMempoolRequest) (*grpcapi.GetMempoolResponse, error) {
+	descs := g.rpc.server.txMemPool.TxDescs()
+	txs := make([]*grpcapi.MempoolTransaction, 0, len(descs))
+	for _, desc := range descs {
+		txs = append(txs, &grpcapi.MempoolTransaction{
+			Hash:   desc.Tx.Hash()[:],
+			Size:   int32(desc.Tx.MsgTx().SerializeSize()),
+			Fee:    desc.Fee,
+			Time:   desc.Added.Unix(),
+			Height: desc.Height,
+		})
+	}
+	return &grpcapi.GetMempoolResponse{Transactions: txs}, nil
+}
+
+// SendTransaction submits a transaction to the memory pool and relays it to the
+// network.
+func (g *grpcServer) SendTransaction(ctx context.Context, req *grpcapi.SendTransactionRequest) (*grpcapi.SendTransactionResponse, error) {
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(req.Transaction)); err != nil {
+		return nil, status.Error(codes.InvalidArgument,
+			"TX decode failed: "+err.Error())
+	}
+
+	tx := provautil.NewTx(&msgTx)
+	s := g.rpc.server
+	acceptedTxs, err := s.txMemPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); ok {
+			rpcsLog.Debugf("Rejected transaction %v: %v", tx.Hash(),
+				err)
+		} else {
+			rpcsLog.Errorf("Failed to process transaction %v: %v",
+				tx.Hash(), err)
+		}
+		return nil, status.Error(codes.InvalidArgument,
+			"TX rejected: "+err.Error())
+	}
+
+	// The transaction should be the first accepted transaction.  Remove
+	// it from the memory pool when it is not, since an error is returned.
+	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
+		s.txMemPool.RemoveTransaction(tx, true)
+		return nil, status.Errorf(codes.Internal,
+			"transaction %v is not in accepted list", tx.Hash())
+	}
+
+	s.AnnounceNewTransactions(acceptedTxs)
+
+	// Rebroadcast the transaction until it is included in a block.
+	txD := acceptedTxs[0]
+	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+	s.AddRebroadcastInventory(iv, txD)
+
+	return &grpcapi.SendTransactionResponse{Hash: tx.Hash()[:]}, nil
+}
+
+// blockTemplate returns a new template of the next block.
+func (g *grpcServer) blockTemplate() (*grpcapi.BlockTemplate, error) {
+	// The coinbase of the template can be redeemed by anyone, which is
+	// acceptable since callers create their own coinbase.
+	template, err := g.rpc.generator.NewBlockTemplate(nil, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal,
+			"Failed to create new block template: "+err.Error())
+	}
+	msgBlock := template.Block
+
+	var header bytes.Buffer
+	if err := msgBlock.Header.Serialize(&header); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var coinbaseValue int64
+	for _, txOut := range msgBlock.Transactions[0].TxOut {
+		coinbaseValue += txOut.Value
+	}
+	txs := make([][]byte, 0, len(msgBlock.Transactions)-1)
+	for _, tx := range msgBlock.Transactions[1:] {
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		txs = append(txs, buf.Bytes())
+	}
+
+	return &grpcapi.BlockTemplate{
+		Height:        template.Height,
+		PreviousHash:  msgBlock.Header.PrevBlock[:],
+		Header:        header.Bytes(),
+		Bits:          msgBlock.Header.Bits,
+		CoinbaseValue: coinbaseValue,
+		Transactions:  txs,
+		Fees:          template.Fees[1:],
+	}, nil
+}
+
+// GetBlockTemplate returns a template of the next block.
+func (g *grpcServer) GetBlockTemplate(ctx context.Context, req *grpcapi.GetBlockTemplateRequest) (*grpcapi.BlockTemplate, error) {
+	return g.blockTemplate()
+}
+
+// GetAdminKeys returns the admin keys of the main chain.
+func (g *grpcServer) GetAdminKeys(ctx context.Context, req *grpcapi.GetAdminKeysRequest) (*grpcapi.GetAdminKeysResponse, error) {
+	chain := g.rpc.chain
+	best := chain.BestSnapshot()
+	adminKeySets := chain.AdminKeySets()
+	serializeKeys := func(keySet btcec.PublicKeySet) [][]byte {
+		keys := make([][]byte, len(keySet))
+		for i := range keySet {
+			keys[i] = keySet[i].SerializeCompressed()
+		}
+		return keys
+	}
+
+	keyIDs := chain.KeyIDs()
+	aspKeys := make([]*grpcapi.AspKey, 0, len(keyIDs))
+	for keyID, pubKey := range keyIDs {
+		aspKeys = append(aspKeys, &grpcapi.AspKey{
+			KeyId:  uint32(keyID),
+			PubKey: pubKey.SerializeCompressed(),
+		})
+	}
+
+	return &grpcapi.GetAdminKeysResponse{
+		Hash:          best.Hash[:],
+		Height:        best.Height,
+		RootKeys:      serializeKeys(adminKeySets[btcec.RootKeySet]),
+		ProvisionKeys: serializeKeys(adminKeySets[btcec.ProvisionKeySet]),
+		IssueKeys:     serializeKeys(adminKeySets[btcec.IssueKeySet]),
+		ValidateKeys:  serializeKeys(adminKeySets[btcec.ValidateKeySet]),
+		AspKeys:       aspKeys,
+		LastKeyId:     uint32(chain.LastKeyID()),
+		TotalSupply:   int64(chain.TotalSupply()),
+	}, nil
+}
+
+// waitNotification waits for the next notification of the passed subscription
+// of a streaming call with the passed context.  An error is returned when the
+// call must end.
+func (g *grpcServer) waitNotification(ctx context.Context, sub *grpcSubscription) (interface{}, error) {
+	select {
+	case ntfn := <-sub.ntfns:
+		return ntfn, nil
+	case <-sub.dropped:
+		return nil, status.Error(codes.ResourceExhausted,
+			"notifications were not received fast enough")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-g.quit:
+		return nil, status.Error(codes.Unavailable,
+			"server is shutting down")
+	}
+}
+
+// SubscribeBlocks streams the blocks connected to and disconnected from the
+// main chain.
+func (g *grpcServer) SubscribeBlocks(req *grpcapi.SubscribeBlocksRequest, stream grpcapi.ProvaNode_SubscribeBlocksServer) error {
+	sub := g.subscribe(g.blockSubs, false)
+	defer g.unsubscribe(g.blockSubs, sub)
+
+	for {
+		ntfn, err := g.waitNotification(stream.Context(), sub)
+		if err != nil {
+			return err
+		}
+		blockNtfn := ntfn.(*grpcBlockNtfn)
+		block := blockNtfn.block
+		msg := &grpcapi.BlockNotification{
+			Type:   grpcapi.BlockNotification_DISCONNECTED,
+			Hash:   block.Hash()[:],
+			Height: block.Height(),
+		}
+		if blockNtfn.connected {
+			msg.Type = grpcapi.BlockNotification_CONNECTED
+		}
+		if req.IncludeBlocks {
+			msg.Block, err = block.Bytes()
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeTransactions streams the transactions accepted into the memory pool.
+func (g *grpcServer) SubscribeTransactions(req *grpcapi.SubscribeTransactionsRequest, stream grpcapi.ProvaNode_SubscribeTransactionsServer) error {
+	sub := g.subscribe(g.txSubs, false)
+	defer g.unsubscribe(g.txSubs, sub)
+
+	for {
+		ntfn, err := g.waitNotification(stream.Context(), sub)
+		if err != nil {
+			return err
+		}
+		tx := ntfn.(*provautil.Tx)
+		var buf bytes.Buffer
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		err = stream.Send(&grpcapi.TransactionNotification{
+			Hash:        tx.Hash()[:],
+			Transaction: buf.Bytes(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeBlockTemplates streams a new block template whenever the best block
+// changes, or the memory pool changed and the last template is older than the
+// regeneration interval of getblocktemplate.
+func (g *grpcServer) SubscribeBlockTemplates(req *grpcapi.GetBlockTemplateRequest, stream grpcapi.ProvaNode_SubscribeBlockTemplatesServer) error {
+	sub := g.subscribe(g.templateSubs, true)
+	defer g.unsubscribe(g.templateSubs, sub)
+
+	regenerate := time.Second * gbtRegenerateSeconds
+	var lastGenerated time.Time
+	var stale bool
+	send := func() error {
+		template, err := g.blockTemplate()
+		if err != nil {
+			return err
+		}
+		lastGenerated = time.Now()
+		stale = false
+		return stream.Send(template)
+	}
+	if err := send(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(regenerate)
+	defer timer.Stop()
+	for {
+		select {
+		case ntfn := <-sub.ntfns:
+			if _, ok := ntfn.(*grpcBlockNtfn); !ok {
+				// Only send a template for memory pool changes
+				// once the last template is old enough.
+				stale = true
+				if time.Since(lastGenerated) < regenerate {
+					continue
+				}
+			}
+		case <-timer.C:
+			timer.Reset(regenerate)
+			if !stale {
+				continue
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-g.quit:
+			return status.Error(codes.Unavailable,
+				"server is shutting down")
+		}
+
+		if err := send(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !grpc
+
+package main
+
+import (
+	"errors"
+
+	"github.com/bitgo/prova/provautil"
+)
+
+// errGRPCUnsupported is returned when the gRPC server is requested from a
+// build without gRPC support.
+var errGRPCUnsupported = errors.New("gRPC support is not available in " +
+	"this build (rebuild with -tags grpc)")
+
+// grpcServer serves the gRPC API of the node.  This build lacks gRPC support,
+// so no server can be created.
+type grpcServer struct{}
+
+// newGRPCServer always returns errGRPCUnsupported since this build lacks gRPC
+// support.
+func newGRPCServer(listenAddrs []string, rpc *rpcServer) (*grpcServer, error) {
+	return nil, errGRPCUnsupported
+}
+
+// Start does nothing since this build lacks gRPC support.
+func (g *grpcServer) Start() {}
+
+// Stop does nothing since this build lacks gRPC support.
+func (g *grpcServer) Stop() {}
+
+// NotifyBlockConnected does nothing since this build lacks gRPC support.
+func (g *grpcServer) NotifyBlockConnected(block *provautil.Block) {}
+
+// NotifyBlockDisconnected does nothing since this build lacks gRPC support.
+func (g *grpcServer) NotifyBlockDisconnected(block *provautil.Block) {}
+
+// NotifyMempoolTx does nothing since this build lacks gRPC support.
+func (g *grpcServer) NotifyMempoolTx(tx *provautil.Tx) {}
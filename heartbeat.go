@@ -0,0 +1,224 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// maxHeartbeatDrift is the maximum difference between the timestamp of
+	// a heartbeat and the local time for the heartbeat to be accepted and
+	// relayed.  It prevents old heartbeats from being replayed.
+	maxHeartbeatDrift = 10 * time.Minute
+
+	// heartbeatStallTimeout is the duration without a heartbeat after which
+	// an active validate key is reported as stalled.
+	heartbeatStallTimeout = 5 * time.Minute
+)
+
+// errInvalidHeartbeat indicates a heartbeat is not signed by its validate key.
+var errInvalidHeartbeat = errors.New("heartbeat signature does not verify")
+
+// validatorHeartbeat houses the latest heartbeat known for a validate key.
+type validatorHeartbeat struct {
+	Msg      *wire.MsgHeartbeat
+	Received time.Time
+
+	// From is the address of the peer the heartbeat was received from.  It
+	// is empty for the heartbeats of the validate keys held by this node.
+	From string
+}
+
+// heartbeatConfig houses the dependencies of a heartbeat manager.
+type heartbeatConfig struct {
+	// Interval is the interval between the heartbeats sent for the
+	// validate keys held by this node.  Zero disables sending them.
+	Interval time.Duration
+
+	// ValidateKeys returns the current validate key set.
+	ValidateKeys func() btcec.PublicKeySet
+
+	// LocalKeys returns the validate keys held by this node.
+	LocalKeys func() []btcec.Signer
+
+	// BestSnapshot returns the best block of the main chain.
+	BestSnapshot func() *blockchain.BestState
+
+	// Relay relays a heartbeat to all peers supporting heartbeats, except
+	// the peer it was received from, which is nil for the heartbeats of
+	// this node.
+	Relay func(msg *wire.MsgHeartbeat, from *serverPeer)
+}
+
+// heartbeatManager announces the validate keys held by this node as alive with
+// periodic heartbeat messages, and keeps the latest heartbeat received for
+// every active validate key so stalled validators can be detected through the
+// getvalidatorheartbeats RPC.
+type heartbeatManager struct {
+	cfg heartbeatConfig
+
+	mtx        sync.Mutex
+	heartbeats map[wire.BlockValidatingPubKey]*validatorHeartbeat
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newHeartbeatManager returns a new heartbeat manager using the passed config.
+func newHeartbeatManager(cfg *heartbeatConfig) *heartbeatManager {
+	return &heartbeatManager{
+		cfg:        *cfg,
+		heartbeats: make(map[wire.BlockValidatingPubKey]*validatorHeartbeat),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Start begins sending heartbeats when they are enabled.
+func (m *heartbeatManager) Start() {
+	if m.cfg.Interval <= 0 {
+		return
+	}
+
+	m.wg.Add(1)
+	go m.heartbeatHandler()
+}
+
+// Stop stops sending heartbeats and waits for the manager to exit.
+func (m *heartbeatManager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// isActive returns whether the passed validating public key is part of the
+// current validate key set.
+func (m *heartbeatManager) isActive(pubKey wire.BlockValidatingPubKey) bool {
+	key, err := btcec.ParsePubKey(pubKey[:], btcec.S256())
+	if err != nil {
+		return false
+	}
+	return m.cfg.ValidateKeys().Pos(key) >= 0
+}
+
+// add records the passed heartbeat when it is newer than the heartbeat known
+// for its validate key, and returns whether it was recorded.
+func (m *heartbeatManager) add(msg *wire.MsgHeartbeat, from string, now time.Time) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	known, ok := m.heartbeats[msg.ValidatingPubKey]
+	if ok && !msg.Timestamp.After(known.Msg.Timestamp) {
+		return false
+	}
+	m.heartbeats[msg.ValidatingPubKey] = &validatorHeartbeat{
+		Msg:      msg,
+		Received: now,
+		From:     from,
+	}
+	return true
+}
+
+// ProcessHeartbeat records the passed heartbeat received from the peer with the
+// passed address when it is the newest heartbeat of an active validate key, and
+// returns whether it was recorded and should be relayed.  Heartbeats which are
+// not signed by their validate key are reported with an error, as peers only
+// relay heartbeats they verified.
+//
+// This function is safe for concurrent access.
+func (m *heartbeatManager) ProcessHeartbeat(msg *wire.MsgHeartbeat, from string) (bool, error) {
+	now := time.Now()
+	drift := msg.Timestamp.Sub(now)
+	if drift > maxHeartbeatDrift || drift < -maxHeartbeatDrift {
+		peerLog.Debugf("Ignoring heartbeat of validate key %v from %s "+
+			"with timestamp %v", msg.ValidatingPubKey, from,
+			msg.Timestamp)
+		return false, nil
+	}
+	if !msg.Verify() {
+		return false, errInvalidHeartbeat
+	}
+
+	// Heartbeats of keys which are not part of the validate key set are
+	// not relayed.  The key may have been revoked, or this node may not
+	// know about the key yet, so the peer is not penalized.
+	if !m.isActive(msg.ValidatingPubKey) {
+		peerLog.Debugf("Ignoring heartbeat of inactive validate key %v "+
+			"from %s", msg.ValidatingPubKey, from)
+		return false, nil
+	}
+	if !m.add(msg, from, now) {
+		return false, nil
+	}
+	peerLog.Tracef("Heartbeat of validate key %v at height %d from %s",
+		msg.ValidatingPubKey, msg.Height, from)
+	return true, nil
+}
+
+// Heartbeats returns the latest heartbeats known for each validate key.
+//
+// This function is safe for concurrent access.
+func (m *heartbeatManager) Heartbeats() map[wire.BlockValidatingPubKey]validatorHeartbeat {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	heartbeats := make(map[wire.BlockValidatingPubKey]validatorHeartbeat,
+		len(m.heartbeats))
+	for pubKey, heartbeat := range m.heartbeats {
+		heartbeats[pubKey] = *heartbeat
+	}
+	return heartbeats
+}
+
+// sendHeartbeats signs and relays a heartbeat for each active validate key
+// held by this node.
+func (m *heartbeatManager) sendHeartbeats() {
+	best := m.cfg.BestSnapshot()
+	validateKeys := m.cfg.ValidateKeys()
+	for _, key := range m.cfg.LocalKeys() {
+		if validateKeys.Pos(key.PubKey()) < 0 {
+			continue
+		}
+		msg := wire.NewMsgHeartbeat(best.Height, best.Hash)
+		if err := msg.Sign(key); err != nil {
+			srvrLog.Errorf("Failed to sign heartbeat: %v", err)
+			continue
+		}
+		if m.add(msg, "", time.Now()) {
+			m.cfg.Relay(msg, nil)
+		}
+	}
+}
+
+// heartbeatHandler sends heartbeats every interval until the manager is
+// stopped.  It must be run as a goroutine.
+func (m *heartbeatManager) heartbeatHandler() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sendHeartbeats()
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// heartbeatStalled returns whether an active validate key with the passed
+// latest heartbeat, which is nil when none is known, is considered stalled at
+// the passed time.
+func heartbeatStalled(heartbeat *validatorHeartbeat, now time.Time) bool {
+	return heartbeat == nil ||
+		now.Sub(heartbeat.Msg.Timestamp) > heartbeatStallTimeout
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestProcessHeartbeat ensures heartbeats are only recorded and relayed when
+// they are the newest valid heartbeat of an active validate key.
+func TestProcessHeartbeat(t *testing.T) {
+	activeKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	inactiveKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	m := newHeartbeatManager(&heartbeatConfig{
+		ValidateKeys: func() btcec.PublicKeySet {
+			return btcec.PublicKeySet{*activeKey.PubKey()}
+		},
+	})
+
+	signed := func(key *btcec.PrivateKey, timestamp time.Time) *wire.MsgHeartbeat {
+		msg := wire.NewMsgHeartbeat(10, &chainhash.Hash{0x01})
+		msg.Timestamp = time.Unix(timestamp.Unix(), 0)
+		if err := msg.Sign(key); err != nil {
+			t.Fatalf("Sign: unexpected error: %v", err)
+		}
+		return msg
+	}
+
+	now := time.Now()
+	stale := signed(activeKey, now.Add(-time.Minute))
+	fresh := signed(activeKey, now)
+	forged := signed(activeKey, now.Add(time.Minute))
+	forged.Height++
+
+	tests := []struct {
+		name    string
+		msg     *wire.MsgHeartbeat
+		relay   bool
+		invalid bool
+	}{
+		{"fresh", fresh, true, false},
+		{"duplicate", fresh, false, false},
+		{"older", stale, false, false},
+		{"invalid signature", forged, false, true},
+		{"inactive key", signed(inactiveKey, now), false, false},
+		{"too old", signed(activeKey, now.Add(-time.Hour)), false, false},
+		{"too new", signed(activeKey, now.Add(time.Hour)), false, false},
+	}
+	for _, test := range tests {
+		relay, err := m.ProcessHeartbeat(test.msg, "127.0.0.1:18333")
+		if (err != nil) != test.invalid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if relay != test.relay {
+			t.Errorf("%s: got relay %v, want %v", test.name, relay,
+				test.relay)
+		}
+	}
+
+	heartbeats := m.Heartbeats()
+	if len(heartbeats) != 1 {
+		t.Fatalf("Heartbeats: got %d heartbeats, want 1", len(heartbeats))
+	}
+	heartbeat := heartbeats[fresh.ValidatingPubKey]
+	if heartbeat.Msg != fresh {
+		t.Errorf("Heartbeats: got heartbeat %v, want %v", heartbeat.Msg,
+			fresh)
+	}
+	if heartbeatStalled(&heartbeat, now) {
+		t.Error("heartbeatStalled: fresh heartbeat reported as stalled")
+	}
+	if !heartbeatStalled(&heartbeat, now.Add(heartbeatStallTimeout+time.Minute)) {
+		t.Error("heartbeatStalled: old heartbeat not reported as stalled")
+	}
+	if !heartbeatStalled(nil, now) {
+		t.Error("heartbeatStalled: missing heartbeat not reported as stalled")
+	}
+}
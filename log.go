@@ -10,6 +10,8 @@ import (
 	"os"
 
 	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/alert"
+	"github.com/bitgo/prova/auditlog"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/connmgr"
@@ -18,7 +20,10 @@ import (
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/mining/cpuminer"
 	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/screening"
+	"github.com/bitgo/prova/spv"
 	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
 	"github.com/btcsuite/btclog"
 	"github.com/btcsuite/seelog"
 )
@@ -29,8 +34,11 @@ import (
 // function.
 var (
 	backendLog = seelog.Disabled
+	logFormat  = logFormatText
 	adxrLog    = btclog.Disabled
+	alrtLog    = btclog.Disabled
 	amgrLog    = btclog.Disabled
+	audtLog    = btclog.Disabled
 	cmgrLog    = btclog.Disabled
 	bcdbLog    = btclog.Disabled
 	bmgrLog    = btclog.Disabled
@@ -41,15 +49,20 @@ var (
 	minrLog    = btclog.Disabled
 	peerLog    = btclog.Disabled
 	rpcsLog    = btclog.Disabled
+	scrnLog    = btclog.Disabled
 	scrpLog    = btclog.Disabled
+	spvcLog    = btclog.Disabled
 	srvrLog    = btclog.Disabled
 	txmpLog    = btclog.Disabled
+	wlltLog    = btclog.Disabled
 )
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
 var subsystemLoggers = map[string]btclog.Logger{
 	"ADXR": adxrLog,
+	"ALRT": alrtLog,
 	"AMGR": amgrLog,
+	"AUDT": audtLog,
 	"CMGR": cmgrLog,
 	"BCDB": bcdbLog,
 	"BMGR": bmgrLog,
@@ -60,9 +73,12 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"PEER": peerLog,
 	"PRVA": btcdLog,
 	"RPCS": rpcsLog,
+	"SCRN": scrnLog,
 	"SCRP": scrpLog,
+	"SPVC": spvcLog,
 	"SRVR": srvrLog,
 	"TXMP": txmpLog,
+	"WLLT": wlltLog,
 }
 
 // useLogger updates the logger references for subsystemID to logger.  Invalid
@@ -77,10 +93,18 @@ func useLogger(subsystemID string, logger btclog.Logger) {
 	case "ADXR":
 		adxrLog = logger
 
+	case "ALRT":
+		alrtLog = logger
+		alert.UseLogger(logger)
+
 	case "AMGR":
 		amgrLog = logger
 		addrmgr.UseLogger(logger)
 
+	case "AUDT":
+		audtLog = logger
+		auditlog.UseLogger(logger)
+
 	case "CMGR":
 		cmgrLog = logger
 		connmgr.UseLogger(logger)
@@ -118,22 +142,36 @@ func useLogger(subsystemID string, logger btclog.Logger) {
 	case "RPCS":
 		rpcsLog = logger
 
+	case "SCRN":
+		scrnLog = logger
+		screening.UseLogger(logger)
+
 	case "SCRP":
 		scrpLog = logger
 		txscript.UseLogger(logger)
 
+	case "SPVC":
+		spvcLog = logger
+		spv.UseLogger(logger)
+
 	case "SRVR":
 		srvrLog = logger
 
 	case "TXMP":
 		txmpLog = logger
 		mempool.UseLogger(logger)
+
+	case "WLLT":
+		wlltLog = logger
+		wallet.UseLogger(logger)
 	}
 }
 
 // initSeelogLogger initializes a new seelog logger that is used as the backend
-// for all logging subsystems.
-func initSeelogLogger(logFile string) {
+// for all logging subsystems.  The messages are written in the passed format,
+// one of the formats accepted by --logformat.  JSON messages are complete
+// objects, so the backend writes them as they are.
+func initSeelogLogger(logFile string, format string) {
 	config := `
 	<seelog type="adaptive" mininterval="2000000" maxinterval="100000000"
 		critmsgcount="500" minlevel="trace">
@@ -142,10 +180,14 @@ func initSeelogLogger(logFile string) {
 			<rollingfile type="size" filename="%s" maxsize="10485760" maxrolls="3" />
 		</outputs>
 		<formats>
-			<format id="all" format="%%Time %%Date [%%LEV] %%Msg%%n" />
+			<format id="all" format="%s" />
 		</formats>
 	</seelog>`
-	config = fmt.Sprintf(config, logFile)
+	msgFormat := "%Time %Date [%LEV] %Msg%n"
+	if format == logFormatJSON {
+		msgFormat = "%Msg%n"
+	}
+	config = fmt.Sprintf(config, logFile, msgFormat)
 
 	logger, err := seelog.LoggerFromConfigAsString(config)
 	if err != nil {
@@ -154,6 +196,7 @@ func initSeelogLogger(logFile string) {
 	}
 
 	backendLog = logger
+	logFormat = format
 }
 
 // setLogLevel sets the logging level for provided subsystem.  Invalid
@@ -174,7 +217,12 @@ func setLogLevel(subsystemID string, logLevel string) {
 
 	// Create new logger for the subsystem if needed.
 	if logger == btclog.Disabled {
-		logger = btclog.NewSubsystemLogger(backendLog, subsystemID+": ")
+		if logFormat == logFormatJSON {
+			logger = newJSONLogger(backendLog, subsystemID)
+		} else {
+			logger = btclog.NewSubsystemLogger(backendLog,
+				subsystemID+": ")
+		}
 		useLogger(subsystemID, logger)
 	}
 	logger.SetLevel(level)
@@ -0,0 +1,255 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/seelog"
+)
+
+const (
+	// logFormatText and logFormatJSON are the formats of the log messages
+	// selected with --logformat.
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// knownLogFormats are the log formats accepted by --logformat.
+var knownLogFormats = []string{logFormatText, logFormatJSON}
+
+// logFields are the structured fields attached to a log message, such as the
+// peer, block hash, txid and height it is about.
+type logFields map[string]interface{}
+
+// Ensure jsonLogger implements the btclog.Logger interface.
+var _ btclog.Logger = (*jsonLogger)(nil)
+
+// jsonLogger is a btclog.Logger which writes every message as a JSON object on
+// a single line, so log pipelines can index the events by subsystem and by the
+// fields attached with withLogFields instead of parsing the text.  The loggers
+// derived with withLogFields share the level of the subsystem logger.
+type jsonLogger struct {
+	backend   seelog.LoggerInterface
+	subsystem string
+	level     *uint32
+	fields    logFields
+}
+
+// newJSONLogger returns a new JSON logger for the passed subsystem which writes
+// to the passed backend.
+func newJSONLogger(backend seelog.LoggerInterface, subsystem string) *jsonLogger {
+	level := uint32(btclog.InfoLvl)
+	return &jsonLogger{
+		backend:   backend,
+		subsystem: subsystem,
+		level:     &level,
+	}
+}
+
+// withLogFields returns a logger which attaches the passed fields to the
+// messages of the passed logger.  Text loggers already carry the same details
+// in their messages, so they are returned as is.
+func withLogFields(logger btclog.Logger, fields logFields) btclog.Logger {
+	l, ok := logger.(*jsonLogger)
+	if !ok {
+		return logger
+	}
+	merged := make(logFields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &jsonLogger{
+		backend:   l.backend,
+		subsystem: l.subsystem,
+		level:     l.level,
+		fields:    merged,
+	}
+}
+
+// filter returns whether or not the log message should be filtered based on
+// the passed level versus the current level.
+func (l *jsonLogger) filter(level btclog.LogLevel) bool {
+	return level < l.Level()
+}
+
+// encode returns the JSON object of a message at the passed level.  The fields
+// are encoded with their string representation when they implement
+// fmt.Stringer, such as hashes and peers, and may not replace the time,
+// level, subsystem and message.
+func (l *jsonLogger) encode(level btclog.LogLevel, msg string) string {
+	entry := make(map[string]interface{}, len(l.fields)+4)
+	for k, v := range l.fields {
+		if s, ok := v.(fmt.Stringer); ok {
+			v = s.String()
+		}
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["subsystem"] = l.subsystem
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// Fall back to the message alone when a field can't be
+		// encoded so the message is not lost.
+		line, _ = json.Marshal(map[string]string{
+			"time":      entry["time"].(string),
+			"level":     level.String(),
+			"subsystem": l.subsystem,
+			"msg":       msg,
+			"error":     err.Error(),
+		})
+	}
+	return string(line)
+}
+
+// write writes the message at the passed level to the backend.
+func (l *jsonLogger) write(level btclog.LogLevel, msg string) error {
+	if l.filter(level) {
+		return nil
+	}
+	line := l.encode(level, msg)
+	switch level {
+	case btclog.TraceLvl:
+		l.backend.Trace(line)
+	case btclog.DebugLvl:
+		l.backend.Debug(line)
+	case btclog.InfoLvl:
+		l.backend.Info(line)
+	case btclog.WarnLvl:
+		return l.backend.Warn(line)
+	case btclog.ErrorLvl:
+		return l.backend.Error(line)
+	default:
+		return l.backend.Critical(line)
+	}
+	return nil
+}
+
+// Tracef formats message according to format specifier and writes to log with
+// TraceLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Tracef(format string, params ...interface{}) {
+	l.write(btclog.TraceLvl, fmt.Sprintf(format, params...))
+}
+
+// Debugf formats message according to format specifier and writes to log with
+// DebugLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Debugf(format string, params ...interface{}) {
+	l.write(btclog.DebugLvl, fmt.Sprintf(format, params...))
+}
+
+// Infof formats message according to format specifier and writes to log with
+// InfoLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Infof(format string, params ...interface{}) {
+	l.write(btclog.InfoLvl, fmt.Sprintf(format, params...))
+}
+
+// Warnf formats message according to format specifier and writes to log with
+// WarnLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Warnf(format string, params ...interface{}) error {
+	return l.write(btclog.WarnLvl, fmt.Sprintf(format, params...))
+}
+
+// Errorf formats message according to format specifier and writes to log with
+// ErrorLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Errorf(format string, params ...interface{}) error {
+	return l.write(btclog.ErrorLvl, fmt.Sprintf(format, params...))
+}
+
+// Criticalf formats message according to format specifier and writes to log
+// with CriticalLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Criticalf(format string, params ...interface{}) error {
+	return l.write(btclog.CriticalLvl, fmt.Sprintf(format, params...))
+}
+
+// Trace formats message using the default formats for its operands and writes
+// to log with TraceLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Trace(v ...interface{}) {
+	l.write(btclog.TraceLvl, fmt.Sprint(v...))
+}
+
+// Debug formats message using the default formats for its operands and writes
+// to log with DebugLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Debug(v ...interface{}) {
+	l.write(btclog.DebugLvl, fmt.Sprint(v...))
+}
+
+// Info formats message using the default formats for its operands and writes
+// to log with InfoLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Info(v ...interface{}) {
+	l.write(btclog.InfoLvl, fmt.Sprint(v...))
+}
+
+// Warn formats message using the default formats for its operands and writes
+// to log with WarnLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Warn(v ...interface{}) error {
+	return l.write(btclog.WarnLvl, fmt.Sprint(v...))
+}
+
+// Error formats message using the default formats for its operands and writes
+// to log with ErrorLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Error(v ...interface{}) error {
+	return l.write(btclog.ErrorLvl, fmt.Sprint(v...))
+}
+
+// Critical formats message using the default formats for its operands and
+// writes to log with CriticalLvl.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Critical(v ...interface{}) error {
+	return l.write(btclog.CriticalLvl, fmt.Sprint(v...))
+}
+
+// Level returns the current logging level.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Level() btclog.LogLevel {
+	return btclog.LogLevel(atomic.LoadUint32(l.level))
+}
+
+// SetLevel changes the logging level to the passed level.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) SetLevel(level btclog.LogLevel) {
+	atomic.StoreUint32(l.level, uint32(level))
+}
+
+// Close the logger.  Any future log messages will be ignored.
+//
+// This is part of the btclog.Logger interface implementation.
+func (l *jsonLogger) Close() {
+	l.SetLevel(btclog.Off)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/seelog"
+)
+
+// TestJSONLogger ensures the JSON logger writes one object per message with
+// the subsystem and the attached fields, and filters messages by level.
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	backend, err := seelog.LoggerFromWriterWithMinLevelAndFormat(&buf,
+		seelog.TraceLvl, "%Msg%n")
+	if err != nil {
+		t.Fatalf("LoggerFromWriterWithMinLevelAndFormat: unexpected "+
+			"error: %v", err)
+	}
+	logger := newJSONLogger(backend, "BMGR")
+
+	hash := chainhash.DoubleHashH([]byte("block"))
+	log := withLogFields(logger, logFields{
+		"peer":   "127.0.0.1:18333",
+		"block":  &hash,
+		"height": int32(7),
+		"msg":    "ignored",
+	})
+	log.Infof("Rejected block %v: %q", hash, "bad \"block\"")
+	logger.Debugf("filtered at the info level")
+
+	// The derived logger shares the level of the subsystem logger.
+	logger.SetLevel(btclog.DebugLvl)
+	log.Debug("debug ", 1)
+	backend.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("line %q is not a JSON object: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"level":     "info",
+		"subsystem": "BMGR",
+		"msg":       "Rejected block " + hash.String() + `: "bad \"block\""`,
+		"peer":      "127.0.0.1:18333",
+		"block":     hash.String(),
+		"height":    float64(7),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("field %s: got %v, want %v", k, entry[k], v)
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("no time field in %q", lines[0])
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("line %q is not a JSON object: %v", lines[1], err)
+	}
+	if entry["level"] != "debug" || entry["msg"] != "debug 1" {
+		t.Errorf("got level %v and message %v, want debug and %q",
+			entry["level"], entry["msg"], "debug 1")
+	}
+
+	// Text loggers are returned as is.
+	if withLogFields(btclog.Disabled, logFields{"peer": "x"}) !=
+		btclog.Disabled {
+		t.Errorf("withLogFields: text logger was replaced")
+	}
+}
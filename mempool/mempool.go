@@ -19,8 +19,10 @@ import (
 	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/eventlog"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/screening"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
@@ -73,6 +75,10 @@ type Config struct {
 	// GetKeyIDs defines the function to use to fetch keyID information.
 	GetKeyIDs func() btcec.KeyIdMap
 
+	// GetFrozenKeyIDs defines the function to use to fetch the frozen
+	// keyIDs.
+	GetFrozenKeyIDs func() btcec.KeyIdMap
+
 	// GetAdminKeySets defines the function to fetch admin key Sets.
 	GetAdminKeySets func() map[btcec.KeySetType]btcec.PublicKeySet
 
@@ -103,6 +109,22 @@ type Config struct {
 	// indexing the unconfirmed transactions in the memory pool.
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
+
+	// EventLog defines the optional event log instance to use for
+	// recording the transactions added to and removed from the memory
+	// pool.  This can be nil if the event log is not enabled.
+	EventLog *eventlog.Log
+
+	// Velocity defines the optional tracker of the volume spent by each
+	// keyID, which refuses new transactions exceeding the velocity limits
+	// configured by the operator.  This can be nil if no limits are
+	// configured.
+	Velocity *VelocityTracker
+
+	// Screening defines the optional hook screening transactions against
+	// the watchlists of the operator, which refuses the excluded ones.
+	// This can be nil if screening is not enabled.
+	Screening *screening.Hook
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -487,6 +509,14 @@ func (mp *TxPool) removeTransaction(tx *provautil.Tx, removeRedeemers bool) {
 		}
 		delete(mp.pool, *txHash)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+		if mp.cfg.EventLog != nil {
+			if err := mp.cfg.EventLog.TxRemoved(tx); err != nil {
+				log.Errorf("Unable to record the removal of "+
+					"transaction %v in the event log: %v",
+					txHash, err)
+			}
+		}
 	}
 }
 
@@ -524,6 +554,80 @@ func (mp *TxPool) RemoveDoubleSpends(tx *provautil.Tx) {
 	mp.mtx.Unlock()
 }
 
+// RemoveFrozenSpends removes all transactions which spend outputs co-signed by
+// the keyIDs frozen by the passed transaction from the memory pool, unless
+// they are issue thread transactions.  Removing those transactions then leads
+// to removing all transactions which rely on them, recursively.  This is
+// necessary when a block freezing keyIDs is connected to the main chain
+// because the transactions can no longer be mined.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) RemoveFrozenSpends(tx *provautil.Tx) {
+	if !txscript.IsKeyIDFreezeTx(tx) {
+		return
+	}
+	_, adminOutputs := txscript.GetAdminDetails(tx)
+	frozen := make(map[btcec.KeyID]struct{})
+	for _, pops := range adminOutputs {
+		isFreeze, _, keyID := txscript.ExtractKeyIDFreezeOpData(pops)
+		if isFreeze {
+			frozen[keyID] = struct{}{}
+		}
+	}
+	if len(frozen) == 0 {
+		return
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	for _, txD := range mp.pool {
+		poolTx := txD.Tx
+		threadInt, _ := txscript.GetAdminDetails(poolTx)
+		if threadInt >= 0 &&
+			provautil.ThreadID(threadInt) == provautil.IssueThread {
+			continue
+		}
+		if mp.spendsKeyIDs(poolTx, frozen) {
+			mp.removeTransaction(poolTx, true)
+		}
+	}
+	mp.mtx.Unlock()
+}
+
+// spendsKeyIDs returns whether the passed transaction spends any outputs
+// co-signed by one of the passed keyIDs.  Outputs which can not be found in
+// the main chain or the pool are ignored.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) spendsKeyIDs(tx *provautil.Tx, keyIDs map[btcec.KeyID]struct{}) bool {
+	utxoView, err := mp.fetchInputUtxos(tx)
+	if err != nil {
+		return false
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			continue
+		}
+		pkScript := entry.PkScriptByIndex(prevOut.Index)
+		pops, err := txscript.ParseScript(pkScript)
+		if err != nil {
+			continue
+		}
+		ids, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			continue
+		}
+		for _, keyID := range ids {
+			if _, ok := keyIDs[keyID]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
@@ -555,6 +659,14 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *provaut
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
 
+	if mp.cfg.EventLog != nil {
+		if err := mp.cfg.EventLog.TxAccepted(tx); err != nil {
+			log.Errorf("Unable to record the acceptance of "+
+				"transaction %v in the event log: %v", tx.Hash(),
+				err)
+		}
+	}
+
 	return txD
 }
 
@@ -577,6 +689,18 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *provautil.Tx) error {
 	return nil
 }
 
+// CheckSpend returns the transaction in the pool which spends the passed
+// outpoint, or nil when no transaction in the pool spends it.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) CheckSpend(op wire.OutPoint) *provautil.Tx {
+	mp.mtx.RLock()
+	txR := mp.outpoints[op]
+	mp.mtx.RUnlock()
+
+	return txR
+}
+
 // fetchInputUtxos loads utxo details about the input transactions referenced by
 // the passed transaction.  First, it loads the details form the viewpoint of
 // the main chain, then it adjusts them based upon the contents of the
@@ -695,6 +819,38 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		}
 	}
 
+	// Don't allow transactions which create timelocked outputs before the
+	// next block is able to contain them.
+	err = blockchain.CheckTransactionTimeLocks(tx, nextBlockHeight,
+		mp.cfg.ChainParams)
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, nil, chainRuleError(cerr)
+		}
+		return nil, nil, err
+	}
+
+	// Don't allow transactions which freeze keyIDs before the next block is
+	// able to contain them.
+	err = blockchain.CheckTransactionFreezes(tx, nextBlockHeight,
+		mp.cfg.ChainParams)
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, nil, chainRuleError(cerr)
+		}
+		return nil, nil, err
+	}
+
+	// Don't allow transactions which create generalized m-of-n Prova
+	// outputs unless they are enabled for the network.
+	if !mp.cfg.ChainParams.RelayGeneralProvaTxs &&
+		mining.HasGeneralProvaOutputs(tx) {
+
+		str := fmt.Sprintf("transaction %v creates generalized prova "+
+			"outputs which are not relayed on this network", txHash)
+		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
 	// The transaction may not use any of the same outputs as other
 	// transactions already in the pool as that would ultimately result in a
 	// double spend.  This check is intended to be quick and therefore only
@@ -726,7 +882,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 	keyView.SetTotalSupply(mp.cfg.TotalSupply())
 	keyView.SetLastKeyID(mp.cfg.LastKeyID())
 	keyView.SetKeyIDs(mp.cfg.GetKeyIDs())
+	keyView.SetFrozenKeyIDs(mp.cfg.GetFrozenKeyIDs())
 	keyView.SetKeys(mp.cfg.GetAdminKeySets())
+	keyView.SetAdminThresholds(mp.cfg.ChainParams.AdminThresholds)
 
 	// Don't allow the transaction if it exists in the main chain and is not
 	// not already fully spent.
@@ -785,8 +943,20 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		return nil, nil, err
 	}
 
+	// Don't allow transactions which spend outputs of frozen keyIDs unless
+	// they are recovering them.
+	err = blockchain.CheckTransactionFrozenInputs(tx, nextBlockHeight,
+		utxoView, keyView, mp.cfg.ChainParams)
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, nil, chainRuleError(cerr)
+		}
+		return nil, nil, err
+	}
+
 	// CheckTransactionOutputs checks outputs for state violations.
-	err = blockchain.CheckTransactionOutputs(tx, keyView)
+	err = blockchain.CheckTransactionOutputs(tx, nextBlockHeight, keyView,
+		mp.cfg.ChainParams)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -809,6 +979,32 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		}
 	}
 
+	// Don't allow transactions touching addresses or keyIDs which are
+	// excluded by screening.
+	if mp.cfg.Screening != nil {
+		result := mp.cfg.Screening.Check(screening.Mempool, tx, utxoView)
+		if result.Decision == screening.Exclude {
+			str := fmt.Sprintf("transaction %v has been excluded "+
+				"by screening: %s", txHash, result.Reason)
+			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	// Don't allow new transactions which would make a keyID exceed its
+	// velocity limits.  Transactions which are being added back to the
+	// memory pool from blocks that have been disconnected during a reorg
+	// were already counted, and admin transactions are not limited.
+	var volumes map[btcec.KeyID]provautil.Amount
+	threadInt, _ := txscript.GetAdminDetails(tx)
+	if isNew && mp.cfg.Velocity != nil && threadInt < 0 {
+		volumes = keyIDVolumes(tx, utxoView)
+		if err := mp.cfg.Velocity.check(volumes, time.Now()); err != nil {
+			str := fmt.Sprintf("transaction %v has been rejected "+
+				"by the velocity limits: %v", txHash, err)
+			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
 	// TODO(prova) : validate admin ops here
 
 	// NOTE: if you modify this code to accept non-standard transactions,
@@ -895,9 +1091,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 	}
 
 	// Verify crypto signatures for each input and reject the transaction if
-	// any don't verify.
+	// any don't verify.  Outputs to generalized m-of-n Prova scripts can
+	// only be spent once these scripts are active for the next block.
+	scriptFlags := txscript.StandardVerifyFlags
+	if nextBlockHeight >= mp.cfg.ChainParams.GeneralProvaActivationHeight {
+		scriptFlags |= txscript.ScriptVerifyGeneralProva
+	}
 	err = blockchain.ValidateTransactionScripts(tx, utxoView, keyView,
-		txscript.StandardVerifyFlags, mp.cfg.SigCache, mp.cfg.HashCache)
+		scriptFlags, mp.cfg.SigCache, mp.cfg.HashCache)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
 			return nil, nil, chainRuleError(cerr)
@@ -907,6 +1108,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 
 	// Add to transaction pool.
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
+	if volumes != nil {
+		mp.cfg.Velocity.record(volumes, time.Now())
+	}
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
@@ -1219,6 +1423,43 @@ func (mp *TxPool) LastUpdated() time.Time {
 	return time.Unix(atomic.LoadInt64(&mp.lastUpdated), 0)
 }
 
+// MinFeeFilter returns the minimum fee rate in atoms/kB a transaction relayed
+// by a peer must pay to be accepted into the memory pool.  It is zero when
+// free transactions are accepted subject to the rate limiter, since those may
+// pay any fee.  The rate is suitable for feefilter messages, which ask peers
+// not to announce transactions paying less.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeFilter() int64 {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	if mp.cfg.Policy.FreeTxRelayLimit > 0 {
+		return 0
+	}
+	return int64(mp.cfg.Policy.MinRelayTxFee)
+}
+
+// Policy returns the policy of the memory pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Policy() Policy {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+	return mp.cfg.Policy
+}
+
+// SetPolicy replaces the policy of the memory pool, such as when the
+// configuration is reloaded.  The transactions already in the pool are kept
+// even when they would not be accepted under the new policy.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetPolicy(policy Policy) {
+	mp.mtx.Lock()
+	mp.cfg.Policy = policy
+	mp.mtx.Unlock()
+}
+
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
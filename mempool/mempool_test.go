@@ -6,6 +6,7 @@
 package mempool
 
 import (
+	"bytes"
 	"encoding/hex"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/btcec"
@@ -100,6 +101,12 @@ func (s *fakeChain) KeyIDs() btcec.KeyIdMap {
 	return map[btcec.KeyID]*btcec.PublicKey{keyId1: pubKey1, keyId2: pubKey2}
 }
 
+// FrozenKeyIDs returns the frozen keyIDs set on the fake chain instance, of
+// which there are none.
+func (s *fakeChain) FrozenKeyIDs() btcec.KeyIdMap {
+	return make(btcec.KeyIdMap)
+}
+
 // BestHeight returns the current height associated with the fake chain
 // instance.
 func (s *fakeChain) BestHeight() uint32 {
@@ -381,6 +388,7 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 			LastKeyID:        chain.LastKeyID,
 			TotalSupply:      chain.TotalSupply,
 			GetKeyIDs:        chain.KeyIDs,
+			GetFrozenKeyIDs:  chain.FrozenKeyIDs,
 			GetAdminKeySets:  chain.AdminKeySets,
 			BestHeight:       chain.BestHeight,
 			MedianTimePast:   chain.MedianTimePast,
@@ -862,3 +870,106 @@ func TestMultiInputOrphanDoubleSpend(t *testing.T) {
 	// was not moved to the transaction pool.
 	testPoolMembership(tc, doubleSpendTx, false, false)
 }
+
+// TestMinFeeFilter ensures the fee rate advertised to peers is only non-zero
+// when free transactions are not relayed.
+func TestMinFeeFilter(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	pool := harness.txPool
+	if got := pool.MinFeeFilter(); got != 0 {
+		t.Fatalf("MinFeeFilter: got %d with free relay, want 0", got)
+	}
+
+	pool.cfg.Policy.FreeTxRelayLimit = 0
+	want := int64(pool.cfg.Policy.MinRelayTxFee)
+	if got := pool.MinFeeFilter(); got != want {
+		t.Fatalf("MinFeeFilter: got %d, want %d", got, want)
+	}
+}
+
+// TestSaveLoad ensures the transactions of the pool are restored by Load in a
+// new pool along with the time they were added, and that malformed files are
+// rejected.
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+
+	// Make the children look older than their parents to ensure parents
+	// are still saved first.
+	added := time.Unix(time.Now().Unix()-3600, 0)
+	for i, tx := range chainedTxns {
+		desc := harness.txPool.pool[*tx.Hash()]
+		desc.Added = added.Add(-time.Duration(i) * time.Minute)
+	}
+
+	var buf bytes.Buffer
+	saved, err := harness.txPool.Save(&buf)
+	if err != nil {
+		t.Fatalf("Save: unexpected error: %v", err)
+	}
+	if saved != len(chainedTxns) {
+		t.Fatalf("Save: wrote %d transactions, want %d", saved,
+			len(chainedTxns))
+	}
+	data := buf.Bytes()
+
+	pool := New(&harness.txPool.cfg)
+	loaded, err := pool.Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if loaded != len(chainedTxns) {
+		t.Fatalf("Load: accepted %d transactions, want %d", loaded,
+			len(chainedTxns))
+	}
+	for i, tx := range chainedTxns {
+		desc, ok := pool.pool[*tx.Hash()]
+		if !ok {
+			t.Fatalf("Load: transaction %d is not in the pool", i)
+		}
+		want := added.Add(-time.Duration(i) * time.Minute)
+		if !desc.Added.Equal(want) {
+			t.Errorf("Load: transaction %d added at %v, want %v", i,
+				desc.Added, want)
+		}
+	}
+
+	// Transactions already in the pool are dropped.
+	loaded, err = pool.Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if loaded != 0 {
+		t.Fatalf("Load: accepted %d duplicate transactions", loaded)
+	}
+
+	// Unknown versions and truncated files are rejected.
+	badVersion := append([]byte{2, 0, 0, 0}, data[4:]...)
+	if _, err := New(&harness.txPool.cfg).Load(bytes.NewReader(
+		badVersion)); err == nil {
+		t.Errorf("Load: no error for an unknown version")
+	}
+	if _, err := New(&harness.txPool.cfg).Load(bytes.NewReader(
+		data[:len(data)-1])); err == nil {
+		t.Errorf("Load: no error for a truncated file")
+	}
+}
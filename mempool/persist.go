@@ -0,0 +1,176 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// persistVersion is the version of the format the pool is saved in.
+	persistVersion = 1
+
+	// maxPersistedTxs is the maximum number of transactions read back by
+	// Load, which bounds the memory used by a damaged file.
+	maxPersistedTxs = 1000000
+)
+
+// persistedTx is a transaction of the pool along with the time it was added.
+type persistedTx struct {
+	tx    *provautil.Tx
+	added time.Time
+}
+
+// sortedForSave returns the transactions of the pool in the order they are
+// saved in, which is the order they were added in, with every transaction
+// after the transactions of the pool it spends so they can be accepted again
+// in that order.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) sortedForSave() []*TxDesc {
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].Added.Before(descs[j].Added)
+	})
+
+	sorted := make([]*TxDesc, 0, len(descs))
+	visited := make(map[chainhash.Hash]struct{}, len(descs))
+	var visit func(desc *TxDesc)
+	visit = func(desc *TxDesc) {
+		if _, ok := visited[*desc.Tx.Hash()]; ok {
+			return
+		}
+		visited[*desc.Tx.Hash()] = struct{}{}
+		for _, txIn := range desc.Tx.MsgTx().TxIn {
+			parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]
+			if ok {
+				visit(parent)
+			}
+		}
+		sorted = append(sorted, desc)
+	}
+	for _, desc := range descs {
+		visit(desc)
+	}
+	return sorted
+}
+
+// Save writes the transactions of the main pool to the passed writer along
+// with the time they were added, so they can be restored with Load after a
+// restart.  The orphan pool is not saved.  It returns the number of
+// transactions written.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Save(w io.Writer) (int, error) {
+	mp.mtx.RLock()
+	descs := mp.sortedForSave()
+	mp.mtx.RUnlock()
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:4], persistVersion)
+	if _, err := w.Write(buf[:4]); err != nil {
+		return 0, err
+	}
+	err := wire.WriteVarInt(w, 0, uint64(len(descs)))
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range descs {
+		binary.LittleEndian.PutUint64(buf[:], uint64(desc.Added.Unix()))
+		if _, err := w.Write(buf[:]); err != nil {
+			return 0, err
+		}
+		if err := desc.Tx.MsgTx().Serialize(w); err != nil {
+			return 0, err
+		}
+	}
+	return len(descs), nil
+}
+
+// readPersistedTxs reads the transactions written by Save from the passed
+// reader.
+func readPersistedTxs(r io.Reader) ([]persistedTx, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:4]); err != nil {
+		return nil, err
+	}
+	version := binary.LittleEndian.Uint32(buf[:4])
+	if version != persistVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxPersistedTxs {
+		return nil, fmt.Errorf("too many transactions [count %d, max "+
+			"%d]", count, maxPersistedTxs)
+	}
+
+	txs := make([]persistedTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		added := int64(binary.LittleEndian.Uint64(buf[:]))
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(r); err != nil {
+			return nil, err
+		}
+		txs = append(txs, persistedTx{
+			tx:    provautil.NewTx(&msgTx),
+			added: time.Unix(added, 0),
+		})
+	}
+	return txs, nil
+}
+
+// Load reads the transactions written by Save from the passed reader and
+// accepts them to the pool again, keeping the time they were first added.  The
+// transactions which are no longer valid, such as those mined or double spent
+// while the node was down, are dropped.  The transactions are not announced.
+// It returns the number of transactions accepted.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader) (int, error) {
+	txs, err := readPersistedTxs(r)
+	if err != nil {
+		return 0, err
+	}
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	var accepted int
+	for _, ptx := range txs {
+		missingParents, txD, err := mp.maybeAcceptTransaction(ptx.tx,
+			true, false, true)
+		if err != nil {
+			log.Debugf("Dropping saved transaction %v: %v",
+				ptx.tx.Hash(), err)
+			continue
+		}
+		if len(missingParents) > 0 {
+			log.Debugf("Dropping saved transaction %v: spends "+
+				"unknown transaction %v", ptx.tx.Hash(),
+				missingParents[0])
+			continue
+		}
+		txD.Added = ptx.added
+		accepted++
+	}
+	return accepted, nil
+}
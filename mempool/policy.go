@@ -104,6 +104,8 @@ func checkInputsStandard(tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint) e
 		switch txscript.GetScriptClass(originPkScript) {
 		case txscript.ProvaTy:
 			fallthrough
+		case txscript.ProvaTimeLockTy:
+			fallthrough
 		case txscript.GeneralProvaTy:
 			break
 		case txscript.ProvaAdminTy:
@@ -168,6 +170,8 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 	switch scriptClass {
 	case txscript.ProvaTy:
 		fallthrough
+	case txscript.ProvaTimeLockTy:
+		fallthrough
 	case txscript.GeneralProvaTy:
 		break
 	case txscript.ProvaAdminTy:
@@ -404,7 +408,20 @@ func checkTransactionStandard(tx *provautil.Tx, height uint32,
 			}
 		}
 
-		if threadId == provautil.IssueThread {
+		if txscript.IsKeyIDFreezeTx(tx) {
+			// Freeze tx may not have any other inputs
+			if len(msgTx.TxIn) > 1 {
+				str := fmt.Sprintf("admin freeze transaction with more than 1 input.")
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			for _, adminOpOut := range adminOutputs {
+				if !txscript.IsKeyIDFreezeOp(adminOpOut) {
+					str := fmt.Sprintf("admin freeze transaction with " +
+						"invalid freeze operation found.")
+					return txRuleError(wire.RejectInvalid, str)
+				}
+			}
+		} else if threadId == provautil.IssueThread {
 			// TODO(prova): take care of issue thread
 			// If issuance/destruction tx, any non-nulldata outputs must be valid Prova scripts
 		}
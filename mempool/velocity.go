@@ -0,0 +1,232 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// VelocityLimit caps the volume each keyID may spend within a sliding window
+// of time.
+type VelocityLimit struct {
+	// Window is the length of the sliding window.
+	Window time.Duration
+
+	// MaxAmount is the maximum volume a keyID may spend within the window.
+	MaxAmount provautil.Amount
+}
+
+// VelocityStatus describes the volume a keyID spent within the window of a
+// velocity limit.
+type VelocityStatus struct {
+	VelocityLimit
+
+	// Spent is the volume the keyID spent within the window.
+	Spent provautil.Amount
+}
+
+// velocitySpend is a volume spent by a keyID at a point in time.
+type velocitySpend struct {
+	time   time.Time
+	amount provautil.Amount
+}
+
+// VelocityTracker tracks the volume each keyID spends in the transactions
+// accepted to the memory pool, and refuses the transactions which would make
+// a keyID exceed the velocity limits configured by the operator.  Since only
+// the transactions of the memory pool are relayed and mined, the limits apply
+// to both.  They are a policy of the node rather than a consensus rule, so
+// blocks mined by other nodes are not affected.
+//
+// The volume a transaction spends for a keyID is the amount of the outputs
+// co-signed by the keyID it spends, net of the amount it pays back to outputs
+// co-signed by the keyID, such as change.  Admin thread transactions are not
+// limited.
+type VelocityTracker struct {
+	mtx       sync.Mutex
+	limits    []VelocityLimit
+	spends    map[btcec.KeyID][]velocitySpend
+	overrides map[btcec.KeyID]time.Time
+}
+
+// NewVelocityTracker returns a tracker enforcing the passed limits.
+func NewVelocityTracker(limits []VelocityLimit) *VelocityTracker {
+	limits = append([]VelocityLimit(nil), limits...)
+	sort.Slice(limits, func(i, j int) bool {
+		return limits[i].Window < limits[j].Window
+	})
+	return &VelocityTracker{
+		limits:    limits,
+		spends:    make(map[btcec.KeyID][]velocitySpend),
+		overrides: make(map[btcec.KeyID]time.Time),
+	}
+}
+
+// Limits returns the velocity limits enforced by the tracker, shortest window
+// first.
+func (vt *VelocityTracker) Limits() []VelocityLimit {
+	return append([]VelocityLimit(nil), vt.limits...)
+}
+
+// keyIDVolumes returns the volume the passed transaction spends for each keyID
+// co-signing the outputs it spends, which must be in the passed view.
+func keyIDVolumes(tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint) map[btcec.KeyID]provautil.Amount {
+	// scriptKeyIDs returns the keyIDs of the passed script, if any.
+	scriptKeyIDs := func(pkScript []byte) []btcec.KeyID {
+		pops, err := txscript.ParseScript(pkScript)
+		if err != nil {
+			return nil
+		}
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			return nil
+		}
+		return keyIDs
+	}
+
+	volumes := make(map[btcec.KeyID]provautil.Amount)
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			continue
+		}
+		amount := provautil.Amount(entry.AmountByIndex(prevOut.Index))
+		for _, keyID := range scriptKeyIDs(entry.PkScriptByIndex(prevOut.Index)) {
+			volumes[keyID] += amount
+		}
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+	for _, txOut := range tx.MsgTx().TxOut {
+		for _, keyID := range scriptKeyIDs(txOut.PkScript) {
+			if _, ok := volumes[keyID]; ok {
+				volumes[keyID] -= provautil.Amount(txOut.Value)
+			}
+		}
+	}
+	for keyID, amount := range volumes {
+		if amount <= 0 {
+			delete(volumes, keyID)
+		}
+	}
+	return volumes
+}
+
+// spent returns the volume the passed keyID spent within the passed window
+// ending at the passed time.
+//
+// This function MUST be called with the tracker lock held.
+func (vt *VelocityTracker) spent(keyID btcec.KeyID, window time.Duration, now time.Time) provautil.Amount {
+	var total provautil.Amount
+	start := now.Add(-window)
+	for _, spend := range vt.spends[keyID] {
+		if spend.time.After(start) {
+			total += spend.amount
+		}
+	}
+	return total
+}
+
+// check returns an error when spending the passed volumes at the passed time
+// would make a keyID which is not overridden exceed a velocity limit.
+func (vt *VelocityTracker) check(volumes map[btcec.KeyID]provautil.Amount, now time.Time) error {
+	vt.mtx.Lock()
+	defer vt.mtx.Unlock()
+
+	for keyID, amount := range volumes {
+		if now.Before(vt.overrides[keyID]) {
+			continue
+		}
+		for _, limit := range vt.limits {
+			spent := vt.spent(keyID, limit.Window, now)
+			if spent+amount > limit.MaxAmount {
+				return fmt.Errorf("spending %v would make keyID %v "+
+					"exceed its velocity limit of %v per %v, "+
+					"%v of which is already spent", amount,
+					keyID, limit.MaxAmount, limit.Window,
+					spent)
+			}
+		}
+	}
+	return nil
+}
+
+// record records the passed volumes as spent at the passed time, and forgets
+// the volumes spent before the longest window.
+func (vt *VelocityTracker) record(volumes map[btcec.KeyID]provautil.Amount, now time.Time) {
+	vt.mtx.Lock()
+	defer vt.mtx.Unlock()
+
+	var maxWindow time.Duration
+	if len(vt.limits) > 0 {
+		maxWindow = vt.limits[len(vt.limits)-1].Window
+	}
+	start := now.Add(-maxWindow)
+	for keyID, amount := range volumes {
+		spends := vt.spends[keyID]
+		for len(spends) > 0 && !spends[0].time.After(start) {
+			spends = spends[1:]
+		}
+		vt.spends[keyID] = append(spends, velocitySpend{
+			time:   now,
+			amount: amount,
+		})
+	}
+	for keyID, until := range vt.overrides {
+		if !now.Before(until) {
+			delete(vt.overrides, keyID)
+		}
+	}
+}
+
+// SetOverride lifts the velocity limits of the passed keyID until the passed
+// time, or restores them when the time is not in the future.  The volumes the
+// keyID spends in the meantime still count towards the limits once restored.
+//
+// This function is safe for concurrent access.
+func (vt *VelocityTracker) SetOverride(keyID btcec.KeyID, until time.Time) {
+	vt.mtx.Lock()
+	defer vt.mtx.Unlock()
+
+	if !time.Now().Before(until) {
+		delete(vt.overrides, keyID)
+		return
+	}
+	vt.overrides[keyID] = until
+}
+
+// Status returns the volume the passed keyID spent within the window of each
+// velocity limit, along with the time its limits are overridden until, which
+// is zero when they are not overridden.
+//
+// This function is safe for concurrent access.
+func (vt *VelocityTracker) Status(keyID btcec.KeyID) ([]VelocityStatus, time.Time) {
+	vt.mtx.Lock()
+	defer vt.mtx.Unlock()
+
+	now := time.Now()
+	status := make([]VelocityStatus, 0, len(vt.limits))
+	for _, limit := range vt.limits {
+		status = append(status, VelocityStatus{
+			VelocityLimit: limit,
+			Spent:         vt.spent(keyID, limit.Window, now),
+		})
+	}
+	until := vt.overrides[keyID]
+	if !now.Before(until) {
+		until = time.Time{}
+	}
+	return status, until
+}
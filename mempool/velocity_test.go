@@ -0,0 +1,106 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestVelocityTracker ensures the volume spent by each keyID is net of the
+// change paid back to it, and is refused once it exceeds a velocity limit
+// unless the limits of the keyID are overridden.
+func TestVelocityTracker(t *testing.T) {
+	pkScript := func(keyIDs ...btcec.KeyID) []byte {
+		addr, err := provautil.NewAddressProva(make([]byte, 20), keyIDs,
+			&chaincfg.RegressionNetParams)
+		if err != nil {
+			t.Fatalf("NewAddressProva: unexpected error: %v", err)
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		return script
+	}
+	keyID1, keyID2 := btcec.KeyID(1), btcec.KeyID(2)
+
+	// Spend 1000 atoms co-signed by both keyIDs, paying 300 atoms of change
+	// back to the first one.
+	prevTx := provautil.NewTx(&wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{wire.NewTxIn(wire.NewOutPoint(
+			&chainhash.Hash{1}, 0), nil)},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000, pkScript(keyID1, keyID2))},
+	})
+	utxoView := blockchain.NewUtxoViewpoint()
+	utxoView.AddTxOuts(prevTx, 1)
+	tx := provautil.NewTx(&wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{wire.NewTxIn(wire.NewOutPoint(prevTx.Hash(),
+			0), nil)},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(300, pkScript(keyID1, 3)),
+			wire.NewTxOut(700, pkScript(4, 5)),
+		},
+	})
+	volumes := keyIDVolumes(tx, utxoView)
+	if len(volumes) != 2 || volumes[keyID1] != 700 || volumes[keyID2] != 1000 {
+		t.Fatalf("keyIDVolumes: got %v, want 700 for keyID 1 and 1000 "+
+			"for keyID 2", volumes)
+	}
+
+	vt := NewVelocityTracker([]VelocityLimit{
+		{Window: 24 * time.Hour, MaxAmount: 2000},
+		{Window: time.Hour, MaxAmount: 1500},
+	})
+	if limits := vt.Limits(); limits[0].Window != time.Hour {
+		t.Errorf("Limits: got %v, want the shortest window first", limits)
+	}
+	now := time.Now()
+	if err := vt.check(volumes, now); err != nil {
+		t.Fatalf("check: unexpected error: %v", err)
+	}
+	vt.record(volumes, now)
+	if err := vt.check(volumes, now.Add(10*time.Minute)); err == nil {
+		t.Errorf("check: no error exceeding the hourly limit")
+	}
+	if err := vt.check(volumes, now.Add(2*time.Hour)); err != nil {
+		t.Errorf("check: unexpected error after the hourly window: %v",
+			err)
+	}
+	vt.record(volumes, now.Add(2*time.Hour))
+	if err := vt.check(volumes, now.Add(4*time.Hour)); err == nil {
+		t.Errorf("check: no error exceeding the daily limit")
+	}
+
+	status, until := vt.Status(keyID2)
+	if len(status) != 2 || status[1].Spent != 2000 || !until.IsZero() {
+		t.Errorf("Status: got %v and override %v, want 2000 spent "+
+			"within the day and no override", status, until)
+	}
+
+	// Overriding the limits of the second keyID lets it exceed them.
+	spend2 := map[btcec.KeyID]provautil.Amount{keyID2: 1000}
+	vt.SetOverride(keyID2, time.Now().Add(24*time.Hour))
+	if err := vt.check(spend2, now.Add(4*time.Hour)); err != nil {
+		t.Errorf("check: unexpected error with an override: %v", err)
+	}
+	if _, until := vt.Status(keyID2); until.IsZero() {
+		t.Errorf("Status: override not reported")
+	}
+	vt.SetOverride(keyID2, time.Time{})
+	if err := vt.check(spend2, now.Add(4*time.Hour)); err == nil {
+		t.Errorf("check: no error once the override is lifted")
+	}
+}
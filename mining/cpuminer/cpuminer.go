@@ -22,6 +22,7 @@ import (
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/signguard"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -50,6 +51,16 @@ var (
 	// and is based on the number of processor cores.  This helps ensure the
 	// system stays reasonably responsive under heavy load.
 	defaultNumWorkers = uint32(runtime.NumCPU())
+
+	// errMinerStopped is returned by GenerateBlocks when the CPU miner is
+	// stopped before all blocks are generated.
+	errMinerStopped = errors.New("CPU miner stopped before all blocks " +
+		"were generated")
+
+	// errSigningNotAllowed is returned by GenerateBlocks when signing the
+	// next block is not allowed.
+	errSigningNotAllowed = errors.New("signing blocks is not allowed " +
+		"while another node holding the validate keys may sign them")
 )
 
 // Config is a descriptor containing the cpu miner configuration.
@@ -87,13 +98,25 @@ type Config struct {
 	// up orphaned anyways.
 	IsCurrent func() bool
 
-	// IsValidateKeyRateLimited defines the function to use to determine
-	// whether or not a validate key is rate limited.
-	IsValidateKeyRateLimited func(validatePubKey wire.BlockValidatingPubKey) (bool, error)
+	// BlocksUntilEligible defines the function to use to determine the
+	// number of blocks which have to be signed by other validate keys
+	// before each of the passed validate keys may sign the next block
+	// without violating the rate limits.
+	BlocksUntilEligible func(pubKeys []wire.BlockValidatingPubKey) ([]int, error)
 
 	// AdminKeySets defines the function to use to retrieve the
 	// admin key sets
 	AdminKeySets func() map[btcec.KeySetType]btcec.PublicKeySet
+
+	// BlockSigned defines the function to call with every solved block,
+	// which is signed by one of the validate keys of the miner, before it
+	// is processed.  It may be nil.
+	BlockSigned func(*provautil.Block)
+
+	// CanSign defines the function to use to determine whether the miner
+	// may sign a block at the passed height, such as when this node stands
+	// by for another node holding the same validate keys.  It may be nil.
+	CanSign func(height uint32) bool
 }
 
 // CPUMiner provides facilities for solving blocks (mining) using the CPU in
@@ -107,7 +130,7 @@ type CPUMiner struct {
 	g                 *mining.BlkTmplGenerator
 	cfg               Config
 	numWorkers        uint32
-	validateKeys      []*btcec.PrivateKey
+	validateKeys      []btcec.Signer
 	started           bool
 	discreteMining    bool
 	submitBlockLock   sync.Mutex
@@ -117,6 +140,7 @@ type CPUMiner struct {
 	queryHashesPerSec chan float64
 	updateHashes      chan uint64
 	speedMonitorQuit  chan struct{}
+	discreteQuit      chan struct{}
 	quit              chan struct{}
 }
 
@@ -181,6 +205,15 @@ func (m *CPUMiner) submitBlock(block *provautil.Block) bool {
 			"block %s is stale", msgBlock.Header.PrevBlock)
 		return false
 	}
+	// Refuse to release a block competing with one signed before by the
+	// same validate key.
+	if err := m.g.RecordSigned(block); err != nil {
+		log.Errorf("Block submitted via CPU miner not released: %v", err)
+		return false
+	}
+	if m.cfg.BlockSigned != nil {
+		m.cfg.BlockSigned(block)
+	}
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
@@ -209,6 +242,17 @@ func (m *CPUMiner) submitBlock(block *provautil.Block) bool {
 	return true
 }
 
+// waitOrQuit waits for the passed duration and returns false when the passed
+// quit channel is closed first.
+func waitOrQuit(d time.Duration, quit chan struct{}) bool {
+	select {
+	case <-quit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
 // solveBlock attempts to find some combination of a nonce and current
 // timestamp which makes the passed block hash to a value less than the
 // target difficulty.  The timestamp is updated periodically and the passed
@@ -219,7 +263,7 @@ func (m *CPUMiner) submitBlock(block *provautil.Block) bool {
 // stale block such as a new block showing up or periodically when there are
 // new transactions and enough time has elapsed without finding a solution.
 func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight uint32,
-	ticker *time.Ticker, validateKey *btcec.PrivateKey,
+	ticker *time.Ticker, validateKey btcec.Signer,
 	quit chan struct{}) bool {
 
 	// Create some convenience variables.
@@ -260,7 +304,13 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight uint32,
 				return false
 			}
 
-			m.g.UpdateBlockTime(msgBlock, validateKey)
+			// Give up on the block when it can't be re-signed,
+			// since its signature no longer covers its timestamp.
+			err := m.g.UpdateBlockTime(msgBlock, validateKey)
+			if err != nil {
+				log.Errorf("Failed to re-sign block: %v", err)
+				return false
+			}
 
 		default:
 			// Non-blocking select to fall through
@@ -311,7 +361,9 @@ out:
 		// since there is no way to relay a found block or receive
 		// transactions to work on when there are no connected peers.
 		if m.cfg.ConnectedCount() == 0 {
-			time.Sleep(time.Second)
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
 			continue
 		}
 
@@ -324,19 +376,35 @@ out:
 		curHeight := m.g.BestSnapshot().Height
 		if curHeight != 0 && !m.cfg.IsCurrent() {
 			m.submitBlockLock.Unlock()
-			time.Sleep(time.Second)
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
+			continue
+		}
+
+		// Wait while signing blocks is not allowed.
+		if !m.canSign(curHeight + 1) {
+			m.submitBlockLock.Unlock()
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
 			continue
 		}
 
 		// Choose a payment address at random.
 		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		miningAddrs := m.MiningAddrs()
+		payToAddr := miningAddrs[rand.Intn(len(miningAddrs))]
 
 		// Confirm that validate keys are present.
 		if len(m.validateKeys) == 0 {
+			m.submitBlockLock.Unlock()
 			errStr := fmt.Sprintf("Missing validate keys, set via"+
 				" setvalidatekeys or env var %s", validateKeysEnvironmentKey)
 			log.Errorf(errStr)
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
 			continue
 		}
 
@@ -348,40 +416,37 @@ out:
 				invalidValidateKey.SerializeCompressed())
 			log.Errorf(str)
 			m.submitBlockLock.Unlock()
-			time.Sleep(2 * time.Second)
+			if !waitOrQuit(2*time.Second, quit) {
+				break out
+			}
 			continue
 		}
 
-		// Pick a validate key to use, absent rate-limited keys.
-		var nonRateLimitedValidateKeys []*btcec.PrivateKey
-		var validateKey *btcec.PrivateKey
-		var validateKeyErr error
-		for _, privKey := range m.validateKeys {
-			var validatePubKey wire.BlockValidatingPubKey
-			copy(validatePubKey[:wire.BlockValidatingPubKeySize], privKey.PubKey().SerializeCompressed()[:wire.BlockValidatingPubKeySize])
-			isRateLimited, validateKeyErr := m.cfg.IsValidateKeyRateLimited(validatePubKey)
-			if validateKeyErr != nil || isRateLimited {
-				continue
-			}
-			nonRateLimitedValidateKeys = append(nonRateLimitedValidateKeys, privKey)
-		}
-		if validateKeyErr != nil {
+		// Pick a validate key at random among those which may sign the
+		// next block without violating the rate limits.  When there are
+		// none, other validators have to sign blocks first, so there is
+		// no point in hashing until a new block shows up.
+		eligibleKeys, blocks, err := m.eligibleValidateKeys(m.validateKeys)
+		if err != nil {
 			m.submitBlockLock.Unlock()
-			errStr := fmt.Sprintf("Failed checking validate key %v", validateKeyErr)
-			log.Errorf(errStr)
-			time.Sleep(time.Second)
+			log.Errorf("Failed checking validate keys: %v", err)
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
 			continue
 		}
-		if keysCount := len(nonRateLimitedValidateKeys); keysCount > 0 {
-			// Choose a signing key at random.
-			validateKey = nonRateLimitedValidateKeys[rand.Intn(keysCount)]
-		} else {
+		if len(eligibleKeys) == 0 {
+			best := m.g.BestSnapshot()
 			m.submitBlockLock.Unlock()
-			errStr := fmt.Sprintf("Block generation rate limited.")
-			log.Errorf(errStr)
-			time.Sleep(5 * time.Second)
+			log.Infof("All validate keys are rate limited -- the "+
+				"first is eligible to sign the block at height %d",
+				best.Height+1+uint32(blocks))
+			if !m.waitForBlock(best.Hash, quit) {
+				break out
+			}
 			continue
 		}
+		validateKey := eligibleKeys[rand.Intn(len(eligibleKeys))]
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
@@ -392,7 +457,9 @@ out:
 			errStr := fmt.Sprintf("Failed to create new block "+
 				"template: %v", err)
 			log.Errorf(errStr)
-			time.Sleep(time.Second)
+			if !waitOrQuit(time.Second, quit) {
+				break out
+			}
 			continue
 		}
 
@@ -410,6 +477,52 @@ out:
 	log.Tracef("Generate blocks worker done")
 }
 
+// eligibleValidateKeys returns those of the passed validate keys which may sign
+// the next block without violating the rate limits.  When there are none, it
+// also returns the number of blocks other validators have to sign before the
+// first of the keys may sign one.
+func (m *CPUMiner) eligibleValidateKeys(validateKeys []btcec.Signer) ([]btcec.Signer, int, error) {
+	pubKeys := make([]wire.BlockValidatingPubKey, len(validateKeys))
+	for i, key := range validateKeys {
+		copy(pubKeys[i][:], key.PubKey().SerializeCompressed())
+	}
+	blocks, err := m.cfg.BlocksUntilEligible(pubKeys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var eligible []btcec.Signer
+	minBlocks := -1
+	for i, n := range blocks {
+		if n == 0 {
+			eligible = append(eligible, validateKeys[i])
+			continue
+		}
+		if minBlocks < 0 || n < minBlocks {
+			minBlocks = n
+		}
+	}
+	return eligible, minBlocks, nil
+}
+
+// waitForBlock waits until the best block is no longer the block with the
+// passed hash, and returns false when the quit channel is closed first.
+func (m *CPUMiner) waitForBlock(hash *chainhash.Hash, quit chan struct{}) bool {
+	for {
+		if !waitOrQuit(time.Second, quit) {
+			return false
+		}
+		if !m.g.BestSnapshot().Hash.IsEqual(hash) {
+			return true
+		}
+	}
+}
+
+// canSign returns whether the miner may sign a block at the passed height.
+func (m *CPUMiner) canSign(height uint32) bool {
+	return m.cfg.CanSign == nil || m.cfg.CanSign(height)
+}
+
 // detectInvalidValidateKey determines if there is an invalid validate key in
 // the miner's validate key set.  If there is an invalid key, it is returned.
 func (m *CPUMiner) detectInvalidValidateKey() *btcec.PublicKey {
@@ -493,7 +606,7 @@ func (m *CPUMiner) EstablishValidateKeys() {
 		return
 	}
 	validateKeys := strings.Split(validateKeyValue, ",")
-	validatePrivKeys := make([]*btcec.PrivateKey, len(validateKeys))
+	validatePrivKeys := make([]btcec.Signer, len(validateKeys))
 	for i, privKeyStr := range validateKeys {
 		privKeyBytes, err := hex.DecodeString(privKeyStr)
 		if err != nil {
@@ -546,9 +659,17 @@ func (m *CPUMiner) Stop() {
 	m.Lock()
 	defer m.Unlock()
 
-	// Nothing to do if the miner is not currently running or if running in
-	// discrete mode (using GenerateNBlocks).
-	if !m.started || m.discreteMining {
+	// Nothing to do if the miner is not currently running.  When running
+	// in discrete mode (using GenerateNBlocks), the generation is only
+	// signalled to stop since it finishes by itself.
+	if !m.started {
+		return
+	}
+	if m.discreteMining {
+		if m.discreteQuit != nil {
+			close(m.discreteQuit)
+			m.discreteQuit = nil
+		}
 		return
 	}
 
@@ -625,10 +746,10 @@ func (m *CPUMiner) NumWorkers() int32 {
 	return int32(m.numWorkers)
 }
 
-// SetValidateKeys updates the private keys used for signing.
+// SetValidateKeys updates the signers of the validate keys used for signing.
 //
 // This function is safe for concurrent access.
-func (m *CPUMiner) SetValidateKeys(validateKeys []*btcec.PrivateKey) {
+func (m *CPUMiner) SetValidateKeys(validateKeys []btcec.Signer) {
 	m.Lock()
 	defer m.Unlock()
 	m.validateKeys = validateKeys
@@ -637,18 +758,47 @@ func (m *CPUMiner) SetValidateKeys(validateKeys []*btcec.PrivateKey) {
 // ValidateKeys returns the validate keys set to sign blocks.
 //
 // This function is safe for concurrent access.
-func (m *CPUMiner) ValidateKeys() []*btcec.PrivateKey {
+func (m *CPUMiner) ValidateKeys() []btcec.Signer {
 	m.Lock()
 	defer m.Unlock()
 	return m.validateKeys
 }
 
+// SetMiningAddrs updates the payment addresses to use for the generated
+// blocks, such as when the configuration is reloaded.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) SetMiningAddrs(miningAddrs []provautil.Address) {
+	m.Lock()
+	defer m.Unlock()
+	m.cfg.MiningAddrs = miningAddrs
+}
+
+// MiningAddrs returns the payment addresses to use for the generated blocks.
+//
+// This function is safe for concurrent access.
+func (m *CPUMiner) MiningAddrs() []provautil.Address {
+	m.Lock()
+	defer m.Unlock()
+	return m.cfg.MiningAddrs
+}
+
 // GenerateNBlocks generates the requested number of blocks. It is self
 // contained in that it creates block templates and attempts to solve them while
 // detecting when it is performing stale work and reacting accordingly by
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.GenerateBlocks(n, nil, nil)
+}
+
+// GenerateBlocks generates the requested number of blocks like GenerateNBlocks,
+// paying them to the passed address and signing them with the passed validate
+// key.  A nil address or validate key is chosen at random for every block from
+// the configured mining addresses or validate keys respectively.
+func (m *CPUMiner) GenerateBlocks(n uint32, payToAddr provautil.Address,
+	validateKey btcec.Signer) ([]*chainhash.Hash, error) {
+
 	m.Lock()
 
 	// Respond with an error if server is already mining.
@@ -661,12 +811,28 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	m.started = true
 	m.discreteMining = true
 
+	// The blocks are generated until the quit channel is closed by Stop.
+	quit := make(chan struct{})
+	m.discreteQuit = quit
+
 	m.speedMonitorQuit = make(chan struct{})
 	m.wg.Add(1)
 	go m.speedMonitor()
 
 	m.Unlock()
 
+	// finish stops the speed monitor once the blocks are generated or the
+	// miner is stopped.
+	finish := func() {
+		m.Lock()
+		close(m.speedMonitorQuit)
+		m.wg.Wait()
+		m.started = false
+		m.discreteMining = false
+		m.discreteQuit = nil
+		m.Unlock()
+	}
+
 	log.Tracef("Generating %d blocks", n)
 
 	i := uint32(0)
@@ -680,9 +846,15 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	for {
 		// Read updateNumWorkers in case someone tries a `setgenerate` while
 		// we're generating. We can ignore it as the `generate` RPC call only
-		// uses 1 worker.
+		// uses 1 worker.  Return the blocks generated so far when the
+		// miner is stopped.
 		select {
 		case <-m.updateNumWorkers:
+		case <-quit:
+			finish()
+			log.Infof("CPU miner stopped after generating %d of %d "+
+				"blocks", i, n)
+			return blockHashes[:i], errMinerStopped
 		default:
 		}
 
@@ -691,24 +863,53 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// template on a block that is in the process of becoming stale.
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
+		if !m.canSign(curHeight + 1) {
+			m.submitBlockLock.Unlock()
+			finish()
+			return blockHashes[:i], errSigningNotAllowed
+		}
 
-		// Choose a payment address at random.
+		// Choose a payment address and a validate key at random unless
+		// they were requested.
 		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
-
-		// Choose a validate key at random.
+		blockPayToAddr := payToAddr
+		if blockPayToAddr == nil {
+			miningAddrs := m.MiningAddrs()
+			blockPayToAddr = miningAddrs[rand.Intn(len(miningAddrs))]
+		}
 		validateKeys := m.ValidateKeys()
-		validateKey := validateKeys[rand.Intn(len(validateKeys))]
+		if validateKey != nil {
+			validateKeys = []btcec.Signer{validateKey}
+		}
+		eligibleKeys, blocks, err := m.eligibleValidateKeys(validateKeys)
+		if err == nil && len(eligibleKeys) == 0 {
+			err = fmt.Errorf("validate keys are rate limited until "+
+				"%d more blocks are signed by other validators",
+				blocks)
+		}
+		if err != nil {
+			m.submitBlockLock.Unlock()
+			finish()
+			return blockHashes[:i], err
+		}
+		blockValidateKey := eligibleKeys[rand.Intn(len(eligibleKeys))]
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr, validateKey)
+		template, err := m.g.NewBlockTemplate(blockPayToAddr, blockValidateKey)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
 				"template: %v", err)
 			log.Errorf(errStr)
+
+			// Retrying does not help while the signing guard
+			// refuses the height.
+			if _, ok := err.(*signguard.DoubleSignError); ok {
+				finish()
+				return blockHashes[:i], err
+			}
 			continue
 		}
 
@@ -716,19 +917,14 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, validateKey, nil) {
+		if m.solveBlock(template.Block, curHeight+1, ticker, blockValidateKey, quit) {
 			block := provautil.NewBlock(template.Block)
 			m.submitBlock(block)
 			blockHashes[i] = block.Hash()
 			i++
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
+				finish()
 				return blockHashes, nil
 			}
 		}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"container/heap"
 	"encoding/hex"
+	"sync"
 	"time"
 
 	"github.com/bitgo/prova/blockchain"
@@ -16,6 +17,8 @@ import (
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/screening"
+	"github.com/bitgo/prova/signguard"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
@@ -312,13 +315,11 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		PkScript: pkScript,
 	})
 
-	// Add block height as a locktime to make a unique txid.
-	// Since BIP30 transactions are required to have unique txids. This is
-	// normally covered with the block height in the coinbase scriptSig.
-	// Since scriptSigs have been eliminated from the txid, dummy locktime
-	// with the block height is created to add back uniqueness to the hash.
-	// There is no consensus rule that this must exist, it is just
-	// included as a convenient way to provide uniqueness.
+	// Commit to the block height in the lock time.  Since scriptSigs have
+	// been eliminated from the txid, the height can't be serialized in the
+	// coinbase script as done by BIP0034.  The consensus rules require the
+	// coinbase lock time to match the block height, which guarantees that
+	// every coinbase has a unique txid.  See blockchain.ExtractCoinbaseHeight.
 	tx.LockTime = nextBlockHeight
 
 	var w bytes.Buffer
@@ -395,6 +396,7 @@ func medianAdjustedTime(chainState *blockchain.BestState, timeSource blockchain.
 // See the NewBlockTemplate method for a detailed description of how the block
 // template is generated.
 type BlkTmplGenerator struct {
+	policyMtx   sync.Mutex
 	policy      *Policy
 	chainParams *chaincfg.Params
 	txSource    TxSource
@@ -402,6 +404,16 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+
+	// screening screens the transactions considered for templates when
+	// set.  It is set before templates are generated and never changed
+	// afterwards.
+	screening *screening.Hook
+
+	// signGuard refuses to sign blocks at the heights of blocks already
+	// signed by the same validate key when set.  It is set before
+	// templates are generated and never changed afterwards.
+	signGuard *signguard.Guard
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -426,6 +438,63 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 	}
 }
 
+// Policy returns the policy the block templates are generated with.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) Policy() Policy {
+	g.policyMtx.Lock()
+	defer g.policyMtx.Unlock()
+	return *g.policy
+}
+
+// SetPolicy replaces the policy the block templates are generated with, such
+// as when the configuration is reloaded.  It applies to the templates
+// generated afterwards.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) SetPolicy(policy Policy) {
+	g.policyMtx.Lock()
+	*g.policy = policy
+	g.policyMtx.Unlock()
+}
+
+// SetScreening sets the hook which screens the transactions considered for
+// the templates against the watchlists of the operator, so the transactions it
+// excludes are not mined.  It must be called before templates are generated.
+func (g *BlkTmplGenerator) SetScreening(hook *screening.Hook) {
+	g.screening = hook
+}
+
+// SetSignGuard sets the guard which refuses to sign templates at the heights
+// of blocks already released with the same validate key.  It must be called
+// before templates are generated.
+func (g *BlkTmplGenerator) SetSignGuard(guard *signguard.Guard) {
+	g.signGuard = guard
+}
+
+// signHeader signs the passed header with the passed validate key unless the
+// signing guard refuses it.
+func (g *BlkTmplGenerator) signHeader(header *wire.BlockHeader, validateKey btcec.Signer) error {
+	if g.signGuard != nil {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], validateKey.PubKey().SerializeCompressed())
+		if err := g.signGuard.Check(pubKey, header.Height); err != nil {
+			return err
+		}
+	}
+	return header.Sign(validateKey)
+}
+
+// RecordSigned records the release of the passed block, which was signed with
+// a validate key of the node, with the signing guard when it is set.  The block
+// must not be released when an error is returned.
+func (g *BlkTmplGenerator) RecordSigned(block *provautil.Block) error {
+	if g.signGuard == nil {
+		return nil
+	}
+	return g.signGuard.Record(&block.MsgBlock().Header)
+}
+
 // NewBlockTemplate returns a new block template that is ready to be solved
 // using the transactions from the passed transaction source pool and a coinbase
 // that either pays to the passed address if it is not nil, or a coinbase that
@@ -488,11 +557,12 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |  transactions (while block size   |   |
 //  |  <= policy.BlockMinSize)          |   |
 //   -----------------------------------  --
-func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, validateKey *btcec.PrivateKey) (*BlockTemplate, error) {
+func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, validateKey btcec.Signer) (*BlockTemplate, error) {
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	prevHash := best.Hash
 	nextBlockHeight := best.Height + 1
+	policy := g.Policy()
 
 	// Create a standard coinbase transaction paying to the provided
 	// address.  NOTE: The coinbase value will be updated to include the
@@ -520,7 +590,7 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, vali
 	// choose the initial sort order for the priority queue based on whether
 	// or not there is an area allocated for high-priority transactions.
 	sourceTxns := g.txSource.MiningDescs()
-	sortedByFee := g.policy.BlockPrioritySize == 0
+	sortedByFee := policy.BlockPrioritySize == 0
 	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
 
 	// Create a slice to hold the transactions to be included in the
@@ -533,7 +603,9 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, vali
 	keyView := blockchain.NewKeyViewpoint()
 	keyView.SetLastKeyID(g.chain.LastKeyID())
 	keyView.SetKeys(g.chain.AdminKeySets())
+	keyView.SetAdminThresholds(g.chainParams.AdminThresholds)
 	keyView.SetKeyIDs(g.chain.KeyIDs())
+	keyView.SetFrozenKeyIDs(g.chain.FrozenKeyIDs())
 
 	// dependers is used to track transactions which depend on another
 	// transaction in the source pool.  This, in conjunction with the
@@ -570,6 +642,25 @@ mempoolLoop:
 			log.Tracef("Skipping non-finalized tx %s", tx.Hash())
 			continue
 		}
+		if blockchain.CheckTransactionTimeLocks(tx, nextBlockHeight,
+			g.chainParams) != nil {
+			log.Tracef("Skipping tx %s with premature timelocked "+
+				"outputs", tx.Hash())
+			continue
+		}
+		if blockchain.CheckTransactionFreezes(tx, nextBlockHeight,
+			g.chainParams) != nil {
+			log.Tracef("Skipping tx %s with premature keyID "+
+				"freezes", tx.Hash())
+			continue
+		}
+		if !g.chainParams.RelayGeneralProvaTxs &&
+			HasGeneralProvaOutputs(tx) {
+
+			log.Tracef("Skipping tx %s with generalized prova "+
+				"outputs", tx.Hash())
+			continue
+		}
 
 		// Fetch all of the utxos referenced by the this transaction.
 		// NOTE: This intentionally does not fetch inputs from the
@@ -654,6 +745,13 @@ mempoolLoop:
 	blockSigOps := numCoinbaseSigOps
 	totalFees := int64(0)
 
+	// Outputs to generalized m-of-n Prova scripts can only be spent once
+	// these scripts are active for the block.
+	scriptFlags := txscript.StandardVerifyFlags
+	if nextBlockHeight >= g.chainParams.GeneralProvaActivationHeight {
+		scriptFlags |= txscript.ScriptVerifyGeneralProva
+	}
+
 	// Choose which transactions make it into the block.
 	for priorityQueue.Len() > 0 {
 		// Grab the highest priority (or highest fee per kilobyte
@@ -668,7 +766,7 @@ mempoolLoop:
 		txSize := uint32(tx.MsgTx().SerializeSize())
 		blockPlusTxSize := blockSize + txSize
 		if blockPlusTxSize < blockSize ||
-			blockPlusTxSize >= g.policy.BlockMaxSize {
+			blockPlusTxSize >= policy.BlockMaxSize {
 
 			log.Tracef("Skipping tx %s because it would exceed "+
 				"the max block size", tx.Hash())
@@ -707,14 +805,14 @@ mempoolLoop:
 		// Skip free transactions once the block is larger than the
 		// minimum block size.
 		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxSize >= g.policy.BlockMinSize {
+			prioItem.feePerKB < int64(policy.TxMinFreeFee) &&
+			blockPlusTxSize >= policy.BlockMinSize {
 
 			log.Tracef("Skipping tx %s with feePerKB %d "+
 				"< TxMinFreeFee %d and block size %d >= "+
 				"minBlockSize %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxSize,
-				g.policy.BlockMinSize)
+				policy.TxMinFreeFee, blockPlusTxSize,
+				policy.BlockMinSize)
 			logSkippedDeps(tx, deps)
 			continue
 		}
@@ -722,13 +820,13 @@ mempoolLoop:
 		// Prioritize by fee per kilobyte once the block is larger than
 		// the priority size or there are no more high-priority
 		// transactions.
-		if !sortedByFee && (blockPlusTxSize >= g.policy.BlockPrioritySize ||
+		if !sortedByFee && (blockPlusTxSize >= policy.BlockPrioritySize ||
 			prioItem.priority <= MinHighPriority) {
 
 			log.Tracef("Switching to sort by fees per "+
 				"kilobyte blockSize %d >= BlockPrioritySize "+
 				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxSize, g.policy.BlockPrioritySize,
+				blockPlusTxSize, policy.BlockPrioritySize,
 				prioItem.priority, MinHighPriority)
 
 			sortedByFee = true
@@ -740,7 +838,7 @@ mempoolLoop:
 			// too low.  Otherwise this transaction will be the
 			// final one in the high-priority section, so just fall
 			// though to the code below so it is added now.
-			if blockPlusTxSize > g.policy.BlockPrioritySize ||
+			if blockPlusTxSize > policy.BlockPrioritySize ||
 				prioItem.priority < MinHighPriority {
 
 				heap.Push(priorityQueue, prioItem)
@@ -748,6 +846,20 @@ mempoolLoop:
 			}
 		}
 
+		// Skip transactions excluded by screening, which may have been
+		// accepted to the source pool before their subjects were
+		// listed.
+		if g.screening != nil {
+			result := g.screening.Check(screening.Template, tx,
+				blockUtxos)
+			if result.Decision == screening.Exclude {
+				log.Tracef("Skipping tx %s excluded by "+
+					"screening: %s", tx.Hash(), result.Reason)
+				logSkippedDeps(tx, deps)
+				continue
+			}
+		}
+
 		// Ensure the transaction inputs pass all of the necessary
 		// preconditions before allowing it to be added to the block.
 		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
@@ -759,8 +871,18 @@ mempoolLoop:
 			continue
 		}
 
+		err = blockchain.CheckTransactionFrozenInputs(tx,
+			nextBlockHeight, blockUtxos, keyView, g.chainParams)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"CheckTransactionFrozenInputs: %v", tx.Hash(), err)
+			logSkippedDeps(tx, deps)
+			continue
+		}
+
 		// CheckTransactionOutputs checks outputs for state violations.
-		err = blockchain.CheckTransactionOutputs(tx, keyView)
+		err = blockchain.CheckTransactionOutputs(tx, nextBlockHeight,
+			keyView, g.chainParams)
 		if err != nil {
 			log.Tracef("Skipping tx %s due to error in "+
 				"CheckTransactionOutputs: %v", tx.Hash(), err)
@@ -769,7 +891,7 @@ mempoolLoop:
 		}
 
 		err = blockchain.ValidateTransactionScripts(tx, blockUtxos, keyView,
-			txscript.StandardVerifyFlags, g.sigCache, g.hashCache)
+			scriptFlags, g.sigCache, g.hashCache)
 		if err != nil {
 			log.Tracef("Skipping tx %s due to error in "+
 				"ValidateTransactionScripts: %v", tx.Hash(), err)
@@ -853,8 +975,13 @@ mempoolLoop:
 		Size:       blockSize,
 	}
 
-	// Sign the block
-	msgBlock.Header.Sign(validateKey)
+	// Sign the block when a validate key is available.  Templates requested
+	// by external miners are signed by them instead.
+	if validateKey != nil {
+		if err := g.signHeader(&msgBlock.Header, validateKey); err != nil {
+			return nil, err
+		}
+	}
 
 	for _, tx := range blockTxns {
 		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
@@ -891,7 +1018,7 @@ mempoolLoop:
 // based on the new time for the test networks since their target difficulty can
 // change based upon time.
 func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock,
-	validateKey *btcec.PrivateKey) error {
+	validateKey btcec.Signer) error {
 
 	// The new timestamp is potentially adjusted to ensure it comes after
 	// the median time of the last several blocks per the chain consensus
@@ -900,7 +1027,9 @@ func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock,
 	msgBlock.Header.Timestamp = newTime
 
 	// Re-sign the block, since we updated the block time
-	msgBlock.Header.Sign(validateKey)
+	if validateKey != nil {
+		return g.signHeader(&msgBlock.Header, validateKey)
+	}
 
 	return nil
 }
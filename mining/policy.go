@@ -8,6 +8,7 @@ package mining
 import (
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -40,6 +41,19 @@ type Policy struct {
 	TxMinFreeFee provautil.Amount
 }
 
+// HasGeneralProvaOutputs returns whether or not the passed transaction creates
+// any outputs to generalized m-of-n Prova scripts other than the standard
+// 2-of-3 form.  Such transactions are only accepted to the mempool and mined
+// on networks which enable them via the RelayGeneralProvaTxs chain parameter.
+func HasGeneralProvaOutputs(tx *provautil.Tx) bool {
+	for _, txOut := range tx.MsgTx().TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.GeneralProvaTy {
+			return true
+		}
+	}
+	return false
+}
+
 // minInt is a helper function to return the minimum of two ints.  This avoids
 // a math import and the need to cast to floats.
 func minInt(a, b int) int {
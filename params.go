@@ -6,6 +6,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
 	"github.com/bitgo/prova/chaincfg"
 )
 
@@ -17,7 +22,8 @@ var activeNetParams = &mainNetParams
 // network and test networks.
 type params struct {
 	*chaincfg.Params
-	rpcPort string
+	rpcPort  string
+	grpcPort string
 }
 
 // mainNetParams contains parameters specific to the main network
@@ -27,8 +33,9 @@ type params struct {
 // it does not handle on to btcd.  This approach allows the wallet process
 // to emulate the full reference implementation RPC API.
 var mainNetParams = params{
-	Params:  &chaincfg.MainNetParams,
-	rpcPort: "8334",
+	Params:   &chaincfg.MainNetParams,
+	rpcPort:  "8334",
+	grpcPort: "8335",
 }
 
 // regressionNetParams contains parameters specific to the regression test
@@ -36,20 +43,75 @@ var mainNetParams = params{
 // than the reference implementation - see the mainNetParams comment for
 // details.
 var regressionNetParams = params{
-	Params:  &chaincfg.RegressionNetParams,
-	rpcPort: "18334",
+	Params:   &chaincfg.RegressionNetParams,
+	rpcPort:  "18334",
+	grpcPort: "18335",
 }
 
 // testNetParams contains parameters specific to the test network
 // (wire.TestNet).
 var testNetParams = params{
-	Params:  &chaincfg.TestNetParams,
-	rpcPort: "18334",
+	Params:   &chaincfg.TestNetParams,
+	rpcPort:  "18334",
+	grpcPort: "18335",
 }
 
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
-	Params:  &chaincfg.SimNetParams,
-	rpcPort: "18556",
+	Params:   &chaincfg.SimNetParams,
+	rpcPort:  "18556",
+	grpcPort: "18557",
+}
+
+// loadNetParams returns the parameters of the custom network defined in the
+// passed parameters file, which is in the format read by chaincfg.DecodeParams
+// with the additional rpcport and grpcport fields.  The ports default to those
+// of the regression test network when they are omitted.  The network is
+// registered with chaincfg so its addresses and keys can be decoded.
+func loadNetParams(path string) (*params, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Separate the ports of the servers from the chain parameters.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	netParams := params{
+		rpcPort:  regressionNetParams.rpcPort,
+		grpcPort: regressionNetParams.grpcPort,
+	}
+	ports := []struct {
+		field string
+		port  *string
+	}{
+		{"rpcport", &netParams.rpcPort},
+		{"grpcport", &netParams.grpcPort},
+	}
+	for _, p := range ports {
+		raw, ok := fields[p.field]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, p.port); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", p.field, err)
+		}
+		delete(fields, p.field)
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	netParams.Params, err = chaincfg.DecodeParams(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := chaincfg.Register(netParams.Params); err != nil {
+		return nil, err
+	}
+	return &netParams, nil
 }
@@ -123,6 +123,8 @@ func invSummary(invList []*wire.InvVect) string {
 			return fmt.Sprintf("block %s", iv.Hash)
 		case wire.InvTypeTx:
 			return fmt.Sprintf("tx %s", iv.Hash)
+		case wire.InvTypeCmpctBlock:
+			return fmt.Sprintf("cmpctblock %s", iv.Hash)
 		}
 
 		return fmt.Sprintf("unknown (%d) %s", uint32(iv.Type), iv.Hash)
@@ -183,6 +185,9 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgAddr:
 		return fmt.Sprintf("%d addr", len(msg.AddrList))
 
+	case *wire.MsgAddrV2:
+		return fmt.Sprintf("%d addr", len(msg.AddrList))
+
 	case *wire.MsgPing:
 		// No summary - perhaps add nonce.
 
@@ -223,6 +228,45 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgHeaders:
 		return fmt.Sprintf("num %d", len(msg.Headers))
 
+	case *wire.MsgSendCmpct:
+		return fmt.Sprintf("announce %v, version %d",
+			msg.AnnounceUsingCmpct, msg.Version)
+
+	case *wire.MsgCmpctBlock:
+		return fmt.Sprintf("hash %s, %d short ids, %d prefilled",
+			msg.BlockHash(), len(msg.ShortIDs), len(msg.PrefilledTxs))
+
+	case *wire.MsgGetBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx", msg.BlockHash,
+			len(msg.Indexes))
+
+	case *wire.MsgBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx", msg.BlockHash,
+			len(msg.Transactions))
+
+	case *wire.MsgGetCFilters:
+		return fmt.Sprintf("start height %d, stop %s", msg.StartHeight,
+			msg.StopHash)
+
+	case *wire.MsgCFilter:
+		return fmt.Sprintf("hash %s, %d bytes", msg.BlockHash,
+			len(msg.Data))
+
+	case *wire.MsgGetCFHeaders:
+		return fmt.Sprintf("start height %d, stop %s", msg.StartHeight,
+			msg.StopHash)
+
+	case *wire.MsgCFHeaders:
+		return fmt.Sprintf("stop %s, %d filter hashes", msg.StopHash,
+			len(msg.FilterHashes))
+
+	case *wire.MsgGetCFCheckpt:
+		return fmt.Sprintf("stop %s", msg.StopHash)
+
+	case *wire.MsgCFCheckpt:
+		return fmt.Sprintf("stop %s, %d filter headers", msg.StopHash,
+			len(msg.FilterHeaders))
+
 	case *wire.MsgReject:
 		// Ensure the variable length strings don't contain any
 		// characters which are even remotely dangerous such as HTML
@@ -0,0 +1,117 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// MessageLimit limits the rate of the entries of a message type accepted from
+// a peer with a token bucket.  The entries of addr, addrv2, inv and getdata
+// messages are the addresses and inventory vectors they carry, while every
+// other message is a single entry.
+//
+// A message carrying more entries than the burst, such as an oversized inv
+// batch, is always over the limit.
+type MessageLimit struct {
+	// Rate is the number of entries per second refilled into the bucket.
+	Rate float64
+
+	// Burst is the size of the bucket, which is the number of entries
+	// accepted at once after the peer has been idle.
+	Burst float64
+}
+
+// DefaultMessageLimits returns the default limits of the message types which
+// can be used to flood a node.  They are generous enough to never be reached
+// by well behaved peers, including peers performing the initial block
+// download.
+func DefaultMessageLimits() map[string]MessageLimit {
+	return map[string]MessageLimit{
+		wire.CmdAddr:    {Rate: 10, Burst: 2 * wire.MaxAddrPerMsg},
+		wire.CmdAddrV2:  {Rate: 10, Burst: 2 * wire.MaxAddrPerMsg},
+		wire.CmdGetAddr: {Rate: 1.0 / 60, Burst: 2},
+		wire.CmdInv:     {Rate: 1000, Burst: 5000},
+		wire.CmdGetData: {Rate: 1000, Burst: wire.MaxInvPerMsg},
+		wire.CmdMemPool: {Rate: 1.0 / 30, Burst: 3},
+
+		// Every validator sends a heartbeat about once a minute, which
+		// is relayed by every peer.
+		wire.CmdHeartbeat: {Rate: 2, Burst: 200},
+	}
+}
+
+// messageEntries returns the number of entries of the passed message which
+// are counted against its limit.
+func messageEntries(msg wire.Message) int {
+	switch m := msg.(type) {
+	case *wire.MsgAddr:
+		return len(m.AddrList)
+	case *wire.MsgAddrV2:
+		return len(m.AddrList)
+	case *wire.MsgInv:
+		return len(m.InvList)
+	case *wire.MsgGetData:
+		return len(m.InvList)
+	}
+	return 1
+}
+
+// messageBucket is the token bucket of a limited message type.
+type messageBucket struct {
+	limit  MessageLimit
+	tokens float64
+	last   time.Time
+}
+
+// messageLimiter enforces the message limits of a peer.  It is only used by
+// the input handler of the peer, so it is not safe for concurrent access.
+type messageLimiter struct {
+	buckets map[string]*messageBucket
+}
+
+// newMessageLimiter returns a new message limiter enforcing the passed limits,
+// with full buckets.  Limits with a rate or burst of zero are ignored.
+func newMessageLimiter(limits map[string]MessageLimit) *messageLimiter {
+	now := time.Now()
+	buckets := make(map[string]*messageBucket, len(limits))
+	for cmd, limit := range limits {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			continue
+		}
+		buckets[cmd] = &messageBucket{
+			limit:  limit,
+			tokens: limit.Burst,
+			last:   now,
+		}
+	}
+	return &messageLimiter{buckets: buckets}
+}
+
+// allow returns whether the passed number of entries of a message with the
+// passed command are within the limit at the passed time, and takes them from
+// the bucket if they are.  Entries over the limit are not taken, so a peer
+// which slows down is accepted again once the bucket refilled.
+func (l *messageLimiter) allow(cmd string, entries int, now time.Time) bool {
+	b, ok := l.buckets[cmd]
+	if !ok {
+		return true
+	}
+
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+		if b.tokens > b.limit.Burst {
+			b.tokens = b.limit.Burst
+		}
+		b.last = now
+	}
+	if float64(entries) > b.tokens {
+		return false
+	}
+	b.tokens -= float64(entries)
+	return true
+}
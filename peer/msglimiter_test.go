@@ -0,0 +1,80 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// TestMessageLimiter ensures message limiters allow bursts of entries up to
+// their limit, reject entries over it without taking them, refill over time
+// and do not limit other message types.
+func TestMessageLimiter(t *testing.T) {
+	l := newMessageLimiter(map[string]MessageLimit{
+		wire.CmdAddr:    {Rate: 10, Burst: 100},
+		wire.CmdGetData: {Rate: 0, Burst: 100},
+	})
+	now := l.buckets[wire.CmdAddr].last
+
+	// A burst up to the limit is allowed.
+	if !l.allow(wire.CmdAddr, 60, now) {
+		t.Error("allow: burst within the limit rejected")
+	}
+	if l.allow(wire.CmdAddr, 60, now) {
+		t.Error("allow: burst over the limit allowed")
+	}
+
+	// Rejected entries are not taken from the bucket.
+	if !l.allow(wire.CmdAddr, 40, now) {
+		t.Error("allow: entries left in the bucket rejected")
+	}
+
+	// The bucket refills at the limited rate, up to the burst.
+	if l.allow(wire.CmdAddr, 11, now.Add(time.Second)) {
+		t.Error("allow: entries over the refilled tokens allowed")
+	}
+	if !l.allow(wire.CmdAddr, 10, now.Add(time.Second)) {
+		t.Error("allow: refilled entries rejected")
+	}
+	if l.allow(wire.CmdAddr, 101, now.Add(time.Hour)) {
+		t.Error("allow: entries over the burst allowed")
+	}
+
+	// Message types without a valid limit are not limited.
+	if !l.allow(wire.CmdGetData, 1000, now) {
+		t.Error("allow: message with zero rate limited")
+	}
+	if !l.allow(wire.CmdInv, 1000, now) {
+		t.Error("allow: message without limit limited")
+	}
+}
+
+// TestMessageEntries ensures the entries of messages are counted.
+func TestMessageEntries(t *testing.T) {
+	addr := wire.NewMsgAddr()
+	addr.AddAddress(wire.NewNetAddressIPPort(nil, 8333, 0))
+	addr.AddAddress(wire.NewNetAddressIPPort(nil, 8334, 0))
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(&wire.InvVect{Type: wire.InvTypeTx})
+
+	tests := []struct {
+		msg  wire.Message
+		want int
+	}{
+		{addr, 2},
+		{inv, 1},
+		{wire.NewMsgGetData(), 0},
+		{wire.NewMsgMemPool(), 1},
+	}
+	for i, test := range tests {
+		if got := messageEntries(test.msg); got != test.want {
+			t.Errorf("messageEntries #%d (%s): got %d, want %d", i,
+				test.msg.Command(), got, test.want)
+		}
+	}
+}
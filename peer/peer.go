@@ -28,7 +28,7 @@ import (
 
 const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
-	MaxProtocolVersion = wire.FeeFilterVersion
+	MaxProtocolVersion = wire.HeartbeatVersion
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
@@ -45,6 +45,10 @@ const (
 	// messages.
 	pingInterval = 2 * time.Minute
 
+	// maxPingHistory is the maximum number of recent ping times kept for
+	// each peer.
+	maxPingHistory = 8
+
 	// negotiateTimeout is the duration of inactivity before we timeout a
 	// peer that hasn't completed the initial version negotiation.
 	negotiateTimeout = 30 * time.Second
@@ -102,6 +106,9 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -172,6 +179,54 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnSendCmpct is invoked when a peer receives a sendcmpct bitcoin
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 bitcoin
+	// message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
+	// OnHeartbeat is invoked when a peer receives a heartbeat prova
+	// message.
+	OnHeartbeat func(p *Peer, msg *wire.MsgHeartbeat)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin
+	// message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
+	// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin
+	// message.
+	OnGetCFilters func(p *Peer, msg *wire.MsgGetCFilters)
+
+	// OnCFilter is invoked when a peer receives a cfilter bitcoin
+	// message.
+	OnCFilter func(p *Peer, msg *wire.MsgCFilter)
+
+	// OnGetCFHeaders is invoked when a peer receives a getcfheaders bitcoin
+	// message.
+	OnGetCFHeaders func(p *Peer, msg *wire.MsgGetCFHeaders)
+
+	// OnCFHeaders is invoked when a peer receives a cfheaders bitcoin
+	// message.
+	OnCFHeaders func(p *Peer, msg *wire.MsgCFHeaders)
+
+	// OnGetCFCheckpt is invoked when a peer receives a getcfcheckpt bitcoin
+	// message.
+	OnGetCFCheckpt func(p *Peer, msg *wire.MsgGetCFCheckpt)
+
+	// OnCFCheckpt is invoked when a peer receives a cfcheckpt bitcoin
+	// message.
+	OnCFCheckpt func(p *Peer, msg *wire.MsgCFCheckpt)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -186,6 +241,12 @@ type MessageListeners struct {
 	// not an error in the write occurred.  This can be useful for
 	// circumstances such as keeping track of server-wide byte counts.
 	OnWrite func(p *Peer, bytesWritten int, msg wire.Message, err error)
+
+	// OnMessageLimited is invoked when a peer receives a message which is
+	// over the limit of its message type.  It consists of the message and
+	// the number of entries it carries.  The message is dropped without
+	// invoking its callback.
+	OnMessageLimited func(p *Peer, msg wire.Message, entries int)
 }
 
 // Config is the struct to hold configuration options useful to Peer.
@@ -234,6 +295,19 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// ReadLimiter limits the rate messages are read from the peer.  It may
+	// be nil in which case the rate is not limited or measured.
+	ReadLimiter *RateLimiter
+
+	// WriteLimiter limits the rate messages are written to the peer.  It
+	// may be nil in which case the rate is not limited or measured.
+	WriteLimiter *RateLimiter
+
+	// MessageLimits limits the rate messages of the included types are
+	// accepted from the peer, keyed by command.  It may be nil in which
+	// case no message type is limited.
+	MessageLimits map[string]MessageLimit
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -251,7 +325,7 @@ func minUint32(a, b uint32) uint32 {
 // newNetAddress attempts to extract the IP address and port from the passed
 // net.Addr interface and create a bitcoin NetAddress structure using that
 // information.
-func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress, error) {
+func newNetAddress(addr net.Addr, services wire.ServiceFlag, hostToNetAddr HostToNetAddrFunc) (*wire.NetAddress, error) {
 	// addr will be a net.TCPAddr when not using a proxy.
 	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
 		ip := tcpAddr.IP
@@ -273,7 +347,9 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 
 	// For the most part, addr should be one of the two above cases, but
 	// to be safe, fall back to trying to parse the information from the
-	// address string as a last resort.
+	// address string as a last resort.  Addresses of networks which are
+	// not held by an IP, such as I2P, are converted by the passed function
+	// when available.
 	host, portStr, err := net.SplitHostPort(addr.String())
 	if err != nil {
 		return nil, err
@@ -283,6 +359,9 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 	if err != nil {
 		return nil, err
 	}
+	if ip == nil && hostToNetAddr != nil {
+		return hostToNetAddr(host, uint16(port), services)
+	}
 	na := wire.NewNetAddressIPPort(ip, uint16(port), services)
 	return na, nil
 }
@@ -330,6 +409,10 @@ type StatsSnap struct {
 	LastRecv       time.Time
 	BytesSent      uint64
 	BytesRecv      uint64
+	SendRate       int64
+	RecvRate       int64
+	SendLimit      int64
+	RecvLimit      int64
 	ConnTime       time.Time
 	TimeOffset     int64
 	Version        uint32
@@ -340,6 +423,9 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	PingHistory    []int64
+	BytesSentMsg   map[string]uint64
+	BytesRecvMsg   map[string]uint64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -406,10 +492,14 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	cmpctBlocksSupported bool   // peer sent a supported sendcmpct message
+	cmpctBlocksPreferred bool   // peer wants compact blocks pushed
+	addrV2Preferred      bool   // peer sent a sendaddrv2 message
 	versionSent          bool
 	verAckReceived       bool
 
 	knownInventory     *mruInventoryMap
+	msgLimiter         *messageLimiter
 	prevGetBlocksMtx   sync.Mutex
 	prevGetBlocksBegin *chainhash.Hash
 	prevGetBlocksStop  *chainhash.Hash
@@ -428,6 +518,9 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	pingHistory        []int64   // Times of recent pings, oldest first.
+	bytesSentMsg       map[string]uint64
+	bytesRecvMsg       map[string]uint64
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
@@ -479,6 +572,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// KnowsInventory returns whether the passed inventory is in the cache of known
+// inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) KnowsInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Exists(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -493,6 +594,15 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	protocolVersion := p.advertisedProtoVer
 	p.flagsMtx.Unlock()
 
+	bytesSentMsg := make(map[string]uint64, len(p.bytesSentMsg))
+	for cmd, n := range p.bytesSentMsg {
+		bytesSentMsg[cmd] = n
+	}
+	bytesRecvMsg := make(map[string]uint64, len(p.bytesRecvMsg))
+	for cmd, n := range p.bytesRecvMsg {
+		bytesRecvMsg[cmd] = n
+	}
+
 	// Get a copy of all relevant flags and stats.
 	statsSnap := &StatsSnap{
 		ID:             id,
@@ -503,6 +613,10 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastRecv:       p.LastRecv(),
 		BytesSent:      p.BytesSent(),
 		BytesRecv:      p.BytesReceived(),
+		SendRate:       p.cfg.WriteLimiter.Rate(),
+		RecvRate:       p.cfg.ReadLimiter.Rate(),
+		SendLimit:      p.cfg.WriteLimiter.Limit(),
+		RecvLimit:      p.cfg.ReadLimiter.Limit(),
 		ConnTime:       p.timeConnected,
 		TimeOffset:     p.timeOffset,
 		Version:        protocolVersion,
@@ -512,6 +626,9 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		PingHistory:    append([]int64(nil), p.pingHistory...),
+		BytesSentMsg:   bytesSentMsg,
+		BytesRecvMsg:   bytesRecvMsg,
 	}
 
 	p.statsMtx.RUnlock()
@@ -755,6 +872,43 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// SupportsCompactBlocks returns if the peer announced support for the compact
+// block encoding version implemented by the wire package, so blocks can be
+// requested from it as compact blocks.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SupportsCompactBlocks() bool {
+	p.flagsMtx.Lock()
+	cmpctBlocksSupported := p.cmpctBlocksSupported
+	p.flagsMtx.Unlock()
+
+	return cmpctBlocksSupported
+}
+
+// WantsCompactBlocks returns if the peer wants new blocks pushed as compact
+// blocks instead of announcing them with headers or inventory vectors.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCompactBlocks() bool {
+	p.flagsMtx.Lock()
+	cmpctBlocksPreferred := p.cmpctBlocksPreferred
+	p.flagsMtx.Unlock()
+
+	return cmpctBlocksPreferred
+}
+
+// WantsAddrV2 returns if the peer wants addresses relayed with addrv2 messages
+// instead of addr messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	addrV2Preferred := p.addrV2Preferred
+	p.flagsMtx.Unlock()
+
+	return addrV2Preferred
+}
+
 // localVersionMsg creates a version message that can be used to send to the
 // remote peer.
 func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
@@ -837,38 +991,48 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 }
 
 // PushAddrMsg sends an addr message to the connected peer using the provided
-// addresses.  This function is useful over manually sending the message via
-// QueueMessage since it automatically limits the addresses to the maximum
-// number allowed by the message and randomizes the chosen addresses when there
-// are too many.  It returns the addresses that were actually sent and no
-// message will be sent if there are no entries in the provided addresses slice.
+// addresses, or an addrv2 message when the peer asked for them.  This function
+// is useful over manually sending the message via QueueMessage since it
+// automatically limits the addresses to the maximum number allowed by the
+// message and randomizes the chosen addresses when there are too many.
+// Addresses which can only be encoded in addrv2 messages are left out when
+// sending an addr message.  It returns the addresses that were actually sent
+// and no message will be sent if there are no entries in the provided
+// addresses slice.
 //
 // This function is safe for concurrent access.
 func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
+	addrV2 := p.WantsAddrV2()
+	addrList := make([]*wire.NetAddress, 0, len(addresses))
+	for _, na := range addresses {
+		if addrV2 || !na.IsAddrV2Only() {
+			addrList = append(addrList, na)
+		}
+	}
 
 	// Nothing to send.
-	if len(addresses) == 0 {
+	if len(addrList) == 0 {
 		return nil, nil
 	}
 
-	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, len(addresses))
-	copy(msg.AddrList, addresses)
-
 	// Randomize the addresses sent if there are more than the maximum allowed.
-	if len(msg.AddrList) > wire.MaxAddrPerMsg {
+	if len(addrList) > wire.MaxAddrPerMsg {
 		// Shuffle the address list.
-		for i := range msg.AddrList {
+		for i := range addrList {
 			j := rand.Intn(i + 1)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+			addrList[i], addrList[j] = addrList[j], addrList[i]
 		}
 
 		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+		addrList = addrList[:wire.MaxAddrPerMsg]
 	}
 
-	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	if addrV2 {
+		p.QueueMessage(&wire.MsgAddrV2{AddrList: addrList}, nil)
+	} else {
+		p.QueueMessage(&wire.MsgAddr{AddrList: addrList}, nil)
+	}
+	return addrList, nil
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1074,6 +1238,10 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
 			p.lastPingNonce = 0
+			if len(p.pingHistory) == maxPingHistory {
+				p.pingHistory = p.pingHistory[1:]
+			}
+			p.pingHistory = append(p.pingHistory, p.lastPingMicros)
 		}
 		p.statsMtx.Unlock()
 	}
@@ -1084,6 +1252,11 @@ func (p *Peer) readMessage() (wire.Message, []byte, error) {
 	n, msg, buf, err := wire.ReadMessageN(p.conn, p.ProtocolVersion(),
 		p.cfg.ChainParams.Net)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	if msg != nil {
+		p.statsMtx.Lock()
+		p.bytesRecvMsg[msg.Command()] += uint64(n)
+		p.statsMtx.Unlock()
+	}
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1147,9 +1320,15 @@ func (p *Peer) writeMessage(msg wire.Message) error {
 	n, err := wire.WriteMessageN(p.conn, msg, p.ProtocolVersion(),
 		p.cfg.ChainParams.Net)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.statsMtx.Lock()
+	p.bytesSentMsg[msg.Command()] += uint64(n)
+	p.statsMtx.Unlock()
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
+
+	// Wait before writing the next message when the write rate is limited.
+	p.cfg.WriteLimiter.Wait(n, p.quit)
 	return err
 }
 
@@ -1228,8 +1407,10 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, merkleblock, tx, or notfound message.
+		// Expects a block, cmpctblock, merkleblock, tx, or notfound
+		// message.
 		pendingResponses[wire.CmdBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdMerkleBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
@@ -1240,6 +1421,10 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		// headers.
 		deadline = time.Now().Add(stallResponseTimeout * 3)
 		pendingResponses[wire.CmdHeaders] = deadline
+
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
 	}
 }
 
@@ -1287,12 +1472,15 @@ out:
 				switch msgCmd := msg.message.Command(); msgCmd {
 				case wire.CmdBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
@@ -1443,6 +1631,20 @@ out:
 		atomic.StoreInt64(&p.lastRecv, time.Now().Unix())
 		p.stallControl <- stallControlMsg{sccReceiveMessage, rmsg}
 
+		// Drop messages over the limit of their type before they are
+		// handled, so floods do not use up any further resources.
+		entries := messageEntries(rmsg)
+		if !p.msgLimiter.allow(rmsg.Command(), entries, time.Now()) {
+			log.Debugf("Dropping %s message with %d entries from %s "+
+				"-- over the limit", rmsg.Command(), entries, p)
+			if p.cfg.Listeners.OnMessageLimited != nil {
+				p.cfg.Listeners.OnMessageLimited(p, rmsg, entries)
+			}
+			p.cfg.ReadLimiter.Wait(wire.MessageHeaderSize+len(buf), p.quit)
+			idleTimer.Reset(idleTimeout)
+			continue
+		}
+
 		// Handle each supported message type.
 		p.stallControl <- stallControlMsg{sccHandlerStart, rmsg}
 		switch msg := rmsg.(type) {
@@ -1478,6 +1680,11 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1579,10 +1786,98 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendCmpct:
+			// Compact blocks of other encoding versions are not
+			// supported, so such messages are only passed on.
+			if msg.Version == wire.CmpctBlockEncodingVersion {
+				p.flagsMtx.Lock()
+				p.cmpctBlocksSupported = true
+				p.cmpctBlocksPreferred = msg.AnnounceUsingCmpct
+				p.flagsMtx.Unlock()
+			}
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// The sendaddrv2 message is only valid before the verack
+			// message.  No read lock is necessary because
+			// verAckReceived is not written to in any other
+			// goroutine.
+			if p.verAckReceived {
+				log.Infof("Received 'sendaddrv2' after 'verack' "+
+					"from peer %v -- disconnecting", p)
+				break out
+			}
+			p.flagsMtx.Lock()
+			p.addrV2Preferred = true
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
+		case *wire.MsgHeartbeat:
+			if p.cfg.Listeners.OnHeartbeat != nil {
+				p.cfg.Listeners.OnHeartbeat(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
+		case *wire.MsgGetCFilters:
+			if p.cfg.Listeners.OnGetCFilters != nil {
+				p.cfg.Listeners.OnGetCFilters(p, msg)
+			}
+
+		case *wire.MsgCFilter:
+			if p.cfg.Listeners.OnCFilter != nil {
+				p.cfg.Listeners.OnCFilter(p, msg)
+			}
+
+		case *wire.MsgGetCFHeaders:
+			if p.cfg.Listeners.OnGetCFHeaders != nil {
+				p.cfg.Listeners.OnGetCFHeaders(p, msg)
+			}
+
+		case *wire.MsgCFHeaders:
+			if p.cfg.Listeners.OnCFHeaders != nil {
+				p.cfg.Listeners.OnCFHeaders(p, msg)
+			}
+
+		case *wire.MsgGetCFCheckpt:
+			if p.cfg.Listeners.OnGetCFCheckpt != nil {
+				p.cfg.Listeners.OnGetCFCheckpt(p, msg)
+			}
+
+		case *wire.MsgCFCheckpt:
+			if p.cfg.Listeners.OnCFCheckpt != nil {
+				p.cfg.Listeners.OnCFCheckpt(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
 		}
+
+		// Wait before reading the next message when the read rate is
+		// limited.  This is done while the handler is considered active
+		// so the time spent waiting does not count towards the response
+		// deadlines of the stall handler.
+		p.cfg.ReadLimiter.Wait(wire.MessageHeaderSize+len(buf), p.quit)
 		p.stallControl <- stallControlMsg{sccHandlerDone, rmsg}
 
 		// A message was received so reset the idle timer.
@@ -1896,7 +2191,8 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 		// Set up a NetAddress for the peer to be used with AddrManager.  We
 		// only do this inbound because outbound set this up at connection time
 		// and no point recomputing.
-		na, err := newNetAddress(p.conn.RemoteAddr(), p.services)
+		na, err := newNetAddress(p.conn.RemoteAddr(), p.services,
+			p.cfg.HostToNetAddress)
 		if err != nil {
 			log.Errorf("Cannot create remote net address: %v", err)
 			p.Disconnect()
@@ -2005,6 +2301,14 @@ func (p *Peer) readRemoteVersionMsg() error {
 		return err
 	}
 
+	// Ask the peer to relay addresses with addrv2 messages when it supports
+	// them.  This must happen before the verack message is sent, and it is
+	// queued ahead of the messages queued by the version listener so a
+	// getaddr request is answered with addrv2 messages.
+	if p.ProtocolVersion() >= wire.AddrV2Version {
+		p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+	}
+
 	if p.cfg.Listeners.OnVersion != nil {
 		p.cfg.Listeners.OnVersion(p, remoteVerMsg)
 	}
@@ -2069,6 +2373,9 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 	p := Peer{
 		inbound:         inbound,
 		knownInventory:  newMruInventoryMap(maxKnownInventory),
+		msgLimiter:      newMessageLimiter(cfg.MessageLimits),
+		bytesSentMsg:    make(map[string]uint64),
+		bytesRecvMsg:    make(map[string]uint64),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
 		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
@@ -202,6 +202,22 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		t.Errorf("testPeer: wrong LastRecv - got %v, want %v", p.LastRecv(), stats.LastRecv)
 		return
 	}
+
+	var bytesSent, bytesRecv uint64
+	for _, n := range stats.BytesSentMsg {
+		bytesSent += n
+	}
+	for _, n := range stats.BytesRecvMsg {
+		bytesRecv += n
+	}
+	if bytesSent != s.wantBytesSent {
+		t.Errorf("testPeer: wrong BytesSentMsg total - got %v, want %v", bytesSent, s.wantBytesSent)
+		return
+	}
+	if bytesRecv != s.wantBytesReceived {
+		t.Errorf("testPeer: wrong BytesRecvMsg total - got %v, want %v", bytesRecv, s.wantBytesReceived)
+		return
+	}
 }
 
 // TestPeerConnection tests connection between inbound and outbound peers.
@@ -235,8 +251,8 @@ func TestPeerConnection(t *testing.T) {
 		wantLastPingNonce:   uint64(0),
 		wantLastPingMicros:  int64(0),
 		wantTimeOffset:      int64(0),
-		wantBytesSent:       158, // 134 version + 24 verack
-		wantBytesReceived:   158,
+		wantBytesSent:       182, // 134 version + 24 sendaddrv2 + 24 verack
+		wantBytesReceived:   182,
 	}
 	tests := []struct {
 		name  string
@@ -315,6 +331,7 @@ func TestPeerConnection(t *testing.T) {
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
+	sendAddrV2 := make(chan struct{}, 1)
 	ok := make(chan wire.Message, 20)
 	peerCfg := &peer.Config{
 		Listeners: peer.MessageListeners{
@@ -324,6 +341,9 @@ func TestPeerListeners(t *testing.T) {
 			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
 				ok <- msg
 			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				ok <- msg
+			},
 			OnPing: func(p *peer.Peer, msg *wire.MsgPing) {
 				ok <- msg
 			},
@@ -387,6 +407,42 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
+				sendAddrV2 <- struct{}{}
+			},
+			OnHeartbeat: func(p *peer.Peer, msg *wire.MsgHeartbeat) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
+			OnGetCFilters: func(p *peer.Peer, msg *wire.MsgGetCFilters) {
+				ok <- msg
+			},
+			OnCFilter: func(p *peer.Peer, msg *wire.MsgCFilter) {
+				ok <- msg
+			},
+			OnGetCFHeaders: func(p *peer.Peer, msg *wire.MsgGetCFHeaders) {
+				ok <- msg
+			},
+			OnCFHeaders: func(p *peer.Peer, msg *wire.MsgCFHeaders) {
+				ok <- msg
+			},
+			OnGetCFCheckpt: func(p *peer.Peer, msg *wire.MsgGetCFCheckpt) {
+				ok <- msg
+			},
+			OnCFCheckpt: func(p *peer.Peer, msg *wire.MsgCFCheckpt) {
+				ok <- msg
+			},
 		},
 		UserAgentName:    "peer",
 		UserAgentVersion: "1.0",
@@ -433,6 +489,10 @@ func TestPeerListeners(t *testing.T) {
 			"OnAddr",
 			wire.NewMsgAddr(),
 		},
+		{
+			"OnAddrV2",
+			wire.NewMsgAddrV2(),
+		},
 		{
 			"OnPing",
 			wire.NewMsgPing(42),
@@ -511,6 +571,50 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(true, wire.CmpctBlockEncodingVersion),
+		},
+		{
+			"OnHeartbeat",
+			wire.NewMsgHeartbeat(1, &chainhash.Hash{}),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{}, &chainhash.Hash{}, 1, 1)), 1),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}, []uint32{1}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnGetCFilters",
+			wire.NewMsgGetCFilters(wire.GCSFilterRegular, 0, &chainhash.Hash{}),
+		},
+		{
+			"OnCFilter",
+			wire.NewMsgCFilter(wire.GCSFilterRegular, &chainhash.Hash{}, []byte("payload")),
+		},
+		{
+			"OnGetCFHeaders",
+			wire.NewMsgGetCFHeaders(wire.GCSFilterRegular, 0, &chainhash.Hash{}),
+		},
+		{
+			"OnCFHeaders",
+			wire.NewMsgCFHeaders(),
+		},
+		{
+			"OnGetCFCheckpt",
+			wire.NewMsgGetCFCheckpt(wire.GCSFilterRegular, &chainhash.Hash{}),
+		},
+		{
+			"OnCFCheckpt",
+			wire.NewMsgCFCheckpt(wire.GCSFilterRegular, &chainhash.Hash{}, 0),
+		},
 	}
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
@@ -523,6 +627,21 @@ func TestPeerListeners(t *testing.T) {
 			return
 		}
 	}
+
+	// The sendcmpct message requested compact blocks to be pushed.
+	if !inPeer.SupportsCompactBlocks() || !inPeer.WantsCompactBlocks() {
+		t.Errorf("TestPeerListeners: compact blocks not negotiated")
+	}
+
+	// The sendaddrv2 message was sent during the handshake.
+	select {
+	case <-sendAddrV2:
+	default:
+		t.Errorf("TestPeerListeners: OnSendAddrV2 not invoked")
+	}
+	if !inPeer.WantsAddrV2() {
+		t.Errorf("TestPeerListeners: addrv2 not negotiated")
+	}
 	inPeer.Disconnect()
 	outPeer.Disconnect()
 }
@@ -646,6 +765,17 @@ func TestOutboundPeer(t *testing.T) {
 		t.Errorf("PushAddrMsg: unexpected err %v\n", err)
 		return
 	}
+
+	// Addresses which need addrv2 messages are not sent to peers which did
+	// not ask for them.
+	torV3 := wire.NewNetAddressNetwork(wire.NetTorV3, make([]byte, 32), 8333,
+		0)
+	sent, err := p2.PushAddrMsg([]*wire.NetAddress{torV3})
+	if err != nil || len(sent) != 0 {
+		t.Errorf("PushAddrMsg: got %v, %v, want no addresses sent", sent,
+			err)
+		return
+	}
 	if err := p2.PushGetBlocksMsg(nil, &chainhash.Hash{}); err != nil {
 		t.Errorf("PushGetBlocksMsg: unexpected err %v\n", err)
 		return
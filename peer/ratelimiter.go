@@ -0,0 +1,163 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimiterBurst is the duration of traffic at the limited rate a
+	// rate limiter allows to be transferred at once after being idle.
+	rateLimiterBurst = time.Second
+
+	// rateWindow is the duration over which the measured rate of a rate
+	// limiter is averaged.
+	rateWindow = 2 * time.Second
+)
+
+// RateLimiter limits the rate of bytes transferred through it with a token
+// bucket, and measures the rate of the transferred bytes.  A rate limiter may
+// have a parent, such as a global limit shared by all peers, in which case
+// transfers are limited by both.
+//
+// Transfers larger than the tokens in the bucket are allowed to take the
+// bucket into debt, so messages of any size can be transferred, and the debt
+// is paid by waiting before the next transfer.
+//
+// The zero value is not usable; use NewRateLimiter instead.  All methods of a
+// nil rate limiter are valid and do not limit anything.
+type RateLimiter struct {
+	mtx    sync.Mutex
+	limit  int64
+	parent *RateLimiter
+	tokens float64
+	last   time.Time
+
+	// The following fields measure the rate of the transferred bytes over
+	// the current and the previous window.
+	windowStart time.Time
+	windowBytes int64
+	prevBytes   int64
+}
+
+// NewRateLimiter returns a new rate limiter which limits transfers to the
+// passed number of bytes per second as well as by the limits of the passed
+// parent, which may be nil.  A limit of 0 does not limit the rate, but still
+// measures it.
+func NewRateLimiter(bytesPerSecond int64, parent *RateLimiter) *RateLimiter {
+	now := time.Now()
+	return &RateLimiter{
+		limit:       bytesPerSecond,
+		parent:      parent,
+		tokens:      float64(bytesPerSecond) * rateLimiterBurst.Seconds(),
+		last:        now,
+		windowStart: now,
+	}
+}
+
+// Limit returns the limit of the rate limiter in bytes per second, not taking
+// its parent into account.  0 means the rate is not limited.
+//
+// This function is safe for concurrent access.
+func (l *RateLimiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.limit
+}
+
+// take records the transfer of n bytes at the passed time and returns the
+// duration to wait before the next transfer to stay within the limit.
+//
+// This function MUST be called with the rate limiter lock held.
+func (l *RateLimiter) take(n int, now time.Time) time.Duration {
+	// Move the measurement window forward.
+	if elapsed := now.Sub(l.windowStart); elapsed >= rateWindow {
+		if elapsed >= 2*rateWindow {
+			l.prevBytes = 0
+		} else {
+			l.prevBytes = l.windowBytes
+		}
+		l.windowBytes = 0
+		l.windowStart = now
+	}
+	l.windowBytes += int64(n)
+
+	if l.limit == 0 {
+		return 0
+	}
+
+	// Refill the bucket for the elapsed time up to the burst and take the
+	// transferred bytes from it.  Concurrent transfers may be recorded out
+	// of order, so the time only moves forward.
+	if now.After(l.last) {
+		burst := float64(l.limit) * rateLimiterBurst.Seconds()
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.limit)
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+		l.last = now
+	}
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / float64(l.limit) * float64(time.Second))
+}
+
+// Wait records the transfer of n bytes and blocks until the debt it caused to
+// the rate limiter and its parents is paid, or until the passed channel is
+// closed.
+//
+// This function is safe for concurrent access.
+func (l *RateLimiter) Wait(n int, quit <-chan struct{}) {
+	var wait time.Duration
+	now := time.Now()
+	for limiter := l; limiter != nil; limiter = limiter.parent {
+		limiter.mtx.Lock()
+		if d := limiter.take(n, now); d > wait {
+			wait = d
+		}
+		limiter.mtx.Unlock()
+	}
+	if wait == 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-quit:
+	}
+}
+
+// Rate returns the measured rate of the bytes transferred through the rate
+// limiter in bytes per second.
+//
+// This function is safe for concurrent access.
+func (l *RateLimiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// The rate is averaged over the current window and the previous one,
+	// so it does not drop to zero whenever a new window starts.
+	elapsed := time.Since(l.windowStart)
+	bytes := l.windowBytes
+	switch {
+	case elapsed >= 2*rateWindow:
+		return 0
+	case elapsed < rateWindow:
+		bytes += l.prevBytes
+		elapsed += rateWindow
+	}
+	return int64(float64(bytes) / elapsed.Seconds())
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRateLimiter ensures rate limiters allow a burst of transfers, delay
+// transfers beyond their limit and the limit of their parent, and measure the
+// transferred rate.
+func TestRateLimiter(t *testing.T) {
+	parent := NewRateLimiter(1000, nil)
+	l := NewRateLimiter(2000, parent)
+	now := l.last
+
+	// A burst of the limit is allowed without waiting.
+	if d := l.take(1000, now); d != 0 {
+		t.Errorf("take: got wait %v for burst, want 0", d)
+	}
+	if d := parent.take(1000, now); d != 0 {
+		t.Errorf("take: got wait %v for burst, want 0", d)
+	}
+
+	// Transfers beyond the burst wait for the debt to be paid.
+	if d := l.take(1500, now); d != 250*time.Millisecond {
+		t.Errorf("take: got wait %v, want 250ms", d)
+	}
+	if d := parent.take(1500, now); d != 1500*time.Millisecond {
+		t.Errorf("take: got wait %v, want 1.5s", d)
+	}
+
+	// The bucket refills over time.
+	if d := l.take(0, now.Add(time.Second)); d != 0 {
+		t.Errorf("take: got wait %v after refill, want 0", d)
+	}
+
+	// The measured rate is averaged over the windows.
+	l.windowStart = time.Now().Add(-rateWindow / 2)
+	l.windowBytes = 3000
+	l.prevBytes = 2000
+	want := int64(5000 / (rateWindow + rateWindow/2).Seconds())
+	if rate := l.Rate(); rate < want-10 || rate > want {
+		t.Errorf("Rate: got %d, want about %d", rate, want)
+	}
+	l.windowStart = time.Now().Add(-2 * rateWindow)
+	if rate := l.Rate(); rate != 0 {
+		t.Errorf("Rate: got %d after idle windows, want 0", rate)
+	}
+
+	// Waiting stops when the quit channel is closed.
+	quit := make(chan struct{})
+	close(quit)
+	start := time.Now()
+	NewRateLimiter(1, nil).Wait(1000, quit)
+	if time.Since(start) > time.Second {
+		t.Error("Wait: did not stop when quit was closed")
+	}
+
+	// A nil rate limiter does not limit or measure anything.
+	var nilLimiter *RateLimiter
+	nilLimiter.Wait(1000, nil)
+	if nilLimiter.Rate() != 0 || nilLimiter.Limit() != 0 {
+		t.Error("nil rate limiter: unexpected rate or limit")
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package provatest provides a harness for end-to-end tests which drive prova
+nodes via their RPC servers.
+
+A Network is a private network based on regtest whose admin keys are generated
+by the harness, so tests can provision keys and issue tokens on it.  NewNode
+launches prova processes on the network, which are connected to each other
+with ConnectNode or ConnectMesh.  The nodes generate blocks on request with the
+validate keys of the network.
+
+	network, err := provatest.NewNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer network.TearDown()
+
+	node, err := network.NewNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _, err := network.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	amounts := map[provautil.Address]provautil.Amount{addr: 1e6}
+	if _, err := node.IssueTokens(amounts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Generate(1); err != nil {
+		t.Fatal(err)
+	}
+
+The prova executable is looked up in the PATH unless Network.Exe names
+another one.
+*/
+package provatest
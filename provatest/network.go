@@ -0,0 +1,277 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provatest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// defaultExe is the name of the prova executable looked up in the PATH
+	// when a network does not name another one.
+	defaultExe = "prova"
+
+	// numAdminKeys is the number of keys generated for each of the root,
+	// provision and issue key sets.  Admin threads require two signatures.
+	numAdminKeys = 2
+
+	// numValidateKeys is the number of validate keys of a new network.
+	numValidateKeys = 2
+
+	// coinbaseMaturity is the coinbase maturity of a new network.  It is
+	// kept low so the admin threads created by the genesis block can be
+	// spent right away.
+	coinbaseMaturity = 1
+)
+
+var (
+	// aspKeyIDs are the key ids of the ASP keys of a new network.  The
+	// addresses returned by NewAddress use both of them.
+	aspKeyIDs = []btcec.KeyID{1, 2}
+
+	// numNetworks is the number of networks created by this process.  It
+	// makes the magic of every network unique.
+	numNetworks uint32
+
+	// networksMtx protects numNetworks.
+	networksMtx sync.Mutex
+)
+
+// Network is a private regtest based network with generated admin keys, on
+// which nodes are launched by NewNode.  The private keys of all key sets are
+// known, so tests can provision keys and issue tokens at will.
+type Network struct {
+	// Params are the chain parameters of the network.  They may be
+	// changed until the first node is launched, after which they are
+	// written to the parameters file passed to every node.
+	Params *chaincfg.Params
+
+	// Exe is the prova executable launched by NewNode.  It defaults to
+	// the prova executable in the PATH.
+	Exe string
+
+	// The private keys of the admin key sets and the ASP keys of the
+	// network.
+	RootKeys      []*btcec.PrivateKey
+	ProvisionKeys []*btcec.PrivateKey
+	IssueKeys     []*btcec.PrivateKey
+	ValidateKeys  []*btcec.PrivateKey
+	ASPKeys       map[btcec.KeyID]*btcec.PrivateKey
+
+	// MiningAddr is the address generated blocks pay to by default, and
+	// MiningKey the key of the address.
+	MiningAddr provautil.Address
+	MiningKey  *btcec.PrivateKey
+
+	dir        string
+	paramsFile string
+	nodes      []*Node
+	mtx        sync.Mutex
+}
+
+// newKeys generates n private keys.
+func newKeys(n int) ([]*btcec.PrivateKey, error) {
+	keys := make([]*btcec.PrivateKey, 0, n)
+	for i := 0; i < n; i++ {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// pubKeySet returns the key set of the public keys of the passed keys.
+func pubKeySet(keys []*btcec.PrivateKey) btcec.PublicKeySet {
+	keySet := make(btcec.PublicKeySet, 0, len(keys))
+	for _, key := range keys {
+		keySet = append(keySet, *key.PubKey())
+	}
+	return keySet
+}
+
+// NewNetwork creates a new network based on regtest with newly generated admin
+// keys and a genesis block committing to them.  The coinbase maturity of the
+// network is a single block and the validate keys are not rate limited, so
+// tests can generate blocks and spend the admin threads without waiting.
+//
+// The temporary directory holding the files of the network and its nodes is
+// removed by TearDown.
+func NewNetwork() (*Network, error) {
+	networksMtx.Lock()
+	netNum := numNetworks
+	numNetworks++
+	networksMtx.Unlock()
+
+	n := &Network{
+		Exe:     defaultExe,
+		ASPKeys: make(map[btcec.KeyID]*btcec.PrivateKey),
+	}
+	var err error
+	for _, keys := range []struct {
+		keys *[]*btcec.PrivateKey
+		num  int
+	}{
+		{&n.RootKeys, numAdminKeys},
+		{&n.ProvisionKeys, numAdminKeys},
+		{&n.IssueKeys, numAdminKeys},
+		{&n.ValidateKeys, numValidateKeys},
+	} {
+		*keys.keys, err = newKeys(keys.num)
+		if err != nil {
+			return nil, err
+		}
+	}
+	aspKeyIDMap := make(btcec.KeyIdMap)
+	for _, keyID := range aspKeyIDs {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		n.ASPKeys[keyID] = key
+		aspKeyIDMap[keyID] = key.PubKey()
+	}
+
+	// Use a magic which differs from those of the default networks and of
+	// the other networks of this process.
+	config := &chaincfg.NetworkConfig{
+		Base:        &chaincfg.RegressionNetParams,
+		Name:        fmt.Sprintf("provatest%d", netNum),
+		Net:         wire.BitcoinNet(0x70747374 + netNum),
+		DefaultPort: chaincfg.RegressionNetParams.DefaultPort,
+		AdminKeySets: map[btcec.KeySetType]btcec.PublicKeySet{
+			btcec.RootKeySet:      pubKeySet(n.RootKeys),
+			btcec.ProvisionKeySet: pubKeySet(n.ProvisionKeys),
+			btcec.IssueKeySet:     pubKeySet(n.IssueKeys),
+			btcec.ValidateKeySet:  pubKeySet(n.ValidateKeys),
+		},
+		ASPKeyIdMap: aspKeyIDMap,
+		Timestamp:   time.Now(),
+		Message:     "provatest",
+	}
+	n.Params, err = chaincfg.NewNetwork(config)
+	if err != nil {
+		return nil, err
+	}
+	n.Params.CoinbaseMaturity = coinbaseMaturity
+	n.Params.ChainTrailingSigKeyLimit = 0
+	n.Params.ChainWindowShareLimit = 0
+
+	n.MiningAddr, n.MiningKey, err = n.NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	n.dir, err = ioutil.TempDir("", config.Name+"-")
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// NewAddress returns a new Prova address of the network along with its
+// private key.  The address uses the ASP keys of the network, so funds paid to
+// it are spent by signing with the returned key and one of the ASP keys.
+func (n *Network) NewAddress() (*provautil.AddressProva, *btcec.PrivateKey, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+	pkHash := provautil.Hash160(key.PubKey().SerializeCompressed())
+	addr, err := provautil.NewAddressProva(pkHash, aspKeyIDs, n.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, key, nil
+}
+
+// WIF returns the WIF encoding of the passed private key on the network.
+func (n *Network) WIF(key *btcec.PrivateKey) (string, error) {
+	wif, err := provautil.NewWIF(key, n.Params, true)
+	if err != nil {
+		return "", err
+	}
+	return wif.String(), nil
+}
+
+// adminWIFs returns the WIF encoded keys of the root, provision and issue key
+// sets.
+func (n *Network) adminWIFs() ([]string, error) {
+	var wifs []string
+	for _, keySet := range [][]*btcec.PrivateKey{n.RootKeys,
+		n.ProvisionKeys, n.IssueKeys} {
+
+		for _, key := range keySet {
+			wif, err := n.WIF(key)
+			if err != nil {
+				return nil, err
+			}
+			wifs = append(wifs, wif)
+		}
+	}
+	return wifs, nil
+}
+
+// writeParams writes the parameters file of the network unless it was written
+// already.
+//
+// This function MUST be called with the network mutex held.
+func (n *Network) writeParams() error {
+	if n.paramsFile != "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := chaincfg.EncodeParams(&buf, n.Params); err != nil {
+		return err
+	}
+	paramsFile := filepath.Join(n.dir, "params.json")
+	if err := ioutil.WriteFile(paramsFile, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	n.paramsFile = paramsFile
+	return nil
+}
+
+// Nodes returns the nodes launched on the network which have not been stopped.
+func (n *Network) Nodes() []*Node {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	nodes := make([]*Node, len(n.nodes))
+	copy(nodes, n.nodes)
+	return nodes
+}
+
+// TearDown stops all nodes of the network and removes the temporary directory
+// holding their files.
+func (n *Network) TearDown() error {
+	n.mtx.Lock()
+	nodes := n.nodes
+	n.nodes = nil
+	n.mtx.Unlock()
+
+	var firstErr error
+	for _, node := range nodes {
+		if err := node.stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := os.RemoveAll(n.dir); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provatest
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+)
+
+const (
+	// rpcUser and rpcPass are the RPC credentials of the launched nodes.
+	rpcUser = "provatest"
+	rpcPass = "provatest"
+
+	// startTimeout is how long NewNode waits for the RPC server of a
+	// launched node to answer.
+	startTimeout = 30 * time.Second
+
+	// stopTimeout is how long a node is given to shut down after being
+	// interrupted before it is killed.
+	stopTimeout = 30 * time.Second
+)
+
+// Node is a prova process launched on a network by NewNode.  It is driven via
+// its RPC server.
+type Node struct {
+	// Dir is the directory holding the data and logs of the node, along
+	// with the output of the process in prova.out.
+	Dir string
+
+	// P2PAddr and RPCAddr are the addresses the node listens on for peers
+	// and RPC clients respectively.
+	P2PAddr string
+	RPCAddr string
+
+	network *Network
+	cmd     *exec.Cmd
+	done    chan struct{}
+	client  *http.Client
+}
+
+// freeAddr returns a local address with a port which is not in use.
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// NewNode launches a new node on the network and waits until its RPC server
+// answers.  The node signs the transactions created by the admin.* RPCs with
+// the admin keys of the network and generates blocks with its validate keys
+// and mining address.  The passed arguments are appended to those of the
+// process, so they may override any of them.
+func (n *Network) NewNode(extraArgs ...string) (*Node, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if err := n.writeParams(); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(n.dir, "node")
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Dir:     dir,
+		network: n,
+		done:    make(chan struct{}),
+		client:  &http.Client{},
+	}
+	if node.P2PAddr, err = freeAddr(); err != nil {
+		return nil, err
+	}
+	if node.RPCAddr, err = freeAddr(); err != nil {
+		return nil, err
+	}
+
+	// Start from an empty configuration file so the configuration of the
+	// user does not apply to the node.
+	configFile := filepath.Join(dir, "prova.conf")
+	if err := ioutil.WriteFile(configFile, nil, 0600); err != nil {
+		return nil, err
+	}
+	args := []string{
+		"--configfile=" + configFile,
+		"--netparams=" + n.paramsFile,
+		"--datadir=" + filepath.Join(dir, "data"),
+		"--logdir=" + filepath.Join(dir, "logs"),
+		"--listen=" + node.P2PAddr,
+		"--rpclisten=" + node.RPCAddr,
+		"--rpcuser=" + rpcUser,
+		"--rpcpass=" + rpcPass,
+		"--notls",
+		"--nodnsseed",
+		"--txindex",
+		"--miningaddr=" + n.MiningAddr.EncodeAddress(),
+		"--debuglevel=debug",
+	}
+	adminWIFs, err := n.adminWIFs()
+	if err != nil {
+		return nil, err
+	}
+	for _, wif := range adminWIFs {
+		args = append(args, "--adminkey="+wif)
+	}
+	args = append(args, extraArgs...)
+
+	validateKeys := make([]string, 0, len(n.ValidateKeys))
+	for _, key := range n.ValidateKeys {
+		validateKeys = append(validateKeys, hex.EncodeToString(key.Serialize()))
+	}
+
+	out, err := os.Create(filepath.Join(dir, "prova.out"))
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	node.cmd = exec.Command(n.Exe, args...)
+	node.cmd.Env = append(os.Environ(),
+		"PROVA_VALIDATE_KEYS="+strings.Join(validateKeys, ","))
+	node.cmd.Stdout = out
+	node.cmd.Stderr = out
+	if err := node.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		node.cmd.Wait()
+		close(node.done)
+	}()
+
+	if err := node.waitForRPC(); err != nil {
+		node.kill()
+		return nil, err
+	}
+	n.nodes = append(n.nodes, node)
+	return node, nil
+}
+
+// waitForRPC blocks until the RPC server of the node answers, the process
+// exits or the start timeout expires.
+func (node *Node) waitForRPC() error {
+	timeout := time.After(startTimeout)
+	for {
+		var count int64
+		err := node.Call(btcjson.NewGetBlockCountCmd(), &count)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-node.done:
+			return fmt.Errorf("node exited before starting, see %s",
+				filepath.Join(node.Dir, "prova.out"))
+		case <-timeout:
+			return fmt.Errorf("node did not start within %v: %v",
+				startTimeout, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// kill kills the process of the node and waits until it exits.
+func (node *Node) kill() {
+	node.cmd.Process.Kill()
+	<-node.done
+}
+
+// stop interrupts the process of the node and waits until it exits.  The
+// process is killed when it does not exit in time.  On windows, interrupt is
+// not supported, so it is killed right away.
+func (node *Node) stop() error {
+	select {
+	case <-node.done:
+		return nil
+	default:
+	}
+	if runtime.GOOS == "windows" {
+		node.kill()
+		return nil
+	}
+	if err := node.cmd.Process.Signal(os.Interrupt); err != nil {
+		return err
+	}
+	select {
+	case <-node.done:
+		return nil
+	case <-time.After(stopTimeout):
+		node.kill()
+		return errors.New("node did not shut down in time")
+	}
+}
+
+// Stop shuts the node down.  Its files are kept until the network is torn
+// down.
+func (node *Node) Stop() error {
+	n := node.network
+	n.mtx.Lock()
+	for i, other := range n.nodes {
+		if other == node {
+			n.nodes = append(n.nodes[:i], n.nodes[i+1:]...)
+			break
+		}
+	}
+	n.mtx.Unlock()
+
+	return node.stop()
+}
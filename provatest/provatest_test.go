@@ -0,0 +1,85 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build rpctest
+
+package provatest
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestNetwork exercises the harness by issuing tokens and provisioning a
+// validate key on one node of a mesh and ensuring the others follow.
+func TestNetwork(t *testing.T) {
+	network, err := NewNetwork()
+	if err != nil {
+		t.Fatalf("NewNetwork: unexpected error: %v", err)
+	}
+	defer network.TearDown()
+
+	var nodes []*Node
+	for i := 0; i < 3; i++ {
+		node, err := network.NewNode()
+		if err != nil {
+			t.Fatalf("NewNode: unexpected error: %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+	if err := ConnectMesh(nodes...); err != nil {
+		t.Fatalf("ConnectMesh: unexpected error: %v", err)
+	}
+
+	node := nodes[0]
+	if _, err := node.Generate(2); err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+
+	addr, _, err := network.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	amounts := map[provautil.Address]provautil.Amount{addr: 1e6}
+	if _, err := node.IssueTokens(amounts); err != nil {
+		t.Fatalf("IssueTokens: unexpected error: %v", err)
+	}
+	validateKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	if _, err := node.ProvisionValidateKey(validateKey.PubKey()); err != nil {
+		t.Fatalf("ProvisionValidateKey: unexpected error: %v", err)
+	}
+	if err := SyncMempools(nodes...); err != nil {
+		t.Fatalf("SyncMempools: unexpected error: %v", err)
+	}
+	if _, err := nodes[1].GenerateToAddress(1, addr); err != nil {
+		t.Fatalf("GenerateToAddress: unexpected error: %v", err)
+	}
+	if err := SyncBlocks(nodes...); err != nil {
+		t.Fatalf("SyncBlocks: unexpected error: %v", err)
+	}
+
+	_, height, err := nodes[2].BestBlock()
+	if err != nil {
+		t.Fatalf("BestBlock: unexpected error: %v", err)
+	}
+	if height != 3 {
+		t.Errorf("BestBlock: got height %d, want 3", height)
+	}
+	var keySets btcjson.AdminListKeySetsResult
+	err = nodes[2].Call(btcjson.NewAdminListKeySetsCmd(), &keySets)
+	if err != nil {
+		t.Fatalf("admin.listkeysets: unexpected error: %v", err)
+	}
+	if len(keySets.Validate) != len(network.ValidateKeys)+1 {
+		t.Errorf("admin.listkeysets: got %d validate keys, want %d",
+			len(keySets.Validate), len(network.ValidateKeys)+1)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provatest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// Call sends the passed btcjson command to the RPC server of the node and
+// unmarshals the result into result, unless it is nil.  An error returned by
+// the server is returned as a *btcjson.RPCError.
+func (node *Node) Call(cmd interface{}, result interface{}) error {
+	marshalledJSON, err := btcjson.MarshalCmd(1, cmd)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequest("POST", "http://"+node.RPCAddr,
+		bytes.NewReader(marshalledJSON))
+	if err != nil {
+		return err
+	}
+	httpRequest.Close = true
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.SetBasicAuth(rpcUser, rpcPass)
+	httpResponse, err := node.client.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	respBytes, err := ioutil.ReadAll(httpResponse.Body)
+	httpResponse.Body.Close()
+	if err != nil {
+		return fmt.Errorf("error reading json reply: %v", err)
+	}
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		if len(respBytes) == 0 {
+			return fmt.Errorf("%d %s", httpResponse.StatusCode,
+				http.StatusText(httpResponse.StatusCode))
+		}
+		return fmt.Errorf("%s", respBytes)
+	}
+
+	var resp btcjson.Response
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// parseHashes parses the passed hex encoded hashes.
+func parseHashes(hashStrs []string) ([]*chainhash.Hash, error) {
+	hashes := make([]*chainhash.Hash, 0, len(hashStrs))
+	for _, hashStr := range hashStrs {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// Generate generates numBlocks blocks paying to the mining address of the
+// network and returns their hashes.
+func (node *Node) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
+	var hashStrs []string
+	err := node.Call(btcjson.NewGenerateCmd(numBlocks), &hashStrs)
+	if err != nil {
+		return nil, err
+	}
+	return parseHashes(hashStrs)
+}
+
+// GenerateToAddress generates numBlocks blocks paying to the passed address and
+// returns their hashes.
+func (node *Node) GenerateToAddress(numBlocks uint32, addr provautil.Address) ([]*chainhash.Hash, error) {
+	var hashStrs []string
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, addr.EncodeAddress())
+	if err := node.Call(cmd, &hashStrs); err != nil {
+		return nil, err
+	}
+	return parseHashes(hashStrs)
+}
+
+// BestBlock returns the hash and height of the best block of the node.
+func (node *Node) BestBlock() (*chainhash.Hash, uint32, error) {
+	var result btcjson.GetBestBlockResult
+	if err := node.Call(btcjson.NewGetBestBlockCmd(), &result); err != nil {
+		return nil, 0, err
+	}
+	hash, err := chainhash.NewHashFromStr(result.Hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, result.Height, nil
+}
+
+// SendRawTransaction submits the passed hex encoded transaction to the node and
+// returns its hash.
+func (node *Node) SendRawTransaction(txHex string) (*chainhash.Hash, error) {
+	var txID string
+	err := node.Call(btcjson.NewSendRawTransactionCmd(txHex, nil), &txID)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(txID)
+}
+
+// SignAndSend signs the passed hex encoded transaction with the passed keys
+// via the signrawtransaction RPC and submits it to the node.  It returns the
+// hash of the transaction.
+func (node *Node) SignAndSend(txHex string, keys ...*btcec.PrivateKey) (*chainhash.Hash, error) {
+	wifs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		wif, err := node.network.WIF(key)
+		if err != nil {
+			return nil, err
+		}
+		wifs = append(wifs, wif)
+	}
+	// The inputs are looked up by the node, but must be passed as an empty
+	// list since optional parameters are only marshalled up to the first
+	// omitted one.
+	inputs := []btcjson.RawTxInput{}
+	var signed btcjson.SignRawTransactionResult
+	cmd := btcjson.NewSignRawTransactionCmd(txHex, &inputs, &wifs, nil)
+	if err := node.Call(cmd, &signed); err != nil {
+		return nil, err
+	}
+	if !signed.Complete {
+		return nil, fmt.Errorf("transaction is not fully signed: %v",
+			signed.Errors)
+	}
+	return node.SendRawTransaction(signed.Hex)
+}
+
+// sendAdminTx creates an admin transaction via the passed command, signs it
+// with the keys of the passed admin key set and submits it to the node.
+func (node *Node) sendAdminTx(cmd interface{}, keys []*btcec.PrivateKey) (*chainhash.Hash, error) {
+	var txHex string
+	if err := node.Call(cmd, &txHex); err != nil {
+		return nil, err
+	}
+	return node.SignAndSend(txHex, keys...)
+}
+
+// ProvisionKeys submits a provision thread transaction performing the passed
+// key operations, such as adding validate or ASP keys, and returns its hash.
+// The transaction takes effect once it is mined.
+func (node *Node) ProvisionKeys(keyOps ...btcjson.AdminKeyOp) (*chainhash.Hash, error) {
+	return node.sendAdminTx(btcjson.NewCreateProvisionTxCmd(keyOps),
+		node.network.ProvisionKeys)
+}
+
+// ProvisionValidateKey submits a provision thread transaction adding the
+// passed key to the validate key set and returns its hash.
+func (node *Node) ProvisionValidateKey(pubKey *btcec.PublicKey) (*chainhash.Hash, error) {
+	return node.ProvisionKeys(btcjson.AdminKeyOp{
+		KeySet: btcec.ValidateKeySet.String(),
+		PubKey: hex.EncodeToString(pubKey.SerializeCompressed()),
+	})
+}
+
+// IssueTokens submits an issue thread transaction paying the passed amounts to
+// their addresses and returns its hash.
+func (node *Node) IssueTokens(amounts map[provautil.Address]provautil.Amount) (*chainhash.Hash, error) {
+	rmgAmounts := make(map[string]float64, len(amounts))
+	for addr, amount := range amounts {
+		rmgAmounts[addr.EncodeAddress()] = amount.ToRMG()
+	}
+	return node.sendAdminTx(btcjson.NewCreateIssueTxCmd(rmgAmounts),
+		node.network.IssueKeys)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provatest
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+)
+
+const (
+	// syncTimeout is how long ConnectNode and the sync functions wait for
+	// the nodes to reach the expected state.
+	syncTimeout = 30 * time.Second
+
+	// pollInterval is the time between the checks of the state of the
+	// nodes while waiting.
+	pollInterval = 100 * time.Millisecond
+)
+
+// waitFor calls done until it returns true or an error, or the sync timeout
+// expires.
+func waitFor(what string, done func() (bool, error)) error {
+	timeout := time.After(syncTimeout)
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for %s", what)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// ConnectNode establishes a persistent peer-to-peer connection from the "from"
+// node to the "to" node and waits until it is established.
+func ConnectNode(from *Node, to *Node) error {
+	cmd := btcjson.NewAddNodeCmd(to.P2PAddr, btcjson.ANAdd)
+	if err := from.Call(cmd, nil); err != nil {
+		return err
+	}
+	return waitFor("connection to "+to.P2PAddr, func() (bool, error) {
+		var peers []btcjson.GetPeerInfoResult
+		err := from.Call(btcjson.NewGetPeerInfoCmd(), &peers)
+		if err != nil {
+			return false, err
+		}
+		for _, peer := range peers {
+			if peer.Addr == to.P2PAddr {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// ConnectMesh connects every passed node to every other passed node.
+func ConnectMesh(nodes ...*Node) error {
+	for i, from := range nodes {
+		for _, to := range nodes[i+1:] {
+			if err := ConnectNode(from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SyncBlocks blocks until all passed nodes report the same best block.
+func SyncBlocks(nodes ...*Node) error {
+	return waitFor("blocks to sync", func() (bool, error) {
+		var bestHash string
+		for i, node := range nodes {
+			hash, _, err := node.BestBlock()
+			if err != nil {
+				return false, err
+			}
+			if i > 0 && hash.String() != bestHash {
+				return false, nil
+			}
+			bestHash = hash.String()
+		}
+		return true, nil
+	})
+}
+
+// SyncMempools blocks until all passed nodes have identical mempools.
+func SyncMempools(nodes ...*Node) error {
+	return waitFor("mempools to sync", func() (bool, error) {
+		var firstPool map[string]struct{}
+		for i, node := range nodes {
+			var txIDs []string
+			cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(false))
+			if err := node.Call(cmd, &txIDs); err != nil {
+				return false, err
+			}
+			pool := make(map[string]struct{}, len(txIDs))
+			for _, txID := range txIDs {
+				pool[txID] = struct{}{}
+			}
+			if i > 0 && !reflect.DeepEqual(pool, firstPool) {
+				return false, nil
+			}
+			firstPool = pool
+		}
+		return true, nil
+	})
+}
@@ -9,10 +9,12 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"strings"
 
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/provautil/base58"
+	"github.com/bitgo/prova/provautil/bech32"
 	"github.com/btcsuite/golangcrypto/ripemd160"
 )
 
@@ -36,15 +38,72 @@ var (
 	ErrAddressCollision = errors.New("address collision")
 )
 
-func encodeProvaAddress(keyIDs []btcec.KeyID, hash160 []byte, netID byte) string {
+// bech32ProvaVersion is the version of the Bech32 encoding of Prova addresses,
+// which is the first 5-bit value of the data.  Both standard and generalized
+// Prova addresses are encoded with it and are told apart by their size.
+const bech32ProvaVersion = 0
+
+func encodeProvaAddress(keyIDs []btcec.KeyID, hash160 []byte, netID byte, hrp string) string {
 	data := make([]byte, 2*btcec.KeyIDSize+ripemd160.Size)
 	copy(data[0:], hash160)
 	offset := ripemd160.Size
 	binary.LittleEndian.PutUint32(data[offset:], uint32(keyIDs[0]))
 	binary.LittleEndian.PutUint32(data[offset+btcec.KeyIDSize:], uint32(keyIDs[1]))
+	if hrp != "" {
+		return encodeBech32Address(data, hrp)
+	}
 	return base58.CheckEncode(data, netID)
 }
 
+// encodeBech32Address returns the Bech32 encoding of the passed address data
+// with the human-readable part.
+func encodeBech32Address(data []byte, hrp string) string {
+	conv, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return ""
+	}
+	encoded, err := bech32.Encode(hrp, append([]byte{bech32ProvaVersion},
+		conv...))
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// decodeBech32Address decodes a Bech32 encoded Prova address with a known
+// human-readable part.
+func decodeBech32Address(addr string, netID byte) (Address, error) {
+	hrp, data, err := bech32.Decode(addr)
+	if err != nil {
+		if err == bech32.ErrChecksum {
+			return nil, ErrChecksumMismatch
+		}
+		return nil, errors.New("decoded address is of unknown format")
+	}
+	if len(data) < 1 || data[0] != bech32ProvaVersion {
+		return nil, errors.New("decoded address is of unknown version")
+	}
+	decoded, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return nil, errors.New("decoded address is of unknown format")
+	}
+
+	if len(decoded) != ripemd160.Size+2*btcec.KeyIDSize {
+		generalAddr, err := newAddressGeneralProvaFromBytes(decoded, netID)
+		if err != nil {
+			return nil, err
+		}
+		generalAddr.hrp = hrp
+		return generalAddr, nil
+	}
+	provaAddr, err := newAddressProvaFromBytes(decoded, netID)
+	if err != nil {
+		return nil, err
+	}
+	provaAddr.hrp = hrp
+	return provaAddr, nil
+}
+
 // TODO(prova): Modify this interface to handle only Prova-form addresses. No need
 // to retain the old interface / address types.
 //
@@ -89,6 +148,16 @@ type Address interface {
 // When the address does not encode the network, such as in the case of a raw
 // public key, the address will be associated with the passed defaultNet.
 func DecodeAddress(addr string, defaultNet *chaincfg.Params) (Address, error) {
+	// Bech32 encoded addresses are prefixed by the human-readable part of a
+	// known network and the separator.  The separator is also a base58
+	// character, so the prefix must be known to tell the encodings apart.
+	if sep := strings.LastIndexByte(addr, '1'); sep > 0 {
+		netID, ok := chaincfg.Bech32ProvaAddrID(addr[:sep])
+		if ok {
+			return decodeBech32Address(addr, netID)
+		}
+	}
+
 	// Switch on decoded length to determine the type.
 	decoded, netID, err := base58.CheckDecode(addr)
 	if err != nil {
@@ -99,8 +168,10 @@ func DecodeAddress(addr string, defaultNet *chaincfg.Params) (Address, error) {
 	}
 
 	if chaincfg.IsProvaAddrID(netID) {
+		// Generalized addresses can never be the same size as standard
+		// 2-of-3 addresses, so the size determines the type.
 		if len(decoded) != ripemd160.Size+2*btcec.KeyIDSize {
-			return nil, errors.New("decoded address is of unknown size")
+			return newAddressGeneralProvaFromBytes(decoded, netID)
 		}
 		return newAddressProvaFromBytes(decoded, netID)
 	}
@@ -109,10 +180,14 @@ func DecodeAddress(addr string, defaultNet *chaincfg.Params) (Address, error) {
 }
 
 // AddressProva is a standard 2-of-3 Prova address
+//
+// Prova addresses are encoded with base58 unless they were decoded from their
+// Bech32 encoding or converted to it with Bech32.
 type AddressProva struct {
 	keyIDs [2]btcec.KeyID
 	hash   [ripemd160.Size]byte
 	netID  byte
+	hrp    string
 }
 
 // NewAddressProva returns a new AddressProva.  pkHash mustbe 20
@@ -157,7 +232,22 @@ func newAddressProvaFromBytes(data []byte, netID byte) (*AddressProva, error) {
 // EncodeAddress returns the string encoding of an Prova address.
 // Part of the Address interface.
 func (a *AddressProva) EncodeAddress() string {
-	return encodeProvaAddress(a.keyIDs[:], a.hash[:], a.netID)
+	return encodeProvaAddress(a.keyIDs[:], a.hash[:], a.netID, a.hrp)
+}
+
+// Bech32 returns a copy of the Prova address which is encoded with Bech32 for
+// the passed network.
+func (a *AddressProva) Bech32(net *chaincfg.Params) *AddressProva {
+	addr := *a
+	addr.hrp = strings.ToLower(net.Bech32HRPProva)
+	return &addr
+}
+
+// Base58 returns a copy of the Prova address which is encoded with base58.
+func (a *AddressProva) Base58() *AddressProva {
+	addr := *a
+	addr.hrp = ""
+	return &addr
 }
 
 // ScriptAddress returns the bytes to be included in a txout script for an Prova address.
@@ -174,6 +264,9 @@ func (a *AddressProva) ScriptKeyIDs() []btcec.KeyID {
 // IsForNet returns whether or not the Prova address is associated
 // with the passed bitcoin network.
 func (a *AddressProva) IsForNet(net *chaincfg.Params) bool {
+	if a.hrp != "" && a.hrp != strings.ToLower(net.Bech32HRPProva) {
+		return false
+	}
 	return a.netID == net.ProvaAddrID
 }
 
@@ -184,6 +277,200 @@ func (a *AddressProva) String() string {
 	return a.EncodeAddress()
 }
 
+// maxGeneralProvaKeys is the maximum number of keys a generalized Prova
+// address may have.  The number of keys in the script must be encoded as a
+// small integer.
+const maxGeneralProvaKeys = 16
+
+// AddressGeneralProva is a generalized m-of-n Prova address.  Funds may be
+// moved with signatures from nRequired of the keys, which consist of pubkey
+// hashes and the keyIDs of ASP keys.  Fewer pubkey hashes than required
+// signatures are allowed so that the funds can never be moved without the
+// cooperation of at least one ASP key, and at least as many keyIDs as required
+// signatures are needed so that the ASP keys may move the funds on their own.
+//
+// The encoded form is the number of required signatures and the number of
+// pubkey hashes, one byte each, followed by the pubkey hashes and then the
+// keyIDs.  It can never be the same size as a standard 2-of-3 Prova address.
+// Like standard Prova addresses, it may be encoded with base58 or Bech32.
+type AddressGeneralProva struct {
+	nRequired int
+	hashes    [][ripemd160.Size]byte
+	keyIDs    []btcec.KeyID
+	netID     byte
+	hrp       string
+}
+
+// NewAddressGeneralProva returns a new AddressGeneralProva requiring nRequired
+// signatures from the keys with the passed pubkey hashes and keyIDs.  Each of
+// the pubkey hashes must be 20 bytes.
+func NewAddressGeneralProva(nRequired int, pkHashes [][]byte, keyIDs []btcec.KeyID, net *chaincfg.Params) (*AddressGeneralProva, error) {
+	return newAddressGeneralProva(nRequired, pkHashes, keyIDs, net.ProvaAddrID)
+}
+
+// newAddressGeneralProva is the internal API to create a generalized Prova
+// address with a known leading identifier byte for a network, rather than
+// looking it up through its parameters.
+func newAddressGeneralProva(nRequired int, pkHashes [][]byte, keyIDs []btcec.KeyID, netID byte) (*AddressGeneralProva, error) {
+	numKeys := len(pkHashes) + len(keyIDs)
+	if numKeys < 3 || numKeys > maxGeneralProvaKeys {
+		return nil, errors.New("generalized Prova address must have " +
+			"between 3 and 16 keys")
+	}
+	if nRequired < 2 {
+		return nil, errors.New("generalized Prova address must require " +
+			"at least 2 signatures")
+	}
+	if len(pkHashes) >= nRequired {
+		return nil, errors.New("pkHashes must be fewer than the number " +
+			"of required signatures")
+	}
+	if len(keyIDs) < nRequired {
+		return nil, errors.New("keyIDs must be at least the number of " +
+			"required signatures")
+	}
+
+	addr := &AddressGeneralProva{
+		nRequired: nRequired,
+		hashes:    make([][ripemd160.Size]byte, len(pkHashes)),
+		keyIDs:    make([]btcec.KeyID, len(keyIDs)),
+		netID:     netID,
+	}
+	for i, pkHash := range pkHashes {
+		if len(pkHash) != ripemd160.Size {
+			return nil, errors.New("pkHash must be 20 bytes")
+		}
+		copy(addr.hashes[i][:], pkHash)
+	}
+	seen := make(map[btcec.KeyID]struct{}, len(keyIDs))
+	for i, keyID := range keyIDs {
+		if _, ok := seen[keyID]; ok {
+			return nil, errors.New("keyIDs must not contain duplicates")
+		}
+		seen[keyID] = struct{}{}
+		addr.keyIDs[i] = keyID
+	}
+	return addr, nil
+}
+
+// newAddressGeneralProvaFromBytes is the internal API to create a generalized
+// Prova address directly from the encoded bytes.
+func newAddressGeneralProvaFromBytes(data []byte, netID byte) (*AddressGeneralProva, error) {
+	if len(data) < 2 {
+		return nil, errors.New("decoded address is of unknown size")
+	}
+	nRequired := int(data[0])
+	numHashes := int(data[1])
+	offset := 2 + numHashes*ripemd160.Size
+	if len(data) < offset || (len(data)-offset)%btcec.KeyIDSize != 0 {
+		return nil, errors.New("decoded address is of unknown size")
+	}
+
+	pkHashes := make([][]byte, numHashes)
+	for i := range pkHashes {
+		start := 2 + i*ripemd160.Size
+		pkHashes[i] = data[start : start+ripemd160.Size]
+	}
+	keyIDs := make([]btcec.KeyID, 0, (len(data)-offset)/btcec.KeyIDSize)
+	for ; offset < len(data); offset += btcec.KeyIDSize {
+		keyIDs = append(keyIDs, btcec.KeyIDFromAddressBuffer(data[offset:]))
+	}
+	return newAddressGeneralProva(nRequired, pkHashes, keyIDs, netID)
+}
+
+// serialize returns the encoded bytes of a generalized Prova address without
+// the leading network identifier.
+func (a *AddressGeneralProva) serialize() []byte {
+	data := make([]byte, 2, 2+len(a.hashes)*ripemd160.Size+
+		len(a.keyIDs)*btcec.KeyIDSize)
+	data[0] = byte(a.nRequired)
+	data[1] = byte(len(a.hashes))
+	for i := range a.hashes {
+		data = append(data, a.hashes[i][:]...)
+	}
+	var buf [btcec.KeyIDSize]byte
+	for _, keyID := range a.keyIDs {
+		binary.LittleEndian.PutUint32(buf[:], uint32(keyID))
+		data = append(data, buf[:]...)
+	}
+	return data
+}
+
+// EncodeAddress returns the string encoding of a generalized Prova address.
+// Part of the Address interface.
+func (a *AddressGeneralProva) EncodeAddress() string {
+	if a.hrp != "" {
+		return encodeBech32Address(a.serialize(), a.hrp)
+	}
+	return base58.CheckEncode(a.serialize(), a.netID)
+}
+
+// Bech32 returns a copy of the generalized Prova address which is encoded with
+// Bech32 for the passed network.
+func (a *AddressGeneralProva) Bech32(net *chaincfg.Params) *AddressGeneralProva {
+	addr := *a
+	addr.hrp = strings.ToLower(net.Bech32HRPProva)
+	return &addr
+}
+
+// Base58 returns a copy of the generalized Prova address which is encoded with
+// base58.
+func (a *AddressGeneralProva) Base58() *AddressGeneralProva {
+	addr := *a
+	addr.hrp = ""
+	return &addr
+}
+
+// ScriptAddress returns the encoded bytes of the generalized Prova address.
+// Unlike a standard Prova address, the keys of a generalized address are not
+// represented by a single value in a txout script, so the bytes uniquely
+// identify the address instead.  Use PubKeyHashes and ScriptKeyIDs to obtain
+// the values included in a txout script.
+// Part of the Address interface.
+func (a *AddressGeneralProva) ScriptAddress() []byte {
+	return a.serialize()
+}
+
+// ScriptKeyIDs returns the key ids to be included in a txout script for a
+// generalized Prova address.
+// Part of the Address interface.
+func (a *AddressGeneralProva) ScriptKeyIDs() []btcec.KeyID {
+	return a.keyIDs
+}
+
+// PubKeyHashes returns the pubkey hashes to be included in a txout script for
+// a generalized Prova address.
+func (a *AddressGeneralProva) PubKeyHashes() [][]byte {
+	pkHashes := make([][]byte, len(a.hashes))
+	for i := range a.hashes {
+		pkHashes[i] = a.hashes[i][:]
+	}
+	return pkHashes
+}
+
+// RequiredSigs returns the number of signatures required to move funds sent to
+// a generalized Prova address.
+func (a *AddressGeneralProva) RequiredSigs() int {
+	return a.nRequired
+}
+
+// IsForNet returns whether or not the generalized Prova address is associated
+// with the passed bitcoin network.
+// Part of the Address interface.
+func (a *AddressGeneralProva) IsForNet(net *chaincfg.Params) bool {
+	if a.hrp != "" && a.hrp != strings.ToLower(net.Bech32HRPProva) {
+		return false
+	}
+	return a.netID == net.ProvaAddrID
+}
+
+// String returns a human-readable string for the generalized Prova address
+// type.  This is equivalent to calling EncodeAddress, but is provided so the
+// type can be used as a fmt.Stringer.
+func (a *AddressGeneralProva) String() string {
+	return a.EncodeAddress()
+}
+
 // AddressPubKeyHash is an Address for a pay-to-pubkey-hash (P2PKH)
 // transaction.
 type AddressPubKeyHash struct {
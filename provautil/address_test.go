@@ -9,8 +9,10 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
@@ -105,3 +107,156 @@ func TestAddresses(t *testing.T) {
 		}
 	}
 }
+
+// TestAddressGeneralProva ensures generalized Prova addresses round trip
+// through their string encoding and that invalid key combinations are
+// rejected.
+func TestAddressGeneralProva(t *testing.T) {
+	pkHash := func(b byte) []byte { return bytes.Repeat([]byte{b}, 20) }
+
+	tests := []struct {
+		name      string
+		nRequired int
+		pkHashes  [][]byte
+		keyIDs    []btcec.KeyID
+		valid     bool
+	}{
+		{"3 of 5", 3, [][]byte{pkHash(1), pkHash(2)},
+			[]btcec.KeyID{1, 2, 0x10000}, true},
+		{"2 of 3 with only keyIDs", 2, nil,
+			[]btcec.KeyID{1, 2, 3}, true},
+		{"2 of 3 standard form", 2, [][]byte{pkHash(1)},
+			[]btcec.KeyID{1, 2}, true},
+		{"too few keys", 2, nil, []btcec.KeyID{1, 2}, false},
+		{"too many keys", 2, nil, []btcec.KeyID{1, 2, 3, 4, 5, 6, 7, 8,
+			9, 10, 11, 12, 13, 14, 15, 16, 17}, false},
+		{"single signature", 1, [][]byte{pkHash(1)},
+			[]btcec.KeyID{1, 2}, false},
+		{"as many pkHashes as signatures", 2, [][]byte{pkHash(1),
+			pkHash(2)}, []btcec.KeyID{1, 2}, false},
+		{"fewer keyIDs than signatures", 3, [][]byte{pkHash(1),
+			pkHash(2)}, []btcec.KeyID{1, 2}, false},
+		{"duplicate keyIDs", 2, [][]byte{pkHash(1)},
+			[]btcec.KeyID{1, 1}, false},
+		{"bad pkHash size", 2, [][]byte{pkHash(1)[:19]},
+			[]btcec.KeyID{1, 2}, false},
+	}
+
+	for _, test := range tests {
+		addr, err := provautil.NewAddressGeneralProva(test.nRequired,
+			test.pkHashes, test.keyIDs, &chaincfg.TestNetParams)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+
+		decoded, err := provautil.DecodeAddress(addr.EncodeAddress(),
+			&chaincfg.TestNetParams)
+		if err != nil {
+			t.Errorf("%s: failed to decode %v: %v", test.name, addr, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, addr) {
+			t.Errorf("%s: decoded address %#v does not match %#v",
+				test.name, decoded, addr)
+			continue
+		}
+		if !decoded.IsForNet(&chaincfg.TestNetParams) {
+			t.Errorf("%s: decoded address is not for the expected "+
+				"network", test.name)
+		}
+	}
+}
+
+// TestAddressBech32 ensures Prova addresses round trip through their Bech32
+// encoding, are only valid for the network of their human-readable part, and
+// keep the script values of their base58 encoding.
+func TestAddressBech32(t *testing.T) {
+	pkHash := bytes.Repeat([]byte{0x42}, 20)
+	base58Addr, err := provautil.NewAddressProva(pkHash,
+		[]btcec.KeyID{1, 0x10000}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	generalAddr, err := provautil.NewAddressGeneralProva(3,
+		[][]byte{pkHash, pkHash}, []btcec.KeyID{1, 2, 3},
+		&chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressGeneralProva: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		addr provautil.Address
+	}{
+		{"standard", base58Addr.Bech32(&chaincfg.TestNetParams)},
+		{"generalized", generalAddr.Bech32(&chaincfg.TestNetParams)},
+	}
+	for _, test := range tests {
+		encoded := test.addr.EncodeAddress()
+		if !strings.HasPrefix(encoded, "tprova1") {
+			t.Errorf("%s: encoded address %s has wrong prefix",
+				test.name, encoded)
+			continue
+		}
+		decoded, err := provautil.DecodeAddress(encoded,
+			&chaincfg.TestNetParams)
+		if err != nil {
+			t.Errorf("%s: failed to decode %s: %v", test.name, encoded,
+				err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, test.addr) {
+			t.Errorf("%s: decoded address %#v does not match %#v",
+				test.name, decoded, test.addr)
+			continue
+		}
+		if decoded.EncodeAddress() != encoded {
+			t.Errorf("%s: round trip produced %s, want %s", test.name,
+				decoded.EncodeAddress(), encoded)
+		}
+
+		// Uppercase strings are equally valid.
+		upper, err := provautil.DecodeAddress(strings.ToUpper(encoded),
+			&chaincfg.TestNetParams)
+		if err != nil || upper.EncodeAddress() != encoded {
+			t.Errorf("%s: failed to decode uppercase address: %v",
+				test.name, err)
+		}
+
+		// The regression test network shares the base58 identifier of
+		// the test network, but not its human-readable part.
+		if !decoded.IsForNet(&chaincfg.TestNetParams) {
+			t.Errorf("%s: address not for the test network", test.name)
+		}
+		if decoded.IsForNet(&chaincfg.RegressionNetParams) {
+			t.Errorf("%s: address for the regression test network",
+				test.name)
+		}
+
+		// A single changed character fails the checksum.
+		corrupt := []byte(encoded)
+		if corrupt[10] == 'q' {
+			corrupt[10] = 'p'
+		} else {
+			corrupt[10] = 'q'
+		}
+		_, err = provautil.DecodeAddress(string(corrupt),
+			&chaincfg.TestNetParams)
+		if err != provautil.ErrChecksumMismatch {
+			t.Errorf("%s: got error %v for corrupt address, want %v",
+				test.name, err, provautil.ErrChecksumMismatch)
+		}
+	}
+
+	if !bytes.Equal(tests[0].addr.ScriptAddress(), base58Addr.ScriptAddress()) {
+		t.Error("script address differs from base58 encoding")
+	}
+	converted := base58Addr.Bech32(&chaincfg.TestNetParams).Base58()
+	if !reflect.DeepEqual(converted, base58Addr) {
+		t.Errorf("Base58: got %#v, want %#v", converted, base58Addr)
+	}
+}
@@ -7,8 +7,10 @@ package provautil
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // AmountUnit describes a method of converting an Amount to something
@@ -47,6 +49,14 @@ func (u AmountUnit) String() string {
 	}
 }
 
+// MaxAmountDecimals is the number of decimal places of an amount in RMG which
+// can be represented by an Amount, as an Atom is 1e-6 of a gram.
+const MaxAmountDecimals = 6
+
+// ErrAmountOverflow describes an error in which an amount, or the result of an
+// operation on amounts, is too large to be represented by an Amount.
+var ErrAmountOverflow = errors.New("amount overflows")
+
 // Amount represents the base monetary unit (colloquially referred to as an
 // 'Atom').  A single Amount is equal to 1e-6 of a gram.
 type Amount int64
@@ -83,7 +93,79 @@ func NewAmount(f float64) (Amount, error) {
 		return 0, errors.New("invalid amount")
 	}
 
-	return round(f * AtomsPerGram), nil
+	// Values beyond the range of an int64 would silently wrap when
+	// converted.
+	atoms := f * AtomsPerGram
+	if atoms >= math.MaxInt64 || atoms <= math.MinInt64 {
+		return 0, ErrAmountOverflow
+	}
+	return round(atoms), nil
+}
+
+// atomsPerDecimal returns the number of Atoms in the smallest unit of an amount
+// in RMG with the passed number of decimal places.
+func atomsPerDecimal(decimals uint8) int64 {
+	atoms := int64(1)
+	for i := decimals; i < MaxAmountDecimals; i++ {
+		atoms *= 10
+	}
+	return atoms
+}
+
+// ParseAmount parses an amount in RMG from its decimal string representation,
+// such as "-12.5", with at most the passed number of decimal places.  Unlike
+// NewAmount, the amount is parsed exactly, without going through a floating
+// point value.  Networks define the number of decimal places of their amounts
+// in their chain parameters.
+func ParseAmount(s string, decimals uint8) (Amount, error) {
+	if decimals > MaxAmountDecimals {
+		return 0, fmt.Errorf("amounts have at most %d decimal places",
+			MaxAmountDecimals)
+	}
+
+	str := s
+	negative := strings.HasPrefix(str, "-")
+	if negative || strings.HasPrefix(str, "+") {
+		str = str[1:]
+	}
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i != -1 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	for _, digits := range []string{intPart, fracPart} {
+		for i := 0; i < len(digits); i++ {
+			if digits[i] < '0' || digits[i] > '9' {
+				return 0, fmt.Errorf("invalid amount %q", s)
+			}
+		}
+	}
+	if len(fracPart) > int(decimals) {
+		return 0, fmt.Errorf("amount %q has more than %d decimal "+
+			"places", s, decimals)
+	}
+
+	// Accumulate the Atoms as a negative value, which also allows the
+	// smallest Amount to be parsed.
+	var atoms int64
+	digits := intPart + fracPart + strings.Repeat("0",
+		MaxAmountDecimals-len(fracPart))
+	for i := 0; i < len(digits); i++ {
+		digit := int64(digits[i] - '0')
+		if atoms < (math.MinInt64+digit)/10 {
+			return 0, ErrAmountOverflow
+		}
+		atoms = atoms*10 - digit
+	}
+	if !negative {
+		if atoms == math.MinInt64 {
+			return 0, ErrAmountOverflow
+		}
+		atoms = -atoms
+	}
+	return Amount(atoms), nil
 }
 
 // ToUnit converts a monetary amount counted in gram base units to a
@@ -111,6 +193,65 @@ func (a Amount) String() string {
 	return a.Format(AmountRMG)
 }
 
+// FormatRMG formats the amount in RMG exactly, without a unit, with at least
+// the passed number of decimal places and as many more as are needed to
+// represent the amount.  The result is parsed back by ParseAmount when the
+// amount has no more decimal places than a network allows.
+func (a Amount) FormatRMG(decimals uint8) string {
+	if decimals > MaxAmountDecimals {
+		decimals = MaxAmountDecimals
+	}
+
+	// The magnitude of the smallest Amount only fits in a uint64.
+	magnitude := uint64(a)
+	sign := ""
+	if a < 0 {
+		magnitude = -magnitude
+		sign = "-"
+	}
+	intPart := strconv.FormatUint(magnitude/AtomsPerGram, 10)
+	fracPart := strconv.FormatUint(magnitude%AtomsPerGram, 10)
+	fracPart = strings.Repeat("0", MaxAmountDecimals-len(fracPart)) +
+		fracPart
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) < int(decimals) {
+		fracPart += strings.Repeat("0", int(decimals)-len(fracPart))
+	}
+	if fracPart == "" {
+		return sign + intPart
+	}
+	return sign + intPart + "." + fracPart
+}
+
+// FitsDecimals returns whether the amount in RMG has no more than the passed
+// number of decimal places.
+func (a Amount) FitsDecimals(decimals uint8) bool {
+	if decimals >= MaxAmountDecimals {
+		return true
+	}
+	return int64(a)%atomsPerDecimal(decimals) == 0
+}
+
+// Add returns the sum of the amount and b.  ErrAmountOverflow is returned
+// instead of silently wrapping when the sum can't be represented.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrAmountOverflow
+	}
+	return sum, nil
+}
+
+// Sub returns the amount minus b.  ErrAmountOverflow is returned instead of
+// silently wrapping when the difference can't be represented.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return 0, ErrAmountOverflow
+	}
+	return diff, nil
+}
+
 // MulF64 multiplies an Amount by a floating point value.  While this is not
 // an operation that must typically be done by a full node or wallet, it is
 // useful for services that build on top of bitcoin (for example, calculating
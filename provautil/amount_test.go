@@ -91,6 +91,16 @@ func TestAmountCreation(t *testing.T) {
 			amount: math.Inf(1),
 			valid:  false,
 		},
+		{
+			name:   "overflows",
+			amount: 1e13,
+			valid:  false,
+		},
+		{
+			name:   "underflows",
+			amount: -1e13,
+			valid:  false,
+		},
 	}
 
 	for _, test := range tests {
@@ -300,3 +310,346 @@ func TestAmountMulF64(t *testing.T) {
 		}
 	}
 }
+
+func TestAmountParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		str      string
+		decimals uint8
+		valid    bool
+		expected Amount
+	}{
+		// Positive tests.
+		{
+			name:     "zero",
+			str:      "0",
+			decimals: 6,
+			valid:    true,
+			expected: 0,
+		},
+		{
+			name:     "integer",
+			str:      "100",
+			decimals: 0,
+			valid:    true,
+			expected: 100 * AtomsPerGram,
+		},
+		{
+			name:     "fraction",
+			str:      "0.123456",
+			decimals: 6,
+			valid:    true,
+			expected: 123456,
+		},
+		{
+			name:     "no integer part",
+			str:      ".5",
+			decimals: 1,
+			valid:    true,
+			expected: 500000,
+		},
+		{
+			name:     "no fractional part",
+			str:      "5.",
+			decimals: 0,
+			valid:    true,
+			expected: 5 * AtomsPerGram,
+		},
+		{
+			name:     "signed",
+			str:      "+1.5",
+			decimals: 2,
+			valid:    true,
+			expected: 1500000,
+		},
+		{
+			name:     "negative",
+			str:      "-21000000.000001",
+			decimals: 6,
+			valid:    true,
+			expected: -21e12 - 1,
+		},
+		{
+			name:     "max amount",
+			str:      "9223372036854.775807",
+			decimals: 6,
+			valid:    true,
+			expected: math.MaxInt64,
+		},
+		{
+			name:     "min amount",
+			str:      "-9223372036854.775808",
+			decimals: 6,
+			valid:    true,
+			expected: math.MinInt64,
+		},
+		{
+			name:     "beyond float precision",
+			str:      "9007199254.740993",
+			decimals: 6,
+			valid:    true,
+			expected: 9007199254740993,
+		},
+
+		// Negative tests.
+		{
+			name:     "empty",
+			str:      "",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "sign only",
+			str:      "-",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "point only",
+			str:      ".",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "invalid digit",
+			str:      "1e6",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "two points",
+			str:      "1.2.3",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "too many decimal places",
+			str:      "0.001",
+			decimals: 2,
+			valid:    false,
+		},
+		{
+			name:     "more decimal places than an atom",
+			str:      "1",
+			decimals: 7,
+			valid:    false,
+		},
+		{
+			name:     "overflows",
+			str:      "9223372036854.775808",
+			decimals: 6,
+			valid:    false,
+		},
+		{
+			name:     "underflows",
+			str:      "-9223372036854.775809",
+			decimals: 6,
+			valid:    false,
+		},
+	}
+
+	for _, test := range tests {
+		a, err := ParseAmount(test.str, test.decimals)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("%v: Positive test Amount parsing failed with: %v", test.name, err)
+			continue
+		case !test.valid && err == nil:
+			t.Errorf("%v: Negative test Amount parsing succeeded (value %v) when should fail", test.name, a)
+			continue
+		}
+
+		if a != test.expected {
+			t.Errorf("%v: Parsed amount %v does not match expected %v", test.name, a, test.expected)
+			continue
+		}
+	}
+}
+
+func TestAmountFormatRMG(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Amount
+		decimals uint8
+		expected string
+	}{
+		{
+			name:     "zero",
+			amount:   0,
+			decimals: 0,
+			expected: "0",
+		},
+		{
+			name:     "zero with decimal places",
+			amount:   0,
+			decimals: 2,
+			expected: "0.00",
+		},
+		{
+			name:     "atom",
+			amount:   1,
+			decimals: 0,
+			expected: "0.000001",
+		},
+		{
+			name:     "trailing zeros trimmed",
+			amount:   1500000,
+			decimals: 0,
+			expected: "1.5",
+		},
+		{
+			name:     "trailing zeros kept",
+			amount:   1500000,
+			decimals: 3,
+			expected: "1.500",
+		},
+		{
+			name:     "decimal places capped",
+			amount:   1500000,
+			decimals: 9,
+			expected: "1.500000",
+		},
+		{
+			name:     "negative",
+			amount:   -21e12 - 1,
+			decimals: 2,
+			expected: "-21000000.000001",
+		},
+		{
+			name:     "max amount",
+			amount:   math.MaxInt64,
+			decimals: 0,
+			expected: "9223372036854.775807",
+		},
+		{
+			name:     "min amount",
+			amount:   math.MinInt64,
+			decimals: 0,
+			expected: "-9223372036854.775808",
+		},
+	}
+
+	for _, test := range tests {
+		s := test.amount.FormatRMG(test.decimals)
+		if s != test.expected {
+			t.Errorf("%v: Formatted amount %v does not match expected %v", test.name, s, test.expected)
+			continue
+		}
+
+		// The formatted amount must parse back to the same amount.
+		a, err := ParseAmount(s, MaxAmountDecimals)
+		if err != nil || a != test.amount {
+			t.Errorf("%v: Formatted amount %v parses to %v (%v)", test.name, s, a, err)
+		}
+	}
+}
+
+func TestAmountFitsDecimals(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		decimals uint8
+		fits     bool
+	}{
+		{0, 0, true},
+		{1, 6, true},
+		{1, 5, false},
+		{1, 9, true},
+		{1500000, 1, true},
+		{1500000, 0, false},
+		{-1500000, 1, true},
+		{-1510000, 1, false},
+		{math.MinInt64, 0, false},
+	}
+
+	for _, test := range tests {
+		fits := test.amount.FitsDecimals(test.decimals)
+		if fits != test.fits {
+			t.Errorf("%v with %d decimal places: expected %v got %v", test.amount, test.decimals, test.fits, fits)
+		}
+	}
+}
+
+func TestAmountAddSub(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     Amount
+		b     Amount
+		sum   Amount
+		diff  Amount
+		sumOK bool
+		difOK bool
+	}{
+		{
+			name:  "small amounts",
+			a:     3,
+			b:     2,
+			sum:   5,
+			diff:  1,
+			sumOK: true,
+			difOK: true,
+		},
+		{
+			name:  "negative result",
+			a:     2,
+			b:     3,
+			sum:   5,
+			diff:  -1,
+			sumOK: true,
+			difOK: true,
+		},
+		{
+			name:  "max amount",
+			a:     math.MaxInt64,
+			b:     1,
+			diff:  math.MaxInt64 - 1,
+			sumOK: false,
+			difOK: true,
+		},
+		{
+			name:  "min amount",
+			a:     math.MinInt64,
+			b:     1,
+			sumOK: true,
+			sum:   math.MinInt64 + 1,
+			difOK: false,
+		},
+		{
+			name:  "min amount subtracted",
+			a:     0,
+			b:     math.MinInt64,
+			sum:   math.MinInt64,
+			sumOK: true,
+			difOK: false,
+		},
+		{
+			name:  "large issuances",
+			a:     math.MaxInt64 / 2,
+			b:     math.MaxInt64/2 + 2,
+			diff:  -2,
+			sumOK: false,
+			difOK: true,
+		},
+	}
+
+	for _, test := range tests {
+		sum, err := test.a.Add(test.b)
+		switch {
+		case test.sumOK && err != nil:
+			t.Errorf("%v: Add failed with: %v", test.name, err)
+		case !test.sumOK && err != ErrAmountOverflow:
+			t.Errorf("%v: Add returned %v (%v) when it should overflow", test.name, sum, err)
+		case test.sumOK && sum != test.sum:
+			t.Errorf("%v: Add expected %v got %v", test.name, test.sum, sum)
+		}
+
+		diff, err := test.a.Sub(test.b)
+		switch {
+		case test.difOK && err != nil:
+			t.Errorf("%v: Sub failed with: %v", test.name, err)
+		case !test.difOK && err != ErrAmountOverflow:
+			t.Errorf("%v: Sub returned %v (%v) when it should overflow", test.name, diff, err)
+		case test.difOK && diff != test.diff:
+			t.Errorf("%v: Sub expected %v got %v", test.name, test.diff, diff)
+		}
+	}
+}
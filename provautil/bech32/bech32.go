@@ -0,0 +1,186 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bech32
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// charset is the alphabet of the data part of Bech32 strings.
+	charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// checksumLen is the number of characters of the checksum.
+	checksumLen = 6
+
+	// MaxLength is the maximum length of a Bech32 string.
+	MaxLength = 1023
+)
+
+var (
+	// ErrChecksum indicates that the checksum of a Bech32 string does not
+	// verify against its contents.
+	ErrChecksum = errors.New("checksum error")
+
+	// ErrInvalidFormat indicates that a string is not a well-formed Bech32
+	// string.
+	ErrInvalidFormat = errors.New("invalid format")
+
+	// ErrInvalidPadding indicates that the data of a Bech32 string has a
+	// non-zero or excessive padding when converted back to bytes.
+	ErrInvalidPadding = errors.New("invalid padding")
+)
+
+// generator holds the generator coefficients of the BCH code of the checksum.
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd,
+	0x2a1462b3}
+
+// charsetRev maps the characters of the alphabet to their values.
+var charsetRev [128]int8
+
+func init() {
+	for i := range charsetRev {
+		charsetRev[i] = -1
+	}
+	for i, c := range charset {
+		charsetRev[c] = int8(i)
+	}
+}
+
+// polymod returns the remainder of the checksum polynomial of the passed
+// values.
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// hrpExpand returns the values of the human-readable part which the checksum
+// commits to.
+func hrpExpand(hrp string) []byte {
+	v := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]>>5)
+	}
+	v = append(v, 0)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]&31)
+	}
+	return v
+}
+
+// createChecksum returns the checksum of the human-readable part and data.
+func createChecksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, make([]byte, checksumLen)...)
+	mod := polymod(values) ^ 1
+	checksum := make([]byte, checksumLen)
+	for i := range checksum {
+		checksum[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// Encode returns the Bech32 string of the passed human-readable part and data,
+// which consists of 5-bit values such as those returned by ConvertBits.
+func Encode(hrp string, data []byte) (string, error) {
+	if len(hrp) < 1 || len(hrp)+1+len(data)+checksumLen > MaxLength {
+		return "", ErrInvalidFormat
+	}
+	hrp = strings.ToLower(hrp)
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", ErrInvalidFormat
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(hrp) + 1 + len(data) + checksumLen)
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data {
+		if v >= 32 {
+			return "", ErrInvalidFormat
+		}
+		sb.WriteByte(charset[v])
+	}
+	for _, v := range createChecksum(hrp, data) {
+		sb.WriteByte(charset[v])
+	}
+	return sb.String(), nil
+}
+
+// Decode decodes a Bech32 string and returns its lowercase human-readable part
+// and its data as 5-bit values, without the checksum.
+func Decode(s string) (string, []byte, error) {
+	if len(s) < 1+1+checksumLen || len(s) > MaxLength {
+		return "", nil, ErrInvalidFormat
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, ErrInvalidFormat
+	}
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+1+checksumLen > len(lower) {
+		return "", nil, ErrInvalidFormat
+	}
+	hrp := lower[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, ErrInvalidFormat
+		}
+	}
+
+	data := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		c := lower[i]
+		if c >= 128 || charsetRev[c] == -1 {
+			return "", nil, ErrInvalidFormat
+		}
+		data = append(data, byte(charsetRev[c]))
+	}
+	if polymod(append(hrpExpand(hrp), data...)) != 1 {
+		return "", nil, ErrChecksum
+	}
+	return hrp, data[:len(data)-checksumLen], nil
+}
+
+// ConvertBits regroups the bits of the passed values from fromBits to toBits
+// bits per value.  When pad is true, the last value is padded with zero bits,
+// otherwise the remaining bits must be zero and fewer than fromBits.
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxV := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, ErrInvalidFormat
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxV))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxV))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxV != 0 {
+		return nil, ErrInvalidPadding
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bech32_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bitgo/prova/provautil/bech32"
+)
+
+// TestBech32 ensures the checksum test vectors of BIP0173 decode and encode
+// back to the same strings, and that malformed strings are rejected.
+func TestBech32(t *testing.T) {
+	tests := []struct {
+		str string
+		err error
+	}{
+		{"A12UEL5L", nil},
+		{"a12uel5l", nil},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", nil},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", nil},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", nil},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", nil},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e2w", bech32.ErrChecksum},
+		{"A12uEL5L", bech32.ErrInvalidFormat},
+		{"pzry9x0s0muk", bech32.ErrInvalidFormat},
+		{"1pzry9x0s0muk", bech32.ErrInvalidFormat},
+		{"x1b4n0q5v", bech32.ErrInvalidFormat},
+		{"li1dgmt3", bech32.ErrInvalidFormat},
+	}
+
+	for i, test := range tests {
+		hrp, data, err := bech32.Decode(test.str)
+		if err != test.err {
+			t.Errorf("Decode #%d (%s): got error %v, want %v", i,
+				test.str, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		encoded, err := bech32.Encode(hrp, data)
+		if err != nil {
+			t.Errorf("Encode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if encoded != strings.ToLower(test.str) {
+			t.Errorf("Encode #%d: got %s, want %s", i, encoded,
+				strings.ToLower(test.str))
+		}
+	}
+}
+
+// TestConvertBits ensures bytes survive a round trip through 5-bit values and
+// that non-zero padding is rejected.
+func TestConvertBits(t *testing.T) {
+	in := []byte{0x00, 0x14, 0x75, 0x1e, 0x76, 0xe8, 0x19, 0x91, 0x96}
+	conv, err := bech32.ConvertBits(in, 8, 5, true)
+	if err != nil {
+		t.Fatalf("ConvertBits: unexpected error: %v", err)
+	}
+	out, err := bech32.ConvertBits(conv, 5, 8, false)
+	if err != nil {
+		t.Fatalf("ConvertBits: unexpected error: %v", err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Fatalf("ConvertBits: got %x, want %x", out, in)
+	}
+
+	conv[len(conv)-1] |= 1
+	if _, err := bech32.ConvertBits(conv, 5, 8, false); err != bech32.ErrInvalidPadding {
+		t.Fatalf("ConvertBits: got error %v, want %v", err,
+			bech32.ErrInvalidPadding)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bech32 provides an API for working with the Bech32 encoding.
+
+Bech32 Encoding
+
+A Bech32 string consists of a human-readable part, the separator character 1,
+and a data part of characters from a 32 character alphabet, the last six of
+which are a checksum.  The alphabet omits the 1, b, i and o characters, and
+strings are either all lowercase or all uppercase, which makes them easier to
+read out and transcribe than base58 strings.  The checksum is a BCH code which
+is guaranteed to detect any error affecting up to four characters of strings
+of up to 90 characters, and which commits to the human-readable part, so a
+string for one network can not be mistaken for a string for another.
+
+Unlike the encoding of segregated witness addresses, this package allows
+strings of up to 1023 characters, the length of the BCH code, since encoded
+Prova addresses with many keys exceed 90 characters.  The guarantees of the
+checksum are weaker for such strings, though it still detects most errors.
+*/
+package bech32
@@ -6,28 +6,55 @@
 package bloom
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
 )
 
+// merkleTree describes the shape of the merkle tree of a block.  The tree
+// commits to the hashes of the transactions without their signatures followed
+// by their hashes with signatures.  Each half has as many leaves as the next
+// power of two of the number of transactions, so the halves are the children
+// of the root.  Leaves past the transactions are empty, as are the nodes
+// without a left child, and nodes without a right child hash their left child
+// with itself.
+type merkleTree struct {
+	numTx     uint32
+	halfWidth uint32
+	height    uint32
+}
+
+// newMerkleTree returns the shape of the merkle tree of a block with the
+// passed number of transactions.
+func newMerkleTree(numTx uint32) merkleTree {
+	t := merkleTree{numTx: numTx, halfWidth: 1, height: 1}
+	for t.halfWidth < numTx {
+		t.halfWidth <<= 1
+		t.height++
+	}
+	return t
+}
+
+// hasNode returns whether the node at the given depth-first height and
+// position is not empty.
+func (t *merkleTree) hasNode(height, pos uint32) bool {
+	return (pos<<height)&(t.halfWidth-1) < t.numTx
+}
+
 // merkleBlock is used to house intermediate information needed to generate a
 // wire.MsgMerkleBlock according to a filter.
 type merkleBlock struct {
-	numTx       uint32
+	tree        merkleTree
 	allHashes   []*chainhash.Hash
 	finalHashes []*chainhash.Hash
 	matchedBits []byte
 	bits        []byte
 }
 
-// calcTreeWidth calculates and returns the the number of nodes (width) or a
-// merkle tree at the given depth-first height.
-func (m *merkleBlock) calcTreeWidth(height uint32) uint32 {
-	return (m.numTx + (1 << height) - 1) >> height
-}
-
 // calcHash returns the hash for a sub-tree given a depth-first height and
 // node position.
 func (m *merkleBlock) calcHash(height, pos uint32) *chainhash.Hash {
@@ -37,7 +64,7 @@ func (m *merkleBlock) calcHash(height, pos uint32) *chainhash.Hash {
 
 	var right *chainhash.Hash
 	left := m.calcHash(height-1, pos*2)
-	if pos*2+1 < m.calcTreeWidth(height-1) {
+	if m.tree.hasNode(height-1, pos*2+1) {
 		right = m.calcHash(height-1, pos*2+1)
 	} else {
 		right = left
@@ -52,7 +79,7 @@ func (m *merkleBlock) calcHash(height, pos uint32) *chainhash.Hash {
 func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 	// Determine whether this node is a parent of a matched node.
 	var isParent byte
-	for i := pos << height; i < (pos+1)<<height && i < m.numTx; i++ {
+	for i := pos << height; i < (pos+1)<<height; i++ {
 		isParent |= m.matchedBits[i]
 	}
 	m.bits = append(m.bits, isParent)
@@ -73,7 +100,7 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 
 	// Descend into the right child and process its sub-tree if
 	// there is one.
-	if pos*2+1 < m.calcTreeWidth(height-1) {
+	if m.tree.hasNode(height-1, pos*2+1) {
 		m.traverseAndBuild(height-1, pos*2+1)
 	}
 }
@@ -81,38 +108,54 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 // NewMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
 // transaction index numbers based on the passed block and filter.
 func NewMerkleBlock(block *provautil.Block, filter *Filter) (*wire.MsgMerkleBlock, []uint32) {
+	return newMerkleBlock(block, filter.MatchTxAndUpdate)
+}
+
+// NewMerkleBlockWithTxs returns a new *wire.MsgMerkleBlock proving the
+// inclusion of the transactions with the passed hashes in the passed block,
+// and an array of their index numbers in the block.  Hashes of transactions
+// which are not in the block are ignored.
+func NewMerkleBlockWithTxs(block *provautil.Block, txHashes []*chainhash.Hash) (*wire.MsgMerkleBlock, []uint32) {
+	match := make(map[chainhash.Hash]struct{}, len(txHashes))
+	for _, txHash := range txHashes {
+		match[*txHash] = struct{}{}
+	}
+	return newMerkleBlock(block, func(tx *provautil.Tx) bool {
+		_, ok := match[*tx.Hash()]
+		return ok
+	})
+}
+
+// newMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
+// transaction index numbers based on the passed block and match function.
+func newMerkleBlock(block *provautil.Block, matchTx func(*provautil.Tx) bool) (*wire.MsgMerkleBlock, []uint32) {
 	numTx := uint32(len(block.Transactions()))
+	tree := newMerkleTree(numTx)
 	mBlock := merkleBlock{
-		numTx:       numTx,
-		allHashes:   make([]*chainhash.Hash, 0, numTx),
-		matchedBits: make([]byte, 0, numTx),
+		tree:        tree,
+		allHashes:   make([]*chainhash.Hash, tree.halfWidth*2),
+		matchedBits: make([]byte, tree.halfWidth*2),
 	}
 
-	// Find and keep track of any transactions that match the filter.
+	// Find and keep track of any transactions that match.  Only the
+	// hashes without signatures are matched.
 	var matchedIndices []uint32
 	for txIndex, tx := range block.Transactions() {
-		if filter.MatchTxAndUpdate(tx) {
-			mBlock.matchedBits = append(mBlock.matchedBits, 0x01)
+		if matchTx(tx) {
+			mBlock.matchedBits[txIndex] = 0x01
 			matchedIndices = append(matchedIndices, uint32(txIndex))
-		} else {
-			mBlock.matchedBits = append(mBlock.matchedBits, 0x00)
 		}
-		mBlock.allHashes = append(mBlock.allHashes, tx.Hash())
-	}
-
-	// Calculate the number of merkle branches (height) in the tree.
-	height := uint32(0)
-	for mBlock.calcTreeWidth(height) > 1 {
-		height++
+		mBlock.allHashes[txIndex] = tx.Hash()
+		mBlock.allHashes[tree.halfWidth+uint32(txIndex)] = tx.HashWithSig()
 	}
 
 	// Build the depth-first partial merkle tree.
-	mBlock.traverseAndBuild(height, 0)
+	mBlock.traverseAndBuild(tree.height, 0)
 
 	// Create and return the merkle block.
 	msgMerkleBlock := wire.MsgMerkleBlock{
 		Header:       block.MsgBlock().Header,
-		Transactions: uint32(mBlock.numTx),
+		Transactions: numTx,
 		Hashes:       make([]*chainhash.Hash, 0, len(mBlock.finalHashes)),
 		Flags:        make([]byte, (len(mBlock.bits)+7)/8),
 	}
@@ -124,3 +167,114 @@ func NewMerkleBlock(block *provautil.Block, filter *Filter) (*wire.MsgMerkleBloc
 	}
 	return &msgMerkleBlock, matchedIndices
 }
+
+// merkleBlockParser is used to house intermediate information needed to
+// extract the matched transactions of a wire.MsgMerkleBlock.
+type merkleBlockParser struct {
+	tree           merkleTree
+	hashes         []*chainhash.Hash
+	flags          []byte
+	bitsUsed       uint32
+	hashesUsed     uint32
+	matchedHashes  []*chainhash.Hash
+	matchedIndices []uint32
+	bad            bool
+}
+
+// traverseAndExtract rebuilds the hash of a sub-tree given a depth-first
+// height and node position from the hashes and flags of the merkle block, the
+// same way traverseAndBuild produced them.  It also collects the matched leaf
+// nodes along the way and flags the merkle block as bad when it runs out of
+// hashes or flags.
+func (p *merkleBlockParser) traverseAndExtract(height, pos uint32) *chainhash.Hash {
+	if p.bitsUsed >= uint32(len(p.flags))*8 {
+		p.bad = true
+		return &chainhash.Hash{}
+	}
+	isParent := (p.flags[p.bitsUsed/8] >> (p.bitsUsed % 8)) & 0x01
+	p.bitsUsed++
+
+	// Leaf nodes and nodes which are not the parent of a matched node
+	// come with their hash.  Only the hashes without signatures can be
+	// matched.
+	if height == 0 || isParent == 0x00 {
+		if p.hashesUsed >= uint32(len(p.hashes)) {
+			p.bad = true
+			return &chainhash.Hash{}
+		}
+		hash := p.hashes[p.hashesUsed]
+		p.hashesUsed++
+		if height == 0 && isParent != 0x00 {
+			if pos >= p.tree.halfWidth {
+				p.bad = true
+			}
+			p.matchedHashes = append(p.matchedHashes, hash)
+			p.matchedIndices = append(p.matchedIndices, pos)
+		}
+		return hash
+	}
+
+	// Descend into the children.  Identical children would allow the
+	// same merkle root for different transaction lists, so they are
+	// rejected like blocks with duplicated transactions.
+	left := p.traverseAndExtract(height-1, pos*2)
+	right := left
+	if p.tree.hasNode(height-1, pos*2+1) {
+		right = p.traverseAndExtract(height-1, pos*2+1)
+		if right.IsEqual(left) {
+			p.bad = true
+		}
+	}
+	return blockchain.HashMerkleBranches(left, right)
+}
+
+// MerkleBlockMatches returns the hashes of the transactions the passed merkle
+// block proves the inclusion of, along with their index numbers in the block.
+// An error is returned when the partial merkle tree of the merkle block is
+// malformed or does not hash to the merkle root of its header.
+//
+// Note that the signature of the header is not checked, so the merkle block
+// only proves the inclusion of the transactions in a block which is otherwise
+// known to be valid.
+func MerkleBlockMatches(msg *wire.MsgMerkleBlock) ([]*chainhash.Hash, []uint32, error) {
+	// A block can't have more transactions than bytes, which also keeps
+	// the tree width calculations from overflowing.
+	if msg.Transactions == 0 || msg.Transactions > wire.MaxBlockPayload {
+		return nil, nil, fmt.Errorf("merkle block has an invalid "+
+			"number of transactions %d", msg.Transactions)
+	}
+	if uint32(len(msg.Hashes)) > msg.Transactions+1 {
+		return nil, nil, fmt.Errorf("merkle block has %d hashes for "+
+			"%d transactions", len(msg.Hashes), msg.Transactions)
+	}
+	if len(msg.Flags)*8 < len(msg.Hashes) {
+		return nil, nil, fmt.Errorf("merkle block has %d flag bytes "+
+			"for %d hashes", len(msg.Flags), len(msg.Hashes))
+	}
+
+	// Rebuild the merkle root from the depth-first partial merkle tree
+	// and ensure all of the hashes and flags were used.
+	p := merkleBlockParser{
+		tree:   newMerkleTree(msg.Transactions),
+		hashes: msg.Hashes,
+		flags:  msg.Flags,
+	}
+	root := p.traverseAndExtract(p.tree.height, 0)
+	if p.bad {
+		return nil, nil, errors.New("merkle block has a malformed " +
+			"partial merkle tree")
+	}
+	if (p.bitsUsed+7)/8 != uint32(len(p.flags)) ||
+		p.hashesUsed != uint32(len(p.hashes)) {
+
+		return nil, nil, errors.New("merkle block has unused hashes " +
+			"or flags")
+	}
+	if !root.IsEqual(&msg.Header.MerkleRoot) {
+		return nil, nil, fmt.Errorf("merkle block partial merkle tree "+
+			"hashes to %v instead of the merkle root %v", root,
+			msg.Header.MerkleRoot)
+	}
+
+	return p.matchedHashes, p.matchedIndices, nil
+}
@@ -8,8 +8,10 @@ package bloom_test
 import (
 	"bytes"
 	"encoding/hex"
+	"reflect"
 	"testing"
 
+	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/provautil/bloom"
@@ -73,3 +75,78 @@ func TestMerkleBlock3(t *testing.T) {
 		return
 	}
 }
+
+// TestMerkleBlockWithTxs ensures a merkle block built for a set of transaction
+// hashes proves the inclusion of exactly those transactions, and that merkle
+// blocks which were tampered with are rejected.
+func TestMerkleBlockWithTxs(t *testing.T) {
+	// Create a block of five distinct signed transactions with a valid
+	// merkle root.
+	msgBlock := wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{},
+		&chainhash.Hash{}, 0, 0))
+	for i := 0; i < 5; i++ {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(i)},
+			[]byte{0x51}))
+		msgBlock.AddTransaction(tx)
+	}
+	blk := provautil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(blk.Transactions())
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	blk = provautil.NewBlock(msgBlock)
+
+	txns := blk.Transactions()
+	unknown := chainhash.Hash{0x01}
+	mBlock, indices := bloom.NewMerkleBlockWithTxs(blk,
+		[]*chainhash.Hash{txns[4].Hash(), &unknown, txns[1].Hash()})
+	if !reflect.DeepEqual(indices, []uint32{1, 4}) {
+		t.Fatalf("NewMerkleBlockWithTxs: got indices %v, want [1 4]",
+			indices)
+	}
+
+	hashes, indices, err := bloom.MerkleBlockMatches(mBlock)
+	if err != nil {
+		t.Fatalf("MerkleBlockMatches: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(indices, []uint32{1, 4}) ||
+		!reflect.DeepEqual(hashes, []*chainhash.Hash{txns[1].Hash(),
+			txns[4].Hash()}) {
+
+		t.Fatalf("MerkleBlockMatches: got hashes %v at %v, want %v "+
+			"and %v at [1 4]", hashes, indices, txns[1].Hash(),
+			txns[4].Hash())
+	}
+
+	// Merkle blocks for another merkle root, with missing or extra flags
+	// and with missing hashes are rejected.
+	tests := []struct {
+		name   string
+		tamper func(*wire.MsgMerkleBlock)
+	}{
+		{"bad merkle root", func(m *wire.MsgMerkleBlock) {
+			m.Header.MerkleRoot[0] ^= 0x01
+		}},
+		{"extra flags", func(m *wire.MsgMerkleBlock) {
+			m.Flags = append(m.Flags, 0x00)
+		}},
+		{"missing flags", func(m *wire.MsgMerkleBlock) {
+			m.Flags = m.Flags[:0]
+		}},
+		{"missing hash", func(m *wire.MsgMerkleBlock) {
+			m.Hashes = m.Hashes[:len(m.Hashes)-1]
+		}},
+		{"no transactions", func(m *wire.MsgMerkleBlock) {
+			m.Transactions = 0
+		}},
+	}
+	for _, test := range tests {
+		tampered := *mBlock
+		tampered.Hashes = append([]*chainhash.Hash(nil), mBlock.Hashes...)
+		tampered.Flags = append([]byte(nil), mBlock.Flags...)
+		test.tamper(&tampered)
+		if _, _, err := bloom.MerkleBlockMatches(&tampered); err == nil {
+			t.Errorf("MerkleBlockMatches (%s): unexpected success",
+				test.name)
+		}
+	}
+}
@@ -43,5 +43,11 @@ To decode/encode an address:
 		return
 	}
 	fmt.Println(addr.EncodeAddress())
+
+Prova addresses have two string encodings: base58, and Bech32 with a
+human-readable part per network such as "prova" on the main network.  Both
+decode to the same address types and pay to the same scripts, and addresses
+keep the encoding they were decoded from.  The Bech32 and Base58 methods of
+the Prova address types convert between the encodings.
 */
 package provautil
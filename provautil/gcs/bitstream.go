@@ -0,0 +1,75 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"io"
+)
+
+// bitWriter appends bits to a byte slice, starting with the most significant
+// bit of each byte.
+type bitWriter struct {
+	bytes []byte
+
+	// free is the number of unused low bits of the last byte.
+	free uint
+}
+
+// writeBit appends a single bit.
+func (w *bitWriter) writeBit(bit bool) {
+	if w.free == 0 {
+		w.bytes = append(w.bytes, 0)
+		w.free = 8
+	}
+	w.free--
+	if bit {
+		w.bytes[len(w.bytes)-1] |= 1 << w.free
+	}
+}
+
+// writeBits appends the low n bits of the passed value, most significant bit
+// first.
+func (w *bitWriter) writeBits(value uint64, n uint) {
+	for n > 0 {
+		n--
+		w.writeBit(value&(1<<n) != 0)
+	}
+}
+
+// bitReader reads bits from a byte slice, starting with the most significant
+// bit of each byte.
+type bitReader struct {
+	bytes []byte
+
+	// pos is the index of the next bit to read.
+	pos uint
+}
+
+// readBit reads a single bit.  io.EOF is returned once all bits are read.
+func (r *bitReader) readBit() (bool, error) {
+	index := r.pos / 8
+	if index >= uint(len(r.bytes)) {
+		return false, io.EOF
+	}
+	bit := r.bytes[index]&(0x80>>(r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+// readBits reads n bits as an unsigned integer, most significant bit first.
+func (r *bitReader) readBits(n uint) (uint64, error) {
+	var value uint64
+	for ; n > 0; n-- {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value <<= 1
+		if bit {
+			value |= 1
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package builder builds the compact block filters of BIP0157 and BIP0158 for
+Prova blocks.
+
+The regular filter of a block contains the output scripts of its transactions,
+except for empty and data carrier scripts, the previous outpoints spent by its
+transactions, and the keyIDs referenced by its Prova output scripts.  Unlike
+BIP0158, which includes the scripts of the spent outputs, the filter only
+includes the spent outpoints, so the filter can be built from the block alone.
+A wallet matches the filters with its scripts, its unspent outpoints and, for
+an account service provider, its keyIDs encoded with KeyIDItem.
+*/
+package builder
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil/gcs"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// DefaultP is the Golomb-Rice parameter of regular filters.
+	DefaultP = 19
+
+	// DefaultM is the inverse false positive rate of regular filters.
+	DefaultM uint64 = 784931
+)
+
+// DeriveKey returns the key of the filter of the block with the passed hash,
+// which is the first gcs.KeySize bytes of the block hash.
+func DeriveKey(blockHash *chainhash.Hash) [gcs.KeySize]byte {
+	var key [gcs.KeySize]byte
+	copy(key[:], blockHash[:gcs.KeySize])
+	return key
+}
+
+// OutPointItem returns the filter item of the passed outpoint, which is the
+// hash of the transaction followed by the little-endian output index.
+func OutPointItem(outPoint *wire.OutPoint) []byte {
+	item := make([]byte, chainhash.HashSize+4)
+	copy(item, outPoint.Hash[:])
+	binary.LittleEndian.PutUint32(item[chainhash.HashSize:], outPoint.Index)
+	return item
+}
+
+// KeyIDItem returns the filter item of the passed keyID, which is encoded like
+// in Prova scripts and addresses.
+func KeyIDItem(keyID btcec.KeyID) []byte {
+	item := make([]byte, btcec.KeyIDSize)
+	keyID.ToAddressFormat(item)
+	return item
+}
+
+// BuildBasicFilter builds the regular filter of the passed block.
+func BuildBasicFilter(block *wire.MsgBlock) (*gcs.Filter, error) {
+	blockHash := block.BlockHash()
+
+	seen := make(map[string]struct{})
+	var items [][]byte
+	addItem := func(item []byte) {
+		if _, ok := seen[string(item)]; ok {
+			return
+		}
+		seen[string(item)] = struct{}{}
+		items = append(items, item)
+	}
+
+	for i, tx := range block.Transactions {
+		// The coinbase does not spend any outputs.
+		if i != 0 {
+			for _, txIn := range tx.TxIn {
+				addItem(OutPointItem(&txIn.PreviousOutPoint))
+			}
+		}
+
+		for _, txOut := range tx.TxOut {
+			if len(txOut.PkScript) == 0 {
+				continue
+			}
+			pops, err := txscript.ParseScript(txOut.PkScript)
+			if err != nil {
+				// Scripts which fail to parse are still
+				// matched as a whole.
+				addItem(txOut.PkScript)
+				continue
+			}
+
+			switch txscript.TypeOfScript(pops) {
+			case txscript.NullDataTy:
+				continue
+
+			case txscript.ProvaTy, txscript.ProvaTimeLockTy,
+				txscript.GeneralProvaTy:
+				keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+				if err == nil {
+					for _, keyID := range keyIDs {
+						addItem(KeyIDItem(keyID))
+					}
+				}
+			}
+			addItem(txOut.PkScript)
+		}
+	}
+
+	return gcs.BuildGCSFilter(DefaultP, DefaultM, DeriveKey(&blockHash),
+		items)
+}
+
+// GetFilterHash returns the hash of the serialized filter.
+func GetFilterHash(filter *gcs.Filter) (chainhash.Hash, error) {
+	nBytes, err := filter.NBytes()
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return chainhash.DoubleHashH(nBytes), nil
+}
+
+// MakeHeaderForFilter returns the header of the passed filter, which commits
+// to the filter and the header of the filter of the previous block.  The
+// previous header of the genesis block is all zeros.
+func MakeHeaderForFilter(filter *gcs.Filter, prevHeader chainhash.Hash) (chainhash.Hash, error) {
+	filterHash, err := GetFilterHash(filter)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return MakeHeaderForFilterHash(filterHash, prevHeader), nil
+}
+
+// MakeHeaderForFilterHash returns the header of the filter with the passed
+// hash, which commits to the filter and the header of the filter of the
+// previous block.
+func MakeHeaderForFilterHash(filterHash, prevHeader chainhash.Hash) chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(2 * chainhash.HashSize)
+	buf.Write(filterHash[:])
+	buf.Write(prevHeader[:])
+	return chainhash.DoubleHashH(buf.Bytes())
+}
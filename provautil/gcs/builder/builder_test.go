@@ -0,0 +1,107 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package builder_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestBuildBasicFilter ensures the regular filter of a block matches its
+// output scripts, spent outpoints and keyIDs, but not its data carrier
+// outputs.
+func TestBuildBasicFilter(t *testing.T) {
+	addr, err := provautil.NewAddressProva(make([]byte, 20),
+		[]btcec.KeyID{1, 70000}, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	provaScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	dataScript, err := txscript.NullDataScript([]byte("prova"))
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), nil))
+	coinbase.AddTxOut(wire.NewTxOut(0, provaScript))
+
+	spent := wire.NewOutPoint(&chainhash.Hash{0x01}, 3)
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(spent, nil))
+	tx.AddTxOut(wire.NewTxOut(0, dataScript))
+
+	block := wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{},
+		&chainhash.Hash{}, 0, 0))
+	block.AddTransaction(coinbase)
+	block.AddTransaction(tx)
+
+	filter, err := builder.BuildBasicFilter(block)
+	if err != nil {
+		t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+	}
+	if filter.N() != 4 {
+		t.Errorf("BuildBasicFilter: got %d items, want 4", filter.N())
+	}
+
+	blockHash := block.BlockHash()
+	key := builder.DeriveKey(&blockHash)
+	tests := []struct {
+		name string
+		item []byte
+		want bool
+	}{
+		{"prova script", provaScript, true},
+		{"spent outpoint", builder.OutPointItem(spent), true},
+		{"first keyID", builder.KeyIDItem(1), true},
+		{"second keyID", builder.KeyIDItem(70000), true},
+		{"other keyID", builder.KeyIDItem(2), false},
+		{"data script", dataScript, false},
+		{"coinbase outpoint", builder.OutPointItem(
+			&coinbase.TxIn[0].PreviousOutPoint), false},
+	}
+	for _, test := range tests {
+		match, err := filter.Match(key, test.item)
+		if err != nil {
+			t.Fatalf("Match (%s): unexpected error: %v", test.name,
+				err)
+		}
+		if match != test.want {
+			t.Errorf("Match (%s): got %v, want %v", test.name,
+				match, test.want)
+		}
+	}
+
+	// Filter headers commit to the filter and the previous header.
+	filterHash, err := builder.GetFilterHash(filter)
+	if err != nil {
+		t.Fatalf("GetFilterHash: unexpected error: %v", err)
+	}
+	header, err := builder.MakeHeaderForFilter(filter, chainhash.Hash{})
+	if err != nil {
+		t.Fatalf("MakeHeaderForFilter: unexpected error: %v", err)
+	}
+	if header != builder.MakeHeaderForFilterHash(filterHash,
+		chainhash.Hash{}) {
+		t.Error("MakeHeaderForFilter: header does not match the " +
+			"header of the filter hash")
+	}
+	if header == builder.MakeHeaderForFilterHash(filterHash,
+		chainhash.Hash{0x01}) {
+		t.Error("MakeHeaderForFilterHash: header does not commit to " +
+			"the previous header")
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package gcs provides an API for building and using Golomb-coded set filters.
+
+A Golomb-coded set (GCS) is a compact probabilistic data structure which,
+like a bloom filter, tells whether an item may be a member of a set.  Items
+are hashed with SipHash-2-4 into the range [0, N*M), where N is the number of
+items and 1/M the false positive rate.  The sorted hashes are then encoded as
+the differences between successive values with Golomb-Rice coding using the
+parameter P, which is close to optimal when M is about 2^P.
+
+The filters are smaller than bloom filters with the same false positive rate,
+but they can only be queried by decoding them from the start.  This makes them
+well suited for filters which are built once for each block and downloaded by
+light clients, as described by BIP0158.
+*/
+package gcs
@@ -0,0 +1,219 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// KeySize is the size of the SipHash keys used to hash the items of a filter.
+const KeySize = 16
+
+var (
+	// ErrNTooBig is returned when a filter is built for more items than
+	// can be counted with a uint32.
+	ErrNTooBig = errors.New("N is too big to fit in uint32")
+
+	// ErrPTooBig is returned when the Golomb-Rice parameter P is too big
+	// for the remainders to fit in a uint64.
+	ErrPTooBig = errors.New("P is too big to fit in uint64")
+
+	// ErrMisserialized is returned when the data of a filter ends before
+	// all of its items are decoded.
+	ErrMisserialized = errors.New("filter data is misserialized")
+)
+
+// Filter is a Golomb-coded set of the hashes of its items.  It is immutable
+// once created.
+type Filter struct {
+	n          uint32
+	p          uint8
+	modulusNP  uint64
+	filterData []byte
+}
+
+// BuildGCSFilter builds a filter of the passed items with the Golomb-Rice
+// parameter P and the inverse false positive rate M.  The items are hashed
+// with the passed key, which must also be used to match the filter.
+func BuildGCSFilter(P uint8, M uint64, key [KeySize]byte, data [][]byte) (*Filter, error) {
+	if uint64(len(data)) > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+	if P > 32 {
+		return nil, ErrPTooBig
+	}
+
+	f := &Filter{
+		n: uint32(len(data)),
+		p: P,
+	}
+	f.modulusNP = uint64(f.n) * M
+
+	// Hash the items into the range of the filter and sort them, so they
+	// can be encoded as the differences between successive values.
+	values := make([]uint64, 0, len(data))
+	for _, d := range data {
+		values = append(values, f.hashItem(key, d))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var w bitWriter
+	var last uint64
+	for _, v := range values {
+		delta := v - last
+		last = v
+
+		// The quotient is written in unary followed by the remainder
+		// in P bits.
+		for q := delta >> P; q > 0; q-- {
+			w.writeBit(true)
+		}
+		w.writeBit(false)
+		w.writeBits(delta, uint(P))
+	}
+	f.filterData = w.bytes
+	return f, nil
+}
+
+// FromBytes returns a filter of N items with the Golomb-Rice parameter P and
+// the inverse false positive rate M from the passed encoded items, as returned
+// by Bytes.
+func FromBytes(N uint32, P uint8, M uint64, d []byte) (*Filter, error) {
+	if P > 32 {
+		return nil, ErrPTooBig
+	}
+	f := &Filter{
+		n:          N,
+		p:          P,
+		modulusNP:  uint64(N) * M,
+		filterData: make([]byte, len(d)),
+	}
+	copy(f.filterData, d)
+	return f, nil
+}
+
+// FromNBytes returns a filter with the Golomb-Rice parameter P and the inverse
+// false positive rate M from the passed serialized filter, as returned by
+// NBytes.
+func FromNBytes(P uint8, M uint64, d []byte) (*Filter, error) {
+	r := bytes.NewReader(d)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+	return FromBytes(uint32(n), P, M, d[len(d)-r.Len():])
+}
+
+// Bytes returns the encoded items of the filter.
+func (f *Filter) Bytes() []byte {
+	d := make([]byte, len(f.filterData))
+	copy(d, f.filterData)
+	return d
+}
+
+// NBytes returns the serialized filter, which is the number of items as a
+// variable length integer followed by the encoded items.
+func (f *Filter) NBytes() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(wire.VarIntSerializeSize(uint64(f.n)) + len(f.filterData))
+	if err := wire.WriteVarInt(&buf, 0, uint64(f.n)); err != nil {
+		return nil, err
+	}
+	buf.Write(f.filterData)
+	return buf.Bytes(), nil
+}
+
+// N returns the number of items of the filter.
+func (f *Filter) N() uint32 {
+	return f.n
+}
+
+// P returns the Golomb-Rice parameter of the filter.
+func (f *Filter) P() uint8 {
+	return f.p
+}
+
+// Match returns whether the passed item may be in the filter.  The key must
+// be the key the filter was built with.
+func (f *Filter) Match(key [KeySize]byte, data []byte) (bool, error) {
+	return f.MatchAny(key, [][]byte{data})
+}
+
+// MatchAny returns whether any of the passed items may be in the filter.  The
+// key must be the key the filter was built with.
+func (f *Filter) MatchAny(key [KeySize]byte, data [][]byte) (bool, error) {
+	if f.n == 0 || len(data) == 0 {
+		return false, nil
+	}
+
+	targets := make([]uint64, 0, len(data))
+	for _, d := range data {
+		targets = append(targets, f.hashItem(key, d))
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+
+	// Walk the sorted items of the filter and the sorted targets together
+	// until a match is found or either runs out.
+	r := bitReader{bytes: f.filterData}
+	var value uint64
+	for i := uint32(0); i < f.n; i++ {
+		delta, err := f.readDelta(&r)
+		if err != nil {
+			return false, err
+		}
+		value += delta
+
+		for len(targets) > 0 && targets[0] < value {
+			targets = targets[1:]
+		}
+		if len(targets) == 0 {
+			return false, nil
+		}
+		if targets[0] == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readDelta decodes the difference to the next item of the filter.
+func (f *Filter) readDelta(r *bitReader) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, ErrMisserialized
+		}
+		if !bit {
+			break
+		}
+		q++
+	}
+	remainder, err := r.readBits(uint(f.p))
+	if err != nil {
+		return 0, ErrMisserialized
+	}
+	return q<<f.p | remainder, nil
+}
+
+// hashItem hashes the passed item with SipHash-2-4 and maps the hash to the
+// range [0, N*M) of the filter.  The mapping multiplies the hash with N*M and
+// keeps the high 64 bits, which is faster than a modulo reduction.
+func (f *Filter) hashItem(key [KeySize]byte, data []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+	hi, _ := bits.Mul64(wire.SipHash24(k0, k1, data), f.modulusNP)
+	return hi
+}
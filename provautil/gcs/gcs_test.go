@@ -0,0 +1,150 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const (
+	// testP and testM are the filter parameters of BIP0158.
+	testP = 19
+	testM = 784931
+)
+
+// testKey is the key used to build and match the test filters.
+var testKey = [KeySize]byte{0x4c, 0xb1, 0xab, 0x12, 0x57, 0x62, 0x1e, 0x41,
+	0x3b, 0x8b, 0x0e, 0x26, 0x64, 0x8d, 0x4a, 0x15}
+
+// testItems returns n distinct items.
+func testItems(n int) [][]byte {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = make([]byte, 8)
+		binary.LittleEndian.PutUint64(items[i], uint64(i)*0x9e3779b97f4a7c15)
+	}
+	return items
+}
+
+// TestBitStream ensures bits are read back in the order they were written.
+func TestBitStream(t *testing.T) {
+	var w bitWriter
+	w.writeBit(true)
+	w.writeBits(0x5, 3)
+	w.writeBits(0x1ff, 9)
+	if !bytes.Equal(w.bytes, []byte{0xdf, 0xf8}) {
+		t.Fatalf("bitWriter: got %x, want dff8", w.bytes)
+	}
+
+	r := bitReader{bytes: w.bytes}
+	if bit, err := r.readBit(); err != nil || !bit {
+		t.Fatalf("readBit: got %v, %v, want true", bit, err)
+	}
+	if v, err := r.readBits(3); err != nil || v != 0x5 {
+		t.Fatalf("readBits: got %x, %v, want 5", v, err)
+	}
+	if v, err := r.readBits(9); err != nil || v != 0x1ff {
+		t.Fatalf("readBits: got %x, %v, want 1ff", v, err)
+	}
+	if _, err := r.readBits(4); err == nil {
+		t.Fatal("readBits: no error reading past the end")
+	}
+}
+
+// TestFilter ensures filters match their items, rarely match other items, and
+// survive serialization.
+func TestFilter(t *testing.T) {
+	items := testItems(1000)
+	f, err := BuildGCSFilter(testP, testM, testKey, items[:500])
+	if err != nil {
+		t.Fatalf("BuildGCSFilter: unexpected error: %v", err)
+	}
+	if f.N() != 500 || f.P() != testP {
+		t.Fatalf("BuildGCSFilter: got N %d and P %d, want 500 and %d",
+			f.N(), f.P(), testP)
+	}
+
+	nBytes, err := f.NBytes()
+	if err != nil {
+		t.Fatalf("NBytes: unexpected error: %v", err)
+	}
+	f2, err := FromNBytes(testP, testM, nBytes)
+	if err != nil {
+		t.Fatalf("FromNBytes: unexpected error: %v", err)
+	}
+	f3, err := FromBytes(f.N(), testP, testM, f.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes: unexpected error: %v", err)
+	}
+
+	for _, filter := range []*Filter{f, f2, f3} {
+		for i, item := range items[:500] {
+			match, err := filter.Match(testKey, item)
+			if err != nil {
+				t.Fatalf("Match: unexpected error: %v", err)
+			}
+			if !match {
+				t.Fatalf("Match: item %d not matched", i)
+			}
+		}
+
+		// The false positive rate is one in M, so none of the other
+		// items are expected to match.
+		match, err := filter.MatchAny(testKey, items[500:])
+		if err != nil {
+			t.Fatalf("MatchAny: unexpected error: %v", err)
+		}
+		if match {
+			t.Fatal("MatchAny: matched items not in the filter")
+		}
+		match, err = filter.MatchAny(testKey, items[490:510])
+		if err != nil {
+			t.Fatalf("MatchAny: unexpected error: %v", err)
+		}
+		if !match {
+			t.Fatal("MatchAny: did not match items in the filter")
+		}
+	}
+
+	// A different key does not match the items.
+	otherKey := testKey
+	otherKey[0] ^= 0xff
+	match, err := f.MatchAny(otherKey, items[:500])
+	if err != nil {
+		t.Fatalf("MatchAny: unexpected error: %v", err)
+	}
+	if match {
+		t.Fatal("MatchAny: matched items with a different key")
+	}
+
+	// Truncated filters are detected.
+	truncated, _ := FromBytes(f.N(), testP, testM, f.Bytes()[:100])
+	if _, err := truncated.MatchAny(testKey, items[499:500]); err != ErrMisserialized {
+		t.Fatalf("MatchAny: got error %v, want %v", err,
+			ErrMisserialized)
+	}
+}
+
+// TestEmptyFilter ensures filters without items serialize to a single byte
+// and match nothing.
+func TestEmptyFilter(t *testing.T) {
+	f, err := BuildGCSFilter(testP, testM, testKey, nil)
+	if err != nil {
+		t.Fatalf("BuildGCSFilter: unexpected error: %v", err)
+	}
+	nBytes, err := f.NBytes()
+	if err != nil {
+		t.Fatalf("NBytes: unexpected error: %v", err)
+	}
+	if !bytes.Equal(nBytes, []byte{0x00}) {
+		t.Fatalf("NBytes: got %x, want 00", nBytes)
+	}
+	match, err := f.Match(testKey, []byte{0x01})
+	if err != nil || match {
+		t.Fatalf("Match: got %v, %v, want no match", match, err)
+	}
+}
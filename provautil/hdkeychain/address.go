@@ -0,0 +1,59 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain
+
+import (
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+)
+
+// GeneralAddress returns the generalized Prova address requiring nRequired
+// signatures from the keys of the passed holder extended keys and the ASP keys
+// of the passed keyIDs.  The holder keys are usually derived at the same path
+// from the extended public keys of every holder, such as a user key and a
+// backup key, so every holder can derive the address on its own.
+func GeneralAddress(nRequired int, holders []*ExtendedKey, keyIDs []btcec.KeyID,
+	net *chaincfg.Params) (*provautil.AddressGeneralProva, error) {
+
+	pkHashes := make([][]byte, 0, len(holders))
+	for _, holder := range holders {
+		pkHashes = append(pkHashes, provautil.Hash160(holder.pubKeyBytes()))
+	}
+	return provautil.NewAddressGeneralProva(nRequired, pkHashes, keyIDs, net)
+}
+
+// DeriveAddress derives the child at the passed index of every holder extended
+// key and returns the Prova address of the children along with the ASP keys of
+// the passed keyIDs.  A single holder yields a standard 2-of-3 Prova address,
+// which requires exactly two keyIDs, while several holders yield a generalized
+// Prova address requiring one more signature than there are holders.
+//
+// Passing the extended public keys of the holders allows watch-only wallets to
+// derive the addresses of a wallet without any of its private keys.
+func DeriveAddress(holders []*ExtendedKey, index uint32, keyIDs []btcec.KeyID,
+	net *chaincfg.Params) (provautil.Address, error) {
+
+	children := make([]*ExtendedKey, 0, len(holders))
+	for _, holder := range holders {
+		child, err := holder.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		addr, err := children[0].Address(keyIDs, net)
+		if err != nil {
+			return nil, err
+		}
+		return addr, nil
+	}
+	addr, err := GeneralAddress(len(children)+1, children, keyIDs, net)
+	if err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
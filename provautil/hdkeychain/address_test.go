@@ -0,0 +1,99 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/hdkeychain"
+)
+
+// TestDeriveAddress ensures the addresses derived from the extended public keys
+// of the holders match those of the private keys and commit to the holder keys
+// and keyIDs.
+func TestDeriveAddress(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	var privs, pubs []*hdkeychain.ExtendedKey
+	for _, b := range []byte{1, 2} {
+		seed := bytes.Repeat([]byte{b}, hdkeychain.RecommendedSeedLen)
+		priv, err := hdkeychain.NewMaster(seed, net)
+		if err != nil {
+			t.Fatalf("NewMaster: unexpected error: %v", err)
+		}
+		pub, err := priv.Neuter()
+		if err != nil {
+			t.Fatalf("Neuter: unexpected error: %v", err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+	}
+
+	// A single holder yields a standard Prova address.
+	keyIDs := []btcec.KeyID{1, 2}
+	addr, err := hdkeychain.DeriveAddress(pubs[:1], 7, keyIDs, net)
+	if err != nil {
+		t.Fatalf("DeriveAddress: unexpected error: %v", err)
+	}
+	privAddr, err := hdkeychain.DeriveAddress(privs[:1], 7, keyIDs, net)
+	if err != nil {
+		t.Fatalf("DeriveAddress: unexpected error: %v", err)
+	}
+	if addr.EncodeAddress() != privAddr.EncodeAddress() {
+		t.Errorf("DeriveAddress: public derivation %v does not match "+
+			"private derivation %v", addr, privAddr)
+	}
+	child, err := privs[0].Child(7)
+	if err != nil {
+		t.Fatalf("Child: unexpected error: %v", err)
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		t.Fatalf("ECPubKey: unexpected error: %v", err)
+	}
+	provaAddr, ok := addr.(*provautil.AddressProva)
+	if !ok {
+		t.Fatalf("DeriveAddress: got %T, want *provautil.AddressProva",
+			addr)
+	}
+	if !bytes.Equal(provaAddr.ScriptAddress(),
+		provautil.Hash160(pubKey.SerializeCompressed())) {
+
+		t.Errorf("DeriveAddress: address does not commit to the " +
+			"holder key")
+	}
+
+	// Two holders yield a generalized 3-of-5 Prova address.
+	keyIDs = []btcec.KeyID{1, 2, 3}
+	addr, err = hdkeychain.DeriveAddress(pubs, 7, keyIDs, net)
+	if err != nil {
+		t.Fatalf("DeriveAddress: unexpected error: %v", err)
+	}
+	general, ok := addr.(*provautil.AddressGeneralProva)
+	if !ok {
+		t.Fatalf("DeriveAddress: got %T, want "+
+			"*provautil.AddressGeneralProva", addr)
+	}
+	if general.RequiredSigs() != 3 || len(general.PubKeyHashes()) != 2 ||
+		len(general.ScriptKeyIDs()) != 3 {
+
+		t.Errorf("DeriveAddress: got %d-of-%d address, want 3-of-5",
+			general.RequiredSigs(), len(general.PubKeyHashes())+
+				len(general.ScriptKeyIDs()))
+	}
+
+	// Invalid keyIDs.
+	if _, err := hdkeychain.DeriveAddress(pubs[:1], 7, keyIDs, net); err == nil {
+		t.Errorf("DeriveAddress: no error with three keyIDs for a " +
+			"single holder")
+	}
+	if _, err := hdkeychain.DeriveAddress(pubs, 7, keyIDs[:2], net); err == nil {
+		t.Errorf("DeriveAddress: no error with fewer keyIDs than " +
+			"required signatures")
+	}
+}
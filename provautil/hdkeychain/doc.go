@@ -29,6 +29,14 @@ send funds to, the underlying key and address material must be accessible.  This
 package provides the ECPubKey, ECPrivKey, and Address functions for this
 purpose.
 
+Prova addresses are controlled by the key of a holder along with the ASP keys
+of two keyIDs.  Address returns the standard 2-of-3 Prova address of an
+extended key and the passed keyIDs.  DeriveAddress derives the child at an
+index of the extended keys of one or more holders and composes their keys and
+the passed keyIDs into an address, a generalized Prova address when there are
+several holders.  Since only public derivation is involved, the extended public
+keys of the holders are enough to derive the addresses of a wallet.
+
 The Master Node
 
 As previously mentioned, the extended keys are hierarchical meaning they are
@@ -47,6 +55,9 @@ the HardenedKeyStart constant + the hardened key number as the index to the
 Child function.  This provides the ability to cascade the keys into a tree and
 hence generate the hierarchical deterministic key chains.
 
+A whole path of children, such as m/0'/1/2, is parsed with ParsePath and
+derived at once with the DerivePath function.
+
 Normal vs Hardened Child Extended Keys
 
 A private extended key can be used to derive both hardened and non-hardened
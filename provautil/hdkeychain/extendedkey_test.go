@@ -16,6 +16,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/provautil/hdkeychain"
 )
@@ -550,7 +551,7 @@ func TestExtendedKeyAPI(t *testing.T) {
 			parentFP:  0,
 			privKey:   "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
 			pubKey:    "0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
-			address:   "15mKKb2eos1hWa6tisdPwwDC1a5J1y9nma",
+			address:   "GDE9ZVVjo76K4LTMsJu6RCoFU914jqgN49C1upR3dbvfZ",
 		},
 		{
 			name:       "test vector 1 chain m/0H/1/2H public",
@@ -559,7 +560,7 @@ func TestExtendedKeyAPI(t *testing.T) {
 			parentFP:   3203769081,
 			privKeyErr: hdkeychain.ErrNotPrivExtKey,
 			pubKey:     "0357bfe1e341d01c69fe5654309956cbea516822fba8a601743a012a7896ee8dc2",
-			address:    "1NjxqbA9aZWnh17q1UW3rB4EPu79wDXj7x",
+			address:    "GRm5UJcAuvMkFiy9VR5K4mhjYAKqtfWmiQbW93wJjX2EG",
 		},
 	}
 
@@ -624,7 +625,8 @@ func TestExtendedKeyAPI(t *testing.T) {
 			continue
 		}
 
-		addr, err := key.Address(&chaincfg.MainNetParams)
+		addr, err := key.Address([]btcec.KeyID{1, 2},
+			&chaincfg.MainNetParams)
 		if err != nil {
 			t.Errorf("Address #%d (%s): unexpected error: %v", i,
 				test.name, err)
@@ -938,8 +940,9 @@ func TestZero(t *testing.T) {
 			return false
 		}
 
-		wantAddr := "1HT7xU2Ngenf7D4yocz2SAcnNLW7rK8d4E"
-		addr, err := key.Address(&chaincfg.MainNetParams)
+		wantAddr := "GMrYfuZKhJfJnJfSzasZSUiwtQSEqfSCe2jBHwQJ64ntk"
+		addr, err := key.Address([]btcec.KeyID{1, 2},
+			&chaincfg.MainNetParams)
 		if err != nil {
 			t.Errorf("Addres s #%d (%s): unexpected error: %v", i,
 				testName, err)
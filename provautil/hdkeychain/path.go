@@ -0,0 +1,60 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPath describes an error in which a derivation path is not of the
+// form m/a/b'/c where hardened indexes are marked with ' or H.
+var ErrInvalidPath = errors.New("invalid derivation path")
+
+// ParsePath parses a derivation path such as m/0'/1/2' into the indexes of the
+// child keys to derive.  Hardened indexes, marked with a trailing ' or H, are
+// returned offset by HardenedKeyStart, so the result can be passed to
+// DerivePath.  The leading m of the master node is optional.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] == "m" || parts[0] == "M" {
+		parts = parts[1:]
+	}
+	indexes := make([]uint32, 0, len(parts))
+	for _, part := range parts {
+		var offset uint32
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "H") ||
+			strings.HasSuffix(part, "h") {
+
+			offset = HardenedKeyStart
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, fmt.Errorf("%v: bad index %q", ErrInvalidPath,
+				part)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+	return indexes, nil
+}
+
+// DerivePath derives the descendant of the extended key at the passed path of
+// child indexes, as returned by ParsePath, by calling Child for every index in
+// turn.  Like Child, it fails with ErrInvalidChild in the rare case an index
+// along the path yields an invalid key.
+func (k *ExtendedKey) DerivePath(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdkeychain_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil/hdkeychain"
+)
+
+// TestParsePath ensures derivation paths are parsed into child indexes.
+func TestParsePath(t *testing.T) {
+	hkStart := uint32(hdkeychain.HardenedKeyStart)
+	tests := []struct {
+		path    string
+		want    []uint32
+		wantErr bool
+	}{
+		{path: "m/0'/1/2H", want: []uint32{hkStart, 1, hkStart + 2}},
+		{path: "0h/2147483647", want: []uint32{hkStart, 2147483647}},
+		{path: "m", want: []uint32{}},
+		{path: "", wantErr: true},
+		{path: "m/x", wantErr: true},
+		{path: "m/-1", wantErr: true},
+		{path: "m/2147483648", wantErr: true},
+		{path: "m/0//1", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := hdkeychain.ParsePath(test.path)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParsePath(%q): unexpected error: %v", test.path,
+				err)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParsePath(%q): got %v, want %v", test.path,
+				got, test.want)
+		}
+	}
+}
+
+// TestDerivePath ensures deriving a path matches the [BIP32] test vectors.
+func TestDerivePath(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewMaster: unexpected error: %v", err)
+	}
+	path, err := hdkeychain.ParsePath("m/0H/1/2H")
+	if err != nil {
+		t.Fatalf("ParsePath: unexpected error: %v", err)
+	}
+	key, err := master.DerivePath(path)
+	if err != nil {
+		t.Fatalf("DerivePath: unexpected error: %v", err)
+	}
+	want := "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM"
+	if key.String() != want {
+		t.Errorf("DerivePath: got %v, want %v", key, want)
+	}
+
+	// Hardened children can't be derived from public keys.
+	pub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter: unexpected error: %v", err)
+	}
+	if _, err := pub.DerivePath(path); err != hdkeychain.ErrDeriveHardFromPublic {
+		t.Errorf("DerivePath: got error %v, want %v", err,
+			hdkeychain.ErrDeriveHardFromPublic)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provautil
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// messageMagic is prefixed to messages before they are hashed for signing so a
+// signed message can't be mistaken for a signed transaction.
+const messageMagic = "Prova Signed Message:\n"
+
+var (
+	// ErrMessageSigner describes an error in which a message was signed by
+	// a key which is neither a holder key nor an ASP key of an address.
+	ErrMessageSigner = errors.New("message not signed by a key of the " +
+		"address")
+
+	// ErrMessageAddress describes an error in which messages are signed or
+	// verified for an address which is not a Prova address.
+	ErrMessageAddress = errors.New("address does not support message " +
+		"signing")
+)
+
+// MessageSigner identifies the key of an address which signed a message.  A
+// Prova address is satisfied by its holder keys, which it commits to by hash,
+// along with the ASP keys it names by key id.
+type MessageSigner struct {
+	// PubKey is the public key recovered from the signature.
+	PubKey *btcec.PublicKey
+
+	// Holder is the index of the holder key among the public key hashes of
+	// the address, or -1 when the message was signed by an ASP key.
+	Holder int
+
+	// KeyID is the key id of the ASP key which signed the message.  It is
+	// only meaningful when Holder is -1.
+	KeyID btcec.KeyID
+}
+
+// IsHolder returns whether the message was signed by a holder key of the
+// address.
+func (s *MessageSigner) IsHolder() bool {
+	return s.Holder >= 0
+}
+
+// MessageHash returns the hash of the passed message which is signed by
+// SignMessage.
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, messageMagic)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// SignMessage signs the passed message with the private key of the passed WIF
+// and returns the compact signature, from which the public key of the signer
+// can be recovered.
+func SignMessage(wif *WIF, message string) ([]byte, error) {
+	return btcec.SignCompact(btcec.S256(), wif.PrivKey, MessageHash(message),
+		wif.CompressPubKey)
+}
+
+// addressKeys returns the holder key hashes and ASP key ids of the passed
+// address.
+func addressKeys(addr Address) ([][]byte, []btcec.KeyID, error) {
+	switch addr := addr.(type) {
+	case *AddressProva:
+		return [][]byte{addr.ScriptAddress()}, addr.ScriptKeyIDs(), nil
+	case *AddressGeneralProva:
+		return addr.PubKeyHashes(), addr.ScriptKeyIDs(), nil
+	}
+	return nil, nil, ErrMessageAddress
+}
+
+// VerifyMessage verifies the passed compact signature of a message against an
+// address and returns the key of the address which signed it.  The ASP keys
+// named by the address are looked up in the passed key id map, which is
+// usually that of the best chain.  ErrMessageSigner is returned when the
+// signature is valid but was made by a key of neither kind.
+func VerifyMessage(addr Address, signature []byte, message string,
+	keyIDs btcec.KeyIdMap) (*MessageSigner, error) {
+
+	pkHashes, addrKeyIDs, err := addressKeys(addr)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, wasCompressed, err := btcec.RecoverCompact(btcec.S256(),
+		signature, MessageHash(message))
+	if err != nil {
+		return nil, err
+	}
+
+	var serializedPubKey []byte
+	if wasCompressed {
+		serializedPubKey = pubKey.SerializeCompressed()
+	} else {
+		serializedPubKey = pubKey.SerializeUncompressed()
+	}
+	pkHash := Hash160(serializedPubKey)
+	for i, holderHash := range pkHashes {
+		if bytes.Equal(pkHash, holderHash) {
+			return &MessageSigner{PubKey: pubKey, Holder: i}, nil
+		}
+	}
+	for _, keyID := range addrKeyIDs {
+		aspKey, ok := keyIDs[keyID]
+		if ok && aspKey.IsEqual(pubKey) {
+			return &MessageSigner{PubKey: pubKey, Holder: -1,
+				KeyID: keyID}, nil
+		}
+	}
+	return nil, ErrMessageSigner
+}
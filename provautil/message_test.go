@@ -0,0 +1,145 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provautil_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	. "github.com/bitgo/prova/provautil"
+)
+
+// TestSignVerifyMessage ensures messages signed by the holder keys and the ASP
+// keys of Prova addresses verify and identify the signing key.
+func TestSignVerifyMessage(t *testing.T) {
+	net := &chaincfg.TestNetParams
+	newWIF := func(b byte) *WIF {
+		priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{b})
+		wif, err := NewWIF(priv, net, true)
+		if err != nil {
+			t.Fatalf("NewWIF: unexpected error: %v", err)
+		}
+		return wif
+	}
+	holder1, holder2 := newWIF(1), newWIF(2)
+	asp1, asp2 := newWIF(3), newWIF(4)
+	stranger := newWIF(5)
+	keyIDs := btcec.KeyIdMap{
+		1: asp1.PrivKey.PubKey(),
+		2: asp2.PrivKey.PubKey(),
+	}
+
+	addr, err := NewAddressProva(Hash160(holder1.SerializePubKey()),
+		[]btcec.KeyID{1, 2}, net)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	generalAddr, err := NewAddressGeneralProva(3, [][]byte{
+		Hash160(holder1.SerializePubKey()),
+		Hash160(holder2.SerializePubKey()),
+	}, []btcec.KeyID{2, 5, 6}, net)
+	if err != nil {
+		t.Fatalf("NewAddressGeneralProva: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		addr   Address
+		wif    *WIF
+		holder int
+		keyID  btcec.KeyID
+		err    error
+	}{
+		{
+			name:   "holder key",
+			addr:   addr,
+			wif:    holder1,
+			holder: 0,
+		},
+		{
+			name:   "first ASP key",
+			addr:   addr,
+			wif:    asp1,
+			holder: -1,
+			keyID:  1,
+		},
+		{
+			name:   "second ASP key",
+			addr:   addr,
+			wif:    asp2,
+			holder: -1,
+			keyID:  2,
+		},
+		{
+			name:   "second holder key of general address",
+			addr:   generalAddr,
+			wif:    holder2,
+			holder: 1,
+		},
+		{
+			name: "ASP key not named by general address",
+			addr: generalAddr,
+			wif:  asp1,
+			err:  ErrMessageSigner,
+		},
+		{
+			name: "unrelated key",
+			addr: addr,
+			wif:  stranger,
+			err:  ErrMessageSigner,
+		},
+	}
+
+	const message = "I control this address"
+	for _, test := range tests {
+		sig, err := SignMessage(test.wif, message)
+		if err != nil {
+			t.Errorf("%s: SignMessage: unexpected error: %v", test.name,
+				err)
+			continue
+		}
+		signer, err := VerifyMessage(test.addr, sig, message, keyIDs)
+		if err != test.err {
+			t.Errorf("%s: VerifyMessage: got error %v, want %v",
+				test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !signer.PubKey.IsEqual(test.wif.PrivKey.PubKey()) {
+			t.Errorf("%s: VerifyMessage: recovered wrong key",
+				test.name)
+		}
+		if signer.Holder != test.holder || signer.IsHolder() !=
+			(test.holder >= 0) {
+			t.Errorf("%s: VerifyMessage: got holder %d, want %d",
+				test.name, signer.Holder, test.holder)
+		}
+		if signer.KeyID != test.keyID {
+			t.Errorf("%s: VerifyMessage: got key id %d, want %d",
+				test.name, signer.KeyID, test.keyID)
+		}
+
+		// The signature must not verify a different message.
+		_, err = VerifyMessage(test.addr, sig, message+".", keyIDs)
+		if err == nil {
+			t.Errorf("%s: VerifyMessage: altered message verified",
+				test.name)
+		}
+	}
+
+	// An ASP key no longer verifies once its key id is unknown.
+	sig, err := SignMessage(asp1, message)
+	if err != nil {
+		t.Fatalf("SignMessage: unexpected error: %v", err)
+	}
+	_, err = VerifyMessage(addr, sig, message, btcec.KeyIdMap{})
+	if err != ErrMessageSigner {
+		t.Errorf("VerifyMessage: got error %v, want %v", err,
+			ErrMessageSigner)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package pspt implements partially signed Prova transactions.
+
+Overview
+
+Spending a Prova output takes signatures from several parties, such as the
+user key and the key of an ASP referenced by keyID in the case of the standard
+2-of-3 Prova scripts.  A partially signed Prova transaction (PSPT) is passed
+between those parties in order to coordinate the signing.  It carries the
+unsigned transaction along with everything a signer needs to know about the
+outputs it spends, which is the amount and public key script of each output,
+the keyIDs the script references, and the number of signatures needed to spend
+it, as well as the signatures collected so far.
+
+A packet goes through the following steps:
+
+ - New creates the packet for an unsigned transaction and the outputs it
+   spends
+ - AddSignature adds a signature for an input after verifying it
+ - Combine merges the signatures collected by several copies of a packet
+ - Finalize builds the signature scripts of the inputs which have collected
+   the signatures they need
+ - Extract returns the signed transaction once every input is finalized
+
+Serialization
+
+Packets are serialized as the magic bytes "pspt" followed by 0xff, the unsigned
+transaction in its wire encoding, and then for each input the amount as a
+little-endian int64, the public key script as variable length bytes, the number
+of keyIDs as a variable length integer followed by each keyID as a
+little-endian uint32, the number of required signatures as a variable length
+integer, the number of collected signatures as a variable length integer
+followed by the public key and signature of each as variable length bytes, and
+finally the signature script of a finalized input as variable length bytes.
+Packets are exchanged in the base64 encoding of that serialization.
+*/
+package pspt
@@ -0,0 +1,631 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pspt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// maxInputSigs is the maximum number of signatures which may be
+	// collected for a single input.  It matches the maximum number of keys
+	// of a generalized Prova script.
+	maxInputSigs = 16
+
+	// maxSigSize is the maximum length of a serialized signature including
+	// the trailing hash type byte.
+	maxSigSize = 80
+)
+
+// magic is the sequence of bytes which every serialized packet starts with.
+var magic = []byte{'p', 's', 'p', 't', 0xff}
+
+var (
+	// ErrInvalidMagic describes an error where the serialized data does
+	// not start with the packet magic bytes.
+	ErrInvalidMagic = errors.New("invalid packet magic")
+
+	// ErrPrevOutCount describes an error where the number of previous
+	// outputs passed to New does not match the number of transaction
+	// inputs.
+	ErrPrevOutCount = errors.New("the number of previous outputs does " +
+		"not match the number of transaction inputs")
+
+	// ErrSignedTx describes an error where the transaction a packet is
+	// created for already carries signature scripts.
+	ErrSignedTx = errors.New("transaction inputs must have empty " +
+		"signature scripts")
+
+	// ErrUnsupportedScript describes an error where an input spends an
+	// output whose public key script is not a Prova script.
+	ErrUnsupportedScript = errors.New("previous output script is not " +
+		"a Prova script")
+
+	// ErrInvalidInput describes an error where the metadata of an input
+	// does not match the output it spends.
+	ErrInvalidInput = errors.New("input metadata does not match the " +
+		"previous output script")
+
+	// ErrInputIndex describes an error where an input index is out of
+	// range.
+	ErrInputIndex = errors.New("input index out of range")
+
+	// ErrInvalidSignature describes an error where a signature does not
+	// verify against the input it is added to.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrDuplicateSignature describes an error where a signature is added
+	// for a public key which has already signed the input.
+	ErrDuplicateSignature = errors.New("input is already signed by the " +
+		"public key")
+
+	// ErrTooManySignatures describes an error where more signatures are
+	// added to an input than it could ever use.
+	ErrTooManySignatures = errors.New("too many signatures for input")
+
+	// ErrFinalized describes an error where a signature is added to an
+	// input which has already been finalized.
+	ErrFinalized = errors.New("input is already finalized")
+
+	// ErrMismatchedPackets describes an error where packets which are not
+	// for the same transaction are combined.
+	ErrMismatchedPackets = errors.New("packets are not for the same " +
+		"transaction")
+
+	// ErrIncomplete describes an error where a transaction is extracted
+	// from a packet which has inputs that are not finalized.
+	ErrIncomplete = errors.New("packet has inputs which are not finalized")
+)
+
+// Sig is a signature collected for an input along with the public key which
+// produced it.
+type Sig struct {
+	// PubKey is the serialized public key.
+	PubKey []byte
+
+	// Signature is the DER encoded signature followed by the hash type.
+	Signature []byte
+}
+
+// Input houses the information needed to sign an input of the unsigned
+// transaction along with the signatures collected for it so far.
+type Input struct {
+	// Amount is the value of the output spent by the input.  Signatures
+	// commit to it.
+	Amount int64
+
+	// PkScript is the public key script of the output spent by the input.
+	PkScript []byte
+
+	// KeyIDs are the keyIDs of the ASP keys referenced by the public key
+	// script, whose keys may provide signatures in addition to the keys of
+	// the public key hashes of the script.
+	KeyIDs []btcec.KeyID
+
+	// RequiredSigs is the number of signatures needed to spend the output.
+	RequiredSigs int
+
+	// Sigs are the signatures collected so far.
+	Sigs []Sig
+
+	// FinalScriptSig is the signature script spending the output once the
+	// input has been finalized.
+	FinalScriptSig []byte
+}
+
+// IsFinalized returns whether or not the input has been finalized.
+func (in *Input) IsFinalized() bool {
+	return len(in.FinalScriptSig) > 0
+}
+
+// PubKeyHashes returns the public key hashes embedded in the public key script
+// spent by the input.
+func (in *Input) PubKeyHashes() [][]byte {
+	pushes, err := txscript.PushedData(in.PkScript)
+	if err != nil {
+		return nil
+	}
+	var hashes [][]byte
+	for _, push := range pushes {
+		if len(push) == 20 {
+			hashes = append(hashes, push)
+		}
+	}
+	return hashes
+}
+
+// Packet is a partially signed Prova transaction.  It carries an unsigned
+// transaction along with the information about the outputs spent by each of its
+// inputs which signers need, and the signatures collected from them so far.
+type Packet struct {
+	// UnsignedTx is the transaction being signed.  Its signature scripts
+	// are always empty.
+	UnsignedTx *wire.MsgTx
+
+	// Inputs describes each input of the unsigned transaction in order.
+	Inputs []Input
+}
+
+// scriptRequirements returns the keyIDs referenced by the passed public key
+// script along with the number of signatures needed to spend it.
+func scriptRequirements(pkScript []byte) ([]btcec.KeyID, int, error) {
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch txscript.TypeOfScript(pops) {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy:
+		keyIDs, err := txscript.ExtractKeyIDs(pops)
+		if err != nil {
+			return nil, 0, err
+		}
+		return keyIDs, 2, nil
+
+	case txscript.GeneralProvaTy:
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Generalized Prova scripts start with the small integer
+		// number of required signatures.
+		return keyIDs, int(pkScript[0] - (txscript.OP_1 - 1)), nil
+
+	case txscript.ProvaAdminTy:
+		// The threshold of admin threads is a chain parameter, so
+		// packets assume the default threshold until it is set with
+		// SetRequiredSigs.
+		return nil, 2, nil
+	}
+
+	return nil, 0, ErrUnsupportedScript
+}
+
+// New returns a packet for the passed unsigned transaction, which spends the
+// passed previous outputs in order.
+func New(tx *wire.MsgTx, prevOuts []*wire.TxOut) (*Packet, error) {
+	if len(prevOuts) != len(tx.TxIn) {
+		return nil, ErrPrevOutCount
+	}
+	for _, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) > 0 {
+			return nil, ErrSignedTx
+		}
+	}
+
+	p := &Packet{
+		UnsignedTx: tx.Copy(),
+		Inputs:     make([]Input, len(tx.TxIn)),
+	}
+	for i, prevOut := range prevOuts {
+		keyIDs, requiredSigs, err := scriptRequirements(prevOut.PkScript)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		p.Inputs[i] = Input{
+			Amount:       prevOut.Value,
+			PkScript:     prevOut.PkScript,
+			KeyIDs:       keyIDs,
+			RequiredSigs: requiredSigs,
+		}
+	}
+	return p, nil
+}
+
+// validate ensures the packet is consistent with the unsigned transaction and
+// the metadata of each input matches the output it spends.
+func (p *Packet) validate() error {
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) {
+		return ErrPrevOutCount
+	}
+	for _, txIn := range p.UnsignedTx.TxIn {
+		if len(txIn.SignatureScript) > 0 {
+			return ErrSignedTx
+		}
+	}
+
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		keyIDs, requiredSigs, err := scriptRequirements(in.PkScript)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		if in.RequiredSigs > 0 && txscript.GetScriptClass(in.PkScript) ==
+			txscript.ProvaAdminTy {
+
+			requiredSigs = in.RequiredSigs
+		}
+		if requiredSigs != in.RequiredSigs || len(keyIDs) != len(in.KeyIDs) {
+			return fmt.Errorf("input %d: %v", i, ErrInvalidInput)
+		}
+		for j := range keyIDs {
+			if keyIDs[j] != in.KeyIDs[j] {
+				return fmt.Errorf("input %d: %v", i, ErrInvalidInput)
+			}
+		}
+	}
+	return nil
+}
+
+// SetRequiredSigs sets the number of signatures needed to spend the admin
+// thread output spent by the input at the passed index, which is the threshold
+// the chain parameters set for the thread.
+func (p *Packet) SetRequiredSigs(idx, requiredSigs int) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return ErrInputIndex
+	}
+	in := &p.Inputs[idx]
+	if in.IsFinalized() {
+		return ErrFinalized
+	}
+	if txscript.GetScriptClass(in.PkScript) != txscript.ProvaAdminTy ||
+		requiredSigs < 1 || requiredSigs > maxInputSigs {
+
+		return fmt.Errorf("input %d: %v", idx, ErrInvalidInput)
+	}
+	in.RequiredSigs = requiredSigs
+	return nil
+}
+
+// SigHash returns the signature hash which signatures for the input at the
+// passed index must commit to.
+func (p *Packet) SigHash(idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return nil, ErrInputIndex
+	}
+	return txscript.CalcSignatureHashNew(p.UnsignedTx, idx, nil,
+		txscript.SigHashAll, p.Inputs[idx].Amount)
+}
+
+// AddSignature adds the signature produced by the passed serialized public key
+// to the input at the passed index.  The signature must be DER encoded followed
+// by the SigHashAll hash type, and must be valid for the input.
+//
+// Whether or not the public key is allowed to sign the input is not checked
+// since the keys referenced by keyIDs are only known to the chain.
+func (p *Packet) AddSignature(idx int, pubKey, sig []byte) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return ErrInputIndex
+	}
+	in := &p.Inputs[idx]
+	if in.IsFinalized() {
+		return ErrFinalized
+	}
+	for _, s := range in.Sigs {
+		if bytes.Equal(s.PubKey, pubKey) {
+			return ErrDuplicateSignature
+		}
+	}
+	if len(in.Sigs) >= maxInputSigs {
+		return ErrTooManySignatures
+	}
+
+	if len(sig) == 0 || txscript.SigHashType(sig[len(sig)-1]) !=
+		txscript.SigHashAll {
+
+		return fmt.Errorf("%v: hash type is not SigHashAll",
+			ErrInvalidSignature)
+	}
+	parsedSig, err := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+	if err != nil {
+		return fmt.Errorf("%v: %v", ErrInvalidSignature, err)
+	}
+	parsedPubKey, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("%v: %v", ErrInvalidSignature, err)
+	}
+	hash, err := p.SigHash(idx)
+	if err != nil {
+		return err
+	}
+	if !parsedSig.Verify(hash, parsedPubKey) {
+		return ErrInvalidSignature
+	}
+
+	in.Sigs = append(in.Sigs, Sig{PubKey: pubKey, Signature: sig})
+	return nil
+}
+
+// Combine returns a packet holding the signatures collected by all of the
+// passed packets, which must all be for the same transaction.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrMismatchedPackets
+	}
+
+	// Copy the first packet by round tripping it through its serialization
+	// so the passed packets are not modified.
+	var buf bytes.Buffer
+	if err := packets[0].Serialize(&buf); err != nil {
+		return nil, err
+	}
+	var combined Packet
+	if err := combined.Deserialize(&buf); err != nil {
+		return nil, err
+	}
+
+	txHash := combined.UnsignedTx.TxHash()
+	for _, p := range packets[1:] {
+		if p.UnsignedTx.TxHash() != txHash ||
+			len(p.Inputs) != len(combined.Inputs) {
+
+			return nil, ErrMismatchedPackets
+		}
+		for i := range p.Inputs {
+			in, dst := &p.Inputs[i], &combined.Inputs[i]
+			if in.Amount != dst.Amount ||
+				in.RequiredSigs != dst.RequiredSigs ||
+				!bytes.Equal(in.PkScript, dst.PkScript) {
+
+				return nil, ErrMismatchedPackets
+			}
+			if dst.IsFinalized() {
+				continue
+			}
+			if in.IsFinalized() {
+				dst.FinalScriptSig = in.FinalScriptSig
+				continue
+			}
+			for _, sig := range in.Sigs {
+				err := combined.AddSignature(i, sig.PubKey,
+					sig.Signature)
+				if err != nil && err != ErrDuplicateSignature {
+					return nil, fmt.Errorf("input %d: %v",
+						i, err)
+				}
+			}
+		}
+	}
+	return &combined, nil
+}
+
+// Finalize builds the signature script of every input which has collected the
+// number of signatures it requires and returns whether or not all of the inputs
+// have been finalized.  Inputs which are still missing signatures are left
+// as they are.
+func (p *Packet) Finalize() (bool, error) {
+	complete := true
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinalized() {
+			continue
+		}
+		if len(in.Sigs) < in.RequiredSigs {
+			complete = false
+			continue
+		}
+
+		builder := txscript.NewScriptBuilder()
+		for _, sig := range in.Sigs[:in.RequiredSigs] {
+			builder.AddData(sig.PubKey).AddData(sig.Signature)
+		}
+		script, err := builder.Script()
+		if err != nil {
+			return false, fmt.Errorf("input %d: %v", i, err)
+		}
+		in.FinalScriptSig = script
+		in.Sigs = nil
+	}
+	return complete, nil
+}
+
+// IsComplete returns whether or not all of the inputs have been finalized.
+func (p *Packet) IsComplete() bool {
+	for i := range p.Inputs {
+		if !p.Inputs[i].IsFinalized() {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract returns the signed transaction once all of the inputs have been
+// finalized.
+func (p *Packet) Extract() (*wire.MsgTx, error) {
+	if !p.IsComplete() {
+		return nil, ErrIncomplete
+	}
+	tx := p.UnsignedTx.Copy()
+	for i := range tx.TxIn {
+		tx.TxIn[i].SignatureScript = p.Inputs[i].FinalScriptSig
+	}
+	return tx, nil
+}
+
+// Serialize encodes the packet to w.
+func (p *Packet) Serialize(w io.Writer) error {
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) {
+		return ErrPrevOutCount
+	}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	if err := p.UnsignedTx.Serialize(w); err != nil {
+		return err
+	}
+
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		err := binary.Write(w, binary.LittleEndian, in.Amount)
+		if err != nil {
+			return err
+		}
+		if err := wire.WriteVarBytes(w, 0, in.PkScript); err != nil {
+			return err
+		}
+		err = wire.WriteVarInt(w, 0, uint64(len(in.KeyIDs)))
+		if err != nil {
+			return err
+		}
+		for _, keyID := range in.KeyIDs {
+			err := binary.Write(w, binary.LittleEndian, uint32(keyID))
+			if err != nil {
+				return err
+			}
+		}
+		err = wire.WriteVarInt(w, 0, uint64(in.RequiredSigs))
+		if err != nil {
+			return err
+		}
+		if err := wire.WriteVarInt(w, 0, uint64(len(in.Sigs))); err != nil {
+			return err
+		}
+		for _, sig := range in.Sigs {
+			if err := wire.WriteVarBytes(w, 0, sig.PubKey); err != nil {
+				return err
+			}
+			err := wire.WriteVarBytes(w, 0, sig.Signature)
+			if err != nil {
+				return err
+			}
+		}
+		if err := wire.WriteVarBytes(w, 0, in.FinalScriptSig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize decodes a packet from r into the receiver and ensures it is
+// consistent.
+func (p *Packet) Deserialize(r io.Reader) error {
+	var m [5]byte
+	if _, err := io.ReadFull(r, m[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(m[:], magic) {
+		return ErrInvalidMagic
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(r); err != nil {
+		return err
+	}
+
+	inputs := make([]Input, len(tx.TxIn))
+	for i := range inputs {
+		in := &inputs[i]
+		err := binary.Read(r, binary.LittleEndian, &in.Amount)
+		if err != nil {
+			return err
+		}
+		in.PkScript, err = wire.ReadVarBytes(r, 0, txscript.MaxScriptSize,
+			"pkScript")
+		if err != nil {
+			return err
+		}
+		count, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return err
+		}
+		if count > maxInputSigs {
+			return fmt.Errorf("input %d: too many keyIDs", i)
+		}
+		if count > 0 {
+			in.KeyIDs = make([]btcec.KeyID, count)
+		}
+		for j := range in.KeyIDs {
+			var keyID uint32
+			err := binary.Read(r, binary.LittleEndian, &keyID)
+			if err != nil {
+				return err
+			}
+			in.KeyIDs[j] = btcec.KeyID(keyID)
+		}
+		requiredSigs, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return err
+		}
+		if requiredSigs > maxInputSigs {
+			return fmt.Errorf("input %d: %v", i, ErrInvalidInput)
+		}
+		in.RequiredSigs = int(requiredSigs)
+		count, err = wire.ReadVarInt(r, 0)
+		if err != nil {
+			return err
+		}
+		if count > maxInputSigs {
+			return fmt.Errorf("input %d: %v", i, ErrTooManySignatures)
+		}
+		for j := uint64(0); j < count; j++ {
+			var sig Sig
+			sig.PubKey, err = wire.ReadVarBytes(r, 0,
+				btcec.PubKeyBytesLenUncompressed, "pubKey")
+			if err != nil {
+				return err
+			}
+			sig.Signature, err = wire.ReadVarBytes(r, 0, maxSigSize,
+				"signature")
+			if err != nil {
+				return err
+			}
+			in.Sigs = append(in.Sigs, sig)
+		}
+		in.FinalScriptSig, err = wire.ReadVarBytes(r, 0,
+			txscript.MaxScriptSize, "finalScriptSig")
+		if err != nil {
+			return err
+		}
+		if len(in.FinalScriptSig) == 0 {
+			in.FinalScriptSig = nil
+		}
+	}
+
+	decoded := Packet{UnsignedTx: &tx, Inputs: inputs}
+	if err := decoded.validate(); err != nil {
+		return err
+	}
+	*p = decoded
+	return nil
+}
+
+// B64Encode returns the base64 encoding of the serialized packet, which is the
+// form packets are exchanged in.
+func (p *Packet) B64Encode() (string, error) {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// NewFromB64 returns the packet with the passed base64 encoding.
+func NewFromB64(encoded string) (*Packet, error) {
+	serialized, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(serialized)
+	var p Packet
+	if err := p.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after packet", r.Len())
+	}
+	return &p, nil
+}
+
+// Fee returns the fee paid by the transaction, which is the total amount of
+// the spent outputs less the total amount of the created ones.
+func (p *Packet) Fee() provautil.Amount {
+	var fee int64
+	for i := range p.Inputs {
+		fee += p.Inputs[i].Amount
+	}
+	for _, txOut := range p.UnsignedTx.TxOut {
+		fee -= txOut.Value
+	}
+	return provautil.Amount(fee)
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pspt_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/pspt"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestPacket ensures a transaction spending a 2-of-3 Prova output can be signed
+// by the user and an ASP independently, combined, finalized and extracted into
+// a transaction which passes script validation.
+func TestPacket(t *testing.T) {
+	userKey, _ := btcec.NewPrivateKey(btcec.S256())
+	aspKey, _ := btcec.NewPrivateKey(btcec.S256())
+	otherKey, _ := btcec.NewPrivateKey(btcec.S256())
+	keyIDs := []btcec.KeyID{1, 2}
+
+	userPubKey := userKey.PubKey().SerializeCompressed()
+	aspPubKey := aspKey.PubKey().SerializeCompressed()
+	addr, err := provautil.NewAddressProva(provautil.Hash160(userPubKey),
+		keyIDs, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(900, pkScript))
+	prevOut := wire.NewTxOut(1000, pkScript)
+
+	p, err := pspt.New(tx, []*wire.TxOut{prevOut})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p.Inputs[0].KeyIDs, keyIDs) ||
+		p.Inputs[0].RequiredSigs != 2 {
+
+		t.Fatalf("New: unexpected input %+v", p.Inputs[0])
+	}
+	if fee := p.Fee(); fee != 100 {
+		t.Fatalf("Fee: got %v, want 100", fee)
+	}
+	signed := tx.Copy()
+	signed.TxIn[0].SignatureScript = []byte{txscript.OP_TRUE}
+	if _, err := pspt.New(signed, []*wire.TxOut{prevOut}); err != pspt.ErrSignedTx {
+		t.Fatalf("New: got %v, want %v", err, pspt.ErrSignedTx)
+	}
+
+	// Each signer decodes its own copy of the packet.
+	encoded, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	sign := func(key *btcec.PrivateKey) *pspt.Packet {
+		p, err := pspt.NewFromB64(encoded)
+		if err != nil {
+			t.Fatalf("NewFromB64: unexpected error: %v", err)
+		}
+		sig, err := txscript.RawTxInSignatureNew(p.UnsignedTx, 0,
+			txscript.NewTxSigHashes(p.UnsignedTx),
+			1000, pkScript, txscript.SigHashAll, key)
+		if err != nil {
+			t.Fatalf("RawTxInSignatureNew: unexpected error: %v", err)
+		}
+		err = p.AddSignature(0, key.PubKey().SerializeCompressed(), sig)
+		if err != nil {
+			t.Fatalf("AddSignature: unexpected error: %v", err)
+		}
+		return p
+	}
+	userPacket := sign(userKey)
+	aspPacket := sign(aspKey)
+
+	// A signature made by a different key than claimed, or one which was
+	// already added, must be rejected.
+	sig := aspPacket.Inputs[0].Sigs[0].Signature
+	if err := userPacket.AddSignature(0, userPubKey, sig); err != pspt.ErrDuplicateSignature {
+		t.Fatalf("AddSignature: got %v, want %v", err,
+			pspt.ErrDuplicateSignature)
+	}
+	otherPubKey := otherKey.PubKey().SerializeCompressed()
+	if err := userPacket.AddSignature(0, otherPubKey, sig); err != pspt.ErrInvalidSignature {
+		t.Fatalf("AddSignature: got %v, want %v", err,
+			pspt.ErrInvalidSignature)
+	}
+	if err := userPacket.AddSignature(1, aspPubKey, sig); err != pspt.ErrInputIndex {
+		t.Fatalf("AddSignature: got %v, want %v", err,
+			pspt.ErrInputIndex)
+	}
+
+	// A single signature is not enough to finalize the input.
+	complete, err := userPacket.Finalize()
+	if err != nil || complete {
+		t.Fatalf("Finalize: got %v (err %v), want false", complete, err)
+	}
+	if _, err := userPacket.Extract(); err != pspt.ErrIncomplete {
+		t.Fatalf("Extract: got %v, want %v", err, pspt.ErrIncomplete)
+	}
+
+	combined, err := pspt.Combine(userPacket, aspPacket)
+	if err != nil {
+		t.Fatalf("Combine: unexpected error: %v", err)
+	}
+	if len(combined.Inputs[0].Sigs) != 2 || len(userPacket.Inputs[0].Sigs) != 1 {
+		t.Fatalf("Combine: unexpected signatures %d combined, %d user",
+			len(combined.Inputs[0].Sigs), len(userPacket.Inputs[0].Sigs))
+	}
+	other, _ := pspt.New(wire.NewMsgTx(1), nil)
+	if _, err := pspt.Combine(userPacket, other); err != pspt.ErrMismatchedPackets {
+		t.Fatalf("Combine: got %v, want %v", err,
+			pspt.ErrMismatchedPackets)
+	}
+
+	complete, err = combined.Finalize()
+	if err != nil || !complete {
+		t.Fatalf("Finalize: got %v (err %v), want true", complete, err)
+	}
+
+	// The finalized packet must survive a round trip.
+	encoded, err = combined.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	decoded, err := pspt.NewFromB64(encoded)
+	if err != nil {
+		t.Fatalf("NewFromB64: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, combined) {
+		t.Fatalf("NewFromB64: got %+v, want %+v", decoded, combined)
+	}
+
+	final, err := decoded.Extract()
+	if err != nil {
+		t.Fatalf("Extract: unexpected error: %v", err)
+	}
+
+	// Resolve the keyIDs to the ASP key and execute the script.
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		t.Fatalf("ParseScript: unexpected error: %v", err)
+	}
+	aspHash := provautil.Hash160(aspPubKey)
+	err = txscript.ReplaceKeyIDs(pops, map[btcec.KeyID][]byte{
+		1: aspHash,
+		2: provautil.Hash160(otherPubKey),
+	})
+	if err != nil {
+		t.Fatalf("ReplaceKeyIDs: unexpected error: %v", err)
+	}
+	resolved, err := txscript.UnparseScript(pops)
+	if err != nil {
+		t.Fatalf("UnparseScript: unexpected error: %v", err)
+	}
+	vm, err := txscript.NewEngine(resolved, final, 0,
+		txscript.ScriptVerifyDERSignatures, nil, nil, 1000)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+}
+
+// TestDeserializeErrors ensures malformed packets are rejected.
+func TestDeserializeErrors(t *testing.T) {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+	pkScript := []byte{txscript.OP_TRUE}
+
+	if _, err := pspt.New(tx, []*wire.TxOut{wire.NewTxOut(1, pkScript)}); err == nil {
+		t.Fatal("New: did not fail on non-Prova script")
+	}
+	if _, err := pspt.New(tx, nil); err != pspt.ErrPrevOutCount {
+		t.Fatalf("New: got %v, want %v", err, pspt.ErrPrevOutCount)
+	}
+
+	// A packet whose metadata does not match the script must be rejected.
+	p := &pspt.Packet{
+		UnsignedTx: tx,
+		Inputs:     []pspt.Input{{Amount: 1, PkScript: pkScript}},
+	}
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	serialized := buf.Bytes()
+	var decoded pspt.Packet
+	if err := decoded.Deserialize(bytes.NewReader(serialized)); err == nil {
+		t.Fatal("Deserialize: did not fail on non-Prova script")
+	}
+
+	serialized[0] ^= 0xff
+	err := decoded.Deserialize(bytes.NewReader(serialized))
+	if err != pspt.ErrInvalidMagic {
+		t.Fatalf("Deserialize: got %v, want %v", err, pspt.ErrInvalidMagic)
+	}
+
+	if _, err := pspt.NewFromB64("not base64!"); err == nil {
+		t.Fatal("NewFromB64: did not fail on invalid encoding")
+	}
+}
+
+// TestAdminRequiredSigs ensures the number of signatures required by admin
+// thread inputs can be set to the threshold of the thread and survives a round
+// trip, while the requirements of other inputs are fixed by their scripts.
+func TestAdminRequiredSigs(t *testing.T) {
+	threadScript, err := txscript.ProvaThreadScript(provautil.RootThread)
+	if err != nil {
+		t.Fatalf("ProvaThreadScript: unexpected error: %v", err)
+	}
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(0, threadScript))
+	p, err := pspt.New(tx, []*wire.TxOut{wire.NewTxOut(0, threadScript)})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if err := p.SetRequiredSigs(0, 3); err != nil {
+		t.Fatalf("SetRequiredSigs: unexpected error: %v", err)
+	}
+	if err := p.SetRequiredSigs(0, 0); err == nil {
+		t.Fatal("SetRequiredSigs: did not fail on a zero threshold")
+	}
+	if err := p.SetRequiredSigs(1, 3); err != pspt.ErrInputIndex {
+		t.Fatalf("SetRequiredSigs: got %v, want %v", err,
+			pspt.ErrInputIndex)
+	}
+	encoded, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: unexpected error: %v", err)
+	}
+	decoded, err := pspt.NewFromB64(encoded)
+	if err != nil {
+		t.Fatalf("NewFromB64: unexpected error: %v", err)
+	}
+	if decoded.Inputs[0].RequiredSigs != 3 {
+		t.Fatalf("NewFromB64: got %d required signatures, want 3",
+			decoded.Inputs[0].RequiredSigs)
+	}
+
+	addr, err := provautil.NewAddressProva(bytes.Repeat([]byte{1}, 20),
+		[]btcec.KeyID{1, 2}, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	p, err = pspt.New(tx, []*wire.TxOut{wire.NewTxOut(1, pkScript)})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if err := p.SetRequiredSigs(0, 3); err == nil {
+		t.Fatal("SetRequiredSigs: did not fail on a Prova input")
+	}
+}
@@ -5,6 +5,8 @@
 package provautil
 
 import (
+	"fmt"
+
 	"github.com/bitgo/prova/wire"
 )
 
@@ -14,6 +16,20 @@ const IssueThread = ThreadID(2)
 
 type ThreadID uint8
 
+// String returns the ThreadID as a human-readable name.
+func (t ThreadID) String() string {
+	switch t {
+	case RootThread:
+		return "ROOT"
+	case ProvisionThread:
+		return "PROVISION"
+	case IssueThread:
+		return "ISSUE"
+	default:
+		return fmt.Sprintf("Unknown ThreadID (%d)", uint8(t))
+	}
+}
+
 func CopyThreadTips(threadTips map[ThreadID]*wire.OutPoint) map[ThreadID]*wire.OutPoint {
 	threadTipsCopy := make(map[ThreadID]*wire.OutPoint)
 	for threadId, outPoint := range threadTips {
@@ -0,0 +1,88 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/txscript"
+)
+
+// rehearsalDbNamePrefix is the prefix for the scratch database which houses
+// the chain state built while rehearsing an upgrade.
+const rehearsalDbNamePrefix = "rehearsal"
+
+// rehearseUpgrade replays the main chain in the passed block database with the
+// rule changes requested via the rehearseupgrade option forced active, and
+// reports the first block which violates them.  The chain state built while
+// replaying is housed in a scratch database next to the block database which
+// is removed once the rehearsal completes.  An error is returned when the
+// rehearsal diverges from the historical chain.
+func rehearseUpgrade(db database.DB, interrupt <-chan struct{}) error {
+	var rehearsalDB database.DB
+	var err error
+	if cfg.DbType == "memdb" {
+		rehearsalDB, err = database.Create(cfg.DbType)
+		if err != nil {
+			return err
+		}
+	} else {
+		dbPath := filepath.Join(cfg.DataDir, rehearsalDbNamePrefix+"_"+
+			cfg.DbType)
+		if err := os.RemoveAll(dbPath); err != nil {
+			return err
+		}
+		rehearsalDB, err = database.Create(cfg.DbType, dbPath,
+			activeNetParams.Net)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dbPath)
+	}
+	defer rehearsalDB.Close()
+
+	// Abort the rehearsal when an interrupt is received.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	btcdLog.Infof("Rehearsing upgrade with %v forced active",
+		cfg.rehearseDeployments)
+	progressLogger := newBlockProgressLogger("Replayed", btcdLog)
+	result, err := blockchain.Rehearse(ctx, &blockchain.RehearsalConfig{
+		SourceDB:          db,
+		DB:                rehearsalDB,
+		ChainParams:       activeNetParams.Params,
+		ForcedDeployments: cfg.rehearseDeployments,
+		SigCache:          txscript.NewSigCache(cfg.SigCacheMaxSize),
+		Progress:          progressLogger.LogBlockHeight,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Diverged() {
+		return fmt.Errorf("rehearsal of %v diverged at block %v "+
+			"(height %d) after replaying %d blocks: %v",
+			cfg.rehearseDeployments, result.DivergenceHash,
+			result.DivergenceHeight, result.BlocksReplayed,
+			result.DivergenceErr)
+	}
+	btcdLog.Infof("Rehearsal of %v replayed all %d blocks up to %v "+
+		"(height %d) without diverging", cfg.rehearseDeployments,
+		result.BlocksReplayed, result.Hash, result.Height)
+	return nil
+}
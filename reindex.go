@@ -0,0 +1,92 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/bitgo/prova/database"
+)
+
+// reindexChainState rebuilds the chain state in the passed block database from
+// the blocks already stored in it as requested via the reindexchainstate
+// option.  The enabled optional indexes are dropped first, so the index manager
+// rebuilds them in the background once the server has started.  A reindex
+// which is stopped by an interrupt is resumed the next time the option is set.
+func reindexChainState(db database.DB, interrupt <-chan struct{}) error {
+	// Drop the enabled indexes.  The address index is dropped along with
+	// the transaction index it relies on.
+	drops := []struct {
+		enabled bool
+		drop    func(database.DB) error
+	}{
+		{cfg.TxIndex || cfg.AddrIndex, indexers.DropTxIndex},
+		{cfg.CfIndex, indexers.DropCfIndex},
+		{cfg.SpentIndex, indexers.DropSpentIndex},
+		{cfg.KeyIDBalIndex, indexers.DropKeyIDBalanceIndex},
+		{cfg.SupplyIndex, indexers.DropSupplyIndex},
+		{cfg.TimestampIndex, indexers.DropTimestampIndex},
+		{cfg.StatsIndex, indexers.DropStatsIndex},
+		{cfg.UtxoCommitIndex, indexers.DropUtxoCommitIndex},
+	}
+	for _, d := range drops {
+		if !d.enabled {
+			continue
+		}
+		if err := d.drop(db); err != nil {
+			return err
+		}
+	}
+
+	// Abort the reindex when an interrupt is received.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	btcdLog.Info("Rebuilding the chain state from the stored blocks")
+	progressLogger := newBlockProgressLogger("Reindexed", btcdLog)
+	best, err := blockchain.ReindexChainState(ctx, &blockchain.ReindexConfig{
+		DB:          db,
+		ChainParams: activeNetParams.Params,
+		Progress:    progressLogger.LogBlockHeight,
+	})
+	if err == context.Canceled {
+		btcdLog.Info("Chain state reindex interrupted -- start with " +
+			"--reindexchainstate to resume it")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	btcdLog.Infof("Rebuilt the chain state up to block %v (height %d)",
+		best.Hash, best.Height)
+	return nil
+}
+
+// checkReindexPending returns an error when the chain state in the passed block
+// database was left behind by an interrupted chain state reindex, which must
+// be finished before the chain state can be used.
+func checkReindexPending(db database.DB) error {
+	pending, err := blockchain.ReindexPending(db)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return errors.New("the chain state is incomplete since a chain " +
+			"state reindex was interrupted -- start with " +
+			"--reindexchainstate to finish it")
+	}
+	return nil
+}
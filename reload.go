@@ -0,0 +1,213 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/bitgo/prova/auditlog"
+	"github.com/bitgo/prova/mining"
+)
+
+// reloadableOptions are the options, named by their long flag, which are
+// applied when the configuration is reloaded while the node is running.  The
+// changes to the other options are only reported, since they take effect
+// after a restart.
+var reloadableOptions = map[string]struct{}{
+	// Logging.
+	"debuglevel": {},
+
+	// RPC rate limits.
+	"rpclimit":     {},
+	"rpcslowquery": {},
+
+	// Mining policy.
+	"blockminsize":      {},
+	"blockmaxsize":      {},
+	"blockprioritysize": {},
+	"miningaddr":        {},
+
+	// Banning and whitelisting of peers.
+	"nobanning":        {},
+	"banduration":      {},
+	"banthreshold":     {},
+	"banwarnthreshold": {},
+	"msglimitbanscore": {},
+	"whitelist":        {},
+
+	// Fees.
+	"minrelaytxfee":  {},
+	"limitfreerelay": {},
+	"relaypriority":  {},
+}
+
+// changedOptions returns the sorted long names of the options which differ
+// between the passed configurations.
+func changedOptions(oldCfg, newCfg *config) []string {
+	oldValue := reflect.ValueOf(oldCfg).Elem()
+	newValue := reflect.ValueOf(newCfg).Elem()
+	cfgType := oldValue.Type()
+
+	var changed []string
+	for i := 0; i < cfgType.NumField(); i++ {
+		name := cfgType.Field(i).Tag.Get("long")
+		if name == "" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(),
+			newValue.Field(i).Interface()) {
+
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// splitReloadable splits the passed option names into the ones which are
+// applied on reload and the ones which require a restart.
+func splitReloadable(names []string) ([]string, []string) {
+	var applied, restart []string
+	for _, name := range names {
+		if _, ok := reloadableOptions[name]; ok {
+			applied = append(applied, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+	return applied, restart
+}
+
+// applyConfig applies the reloadable options of the passed configuration to
+// the running server.  The debug levels are only set again when they changed,
+// so the levels set with the debuglevel RPC are otherwise kept.
+func (s *server) applyConfig(newCfg *config, debugLevelChanged bool) {
+	if debugLevelChanged {
+		// The levels were validated when the config was parsed.
+		setLogLevels(defaultLogLevel)
+		parseAndSetDebugLevels(newCfg.DebugLevel)
+	}
+
+	if s.rpcServer != nil {
+		s.rpcServer.metrics.setLimits(newCfg.rpcLimits,
+			newCfg.RPCSlowQuery)
+	}
+
+	s.blockTemplateGenerator.SetPolicy(mining.Policy{
+		BlockMinSize:      newCfg.BlockMinSize,
+		BlockMaxSize:      newCfg.BlockMaxSize,
+		BlockPrioritySize: newCfg.BlockPrioritySize,
+		TxMinFreeFee:      newCfg.minRelayTxFee,
+	})
+	s.cpuMiner.SetMiningAddrs(newCfg.miningAddrs)
+
+	policy := s.txMemPool.Policy()
+	policy.DisableRelayPriority = !newCfg.RelayPriority
+	policy.FreeTxRelayLimit = newCfg.FreeTxRelayLimit
+	policy.MinRelayTxFee = newCfg.minRelayTxFee
+	s.txMemPool.SetPolicy(policy)
+
+	// The whitelists apply to the peers which connect afterwards.
+	s.reloadMtx.Lock()
+	s.peerPolicy = newPeerPolicy(newCfg)
+	s.reloadMtx.Unlock()
+}
+
+// reloadConfig loads the configuration again and applies the reloadable
+// options which changed since it was last loaded.  The changes to the options
+// which take effect after a restart are logged as such.  The running
+// configuration is kept when the reloaded one is invalid.
+func (s *server) reloadConfig() {
+	newCfg, _, err := parseConfig(true)
+	if err != nil {
+		srvrLog.Errorf("Failed to reload the configuration, keeping "+
+			"the running one: %v", err)
+		return
+	}
+
+	s.reloadMtx.Lock()
+	oldCfg := s.loadedCfg
+	s.loadedCfg = newCfg
+	s.reloadMtx.Unlock()
+
+	applied, restart := splitReloadable(changedOptions(oldCfg, newCfg))
+	if len(applied) == 0 && len(restart) == 0 {
+		srvrLog.Infof("Reloaded the configuration: no options changed")
+		return
+	}
+	debugLevelChanged := oldCfg.DebugLevel != newCfg.DebugLevel
+	s.applyConfig(newCfg, debugLevelChanged)
+	s.auditConfigChange(applied, restart)
+	if len(applied) > 0 {
+		srvrLog.Infof("Reloaded the configuration: applied the changes "+
+			"to %s", strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		srvrLog.Warnf("Reloaded the configuration: the changes to %s "+
+			"require a restart to take effect",
+			strings.Join(restart, ", "))
+	}
+}
+
+// auditConfigChange records the names of the options which changed when the
+// configuration was reloaded in the audit log when it is enabled.  Their values
+// are not recorded, since some of them hold passwords.
+func (s *server) auditConfigChange(applied, restart []string) {
+	if s.auditLog == nil {
+		return
+	}
+	details := make(map[string]string)
+	if len(applied) > 0 {
+		details["applied"] = strings.Join(applied, ",")
+	}
+	if len(restart) > 0 {
+		details["restart"] = strings.Join(restart, ",")
+	}
+	err := s.auditLog.Record(auditlog.ConfigChange, "reloadconfig", details)
+	if err != nil {
+		srvrLog.Errorf("Failed to record the configuration reload in "+
+			"the audit log: %v", err)
+	}
+}
+
+// reloadScreening reads the screening list again when screening is enabled.
+// The running watchlist is kept when the list is invalid.
+func (s *server) reloadScreening() {
+	if s.screening == nil {
+		return
+	}
+	if err := s.screening.Reload(); err != nil {
+		srvrLog.Errorf("Failed to reload the screening list, keeping "+
+			"the running one: %v", err)
+	}
+}
+
+// reloadHandler reloads the configuration whenever one of the reload signals
+// is received until the server is shutting down.  It must be run as a
+// goroutine.
+func (s *server) reloadHandler() {
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, reloadSignals...)
+	defer signal.Stop(reloadChannel)
+
+out:
+	for {
+		select {
+		case sig := <-reloadChannel:
+			srvrLog.Infof("Received signal (%s).  Reloading the "+
+				"configuration...", sig)
+			s.reloadConfig()
+			s.reloadScreening()
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestReloadableOptions ensures every reloadable option names an option of the
+// configuration.
+func TestReloadableOptions(t *testing.T) {
+	names := make(map[string]struct{})
+	cfgType := reflect.TypeOf(config{})
+	for i := 0; i < cfgType.NumField(); i++ {
+		if name := cfgType.Field(i).Tag.Get("long"); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	for name := range reloadableOptions {
+		if _, ok := names[name]; !ok {
+			t.Errorf("reloadable option %q is not a config option",
+				name)
+		}
+	}
+}
+
+// TestChangedOptions ensures the options which differ between configurations
+// are found and split into the reloadable ones and the ones requiring a
+// restart.
+func TestChangedOptions(t *testing.T) {
+	oldCfg := &config{
+		DebugLevel:  "info",
+		BanDuration: time.Hour,
+		Whitelists:  []string{"10.0.0.0/8"},
+		MaxPeers:    125,
+	}
+	newCfg := *oldCfg
+	if changed := changedOptions(oldCfg, &newCfg); len(changed) != 0 {
+		t.Errorf("changedOptions: got %v for equal configs", changed)
+	}
+
+	newCfg.DebugLevel = "debug"
+	newCfg.Whitelists = []string{"10.0.0.0/8", "192.168.0.0/16"}
+	newCfg.MaxPeers = 8
+	newCfg.whitelists = nil
+	changed := changedOptions(oldCfg, &newCfg)
+	want := []string{"debuglevel", "maxpeers", "whitelist"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changedOptions: got %v, want %v", changed, want)
+	}
+
+	applied, restart := splitReloadable(changed)
+	if !reflect.DeepEqual(applied, []string{"debuglevel", "whitelist"}) {
+		t.Errorf("splitReloadable: got applied %v", applied)
+	}
+	if !reflect.DeepEqual(restart, []string{"maxpeers"}) {
+		t.Errorf("splitReloadable: got restart %v", restart)
+	}
+}
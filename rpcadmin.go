@@ -0,0 +1,907 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/pspt"
+	"github.com/bitgo/prova/txrules/adminbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
+	"github.com/bitgo/prova/wire"
+)
+
+// pubKeySetStrings returns the hex encoded public keys of the passed key set,
+// or an empty slice when the key set is empty.
+func pubKeySetStrings(keySet btcec.PublicKeySet) []string {
+	keys := keySet.ToStringArray()
+	if keys == nil {
+		keys = []string{}
+	}
+	return keys
+}
+
+// handleAdminListKeySets implements the admin.listkeysets command.
+func handleAdminListKeySets(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	adminKeySets := s.chain.AdminKeySets()
+
+	aspKeyIDs := s.chain.KeyIDs()
+	frozenKeyIDs := s.chain.FrozenKeyIDs()
+	asp := make([]btcjson.ASPKeyIdResult, 0, len(aspKeyIDs))
+	for keyID, pubKey := range aspKeyIDs {
+		asp = append(asp, btcjson.ASPKeyIdResult{
+			KeyID:  uint32(keyID),
+			PubKey: hex.EncodeToString(pubKey.SerializeCompressed()),
+			Frozen: frozenKeyIDs[keyID] != nil,
+		})
+	}
+	sort.Slice(asp, func(i, j int) bool {
+		return asp[i].KeyID < asp[j].KeyID
+	})
+
+	return &btcjson.AdminListKeySetsResult{
+		Hash:      best.Hash.String(),
+		Height:    best.Height,
+		LastKeyID: uint32(s.chain.LastKeyID()),
+		Root:      pubKeySetStrings(adminKeySets[btcec.RootKeySet]),
+		Provision: pubKeySetStrings(adminKeySets[btcec.ProvisionKeySet]),
+		Issue:     pubKeySetStrings(adminKeySets[btcec.IssueKeySet]),
+		Validate:  pubKeySetStrings(adminKeySets[btcec.ValidateKeySet]),
+		ASP:       asp,
+	}, nil
+}
+
+// handleAdminGetKeyIDInfo implements the admin.getkeyidinfo command.
+func handleAdminGetKeyIDInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminGetKeyIDInfoCmd)
+
+	keyID := btcec.KeyID(c.KeyID)
+	if keyID == 0 || keyID > s.chain.LastKeyID() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("KeyID %d has not been assigned", keyID),
+		}
+	}
+
+	// KeyIDs which have been assigned but are no longer mapped to a key
+	// belong to revoked ASP keys.  Frozen keyIDs remain frozen when their
+	// keys are revoked.
+	result := &btcjson.AdminKeyIDInfoResult{KeyID: c.KeyID}
+	if pubKey, ok := s.chain.KeyIDs()[keyID]; ok {
+		result.Active = true
+		result.PubKey = hex.EncodeToString(pubKey.SerializeCompressed())
+	}
+	if pubKey, ok := s.chain.FrozenKeyIDs()[keyID]; ok {
+		result.Frozen = true
+		result.PubKey = hex.EncodeToString(pubKey.SerializeCompressed())
+	}
+	return result, nil
+}
+
+// handleAdminProvisionValidateKey implements the admin.provisionvalidatekey
+// command.
+func handleAdminProvisionValidateKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminProvisionValidateKeyCmd)
+
+	ops, err := parseAdminKeyOps([]btcjson.AdminKeyOp{{
+		KeySet: btcec.ValidateKeySet.String(),
+		PubKey: c.PubKey,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	mtx, err := adminbuilder.NewProvisionTx(s.chain.ThreadTips(),
+		s.chain.LastKeyID(), ops)
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("provision validate key %s", c.PubKey)
+	return finishAdminTx(s, "admin.provisionvalidatekey", desc, mtx,
+		*c.Submit)
+}
+
+// handleAdminRevokeKey implements the admin.revokekey command.
+func handleAdminRevokeKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminRevokeKeyCmd)
+
+	ops, err := parseAdminKeyOps([]btcjson.AdminKeyOp{c.KeyOp})
+	if err != nil {
+		return nil, err
+	}
+	mtx, err := adminbuilder.NewKeyRevokeTx(s.chain.ThreadTips(), ops)
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("revoke %s key %s", ops[0].KeySet, c.KeyOp.PubKey)
+	if ops[0].KeySet == btcec.ASPKeySet {
+		desc += fmt.Sprintf(" with keyID %d", ops[0].KeyID)
+	}
+	return finishAdminTx(s, "admin.revokekey", desc, mtx, *c.Submit)
+}
+
+// handleAdminIssueTokens implements the admin.issuetokens command.
+func handleAdminIssueTokens(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminIssueTokensCmd)
+
+	mtx, total, err := newIssueTx(s, c.Amounts)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]string, 0, len(c.Amounts))
+	for encodedAddr := range c.Amounts {
+		recipients = append(recipients, encodedAddr)
+	}
+	sort.Strings(recipients)
+	desc := fmt.Sprintf("issue %v to %s", total,
+		strings.Join(recipients, ", "))
+	return finishAdminTx(s, "admin.issuetokens", desc, mtx, *c.Submit)
+}
+
+// handleAdminDestroyTokens implements the admin.destroytokens command.
+func handleAdminDestroyTokens(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminDestroyTokensCmd)
+
+	mtx, err := newDestroyTx(s, c.Inputs, c.Amount, c.ChangeAddress)
+	if err != nil {
+		return nil, err
+	}
+	amount, _ := provautil.NewAmount(c.Amount)
+	desc := fmt.Sprintf("destroy %v out of %d inputs", amount,
+		len(c.Inputs))
+	return finishAdminTx(s, "admin.destroytokens", desc, mtx, *c.Submit)
+}
+
+// handleAdminFreezeKeyID implements the admin.freezekeyid command.
+func handleAdminFreezeKeyID(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminFreezeKeyIDCmd)
+
+	keyID := btcec.KeyID(c.KeyID)
+	pubKey, ok := s.chain.KeyIDs()[keyID]
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("KeyID %d is not active", keyID),
+		}
+	}
+	mtx, err := adminbuilder.NewKeyIDFreezeTx(s.chain.ThreadTips(), true,
+		[]adminbuilder.KeyOp{{
+			KeySet: btcec.ASPKeySet,
+			PubKey: pubKey,
+			KeyID:  keyID,
+		}})
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("freeze keyID %d", keyID)
+	return finishAdminTx(s, "admin.freezekeyid", desc, mtx, *c.Submit)
+}
+
+// handleAdminUnfreezeKeyID implements the admin.unfreezekeyid command.
+func handleAdminUnfreezeKeyID(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminUnfreezeKeyIDCmd)
+
+	keyID := btcec.KeyID(c.KeyID)
+	pubKey, ok := s.chain.FrozenKeyIDs()[keyID]
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("KeyID %d is not frozen", keyID),
+		}
+	}
+	mtx, err := adminbuilder.NewKeyIDFreezeTx(s.chain.ThreadTips(), false,
+		[]adminbuilder.KeyOp{{
+			KeySet: btcec.ASPKeySet,
+			PubKey: pubKey,
+			KeyID:  keyID,
+		}})
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("unfreeze keyID %d", keyID)
+	return finishAdminTx(s, "admin.unfreezekeyid", desc, mtx, *c.Submit)
+}
+
+// rotatedKeyIDScript returns the script paying to the standard Prova address
+// the passed script pays to with the old keyID replaced by the new one.  Nil
+// is returned for any other kind of script, which is not migrated.
+func rotatedKeyIDScript(pkScript []byte, oldKeyID, newKeyID btcec.KeyID, params *chaincfg.Params) []byte {
+	if txscript.GetScriptClass(pkScript) != txscript.ProvaTy {
+		return nil
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addrs) != 1 {
+		return nil
+	}
+	addr, ok := addrs[0].(*provautil.AddressProva)
+	if !ok {
+		return nil
+	}
+
+	keyIDs := make([]btcec.KeyID, 0, len(addr.ScriptKeyIDs()))
+	for _, keyID := range addr.ScriptKeyIDs() {
+		if keyID == oldKeyID {
+			keyID = newKeyID
+		}
+		keyIDs = append(keyIDs, keyID)
+	}
+	rotated, err := provautil.NewAddressProva(addr.ScriptAddress(), keyIDs,
+		params)
+	if err != nil {
+		return nil
+	}
+	rotatedScript, err := txscript.PayToAddrScript(rotated)
+	if err != nil {
+		return nil
+	}
+	return rotatedScript
+}
+
+// handleAdminRotateKeyID implements the admin.rotatekeyid command.  It creates
+// the provision thread transaction assigning the next keyID to the replacement
+// ASP key, along with the unsigned transactions migrating the funds co-signed
+// by the old keyID to the same addresses co-signed by the new keyID.
+func handleAdminRotateKeyID(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminRotateKeyIDCmd)
+
+	// Respond with an error if the keyID balance index is not enabled.
+	keyIDBalIndex := s.server.indexes().keyIDBalanceIndex
+	if keyIDBalIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "KeyID balance index must be enabled (--keyidbalanceindex)",
+		}
+	}
+	keyID := btcec.KeyID(c.KeyID)
+	if _, ok := s.chain.KeyIDs()[keyID]; !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("KeyID %d is not active", keyID),
+		}
+	}
+	if _, ok := s.chain.FrozenKeyIDs()[keyID]; ok {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("KeyID %d is frozen and its outputs "+
+				"can not be migrated", keyID),
+		}
+	}
+	ops, err := parseAdminKeyOps([]btcjson.AdminKeyOp{{
+		KeySet: btcec.ASPKeySet.String(),
+		PubKey: c.PubKey,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	feePerKB, err := rpcFeeRate(s, c.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	// The replacement key is assigned the keyID following the last one.
+	lastKeyID := s.chain.LastKeyID()
+	newKeyID := lastKeyID + 1
+	mtx, err := adminbuilder.NewProvisionTx(s.chain.ThreadTips(), lastKeyID,
+		ops)
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos, err := keyIDBalIndex.UtxosForKeyID(keyID)
+	if err != nil {
+		context := "Failed to load keyID utxos"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Group the spendable outputs by the script they migrate to, in the
+	// order they were created.  Outputs which can not be migrated, such as
+	// timelocked or immature ones, are reported as skipped.
+	params := s.server.chainParams
+	best := s.chain.BestSnapshot()
+	maturity := uint32(params.CoinbaseMaturity)
+	result := &btcjson.AdminRotateKeyIDResult{
+		KeyID:    c.KeyID,
+		NewKeyID: uint32(newKeyID),
+		Sweeps:   []btcjson.TemplateTxResult{},
+		Skipped:  []btcjson.AddressUtxoResult{},
+	}
+	var scripts [][]byte
+	coinsByScript := make(map[string][]wallet.Coin)
+	prevOuts := make(map[wire.OutPoint]btcjson.AddressUtxoResult)
+	for _, utxo := range utxos {
+		if s.server.txMemPool.CheckSpend(utxo.OutPoint) != nil {
+			continue
+		}
+		prevOut := btcjson.AddressUtxoResult{
+			Txid:        utxo.OutPoint.Hash.String(),
+			OutputIndex: utxo.OutPoint.Index,
+			Script:      hex.EncodeToString(utxo.PkScript),
+			Atoms:       utxo.Amount,
+			Height:      utxo.Height,
+		}
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(utxo.PkScript,
+			params)
+		if len(addrs) > 0 {
+			prevOut.Address = addrs[0].EncodeAddress()
+		}
+
+		pkScript := rotatedKeyIDScript(utxo.PkScript, keyID, newKeyID,
+			params)
+		if pkScript != nil && best.Height-utxo.Height+1 < maturity {
+			entry, err := s.chain.FetchUtxoEntry(&utxo.OutPoint.Hash)
+			if err != nil {
+				context := "Failed to fetch utxo"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if entry == nil || entry.IsCoinBase() {
+				pkScript = nil
+			}
+		}
+		if pkScript == nil {
+			result.Skipped = append(result.Skipped, prevOut)
+			continue
+		}
+		if _, ok := coinsByScript[string(pkScript)]; !ok {
+			scripts = append(scripts, pkScript)
+		}
+		coinsByScript[string(pkScript)] = append(
+			coinsByScript[string(pkScript)], wallet.Coin{
+				OutPoint: utxo.OutPoint,
+				Amount:   provautil.Amount(utxo.Amount),
+				PkScript: utxo.PkScript,
+			})
+		prevOuts[utxo.OutPoint] = prevOut
+	}
+
+	// Sweep the outputs of each script in batches of the maximum size of
+	// the transactions built from templates.  Batches which do not cover
+	// the fee and a non-dust output are skipped.
+	for _, pkScript := range scripts {
+		coins := coinsByScript[string(pkScript)]
+		maxInputs := wallet.MaxSweepInputs(pkScript)
+		for len(coins) > 0 {
+			batch := coins
+			if len(batch) > maxInputs {
+				batch = batch[:maxInputs]
+			}
+			coins = coins[len(batch):]
+
+			sweepTx, fee, err := wallet.NewSweepTx(batch, pkScript,
+				feePerKB)
+			if err == wallet.ErrInsufficientFunds {
+				for _, coin := range batch {
+					result.Skipped = append(result.Skipped,
+						prevOuts[coin.OutPoint])
+				}
+				continue
+			}
+			if err != nil {
+				return nil, templateTxError(err)
+			}
+			sweep, err := templateTxResult(sweepTx, fee, prevOuts)
+			if err != nil {
+				return nil, err
+			}
+			result.Sweeps = append(result.Sweeps, *sweep)
+		}
+	}
+
+	desc := fmt.Sprintf("rotate keyID %d to keyID %d with ASP key %s "+
+		"migrating %d outputs in %d transactions", keyID, newKeyID,
+		c.PubKey, len(prevOuts), len(result.Sweeps))
+	provision, err := finishAdminTx(s, "admin.rotatekeyid", desc, mtx,
+		*c.Submit)
+	if err != nil {
+		return nil, err
+	}
+	result.Provision = provision.(*btcjson.AdminTxResult)
+	return result, nil
+}
+
+// signAdminTx signs the inputs of the passed admin transaction with those of
+// the configured admin keys which are able to spend them, whether they were
+// given with --adminkey or are held by a hardware security module or a remote
+// signing service.  The returned errors describe the inputs which are not fully
+// signed yet.
+func signAdminTx(s *rpcServer, mtx *wire.MsgTx) ([]btcjson.SignRawTransactionError, error) {
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(s.chain.KeyIDs())
+	keyView.SetKeys(s.chain.AdminKeySets())
+	keyView.SetAdminThresholds(s.server.chainParams.AdminThresholds)
+	sigHashes := txscript.NewTxSigHashes(mtx)
+	var signErrors []btcjson.SignRawTransactionError
+	for i, txIn := range mtx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("No unspent output %v",
+					prevOut),
+			}
+		}
+
+		err = signRawTxInput(s, mtx, i, sigHashes,
+			entry.PkScriptByIndex(prevOut.Index),
+			entry.AmountByIndex(prevOut.Index), cfg.adminKeys, keyView)
+		if err != nil {
+			signErrors = append(signErrors, btcjson.SignRawTransactionError{
+				TxID:      prevOut.Hash.String(),
+				Vout:      prevOut.Index,
+				ScriptSig: hex.EncodeToString(txIn.SignatureScript),
+				Sequence:  txIn.Sequence,
+				Error:     err.Error(),
+			})
+		}
+	}
+	return signErrors, nil
+}
+
+// finishAdminTx signs the passed admin transaction created by the named admin
+// method with the configured admin keys, if any, and submits it to the network
+// when requested and fully signed.  Every step is logged along with the passed
+// description of the operation, so the changes to the admin state of the chain
+// made through the node can be audited.
+func finishAdminTx(s *rpcServer, method, desc string, mtx *wire.MsgTx, submit bool) (interface{}, error) {
+	result := &btcjson.AdminTxResult{}
+	if len(cfg.adminKeys) != 0 {
+		signErrors, err := signAdminTx(s, mtx)
+		if err != nil {
+			return nil, err
+		}
+		result.Complete = len(signErrors) == 0
+		result.Errors = signErrors
+	}
+
+	mtxHex, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
+	}
+	tx := provautil.NewTx(mtx)
+	result.TxID = tx.Hash().String()
+	result.Hex = mtxHex
+	rpcsLog.Infof("Admin %s: created transaction %v to %s (signed: %v)",
+		method, tx.Hash(), desc, result.Complete)
+
+	if !submit {
+		return result, nil
+	}
+	if !result.Complete {
+		rpcsLog.Warnf("Admin %s: not submitting transaction %v which "+
+			"is not fully signed by the configured admin keys",
+			method, tx.Hash())
+		return result, nil
+	}
+	if err := submitTransaction(s, tx); err != nil {
+		rpcsLog.Warnf("Admin %s: failed to submit transaction %v: %v",
+			method, tx.Hash(), err)
+		return nil, err
+	}
+	result.Submitted = true
+	rpcsLog.Infof("Admin %s: submitted transaction %v", method, tx.Hash())
+	return result, nil
+}
+
+// adminProposalResult returns the result of the proposal commands for the
+// passed proposal.
+func adminProposalResult(p *adminProposal) (*btcjson.AdminProposalResult, error) {
+	encoded, err := encodePSPT(p.packet)
+	if err != nil {
+		return nil, err
+	}
+	hash := p.hash()
+	result := &btcjson.AdminProposalResult{
+		TxID:        hash.String(),
+		Description: p.description,
+		Created:     p.created.Unix(),
+		PSPT:        encoded,
+		Inputs: make([]btcjson.AdminProposalInputResult, 0,
+			len(p.packet.Inputs)),
+		Complete:  p.packet.IsComplete(),
+		Submitted: p.submitted,
+	}
+	for i := range p.packet.Inputs {
+		signers := make([]string, 0, p.packet.Inputs[i].RequiredSigs)
+		for _, pubKey := range p.signers(i) {
+			signers = append(signers, hex.EncodeToString(pubKey))
+		}
+		result.Inputs = append(result.Inputs, btcjson.AdminProposalInputResult{
+			RequiredSigs: p.packet.Inputs[i].RequiredSigs,
+			Signers:      signers,
+			Finalized:    p.packet.Inputs[i].IsFinalized(),
+		})
+	}
+	if result.Complete {
+		mtx, err := p.packet.Extract()
+		if err != nil {
+			context := "Failed to extract transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Hex, err = messageToHex(mtx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// addProposalSig adds the signature produced by the passed serialized public
+// key to the input at the passed index of a proposal, once the key is checked
+// to be able to sign the input.  Signatures which were already added are
+// ignored.
+func addProposalSig(s *rpcServer, p *adminProposal, idx int, pubKey, sig []byte) error {
+	authorized, err := psptSignerAuthorized(s,
+		p.packet.Inputs[idx].PkScript, pubKey)
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Public key %x is not able to sign "+
+				"input %d", pubKey, idx),
+		}
+	}
+	err = p.packet.AddSignature(idx, pubKey, sig)
+	if err != nil && err != pspt.ErrDuplicateSignature &&
+		err != pspt.ErrFinalized {
+
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unable to add signature to input "+
+				"%d: %v", idx, err),
+		}
+	}
+	return nil
+}
+
+// finishProposal finalizes the inputs of the passed proposal which have the
+// signatures they require, and submits the transaction to the network once all
+// of them are finalized.  A failure to submit the transaction is returned as a
+// message rather than an error, since the signatures collected are kept
+// regardless.
+func finishProposal(s *rpcServer, method string, p *adminProposal) (string, error) {
+	complete, err := p.packet.Finalize()
+	if err != nil {
+		return "", &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to finalize proposal: " + err.Error(),
+		}
+	}
+	if !complete || p.submitted {
+		return "", nil
+	}
+
+	mtx, err := p.packet.Extract()
+	if err != nil {
+		context := "Failed to extract transaction"
+		return "", internalRPCError(err.Error(), context)
+	}
+	tx := provautil.NewTx(mtx)
+	if err := submitTransaction(s, tx); err != nil {
+		rpcsLog.Warnf("Admin %s: failed to submit proposed transaction "+
+			"%v: %v", method, tx.Hash(), err)
+		return err.Error(), nil
+	}
+	p.submitted = true
+	rpcsLog.Infof("Admin %s: submitted proposed transaction %v to %s",
+		method, tx.Hash(), p.description)
+	return "", nil
+}
+
+// updateProposal applies the passed update to the proposal of the transaction
+// with the passed hash on behalf of the named admin method, then finalizes and
+// submits it when it has every signature it requires.
+func updateProposal(s *rpcServer, method, txID string, update func(p *adminProposal) error) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil {
+		return nil, rpcDecodeHexError(txID)
+	}
+	var submitError string
+	p, err := s.server.adminProposals.Update(hash, func(p *adminProposal) error {
+		if err := update(p); err != nil {
+			return err
+		}
+		var err error
+		submitError, err = finishProposal(s, method, p)
+		return err
+	})
+	if err != nil {
+		if _, ok := err.(*btcjson.RPCError); ok {
+			return nil, err
+		}
+		context := "Failed to update proposal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if p == nil {
+		return nil, noProposalError(txID)
+	}
+
+	result, err := adminProposalResult(p)
+	if err != nil {
+		return nil, err
+	}
+	result.SubmitError = submitError
+	rpcsLog.Infof("Admin %s: updated proposed transaction %v (complete: %v)",
+		method, hash, result.Complete)
+	return result, nil
+}
+
+// noProposalError returns the error for a transaction which is not proposed.
+func noProposalError(txID string) error {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCNoTxInfo,
+		Message: fmt.Sprintf("No proposal for transaction %s", txID),
+	}
+}
+
+// handleAdminProposeTx implements the admin.proposetx command.  It registers
+// an admin transaction for co-signing by the admin keyholders, keeping the
+// signatures it already carries.
+func handleAdminProposeTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminProposeTxCmd)
+
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+
+	// The signatures of the transaction, such as those of the admin keys
+	// of the node added by the admin commands, are moved to the proposal.
+	sigScripts := make([][]byte, len(mtx.TxIn))
+	for i, txIn := range mtx.TxIn {
+		sigScripts[i] = txIn.SignatureScript
+		txIn.SignatureScript = nil
+	}
+	if _, err := checkAdminTx(s, &mtx, nil); err != nil {
+		return nil, err
+	}
+	packet, err := newPSPT(s, &mtx)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin := false
+	for i := range packet.Inputs {
+		if txscript.GetScriptClass(packet.Inputs[i].PkScript) ==
+			txscript.ProvaAdminTy {
+
+			isAdmin = true
+		}
+	}
+	if !isAdmin {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The transaction does not spend an admin thread",
+		}
+	}
+
+	p := &adminProposal{
+		created: time.Unix(time.Now().Unix(), 0),
+		packet:  packet,
+	}
+	if c.Description != nil {
+		p.description = *c.Description
+	}
+	for i, sigScript := range sigScripts {
+		pushes, err := txscript.PushedData(sigScript)
+		if err != nil || len(pushes)%2 != 0 {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Input %d has an invalid "+
+					"signature script", i),
+			}
+		}
+		for j := 0; j < len(pushes); j += 2 {
+			err := addProposalSig(s, p, i, pushes[j], pushes[j+1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.server.adminProposals.Add(p); err != nil {
+		if err == errProposalExists {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "The transaction is already proposed",
+			}
+		}
+		context := "Failed to store proposal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	hash := p.hash()
+	rpcsLog.Infof("Admin admin.proposetx: proposed transaction %v to %s",
+		&hash, p.description)
+
+	// Transactions which carry every signature they require are submitted
+	// right away.
+	return updateProposal(s, "admin.proposetx", hash.String(),
+		func(*adminProposal) error { return nil })
+}
+
+// handleAdminGetProposal implements the admin.getproposal command.
+func handleAdminGetProposal(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminGetProposalCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	p, err := s.server.adminProposals.Fetch(hash)
+	if err != nil {
+		context := "Failed to fetch proposal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if p == nil {
+		return nil, noProposalError(c.TxID)
+	}
+	return adminProposalResult(p)
+}
+
+// handleAdminListProposals implements the admin.listproposals command.
+func handleAdminListProposals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	proposals, err := s.server.adminProposals.All()
+	if err != nil {
+		context := "Failed to fetch proposals"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	results := make([]btcjson.AdminProposalResult, 0, len(proposals))
+	for _, p := range proposals {
+		result, err := adminProposalResult(p)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// handleAdminSignProposal implements the admin.signproposal command.  It signs
+// the inputs of a proposal with the configured admin keys.
+func handleAdminSignProposal(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminSignProposalCmd)
+
+	if len(cfg.adminKeys) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "No admin keys are configured (--adminkey, " +
+				"--pkcs11adminkey or --remotesigneradminkey)",
+		}
+	}
+	return updateProposal(s, "admin.signproposal", c.TxID, func(p *adminProposal) error {
+		for i := range p.packet.Inputs {
+			in := &p.packet.Inputs[i]
+			if in.IsFinalized() {
+				continue
+			}
+			sigHash, err := p.packet.SigHash(i)
+			if err != nil {
+				context := "Failed to compute signature hash"
+				return internalRPCError(err.Error(), context)
+			}
+			for keyHash, signer := range cfg.adminKeys {
+				if len(in.Sigs) >= in.RequiredSigs {
+					break
+				}
+				pubKey := signerPubKey(signer, []byte(keyHash))
+				authorized, err := psptSignerAuthorized(s,
+					in.PkScript, pubKey)
+				if err != nil {
+					return err
+				}
+				if !authorized {
+					continue
+				}
+				sig, err := signer.Sign(sigHash)
+				if err != nil {
+					context := "Failed to sign input"
+					return internalRPCError(err.Error(), context)
+				}
+				err = p.packet.AddSignature(i, pubKey, append(
+					sig.Serialize(), byte(txscript.SigHashAll)))
+				if err != nil && err != pspt.ErrDuplicateSignature {
+					context := "Failed to add signature"
+					return internalRPCError(err.Error(), context)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// handleAdminAddProposalSigs implements the admin.addproposalsigs command.  It
+// imports the signatures of a partially signed transaction, such as one signed
+// offline with updatepspt, into the proposal of the same transaction.
+func handleAdminAddProposalSigs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminAddProposalSigsCmd)
+
+	imported, err := decodePSPT(c.PSPT)
+	if err != nil {
+		return nil, err
+	}
+	return updateProposal(s, "admin.addproposalsigs", c.TxID, func(p *adminProposal) error {
+		if imported.UnsignedTx.TxHash() != p.hash() ||
+			len(imported.Inputs) != len(p.packet.Inputs) {
+
+			return &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "The PSPT is not for the proposed transaction",
+			}
+		}
+		for i := range imported.Inputs {
+			in := &imported.Inputs[i]
+			if in.IsFinalized() && !p.packet.Inputs[i].IsFinalized() {
+				return &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Input %d of the PSPT "+
+						"is finalized, so its signatures can "+
+						"not be checked", i),
+				}
+			}
+			for _, sig := range in.Sigs {
+				err := addProposalSig(s, p, i, sig.PubKey,
+					sig.Signature)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// handleAdminDropProposal implements the admin.dropproposal command.
+func handleAdminDropProposal(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AdminDropProposalCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	found, err := s.server.adminProposals.Remove(hash)
+	if err != nil {
+		context := "Failed to remove proposal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if !found {
+		return nil, noProposalError(c.TxID)
+	}
+	rpcsLog.Infof("Admin admin.dropproposal: dropped proposed transaction %v",
+		hash)
+	return nil, nil
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitgo/prova/auditlog"
+	"github.com/bitgo/prova/btcjson"
+)
+
+const (
+	// rpcCookieUser is the name of the user authenticated by the password in
+	// the RPC cookie file.
+	rpcCookieUser = "__cookie__"
+
+	// rpcGeneratedPasswordSize is the number of random bytes of generated
+	// RPC passwords.
+	rpcGeneratedPasswordSize = 32
+)
+
+// rpcPermission is a set of permissions of an RPC user.  Each permission
+// grants access to a group of RPC methods and websocket notifications.
+type rpcPermission uint8
+
+const (
+	// rpcPermRead grants access to methods and notifications which only
+	// query the state of the chain and the node.  All users have it.
+	rpcPermRead rpcPermission = 1 << iota
+
+	// rpcPermWallet grants access to methods which submit transactions,
+	// and to the notifications and rescans used by wallets.
+	rpcPermWallet
+
+	// rpcPermMining grants access to methods used by external miners and
+	// validators to create and submit blocks.
+	rpcPermMining
+
+	// rpcPermAdmin grants access to all methods, including the ones which
+	// change the configuration of the node.
+	rpcPermAdmin
+
+	// rpcPermAll is the set of all permissions, which admin users have.
+	rpcPermAll = rpcPermRead | rpcPermWallet | rpcPermMining | rpcPermAdmin
+
+	// rpcPermLimited is the set of permissions of the user configured with
+	// --rpclimituser.
+	rpcPermLimited = rpcPermRead | rpcPermWallet | rpcPermMining
+)
+
+// rpcPermissionNames maps the names of permissions used by --rpcauth to the
+// permissions they grant.
+var rpcPermissionNames = map[string]rpcPermission{
+	"read":   rpcPermRead,
+	"wallet": rpcPermWallet,
+	"mining": rpcPermMining,
+	"admin":  rpcPermAll,
+}
+
+// String returns the permissions as the comma separated list of their names.
+func (p rpcPermission) String() string {
+	if p&rpcPermAdmin != 0 {
+		return "admin"
+	}
+	var names []string
+	for _, name := range []string{"read", "wallet", "mining"} {
+		if p&rpcPermissionNames[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Commands that are available to users with the wallet permission.
+var rpcWalletMethods = map[string]struct{}{
+	// Websockets commands
+	"loadtxfilter":         {},
+	"notifybroadcasts":     {},
+	"notifyreceived":       {},
+	"notifyspent":          {},
+	"rescan":               {},
+	"rescanblocks":         {},
+	"stopnotifyreceived":   {},
+	"stopnotifybroadcasts": {},
+	"stopnotifyspent":      {},
+	"watch":                {},
+
+	// HTTP/S-only commands
+	"broadcasttransaction": {},
+	"getbroadcaststatus":   {},
+	"sendrawtransaction":   {},
+
+	// Built-in wallet commands
+	"getbalance":       {},
+	"getnewaddress":    {},
+	"listtransactions": {},
+	"listunspent":      {},
+	"sendtoaddress":    {},
+}
+
+// Commands that are available to users with the mining permission.
+var rpcMiningMethods = map[string]struct{}{
+	"getblocktemplate": {},
+	"getgenerate":      {},
+	"gethashespersec":  {},
+	"getmininginfo":    {},
+	"submitblock":      {},
+}
+
+// rpcMethodPermission returns the permission required to call the passed RPC
+// method.  Methods which are not known to only need one of the other
+// permissions require the admin permission.
+func rpcMethodPermission(method string) rpcPermission {
+	if _, ok := rpcLimited[method]; ok {
+		return rpcPermRead
+	}
+	if _, ok := rpcWalletMethods[method]; ok {
+		return rpcPermWallet
+	}
+	if _, ok := rpcMiningMethods[method]; ok {
+		return rpcPermMining
+	}
+	return rpcPermAdmin
+}
+
+// rpcUser describes a user allowed to access the RPC server.
+type rpcUser struct {
+	name        string
+	authsha     [sha256.Size]byte
+	permissions rpcPermission
+}
+
+// newRPCUser returns an RPC user with the passed credentials and permissions.
+func newRPCUser(name, pass string, permissions rpcPermission) *rpcUser {
+	return &rpcUser{
+		name:        name,
+		authsha:     rpcAuthSha(name, pass),
+		permissions: permissions | rpcPermRead,
+	}
+}
+
+// rpcAuthSha returns the hash of the HTTP basic authorization header of the
+// passed credentials, which is what authorization headers are compared by.
+func rpcAuthSha(name, pass string) [sha256.Size]byte {
+	login := name + ":" + pass
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	return sha256.Sum256([]byte(auth))
+}
+
+// authorized returns whether the user may call the passed RPC method.
+func (u *rpcUser) authorized(method string) bool {
+	return u.permissions&rpcMethodPermission(method) != 0
+}
+
+// rpcChainInterventionMethods are the methods which intervene in the view of
+// the node on the chain, which are recorded as such in the audit log.
+var rpcChainInterventionMethods = map[string]struct{}{
+	"compactdb":       {},
+	"dropindex":       {},
+	"enableindex":     {},
+	"invalidateblock": {},
+	"preciousblock":   {},
+	"reconsiderblock": {},
+	"setmocktime":     {},
+}
+
+// auditCall logs calls of methods which need more than the read permission,
+// so changes to the node and the network can be traced back to their user.
+// Calls of methods which need the admin permission are also recorded in the
+// audit log along with their parameters when it is enabled.
+func (s *rpcServer) auditCall(user *rpcUser, method string, params []json.RawMessage, remoteAddr string) {
+	permission := rpcMethodPermission(method)
+	if permission == rpcPermRead {
+		return
+	}
+	rpcsLog.Infof("RPC user %s called %s from %s", user.name, method,
+		remoteAddr)
+	if s.auditLog == nil || permission != rpcPermAdmin {
+		return
+	}
+
+	kind := auditlog.AdminRPC
+	if _, ok := rpcChainInterventionMethods[method]; ok {
+		kind = auditlog.ChainIntervention
+	}
+	details := map[string]string{
+		"user":   user.name,
+		"remote": remoteAddr,
+	}
+	if _, ok := rpcRedactedMethods[method]; ok {
+		details["params"] = "redacted"
+	} else if len(params) > 0 {
+		encoded, err := json.Marshal(params)
+		if err == nil {
+			details["params"] = string(encoded)
+		}
+	}
+	if err := s.auditLog.Record(kind, method, details); err != nil {
+		rpcsLog.Errorf("Failed to record the call of %s in the audit "+
+			"log: %v", method, err)
+	}
+}
+
+// rpcUnauthorizedError returns the error of a call of the passed method by a
+// user lacking the permission it requires.
+func rpcUnauthorizedError(method string) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code: btcjson.ErrRPCInvalidParams.Code,
+		Message: fmt.Sprintf("user not authorized for this method "+
+			"(requires %v permission)", rpcMethodPermission(method)),
+	}
+}
+
+// parseRPCAuth parses the passed --rpcauth value, which has the form
+// user:password:permissions, where permissions is a comma separated list of
+// permission names.  The password may contain colons.
+func parseRPCAuth(auth string) (*rpcUser, error) {
+	userEnd := strings.Index(auth, ":")
+	passEnd := strings.LastIndex(auth, ":")
+	if userEnd <= 0 || passEnd == userEnd {
+		// The value is not echoed since it may contain a password.
+		return nil, fmt.Errorf("RPC user is not of the form " +
+			"user:password:permissions")
+	}
+	name, pass := auth[:userEnd], auth[userEnd+1:passEnd]
+	if pass == "" {
+		return nil, fmt.Errorf("RPC user %q has an empty password", name)
+	}
+
+	permissions, err := parseRPCPermissions(name, auth[passEnd+1:])
+	if err != nil {
+		return nil, err
+	}
+	return newRPCUser(name, pass, permissions), nil
+}
+
+// parseRPCCertAuth parses the passed --rpccertauth value, which has the form
+// commonname:permissions, where permissions is a comma separated list of
+// permission names.  The returned user is authenticated by client certificates
+// with the common name, which may contain colons, and has no password.
+func parseRPCCertAuth(auth string) (*rpcUser, error) {
+	nameEnd := strings.LastIndex(auth, ":")
+	if nameEnd <= 0 {
+		return nil, fmt.Errorf("RPC certificate identity %q is not of "+
+			"the form commonname:permissions", auth)
+	}
+	name := auth[:nameEnd]
+	permissions, err := parseRPCPermissions(name, auth[nameEnd+1:])
+	if err != nil {
+		return nil, err
+	}
+	return &rpcUser{
+		name:        name,
+		permissions: permissions | rpcPermRead,
+	}, nil
+}
+
+// parseRPCPermissions parses the passed comma separated list of the
+// permission names of the named RPC user.
+func parseRPCPermissions(name, permNames string) (rpcPermission, error) {
+	var permissions rpcPermission
+	for _, permName := range strings.Split(permNames, ",") {
+		permission, ok := rpcPermissionNames[strings.TrimSpace(permName)]
+		if !ok {
+			return 0, fmt.Errorf("RPC user %q has unknown "+
+				"permission %q -- valid permissions are "+
+				"{read, wallet, mining, admin}", name, permName)
+		}
+		permissions |= permission
+	}
+	return permissions, nil
+}
+
+// loadRPCClientCAs returns the pool of the PEM encoded certificate authorities
+// in the passed file, which issue the client certificates RPC clients
+// authenticate with.
+func loadRPCClientCAs(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// generateRPCPassword returns a new random RPC password.
+func generateRPCPassword() (string, error) {
+	var b [rpcGeneratedPasswordSize]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// writeRPCCookie writes the credentials of the cookie user with the passed
+// password to the passed file, which only the owner may read.  The file is
+// replaced atomically so clients never read a partially written cookie.
+func writeRPCCookie(path, pass string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	cookie := []byte(rpcCookieUser + ":" + pass)
+	if err := ioutil.WriteFile(tmpPath, cookie, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// authenticate returns the user whose credentials are in the passed HTTP
+// basic authorization header, or nil when they are not the credentials of any
+// user.
+//
+// This check is time-constant.
+func (s *rpcServer) authenticate(authHeader string) *rpcUser {
+	authsha := sha256.Sum256([]byte(authHeader))
+
+	// Compare against all users so the time taken does not depend on
+	// which user matched.
+	var match *rpcUser
+	s.usersMtx.RLock()
+	for _, user := range s.users {
+		if subtle.ConstantTimeCompare(authsha[:], user.authsha[:]) == 1 {
+			match = user
+		}
+	}
+	s.usersMtx.RUnlock()
+	return match
+}
+
+// authenticateCert returns the user whose identity is the common name of the
+// passed client certificate, which has already been verified to be issued by
+// one of the authorities of --rpcclientca, or nil when it is not the identity
+// of any user.
+func (s *rpcServer) authenticateCert(cert *x509.Certificate) *rpcUser {
+	return s.certUsers[cert.Subject.CommonName]
+}
+
+// rotateUser replaces the password of the user with the passed name with a new
+// random password, which is returned along with whether the user exists.  The
+// cookie file is rewritten when the password of the cookie user is rotated.
+// Clients which already authenticated a websocket connection with the old
+// password stay connected.
+func (s *rpcServer) rotateUser(name string) (string, bool, error) {
+	s.usersMtx.Lock()
+	defer s.usersMtx.Unlock()
+
+	for i, user := range s.users {
+		if user.name != name {
+			continue
+		}
+
+		pass, err := generateRPCPassword()
+		if err != nil {
+			return "", true, err
+		}
+		if name == rpcCookieUser {
+			if err := writeRPCCookie(cfg.RPCCookieFile, pass); err != nil {
+				return "", true, err
+			}
+		}
+
+		// Users are replaced rather than modified since they are
+		// read without holding the lock once authenticated.
+		s.users[i] = newRPCUser(name, pass, user.permissions)
+		rpcsLog.Infof("Rotated the password of RPC user %s", name)
+		return pass, true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseRPCAuth ensures --rpcauth values are parsed into users with the
+// named permissions, and malformed values are rejected.
+func TestParseRPCAuth(t *testing.T) {
+	tests := []struct {
+		auth        string
+		name        string
+		permissions rpcPermission
+		valid       bool
+	}{
+		{"explorer:secret:read", "explorer", rpcPermRead, true},
+		{"pool:se:cret:mining, wallet", "pool",
+			rpcPermRead | rpcPermWallet | rpcPermMining, true},
+		{"ops:secret:admin", "ops", rpcPermAll, true},
+		{"ops:secret:root", "", 0, false},
+		{"ops:secret", "", 0, false},
+		{"ops::read", "", 0, false},
+		{":secret:read", "", 0, false},
+	}
+	for _, test := range tests {
+		user, err := parseRPCAuth(test.auth)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: unexpected error: %v", test.auth, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if user.name != test.name || user.permissions != test.permissions {
+			t.Errorf("%q: got user %s with %v, want %s with %v",
+				test.auth, user.name, user.permissions, test.name,
+				test.permissions)
+		}
+	}
+}
+
+// TestParseRPCCertAuth ensures --rpccertauth values are parsed into users
+// without passwords with the named permissions, and malformed values are
+// rejected.
+func TestParseRPCCertAuth(t *testing.T) {
+	tests := []struct {
+		auth        string
+		name        string
+		permissions rpcPermission
+		valid       bool
+	}{
+		{"explorer:read", "explorer", rpcPermRead, true},
+		{"pool.example.com:mining,wallet", "pool.example.com",
+			rpcPermRead | rpcPermWallet | rpcPermMining, true},
+		{"ops:deploy:admin", "ops:deploy", rpcPermAll, true},
+		{"ops:root", "", 0, false},
+		{"ops", "", 0, false},
+		{":read", "", 0, false},
+	}
+	for _, test := range tests {
+		user, err := parseRPCCertAuth(test.auth)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: unexpected error: %v", test.auth, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if user.name != test.name || user.permissions != test.permissions {
+			t.Errorf("%q: got user %s with %v, want %s with %v",
+				test.auth, user.name, user.permissions, test.name,
+				test.permissions)
+		}
+	}
+}
+
+// TestCheckAuthClientCert ensures requests presenting a verified client
+// certificate are authenticated by the identity of the certificate rather
+// than by the credentials in their header.
+func TestCheckAuthClientCert(t *testing.T) {
+	reader := newRPCUser("reader", "pass", rpcPermRead)
+	pool, err := parseRPCCertAuth("pool:mining")
+	if err != nil {
+		t.Fatalf("parseRPCCertAuth: unexpected error: %v", err)
+	}
+	s := &rpcServer{
+		users:     []*rpcUser{reader},
+		certUsers: map[string]*rpcUser{pool.name: pool},
+	}
+	request := func(commonName string, header bool) *http.Request {
+		r := &http.Request{Header: make(http.Header)}
+		if header {
+			r.SetBasicAuth("reader", "pass")
+		}
+		if commonName != "" {
+			cert := &x509.Certificate{
+				Subject: pkix.Name{CommonName: commonName},
+			}
+			r.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			}
+		}
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		commonName string
+		header     bool
+		user       *rpcUser
+		valid      bool
+	}{
+		{"certificate", "pool", false, pool, true},
+		{"certificate and header", "pool", true, pool, true},
+		{"unknown certificate", "other", true, nil, false},
+		{"header", "", true, reader, true},
+		{"nothing", "", false, nil, false},
+	}
+	for _, test := range tests {
+		user, err := s.checkAuth(request(test.commonName, test.header),
+			true)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if user != test.user {
+			t.Errorf("%s: got user %v, want %v", test.name, user,
+				test.user)
+		}
+	}
+}
+
+// TestRPCUserAuthorized ensures users may only call the methods their
+// permissions grant and are authenticated by their own credentials.
+func TestRPCUserAuthorized(t *testing.T) {
+	reader := newRPCUser("reader", "pass", rpcPermRead)
+	pool := newRPCUser("pool", "pass", rpcPermMining)
+	limited := newRPCUser("limited", "pass", rpcPermLimited)
+	admin := newRPCUser("admin", "pass", rpcPermAll)
+
+	tests := []struct {
+		method  string
+		allowed []*rpcUser
+	}{
+		{"getblock", []*rpcUser{reader, pool, limited, admin}},
+		{"notifyblocks", []*rpcUser{reader, pool, limited, admin}},
+		{"getblocktemplate", []*rpcUser{pool, limited, admin}},
+		{"sendrawtransaction", []*rpcUser{limited, admin}},
+		{"notifyreceived", []*rpcUser{limited, admin}},
+		{"setgenerate", []*rpcUser{admin}},
+		{"stop", []*rpcUser{admin}},
+	}
+	for _, test := range tests {
+		for _, user := range []*rpcUser{reader, pool, limited, admin} {
+			want := false
+			for _, allowed := range test.allowed {
+				want = want || allowed == user
+			}
+			if got := user.authorized(test.method); got != want {
+				t.Errorf("%s calling %s: got authorized %v, want %v",
+					user.name, test.method, got, want)
+			}
+		}
+	}
+
+	s := rpcServer{users: []*rpcUser{reader, admin}}
+	auth := func(login string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+	}
+	if user := s.authenticate(auth("admin:pass")); user != admin {
+		t.Errorf("authenticate: got user %v, want admin", user)
+	}
+	if user := s.authenticate(auth("admin:wrong")); user != nil {
+		t.Errorf("authenticate: got user %v for wrong password", user)
+	}
+	if user := s.authenticate(auth("pool:pass")); user != nil {
+		t.Errorf("authenticate: got user %v for unknown user", user)
+	}
+}
+
+// TestWriteRPCCookie ensures the cookie file contains the credentials of the
+// cookie user, can only be read by its owner, and is replaced when rewritten.
+func TestWriteRPCCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpccookie")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mainnet", ".cookie")
+	for _, pass := range []string{"first", "second"} {
+		if err := writeRPCCookie(path, pass); err != nil {
+			t.Fatalf("writeRPCCookie: %v", err)
+		}
+		cookie, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if want := rpcCookieUser + ":" + pass; string(cookie) != want {
+			t.Errorf("cookie is %q, want %q", cookie, want)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("cookie has mode %v, want 0600", perm)
+		}
+	}
+}
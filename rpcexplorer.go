@@ -0,0 +1,463 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// maxExplorerBlocks is the maximum number of blocks returned by the
+	// getexplorerblocks command.
+	maxExplorerBlocks = 100
+
+	// maxExplorerAddressTxs is the maximum number of transactions returned
+	// by the getexploreraddress command.
+	maxExplorerAddressTxs = 1000
+
+	// maxExplorerDays is the maximum number of days returned by the
+	// getexplorercharts command.
+	maxExplorerDays = 3650
+
+	// explorerTokenSize is the size of the decoded continuation token of
+	// the getexploreraddress command, which is the height of the
+	// block of the last transaction returned followed by the index of the
+	// transaction in the block.
+	explorerTokenSize = 8
+)
+
+// explorerScriptDetails returns the type, addresses and keyIDs of the passed
+// public key script.
+func explorerScriptDetails(pkScript []byte, params *chaincfg.Params) (string, []string, []uint32) {
+	class, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+	encodedAddrs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		encodedAddrs = append(encodedAddrs, addr.EncodeAddress())
+	}
+	var details btcjson.ScriptPubKeyResult
+	addProvaScriptDetails(&details, pkScript, class, nil)
+	return class.String(), encodedAddrs, details.KeyIDs
+}
+
+// handleGetExplorerBlocks implements the getexplorerblocks command.  Blocks
+// are returned from the passed height, or the best block, down towards the
+// genesis block along with the fees they collected, which are computed from
+// the spend journal so no index is required.
+func handleGetExplorerBlocks(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetExplorerBlocksCmd)
+
+	count := 20
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if count < 1 || count > maxExplorerBlocks {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Count must be between 1 and 100",
+		}
+	}
+
+	best := s.chain.BestSnapshot()
+	height := best.Height
+	if c.Height != nil {
+		height = *c.Height
+	}
+	if height > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block height out of range",
+		}
+	}
+
+	results := make([]btcjson.ExplorerBlockResult, 0, count)
+	for ; len(results) < count; height-- {
+		block, err := s.chain.BlockByHeight(height)
+		if err != nil {
+			context := "Failed to fetch block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		spentTxOuts, err := s.chain.FetchSpentTxOuts(block)
+		if err != nil {
+			context := "Failed to fetch spent outputs"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// Sum the outputs of the block and the fees collected from its
+		// transactions.  The spent outputs are in the order of the
+		// inputs of the transactions following the coinbase.  Admin
+		// transactions don't pay fees.
+		var atoms, fees int64
+		var stxoIdx int
+		for i, tx := range block.Transactions() {
+			var out int64
+			for _, txOut := range tx.MsgTx().TxOut {
+				out += txOut.Value
+			}
+			atoms += out
+			if i == 0 {
+				continue
+			}
+			var in int64
+			for range tx.MsgTx().TxIn {
+				if txOut := spentTxOuts[stxoIdx]; txOut != nil {
+					in += txOut.Value
+				}
+				stxoIdx++
+			}
+			if threadInt, _ := txscript.GetAdminDetails(tx); threadInt >= 0 {
+				continue
+			}
+			if in > out {
+				fees += in - out
+			}
+		}
+
+		header := &block.MsgBlock().Header
+		results = append(results, btcjson.ExplorerBlockResult{
+			Hash:             block.Hash().String(),
+			Height:           height,
+			Time:             header.Timestamp.Unix(),
+			Txs:              len(block.Transactions()),
+			Size:             block.MsgBlock().SerializeSize(),
+			Atoms:            atoms,
+			Fees:             fees,
+			Difficulty:       getDifficultyRatio(header.Bits),
+			ValidatingPubKey: header.ValidatingPubKey.String(),
+		})
+		if height == 0 {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// handleGetExplorerTransaction implements the getexplorertransaction command.
+// Unlike getrawtransaction, the outputs spent by the inputs are always
+// resolved and the transactions spending the outputs are included when the
+// spent index is enabled.
+func handleGetExplorerTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetExplorerTransactionCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	// Try to fetch the transaction from the memory pool and if that fails,
+	// try the block database.
+	var mtx *wire.MsgTx
+	var blkHash *chainhash.Hash
+	var blkHeight uint32
+	var blkHeader wire.BlockHeader
+	tx, err := s.server.txMemPool.FetchTransaction(txHash)
+	if err != nil {
+		txIndex := s.server.indexes().txIndex
+		if txIndex == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: "The transaction index must be " +
+					"enabled to query the blockchain " +
+					"(specify --txindex)",
+			}
+		}
+
+		blockRegion, err := txIndex.TxBlockRegion(txHash)
+		if err != nil {
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		var txBytes []byte
+		err = s.server.db.View(func(dbTx database.Tx) error {
+			var err error
+			txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+			return err
+		})
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		blkHash = blockRegion.Hash
+		blkHeight, err = s.chain.BlockHeightByHash(blkHash)
+		if err != nil {
+			context := "Failed to retrieve block height"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		blkHeader, err = s.chain.FetchHeader(blkHash)
+		if err != nil {
+			context := "Failed to fetch block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(txBytes))
+		if err != nil {
+			context := "Failed to deserialize transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		mtx = &msgTx
+	} else {
+		mtx = tx.MsgTx()
+	}
+
+	params := s.server.chainParams
+	coinbase := blockchain.IsCoinBaseTx(mtx)
+	threadInt, _ := txscript.GetAdminDetailsMsgTx(mtx)
+	result := &btcjson.ExplorerTxResult{
+		Txid:     txHash.String(),
+		Version:  mtx.Version,
+		LockTime: mtx.LockTime,
+		Size:     mtx.SerializeSize(),
+		Admin:    threadInt >= 0,
+		Vin:      make([]btcjson.ExplorerVinResult, 0, len(mtx.TxIn)),
+		Vout:     make([]btcjson.ExplorerVoutResult, 0, len(mtx.TxOut)),
+	}
+	if blkHash != nil {
+		result.BlockHash = blkHash.String()
+		result.Height = blkHeight
+		result.Time = blkHeader.Timestamp.Unix()
+		result.Confirmations = 1 + s.chain.BestSnapshot().Height - blkHeight
+	}
+
+	// Resolve the outputs spent by the inputs.
+	var prevOuts map[wire.OutPoint]wire.TxOut
+	if !coinbase {
+		prevOuts, err = fetchPrevOuts(s, mtx, blkHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var in, out int64
+	for _, txIn := range mtx.TxIn {
+		if coinbase {
+			result.Vin = append(result.Vin, btcjson.ExplorerVinResult{
+				Coinbase: true,
+			})
+			continue
+		}
+		vin := btcjson.ExplorerVinResult{
+			Txid: txIn.PreviousOutPoint.Hash.String(),
+			Vout: txIn.PreviousOutPoint.Index,
+		}
+		if prevOut, ok := prevOuts[txIn.PreviousOutPoint]; ok {
+			vin.Type, vin.Addresses, vin.KeyIDs = explorerScriptDetails(
+				prevOut.PkScript, params)
+			vin.Atoms = prevOut.Value
+			in += prevOut.Value
+		}
+		result.Vin = append(result.Vin, vin)
+	}
+
+	// Add the outputs along with the inputs spending them when the spent
+	// index is enabled.
+	spentIndex := s.server.indexes().spentIndex
+	for i, txOut := range mtx.TxOut {
+		vout := btcjson.ExplorerVoutResult{
+			N:     uint32(i),
+			Atoms: txOut.Value,
+		}
+		vout.Type, vout.Addresses, vout.KeyIDs = explorerScriptDetails(
+			txOut.PkScript, params)
+		out += txOut.Value
+
+		if spentIndex != nil && blkHash != nil {
+			outPoint := wire.OutPoint{Hash: *txHash, Index: uint32(i)}
+			spend, err := spentIndex.SpendByOutPoint(&outPoint)
+			if err != nil {
+				context := "Failed to fetch spending input"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if spend != nil {
+				vout.SpentTxid = spend.TxHash.String()
+				vout.SpentIndex = spend.Index
+				vout.SpentHeight = spend.Height
+			}
+		}
+		result.Vout = append(result.Vout, vout)
+	}
+
+	// Coinbase and admin transactions don't pay fees, and the fee is only
+	// known when all the spent outputs were resolved.
+	if !coinbase && !result.Admin && len(prevOuts) == len(mtx.TxIn) &&
+		in > out {
+
+		result.Fee = in - out
+	}
+
+	return result, nil
+}
+
+// handleGetExplorerAddress implements the getexploreraddress command.  The
+// transactions changing the balance of the address are returned newest first
+// in pages, with the token returned along with a page used to request the next
+// one.
+func handleGetExplorerAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetExplorerAddressCmd)
+
+	addrIndex := s.server.addrIndex
+	if addrIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+
+	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
+	if err != nil || !addr.IsForNet(s.server.chainParams) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key",
+		}
+	}
+
+	count := 20
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if count < 1 || count > maxExplorerAddressTxs {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Count must be between 1 and 1000",
+		}
+	}
+
+	// Decode the position to continue from when a token is passed and
+	// start with the newest transaction otherwise.
+	height, txIndex := uint32(math.MaxUint32), uint32(math.MaxUint32)
+	if c.Token != nil && *c.Token != "" {
+		token, err := hex.DecodeString(*c.Token)
+		if err != nil || len(token) != explorerTokenSize {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid token",
+			}
+		}
+		height = binary.BigEndian.Uint32(token[0:4])
+		txIndex = binary.BigEndian.Uint32(token[4:8])
+	}
+
+	txs, more, err := addrIndex.TxsForAddressBefore(addr, height, txIndex,
+		count)
+	if err != nil {
+		context := "Failed to load address index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.ExplorerAddressResult{
+		Address: c.Address,
+		Txs:     make([]btcjson.ExplorerAddressTxResult, 0, len(txs)),
+	}
+	times := make(map[uint32]int64)
+	for _, tx := range txs {
+		blockTime, ok := times[tx.Height]
+		if !ok {
+			blkHash, err := s.chain.BlockHashByHeight(tx.Height)
+			if err != nil {
+				context := "Failed to fetch block hash"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			header, err := s.chain.FetchHeader(blkHash)
+			if err != nil {
+				context := "Failed to fetch block header"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			blockTime = header.Timestamp.Unix()
+			times[tx.Height] = blockTime
+		}
+		result.Txs = append(result.Txs, btcjson.ExplorerAddressTxResult{
+			Txid:     tx.TxHash.String(),
+			Height:   tx.Height,
+			Time:     blockTime,
+			Received: tx.Received,
+			Sent:     tx.Sent,
+		})
+	}
+	if more && len(txs) > 0 {
+		last := txs[len(txs)-1]
+		var token [explorerTokenSize]byte
+		binary.BigEndian.PutUint32(token[0:4], last.Height)
+		binary.BigEndian.PutUint32(token[4:8], last.TxIndex)
+		result.Next = hex.EncodeToString(token[:])
+	}
+
+	return result, nil
+}
+
+// handleGetExplorerCharts implements the getexplorercharts command.  The
+// statistics of the passed number of days up to the day of the best block are
+// returned oldest first, including the days without blocks.
+func handleGetExplorerCharts(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetExplorerChartsCmd)
+
+	statsIndex := s.server.indexes().statsIndex
+	if statsIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Daily statistics index must be enabled (--statsindex)",
+		}
+	}
+
+	days := 30
+	if c.Days != nil {
+		days = *c.Days
+	}
+	if days < 1 || days > maxExplorerDays {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Days must be between 1 and 3650",
+		}
+	}
+
+	best := s.chain.BestSnapshot()
+	header, err := s.chain.FetchHeader(best.Hash)
+	if err != nil {
+		context := "Failed to fetch block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	const day = int64(24 * time.Hour / time.Second)
+	end := header.Timestamp.Unix() / day * day
+	start := end - int64(days-1)*day
+
+	stats, err := statsIndex.StatsForDays(start, end)
+	if err != nil {
+		context := "Failed to load daily statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.ExplorerDayResult, 0, days)
+	for timestamp := start; timestamp <= end; timestamp += day {
+		result := btcjson.ExplorerDayResult{
+			Date: time.Unix(timestamp, 0).UTC().Format("2006-01-02"),
+			Time: timestamp,
+		}
+		if len(stats) > 0 && stats[0].Timestamp == timestamp {
+			result.Blocks = stats[0].Blocks
+			result.Txs = stats[0].Txs
+			result.Fees = stats[0].Fees
+			result.ActiveKeyIDs = stats[0].ActiveKeyIDs
+			stats = stats[1:]
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
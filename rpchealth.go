@@ -0,0 +1,255 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// healthzPath and readyzPath are the paths of the liveness and
+	// readiness endpoints served when --health is set.
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	// defaultHealthBlockAgeFactor is the number of expected block intervals
+	// the best block may be old before the node is reported as not ready,
+	// unless --healthmaxblockage is set.
+	defaultHealthBlockAgeFactor = 10
+)
+
+// The names of the health checks reported by gethealth and the health
+// endpoints.
+const (
+	healthCheckDatabase  = "database"
+	healthCheckSync      = "sync"
+	healthCheckPeers     = "peers"
+	healthCheckBlockAge  = "blockage"
+	healthCheckValidator = "validator"
+)
+
+// healthCheck is the outcome of a single health check.  Liveness checks fail
+// both the liveness and the readiness of the node, while the others only fail
+// its readiness, since restarting the node would not help them.
+type healthCheck struct {
+	name     string
+	liveness bool
+	err      error
+	detail   string
+}
+
+// healthResult returns the result reporting the passed health checks.
+func healthResult(checks []healthCheck) *btcjson.GetHealthResult {
+	result := &btcjson.GetHealthResult{
+		Live:   true,
+		Ready:  true,
+		Checks: make([]btcjson.HealthCheckResult, 0, len(checks)),
+	}
+	for _, check := range checks {
+		checkResult := btcjson.HealthCheckResult{
+			Name:   check.name,
+			OK:     check.err == nil,
+			Detail: check.detail,
+		}
+		if check.err != nil {
+			checkResult.Detail = check.err.Error()
+			result.Ready = false
+			if check.liveness {
+				result.Live = false
+			}
+		}
+		result.Checks = append(result.Checks, checkResult)
+	}
+	return result
+}
+
+// checkDirWritable ensures files can be created and synced in the passed
+// directory by writing and removing a probe file.
+func checkDirWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".healthcheck")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write([]byte("ok")); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkBlockAge returns an error when the passed age of the best block exceeds
+// the passed maximum.
+func checkBlockAge(age, maxAge time.Duration) error {
+	if age > maxAge {
+		return fmt.Errorf("best block is %v old, more than the maximum "+
+			"of %v", age, maxAge)
+	}
+	return nil
+}
+
+// healthDatabaseCheck checks that the block database can be written to.  The
+// probe is written in the directory of the database, or the data directory
+// when the database has none, such as when it is held in memory.
+func (s *rpcServer) healthDatabaseCheck() healthCheck {
+	check := healthCheck{name: healthCheckDatabase, liveness: true}
+	if cfg.ReadOnly {
+		check.detail = "read-only"
+		return check
+	}
+	dir := blockDbPath(cfg.DbType)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = cfg.DataDir
+	}
+	if err := checkDirWritable(dir); err != nil {
+		check.err = fmt.Errorf("database is not writable: %v", err)
+		return check
+	}
+	check.detail = "writable"
+	return check
+}
+
+// healthSyncChecks checks that the node is synced with its peers, has enough
+// of them and that its best block is recent enough with regard to the
+// expected block interval of the network.
+func (s *rpcServer) healthSyncChecks() []healthCheck {
+	best := s.chain.BestSnapshot()
+
+	syncCheck := healthCheck{name: healthCheckSync}
+	if cfg.ReadOnly || s.server.blockManager.IsCurrent() {
+		syncCheck.detail = fmt.Sprintf("synced at height %d",
+			best.Height)
+	} else {
+		syncCheck.err = fmt.Errorf("syncing at height %d", best.Height)
+	}
+
+	peersCheck := healthCheck{name: healthCheckPeers}
+	numPeers := s.server.ConnectedCount()
+	switch {
+	case cfg.ReadOnly:
+		peersCheck.detail = "read-only"
+	case int(numPeers) < cfg.HealthMinPeers:
+		peersCheck.err = fmt.Errorf("%d peers connected, fewer than "+
+			"the minimum of %d", numPeers, cfg.HealthMinPeers)
+	default:
+		peersCheck.detail = fmt.Sprintf("%d peers connected", numPeers)
+	}
+
+	ageCheck := healthCheck{name: healthCheckBlockAge}
+	header, err := s.chain.FetchHeader(best.Hash)
+	if err != nil {
+		ageCheck.err = fmt.Errorf("unable to fetch best block: %v",
+			err)
+	} else {
+		maxAge := cfg.HealthMaxBlockAge
+		if maxAge == 0 {
+			maxAge = defaultHealthBlockAgeFactor *
+				s.server.chainParams.TargetTimePerBlock
+		}
+		age := time.Since(header.Timestamp).Truncate(time.Second)
+		ageCheck.err = checkBlockAge(age, maxAge)
+		ageCheck.detail = fmt.Sprintf("best block is %v old", age)
+	}
+
+	return []healthCheck{syncCheck, peersCheck, ageCheck}
+}
+
+// healthValidatorCheck checks that a node holding validate keys is able to
+// sign blocks with at least one of them, that is, one of them belongs to the
+// validate key set and is not rate limited.  Nodes which hold no validate keys
+// pass the check.
+func (s *rpcServer) healthValidatorCheck() healthCheck {
+	check := healthCheck{name: healthCheckValidator}
+	validateKeys := s.server.cpuMiner.ValidateKeys()
+	if len(validateKeys) == 0 {
+		check.detail = "no validate keys"
+		return check
+	}
+
+	validateKeySet := s.chain.AdminKeySets()[btcec.ValidateKeySet]
+	var active, ready int
+	for _, validateKey := range validateKeys {
+		if validateKeySet.Pos(validateKey.PubKey()) == -1 {
+			continue
+		}
+		active++
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], validateKey.PubKey().SerializeCompressed())
+		rateLimited, err := s.chain.IsValidateKeyRateLimited(pubKey)
+		if err != nil {
+			check.err = fmt.Errorf("unable to check validate key "+
+				"rate limit: %v", err)
+			return check
+		}
+		if !rateLimited {
+			ready++
+		}
+	}
+	if ready == 0 {
+		check.err = fmt.Errorf("none of the %d validate keys can sign "+
+			"blocks: %d are in the validate key set and all of "+
+			"those are rate limited", len(validateKeys), active)
+		return check
+	}
+	check.detail = fmt.Sprintf("%d of %d validate keys can sign blocks",
+		ready, len(validateKeys))
+	return check
+}
+
+// health runs all health checks of the node.
+func (s *rpcServer) health() *btcjson.GetHealthResult {
+	checks := []healthCheck{s.healthDatabaseCheck()}
+	checks = append(checks, s.healthSyncChecks()...)
+	checks = append(checks, s.healthValidatorCheck())
+	return healthResult(checks)
+}
+
+// handleGetHealth implements the gethealth command.
+func handleGetHealth(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.health(), nil
+}
+
+// writeHealth writes the passed health result as the response to a health
+// endpoint request, with status OK when the passed condition holds and
+// service unavailable otherwise, which is what orchestrators and load
+// balancers act on.
+func writeHealth(w http.ResponseWriter, result *btcjson.GetHealthResult, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleHealthz serves the unauthenticated liveness endpoint, which fails when
+// restarting the node may help.
+func (s *rpcServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	checks := []healthCheck{s.healthDatabaseCheck()}
+	result := healthResult(checks)
+	writeHealth(w, result, result.Live)
+}
+
+// handleReadyz serves the unauthenticated readiness endpoint, which fails when
+// the node should not be sent traffic.
+func (s *rpcServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	result := s.health()
+	writeHealth(w, result, result.Ready)
+}
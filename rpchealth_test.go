@@ -0,0 +1,116 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHealthResult ensures failing liveness checks fail both the liveness and
+// the readiness of the node while other failing checks only fail its
+// readiness.
+func TestHealthResult(t *testing.T) {
+	errCheck := errors.New("check failed")
+	tests := []struct {
+		name   string
+		checks []healthCheck
+		live   bool
+		ready  bool
+	}{
+		{
+			name: "all pass",
+			checks: []healthCheck{
+				{name: healthCheckDatabase, liveness: true},
+				{name: healthCheckSync},
+			},
+			live:  true,
+			ready: true,
+		},
+		{
+			name: "readiness check fails",
+			checks: []healthCheck{
+				{name: healthCheckDatabase, liveness: true},
+				{name: healthCheckSync, err: errCheck},
+			},
+			live:  true,
+			ready: false,
+		},
+		{
+			name: "liveness check fails",
+			checks: []healthCheck{
+				{name: healthCheckDatabase, liveness: true,
+					err: errCheck},
+				{name: healthCheckSync},
+			},
+			live:  false,
+			ready: false,
+		},
+	}
+
+	for _, test := range tests {
+		result := healthResult(test.checks)
+		if result.Live != test.live || result.Ready != test.ready {
+			t.Errorf("%s: got live %v and ready %v, want %v and %v",
+				test.name, result.Live, result.Ready, test.live,
+				test.ready)
+			continue
+		}
+		if len(result.Checks) != len(test.checks) {
+			t.Errorf("%s: got %d checks, want %d", test.name,
+				len(result.Checks), len(test.checks))
+			continue
+		}
+		for i, check := range test.checks {
+			got := result.Checks[i]
+			if got.Name != check.name || got.OK != (check.err == nil) {
+				t.Errorf("%s: check %d: got %s ok %v, want %s "+
+					"ok %v", test.name, i, got.Name, got.OK,
+					check.name, check.err == nil)
+			}
+			if check.err != nil && got.Detail != check.err.Error() {
+				t.Errorf("%s: check %d: got detail %q, want %q",
+					test.name, i, got.Detail, check.err.Error())
+			}
+		}
+	}
+}
+
+// TestHealthChecks ensures the block age and directory checks report the
+// expected errors.
+func TestHealthChecks(t *testing.T) {
+	if err := checkBlockAge(time.Minute, time.Hour); err != nil {
+		t.Errorf("checkBlockAge: unexpected error: %v", err)
+	}
+	if err := checkBlockAge(time.Hour, time.Hour); err != nil {
+		t.Errorf("checkBlockAge: unexpected error at the maximum: %v", err)
+	}
+	if err := checkBlockAge(2*time.Hour, time.Hour); err == nil {
+		t.Errorf("checkBlockAge: old block passed")
+	}
+
+	dir, err := ioutil.TempDir("", "healthcheck")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := checkDirWritable(dir); err != nil {
+		t.Errorf("checkDirWritable: unexpected error: %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: unexpected error: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("checkDirWritable: left %d files behind", len(files))
+	}
+	if err := checkDirWritable(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("checkDirWritable: missing directory passed")
+	}
+}
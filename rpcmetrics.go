@@ -0,0 +1,374 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/peer"
+)
+
+const (
+	// defaultRPCSlowQuery is the default duration of RPC calls above which
+	// they are logged as slow.
+	defaultRPCSlowQuery = time.Second * 5
+
+	// maxSlowQueryParamsLen is the maximum length of the parameters logged
+	// along with slow RPC calls, since some methods take whole serialized
+	// transactions.
+	maxSlowQueryParamsLen = 256
+)
+
+// rpcRedactedMethods are the methods which take private keys, passphrases or
+// credentials, so their parameters are neither logged along with slow calls
+// nor recorded in the audit log.
+var rpcRedactedMethods = map[string]struct{}{
+	"authenticate":            {},
+	"encryptwallet":           {},
+	"generatewithvalidatekey": {},
+	"importprivkey":           {},
+	"rotaterpcauth":           {},
+	"setvalidatekeys":         {},
+	"signmessagewithprivkey":  {},
+	"signrawtransaction":      {},
+	"updatepspt":              {},
+	"walletpassphrase":        {},
+	"walletpassphrasechange":  {},
+}
+
+// rpcLimitClasses maps the names of the method classes which can be limited by
+// --rpclimit to the permissions required by the methods of the class.
+var rpcLimitClasses = map[string]rpcPermission{
+	"read":   rpcPermRead,
+	"wallet": rpcPermWallet,
+	"mining": rpcPermMining,
+	"admin":  rpcPermAdmin,
+}
+
+// parseRPCLimit parses the passed --rpclimit value, which has the form
+// class=rate/burst.  A rate of 0 means the class is not limited.
+func parseRPCLimit(rpcLimit string) (rpcPermission, peer.MessageLimit, error) {
+	name, limit, err := parseMessageLimit(rpcLimit)
+	if err != nil {
+		return 0, limit, err
+	}
+	class, ok := rpcLimitClasses[name]
+	if !ok {
+		return 0, limit, fmt.Errorf("unknown method class %q -- valid "+
+			"classes are {read, wallet, mining, admin}", name)
+	}
+	return class, limit, nil
+}
+
+// rpcLimitBucket is the token bucket of the calls of a user to the methods of
+// a class.
+type rpcLimitBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rpcActiveCall is an RPC call which is being processed.
+type rpcActiveCall struct {
+	method string
+	user   string
+	params interface{}
+	start  time.Time
+}
+
+// rpcMethodStats are the statistics of the calls of an RPC method.
+type rpcMethodStats struct {
+	calls       uint64
+	errors      uint64
+	rateLimited uint64
+	totalTime   time.Duration
+	maxTime     time.Duration
+}
+
+// rpcMetrics enforces the rate limits of the RPC users and keeps the
+// statistics of the RPC calls reported by getrpcinfo.
+//
+// This struct is safe for concurrent access.
+type rpcMetrics struct {
+	mtx        sync.Mutex
+	limits     map[rpcPermission]peer.MessageLimit
+	buckets    map[string]map[rpcPermission]*rpcLimitBucket
+	slowQuery  time.Duration
+	nextCallID uint64
+	active     map[uint64]*rpcActiveCall
+	methods    map[string]*rpcMethodStats
+
+	// idle is closed once no calls are in progress.  It is only set while
+	// a caller of idleChan waits for the calls in progress.
+	idle chan struct{}
+}
+
+// newRPCMetrics returns new RPC metrics enforcing the passed limits on the
+// calls to each method class, and logging calls taking at least the passed
+// duration.  A duration of zero disables logging slow calls.
+func newRPCMetrics(limits map[rpcPermission]peer.MessageLimit,
+	slowQuery time.Duration) *rpcMetrics {
+
+	return &rpcMetrics{
+		limits:    limits,
+		buckets:   make(map[string]map[rpcPermission]*rpcLimitBucket),
+		slowQuery: slowQuery,
+		active:    make(map[uint64]*rpcActiveCall),
+		methods:   make(map[string]*rpcMethodStats),
+	}
+}
+
+// setLimits replaces the limits on the calls to each method class and the
+// duration above which calls are logged as slow, such as when the
+// configuration is reloaded.  The tokens left to the users are kept, and
+// capped at the new burst of their class.
+func (m *rpcMetrics) setLimits(limits map[rpcPermission]peer.MessageLimit,
+	slowQuery time.Duration) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.limits = limits
+	m.slowQuery = slowQuery
+	for _, userBuckets := range m.buckets {
+		for class, b := range userBuckets {
+			limit, ok := limits[class]
+			if !ok {
+				delete(userBuckets, class)
+				continue
+			}
+			if b.tokens > limit.Burst {
+				b.tokens = limit.Burst
+			}
+		}
+	}
+}
+
+// methodStats returns the statistics of the passed method.
+//
+// This function MUST be called with the metrics lock held.
+func (m *rpcMetrics) methodStats(method string) *rpcMethodStats {
+	stats, ok := m.methods[method]
+	if !ok {
+		stats = &rpcMethodStats{}
+		m.methods[method] = stats
+	}
+	return stats
+}
+
+// allow returns whether the passed user may call a method of the passed class
+// at the passed time, and takes a token from the bucket of the user for the
+// class if it may.  Buckets are kept per user rather than per connection, so
+// clients can't avoid the limit by reconnecting.  The passed method is only
+// used to count the calls which were rejected, and may be empty.
+func (m *rpcMetrics) allow(user *rpcUser, class rpcPermission, method string,
+	now time.Time) bool {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	limit, ok := m.limits[class]
+	if !ok {
+		return true
+	}
+
+	userBuckets, ok := m.buckets[user.name]
+	if !ok {
+		userBuckets = make(map[rpcPermission]*rpcLimitBucket)
+		m.buckets[user.name] = userBuckets
+	}
+	b, ok := userBuckets[class]
+	if !ok {
+		b = &rpcLimitBucket{tokens: limit.Burst, last: now}
+		userBuckets[class] = b
+	}
+
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+		if b.tokens > limit.Burst {
+			b.tokens = limit.Burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		// Only count registered methods, so clients can't grow the
+		// statistics with made up method names.
+		if _, err := btcjson.MethodUsageFlags(method); err == nil {
+			m.methodStats(method).rateLimited++
+		}
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// startCall records the start of a call of the passed method by the passed
+// user with the passed parameters, and returns an id to finish it with.
+func (m *rpcMetrics) startCall(user *rpcUser, method string,
+	params interface{}) uint64 {
+
+	m.mtx.Lock()
+	m.nextCallID++
+	id := m.nextCallID
+	m.active[id] = &rpcActiveCall{
+		method: method,
+		user:   user.name,
+		params: params,
+		start:  time.Now(),
+	}
+	m.mtx.Unlock()
+	return id
+}
+
+// finishCall records the end of the call with the passed id and whether it
+// failed, and logs it when it was slow.
+func (m *rpcMetrics) finishCall(id uint64, failed bool) {
+	m.mtx.Lock()
+	call, ok := m.active[id]
+	if !ok {
+		m.mtx.Unlock()
+		return
+	}
+	delete(m.active, id)
+	if len(m.active) == 0 && m.idle != nil {
+		close(m.idle)
+		m.idle = nil
+	}
+	duration := time.Since(call.start)
+	stats := m.methodStats(call.method)
+	stats.calls++
+	if failed {
+		stats.errors++
+	}
+	stats.totalTime += duration
+	if duration > stats.maxTime {
+		stats.maxTime = duration
+	}
+	slowQuery := m.slowQuery
+	m.mtx.Unlock()
+
+	if slowQuery > 0 && duration >= slowQuery {
+		rpcsLog.Warnf("Slow RPC call %s by user %s took %v with "+
+			"parameters %s", call.method, call.user, duration,
+			slowQueryParams(call.method, call.params))
+	}
+}
+
+// idleChan returns a channel which is closed once no calls are in progress,
+// such as when the calls in progress are drained on shutdown.
+func (m *rpcMetrics) idleChan() <-chan struct{} {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if len(m.active) == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	if m.idle == nil {
+		m.idle = make(chan struct{})
+	}
+	return m.idle
+}
+
+// activeCalls returns the methods of the calls in progress along with how long
+// they have been running, longest first.
+func (m *rpcMetrics) activeCalls() []string {
+	m.mtx.Lock()
+	calls := make([]*rpcActiveCall, 0, len(m.active))
+	for _, call := range m.active {
+		calls = append(calls, call)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].start.Before(calls[j].start)
+	})
+	now := time.Now()
+	active := make([]string, 0, len(calls))
+	for _, call := range calls {
+		active = append(active, fmt.Sprintf("%s (%v)", call.method,
+			now.Sub(call.start).Truncate(time.Millisecond)))
+	}
+	return active
+}
+
+// slowQueryParams returns the parameters of a call of the passed method as
+// they are logged along with slow calls.
+func slowQueryParams(method string, params interface{}) string {
+	if _, ok := rpcRedactedMethods[method]; ok {
+		return "[redacted]"
+	}
+	marshalled, err := json.Marshal(params)
+	if err != nil {
+		return "[unknown]"
+	}
+	if len(marshalled) > maxSlowQueryParamsLen {
+		return string(marshalled[:maxSlowQueryParamsLen]) + "..."
+	}
+	return string(marshalled)
+}
+
+// info returns the calls in progress and the statistics of the calls of each
+// method, sorted by method, as reported by getrpcinfo.  Durations are in
+// microseconds.
+func (m *rpcMetrics) info() *btcjson.GetRPCInfoResult {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	result := &btcjson.GetRPCInfoResult{
+		ActiveCommands: make([]btcjson.RPCActiveCommandResult, 0,
+			len(m.active)),
+		Methods: make([]btcjson.RPCMethodStatsResult, 0,
+			len(m.methods)),
+	}
+	for _, call := range m.active {
+		result.ActiveCommands = append(result.ActiveCommands,
+			btcjson.RPCActiveCommandResult{
+				Method: call.method,
+				User:   call.user,
+				Duration: int64(now.Sub(call.start) /
+					time.Microsecond),
+			})
+	}
+	for method, stats := range m.methods {
+		var avgTime time.Duration
+		if stats.calls > 0 {
+			avgTime = stats.totalTime / time.Duration(stats.calls)
+		}
+		result.Methods = append(result.Methods,
+			btcjson.RPCMethodStatsResult{
+				Method:      method,
+				Calls:       stats.calls,
+				Errors:      stats.errors,
+				RateLimited: stats.rateLimited,
+				AvgTime:     int64(avgTime / time.Microsecond),
+				MaxTime:     int64(stats.maxTime / time.Microsecond),
+			})
+	}
+	sort.Slice(result.ActiveCommands, func(i, j int) bool {
+		return result.ActiveCommands[i].Duration >
+			result.ActiveCommands[j].Duration
+	})
+	sort.Slice(result.Methods, func(i, j int) bool {
+		return result.Methods[i].Method < result.Methods[j].Method
+	})
+	return result
+}
+
+// rpcRateLimitedError returns the error of a call of the passed method by a
+// user over the rate limit of the class of the method.
+func rpcRateLimitedError(method string) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code: btcjson.ErrRPCRateLimited,
+		Message: fmt.Sprintf("rate limit of %v methods exceeded -- "+
+			"try again later", rpcMethodPermission(method)),
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/peer"
+)
+
+// TestParseRPCLimit ensures --rpclimit values are parsed into the limit of
+// their method class, and malformed values are rejected.
+func TestParseRPCLimit(t *testing.T) {
+	tests := []struct {
+		rpcLimit string
+		class    rpcPermission
+		limit    peer.MessageLimit
+		valid    bool
+	}{
+		{"read=50/100", rpcPermRead, peer.MessageLimit{Rate: 50, Burst: 100}, true},
+		{"Admin=0.5/2", rpcPermAdmin, peer.MessageLimit{Rate: 0.5, Burst: 2}, true},
+		{"wallet=0", rpcPermWallet, peer.MessageLimit{}, true},
+		{"getinfo=1/1", 0, peer.MessageLimit{}, false},
+		{"mining=1", 0, peer.MessageLimit{}, false},
+		{"mining", 0, peer.MessageLimit{}, false},
+	}
+
+	for _, test := range tests {
+		class, limit, err := parseRPCLimit(test.rpcLimit)
+		if (err == nil) != test.valid {
+			t.Errorf("parseRPCLimit(%q): unexpected error %v",
+				test.rpcLimit, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if class != test.class || limit != test.limit {
+			t.Errorf("parseRPCLimit(%q): got %v %+v, want %v %+v",
+				test.rpcLimit, class, limit, test.class, test.limit)
+		}
+	}
+}
+
+// TestRPCMetricsAllow ensures the calls of each user to each method class are
+// limited separately, and rejected calls are counted.
+func TestRPCMetricsAllow(t *testing.T) {
+	m := newRPCMetrics(map[rpcPermission]peer.MessageLimit{
+		rpcPermRead: {Rate: 1, Burst: 2},
+	}, 0)
+	alice := newRPCUser("alice", "pass", rpcPermAll)
+	bob := newRPCUser("bob", "pass", rpcPermAll)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if !m.allow(alice, rpcPermRead, "getinfo", now) {
+			t.Fatalf("call %d within the burst rejected", i)
+		}
+	}
+	if m.allow(alice, rpcPermRead, "getinfo", now) {
+		t.Errorf("call over the burst allowed")
+	}
+	if !m.allow(bob, rpcPermRead, "getinfo", now) {
+		t.Errorf("call of another user rejected")
+	}
+	if !m.allow(alice, rpcPermAdmin, "stop", now) {
+		t.Errorf("call of an unlimited class rejected")
+	}
+	if !m.allow(alice, rpcPermRead, "getinfo", now.Add(time.Second)) {
+		t.Errorf("call after the bucket refilled rejected")
+	}
+
+	// Made up methods are not counted.
+	m.allow(alice, rpcPermRead, "nosuchmethod", now)
+
+	info := m.info()
+	if len(info.Methods) != 1 || info.Methods[0].Method != "getinfo" ||
+		info.Methods[0].RateLimited != 1 {
+
+		t.Errorf("unexpected method statistics %+v", info.Methods)
+	}
+}
+
+// TestRPCMetricsCalls ensures calls are reported as active until they finish,
+// and the statistics of finished calls are kept.
+func TestRPCMetricsCalls(t *testing.T) {
+	m := newRPCMetrics(nil, 0)
+	user := newRPCUser("alice", "pass", rpcPermAll)
+
+	first := m.startCall(user, "getinfo", nil)
+	second := m.startCall(user, "getblock", nil)
+	info := m.info()
+	if len(info.ActiveCommands) != 2 || len(info.Methods) != 0 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+
+	m.finishCall(first, false)
+	m.finishCall(second, true)
+	m.finishCall(second, true)
+	info = m.info()
+	if len(info.ActiveCommands) != 0 {
+		t.Errorf("finished calls still active: %+v", info.ActiveCommands)
+	}
+	if len(info.Methods) != 2 {
+		t.Fatalf("unexpected method statistics %+v", info.Methods)
+	}
+	getBlock, getInfo := info.Methods[0], info.Methods[1]
+	if getBlock.Method != "getblock" || getBlock.Calls != 1 ||
+		getBlock.Errors != 1 {
+
+		t.Errorf("unexpected getblock statistics %+v", getBlock)
+	}
+	if getInfo.Method != "getinfo" || getInfo.Calls != 1 ||
+		getInfo.Errors != 0 {
+
+		t.Errorf("unexpected getinfo statistics %+v", getInfo)
+	}
+}
+
+// TestRPCMetricsIdle ensures the idle channel is closed once the calls in
+// progress finish, which is how the calls are drained on shutdown.
+func TestRPCMetricsIdle(t *testing.T) {
+	m := newRPCMetrics(nil, 0)
+	user := newRPCUser("alice", "pass", rpcPermAll)
+
+	select {
+	case <-m.idleChan():
+	default:
+		t.Fatalf("idle channel not closed without calls in progress")
+	}
+
+	first := m.startCall(user, "getinfo", nil)
+	second := m.startCall(user, "generate", nil)
+	idle := m.idleChan()
+	if active := m.activeCalls(); len(active) != 2 {
+		t.Fatalf("got active calls %v, want 2", active)
+	}
+
+	m.finishCall(first, false)
+	select {
+	case <-idle:
+		t.Fatalf("idle channel closed with a call in progress")
+	default:
+	}
+	m.finishCall(second, false)
+	select {
+	case <-idle:
+	default:
+		t.Fatalf("idle channel not closed once the calls finished")
+	}
+}
+
+// TestSlowQueryParams ensures the parameters of methods which take secrets are
+// redacted, every redacted method exists, and other parameters are truncated.
+func TestSlowQueryParams(t *testing.T) {
+	for method := range rpcRedactedMethods {
+		_, handled := rpcHandlers[method]
+		_, wallet := rpcAskWallet[method]
+		_, ws := wsHandlers[method]
+		if !handled && !wallet && !ws && method != "authenticate" {
+			t.Errorf("redacted method %s does not exist", method)
+		}
+		if got := slowQueryParams(method, []string{"secret"}); got !=
+			"[redacted]" {
+
+			t.Errorf("slowQueryParams(%s): got %s, want [redacted]",
+				method, got)
+		}
+	}
+
+	if got := slowQueryParams("getblock", []string{"hash"}); got !=
+		`["hash"]` {
+
+		t.Errorf("slowQueryParams: got %s, want [\"hash\"]", got)
+	}
+	long := make([]int, maxSlowQueryParamsLen)
+	if got := slowQueryParams("getblock", long); len(got) !=
+		maxSlowQueryParamsLen+len("...") {
+
+		t.Errorf("slowQueryParams: got %d bytes for long parameters, "+
+			"want %d", len(got), maxSlowQueryParamsLen+len("..."))
+	}
+}
@@ -0,0 +1,505 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// restPathPrefix is the path prefix of the REST interface.
+	restPathPrefix = "/rest/"
+
+	// restMaxHeaders is the maximum number of headers returned by a single
+	// headers request.
+	restMaxHeaders = 2000
+
+	// restMaxOutPoints is the maximum number of outpoints queried by a
+	// single getutxos request.
+	restMaxOutPoints = 15
+
+	// restMempoolHeight is the height reported for unspent outputs of
+	// transactions in the memory pool, which matches the reference
+	// implementation.
+	restMempoolHeight = 0x7fffffff
+)
+
+// restFormat identifies the output format of a REST request.
+type restFormat int
+
+// The output formats of REST requests, selected by the extension of the last
+// path component.
+const (
+	restFormatBinary restFormat = iota
+	restFormatHex
+	restFormatJSON
+)
+
+// restFormats maps the extensions of REST requests to their output format.
+var restFormats = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restError describes a failed REST request along with its HTTP status code.
+type restError struct {
+	code    int
+	message string
+}
+
+// Error satisfies the error interface.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// newRESTError returns a restError with the passed HTTP status code and a
+// message formatted according to the passed format specifier.
+func newRESTError(code int, format string, a ...interface{}) *restError {
+	return &restError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+// restErrorFromRPC converts an error returned by a JSON-RPC handler to a
+// restError.  Errors about missing blocks and transactions are reported as not
+// found, while any other error is an internal error.
+func restErrorFromRPC(err error) *restError {
+	if rpcErr, ok := err.(*btcjson.RPCError); ok {
+		// Missing blocks and transactions share the same error code.
+		switch rpcErr.Code {
+		case btcjson.ErrRPCBlockNotFound:
+			return newRESTError(http.StatusNotFound, "%s", rpcErr.Message)
+		case btcjson.ErrRPCDecodeHexString:
+			return newRESTError(http.StatusBadRequest, "%s",
+				rpcErr.Message)
+		}
+		return newRESTError(http.StatusInternalServerError, "%s",
+			rpcErr.Message)
+	}
+	return newRESTError(http.StatusInternalServerError, "%v", err)
+}
+
+// restFormatError returns the error of a request for an output format the
+// resource is not available in.
+func restFormatError(available string) *restError {
+	return newRESTError(http.StatusNotFound, "output format not found "+
+		"(available: %s)", available)
+}
+
+// parseRESTPath splits the passed path below the REST prefix into its
+// components and returns them along with the output format selected by the
+// extension of the last component, which is removed from it.
+func parseRESTPath(path string) ([]string, restFormat, error) {
+	parts := strings.Split(path, "/")
+	last := parts[len(parts)-1]
+	dot := strings.LastIndex(last, ".")
+	if dot < 0 {
+		return nil, 0, restFormatError("bin, hex, json")
+	}
+	format, ok := restFormats[last[dot+1:]]
+	if !ok {
+		return nil, 0, restFormatError("bin, hex, json")
+	}
+	parts[len(parts)-1] = last[:dot]
+	return parts, format, nil
+}
+
+// parseRESTHash returns the hash encoded in the passed string.
+func parseRESTHash(str string) (*chainhash.Hash, error) {
+	if len(str) != chainhash.MaxHashStringSize {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid hash: %s",
+			str)
+	}
+	hash, err := chainhash.NewHashFromStr(str)
+	if err != nil {
+		return nil, newRESTError(http.StatusBadRequest, "Invalid hash: %s",
+			str)
+	}
+	return hash, nil
+}
+
+// parseRESTOutPoint returns the outpoint encoded as <txid>-<index> in the
+// passed string.
+func parseRESTOutPoint(str string) (*wire.OutPoint, error) {
+	sep := strings.Index(str, "-")
+	if sep < 0 {
+		return nil, newRESTError(http.StatusBadRequest,
+			"Parse error: invalid outpoint %s", str)
+	}
+	hash, err := parseRESTHash(str[:sep])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(str[sep+1:], 10, 32)
+	if err != nil {
+		return nil, newRESTError(http.StatusBadRequest,
+			"Parse error: invalid outpoint %s", str)
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// handleREST serves the read-only REST interface, which mirrors the REST
+// interface of the reference implementation.  Requests are not authenticated,
+// so it is only served when enabled with --rest.
+func (s *rpcServer) handleREST(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Limit the number of connections to max allowed.
+	if s.limitConnections(w, r.RemoteAddr) {
+		return
+	}
+	s.incrementClients()
+	defer s.decrementClients()
+
+	body, contentType, err := s.restResponse(r.URL.Path[len(restPathPrefix):])
+	if err != nil {
+		restErr, ok := err.(*restError)
+		if !ok {
+			restErr = newRESTError(http.StatusInternalServerError,
+				"%v", err)
+		}
+		rpcsLog.Debugf("REST request %s from %s failed: %v", r.URL.Path,
+			r.RemoteAddr, restErr)
+		http.Error(w, restErr.message, restErr.code)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if _, err := w.Write(body); err != nil {
+		rpcsLog.Errorf("Failed to write REST reply to %s: %v",
+			r.RemoteAddr, err)
+	}
+}
+
+// restResponse returns the body and content type of the response to the REST
+// request for the passed path below the REST prefix.
+func (s *rpcServer) restResponse(path string) ([]byte, string, error) {
+	parts, format, err := parseRESTPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Requests only need raw data for the binary and hex formats, and a
+	// result to marshal for the JSON format.
+	var raw []byte
+	var result interface{}
+	switch {
+	case parts[0] == "tx" && len(parts) == 2:
+		raw, result, err = s.restTx(parts[1], format)
+
+	case parts[0] == "block" && len(parts) == 2:
+		raw, result, err = s.restBlock(parts[1], true, format)
+
+	case parts[0] == "block" && len(parts) == 3 && parts[1] == "notxdetails":
+		raw, result, err = s.restBlock(parts[2], false, format)
+
+	case parts[0] == "headers" && len(parts) == 3:
+		raw, result, err = s.restHeaders(parts[1], parts[2], format)
+
+	case parts[0] == "getutxos" && len(parts) > 1:
+		raw, result, err = s.restUTXOs(parts[1:], format)
+
+	case parts[0] == "keyview" && len(parts) == 1:
+		if format != restFormatJSON {
+			return nil, "", restFormatError("json")
+		}
+		result, err = handleGetAdminInfo(s, nil, nil)
+
+	case parts[0] == "mempool" && len(parts) == 2 && parts[1] == "info":
+		if format != restFormatJSON {
+			return nil, "", restFormatError("json")
+		}
+		result, err = handleGetMempoolInfo(s, nil, nil)
+
+	case parts[0] == "mempool" && len(parts) == 2 && parts[1] == "contents":
+		if format != restFormatJSON {
+			return nil, "", restFormatError("json")
+		}
+		result = s.server.txMemPool.RawMempoolVerbose()
+
+	default:
+		return nil, "", newRESTError(http.StatusNotFound, "Not found")
+	}
+	if err != nil {
+		if _, ok := err.(*restError); ok {
+			return nil, "", err
+		}
+		return nil, "", restErrorFromRPC(err)
+	}
+
+	switch format {
+	case restFormatBinary:
+		return raw, "application/octet-stream", nil
+	case restFormatHex:
+		return []byte(hex.EncodeToString(raw) + "\n"), "text/plain", nil
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, "", err
+	}
+	return append(body, '\n'), "application/json", nil
+}
+
+// restTx returns a transaction of the memory pool, or of the main chain when
+// the transaction index is enabled.
+func (s *rpcServer) restTx(hashStr string, format restFormat) ([]byte, interface{}, error) {
+	if _, err := parseRESTHash(hashStr); err != nil {
+		return nil, nil, err
+	}
+
+	verbose := 0
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	result, err := handleGetRawTransaction(s, &btcjson.GetRawTransactionCmd{
+		Txid:    hashStr,
+		Verbose: &verbose,
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == restFormatJSON {
+		return nil, result, nil
+	}
+	raw, err := hex.DecodeString(result.(string))
+	return raw, nil, err
+}
+
+// restBlock returns a block of the main chain, with the details of its
+// transactions when requested.
+func (s *rpcServer) restBlock(hashStr string, txDetails bool, format restFormat) ([]byte, interface{}, error) {
+	if _, err := parseRESTHash(hashStr); err != nil {
+		return nil, nil, err
+	}
+
+	var verbosity int
+	if format == restFormatJSON {
+		verbosity = 1
+	}
+	result, err := handleGetBlock(s, &btcjson.GetBlockCmd{
+		Hash:      hashStr,
+		Verbosity: &verbosity,
+		VerboseTx: &txDetails,
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if verbosity != 0 {
+		return nil, result, nil
+	}
+	raw, err := hex.DecodeString(result.(string))
+	return raw, nil, err
+}
+
+// restHeaders returns up to the passed number of headers of the main chain,
+// starting with the header of the block with the passed hash.
+func (s *rpcServer) restHeaders(countStr, hashStr string, format restFormat) ([]byte, interface{}, error) {
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 || count > restMaxHeaders {
+		return nil, nil, newRESTError(http.StatusBadRequest,
+			"Header count out of range: %s", countStr)
+	}
+	hash, err := parseRESTHash(hashStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Headers of blocks which are not in the main chain are not known,
+	// which the reference implementation reports as an empty result.
+	height, err := s.chain.BlockHeightByHash(hash)
+	if err != nil {
+		count = 0
+	}
+	best := s.chain.BestSnapshot()
+	if remaining := int(best.Height-height) + 1; count > remaining {
+		count = remaining
+	}
+
+	var raw bytes.Buffer
+	results := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		hash, err := s.chain.BlockHashByHeight(height + uint32(i))
+		if err != nil {
+			break
+		}
+
+		if format == restFormatJSON {
+			verbose := true
+			result, err := handleGetBlockHeader(s,
+				&btcjson.GetBlockHeaderCmd{
+					Hash:    hash.String(),
+					Verbose: &verbose,
+				}, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			results = append(results, result)
+			continue
+		}
+
+		header, err := s.chain.FetchHeader(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := header.Serialize(&raw); err != nil {
+			return nil, nil, err
+		}
+	}
+	return raw.Bytes(), results, nil
+}
+
+// restUTXO describes an unspent transaction output of a getutxos request.
+type restUTXO struct {
+	Height       uint32                     `json:"height"`
+	Value        float64                    `json:"value"`
+	ScriptPubKey btcjson.ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// restUTXOsResult models the JSON result of a getutxos request.
+type restUTXOsResult struct {
+	ChainHeight  uint32     `json:"chainHeight"`
+	ChainTipHash string     `json:"chaintipHash"`
+	Bitmap       string     `json:"bitmap"`
+	UTXOs        []restUTXO `json:"utxos"`
+}
+
+// restUTXOs returns which of the passed outpoints are unspent, along with the
+// unspent outputs.  When the first part is checkmempool, outputs of
+// transactions in the memory pool are included and outputs spent by them are
+// excluded.
+func (s *rpcServer) restUTXOs(parts []string, format restFormat) ([]byte, interface{}, error) {
+	checkMempool := parts[0] == "checkmempool"
+	if checkMempool {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return nil, nil, newRESTError(http.StatusBadRequest,
+			"Error: empty request")
+	}
+	if len(parts) > restMaxOutPoints {
+		return nil, nil, newRESTError(http.StatusBadRequest,
+			"Error: max outpoints exceeded (max: %d, tried: %d)",
+			restMaxOutPoints, len(parts))
+	}
+	outpoints := make([]*wire.OutPoint, 0, len(parts))
+	for _, part := range parts {
+		op, err := parseRESTOutPoint(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		outpoints = append(outpoints, op)
+	}
+
+	// The bitmap has a bit set for every unspent outpoint, in the order
+	// of the outpoints.
+	best := s.chain.BestSnapshot()
+	bitmap := make([]byte, (len(outpoints)+7)/8)
+	bitmapStr := make([]byte, len(outpoints))
+	var utxos []restUTXO
+	var txOuts []*wire.TxOut
+	var versions []int32
+	mp := s.server.txMemPool
+	for i, op := range outpoints {
+		bitmapStr[i] = '0'
+
+		var tx *provautil.Tx
+		if checkMempool {
+			if mp.CheckSpend(*op) != nil {
+				continue
+			}
+			tx, _ = mp.FetchTransaction(&op.Hash)
+		}
+
+		var height uint32
+		var version int32
+		var txOut *wire.TxOut
+		if tx != nil {
+			mtx := tx.MsgTx()
+			if op.Index >= uint32(len(mtx.TxOut)) {
+				continue
+			}
+			height = restMempoolHeight
+			version = mtx.Version
+			txOut = mtx.TxOut[op.Index]
+		} else {
+			entry, err := s.chain.FetchUtxoEntry(&op.Hash)
+			if err != nil {
+				return nil, nil, err
+			}
+			if entry == nil || entry.IsOutputSpent(op.Index) {
+				continue
+			}
+			height = entry.BlockHeight()
+			version = entry.Version()
+			txOut = wire.NewTxOut(entry.AmountByIndex(op.Index),
+				entry.PkScriptByIndex(op.Index))
+		}
+
+		bitmap[i/8] |= 1 << uint(i%8)
+		bitmapStr[i] = '1'
+		utxos = append(utxos, restUTXO{
+			Height: height,
+			Value:  provautil.Amount(txOut.Value).ToRMG(),
+			ScriptPubKey: createScriptPubKeyResult(txOut.PkScript,
+				s.server.chainParams),
+		})
+		txOuts = append(txOuts, txOut)
+		versions = append(versions, version)
+	}
+
+	if format == restFormatJSON {
+		return nil, &restUTXOsResult{
+			ChainHeight:  best.Height,
+			ChainTipHash: best.Hash.String(),
+			Bitmap:       string(bitmapStr),
+			UTXOs:        utxos,
+		}, nil
+	}
+
+	// The binary format is the chain height and tip hash followed by the
+	// bitmap and the unspent outputs along with their height, which matches
+	// the reference implementation.
+	var raw bytes.Buffer
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], best.Height)
+	raw.Write(buf[:])
+	raw.Write(best.Hash[:])
+	if err := wire.WriteVarBytes(&raw, 0, bitmap); err != nil {
+		return nil, nil, err
+	}
+	if err := wire.WriteVarInt(&raw, 0, uint64(len(txOuts))); err != nil {
+		return nil, nil, err
+	}
+	for i, txOut := range txOuts {
+		binary.LittleEndian.PutUint32(buf[:], uint32(versions[i]))
+		raw.Write(buf[:])
+		binary.LittleEndian.PutUint32(buf[:], utxos[i].Height)
+		raw.Write(buf[:])
+		err := wire.WriteTxOut(&raw, 0, versions[i], txOut)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return raw.Bytes(), nil, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// TestParseRESTPath ensures REST paths are split into their components and
+// output format, and paths without a known format are rejected.
+func TestParseRESTPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		parts  []string
+		format restFormat
+		valid  bool
+	}{
+		{"tx/ab.json", []string{"tx", "ab"}, restFormatJSON, true},
+		{"block/notxdetails/ab.bin", []string{"block", "notxdetails", "ab"},
+			restFormatBinary, true},
+		{"getutxos/ab-0/cd-1.hex", []string{"getutxos", "ab-0", "cd-1"},
+			restFormatHex, true},
+		{"mempool/info.json", []string{"mempool", "info"},
+			restFormatJSON, true},
+		{"tx/ab", nil, 0, false},
+		{"tx/ab.xml", nil, 0, false},
+		{"tx.json/ab", nil, 0, false},
+	}
+	for _, test := range tests {
+		parts, format, err := parseRESTPath(test.path)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+			continue
+		}
+		if !test.valid {
+			if restErr, ok := err.(*restError); !ok ||
+				restErr.code != http.StatusNotFound {
+				t.Errorf("%s: got error %v, want not found",
+					test.path, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(parts, test.parts) || format != test.format {
+			t.Errorf("%s: got %v %v, want %v %v", test.path, parts,
+				format, test.parts, test.format)
+		}
+	}
+}
+
+// TestParseRESTOutPoint ensures outpoints of getutxos requests are parsed and
+// malformed outpoints are rejected as bad requests.
+func TestParseRESTOutPoint(t *testing.T) {
+	txid := "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+	op, err := parseRESTOutPoint(txid + "-7")
+	if err != nil {
+		t.Fatalf("parseRESTOutPoint: unexpected error: %v", err)
+	}
+	if op.Hash.String() != txid || op.Index != 7 {
+		t.Errorf("parseRESTOutPoint: got %v, want %s:7", op, txid)
+	}
+
+	invalid := []string{txid, txid + "-", txid + "-x", txid + "--1",
+		txid[1:] + "-0", "zz" + txid[2:] + "-0"}
+	for _, str := range invalid {
+		_, err := parseRESTOutPoint(str)
+		restErr, ok := err.(*restError)
+		if !ok || restErr.code != http.StatusBadRequest {
+			t.Errorf("parseRESTOutPoint(%q): got error %v, want bad "+
+				"request", str, err)
+		}
+	}
+}
@@ -8,33 +8,44 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/bitgo/prova/attestation"
+	"github.com/bitgo/prova/auditlog"
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/eventlog"
 	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/bloom"
+	"github.com/bitgo/prova/provautil/pspt"
+	"github.com/bitgo/prova/txrules/adminbuilder"
 	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
 	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/golangcrypto/ripemd160"
 	"github.com/btcsuite/websocket"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -65,6 +76,18 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxEventLogCount is the maximum number of events returned by a
+	// single geteventlog call.
+	maxEventLogCount = 10000
+
+	// maxAuditLogCount is the maximum number of entries returned by a
+	// single getauditlog call.
+	maxAuditLogCount = 10000
+
+	// maxValidatorInfoWindows is the maximum number of windows the signed
+	// blocks are counted for by a single getvalidatorinfo call.
+	maxValidatorInfoWindows = 16
 )
 
 var (
@@ -108,6 +131,14 @@ var (
 		Code:    btcjson.ErrRPCNoWallet,
 		Message: "This implementation does not implement wallet commands",
 	}
+
+	// ErrRPCReadOnly is an error returned to RPC clients when the provided
+	// command needs to write to the block database or to reach peers while
+	// the node runs with --readonly.
+	ErrRPCReadOnly = &btcjson.RPCError{
+		Code:    btcjson.ErrRPCMisc,
+		Message: "Command unavailable while the block database is opened read-only",
+	}
 )
 
 type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{}, error)
@@ -117,47 +148,141 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getaddresstxids":       handleGetAddressTxIds,
-	"getadmininfo":          handleGetAdminInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocktemplate":      handleGetBlockTemplate,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getheaders":            handleGetHeaders,
-	"getinfo":               handleGetInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"gettxout":              handleGetTxOut,
-	"help":                  handleHelp,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"setvalidatekeys":       handleSetValidateKeys,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
+	"addnode":                    handleAddNode,
+	"admin.addproposalsigs":      handleAdminAddProposalSigs,
+	"admin.destroytokens":        handleAdminDestroyTokens,
+	"admin.dropproposal":         handleAdminDropProposal,
+	"admin.freezekeyid":          handleAdminFreezeKeyID,
+	"admin.getkeyidinfo":         handleAdminGetKeyIDInfo,
+	"admin.getproposal":          handleAdminGetProposal,
+	"admin.issuetokens":          handleAdminIssueTokens,
+	"admin.listkeysets":          handleAdminListKeySets,
+	"admin.listproposals":        handleAdminListProposals,
+	"admin.proposetx":            handleAdminProposeTx,
+	"admin.provisionvalidatekey": handleAdminProvisionValidateKey,
+	"admin.revokekey":            handleAdminRevokeKey,
+	"admin.rotatekeyid":          handleAdminRotateKeyID,
+	"admin.signproposal":         handleAdminSignProposal,
+	"admin.unfreezekeyid":        handleAdminUnfreezeKeyID,
+	"backupchainstate":           handleBackupChainState,
+	"broadcasttransaction":       handleBroadcastTransaction,
+	"clearbanned":                handleClearBanned,
+	"combinepspt":                handleCombinePSPT,
+	"compactdb":                  handleCompactDB,
+	"createconsolidatetx":        handleCreateConsolidateTx,
+	"createdestroytx":            handleCreateDestroyTx,
+	"createissuetx":              handleCreateIssueTx,
+	"createkeyrevoketx":          handleCreateKeyRevokeTx,
+	"createprovisiontx":          handleCreateProvisionTx,
+	"createpspt":                 handleCreatePSPT,
+	"createrawtransaction":       handleCreateRawTransaction,
+	"createsplittx":              handleCreateSplitTx,
+	"createsweeptx":              handleCreateSweepTx,
+	"debuglevel":                 handleDebugLevel,
+	"debugscript":                handleDebugScript,
+	"decoderawtransaction":       handleDecodeRawTransaction,
+	"decodescript":               handleDecodeScript,
+	"dropindex":                  handleDropIndex,
+	"enableindex":                handleEnableIndex,
+	"finalizepspt":               handleFinalizePSPT,
+	"fundrawtransaction":         handleFundRawTransaction,
+	"generate":                   handleGenerate,
+	"generatetoaddress":          handleGenerateToAddress,
+	"generatewithvalidatekey":    handleGenerateWithValidateKey,
+	"getaddednodeinfo":           handleGetAddedNodeInfo,
+	"getaddressbalance":          handleGetAddressBalance,
+	"getaddressdeltas":           handleGetAddressDeltas,
+	"getaddressmempool":          handleGetAddressMempool,
+	"getaddresstxids":            handleGetAddressTxIds,
+	"getaddressutxos":            handleGetAddressUtxos,
+	"getadmininfo":               handleGetAdminInfo,
+	"getattestation":             handleGetAttestation,
+	"getauditlog":                handleGetAuditLog,
+	"getbestblock":               handleGetBestBlock,
+	"getbestblockhash":           handleGetBestBlockHash,
+	"getblock":                   handleGetBlock,
+	"getblockchaininfo":          handleGetBlockChainInfo,
+	"getblockcount":              handleGetBlockCount,
+	"getblockhash":               handleGetBlockHash,
+	"getblockhashbytime":         handleGetBlockHashByTime,
+	"getblockheader":             handleGetBlockHeader,
+	"getblockheaders":            handleGetBlockHeaders,
+	"getblocktemplate":           handleGetBlockTemplate,
+	"getbroadcaststatus":         handleGetBroadcastStatus,
+	"getcfilter":                 handleGetCFilter,
+	"getcfilterheader":           handleGetCFilterHeader,
+	"getcirculatingsupply":       handleGetCirculatingSupply,
+	"getclusterinfo":             handleGetClusterInfo,
+	"getconnectioncount":         handleGetConnectionCount,
+	"getconsistencystatus":       handleGetConsistencyStatus,
+	"getcurrentnet":              handleGetCurrentNet,
+	"getdbinfo":                  handleGetDBInfo,
+	"getdiagnostics":             handleGetDiagnostics,
+	"getdifficulty":              handleGetDifficulty,
+	"geteventlog":                handleGetEventLog,
+	"getexploreraddress":         handleGetExplorerAddress,
+	"getexplorerblocks":          handleGetExplorerBlocks,
+	"getexplorercharts":          handleGetExplorerCharts,
+	"getexplorertransaction":     handleGetExplorerTransaction,
+	"getgenerate":                handleGetGenerate,
+	"gethashcacheinfo":           handleGetHashCacheInfo,
+	"gethashespersec":            handleGetHashesPerSec,
+	"getheaders":                 handleGetHeaders,
+	"getindexinfo":               handleGetIndexInfo,
+	"getinfo":                    handleGetInfo,
+	"getkeyidbalance":            handleGetKeyIDBalance,
+	"getkeyidvelocity":           handleGetKeyIDVelocity,
+	"getmempoolinfo":             handleGetMempoolInfo,
+	"getmininginfo":              handleGetMiningInfo,
+	"getnettotals":               handleGetNetTotals,
+	"getnetworkhashps":           handleGetNetworkHashPS,
+	"getorphanblocks":            handleGetOrphanBlocks,
+	"getpeerinfo":                handleGetPeerInfo,
+	"getrawmempool":              handleGetRawMempool,
+	"getrpcinfo":                 handleGetRPCInfo,
+	"gethealth":                  handleGetHealth,
+	"getloglevels":               handleGetLogLevels,
+	"getrawtransaction":          handleGetRawTransaction,
+	"getspentinfo":               handleGetSpentInfo,
+	"getspvinfo":                 handleGetSPVInfo,
+	"getsupplyhistory":           handleGetSupplyHistory,
+	"gettxout":                   handleGetTxOut,
+	"gettxoutproof":              handleGetTxOutProof,
+	"getutxocommitment":          handleGetUtxoCommitment,
+	"getutxoproof":               handleGetUtxoProof,
+	"getvalidatorheartbeats":     handleGetValidatorHeartbeats,
+	"getvalidatorschedule":       handleGetValidatorSchedule,
+	"getvalidatorinfo":           handleGetValidatorInfo,
+	"help":                       handleHelp,
+	"listbanned":                 handleListBanned,
+	"node":                       handleNode,
+	"ping":                       handlePing,
+	"searchrawtransactions":      handleSearchRawTransactions,
+	"sendrawtransaction":         handleSendRawTransaction,
+	"setban":                     handleSetBan,
+	"setgenerate":                handleSetGenerate,
+	"setkeyidvelocityoverride":   handleSetKeyIDVelocityOverride,
+	"setmocktime":                handleSetMockTime,
+	"resetsignguard":             handleResetSignGuard,
+	"rotaterpcauth":              handleRotateRPCAuth,
+	"rpc.discover":               handleRPCDiscover,
+	"setprofileserver":           handleSetProfileServer,
+	"setvalidatekeys":            handleSetValidateKeys,
+	"signmessagewithprivkey":     handleSignMessageWithPrivKey,
+	"signrawtransaction":         handleSignRawTransaction,
+	"startcpuprofile":            handleStartCPUProfile,
+	"stop":                       handleStop,
+	"stopcpuprofile":             handleStopCPUProfile,
+	"submitblock":                handleSubmitBlock,
+	"updatepspt":                 handleUpdatePSPT,
+	"validateaddress":            handleValidateAddress,
+	"verifyattestation":          handleVerifyAttestation,
+	"verifyauditlog":             handleVerifyAuditLog,
+	"verifychain":                handleVerifyChain,
+	"verifymessage":              handleVerifyMessage,
+	"verifytxoutproof":           handleVerifyTxOutProof,
+	"verifyutxoproof":            handleVerifyUtxoProof,
+	"writeprofile":               handleWriteProfile,
 }
 
 // list of commands that we recognize, but for which there is no support because
@@ -201,7 +326,6 @@ var rpcAskWallet = map[string]struct{}{
 	"setaccount":             {},
 	"settxfee":               {},
 	"signmessage":            {},
-	"signrawtransaction":     {},
 	"walletlock":             {},
 	"walletpassphrase":       {},
 	"walletpassphrasechange": {},
@@ -209,58 +333,126 @@ var rpcAskWallet = map[string]struct{}{
 
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
-	"estimatefee":       {},
-	"estimatepriority":  {},
-	"getblockchaininfo": {},
-	"getchaintips":      {},
-	"getmempoolentry":   {},
-	"getnetworkinfo":    {},
-	"getwork":           {},
-	"invalidateblock":   {},
-	"preciousblock":     {},
-	"reconsiderblock":   {},
-}
-
-// Commands that are available to a limited user
+	"estimatefee":      {},
+	"estimatepriority": {},
+	"getchaintips":     {},
+	"getmempoolentry":  {},
+	"getnetworkinfo":   {},
+	"getwork":          {},
+	"invalidateblock":  {},
+	"preciousblock":    {},
+	"reconsiderblock":  {},
+}
+
+// Commands that write to the block database or rely on peers, which are refused
+// when the node runs with --readonly.  Transactions submitted through any
+// command are refused as well.
+var rpcReadOnlyRefused = map[string]struct{}{
+	"addnode":                 {},
+	"compactdb":               {},
+	"dropindex":               {},
+	"enableindex":             {},
+	"generate":                {},
+	"generatetoaddress":       {},
+	"generatewithvalidatekey": {},
+	"node":                    {},
+	"setgenerate":             {},
+	"setmocktime":             {},
+	"setvalidatekeys":         {},
+	"submitblock":             {},
+}
+
+// Commands that are available to all users, which only query the state of the
+// chain and the node.  See rpcauth.go for the commands which need further
+// permissions.
 var rpcLimited = map[string]struct{}{
 	// Websockets commands
-	"loadtxfilter":          {},
-	"notifyblocks":          {},
-	"notifynewtransactions": {},
-	"notifyreceived":        {},
-	"notifyspent":           {},
-	"rescan":                {},
-	"rescanblocks":          {},
-	"session":               {},
+	"notifyblocks":              {},
+	"notifyevents":              {},
+	"notifynewtransactions":     {},
+	"session":                   {},
+	"stopnotifyblocks":          {},
+	"stopnotifyevents":          {},
+	"stopnotifynewtransactions": {},
 
 	// Websockets AND HTTP/S commands
-	"help": {},
+	"help":         {},
+	"rpc.discover": {},
 
 	// HTTP/S-only commands
-	"createrawtransaction":  {},
-	"decoderawtransaction":  {},
-	"decodescript":          {},
-	"getaddresstxids":       {},
-	"getadmininfo":          {},
-	"getbestblock":          {},
-	"getbestblockhash":      {},
-	"getblock":              {},
-	"getblockcount":         {},
-	"getblockhash":          {},
-	"getcurrentnet":         {},
-	"getdifficulty":         {},
-	"getheaders":            {},
-	"getinfo":               {},
-	"getnettotals":          {},
-	"getnetworkhashps":      {},
-	"getrawmempool":         {},
-	"getrawtransaction":     {},
-	"gettxout":              {},
-	"searchrawtransactions": {},
-	"sendrawtransaction":    {},
-	"submitblock":           {},
-	"validateaddress":       {},
-	"verifymessage":         {},
+	"combinepspt":            {},
+	"createconsolidatetx":    {},
+	"createdestroytx":        {},
+	"createissuetx":          {},
+	"createkeyrevoketx":      {},
+	"createprovisiontx":      {},
+	"createpspt":             {},
+	"createrawtransaction":   {},
+	"createsplittx":          {},
+	"createsweeptx":          {},
+	"debugscript":            {},
+	"decoderawtransaction":   {},
+	"decodescript":           {},
+	"finalizepspt":           {},
+	"fundrawtransaction":     {},
+	"getaddressbalance":      {},
+	"getaddressdeltas":       {},
+	"getaddressmempool":      {},
+	"getaddresstxids":        {},
+	"getaddressutxos":        {},
+	"getadmininfo":           {},
+	"getbestblock":           {},
+	"getbestblockhash":       {},
+	"getblock":               {},
+	"getblockchaininfo":      {},
+	"getblockcount":          {},
+	"getblockhash":           {},
+	"getblockhashbytime":     {},
+	"getblockheaders":        {},
+	"getcfilter":             {},
+	"getcfilterheader":       {},
+	"getcirculatingsupply":   {},
+	"getclusterinfo":         {},
+	"getconsistencystatus":   {},
+	"getcurrentnet":          {},
+	"getdbinfo":              {},
+	"getdiagnostics":         {},
+	"getdifficulty":          {},
+	"geteventlog":            {},
+	"getexploreraddress":     {},
+	"getexplorerblocks":      {},
+	"getexplorercharts":      {},
+	"getexplorertransaction": {},
+	"gethashcacheinfo":       {},
+	"gethealth":              {},
+	"getheaders":             {},
+	"getindexinfo":           {},
+	"getinfo":                {},
+	"getkeyidbalance":        {},
+	"getkeyidvelocity":       {},
+	"getnettotals":           {},
+	"getnetworkhashps":       {},
+	"getorphanblocks":        {},
+	"getrawmempool":          {},
+	"getrawtransaction":      {},
+	"getspvinfo":             {},
+	"getsupplyhistory":       {},
+	"gettxout":               {},
+	"gettxoutproof":          {},
+	"getutxocommitment":      {},
+	"getutxoproof":           {},
+	"getvalidatorheartbeats": {},
+	"getvalidatorschedule":   {},
+	"getvalidatorinfo":       {},
+	"searchrawtransactions":  {},
+	"signmessagewithprivkey": {},
+	"signrawtransaction":     {},
+	"updatepspt":             {},
+	"validateaddress":        {},
+	"verifyattestation":      {},
+	"verifymessage":          {},
+	"verifytxoutproof":       {},
+	"verifyutxoproof":        {},
 }
 
 // builderScript is a convenience function which is used for hard-coded scripts
@@ -289,6 +481,19 @@ func internalRPCError(errStr, context string) *btcjson.RPCError {
 	return btcjson.NewRPCError(btcjson.ErrRPCInternal.Code, errStr)
 }
 
+// canonicalAddress returns the string encoding of the passed address used by
+// the addresses extracted from scripts, so addresses given in any of their
+// encodings can be compared with them.
+func canonicalAddress(addr provautil.Address) string {
+	switch a := addr.(type) {
+	case *provautil.AddressProva:
+		return a.Base58().EncodeAddress()
+	case *provautil.AddressGeneralProva:
+		return a.Base58().EncodeAddress()
+	}
+	return addr.EncodeAddress()
+}
+
 // rpcDecodeHexError is a convenience function for returning a nicely formatted
 // RPC error which indicates the provided hex string failed to decode.
 func rpcDecodeHexError(gotHex string) *btcjson.RPCError {
@@ -486,258 +691,465 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
-// handleCreateRawTransaction handles createrawtransaction commands.
-func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.CreateRawTransactionCmd)
+// decodePSPT decodes the passed base64 encoded partially signed transaction.
+func decodePSPT(encoded string) (*pspt.Packet, error) {
+	p, err := pspt.NewFromB64(encoded)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "PSPT decode failed: " + err.Error(),
+		}
+	}
+	return p, nil
+}
 
-	// Validate the locktime, if given.
-	if c.LockTime != nil &&
-		(*c.LockTime < 0 || *c.LockTime > int64(wire.MaxTxInSequenceNum)) {
+// encodePSPT returns the base64 encoding of the passed partially signed
+// transaction.
+func encodePSPT(p *pspt.Packet) (string, error) {
+	encoded, err := p.B64Encode()
+	if err != nil {
+		context := "Failed to encode PSPT"
+		return "", internalRPCError(err.Error(), context)
+	}
+	return encoded, nil
+}
+
+// handleBackupChainState implements the backupchainstate command.
+func handleBackupChainState(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.BackupChainStateCmd)
+
+	// Relative destinations are relative to the data directory.
+	destPath := cleanAndExpandPath(c.Destination)
+	if !filepath.IsAbs(destPath) {
+		destPath = filepath.Join(cfg.DataDir, destPath)
+	}
+
+	hash, height, err := s.chain.BackupChainState(destPath)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to back up the chain state: " + err.Error(),
+		}
+	}
+	return &btcjson.BackupChainStateResult{
+		Destination: destPath,
+		Hash:        hash.String(),
+		Height:      height,
+	}, nil
+}
+
+// handleBroadcastTransaction implements the broadcasttransaction command.
+func handleBroadcastTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.BroadcastTransactionCmd)
+	targetConfs := uint32(1)
+	if c.TargetConfs != nil {
+		targetConfs = *c.TargetConfs
+	}
+	if targetConfs == 0 {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCInvalidParameter,
-			Message: "Locktime out of range",
+			Message: "Target confirmations must be at least 1",
 		}
 	}
 
-	// Add all transaction inputs to a new transaction after performing
-	// some validity checks.
-	mtx := wire.NewMsgTx(wire.TxVersion)
-	for _, input := range c.Inputs {
-		txHash, err := chainhash.NewHashFromStr(input.Txid)
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+
+	// Track the campaign before the transaction is announced so that no
+	// request from peers is missed.
+	tx := provautil.NewTx(&msgTx)
+	id, err := s.server.broadcasts.Track(tx, targetConfs)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	if err := submitTransaction(s, tx); err != nil {
+		s.server.broadcasts.Remove(id)
+		return nil, err
+	}
+	return &btcjson.BroadcastTransactionResult{
+		ID:   id,
+		TxID: tx.Hash().String(),
+	}, nil
+}
+
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.server.banManager.Clear(); err != nil {
+		context := "Failed to clear bans"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return nil, nil
+}
+
+// handleCombinePSPT handles combinepspt commands.
+func handleCombinePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CombinePSPTCmd)
+
+	packets := make([]*pspt.Packet, 0, len(c.PSPTs))
+	for _, encoded := range c.PSPTs {
+		p, err := decodePSPT(encoded)
 		if err != nil {
-			return nil, rpcDecodeHexError(input.Txid)
+			return nil, err
 		}
+		packets = append(packets, p)
+	}
 
-		prevOut := wire.NewOutPoint(txHash, input.Vout)
-		txIn := wire.NewTxIn(prevOut, []byte{})
-		if c.LockTime != nil && *c.LockTime != 0 {
-			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+	combined, err := pspt.Combine(packets...)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to combine PSPTs: " + err.Error(),
 		}
-		mtx.AddTxIn(txIn)
 	}
+	return encodePSPT(combined)
+}
 
-	// Add all transaction outputs to the transaction after performing
-	// some validity checks.
-	for encodedAddr, amount := range c.Amounts {
-		// Ensure amount is in the valid range for monetary amounts.
-		if amount <= 0 || amount > provautil.MaxAtoms {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCType,
-				Message: "Invalid amount",
+// parseAdminKeyOps converts the passed JSON key operations to the key
+// operations of the admin transaction builder.
+func parseAdminKeyOps(keyOps []btcjson.AdminKeyOp) ([]adminbuilder.KeyOp, error) {
+	ops := make([]adminbuilder.KeyOp, 0, len(keyOps))
+	for i, keyOp := range keyOps {
+		var op adminbuilder.KeyOp
+		keySetFound := false
+		for keySet := btcec.ProvisionKeySet; keySet <= btcec.ASPKeySet; keySet++ {
+			if strings.EqualFold(keyOp.KeySet, keySet.String()) {
+				op.KeySet = keySet
+				keySetFound = true
+				break
 			}
 		}
-
-		// Decode the provided address.
-		addr, err := provautil.DecodeAddress(encodedAddr,
-			activeNetParams.Params)
-		if err != nil {
+		if !keySetFound {
 			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCInvalidAddressOrKey,
-				Message: "Invalid address or key: " + err.Error(),
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid key set %q for key "+
+					"operation %d", keyOp.KeySet, i),
 			}
 		}
 
-		// Ensure the address is one of the supported types and that
-		// the network encoded with the address matches the network the
-		// server is currently on.
-		switch addr.(type) {
-		default:
+		pubKeyBytes, err := hex.DecodeString(keyOp.PubKey)
+		if err != nil {
+			return nil, rpcDecodeHexError(keyOp.PubKey)
+		}
+		op.PubKey, err = btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
 			return nil, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCInvalidAddressOrKey,
-				Message: "Invalid address or key",
+				Message: "Invalid public key: " + err.Error(),
 			}
 		}
-		if !addr.IsForNet(s.server.chainParams) {
-			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInvalidAddressOrKey,
-				Message: "Invalid address: " + encodedAddr +
-					" is for the wrong network",
-			}
+		if keyOp.KeyID != nil {
+			op.KeyID = btcec.KeyID(*keyOp.KeyID)
 		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
 
-		// Create a new script which pays to the provided address.
-		pkScript, err := txscript.PayToAddrScript(addr)
-		if err != nil {
-			context := "Failed to generate pay-to-address script"
-			return nil, internalRPCError(err.Error(), context)
+// provaOutputScript returns the script which pays to the passed encoded Prova
+// address.
+func provaOutputScript(s *rpcServer, encodedAddr string) ([]byte, error) {
+	addr, err := provautil.DecodeAddress(encodedAddr, s.server.chainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
 		}
-
-		// Convert the amount to atoms.
-		atoms, err := provautil.NewAmount(amount)
-		if err != nil {
-			context := "Failed to convert amount"
-			return nil, internalRPCError(err.Error(), context)
+	}
+	switch addr.(type) {
+	case *provautil.AddressProva, *provautil.AddressGeneralProva:
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + encodedAddr + " is not a Prova address",
 		}
+	}
+	if !addr.IsForNet(s.server.chainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + encodedAddr +
+				" is for the wrong network",
+		}
+	}
 
-		txOut := wire.NewTxOut(int64(atoms), pkScript)
-		mtx.AddTxOut(txOut)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		context := "Failed to generate pay-to-address script"
+		return nil, internalRPCError(err.Error(), context)
 	}
+	return pkScript, nil
+}
 
-	// Set the Locktime, if given.
-	if c.LockTime != nil {
-		mtx.LockTime = uint32(*c.LockTime)
+// checkAdminTx checks the passed admin transaction built from the current
+// thread tips against the admin state of the best chain.  The error of the
+// builder creating the transaction, if any, is passed through as a parameter
+// error.
+func checkAdminTx(s *rpcServer, mtx *wire.MsgTx, err error) (*wire.MsgTx, error) {
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to create admin transaction: " + err.Error(),
+		}
 	}
 
-	// Return the serialized and hex-encoded transaction.  Note that this
-	// is intentionally not directly returning because the first return
-	// value is a string and it would result in returning an empty string to
-	// the client instead of nothing (nil) in the case of an error.
-	mtxHex, err := messageToHex(mtx)
+	tx := provautil.NewTx(mtx)
+	err = blockchain.CheckTransactionSanity(tx)
+	nextHeight := s.chain.BestSnapshot().Height + 1
+	if err == nil {
+		err = blockchain.CheckTransactionFreezes(tx, nextHeight,
+			s.server.chainParams)
+	}
+	if err == nil {
+		keyView := blockchain.NewKeyViewpoint()
+		keyView.SetLastKeyID(s.chain.LastKeyID())
+		keyView.SetKeyIDs(s.chain.KeyIDs())
+		keyView.SetFrozenKeyIDs(s.chain.FrozenKeyIDs())
+		keyView.SetKeys(s.chain.AdminKeySets())
+		keyView.SetAdminThresholds(s.server.chainParams.AdminThresholds)
+		err = blockchain.CheckTransactionOutputs(tx, nextHeight, keyView,
+			s.server.chainParams)
+	}
 	if err != nil {
-		return nil, err
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid admin transaction: " + err.Error(),
+		}
 	}
-	return mtxHex, nil
+	return mtx, nil
 }
 
-type addressToKey struct {
-	key        *btcec.PrivateKey
-	compressed bool
+// adminTxToHex checks the passed admin transaction built from the current
+// thread tips against the admin state of the best chain and returns its hex
+// encoding.
+func adminTxToHex(s *rpcServer, mtx *wire.MsgTx, err error) (interface{}, error) {
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, err
+	}
+	return messageToHex(mtx)
 }
 
-// handleDebugLevel handles debuglevel commands.
-func handleDebugLevel(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.DebugLevelCmd)
-
-	// Special show command to list supported subsystems.
-	if c.LevelSpec == "show" {
-		return fmt.Sprintf("Supported subsystems %v",
-			supportedSubsystems()), nil
+// handleCompactDB implements the compactdb command.
+func handleCompactDB(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	maintainer, ok := s.server.db.(database.Maintainer)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The database does not support compaction",
+		}
 	}
-
-	err := parseAndSetDebugLevels(c.LevelSpec)
-	if err != nil {
+	if err := maintainer.Compact(); err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidParams.Code,
-			Message: err.Error(),
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Failed to compact the database: " + err.Error(),
 		}
 	}
+	return nil, nil
+}
 
-	return "Done.", nil
+// handleCreateDestroyTx handles createdestroytx commands.
+func handleCreateDestroyTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateDestroyTxCmd)
+
+	mtx, err := newDestroyTx(s, c.Inputs, c.Amount, c.ChangeAddress)
+	if err != nil {
+		return nil, err
+	}
+	return messageToHex(mtx)
 }
 
-// createVinList returns a slice of JSON objects for the inputs of the passed
-// transaction.
-func createVinList(mtx *wire.MsgTx) []btcjson.Vin {
-	// Coinbase transactions only have a single txin by definition.
-	vinList := make([]btcjson.Vin, len(mtx.TxIn))
-	if blockchain.IsCoinBaseTx(mtx) {
-		txIn := mtx.TxIn[0]
-		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
-		vinList[0].Sequence = txIn.Sequence
-		return vinList
+// newDestroyTx returns the checked, unsigned admin transaction which destroys
+// the passed amount in RMG out of the passed inputs, paying the change to the
+// passed change address.
+func newDestroyTx(s *rpcServer, txInputs []btcjson.TransactionInput,
+	rmgAmount float64, changeAddress *string) (*wire.MsgTx, error) {
+
+	amount, err := newTxAmount(s, rmgAmount)
+	if err != nil {
+		return nil, err
 	}
 
-	for i, txIn := range mtx.TxIn {
-		// The disassembled string will contain [error] inline
-		// if the script doesn't fully parse, so ignore the
-		// error here.
-		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+	// Look up the outputs spent by the transaction in order to determine
+	// the change left over once the amount is destroyed.
+	inputs := make([]*wire.OutPoint, 0, len(txInputs))
+	var totalIn provautil.Amount
+	for _, input := range txInputs {
+		txHash, err := chainhash.NewHashFromStr(input.Txid)
+		if err != nil {
+			return nil, rpcDecodeHexError(input.Txid)
+		}
+		prevOut := wire.NewOutPoint(txHash, input.Vout)
+		entry, err := s.chain.FetchUtxoEntry(txHash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("No unspent output %v",
+					prevOut),
+			}
+		}
+		totalIn, err = totalIn.Add(provautil.Amount(
+			entry.AmountByIndex(prevOut.Index)))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Total amount of the inputs overflows",
+			}
+		}
+		inputs = append(inputs, prevOut)
+	}
+	changeAmount, err := totalIn.Sub(amount)
+	if err != nil || changeAmount < 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Inputs of %v do not cover the "+
+				"destroyed amount of %v", totalIn, amount),
+		}
+	}
 
-		vinEntry := &vinList[i]
-		vinEntry.Txid = txIn.PreviousOutPoint.Hash.String()
-		vinEntry.Vout = txIn.PreviousOutPoint.Index
-		vinEntry.Sequence = txIn.Sequence
-		vinEntry.ScriptSig = &btcjson.ScriptSig{
-			Asm: disbuf,
-			Hex: hex.EncodeToString(txIn.SignatureScript),
+	var change []*wire.TxOut
+	if changeAmount > 0 {
+		if changeAddress == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("A change address is required "+
+					"for the change of %v", changeAmount),
+			}
+		}
+		pkScript, err := provaOutputScript(s, *changeAddress)
+		if err != nil {
+			return nil, err
 		}
+		change = append(change, wire.NewTxOut(int64(changeAmount),
+			pkScript))
 	}
 
-	return vinList
+	mtx, err := adminbuilder.NewDestroyTx(s.chain.ThreadTips(), inputs,
+		int64(amount), change)
+	return checkAdminTx(s, mtx, err)
 }
 
-// createVoutList returns a slice of JSON objects for the outputs of the passed
-// transaction.
-func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap map[string]struct{}) []btcjson.Vout {
-	voutList := make([]btcjson.Vout, 0, len(mtx.TxOut))
-	threadInt, _ := txscript.GetAdminDetailsMsgTx(mtx)
-	isAdmin := provautil.ThreadID(threadInt) == provautil.RootThread || provautil.ThreadID(threadInt) == provautil.ProvisionThread
-	for i, v := range mtx.TxOut {
-		// The disassembled string will contain [error] inline if the
-		// script doesn't fully parse, so ignore the error here.
-		disbuf, _ := txscript.DisasmString(v.PkScript)
-
-		// Ignore the error here since an error means the script
-		// couldn't parse and there is no additional information about
-		// it anyways.
-		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
-			v.PkScript, chainParams)
+// handleCreateIssueTx handles createissuetx commands.
+func handleCreateIssueTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateIssueTxCmd)
 
-		// Encode the addresses while checking if the address passes the
-		// filter when needed.
-		passesFilter := len(filterAddrMap) == 0
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.EncodeAddress()
-			encodedAddrs[j] = encodedAddr
+	mtx, _, err := newIssueTx(s, c.Amounts)
+	if err != nil {
+		return nil, err
+	}
+	return messageToHex(mtx)
+}
 
-			// No need to check the map again if the filter already
-			// passes.
-			if passesFilter {
-				continue
-			}
-			if _, exists := filterAddrMap[encodedAddr]; exists {
-				passesFilter = true
-			}
+// newTxAmount converts the passed amount in RMG of a transaction output to
+// atoms, ensuring it is positive, does not exceed the maximum transaction
+// amount and has no more decimal places than the network allows.
+func newTxAmount(s *rpcServer, rmgAmount float64) (provautil.Amount, error) {
+	amount, err := provautil.NewAmount(rmgAmount)
+	if err != nil || amount <= 0 || amount > provautil.MaxAtoms {
+		return 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCType,
+			Message: "Invalid amount",
 		}
-
-		if !passesFilter {
-			continue
+	}
+	decimals := s.server.chainParams.AmountDecimals
+	if !amount.FitsDecimals(decimals) {
+		return 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCType,
+			Message: fmt.Sprintf("Invalid amount %v: amounts have at "+
+				"most %d decimal places on this network",
+				amount.FormatRMG(0), decimals),
 		}
+	}
+	return amount, nil
+}
 
-		var vout btcjson.Vout
-		vout.N = uint32(i)
-		vout.Value = provautil.Amount(v.Value).ToRMG()
-		vout.ScriptPubKey.Addresses = encodedAddrs
-		vout.ScriptPubKey.Asm = disbuf
-		vout.ScriptPubKey.Hex = hex.EncodeToString(v.PkScript)
-		vout.ScriptPubKey.Type = scriptClass.String()
-		vout.ScriptPubKey.ReqSigs = int32(reqSigs)
-
-		if isAdmin && scriptClass == txscript.NullDataTy {
-			vout.ScriptPubKey.AdminOp = txscript.AdminOpString(v.PkScript)
+// newIssueTx returns the checked, unsigned admin transaction which issues the
+// passed amounts in RMG to their addresses, along with the total amount
+// issued.
+func newIssueTx(s *rpcServer, amounts map[string]float64) (*wire.MsgTx, provautil.Amount, error) {
+	// Add the outputs in a deterministic order.
+	addrs := make([]string, 0, len(amounts))
+	for encodedAddr := range amounts {
+		addrs = append(addrs, encodedAddr)
+	}
+	sort.Strings(addrs)
+
+	outputs := make([]*wire.TxOut, 0, len(addrs))
+	var total provautil.Amount
+	for _, encodedAddr := range addrs {
+		amount, err := newTxAmount(s, amounts[encodedAddr])
+		if err != nil {
+			return nil, 0, err
 		}
-
-		voutList = append(voutList, vout)
+		total, err = total.Add(amount)
+		if err != nil {
+			return nil, 0, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Total issued amount overflows",
+			}
+		}
+		pkScript, err := provaOutputScript(s, encodedAddr)
+		if err != nil {
+			return nil, 0, err
+		}
+		outputs = append(outputs, wire.NewTxOut(int64(amount), pkScript))
 	}
 
-	return voutList
+	mtx, err := adminbuilder.NewIssueTx(s.chain.ThreadTips(), outputs)
+	mtx, err = checkAdminTx(s, mtx, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mtx, total, nil
 }
 
-// createTxRawResult converts the passed transaction and associated parameters
-// to a raw transaction JSON object.
-func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
-	txHash string, blkHeader *wire.BlockHeader, blkHash string,
-	blkHeight uint32, chainHeight uint32) (*btcjson.TxRawResult, error) {
+// handleCreateKeyRevokeTx handles createkeyrevoketx commands.
+func handleCreateKeyRevokeTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateKeyRevokeTxCmd)
 
-	mtxHex, err := messageToHex(mtx)
+	ops, err := parseAdminKeyOps(c.KeyOps)
 	if err != nil {
 		return nil, err
 	}
+	mtx, err := adminbuilder.NewKeyRevokeTx(s.chain.ThreadTips(), ops)
+	return adminTxToHex(s, mtx, err)
+}
 
-	txReply := &btcjson.TxRawResult{
-		Hex:      mtxHex,
-		Txid:     txHash,
-		Vin:      createVinList(mtx),
-		Vout:     createVoutList(mtx, chainParams, nil),
-		Version:  mtx.Version,
-		LockTime: mtx.LockTime,
-	}
+// handleCreateProvisionTx handles createprovisiontx commands.
+func handleCreateProvisionTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateProvisionTxCmd)
 
-	if blkHeader != nil {
-		// This is not a typo, they are identical in bitcoind as well.
-		txReply.Time = blkHeader.Timestamp.Unix()
-		txReply.Blocktime = blkHeader.Timestamp.Unix()
-		txReply.BlockHash = blkHash
-		txReply.Confirmations = uint64(1 + chainHeight - blkHeight)
+	ops, err := parseAdminKeyOps(c.KeyOps)
+	if err != nil {
+		return nil, err
 	}
-
-	return txReply, nil
+	mtx, err := adminbuilder.NewProvisionTx(s.chain.ThreadTips(),
+		s.chain.LastKeyID(), ops)
+	return adminTxToHex(s, mtx, err)
 }
 
-// handleDecodeRawTransaction handles decoderawtransaction commands.
-func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.DecodeRawTransactionCmd)
+// handleCreatePSPT handles createpspt commands.
+func handleCreatePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreatePSPTCmd)
 
 	// Deserialize the transaction.
 	hexStr := c.HexTx
@@ -757,1162 +1169,2810 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 		}
 	}
 
-	// Create and return the result.
-	txReply := btcjson.TxRawDecodeResult{
-		Txid:     mtx.TxHash().String(),
-		Version:  mtx.Version,
-		Locktime: mtx.LockTime,
-		Vin:      createVinList(&mtx),
-		Vout:     createVoutList(&mtx, s.server.chainParams, nil),
+	p, err := newPSPT(s, &mtx)
+	if err != nil {
+		return nil, err
 	}
-	return txReply, nil
+	return encodePSPT(p)
 }
 
-// handleGenerate handles generate commands.
-func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if there are no addresses to pay the
-	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No payment addresses specified " +
-				"via --miningaddr",
+// newPSPT returns a partially signed transaction for the passed unsigned
+// transaction, looking up the outputs it spends since signers need their
+// amounts and scripts.
+func newPSPT(s *rpcServer, mtx *wire.MsgTx) (*pspt.Packet, error) {
+	prevOuts := make([]*wire.TxOut, 0, len(mtx.TxIn))
+	for _, txIn := range mtx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("No unspent output %v",
+					prevOut),
+			}
 		}
+		prevOuts = append(prevOuts, wire.NewTxOut(
+			entry.AmountByIndex(prevOut.Index),
+			entry.PkScriptByIndex(prevOut.Index)))
 	}
 
-	// Respond with an error if there's virtually 0 chance of mining a block
-	// with the CPU.
-	params := s.server.chainParams
-	if !s.server.chainParams.GenerateSupported {
+	p, err := pspt.New(mtx, prevOuts)
+	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to main a block with the CPU.",
-				params.Net),
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to create PSPT: " + err.Error(),
 		}
 	}
 
-	c := cmd.(*btcjson.GenerateCmd)
-
-	// Respond with an error if the client is requesting 0 blocks to be generated.
-	if c.NumBlocks == 0 {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
-			Message: "Please request a nonzero number of blocks to generate.",
+	// Admin thread inputs require the threshold of their thread.
+	for i, prevOut := range prevOuts {
+		pops, err := txscript.ParseScript(prevOut.PkScript)
+		if err != nil || txscript.TypeOfScript(pops) != txscript.ProvaAdminTy {
+			continue
+		}
+		threadID, err := txscript.ExtractThreadID(pops)
+		if err != nil {
+			continue
+		}
+		threshold := s.server.chainParams.AdminThreshold(
+			btcec.KeySetType(threadID))
+		if err := p.SetRequiredSigs(i, threshold); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Unable to create PSPT: " + err.Error(),
+			}
 		}
 	}
+	return p, nil
+}
 
-	// Attempt to establish validate keys from the environment var if there
-	// are none already registered.
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
-		s.server.cpuMiner.EstablishValidateKeys()
-	}
+// handleCreateRawTransaction handles createrawtransaction commands.
+func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateRawTransactionCmd)
 
-	// Check that there are validate keys set
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+	// Validate the locktime, if given.
+	if c.LockTime != nil &&
+		(*c.LockTime < 0 || *c.LockTime > int64(wire.MaxTxInSequenceNum)) {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No validate keys provided via " +
-				"--setvalidatekeys or PROVA_VALIDATE_KEYS " +
-				"environment variable",
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Locktime out of range",
 		}
 	}
 
-	// Create a reply
-	reply := make([]string, c.NumBlocks)
-
-	blockHashes, err := s.server.cpuMiner.GenerateNBlocks(c.NumBlocks)
-	if err != nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
-			Message: err.Error(),
+	// Add all transaction inputs to a new transaction after performing
+	// some validity checks.
+	mtx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range c.Inputs {
+		txHash, err := chainhash.NewHashFromStr(input.Txid)
+		if err != nil {
+			return nil, rpcDecodeHexError(input.Txid)
 		}
-	}
 
-	// Mine the correct number of blocks, assigning the hex representation of the
-	// hash of each one to its place in the reply.
-	for i, hash := range blockHashes {
-		reply[i] = hash.String()
+		prevOut := wire.NewOutPoint(txHash, input.Vout)
+		txIn := wire.NewTxIn(prevOut, []byte{})
+		if c.LockTime != nil && *c.LockTime != 0 {
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+		mtx.AddTxIn(txIn)
 	}
 
-	return reply, nil
-}
+	// Add all transaction outputs to the transaction after performing
+	// some validity checks.
+	for encodedAddr, amount := range c.Amounts {
+		// Convert the amount to atoms, ensuring it is in the valid range
+		// for monetary amounts.
+		atoms, err := newTxAmount(s, amount)
+		if err != nil {
+			return nil, err
+		}
 
-// handleGetAddedNodeInfo handles getaddednodeinfo commands.
-func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
+		// Decode the provided address.
+		addr, err := provautil.DecodeAddress(encodedAddr,
+			activeNetParams.Params)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
 
-	// Retrieve a list of persistent (added) peers from the bitcoin server
-	// and filter the list of peers per the specified address (if any).
-	peers := s.server.AddedNodeInfo()
-	if c.Node != nil {
-		node := *c.Node
-		found := false
-		for i, peer := range peers {
-			if peer.Addr() == node {
-				peers = peers[i : i+1]
-				found = true
+		// Ensure the address is one of the supported types and that
+		// the network encoded with the address matches the network the
+		// server is currently on.
+		switch addr.(type) {
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key",
 			}
 		}
-		if !found {
+		if !addr.IsForNet(s.server.chainParams) {
 			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCClientNodeNotAdded,
-				Message: "Node has not been added",
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address: " + encodedAddr +
+					" is for the wrong network",
 			}
 		}
-	}
 
-	// Without the dns flag, the result is just a slice of the addresses as
-	// strings.
-	if !c.DNS {
-		results := make([]string, 0, len(peers))
-		for _, peer := range peers {
-			results = append(results, peer.Addr())
+		// Create a new script which pays to the provided address.
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			context := "Failed to generate pay-to-address script"
+			return nil, internalRPCError(err.Error(), context)
 		}
-		return results, nil
+
+		txOut := wire.NewTxOut(int64(atoms), pkScript)
+		mtx.AddTxOut(txOut)
 	}
 
-	// With the dns flag, the result is an array of JSON objects which
-	// include the result of DNS lookups for each peer.
-	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(peers))
-	for _, peer := range peers {
-		// Set the "address" of the peer which could be an ip address
-		// or a domain name.
-		var result btcjson.GetAddedNodeInfoResult
-		result.AddedNode = peer.Addr()
-		result.Connected = btcjson.Bool(peer.Connected())
+	// Set the Locktime, if given.
+	if c.LockTime != nil {
+		mtx.LockTime = uint32(*c.LockTime)
+	}
 
-		// Split the address into host and port portions so we can do
-		// a DNS lookup against the host.  When no port is specified in
-		// the address, just use the address as the host.
-		host, _, err := net.SplitHostPort(peer.Addr())
-		if err != nil {
-			host = peer.Addr()
-		}
+	// Return the serialized and hex-encoded transaction.  Note that this
+	// is intentionally not directly returning because the first return
+	// value is a string and it would result in returning an empty string to
+	// the client instead of nothing (nil) in the case of an error.
+	mtxHex, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
+	}
+	return mtxHex, nil
+}
 
-		var ipList []string
-		switch {
-		case net.ParseIP(host) != nil, strings.HasSuffix(host, ".onion"):
-			ipList = make([]string, 1)
-			ipList[0] = host
-		default:
-			// Do a DNS lookup for the address.  If the lookup fails, just
-			// use the host.
-			ips, err := btcdLookup(host)
-			if err != nil {
-				ipList = make([]string, 1)
-				ipList[0] = host
-				break
-			}
-			ipList = make([]string, 0, len(ips))
-			for _, ip := range ips {
-				ipList = append(ipList, ip.String())
-			}
-		}
+type addressToKey struct {
+	key        *btcec.PrivateKey
+	compressed bool
+}
 
-		// Add the addresses and connection info to the result.
-		addrs := make([]btcjson.GetAddedNodeInfoResultAddr, 0, len(ipList))
-		for _, ip := range ipList {
-			var addr btcjson.GetAddedNodeInfoResultAddr
-			addr.Address = ip
-			addr.Connected = "false"
-			if ip == host && peer.Connected() {
-				addr.Connected = directionString(peer.Inbound())
-			}
-			addrs = append(addrs, addr)
-		}
-		result.Addresses = &addrs
-		results = append(results, &result)
+// handleDebugLevel handles debuglevel commands.
+func handleDebugLevel(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DebugLevelCmd)
+
+	// Special show command to list supported subsystems.
+	if c.LevelSpec == "show" {
+		return fmt.Sprintf("Supported subsystems %v",
+			supportedSubsystems()), nil
 	}
-	return results, nil
-}
 
-// handleGetAddressTxIds implements the getaddresstxids command.
-func handleGetAddressTxIds(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if the address index is not enabled.
-	addrIndex := s.server.addrIndex
-	if addrIndex == nil {
+	err := parseAndSetDebugLevels(c.LevelSpec)
+	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "Address index must be enabled (--addrindex)",
+			Code:    btcjson.ErrRPCInvalidParams.Code,
+			Message: err.Error(),
 		}
 	}
 
-	c := cmd.(*btcjson.GetAddressTxIdsCmd)
+	return "Done.", nil
+}
 
-	start := uint32(0)
-	if c.Request.Start > 0 {
-		start = c.Request.Start + 1
+// handleGetLogLevels implements the getloglevels command.
+func handleGetLogLevels(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	levels := make(map[string]string, len(subsystemLoggers))
+	for subsystemID, logger := range subsystemLoggers {
+		levels[subsystemID] = logger.Level().String()
 	}
+	return &btcjson.GetLogLevelsResult{
+		Format: logFormat,
+		Levels: levels,
+	}, nil
+}
 
-	end := uint32(1<<32 - 1)
-	if c.Request.End > 0 {
-		end = c.Request.End + 1
+// createVinList returns a slice of JSON objects for the inputs of the passed
+// transaction.
+func createVinList(mtx *wire.MsgTx) []btcjson.Vin {
+	// Coinbase transactions only have a single txin by definition.
+	vinList := make([]btcjson.Vin, len(mtx.TxIn))
+	if blockchain.IsCoinBaseTx(mtx) {
+		txIn := mtx.TxIn[0]
+		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vinList[0].Sequence = txIn.Sequence
+		return vinList
 	}
 
-	if start > end {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "End height must not be less than the start height.",
+	for i, txIn := range mtx.TxIn {
+		// The disassembled string will contain [error] inline
+		// if the script doesn't fully parse, so ignore the
+		// error here.
+		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		vinEntry := &vinList[i]
+		vinEntry.Txid = txIn.PreviousOutPoint.Hash.String()
+		vinEntry.Vout = txIn.PreviousOutPoint.Index
+		vinEntry.Sequence = txIn.Sequence
+		vinEntry.ScriptSig = &btcjson.ScriptSig{
+			Asm: disbuf,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
 		}
 	}
 
-	// Attempt to decode the supplied addresses.
-	addressTxns := make([]retrievedTx, 0)
-	for _, address := range c.Request.Addresses {
-		addr, err := provautil.DecodeAddress(address, s.server.chainParams)
+	return vinList
+}
+
+// addProvaScriptDetails decomposes the passed public key script into the keys
+// and admin operation it is made up of and adds them to the passed result.
+// Admin operations are only recognized when they are valid for one of the
+// passed admin threads.
+func addProvaScriptDetails(result *btcjson.ScriptPubKeyResult, pkScript []byte,
+	scriptClass txscript.ScriptClass, threads []provautil.ThreadID) {
+
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return
+	}
+
+	switch scriptClass {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy, txscript.GeneralProvaTy:
+		if scriptClass == txscript.ProvaTimeLockTy {
+			result.LockTime, _ = txscript.ExtractTimeLock(pkScript)
+		}
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
 		if err != nil {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCInvalidAddressOrKey,
-				Message: "Invalid address or key: " + err.Error(),
+			return
+		}
+		result.KeyIDs = make([]uint32, 0, len(keyIDs))
+		for _, keyID := range keyIDs {
+			result.KeyIDs = append(result.KeyIDs, uint32(keyID))
+		}
+		pushes, _ := txscript.PushedData(pkScript)
+		for _, push := range pushes {
+			if len(push) == ripemd160.Size {
+				result.PubKeyHashes = append(result.PubKeyHashes,
+					hex.EncodeToString(push))
 			}
 		}
 
-		err = s.server.db.View(func(dbTx database.Tx) error {
-			regions, err := addrIndex.BoundedTxRegionsForAddress(
-				dbTx, addr, start, end)
-			if err != nil {
-				return err
-			}
+	case txscript.ProvaAdminTy:
+		threadID, err := txscript.ExtractThreadID(pops)
+		if err == nil {
+			result.Thread = threadID.String()
+		}
 
-			// Load the raw transaction bytes from the database.
-			serializedTxns, err := dbTx.FetchBlockRegions(regions)
-			if err != nil {
-				return err
+	case txscript.NullDataTy:
+		for _, threadID := range threads {
+			if !txscript.IsValidAdminOp(pops, threadID) {
+				continue
 			}
-
-			// Add the transaction and the hash of the block it is
-			// contained in to the list.
-			for i, serializedTx := range serializedTxns {
-				addressTxns = append(addressTxns, retrievedTx{
-					txBytes: serializedTx,
-					blkHash: regions[i].Hash,
-				})
+			isAddOp, keySetType, pubKey, keyID :=
+				txscript.ExtractAdminOpData(pops)
+			result.AdminOp = txscript.AdminOpString(pkScript)
+			result.Thread = threadID.String()
+			result.Operation = "REVOKE_KEY"
+			if isAddOp {
+				result.Operation = "ADD_KEY"
 			}
-
-			return nil
-		})
-		if err != nil {
-			context := "Failed to load address index entries"
-			return nil, internalRPCError(err.Error(), context)
+			result.KeySet = keySetType.String()
+			result.PubKey = hex.EncodeToString(pubKey.SerializeCompressed())
+			result.KeyID = uint32(keyID)
+			break
 		}
+	}
+}
+
+// createVoutList returns a slice of JSON objects for the outputs of the passed
+// transaction.
+func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap map[string]struct{}) []btcjson.Vout {
+	voutList := make([]btcjson.Vout, 0, len(mtx.TxOut))
 
+	// Admin operations are only decoded for the outputs of admin
+	// transactions, and the outputs of issue thread transactions either
+	// issue or destroy tokens depending on whether they spend any.
+	var adminThreads []provautil.ThreadID
+	threadInt, _ := txscript.GetAdminDetailsMsgTx(mtx)
+	if threadInt >= 0 {
+		adminThreads = []provautil.ThreadID{provautil.ThreadID(threadInt)}
 	}
+	isIssue := threadInt >= 0 &&
+		provautil.ThreadID(threadInt) == provautil.IssueThread
+	isDestruction := isIssue && len(mtx.TxIn) > 1
+	for i, v := range mtx.TxOut {
+		// The disassembled string will contain [error] inline if the
+		// script doesn't fully parse, so ignore the error here.
+		disbuf, _ := txscript.DisasmString(v.PkScript)
 
-	// Create a reply
-	reply := make([]string, len(addressTxns))
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+			v.PkScript, chainParams)
 
-	for i := range addressTxns {
-		// The deserialized transaction is needed, so deserialize the
-		// retrieved transaction if it's in serialized form (which will
-		// be the case when it was lookup up from the database).
-		// Otherwise, use the existing deserialized transaction.
-		rtx := &addressTxns[i]
-		var mtx *wire.MsgTx
-		if rtx.tx == nil {
-			// Deserialize the transaction.
-			mtx = new(wire.MsgTx)
-			err := mtx.Deserialize(bytes.NewReader(rtx.txBytes))
-			if err != nil {
-				context := "Failed to deserialize transaction"
-				return nil, internalRPCError(err.Error(),
-					context)
+		// Encode the addresses while checking if the address passes the
+		// filter when needed.
+		passesFilter := len(filterAddrMap) == 0
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddr := addr.EncodeAddress()
+			encodedAddrs[j] = encodedAddr
+
+			// No need to check the map again if the filter already
+			// passes.
+			if passesFilter {
+				continue
+			}
+			if _, exists := filterAddrMap[encodedAddr]; exists {
+				passesFilter = true
 			}
-		} else {
-			mtx = rtx.tx.MsgTx()
 		}
-		reply[i] = mtx.TxHash().String()
+
+		if !passesFilter {
+			continue
+		}
+
+		var vout btcjson.Vout
+		vout.N = uint32(i)
+		vout.Value = provautil.Amount(v.Value).ToRMG()
+		vout.ScriptPubKey.Addresses = encodedAddrs
+		vout.ScriptPubKey.Asm = disbuf
+		vout.ScriptPubKey.Hex = hex.EncodeToString(v.PkScript)
+		vout.ScriptPubKey.Type = scriptClass.String()
+		vout.ScriptPubKey.ReqSigs = int32(reqSigs)
+		addProvaScriptDetails(&vout.ScriptPubKey, v.PkScript, scriptClass,
+			adminThreads)
+		if isIssue && i > 0 {
+			switch {
+			case isDestruction && scriptClass == txscript.NullDataTy:
+				vout.ScriptPubKey.Operation = "DESTROY"
+			case !isDestruction && scriptClass != txscript.NullDataTy:
+				vout.ScriptPubKey.Operation = "ISSUE"
+			}
+		}
+
+		voutList = append(voutList, vout)
 	}
 
-	return reply, nil
+	return voutList
 }
 
-// handleGetAdminInfo implements the getadmininfo command.
-func handleGetAdminInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.chain.BestSnapshot()
-	adminKeySets := s.chain.AdminKeySets()
-	aspKeyIdMap := s.chain.KeyIDs()
-	rootTip := s.chain.ThreadTips()[provautil.RootThread]
-	provisionTip := s.chain.ThreadTips()[provautil.ProvisionThread]
-	issueTip := s.chain.ThreadTips()[provautil.IssueThread]
-	threadTipObj := []btcjson.ThreadTipResult{
-		{
-			ID:       uint32(provautil.RootThread),
-			Name:     "root",
-			OutPoint: rootTip.String(),
-		},
-		{
-			ID:       uint32(provautil.ProvisionThread),
-			Name:     "provision",
-			OutPoint: provisionTip.String(),
-		},
-		{
-			ID:       uint32(provautil.IssueThread),
-			Name:     "issue",
-			OutPoint: issueTip.String(),
-		},
+// createTxRawResult converts the passed transaction and associated parameters
+// to a raw transaction JSON object.
+func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
+	txHash string, blkHeader *wire.BlockHeader, blkHash string,
+	blkHeight uint32, chainHeight uint32) (*btcjson.TxRawResult, error) {
+
+	mtxHex, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
 	}
-	aspObj := make([]btcjson.ASPKeyIdResult, len(aspKeyIdMap))
-	i := 0
-	for k, v := range aspKeyIdMap {
-		aspObj[i] = btcjson.ASPKeyIdResult{
-			KeyID:  uint32(k),
-			PubKey: hex.EncodeToString(v.SerializeCompressed()),
-		}
-		i++
-	}
-	result := &btcjson.GetAdminInfoResult{
-		Hash:          best.Hash.String(),
-		Height:        best.Height,
-		ThreadTips:    threadTipObj,
-		TotalSupply:   s.chain.TotalSupply(),
-		LastKeyID:     uint32(s.chain.LastKeyID()),
-		RootKeys:      adminKeySets[btcec.RootKeySet].ToStringArray(),
-		ProvisionKeys: adminKeySets[btcec.ProvisionKeySet].ToStringArray(),
-		IssueKeys:     adminKeySets[btcec.IssueKeySet].ToStringArray(),
-		ValidateKeys:  adminKeySets[btcec.ValidateKeySet].ToStringArray(),
-		ASPKeys:       aspObj,
+
+	txReply := &btcjson.TxRawResult{
+		Hex:      mtxHex,
+		Txid:     txHash,
+		Vin:      createVinList(mtx),
+		Vout:     createVoutList(mtx, chainParams, nil),
+		Version:  mtx.Version,
+		LockTime: mtx.LockTime,
 	}
-	return result, nil
-}
 
-// handleGetBestBlock implements the getbestblock command.
-func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// All other "get block" commands give either the height, the
-	// hash, or both but require the block SHA.  This gets both for
-	// the best block.
-	best := s.chain.BestSnapshot()
-	result := &btcjson.GetBestBlockResult{
-		Hash:   best.Hash.String(),
-		Height: best.Height,
+	if blkHeader != nil {
+		// This is not a typo, they are identical in bitcoind as well.
+		txReply.Time = blkHeader.Timestamp.Unix()
+		txReply.Blocktime = blkHeader.Timestamp.Unix()
+		txReply.BlockHash = blkHash
+		txReply.Confirmations = uint64(1 + chainHeight - blkHeight)
 	}
-	return result, nil
+
+	return txReply, nil
 }
 
-// handleGetBestBlockHash implements the getbestblockhash command.
-func handleGetBestBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.chain.BestSnapshot()
-	return best.Hash.String(), nil
+// condStateNames maps the conditional execution states recorded by the script
+// engine to the names reported by the debugscript command.
+var condStateNames = map[int]string{
+	txscript.OpCondFalse: "false",
+	txscript.OpCondTrue:  "true",
+	txscript.OpCondSkip:  "skip",
 }
 
-// getDifficultyRatio returns the proof-of-work difficulty as a multiple of the
-// minimum difficulty using the passed bits field from the header of a block.
-func getDifficultyRatio(bits uint32) float64 {
-	// The minimum difficulty is the max possible proof-of-work limit bits
-	// converted back to a number.  Note this is not the same as the proof of
-	// work limit directly because the block difficulty is encoded in a block
-	// with the compact form which loses precision.
-	max := blockchain.CompactToBig(activeNetParams.PowLimitBits)
-	target := blockchain.CompactToBig(bits)
+// hexStack returns the passed stack with each item hex-encoded.
+func hexStack(stack [][]byte) []string {
+	items := make([]string, 0, len(stack))
+	for _, item := range stack {
+		items = append(items, hex.EncodeToString(item))
+	}
+	return items
+}
 
-	difficulty := new(big.Rat).SetFrac(max, target)
-	outString := difficulty.FloatString(8)
-	diff, err := strconv.ParseFloat(outString, 64)
-	if err != nil {
-		rpcsLog.Errorf("Cannot get difficulty: %v", err)
-		return 0
+// scriptFlags returns the standard script flags along with the flags of the
+// script rule changes which are active for the next block.
+func (s *rpcServer) scriptFlags() txscript.ScriptFlags {
+	flags := txscript.StandardVerifyFlags
+	nextHeight := s.chain.BestSnapshot().Height + 1
+	if nextHeight >= s.server.chainParams.GeneralProvaActivationHeight {
+		flags |= txscript.ScriptVerifyGeneralProva
 	}
-	return diff
+	return flags
 }
 
-// handleGetBlock implements the getblock command.
-func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetBlockCmd)
+// handleDebugScript handles debugscript commands.
+func handleDebugScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DebugScriptCmd)
 
-	// Load the raw block bytes from the database.
-	hash, err := chainhash.NewHashFromStr(c.Hash)
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
 	if err != nil {
-		return nil, rpcDecodeHexError(c.Hash)
+		return nil, rpcDecodeHexError(hexStr)
 	}
-	var blkBytes []byte
-	err = s.server.db.View(func(dbTx database.Tx) error {
-		var err error
-		blkBytes, err = dbTx.FetchBlock(hash)
-		return err
-	})
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
 	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCBlockNotFound,
-			Message: "Block not found",
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
 		}
 	}
-
-	// When the verbose flag isn't set, simply return the serialized block
-	// as a hex-encoded string.
-	if c.Verbose != nil && !*c.Verbose {
-		return hex.EncodeToString(blkBytes), nil
+	if int(c.InputIndex) >= len(mtx.TxIn) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Input index %d is out of range for "+
+				"a transaction with %d inputs", c.InputIndex,
+				len(mtx.TxIn)),
+		}
 	}
+	txIn := mtx.TxIn[c.InputIndex]
 
-	// The verbose flag is set, so generate the JSON object and return it.
-
-	// Deserialize the block.
-	blk, err := provautil.NewBlockFromBytes(blkBytes)
-	if err != nil {
-		context := "Failed to deserialize block"
-		return nil, internalRPCError(err.Error(), context)
+	hexStr = c.PkScript
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
 	}
-
-	// Get the block height from chain.
-	blockHeight, err := s.chain.BlockHeightByHash(hash)
+	pkScript, err := hex.DecodeString(hexStr)
 	if err != nil {
-		context := "Failed to obtain block height"
-		return nil, internalRPCError(err.Error(), context)
+		return nil, rpcDecodeHexError(hexStr)
 	}
-	best := s.chain.BestSnapshot()
 
-	// Get next block hash unless there are none.
-	var nextHashString string
-	if blockHeight < best.Height {
-		nextHash, err := s.chain.BlockHashByHeight(blockHeight + 1)
+	// Signatures commit to the amount of the spent output, so look it up
+	// when it was not provided.
+	var amount provautil.Amount
+	if c.Amount != nil {
+		amount, err = provautil.NewAmount(*c.Amount)
 		if err != nil {
-			context := "No next block"
-			return nil, internalRPCError(err.Error(), context)
-		}
-		nextHashString = nextHash.String()
-	}
-
-	blockHeader := &blk.MsgBlock().Header
-	blockReply := btcjson.GetBlockVerboseResult{
-		Hash:             c.Hash,
-		Version:          blockHeader.Version,
-		MerkleRoot:       blockHeader.MerkleRoot.String(),
-		PreviousHash:     blockHeader.PrevBlock.String(),
-		Nonce:            blockHeader.Nonce,
-		Time:             blockHeader.Timestamp.Unix(),
-		Confirmations:    uint64(1 + best.Height - blockHeight),
-		Height:           int64(blockHeader.Height),
-		Size:             int32(blockHeader.Size),
-		Bits:             strconv.FormatInt(int64(blockHeader.Bits), 16),
-		Difficulty:       getDifficultyRatio(blockHeader.Bits),
-		NextHash:         nextHashString,
-		ValidatingPubKey: blockHeader.ValidatingPubKey.String(),
-		Signature:        blockHeader.Signature.String(),
-	}
-
-	if c.VerboseTx == nil || !*c.VerboseTx {
-		transactions := blk.Transactions()
-		txNames := make([]string, len(transactions))
-		for i, tx := range transactions {
-			txNames[i] = tx.Hash().String()
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid amount: " + err.Error(),
+			}
 		}
-
-		blockReply.Tx = txNames
 	} else {
-		txns := blk.Transactions()
-		rawTxns := make([]btcjson.TxRawResult, len(txns))
-		for i, tx := range txns {
-			rawTxn, err := createTxRawResult(s.server.chainParams,
-				tx.MsgTx(), tx.Hash().String(), blockHeader,
-				hash.String(), blockHeight, best.Height)
-			if err != nil {
-				return nil, err
+		prevOut := &txIn.PreviousOutPoint
+		entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("No unspent output %v -- "+
+					"the amount must be provided", prevOut),
 			}
-			rawTxns[i] = *rawTxn
 		}
-		blockReply.RawTx = rawTxns
+		amount = provautil.Amount(entry.AmountByIndex(prevOut.Index))
 	}
 
-	return blockReply, nil
-}
+	// Resolve the keyIDs and admin threads referenced by the script
+	// against the current chain state the same way block and transaction
+	// validation does.
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(s.chain.KeyIDs())
+	keyView.SetKeys(s.chain.AdminKeySets())
+	keyView.SetAdminThresholds(s.server.chainParams.AdminThresholds)
+	scriptFlags := s.scriptFlags()
+	pkScript, err = blockchain.ResolvePkScript(pkScript, keyView,
+		scriptFlags)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to resolve script: " + err.Error(),
+		}
+	}
 
-// handleGetBlockCount implements the getblockcount command.
-func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.chain.BestSnapshot()
-	return int64(best.Height), nil
-}
+	sigScriptDisasm, _ := txscript.DisasmString(txIn.SignatureScript)
+	pkScriptDisasm, _ := txscript.DisasmString(pkScript)
+	result := &btcjson.DebugScriptResult{
+		SigScript: sigScriptDisasm,
+		PkScript:  pkScriptDisasm,
+		Amount:    amount.ToRMG(),
+		Steps:     []btcjson.DebugScriptStep{},
+	}
 
-// handleGetBlockHash implements the getblockhash command.
-func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetBlockHashCmd)
-	hash, err := s.chain.BlockHashByHeight(uint32(c.Index))
+	vm, err := txscript.NewEngine(pkScript, &mtx, int(c.InputIndex),
+		scriptFlags, nil, nil, int64(amount))
 	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	vm.EnableTrace()
+	err = vm.Execute()
+	if txscript.IsErrorCode(err, txscript.ErrTraceTooLarge) {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCOutOfRange,
-			Message: "Block number out of range",
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Script trace too large: " + err.Error(),
 		}
 	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Valid = err == nil
 
-	return hash.String(), nil
+	for _, step := range vm.Trace() {
+		script := "pkscript"
+		if step.ScriptIdx == 0 {
+			script = "sigscript"
+		}
+		condStack := make([]string, 0, len(step.CondStack))
+		for _, cond := range step.CondStack {
+			condStack = append(condStack, condStateNames[cond])
+		}
+		resultStep := btcjson.DebugScriptStep{
+			Script:    script,
+			Offset:    step.ScriptOff,
+			Opcode:    step.Opcode,
+			Executed:  step.Executed,
+			Stack:     hexStack(step.Stack),
+			AltStack:  hexStack(step.AltStack),
+			CondStack: condStack,
+		}
+		if step.Err != nil {
+			resultStep.Error = step.Err.Error()
+		}
+		result.Steps = append(result.Steps, resultStep)
+	}
+
+	return result, nil
 }
 
-// handleGetBlockHeader implements the getblockheader command.
-func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetBlockHeaderCmd)
+// handleDecodeRawTransaction handles decoderawtransaction commands.
+func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodeRawTransactionCmd)
 
-	// Fetch the header from chain.
-	hash, err := chainhash.NewHashFromStr(c.Hash)
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
 	if err != nil {
-		return nil, rpcDecodeHexError(c.Hash)
+		return nil, rpcDecodeHexError(hexStr)
 	}
-	blockHeader, err := s.chain.FetchHeader(hash)
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
 	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCBlockNotFound,
-			Message: "Block not found",
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
 		}
 	}
 
-	// When the verbose flag isn't set, simply return the serialized block
-	// header as a hex-encoded string.
-	if c.Verbose != nil && !*c.Verbose {
-		var headerBuf bytes.Buffer
-		err := blockHeader.Serialize(&headerBuf)
-		if err != nil {
-			context := "Failed to serialize block header"
-			return nil, internalRPCError(err.Error(), context)
-		}
-		return hex.EncodeToString(headerBuf.Bytes()), nil
+	// Create and return the result.
+	txReply := btcjson.TxRawDecodeResult{
+		Txid:     mtx.TxHash().String(),
+		Version:  mtx.Version,
+		Locktime: mtx.LockTime,
+		Vin:      createVinList(&mtx),
+		Vout:     createVoutList(&mtx, s.server.chainParams, nil),
 	}
+	return txReply, nil
+}
 
-	// The verbose flag is set, so generate the JSON object and return it.
+// handleDecodeScript handles decodescript commands.
+func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodeScriptCmd)
 
-	// Get the block height from chain.
-	blockHeight, err := s.chain.BlockHeightByHash(hash)
+	// Convert the hex script to bytes.
+	hexStr := c.HexScript
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	script, err := hex.DecodeString(hexStr)
 	if err != nil {
-		context := "Failed to obtain block height"
-		return nil, internalRPCError(err.Error(), context)
+		return nil, rpcDecodeHexError(hexStr)
 	}
-	best := s.chain.BestSnapshot()
 
-	// Get next block hash unless there are none.
-	var nextHashString string
-	if blockHeight < best.Height {
-		nextHash, err := s.chain.BlockHashByHeight(blockHeight + 1)
-		if err != nil {
-			context := "No next block"
-			return nil, internalRPCError(err.Error(), context)
-		}
-		nextHashString = nextHash.String()
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(script)
+
+	// Get information about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(script,
+		s.server.chainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
 	}
 
-	blockHeaderReply := btcjson.GetBlockHeaderVerboseResult{
-		Hash:             c.Hash,
-		Confirmations:    uint64(1 + best.Height - blockHeight),
-		Height:           int32(blockHeader.Height),
-		Version:          blockHeader.Version,
-		MerkleRoot:       blockHeader.MerkleRoot.String(),
-		NextHash:         nextHashString,
-		PreviousHash:     blockHeader.PrevBlock.String(),
-		Nonce:            uint64(blockHeader.Nonce),
-		Time:             blockHeader.Timestamp.Unix(),
-		Bits:             strconv.FormatInt(int64(blockHeader.Bits), 16),
-		Difficulty:       getDifficultyRatio(blockHeader.Bits),
-		Signature:        blockHeader.Signature.String(),
-		ValidatingPubKey: blockHeader.ValidatingPubKey.String(),
+	// The transaction the script belongs to is not known, so decode admin
+	// operations of any thread which has them.
+	var details btcjson.ScriptPubKeyResult
+	addProvaScriptDetails(&details, script, scriptClass,
+		[]provautil.ThreadID{provautil.RootThread,
+			provautil.ProvisionThread})
+
+	// Generate and return the reply.
+	reply := btcjson.DecodeScriptResult{
+		Asm:          disbuf,
+		ReqSigs:      int32(reqSigs),
+		Type:         scriptClass.String(),
+		AdminOp:      details.AdminOp,
+		LockTime:     details.LockTime,
+		PubKeyHashes: details.PubKeyHashes,
+		KeyIDs:       details.KeyIDs,
+		Thread:       details.Thread,
+		Operation:    details.Operation,
+		KeySet:       details.KeySet,
+		PubKey:       details.PubKey,
+		KeyID:        details.KeyID,
+		Addresses:    addresses,
 	}
-	return blockHeaderReply, nil
+	return reply, nil
 }
 
-// encodeTemplateID encodes the passed details into an ID that can be used to
-// uniquely identify a block template.
-func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
-	return fmt.Sprintf("%s-%d", prevHash.String(), lastGenerated.Unix())
+// handleDropIndex implements the dropindex command.
+func handleDropIndex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DropIndexCmd)
+	if err := s.server.DropIndex(c.Index); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+	return nil, nil
 }
 
-// decodeTemplateID decodes an ID that is used to uniquely identify a block
-// template.  This is mainly used as a mechanism to track when to update clients
-// that are using long polling for block templates.  The ID consists of the
-// previous block hash for the associated template and the time the associated
-// template was generated.
-func decodeTemplateID(templateID string) (*chainhash.Hash, int64, error) {
-	fields := strings.Split(templateID, "-")
-	if len(fields) != 2 {
-		return nil, 0, errors.New("invalid longpollid format")
+// handleEnableIndex implements the enableindex command.
+func handleEnableIndex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EnableIndexCmd)
+	if err := s.server.EnableIndex(c.Index); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
 	}
+	return nil, nil
+}
 
-	prevHash, err := chainhash.NewHashFromStr(fields[0])
+// handleFinalizePSPT handles finalizepspt commands.
+func handleFinalizePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.FinalizePSPTCmd)
+
+	p, err := decodePSPT(c.PSPT)
 	if err != nil {
-		return nil, 0, errors.New("invalid longpollid format")
+		return nil, err
 	}
-	lastGenerated, err := strconv.ParseInt(fields[1], 10, 64)
+	complete, err := p.Finalize()
 	if err != nil {
-		return nil, 0, errors.New("invalid longpollid format")
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to finalize PSPT: " + err.Error(),
+		}
 	}
 
-	return prevHash, lastGenerated, nil
+	// Return the signed transaction instead of the packet once it is
+	// complete unless the caller asked for the packet.
+	result := &btcjson.FinalizePSPTResult{Complete: complete}
+	if complete && (c.Extract == nil || *c.Extract) {
+		tx, err := p.Extract()
+		if err != nil {
+			context := "Failed to extract transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Hex, err = messageToHex(tx)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result.PSPT, err = encodePSPT(p)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// notifyLongPollers notifies any channels that have been registered to be
-// notified when block templates are stale.
-//
-// This function MUST be called with the state locked.
-func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGenerated time.Time) {
-	// Notify anything that is waiting for a block template update from a
-	// hash which is not the hash of the tip of the best chain since their
-	// work is now invalid.
-	for hash, channels := range state.notifyMap {
-		if !hash.IsEqual(latestHash) {
-			for _, c := range channels {
-				close(c)
-			}
-			delete(state.notifyMap, hash)
+// generateBlocks generates the requested number of blocks for the generate
+// commands, paying them to the passed address and signing them with the
+// validate key of the CPU miner matching the passed public key.  A nil address
+// or validate key is chosen at random for every block.  It returns the hashes
+// of the generated blocks.
+func generateBlocks(s *rpcServer, numBlocks uint32, payToAddr provautil.Address,
+	validatePubKey *btcec.PublicKey) (interface{}, error) {
+
+	// Respond with an error if there's virtually 0 chance of mining a block
+	// with the CPU.
+	params := s.server.chainParams
+	if !s.server.chainParams.GenerateSupported {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `generate` on "+
+				"the current network, %s, as it's unlikely to "+
+				"be possible to main a block with the CPU.",
+				params.Net),
 		}
 	}
 
-	// Return now if the provided last generated timestamp has not been
-	// initialized.
-	if lastGenerated.IsZero() {
-		return
+	// Respond with an error if the client is requesting 0 blocks to be generated.
+	if numBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
 	}
 
-	// Return now if there is nothing registered for updates to the current
-	// best block hash.
-	channels, ok := state.notifyMap[*latestHash]
-	if !ok {
-		return
+	// Attempt to establish validate keys from the environment var if there
+	// are none already registered.
+	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+		s.server.cpuMiner.EstablishValidateKeys()
 	}
 
-	// Notify anything that is waiting for a block template update from a
-	// block template generated before the most recently generated block
-	// template.
-	lastGeneratedUnix := lastGenerated.Unix()
-	for lastGen, c := range channels {
-		if lastGen < lastGeneratedUnix {
-			close(c)
-			delete(channels, lastGen)
+	// Check that there are validate keys set
+	validateKeys := s.server.cpuMiner.ValidateKeys()
+	if len(validateKeys) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No validate keys provided via " +
+				"--setvalidatekeys or PROVA_VALIDATE_KEYS " +
+				"environment variable",
 		}
 	}
 
-	// Remove the entry altogether if there are no more registered
-	// channels.
-	if len(channels) == 0 {
-		delete(state.notifyMap, *latestHash)
+	// Find the requested validate key among those of the CPU miner.
+	var validateKey btcec.Signer
+	if validatePubKey != nil {
+		for _, key := range validateKeys {
+			if key.PubKey().IsEqual(validatePubKey) {
+				validateKey = key
+				break
+			}
+		}
+		if validateKey == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "The validate key is not among those " +
+					"set via setvalidatekeys or the " +
+					"PROVA_VALIDATE_KEYS environment variable",
+			}
+		}
 	}
-}
 
-// NotifyBlockConnected uses the newly-connected block to notify any long poll
-// clients with a new block template when their existing block template is
-// stale due to the newly connected block.
-func (state *gbtWorkState) NotifyBlockConnected(blockHash *chainhash.Hash) {
-	go func() {
-		state.Lock()
-		defer state.Unlock()
+	// Create a reply
+	reply := make([]string, numBlocks)
 
-		state.notifyLongPollers(blockHash, state.lastTxUpdate)
-	}()
+	blockHashes, err := s.server.cpuMiner.GenerateBlocks(numBlocks,
+		payToAddr, validateKey)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	// Mine the correct number of blocks, assigning the hex representation of the
+	// hash of each one to its place in the reply.
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+
+	return reply, nil
 }
 
-// NotifyMempoolTx uses the new last updated time for the transaction memory
-// pool to notify any long poll clients with a new block template when their
-// existing block template is stale due to enough time passing and the contents
-// of the memory pool changing.
-func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
-	go func() {
-		state.Lock()
-		defer state.Unlock()
+// handleFundRawTransaction handles fundrawtransaction commands.  Unspent
+// outputs of the passed watch-only addresses are added to the inputs of the
+// transaction as selected by the chosen strategy, along with a change output,
+// so that the transaction can be signed by an external signing service.
+func handleFundRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.FundRawTransactionCmd)
+	opts := &c.Options
 
-		// No need to notify anything if no block templates have been generated
-		// yet.
-		if state.prevHash == nil || state.lastGenerated.IsZero() {
-			return
+	if len(opts.Addresses) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Addresses must be specified",
 		}
+	}
 
-		if time.Now().After(state.lastGenerated.Add(time.Second *
-			gbtRegenerateSeconds)) {
-
-			state.notifyLongPollers(state.prevHash, lastUpdated)
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
 		}
-	}()
-}
+	}
 
-// templateUpdateChan returns a channel that will be closed once the block
-// template associated with the passed previous hash and last generated time
-// is stale.  The function will return existing channels for duplicate
-// parameters which allows multiple clients to wait for the same block template
-// without requiring a different channel for each client.
-//
-// This function MUST be called with the state locked.
-func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, lastGenerated int64) chan struct{} {
-	// Either get the current list of channels waiting for updates about
-	// changes to block template for the previous hash or create a new one.
-	channels, ok := state.notifyMap[*prevHash]
-	if !ok {
-		m := make(map[int64]chan struct{})
-		state.notifyMap[*prevHash] = m
-		channels = m
+	strategy := wallet.StrategyBranchAndBound
+	if opts.Strategy != nil {
+		strategy = *opts.Strategy
+	}
+	switch strategy {
+	case wallet.StrategyBranchAndBound, wallet.StrategyLargestFirst,
+		wallet.StrategyPrivacy:
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown coin selection strategy: " + strategy,
+		}
+	}
+	feePerKB, err := rpcFeeRate(s, opts.FeeRate)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get the current channel associated with the time the block template
-	// was last generated or create a new one.
-	c, ok := channels[lastGenerated]
-	if !ok {
-		c = make(chan struct{})
-		channels[lastGenerated] = c
+	// Look up the outputs already spent by the transaction.
+	req := &wallet.CoinSelectionRequest{
+		Strategy: strategy,
+		Outputs:  mtx.TxOut,
+		FeePerKB: feePerKB,
+	}
+	spent := make(map[wire.OutPoint]struct{}, len(mtx.TxIn))
+	for _, txIn := range mtx.TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("No unspent output %v",
+					prevOut),
+			}
+		}
+		req.Inputs = append(req.Inputs, wallet.Coin{
+			OutPoint: prevOut,
+			Amount:   provautil.Amount(entry.AmountByIndex(prevOut.Index)),
+			PkScript: entry.PkScriptByIndex(prevOut.Index),
+		})
+		spent[prevOut] = struct{}{}
 	}
 
-	return c
-}
+	minConf := int32(1)
+	if opts.MinConf != nil {
+		minConf = *opts.MinConf
+	}
+	coins, prevOuts, err := watchOnlyCoins(s, opts.Addresses, minConf,
+		spent)
+	if err != nil {
+		return nil, err
+	}
+	req.Coins = coins
 
-// updateBlockTemplate creates or updates a block template for the work state.
-// A new block template will be generated when the current best block has
-// changed or the transactions in the memory pool have been updated and it has
-// been long enough since the last template was generated.  Otherwise, the
-// timestamp for the existing block template is updated (and possibly the
-// difficulty on testnet per the consesus rules).  Finally, if the
-// useCoinbaseValue flag is false and the existing block template does not
-// already contain a valid payment address, the block template will be updated
-// with a randomly selected payment address from the list of configured
-// addresses.
-//
-// This function MUST be called with the state locked.
-func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bool) error {
-	lastTxUpdate := s.server.txMemPool.LastUpdated()
-	if lastTxUpdate.IsZero() {
-		lastTxUpdate = time.Now()
+	// The change output is sized after the scripts of the watch-only
+	// addresses unless a change address is passed.
+	var changeScript []byte
+	if opts.ChangeAddress != nil {
+		changeScript, err = provaOutputScript(s, *opts.ChangeAddress)
+		if err != nil {
+			return nil, err
+		}
+		req.ChangeScript = changeScript
+	} else {
+		req.ChangeScript, err = provaOutputScript(s, opts.Addresses[0])
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Generate a new block template when the current best block has
-	// changed or the transactions in the memory pool have been updated and
-	// it has been at least gbtRegenerateSecond since the last template was
-	// generated.
-	var msgBlock *wire.MsgBlock
-	var targetDifficulty string
-	latestHash := s.server.blockManager.chain.BestSnapshot().Hash
-	template := state.template
-	if template == nil || state.prevHash == nil ||
-		!state.prevHash.IsEqual(latestHash) ||
-		(state.lastTxUpdate != lastTxUpdate &&
-			time.Now().After(state.lastGenerated.Add(time.Second*
-				gbtRegenerateSeconds))) {
+	selection, err := wallet.SelectCoins(req)
+	if err == wallet.ErrInsufficientFunds {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletInsufficientFunds,
+			Message: "Insufficient funds",
+		}
+	}
+	if err != nil {
+		context := "Failed to select coins"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-		// Reset the previous best hash the block template was generated
-		// against so any errors below cause the next invocation to try
-		// again.
-		state.prevHash = nil
+	result := &btcjson.FundRawTransactionResult{
+		Fee:       selection.Fee.ToRMG(),
+		ChangePos: -1,
+		PrevOuts:  make([]btcjson.AddressUtxoResult, 0, len(selection.Coins)),
+	}
+	for _, coin := range selection.Coins {
+		mtx.AddTxIn(wire.NewTxIn(&coin.OutPoint, nil))
+		result.PrevOuts = append(result.PrevOuts, prevOuts[coin.OutPoint])
+	}
 
-		// Choose a payment address at random if the caller requests a
-		// full coinbase as opposed to only the pertinent details needed
-		// to create their own coinbase.
-		var payAddr provautil.Address
-		if !useCoinbaseValue {
-			payAddr = cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+	// The change output is appended, except with the privacy strategy which
+	// inserts it at a random position so that it can not be told apart from
+	// the payments by its position.
+	if selection.Change > 0 {
+		if changeScript == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("A change address is required "+
+					"for the change of %v", selection.Change),
+			}
 		}
-
-		// Create a new block template that has a coinbase which anyone
-		// can redeem.  This is only acceptable because the returned
-		// block template doesn't include the coinbase, so the caller
-		// will ultimately create their own coinbase which pays to the
-		// appropriate address(es).
-		blkTemplate, err := s.generator.NewBlockTemplate(payAddr, nil)
-		if err != nil {
-			return internalRPCError("Failed to create new block "+
-				"template: "+err.Error(), "")
+		pos := len(mtx.TxOut)
+		if strategy == wallet.StrategyPrivacy {
+			pos = rand.Intn(len(mtx.TxOut) + 1)
 		}
-		template = blkTemplate
-		msgBlock = template.Block
-		targetDifficulty = fmt.Sprintf("%064x",
-			blockchain.CompactToBig(msgBlock.Header.Bits))
-
-		// Get the minimum allowed timestamp for the block based on the
-		// median timestamp of the last several blocks per the chain
-		// consensus rules.
-		best := s.server.blockManager.chain.BestSnapshot()
-		minTimestamp := mining.MinimumMedianTime(best)
+		mtx.TxOut = append(mtx.TxOut, nil)
+		copy(mtx.TxOut[pos+1:], mtx.TxOut[pos:])
+		mtx.TxOut[pos] = wire.NewTxOut(int64(selection.Change), changeScript)
+		result.ChangePos = pos
+	}
 
-		// Update work state to ensure another block template isn't
-		// generated until needed.
-		state.template = template
-		state.lastGenerated = time.Now()
-		state.lastTxUpdate = lastTxUpdate
-		state.prevHash = latestHash
-		state.minTimestamp = minTimestamp
+	result.Hex, err = messageToHex(&mtx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-		rpcsLog.Debugf("Generated block template (timestamp %v, "+
-			"target %s, merkle root %s)",
-			msgBlock.Header.Timestamp, targetDifficulty,
-			msgBlock.Header.MerkleRoot)
+// rpcFeeRate converts the passed fee rate in RMG/kB to atoms per kilobyte,
+// defaulting to the minimum relay fee of the memory pool when it is nil.
+func rpcFeeRate(s *rpcServer, feeRate *float64) (provautil.Amount, error) {
+	if feeRate == nil {
+		return s.server.txMemPool.Policy().MinRelayTxFee, nil
+	}
+	feePerKB, err := provautil.NewAmount(*feeRate)
+	if err != nil || feePerKB < 0 {
+		return 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid fee rate",
+		}
+	}
+	return feePerKB, nil
+}
 
-		// Notify any clients that are long polling about the new
-		// template.
-		state.notifyLongPollers(latestHash, lastTxUpdate)
-	} else {
-		// At this point, there is a saved block template and another
-		// request for a template was made, but either the available
-		// transactions haven't change or it hasn't been long enough to
-		// trigger a new block template to be generated.  So, update the
-		// existing block template.
+// watchOnlyCoins returns the unspent outputs of the passed watch-only Prova
+// addresses which may be spent by a new transaction along with their
+// descriptions by outpoint.  Outputs spent in the memory pool or in the passed
+// set, outputs with fewer than the passed number of confirmations and
+// immature coinbases are left out.  The address index must be enabled.
+func watchOnlyCoins(s *rpcServer, addrs []string, minConf int32,
+	spent map[wire.OutPoint]struct{}) ([]wallet.Coin, map[wire.OutPoint]btcjson.AddressUtxoResult, error) {
 
-		// When the caller requires a full coinbase as opposed to only
-		// the pertinent details needed to create their own coinbase,
-		// add a payment address to the output of the coinbase of the
-		// template if it doesn't already have one.  Since this requires
-		// mining addresses to be specified via the config, an error is
-		// returned if none have been specified.
-		if !useCoinbaseValue && !template.ValidPayAddress {
-			// Choose a payment address at random.
-			payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.server.addrIndex
+	if addrIndex == nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+	if minConf < 1 {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Minimum confirmations must be at least 1",
+		}
+	}
 
-			// Update the block coinbase output of the template to
-			// pay to the randomly selected payment address.
-			pkScript, err := txscript.PayToAddrScript(payToAddr)
-			if err != nil {
-				context := "Failed to create pay-to-addr script"
-				return internalRPCError(err.Error(), context)
+	best := s.chain.BestSnapshot()
+	maturity := uint32(s.server.chainParams.CoinbaseMaturity)
+	var coins []wallet.Coin
+	prevOuts := make(map[wire.OutPoint]btcjson.AddressUtxoResult)
+	for _, encodedAddr := range addrs {
+		if _, err := provaOutputScript(s, encodedAddr); err != nil {
+			return nil, nil, err
+		}
+		addr, _ := provautil.DecodeAddress(encodedAddr,
+			s.server.chainParams)
+		utxos, err := addrIndex.UtxosForAddress(addr)
+		if err != nil {
+			context := "Failed to load address utxos"
+			return nil, nil, internalRPCError(err.Error(), context)
+		}
+		for _, utxo := range utxos {
+			if _, ok := spent[utxo.OutPoint]; ok {
+				continue
+			}
+			if _, ok := prevOuts[utxo.OutPoint]; ok {
+				continue
+			}
+			if s.server.txMemPool.CheckSpend(utxo.OutPoint) != nil {
+				continue
+			}
+			confs := best.Height - utxo.Height + 1
+			if confs < uint32(minConf) {
+				continue
+			}
+			if confs < maturity {
+				entry, err := s.chain.FetchUtxoEntry(
+					&utxo.OutPoint.Hash)
+				if err != nil {
+					context := "Failed to fetch utxo"
+					return nil, nil, internalRPCError(
+						err.Error(), context)
+				}
+				if entry == nil || entry.IsCoinBase() {
+					continue
+				}
+			}
+			coins = append(coins, wallet.Coin{
+				OutPoint: utxo.OutPoint,
+				Amount:   provautil.Amount(utxo.Amount),
+				PkScript: utxo.PkScript,
+			})
+			prevOuts[utxo.OutPoint] = btcjson.AddressUtxoResult{
+				Address:     encodedAddr,
+				Txid:        utxo.OutPoint.Hash.String(),
+				OutputIndex: utxo.OutPoint.Index,
+				Script:      hex.EncodeToString(utxo.PkScript),
+				Atoms:       utxo.Amount,
+				Height:      utxo.Height,
 			}
-			template.Block.Transactions[0].TxOut[0].PkScript = pkScript
-			template.ValidPayAddress = true
-
-			// Update the merkle root.
-			block := provautil.NewBlock(template.Block)
-			merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
-			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
 		}
-
-		// Set locals for convenience.
-		msgBlock = template.Block
-		targetDifficulty = fmt.Sprintf("%064x",
-			blockchain.CompactToBig(msgBlock.Header.Bits))
-
-		// Update the time of the block template to the current time
-		// while accounting for the median time of the past several
-		// blocks per the chain consensus rules.
-		s.generator.UpdateBlockTime(msgBlock, nil)
-		msgBlock.Header.Nonce = 0
-
-		rpcsLog.Debugf("Updated block template (timestamp %v, "+
-			"target %s)", msgBlock.Header.Timestamp,
-			targetDifficulty)
 	}
+	return coins, prevOuts, nil
+}
 
-	return nil
+// decodeGenerateAddress decodes the address generated blocks are paid to for
+// the generate commands.
+func decodeGenerateAddress(s *rpcServer, encodedAddr string) (provautil.Address, error) {
+	addr, err := provautil.DecodeAddress(encodedAddr, s.server.chainParams)
+	if err != nil || !addr.IsForNet(s.server.chainParams) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key",
+		}
+	}
+	return addr, nil
 }
 
-// blockTemplateResult returns the current block template associated with the
-// state as a btcjson.GetBlockTemplateResult that is ready to be encoded to JSON
-// and returned to the caller.
-//
-// This function MUST be called with the state locked.
-func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
-	// Ensure the timestamps are still in valid range for the template.
-	// This should really only ever happen if the local clock is changed
-	// after the template is generated, but it's important to avoid serving
-	// invalid block templates.
-	template := state.template
-	msgBlock := template.Block
-	header := &msgBlock.Header
-	adjustedTime := state.timeSource.AdjustedTime()
-	maxTime := adjustedTime.Add(time.Second * blockchain.MaxTimeOffsetSeconds)
-	if header.Timestamp.After(maxTime) {
+// handleGenerate handles generate commands.
+func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if there are no addresses to pay the
+	// created blocks to.
+	if len(s.server.cpuMiner.MiningAddrs()) == 0 {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCOutOfRange,
-			Message: fmt.Sprintf("The template time is after the "+
-				"maximum allowed time for a block - template "+
-				"time %v, maximum time %v", adjustedTime,
-				maxTime),
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified " +
+				"via --miningaddr",
 		}
 	}
 
-	// Convert each transaction in the block template to a template result
-	// transaction.  The result does not include the coinbase, so notice
-	// the adjustments to the various lengths and indices.
-	numTx := len(msgBlock.Transactions)
-	transactions := make([]btcjson.GetBlockTemplateResultTx, 0, numTx-1)
-	txIndex := make(map[chainhash.Hash]int64, numTx)
-	for i, tx := range msgBlock.Transactions {
-		txHash := tx.TxHash()
-		txIndex[txHash] = int64(i)
+	c := cmd.(*btcjson.GenerateCmd)
+	return generateBlocks(s, c.NumBlocks, nil, nil)
+}
 
-		// Skip the coinbase transaction.
-		if i == 0 {
-			continue
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+	payToAddr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	return generateBlocks(s, c.NumBlocks, payToAddr, nil)
+}
+
+// handleGenerateWithValidateKey handles generatewithvalidatekey commands.
+func handleGenerateWithValidateKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateWithValidateKeyCmd)
+
+	pubKeyBytes, err := hex.DecodeString(c.ValidateKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.ValidateKey)
+	}
+	validatePubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid validate key: " + err.Error(),
 		}
+	}
 
-		// Create an array of 1-based indices to transactions that come
-		// before this one in the transactions list which this one
-		// depends on.  This is necessary since the created block must
-		// ensure proper ordering of the dependencies.  A map is used
-		// before creating the final array to prevent duplicate entries
-		// when multiple inputs reference the same transaction.
-		dependsMap := make(map[int64]struct{})
-		for _, txIn := range tx.TxIn {
-			if idx, ok := txIndex[txIn.PreviousOutPoint.Hash]; ok {
-				dependsMap[idx] = struct{}{}
-			}
+	var payToAddr provautil.Address
+	if c.Address != nil {
+		payToAddr, err = decodeGenerateAddress(s, *c.Address)
+		if err != nil {
+			return nil, err
 		}
-		depends := make([]int64, 0, len(dependsMap))
-		for idx := range dependsMap {
-			depends = append(depends, idx)
+	} else if len(s.server.cpuMiner.MiningAddrs()) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified " +
+				"via --miningaddr",
 		}
+	}
+	return generateBlocks(s, c.NumBlocks, payToAddr, validatePubKey)
+}
 
-		// Serialize the transaction for later conversion to hex.
-		txBuf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
-		if err := tx.Serialize(txBuf); err != nil {
-			context := "Failed to serialize transaction"
-			return nil, internalRPCError(err.Error(), context)
-		}
+// handleGetAddedNodeInfo handles getaddednodeinfo commands.
+func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
 
-		resultTx := btcjson.GetBlockTemplateResultTx{
-			Data:    hex.EncodeToString(txBuf.Bytes()),
-			Hash:    txHash.String(),
-			Depends: depends,
-			Fee:     template.Fees[i],
-			SigOps:  template.SigOpCounts[i],
+	// Retrieve a list of persistent (added) peers from the bitcoin server
+	// and filter the list of peers per the specified address (if any).
+	peers := s.server.AddedNodeInfo()
+	if c.Node != nil {
+		node := *c.Node
+		found := false
+		for i, peer := range peers {
+			if peer.Addr() == node {
+				peers = peers[i : i+1]
+				found = true
+			}
+		}
+		if !found {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeNotAdded,
+				Message: "Node has not been added",
+			}
 		}
-		transactions = append(transactions, resultTx)
 	}
 
-	// Generate the block template reply.  Note that following mutations are
-	// implied by the included or omission of fields:
-	//  Including MinTime -> time/decrement
-	//  Omitting CoinbaseTxn -> coinbase, generation
-	targetDifficulty := fmt.Sprintf("%064x", blockchain.CompactToBig(header.Bits))
-	templateID := encodeTemplateID(state.prevHash, state.lastGenerated)
-	reply := btcjson.GetBlockTemplateResult{
-		Bits:         strconv.FormatInt(int64(header.Bits), 16),
-		CurTime:      header.Timestamp.Unix(),
-		Height:       int64(template.Height),
-		PreviousHash: header.PrevBlock.String(),
-		SigOpLimit:   blockchain.MaxSigOpsPerBlock,
-		SizeLimit:    wire.MaxBlockPayload,
-		Transactions: transactions,
-		Version:      header.Version,
-		LongPollID:   templateID,
-		SubmitOld:    submitOld,
-		Target:       targetDifficulty,
-		MinTime:      state.minTimestamp.Unix(),
-		MaxTime:      maxTime.Unix(),
-		Mutable:      gbtMutableFields,
-		NonceRange:   gbtNonceRange,
-		Capabilities: gbtCapabilities,
+	// Without the dns flag, the result is just a slice of the addresses as
+	// strings.
+	if !c.DNS {
+		results := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			results = append(results, peer.Addr())
+		}
+		return results, nil
 	}
-	if useCoinbaseValue {
-		reply.CoinbaseAux = gbtCoinbaseAux
-		reply.CoinbaseValue = &msgBlock.Transactions[0].TxOut[0].Value
-	} else {
-		// Ensure the template has a valid payment address associated
-		// with it when a full coinbase is requested.
-		if !template.ValidPayAddress {
-			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInternal.Code,
-				Message: "A coinbase transaction has been " +
-					"requested, but the server has not " +
-					"been configured with any payment " +
-					"addresses via --miningaddr",
-			}
+
+	// With the dns flag, the result is an array of JSON objects which
+	// include the result of DNS lookups for each peer.
+	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(peers))
+	for _, peer := range peers {
+		// Set the "address" of the peer which could be an ip address
+		// or a domain name.
+		var result btcjson.GetAddedNodeInfoResult
+		result.AddedNode = peer.Addr()
+		result.Connected = btcjson.Bool(peer.Connected())
+
+		// Split the address into host and port portions so we can do
+		// a DNS lookup against the host.  When no port is specified in
+		// the address, just use the address as the host.
+		host, _, err := net.SplitHostPort(peer.Addr())
+		if err != nil {
+			host = peer.Addr()
 		}
 
-		// Serialize the transaction for conversion to hex.
-		tx := msgBlock.Transactions[0]
-		txBuf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
-		if err := tx.Serialize(txBuf); err != nil {
-			context := "Failed to serialize transaction"
-			return nil, internalRPCError(err.Error(), context)
+		var ipList []string
+		switch {
+		case net.ParseIP(host) != nil, strings.HasSuffix(host, ".onion"),
+			strings.HasSuffix(host, ".b32.i2p"):
+			ipList = make([]string, 1)
+			ipList[0] = host
+		default:
+			// Do a DNS lookup for the address.  If the lookup fails, just
+			// use the host.
+			ips, err := btcdLookup(host)
+			if err != nil {
+				ipList = make([]string, 1)
+				ipList[0] = host
+				break
+			}
+			ipList = make([]string, 0, len(ips))
+			for _, ip := range ips {
+				ipList = append(ipList, ip.String())
+			}
 		}
 
-		resultTx := btcjson.GetBlockTemplateResultTx{
-			Data:    hex.EncodeToString(txBuf.Bytes()),
-			Hash:    tx.TxHash().String(),
-			Depends: []int64{},
-			Fee:     template.Fees[0],
-			SigOps:  template.SigOpCounts[0],
+		// Add the addresses and connection info to the result.
+		addrs := make([]btcjson.GetAddedNodeInfoResultAddr, 0, len(ipList))
+		for _, ip := range ipList {
+			var addr btcjson.GetAddedNodeInfoResultAddr
+			addr.Address = ip
+			addr.Connected = "false"
+			if ip == host && peer.Connected() {
+				addr.Connected = directionString(peer.Inbound())
+			}
+			addrs = append(addrs, addr)
 		}
+		result.Addresses = &addrs
+		results = append(results, &result)
+	}
+	return results, nil
+}
 
-		reply.CoinbaseTxn = &resultTx
+// addrIndexAddresses returns the address index along with the decoded
+// addresses of the passed request.  An error is returned when the address index
+// is not enabled or an address is invalid.
+func addrIndexAddresses(s *rpcServer, request *btcjson.AddressTxRequest) (*indexers.AddrIndex, []provautil.Address, error) {
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.server.addrIndex
+	if addrIndex == nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+	if request == nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Addresses must be specified",
+		}
 	}
 
-	return &reply, nil
+	addrs := make([]provautil.Address, 0, len(request.Addresses))
+	for _, address := range request.Addresses {
+		addr, err := provautil.DecodeAddress(address, s.server.chainParams)
+		if err != nil {
+			return nil, nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrIndex, addrs, nil
 }
 
-// handleGetBlockTemplateLongPoll is a helper for handleGetBlockTemplateRequest
-// which deals with handling long polling for block templates.  When a caller
-// sends a request with a long poll ID that was previously returned, a response
-// is not sent until the caller should stop working on the previous block
-// template in favor of the new one.  In particular, this is the case when the
-// old block template is no longer valid due to a solution already being found
-// and added to the block chain, or new transactions have shown up and some time
-// has passed without finding a solution.
-//
-// See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
-	state := s.gbtWorkState
-	state.Lock()
-	// The state unlock is intentionally not deferred here since it needs to
-	// be manually unlocked before waiting for a notification about block
-	// template changes.
-
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
-		state.Unlock()
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	addrIndex, addrs, err := addrIndexAddresses(s, c.Request)
+	if err != nil {
 		return nil, err
 	}
 
-	// Just return the current block template if the long poll ID provided by
-	// the caller is invalid.
-	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
-	if err != nil {
-		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+	var result btcjson.GetAddressBalanceResult
+	for _, addr := range addrs {
+		balance, received, err := addrIndex.BalanceForAddress(addr)
 		if err != nil {
-			state.Unlock()
-			return nil, err
+			context := "Failed to load address balance"
+			return nil, internalRPCError(err.Error(), context)
 		}
-
-		state.Unlock()
-		return result, nil
+		result.Balance += balance
+		result.Received += received
 	}
+	return result, nil
+}
 
-	// Return the block template now if the specific block template
-	// identified by the long poll ID no longer matches the current block
-	// template as this means the provided template is stale.
-	prevTemplateHash := &state.template.Block.Header.PrevBlock
-	if !prevHash.IsEqual(prevTemplateHash) ||
-		lastGenerated != state.lastGenerated.Unix() {
+// handleGetAddressDeltas implements the getaddressdeltas command.
+func handleGetAddressDeltas(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressDeltasCmd)
+	addrIndex, addrs, err := addrIndexAddresses(s, c.Request)
+	if err != nil {
+		return nil, err
+	}
+	if c.Request.End != 0 && c.Request.Start > c.Request.End {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
 
-		// Include whether or not it is valid to submit work against the
-		// old block template depending on whether or not a solution has
-		// already been found and added to the block chain.
-		submitOld := prevHash.IsEqual(prevTemplateHash)
-		result, err := state.blockTemplateResult(useCoinbaseValue,
-			&submitOld)
+	results := make([]btcjson.AddressDeltaResult, 0)
+	for i, addr := range addrs {
+		deltas, err := addrIndex.DeltasForAddress(addr,
+			c.Request.Start, c.Request.End)
 		if err != nil {
-			state.Unlock()
-			return nil, err
+			context := "Failed to load address deltas"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		for _, delta := range deltas {
+			results = append(results, btcjson.AddressDeltaResult{
+				Address:    c.Request.Addresses[i],
+				Txid:       delta.TxHash.String(),
+				Index:      delta.Index,
+				BlockIndex: delta.TxIndex,
+				Height:     delta.Height,
+				Atoms:      delta.Amount,
+			})
 		}
-
-		state.Unlock()
-		return result, nil
 	}
+	return results, nil
+}
 
-	// Register the previous hash and last generated time for notifications
-	// Get a channel that will be notified when the template associated with
-	// the provided ID is stale and a new block template should be returned to
-	// the caller.
-	longPollChan := state.templateUpdateChan(prevHash, lastGenerated)
-	state.Unlock()
-
-	select {
-	// When the client closes before it's time to send a reply, just return
-	// now so the goroutine doesn't hang around.
-	case <-closeChan:
-		return nil, ErrClientQuit
-
-	// Wait until signal received to send the reply.
-	case <-longPollChan:
-		// Fallthrough
+// handleGetAddressMempool implements the getaddressmempool command.
+func handleGetAddressMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressMempoolCmd)
+	addrIndex, addrs, err := addrIndexAddresses(s, c.Request)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get the lastest block template
-	state.Lock()
-	defer state.Unlock()
-
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
-		return nil, err
+	results := make([]btcjson.AddressMempoolResult, 0)
+	for i, addr := range addrs {
+		for _, delta := range addrIndex.UnconfirmedDeltasForAddress(addr) {
+			result := btcjson.AddressMempoolResult{
+				Address: c.Request.Addresses[i],
+				Txid:    delta.TxHash.String(),
+				Index:   delta.Index,
+				Atoms:   delta.Amount,
+			}
+			if delta.PrevOut != nil {
+				result.PrevTxid = delta.PrevOut.Hash.String()
+				result.PrevOut = delta.PrevOut.Index
+			}
+			results = append(results, result)
+		}
 	}
+	return results, nil
+}
 
-	// Include whether or not it is valid to submit work against the old
-	// block template depending on whether or not a solution has already
-	// been found and added to the block chain.
-	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
-	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	addrIndex, addrs, err := addrIndexAddresses(s, c.Request)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	results := make([]btcjson.AddressUtxoResult, 0)
+	for i, addr := range addrs {
+		utxos, err := addrIndex.UtxosForAddress(addr)
+		if err != nil {
+			context := "Failed to load address utxos"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		for _, utxo := range utxos {
+			results = append(results, btcjson.AddressUtxoResult{
+				Address:     c.Request.Addresses[i],
+				Txid:        utxo.OutPoint.Hash.String(),
+				OutputIndex: utxo.OutPoint.Index,
+				Script:      hex.EncodeToString(utxo.PkScript),
+				Atoms:       utxo.Amount,
+				Height:      utxo.Height,
+			})
+		}
+	}
+	return results, nil
 }
 
-// handleGetBlockTemplateRequest is a helper for handleGetBlockTemplate which
-// deals with generating and returning block templates to the caller.  It
-// handles both long poll requests as specified by BIP 0022 as well as regular
-// requests.  In addition, it detects the capabilities reported by the caller
-// in regards to whether or not it supports creating its own coinbase (the
-// coinbasetxn and coinbasevalue capabilities) and modifies the returned block
-// template accordingly.
-func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, closeChan <-chan struct{}) (interface{}, error) {
-	// Extract the relevant passed capabilities and restrict the result to
-	// either a coinbase value or a coinbase transaction object depending on
-	// the request.  Default to only providing a coinbase value.
-	useCoinbaseValue := true
-	if request != nil {
-		var hasCoinbaseValue, hasCoinbaseTxn bool
-		for _, capability := range request.Capabilities {
-			switch capability {
-			case "coinbasetxn":
-				hasCoinbaseTxn = true
-			case "coinbasevalue":
-				hasCoinbaseValue = true
+// handleGetAddressTxIds implements the getaddresstxids command.
+func handleGetAddressTxIds(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.server.addrIndex
+	if addrIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetAddressTxIdsCmd)
+
+	start := uint32(0)
+	if c.Request.Start > 0 {
+		start = c.Request.Start + 1
+	}
+
+	end := uint32(1<<32 - 1)
+	if c.Request.End > 0 {
+		end = c.Request.End + 1
+	}
+
+	if start > end {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	// Attempt to decode the supplied addresses.
+	addressTxns := make([]retrievedTx, 0)
+	for _, address := range c.Request.Addresses {
+		addr, err := provautil.DecodeAddress(address, s.server.chainParams)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
 			}
 		}
 
-		if hasCoinbaseTxn && !hasCoinbaseValue {
-			useCoinbaseValue = false
+		err = s.server.db.View(func(dbTx database.Tx) error {
+			regions, err := addrIndex.BoundedTxRegionsForAddress(
+				dbTx, addr, start, end)
+			if err != nil {
+				return err
+			}
+
+			// Load the raw transaction bytes from the database.
+			serializedTxns, err := dbTx.FetchBlockRegions(regions)
+			if err != nil {
+				return err
+			}
+
+			// Add the transaction and the hash of the block it is
+			// contained in to the list.
+			for i, serializedTx := range serializedTxns {
+				addressTxns = append(addressTxns, retrievedTx{
+					txBytes: serializedTx,
+					blkHash: regions[i].Hash,
+				})
+			}
+
+			return nil
+		})
+		if err != nil {
+			context := "Failed to load address index entries"
+			return nil, internalRPCError(err.Error(), context)
 		}
+
 	}
 
-	// When a coinbase transaction has been requested, respond with an error
-	// if there are no addresses to pay the created block template to.
-	if !useCoinbaseValue && len(cfg.miningAddrs) == 0 {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "A coinbase transaction has been requested, " +
-				"but the server has not been configured with " +
-				"any payment addresses via --miningaddr",
+	// Create a reply
+	reply := make([]string, len(addressTxns))
+
+	for i := range addressTxns {
+		// The deserialized transaction is needed, so deserialize the
+		// retrieved transaction if it's in serialized form (which will
+		// be the case when it was lookup up from the database).
+		// Otherwise, use the existing deserialized transaction.
+		rtx := &addressTxns[i]
+		var mtx *wire.MsgTx
+		if rtx.tx == nil {
+			// Deserialize the transaction.
+			mtx = new(wire.MsgTx)
+			err := mtx.Deserialize(bytes.NewReader(rtx.txBytes))
+			if err != nil {
+				context := "Failed to deserialize transaction"
+				return nil, internalRPCError(err.Error(),
+					context)
+			}
+		} else {
+			mtx = rtx.tx.MsgTx()
 		}
+		reply[i] = mtx.TxHash().String()
 	}
 
-	// Return an error if there are no peers connected since there is no
-	// way to relay a found block or receive transactions to work on.
-	// However, allow this state when running in the regression test or
-	// simulation test mode.
-	if !(cfg.RegressionTest || cfg.SimNet) && s.server.ConnectedCount() == 0 {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCClientNotConnected,
-			Message: "Bitcoin is not connected",
+	return reply, nil
+}
+
+// threadTipResults returns the JSON representation of the passed admin thread
+// tips.
+func threadTipResults(tips map[provautil.ThreadID]*wire.OutPoint) []btcjson.ThreadTipResult {
+	rootTip := tips[provautil.RootThread]
+	provisionTip := tips[provautil.ProvisionThread]
+	issueTip := tips[provautil.IssueThread]
+	return []btcjson.ThreadTipResult{
+		{
+			ID:       uint32(provautil.RootThread),
+			Name:     "root",
+			OutPoint: rootTip.String(),
+		},
+		{
+			ID:       uint32(provautil.ProvisionThread),
+			Name:     "provision",
+			OutPoint: provisionTip.String(),
+		},
+		{
+			ID:       uint32(provautil.IssueThread),
+			Name:     "issue",
+			OutPoint: issueTip.String(),
+		},
+	}
+}
+
+// handleGetAdminInfo implements the getadmininfo command.
+func handleGetAdminInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	adminKeySets := s.chain.AdminKeySets()
+	aspKeyIdMap := s.chain.KeyIDs()
+	threadTipObj := threadTipResults(s.chain.ThreadTips())
+	aspObj := make([]btcjson.ASPKeyIdResult, len(aspKeyIdMap))
+	i := 0
+	for k, v := range aspKeyIdMap {
+		aspObj[i] = btcjson.ASPKeyIdResult{
+			KeyID:  uint32(k),
+			PubKey: hex.EncodeToString(v.SerializeCompressed()),
+		}
+		i++
+	}
+	result := &btcjson.GetAdminInfoResult{
+		Hash:          best.Hash.String(),
+		Height:        best.Height,
+		ThreadTips:    threadTipObj,
+		TotalSupply:   s.chain.TotalSupply(),
+		LastKeyID:     uint32(s.chain.LastKeyID()),
+		RootKeys:      adminKeySets[btcec.RootKeySet].ToStringArray(),
+		ProvisionKeys: adminKeySets[btcec.ProvisionKeySet].ToStringArray(),
+		IssueKeys:     adminKeySets[btcec.IssueKeySet].ToStringArray(),
+		ValidateKeys:  adminKeySets[btcec.ValidateKeySet].ToStringArray(),
+		ASPKeys:       aspObj,
+	}
+	return result, nil
+}
+
+// attestationKey returns the key chain state attestations are signed with:
+// the configured attestation key, or else the first validate key of the node
+// which is part of the active validate key set.  It returns nil when there is
+// no such key.
+func (s *rpcServer) attestationKey() btcec.Signer {
+	if cfg.attestationKey != nil {
+		return cfg.attestationKey
+	}
+	validateKeys := s.chain.AdminKeySets()[btcec.ValidateKeySet]
+	for _, key := range s.server.cpuMiner.ValidateKeys() {
+		if validateKeys.Pos(key.PubKey()) >= 0 {
+			return key
 		}
 	}
+	return nil
+}
+
+// attestationResult returns the JSON representation of an attestation.
+func attestationResult(a *attestation.Attestation) (*btcjson.AttestationResult, error) {
+	var buf bytes.Buffer
+	if err := a.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return &btcjson.AttestationResult{
+		Network:     a.Net.String(),
+		Height:      a.Height,
+		Hash:        a.BlockHash.String(),
+		UtxoSetHash: a.UtxoSetHash.String(),
+		Utxos:       a.UtxoCount,
+		UtxoAmount:  a.UtxoAmount,
+		Supply:      a.Supply,
+		Time:        a.Timestamp.Unix(),
+		PubKey:      hex.EncodeToString(a.PubKey[:]),
+		Signature:   hex.EncodeToString(a.Signature),
+		Hex:         hex.EncodeToString(buf.Bytes()),
+	}, nil
+}
 
-	// No point in generating or accepting work before the chain is synced.
-	currentHeight := s.server.blockManager.chain.BestSnapshot().Height
-	if currentHeight != 0 && !s.server.blockManager.IsCurrent() {
+// handleGetAttestation implements the getattestation command.
+func handleGetAttestation(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	key := s.attestationKey()
+	if key == nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCClientInInitialDownload,
-			Message: "Bitcoin is downloading blocks...",
+			Code: btcjson.ErrRPCMisc,
+			Message: "No attestation key: --attestationkey or an " +
+				"active validate key is required",
 		}
 	}
 
-	// When a long poll ID was provided, this is a long poll request by the
-	// client to be notified when block template referenced by the ID should
-	// be replaced with a new one.
-	if request != nil && request.LongPollID != "" {
-		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeChan)
+	stats, err := s.chain.FetchUtxoSetStats()
+	if err != nil {
+		context := "Failed to compute the utxo set statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	a := &attestation.Attestation{
+		Net:         s.server.chainParams.Net,
+		Height:      stats.Height,
+		BlockHash:   stats.Hash,
+		UtxoSetHash: stats.SetHash,
+		UtxoCount:   stats.Outputs,
+		UtxoAmount:  stats.TotalAmount,
+		Supply:      stats.TotalSupply,
+		Timestamp:   time.Unix(time.Now().Unix(), 0),
+	}
+	if err := a.Sign(key); err != nil {
+		context := "Failed to sign the attestation"
+		return nil, internalRPCError(err.Error(), context)
 	}
+	result, err := attestationResult(a)
+	if err != nil {
+		context := "Failed to serialize the attestation"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return result, nil
+}
 
-	// Protect concurrent access when updating block templates.
-	state := s.gbtWorkState
-	state.Lock()
-	defer state.Unlock()
+// auditLogUnavailable returns the error of the audit log commands when the
+// audit log is not enabled.
+func auditLogUnavailable() error {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCMisc,
+		Message: "Audit log must be enabled (--auditlog)",
+	}
+}
 
-	// Get and return a block template.  A new block template will be
-	// generated when the current best block has changed or the transactions
-	// in the memory pool have been updated and it has been at least five
-	// seconds since the last template was generated.  Otherwise, the
-	// timestamp for the existing block template is updated (and possibly
-	// the difficulty on testnet per the consesus rules).
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
-		return nil, err
+// handleGetAuditLog implements the getauditlog command.
+func handleGetAuditLog(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.auditLog == nil {
+		return nil, auditLogUnavailable()
 	}
-	return state.blockTemplateResult(useCoinbaseValue, nil)
+
+	c := cmd.(*btcjson.GetAuditLogCmd)
+	if *c.Count < 0 || *c.Count > maxAuditLogCount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Count must be between 0 and %d",
+				maxAuditLogCount),
+		}
+	}
+
+	// The last entry is fetched first, so the entries returned are known
+	// to chain up to it.
+	last, lastHash := s.auditLog.Last()
+	entries, err := s.auditLog.Entries(*c.Start, *c.Count)
+	if err != nil {
+		context := "Failed to read the audit log"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetAuditLogResult{
+		Last:     last,
+		LastHash: lastHash,
+		Entries:  make([]btcjson.AuditLogEntryResult, 0, len(entries)),
+	}
+	for _, e := range entries {
+		result.Entries = append(result.Entries, btcjson.AuditLogEntryResult{
+			Seq:      e.Seq,
+			Time:     e.Time,
+			Kind:     e.Kind.String(),
+			Action:   e.Action,
+			Details:  e.Details,
+			PrevHash: e.PrevHash,
+			Hash:     e.Hash,
+		})
+	}
+	return result, nil
 }
 
-// chainErrToGBTErrString converts an error returned from btcchain to a string
-// which matches the reasons and format described in BIP0022 for rejection
-// reasons.
-func chainErrToGBTErrString(err error) string {
-	// When the passed error is not a RuleError, just return a generic
-	// rejected string with the error text.
-	ruleErr, ok := err.(blockchain.RuleError)
-	if !ok {
-		return "rejected: " + err.Error()
+// handleGetBestBlock implements the getbestblock command.
+func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// All other "get block" commands give either the height, the
+	// hash, or both but require the block SHA.  This gets both for
+	// the best block.
+	best := s.chain.BestSnapshot()
+	result := &btcjson.GetBestBlockResult{
+		Hash:   best.Hash.String(),
+		Height: best.Height,
 	}
+	return result, nil
+}
 
-	switch ruleErr.ErrorCode {
-	case blockchain.ErrDuplicateBlock:
-		return "duplicate"
-	case blockchain.ErrBlockTooBig:
-		return "bad-block-size"
-	case blockchain.ErrBlockVersionTooOld:
-		return "bad-version"
-	case blockchain.ErrInvalidTime:
+// handleGetBestBlockHash implements the getbestblockhash command.
+func handleGetBestBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	return best.Hash.String(), nil
+}
+
+// getDifficultyRatio returns the proof-of-work difficulty as a multiple of the
+// minimum difficulty using the passed bits field from the header of a block.
+func getDifficultyRatio(bits uint32) float64 {
+	// The minimum difficulty is the max possible proof-of-work limit bits
+	// converted back to a number.  Note this is not the same as the proof of
+	// work limit directly because the block difficulty is encoded in a block
+	// with the compact form which loses precision.
+	max := blockchain.CompactToBig(activeNetParams.PowLimitBits)
+	target := blockchain.CompactToBig(bits)
+
+	difficulty := new(big.Rat).SetFrac(max, target)
+	outString := difficulty.FloatString(8)
+	diff, err := strconv.ParseFloat(outString, 64)
+	if err != nil {
+		rpcsLog.Errorf("Cannot get difficulty: %v", err)
+		return 0
+	}
+	return diff
+}
+
+// legacyGetBlockParams returns the passed getblock parameters with a boolean
+// verbose flag replaced by the equivalent verbosity level.
+func legacyGetBlockParams(params []json.RawMessage) []json.RawMessage {
+	if len(params) < 2 {
+		return params
+	}
+	var verbose bool
+	if err := json.Unmarshal(params[1], &verbose); err != nil {
+		return params
+	}
+	converted := append([]json.RawMessage(nil), params...)
+	converted[1] = json.RawMessage("0")
+	if verbose {
+		converted[1] = json.RawMessage("1")
+	}
+	return converted
+}
+
+// handleGetBlock implements the getblock command.
+func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockCmd)
+	verbosity := 1
+	if c.Verbosity != nil {
+		verbosity = *c.Verbosity
+	}
+	if verbosity < 0 || verbosity > 2 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Verbosity must be 0, 1 or 2",
+		}
+	}
+
+	// Load the raw block bytes from the database.
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	var blkBytes []byte
+	err = s.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		blkBytes, err = dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// When the verbosity is 0, simply return the serialized block as a
+	// hex-encoded string.
+	if verbosity == 0 {
+		return hex.EncodeToString(blkBytes), nil
+	}
+
+	// Otherwise generate the JSON object and return it.
+
+	// Deserialize the block.
+	blk, err := provautil.NewBlockFromBytes(blkBytes)
+	if err != nil {
+		context := "Failed to deserialize block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Get the block height from chain.
+	blockHeight, err := s.chain.BlockHeightByHash(hash)
+	if err != nil {
+		context := "Failed to obtain block height"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	best := s.chain.BestSnapshot()
+
+	// Get next block hash unless there are none.
+	var nextHashString string
+	if blockHeight < best.Height {
+		nextHash, err := s.chain.BlockHashByHeight(blockHeight + 1)
+		if err != nil {
+			context := "No next block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		nextHashString = nextHash.String()
+	}
+
+	blockHeader := &blk.MsgBlock().Header
+	blockReply := btcjson.GetBlockVerboseResult{
+		Hash:             c.Hash,
+		Version:          blockHeader.Version,
+		MerkleRoot:       blockHeader.MerkleRoot.String(),
+		PreviousHash:     blockHeader.PrevBlock.String(),
+		Nonce:            blockHeader.Nonce,
+		Time:             blockHeader.Timestamp.Unix(),
+		Confirmations:    uint64(1 + best.Height - blockHeight),
+		Height:           int64(blockHeader.Height),
+		Size:             int32(blockHeader.Size),
+		Bits:             strconv.FormatInt(int64(blockHeader.Bits), 16),
+		Difficulty:       getDifficultyRatio(blockHeader.Bits),
+		NextHash:         nextHashString,
+		ValidatingPubKey: blockHeader.ValidatingPubKey.String(),
+		Signature:        blockHeader.Signature.String(),
+	}
+
+	if verbosity == 2 {
+		txns, err := createBlockTxResults(s, blk, blockHeight,
+			best.Height)
+		if err != nil {
+			return nil, err
+		}
+		return btcjson.GetBlockVerboseTxResult{
+			Hash:             blockReply.Hash,
+			Confirmations:    blockReply.Confirmations,
+			Size:             blockReply.Size,
+			Height:           blockReply.Height,
+			Version:          blockReply.Version,
+			MerkleRoot:       blockReply.MerkleRoot,
+			Tx:               txns,
+			Time:             blockReply.Time,
+			Nonce:            blockReply.Nonce,
+			Bits:             blockReply.Bits,
+			Difficulty:       blockReply.Difficulty,
+			PreviousHash:     blockReply.PreviousHash,
+			NextHash:         blockReply.NextHash,
+			ValidatingPubKey: blockReply.ValidatingPubKey,
+			Signature:        blockReply.Signature,
+		}, nil
+	}
+
+	if c.VerboseTx == nil || !*c.VerboseTx {
+		transactions := blk.Transactions()
+		txNames := make([]string, len(transactions))
+		for i, tx := range transactions {
+			txNames[i] = tx.Hash().String()
+		}
+
+		blockReply.Tx = txNames
+	} else {
+		txns := blk.Transactions()
+		rawTxns := make([]btcjson.TxRawResult, len(txns))
+		for i, tx := range txns {
+			rawTxn, err := createTxRawResult(s.server.chainParams,
+				tx.MsgTx(), tx.Hash().String(), blockHeader,
+				hash.String(), blockHeight, best.Height)
+			if err != nil {
+				return nil, err
+			}
+			rawTxns[i] = *rawTxn
+		}
+		blockReply.RawTx = rawTxns
+	}
+
+	return blockReply, nil
+}
+
+// createBlockTxResults returns the fully decoded transactions of the passed
+// block of the main chain, including the previous outputs spent by their
+// inputs.  The previous outputs are loaded from the spend journal of the
+// block, or from the transaction index when the spend journal is unavailable.
+// They are omitted when neither is available.
+func createBlockTxResults(s *rpcServer, blk *provautil.Block, blockHeight uint32, bestHeight uint32) ([]btcjson.SearchRawTransactionsResult, error) {
+	originOutputs, err := fetchBlockSpentTxOuts(s, blk.Hash())
+	if err != nil {
+		rpcsLog.Debugf("Failed to load the spent outputs of block "+
+			"%v: %v", blk.Hash(), err)
+	}
+
+	chainParams := s.server.chainParams
+	blockHeader := &blk.MsgBlock().Header
+	blockHash := blk.Hash().String()
+	txns := blk.Transactions()
+	results := make([]btcjson.SearchRawTransactionsResult, len(txns))
+	for i, tx := range txns {
+		mtx := tx.MsgTx()
+		txOrigins := originOutputs
+		if txOrigins == nil && s.server.indexes().txIndex != nil &&
+			!blockchain.IsCoinBaseTx(mtx) {
+
+			txOrigins, err = fetchInputTxos(s, mtx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		mtxHex, err := messageToHex(mtx)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = btcjson.SearchRawTransactionsResult{
+			Hex:      mtxHex,
+			Txid:     tx.Hash().String(),
+			Version:  mtx.Version,
+			LockTime: mtx.LockTime,
+			Vin: createVinListOriginOutputs(mtx, chainParams,
+				txOrigins, true, nil),
+			Vout:          createVoutList(mtx, chainParams, nil),
+			BlockHash:     blockHash,
+			Confirmations: uint64(1 + bestHeight - blockHeight),
+			Time:          blockHeader.Timestamp.Unix(),
+			Blocktime:     blockHeader.Timestamp.Unix(),
+		}
+	}
+	return results, nil
+}
+
+// handleGetBlockChainInfo implements the getblockchaininfo command.
+func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	params := s.server.chainParams
+	best := s.chain.BestSnapshot()
+	adminKeySets := s.chain.AdminKeySets()
+	result := &btcjson.GetBlockChainInfoResult{
+		Chain:         params.Name,
+		Blocks:        int32(best.Height),
+		Headers:       int32(best.Height),
+		BestBlockHash: best.Hash.String(),
+		Difficulty:    getDifficultyRatio(best.Bits),
+		AdminThresholds: btcjson.AdminThresholdsResult{
+			Root:      params.AdminThreshold(btcec.RootKeySet),
+			Provision: params.AdminThreshold(btcec.ProvisionKeySet),
+			Issue:     params.AdminThreshold(btcec.IssueKeySet),
+		},
+		ThreadTips: threadTipResults(s.chain.ThreadTips()),
+		KeyCounts: btcjson.KeyCountsResult{
+			Root:      len(adminKeySets[btcec.RootKeySet]),
+			Provision: len(adminKeySets[btcec.ProvisionKeySet]),
+			Issue:     len(adminKeySets[btcec.IssueKeySet]),
+			Validate:  len(adminKeySets[btcec.ValidateKeySet]),
+			ASP:       len(s.chain.KeyIDs()),
+			Frozen:    len(s.chain.FrozenKeyIDs()),
+		},
+		TotalSupply: s.chain.TotalSupply(),
+	}
+
+	// The issuance totals are only known with the supply index.
+	if supplyIndex := s.server.indexes().supplyIndex; supplyIndex != nil {
+		totals, err := supplyIndex.Totals()
+		if err != nil {
+			context := "Failed to load supply totals"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Issuance = &btcjson.IssuanceResult{
+			LastIssueHeight: totals.LastIssueHeight,
+			Issued:          totals.Issued,
+			Destroyed:       totals.Destroyed,
+		}
+	}
+
+	// The rule changes apply to the blocks at and above their activation
+	// height, which is the maximum height when they are not scheduled.
+	softForks := []struct {
+		id     string
+		height uint32
+	}{
+		{"cltv", params.CLTVActivationHeight},
+		{"schnorr", params.SchnorrActivationHeight},
+		{"freeze", params.FreezeActivationHeight},
+		{"coinbaseheight", params.CoinbaseActivationHeight},
+		{"generalprova", params.GeneralProvaActivationHeight},
+	}
+	result.SoftForks = make([]btcjson.SoftForkResult, 0, len(softForks))
+	for _, fork := range softForks {
+		softFork := btcjson.SoftForkResult{
+			ID:     fork.id,
+			Active: best.Height+1 >= fork.height,
+		}
+		if fork.height != math.MaxUint32 {
+			height := fork.height
+			softFork.ActivationHeight = &height
+		}
+		result.SoftForks = append(result.SoftForks, softFork)
+	}
+	return result, nil
+}
+
+// handleGetBlockCount implements the getblockcount command.
+func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	return int64(best.Height), nil
+}
+
+// handleGetBlockHash implements the getblockhash command.
+func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHashCmd)
+	hash, err := s.chain.BlockHashByHeight(uint32(c.Index))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+
+	return hash.String(), nil
+}
+
+// handleGetBlockHashByTime implements the getblockhashbytime command.
+func handleGetBlockHashByTime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the timestamp index is not enabled.
+	timestampIndex := s.server.indexes().timestampIndex
+	if timestampIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Timestamp index must be enabled (--timestampindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetBlockHashByTimeCmd)
+	blockTime, err := timestampIndex.BlockByTime(c.Timestamp)
+	if err != nil {
+		context := "Failed to load block by time"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if blockTime == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "No block at or before the timestamp",
+		}
+	}
+	return &btcjson.GetBlockHashByTimeResult{
+		Hash:   blockTime.Hash.String(),
+		Height: blockTime.Height,
+		Time:   blockTime.Timestamp,
+	}, nil
+}
+
+// handleGetBlockHeader implements the getblockheader command.
+func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHeaderCmd)
+
+	// Fetch the header from chain.
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	blockHeader, err := s.chain.FetchHeader(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// When the verbose flag isn't set, simply return the serialized block
+	// header as a hex-encoded string.
+	if c.Verbose != nil && !*c.Verbose {
+		var headerBuf bytes.Buffer
+		err := blockHeader.Serialize(&headerBuf)
+		if err != nil {
+			context := "Failed to serialize block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		return hex.EncodeToString(headerBuf.Bytes()), nil
+	}
+
+	// The verbose flag is set, so generate the JSON object and return it.
+
+	// Get the block height from chain.
+	blockHeight, err := s.chain.BlockHeightByHash(hash)
+	if err != nil {
+		context := "Failed to obtain block height"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	best := s.chain.BestSnapshot()
+
+	// Get next block hash unless there are none.
+	var nextHashString string
+	if blockHeight < best.Height {
+		nextHash, err := s.chain.BlockHashByHeight(blockHeight + 1)
+		if err != nil {
+			context := "No next block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		nextHashString = nextHash.String()
+	}
+
+	return blockHeaderVerboseResult(&blockHeader, c.Hash,
+		uint64(1+best.Height-blockHeight), nextHashString), nil
+}
+
+// blockHeaderVerboseResult returns the JSON object describing the passed block
+// header as returned by the getblockheader and getblockheaders commands.
+func blockHeaderVerboseResult(blockHeader *wire.BlockHeader, hash string, confirmations uint64, nextHash string) btcjson.GetBlockHeaderVerboseResult {
+	return btcjson.GetBlockHeaderVerboseResult{
+		Hash:             hash,
+		Confirmations:    confirmations,
+		Height:           int32(blockHeader.Height),
+		Version:          blockHeader.Version,
+		MerkleRoot:       blockHeader.MerkleRoot.String(),
+		NextHash:         nextHash,
+		PreviousHash:     blockHeader.PrevBlock.String(),
+		Nonce:            uint64(blockHeader.Nonce),
+		Time:             blockHeader.Timestamp.Unix(),
+		Bits:             strconv.FormatInt(int64(blockHeader.Bits), 16),
+		Difficulty:       getDifficultyRatio(blockHeader.Bits),
+		Signature:        blockHeader.Signature.String(),
+		ValidatingPubKey: blockHeader.ValidatingPubKey.String(),
+	}
+}
+
+// handleGetBlockHeaders implements the getblockheaders command.
+func handleGetBlockHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHeadersCmd)
+
+	if c.Count <= 0 || c.Count > wire.MaxBlockHeadersPerMsg {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Count must be between 1 and %d",
+				wire.MaxBlockHeadersPerMsg),
+		}
+	}
+
+	// The start block is either identified by its hash or its height.
+	var startHeight uint32
+	if len(c.Start) == chainhash.MaxHashStringSize {
+		hash, err := chainhash.NewHashFromStr(c.Start)
+		if err != nil {
+			return nil, rpcDecodeHexError(c.Start)
+		}
+		startHeight, err = s.chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+	} else {
+		height, err := strconv.ParseUint(c.Start, 10, 32)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Start %q is neither a block "+
+					"hash nor a block height", c.Start),
+			}
+		}
+		startHeight = uint32(height)
+	}
+	if startHeight > s.chain.BestSnapshot().Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+
+	// Fetch one more hash than requested so the next block hash of the last
+	// returned header is known as well.
+	hashes, err := s.chain.HeightRange(startHeight,
+		startHeight+uint32(c.Count)+1)
+	if err != nil {
+		context := "Failed to fetch block hashes"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	numHeaders := len(hashes)
+	if numHeaders > c.Count {
+		numHeaders = c.Count
+	}
+
+	// The best chain only grows past the fetched range while the headers are
+	// loaded, so the snapshot taken now never makes the confirmations of the
+	// returned headers underflow.
+	best := s.chain.BestSnapshot()
+	verbose := c.Verbose == nil || *c.Verbose
+	rawHeaders := make([]string, 0, numHeaders)
+	verboseHeaders := make([]btcjson.GetBlockHeaderVerboseResult, 0,
+		numHeaders)
+	for i := 0; i < numHeaders; i++ {
+		blockHeader, err := s.chain.FetchHeader(&hashes[i])
+		if err != nil {
+			context := "Failed to fetch block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		if !verbose {
+			var headerBuf bytes.Buffer
+			err := blockHeader.Serialize(&headerBuf)
+			if err != nil {
+				context := "Failed to serialize block header"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			rawHeaders = append(rawHeaders,
+				hex.EncodeToString(headerBuf.Bytes()))
+			continue
+		}
+
+		var nextHash string
+		if i+1 < len(hashes) {
+			nextHash = hashes[i+1].String()
+		}
+		confirmations := uint64(1 + best.Height - blockHeader.Height)
+		verboseHeaders = append(verboseHeaders, blockHeaderVerboseResult(
+			&blockHeader, hashes[i].String(), confirmations, nextHash))
+	}
+
+	if !verbose {
+		return rawHeaders, nil
+	}
+	return verboseHeaders, nil
+}
+
+// encodeTemplateID encodes the passed details into an ID that can be used to
+// uniquely identify a block template.
+func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
+	return fmt.Sprintf("%s-%d", prevHash.String(), lastGenerated.Unix())
+}
+
+// decodeTemplateID decodes an ID that is used to uniquely identify a block
+// template.  This is mainly used as a mechanism to track when to update clients
+// that are using long polling for block templates.  The ID consists of the
+// previous block hash for the associated template and the time the associated
+// template was generated.
+func decodeTemplateID(templateID string) (*chainhash.Hash, int64, error) {
+	fields := strings.Split(templateID, "-")
+	if len(fields) != 2 {
+		return nil, 0, errors.New("invalid longpollid format")
+	}
+
+	prevHash, err := chainhash.NewHashFromStr(fields[0])
+	if err != nil {
+		return nil, 0, errors.New("invalid longpollid format")
+	}
+	lastGenerated, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, 0, errors.New("invalid longpollid format")
+	}
+
+	return prevHash, lastGenerated, nil
+}
+
+// notifyLongPollers notifies any channels that have been registered to be
+// notified when block templates are stale.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGenerated time.Time) {
+	// Notify anything that is waiting for a block template update from a
+	// hash which is not the hash of the tip of the best chain since their
+	// work is now invalid.
+	for hash, channels := range state.notifyMap {
+		if !hash.IsEqual(latestHash) {
+			for _, c := range channels {
+				close(c)
+			}
+			delete(state.notifyMap, hash)
+		}
+	}
+
+	// Return now if the provided last generated timestamp has not been
+	// initialized.
+	if lastGenerated.IsZero() {
+		return
+	}
+
+	// Return now if there is nothing registered for updates to the current
+	// best block hash.
+	channels, ok := state.notifyMap[*latestHash]
+	if !ok {
+		return
+	}
+
+	// Notify anything that is waiting for a block template update from a
+	// block template generated before the most recently generated block
+	// template.
+	lastGeneratedUnix := lastGenerated.Unix()
+	for lastGen, c := range channels {
+		if lastGen < lastGeneratedUnix {
+			close(c)
+			delete(channels, lastGen)
+		}
+	}
+
+	// Remove the entry altogether if there are no more registered
+	// channels.
+	if len(channels) == 0 {
+		delete(state.notifyMap, *latestHash)
+	}
+}
+
+// NotifyBlockConnected uses the newly-connected block to notify any long poll
+// clients with a new block template when their existing block template is
+// stale due to the newly connected block.
+func (state *gbtWorkState) NotifyBlockConnected(blockHash *chainhash.Hash) {
+	go func() {
+		state.Lock()
+		defer state.Unlock()
+
+		state.notifyLongPollers(blockHash, state.lastTxUpdate)
+	}()
+}
+
+// NotifyMempoolTx uses the new last updated time for the transaction memory
+// pool to notify any long poll clients with a new block template when their
+// existing block template is stale due to enough time passing and the contents
+// of the memory pool changing.
+func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
+	go func() {
+		state.Lock()
+		defer state.Unlock()
+
+		// No need to notify anything if no block templates have been generated
+		// yet.
+		if state.prevHash == nil || state.lastGenerated.IsZero() {
+			return
+		}
+
+		if time.Now().After(state.lastGenerated.Add(time.Second *
+			gbtRegenerateSeconds)) {
+
+			state.notifyLongPollers(state.prevHash, lastUpdated)
+		}
+	}()
+}
+
+// templateUpdateChan returns a channel that will be closed once the block
+// template associated with the passed previous hash and last generated time
+// is stale.  The function will return existing channels for duplicate
+// parameters which allows multiple clients to wait for the same block template
+// without requiring a different channel for each client.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, lastGenerated int64) chan struct{} {
+	// Either get the current list of channels waiting for updates about
+	// changes to block template for the previous hash or create a new one.
+	channels, ok := state.notifyMap[*prevHash]
+	if !ok {
+		m := make(map[int64]chan struct{})
+		state.notifyMap[*prevHash] = m
+		channels = m
+	}
+
+	// Get the current channel associated with the time the block template
+	// was last generated or create a new one.
+	c, ok := channels[lastGenerated]
+	if !ok {
+		c = make(chan struct{})
+		channels[lastGenerated] = c
+	}
+
+	return c
+}
+
+// randomMiningAddr returns one of the payment addresses configured with
+// --miningaddr at random.  An error is returned when there are none, which the
+// callers have checked, unless the configuration was reloaded since.
+func (s *rpcServer) randomMiningAddr() (provautil.Address, error) {
+	miningAddrs := s.server.cpuMiner.MiningAddrs()
+	if len(miningAddrs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified " +
+				"via --miningaddr",
+		}
+	}
+	return miningAddrs[rand.Intn(len(miningAddrs))], nil
+}
+
+// updateBlockTemplate creates or updates a block template for the work state.
+// A new block template will be generated when the current best block has
+// changed or the transactions in the memory pool have been updated and it has
+// been long enough since the last template was generated.  Otherwise, the
+// timestamp for the existing block template is updated (and possibly the
+// difficulty on testnet per the consesus rules).  Finally, if the
+// useCoinbaseValue flag is false and the existing block template does not
+// already contain a valid payment address, the block template will be updated
+// with a randomly selected payment address from the list of configured
+// addresses.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bool) error {
+	lastTxUpdate := s.server.txMemPool.LastUpdated()
+	if lastTxUpdate.IsZero() {
+		lastTxUpdate = time.Now()
+	}
+
+	// Generate a new block template when the current best block has
+	// changed or the transactions in the memory pool have been updated and
+	// it has been at least gbtRegenerateSecond since the last template was
+	// generated.
+	var msgBlock *wire.MsgBlock
+	var targetDifficulty string
+	latestHash := s.server.blockManager.chain.BestSnapshot().Hash
+	template := state.template
+	if template == nil || state.prevHash == nil ||
+		!state.prevHash.IsEqual(latestHash) ||
+		(state.lastTxUpdate != lastTxUpdate &&
+			time.Now().After(state.lastGenerated.Add(time.Second*
+				gbtRegenerateSeconds))) {
+
+		// Reset the previous best hash the block template was generated
+		// against so any errors below cause the next invocation to try
+		// again.
+		state.prevHash = nil
+
+		// Choose a payment address at random if the caller requests a
+		// full coinbase as opposed to only the pertinent details needed
+		// to create their own coinbase.
+		var payAddr provautil.Address
+		if !useCoinbaseValue {
+			var err error
+			payAddr, err = s.randomMiningAddr()
+			if err != nil {
+				return err
+			}
+		}
+
+		// Create a new block template that has a coinbase which anyone
+		// can redeem.  This is only acceptable because the returned
+		// block template doesn't include the coinbase, so the caller
+		// will ultimately create their own coinbase which pays to the
+		// appropriate address(es).
+		blkTemplate, err := s.generator.NewBlockTemplate(payAddr, nil)
+		if err != nil {
+			return internalRPCError("Failed to create new block "+
+				"template: "+err.Error(), "")
+		}
+		template = blkTemplate
+		msgBlock = template.Block
+		targetDifficulty = fmt.Sprintf("%064x",
+			blockchain.CompactToBig(msgBlock.Header.Bits))
+
+		// Get the minimum allowed timestamp for the block based on the
+		// median timestamp of the last several blocks per the chain
+		// consensus rules.
+		best := s.server.blockManager.chain.BestSnapshot()
+		minTimestamp := mining.MinimumMedianTime(best)
+
+		// Update work state to ensure another block template isn't
+		// generated until needed.
+		state.template = template
+		state.lastGenerated = time.Now()
+		state.lastTxUpdate = lastTxUpdate
+		state.prevHash = latestHash
+		state.minTimestamp = minTimestamp
+
+		rpcsLog.Debugf("Generated block template (timestamp %v, "+
+			"target %s, merkle root %s)",
+			msgBlock.Header.Timestamp, targetDifficulty,
+			msgBlock.Header.MerkleRoot)
+
+		// Notify any clients that are long polling about the new
+		// template.
+		state.notifyLongPollers(latestHash, lastTxUpdate)
+	} else {
+		// At this point, there is a saved block template and another
+		// request for a template was made, but either the available
+		// transactions haven't change or it hasn't been long enough to
+		// trigger a new block template to be generated.  So, update the
+		// existing block template.
+
+		// When the caller requires a full coinbase as opposed to only
+		// the pertinent details needed to create their own coinbase,
+		// add a payment address to the output of the coinbase of the
+		// template if it doesn't already have one.  Since this requires
+		// mining addresses to be specified via the config, an error is
+		// returned if none have been specified.
+		if !useCoinbaseValue && !template.ValidPayAddress {
+			// Choose a payment address at random.
+			payToAddr, err := s.randomMiningAddr()
+			if err != nil {
+				return err
+			}
+
+			// Update the block coinbase output of the template to
+			// pay to the randomly selected payment address.
+			pkScript, err := txscript.PayToAddrScript(payToAddr)
+			if err != nil {
+				context := "Failed to create pay-to-addr script"
+				return internalRPCError(err.Error(), context)
+			}
+			template.Block.Transactions[0].TxOut[0].PkScript = pkScript
+			template.ValidPayAddress = true
+
+			// Update the merkle root.
+			block := provautil.NewBlock(template.Block)
+			merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
+		}
+
+		// Set locals for convenience.
+		msgBlock = template.Block
+		targetDifficulty = fmt.Sprintf("%064x",
+			blockchain.CompactToBig(msgBlock.Header.Bits))
+
+		// Update the time of the block template to the current time
+		// while accounting for the median time of the past several
+		// blocks per the chain consensus rules.
+		s.generator.UpdateBlockTime(msgBlock, nil)
+		msgBlock.Header.Nonce = 0
+
+		rpcsLog.Debugf("Updated block template (timestamp %v, "+
+			"target %s)", msgBlock.Header.Timestamp,
+			targetDifficulty)
+	}
+
+	return nil
+}
+
+// blockTemplateResult returns the current block template associated with the
+// state as a btcjson.GetBlockTemplateResult that is ready to be encoded to JSON
+// and returned to the caller.
+//
+// This function MUST be called with the state locked.
+func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
+	// Ensure the timestamps are still in valid range for the template.
+	// This should really only ever happen if the local clock is changed
+	// after the template is generated, but it's important to avoid serving
+	// invalid block templates.
+	template := state.template
+	msgBlock := template.Block
+	header := &msgBlock.Header
+	adjustedTime := state.timeSource.AdjustedTime()
+	maxTime := adjustedTime.Add(time.Second * blockchain.MaxTimeOffsetSeconds)
+	if header.Timestamp.After(maxTime) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCOutOfRange,
+			Message: fmt.Sprintf("The template time is after the "+
+				"maximum allowed time for a block - template "+
+				"time %v, maximum time %v", adjustedTime,
+				maxTime),
+		}
+	}
+
+	// Convert each transaction in the block template to a template result
+	// transaction.  The result does not include the coinbase, so notice
+	// the adjustments to the various lengths and indices.
+	numTx := len(msgBlock.Transactions)
+	transactions := make([]btcjson.GetBlockTemplateResultTx, 0, numTx-1)
+	txIndex := make(map[chainhash.Hash]int64, numTx)
+	for i, tx := range msgBlock.Transactions {
+		txHash := tx.TxHash()
+		txIndex[txHash] = int64(i)
+
+		// Skip the coinbase transaction.
+		if i == 0 {
+			continue
+		}
+
+		// Create an array of 1-based indices to transactions that come
+		// before this one in the transactions list which this one
+		// depends on.  This is necessary since the created block must
+		// ensure proper ordering of the dependencies.  A map is used
+		// before creating the final array to prevent duplicate entries
+		// when multiple inputs reference the same transaction.
+		dependsMap := make(map[int64]struct{})
+		for _, txIn := range tx.TxIn {
+			if idx, ok := txIndex[txIn.PreviousOutPoint.Hash]; ok {
+				dependsMap[idx] = struct{}{}
+			}
+		}
+		depends := make([]int64, 0, len(dependsMap))
+		for idx := range dependsMap {
+			depends = append(depends, idx)
+		}
+
+		// Serialize the transaction for later conversion to hex.
+		txBuf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(txBuf); err != nil {
+			context := "Failed to serialize transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		resultTx := btcjson.GetBlockTemplateResultTx{
+			Data:    hex.EncodeToString(txBuf.Bytes()),
+			Hash:    txHash.String(),
+			Depends: depends,
+			Fee:     template.Fees[i],
+			SigOps:  template.SigOpCounts[i],
+		}
+		transactions = append(transactions, resultTx)
+	}
+
+	// Generate the block template reply.  Note that following mutations are
+	// implied by the included or omission of fields:
+	//  Including MinTime -> time/decrement
+	//  Omitting CoinbaseTxn -> coinbase, generation
+	targetDifficulty := fmt.Sprintf("%064x", blockchain.CompactToBig(header.Bits))
+	templateID := encodeTemplateID(state.prevHash, state.lastGenerated)
+	reply := btcjson.GetBlockTemplateResult{
+		Bits:         strconv.FormatInt(int64(header.Bits), 16),
+		CurTime:      header.Timestamp.Unix(),
+		Height:       int64(template.Height),
+		PreviousHash: header.PrevBlock.String(),
+		SigOpLimit:   blockchain.MaxSigOpsPerBlock,
+		SizeLimit:    wire.MaxBlockPayload,
+		Transactions: transactions,
+		Version:      header.Version,
+		LongPollID:   templateID,
+		SubmitOld:    submitOld,
+		Target:       targetDifficulty,
+		MinTime:      state.minTimestamp.Unix(),
+		MaxTime:      maxTime.Unix(),
+		Mutable:      gbtMutableFields,
+		NonceRange:   gbtNonceRange,
+		Capabilities: gbtCapabilities,
+	}
+	if useCoinbaseValue {
+		reply.CoinbaseAux = gbtCoinbaseAux
+		reply.CoinbaseValue = &msgBlock.Transactions[0].TxOut[0].Value
+	} else {
+		// Ensure the template has a valid payment address associated
+		// with it when a full coinbase is requested.
+		if !template.ValidPayAddress {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInternal.Code,
+				Message: "A coinbase transaction has been " +
+					"requested, but the server has not " +
+					"been configured with any payment " +
+					"addresses via --miningaddr",
+			}
+		}
+
+		// Serialize the transaction for conversion to hex.
+		tx := msgBlock.Transactions[0]
+		txBuf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(txBuf); err != nil {
+			context := "Failed to serialize transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		resultTx := btcjson.GetBlockTemplateResultTx{
+			Data:    hex.EncodeToString(txBuf.Bytes()),
+			Hash:    tx.TxHash().String(),
+			Depends: []int64{},
+			Fee:     template.Fees[0],
+			SigOps:  template.SigOpCounts[0],
+		}
+
+		reply.CoinbaseTxn = &resultTx
+	}
+
+	return &reply, nil
+}
+
+// handleGetBlockTemplateLongPoll is a helper for handleGetBlockTemplateRequest
+// which deals with handling long polling for block templates.  When a caller
+// sends a request with a long poll ID that was previously returned, a response
+// is not sent until the caller should stop working on the previous block
+// template in favor of the new one.  In particular, this is the case when the
+// old block template is no longer valid due to a solution already being found
+// and added to the block chain, or new transactions have shown up and some time
+// has passed without finding a solution.
+//
+// See https://en.bitcoin.it/wiki/BIP_0022 for more details.
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
+	state := s.gbtWorkState
+	state.Lock()
+	// The state unlock is intentionally not deferred here since it needs to
+	// be manually unlocked before waiting for a notification about block
+	// template changes.
+
+	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+		state.Unlock()
+		return nil, err
+	}
+
+	// Just return the current block template if the long poll ID provided by
+	// the caller is invalid.
+	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
+	if err != nil {
+		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+		if err != nil {
+			state.Unlock()
+			return nil, err
+		}
+
+		state.Unlock()
+		return result, nil
+	}
+
+	// Return the block template now if the specific block template
+	// identified by the long poll ID no longer matches the current block
+	// template as this means the provided template is stale.
+	prevTemplateHash := &state.template.Block.Header.PrevBlock
+	if !prevHash.IsEqual(prevTemplateHash) ||
+		lastGenerated != state.lastGenerated.Unix() {
+
+		// Include whether or not it is valid to submit work against the
+		// old block template depending on whether or not a solution has
+		// already been found and added to the block chain.
+		submitOld := prevHash.IsEqual(prevTemplateHash)
+		result, err := state.blockTemplateResult(useCoinbaseValue,
+			&submitOld)
+		if err != nil {
+			state.Unlock()
+			return nil, err
+		}
+
+		state.Unlock()
+		return result, nil
+	}
+
+	// Register the previous hash and last generated time for notifications
+	// Get a channel that will be notified when the template associated with
+	// the provided ID is stale and a new block template should be returned to
+	// the caller.
+	longPollChan := state.templateUpdateChan(prevHash, lastGenerated)
+	state.Unlock()
+
+	select {
+	// When the client closes before it's time to send a reply, just return
+	// now so the goroutine doesn't hang around.
+	case <-closeChan:
+		return nil, ErrClientQuit
+
+	// Wait until signal received to send the reply.
+	case <-longPollChan:
+		// Fallthrough
+	}
+
+	// Get the lastest block template
+	state.Lock()
+	defer state.Unlock()
+
+	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+		return nil, err
+	}
+
+	// Include whether or not it is valid to submit work against the old
+	// block template depending on whether or not a solution has already
+	// been found and added to the block chain.
+	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
+	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// handleGetBlockTemplateRequest is a helper for handleGetBlockTemplate which
+// deals with generating and returning block templates to the caller.  It
+// handles both long poll requests as specified by BIP 0022 as well as regular
+// requests.  In addition, it detects the capabilities reported by the caller
+// in regards to whether or not it supports creating its own coinbase (the
+// coinbasetxn and coinbasevalue capabilities) and modifies the returned block
+// template accordingly.
+func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, closeChan <-chan struct{}) (interface{}, error) {
+	// Extract the relevant passed capabilities and restrict the result to
+	// either a coinbase value or a coinbase transaction object depending on
+	// the request.  Default to only providing a coinbase value.
+	useCoinbaseValue := true
+	if request != nil {
+		var hasCoinbaseValue, hasCoinbaseTxn bool
+		for _, capability := range request.Capabilities {
+			switch capability {
+			case "coinbasetxn":
+				hasCoinbaseTxn = true
+			case "coinbasevalue":
+				hasCoinbaseValue = true
+			}
+		}
+
+		if hasCoinbaseTxn && !hasCoinbaseValue {
+			useCoinbaseValue = false
+		}
+	}
+
+	// When a coinbase transaction has been requested, respond with an error
+	// if there are no addresses to pay the created block template to.
+	if !useCoinbaseValue && len(s.server.cpuMiner.MiningAddrs()) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "A coinbase transaction has been requested, " +
+				"but the server has not been configured with " +
+				"any payment addresses via --miningaddr",
+		}
+	}
+
+	// Return an error if there are no peers connected since there is no
+	// way to relay a found block or receive transactions to work on.
+	// However, allow this state when running in the regression test or
+	// simulation test mode.
+	if !(cfg.RegressionTest || cfg.SimNet) && s.server.ConnectedCount() == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNotConnected,
+			Message: "Bitcoin is not connected",
+		}
+	}
+
+	// No point in generating or accepting work before the chain is synced.
+	currentHeight := s.server.blockManager.chain.BestSnapshot().Height
+	if currentHeight != 0 && !s.server.blockManager.IsCurrent() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInInitialDownload,
+			Message: "Bitcoin is downloading blocks...",
+		}
+	}
+
+	// When a long poll ID was provided, this is a long poll request by the
+	// client to be notified when block template referenced by the ID should
+	// be replaced with a new one.
+	if request != nil && request.LongPollID != "" {
+		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
+			useCoinbaseValue, closeChan)
+	}
+
+	// Protect concurrent access when updating block templates.
+	state := s.gbtWorkState
+	state.Lock()
+	defer state.Unlock()
+
+	// Get and return a block template.  A new block template will be
+	// generated when the current best block has changed or the transactions
+	// in the memory pool have been updated and it has been at least five
+	// seconds since the last template was generated.  Otherwise, the
+	// timestamp for the existing block template is updated (and possibly
+	// the difficulty on testnet per the consesus rules).
+	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+		return nil, err
+	}
+	return state.blockTemplateResult(useCoinbaseValue, nil)
+}
+
+// chainErrToGBTErrString converts an error returned from btcchain to a string
+// which matches the reasons and format described in BIP0022 for rejection
+// reasons.
+func chainErrToGBTErrString(err error) string {
+	// When the passed error is not a RuleError, just return a generic
+	// rejected string with the error text.
+	ruleErr, ok := err.(blockchain.RuleError)
+	if !ok {
+		return "rejected: " + err.Error()
+	}
+
+	switch ruleErr.ErrorCode {
+	case blockchain.ErrDuplicateBlock:
+		return "duplicate"
+	case blockchain.ErrBlockTooBig:
+		return "bad-block-size"
+	case blockchain.ErrBlockVersionTooOld:
+		return "bad-version"
+	case blockchain.ErrInvalidTime:
 		return "bad-time"
 	case blockchain.ErrTimeTooOld:
 		return "time-too-old"
@@ -1994,461 +4054,1786 @@ func chainErrToGBTErrString(err error) string {
 		return "bad-txns-highfee"
 	}
 
-	return "rejected: " + err.Error()
+	return "rejected: " + err.Error()
+}
+
+// handleGetBlockTemplateProposal is a helper for handleGetBlockTemplate which
+// deals with block proposals.
+//
+// See https://en.bitcoin.it/wiki/BIP_0023 for more details.
+func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateRequest) (interface{}, error) {
+	hexData := request.Data
+	if hexData == "" {
+		return false, &btcjson.RPCError{
+			Code: btcjson.ErrRPCType,
+			Message: fmt.Sprintf("Data must contain the " +
+				"hex-encoded serialized block that is being " +
+				"proposed"),
+		}
+	}
+
+	// Ensure the provided data is sane and deserialize the proposed block.
+	if len(hexData)%2 != 0 {
+		hexData = "0" + hexData
+	}
+	dataBytes, err := hex.DecodeString(hexData)
+	if err != nil {
+		return false, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDeserialization,
+			Message: fmt.Sprintf("Data must be "+
+				"hexadecimal string (not %q)", hexData),
+		}
+	}
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(bytes.NewReader(dataBytes)); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Block decode failed: " + err.Error(),
+		}
+	}
+	block := provautil.NewBlock(&msgBlock)
+
+	// Ensure the block is building from the expected previous block.
+	expectedPrevHash := s.server.blockManager.chain.BestSnapshot().Hash
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	if expectedPrevHash == nil || !expectedPrevHash.IsEqual(prevHash) {
+		return "bad-prevblk", nil
+	}
+
+	flags := blockchain.BFDryRun | blockchain.BFNoPoWCheck
+	isOrphan, err := s.server.blockManager.ProcessBlock(block, flags)
+	if err != nil {
+		if _, ok := err.(blockchain.RuleError); !ok {
+			err := rpcsLog.Errorf("Failed to process block "+
+				"proposal: %v", err)
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCVerify,
+				Message: err.Error(),
+			}
+		}
+
+		rpcsLog.Infof("Rejected block proposal: %v", err)
+		return chainErrToGBTErrString(err), nil
+	}
+	if isOrphan {
+		return "orphan", nil
+	}
+
+	return nil, nil
+}
+
+// handleGetBlockTemplate implements the getblocktemplate command.
+//
+// See https://en.bitcoin.it/wiki/BIP_0022 and
+// https://en.bitcoin.it/wiki/BIP_0023 for more details.
+func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockTemplateCmd)
+	request := c.Request
+
+	// Set the default mode and override it if supplied.
+	mode := "template"
+	if request != nil && request.Mode != "" {
+		mode = request.Mode
+	}
+
+	switch mode {
+	case "template":
+		return handleGetBlockTemplateRequest(s, request, closeChan)
+	case "proposal":
+		return handleGetBlockTemplateProposal(s, request)
+	}
+
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Invalid mode",
+	}
+}
+
+// handleGetBroadcastStatus implements the getbroadcaststatus command.
+func handleGetBroadcastStatus(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBroadcastStatusCmd)
+	status := s.server.broadcasts.Status(c.ID)
+	if status == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("No broadcast campaign with ID %d",
+				c.ID),
+		}
+	}
+	return status, nil
+}
+
+// handleGetCFilter implements the getcfilter command.
+func handleGetCFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the committed filter index is not enabled.
+	cfIndex := s.server.cfIndex
+	if cfIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Committed filter index must be enabled (--cfindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetCFilterCmd)
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+
+	filterBytes, err := cfIndex.FilterByBlockHash(hash)
+	if err != nil {
+		context := "Failed to load committed filter"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if filterBytes == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	return hex.EncodeToString(filterBytes), nil
+}
+
+// handleGetCFilterHeader implements the getcfilterheader command.
+func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the committed filter index is not enabled.
+	cfIndex := s.server.cfIndex
+	if cfIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Committed filter index must be enabled (--cfindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetCFilterHeaderCmd)
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+
+	headerBytes, err := cfIndex.FilterHeaderByBlockHash(hash)
+	if err != nil {
+		context := "Failed to load committed filter header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if headerBytes == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// The filter header is displayed like a hash.
+	var header chainhash.Hash
+	copy(header[:], headerBytes)
+	return header.String(), nil
+}
+
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the spent index is not enabled.
+	spentIndex := s.server.indexes().spentIndex
+	if spentIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetSpentInfoCmd)
+	txHash, err := chainhash.NewHashFromStr(c.Request.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Request.Txid)
+	}
+	outPoint := wire.NewOutPoint(txHash, c.Request.Index)
+
+	spend, err := spentIndex.SpendByOutPoint(outPoint)
+	if err != nil {
+		context := "Failed to load spent info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if spend != nil {
+		return &btcjson.GetSpentInfoResult{
+			Txid:   spend.TxHash.String(),
+			Index:  spend.Index,
+			Height: spend.Height,
+		}, nil
+	}
+
+	// Outputs which are not spent in the main chain may be spent by a
+	// transaction in the memory pool, which has no height yet.
+	if tx := s.server.txMemPool.CheckSpend(*outPoint); tx != nil {
+		for i, txIn := range tx.MsgTx().TxIn {
+			if txIn.PreviousOutPoint == *outPoint {
+				return &btcjson.GetSpentInfoResult{
+					Txid:  tx.Hash().String(),
+					Index: uint32(i),
+				}, nil
+			}
+		}
+	}
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCNoTxInfo,
+		Message: "Unable to get spent info",
+	}
+}
+
+// handleGetSupplyHistory implements the getsupplyhistory command.
+func handleGetSupplyHistory(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the supply index is not enabled.
+	supplyIndex := s.server.indexes().supplyIndex
+	if supplyIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Supply index must be enabled (--supplyindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetSupplyHistoryCmd)
+	if *c.End != 0 && *c.Start > *c.End {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	changes, err := supplyIndex.SupplyHistory(*c.Start, *c.End)
+	if err != nil {
+		context := "Failed to load supply history"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	results := make([]btcjson.SupplyChangeResult, 0, len(changes))
+	for _, change := range changes {
+		result := btcjson.SupplyChangeResult{
+			Txid:       change.TxHash.String(),
+			Height:     change.Height,
+			BlockIndex: change.TxIndex,
+			Type:       "issue",
+			Atoms:      change.Amount,
+			Supply:     change.Supply,
+			Keys:       make([]string, 0, len(change.Keys)),
+		}
+		if change.Amount < 0 {
+			result.Type = "destroy"
+			result.Atoms = -change.Amount
+		}
+		for _, key := range change.Keys {
+			result.Keys = append(result.Keys,
+				hex.EncodeToString(key.SerializeCompressed()))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleGetCirculatingSupply implements the getcirculatingsupply command.
+func handleGetCirculatingSupply(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// The current supply is part of the chain state, while the supply at
+	// a past height is only available from the supply index.
+	c := cmd.(*btcjson.GetCirculatingSupplyCmd)
+	best := s.chain.BestSnapshot()
+	if c.Height == nil {
+		return &btcjson.GetCirculatingSupplyResult{
+			Height: best.Height,
+			Supply: s.chain.TotalSupply(),
+		}, nil
+	}
+
+	supplyIndex := s.server.indexes().supplyIndex
+	if supplyIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Supply index must be enabled (--supplyindex)",
+		}
+	}
+	if *c.Height > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+	supply, err := supplyIndex.SupplyAtHeight(*c.Height)
+	if err != nil {
+		context := "Failed to load supply"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return &btcjson.GetCirculatingSupplyResult{
+		Height: *c.Height,
+		Supply: supply,
+	}, nil
+}
+
+// handleGetClusterInfo implements the getclusterinfo command.
+func handleGetClusterInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	cluster := s.server.cluster
+	if cluster == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Cluster mode must be enabled (--clusterrole)",
+		}
+	}
+
+	status := cluster.Status()
+	result := &btcjson.GetClusterInfoResult{
+		Role:       cluster.cfg.Role,
+		State:      status.State.String(),
+		Lockout:    int64(cluster.lockout() / time.Second),
+		PeerHeight: status.PeerHeight,
+	}
+	if !status.ActiveSince.IsZero() {
+		result.ActiveSince = status.ActiveSince.Unix()
+	}
+	if !status.LastPeer.IsZero() {
+		result.LastHeartbeat = status.LastPeer.Unix()
+		result.PeerPubKey = hex.EncodeToString(status.PeerKey[:])
+	}
+	return result, nil
+}
+
+// handleGetConnectionCount implements the getconnectioncount command.
+func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.server.ConnectedCount(), nil
+}
+
+// handleGetConsistencyStatus implements the getconsistencystatus command.
+func handleGetConsistencyStatus(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	status := s.server.consistencyChecker.Status()
+	result := &btcjson.GetConsistencyStatusResult{
+		Enabled:        status.Enabled,
+		Interval:       int64(status.Interval / time.Second),
+		HaltOnMismatch: status.HaltOnMismatch,
+		Runs:           status.Runs,
+		FailedRuns:     status.FailedRuns,
+		MismatchRuns:   status.MismatchRuns,
+	}
+	if status.Runs == 0 {
+		return result, nil
+	}
+
+	result.LastRun = status.LastRun.Unix()
+	result.LastDuration = status.LastDuration.Seconds()
+	if status.LastErr != nil {
+		result.LastError = status.LastErr.Error()
+	}
+	if report := status.LastReport; report != nil {
+		mismatches := report.Mismatches
+		if mismatches == nil {
+			mismatches = []string{}
+		}
+		result.LastCheck = &btcjson.ConsistencyCheckResult{
+			Hash:           report.Hash.String(),
+			Height:         report.Height,
+			KeyViewBlocks:  report.KeyViewBlocks,
+			UndoBlocks:     report.UndoBlocks,
+			SampledOutputs: report.SampledOutputs,
+			Consistent:     report.Consistent(),
+			Mismatches:     mismatches,
+		}
+	}
+	return result, nil
+}
+
+// handleGetCurrentNet implements the getcurrentnet command.
+func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.server.chainParams.Net, nil
+}
+
+// dbLatencyResult converts the passed latency histogram of a database
+// operation to the result returned by the getdbinfo command.
+func dbLatencyResult(h *database.LatencyHistogram) btcjson.DBLatencyResult {
+	return btcjson.DBLatencyResult{
+		Count:   h.Count,
+		AvgTime: int64(h.Mean() / time.Microsecond),
+		MaxTime: int64(h.Max / time.Microsecond),
+		Buckets: h.Buckets,
+	}
+}
+
+// handleGetDBInfo implements the getdbinfo command.
+func handleGetDBInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	maintainer, ok := s.server.db.(database.Maintainer)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The database does not report operation metrics",
+		}
+	}
+	stats, err := maintainer.Stats()
+	if err != nil {
+		context := "Failed to load database metrics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	latencyBuckets := make([]int64, 0, len(database.LatencyBuckets))
+	for _, bound := range database.LatencyBuckets {
+		latencyBuckets = append(latencyBuckets,
+			int64(bound/time.Microsecond))
+	}
+	return &btcjson.GetDBInfoResult{
+		MetadataReads:     stats.MetadataReads,
+		CacheHits:         stats.CacheHits,
+		CacheHitRate:      stats.CacheHitRate(),
+		MetadataWrites:    stats.MetadataWrites,
+		CacheFlushes:      stats.CacheFlushes,
+		FlushLatency:      dbLatencyResult(&stats.FlushLatency),
+		WriteStalls:       stats.WriteStalls,
+		WriteStallTime:    int64(stats.WriteStallTime / time.Microsecond),
+		StorageReadBytes:  stats.StorageReadBytes,
+		StorageWriteBytes: stats.StorageWriteBytes,
+		BlockReads:        stats.BlockReads,
+		BlockReadBytes:    stats.BlockReadBytes,
+		BlockReadLatency:  dbLatencyResult(&stats.BlockReadLatency),
+		BlockWrites:       stats.BlockWrites,
+		BlockWriteBytes:   stats.BlockWriteBytes,
+		BlockWriteLatency: dbLatencyResult(&stats.BlockWriteLatency),
+		LatencyBuckets:    latencyBuckets,
+	}, nil
+}
+
+// handleGetDifficulty implements the getdifficulty command.
+func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	return getDifficultyRatio(best.Bits), nil
+}
+
+// eventLogEntryResult returns the JSON object describing the passed event of
+// the event log.
+func eventLogEntryResult(e *eventlog.Event) btcjson.EventLogEntryResult {
+	result := btcjson.EventLogEntryResult{
+		Seq:    e.Seq,
+		Type:   e.Type.String(),
+		Time:   e.Time.Unix(),
+		Hash:   e.Hash.String(),
+		Height: e.Height,
+	}
+	if e.PubKey != nil {
+		result.KeySet = e.KeySet.String()
+		result.PubKey = hex.EncodeToString(e.PubKey.SerializeCompressed())
+		result.KeyID = uint32(e.KeyID)
+	}
+	return result
+}
+
+// handleGetEventLog implements the geteventlog command.
+func handleGetEventLog(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the event log is not enabled.
+	eventLog := s.server.eventLog
+	if eventLog == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Event log must be enabled (--eventlog)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetEventLogCmd)
+	if *c.Count < 0 || *c.Count > maxEventLogCount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Count must be between 0 and %d",
+				maxEventLogCount),
+		}
+	}
+
+	events, err := eventLog.Events(c.Cursor, *c.Count)
+	switch err {
+	case nil:
+	case eventlog.ErrCursorPruned, eventlog.ErrCursorUnknown:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCEventCursor,
+			Message: fmt.Sprintf("Cursor %d: %v", c.Cursor, err),
+		}
+	default:
+		context := "Failed to fetch events"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	first, last := eventLog.Bounds()
+	result := &btcjson.GetEventLogResult{
+		First:  first,
+		Last:   last,
+		Next:   c.Cursor,
+		Events: make([]btcjson.EventLogEntryResult, 0, len(events)),
+	}
+	for _, e := range events {
+		result.Events = append(result.Events, eventLogEntryResult(e))
+		result.Next = e.Seq
+	}
+	return result, nil
+}
+
+// handleGetGenerate implements the getgenerate command.
+func handleGetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.server.cpuMiner.IsMining(), nil
+}
+
+// handleGetHashCacheInfo implements the gethashcacheinfo command.
+func handleGetHashCacheInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	stats := s.server.hashCache.Stats()
+	return &btcjson.GetHashCacheInfoResult{
+		Entries:    uint64(stats.Entries),
+		MaxEntries: uint64(stats.MaxEntries),
+		Digests:    uint64(stats.Digests),
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		HitRate:    stats.HitRate(),
+	}, nil
+}
+
+// handleGetHashesPerSec implements the gethashespersec command.
+func handleGetHashesPerSec(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return int64(s.server.cpuMiner.HashesPerSecond()), nil
+}
+
+// handleGetHeaders implements the getheaders command.
+//
+// NOTE: This is a btcsuite extension ported from
+// github.com/decred/dcrd.
+func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetHeadersCmd)
+	blockLocators := make([]*chainhash.Hash, len(c.BlockLocators))
+	for i := range c.BlockLocators {
+		blockLocator, err := chainhash.NewHashFromStr(c.BlockLocators[i])
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Failed to decode block locator: " +
+					err.Error(),
+			}
+		}
+		blockLocators[i] = blockLocator
+	}
+	var hashStop chainhash.Hash
+	if c.HashStop != "" {
+		err := chainhash.Decode(&hashStop, c.HashStop)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Failed to decode hashstop: " + err.Error(),
+			}
+		}
+	}
+	blockHashes, err := s.server.locateBlocks(blockLocators, &hashStop)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDatabase,
+			Message: "Failed to fetch hashes of block " +
+				"headers: " + err.Error(),
+		}
+	}
+	blockHeaders, err := fetchHeaders(s.chain, blockHashes)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDatabase,
+			Message: "Failed to fetch headers of located blocks: " +
+				err.Error(),
+		}
+	}
+
+	hexBlockHeaders := make([]string, len(blockHeaders))
+	var buf bytes.Buffer
+	for i, h := range blockHeaders {
+		err := h.Serialize(&buf)
+		if err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Failed to serialize block header")
+		}
+		hexBlockHeaders[i] = hex.EncodeToString(buf.Bytes())
+		buf.Reset()
+	}
+	return hexBlockHeaders, nil
+}
+
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetIndexInfoCmd)
+	result := make(map[string]btcjson.IndexInfoResult)
+	statuses, err := s.server.indexManager.IndexStatuses()
+	if err != nil {
+		context := "Failed to load index info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for _, status := range statuses {
+		if c.IndexName != nil && *c.IndexName != status.Name {
+			continue
+		}
+		info := btcjson.IndexInfoResult{
+			Synced:          status.Synced,
+			BestBlockHeight: status.Height,
+			SizeOnDisk:      status.Size,
+			Health:          "ok",
+		}
+		switch {
+		case status.Err != nil:
+			info.Health = "failed"
+			info.Error = status.Err.Error()
+		case !status.Synced:
+			info.Health = "syncing"
+		}
+		result[status.Name] = info
+	}
+	return result, nil
+}
+
+// handleGetInfo implements the getinfo command. We only return the fields
+// that are not related to wallet functionality.
+func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	ret := &btcjson.InfoChainResult{
+		Version:         int32(1000000*appMajor + 10000*appMinor + 100*appPatch),
+		ProtocolVersion: int32(maxProtocolVersion),
+		Blocks:          best.Height,
+		TimeOffset:      int64(s.server.timeSource.Offset().Seconds()),
+		Connections:     s.server.ConnectedCount(),
+		Proxy:           cfg.Proxy,
+		Difficulty:      getDifficultyRatio(best.Bits),
+		TestNet:         cfg.TestNet,
+		RelayFee:        s.server.txMemPool.Policy().MinRelayTxFee.ToRMG(),
+	}
+
+	return ret, nil
+}
+
+// handleGetKeyIDBalance implements the getkeyidbalance command.
+func handleGetKeyIDBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the keyID balance index is not enabled.
+	keyIDBalIndex := s.server.indexes().keyIDBalanceIndex
+	if keyIDBalIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "KeyID balance index must be enabled (--keyidbalanceindex)",
+		}
+	}
+
+	// The balance is the current one unless a past height is requested.
+	c := cmd.(*btcjson.GetKeyIDBalanceCmd)
+	height := s.chain.BestSnapshot().Height
+	if c.Height != nil {
+		if *c.Height > height {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCOutOfRange,
+				Message: "Block number out of range",
+			}
+		}
+		height = *c.Height
+	}
+
+	keyID := btcec.KeyID(c.KeyID)
+	balance, err := keyIDBalIndex.BalanceForKeyID(keyID, height)
+	if err != nil {
+		context := "Failed to load keyID balance"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return &btcjson.GetKeyIDBalanceResult{
+		KeyID:   c.KeyID,
+		Height:  height,
+		Balance: balance,
+	}, nil
+}
+
+// velocityTracker returns the tracker of the keyID velocity limits, or an
+// error suitable for use in replies when no limits are configured.
+func velocityTracker(s *rpcServer) (*mempool.VelocityTracker, error) {
+	if s.server.velocity == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "KeyID velocity limits must be configured (--keyidvelocitylimit)",
+		}
+	}
+	return s.server.velocity, nil
+}
+
+// handleGetKeyIDVelocity implements the getkeyidvelocity command.
+func handleGetKeyIDVelocity(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetKeyIDVelocityCmd)
+	velocity, err := velocityTracker(s)
+	if err != nil {
+		return nil, err
+	}
+
+	status, until := velocity.Status(btcec.KeyID(c.KeyID))
+	result := &btcjson.GetKeyIDVelocityResult{
+		KeyID:   c.KeyID,
+		Windows: make([]btcjson.KeyIDVelocityWindowResult, 0, len(status)),
+	}
+	for _, window := range status {
+		remaining := window.MaxAmount - window.Spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		result.Windows = append(result.Windows,
+			btcjson.KeyIDVelocityWindowResult{
+				Window:    int64(window.Window / time.Second),
+				MaxAmount: window.MaxAmount.ToRMG(),
+				Spent:     window.Spent.ToRMG(),
+				Remaining: remaining.ToRMG(),
+			})
+	}
+	if !until.IsZero() {
+		result.OverrideUntil = until.Unix()
+	}
+	return result, nil
+}
+
+// handleSetKeyIDVelocityOverride implements the setkeyidvelocityoverride
+// command.
+func handleSetKeyIDVelocityOverride(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetKeyIDVelocityOverrideCmd)
+	velocity, err := velocityTracker(s)
+	if err != nil {
+		return nil, err
+	}
+	if c.Duration < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The duration may not be negative",
+		}
+	}
+
+	keyID := btcec.KeyID(c.KeyID)
+	if c.Duration == 0 {
+		velocity.SetOverride(keyID, time.Time{})
+		rpcsLog.Infof("Restored the velocity limits of keyID %v", keyID)
+		return nil, nil
+	}
+	until := time.Now().Add(time.Duration(c.Duration) * time.Second)
+	velocity.SetOverride(keyID, until)
+	rpcsLog.Infof("Lifted the velocity limits of keyID %v until %v", keyID,
+		until)
+	return nil, nil
+}
+
+// handleGetMempoolInfo implements the getmempoolinfo command.
+func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	mempoolTxns := s.server.txMemPool.TxDescs()
+
+	var numBytes int64
+	for _, txD := range mempoolTxns {
+		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
+	}
+
+	ret := &btcjson.GetMempoolInfoResult{
+		Size:  int64(len(mempoolTxns)),
+		Bytes: numBytes,
+	}
+
+	return ret, nil
+}
+
+// handleGetMiningInfo implements the getmininginfo command. We only return the
+// fields that are not related to wallet functionality.
+func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Create a default getnetworkhashps command to use defaults and make
+	// use of the existing getnetworkhashps handler.
+	gnhpsCmd := btcjson.NewGetNetworkHashPSCmd(nil, nil)
+	networkHashesPerSecIface, err := handleGetNetworkHashPS(s, gnhpsCmd,
+		closeChan)
+	if err != nil {
+		return nil, err
+	}
+	networkHashesPerSec, ok := networkHashesPerSecIface.(int64)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "networkHashesPerSec is not an int64",
+		}
+	}
+
+	best := s.chain.BestSnapshot()
+	result := btcjson.GetMiningInfoResult{
+		Blocks:           int64(best.Height),
+		CurrentBlockSize: best.BlockSize,
+		CurrentBlockTx:   best.NumTxns,
+		Difficulty:       getDifficultyRatio(best.Bits),
+		Generate:         s.server.cpuMiner.IsMining(),
+		GenProcLimit:     s.server.cpuMiner.NumWorkers(),
+		HashesPerSec:     int64(s.server.cpuMiner.HashesPerSecond()),
+		NetworkHashPS:    networkHashesPerSec,
+		PooledTx:         uint64(s.server.txMemPool.Count()),
+		TestNet:          cfg.TestNet,
+	}
+	return &result, nil
+}
+
+// handleGetNetTotals implements the getnettotals command.
+func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	totalBytesRecv, totalBytesSent := s.server.NetTotals()
+	reply := &btcjson.GetNetTotalsResult{
+		TotalBytesRecv: totalBytesRecv,
+		TotalBytesSent: totalBytesSent,
+		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+	}
+	return reply, nil
+}
+
+// handleGetNetworkHashPS implements the getnetworkhashps command.
+func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Note: All valid error return paths should return an int64.
+	// Literal zeros are inferred as int, and won't coerce to int64
+	// because the return value is an interface{}.
+
+	c := cmd.(*btcjson.GetNetworkHashPSCmd)
+
+	// When the passed height is too high or zero, just return 0 now
+	// since we can't reasonably calculate the number of network hashes
+	// per second from invalid values.  When it's negative, use the current
+	// best block height.
+	best := s.chain.BestSnapshot()
+	endHeight := int32(-1)
+	if c.Height != nil {
+		endHeight = int32(*c.Height)
+	}
+	if endHeight > int32(best.Height) || endHeight == 0 {
+		return int64(0), nil
+	}
+	if endHeight < 0 {
+		endHeight = int32(best.Height)
+	}
+
+	// Calculate the number of blocks per retarget interval based on the
+	// chain parameters.
+	blocksPerRetarget := int32(s.server.chainParams.PowAveragingWindow)
+
+	// Calculate the starting block height based on the passed number of
+	// blocks.  When the passed value is negative, use the last block the
+	// difficulty changed as the starting height.  Also make sure the
+	// starting height is not before the beginning of the chain.
+	// TODO(prova): adjust this calculation for the rolling difficulty avg.
+	numBlocks := int32(120)
+	if c.Blocks != nil {
+		numBlocks = int32(*c.Blocks)
+	}
+	var startHeight int32
+	if numBlocks <= 0 {
+		startHeight = endHeight - ((endHeight % blocksPerRetarget) + 1)
+	} else {
+		startHeight = endHeight - numBlocks
+	}
+	if startHeight < 0 {
+		startHeight = 0
+	}
+	rpcsLog.Debugf("Calculating network hashes per second from %d to %d",
+		startHeight, endHeight)
+
+	// Find the min and max block timestamps as well as calculate the total
+	// amount of work that happened between the start and end blocks.
+	var minTimestamp, maxTimestamp time.Time
+	totalWork := big.NewInt(0)
+	for curHeight := startHeight; curHeight <= endHeight; curHeight++ {
+		hash, err := s.chain.BlockHashByHeight(uint32(curHeight))
+		if err != nil {
+			context := "Failed to fetch block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// Fetch the header from chain.
+		header, err := s.chain.FetchHeader(hash)
+		if err != nil {
+			context := "Failed to fetch block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		if curHeight == startHeight {
+			minTimestamp = header.Timestamp
+			maxTimestamp = minTimestamp
+		} else {
+			totalWork.Add(totalWork, blockchain.CalcWork(header.Bits))
+
+			if minTimestamp.After(header.Timestamp) {
+				minTimestamp = header.Timestamp
+			}
+			if maxTimestamp.Before(header.Timestamp) {
+				maxTimestamp = header.Timestamp
+			}
+		}
+	}
+
+	// Calculate the difference in seconds between the min and max block
+	// timestamps and avoid division by zero in the case where there is no
+	// time difference.
+	timeDiff := int64(maxTimestamp.Sub(minTimestamp) / time.Second)
+	if timeDiff == 0 {
+		return int64(0), nil
+	}
+
+	hashesPerSec := new(big.Int).Div(totalWork, big.NewInt(timeDiff))
+	return hashesPerSec.Int64(), nil
 }
 
-// handleGetBlockTemplateProposal is a helper for handleGetBlockTemplate which
-// deals with block proposals.
-//
-// See https://en.bitcoin.it/wiki/BIP_0023 for more details.
-func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateRequest) (interface{}, error) {
-	hexData := request.Data
-	if hexData == "" {
-		return false, &btcjson.RPCError{
-			Code: btcjson.ErrRPCType,
-			Message: fmt.Sprintf("Data must contain the " +
-				"hex-encoded serialized block that is being " +
-				"proposed"),
+// handleGetOrphanBlocks implements the getorphanblocks command.
+func handleGetOrphanBlocks(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	orphans := s.chain.OrphanBlocks()
+	results := make([]btcjson.OrphanBlockResult, 0, len(orphans))
+	for _, orphan := range orphans {
+		results = append(results, btcjson.OrphanBlockResult{
+			Hash:             orphan.Hash.String(),
+			PreviousHash:     orphan.PrevHash.String(),
+			Height:           orphan.Height,
+			Size:             int32(orphan.Size),
+			Root:             orphan.Root.String(),
+			MissingBlockHash: orphan.Missing.String(),
+			Received:         orphan.Received.Unix(),
+			Expires:          orphan.Expiration.Unix(),
+		})
+	}
+	return results, nil
+}
+
+// handleGetPeerInfo implements the getpeerinfo command.
+func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	peers := s.server.Peers()
+	syncPeer := s.server.blockManager.SyncPeer()
+	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
+	for _, p := range peers {
+		statsSnap := p.StatsSnapshot()
+		info := &btcjson.GetPeerInfoResult{
+			ID:             statsSnap.ID,
+			Addr:           statsSnap.Addr,
+			AddrLocal:      p.LocalAddr().String(),
+			Services:       fmt.Sprintf("%08d", uint64(statsSnap.Services)),
+			RelayTxes:      !p.disableRelayTx,
+			LastSend:       statsSnap.LastSend.Unix(),
+			LastRecv:       statsSnap.LastRecv.Unix(),
+			BytesSent:      statsSnap.BytesSent,
+			BytesRecv:      statsSnap.BytesRecv,
+			SendRate:       statsSnap.SendRate,
+			RecvRate:       statsSnap.RecvRate,
+			SendLimit:      statsSnap.SendLimit,
+			RecvLimit:      statsSnap.RecvLimit,
+			ConnTime:       statsSnap.ConnTime.Unix(),
+			PingTime:       float64(statsSnap.LastPingMicros),
+			TimeOffset:     statsSnap.TimeOffset,
+			Version:        statsSnap.Version,
+			SubVer:         statsSnap.UserAgent,
+			Inbound:        statsSnap.Inbound,
+			StartingHeight: statsSnap.StartingHeight,
+			CurrentHeight:  statsSnap.LastBlock,
+			BanScore:       int32(p.banScore.Int()),
+			FeeFilter:      atomic.LoadInt64(&p.feeFilter),
+			SyncNode:       p == syncPeer,
+			BytesSentMsg:   statsSnap.BytesSentMsg,
+			BytesRecvMsg:   statsSnap.BytesRecvMsg,
+			PingHistory:    make([]float64, len(statsSnap.PingHistory)),
+			PingVariance:   pingVariance(statsSnap.PingHistory),
+			BlocksRecv:     atomic.LoadUint64(&p.blocksRecv),
+			BlocksAccepted: atomic.LoadUint64(&p.blocksAccepted),
+			TxsRecv:        atomic.LoadUint64(&p.txsRecv),
+			TxsAccepted:    atomic.LoadUint64(&p.txsAccepted),
+			SendHeaders:    p.WantsHeaders(),
+			CmpctBlocks:    p.SupportsCompactBlocks(),
+			CmpctBlocksHB:  p.WantsCompactBlocks(),
+			AddrV2:         p.WantsAddrV2(),
+			Validator:      p.isFederationMember(),
+			FedMember:      p.fedMember,
+		}
+		for i, micros := range statsSnap.PingHistory {
+			info.PingHistory[i] = float64(micros)
+		}
+		if p.LastPingNonce() != 0 {
+			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
+			// We actually want microseconds.
+			info.PingWait = wait / 1000
 		}
+		infos = append(infos, info)
 	}
+	return infos, nil
+}
 
-	// Ensure the provided data is sane and deserialize the proposed block.
-	if len(hexData)%2 != 0 {
-		hexData = "0" + hexData
+// pingVariance returns the variance of the passed ping times.
+func pingVariance(pings []int64) float64 {
+	if len(pings) == 0 {
+		return 0
 	}
-	dataBytes, err := hex.DecodeString(hexData)
+	var sum float64
+	for _, ping := range pings {
+		sum += float64(ping)
+	}
+	mean := sum / float64(len(pings))
+	var variance float64
+	for _, ping := range pings {
+		d := float64(ping) - mean
+		variance += d * d
+	}
+	return variance / float64(len(pings))
+}
+
+// handleGetRawMempool implements the getrawmempool command.
+func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetRawMempoolCmd)
+	mp := s.server.txMemPool
+
+	if c.Verbose != nil && *c.Verbose {
+		return mp.RawMempoolVerbose(), nil
+	}
+
+	// The response is simply an array of the transaction hashes if the
+	// verbose flag is not set.
+	descs := mp.TxDescs()
+	hashStrings := make([]string, len(descs))
+	for i := range hashStrings {
+		hashStrings[i] = descs[i].Tx.Hash().String()
+	}
+
+	return hashStrings, nil
+}
+
+// handleGetRawTransaction implements the getrawtransaction command.
+func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetRawTransactionCmd)
+
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
 	if err != nil {
-		return false, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDeserialization,
-			Message: fmt.Sprintf("Data must be "+
-				"hexadecimal string (not %q)", hexData),
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	verbose := false
+	if c.Verbose != nil {
+		verbose = *c.Verbose != 0
+	}
+
+	// Try to fetch the transaction from the memory pool and if that fails,
+	// try the block database.
+	var mtx *wire.MsgTx
+	var blkHash *chainhash.Hash
+	var blkHeight uint32
+	tx, err := s.server.txMemPool.FetchTransaction(txHash)
+	if err != nil {
+		txIndex := s.server.indexes().txIndex
+		if txIndex == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: "The transaction index must be " +
+					"enabled to query the blockchain " +
+					"(specify --txindex)",
+			}
+		}
+
+		// Look up the location of the transaction.
+		blockRegion, err := txIndex.TxBlockRegion(txHash)
+		if err != nil {
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// Load the raw transaction bytes from the database.
+		var txBytes []byte
+		err = s.server.db.View(func(dbTx database.Tx) error {
+			var err error
+			txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+			return err
+		})
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// When the verbose flag isn't set, simply return the serialized
+		// transaction as a hex-encoded string.  This is done here to
+		// avoid deserializing it only to reserialize it again later.
+		if !verbose {
+			return hex.EncodeToString(txBytes), nil
+		}
+
+		// Grab the block height.
+		blkHash = blockRegion.Hash
+		blkHeight, err = s.chain.BlockHeightByHash(blkHash)
+		if err != nil {
+			context := "Failed to retrieve block height"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// Deserialize the transaction
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(txBytes))
+		if err != nil {
+			context := "Failed to deserialize transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		mtx = &msgTx
+	} else {
+		// When the verbose flag isn't set, simply return the
+		// network-serialized transaction as a hex-encoded string.
+		if !verbose {
+			// Note that this is intentionally not directly
+			// returning because the first return value is a
+			// string and it would result in returning an empty
+			// string to the client instead of nothing (nil) in the
+			// case of an error.
+			mtxHex, err := messageToHex(tx.MsgTx())
+			if err != nil {
+				return nil, err
+			}
+			return mtxHex, nil
 		}
+
+		mtx = tx.MsgTx()
 	}
-	var msgBlock wire.MsgBlock
-	if err := msgBlock.Deserialize(bytes.NewReader(dataBytes)); err != nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDeserialization,
-			Message: "Block decode failed: " + err.Error(),
+
+	// The verbose flag is set, so generate the JSON object and return it.
+	var blkHeader *wire.BlockHeader
+	var blkHashStr string
+	var chainHeight uint32
+	if blkHash != nil {
+		// Fetch the header from chain.
+		header, err := s.chain.FetchHeader(blkHash)
+		if err != nil {
+			context := "Failed to fetch block header"
+			return nil, internalRPCError(err.Error(), context)
 		}
+
+		blkHeader = &header
+		blkHashStr = blkHash.String()
+		chainHeight = s.chain.BestSnapshot().Height
 	}
-	block := provautil.NewBlock(&msgBlock)
 
-	// Ensure the block is building from the expected previous block.
-	expectedPrevHash := s.server.blockManager.chain.BestSnapshot().Hash
-	prevHash := &block.MsgBlock().Header.PrevBlock
-	if expectedPrevHash == nil || !expectedPrevHash.IsEqual(prevHash) {
-		return "bad-prevblk", nil
+	// Include the previous outputs spent by the inputs when requested.
+	if c.VinExtra != nil && *c.VinExtra != 0 {
+		var originOutputs map[wire.OutPoint]wire.TxOut
+		if !blockchain.IsCoinBaseTx(mtx) {
+			originOutputs, err = fetchPrevOuts(s, mtx, blkHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		mtxHex, err := messageToHex(mtx)
+		if err != nil {
+			return nil, err
+		}
+		result := btcjson.SearchRawTransactionsResult{
+			Hex:      mtxHex,
+			Txid:     txHash.String(),
+			Version:  mtx.Version,
+			LockTime: mtx.LockTime,
+			Vin: createVinListOriginOutputs(mtx,
+				s.server.chainParams, originOutputs, true, nil),
+			Vout: createVoutList(mtx, s.server.chainParams, nil),
+		}
+		if blkHeader != nil {
+			result.Time = blkHeader.Timestamp.Unix()
+			result.Blocktime = blkHeader.Timestamp.Unix()
+			result.BlockHash = blkHashStr
+			result.Confirmations = uint64(1 + chainHeight - blkHeight)
+		}
+		return result, nil
 	}
 
-	flags := blockchain.BFDryRun | blockchain.BFNoPoWCheck
-	isOrphan, err := s.server.blockManager.ProcessBlock(block, flags)
+	rawTxn, err := createTxRawResult(s.server.chainParams, mtx,
+		txHash.String(), blkHeader, blkHashStr, blkHeight, chainHeight)
 	if err != nil {
-		if _, ok := err.(blockchain.RuleError); !ok {
-			err := rpcsLog.Errorf("Failed to process block "+
-				"proposal: %v", err)
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCVerify,
-				Message: err.Error(),
+		return nil, err
+	}
+	return *rawTxn, nil
+}
+
+// fetchPrevOuts returns the outputs spent by the inputs of the passed
+// transaction.  The outputs spent by a transaction of the main chain, which is
+// identified by the hash of its block, are loaded from the spend journal of
+// the block, while those spent by an unconfirmed transaction are loaded from
+// the memory pool and the utxo set.  The transaction index is used for any
+// outputs which are not available from those.
+func fetchPrevOuts(s *rpcServer, mtx *wire.MsgTx, blkHash *chainhash.Hash) (map[wire.OutPoint]wire.TxOut, error) {
+	originOutputs := make(map[wire.OutPoint]wire.TxOut, len(mtx.TxIn))
+	if blkHash != nil {
+		spentTxOuts, err := fetchBlockSpentTxOuts(s, blkHash)
+		if err != nil {
+			rpcsLog.Debugf("Failed to load the spent outputs of "+
+				"block %v: %v", blkHash, err)
+		}
+		for _, txIn := range mtx.TxIn {
+			origin := txIn.PreviousOutPoint
+			if txOut, ok := spentTxOuts[origin]; ok {
+				originOutputs[origin] = txOut
 			}
 		}
+	} else {
+		mp := s.server.txMemPool
+		for _, txIn := range mtx.TxIn {
+			origin := &txIn.PreviousOutPoint
+			originTx, err := mp.FetchTransaction(&origin.Hash)
+			if err == nil {
+				txOuts := originTx.MsgTx().TxOut
+				if origin.Index < uint32(len(txOuts)) {
+					originOutputs[*origin] = *txOuts[origin.Index]
+				}
+				continue
+			}
 
-		rpcsLog.Infof("Rejected block proposal: %v", err)
-		return chainErrToGBTErrString(err), nil
-	}
-	if isOrphan {
-		return "orphan", nil
+			entry, err := s.chain.FetchUtxoEntry(&origin.Hash)
+			if err != nil {
+				context := "Failed to fetch utxo entry"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if entry == nil || entry.IsOutputSpent(origin.Index) {
+				continue
+			}
+			originOutputs[*origin] = wire.TxOut{
+				Value:    entry.AmountByIndex(origin.Index),
+				PkScript: entry.PkScriptByIndex(origin.Index),
+			}
+		}
 	}
 
-	return nil, nil
+	// Fall back to the transaction index for any outputs which are still
+	// missing.
+	if len(originOutputs) == len(mtx.TxIn) || s.server.indexes().txIndex == nil {
+		return originOutputs, nil
+	}
+	return fetchInputTxos(s, mtx)
 }
 
-// handleGetBlockTemplate implements the getblocktemplate command.
-//
-// See https://en.bitcoin.it/wiki/BIP_0022 and
-// https://en.bitcoin.it/wiki/BIP_0023 for more details.
-func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetBlockTemplateCmd)
-	request := c.Request
-
-	// Set the default mode and override it if supplied.
-	mode := "template"
-	if request != nil && request.Mode != "" {
-		mode = request.Mode
+// fetchBlockSpentTxOuts returns the outputs spent by the transactions of the
+// main chain block with the passed hash mapped by the outpoints referencing
+// them.
+func fetchBlockSpentTxOuts(s *rpcServer, blkHash *chainhash.Hash) (map[wire.OutPoint]wire.TxOut, error) {
+	block, err := s.chain.BlockByHash(blkHash)
+	if err != nil {
+		return nil, err
 	}
-
-	switch mode {
-	case "template":
-		return handleGetBlockTemplateRequest(s, request, closeChan)
-	case "proposal":
-		return handleGetBlockTemplateProposal(s, request)
+	spentTxOuts, err := s.chain.FetchSpentTxOuts(block)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, &btcjson.RPCError{
-		Code:    btcjson.ErrRPCInvalidParameter,
-		Message: "Invalid mode",
+	originOutputs := make(map[wire.OutPoint]wire.TxOut, len(spentTxOuts))
+	var spentIdx int
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			originOutputs[txIn.PreviousOutPoint] = *spentTxOuts[spentIdx]
+			spentIdx++
+		}
 	}
+	return originOutputs, nil
 }
 
-// handleGetConnectionCount implements the getconnectioncount command.
-func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	return s.server.ConnectedCount(), nil
-}
-
-// handleGetCurrentNet implements the getcurrentnet command.
-func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	return s.server.chainParams.Net, nil
-}
-
-// handleGetDifficulty implements the getdifficulty command.
-func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.chain.BestSnapshot()
-	return getDifficultyRatio(best.Bits), nil
+// handleGetRPCInfo implements the getrpcinfo command.
+func handleGetRPCInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.metrics.info(), nil
 }
 
-// handleGetGenerate implements the getgenerate command.
-func handleGetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	return s.server.cpuMiner.IsMining(), nil
-}
+// handleGetTxOut handles gettxout commands.
+func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutCmd)
 
-// handleGetHashesPerSec implements the gethashespersec command.
-func handleGetHashesPerSec(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	return int64(s.server.cpuMiner.HashesPerSecond()), nil
-}
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
 
-// handleGetHeaders implements the getheaders command.
-//
-// NOTE: This is a btcsuite extension ported from
-// github.com/decred/dcrd.
-func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetHeadersCmd)
-	blockLocators := make([]*chainhash.Hash, len(c.BlockLocators))
-	for i := range c.BlockLocators {
-		blockLocator, err := chainhash.NewHashFromStr(c.BlockLocators[i])
+	// If requested and the tx is available in the mempool try to fetch it
+	// from there, otherwise attempt to fetch from the block database.
+	var bestBlockHash string
+	var confirmations uint32
+	var txVersion int32
+	var value int64
+	var pkScript []byte
+	var isCoinbase bool
+	includeMempool := true
+	if c.IncludeMempool != nil {
+		includeMempool = *c.IncludeMempool
+	}
+	// TODO: This is racy.  It should attempt to fetch it directly and check
+	// the error.
+	if includeMempool && s.server.txMemPool.HaveTransaction(txHash) {
+		tx, err := s.server.txMemPool.FetchTransaction(txHash)
 		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		mtx := tx.MsgTx()
+		if c.Vout > uint32(len(mtx.TxOut)-1) {
 			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInvalidParameter,
-				Message: "Failed to decode block locator: " +
-					err.Error(),
+				Code: btcjson.ErrRPCInvalidTxVout,
+				Message: "Output index number (vout) does not " +
+					"exist for transaction.",
 			}
 		}
-		blockLocators[i] = blockLocator
+
+		txOut := mtx.TxOut[c.Vout]
+		if txOut == nil {
+			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
+				"does not exist", c.Vout, txHash)
+			return nil, internalRPCError(errStr, "")
+		}
+
+		best := s.chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 0
+		txVersion = mtx.Version
+		value = txOut.Value
+		pkScript = txOut.PkScript
+		isCoinbase = blockchain.IsCoinBaseTx(mtx)
+	} else {
+		entry, err := s.chain.FetchUtxoEntry(txHash)
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// To match the behavior of the reference client, return nil
+		// (JSON null) if the transaction output is spent by another
+		// transaction already in the main chain.  Mined transactions
+		// that are spent by a mempool transaction are not affected by
+		// this.
+		if entry == nil || entry.IsOutputSpent(c.Vout) {
+			return nil, nil
+		}
+
+		best := s.chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 1 + best.Height - entry.BlockHeight()
+		txVersion = entry.Version()
+		value = entry.AmountByIndex(c.Vout)
+		pkScript = entry.PkScriptByIndex(c.Vout)
+		isCoinbase = entry.IsCoinBase()
 	}
-	var hashStop chainhash.Hash
-	if c.HashStop != "" {
-		err := chainhash.Decode(&hashStop, c.HashStop)
+
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         provautil.Amount(value).ToRMG(),
+		Version:       txVersion,
+		ScriptPubKey:  createScriptPubKeyResult(pkScript, s.server.chainParams),
+		Coinbase:      isCoinbase,
+	}
+	return txOutReply, nil
+}
+
+// txBlockHash returns the hash of the main chain block containing the
+// transaction with the passed hash.  It is looked up with the transaction index
+// when enabled, and otherwise in the utxo set, which only knows the
+// transactions with unspent outputs.
+func txBlockHash(s *rpcServer, txHash *chainhash.Hash) (*chainhash.Hash, error) {
+	if txIndex := s.server.indexes().txIndex; txIndex != nil {
+		blockRegion, err := txIndex.TxBlockRegion(txHash)
 		if err != nil {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCInvalidParameter,
-				Message: "Failed to decode hashstop: " + err.Error(),
-			}
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, rpcNoTxInfoError(txHash)
 		}
+		return blockRegion.Hash, nil
 	}
-	blockHashes, err := s.server.locateBlocks(blockLocators, &hashStop)
+
+	entry, err := s.chain.FetchUtxoEntry(txHash)
 	if err != nil {
+		context := "Failed to fetch utxo entry"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if entry == nil || entry.IsFullySpent() {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDatabase,
-			Message: "Failed to fetch hashes of block " +
-				"headers: " + err.Error(),
+			Code: btcjson.ErrRPCNoTxInfo,
+			Message: "Transaction not found in the utxo set, the " +
+				"block hash must be passed or the transaction " +
+				"index enabled (--txindex)",
 		}
 	}
-	blockHeaders, err := fetchHeaders(s.chain, blockHashes)
+	blkHash, err := s.chain.BlockHashByHeight(entry.BlockHeight())
 	if err != nil {
+		context := "Failed to fetch block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return blkHash, nil
+}
+
+// handleGetTxOutProof implements the gettxoutproof command.  The proof is a
+// serialized merkleblock message whose signed header commits to the passed
+// transactions, so their inclusion can be verified with only the header chain.
+func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutProofCmd)
+
+	if len(c.TxIDs) == 0 {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDatabase,
-			Message: "Failed to fetch headers of located blocks: " +
-				err.Error(),
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No transaction hashes passed",
 		}
 	}
-
-	hexBlockHeaders := make([]string, len(blockHeaders))
-	var buf bytes.Buffer
-	for i, h := range blockHeaders {
-		err := h.Serialize(&buf)
+	txHashes := make([]*chainhash.Hash, 0, len(c.TxIDs))
+	seen := make(map[chainhash.Hash]struct{}, len(c.TxIDs))
+	for _, txid := range c.TxIDs {
+		txHash, err := chainhash.NewHashFromStr(txid)
 		if err != nil {
-			return nil, internalRPCError(err.Error(),
-				"Failed to serialize block header")
+			return nil, rpcDecodeHexError(txid)
 		}
-		hexBlockHeaders[i] = hex.EncodeToString(buf.Bytes())
-		buf.Reset()
+		if _, ok := seen[*txHash]; ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Duplicate transaction hash " + txid,
+			}
+		}
+		seen[*txHash] = struct{}{}
+		txHashes = append(txHashes, txHash)
 	}
-	return hexBlockHeaders, nil
-}
 
-// handleGetInfo implements the getinfo command. We only return the fields
-// that are not related to wallet functionality.
-func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	best := s.chain.BestSnapshot()
-	ret := &btcjson.InfoChainResult{
-		Version:         int32(1000000*appMajor + 10000*appMinor + 100*appPatch),
-		ProtocolVersion: int32(maxProtocolVersion),
-		Blocks:          best.Height,
-		TimeOffset:      int64(s.server.timeSource.Offset().Seconds()),
-		Connections:     s.server.ConnectedCount(),
-		Proxy:           cfg.Proxy,
-		Difficulty:      getDifficultyRatio(best.Bits),
-		TestNet:         cfg.TestNet,
-		RelayFee:        cfg.minRelayTxFee.ToRMG(),
+	// Load the block, which is the one containing the first transaction
+	// when it isn't passed.
+	var blkHash *chainhash.Hash
+	var err error
+	if c.BlockHash != nil {
+		blkHash, err = chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+	} else {
+		blkHash, err = txBlockHash(s, txHashes[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	block, err := s.chain.BlockByHash(blkHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
 	}
 
-	return ret, nil
+	merkleBlock, matched := bloom.NewMerkleBlockWithTxs(block, txHashes)
+	if len(matched) != len(txHashes) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Not all transactions found in block " + blkHash.String(),
+		}
+	}
+	return messageToHex(merkleBlock)
 }
 
-// handleGetMempoolInfo implements the getmempoolinfo command.
-func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	mempoolTxns := s.server.txMemPool.TxDescs()
-
-	var numBytes int64
-	for _, txD := range mempoolTxns {
-		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
-	}
+// createScriptPubKeyResult returns the JSON description of the passed public key
+// script of an unspent transaction output.
+func createScriptPubKeyResult(pkScript []byte, chainParams *chaincfg.Params) btcjson.ScriptPubKeyResult {
+	// Disassemble script into single line printable format.
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
 
-	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+	// Get further info about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		chainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
 	}
 
-	return ret, nil
+	return btcjson.ScriptPubKeyResult{
+		Asm:       disbuf,
+		Hex:       hex.EncodeToString(pkScript),
+		ReqSigs:   int32(reqSigs),
+		Type:      scriptClass.String(),
+		Addresses: addresses,
+	}
 }
 
-// handleGetMiningInfo implements the getmininginfo command. We only return the
-// fields that are not related to wallet functionality.
-func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Create a default getnetworkhashps command to use defaults and make
-	// use of the existing getnetworkhashps handler.
-	gnhpsCmd := btcjson.NewGetNetworkHashPSCmd(nil, nil)
-	networkHashesPerSecIface, err := handleGetNetworkHashPS(s, gnhpsCmd,
-		closeChan)
-	if err != nil {
-		return nil, err
-	}
-	networkHashesPerSec, ok := networkHashesPerSecIface.(int64)
-	if !ok {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
-			Message: "networkHashesPerSec is not an int64",
+// handleGetValidatorHeartbeats implements the getvalidatorheartbeats command.
+func handleGetValidatorHeartbeats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	heartbeats := s.server.heartbeatManager.Heartbeats()
+
+	localKeys := make(map[wire.BlockValidatingPubKey]struct{})
+	for _, key := range s.server.cpuMiner.ValidateKeys() {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], key.PubKey().SerializeCompressed())
+		localKeys[pubKey] = struct{}{}
+	}
+
+	now := time.Now()
+	newResult := func(pubKey wire.BlockValidatingPubKey, active bool) btcjson.ValidatorHeartbeatResult {
+		_, local := localKeys[pubKey]
+		result := btcjson.ValidatorHeartbeatResult{
+			PubKey: hex.EncodeToString(pubKey[:]),
+			Active: active,
+			Local:  local,
+		}
+		var last *validatorHeartbeat
+		if heartbeat, ok := heartbeats[pubKey]; ok {
+			last = &heartbeat
+			result.LastHeartbeat = &btcjson.HeartbeatResult{
+				Height:   heartbeat.Msg.Height,
+				Hash:     heartbeat.Msg.BlockHash.String(),
+				Time:     heartbeat.Msg.Timestamp.Unix(),
+				Received: heartbeat.Received.Unix(),
+				From:     heartbeat.From,
+			}
 		}
-	}
 
-	best := s.chain.BestSnapshot()
-	result := btcjson.GetMiningInfoResult{
-		Blocks:           int64(best.Height),
-		CurrentBlockSize: best.BlockSize,
-		CurrentBlockTx:   best.NumTxns,
-		Difficulty:       getDifficultyRatio(best.Bits),
-		Generate:         s.server.cpuMiner.IsMining(),
-		GenProcLimit:     s.server.cpuMiner.NumWorkers(),
-		HashesPerSec:     int64(s.server.cpuMiner.HashesPerSecond()),
-		NetworkHashPS:    networkHashesPerSec,
-		PooledTx:         uint64(s.server.txMemPool.Count()),
-		TestNet:          cfg.TestNet,
+		// Only active keys are expected to send heartbeats.
+		result.Stalled = active && heartbeatStalled(last, now)
+		return result
 	}
-	return &result, nil
-}
 
-// handleGetNetTotals implements the getnettotals command.
-func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	totalBytesRecv, totalBytesSent := s.server.NetTotals()
-	reply := &btcjson.GetNetTotalsResult{
-		TotalBytesRecv: totalBytesRecv,
-		TotalBytesSent: totalBytesSent,
-		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+	// Report the active validate keys first, followed by any key which is
+	// no longer part of the validate key set but sent a heartbeat.
+	validateKeys := s.chain.AdminKeySets()[btcec.ValidateKeySet]
+	results := make([]btcjson.ValidatorHeartbeatResult, 0, len(validateKeys))
+	active := make(map[wire.BlockValidatingPubKey]struct{}, len(validateKeys))
+	for _, key := range validateKeys {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], key.SerializeCompressed())
+		active[pubKey] = struct{}{}
+		results = append(results, newResult(pubKey, true))
 	}
-	return reply, nil
+	for pubKey := range heartbeats {
+		if _, ok := active[pubKey]; ok {
+			continue
+		}
+		results = append(results, newResult(pubKey, false))
+	}
+	return results, nil
 }
 
-// handleGetNetworkHashPS implements the getnetworkhashps command.
-func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Note: All valid error return paths should return an int64.
-	// Literal zeros are inferred as int, and won't coerce to int64
-	// because the return value is an interface{}.
-
-	c := cmd.(*btcjson.GetNetworkHashPSCmd)
+// handleGetValidatorSchedule implements the getvalidatorschedule command.
+func handleGetValidatorSchedule(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	localKeys := make(map[wire.BlockValidatingPubKey]struct{})
+	for _, key := range s.server.cpuMiner.ValidateKeys() {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], key.PubKey().SerializeCompressed())
+		localKeys[pubKey] = struct{}{}
+	}
 
-	// When the passed height is too high or zero, just return 0 now
-	// since we can't reasonably calculate the number of network hashes
-	// per second from invalid values.  When it's negative, use the current
-	// best block height.
+	validateKeys := s.chain.AdminKeySets()[btcec.ValidateKeySet]
+	pubKeys := make([]wire.BlockValidatingPubKey, len(validateKeys))
+	for i, key := range validateKeys {
+		copy(pubKeys[i][:], key.SerializeCompressed())
+	}
 	best := s.chain.BestSnapshot()
-	endHeight := int32(-1)
-	if c.Height != nil {
-		endHeight = int32(*c.Height)
+	blocks, err := s.chain.BlocksUntilEligible(pubKeys)
+	if err != nil {
+		context := "Failed to compute validator schedule"
+		return nil, internalRPCError(err.Error(), context)
 	}
-	if endHeight > int32(best.Height) || endHeight == 0 {
-		return int64(0), nil
+
+	// The estimates assume the blocks in between are produced at the target
+	// block interval.
+	header, err := s.chain.FetchHeader(best.Hash)
+	if err != nil {
+		context := "Failed to fetch block header"
+		return nil, internalRPCError(err.Error(), context)
 	}
-	if endHeight < 0 {
-		endHeight = int32(best.Height)
+	interval := s.server.chainParams.TargetTimePerBlock
+	results := make([]btcjson.ValidatorScheduleResult, 0, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		_, local := localKeys[pubKey]
+		n := blocks[i]
+		results = append(results, btcjson.ValidatorScheduleResult{
+			PubKey:              hex.EncodeToString(pubKey[:]),
+			Local:               local,
+			Eligible:            n == 0,
+			BlocksUntilEligible: n,
+			EligibleHeight:      best.Height + 1 + uint32(n),
+			EstimatedTime: header.Timestamp.Add(
+				time.Duration(n+1) * interval).Unix(),
+		})
 	}
+	return results, nil
+}
 
-	// Calculate the number of blocks per retarget interval based on the
-	// chain parameters.
-	blocksPerRetarget := int32(s.server.chainParams.PowAveragingWindow)
+// handleGetValidatorInfo implements the getvalidatorinfo command.
+func handleGetValidatorInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetValidatorInfoCmd)
 
-	// Calculate the starting block height based on the passed number of
-	// blocks.  When the passed value is negative, use the last block the
-	// difficulty changed as the starting height.  Also make sure the
-	// starting height is not before the beginning of the chain.
-	// TODO(prova): adjust this calculation for the rolling difficulty avg.
-	numBlocks := int32(120)
-	if c.Blocks != nil {
-		numBlocks = int32(*c.Blocks)
+	// Default to reporting the number of blocks signed within the window
+	// used for validate key rate limiting.
+	params := s.server.chainParams
+	windows := []uint32{uint32(params.PowAveragingWindow)}
+	if c.Windows != nil {
+		windows = *c.Windows
 	}
-	var startHeight int32
-	if numBlocks <= 0 {
-		startHeight = endHeight - ((endHeight % blocksPerRetarget) + 1)
-	} else {
-		startHeight = endHeight - numBlocks
+	if len(windows) > maxValidatorInfoWindows {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("At most %d windows may be "+
+				"requested", maxValidatorInfoWindows),
+		}
 	}
-	if startHeight < 0 {
-		startHeight = 0
+	for _, window := range windows {
+		if window == 0 || window > blockchain.MaxValidateKeyStatsWindow {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Windows must be between 1 "+
+					"and %d", blockchain.MaxValidateKeyStatsWindow),
+			}
+		}
 	}
-	rpcsLog.Debugf("Calculating network hashes per second from %d to %d",
-		startHeight, endHeight)
 
-	// Find the min and max block timestamps as well as calculate the total
-	// amount of work that happened between the start and end blocks.
-	var minTimestamp, maxTimestamp time.Time
-	totalWork := big.NewInt(0)
-	for curHeight := startHeight; curHeight <= endHeight; curHeight++ {
-		hash, err := s.chain.BlockHashByHeight(uint32(curHeight))
-		if err != nil {
-			context := "Failed to fetch block hash"
-			return nil, internalRPCError(err.Error(), context)
-		}
+	best := s.chain.BestSnapshot()
+	stats, err := s.chain.ValidateKeyStats(windows)
+	if err != nil {
+		context := "Failed to calculate validate key statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-		// Fetch the header from chain.
-		header, err := s.chain.FetchHeader(hash)
-		if err != nil {
-			context := "Failed to fetch block header"
-			return nil, internalRPCError(err.Error(), context)
+	validators := make([]btcjson.ValidatorInfoResult, 0, len(stats))
+	for i := range stats {
+		keyStats := &stats[i]
+		windowResults := make([]btcjson.ValidatorWindowResult,
+			len(windows))
+		for j, window := range windows {
+			windowResults[j] = btcjson.ValidatorWindowResult{
+				Window: window,
+				Blocks: keyStats.WindowCounts[j],
+			}
 		}
 
-		if curHeight == startHeight {
-			minTimestamp = header.Timestamp
-			maxTimestamp = minTimestamp
-		} else {
-			totalWork.Add(totalWork, blockchain.CalcWork(header.Bits))
-
-			if minTimestamp.After(header.Timestamp) {
-				minTimestamp = header.Timestamp
-			}
-			if maxTimestamp.Before(header.Timestamp) {
-				maxTimestamp = header.Timestamp
-			}
+		var lastSeenHeight *uint32
+		if keyStats.Seen {
+			lastSeenHeight = &keyStats.LastSeenHeight
 		}
+		validators = append(validators, btcjson.ValidatorInfoResult{
+			PubKey:           keyStats.PubKey.String(),
+			Active:           keyStats.Active,
+			LastSeenHeight:   lastSeenHeight,
+			Windows:          windowResults,
+			TrailingBlocks:   keyStats.TrailingCount,
+			TrailingHeadroom: keyStats.TrailingHeadroom,
+			ShareHeadroom:    keyStats.ShareHeadroom,
+			RateLimited:      keyStats.RateLimited,
+		})
 	}
 
-	// Calculate the difference in seconds between the min and max block
-	// timestamps and avoid division by zero in the case where there is no
-	// time difference.
-	timeDiff := int64(maxTimestamp.Sub(minTimestamp) / time.Second)
-	if timeDiff == 0 {
-		return int64(0), nil
+	result := &btcjson.GetValidatorInfoResult{
+		Hash:          best.Hash.String(),
+		Height:        best.Height,
+		RateWindow:    params.PowAveragingWindow,
+		TrailingLimit: params.ChainTrailingSigKeyLimit,
+		ShareLimit:    params.ChainWindowShareLimit,
+		Validators:    validators,
 	}
-
-	hashesPerSec := new(big.Int).Div(totalWork, big.NewInt(timeDiff))
-	return hashesPerSec.Int64(), nil
+	return result, nil
 }
 
-// handleGetPeerInfo implements the getpeerinfo command.
-func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	peers := s.server.Peers()
-	syncPeer := s.server.blockManager.SyncPeer()
-	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
-	for _, p := range peers {
-		statsSnap := p.StatsSnapshot()
-		info := &btcjson.GetPeerInfoResult{
-			ID:             statsSnap.ID,
-			Addr:           statsSnap.Addr,
-			AddrLocal:      p.LocalAddr().String(),
-			Services:       fmt.Sprintf("%08d", uint64(statsSnap.Services)),
-			RelayTxes:      !p.disableRelayTx,
-			LastSend:       statsSnap.LastSend.Unix(),
-			LastRecv:       statsSnap.LastRecv.Unix(),
-			BytesSent:      statsSnap.BytesSent,
-			BytesRecv:      statsSnap.BytesRecv,
-			ConnTime:       statsSnap.ConnTime.Unix(),
-			PingTime:       float64(statsSnap.LastPingMicros),
-			TimeOffset:     statsSnap.TimeOffset,
-			Version:        statsSnap.Version,
-			SubVer:         statsSnap.UserAgent,
-			Inbound:        statsSnap.Inbound,
-			StartingHeight: statsSnap.StartingHeight,
-			CurrentHeight:  statsSnap.LastBlock,
-			BanScore:       int32(p.banScore.Int()),
-			FeeFilter:      atomic.LoadInt64(&p.feeFilter),
-			SyncNode:       p == syncPeer,
+// handleHelp implements the help command.
+func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.HelpCmd)
+
+	// Provide a usage overview of all commands when no specific command
+	// was specified.
+	var command string
+	if c.Command != nil {
+		command = *c.Command
+	}
+	if command == "" {
+		usage, err := s.helpCacher.rpcUsage(false)
+		if err != nil {
+			context := "Failed to generate RPC usage"
+			return nil, internalRPCError(err.Error(), context)
 		}
-		if p.LastPingNonce() != 0 {
-			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
-			// We actually want microseconds.
-			info.PingWait = wait / 1000
+		return usage, nil
+	}
+
+	// Check that the command asked for is supported and implemented.  Only
+	// search the main list of handlers since help should not be provided
+	// for commands that are unimplemented or related to wallet
+	// functionality.
+	if _, ok := rpcHandlers[command]; !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown command: " + command,
 		}
-		infos = append(infos, info)
 	}
-	return infos, nil
+
+	// Get the help for the command.
+	help, err := s.helpCacher.rpcMethodHelp(command)
+	if err != nil {
+		context := "Failed to generate help"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return help, nil
 }
 
-// handleGetRawMempool implements the getrawmempool command.
-func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetRawMempoolCmd)
-	mp := s.server.txMemPool
+// handleRPCDiscover implements the rpc.discover command.
+func handleRPCDiscover(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	doc, err := s.helpCacher.rpcDiscover()
+	if err != nil {
+		context := "Failed to generate OpenRPC document"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return doc, nil
+}
 
-	if c.Verbose != nil && *c.Verbose {
-		return mp.RawMempoolVerbose(), nil
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans := s.server.banManager.Bans()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:     ban.Subnet.String(),
+			BannedUntil: ban.Until.Unix(),
+			BanCreated:  ban.Created.Unix(),
+			BanReason:   ban.Reason,
+		})
 	}
+	return results, nil
+}
 
-	// The response is simply an array of the transaction hashes if the
-	// verbose flag is not set.
-	descs := mp.TxDescs()
-	hashStrings := make([]string, len(descs))
-	for i := range hashStrings {
-		hashStrings[i] = descs[i].Tx.Hash().String()
+// handlePing implements the ping command.
+func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Ask server to ping \o_
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, internalRPCError("Not sending ping - failed to "+
+			"generate nonce: "+err.Error(), "")
 	}
+	s.server.BroadcastMessage(wire.NewMsgPing(nonce))
 
-	return hashStrings, nil
+	return nil, nil
+}
+
+// retrievedTx represents a transaction that was either loaded from the
+// transaction memory pool or from the database.  When a transaction is loaded
+// from the database, it is loaded with the raw serialized bytes while the
+// mempool has the fully deserialized structure.  This structure therefore will
+// have one of the two fields set depending on where is was retrieved from.
+// This is mainly done for efficiency to avoid extra serialization steps when
+// possible.
+type retrievedTx struct {
+	txBytes []byte
+	blkHash *chainhash.Hash // Only set when transaction is in a block.
+	tx      *provautil.Tx
 }
 
-// handleGetRawTransaction implements the getrawtransaction command.
-func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetRawTransactionCmd)
-
-	// Convert the provided transaction hash hex to a Hash.
-	txHash, err := chainhash.NewHashFromStr(c.Txid)
-	if err != nil {
-		return nil, rpcDecodeHexError(c.Txid)
-	}
+// fetchInputTxos fetches the outpoints from all transactions referenced by the
+// inputs to the passed transaction by checking the transaction mempool first
+// then the transaction index for those already mined into blocks.
+func fetchInputTxos(s *rpcServer, tx *wire.MsgTx) (map[wire.OutPoint]wire.TxOut, error) {
+	mp := s.server.txMemPool
+	txIndex := s.server.indexes().txIndex
+	originOutputs := make(map[wire.OutPoint]wire.TxOut)
+	for txInIndex, txIn := range tx.TxIn {
+		// Attempt to fetch and use the referenced transaction from the
+		// memory pool.
+		origin := &txIn.PreviousOutPoint
+		originTx, err := mp.FetchTransaction(&origin.Hash)
+		if err == nil {
+			txOuts := originTx.MsgTx().TxOut
+			if origin.Index >= uint32(len(txOuts)) {
+				errStr := fmt.Sprintf("unable to find output "+
+					"%v referenced from transaction %s:%d",
+					origin, tx.TxHash(), txInIndex)
+				return nil, internalRPCError(errStr, "")
+			}
 
-	verbose := false
-	if c.Verbose != nil {
-		verbose = *c.Verbose != 0
-	}
+			originOutputs[*origin] = *txOuts[origin.Index]
+			continue
+		}
 
-	// Try to fetch the transaction from the memory pool and if that fails,
-	// try the block database.
-	var mtx *wire.MsgTx
-	var blkHash *chainhash.Hash
-	var blkHeight uint32
-	tx, err := s.server.txMemPool.FetchTransaction(txHash)
-	if err != nil {
-		txIndex := s.server.txIndex
+		// Look up the location of the transaction.
 		if txIndex == nil {
 			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCNoTxInfo,
-				Message: "The transaction index must be " +
-					"enabled to query the blockchain " +
-					"(specify --txindex)",
+				Code:    btcjson.ErrRPCMisc,
+				Message: "Transaction index must be enabled (--txindex)",
 			}
 		}
-
-		// Look up the location of the transaction.
-		blockRegion, err := txIndex.TxBlockRegion(txHash)
+		blockRegion, err := txIndex.TxBlockRegion(&origin.Hash)
 		if err != nil {
 			context := "Failed to retrieve transaction location"
 			return nil, internalRPCError(err.Error(), context)
 		}
 		if blockRegion == nil {
-			return nil, rpcNoTxInfoError(txHash)
+			return nil, rpcNoTxInfoError(&origin.Hash)
 		}
 
 		// Load the raw transaction bytes from the database.
@@ -2459,22 +5844,7 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 			return err
 		})
 		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
-		}
-
-		// When the verbose flag isn't set, simply return the serialized
-		// transaction as a hex-encoded string.  This is done here to
-		// avoid deserializing it only to reserialize it again later.
-		if !verbose {
-			return hex.EncodeToString(txBytes), nil
-		}
-
-		// Grab the block height.
-		blkHash = blockRegion.Hash
-		blkHeight, err = s.chain.BlockHeightByHash(blkHash)
-		if err != nil {
-			context := "Failed to retrieve block height"
-			return nil, internalRPCError(err.Error(), context)
+			return nil, rpcNoTxInfoError(&origin.Hash)
 		}
 
 		// Deserialize the transaction
@@ -2484,841 +5854,1157 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 			context := "Failed to deserialize transaction"
 			return nil, internalRPCError(err.Error(), context)
 		}
-		mtx = &msgTx
-	} else {
-		// When the verbose flag isn't set, simply return the
-		// network-serialized transaction as a hex-encoded string.
-		if !verbose {
-			// Note that this is intentionally not directly
-			// returning because the first return value is a
-			// string and it would result in returning an empty
-			// string to the client instead of nothing (nil) in the
-			// case of an error.
-			mtxHex, err := messageToHex(tx.MsgTx())
-			if err != nil {
-				return nil, err
-			}
-			return mtxHex, nil
-		}
 
-		mtx = tx.MsgTx()
+		// Add the referenced output to the map.
+		if origin.Index >= uint32(len(msgTx.TxOut)) {
+			errStr := fmt.Sprintf("unable to find output %v "+
+				"referenced from transaction %s:%d", origin,
+				tx.TxHash(), txInIndex)
+			return nil, internalRPCError(errStr, "")
+		}
+		originOutputs[*origin] = *msgTx.TxOut[origin.Index]
 	}
 
-	// The verbose flag is set, so generate the JSON object and return it.
-	var blkHeader *wire.BlockHeader
-	var blkHashStr string
-	var chainHeight uint32
-	if blkHash != nil {
-		// Fetch the header from chain.
-		header, err := s.chain.FetchHeader(blkHash)
+	return originOutputs, nil
+}
+
+// createVinListPrevOut returns a slice of JSON objects for the inputs of the
+// passed transaction.
+func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.Params, vinExtra bool, filterAddrMap map[string]struct{}) ([]btcjson.VinPrevOut, error) {
+	// Lookup all of the referenced transaction outputs needed to populate
+	// the previous output information if requested.
+	var originOutputs map[wire.OutPoint]wire.TxOut
+	if !blockchain.IsCoinBaseTx(mtx) && (vinExtra || len(filterAddrMap) > 0) {
+		var err error
+		originOutputs, err = fetchInputTxos(s, mtx)
 		if err != nil {
-			context := "Failed to fetch block header"
-			return nil, internalRPCError(err.Error(), context)
+			return nil, err
 		}
-
-		blkHeader = &header
-		blkHashStr = blkHash.String()
-		chainHeight = s.chain.BestSnapshot().Height
 	}
 
-	rawTxn, err := createTxRawResult(s.server.chainParams, mtx,
-		txHash.String(), blkHeader, blkHashStr, blkHeight, chainHeight)
-	if err != nil {
-		return nil, err
-	}
-	return *rawTxn, nil
+	return createVinListOriginOutputs(mtx, chainParams, originOutputs,
+		vinExtra, filterAddrMap), nil
 }
 
-// handleGetTxOut handles gettxout commands.
-func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetTxOutCmd)
+// createVinListOriginOutputs returns a slice of JSON objects for the inputs of
+// the passed transaction, with the previous output information taken from the
+// passed outputs referenced by the inputs when requested.
+func createVinListOriginOutputs(mtx *wire.MsgTx, chainParams *chaincfg.Params, originOutputs map[wire.OutPoint]wire.TxOut, vinExtra bool, filterAddrMap map[string]struct{}) []btcjson.VinPrevOut {
+	// Coinbase transactions only have a single txin by definition.
+	if blockchain.IsCoinBaseTx(mtx) {
+		// Only include the transaction if the filter map is empty
+		// because a coinbase input has no addresses and so would never
+		// match a non-empty filter.
+		if len(filterAddrMap) != 0 {
+			return nil
+		}
 
-	// Convert the provided transaction hash hex to a Hash.
-	txHash, err := chainhash.NewHashFromStr(c.Txid)
-	if err != nil {
-		return nil, rpcDecodeHexError(c.Txid)
+		txIn := mtx.TxIn[0]
+		vinList := make([]btcjson.VinPrevOut, 1)
+		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vinList[0].Sequence = txIn.Sequence
+		return vinList
 	}
 
-	// If requested and the tx is available in the mempool try to fetch it
-	// from there, otherwise attempt to fetch from the block database.
-	var bestBlockHash string
-	var confirmations uint32
-	var txVersion int32
-	var value int64
-	var pkScript []byte
-	var isCoinbase bool
-	includeMempool := true
-	if c.IncludeMempool != nil {
-		includeMempool = *c.IncludeMempool
-	}
-	// TODO: This is racy.  It should attempt to fetch it directly and check
-	// the error.
-	if includeMempool && s.server.txMemPool.HaveTransaction(txHash) {
-		tx, err := s.server.txMemPool.FetchTransaction(txHash)
-		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
+	// Use a dynamically sized list to accommodate the address filter.
+	vinList := make([]btcjson.VinPrevOut, 0, len(mtx.TxIn))
+
+	for _, txIn := range mtx.TxIn {
+		// The disassembled string will contain [error] inline
+		// if the script doesn't fully parse, so ignore the
+		// error here.
+		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		// Create the basic input entry without the additional optional
+		// previous output details which will be added later if
+		// requested and available.
+		prevOut := &txIn.PreviousOutPoint
+		vinEntry := btcjson.VinPrevOut{
+			Txid:     prevOut.Hash.String(),
+			Vout:     prevOut.Index,
+			Sequence: txIn.Sequence,
+			ScriptSig: &btcjson.ScriptSig{
+				Asm: disbuf,
+				Hex: hex.EncodeToString(txIn.SignatureScript),
+			},
 		}
 
-		mtx := tx.MsgTx()
-		if c.Vout > uint32(len(mtx.TxOut)-1) {
-			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInvalidTxVout,
-				Message: "Output index number (vout) does not " +
-					"exist for transaction.",
-			}
+		// Add the entry to the list now if it already passed the filter
+		// since the previous output might not be available.
+		passesFilter := len(filterAddrMap) == 0
+		if passesFilter {
+			vinList = append(vinList, vinEntry)
 		}
 
-		txOut := mtx.TxOut[c.Vout]
-		if txOut == nil {
-			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
-				"does not exist", c.Vout, txHash)
-			return nil, internalRPCError(errStr, "")
+		// Only populate previous output information if requested and
+		// available.
+		if len(originOutputs) == 0 {
+			continue
+		}
+		originTxOut, ok := originOutputs[*prevOut]
+		if !ok {
+			continue
 		}
 
-		best := s.chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 0
-		txVersion = mtx.Version
-		value = txOut.Value
-		pkScript = txOut.PkScript
-		isCoinbase = blockchain.IsCoinBaseTx(mtx)
-	} else {
-		entry, err := s.chain.FetchUtxoEntry(txHash)
-		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		scriptClass, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+			originTxOut.PkScript, chainParams)
+
+		// Encode the addresses while checking if the address passes the
+		// filter when needed.
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddr := addr.EncodeAddress()
+			encodedAddrs[j] = encodedAddr
+
+			// No need to check the map again if the filter already
+			// passes.
+			if passesFilter {
+				continue
+			}
+			if _, exists := filterAddrMap[encodedAddr]; exists {
+				passesFilter = true
+			}
 		}
 
-		// To match the behavior of the reference client, return nil
-		// (JSON null) if the transaction output is spent by another
-		// transaction already in the main chain.  Mined transactions
-		// that are spent by a mempool transaction are not affected by
-		// this.
-		if entry == nil || entry.IsOutputSpent(c.Vout) {
-			return nil, nil
+		// Ignore the entry if it doesn't pass the filter.
+		if !passesFilter {
+			continue
+		}
+
+		// Add entry to the list if it wasn't already done above.
+		if len(filterAddrMap) != 0 {
+			vinList = append(vinList, vinEntry)
 		}
 
-		best := s.chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 1 + best.Height - entry.BlockHeight()
-		txVersion = entry.Version()
-		value = entry.AmountByIndex(c.Vout)
-		pkScript = entry.PkScriptByIndex(c.Vout)
-		isCoinbase = entry.IsCoinBase()
+		// Update the entry with previous output information if
+		// requested.
+		if vinExtra {
+			var details btcjson.ScriptPubKeyResult
+			addProvaScriptDetails(&details, originTxOut.PkScript,
+				scriptClass, nil)
+			vinListEntry := &vinList[len(vinList)-1]
+			vinListEntry.PrevOut = &btcjson.PrevOut{
+				Addresses: encodedAddrs,
+				Value:     provautil.Amount(originTxOut.Value).ToRMG(),
+				Type:      scriptClass.String(),
+				KeyIDs:    details.KeyIDs,
+			}
+		}
 	}
 
-	// Disassemble script into single line printable format.
-	// The disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	disbuf, _ := txscript.DisasmString(pkScript)
+	return vinList
+}
 
-	// Get further info about the script.
-	// Ignore the error here since an error means the script couldn't parse
-	// and there is no additional information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.server.chainParams)
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.EncodeAddress()
+// fetchMempoolTxnsForAddress queries the address index for all unconfirmed
+// transactions that involve the provided address.  The results will be limited
+// by the number to skip and the number requested.
+func fetchMempoolTxnsForAddress(s *rpcServer, addr provautil.Address, numToSkip, numRequested uint32) ([]*provautil.Tx, uint32) {
+	// There are no entries to return when there are less available than the
+	// number being skipped.
+	mpTxns := s.server.addrIndex.UnconfirmedTxnsForAddress(addr)
+	numAvailable := uint32(len(mpTxns))
+	if numToSkip > numAvailable {
+		return nil, numAvailable
 	}
 
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         provautil.Amount(value).ToRMG(),
-		Version:       txVersion,
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+	// Filter the available entries based on the number to skip and number
+	// requested.
+	rangeEnd := numToSkip + numRequested
+	if rangeEnd > numAvailable {
+		rangeEnd = numAvailable
 	}
-	return txOutReply, nil
+	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
-// handleHelp implements the help command.
-func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.HelpCmd)
+// handleSearchRawTransactions implements the searchrawtransactions command.
+func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.server.addrIndex
+	if addrIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
 
-	// Provide a usage overview of all commands when no specific command
-	// was specified.
-	var command string
-	if c.Command != nil {
-		command = *c.Command
+	// Override the flag for including extra previous output information in
+	// each input if needed.
+	c := cmd.(*btcjson.SearchRawTransactionsCmd)
+	vinExtra := false
+	if c.VinExtra != nil {
+		vinExtra = *c.VinExtra != 0
 	}
-	if command == "" {
-		usage, err := s.helpCacher.rpcUsage(false)
-		if err != nil {
-			context := "Failed to generate RPC usage"
-			return nil, internalRPCError(err.Error(), context)
+
+	// Including the extra previous output information requires the
+	// transaction index.  Currently the address index relies on the
+	// transaction index, so this check is redundant, but it's better to be
+	// safe in case the address index is ever changed to not rely on it.
+	if vinExtra && s.server.indexes().txIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Transaction index must be enabled (--txindex)",
 		}
-		return usage, nil
 	}
 
-	// Check that the command asked for is supported and implemented.  Only
-	// search the main list of handlers since help should not be provided
-	// for commands that are unimplemented or related to wallet
-	// functionality.
-	if _, ok := rpcHandlers[command]; !ok {
+	// Attempt to decode the supplied address.
+	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
+	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidParameter,
-			Message: "Unknown command: " + command,
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
 		}
 	}
 
-	// Get the help for the command.
-	help, err := s.helpCacher.rpcMethodHelp(command)
-	if err != nil {
-		context := "Failed to generate help"
-		return nil, internalRPCError(err.Error(), context)
+	// Override the default number of requested entries if needed.  Also,
+	// just return now if the number of requested entries is zero to avoid
+	// extra work.
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	if numRequested == 0 {
+		return nil, nil
 	}
-	return help, nil
-}
 
-// handlePing implements the ping command.
-func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Ask server to ping \o_
-	nonce, err := wire.RandomUint64()
-	if err != nil {
-		return nil, internalRPCError("Not sending ping - failed to "+
-			"generate nonce: "+err.Error(), "")
+	// Override the default number of entries to skip if needed.
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
 	}
-	s.server.BroadcastMessage(wire.NewMsgPing(nonce))
 
-	return nil, nil
-}
+	// Override the reverse flag if needed.
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
 
-// retrievedTx represents a transaction that was either loaded from the
-// transaction memory pool or from the database.  When a transaction is loaded
-// from the database, it is loaded with the raw serialized bytes while the
-// mempool has the fully deserialized structure.  This structure therefore will
-// have one of the two fields set depending on where is was retrieved from.
-// This is mainly done for efficiency to avoid extra serialization steps when
-// possible.
-type retrievedTx struct {
-	txBytes []byte
-	blkHash *chainhash.Hash // Only set when transaction is in a block.
-	tx      *provautil.Tx
-}
+	// Add transactions from mempool first if client asked for reverse
+	// order.  Otherwise, they will be added last (as needed depending on
+	// the requested counts).
+	//
+	// NOTE: This code doesn't sort by dependency.  This might be something
+	// to do in the future for the client's convenience, or leave it to the
+	// client.
+	numSkipped := uint32(0)
+	addressTxns := make([]retrievedTx, 0, numRequested)
+	if reverse {
+		// Transactions in the mempool are not in a block header yet,
+		// so the block header field in the retieved transaction struct
+		// is left nil.
+		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
+			uint32(numToSkip), uint32(numRequested))
+		numSkipped += mpSkipped
+		for _, tx := range mpTxns {
+			addressTxns = append(addressTxns, retrievedTx{tx: tx})
+		}
+	}
 
-// fetchInputTxos fetches the outpoints from all transactions referenced by the
-// inputs to the passed transaction by checking the transaction mempool first
-// then the transaction index for those already mined into blocks.
-func fetchInputTxos(s *rpcServer, tx *wire.MsgTx) (map[wire.OutPoint]wire.TxOut, error) {
-	mp := s.server.txMemPool
-	originOutputs := make(map[wire.OutPoint]wire.TxOut)
-	for txInIndex, txIn := range tx.TxIn {
-		// Attempt to fetch and use the referenced transaction from the
-		// memory pool.
-		origin := &txIn.PreviousOutPoint
-		originTx, err := mp.FetchTransaction(&origin.Hash)
-		if err == nil {
-			txOuts := originTx.MsgTx().TxOut
-			if origin.Index >= uint32(len(txOuts)) {
-				errStr := fmt.Sprintf("unable to find output "+
-					"%v referenced from transaction %s:%d",
-					origin, tx.TxHash(), txInIndex)
-				return nil, internalRPCError(errStr, "")
+	// Fetch transactions from the database in the desired order if more are
+	// needed.
+	if len(addressTxns) < numRequested {
+		err = s.server.db.View(func(dbTx database.Tx) error {
+			regions, dbSkipped, err := addrIndex.TxRegionsForAddress(
+				dbTx, addr, uint32(numToSkip)-numSkipped,
+				uint32(numRequested-len(addressTxns)), reverse)
+			if err != nil {
+				return err
+			}
+
+			// Load the raw transaction bytes from the database.
+			serializedTxns, err := dbTx.FetchBlockRegions(regions)
+			if err != nil {
+				return err
+			}
+
+			// Add the transaction and the hash of the block it is
+			// contained in to the list.  Note that the transaction
+			// is left serialized here since the caller might have
+			// requested non-verbose output and hence there would be
+			// no point in deserializing it just to reserialize it
+			// later.
+			for i, serializedTx := range serializedTxns {
+				addressTxns = append(addressTxns, retrievedTx{
+					txBytes: serializedTx,
+					blkHash: regions[i].Hash,
+				})
 			}
+			numSkipped += dbSkipped
+
+			return nil
+		})
+		if err != nil {
+			context := "Failed to load address index entries"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+	}
+
+	// Add transactions from mempool last if client did not request reverse
+	// order and the number of results is still under the number requested.
+	if !reverse && len(addressTxns) < numRequested {
+		// Transactions in the mempool are not in a block header yet,
+		// so the block header field in the retieved transaction struct
+		// is left nil.
+		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
+			uint32(numToSkip)-numSkipped, uint32(numRequested-
+				len(addressTxns)))
+		numSkipped += mpSkipped
+		for _, tx := range mpTxns {
+			addressTxns = append(addressTxns, retrievedTx{tx: tx})
+		}
+	}
+
+	// Address has never been used if neither source yielded any results.
+	if len(addressTxns) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: "No information available about address",
+		}
+	}
 
-			originOutputs[*origin] = *txOuts[origin.Index]
+	// Serialize all of the transactions to hex.
+	hexTxns := make([]string, len(addressTxns))
+	for i := range addressTxns {
+		// Simply encode the raw bytes to hex when the retrieved
+		// transaction is already in serialized form.
+		rtx := &addressTxns[i]
+		if rtx.txBytes != nil {
+			hexTxns[i] = hex.EncodeToString(rtx.txBytes)
 			continue
 		}
 
-		// Look up the location of the transaction.
-		blockRegion, err := s.server.txIndex.TxBlockRegion(&origin.Hash)
+		// Serialize the transaction first and convert to hex when the
+		// retrieved transaction is the deserialized structure.
+		hexTxns[i], err = messageToHex(rtx.tx.MsgTx())
 		if err != nil {
-			context := "Failed to retrieve transaction location"
-			return nil, internalRPCError(err.Error(), context)
+			return nil, err
 		}
-		if blockRegion == nil {
-			return nil, rpcNoTxInfoError(&origin.Hash)
+	}
+
+	// When not in verbose mode, simply return a list of serialized txns.
+	if c.Verbose != nil && *c.Verbose == 0 {
+		return hexTxns, nil
+	}
+
+	// Normalize the provided filter addresses (if any) to ensure there are
+	// no duplicates and that they match the addresses extracted from
+	// scripts regardless of their encoding.
+	filterAddrMap := make(map[string]struct{})
+	if c.FilterAddrs != nil && len(*c.FilterAddrs) > 0 {
+		for _, addr := range *c.FilterAddrs {
+			a, err := provautil.DecodeAddress(addr, s.server.chainParams)
+			if err == nil {
+				addr = canonicalAddress(a)
+			}
+			filterAddrMap[addr] = struct{}{}
 		}
+	}
 
-		// Load the raw transaction bytes from the database.
-		var txBytes []byte
-		err = s.server.db.View(func(dbTx database.Tx) error {
-			var err error
-			txBytes, err = dbTx.FetchBlockRegion(blockRegion)
-			return err
-		})
-		if err != nil {
-			return nil, rpcNoTxInfoError(&origin.Hash)
+	// The verbose flag is set, so generate the JSON object and return it.
+	best := s.chain.BestSnapshot()
+	chainParams := s.server.chainParams
+	srtList := make([]btcjson.SearchRawTransactionsResult, len(addressTxns))
+	for i := range addressTxns {
+		// The deserialized transaction is needed, so deserialize the
+		// retrieved transaction if it's in serialized form (which will
+		// be the case when it was lookup up from the database).
+		// Otherwise, use the existing deserialized transaction.
+		rtx := &addressTxns[i]
+		var mtx *wire.MsgTx
+		if rtx.tx == nil {
+			// Deserialize the transaction.
+			mtx = new(wire.MsgTx)
+			err := mtx.Deserialize(bytes.NewReader(rtx.txBytes))
+			if err != nil {
+				context := "Failed to deserialize transaction"
+				return nil, internalRPCError(err.Error(),
+					context)
+			}
+		} else {
+			mtx = rtx.tx.MsgTx()
 		}
 
-		// Deserialize the transaction
-		var msgTx wire.MsgTx
-		err = msgTx.Deserialize(bytes.NewReader(txBytes))
+		result := &srtList[i]
+		result.Hex = hexTxns[i]
+		result.Txid = mtx.TxHash().String()
+		result.Vin, err = createVinListPrevOut(s, mtx, chainParams,
+			vinExtra, filterAddrMap)
 		if err != nil {
-			context := "Failed to deserialize transaction"
-			return nil, internalRPCError(err.Error(), context)
+			return nil, err
 		}
+		result.Vout = createVoutList(mtx, chainParams, filterAddrMap)
+		result.Version = mtx.Version
+		result.LockTime = mtx.LockTime
 
-		// Add the referenced output to the map.
-		if origin.Index >= uint32(len(msgTx.TxOut)) {
-			errStr := fmt.Sprintf("unable to find output %v "+
-				"referenced from transaction %s:%d", origin,
-				tx.TxHash(), txInIndex)
-			return nil, internalRPCError(errStr, "")
+		// Transactions grabbed from the mempool aren't yet in a block,
+		// so conditionally fetch block details here.  This will be
+		// reflected in the final JSON output (mempool won't have
+		// confirmations or block information).
+		var blkHeader *wire.BlockHeader
+		var blkHashStr string
+		var blkHeight uint32
+		if blkHash := rtx.blkHash; blkHash != nil {
+			// Fetch the header from chain.
+			header, err := s.chain.FetchHeader(blkHash)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCBlockNotFound,
+					Message: "Block not found",
+				}
+			}
+
+			// Get the block height from chain.
+			height, err := s.chain.BlockHeightByHash(blkHash)
+			if err != nil {
+				context := "Failed to obtain block height"
+				return nil, internalRPCError(err.Error(), context)
+			}
+
+			blkHeader = &header
+			blkHashStr = blkHash.String()
+			blkHeight = height
+		}
+
+		// Add the block information to the result if there is any.
+		if blkHeader != nil {
+			// This is not a typo, they are identical in Bitcoin
+			// Core as well.
+			result.Time = blkHeader.Timestamp.Unix()
+			result.Blocktime = blkHeader.Timestamp.Unix()
+			result.BlockHash = blkHashStr
+			result.Confirmations = uint64(1 + best.Height - blkHeight)
 		}
-		originOutputs[*origin] = *msgTx.TxOut[origin.Index]
 	}
 
-	return originOutputs, nil
+	return srtList, nil
 }
 
-// createVinListPrevOut returns a slice of JSON objects for the inputs of the
-// passed transaction.
-func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.Params, vinExtra bool, filterAddrMap map[string]struct{}) ([]btcjson.VinPrevOut, error) {
-	// Coinbase transactions only have a single txin by definition.
-	if blockchain.IsCoinBaseTx(mtx) {
-		// Only include the transaction if the filter map is empty
-		// because a coinbase input has no addresses and so would never
-		// match a non-empty filter.
-		if len(filterAddrMap) != 0 {
-			return nil, nil
+// handleSendRawTransaction implements the sendrawtransaction command.
+func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SendRawTransactionCmd)
+	// Deserialize and send off to tx relay
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
 		}
+	}
 
-		txIn := mtx.TxIn[0]
-		vinList := make([]btcjson.VinPrevOut, 1)
-		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
-		vinList[0].Sequence = txIn.Sequence
-		return vinList, nil
+	tx := provautil.NewTx(&msgTx)
+	if err := submitTransaction(s, tx); err != nil {
+		return nil, err
 	}
+	return tx.Hash().String(), nil
+}
 
-	// Use a dynamically sized list to accommodate the address filter.
-	vinList := make([]btcjson.VinPrevOut, 0, len(mtx.TxIn))
+// submitTransaction adds the passed transaction created or signed locally to
+// the memory pool, announces it to the network and keeps rebroadcasting it
+// until it makes its way into a block.
+func submitTransaction(s *rpcServer, tx *provautil.Tx) error {
+	// Nothing can be announced without peers.
+	if cfg.ReadOnly {
+		return ErrRPCReadOnly
+	}
 
-	// Lookup all of the referenced transaction outputs needed to populate
-	// the previous output information if requested.
-	var originOutputs map[wire.OutPoint]wire.TxOut
-	if vinExtra || len(filterAddrMap) > 0 {
-		var err error
-		originOutputs, err = fetchInputTxos(s, mtx)
-		if err != nil {
-			return nil, err
+	// User 0 for the tag to represent local node
+	acceptedTxs, err := s.server.txMemPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		// When the error is a rule error, it means the transaction was
+		// simply rejected as opposed to something actually going wrong,
+		// so log it as such.  Otherwise, something really did go wrong,
+		// so log it as an actual error.  In both cases, a JSON-RPC
+		// error is returned to the client with the deserialization
+		// error code (to match bitcoind behavior).
+		if _, ok := err.(mempool.RuleError); ok {
+			rpcsLog.Debugf("Rejected transaction %v: %v", tx.Hash(),
+				err)
+		} else {
+			rpcsLog.Errorf("Failed to process transaction %v: %v",
+				tx.Hash(), err)
+		}
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX rejected: " + err.Error(),
 		}
 	}
 
-	for _, txIn := range mtx.TxIn {
-		// The disassembled string will contain [error] inline
-		// if the script doesn't fully parse, so ignore the
-		// error here.
-		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+	// When the transaction was accepted it should be the first item in the
+	// returned array of accepted transactions.  The only way this will not
+	// be true is if the API for ProcessTransaction changes and this code is
+	// not properly updated, but ensure the condition holds as a safeguard.
+	//
+	// Also, since an error is being returned to the caller, ensure the
+	// transaction is removed from the memory pool.
+	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
+		s.server.txMemPool.RemoveTransaction(tx, true)
 
-		// Create the basic input entry without the additional optional
-		// previous output details which will be added later if
-		// requested and available.
-		prevOut := &txIn.PreviousOutPoint
-		vinEntry := btcjson.VinPrevOut{
-			Txid:     prevOut.Hash.String(),
-			Vout:     prevOut.Index,
-			Sequence: txIn.Sequence,
-			ScriptSig: &btcjson.ScriptSig{
-				Asm: disbuf,
-				Hex: hex.EncodeToString(txIn.SignatureScript),
-			},
-		}
+		errStr := fmt.Sprintf("transaction %v is not in accepted list",
+			tx.Hash())
+		return internalRPCError(errStr, "")
+	}
 
-		// Add the entry to the list now if it already passed the filter
-		// since the previous output might not be available.
-		passesFilter := len(filterAddrMap) == 0
-		if passesFilter {
-			vinList = append(vinList, vinEntry)
-		}
+	s.server.AnnounceNewTransactions(acceptedTxs)
 
-		// Only populate previous output information if requested and
-		// available.
-		if len(originOutputs) == 0 {
-			continue
-		}
-		originTxOut, ok := originOutputs[*prevOut]
-		if !ok {
-			continue
-		}
+	// Keep track of all the sendrawtransaction request txns so that they
+	// can be rebroadcast if they don't make their way into a block.
+	txD := acceptedTxs[0]
+	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+	s.server.AddRebroadcastInventory(iv, txD)
 
-		// Ignore the error here since an error means the script
-		// couldn't parse and there is no additional information about
-		// it anyways.
-		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
-			originTxOut.PkScript, chainParams)
+	return nil
+}
 
-		// Encode the addresses while checking if the address passes the
-		// filter when needed.
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.EncodeAddress()
-			encodedAddrs[j] = encodedAddr
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := connmgr.ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
 
-			// No need to check the map again if the filter already
-			// passes.
-			if passesFilter {
-				continue
-			}
-			if _, exists := filterAddrMap[encodedAddr]; exists {
-				passesFilter = true
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		if s.server.banManager.IsSubnetBanned(subnet) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: "IP/Subnet already banned",
 			}
 		}
 
-		// Ignore the entry if it doesn't pass the filter.
-		if !passesFilter {
-			continue
+		// The ban time is a duration in seconds unless it is
+		// absolute, and defaults to the configured ban duration.
+		until := time.Now().Add(s.server.currentPeerPolicy().banDuration)
+		if c.BanTime != nil && *c.BanTime > 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
 		}
-
-		// Add entry to the list if it wasn't already done above.
-		if len(filterAddrMap) != 0 {
-			vinList = append(vinList, vinEntry)
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Ban time is in the past",
+			}
+		}
+		if err := s.server.BanSubnet(subnet, until); err != nil {
+			context := "Failed to save ban"
+			return nil, internalRPCError(err.Error(), context)
 		}
 
-		// Update the entry with previous output information if
-		// requested.
-		if vinExtra {
-			vinListEntry := &vinList[len(vinList)-1]
-			vinListEntry.PrevOut = &btcjson.PrevOut{
-				Addresses: encodedAddrs,
-				Value:     provautil.Amount(originTxOut.Value).ToRMG(),
+	case btcjson.SBRemove:
+		removed, err := s.server.banManager.Unban(subnet)
+		if err != nil {
+			context := "Failed to save bans"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if !removed {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeNotAdded,
+				Message: "Unban failed: IP/Subnet was not banned",
 			}
 		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
 	}
 
-	return vinList, nil
+	// no data returned unless an error.
+	return nil, nil
 }
 
-// fetchMempoolTxnsForAddress queries the address index for all unconfirmed
-// transactions that involve the provided address.  The results will be limited
-// by the number to skip and the number requested.
-func fetchMempoolTxnsForAddress(s *rpcServer, addr provautil.Address, numToSkip, numRequested uint32) ([]*provautil.Tx, uint32) {
-	// There are no entries to return when there are less available than the
-	// number being skipped.
-	mpTxns := s.server.addrIndex.UnconfirmedTxnsForAddress(addr)
-	numAvailable := uint32(len(mpTxns))
-	if numToSkip > numAvailable {
-		return nil, numAvailable
+// handleSetGenerate implements the setgenerate command.
+func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetGenerateCmd)
+
+	// Disable generation regardless of the provided generate flag if the
+	// maximum number of threads (goroutines for our purposes) is 0.
+	// Otherwise enable or disable it depending on the provided flag.
+	generate := c.Generate
+	genProcLimit := -1
+	if c.GenProcLimit != nil {
+		genProcLimit = *c.GenProcLimit
+	}
+	if genProcLimit == 0 {
+		generate = false
 	}
 
-	// Filter the available entries based on the number to skip and number
-	// requested.
-	rangeEnd := numToSkip + numRequested
-	if rangeEnd > numAvailable {
-		rangeEnd = numAvailable
+	if !generate {
+		s.server.cpuMiner.Stop()
+
+		return nil, nil
 	}
-	return mpTxns[numToSkip:rangeEnd], numToSkip
-}
 
-// handleSearchRawTransactions implements the searchrawtransactions command.
-func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if the address index is not enabled.
-	addrIndex := s.server.addrIndex
-	if addrIndex == nil {
+	// Respond with an error if there are no addresses to pay the
+	// created blocks to.
+	if len(s.server.cpuMiner.MiningAddrs()) == 0 {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "Address index must be enabled (--addrindex)",
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified " +
+				"via --miningaddr",
 		}
 	}
 
-	// Override the flag for including extra previous output information in
-	// each input if needed.
-	c := cmd.(*btcjson.SearchRawTransactionsCmd)
-	vinExtra := false
-	if c.VinExtra != nil {
-		vinExtra = *c.VinExtra != 0
+	// Attempt to establish validate keys from the environment var if there
+	// are none already registered.
+	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+		s.server.cpuMiner.EstablishValidateKeys()
 	}
 
-	// Including the extra previous output information requires the
-	// transaction index.  Currently the address index relies on the
-	// transaction index, so this check is redundant, but it's better to be
-	// safe in case the address index is ever changed to not rely on it.
-	if vinExtra && s.server.txIndex == nil {
+	// Respond with an error if there are no validate keys available to
+	// sign the created blocks.
+	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCMisc,
-			Message: "Transaction index must be enabled (--txindex)",
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No validating priv keys specified " +
+				"via --setvalidatekeys or PROVA_VALIDATE_KEYS" +
+				"env variable",
 		}
 	}
 
-	// Attempt to decode the supplied address.
-	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
-	if err != nil {
+	// It's safe to call start even if it's already started.
+	s.server.cpuMiner.SetNumWorkers(int32(genProcLimit))
+	s.server.cpuMiner.Start()
+
+	return nil, nil
+}
+
+// handleRotateRPCAuth implements the rotaterpcauth command.
+func handleRotateRPCAuth(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.RotateRPCAuthCmd)
+
+	name := rpcCookieUser
+	if c.User != nil {
+		name = *c.User
+	}
+	if name == rpcCookieUser && cfg.NoRPCCookie {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidAddressOrKey,
-			Message: "Invalid address or key: " + err.Error(),
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "RPC cookie authentication is disabled",
 		}
 	}
 
-	// Override the default number of requested entries if needed.  Also,
-	// just return now if the number of requested entries is zero to avoid
-	// extra work.
-	numRequested := 100
-	if c.Count != nil {
-		numRequested = *c.Count
-		if numRequested < 0 {
-			numRequested = 1
+	pass, ok, err := s.rotateUser(name)
+	if err != nil {
+		context := "Failed to rotate RPC password"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unknown RPC user %q", name),
 		}
 	}
-	if numRequested == 0 {
-		return nil, nil
+	result := &btcjson.RotateRPCAuthResult{
+		User:     name,
+		Password: pass,
 	}
-
-	// Override the default number of entries to skip if needed.
-	var numToSkip int
-	if c.Skip != nil {
-		numToSkip = *c.Skip
-		if numToSkip < 0 {
-			numToSkip = 0
-		}
+	if name == rpcCookieUser {
+		result.CookieFile = cfg.RPCCookieFile
 	}
+	return result, nil
+}
 
-	// Override the reverse flag if needed.
-	var reverse bool
-	if c.Reverse != nil {
-		reverse = *c.Reverse
-	}
+// handleSetMockTime implements the setmocktime command.
+func handleSetMockTime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetMockTimeCmd)
 
-	// Add transactions from mempool first if client asked for reverse
-	// order.  Otherwise, they will be added last (as needed depending on
-	// the requested counts).
-	//
-	// NOTE: This code doesn't sort by dependency.  This might be something
-	// to do in the future for the client's convenience, or leave it to the
-	// client.
-	numSkipped := uint32(0)
-	addressTxns := make([]retrievedTx, 0, numRequested)
-	if reverse {
-		// Transactions in the mempool are not in a block header yet,
-		// so the block header field in the retieved transaction struct
-		// is left nil.
-		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
-			uint32(numToSkip), uint32(numRequested))
-		numSkipped += mpSkipped
-		for _, tx := range mpTxns {
-			addressTxns = append(addressTxns, retrievedTx{tx: tx})
+	// The time can only be controlled on the test networks which support
+	// generating blocks.
+	mockableTime, ok := s.server.timeSource.(*blockchain.MockableTime)
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("No support for `setmocktime` on "+
+				"the current network, %s", s.server.chainParams.Net),
+		}
+	}
+	if c.Timestamp < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The timestamp must not be negative",
 		}
 	}
 
-	// Fetch transactions from the database in the desired order if more are
-	// needed.
-	if len(addressTxns) < numRequested {
-		err = s.server.db.View(func(dbTx database.Tx) error {
-			regions, dbSkipped, err := addrIndex.TxRegionsForAddress(
-				dbTx, addr, uint32(numToSkip)-numSkipped,
-				uint32(numRequested-len(addressTxns)), reverse)
-			if err != nil {
-				return err
-			}
-
-			// Load the raw transaction bytes from the database.
-			serializedTxns, err := dbTx.FetchBlockRegions(regions)
-			if err != nil {
-				return err
-			}
+	if c.Timestamp == 0 {
+		mockableTime.SetMockTime(time.Time{})
+		return nil, nil
+	}
+	mockableTime.SetMockTime(time.Unix(c.Timestamp, 0))
+	return nil, nil
+}
 
-			// Add the transaction and the hash of the block it is
-			// contained in to the list.  Note that the transaction
-			// is left serialized here since the caller might have
-			// requested non-verbose output and hence there would be
-			// no point in deserializing it just to reserialize it
-			// later.
-			for i, serializedTx := range serializedTxns {
-				addressTxns = append(addressTxns, retrievedTx{
-					txBytes: serializedTx,
-					blkHash: regions[i].Hash,
-				})
-			}
-			numSkipped += dbSkipped
+// handleSetValidateKeys implements the setvalidatekeys command.
+func handleSetValidateKeys(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetValidateKeysCmd)
 
-			return nil
-		})
-		if err != nil {
-			context := "Failed to load address index entries"
-			return nil, internalRPCError(err.Error(), context)
+	if c.PrivKeys == nil || len(c.PrivKeys) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "No validate keys provided",
 		}
-
 	}
-
-	// Add transactions from mempool last if client did not request reverse
-	// order and the number of results is still under the number requested.
-	if !reverse && len(addressTxns) < numRequested {
-		// Transactions in the mempool are not in a block header yet,
-		// so the block header field in the retieved transaction struct
-		// is left nil.
-		mpTxns, mpSkipped := fetchMempoolTxnsForAddress(s, addr,
-			uint32(numToSkip)-numSkipped, uint32(numRequested-
-				len(addressTxns)))
-		numSkipped += mpSkipped
-		for _, tx := range mpTxns {
-			addressTxns = append(addressTxns, retrievedTx{tx: tx})
+	validateKeys := make([]btcec.Signer, len(c.PrivKeys))
+	for i, privKeyStr := range c.PrivKeys {
+		privKeyBytes, err := hex.DecodeString(privKeyStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(privKeyStr)
 		}
+		privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
+		validateKeys[i] = privKey
 	}
+	s.server.cpuMiner.SetValidateKeys(validateKeys)
 
-	// Address has never been used if neither source yielded any results.
-	if len(addressTxns) == 0 {
+	return nil, nil
+}
+
+// handleResetSignGuard implements the resetsignguard command.
+func handleResetSignGuard(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ResetSignGuardCmd)
+	signGuard := s.server.signGuard
+	if signGuard == nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCNoTxInfo,
-			Message: "No information available about address",
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The signing guard is disabled (--nosignguard)",
 		}
 	}
-
-	// Serialize all of the transactions to hex.
-	hexTxns := make([]string, len(addressTxns))
-	for i := range addressTxns {
-		// Simply encode the raw bytes to hex when the retrieved
-		// transaction is already in serialized form.
-		rtx := &addressTxns[i]
-		if rtx.txBytes != nil {
-			hexTxns[i] = hex.EncodeToString(rtx.txBytes)
-			continue
-		}
-
-		// Serialize the transaction first and convert to hex when the
-		// retrieved transaction is the deserialized structure.
-		hexTxns[i], err = messageToHex(rtx.tx.MsgTx())
-		if err != nil {
-			return nil, err
+	serialized, err := hex.DecodeString(c.PubKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.PubKey)
+	}
+	key, err := btcec.ParsePubKey(serialized, btcec.S256())
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid validate pubKey: " + err.Error(),
 		}
 	}
+	var pubKey wire.BlockValidatingPubKey
+	copy(pubKey[:], key.SerializeCompressed())
 
-	// When not in verbose mode, simply return a list of serialized txns.
-	if c.Verbose != nil && *c.Verbose == 0 {
-		return hexTxns, nil
+	previous, err := signGuard.Reset(pubKey, c.Height)
+	if err != nil {
+		context := "Failed to reset the signing guard"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	rpcsLog.Warnf("Signing guard of validate key %v reset to height %d",
+		pubKey, c.Height)
+	if previous == nil {
+		return nil, nil
 	}
+	return &btcjson.SignGuardRecordResult{
+		Height:    previous.Height,
+		Timestamp: previous.Timestamp,
+		Hash:      previous.Hash,
+	}, nil
+}
 
-	// Normalize the provided filter addresses (if any) to ensure there are
-	// no duplicates.
-	filterAddrMap := make(map[string]struct{})
-	if c.FilterAddrs != nil && len(*c.FilterAddrs) > 0 {
-		for _, addr := range *c.FilterAddrs {
-			filterAddrMap[addr] = struct{}{}
+// signRawTxInput signs the input at the passed index of the transaction, which
+// spends an output with the passed public key script and amount, with those of
+// the passed keys which are able to spend it.  The keys are indexed by the
+// hash160 of their serialized public keys, and may be held outside of the node
+// such as by a hardware security module.
+//
+// The keyIDs and admin threads referenced by the script are resolved against
+// the current chain state in order to find the keys the script expects, so keys
+// of the ASPs referenced by keyID are recognized along with the keys of the
+// hashes embedded in the script.  Valid signatures already present in the
+// signature script of the input are kept, and the signatures are ordered as the
+// keys they belong to appear in the script.
+//
+// The signature script of the input is updated, and an error describing why
+// the input does not yet spend the output is returned when it is incomplete or
+// invalid.
+func signRawTxInput(s *rpcServer, mtx *wire.MsgTx, idx int, sigHashes *txscript.TxSigHashes,
+	pkScript []byte, amount int64, keys map[string]btcec.Signer,
+	keyView *blockchain.KeyViewpoint) error {
+
+	class := txscript.GetScriptClass(pkScript)
+	switch class {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy,
+		txscript.GeneralProvaTy, txscript.ProvaAdminTy:
+	default:
+		return errors.New("unable to sign non-Prova script")
+	}
+	_, _, requiredSigs, err := txscript.ExtractPkScriptAddrs(pkScript,
+		s.server.chainParams)
+	if err != nil {
+		return err
+	}
+	scriptFlags := s.scriptFlags()
+	resolved, err := blockchain.ResolvePkScript(pkScript, keyView,
+		scriptFlags)
+	if err != nil {
+		return err
+	}
+	if class == txscript.ProvaAdminTy {
+		// Resolved admin thread scripts start with the small integer
+		// threshold of the thread.
+		requiredSigs = int(resolved[0] - (txscript.OP_1 - 1))
+	}
+	pushes, err := txscript.PushedData(resolved)
+	if err != nil {
+		return err
+	}
+	var keyHashes [][]byte
+	for _, push := range pushes {
+		if len(push) == ripemd160.Size {
+			keyHashes = append(keyHashes, push)
 		}
 	}
 
-	// The verbose flag is set, so generate the JSON object and return it.
-	best := s.chain.BestSnapshot()
-	chainParams := s.server.chainParams
-	srtList := make([]btcjson.SearchRawTransactionsResult, len(addressTxns))
-	for i := range addressTxns {
-		// The deserialized transaction is needed, so deserialize the
-		// retrieved transaction if it's in serialized form (which will
-		// be the case when it was lookup up from the database).
-		// Otherwise, use the existing deserialized transaction.
-		rtx := &addressTxns[i]
-		var mtx *wire.MsgTx
-		if rtx.tx == nil {
-			// Deserialize the transaction.
-			mtx = new(wire.MsgTx)
-			err := mtx.Deserialize(bytes.NewReader(rtx.txBytes))
-			if err != nil {
-				context := "Failed to deserialize transaction"
-				return nil, internalRPCError(err.Error(),
-					context)
+	sigHash, err := txscript.CalcSignatureHashNew(mtx, idx, sigHashes,
+		txscript.SigHashAll, amount)
+	if err != nil {
+		return err
+	}
+
+	// findKey returns the index of the script key hash which belongs to
+	// the passed public key and has not been signed for yet.
+	signed := make([][2][]byte, len(keyHashes))
+	findKey := func(pubKey []byte) int {
+		pubKeyHash := provautil.Hash160(pubKey)
+		for i, keyHash := range keyHashes {
+			if signed[i][0] == nil && bytes.Equal(keyHash, pubKeyHash) {
+				return i
 			}
-		} else {
-			mtx = rtx.tx.MsgTx()
 		}
+		return -1
+	}
 
-		result := &srtList[i]
-		result.Hex = hexTxns[i]
-		result.Txid = mtx.TxHash().String()
-		result.Vin, err = createVinListPrevOut(s, mtx, chainParams,
-			vinExtra, filterAddrMap)
-		if err != nil {
-			return nil, err
-		}
-		result.Vout = createVoutList(mtx, chainParams, filterAddrMap)
-		result.Version = mtx.Version
-		result.LockTime = mtx.LockTime
+	// Keep the valid signatures of the script keys which are already
+	// present in the signature script.
+	numSigs := 0
+	txIn := mtx.TxIn[idx]
+	existing, err := txscript.PushedData(txIn.SignatureScript)
+	if err == nil && len(existing)%2 == 0 {
+		for i := 0; i < len(existing); i += 2 {
+			pubKey, sig := existing[i], existing[i+1]
+			keyIdx := findKey(pubKey)
+			if keyIdx == -1 || len(sig) == 0 ||
+				txscript.SigHashType(sig[len(sig)-1]) != txscript.SigHashAll {
 
-		// Transactions grabbed from the mempool aren't yet in a block,
-		// so conditionally fetch block details here.  This will be
-		// reflected in the final JSON output (mempool won't have
-		// confirmations or block information).
-		var blkHeader *wire.BlockHeader
-		var blkHashStr string
-		var blkHeight uint32
-		if blkHash := rtx.blkHash; blkHash != nil {
-			// Fetch the header from chain.
-			header, err := s.chain.FetchHeader(blkHash)
-			if err != nil {
-				return nil, &btcjson.RPCError{
-					Code:    btcjson.ErrRPCBlockNotFound,
-					Message: "Block not found",
-				}
+				continue
 			}
-
-			// Get the block height from chain.
-			height, err := s.chain.BlockHeightByHash(blkHash)
+			parsedSig, err := btcec.ParseDERSignature(sig[:len(sig)-1],
+				btcec.S256())
 			if err != nil {
-				context := "Failed to obtain block height"
-				return nil, internalRPCError(err.Error(), context)
+				continue
 			}
+			parsedPubKey, err := btcec.ParsePubKey(pubKey, btcec.S256())
+			if err != nil || !parsedSig.Verify(sigHash, parsedPubKey) {
+				continue
+			}
+			signed[keyIdx] = [2][]byte{pubKey, sig}
+			numSigs++
+		}
+	}
 
-			blkHeader = &header
-			blkHashStr = blkHash.String()
-			blkHeight = height
+	// Sign with the passed keys which belong to the remaining script keys
+	// until the required number of signatures is reached.
+	for i := range keyHashes {
+		if numSigs >= requiredSigs {
+			break
+		}
+		signer, ok := keys[string(keyHashes[i])]
+		if !ok || signed[i][0] != nil {
+			continue
+		}
+		sig, err := signer.Sign(sigHash)
+		if err != nil {
+			return err
 		}
+		signed[i] = [2][]byte{signerPubKey(signer, keyHashes[i]),
+			append(sig.Serialize(), byte(txscript.SigHashAll))}
+		numSigs++
+	}
 
-		// Add the block information to the result if there is any.
-		if blkHeader != nil {
-			// This is not a typo, they are identical in Bitcoin
-			// Core as well.
-			result.Time = blkHeader.Timestamp.Unix()
-			result.Blocktime = blkHeader.Timestamp.Unix()
-			result.BlockHash = blkHashStr
-			result.Confirmations = uint64(1 + best.Height - blkHeight)
+	builder := txscript.NewScriptBuilder()
+	numSigs = 0
+	for _, pair := range signed {
+		if pair[0] == nil || numSigs == requiredSigs {
+			continue
 		}
+		builder.AddData(pair[0]).AddData(pair[1])
+		numSigs++
+	}
+	txIn.SignatureScript, err = builder.Script()
+	if err != nil {
+		return err
+	}
+	if numSigs < requiredSigs {
+		return fmt.Errorf("input has %d of %d required signatures",
+			numSigs, requiredSigs)
 	}
 
-	return srtList, nil
+	vm, err := txscript.NewEngine(resolved, mtx, idx, scriptFlags, nil,
+		sigHashes, amount)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
 }
 
-// handleSendRawTransaction implements the sendrawtransaction command.
-func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.SendRawTransactionCmd)
-	// Deserialize and send off to tx relay
-	hexStr := c.HexTx
-	if len(hexStr)%2 != 0 {
-		hexStr = "0" + hexStr
-	}
-	serializedTx, err := hex.DecodeString(hexStr)
+// decodeMessageAddress decodes the address of a signed message, ensuring it is
+// for the network the server is on.
+func decodeMessageAddress(s *rpcServer, encodedAddr string) (provautil.Address, error) {
+	addr, err := provautil.DecodeAddress(encodedAddr, s.server.chainParams)
 	if err != nil {
-		return nil, rpcDecodeHexError(hexStr)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
 	}
-	var msgTx wire.MsgTx
-	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
-	if err != nil {
+	if !addr.IsForNet(s.server.chainParams) {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDeserialization,
-			Message: "TX decode failed: " + err.Error(),
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + encodedAddr +
+				" is for the wrong network",
 		}
 	}
+	return addr, nil
+}
 
-	// User 0 for the tag to represent local node
-	tx := provautil.NewTx(&msgTx)
-	acceptedTxs, err := s.server.txMemPool.ProcessTransaction(tx, false, false, 0)
+// messageSignerResult returns the result describing the key of an address
+// which signed a message.
+func messageSignerResult(signer *provautil.MessageSigner) *btcjson.MessageSignerResult {
+	result := &btcjson.MessageSignerResult{
+		PubKey: hex.EncodeToString(signer.PubKey.SerializeCompressed()),
+	}
+	if signer.IsHolder() {
+		holder := signer.Holder
+		result.Holder = &holder
+	} else {
+		keyID := uint32(signer.KeyID)
+		result.KeyID = &keyID
+	}
+	return result
+}
+
+// handleSignMessageWithPrivKey implements the signmessagewithprivkey command.
+// The key must be one of the keys the address is satisfied by, either a holder
+// key or one of the ASP keys named by the address, and the result tells which.
+func handleSignMessageWithPrivKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SignMessageWithPrivKeyCmd)
+
+	wif, err := provautil.DecodeWIF(c.PrivKey)
 	if err != nil {
-		// When the error is a rule error, it means the transaction was
-		// simply rejected as opposed to something actually going wrong,
-		// so log it as such.  Otherwise, something really did go wrong,
-		// so log it as an actual error.  In both cases, a JSON-RPC
-		// error is returned to the client with the deserialization
-		// error code (to match bitcoind behavior).
-		if _, ok := err.(mempool.RuleError); ok {
-			rpcsLog.Debugf("Rejected transaction %v: %v", tx.Hash(),
-				err)
-		} else {
-			rpcsLog.Errorf("Failed to process transaction %v: %v",
-				tx.Hash(), err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid private key: " + err.Error(),
 		}
+	}
+	if !wif.IsForNet(s.server.chainParams) {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDeserialization,
-			Message: "TX rejected: " + err.Error(),
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Private key is for the wrong network",
 		}
 	}
-
-	// When the transaction was accepted it should be the first item in the
-	// returned array of accepted transactions.  The only way this will not
-	// be true is if the API for ProcessTransaction changes and this code is
-	// not properly updated, but ensure the condition holds as a safeguard.
-	//
-	// Also, since an error is being returned to the caller, ensure the
-	// transaction is removed from the memory pool.
-	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.server.txMemPool.RemoveTransaction(tx, true)
-
-		errStr := fmt.Sprintf("transaction %v is not in accepted list",
-			tx.Hash())
-		return nil, internalRPCError(errStr, "")
+	addr, err := decodeMessageAddress(s, c.Address)
+	if err != nil {
+		return nil, err
 	}
 
-	s.server.AnnounceNewTransactions(acceptedTxs)
+	sig, err := provautil.SignMessage(wif, c.Message)
+	if err != nil {
+		context := "Failed to sign message"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-	// Keep track of all the sendrawtransaction request txns so that they
-	// can be rebroadcast if they don't make their way into a block.
-	txD := acceptedTxs[0]
-	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
-	s.server.AddRebroadcastInventory(iv, txD)
+	// Recover the signer from the signature to ensure the key is one of
+	// those of the address and to tell the caller which one it is.
+	signer, err := provautil.VerifyMessage(addr, sig, c.Message,
+		s.chain.KeyIDs())
+	switch err {
+	case nil:
+	case provautil.ErrMessageSigner:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Private key is neither a holder key nor an " +
+				"ASP key of the address",
+		}
+	case provautil.ErrMessageAddress:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Address does not support message signing",
+		}
+	default:
+		context := "Failed to verify signed message"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-	return tx.Hash().String(), nil
+	return &btcjson.SignMessageWithPrivKeyResult{
+		Signature: base64.StdEncoding.EncodeToString(sig),
+		Signer:    *messageSignerResult(signer),
+	}, nil
 }
 
-// handleSetGenerate implements the setgenerate command.
-func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.SetGenerateCmd)
+// handleSignRawTransaction implements the signrawtransaction command.
+func handleSignRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SignRawTransactionCmd)
 
-	// Disable generation regardless of the provided generate flag if the
-	// maximum number of threads (goroutines for our purposes) is 0.
-	// Otherwise enable or disable it depending on the provided flag.
-	generate := c.Generate
-	genProcLimit := -1
-	if c.GenProcLimit != nil {
-		genProcLimit = *c.GenProcLimit
+	// Prova signatures always commit to the entire transaction.
+	if c.Flags != nil && *c.Flags != "ALL" {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid sighash param -- only ALL is supported",
+		}
 	}
-	if genProcLimit == 0 {
-		generate = false
+	if c.PrivKeys == nil || len(*c.PrivKeys) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Private keys must be provided since there is no wallet",
+		}
 	}
 
-	if !generate {
-		s.server.cpuMiner.Stop()
-
-		return nil, nil
+	// Deserialize the transaction.
+	hexStr := c.RawTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
 	}
-
-	// Respond with an error if there are no addresses to pay the
-	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No payment addresses specified " +
-				"via --miningaddr",
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
 		}
 	}
 
-	// Attempt to establish validate keys from the environment var if there
-	// are none already registered.
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
-		s.server.cpuMiner.EstablishValidateKeys()
+	// Index the keys by the hash of the public key they sign with, which
+	// is what scripts reference them by.
+	keys := make(map[string]btcec.Signer, len(*c.PrivKeys))
+	for _, encoded := range *c.PrivKeys {
+		wif, err := provautil.DecodeWIF(encoded)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid private key: " + err.Error(),
+			}
+		}
+		if !wif.IsForNet(s.server.chainParams) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Private key is for the wrong network",
+			}
+		}
+		keys[string(provautil.Hash160(wif.SerializePubKey()))] = wif.PrivKey
 	}
 
-	// Respond with an error if there are no validate keys available to
-	// sign the created blocks.
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No validating priv keys specified " +
-				"via --setvalidatekeys or PROVA_VALIDATE_KEYS" +
-				"env variable",
+	// Outputs passed by the caller take precedence over the unspent
+	// outputs of the main chain, which allows signing transactions which
+	// spend outputs that are not confirmed yet.
+	type prevOutput struct {
+		pkScript []byte
+		amount   *float64
+	}
+	prevOuts := make(map[wire.OutPoint]prevOutput)
+	if c.Inputs != nil {
+		for _, input := range *c.Inputs {
+			txHash, err := chainhash.NewHashFromStr(input.Txid)
+			if err != nil {
+				return nil, rpcDecodeHexError(input.Txid)
+			}
+			pkScript, err := hex.DecodeString(input.ScriptPubKey)
+			if err != nil {
+				return nil, rpcDecodeHexError(input.ScriptPubKey)
+			}
+			outPoint := wire.OutPoint{Hash: *txHash, Index: input.Vout}
+			prevOuts[outPoint] = prevOutput{pkScript, input.Amount}
 		}
 	}
 
-	// It's safe to call start even if it's already started.
-	s.server.cpuMiner.SetNumWorkers(int32(genProcLimit))
-	s.server.cpuMiner.Start()
-
-	return nil, nil
-}
-
-// handleSetValidateKeys implements the setvalidatekeys command.
-func handleSetValidateKeys(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.SetValidateKeysCmd)
-
-	if c.PrivKeys == nil || len(c.PrivKeys) == 0 {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
-			Message: "No validate keys provided",
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(s.chain.KeyIDs())
+	keyView.SetKeys(s.chain.AdminKeySets())
+	keyView.SetAdminThresholds(s.server.chainParams.AdminThresholds)
+	sigHashes := txscript.NewTxSigHashes(&mtx)
+	var signErrors []btcjson.SignRawTransactionError
+	for i, txIn := range mtx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		output, ok := prevOuts[*prevOut]
+		if !ok || output.amount == nil {
+			entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+			if err != nil {
+				context := "Failed to fetch utxo"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCNoTxInfo,
+					Message: fmt.Sprintf("No unspent output %v "+
+						"-- its script and amount must be "+
+						"provided", prevOut),
+				}
+			}
+			if !ok {
+				output.pkScript = entry.PkScriptByIndex(prevOut.Index)
+			}
+			amount := provautil.Amount(entry.AmountByIndex(prevOut.Index))
+			rmg := amount.ToRMG()
+			output.amount = &rmg
 		}
-	}
-	validateKeys := make([]*btcec.PrivateKey, len(c.PrivKeys))
-	for i, privKeyStr := range c.PrivKeys {
-		privKeyBytes, err := hex.DecodeString(privKeyStr)
+		amount, err := provautil.NewAmount(*output.amount)
 		if err != nil {
-			return nil, rpcDecodeHexError(privKeyStr)
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid amount: " + err.Error(),
+			}
+		}
+
+		err = signRawTxInput(s, &mtx, i, sigHashes, output.pkScript,
+			int64(amount), keys, keyView)
+		if err != nil {
+			signErrors = append(signErrors, btcjson.SignRawTransactionError{
+				TxID:      prevOut.Hash.String(),
+				Vout:      prevOut.Index,
+				ScriptSig: hex.EncodeToString(txIn.SignatureScript),
+				Sequence:  txIn.Sequence,
+				Error:     err.Error(),
+			})
 		}
-		privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
-		validateKeys[i] = privKey
 	}
-	s.server.cpuMiner.SetValidateKeys(validateKeys)
 
-	return nil, nil
+	mtxHex, err := messageToHex(&mtx)
+	if err != nil {
+		return nil, err
+	}
+	return &btcjson.SignRawTransactionResult{
+		Hex:      mtxHex,
+		Complete: len(signErrors) == 0,
+		Errors:   signErrors,
+	}, nil
 }
 
 // handleStop implements the stop command.
@@ -3361,6 +7047,86 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleUpdatePSPT handles updatepspt commands.
+func handleUpdatePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.UpdatePSPTCmd)
+
+	p, err := decodePSPT(c.PSPT)
+	if err != nil {
+		return nil, err
+	}
+	if int(c.InputIndex) >= len(p.Inputs) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Input index %d is out of range for "+
+				"a transaction with %d inputs", c.InputIndex,
+				len(p.Inputs)),
+		}
+	}
+	pubKey, err := hex.DecodeString(c.PubKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.PubKey)
+	}
+	sig, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Signature)
+	}
+
+	// Only accept signatures from the keys which are able to spend the
+	// output.
+	authorized, err := psptSignerAuthorized(s,
+		p.Inputs[c.InputIndex].PkScript, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Public key %x is not able to sign "+
+				"input %d", pubKey, c.InputIndex),
+		}
+	}
+
+	err = p.AddSignature(int(c.InputIndex), pubKey, sig)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to add signature: " + err.Error(),
+		}
+	}
+	return encodePSPT(p)
+}
+
+// psptSignerAuthorized returns whether the passed serialized public key is
+// able to sign an input spending the passed public key script, resolving its
+// keyIDs and admin threads against the current chain state.
+func psptSignerAuthorized(s *rpcServer, pkScript, pubKey []byte) (bool, error) {
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(s.chain.KeyIDs())
+	keyView.SetKeys(s.chain.AdminKeySets())
+	keyView.SetAdminThresholds(s.server.chainParams.AdminThresholds)
+	resolved, err := blockchain.ResolvePkScript(pkScript, keyView,
+		s.scriptFlags())
+	if err != nil {
+		return false, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to resolve script: " + err.Error(),
+		}
+	}
+	pushes, err := txscript.PushedData(resolved)
+	if err != nil {
+		context := "Failed to parse script"
+		return false, internalRPCError(err.Error(), context)
+	}
+	pubKeyHash := provautil.Hash160(pubKey)
+	for _, push := range pushes {
+		if bytes.Equal(push, pubKeyHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // handleValidateAddress implements the validateaddress command.
 func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.ValidateAddressCmd)
@@ -3413,6 +7179,71 @@ func verifyChain(s *rpcServer, level int32, depth uint32) error {
 	return nil
 }
 
+// handleVerifyAttestation implements the verifyattestation command.  Besides
+// checking the signature, it reports whether the attestation was signed by an
+// active validate key and is of a block of the local main chain, so auditors
+// can compare the attestations of several validators against a node they run.
+func handleVerifyAttestation(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyAttestationCmd)
+
+	serialized, err := hex.DecodeString(c.Hex)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hex)
+	}
+	var a attestation.Attestation
+	if err := a.Deserialize(bytes.NewReader(serialized)); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Attestation decode failed: " + err.Error(),
+		}
+	}
+	decoded, err := attestationResult(&a)
+	if err != nil {
+		context := "Failed to serialize the attestation"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.VerifyAttestationResult{
+		Attestation: *decoded,
+		Valid:       a.Verify(),
+	}
+	pubKey, err := btcec.ParsePubKey(a.PubKey[:], btcec.S256())
+	if err == nil {
+		validateKeys := s.chain.AdminKeySets()[btcec.ValidateKeySet]
+		result.Validator = validateKeys.Pos(pubKey) >= 0
+	}
+	if a.Net == s.server.chainParams.Net {
+		hash, err := s.chain.BlockHashByHeight(a.Height)
+		result.MainChain = err == nil && *hash == a.BlockHash
+	}
+	return result, nil
+}
+
+// handleVerifyAuditLog implements the verifyauditlog command.
+func handleVerifyAuditLog(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.auditLog == nil {
+		return nil, auditLogUnavailable()
+	}
+
+	_, lastHash := s.auditLog.Last()
+	n, err := s.auditLog.Verify()
+	result := &btcjson.VerifyAuditLogResult{
+		Valid:    err == nil,
+		Entries:  n,
+		LastHash: lastHash,
+	}
+	switch err := err.(type) {
+	case nil:
+	case *auditlog.VerifyError:
+		result.InvalidEntry = err.Seq
+		result.Error = err.Reason
+	default:
+		context := "Failed to verify the audit log"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return result, nil
+}
+
 // handleVerifyChain implements the verifychain command.
 func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyChainCmd)
@@ -3429,6 +7260,108 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return err == nil, nil
 }
 
+// handleVerifyMessage implements the verifymessage command.  The ASP keys named
+// by Prova addresses are those of the best chain, so a message signed by an ASP
+// key no longer verifies once the key id is reassigned.
+func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyMessageCmd)
+
+	addr, err := decodeMessageAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCParse.Code,
+			Message: "Malformed base64 encoding: " + err.Error(),
+		}
+	}
+
+	signer, err := provautil.VerifyMessage(addr, sig, c.Message,
+		s.chain.KeyIDs())
+	if err == provautil.ErrMessageAddress {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Address does not support message signing",
+		}
+	}
+	if c.Verbose == nil || !*c.Verbose {
+		return err == nil, nil
+	}
+	if err != nil {
+		return &btcjson.VerifyMessageResult{Error: err.Error()}, nil
+	}
+	return &btcjson.VerifyMessageResult{
+		Verified: true,
+		Signer:   messageSignerResult(signer),
+	}, nil
+}
+
+// decodeTxOutProof decodes the passed hex-encoded proof of the gettxoutproof
+// command and returns its header along with the hashes of the transactions it
+// proves the inclusion of.  An error is returned when the partial merkle tree
+// does not hash to the merkle root of the header or the header isn't signed by
+// its validating public key.
+func decodeTxOutProof(proof string) (*wire.BlockHeader, []*chainhash.Hash, error) {
+	serialized, err := hex.DecodeString(proof)
+	if err != nil {
+		return nil, nil, rpcDecodeHexError(proof)
+	}
+	var merkleBlock wire.MsgMerkleBlock
+	err = merkleBlock.BtcDecode(bytes.NewReader(serialized), maxProtocolVersion)
+	if err != nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Proof decode failed: " + err.Error(),
+		}
+	}
+
+	txHashes, _, err := bloom.MerkleBlockMatches(&merkleBlock)
+	if err != nil {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid proof: " + err.Error(),
+		}
+	}
+	header := &merkleBlock.Header
+	pubKey, err := btcec.ParsePubKey(header.ValidatingPubKey[:], btcec.S256())
+	if err != nil || !header.Verify(pubKey) {
+		return nil, nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid proof: the block signature is invalid",
+		}
+	}
+	return header, txHashes, nil
+}
+
+// handleVerifyTxOutProof implements the verifytxoutproof command.  The hashes
+// of the transactions the proof commits to are returned when its block is in
+// the main chain, and none otherwise.
+func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyTxOutProofCmd)
+
+	header, txHashes, err := decodeTxOutProof(c.Proof)
+	if err != nil {
+		return nil, err
+	}
+	blkHash := header.BlockHash()
+	mainChain, err := s.chain.MainChainHasBlock(&blkHash)
+	if err != nil {
+		context := "Failed to look up block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	txids := make([]string, 0, len(txHashes))
+	if !mainChain {
+		return txids, nil
+	}
+	for _, txHash := range txHashes {
+		txids = append(txids, txHash.String())
+	}
+	return txids, nil
+}
+
 // rpcServer holds the items the rpc server may need to access (config,
 // shutdown, main server, etc.)
 type rpcServer struct {
@@ -3437,8 +7370,12 @@ type rpcServer struct {
 	generator              *mining.BlkTmplGenerator
 	server                 *server
 	chain                  *blockchain.BlockChain
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	spv                    *spvNode
+	usersMtx               sync.RWMutex
+	users                  []*rpcUser
+	certUsers              map[string]*rpcUser
+	metrics                *rpcMetrics
+	auditLog               *auditlog.Log
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
 	statusLines            map[int]string
@@ -3506,7 +7443,22 @@ func (s *rpcServer) writeHTTPResponseHeaders(req *http.Request, headers http.Hea
 	return err
 }
 
-// Stop is used by server.go to stop the rpc listener.
+// errRPCShuttingDown is the error of the calls made while the RPC server waits
+// for the calls in progress to finish on shutdown.
+var errRPCShuttingDown = &btcjson.RPCError{
+	Code:    btcjson.ErrRPCMisc,
+	Message: "Server is shutting down",
+}
+
+// shuttingDown returns whether the RPC server is shutting down, in which case
+// no new calls are served.
+func (s *rpcServer) shuttingDown() bool {
+	return atomic.LoadInt32(&s.shutdown) != 0
+}
+
+// Stop is used by server.go to stop the rpc listener.  The calls in progress,
+// including those of websocket clients, are finished before the websocket
+// clients are disconnected, while new calls are refused.
 func (s *rpcServer) Stop() error {
 	if atomic.AddInt32(&s.shutdown, 1) != 1 {
 		rpcsLog.Infof("RPC server is already in the process of shutting down")
@@ -3520,10 +7472,18 @@ func (s *rpcServer) Stop() error {
 			return err
 		}
 	}
+	<-s.metrics.idleChan()
 	s.ntfnMgr.Shutdown()
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
 	s.wg.Wait()
+
+	// The cookie is no longer valid once the server stopped.
+	if !cfg.NoRPCCookie {
+		if err := os.Remove(cfg.RPCCookieFile); err != nil {
+			rpcsLog.Errorf("Failed to remove RPC cookie file: %v", err)
+		}
+	}
 	rpcsLog.Infof("RPC server shutdown complete")
 	return nil
 }
@@ -3576,40 +7536,45 @@ func (s *rpcServer) decrementClients() {
 //
 // This check is time-constant.
 //
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
+// Clients presenting a client certificate issued by --rpcclientca are instead
+// authenticated by the common name of the certificate.
+//
+// The returned user is the authenticated user, which determines the methods
+// the client may call.  It is nil when no authentication was supplied and it
+// is not required.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (*rpcUser, error) {
+	// A verified client certificate authenticates the user whose
+	// identity it carries, regardless of any credentials in the header.
+	if r.TLS != nil && len(r.TLS.VerifiedChains) != 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		user := s.authenticateCert(cert)
+		if user == nil {
+			rpcsLog.Warnf("RPC authentication failure from %s: "+
+				"client certificate %q is not of any user",
+				r.RemoteAddr, cert.Subject.CommonName)
+			return nil, errors.New("auth failure")
+		}
+		return user, nil
+	}
+
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
 		if require {
 			rpcsLog.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	user := s.authenticate(authhdr[0])
+	if user == nil {
+		// Request's auth doesn't match any user
+		rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
+		return nil, errors.New("auth failure")
 	}
-
-	// Request's auth doesn't match either user
-	rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, errors.New("auth failure")
+	return user, nil
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
@@ -3627,6 +7592,12 @@ type parsedRPCCmd struct {
 // commands which are not recognized or not implemented will return an error
 // suitable for use in replies.
 func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct{}) (interface{}, error) {
+	if s.spv != nil {
+		return s.spvCmdResult(cmd, closeChan)
+	}
+	if _, ok := rpcReadOnlyRefused[cmd.method]; ok && cfg.ReadOnly {
+		return nil, ErrRPCReadOnly
+	}
 	handler, ok := rpcHandlers[cmd.method]
 	if ok {
 		goto handled
@@ -3656,6 +7627,12 @@ func parseCmd(request *btcjson.Request) *parsedRPCCmd {
 	parsedCmd.id = request.ID
 	parsedCmd.method = request.Method
 
+	// Older clients pass a boolean verbose flag to getblock instead of a
+	// verbosity level.
+	if request.Method == "getblock" {
+		request.Params = legacyGetBlockParams(request.Params)
+	}
+
 	cmd, err := btcjson.UnmarshalCmd(request)
 	if err != nil {
 		// When the error is because the method is not registered,
@@ -3695,7 +7672,7 @@ func createMarshalledReply(id, result interface{}, replyErr error) ([]byte, erro
 }
 
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, user *rpcUser) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
@@ -3783,14 +7760,20 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 			}
 		}()
 
-		// Check if the user is limited and set error if method unauthorized
-		if !isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr = &btcjson.RPCError{
-					Code:    btcjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-			}
+		// Set error if the user lacks the permission of the method or
+		// exceeded the rate limit of its class.
+		if !user.authorized(request.Method) {
+			jsonErr = rpcUnauthorizedError(request.Method)
+		} else if s.shuttingDown() {
+			jsonErr = errRPCShuttingDown
+		} else if !s.metrics.allow(user,
+			rpcMethodPermission(request.Method), request.Method,
+			time.Now()) {
+
+			jsonErr = rpcRateLimitedError(request.Method)
+		} else {
+			s.auditCall(user, request.Method, request.Params,
+				r.RemoteAddr)
 		}
 
 		if jsonErr == nil {
@@ -3800,7 +7783,10 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 			if parsedCmd.err != nil {
 				jsonErr = parsedCmd.err
 			} else {
+				callID := s.metrics.startCall(user, parsedCmd.method,
+					parsedCmd.cmd)
 				result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
+				s.metrics.finishCall(callID, jsonErr != nil)
 			}
 		}
 	}
@@ -3862,37 +7848,50 @@ func (s *rpcServer) Start() {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
-		_, isAdmin, err := s.checkAuth(r, true)
+		user, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, user)
 	})
 
-	// Websocket endpoint.
-	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
-		if err != nil {
-			jsonAuthFail(w)
-			return
-		}
+	// Unauthenticated REST endpoint when enabled.
+	if cfg.REST {
+		rpcServeMux.HandleFunc(restPathPrefix, s.handleREST)
+	}
 
-		// Attempt to upgrade the connection to a websocket connection
-		// using the default size for read/write buffers.
-		ws, err := websocket.Upgrade(w, r, nil, 0, 0)
-		if err != nil {
-			if _, ok := err.(websocket.HandshakeError); !ok {
-				rpcsLog.Errorf("Unexpected websocket error: %v",
-					err)
+	// Unauthenticated health endpoints when enabled.
+	if cfg.Health {
+		rpcServeMux.HandleFunc(healthzPath, s.handleHealthz)
+		rpcServeMux.HandleFunc(readyzPath, s.handleReadyz)
+	}
+
+	// Websocket endpoint, which is not served in light client mode.
+	if s.spv == nil {
+		rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+			user, err := s.checkAuth(r, false)
+			if err != nil {
+				jsonAuthFail(w)
+				return
 			}
-			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
-			return
-		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
-	})
+
+			// Attempt to upgrade the connection to a websocket connection
+			// using the default size for read/write buffers.
+			ws, err := websocket.Upgrade(w, r, nil, 0, 0)
+			if err != nil {
+				if _, ok := err.(websocket.HandshakeError); !ok {
+					rpcsLog.Errorf("Unexpected websocket error: %v",
+						err)
+				}
+				http.Error(w, "400 Bad Request.", http.StatusBadRequest)
+				return
+			}
+			s.WebsocketHandler(ws, r.RemoteAddr, user)
+		})
+	}
 
 	for _, listener := range s.listeners {
 		s.wg.Add(1)
@@ -3937,23 +7936,30 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 		server:                 s,
 		generator:              generator,
 		chain:                  s.blockManager.chain,
+		auditLog:               s.auditLog,
 		statusLines:            make(map[int]string),
 		gbtWorkState:           newGbtWorkState(s.timeSource),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
-		quit: make(chan int),
+		quit:                   make(chan int),
 	}
-	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
+	if err := rpc.initListeners(listenAddrs); err != nil {
+		return nil, err
 	}
-	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+	return &rpc, nil
+}
+
+// initListeners sets up the users, the metrics, the websocket notification
+// manager and the listeners of the RPC server, and writes the cookie file.  It
+// is shared by the full node and the light client RPC servers.
+func (s *rpcServer) initListeners(listenAddrs []string) error {
+	s.users = append([]*rpcUser(nil), cfg.rpcUsers...)
+	s.certUsers = make(map[string]*rpcUser, len(cfg.rpcCertUsers))
+	for _, user := range cfg.rpcCertUsers {
+		s.certUsers[user.name] = user
 	}
-	rpc.ntfnMgr = newWsNotificationManager(&rpc)
+	s.metrics = newRPCMetrics(cfg.rpcLimits, cfg.RPCSlowQuery)
+	s.ntfnMgr = newWsNotificationManager(s)
 
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
@@ -3963,12 +7969,12 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 		if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
 			err := genCertPair(cfg.RPCCert, cfg.RPCKey)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 		keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		tlsConfig := tls.Config{
@@ -3976,6 +7982,20 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 			MinVersion:   tls.VersionTLS12,
 		}
 
+		// Verify the client certificates clients authenticate with
+		// when a certificate authority is configured.
+		if cfg.RPCClientCA != "" {
+			pool, err := loadRPCClientCAs(cfg.RPCClientCA)
+			if err != nil {
+				return err
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			if cfg.RPCRequireClientCert {
+				tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+
 		// Change the standard net.Listen function to the tls one.
 		listenFunc = func(net string, laddr string) (net.Listener, error) {
 			return tls.Listen(net, laddr, &tlsConfig)
@@ -3986,7 +8006,7 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 	// factored into something shared.
 	ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners(listenAddrs)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	listeners := make([]net.Listener, 0,
 		len(ipv6ListenAddrs)+len(ipv4ListenAddrs))
@@ -4007,13 +8027,38 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 		}
 		listeners = append(listeners, listener)
 	}
+
+	for _, path := range cfg.RPCUnixListeners {
+		listener, err := listenRPCUnix(path, cfg.rpcUnixSocketMode)
+		if err != nil {
+			rpcsLog.Warnf("Can't listen on %s: %v", path, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
 	if len(listeners) == 0 {
-		return nil, errors.New("RPCS: No valid listen address")
+		return errors.New("RPCS: No valid listen address")
 	}
 
-	rpc.listeners = listeners
+	s.listeners = listeners
 
-	return &rpc, nil
+	// Write the credentials of the cookie user to the cookie file, so local
+	// clients can authenticate by reading it.
+	if !cfg.NoRPCCookie {
+		pass, err := generateRPCPassword()
+		if err != nil {
+			return err
+		}
+		if err := writeRPCCookie(cfg.RPCCookieFile, pass); err != nil {
+			return err
+		}
+		s.users = append(s.users, newRPCUser(rpcCookieUser, pass,
+			rpcPermAll))
+		rpcsLog.Infof("RPC authentication cookie written to %s",
+			cfg.RPCCookieFile)
+	}
+
+	return nil
 }
 
 func init() {
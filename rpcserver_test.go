@@ -0,0 +1,55 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcjson"
+)
+
+// TestLegacyGetBlockParams ensures the boolean verbose flag of older getblock
+// clients is parsed as the equivalent verbosity level.
+func TestLegacyGetBlockParams(t *testing.T) {
+	tests := []struct {
+		params    string
+		verbosity int
+		verboseTx bool
+	}{
+		{`["123"]`, 1, false},
+		{`["123",false]`, 0, false},
+		{`["123",true]`, 1, false},
+		{`["123",true,true]`, 1, true},
+		{`["123",0]`, 0, false},
+		{`["123",2]`, 2, false},
+	}
+
+	for _, test := range tests {
+		var request btcjson.Request
+		request.Method = "getblock"
+		request.ID = 1
+		if err := json.Unmarshal([]byte(test.params), &request.Params); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		parsed := parseCmd(&request)
+		if parsed.err != nil {
+			t.Errorf("parseCmd(%s): unexpected error %v", test.params,
+				parsed.err)
+			continue
+		}
+		want := &btcjson.GetBlockCmd{
+			Hash:      "123",
+			Verbosity: btcjson.Int(test.verbosity),
+			VerboseTx: btcjson.Bool(test.verboseTx),
+		}
+		if !reflect.DeepEqual(parsed.cmd, want) {
+			t.Errorf("parseCmd(%s): got %+v, want %+v", test.params,
+				parsed.cmd, want)
+		}
+	}
+}
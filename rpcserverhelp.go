@@ -30,11 +30,36 @@ var helpDescsEnUS = map[string]string{
 	"debuglevel--result0":    "The string 'Done.'",
 	"debuglevel--result1":    "The list of subsystems",
 
+	// GetLogLevelsCmd help.
+	"getloglevels--synopsis": "Returns the format of the log messages and the current logging level of each subsystem.\n" +
+		"The levels are changed at runtime with the debuglevel command.",
+
+	// GetLogLevelsResult help.
+	"getloglevelsresult-format":        "The format of the log messages, text or json",
+	"getloglevelsresult-levels":        "The logging level of each subsystem",
+	"getloglevelsresult-levels--key":   "subsystem",
+	"getloglevelsresult-levels--value": "level",
+	"getloglevelsresult-levels--desc":  "The subsystem as the key and its logging level as the value",
+
 	// AddNodeCmd help.
 	"addnode--synopsis": "Attempts to add or remove a persistent peer.",
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// BroadcastTransactionCmd help.
+	"broadcasttransaction--synopsis": "Submits a serialized, hex-encoded transaction to the network and tracks its propagation and confirmation in a broadcast campaign.\n" +
+		"The campaign is final once the transaction, or a transaction conflicting with it, has the target confirmations.\n" +
+		"Its status is returned by getbroadcaststatus and its final status is sent to websocket clients registered with notifybroadcasts.",
+	"broadcasttransaction-hextx":       "Serialized, hex-encoded signed transaction",
+	"broadcasttransaction-targetconfs": "Number of confirmations after which the campaign is final",
+
+	// BroadcastTransactionResult help.
+	"broadcasttransactionresult-id":   "The ID of the broadcast campaign",
+	"broadcasttransactionresult-txid": "The hash of the transaction",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans of IP addresses and subnets.",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -45,10 +70,214 @@ var helpDescsEnUS = map[string]string{
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
 
+	// CombinePSPTCmd help.
+	"combinepspt--synopsis": "Combines the signatures collected by several copies of a partially signed transaction (PSPT).",
+	"combinepspt-pspts":     "The base64-encoded PSPTs to combine, which must all be for the same transaction",
+	"combinepspt--result0":  "The base64-encoded PSPT holding all of the signatures",
+
+	// AdminKeyOp help.
+	"adminkeyop-keyset": "The key set of the key (PROVISION, ISSUE, VALIDATE or ASP)",
+	"adminkeyop-pubkey": "The hex-encoded compressed public key",
+	"adminkeyop-keyid":  "The keyID of an ASP key; when adding ASP keys it defaults to the next keyID in sequence",
+
+	// AdminTxResult help.
+	"admintxresult-txid":      "The hash of the admin transaction",
+	"admintxresult-hex":       "Hex-encoded bytes of the serialized transaction",
+	"admintxresult-complete":  "Whether the transaction is fully signed by the admin keys configured with --adminkey",
+	"admintxresult-submitted": "Whether the transaction was accepted to the memory pool and relayed to the network",
+	"admintxresult-errors":    "The inputs which are not fully signed yet, when the node is configured with admin keys",
+
+	// AdminListKeySetsCmd help.
+	"admin.listkeysets--synopsis": "Returns the current keys of the ROOT, PROVISION, ISSUE, VALIDATE and ASP key sets.",
+
+	// AdminListKeySetsResult help.
+	"adminlistkeysetsresult-hash":      "Hash of the best block at which the key sets are valid",
+	"adminlistkeysetsresult-height":    "Height of the best block at which the key sets are valid",
+	"adminlistkeysetsresult-lastkeyid": "Last keyID assigned to an ASP key",
+	"adminlistkeysetsresult-root":      "The hex-encoded root public keys",
+	"adminlistkeysetsresult-provision": "The hex-encoded provision public keys",
+	"adminlistkeysetsresult-issue":     "The hex-encoded issue public keys",
+	"adminlistkeysetsresult-validate":  "The hex-encoded validate public keys",
+	"adminlistkeysetsresult-asp":       "The ASP public keys by keyID, in keyID order",
+
+	// AdminGetKeyIDInfoCmd help.
+	"admin.getkeyidinfo--synopsis": "Returns whether an assigned keyID is active and the ASP public key it refers to.",
+	"admin.getkeyidinfo-keyid":     "The keyID",
+
+	// AdminKeyIDInfoResult help.
+	"adminkeyidinforesult-keyid":  "The keyID",
+	"adminkeyidinforesult-active": "Whether the ASP key of the keyID is still provisioned; revoked keyIDs are never reassigned",
+	"adminkeyidinforesult-frozen": "Whether the keyID is frozen; outputs co-signed by frozen keyIDs may only be spent by issue thread transactions",
+	"adminkeyidinforesult-pubkey": "The hex-encoded ASP public key of the keyID while it is active or frozen",
+
+	// AdminProvisionValidateKeyCmd help.
+	"admin.provisionvalidatekey--synopsis": "Creates the provision thread transaction adding a key to the validate key set.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.provisionvalidatekey-pubkey": "The hex-encoded compressed validate public key",
+	"admin.provisionvalidatekey-submit": "Submit the transaction to the network once it is fully signed",
+
+	// AdminRevokeKeyCmd help.
+	"admin.revokekey--synopsis": "Creates the admin transaction revoking a key of a key set.\n" +
+		"Provision and issue keys are revoked on the root thread, validate and ASP keys on the provision thread.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.revokekey-keyop":  "The key to revoke",
+	"admin.revokekey-submit": "Submit the transaction to the network once it is fully signed",
+
+	// AdminRotateKeyIDCmd help.
+	"admin.rotatekeyid--synopsis": "Creates the provision thread transaction assigning a new keyID to a replacement ASP key, along with the unsigned transactions migrating the funds co-signed by an active keyID to the same addresses co-signed by the new keyID.\n" +
+		"The outputs are looked up with the keyID balance index (--keyidbalanceindex), and the migration transactions are only valid once the provision transaction is confirmed.\n" +
+		"Outputs which are timelocked, immature, not standard Prova outputs or not worth migrating are reported as skipped.\n" +
+		"The old keyID remains active until it is revoked with admin.revokekey.\n" +
+		"The provision transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.rotatekeyid-keyid":   "The keyID to rotate",
+	"admin.rotatekeyid-pubkey":  "The hex-encoded compressed replacement ASP public key",
+	"admin.rotatekeyid-feerate": "The fee rate of the migration transactions in RMG/kB (default: the minimum relay fee)",
+	"admin.rotatekeyid-submit":  "Submit the provision transaction to the network once it is fully signed",
+
+	// AdminRotateKeyIDResult help.
+	"adminrotatekeyidresult-keyid":     "The rotated keyID",
+	"adminrotatekeyidresult-newkeyid":  "The keyID assigned to the replacement ASP key",
+	"adminrotatekeyidresult-provision": "The provision thread transaction assigning the new keyID",
+	"adminrotatekeyidresult-sweeps":    "The unsigned transactions migrating the outputs of each address to the new keyID, to be signed by the holders of the addresses",
+	"adminrotatekeyidresult-skipped":   "The outputs co-signed by the keyID which are not migrated",
+
+	// AdminIssueTokensCmd help.
+	"admin.issuetokens--synopsis": "Creates the issue thread transaction issuing funds to the provided addresses.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.issuetokens-amounts":        "JSON object with the destination Prova addresses as keys and amounts as values",
+	"admin.issuetokens-amounts--key":   "address",
+	"admin.issuetokens-amounts--value": "n.nnn",
+	"admin.issuetokens-amounts--desc":  "The destination address as the key and the amount in RMG as the value",
+	"admin.issuetokens-submit":         "Submit the transaction to the network once it is fully signed",
+
+	// AdminDestroyTokensCmd help.
+	"admin.destroytokens--synopsis": "Creates the issue thread transaction destroying funds held by the provided inputs.\n" +
+		"Any value of the inputs which is not destroyed is paid to the change address.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.destroytokens-inputs":        "The inputs holding the funds to destroy",
+	"admin.destroytokens-amount":        "The amount to destroy in RMG",
+	"admin.destroytokens-changeaddress": "The Prova address the remaining value of the inputs is paid to",
+	"admin.destroytokens-submit":        "Submit the transaction to the network once it is fully signed",
+
+	// AdminFreezeKeyIDCmd help.
+	"admin.freezekeyid--synopsis": "Creates the issue thread transaction freezing an active keyID.\n" +
+		"Outputs co-signed by a frozen keyID may only be spent by issue thread transactions, such as those created by admin.destroytokens, which recover the funds.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.freezekeyid-keyid":  "The keyID to freeze",
+	"admin.freezekeyid-submit": "Submit the transaction to the network once it is fully signed",
+
+	// AdminUnfreezeKeyIDCmd help.
+	"admin.unfreezekeyid--synopsis": "Creates the issue thread transaction unfreezing a frozen keyID.\n" +
+		"The transaction is signed with the admin keys configured with --adminkey, if any, and submitted when requested and fully signed.\n" +
+		"The operation is logged for auditing.",
+	"admin.unfreezekeyid-keyid":  "The keyID to unfreeze",
+	"admin.unfreezekeyid-submit": "Submit the transaction to the network once it is fully signed",
+
+	// AdminProposeTxCmd help.
+	"admin.proposetx--synopsis": "Registers an admin transaction for co-signing by the admin keyholders and returns its proposal.\n" +
+		"Signatures the transaction already carries, such as those of the admin keys configured with --adminkey added by the admin commands, are kept.\n" +
+		"Proposals are kept in the database until they are dropped, and the transaction is submitted to the network as soon as every input has the signatures it requires.",
+	"admin.proposetx-hextx":       "The hex-encoded admin transaction, as returned by the admin commands",
+	"admin.proposetx-description": "A description of the operation shown to the keyholders",
+
+	// AdminGetProposalCmd help.
+	"admin.getproposal--synopsis": "Returns the proposal of an admin transaction, including the PSPT the keyholders sign.",
+	"admin.getproposal-txid":      "The hash of the proposed transaction",
+
+	// AdminListProposalsCmd help.
+	"admin.listproposals--synopsis": "Returns every proposal of an admin transaction, oldest first.",
+
+	// AdminSignProposalCmd help.
+	"admin.signproposal--synopsis": "Signs the proposal of an admin transaction with the admin keys configured with --adminkey.\n" +
+		"The transaction is submitted to the network once every input has the signatures it requires.",
+	"admin.signproposal-txid": "The hash of the proposed transaction",
+
+	// AdminAddProposalSigsCmd help.
+	"admin.addproposalsigs--synopsis": "Imports the signatures of a PSPT signed offline, such as with updatepspt, into the proposal of the same admin transaction.\n" +
+		"Only signatures of the keys able to sign each input are accepted, and the PSPT must not be finalized.\n" +
+		"The transaction is submitted to the network once every input has the signatures it requires.",
+	"admin.addproposalsigs-txid": "The hash of the proposed transaction",
+	"admin.addproposalsigs-pspt": "The base64-encoded PSPT carrying the signatures",
+
+	// AdminDropProposalCmd help.
+	"admin.dropproposal--synopsis": "Removes the proposal of an admin transaction.",
+	"admin.dropproposal-txid":      "The hash of the proposed transaction",
+
+	// AdminProposalResult help.
+	"adminproposalresult-txid":        "The hash of the proposed transaction, which identifies the proposal",
+	"adminproposalresult-description": "The description of the operation",
+	"adminproposalresult-created":     "The time the transaction was proposed in seconds since 1 Jan 1970 GMT",
+	"adminproposalresult-pspt":        "The base64-encoded PSPT of the transaction with the signatures collected so far",
+	"adminproposalresult-inputs":      "The signatures collected for each input, in the order of the inputs",
+	"adminproposalresult-complete":    "Whether every input has the signatures it requires",
+	"adminproposalresult-submitted":   "Whether the transaction was accepted to the memory pool and relayed to the network",
+	"adminproposalresult-hex":         "The hex-encoded signed transaction (only when complete)",
+	"adminproposalresult-submiterror": "Why the complete transaction could not be submitted, if it was just completed and failed to be",
+
+	// AdminProposalInputResult help.
+	"adminproposalinputresult-requiredsigs": "The number of signatures the input requires",
+	"adminproposalinputresult-signers":      "The hex-encoded public keys which signed the input",
+	"adminproposalinputresult-finalized":    "Whether the input has the signatures it requires",
+
+	// CreateConsolidateTxCmd help.
+	"createconsolidatetx--synopsis": "Returns a new unsigned transaction spending the smallest spendable outputs of watch-only addresses below a threshold to a single output.\n" +
+		"Outputs which cost more to spend than they are worth are left out, and the fee accounts for the size of the 2-of-3 signatures of every input.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"createconsolidatetx-addresses":   "The watch-only addresses whose outputs are consolidated",
+	"createconsolidatetx-threshold":   "The amount in RMG below which outputs are consolidated",
+	"createconsolidatetx-destination": "The Prova address the consolidated output pays to",
+	"createconsolidatetx-feerate":     "The fee rate in RMG/kB (default: the minimum relay fee)",
+	"createconsolidatetx-maxinputs":   "The maximum number of outputs to consolidate",
+	"createconsolidatetx-minconf":     "The minimum number of confirmations of the consolidated outputs",
+
+	// CreateDestroyTxCmd help.
+	"createdestroytx--synopsis": "Returns a new unsigned issue thread transaction spending the current thread tip and the provided inputs in order to destroy funds.\n" +
+		"Any value of the inputs which is not destroyed is paid to the change address.\n" +
+		"The thread input must be signed by issue keys and the remaining inputs by the keys of the outputs they spend.",
+	"createdestroytx-inputs":        "The inputs holding the funds to destroy",
+	"createdestroytx-amount":        "The amount to destroy in RMG",
+	"createdestroytx-changeaddress": "The Prova address the remaining value of the inputs is paid to",
+	"createdestroytx--result0":      "Hex-encoded bytes of the serialized transaction",
+
+	// CreateIssueTxCmd help.
+	"createissuetx--synopsis": "Returns a new unsigned issue thread transaction spending the current thread tip in order to issue funds to the provided addresses.\n" +
+		"The transaction must be signed by issue keys.",
+	"createissuetx-amounts":        "JSON object with the destination Prova addresses as keys and amounts as values",
+	"createissuetx-amounts--key":   "address",
+	"createissuetx-amounts--value": "n.nnn",
+	"createissuetx-amounts--desc":  "The destination address as the key and the amount in RMG as the value",
+	"createissuetx--result0":       "Hex-encoded bytes of the serialized transaction",
+
+	// CreateKeyRevokeTxCmd help.
+	"createkeyrevoketx--synopsis": "Returns a new unsigned admin transaction spending the current tip of the thread governing the provided key sets in order to revoke the keys.\n" +
+		"Provision and issue keys are revoked on the root thread, validate and ASP keys on the provision thread.\n" +
+		"The transaction must be signed by keys of the thread.",
+	"createkeyrevoketx-keyops":   "The keys to revoke, which must all be governed by the same thread",
+	"createkeyrevoketx--result0": "Hex-encoded bytes of the serialized transaction",
+
+	// CreateProvisionTxCmd help.
+	"createprovisiontx--synopsis": "Returns a new unsigned admin transaction spending the current tip of the thread governing the provided key sets in order to add the keys.\n" +
+		"Provision and issue keys are added on the root thread, validate and ASP keys on the provision thread.\n" +
+		"The transaction must be signed by keys of the thread.",
+	"createprovisiontx-keyops":   "The keys to add, which must all be governed by the same thread",
+	"createprovisiontx--result0": "Hex-encoded bytes of the serialized transaction",
+
+	// CreatePSPTCmd help.
+	"createpspt--synopsis": "Creates a partially signed transaction (PSPT) for an unsigned transaction spending Prova outputs.\n" +
+		"The amounts and scripts of the spent outputs are looked up in the unspent outputs of the main chain.",
+	"createpspt-hextx":    "Serialized, hex-encoded transaction with empty signature scripts",
+	"createpspt--result0": "The base64-encoded PSPT",
+
 	// CreateRawTransactionCmd help.
 	"createrawtransaction--synopsis": "Returns a new transaction spending the provided inputs and sending to the provided addresses.\n" +
 		"The transaction inputs are not signed in the created transaction.\n" +
-		"The signrawtransaction RPC command provided by wallet must be used to sign the resulting transaction.",
+		"The signrawtransaction or PSPT RPC commands must be used to sign the resulting transaction.",
 	"createrawtransaction-inputs":         "The inputs to the transaction",
 	"createrawtransaction-amounts":        "JSON object with the destination addresses as keys and amounts as values",
 	"createrawtransaction-amounts--key":   "address",
@@ -57,6 +286,32 @@ var helpDescsEnUS = map[string]string{
 	"createrawtransaction-locktime":       "Locktime value; a non-zero value will also locktime-activate the inputs",
 	"createrawtransaction--result0":       "Hex-encoded bytes of the serialized transaction",
 
+	// CreateSplitTxCmd help.
+	"createsplittx--synopsis": "Returns a new unsigned transaction splitting an unspent output into a number of outputs paying to an address.\n" +
+		"Without an amount, the value of the output net of the fee is split equally.\n" +
+		"Otherwise every output pays the amount, and the remainder is paid back to the address unless it is dust.\n" +
+		"The fee accounts for the size of the 2-of-3 signatures of the input.",
+	"createsplittx-txid":    "The hash of the transaction of the output to split",
+	"createsplittx-vout":    "The index of the output to split",
+	"createsplittx-address": "The Prova address the outputs pay to",
+	"createsplittx-count":   "The number of outputs",
+	"createsplittx-amount":  "The amount in RMG of every output (default: an equal split)",
+	"createsplittx-feerate": "The fee rate in RMG/kB (default: the minimum relay fee)",
+
+	// CreateSweepTxCmd help.
+	"createsweeptx--synopsis": "Returns a new unsigned transaction spending every spendable output of a watch-only address to a single output.\n" +
+		"The fee accounts for the size of the 2-of-3 signatures of every input.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"createsweeptx-address":     "The watch-only address to sweep",
+	"createsweeptx-destination": "The Prova address the swept output pays to",
+	"createsweeptx-feerate":     "The fee rate in RMG/kB (default: the minimum relay fee)",
+	"createsweeptx-minconf":     "The minimum number of confirmations of the swept outputs",
+
+	// TemplateTxResult help.
+	"templatetxresult-hex":      "The hex-encoded unsigned transaction",
+	"templatetxresult-fee":      "The fee paid by the transaction in RMG",
+	"templatetxresult-prevouts": "The outputs spent by the inputs, in the order of the inputs",
+
 	// ScriptSig help.
 	"scriptsig-asm": "Disassembly of the script",
 	"scriptsig-hex": "Hex-encoded bytes of the script",
@@ -64,6 +319,8 @@ var helpDescsEnUS = map[string]string{
 	// PrevOut help.
 	"prevout-addresses": "previous output addresses",
 	"prevout-value":     "previous output value",
+	"prevout-type":      "previous output script class",
+	"prevout-keyIDs":    "previous output keyIDs (Prova scripts only)",
 
 	// VinPrevOut help.
 	"vinprevout-coinbase":  "The hex-encoded bytes of the signature script (coinbase txns only)",
@@ -81,12 +338,20 @@ var helpDescsEnUS = map[string]string{
 	"vin-sequence":  "The script sequence number",
 
 	// ScriptPubKeyResult help.
-	"scriptpubkeyresult-asm":       "Disassembly of the script",
-	"scriptpubkeyresult-hex":       "Hex-encoded bytes of the script",
-	"scriptpubkeyresult-reqSigs":   "The number of required signatures",
-	"scriptpubkeyresult-type":      "The type of the script (e.g. 'pubkeyhash')",
-	"scriptpubkeyresult-adminOp":   "A human readable interpretation of an admin thread op",
-	"scriptpubkeyresult-addresses": "The bitcoin addresses associated with this script",
+	"scriptpubkeyresult-asm":          "Disassembly of the script",
+	"scriptpubkeyresult-hex":          "Hex-encoded bytes of the script",
+	"scriptpubkeyresult-reqSigs":      "The number of required signatures",
+	"scriptpubkeyresult-type":         "The type of the script (e.g. 'pubkeyhash')",
+	"scriptpubkeyresult-adminOp":      "A human readable interpretation of an admin thread op",
+	"scriptpubkeyresult-lockTime":     "The absolute lock time before which a timelocked Prova script cannot be spent",
+	"scriptpubkeyresult-pubKeyHashes": "The hex-encoded public key hashes embedded in a Prova script",
+	"scriptpubkeyresult-keyIDs":       "The keyIDs of the ASP keys referenced by a Prova script",
+	"scriptpubkeyresult-thread":       "The admin thread of an admin thread script or admin operation ('ROOT', 'PROVISION' or 'ISSUE')",
+	"scriptpubkeyresult-operation":    "The operation performed by an output of an admin transaction ('ADD_KEY', 'REVOKE_KEY', 'ISSUE' or 'DESTROY')",
+	"scriptpubkeyresult-keySet":       "The key set modified by an admin operation ('PROVISION', 'ISSUE', 'VALIDATE' or 'ASP')",
+	"scriptpubkeyresult-pubKey":       "The hex-encoded public key added or revoked by an admin operation",
+	"scriptpubkeyresult-keyID":        "The keyID of the ASP key added or revoked by an admin operation",
+	"scriptpubkeyresult-addresses":    "The bitcoin addresses associated with this script",
 
 	// Vout help.
 	"vout-value":        "The amount in RMG",
@@ -104,21 +369,207 @@ var helpDescsEnUS = map[string]string{
 	"decoderawtransaction--synopsis": "Returns a JSON object representing the provided serialized, hex-encoded transaction.",
 	"decoderawtransaction-hextx":     "Serialized, hex-encoded transaction",
 
+	// GetRPCInfoCmd help.
+	"getrpcinfo--synopsis": "Returns the RPC calls in progress and statistics of the calls of each method since the server started.\n" +
+		"Durations are in microseconds.",
+
+	// RPCActiveCommandResult help.
+	"rpcactivecommandresult-method":   "The method being called",
+	"rpcactivecommandresult-user":     "The name of the RPC user making the call",
+	"rpcactivecommandresult-duration": "The time the call has been running for",
+
+	// RPCMethodStatsResult help.
+	"rpcmethodstatsresult-method":      "The method",
+	"rpcmethodstatsresult-calls":       "The number of completed calls",
+	"rpcmethodstatsresult-errors":      "The number of completed calls which returned an error",
+	"rpcmethodstatsresult-ratelimited": "The number of calls rejected for exceeding the rate limit of the user",
+	"rpcmethodstatsresult-avgtime":     "The average duration of the completed calls",
+	"rpcmethodstatsresult-maxtime":     "The longest duration of a completed call",
+
+	// GetRPCInfoResult help.
+	"getrpcinforesult-activecommands": "The calls in progress, longest running first",
+	"getrpcinforesult-methods":        "The statistics of the calls of each method which was called, sorted by method",
+
+	// RPCDiscoverCmd help.
+	"rpc.discover--synopsis": "Returns an OpenRPC document describing every method of the RPC server, with the parameters and the result of each method generated from the registered commands.\n" +
+		"Methods which are only available via websockets are marked with the x-websocket-only field.",
+	"rpc.discover--result0--key":   "openrpc, info, methods",
+	"rpc.discover--result0--value": "The OpenRPC version, the title and version of the API, and the descriptions of the methods sorted by name",
+	"rpc.discover--result0--desc":  "The OpenRPC document",
+
+	// RotateRPCAuthCmd help.
+	"rotaterpcauth--synopsis": "Replaces the password of an RPC user with a new random password until the server restarts.\n" +
+		"The password of the RPC authentication cookie is rotated, and the cookie file rewritten, when no user is specified.",
+	"rotaterpcauth-user": "The name of the RPC user",
+
+	// RotateRPCAuthResult help.
+	"rotaterpcauthresult-user":       "The name of the RPC user",
+	"rotaterpcauthresult-password":   "The new password of the RPC user",
+	"rotaterpcauthresult-cookiefile": "The path of the rewritten cookie file, when the password of the cookie was rotated",
+
+	// BackupChainStateCmd help.
+	"backupchainstate--synopsis": "Writes a consistent copy of the block database, including the blocks and the optional indexes, to a new directory without stopping the node.\n" +
+		"Complete block files are hard linked into the copy when the directory is on the same file system.",
+	"backupchainstate-destination": "The directory to write the copy to, which must not exist yet (relative paths are relative to the data directory)",
+
+	// BackupChainStateResult help.
+	"backupchainstateresult-destination": "The directory the copy was written to",
+	"backupchainstateresult-hash":        "The hash of the best block in the copy",
+	"backupchainstateresult-height":      "The height of the best block in the copy",
+
+	// CompactDBCmd help.
+	"compactdb--synopsis": "Writes the database cache to disk and compacts the metadata database, which reclaims the space of deleted and overwritten entries.\n" +
+		"The compaction may take several minutes and slows down block processing while it runs, so it is best done during a maintenance window.",
+
+	// GetDBInfoCmd help.
+	"getdbinfo--synopsis": "Returns statistics of the operations of the block database since the server started.\n" +
+		"Durations are in microseconds.",
+
+	// DBLatencyResult help.
+	"dblatencyresult-count":   "The number of operations",
+	"dblatencyresult-avgtime": "The average duration of the operations",
+	"dblatencyresult-maxtime": "The longest duration of an operation",
+	"dblatencyresult-buckets": "The number of operations which took up to each of the latencybuckets, followed by the number which took longer",
+
+	// GetDBInfoResult help.
+	"getdbinforesult-metadatareads":     "The number of metadata entries read",
+	"getdbinforesult-cachehits":         "The number of metadata entries read from the database cache",
+	"getdbinforesult-cachehitrate":      "Fraction of the metadata entries read from the database cache",
+	"getdbinforesult-metadatawrites":    "The number of metadata entries written or deleted",
+	"getdbinforesult-cacheflushes":      "The number of times the database cache was written to disk",
+	"getdbinforesult-flushlatency":      "The durations of the writes of the database cache to disk",
+	"getdbinforesult-writestalls":       "The number of times metadata writes were delayed because compactions fell behind",
+	"getdbinforesult-writestalltime":    "The total time metadata writes were delayed",
+	"getdbinforesult-storagereadbytes":  "The number of bytes of metadata compactions read from disk",
+	"getdbinforesult-storagewritebytes": "The number of bytes of metadata compactions wrote to disk",
+	"getdbinforesult-blockreads":        "The number of block reads from the flat block files",
+	"getdbinforesult-blockreadbytes":    "The number of bytes read from the flat block files",
+	"getdbinforesult-blockreadlatency":  "The durations of the block reads",
+	"getdbinforesult-blockwrites":       "The number of block writes to the flat block files",
+	"getdbinforesult-blockwritebytes":   "The number of bytes written to the flat block files",
+	"getdbinforesult-blockwritelatency": "The durations of the block writes",
+	"getdbinforesult-latencybuckets":    "The upper bounds of the buckets of the durations",
+
+	// EnableIndexCmd help.
+	"enableindex--synopsis": "Enables an optional index until the server restarts, catching it up with the main chain in the background.\n" +
+		"The address and committed filter indexes can only be enabled with their options while the server is stopped.",
+	"enableindex-index": "The name of the option which enables the index, such as txindex",
+
+	// DropIndexCmd help.
+	"dropindex--synopsis": "Disables an optional index and removes it from the database in the background.\n" +
+		"The address and committed filter indexes can only be dropped with their options while the server is stopped.",
+	"dropindex-index": "The name of the option which enables the index, such as txindex",
+
+	// SetProfileServerCmd help.
+	"setprofileserver--synopsis": "Starts or stops the HTTP profiling server, which serves the pprof endpoints under /debug/pprof/ on all interfaces without authentication.",
+	"setprofileserver-enable":    "Whether to start or stop the server",
+	"setprofileserver-port":      "The port to listen on, between 1024 and 65535 (default: the port set with --profile)",
+	"setprofileserver--result0":  "The address the server listens on, or an empty string when it was stopped",
+
+	// StartCPUProfileCmd help.
+	"startcpuprofile--synopsis": "Starts writing a CPU profile, which is finished by stopcpuprofile or when the node shuts down.",
+	"startcpuprofile-file":      "The file to write the profile to, relative to the profiles directory under the data directory.  It must not exist yet",
+	"startcpuprofile--result0":  "The path of the profile",
+
+	// StopCPUProfileCmd help.
+	"stopcpuprofile--synopsis": "Stops writing the CPU profile started by startcpuprofile or --cpuprofile.",
+	"stopcpuprofile--result0":  "The path of the profile",
+
+	// WriteProfileCmd help.
+	"writeprofile--synopsis": "Writes a runtime profile, such as the heap profile or the dump of the stacks of all goroutines, to a file.",
+	"writeprofile-profile":   "The name of the profile: heap, goroutine, threadcreate, block or mutex.  Goroutine profiles are written as text, the others in the format read by go tool pprof",
+	"writeprofile-file":      "The file to write the profile to, relative to the profiles directory under the data directory.  It must not exist yet",
+	"writeprofile--result0":  "The path of the profile",
+
 	// SetValidateKeysCmd help.
 	"setvalidatekeys--synopsis": "Sets the private keys to use to sign generated blocks",
 	"setvalidatekeys-privkeys":  "Hex-encoded 32 byte private keys",
 
+	// ResetSignGuardCmd help.
+	"resetsignguard--synopsis": "Overrides the height of the last block signed by a validate key, which the node refuses to sign another block at or below.\n" +
+		"Meant for recovery, such as after a block of the key was reorganized away and the key has to sign another block at its height.\n" +
+		"Returns the replaced record, or null when there was none.",
+	"resetsignguard-pubkey": "The hex-encoded validate pubKey",
+	"resetsignguard-height": "The height above which the key signs blocks again, or 0 to clear the record",
+
+	// SignGuardRecordResult help.
+	"signguardrecordresult-height":    "The height of the last block signed by the key",
+	"signguardrecordresult-timestamp": "The timestamp of the block in seconds since 1 Jan 1970 GMT, or 0 after a reset",
+	"signguardrecordresult-hash":      "The hash of the block, empty after a reset",
+
 	// DecodeScriptResult help.
-	"decodescriptresult-asm":       "Disassembly of the script",
-	"decodescriptresult-reqSigs":   "The number of required signatures",
-	"decodescriptresult-type":      "The type of the script (e.g. 'pubkeyhash')",
-	"decodescriptresult-addresses": "The bitcoin addresses associated with this script",
-	"decodescriptresult-p2sh":      "The script hash for use in pay-to-script-hash transactions",
+	"decodescriptresult-asm":          "Disassembly of the script",
+	"decodescriptresult-reqSigs":      "The number of required signatures",
+	"decodescriptresult-type":         "The type of the script (e.g. 'pubkeyhash')",
+	"decodescriptresult-adminOp":      "A human readable interpretation of an admin thread op",
+	"decodescriptresult-lockTime":     "The absolute lock time before which a timelocked Prova script cannot be spent",
+	"decodescriptresult-pubKeyHashes": "The hex-encoded public key hashes embedded in a Prova script",
+	"decodescriptresult-keyIDs":       "The keyIDs of the ASP keys referenced by a Prova script",
+	"decodescriptresult-thread":       "The admin thread of an admin thread script or admin operation ('ROOT', 'PROVISION' or 'ISSUE')",
+	"decodescriptresult-operation":    "The operation performed by an output of an admin transaction ('ADD_KEY', 'REVOKE_KEY', 'ISSUE' or 'DESTROY')",
+	"decodescriptresult-keySet":       "The key set modified by an admin operation ('PROVISION', 'ISSUE', 'VALIDATE' or 'ASP')",
+	"decodescriptresult-pubKey":       "The hex-encoded public key added or revoked by an admin operation",
+	"decodescriptresult-keyID":        "The keyID of the ASP key added or revoked by an admin operation",
+	"decodescriptresult-addresses":    "The addresses associated with this script",
 
 	// DecodeScriptCmd help.
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DebugScriptStep help.
+	"debugscriptstep-script":    "The script the opcode belongs to ('sigscript' or 'pkscript')",
+	"debugscriptstep-offset":    "The offset of the opcode within the script",
+	"debugscriptstep-opcode":    "Disassembly of the opcode",
+	"debugscriptstep-executed":  "Whether the opcode was in an executing branch as opposed to skipped due to an enclosing conditional",
+	"debugscriptstep-stack":     "The hex-encoded data stack after the opcode, with the top item last",
+	"debugscriptstep-altstack":  "The hex-encoded alternate stack after the opcode, with the top item last",
+	"debugscriptstep-condstack": "The state of each enclosing conditional after the opcode ('true', 'false' or 'skip'), with the innermost last",
+	"debugscriptstep-error":     "The error which caused execution to fail at the opcode, if any",
+
+	// DebugScriptResult help.
+	"debugscriptresult-sigscript": "Disassembly of the signature script of the input",
+	"debugscriptresult-pkscript":  "Disassembly of the executed public key script, with any keyIDs and admin threads resolved against the current chain state",
+	"debugscriptresult-amount":    "The amount of the spent output in RMG",
+	"debugscriptresult-valid":     "Whether the input successfully spends the output",
+	"debugscriptresult-error":     "The reason the input does not spend the output, if any",
+	"debugscriptresult-steps":     "The state of the script engine after each executed opcode",
+
+	// DebugScriptCmd help.
+	"debugscript--synopsis":  "Executes the scripts of a transaction input with the standard verification flags and returns a trace of every step.  An error is returned when the stacks recorded by the trace exceed 16 MiB in total.",
+	"debugscript-hextx":      "Serialized, hex-encoded transaction",
+	"debugscript-inputindex": "The index of the input to execute",
+	"debugscript-pkscript":   "Hex-encoded public key script of the output spent by the input",
+	"debugscript-amount":     "The amount of the spent output in RMG -- looked up in the unspent outputs of the main chain when omitted",
+
+	// FinalizePSPTResult help.
+	"finalizepsptresult-pspt":     "The base64-encoded PSPT (only when it is incomplete or extract is false)",
+	"finalizepsptresult-hex":      "The hex-encoded signed transaction (only when it is complete and extract is true)",
+	"finalizepsptresult-complete": "Whether or not every input has the signatures it requires",
+
+	// FinalizePSPTCmd help.
+	"finalizepspt--synopsis": "Builds the signature scripts of the inputs of a partially signed transaction (PSPT) which have collected the signatures they require.",
+	"finalizepspt-pspt":      "The base64-encoded PSPT",
+	"finalizepspt-extract":   "Return the signed transaction instead of the PSPT once every input is finalized",
+
+	// FundRawTransactionCmd help.
+	"fundrawtransaction--synopsis": "Adds inputs spending unspent outputs of watch-only addresses to a transaction, along with a change output, so that it can be signed by an external signing service.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"fundrawtransaction-hextx":   "The hex-encoded transaction, whose outputs and existing inputs are kept",
+	"fundrawtransaction-options": "The options of the coin selection",
+
+	// FundRawTransactionOpts help.
+	"fundrawtransactionopts-addresses":     "The watch-only addresses whose confirmed unspent outputs may be selected",
+	"fundrawtransactionopts-changeaddress": "The Prova address the change is paid to, required when there is change",
+	"fundrawtransactionopts-strategy":      "The coin selection strategy: branchandbound to avoid change when possible (default), largestfirst to minimize the number of inputs, or privacy to spend every output of as few addresses as possible",
+	"fundrawtransactionopts-feerate":       "The fee rate in RMG/kB (default: the minimum relay fee)",
+	"fundrawtransactionopts-minconf":       "The minimum number of confirmations of the selected outputs (default: 1)",
+
+	// FundRawTransactionResult help.
+	"fundrawtransactionresult-hex":       "The hex-encoded unsigned transaction with the selected inputs and change",
+	"fundrawtransactionresult-fee":       "The fee paid by the transaction in RMG",
+	"fundrawtransactionresult-changepos": "The index of the change output, or -1 when there is none",
+	"fundrawtransactionresult-prevouts":  "The outputs spent by the added inputs, in the order of the inputs",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
@@ -126,6 +577,21 @@ var helpDescsEnUS = map[string]string{
 	"generate-validatekeys": "Hex-encoded private keys to use for block signing",
 	"generate--result0":     "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the passed address (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase of each block pays to",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateWithValidateKeyCmd help
+	"generatewithvalidatekey--synopsis": "Generates a set number of blocks signed by the passed validate key (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatewithvalidatekey-numblocks":   "Number of blocks to generate",
+	"generatewithvalidatekey-validatekey": "Hex-encoded public key of the validate key to sign the blocks with, which must have been set via setvalidatekeys or PROVA_VALIDATE_KEYS",
+	"generatewithvalidatekey-address":     "The address the coinbase of each block pays to (default: one of the --miningaddr addresses)",
+	"generatewithvalidatekey--result0":    "The hashes, in order, of blocks generated by the call",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -155,6 +621,57 @@ var helpDescsEnUS = map[string]string{
 	"addresstxrequest-start":     "The block to start at",
 	"addresstxrequest-end":       "The block to end at",
 
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the balance of the passed addresses along with the total amount they have received.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"getaddressbalance-request": "AddressTxRequest object containing the addresses",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":  "The total balance of the addresses in atoms",
+	"getaddressbalanceresult-received": "The total amount received by the addresses in atoms",
+
+	// GetAddressDeltasCmd help.
+	"getaddressdeltas--synopsis": "Returns the changes to the balances of the passed addresses made by each input and output of the transactions in the main chain.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"getaddressdeltas-request":  "AddressTxRequest object containing the addresses along with the optional heights of the first and last blocks",
+	"getaddressdeltas--result0": "The changes to the balances ordered by address and position in the chain",
+
+	// AddressDeltaResult help.
+	"addressdeltaresult-address":    "The address",
+	"addressdeltaresult-txid":       "The hash of the transaction",
+	"addressdeltaresult-index":      "The index of the input or output",
+	"addressdeltaresult-blockindex": "The index of the transaction in the block",
+	"addressdeltaresult-height":     "The height of the block",
+	"addressdeltaresult-atoms":      "The change to the balance in atoms, which is negative for inputs",
+
+	// GetAddressMempoolCmd help.
+	"getaddressmempool--synopsis": "Returns the changes to the balances of the passed addresses made by each input and output of the transactions in the memory pool.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"getaddressmempool-request":  "AddressTxRequest object containing the addresses",
+	"getaddressmempool--result0": "The changes to the balances",
+
+	// AddressMempoolResult help.
+	"addressmempoolresult-address":  "The address",
+	"addressmempoolresult-txid":     "The hash of the transaction",
+	"addressmempoolresult-index":    "The index of the input or output",
+	"addressmempoolresult-atoms":    "The change to the balance in atoms, which is negative for inputs",
+	"addressmempoolresult-prevtxid": "The hash of the transaction of the spent output (inputs only)",
+	"addressmempoolresult-prevout":  "The index of the spent output (inputs only)",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs paying to the passed addresses as of the best block.\n" +
+		"Usage of this RPC requires the optional --addrindex flag to be activated.",
+	"getaddressutxos-request":  "AddressTxRequest object containing the addresses",
+	"getaddressutxos--result0": "The unspent outputs",
+
+	// AddressUtxoResult help.
+	"addressutxoresult-address":     "The address",
+	"addressutxoresult-txid":        "The hash of the transaction",
+	"addressutxoresult-outputIndex": "The index of the output",
+	"addressutxoresult-script":      "The hex-encoded public key script of the output",
+	"addressutxoresult-atoms":       "The value of the output in atoms",
+	"addressutxoresult-height":      "The height of the block containing the output",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -166,6 +683,7 @@ var helpDescsEnUS = map[string]string{
 	// ASPKeyIdResult help.
 	"aspkeyidresult-pubkey": "compressed, serialized pubKey of ASP",
 	"aspkeyidresult-keyid":  "uint32 keyID assigned to ASP",
+	"aspkeyidresult-frozen": "Whether the keyID is frozen",
 
 	// ThreadTipResult help.
 	"threadtipresult-id":       "ID of admin thread",
@@ -194,10 +712,11 @@ var helpDescsEnUS = map[string]string{
 	// GetBlockCmd help.
 	"getblock--synopsis":   "Returns information about a block given its hash.",
 	"getblock-hash":        "The hash of the block",
-	"getblock-verbose":     "Specifies the block is returned as a JSON object instead of hex-encoded string",
-	"getblock-verbosetx":   "Specifies that each transaction is returned as a JSON object and only applies if the verbose flag is true (btcd extension)",
-	"getblock--condition0": "verbose=false",
-	"getblock--condition1": "verbose=true",
+	"getblock-verbosity":   "Specifies the block is returned as a hex-encoded string (0), a JSON object (1), or a JSON object with each transaction fully decoded including the previous outputs it spends (2).  The booleans false and true are accepted for 0 and 1",
+	"getblock-verbosetx":   "Specifies that each transaction is returned as a JSON object and only applies if the verbosity is 1 (btcd extension)",
+	"getblock--condition0": "verbosity=0",
+	"getblock--condition1": "verbosity=1",
+	"getblock--condition2": "verbosity=2",
 	"getblock--result0":    "Hex-encoded bytes of the serialized block",
 
 	// TxRawResult help.
@@ -224,6 +743,23 @@ var helpDescsEnUS = map[string]string{
 	"searchrawtransactionsresult-time":          "Transaction time in seconds since 1 Jan 1970 GMT",
 	"searchrawtransactionsresult-blocktime":     "Block time in seconds since the 1 Jan 1970 GMT",
 
+	// GetBlockVerboseTxResult help.
+	"getblockverbosetxresult-hash":              "The hash of the block (same as provided)",
+	"getblockverbosetxresult-confirmations":     "The number of confirmations",
+	"getblockverbosetxresult-size":              "The size of the block",
+	"getblockverbosetxresult-height":            "The height of the block in the block chain",
+	"getblockverbosetxresult-version":           "The block version",
+	"getblockverbosetxresult-merkleroot":        "Root hash of the merkle tree",
+	"getblockverbosetxresult-tx":                "The transactions as JSON objects, with the previous outputs spent by their inputs",
+	"getblockverbosetxresult-time":              "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockverbosetxresult-nonce":             "The block nonce",
+	"getblockverbosetxresult-bits":              "The bits which represent the block difficulty",
+	"getblockverbosetxresult-difficulty":        "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"getblockverbosetxresult-previousblockhash": "The hash of the previous block",
+	"getblockverbosetxresult-nextblockhash":     "The hash of the next block (only if there is one)",
+	"getblockverbosetxresult-validatingpubkey":  "The validating public key signing the block",
+	"getblockverbosetxresult-signature":         "The signature of the block generator",
+
 	// GetBlockVerboseResult help.
 	"getblockverboseresult-hash":              "The hash of the block (same as provided)",
 	"getblockverboseresult-confirmations":     "The number of confirmations",
@@ -242,6 +778,45 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-validatingpubkey":  "The validating public key signing the block",
 	"getblockverboseresult-signature":         "The signature of the block generator",
 
+	// GetBlockChainInfoCmd help.
+	"getblockchaininfo--synopsis": "Returns information about the state of the block chain.",
+
+	// GetBlockChainInfoResult help.
+	"getblockchaininforesult-chain":           "The name of the network",
+	"getblockchaininforesult-blocks":          "The height of the best block",
+	"getblockchaininforesult-headers":         "The height of the best header, which is the best block",
+	"getblockchaininforesult-bestblockhash":   "The hash of the best block",
+	"getblockchaininforesult-difficulty":      "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"getblockchaininforesult-adminthresholds": "The numbers of admin key signatures the admin threads require",
+	"getblockchaininforesult-threadtips":      "The outpoints of the tips of the admin threads",
+	"getblockchaininforesult-keycounts":       "The numbers of keys of each type",
+	"getblockchaininforesult-totalsupply":     "The total supply in atoms",
+	"getblockchaininforesult-issuance":        "The issuances and destructions of funds (only with --supplyindex)",
+	"getblockchaininforesult-softforks":       "The consensus rule changes which activate at a height",
+
+	// AdminThresholdsResult help.
+	"adminthresholdsresult-root":      "The number of root key signatures the root thread requires",
+	"adminthresholdsresult-provision": "The number of provision key signatures the provision thread requires",
+	"adminthresholdsresult-issue":     "The number of issue key signatures the issue thread requires",
+
+	// KeyCountsResult help.
+	"keycountsresult-root":      "The number of root keys",
+	"keycountsresult-provision": "The number of provision keys",
+	"keycountsresult-issue":     "The number of issue keys",
+	"keycountsresult-validate":  "The number of validate keys",
+	"keycountsresult-asp":       "The number of ASP key ids",
+	"keycountsresult-frozen":    "The number of frozen ASP key ids",
+
+	// IssuanceResult help.
+	"issuanceresult-lastissueheight": "The height of the block containing the latest issuance, or 0 if there was none",
+	"issuanceresult-issued":          "The total amount issued in atoms",
+	"issuanceresult-destroyed":       "The total amount destroyed in atoms",
+
+	// SoftForkResult help.
+	"softforkresult-id":               "The name of the rule change",
+	"softforkresult-activationheight": "The height the rule change activates at (omitted if it is not scheduled)",
+	"softforkresult-active":           "Whether or not the rule change applies to the next block",
+
 	// GetBlockCountCmd help.
 	"getblockcount--synopsis": "Returns the number of blocks in the longest block chain.",
 	"getblockcount--result0":  "The current block count",
@@ -251,6 +826,16 @@ var helpDescsEnUS = map[string]string{
 	"getblockhash-index":     "The block height",
 	"getblockhash--result0":  "The block hash",
 
+	// GetBlockHashByTimeCmd help.
+	"getblockhashbytime--synopsis": "Returns the block of the main chain with the latest timestamp at or before the passed time.\n" +
+		"Usage of this RPC requires the optional --timestampindex flag to be activated.",
+	"getblockhashbytime-timestamp": "The time in seconds since 1 Jan 1970 GMT",
+
+	// GetBlockHashByTimeResult help.
+	"getblockhashbytimeresult-hash":   "The hash of the block",
+	"getblockhashbytimeresult-height": "The height of the block",
+	"getblockhashbytimeresult-time":   "The timestamp of the block in seconds since 1 Jan 1970 GMT",
+
 	// GetBlockHeaderCmd help.
 	"getblockheader--synopsis":   "Returns information about a block header given its hash.",
 	"getblockheader-hash":        "The hash of the block",
@@ -259,6 +844,46 @@ var helpDescsEnUS = map[string]string{
 	"getblockheader--condition1": "verbose=true",
 	"getblockheader--result0":    "The block header hash",
 
+	// GetBroadcastStatusCmd help.
+	"getbroadcaststatus--synopsis": "Returns the status of a broadcast campaign started with broadcasttransaction.",
+	"getbroadcaststatus-id":        "The ID of the broadcast campaign",
+
+	// BroadcastStatusResult help.
+	"broadcaststatusresult-id":                    "The ID of the broadcast campaign",
+	"broadcaststatusresult-txid":                  "The hash of the transaction",
+	"broadcaststatusresult-status":                "The status of the campaign (pending, propagated, confirming, confirmed or conflicted)",
+	"broadcaststatusresult-final":                 "Whether the campaign is final, either confirmed or conflicted",
+	"broadcaststatusresult-targetconfs":           "The number of confirmations after which the campaign is final",
+	"broadcaststatusresult-confirmations":         "The number of confirmations of the transaction",
+	"broadcaststatusresult-blockhash":             "The hash of the main chain block containing the transaction",
+	"broadcaststatusresult-blockheight":           "The height of the main chain block containing the transaction",
+	"broadcaststatusresult-peersrequested":        "The number of peers which requested the transaction from this node",
+	"broadcaststatusresult-peersannounced":        "The number of peers which announced the transaction to this node",
+	"broadcaststatusresult-unconfirmations":       "The number of times the block containing the transaction was disconnected from the main chain",
+	"broadcaststatusresult-conflicttxid":          "The hash of the main chain transaction spending an input of the transaction",
+	"broadcaststatusresult-conflictconfirmations": "The number of confirmations of the conflicting transaction",
+	"broadcaststatusresult-started":               "The time the campaign started in seconds since 1 Jan 1970 GMT",
+	"broadcaststatusresult-finished":              "The time the campaign became final in seconds since 1 Jan 1970 GMT",
+
+	// GetCFilterCmd help.
+	"getcfilter--synopsis": "Returns the committed compact filter (BIP0158) of a block given its hash.",
+	"getcfilter-hash":      "The hash of the block",
+	"getcfilter--result0":  "The hex-encoded serialized filter",
+
+	// GetCFilterHeaderCmd help.
+	"getcfilterheader--synopsis": "Returns the header of the committed compact filter (BIP0157) of a block given its hash.",
+	"getcfilterheader-hash":      "The hash of the block",
+	"getcfilterheader--result0":  "The filter header",
+
+	// GetBlockHeadersCmd help.
+	"getblockheaders--synopsis":   "Returns up to count consecutive block headers of the main chain, starting with the given block.",
+	"getblockheaders-start":       "The hash or the height of the first block",
+	"getblockheaders-count":       "The maximum number of block headers to return (at most 2000)",
+	"getblockheaders-verbose":     "Specifies the block headers are returned as JSON objects instead of hex-encoded strings",
+	"getblockheaders--condition0": "verbose=false",
+	"getblockheaders--condition1": "verbose=true",
+	"getblockheaders--result0":    "The hex-encoded block headers",
+
 	// GetBlockHeaderVerboseResult help.
 	"getblockheaderverboseresult-hash":              "The hash of the block (same as provided)",
 	"getblockheaderverboseresult-confirmations":     "The number of confirmations",
@@ -329,22 +954,279 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplate--condition2": "mode=proposal, accepted",
 	"getblocktemplate--result1":    "An error string which represents why the proposal was rejected or nothing if accepted",
 
+	// GetCirculatingSupplyCmd help.
+	"getcirculatingsupply--synopsis": "Returns the total supply of funds, either currently or after the block at a past height.\n" +
+		"Past heights require the optional --supplyindex flag to be activated.",
+	"getcirculatingsupply-height": "The height of the block after which to return the supply (default: the best height)",
+
+	// GetCirculatingSupplyResult help.
+	"getcirculatingsupplyresult-height": "The height of the block after which the supply applies",
+	"getcirculatingsupplyresult-supply": "The total supply in atoms",
+
+	// GetClusterInfoCmd help.
+	"getclusterinfo--synopsis": "Returns the state of this node as a member of a validator cluster, whose members hold the same validate keys while only one of them signs blocks.\n" +
+		"Usage of this RPC requires the --clusterrole option to be set.",
+
+	// GetClusterInfoResult help.
+	"getclusterinforesult-role":          "The configured role of this node (primary or standby)",
+	"getclusterinforesult-state":         "Whether this node signs blocks (active), saw another member sign within the lockout (standby) or waits for the lockout to expire (lockout)",
+	"getclusterinforesult-lockout":       "The duration in seconds without heartbeats signed by another member after which this node takes over",
+	"getclusterinforesult-activesince":   "The time this node took over signing in seconds since 1 Jan 1970 GMT (omitted unless active)",
+	"getclusterinforesult-lastheartbeat": "The time the latest heartbeat of a shared validate key signed by another member was received in seconds since 1 Jan 1970 GMT (omitted if none was)",
+	"getclusterinforesult-peerheight":    "The greatest best block height announced by another member, above which this node signs blocks",
+	"getclusterinforesult-peerpubkey":    "The validate key of the latest heartbeat signed by another member (omitted if none was)",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
 
 	// GetCurrentNetCmd help.
+	// ConsistencyCheckResult help.
+	"consistencycheckresult-hash":           "Hash of the end of the main chain when the check was performed",
+	"consistencycheckresult-height":         "Height of the end of the main chain when the check was performed",
+	"consistencycheckresult-keyviewblocks":  "Number of blocks replayed to re-derive the admin key state",
+	"consistencycheckresult-undoblocks":     "Number of blocks at the end of the main chain whose spend journal entries were checked",
+	"consistencycheckresult-sampledoutputs": "Number of spent outputs from the spend journal verified against the transactions that created them",
+	"consistencycheckresult-consistent":     "Whether or not the check completed without detecting any inconsistencies",
+	"consistencycheckresult-mismatches":     "Descriptions of the detected inconsistencies",
+
+	// GetConsistencyStatusResult help.
+	"getconsistencystatusresult-enabled":        "Whether or not the background consistency checks are enabled",
+	"getconsistencystatusresult-interval":       "Number of seconds between checks",
+	"getconsistencystatusresult-haltonmismatch": "Whether or not the node shuts down when a check detects an inconsistency",
+	"getconsistencystatusresult-runs":           "Number of checks performed since the node started",
+	"getconsistencystatusresult-failedruns":     "Number of checks which could not be completed",
+	"getconsistencystatusresult-mismatchruns":   "Number of checks which detected an inconsistency",
+	"getconsistencystatusresult-lastrun":        "Time the most recent check started in seconds since 1 Jan 1970 GMT (omitted if no check has been performed)",
+	"getconsistencystatusresult-lastduration":   "Number of seconds the most recent check took (omitted if no check has been performed)",
+	"getconsistencystatusresult-lasterror":      "Reason the most recent check could not be completed (omitted if it completed)",
+	"getconsistencystatusresult-lastcheck":      "Results of the most recent completed check (omitted if no check has completed)",
+
+	// GetConsistencyStatusCmd help.
+	"getconsistencystatus--synopsis": "Returns the status of the background checks of the utxo set and admin key state against the block data.",
+
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifer",
 
+	// GetAttestationCmd help.
+	"getattestation--synopsis": "Returns an attestation of the best block, utxo set and supply of the chain, signed with the attestation key or else an active validate key of the node.",
+
+	// AttestationResult help.
+	"attestationresult-network":     "The network of the chain",
+	"attestationresult-height":      "The height of the best block",
+	"attestationresult-hash":        "The hash of the best block",
+	"attestationresult-utxosethash": "The hash committing to every unspent output as of the best block",
+	"attestationresult-utxos":       "The number of unspent outputs",
+	"attestationresult-utxoamount":  "The total amount of the unspent outputs in atoms",
+	"attestationresult-supply":      "The supply of the chain in atoms",
+	"attestationresult-time":        "The time the attestation was made in seconds since 1 Jan 1970 GMT",
+	"attestationresult-pubkey":      "The compressed public key which signed the attestation",
+	"attestationresult-signature":   "The hex-encoded DER signature of the attestation",
+	"attestationresult-hex":         "The hex-encoded serialized attestation, as accepted by verifyattestation",
+
+	// GetAuditLogCmd help.
+	"getauditlog--synopsis": "Returns the entries of the audit log of privileged operations starting from the passed sequence number.\n" +
+		"The hash of each entry is the hex-encoded SHA-256 of its line in the audit log file with the hash set to the empty string, and every entry holds the hash of the previous one.",
+	"getauditlog-start": "The sequence number of the first entry to return",
+	"getauditlog-count": "The maximum number of entries to return (at most 10000)",
+
+	// GetAuditLogResult help.
+	"getauditlogresult-last":     "The sequence number of the last entry recorded (0 when the log is empty)",
+	"getauditlogresult-lasthash": "The hash of the last entry recorded, which the returned entries chain up to",
+	"getauditlogresult-entries":  "The entries starting from the passed sequence number",
+
+	// AuditLogEntryResult help.
+	"auditlogentryresult-seq":            "The sequence number of the entry",
+	"auditlogentryresult-time":           "The time the entry was recorded in seconds since 1 Jan 1970 GMT",
+	"auditlogentryresult-kind":           "The kind of the operation (adminrpc, blocksigning, configchange, chainintervention)",
+	"auditlogentryresult-action":         "The RPC method called, or signblock or reloadconfig",
+	"auditlogentryresult-details":        "The details of the operation, such as the user, remote address and parameters of RPC calls, or the hash, height and validate key of signed blocks",
+	"auditlogentryresult-details--key":   "name",
+	"auditlogentryresult-details--value": "value",
+	"auditlogentryresult-details--desc":  "The name of the detail as the key and its value as the value",
+	"auditlogentryresult-prevhash":       "The hash of the previous entry (64 zeros for the first entry)",
+	"auditlogentryresult-hash":           "The hash of the entry",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
 
+	// GetEventLogCmd help.
+	"geteventlog--synopsis": "Returns the events recorded in the event log after the passed cursor.\n" +
+		"Clients pass the sequence number of the last event they have processed as the cursor, or 0 to start with the oldest event kept.\n" +
+		"An error is returned when events following the cursor have been pruned, in which case the client has to resynchronize in full.",
+	"geteventlog-cursor": "The sequence number of the last event processed by the client",
+	"geteventlog-count":  "The maximum number of events to return (at most 10000)",
+
+	// GetEventLogResult help.
+	"geteventlogresult-first":  "The sequence number of the oldest event kept (0 when the log is empty)",
+	"geteventlogresult-last":   "The sequence number of the most recent event (0 when the log is empty)",
+	"geteventlogresult-next":   "The cursor to pass to continue after the returned events",
+	"geteventlogresult-events": "The events following the cursor",
+
+	// EventLogEntryResult help.
+	"eventlogentryresult-seq":    "The sequence number of the event",
+	"eventlogentryresult-type":   "The kind of the event (blockconnected, blockdisconnected, txaccepted, txremoved, keyadded, keyrevoked)",
+	"eventlogentryresult-time":   "The time the event was recorded in seconds since 1 Jan 1970 GMT",
+	"eventlogentryresult-hash":   "The hash of the block for block events, or of the transaction for transaction and admin key events",
+	"eventlogentryresult-height": "The height of the block for block and admin key events",
+	"eventlogentryresult-keyset": "The key set of the key for admin key events",
+	"eventlogentryresult-pubkey": "The hex-encoded public key for admin key events",
+	"eventlogentryresult-keyid":  "The keyID of the key for ASP admin key events",
+
+	// GetExplorerAddressCmd help.
+	"getexploreraddress--synopsis": "Returns the transactions of the main chain changing the balance of an address, newest first, in pages.\n" +
+		"The next field of the result is passed as the token to fetch the following page.\n" +
+		"The address index must be enabled (--addrindex).",
+	"getexploreraddress-address": "The address",
+	"getexploreraddress-count":   "The maximum number of transactions to return (at most 1000)",
+	"getexploreraddress-token":   "The token returned with the previous page, or empty to start with the newest transaction",
+
+	// ExplorerAddressResult help.
+	"exploreraddressresult-address": "The address",
+	"exploreraddressresult-txs":     "The transactions of the page",
+	"exploreraddressresult-next":    "The token to pass to fetch the following page (omitted when there are no more transactions)",
+
+	// ExplorerAddressTxResult help.
+	"exploreraddresstxresult-txid":     "The hash of the transaction",
+	"exploreraddresstxresult-height":   "The height of the block containing the transaction",
+	"exploreraddresstxresult-time":     "The timestamp of the block containing the transaction in seconds since 1 Jan 1970 GMT",
+	"exploreraddresstxresult-received": "The total amount of the outputs paying to the address in atoms",
+	"exploreraddresstxresult-sent":     "The total amount of the inputs spending from the address in atoms",
+
+	// GetExplorerBlocksCmd help.
+	"getexplorerblocks--synopsis": "Returns summaries of the blocks of the main chain from the passed height down towards the genesis block.\n" +
+		"The height of the last returned block minus one is passed to fetch the following page.",
+	"getexplorerblocks-count":    "The maximum number of blocks to return (at most 100)",
+	"getexplorerblocks-height":   "The height of the first block to return (defaults to the best block)",
+	"getexplorerblocks--result0": "The summaries of the blocks, highest first",
+
+	// ExplorerBlockResult help.
+	"explorerblockresult-hash":             "The hash of the block",
+	"explorerblockresult-height":           "The height of the block",
+	"explorerblockresult-time":             "The timestamp of the block in seconds since 1 Jan 1970 GMT",
+	"explorerblockresult-txs":              "The number of transactions in the block",
+	"explorerblockresult-size":             "The size of the block in bytes",
+	"explorerblockresult-atoms":            "The total amount of the outputs of the block in atoms",
+	"explorerblockresult-fees":             "The total fees collected by the block in atoms",
+	"explorerblockresult-difficulty":       "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"explorerblockresult-validatingpubkey": "The validate key which signed the block",
+
+	// GetExplorerChartsCmd help.
+	"getexplorercharts--synopsis": "Returns the daily activity of the chain for the passed number of days up to the day of the best block, oldest first.\n" +
+		"Days are UTC days of the block timestamps.  The daily statistics index must be enabled (--statsindex).",
+	"getexplorercharts-days":     "The number of days to return (at most 3650)",
+	"getexplorercharts--result0": "The activity of each day, including days without blocks",
+
+	// ExplorerDayResult help.
+	"explorerdayresult-date":         "The day formatted as YYYY-MM-DD",
+	"explorerdayresult-time":         "The start of the day in seconds since 1 Jan 1970 GMT",
+	"explorerdayresult-blocks":       "The number of blocks with a timestamp during the day",
+	"explorerdayresult-txs":          "The number of transactions of the blocks excluding coinbases",
+	"explorerdayresult-fees":         "The total fees collected by the blocks in atoms",
+	"explorerdayresult-activekeyids": "The number of distinct keyIDs of the outputs created or spent by the blocks",
+
+	// GetExplorerTransactionCmd help.
+	"getexplorertransaction--synopsis": "Returns a transaction of the memory pool or the main chain with the outputs spent by its inputs resolved.\n" +
+		"The inputs spending the outputs are included when the spent index is enabled (--spentindex).\n" +
+		"Transactions of the main chain require the transaction index (--txindex).",
+	"getexplorertransaction-txid": "The hash of the transaction",
+
+	// ExplorerTxResult help.
+	"explorertxresult-txid":          "The hash of the transaction",
+	"explorertxresult-version":       "The transaction version",
+	"explorertxresult-locktime":      "The transaction lock time",
+	"explorertxresult-size":          "The size of the transaction in bytes",
+	"explorertxresult-blockhash":     "The hash of the block containing the transaction (omitted for the memory pool)",
+	"explorertxresult-height":        "The height of the block containing the transaction",
+	"explorertxresult-time":          "The timestamp of the block containing the transaction in seconds since 1 Jan 1970 GMT",
+	"explorertxresult-confirmations": "The number of confirmations (0 for the memory pool)",
+	"explorertxresult-admin":         "Whether the transaction is an admin transaction",
+	"explorertxresult-fee":           "The fee paid by the transaction in atoms (0 for coinbase and admin transactions)",
+	"explorertxresult-vin":           "The inputs of the transaction",
+	"explorertxresult-vout":          "The outputs of the transaction",
+
+	// ExplorerVinResult help.
+	"explorervinresult-coinbase":  "Whether the input is the input of a coinbase",
+	"explorervinresult-txid":      "The hash of the transaction of the spent output",
+	"explorervinresult-vout":      "The index of the spent output",
+	"explorervinresult-type":      "The type of the script of the spent output",
+	"explorervinresult-addresses": "The addresses of the spent output",
+	"explorervinresult-keyids":    "The keyIDs of the spent output",
+	"explorervinresult-atoms":     "The amount of the spent output in atoms",
+
+	// ExplorerVoutResult help.
+	"explorervoutresult-n":           "The index of the output",
+	"explorervoutresult-type":        "The type of the script of the output",
+	"explorervoutresult-addresses":   "The addresses of the output",
+	"explorervoutresult-keyids":      "The keyIDs of the output",
+	"explorervoutresult-atoms":       "The amount of the output in atoms",
+	"explorervoutresult-spenttxid":   "The hash of the transaction spending the output (omitted when unspent or unknown)",
+	"explorervoutresult-spentindex":  "The index of the input spending the output",
+	"explorervoutresult-spentheight": "The height of the block of the transaction spending the output",
+
 	// GetGenerateCmd help.
 	"getgenerate--synopsis": "Returns if the server is set to generate coins (mine) or not.",
 	"getgenerate--result0":  "True if mining, false if not",
 
+	// GetHashCacheInfoResult help.
+	"gethashcacheinforesult-entries":    "Number of transactions whose signature hashes are cached",
+	"gethashcacheinforesult-maxentries": "Maximum number of transactions whose signature hashes may be cached",
+	"gethashcacheinforesult-digests":    "Number of cached signature hash digests of transaction inputs",
+	"gethashcacheinforesult-hits":       "Number of signature hash digests found in the cache since the node started",
+	"gethashcacheinforesult-misses":     "Number of signature hash digests which had to be calculated since the node started",
+	"gethashcacheinforesult-hitrate":    "Fraction of signature hash digests found in the cache",
+
+	// GetDiagnosticsCmd help.
+	"getdiagnostics--synopsis": "Returns the memory, goroutine and garbage collection statistics of the node, and the state of the profiler.",
+
+	// GetDiagnosticsResult help.
+	"getdiagnosticsresult-goversion":     "The version of Go the node was built with",
+	"getdiagnosticsresult-numcpu":        "The number of logical CPUs of the host",
+	"getdiagnosticsresult-gomaxprocs":    "The maximum number of CPUs executing Go code simultaneously",
+	"getdiagnosticsresult-goroutines":    "The number of running goroutines",
+	"getdiagnosticsresult-memory":        "The memory statistics of the node",
+	"getdiagnosticsresult-gc":            "The garbage collection statistics of the node",
+	"getdiagnosticsresult-cpuprofile":    "The file the CPU profile is being written to, if any",
+	"getdiagnosticsresult-profileserver": "The address the HTTP profiling server listens on, if it is running",
+
+	// DiagnosticsMemoryResult help.
+	"diagnosticsmemoryresult-alloc":        "Bytes of allocated heap objects",
+	"diagnosticsmemoryresult-totalalloc":   "Cumulative bytes allocated for heap objects since the node started",
+	"diagnosticsmemoryresult-sys":          "Total bytes of memory obtained from the operating system",
+	"diagnosticsmemoryresult-heapalloc":    "Bytes of allocated heap objects",
+	"diagnosticsmemoryresult-heapinuse":    "Bytes in in-use heap spans",
+	"diagnosticsmemoryresult-heapidle":     "Bytes in idle heap spans",
+	"diagnosticsmemoryresult-heapreleased": "Bytes of heap memory returned to the operating system",
+	"diagnosticsmemoryresult-heapobjects":  "Number of allocated heap objects",
+	"diagnosticsmemoryresult-stackinuse":   "Bytes in stack spans",
+	"diagnosticsmemoryresult-mallocs":      "Cumulative count of heap objects allocated",
+	"diagnosticsmemoryresult-frees":        "Cumulative count of heap objects freed",
+
+	// DiagnosticsGCResult help.
+	"diagnosticsgcresult-numgc":         "Number of completed garbage collection cycles",
+	"diagnosticsgcresult-lastgc":        "Time of the last garbage collection in seconds since 1 Jan 1970 GMT, or 0 if none ran",
+	"diagnosticsgcresult-nextgc":        "Heap size in bytes at which the next garbage collection will run",
+	"diagnosticsgcresult-pausetotal":    "Total time in microseconds the node was paused by garbage collection",
+	"diagnosticsgcresult-recentpauses":  "The most recent garbage collection pauses in microseconds, most recent first",
+	"diagnosticsgcresult-gccpufraction": "Fraction of the available CPU time used by garbage collection since the node started",
+
+	// GetHealthCmd help.
+	"gethealth--synopsis": "Returns the health of the node as reported by the /healthz and /readyz endpoints: whether its database is writable, it is synced, has enough peers, its best block is recent and its validate keys can sign blocks.",
+
+	// GetHealthResult help.
+	"gethealthresult-live":   "Whether the node is live, that is, its database is writable",
+	"gethealthresult-ready":  "Whether the node is ready to serve traffic, that is, all checks passed",
+	"gethealthresult-checks": "The result of each health check",
+
+	// HealthCheckResult help.
+	"healthcheckresult-name":   "The name of the check (database, sync, peers, blockage or validator)",
+	"healthcheckresult-ok":     "Whether the check passed",
+	"healthcheckresult-detail": "The state checked or the reason the check failed",
+
+	// GetHashCacheInfoCmd help.
+	"gethashcacheinfo--synopsis": "Returns statistics about the cache of signature hashes shared by mempool acceptance, block template generation and block connection.",
+
 	// GetHashesPerSecCmd help.
 	"gethashespersec--synopsis": "Returns a recent hashes per second performance measurement while generating coins (mining).",
 	"gethashespersec--result0":  "The number of hashes per second",
@@ -385,9 +1267,50 @@ var helpDescsEnUS = map[string]string{
 	"getheaders-hashstop":      "Block hash to stop including block headers for; if not found, all headers to the latest known block are returned.",
 	"getheaders--result0":      "Serialized block headers of all located blocks, limited to some arbitrary maximum number of hashes (currently 2000, which matches the wire protocol headers message, but this is not guaranteed)",
 
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis":       "Returns the state of the optional indexes, which are caught up with the main chain in the background after they are enabled.",
+	"getindexinfo-indexname":       "Only return the state of the index with this name",
+	"getindexinfo--result0--key":   "name",
+	"getindexinfo--result0--value": "{...}",
+	"getindexinfo--result0--desc":  "The name of the index and its state",
+
+	// IndexInfoResult help.
+	"indexinforesult-synced":            "Whether the index has caught up with the main chain",
+	"indexinforesult-best_block_height": "The height of the last block indexed, or -1 when none is indexed yet",
+	"indexinforesult-size_on_disk":      "The approximate number of bytes taken by the entries of the index, before compression",
+	"indexinforesult-health":            "The health of the index (ok, syncing, or failed)",
+	"indexinforesult-error":             "The error which stopped catching up the index when it failed",
+
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetKeyIDBalanceCmd help.
+	"getkeyidbalance--synopsis": "Returns the confirmed balance of the outputs co-signed by a keyID, either currently or after the block at a past height.\n" +
+		"Usage of this RPC requires the optional --keyidbalanceindex flag to be activated.",
+	"getkeyidbalance-keyid":  "The keyID of the account service provider key",
+	"getkeyidbalance-height": "The height of the block after which to return the balance (default: the best height)",
+
+	// GetKeyIDBalanceResult help.
+	"getkeyidbalanceresult-keyid":   "The keyID",
+	"getkeyidbalanceresult-height":  "The height of the block after which the balance applies",
+	"getkeyidbalanceresult-balance": "The total value in atoms of the outputs co-signed by the keyID",
+
+	// GetKeyIDVelocityCmd help.
+	"getkeyidvelocity--synopsis": "Returns the volume a keyID spent within the window of each velocity limit, which is net of the change paid back to the keyID.\n" +
+		"Usage of this RPC requires velocity limits to be configured with --keyidvelocitylimit.",
+	"getkeyidvelocity-keyid": "The keyID",
+
+	// GetKeyIDVelocityResult help.
+	"getkeyidvelocityresult-keyid":         "The keyID",
+	"getkeyidvelocityresult-windows":       "The volume spent within the window of each velocity limit, shortest window first",
+	"getkeyidvelocityresult-overrideuntil": "The time the velocity limits of the keyID are lifted until in seconds since 1 Jan 1970 GMT, when they are",
+
+	// KeyIDVelocityWindowResult help.
+	"keyidvelocitywindowresult-window":    "The length of the window in seconds",
+	"keyidvelocitywindowresult-maxamount": "The maximum volume in RMG the keyID may spend within the window",
+	"keyidvelocitywindowresult-spent":     "The volume in RMG the keyID spent within the window",
+	"keyidvelocitywindowresult-remaining": "The volume in RMG the keyID may still spend within the window",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
@@ -426,27 +1349,64 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
 
 	// GetPeerInfoResult help.
-	"getpeerinforesult-id":             "A unique node ID",
-	"getpeerinforesult-addr":           "The ip address and port of the peer",
-	"getpeerinforesult-addrlocal":      "Local address",
-	"getpeerinforesult-services":       "Services bitmask which represents the services supported by the peer",
-	"getpeerinforesult-relaytxes":      "Peer has requested transactions be relayed to it",
-	"getpeerinforesult-lastsend":       "Time the last message was received in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-lastrecv":       "Time the last message was sent in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-bytessent":      "Total bytes sent",
-	"getpeerinforesult-bytesrecv":      "Total bytes received",
-	"getpeerinforesult-conntime":       "Time the connection was made in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-timeoffset":     "The time offset of the peer",
-	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
-	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
-	"getpeerinforesult-version":        "The protocol version of the peer",
-	"getpeerinforesult-subver":         "The user agent of the peer",
-	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
-	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
-	"getpeerinforesult-currentheight":  "The current height of the peer",
-	"getpeerinforesult-banscore":       "The ban score",
-	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
-	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-id":                       "A unique node ID",
+	"getpeerinforesult-addr":                     "The ip address and port of the peer",
+	"getpeerinforesult-addrlocal":                "Local address",
+	"getpeerinforesult-services":                 "Services bitmask which represents the services supported by the peer",
+	"getpeerinforesult-relaytxes":                "Peer has requested transactions be relayed to it",
+	"getpeerinforesult-lastsend":                 "Time the last message was received in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-lastrecv":                 "Time the last message was sent in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-bytessent":                "Total bytes sent",
+	"getpeerinforesult-bytesrecv":                "Total bytes received",
+	"getpeerinforesult-bytessent_per_msg":        "Total bytes sent by message type",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "n",
+	"getpeerinforesult-bytessent_per_msg--desc":  "The command of the message type as the key and the bytes sent as the value",
+	"getpeerinforesult-bytesrecv_per_msg":        "Total bytes received by message type",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "n",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "The command of the message type as the key and the bytes received as the value",
+	"getpeerinforesult-sendrate":                 "Bytes per second recently sent",
+	"getpeerinforesult-recvrate":                 "Bytes per second recently received",
+	"getpeerinforesult-sendlimit":                "Maximum bytes per second sent to the peer, not counting the global limit (0 for unlimited)",
+	"getpeerinforesult-recvlimit":                "Maximum bytes per second received from the peer, not counting the global limit (0 for unlimited)",
+	"getpeerinforesult-conntime":                 "Time the connection was made in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-timeoffset":               "The time offset of the peer",
+	"getpeerinforesult-pingtime":                 "Number of microseconds the last ping took",
+	"getpeerinforesult-pingwait":                 "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-pinghistory":              "Number of microseconds the recent pings took, oldest first",
+	"getpeerinforesult-pingvariance":             "Variance of the recent ping times in microseconds squared",
+	"getpeerinforesult-version":                  "The protocol version of the peer",
+	"getpeerinforesult-subver":                   "The user agent of the peer",
+	"getpeerinforesult-inbound":                  "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-startingheight":           "The latest block height the peer knew about when the connection was established",
+	"getpeerinforesult-currentheight":            "The current height of the peer",
+	"getpeerinforesult-banscore":                 "The ban score",
+	"getpeerinforesult-feefilter":                "The requested minimum fee a transaction must have to be announced to the peer",
+	"getpeerinforesult-syncnode":                 "Whether or not the peer is the sync peer",
+	"getpeerinforesult-blocksrecv":               "Number of blocks received from the peer",
+	"getpeerinforesult-blocksaccepted":           "Number of blocks received from the peer which were accepted",
+	"getpeerinforesult-txsrecv":                  "Number of transactions received from the peer",
+	"getpeerinforesult-txsaccepted":              "Number of transactions received from the peer which were accepted to the memory pool",
+	"getpeerinforesult-sendheaders":              "Whether or not the peer asked for blocks to be announced with headers",
+	"getpeerinforesult-cmpctblocks":              "Whether or not the peer supports compact blocks",
+	"getpeerinforesult-cmpctblockshb":            "Whether or not the peer asked for new blocks to be pushed as compact blocks",
+	"getpeerinforesult-addrv2":                   "Whether or not the peer asked for addresses to be relayed with addrv2 messages",
+	"getpeerinforesult-validator":                "Whether or not the peer is a fellow federation member running a validator",
+	"getpeerinforesult-federationmember":         "The name of the federation member if the peer is one",
+
+	// GetOrphanBlocksCmd help.
+	"getorphanblocks--synopsis": "Returns the blocks in the orphan pool, whose parents are unknown, in the order they were received.",
+
+	// OrphanBlockResult help.
+	"orphanblockresult-hash":              "The hash of the block",
+	"orphanblockresult-previousblockhash": "The hash of the parent of the block",
+	"orphanblockresult-height":            "The height of the block",
+	"orphanblockresult-size":              "The size of the block in bytes",
+	"orphanblockresult-root":              "The hash of the first block of the chain of orphans the block is part of",
+	"orphanblockresult-missingblockhash":  "The hash of the missing ancestor, which is the parent of the root",
+	"orphanblockresult-received":          "Time the block was received in seconds since 1 Jan 1970 GMT",
+	"orphanblockresult-expires":           "Time the block is dropped unless its missing ancestor arrives first in seconds since 1 Jan 1970 GMT",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -471,8 +1431,10 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--synopsis":   "Returns information about a transaction given its hash.",
 	"getrawtransaction-txid":        "The hash of the transaction",
 	"getrawtransaction-verbose":     "Specifies the transaction is returned as a JSON object instead of a hex-encoded string",
+	"getrawtransaction-vinextra":    "Specify that the value, script class, addresses and keyIDs of the previous output will be returned in vin and only applies if the verbose flag is set",
 	"getrawtransaction--condition0": "verbose=false",
 	"getrawtransaction--condition1": "verbose=true",
+	"getrawtransaction--condition2": "verbose=true, vinextra=1",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
 	// GetTxOutResult help.
@@ -483,12 +1445,131 @@ var helpDescsEnUS = map[string]string{
 	"gettxoutresult-version":       "The transaction version",
 	"gettxoutresult-coinbase":      "Whether or not the transaction is a coinbase",
 
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the input of a transaction which spends the passed output, either in the main chain or in the memory pool.\n" +
+		"Usage of this RPC requires the optional --spentindex flag to be activated.",
+	"getspentinfo-request": "SpentInfoRequest object identifying the output",
+
+	// SpentInfoRequest help.
+	"spentinforequest-txid":  "The hash of the transaction of the output",
+	"spentinforequest-index": "The index of the output",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the spending input",
+	"getspentinforesult-height": "The height of the block containing the spending transaction, or 0 when it is in the memory pool",
+
+	// GetSupplyHistoryCmd help.
+	"getsupplyhistory--synopsis": "Returns every issuance and destruction of funds in the main chain between two heights along with the resulting supply.\n" +
+		"Usage of this RPC requires the optional --supplyindex flag to be activated.",
+	"getsupplyhistory-start": "The height of the first block to return changes for",
+	"getsupplyhistory-end":   "The height of the last block to return changes for, or 0 for the best height",
+
+	// SupplyChangeResult help.
+	"supplychangeresult-txid":       "The hash of the issue thread transaction",
+	"supplychangeresult-height":     "The height of the block containing the transaction",
+	"supplychangeresult-blockindex": "The index of the transaction within its block",
+	"supplychangeresult-type":       "Whether the transaction issues or destroys funds (issue/destroy)",
+	"supplychangeresult-atoms":      "The amount issued or destroyed in atoms",
+	"supplychangeresult-supply":     "The total supply in atoms after the transaction",
+	"supplychangeresult-keys":       "The hex-encoded issue keys which authorized the transaction",
+
 	// GetTxOutCmd help.
 	"gettxout--synopsis":      "Returns information about an unspent transaction output..",
 	"gettxout-txid":           "The hash of the transaction",
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutProofCmd help.
+	"gettxoutproof--synopsis": "Returns a proof that transactions are included in a block, which is verified with only the header chain.\n" +
+		"The proof is a serialized merkleblock message whose signed header commits to the transactions through a partial merkle tree.\n" +
+		"Unless the block hash is passed, the block is looked up with the transaction index (--txindex) or, without it, in the utxo set, which only knows the transactions with unspent outputs.",
+	"gettxoutproof-txids":     "The hashes of the transactions, which must all be in the same block",
+	"gettxoutproof-blockhash": "The hash of the block containing the transactions",
+	"gettxoutproof--result0":  "The hex-encoded proof",
+
+	// GetUtxoCommitmentCmd help.
+	"getutxocommitment--synopsis": "Returns the commitment to the utxo set as of a block of the main chain, which requires the utxo commitment index (--utxocommitindex).\n" +
+		"The commitment is the root of a merkle tree over 4096 bins of unspent outputs, split by the first 12 bits of their transaction hash, each summarized by an elliptic curve multiset hash.",
+	"getutxocommitment-height": "The height of the block (default: the best block)",
+
+	// GetUtxoCommitmentResult help.
+	"getutxocommitmentresult-height": "The height of the block",
+	"getutxocommitmentresult-hash":   "The hash of the block",
+	"getutxocommitmentresult-root":   "The commitment to the utxo set as of the block",
+
+	// GetUtxoProofCmd help.
+	"getutxoproof--synopsis": "Returns a proof whether an output is unspent as of the best block, which is checked against the commitment to the utxo set of the block with verifyutxoproof.\n" +
+		"The proof lists all unspent outputs of the bin of the output, so it also proves that an output is spent or never existed.\n" +
+		"It requires the utxo commitment index (--utxocommitindex).",
+	"getutxoproof-txid": "The hash of the transaction",
+	"getutxoproof-vout": "The index of the output",
+
+	// GetUtxoProofResult help.
+	"getutxoproofresult-height":       "The height of the block the proof is as of",
+	"getutxoproofresult-hash":         "The hash of the block the proof is as of",
+	"getutxoproofresult-root":         "The commitment to the utxo set as of the block",
+	"getutxoproofresult-bin":          "The bin of the output",
+	"getutxoproofresult-binoutputs":   "The number of unspent outputs of the bin listed in the proof",
+	"getutxoproofresult-unspent":      "Whether the output is unspent",
+	"getutxoproofresult-amount":       "The amount of the output in atoms, when unspent",
+	"getutxoproofresult-scriptpubkey": "The hex-encoded public key script of the output, when unspent",
+	"getutxoproofresult-proof":        "The hex-encoded proof",
+
+	// ValidatorWindowResult help.
+	"validatorwindowresult-window": "Number of most recent blocks in the window",
+	"validatorwindowresult-blocks": "Number of blocks in the window signed by the validate key",
+
+	// ValidatorInfoResult help.
+	"validatorinforesult-pubkey":           "The hex-encoded validate pubKey",
+	"validatorinforesult-active":           "Whether or not the key is part of the current validate key set",
+	"validatorinforesult-lastseenheight":   "Height of the most recent block signed by the key (omitted if the key signed none of the scanned blocks)",
+	"validatorinforesult-windows":          "Number of blocks signed by the key within each requested window",
+	"validatorinforesult-trailingblocks":   "Number of consecutive blocks at the end of the main chain signed by the key",
+	"validatorinforesult-trailingheadroom": "Number of additional consecutive blocks the key may sign before being rate limited (4294967295 if the limit is disabled)",
+	"validatorinforesult-shareheadroom":    "Number of additional blocks the key may sign within the rate limiting window before being rate limited (4294967295 if the limit is disabled)",
+	"validatorinforesult-ratelimited":      "Whether or not a block signed by the key extending the main chain would be rate limited",
+
+	// GetValidatorInfoResult help.
+	"getvalidatorinforesult-hash":          "Hash of the block at which the returned validator state is valid",
+	"getvalidatorinforesult-height":        "Height of the block at which the returned validator state is valid",
+	"getvalidatorinforesult-ratewindow":    "Number of blocks considered by the validate key rate limiting rules",
+	"getvalidatorinforesult-trailinglimit": "Maximum number of consecutive blocks a validate key may sign",
+	"getvalidatorinforesult-sharelimit":    "Maximum percentage of the rate limiting window a validate key may sign",
+	"getvalidatorinforesult-validators":    "Statistics for the active validate keys and any other key which signed a scanned block",
+
+	// HeartbeatResult help.
+	"heartbeatresult-height":   "Height of the best block of the validator",
+	"heartbeatresult-hash":     "Hash of the best block of the validator",
+	"heartbeatresult-time":     "Time the heartbeat was signed in seconds since 1 Jan 1970 GMT",
+	"heartbeatresult-received": "Time the heartbeat was received in seconds since 1 Jan 1970 GMT",
+	"heartbeatresult-from":     "Address of the peer the heartbeat was received from (omitted for the keys held by this node)",
+
+	// ValidatorHeartbeatResult help.
+	"validatorheartbeatresult-pubkey":        "The hex-encoded validate pubKey",
+	"validatorheartbeatresult-active":        "Whether or not the key is part of the current validate key set",
+	"validatorheartbeatresult-local":         "Whether or not the key is held by this node",
+	"validatorheartbeatresult-stalled":       "Whether or not the key is active and no recent heartbeat is known for it",
+	"validatorheartbeatresult-lastheartbeat": "The latest heartbeat known for the key (omitted if none is known)",
+
+	// GetValidatorHeartbeatsCmd help.
+	"getvalidatorheartbeats--synopsis": "Returns the latest heartbeat known for each active validate key and any other key which sent one, to detect stalled validators.",
+
+	// GetValidatorScheduleCmd help.
+	"getvalidatorschedule--synopsis": "Returns when each active validate key is next allowed to sign a block under the validate key rate limits.",
+
+	// ValidatorScheduleResult help.
+	"validatorscheduleresult-pubkey":              "The hex-encoded validate pubKey",
+	"validatorscheduleresult-local":               "Whether or not the key is held by this node",
+	"validatorscheduleresult-eligible":            "Whether or not the key may sign the next block",
+	"validatorscheduleresult-blocksuntileligible": "The number of blocks other validate keys have to sign before the key may sign one",
+	"validatorscheduleresult-eligibleheight":      "The height of the first block the key may sign",
+	"validatorscheduleresult-estimatedtime":       "The estimated time the key may sign a block in seconds since 1 Jan 1970 GMT, assuming blocks are produced at the target interval",
+
+	// GetValidatorInfoCmd help.
+	"getvalidatorinfo--synopsis": "Returns the validate key set along with block generation and rate limiting statistics for each key.",
+	"getvalidatorinfo-windows":   "Sizes of the windows of most recent blocks to count signed blocks for, at most 16 windows of up to 10000 blocks (default: the rate limiting window)",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -497,6 +1578,15 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":      "The banned IP address or subnet",
+	"listbannedresult-banned_until": "Time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_created":  "Time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_reason":   "The reason of the ban, 'manually added' or 'node misbehaving'",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -524,13 +1614,75 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction-allowhighfees": "Whether or not to allow insanely high fees (btcd does not yet implement this parameter, so it has no effect)",
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans an IP address or subnet, or removes its ban.\n" +
+		"Peers within a banned subnet are disconnected unless they are whitelisted or fellow federation members.",
+	"setban-subnet":   "IP address or subnet in CIDR notation (e.g. 192.168.0.0/24) to operate on",
+	"setban-subcmd":   "'add' to ban the IP address or subnet, or 'remove' to remove its ban",
+	"setban-bantime":  "Seconds the ban lasts, or the time it expires in seconds since 1 Jan 1970 GMT when absolute is true (0 for the configured ban duration)",
+	"setban-absolute": "Whether bantime is an absolute time instead of a duration",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetKeyIDVelocityOverrideCmd help.
+	"setkeyidvelocityoverride--synopsis": "Lifts the velocity limits of a keyID for a while, so its transactions exceeding them are relayed and mined, or restores them.\n" +
+		"The volume the keyID spends in the meantime still counts towards the limits once restored.",
+	"setkeyidvelocityoverride-keyid":    "The keyID",
+	"setkeyidvelocityoverride-duration": "Seconds the velocity limits are lifted for, or 0 to restore them",
+
+	// SetMockTimeCmd help.
+	"setmocktime--synopsis": "Fixes the time used for new blocks and for checking block timestamps to the passed time (simnet or regtest only), which controls the median time of the chain.",
+	"setmocktime-timestamp": "The mock time in seconds since 1 Jan 1970 GMT, or 0 to use the real time again",
+
+	// RawTxInput help.
+	"rawtxinput-txid":         "The hash of the transaction the spent output belongs to",
+	"rawtxinput-vout":         "The index of the spent output",
+	"rawtxinput-scriptPubKey": "The hex-encoded public key script of the spent output",
+	"rawtxinput-redeemScript": "This field is ignored since Prova does not support pay-to-script-hash",
+	"rawtxinput-amount":       "The amount of the spent output in RMG -- looked up in the unspent outputs of the main chain when omitted",
+
+	// SignRawTransactionError help.
+	"signrawtransactionerror-txid":      "The hash of the transaction the output spent by the input belongs to",
+	"signrawtransactionerror-vout":      "The index of the output spent by the input",
+	"signrawtransactionerror-scriptSig": "The hex-encoded signature script of the input",
+	"signrawtransactionerror-sequence":  "The sequence number of the input",
+	"signrawtransactionerror-error":     "The reason the input does not spend the output yet",
+
+	// SignRawTransactionResult help.
+	"signrawtransactionresult-hex":      "The hex-encoded transaction with the signatures added",
+	"signrawtransactionresult-complete": "Whether or not every input has all of the signatures it requires",
+	"signrawtransactionresult-errors":   "The inputs which do not spend their outputs yet",
+
+	// SignRawTransactionCmd help.
+	"signrawtransaction--synopsis": "Signs the inputs of a transaction spending Prova outputs with the provided private keys.\n" +
+		"The keyIDs and admin threads referenced by each spent output are resolved against the current chain state, so the keys of ASPs referenced by keyID are recognized along with the keys of the hashes embedded in the scripts.\n" +
+		"Valid signatures already present in the inputs are kept, which allows each party to sign in turn.",
+	"signrawtransaction-rawtx":    "Serialized, hex-encoded transaction",
+	"signrawtransaction-inputs":   "The outputs spent by the transaction which are not unspent outputs of the main chain, such as outputs of unconfirmed transactions",
+	"signrawtransaction-privkeys": "WIF-encoded private keys to sign with",
+	"signrawtransaction-flags":    "The signature hash type, which must be ALL",
+
+	// SignMessageWithPrivKeyCmd help.
+	"signmessagewithprivkey--synopsis": "Signs a message with a private key to prove control of a Prova address off-chain.\n" +
+		"The key must be one of those satisfying the address: a holder key the address commits to, or one of the ASP keys it names by keyID, which are resolved against the current chain state.",
+	"signmessagewithprivkey-privkey": "WIF-encoded private key to sign with",
+	"signmessagewithprivkey-address": "The Prova address the key belongs to",
+	"signmessagewithprivkey-message": "The message to sign",
+
+	// SignMessageWithPrivKeyResult help.
+	"signmessagewithprivkeyresult-signature": "The base-64 encoded signature of the message",
+	"signmessagewithprivkeyresult-signer":    "The key of the address which signed the message",
+
+	// MessageSignerResult help.
+	"messagesignerresult-pubkey": "The hex-encoded public key of the signer",
+	"messagesignerresult-holder": "The index of the holder key among the keys the address commits to, when signed by a holder key",
+	"messagesignerresult-keyid":  "The keyID slot of the address the signature satisfies, when signed by an ASP key",
+
 	// StopCmd help.
-	"stop--synopsis": "Shutdown Prova.",
+	"stop--synopsis": "Shutdown Prova.  The calls in progress are finished before the RPC server stops, while new calls fail with an error.",
 	"stop--result0":  "The string 'Prova stopping.'",
 
 	// SubmitBlockOptions help.
@@ -544,6 +1696,14 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// UpdatePSPTCmd help.
+	"updatepspt--synopsis":  "Adds a signature to an input of a partially signed transaction (PSPT) after verifying it.",
+	"updatepspt-pspt":       "The base64-encoded PSPT",
+	"updatepspt-inputindex": "The index of the signed input",
+	"updatepspt-pubkey":     "The hex-encoded public key which produced the signature, which must be able to spend the input as of the current chain state",
+	"updatepspt-signature":  "The hex-encoded DER signature followed by the SigHashAll hash type",
+	"updatepspt--result0":   "The base64-encoded PSPT with the signature added",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
@@ -552,6 +1712,42 @@ var helpDescsEnUS = map[string]string{
 	"validateaddress--synopsis": "Verify an address is valid.",
 	"validateaddress-address":   "Bitcoin address to validate",
 
+	// VerifyAttestationCmd help.
+	"verifyattestation--synopsis": "Decodes and verifies an attestation returned by getattestation, and compares it with the local chain.",
+	"verifyattestation-hex":       "The hex-encoded serialized attestation",
+
+	// VerifyAttestationResult help.
+	"verifyattestationresult-attestation": "The decoded attestation",
+	"verifyattestationresult-valid":       "Whether the attestation is signed by its public key",
+	"verifyattestationresult-validator":   "Whether the public key is part of the active validate key set",
+	"verifyattestationresult-mainchain":   "Whether the attested block is part of the local main chain of the same network",
+
+	// GetSPVInfoCmd help.
+	"getspvinfo--synopsis": "Returns the state of the light client (--spv): the tip of its headers, the last block it verified and the addresses it watches.",
+
+	// GetSPVInfoResult help.
+	"getspvinforesult-height":         "The height of the tip of the headers",
+	"getspvinforesult-hash":           "The hash of the tip of the headers",
+	"getspvinforesult-verifiedheight": "The height of the last verified block, which is the best block of the other commands",
+	"getspvinforesult-verifiedhash":   "The hash of the last verified block",
+	"getspvinforesult-peers":          "The number of connected full peers",
+	"getspvinforesult-validatekeys":   "The compressed validate keys as of the last verified block",
+	"getspvinforesult-watched":        "The watched addresses",
+
+	// SPVWatchedAddressResult help.
+	"spvwatchedaddressresult-address": "The watched address",
+	"spvwatchedaddressresult-height":  "The height of the first block verified for the address",
+
+	// VerifyAuditLogCmd help.
+	"verifyauditlog--synopsis": "Verifies the hash chain of the audit log file and that it still ends with the last entry recorded.",
+
+	// VerifyAuditLogResult help.
+	"verifyauditlogresult-valid":        "Whether the audit log is intact",
+	"verifyauditlogresult-entries":      "The number of valid entries",
+	"verifyauditlogresult-lasthash":     "The hash of the last entry recorded",
+	"verifyauditlogresult-invalidentry": "The sequence number of the first invalid entry, when the log is not intact",
+	"verifyauditlogresult-error":        "How the first invalid entry is invalid, when the log is not intact",
+
 	// VerifyChainCmd help.
 	"verifychain--synopsis": "Verifies the block chain database.\n" +
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
@@ -563,11 +1759,44 @@ var helpDescsEnUS = map[string]string{
 	"verifychain--result0":   "Whether or not the chain verified",
 
 	// VerifyMessageCmd help.
-	"verifymessage--synopsis": "Verify a signed message.",
-	"verifymessage-address":   "The bitcoin address to use for the signature",
-	"verifymessage-signature": "The base-64 encoded signature provided by the signer",
-	"verifymessage-message":   "The signed message",
-	"verifymessage--result0":  "Whether or not the signature verified",
+	"verifymessage--synopsis": "Verify a message signed by a holder key or an ASP key of a Prova address.\n" +
+		"The ASP keys named by the address are resolved against the current chain state.",
+	"verifymessage-address":     "The Prova address to use for the signature",
+	"verifymessage-signature":   "The base-64 encoded signature provided by the signer",
+	"verifymessage-message":     "The signed message",
+	"verifymessage-verbose":     "Returns JSON object identifying the signer when true or a boolean when false",
+	"verifymessage--condition0": "verbose=false",
+	"verifymessage--condition1": "verbose=true",
+	"verifymessage--result0":    "Whether or not the signature verified",
+
+	// VerifyMessageResult help.
+	"verifymessageresult-verified": "Whether or not the signature verified",
+	"verifymessageresult-signer":   "The key of the address which signed the message, when verified",
+	"verifymessageresult-error":    "The reason the signature did not verify",
+
+	// VerifyTxOutProofCmd help.
+	"verifytxoutproof--synopsis": "Verifies a proof returned by gettxoutproof and returns the hashes of the transactions it commits to.\n" +
+		"An error is returned when the proof does not hash to the merkle root of its header or the header is not validly signed, and no hashes are returned when the block is not in the main chain.",
+	"verifytxoutproof-proof":    "The hex-encoded proof",
+	"verifytxoutproof--result0": "The hashes of the transactions the proof commits to",
+
+	// VerifyUtxoProofCmd help.
+	"verifyutxoproof--synopsis": "Verifies a proof returned by getutxoproof against the commitment it contains and reports whether the output is unspent.\n" +
+		"The proof only shows the state of the utxo set when the commitment is trusted, so it is compared with the commitment of this node for the block when the utxo commitment index (--utxocommitindex) is enabled.",
+	"verifyutxoproof-proof": "The hex-encoded proof",
+	"verifyutxoproof-txid":  "The hash of the transaction",
+	"verifyutxoproof-vout":  "The index of the output",
+
+	// VerifyUtxoProofResult help.
+	"verifyutxoproofresult-valid":        "Whether the proof hashes to its commitment and covers the output",
+	"verifyutxoproofresult-error":        "The reason the proof is invalid",
+	"verifyutxoproofresult-height":       "The height of the block the proof is as of",
+	"verifyutxoproofresult-hash":         "The hash of the block the proof is as of",
+	"verifyutxoproofresult-root":         "The commitment to the utxo set the proof is checked against",
+	"verifyutxoproofresult-unspent":      "Whether the proof shows the output is unspent",
+	"verifyutxoproofresult-amount":       "The amount of the output in atoms, when unspent",
+	"verifyutxoproofresult-scriptpubkey": "The hex-encoded public key script of the output, when unspent",
+	"verifyutxoproofresult-mainchain":    "Whether the block is part of the local main chain with the same commitment",
 
 	// -------- Websocket-specific help --------
 
@@ -578,6 +1807,19 @@ var helpDescsEnUS = map[string]string{
 	// NotifyBlocksCmd help.
 	"notifyblocks--synopsis": "Request notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
+	// NotifyEventsCmd help.
+	"notifyevents--synopsis": "Request an eventlogged notification for every event recorded in the event log.\n" +
+		"Register before fetching the missed events with geteventlog, and skip the notifications of events which have already been processed.",
+
+	// StopNotifyEventsCmd help.
+	"stopnotifyevents--synopsis": "Cancel registered notifications for events recorded in the event log.",
+
+	// NotifyBroadcastsCmd help.
+	"notifybroadcasts--synopsis": "Request a broadcastfinished notification for every broadcast campaign reaching its final status.",
+
+	// StopNotifyBroadcastsCmd help.
+	"stopnotifybroadcasts--synopsis": "Cancel registered notifications for broadcast campaigns reaching their final status.",
+
 	// StopNotifyBlocksCmd help.
 	"stopnotifyblocks--synopsis": "Cancel registered notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
@@ -592,10 +1834,12 @@ var helpDescsEnUS = map[string]string{
 	"notifyreceived--synopsis": "Send a recvtx notification when a transaction added to mempool or appears in a newly-attached block contains a txout pkScript sending to any of the passed addresses.\n" +
 		"Matching outpoints are automatically registered for redeemingtx notifications.",
 	"notifyreceived-addresses": "List of address to receive notifications about",
+	"notifyreceived-keyids":    "List of keyIDs to receive notifications about for any txout pkScript they co-sign, regardless of the holder address",
 
 	// StopNotifyReceivedCmd help.
 	"stopnotifyreceived--synopsis": "Cancel registered receive notifications for each passed address.",
 	"stopnotifyreceived-addresses": "List of address to cancel receive notifications for",
+	"stopnotifyreceived-keyids":    "List of keyIDs to cancel receive notifications for",
 
 	// OutPoint help.
 	"outpoint-hash":  "The hex-encoded bytes of the outpoint hash",
@@ -604,16 +1848,19 @@ var helpDescsEnUS = map[string]string{
 	// NotifySpentCmd help.
 	"notifyspent--synopsis": "Send a redeemingtx notification when a transaction spending an outpoint appears in mempool (if relayed to this Prova instance) and when such a transaction first appears in a newly-attached block.",
 	"notifyspent-outpoints": "List of transaction outpoints to monitor.",
+	"notifyspent-keyids":    "List of keyIDs to monitor. Any transaction spending an output co-signed by one of the keyIDs is notified until the keyID is removed with stopnotifyspent.",
 
 	// StopNotifySpentCmd help.
 	"stopnotifyspent--synopsis": "Cancel registered spending notifications for each passed outpoint.",
 	"stopnotifyspent-outpoints": "List of transaction outpoints to stop monitoring.",
+	"stopnotifyspent-keyids":    "List of keyIDs to stop monitoring.",
 
 	// LoadTxFilterCmd help.
 	"loadtxfilter--synopsis": "Load, add to, or reload a websocket client's transaction filter for mempool transactions, new blocks and rescanblocks.",
 	"loadtxfilter-reload":    "Load a new filter instead of adding data to an existing one",
 	"loadtxfilter-addresses": "Array of addresses to add to the transaction filter",
 	"loadtxfilter-outpoints": "Array of outpoints to add to the transaction filter",
+	"loadtxfilter-keyids":    "Array of keyIDs to add to the transaction filter; outputs co-signed by any of them match regardless of the holder address",
 
 	// Rescan help.
 	"rescan--synopsis": "Rescan block chain for transactions to addresses.\n" +
@@ -633,61 +1880,168 @@ var helpDescsEnUS = map[string]string{
 	// RescannedBlock help.
 	"rescannedblock-hash":         "Hash of the matching block.",
 	"rescannedblock-transactions": "List of matching transactions, serialized and hex-encoded.",
+
+	// WatchCmd help.
+	"watch--synopsis": "Watch addresses and keyIDs, rescanning the block chain for them in the background from the birthday height.\n" +
+		"Rescan results are sent as recvtx and redeemingtx notifications, along with watchrescanprogress notifications, until a watchrescanfinished notification.\n" +
+		"The client is then notified about the addresses and keyIDs like after notifyreceived and notifyspent.",
+	"watch-addresses": "List of addresses to watch",
+	"watch-keyids":    "List of keyIDs to watch; outputs co-signed by any of them match regardless of the holder address",
+	"watch-birthday":  "Height of the first block which may involve the addresses and keyIDs",
+
+	// WatchResult help.
+	"watchresult-watchid": "ID of the watch, identifying its rescan notifications",
 }
 
 // rpcResultTypes specifies the result types that each RPC command can return.
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
-	"getaddresstxids":       {(*[]string)(nil)},
-	"getadmininfo":          {(*btcjson.GetAdminInfoResult)(nil)},
-	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getheaders":            {(*[]string)(nil)},
-	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*int64)(nil)},
-	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
-	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
-	"node":                  nil,
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"ping":                  nil,
-	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"setvalidatekeys":       nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
+	"addnode":                    nil,
+	"admin.addproposalsigs":      {(*btcjson.AdminProposalResult)(nil)},
+	"admin.destroytokens":        {(*btcjson.AdminTxResult)(nil)},
+	"admin.dropproposal":         nil,
+	"admin.freezekeyid":          {(*btcjson.AdminTxResult)(nil)},
+	"admin.getkeyidinfo":         {(*btcjson.AdminKeyIDInfoResult)(nil)},
+	"admin.getproposal":          {(*btcjson.AdminProposalResult)(nil)},
+	"admin.issuetokens":          {(*btcjson.AdminTxResult)(nil)},
+	"admin.listkeysets":          {(*btcjson.AdminListKeySetsResult)(nil)},
+	"admin.listproposals":        {(*[]btcjson.AdminProposalResult)(nil)},
+	"admin.proposetx":            {(*btcjson.AdminProposalResult)(nil)},
+	"admin.provisionvalidatekey": {(*btcjson.AdminTxResult)(nil)},
+	"admin.revokekey":            {(*btcjson.AdminTxResult)(nil)},
+	"admin.rotatekeyid":          {(*btcjson.AdminRotateKeyIDResult)(nil)},
+	"admin.signproposal":         {(*btcjson.AdminProposalResult)(nil)},
+	"admin.unfreezekeyid":        {(*btcjson.AdminTxResult)(nil)},
+	"backupchainstate":           {(*btcjson.BackupChainStateResult)(nil)},
+	"broadcasttransaction":       {(*btcjson.BroadcastTransactionResult)(nil)},
+	"clearbanned":                nil,
+	"combinepspt":                {(*string)(nil)},
+	"compactdb":                  nil,
+	"createconsolidatetx":        {(*btcjson.TemplateTxResult)(nil)},
+	"createdestroytx":            {(*string)(nil)},
+	"createissuetx":              {(*string)(nil)},
+	"createkeyrevoketx":          {(*string)(nil)},
+	"createprovisiontx":          {(*string)(nil)},
+	"createpspt":                 {(*string)(nil)},
+	"createrawtransaction":       {(*string)(nil)},
+	"createsplittx":              {(*btcjson.TemplateTxResult)(nil)},
+	"createsweeptx":              {(*btcjson.TemplateTxResult)(nil)},
+	"debuglevel":                 {(*string)(nil), (*string)(nil)},
+	"debugscript":                {(*btcjson.DebugScriptResult)(nil)},
+	"decoderawtransaction":       {(*btcjson.TxRawDecodeResult)(nil)},
+	"decodescript":               {(*btcjson.DecodeScriptResult)(nil)},
+	"dropindex":                  nil,
+	"enableindex":                nil,
+	"finalizepspt":               {(*btcjson.FinalizePSPTResult)(nil)},
+	"fundrawtransaction":         {(*btcjson.FundRawTransactionResult)(nil)},
+	"generate":                   {(*[]string)(nil)},
+	"generatetoaddress":          {(*[]string)(nil)},
+	"generatewithvalidatekey":    {(*[]string)(nil)},
+	"getaddednodeinfo":           {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":          {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getaddressdeltas":           {(*[]btcjson.AddressDeltaResult)(nil)},
+	"getaddressmempool":          {(*[]btcjson.AddressMempoolResult)(nil)},
+	"getaddresstxids":            {(*[]string)(nil)},
+	"getaddressutxos":            {(*[]btcjson.AddressUtxoResult)(nil)},
+	"getadmininfo":               {(*btcjson.GetAdminInfoResult)(nil)},
+	"getattestation":             {(*btcjson.AttestationResult)(nil)},
+	"getauditlog":                {(*btcjson.GetAuditLogResult)(nil)},
+	"getbestblock":               {(*btcjson.GetBestBlockResult)(nil)},
+	"getbestblockhash":           {(*string)(nil)},
+	"getblock":                   {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil), (*btcjson.GetBlockVerboseTxResult)(nil)},
+	"getblockchaininfo":          {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getblockcount":              {(*int64)(nil)},
+	"getblockhash":               {(*string)(nil)},
+	"getblockhashbytime":         {(*btcjson.GetBlockHashByTimeResult)(nil)},
+	"getblockheader":             {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockheaders":            {(*[]string)(nil), (*[]btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblocktemplate":           {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getbroadcaststatus":         {(*btcjson.BroadcastStatusResult)(nil)},
+	"getcfilter":                 {(*string)(nil)},
+	"getcfilterheader":           {(*string)(nil)},
+	"getcirculatingsupply":       {(*btcjson.GetCirculatingSupplyResult)(nil)},
+	"getclusterinfo":             {(*btcjson.GetClusterInfoResult)(nil)},
+	"getconnectioncount":         {(*int32)(nil)},
+	"getconsistencystatus":       {(*btcjson.GetConsistencyStatusResult)(nil)},
+	"getcurrentnet":              {(*uint32)(nil)},
+	"getdbinfo":                  {(*btcjson.GetDBInfoResult)(nil)},
+	"getdiagnostics":             {(*btcjson.GetDiagnosticsResult)(nil)},
+	"getdifficulty":              {(*float64)(nil)},
+	"geteventlog":                {(*btcjson.GetEventLogResult)(nil)},
+	"getexploreraddress":         {(*btcjson.ExplorerAddressResult)(nil)},
+	"getexplorerblocks":          {(*[]btcjson.ExplorerBlockResult)(nil)},
+	"getexplorercharts":          {(*[]btcjson.ExplorerDayResult)(nil)},
+	"getexplorertransaction":     {(*btcjson.ExplorerTxResult)(nil)},
+	"getgenerate":                {(*bool)(nil)},
+	"gethashcacheinfo":           {(*btcjson.GetHashCacheInfoResult)(nil)},
+	"gethealth":                  {(*btcjson.GetHealthResult)(nil)},
+	"gethashespersec":            {(*float64)(nil)},
+	"getheaders":                 {(*[]string)(nil)},
+	"getindexinfo":               {(*map[string]btcjson.IndexInfoResult)(nil)},
+	"getinfo":                    {(*btcjson.InfoChainResult)(nil)},
+	"getkeyidbalance":            {(*btcjson.GetKeyIDBalanceResult)(nil)},
+	"getkeyidvelocity":           {(*btcjson.GetKeyIDVelocityResult)(nil)},
+	"getmempoolinfo":             {(*btcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":              {(*btcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":               {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkhashps":           {(*int64)(nil)},
+	"getorphanblocks":            {(*[]btcjson.OrphanBlockResult)(nil)},
+	"getpeerinfo":                {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":              {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrpcinfo":                 {(*btcjson.GetRPCInfoResult)(nil)},
+	"getloglevels":               {(*btcjson.GetLogLevelsResult)(nil)},
+	"rpc.discover":               {(*map[string]interface{})(nil)},
+	"getrawtransaction":          {(*string)(nil), (*btcjson.TxRawResult)(nil), (*btcjson.SearchRawTransactionsResult)(nil)},
+	"getspentinfo":               {(*btcjson.GetSpentInfoResult)(nil)},
+	"getspvinfo":                 {(*btcjson.GetSPVInfoResult)(nil)},
+	"getsupplyhistory":           {(*[]btcjson.SupplyChangeResult)(nil)},
+	"gettxout":                   {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutproof":              {(*string)(nil)},
+	"getutxocommitment":          {(*btcjson.GetUtxoCommitmentResult)(nil)},
+	"getutxoproof":               {(*btcjson.GetUtxoProofResult)(nil)},
+	"getvalidatorheartbeats":     {(*[]btcjson.ValidatorHeartbeatResult)(nil)},
+	"getvalidatorschedule":       {(*[]btcjson.ValidatorScheduleResult)(nil)},
+	"getvalidatorinfo":           {(*btcjson.GetValidatorInfoResult)(nil)},
+	"node":                       nil,
+	"help":                       {(*string)(nil), (*string)(nil)},
+	"listbanned":                 {(*[]btcjson.ListBannedResult)(nil)},
+	"ping":                       nil,
+	"searchrawtransactions":      {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
+	"sendrawtransaction":         {(*string)(nil)},
+	"setban":                     nil,
+	"setgenerate":                nil,
+	"setkeyidvelocityoverride":   nil,
+	"setmocktime":                nil,
+	"resetsignguard":             {(*btcjson.SignGuardRecordResult)(nil)},
+	"rotaterpcauth":              {(*btcjson.RotateRPCAuthResult)(nil)},
+	"setprofileserver":           {(*string)(nil)},
+	"setvalidatekeys":            nil,
+	"signmessagewithprivkey":     {(*btcjson.SignMessageWithPrivKeyResult)(nil)},
+	"signrawtransaction":         {(*btcjson.SignRawTransactionResult)(nil)},
+	"startcpuprofile":            {(*string)(nil)},
+	"stop":                       {(*string)(nil)},
+	"stopcpuprofile":             {(*string)(nil)},
+	"submitblock":                {nil, (*string)(nil)},
+	"updatepspt":                 {(*string)(nil)},
+	"validateaddress":            {(*btcjson.ValidateAddressChainResult)(nil)},
+	"verifyattestation":          {(*btcjson.VerifyAttestationResult)(nil)},
+	"verifyauditlog":             {(*btcjson.VerifyAuditLogResult)(nil)},
+	"verifychain":                {(*bool)(nil)},
+	"verifymessage":              {(*bool)(nil), (*btcjson.VerifyMessageResult)(nil)},
+	"verifytxoutproof":           {(*[]string)(nil)},
+	"verifyutxoproof":            {(*btcjson.VerifyUtxoProofResult)(nil)},
+	"writeprofile":               {(*string)(nil)},
 
 	// Websocket commands.
 	"loadtxfilter":              nil,
 	"session":                   {(*btcjson.SessionResult)(nil)},
 	"notifyblocks":              nil,
 	"stopnotifyblocks":          nil,
+	"notifyevents":              nil,
+	"stopnotifyevents":          nil,
+	"notifybroadcasts":          nil,
+	"stopnotifybroadcasts":      nil,
 	"notifynewtransactions":     nil,
 	"stopnotifynewtransactions": nil,
 	"notifyreceived":            nil,
@@ -696,6 +2050,7 @@ var rpcResultTypes = map[string][]interface{}{
 	"stopnotifyspent":           nil,
 	"rescan":                    nil,
 	"rescanblocks":              {(*[]btcjson.RescannedBlock)(nil)},
+	"watch":                     {(*btcjson.WatchResult)(nil)},
 }
 
 // helpCacher provides a concurrent safe type that provides help and usage for
@@ -704,6 +2059,7 @@ type helpCacher struct {
 	sync.Mutex
 	usage      string
 	methodHelp map[string]string
+	discover   *btcjson.OpenRPCDocument
 }
 
 // rpcMethodHelp returns an RPC help string for the provided method.
@@ -772,6 +2128,56 @@ func (c *helpCacher) rpcUsage(includeWebsockets bool) (string, error) {
 	return c.usage, nil
 }
 
+// rpcDiscover returns an OpenRPC document describing all supported RPC
+// commands, including the websocket commands.
+//
+// This function is safe for concurrent access.
+func (c *helpCacher) rpcDiscover() (*btcjson.OpenRPCDocument, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	// Return the cached document if it is available.
+	if c.discover != nil {
+		return c.discover, nil
+	}
+
+	// Generate the description of every command.  Commands such as help
+	// which have a websocket handler of their own are only described once.
+	methods := make([]string, 0, len(rpcHandlers)+len(wsHandlers))
+	for k := range rpcHandlers {
+		methods = append(methods, k)
+	}
+	for k := range wsHandlers {
+		if _, ok := rpcHandlers[k]; !ok {
+			methods = append(methods, k)
+		}
+	}
+	sort.Strings(methods)
+	doc := &btcjson.OpenRPCDocument{
+		OpenRPC: btcjson.OpenRPCVersion,
+		Info: btcjson.OpenRPCInfo{
+			Title:   "Prova JSON-RPC API",
+			Version: version(),
+		},
+		Methods: make([]btcjson.OpenRPCMethod, 0, len(methods)),
+	}
+	for _, method := range methods {
+		resultTypes, ok := rpcResultTypes[method]
+		if !ok {
+			return nil, errors.New("no result types specified " +
+				"for method " + method)
+		}
+		desc, err := btcjson.GenerateMethodDescriptor(method,
+			helpDescsEnUS, resultTypes...)
+		if err != nil {
+			return nil, err
+		}
+		doc.Methods = append(doc.Methods, *desc)
+	}
+	c.discover = doc
+	return doc, nil
+}
+
 // newHelpCacher returns a new instance of a help cacher which provides help and
 // usage for the RPC server commands and caches the results for future calls.
 func newHelpCacher() *helpCacher {
@@ -63,4 +63,27 @@ func TestHelp(t *testing.T) {
 			continue
 		}
 	}
+
+	// Ensure the OpenRPC document describing every command can be
+	// generated without errors.
+	doc, err := helpCacher.rpcDiscover()
+	if err != nil {
+		t.Fatalf("Failed to generate OpenRPC document: %v", err)
+	}
+	for i, method := range doc.Methods {
+		_, isHTTP := rpcHandlers[method.Name]
+		if method.WebsocketOnly == isHTTP {
+			t.Errorf("OpenRPC document marks method '%v' as "+
+				"websocket only: %v", method.Name,
+				method.WebsocketOnly)
+		}
+		if i > 0 && doc.Methods[i-1].Name >= method.Name {
+			t.Errorf("OpenRPC document describes method '%v' "+
+				"out of order", method.Name)
+		}
+	}
+	if _, err := helpCacher.rpcDiscover(); err != nil {
+		t.Fatalf("Failed to generate OpenRPC document (cached): %v",
+			err)
+	}
 }
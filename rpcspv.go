@@ -0,0 +1,360 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// rpcSPVHandlers maps the RPC commands served in light client mode (--spv) to
+// their handlers.  The best block of these commands is the last verified
+// block.
+var rpcSPVHandlers = map[string]commandHandler{
+	"decoderawtransaction": handleDecodeRawTransaction,
+	"decodescript":         handleDecodeScript,
+	"getaddressbalance":    handleSPVGetAddressBalance,
+	"getaddresstxids":      handleSPVGetAddressTxIds,
+	"getaddressutxos":      handleSPVGetAddressUtxos,
+	"getbestblockhash":     handleSPVGetBestBlockHash,
+	"getblockcount":        handleSPVGetBlockCount,
+	"getblockhash":         handleSPVGetBlockHash,
+	"getblockheader":       handleSPVGetBlockHeader,
+	"getconnectioncount":   handleSPVGetConnectionCount,
+	"getspvinfo":           handleGetSPVInfo,
+	"help":                 handleHelp,
+	"sendrawtransaction":   handleSPVSendRawTransaction,
+	"stop":                 handleStop,
+	"validateaddress":      handleValidateAddress,
+	"verifytxoutproof":     handleSPVVerifyTxOutProof,
+}
+
+// newSPVRPCServer returns a new instance of the rpcServer struct serving the
+// RPCs of the passed light client node.
+func newSPVRPCServer(listenAddrs []string, n *spvNode) (*rpcServer, error) {
+	rpc := rpcServer{
+		spv:                    n,
+		statusLines:            make(map[int]string),
+		helpCacher:             newHelpCacher(),
+		requestProcessShutdown: make(chan struct{}),
+		quit:                   make(chan int),
+	}
+	if err := rpc.initListeners(listenAddrs); err != nil {
+		return nil, err
+	}
+	return &rpc, nil
+}
+
+// spvCmdResult returns the result of the passed command in light client mode.
+func (s *rpcServer) spvCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct{}) (interface{}, error) {
+	handler, ok := rpcSPVHandlers[cmd.method]
+	if !ok {
+		if _, ok := rpcHandlers[cmd.method]; !ok {
+			return nil, btcjson.ErrRPCMethodNotFound
+		}
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "Command " + cmd.method + " is not supported " +
+				"in light client mode (--spv)",
+		}
+	}
+	return handler(s, cmd.cmd, closeChan)
+}
+
+// spvAddresses decodes the addresses of the passed request.
+func spvAddresses(request *btcjson.AddressTxRequest) ([]provautil.Address, error) {
+	if request == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Addresses must be specified",
+		}
+	}
+
+	addrs := make([]provautil.Address, 0, len(request.Addresses))
+	for _, address := range request.Addresses {
+		addr, err := provautil.DecodeAddress(address, activeNetParams.Params)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// spvAddressError returns the RPC error of a failed lookup of a watched
+// address.
+func spvAddressError(err error) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidAddressOrKey,
+		Message: err.Error() + " (--spvwatchaddress)",
+	}
+}
+
+// handleSPVGetAddressBalance implements the getaddressbalance command in light
+// client mode.
+func handleSPVGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	addrs, err := spvAddresses(c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.GetAddressBalanceResult
+	for _, addr := range addrs {
+		balance, received, err := s.spv.chain.Balance(addr)
+		if err != nil {
+			return nil, spvAddressError(err)
+		}
+		result.Balance += balance
+		result.Received += received
+	}
+	return result, nil
+}
+
+// handleSPVGetAddressUtxos implements the getaddressutxos command in light
+// client mode.
+func handleSPVGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	addrs, err := spvAddresses(c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]btcjson.AddressUtxoResult, 0)
+	for i, addr := range addrs {
+		utxos, err := s.spv.chain.Utxos(addr)
+		if err != nil {
+			return nil, spvAddressError(err)
+		}
+		for _, utxo := range utxos {
+			results = append(results, btcjson.AddressUtxoResult{
+				Address:     c.Request.Addresses[i],
+				Txid:        utxo.OutPoint.Hash.String(),
+				OutputIndex: utxo.OutPoint.Index,
+				Script:      hex.EncodeToString(utxo.PkScript),
+				Atoms:       utxo.Amount,
+				Height:      utxo.Height,
+			})
+		}
+	}
+	return results, nil
+}
+
+// handleSPVGetAddressTxIds implements the getaddresstxids command in light
+// client mode.  The start and end heights are inclusive, and an end height of
+// zero stands for the last verified block.
+func handleSPVGetAddressTxIds(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressTxIdsCmd)
+	addrs, err := spvAddresses(c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	start := c.Request.Start
+	_, end := s.spv.chain.VerifiedTip()
+	if c.Request.End > 0 && c.Request.End < end {
+		end = c.Request.End
+	}
+	if c.Request.End > 0 && start > c.Request.End {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	reply := make([]string, 0)
+	for _, addr := range addrs {
+		txs, err := s.spv.chain.Transactions(addr, start, end)
+		if err != nil {
+			return nil, spvAddressError(err)
+		}
+		for _, tx := range txs {
+			reply = append(reply, tx.Hash.String())
+		}
+	}
+	return reply, nil
+}
+
+// handleSPVGetBestBlockHash implements the getbestblockhash command in light
+// client mode.
+func handleSPVGetBestBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	hash, _ := s.spv.chain.VerifiedTip()
+	return hash.String(), nil
+}
+
+// handleSPVGetBlockCount implements the getblockcount command in light client
+// mode.
+func handleSPVGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	_, height := s.spv.chain.VerifiedTip()
+	return int64(height), nil
+}
+
+// handleSPVGetBlockHash implements the getblockhash command in light client
+// mode.
+func handleSPVGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHashCmd)
+	_, verified := s.spv.chain.VerifiedTip()
+	if c.Index < 0 || c.Index > int64(verified) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+	hash, err := s.spv.chain.HashByHeight(uint32(c.Index))
+	if err != nil {
+		context := "Failed to load block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return hash.String(), nil
+}
+
+// handleSPVGetBlockHeader implements the getblockheader command in light
+// client mode.  Only the headers of the verified blocks are served.
+func handleSPVGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHeaderCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	_, verified := s.spv.chain.VerifiedTip()
+	blockHeader, err := s.spv.chain.HeaderByHash(hash)
+	if err != nil || blockHeader.Height > verified {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// When the verbose flag isn't set, simply return the serialized block
+	// header as a hex-encoded string.
+	if c.Verbose != nil && !*c.Verbose {
+		var headerBuf bytes.Buffer
+		err := blockHeader.Serialize(&headerBuf)
+		if err != nil {
+			context := "Failed to serialize block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		return hex.EncodeToString(headerBuf.Bytes()), nil
+	}
+
+	// Get next block hash unless there are none.
+	var nextHashString string
+	if blockHeader.Height < verified {
+		nextHash, err := s.spv.chain.HashByHeight(blockHeader.Height + 1)
+		if err != nil {
+			context := "No next block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		nextHashString = nextHash.String()
+	}
+
+	return blockHeaderVerboseResult(blockHeader, c.Hash,
+		uint64(1+verified-blockHeader.Height), nextHashString), nil
+}
+
+// handleSPVGetConnectionCount implements the getconnectioncount command in
+// light client mode.
+func handleSPVGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.spv.ConnectedCount(), nil
+}
+
+// handleSPVSendRawTransaction implements the sendrawtransaction command in
+// light client mode.  The transaction is relayed to the connected peers
+// without being validated.
+func handleSPVSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SendRawTransactionCmd)
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+
+	if _, err := s.spv.RelayTransaction(&msgTx); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNotConnected,
+			Message: "Failed to relay transaction: " + err.Error(),
+		}
+	}
+	return msgTx.TxHash().String(), nil
+}
+
+// handleSPVVerifyTxOutProof implements the verifytxoutproof command in light
+// client mode.  The block of the proof only has to be in the main chain of the
+// headers, which have been checked, so the inclusion of payments is verified
+// without waiting for the blocks to be verified.
+func handleSPVVerifyTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyTxOutProofCmd)
+
+	header, txHashes, err := decodeTxOutProof(c.Proof)
+	if err != nil {
+		return nil, err
+	}
+	blkHash := header.BlockHash()
+
+	txids := make([]string, 0, len(txHashes))
+	if _, err := s.spv.chain.HeaderByHash(&blkHash); err != nil {
+		return txids, nil
+	}
+	for _, txHash := range txHashes {
+		txids = append(txids, txHash.String())
+	}
+	return txids, nil
+}
+
+// handleGetSPVInfo implements the getspvinfo command.
+func handleGetSPVInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.spv == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The node is not running in light client mode (--spv)",
+		}
+	}
+
+	chain := s.spv.chain
+	hash, height := chain.Tip()
+	verifiedHash, verifiedHeight := chain.VerifiedTip()
+	result := &btcjson.GetSPVInfoResult{
+		Height:         height,
+		Hash:           hash.String(),
+		VerifiedHeight: verifiedHeight,
+		VerifiedHash:   verifiedHash.String(),
+		Peers:          s.spv.ConnectedCount(),
+		ValidateKeys:   make([]string, 0),
+		Watched:        make([]btcjson.SPVWatchedAddressResult, 0),
+	}
+	for _, key := range chain.ValidateKeys() {
+		result.ValidateKeys = append(result.ValidateKeys,
+			hex.EncodeToString(key.SerializeCompressed()))
+	}
+	for _, w := range chain.Addresses() {
+		result.Watched = append(result.Watched,
+			btcjson.SPVWatchedAddressResult{
+				Address: w.Address.EncodeAddress(),
+				Height:  w.Height,
+			})
+	}
+	return result, nil
+}
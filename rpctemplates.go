@@ -0,0 +1,231 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
+	"github.com/bitgo/prova/wire"
+)
+
+// templateTxError converts an error returned by the transaction templates of
+// the wallet package to an RPC error.
+func templateTxError(err error) error {
+	switch err {
+	case wallet.ErrInsufficientFunds:
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletInsufficientFunds,
+			Message: "Insufficient funds to pay the fee and a non-dust output",
+		}
+	case wallet.ErrTxTooLarge:
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The transaction would exceed the maximum standard size",
+		}
+	}
+	context := "Failed to create transaction"
+	return internalRPCError(err.Error(), context)
+}
+
+// templateTxResult returns the result of the template commands for the passed
+// unsigned transaction, its fee and the outputs it spends by outpoint.
+func templateTxResult(mtx *wire.MsgTx, fee provautil.Amount,
+	prevOuts map[wire.OutPoint]btcjson.AddressUtxoResult) (*btcjson.TemplateTxResult, error) {
+
+	hexTx, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
+	}
+	result := &btcjson.TemplateTxResult{
+		Hex:      hexTx,
+		Fee:      fee.ToRMG(),
+		PrevOuts: make([]btcjson.AddressUtxoResult, 0, len(mtx.TxIn)),
+	}
+	for _, txIn := range mtx.TxIn {
+		result.PrevOuts = append(result.PrevOuts,
+			prevOuts[txIn.PreviousOutPoint])
+	}
+	return result, nil
+}
+
+// handleCreateSweepTx handles createsweeptx commands.  It builds an unsigned
+// transaction spending every spendable output of an address to a single
+// output paying to the destination.
+func handleCreateSweepTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateSweepTxCmd)
+
+	pkScript, err := provaOutputScript(s, c.Destination)
+	if err != nil {
+		return nil, err
+	}
+	feePerKB, err := rpcFeeRate(s, c.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	coins, prevOuts, err := watchOnlyCoins(s, []string{c.Address},
+		*c.MinConf, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletInsufficientFunds,
+			Message: "No spendable outputs to sweep",
+		}
+	}
+
+	mtx, fee, err := wallet.NewSweepTx(coins, pkScript, feePerKB)
+	if err != nil {
+		return nil, templateTxError(err)
+	}
+	return templateTxResult(mtx, fee, prevOuts)
+}
+
+// handleCreateConsolidateTx handles createconsolidatetx commands.  It builds
+// an unsigned transaction spending the smallest spendable outputs of the
+// addresses below a threshold to a single output paying to the destination.
+func handleCreateConsolidateTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateConsolidateTxCmd)
+
+	if len(c.Addresses) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Addresses must be specified",
+		}
+	}
+	threshold, err := provautil.NewAmount(c.Threshold)
+	if err != nil || threshold <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The threshold must be positive",
+		}
+	}
+	if *c.MaxInputs < 2 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The maximum number of inputs must be at least 2",
+		}
+	}
+	pkScript, err := provaOutputScript(s, c.Destination)
+	if err != nil {
+		return nil, err
+	}
+	feePerKB, err := rpcFeeRate(s, c.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	coins, prevOuts, err := watchOnlyCoins(s, c.Addresses, *c.MinConf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	coins = wallet.ConsolidationCoins(coins, threshold, *c.MaxInputs,
+		feePerKB)
+	if len(coins) < 2 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCWalletInsufficientFunds,
+			Message: fmt.Sprintf("Fewer than 2 spendable outputs "+
+				"below %v to consolidate", threshold),
+		}
+	}
+	mtx, fee, err := wallet.NewSweepTx(coins, pkScript, feePerKB)
+	if err != nil {
+		return nil, templateTxError(err)
+	}
+	return templateTxResult(mtx, fee, prevOuts)
+}
+
+// handleCreateSplitTx handles createsplittx commands.  It builds an unsigned
+// transaction spending an unspent output to a number of outputs of equal
+// amounts, or of the passed amount with the remainder as change, paying to an
+// address.
+func handleCreateSplitTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateSplitTxCmd)
+
+	if c.Count < 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The number of outputs must be at least 1",
+		}
+	}
+	var amount provautil.Amount
+	if c.Amount != nil {
+		var err error
+		amount, err = newTxAmount(s, *c.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pkScript, err := provaOutputScript(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	feePerKB, err := rpcFeeRate(s, c.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the output to split, which must be spendable.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+	prevOut := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+	entry, err := s.chain.FetchUtxoEntry(txHash)
+	if err != nil {
+		context := "Failed to fetch utxo"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if entry == nil || entry.IsOutputSpent(c.Vout) ||
+		s.server.txMemPool.CheckSpend(prevOut) != nil {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: fmt.Sprintf("No unspent output %v", prevOut),
+		}
+	}
+	best := s.chain.BestSnapshot()
+	if entry.IsCoinBase() && best.Height-entry.BlockHeight()+1 <
+		uint32(s.server.chainParams.CoinbaseMaturity) {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Output %v is an immature coinbase", prevOut),
+		}
+	}
+	coin := wallet.Coin{
+		OutPoint: prevOut,
+		Amount:   provautil.Amount(entry.AmountByIndex(c.Vout)),
+		PkScript: entry.PkScriptByIndex(c.Vout),
+	}
+	var addr string
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(coin.PkScript,
+		s.server.chainParams)
+	if len(addrs) > 0 {
+		addr = addrs[0].EncodeAddress()
+	}
+
+	mtx, fee, err := wallet.NewSplitTx(coin, pkScript, c.Count, amount,
+		feePerKB)
+	if err != nil {
+		return nil, templateTxError(err)
+	}
+	return templateTxResult(mtx, fee, map[wire.OutPoint]btcjson.AddressUtxoResult{
+		prevOut: {
+			Address:     addr,
+			Txid:        prevOut.Hash.String(),
+			OutputIndex: prevOut.Index,
+			Script:      hex.EncodeToString(coin.PkScript),
+			Atoms:       int64(coin.Amount),
+			Height:      entry.BlockHeight(),
+		},
+	})
+}
@@ -109,6 +109,7 @@ func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight int32,
 		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, net),
 		PkScript: pkScript,
 	})
+	tx.LockTime = uint32(nextBlockHeight)
 	return provautil.NewTx(tx), nil
 }
 
@@ -0,0 +1,99 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// defaultRPCUnixSocketMode is the default file mode of the RPC Unix domain
+// sockets, which only allows the user running the server to connect.
+const defaultRPCUnixSocketMode = "0600"
+
+// parseRPCUnixSocketMode parses the passed octal file mode of the RPC Unix
+// domain sockets.
+func parseRPCUnixSocketMode(mode string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm&^uint64(os.ModePerm) != 0 {
+		return 0, fmt.Errorf("invalid file mode %q -- the mode must "+
+			"be octal permission bits such as 0660", mode)
+	}
+	return os.FileMode(perm), nil
+}
+
+// rpcUnixConn is a connection accepted from an RPC Unix domain socket.  The
+// remote address of Unix domain socket connections is unnamed, so the path of
+// the socket is reported instead to identify the client in logs.
+type rpcUnixConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+// RemoteAddr returns the address of the socket the connection was accepted
+// from.
+//
+// This is part of the net.Conn interface.
+func (c *rpcUnixConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// rpcUnixListener is a listener on an RPC Unix domain socket.
+type rpcUnixListener struct {
+	net.Listener
+	addr net.Addr
+}
+
+// Accept waits for and returns the next connection to the socket.
+//
+// This is part of the net.Listener interface.
+func (l *rpcUnixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rpcUnixConn{Conn: conn, addr: l.addr}, nil
+}
+
+// listenRPCUnix listens for RPC connections on a Unix domain socket at the
+// passed path with the passed file mode.  A socket left behind by a server
+// which did not shut down cleanly is replaced, but a socket another process
+// is still listening on is not.  The socket is removed when the listener is
+// closed.
+//
+// Connections to the socket don't use TLS since they never leave the host.
+// Access is instead controlled by the permissions of the socket file, in
+// addition to the RPC credentials every client must provide.
+func listenRPCUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a "+
+				"socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &rpcUnixListener{Listener: listener, addr: listener.Addr()}, nil
+}
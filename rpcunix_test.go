@@ -0,0 +1,113 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseRPCUnixSocketMode ensures only octal permission bits are accepted
+// as the file mode of the RPC Unix domain sockets.
+func TestParseRPCUnixSocketMode(t *testing.T) {
+	tests := []struct {
+		mode  string
+		want  os.FileMode
+		valid bool
+	}{
+		{"0600", 0600, true},
+		{"660", 0660, true},
+		{"0777", 0777, true},
+		{"01777", 0, false},
+		{"0680", 0, false},
+		{"rw", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		mode, err := parseRPCUnixSocketMode(test.mode)
+		if (err == nil) != test.valid {
+			t.Errorf("parseRPCUnixSocketMode(%q): unexpected error "+
+				"%v", test.mode, err)
+			continue
+		}
+		if mode != test.want {
+			t.Errorf("parseRPCUnixSocketMode(%q): got %v, want %v",
+				test.mode, mode, test.want)
+		}
+	}
+}
+
+// TestListenRPCUnix ensures RPC Unix domain sockets are created with the
+// requested file mode, stale sockets are replaced, and sockets in use or
+// other files are left alone.
+func TestListenRPCUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpcunix")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rpc.sock")
+	listener, err := listenRPCUnix(path, 0660)
+	if err != nil {
+		t.Fatalf("listenRPCUnix: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0660 {
+		t.Errorf("socket has mode %v, want 0660", perm)
+	}
+
+	// Connections report the socket as their remote address.
+	go func() {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if addr := conn.RemoteAddr().String(); addr != path {
+		t.Errorf("remote address is %q, want %q", addr, path)
+	}
+	conn.Close()
+
+	// The socket can't be taken over while it is in use.
+	if _, err := listenRPCUnix(path, 0600); err == nil {
+		t.Errorf("listenRPCUnix: listened on a socket in use")
+	}
+
+	// A socket left behind is replaced.
+	listener.(*rpcUnixListener).Listener.(*net.UnixListener).
+		SetUnlinkOnClose(false)
+	listener.Close()
+	listener, err = listenRPCUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("listenRPCUnix: stale socket not replaced: %v", err)
+	}
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket not removed when the listener closed")
+	}
+
+	// Other files are never removed.
+	filePath := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(filePath, nil, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := listenRPCUnix(filePath, 0600); err == nil {
+		t.Errorf("listenRPCUnix: replaced a regular file")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/utxocommit"
+	"github.com/bitgo/prova/wire"
+)
+
+// maxUtxoProofAttempts is the number of times the getutxoproof command reads
+// the utxo set and the utxo commitment index until both are as of the same
+// block, which only fails when blocks keep being connected in between or the
+// index is being caught up.
+const maxUtxoProofAttempts = 3
+
+// utxoCommitIndex returns the utxo commitment index, or an error when it is not
+// enabled.
+func (s *rpcServer) utxoCommitIndex() (*indexers.UtxoCommitIndex, error) {
+	utxoCommitIndex := s.server.indexes().utxoCommitIndex
+	if utxoCommitIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Utxo commitment index must be enabled (--utxocommitindex)",
+		}
+	}
+	return utxoCommitIndex, nil
+}
+
+// handleGetUtxoCommitment implements the getutxocommitment command.
+func handleGetUtxoCommitment(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetUtxoCommitmentCmd)
+	utxoCommitIndex, err := s.utxoCommitIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	height := s.chain.BestSnapshot().Height
+	if c.Height != nil {
+		height = *c.Height
+	}
+	commitment, err := utxoCommitIndex.Commitment(height)
+	if err != nil {
+		context := "Failed to load utxo commitment"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if commitment == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCOutOfRange,
+			Message: fmt.Sprintf("No utxo commitment for the block "+
+				"at height %d", height),
+		}
+	}
+
+	return &btcjson.GetUtxoCommitmentResult{
+		Height: commitment.Height,
+		Hash:   commitment.Hash.String(),
+		Root:   commitment.Root.String(),
+	}, nil
+}
+
+// handleGetUtxoProof implements the getutxoproof command.  The proof is as of
+// the best block, since the bins of the utxo set are only known as of it, and
+// proves whether the output is unspent either way.
+func handleGetUtxoProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetUtxoProofCmd)
+	utxoCommitIndex, err := s.utxoCommitIndex()
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+	outPoint := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+
+	// The utxo set and the index are read separately, so they are read
+	// again when a block is connected in between.
+	bin := utxocommit.Bin(txHash)
+	start, end := utxocommit.BinRange(bin)
+	var proof *utxocommit.Proof
+	for attempt := 0; attempt < maxUtxoProofAttempts; attempt++ {
+		utxos, hash, _, err := s.chain.FetchUtxoSetRange(start, end)
+		if err != nil {
+			context := "Failed to load utxo set"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		commitment, branch, err := utxoCommitIndex.Branch(bin)
+		if err != nil {
+			context := "Failed to load utxo commitment"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if commitment.Hash != *hash {
+			continue
+		}
+
+		proof = &utxocommit.Proof{
+			Height:    commitment.Height,
+			BlockHash: commitment.Hash,
+			Root:      commitment.Root,
+			Bin:       bin,
+			Outputs:   make([]utxocommit.Output, 0, len(utxos)),
+			Branch:    branch,
+		}
+		for _, utxo := range utxos {
+			proof.Outputs = append(proof.Outputs, utxocommit.Output{
+				OutPoint: utxo.OutPoint,
+				Amount:   utxo.Amount,
+				PkScript: utxo.PkScript,
+			})
+		}
+		break
+	}
+	if proof == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "The utxo commitment index is not caught up " +
+				"with the best block",
+		}
+	}
+	if err := proof.Verify(); err != nil {
+		context := "Utxo commitment does not match the utxo set"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	var buf bytes.Buffer
+	if err := proof.Serialize(&buf); err != nil {
+		context := "Failed to serialize proof"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result := &btcjson.GetUtxoProofResult{
+		Height:     proof.Height,
+		Hash:       proof.BlockHash.String(),
+		Root:       proof.Root.String(),
+		Bin:        proof.Bin,
+		BinOutputs: len(proof.Outputs),
+		Proof:      hex.EncodeToString(buf.Bytes()),
+	}
+	// The outpoint belongs to the bin of the proof.
+	output, _ := proof.Unspent(&outPoint)
+	if output != nil {
+		result.Unspent = true
+		result.Amount = output.Amount
+		result.ScriptPubKey = hex.EncodeToString(output.PkScript)
+	}
+	return result, nil
+}
+
+// handleVerifyUtxoProof implements the verifyutxoproof command.  Besides
+// checking the proof against its own root, it reports whether the root is the
+// commitment of this node for the block of the proof, which requires the utxo
+// commitment index.
+func handleVerifyUtxoProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyUtxoProofCmd)
+	serialized, err := hex.DecodeString(c.Proof)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Proof)
+	}
+	var proof utxocommit.Proof
+	if err := proof.Deserialize(bytes.NewReader(serialized)); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Proof decode failed: " + err.Error(),
+		}
+	}
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+	outPoint := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+
+	result := &btcjson.VerifyUtxoProofResult{
+		Height: proof.Height,
+		Hash:   proof.BlockHash.String(),
+		Root:   proof.Root.String(),
+	}
+	if err := proof.Verify(); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	output, err := proof.Unspent(&outPoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Valid = true
+	if output != nil {
+		result.Unspent = true
+		result.Amount = output.Amount
+		result.ScriptPubKey = hex.EncodeToString(output.PkScript)
+	}
+
+	if utxoCommitIndex := s.server.indexes().utxoCommitIndex; utxoCommitIndex != nil {
+		commitment, err := utxoCommitIndex.Commitment(proof.Height)
+		if err != nil {
+			context := "Failed to load utxo commitment"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.MainChain = commitment != nil &&
+			commitment.Hash == proof.BlockHash &&
+			commitment.Root == proof.Root
+	}
+	return result, nil
+}
@@ -0,0 +1,428 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build wallet
+
+package main
+
+import (
+	"encoding/hex"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
+	"github.com/bitgo/prova/wire"
+)
+
+// rpcWalletHandlers maps the RPC commands served by the built-in wallet to
+// their handlers.
+var rpcWalletHandlers = map[string]commandHandler{
+	"getbalance":       handleGetBalance,
+	"getnewaddress":    handleGetNewAddress,
+	"listtransactions": handleListTransactions,
+	"listunspent":      handleListUnspent,
+	"sendtoaddress":    handleSendToAddress,
+}
+
+// rpcWalletHelpDescs are the help descriptions of the RPC commands served by
+// the built-in wallet.
+var rpcWalletHelpDescs = map[string]string{
+	// GetBalanceCmd help.
+	"getbalance--synopsis": "Returns the balance of the spendable outputs of the built-in wallet.",
+	"getbalance-account":   "Unused -- the wallet has no accounts, so only the default account \"\" and all accounts \"*\" are accepted",
+	"getbalance-minconf":   "Minimum number of confirmations of the outputs to include",
+	"getbalance--result0":  "The balance in RMG",
+
+	// GetNewAddressCmd help.
+	"getnewaddress--synopsis": "Returns a new Prova address of the built-in wallet to receive funds with.\n" +
+		"The address is made of a holder key derived by the wallet and the keyIDs configured by --walletkeyid.",
+	"getnewaddress-account":  "Unused -- the wallet has no accounts, so only the default account \"\" is accepted",
+	"getnewaddress--result0": "The new address",
+
+	// ListTransactionsCmd help.
+	"listtransactions--synopsis": "Returns the most recent transactions paying to or spending from the built-in wallet, from the oldest to the most recent one.\n" +
+		"A transaction is described by an entry for every output it pays to the wallet and every output it pays to others from the wallet; change is left out.",
+	"listtransactions-account":          "Unused -- the wallet has no accounts, so only the default account \"\" and all accounts \"*\" are accepted",
+	"listtransactions-count":            "Maximum number of transactions to return",
+	"listtransactions-from":             "Number of most recent transactions to skip",
+	"listtransactions-includewatchonly": "Unused -- the wallet has no watch-only addresses",
+
+	// ListTransactionsResult help.
+	"listtransactionsresult-abandoned":          "Unused",
+	"listtransactionsresult-account":            "Always the default account \"\"",
+	"listtransactionsresult-address":            "The address the output pays to",
+	"listtransactionsresult-amount":             "The value of the output in RMG, which is negative for sends",
+	"listtransactionsresult-bip125-replaceable": "Unused",
+	"listtransactionsresult-blockhash":          "The hash of the block containing the transaction",
+	"listtransactionsresult-blockindex":         "Unused",
+	"listtransactionsresult-blocktime":          "The time of the block containing the transaction in seconds since 1 Jan 1970 GMT",
+	"listtransactionsresult-category":           "The kind of entry (send, receive, generate or immature)",
+	"listtransactionsresult-confirmations":      "The number of confirmations of the transaction",
+	"listtransactionsresult-fee":                "The negative fee in RMG paid by a send, when all of its inputs spend outputs of the wallet",
+	"listtransactionsresult-generated":          "Whether the transaction is a coinbase",
+	"listtransactionsresult-involveswatchonly":  "Unused",
+	"listtransactionsresult-time":               "The time of the block containing the transaction, or the time the wallet learned of it while unconfirmed, in seconds since 1 Jan 1970 GMT",
+	"listtransactionsresult-timereceived":       "The time the wallet learned of the transaction in seconds since 1 Jan 1970 GMT",
+	"listtransactionsresult-trusted":            "Whether the transaction is confirmed or spends from the wallet",
+	"listtransactionsresult-txid":               "The hash of the transaction",
+	"listtransactionsresult-vout":               "The index of the output",
+	"listtransactionsresult-walletconflicts":    "Unused",
+	"listtransactionsresult-comment":            "Unused",
+	"listtransactionsresult-otheraccount":       "Unused",
+
+	// ListUnspentCmd help.
+	"listunspent--synopsis": "Returns the unspent outputs of the built-in wallet ordered by their outpoints.",
+	"listunspent-minconf":   "Minimum number of confirmations of the outputs to include",
+	"listunspent-maxconf":   "Maximum number of confirmations of the outputs to include",
+	"listunspent-addresses": "Only include the outputs paying to these addresses",
+
+	// ListUnspentResult help.
+	"listunspentresult-txid":          "The hash of the transaction of the output",
+	"listunspentresult-vout":          "The index of the output",
+	"listunspentresult-address":       "The address the output pays to",
+	"listunspentresult-account":       "Always the default account \"\"",
+	"listunspentresult-scriptPubKey":  "The hex-encoded public key script of the output",
+	"listunspentresult-redeemScript":  "Unused",
+	"listunspentresult-amount":        "The value of the output in RMG",
+	"listunspentresult-confirmations": "The number of confirmations of the output",
+	"listunspentresult-spendable":     "Whether the output may be spent, which is not the case for immature coinbases",
+	"listunspentresult-keyids":        "The keyIDs of the ASP keys which may co-sign spends of the output",
+
+	// SendToAddressCmd help.
+	"sendtoaddress--synopsis": "Sends the amount to the address from the built-in wallet, paying the minimum relay fee, and returns the hash of the transaction.\n" +
+		"The outputs spent are signed with their holder keys and co-signed with an ASP key configured by --walletaspkey.",
+	"sendtoaddress-address":   "The address to send to",
+	"sendtoaddress-amount":    "The amount to send in RMG",
+	"sendtoaddress-comment":   "Unused",
+	"sendtoaddress-commentto": "Unused",
+	"sendtoaddress--result0":  "The hash of the transaction",
+}
+
+// rpcWalletResultTypes are the result types of the RPC commands served by the
+// built-in wallet.
+var rpcWalletResultTypes = map[string][]interface{}{
+	"getbalance":       {(*float64)(nil)},
+	"getnewaddress":    {(*string)(nil)},
+	"listtransactions": {(*[]btcjson.ListTransactionsResult)(nil)},
+	"listunspent":      {(*[]btcjson.ListUnspentResult)(nil)},
+	"sendtoaddress":    {(*string)(nil)},
+}
+
+// newWallet returns the built-in wallet configured by --walletkey, persisted
+// in the block database of the passed server.
+func newWallet(s *server) (*wallet.Wallet, error) {
+	chain := s.blockManager.chain
+	w, err := wallet.New(&wallet.Config{
+		DB:            s.db,
+		ChainParams:   s.chainParams,
+		HolderKey:     cfg.walletKey,
+		KeyIDs:        cfg.walletKeyIDs,
+		ASPKeys:       cfg.walletASPKeys,
+		KeyIDMap:      chain.KeyIDs,
+		BestSnapshot:  chain.BestSnapshot,
+		BlockByHeight: chain.BlockByHeight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !w.CanSign() {
+		wlltLog.Warnf("None of the --walletaspkey keys is assigned to "+
+			"the wallet keyIDs %v, so the wallet can not co-sign "+
+			"its spends", cfg.walletKeyIDs)
+	}
+	return w, nil
+}
+
+// rpcWallet returns the built-in wallet of the passed RPC server, or an error
+// suitable for use in replies when it is not loaded.
+func rpcWallet(s *rpcServer) (*wallet.Wallet, error) {
+	if s.server.wallet == nil {
+		return nil, ErrRPCNoWallet
+	}
+	return s.server.wallet, nil
+}
+
+// checkWalletAccount returns an error suitable for use in replies unless the
+// passed account is the default account, or all accounts when allowed, since
+// the built-in wallet has no accounts.
+func checkWalletAccount(account *string, allowAll bool) error {
+	if account == nil || *account == "" || (allowAll && *account == "*") {
+		return nil
+	}
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCWalletInvalidAccountName,
+		Message: "The wallet has no accounts: " + *account,
+	}
+}
+
+// handleGetBalance implements the getbalance command.
+func handleGetBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBalanceCmd)
+	w, err := rpcWallet(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWalletAccount(c.Account, true); err != nil {
+		return nil, err
+	}
+
+	minConf := 1
+	if c.MinConf != nil {
+		minConf = *c.MinConf
+	}
+	return w.Balance(int32(minConf)).ToRMG(), nil
+}
+
+// handleGetNewAddress implements the getnewaddress command.
+func handleGetNewAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetNewAddressCmd)
+	w, err := rpcWallet(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWalletAccount(c.Account, false); err != nil {
+		return nil, err
+	}
+
+	addr, err := w.NewAddress()
+	if err != nil {
+		context := "Failed to derive address"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// handleListTransactions implements the listtransactions command.
+func handleListTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ListTransactionsCmd)
+	w, err := rpcWallet(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWalletAccount(c.Account, true); err != nil {
+		return nil, err
+	}
+
+	count, from := 10, 0
+	if c.Count != nil {
+		count = *c.Count
+	}
+	if c.From != nil {
+		from = *c.From
+	}
+	if count < 0 || from < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Count and from may not be negative",
+		}
+	}
+
+	results := []btcjson.ListTransactionsResult{}
+	for _, d := range w.Transactions(count, from) {
+		results = append(results, listTransactionsEntries(s, d)...)
+	}
+	return results, nil
+}
+
+// listTransactionsEntries returns the listtransactions entries describing the
+// passed wallet transaction: a send for every output paying to others when
+// the transaction spends from the wallet, and a receive for every output
+// paying to the wallet which is not change.
+func listTransactionsEntries(s *rpcServer, d *wallet.TxDetails) []btcjson.ListTransactionsResult {
+	generated := blockchain.IsCoinBaseTx(d.Tx)
+	entry := btcjson.ListTransactionsResult{
+		Confirmations:   int64(d.Confirmations),
+		Generated:       generated,
+		Time:            d.Received.Unix(),
+		TimeReceived:    d.Received.Unix(),
+		Trusted:         d.Confirmations > 0 || d.Debit > 0,
+		TxID:            d.Hash.String(),
+		WalletConflicts: []string{},
+	}
+	if d.Confirmations > 0 {
+		entry.BlockHash = d.BlockHash.String()
+		entry.BlockTime = d.BlockTime.Unix()
+		entry.Time = d.BlockTime.Unix()
+	}
+
+	outputAddress := func(i uint32) string {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			d.Tx.TxOut[i].PkScript, s.server.chainParams)
+		if err != nil || len(addrs) == 0 {
+			return ""
+		}
+		return addrs[0].EncodeAddress()
+	}
+	ours := make(map[uint32]struct{}, len(d.Credits)+len(d.Change))
+	for _, i := range d.Credits {
+		ours[i] = struct{}{}
+	}
+	for _, i := range d.Change {
+		ours[i] = struct{}{}
+	}
+
+	var entries []btcjson.ListTransactionsResult
+	if d.Debit > 0 {
+		for i, txOut := range d.Tx.TxOut {
+			if _, ok := ours[uint32(i)]; ok {
+				continue
+			}
+			send := entry
+			send.Category = "send"
+			send.Address = outputAddress(uint32(i))
+			send.Amount = -provautil.Amount(txOut.Value).ToRMG()
+			send.Vout = uint32(i)
+			if d.Fee != nil {
+				fee := -d.Fee.ToRMG()
+				send.Fee = &fee
+			}
+			entries = append(entries, send)
+		}
+	}
+	for _, i := range d.Credits {
+		receive := entry
+		switch {
+		case !generated:
+			receive.Category = "receive"
+		case d.Confirmations >= int32(s.server.chainParams.CoinbaseMaturity):
+			receive.Category = "generate"
+		default:
+			receive.Category = "immature"
+		}
+		receive.Address = outputAddress(i)
+		receive.Amount = provautil.Amount(d.Tx.TxOut[i].Value).ToRMG()
+		receive.Vout = i
+		entries = append(entries, receive)
+	}
+	return entries
+}
+
+// handleListUnspent implements the listunspent command.
+func handleListUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ListUnspentCmd)
+	w, err := rpcWallet(s)
+	if err != nil {
+		return nil, err
+	}
+
+	minConf, maxConf := 1, 9999999
+	if c.MinConf != nil {
+		minConf = *c.MinConf
+	}
+	if c.MaxConf != nil {
+		maxConf = *c.MaxConf
+	}
+	var filter map[string]struct{}
+	if c.Addresses != nil {
+		filter = make(map[string]struct{}, len(*c.Addresses))
+		for _, encoded := range *c.Addresses {
+			addr, err := provautil.DecodeAddress(encoded,
+				s.server.chainParams)
+			if err != nil || !addr.IsForNet(s.server.chainParams) {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Invalid address: " + encoded,
+				}
+			}
+			filter[addr.EncodeAddress()] = struct{}{}
+		}
+	}
+
+	keyIDs := make([]uint32, 0, 2)
+	for _, keyID := range w.KeyIDs() {
+		keyIDs = append(keyIDs, uint32(keyID))
+	}
+	results := []btcjson.ListUnspentResult{}
+	for _, u := range w.Unspent(int32(minConf), int32(maxConf)) {
+		encoded := u.Address.EncodeAddress()
+		if _, ok := filter[encoded]; filter != nil && !ok {
+			continue
+		}
+		results = append(results, btcjson.ListUnspentResult{
+			TxID:          u.OutPoint.Hash.String(),
+			Vout:          u.OutPoint.Index,
+			Address:       encoded,
+			ScriptPubKey:  hex.EncodeToString(u.PkScript),
+			Amount:        u.Amount.ToRMG(),
+			Confirmations: int64(u.Confirmations),
+			Spendable:     u.Spendable,
+			KeyIDs:        keyIDs,
+		})
+	}
+	return results, nil
+}
+
+// handleSendToAddress implements the sendtoaddress command.
+func handleSendToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SendToAddressCmd)
+	w, err := rpcWallet(s)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
+	if err != nil || !addr.IsForNet(s.server.chainParams) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address,
+		}
+	}
+	amount, err := provautil.NewAmount(c.Amount)
+	if err != nil || amount <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The amount must be positive",
+		}
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		context := "Failed to create output script"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	feePerKB := s.server.txMemPool.Policy().MinRelayTxFee
+	msgTx, err := w.CreateTx([]*wire.TxOut{
+		wire.NewTxOut(int64(amount), pkScript),
+	}, feePerKB)
+	switch err {
+	case nil:
+	case wallet.ErrInsufficientFunds:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWalletInsufficientFunds,
+			Message: "Insufficient funds",
+		}
+	case wallet.ErrNoASPKey:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCWallet,
+			Message: err.Error(),
+		}
+	default:
+		context := "Failed to create transaction"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The wallet records the transaction once it is accepted into the
+	// memory pool, which also releases the outputs it spends.
+	tx := provautil.NewTx(msgTx)
+	if err := submitTransaction(s, tx); err != nil {
+		w.Release(msgTx)
+		return nil, err
+	}
+	return tx.Hash().String(), nil
+}
+
+func init() {
+	for method, handler := range rpcWalletHandlers {
+		rpcHandlersBeforeInit[method] = handler
+		delete(rpcAskWallet, method)
+	}
+	for key, desc := range rpcWalletHelpDescs {
+		helpDescsEnUS[key] = desc
+	}
+	for method, resultTypes := range rpcWalletResultTypes {
+		rpcResultTypes[method] = resultTypes
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !wallet
+
+package main
+
+import (
+	"errors"
+
+	"github.com/bitgo/prova/wallet"
+)
+
+// errWalletUnsupported is returned when the built-in wallet is requested from
+// a build without wallet support.
+var errWalletUnsupported = errors.New("wallet support is not available in " +
+	"this build (rebuild with -tags wallet)")
+
+// newWallet always returns errWalletUnsupported since this build lacks wallet
+// support.
+func newWallet(s *server) (*wallet.Wallet, error) {
+	return nil, errWalletUnsupported
+}
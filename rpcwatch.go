@@ -0,0 +1,337 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// watchProgressInterval is the minimum interval between the progress
+// notifications of a watch rescan.
+const watchProgressInterval = 10 * time.Second
+
+// watchRescan tracks the background rescan of a watch from its birthday
+// height to the tip of the main chain.
+type watchRescan struct {
+	wsc     *wsClient
+	id      uint64
+	addrs   []string
+	keyIDs  []btcec.KeyID
+	scripts [][]byte
+	lookups rescanKeys
+
+	// addrBlocks is the set of blocks the address index lists as involving
+	// one of the watched addresses up to height addrBlocksEnd.  It is nil
+	// when the address index is not used to select the rescanned blocks.
+	addrBlocks    map[chainhash.Hash]struct{}
+	addrBlocksEnd uint32
+
+	// lastHash and lastHeight identify the last block of the main chain
+	// the rescan went past.  lastHash is nil until the first block.
+	lastHash   *chainhash.Hash
+	lastHeight uint32
+}
+
+// handleWatch implements the watch command extension for websocket
+// connections.  The passed addresses and keyIDs are rescanned in the
+// background from the birthday height, after which the client keeps being
+// notified about them like for notifyreceived and notifyspent.  Blocks are
+// selected with the address or committed filter index when available so
+// onboarding an existing account does not require a rescan of every block.
+func handleWatch(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.WatchCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	addrs, err := checkAddressValidity(cmd.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	keyIDs := cmdKeyIDs(cmd.KeyIDs)
+	if len(addrs) == 0 && len(keyIDs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No addresses or keyIDs to watch",
+		}
+	}
+
+	var birthday uint32
+	if cmd.Birthday != nil {
+		birthday = *cmd.Birthday
+	}
+	best := wsc.server.chain.BestSnapshot()
+	if birthday > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Birthday height is beyond the best block height",
+		}
+	}
+
+	w := &watchRescan{
+		wsc:    wsc,
+		addrs:  addrs,
+		keyIDs: keyIDs,
+		lookups: rescanKeys{
+			fallbacks: make(map[string]struct{}, len(addrs)),
+			keyIDs:    make(map[btcec.KeyID]struct{}, len(keyIDs)),
+			unspent:   make(map[wire.OutPoint]struct{}),
+		},
+	}
+	for _, keyID := range keyIDs {
+		w.lookups.keyIDs[keyID] = struct{}{}
+	}
+	for _, addrStr := range addrs {
+		// The addresses were validated above.
+		addr, _ := provautil.DecodeAddress(addrStr, activeNetParams.Params)
+		w.lookups.fallbacks[addrStr] = struct{}{}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Watch address " + addrStr + ": " + err.Error(),
+			}
+		}
+		w.scripts = append(w.scripts, pkScript)
+	}
+
+	// Blocks involving the addresses are listed exactly by the address
+	// index, which does not index keyIDs though.
+	if addrIndex := wsc.server.server.addrIndex; addrIndex != nil &&
+		len(keyIDs) == 0 {
+
+		w.addrBlocks = make(map[chainhash.Hash]struct{})
+		w.addrBlocksEnd = best.Height
+		err := wsc.server.server.db.View(func(dbTx database.Tx) error {
+			for _, addrStr := range addrs {
+				addr, _ := provautil.DecodeAddress(addrStr,
+					activeNetParams.Params)
+				regions, err := addrIndex.BoundedTxRegionsForAddress(
+					dbTx, addr, birthday, best.Height)
+				if err != nil {
+					return err
+				}
+				for _, region := range regions {
+					w.addrBlocks[*region.Hash] = struct{}{}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			context := "Failed to load address index entries"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+
+	wsc.Lock()
+	wsc.nextWatchID++
+	w.id = wsc.nextWatchID
+	wsc.Unlock()
+
+	rpcsLog.Infof("Beginning watch rescan for %d addresses and %d keyIDs "+
+		"from height %d", len(addrs), len(keyIDs), birthday)
+	go w.rescan(birthday)
+
+	return &btcjson.WatchResult{WatchID: w.id}, nil
+}
+
+// filterItems returns the committed filter items a block relevant to the
+// watch matches.
+func (w *watchRescan) filterItems() [][]byte {
+	items := make([][]byte, 0, len(w.scripts)+len(w.keyIDs)+
+		len(w.lookups.unspent))
+	items = append(items, w.scripts...)
+	for _, keyID := range w.keyIDs {
+		items = append(items, builder.KeyIDItem(keyID))
+	}
+	for op := range w.lookups.unspent {
+		items = append(items, builder.OutPointItem(&op))
+	}
+	return items
+}
+
+// relevant returns whether the block with the passed hash and height may
+// contain transactions relevant to the watch, given its committed filter
+// which is nil when the block is not indexed.
+func (w *watchRescan) relevant(hash *chainhash.Hash, height uint32,
+	filter []byte) (bool, error) {
+
+	if w.addrBlocks != nil && height <= w.addrBlocksEnd {
+		_, ok := w.addrBlocks[*hash]
+		return ok, nil
+	}
+	if filter == nil {
+		return true, nil
+	}
+	f, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, filter)
+	if err != nil {
+		return false, err
+	}
+	return f.MatchAny(builder.DeriveKey(hash), w.filterItems())
+}
+
+// notify marshals and queues the passed notification for the client of the
+// watch.  It returns ErrClientQuit once the client disconnected.
+func (w *watchRescan) notify(ntfn interface{}) error {
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal watch notification: %v", err)
+		return nil
+	}
+	return w.wsc.QueueNotification(marshalledJSON)
+}
+
+// lastBlockTime returns the timestamp of the last block the rescan went past.
+func (w *watchRescan) lastBlockTime() int64 {
+	header, err := w.wsc.server.chain.FetchHeader(w.lastHash)
+	if err != nil {
+		return 0
+	}
+	return header.Timestamp.Unix()
+}
+
+// rescan rescans the main chain from the passed height for the watch, then
+// registers the client for notifications about the watch.  It must be run as
+// a goroutine.
+func (w *watchRescan) rescan(height uint32) {
+	err := w.rescanBlocks(height)
+	if err == ErrClientQuit {
+		rpcsLog.Debugf("Stopped watch rescan at height %v for "+
+			"disconnected client", w.lastHeight)
+		return
+	}
+
+	var errStr *string
+	if err != nil {
+		rpcsLog.Errorf("Watch rescan failed: %v", err)
+		s := err.Error()
+		errStr = &s
+	}
+	var hash string
+	var blockTime int64
+	if w.lastHash != nil {
+		hash = w.lastHash.String()
+		blockTime = w.lastBlockTime()
+	}
+
+	// The rescan is finished, so we don't care whether the client has
+	// disconnected at this point, so discard error.
+	_ = w.notify(btcjson.NewWatchRescanFinishedNtfn(w.id, hash,
+		int32(w.lastHeight), blockTime, errStr))
+	rpcsLog.Info("Finished watch rescan")
+}
+
+// rescanBlocks rescans the main chain from the passed height up to the tip,
+// at which point the client is registered for continuous notifications.
+func (w *watchRescan) rescanBlocks(height uint32) error {
+	wsc := w.wsc
+	chain := wsc.server.chain
+	cfIndex := wsc.server.server.cfIndex
+
+	ticker := time.NewTicker(watchProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		// Fetch the hashes of the next blocks along with the hash of the
+		// last block rescanned, which detects a reorganize away from it.
+		start := height
+		if w.lastHash != nil {
+			start--
+		}
+		hashList, err := chain.HeightRange(start, height+wire.MaxInvPerMsg)
+		if err != nil {
+			return err
+		}
+		if w.lastHash != nil && len(hashList) > 0 {
+			if hashList[0] != *w.lastHash {
+				return &ErrRescanReorg
+			}
+			hashList = hashList[1:]
+		}
+
+		if len(hashList) == 0 {
+			// Register the client for continuous notifications
+			// while holding exclusive access of the block manager,
+			// unless blocks were attached since the fetch above.
+			blockManager := wsc.server.server.blockManager
+			pauseGuard := blockManager.Pause()
+			best := blockManager.chain.BestSnapshot()
+			done := w.lastHash == nil || *w.lastHash == *best.Hash
+			if done {
+				n := wsc.server.ntfnMgr
+				n.RegisterSpentRequests(wsc, w.lookups.unspentSlice())
+				n.RegisterTxOutAddressRequests(wsc, w.addrs)
+				n.RegisterTxOutKeyIDRequests(wsc, w.keyIDs)
+				n.RegisterSpentKeyIDRequests(wsc, w.keyIDs)
+			}
+			close(pauseGuard)
+			if done {
+				return nil
+			}
+			continue
+		}
+
+		var filters [][]byte
+		if cfIndex != nil {
+			hashes := make([]*chainhash.Hash, len(hashList))
+			for i := range hashList {
+				hashes[i] = &hashList[i]
+			}
+			filters, err = cfIndex.FiltersByBlockHashes(hashes)
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range hashList {
+			select {
+			case <-wsc.quit:
+				return ErrClientQuit
+			default:
+			}
+
+			var filter []byte
+			if filters != nil {
+				filter = filters[i]
+			}
+			ok, err := w.relevant(&hashList[i], height, filter)
+			if err != nil {
+				return err
+			}
+			if ok {
+				blk, err := chain.BlockByHash(&hashList[i])
+				if err != nil {
+					return err
+				}
+				rescanBlock(wsc, &w.lookups, blk)
+			}
+			w.lastHash = &hashList[i]
+			w.lastHeight = height
+			height++
+
+			// Periodically notify the client of the progress.
+			select {
+			case <-ticker.C:
+				err := w.notify(btcjson.NewWatchRescanProgressNtfn(
+					w.id, w.lastHash.String(),
+					int32(w.lastHeight), w.lastBlockTime()))
+				if err == ErrClientQuit {
+					return err
+				}
+			default:
+			}
+		}
+	}
+}
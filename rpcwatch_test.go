@@ -0,0 +1,140 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestWatchRescanRelevant ensures watch rescans select the blocks paying to
+// the watched addresses or keyIDs, or spending the outputs found so far,
+// using the committed filters or the address index.
+func TestWatchRescanRelevant(t *testing.T) {
+	params := activeNetParams.Params
+	addr, err := provautil.NewAddressProva(bytes.Repeat([]byte{0x42}, 20),
+		[]btcec.KeyID{1, 2}, params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	// The first block pays to the address, and the second one spends the
+	// output.
+	fundingTx := wire.NewMsgTx(1)
+	fundingTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, nil))
+	fundingTx.AddTxOut(wire.NewTxOut(50, pkScript))
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{
+		Index: wire.MaxPrevOutIndex}, []byte{0x01}))
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_TRUE}))
+	spendTx := wire.NewMsgTx(1)
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	spendTx.AddTxIn(wire.NewTxIn(&outPoint, nil))
+	spendTx.AddTxOut(wire.NewTxOut(50, []byte{txscript.OP_TRUE}))
+	blocks := []*wire.MsgBlock{
+		{Transactions: []*wire.MsgTx{fundingTx}},
+		{Transactions: []*wire.MsgTx{coinbase, spendTx}},
+	}
+	hashes := make([]chainhash.Hash, len(blocks))
+	filters := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		// Chain the blocks so their hashes differ.
+		block.Header.Height = uint32(i)
+		if i > 0 {
+			block.Header.PrevBlock = hashes[i-1]
+		}
+		hashes[i] = block.BlockHash()
+		filter, err := builder.BuildBasicFilter(block)
+		if err != nil {
+			t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+		}
+		if filters[i], err = filter.NBytes(); err != nil {
+			t.Fatalf("NBytes: unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		scripts [][]byte
+		keyIDs  []btcec.KeyID
+		unspent []wire.OutPoint
+		want    []bool
+	}{
+		{"address", [][]byte{pkScript}, nil, nil, []bool{true, false}},
+		{"co-signing keyID", nil, []btcec.KeyID{2}, nil,
+			[]bool{true, false}},
+		{"unrelated keyID", nil, []btcec.KeyID{7}, nil,
+			[]bool{false, false}},
+		{"found output", [][]byte{pkScript}, nil,
+			[]wire.OutPoint{outPoint}, []bool{true, true}},
+	}
+	for _, test := range tests {
+		w := &watchRescan{
+			scripts: test.scripts,
+			keyIDs:  test.keyIDs,
+			lookups: rescanKeys{unspent: make(map[wire.OutPoint]struct{})},
+		}
+		for _, op := range test.unspent {
+			w.lookups.unspent[op] = struct{}{}
+		}
+		for i := range blocks {
+			got, err := w.relevant(&hashes[i], uint32(i), filters[i])
+			if err != nil {
+				t.Fatalf("%s: relevant: unexpected error: %v",
+					test.name, err)
+			}
+			if got != test.want[i] {
+				t.Errorf("%s: block %d: got relevant %v, want %v",
+					test.name, i, got, test.want[i])
+			}
+		}
+	}
+
+	// Blocks up to the end of the address index entries are relevant only
+	// when listed, and the ones which are not indexed always are.
+	w := &watchRescan{
+		addrBlocks:    map[chainhash.Hash]struct{}{hashes[0]: {}},
+		addrBlocksEnd: 0,
+	}
+	for i, want := range []bool{true, true} {
+		got, err := w.relevant(&hashes[i], uint32(i), nil)
+		if err != nil || got != want {
+			t.Errorf("address index: block %d: got relevant %v (%v), "+
+				"want %v", i, got, err, want)
+		}
+	}
+	w.addrBlocksEnd = 1
+	if got, _ := w.relevant(&hashes[1], 1, nil); got {
+		t.Errorf("address index: unlisted block is relevant")
+	}
+
+	// Outputs match the rescan lookups by address or co-signing keyID.
+	lookups := rescanKeys{
+		fallbacks: map[string]struct{}{canonicalAddress(addr): {}},
+	}
+	if !lookups.matchesPkScript(pkScript, params) {
+		t.Errorf("matchesPkScript: output to the address does not match")
+	}
+	lookups = rescanKeys{keyIDs: map[btcec.KeyID]struct{}{1: {}}}
+	if !lookups.matchesPkScript(pkScript, params) {
+		t.Errorf("matchesPkScript: output co-signed by the keyID does " +
+			"not match")
+	}
+	if lookups.matchesPkScript([]byte{txscript.OP_TRUE}, params) {
+		t.Errorf("matchesPkScript: unrelated output matches")
+	}
+}
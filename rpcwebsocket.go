@@ -9,8 +9,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -22,9 +20,12 @@ import (
 	"time"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/eventlog"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
@@ -66,16 +67,21 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifybroadcasts":          handleNotifyBroadcasts,
+	"notifyevents":              handleNotifyEvents,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifybroadcasts":      handleStopNotifyBroadcasts,
+	"stopnotifyevents":          handleStopNotifyEvents,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
 	"rescan":                    handleRescan,
 	"rescanblocks":              handleRescanBlocks,
+	"watch":                     handleWatch,
 }
 
 // WebsocketHandler handles a new websocket client by creating a new wsClient,
@@ -84,7 +90,7 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	user *rpcUser) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -103,7 +109,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, user)
 	if err != nil {
 		rpcsLog.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -246,6 +252,32 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *provautil.Tx, isNew bool) {
 	}
 }
 
+// NotifyEvent passes an event recorded in the event log to the notification
+// manager for event notification processing.
+func (m *wsNotificationManager) NotifyEvent(e *eventlog.Event) {
+	// As NotifyEvent will be called by the event log and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationEventLogged)(e):
+	case <-m.quit:
+	}
+}
+
+// NotifyBroadcastFinished passes the final status of a broadcast campaign to
+// the notification manager for broadcast notification processing.
+func (m *wsNotificationManager) NotifyBroadcastFinished(status *btcjson.BroadcastStatusResult) {
+	// As NotifyBroadcastFinished will be called by the block manager and
+	// the RPC server may no longer be running, use a select statement to
+	// unblock enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationBroadcastFinished)(status):
+	case <-m.quit:
+	}
+}
+
 // Notification types
 type notificationBlockConnected provautil.Block
 type notificationBlockDisconnected provautil.Block
@@ -253,12 +285,18 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *provautil.Tx
 }
+type notificationEventLogged eventlog.Event
+type notificationBroadcastFinished btcjson.BroadcastStatusResult
 
 // Notification control requests
 type notificationRegisterClient wsClient
 type notificationUnregisterClient wsClient
 type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
+type notificationRegisterEvents wsClient
+type notificationUnregisterEvents wsClient
+type notificationRegisterBroadcasts wsClient
+type notificationUnregisterBroadcasts wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
 type notificationRegisterSpent struct {
@@ -277,6 +315,16 @@ type notificationUnregisterAddr struct {
 	wsc  *wsClient
 	addr string
 }
+type notificationRegisterKeyIDs struct {
+	wsc    *wsClient
+	keyIDs []btcec.KeyID
+	spent  bool
+}
+type notificationUnregisterKeyID struct {
+	wsc   *wsClient
+	keyID btcec.KeyID
+	spent bool
+}
 
 // notificationHandler reads notifications and control messages from the queue
 // handler and processes one at a time.
@@ -292,9 +340,13 @@ func (m *wsNotificationManager) notificationHandler() {
 	// Where possible, the quit channel is used as the unique id for a client
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
+	eventNotifications := make(map[chan struct{}]*wsClient)
+	broadcastNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
+	watchedKeyIDs := make(map[btcec.KeyID]map[chan struct{}]*wsClient)
+	watchedSpentKeyIDs := make(map[btcec.KeyID]map[chan struct{}]*wsClient)
 
 out:
 	for {
@@ -310,10 +362,27 @@ out:
 
 				// Skip iterating through all txs if no
 				// tx notification requests exist.
-				if len(watchedOutPoints) != 0 || len(watchedAddrs) != 0 {
+				if len(watchedOutPoints) != 0 || len(watchedAddrs) != 0 ||
+					len(watchedKeyIDs) != 0 || len(watchedSpentKeyIDs) != 0 {
+
+					// The outputs spent by the block are only
+					// needed to match spent keyID requests.
+					var prevOuts map[wire.OutPoint]wire.TxOut
+					if len(watchedSpentKeyIDs) != 0 {
+						var err error
+						prevOuts, err = fetchBlockSpentTxOuts(m.server,
+							block.Hash())
+						if err != nil {
+							rpcsLog.Warnf("Failed to load the spent "+
+								"outputs of block %v: %v",
+								block.Hash(), err)
+						}
+					}
 					for _, tx := range block.Transactions() {
 						m.notifyForTx(watchedOutPoints,
-							watchedAddrs, tx, block)
+							watchedAddrs, watchedKeyIDs,
+							watchedSpentKeyIDs, tx, block,
+							prevOuts)
 					}
 				}
 
@@ -338,9 +407,34 @@ out:
 				if n.isNew && len(txNotifications) != 0 {
 					m.notifyForNewTx(txNotifications, n.tx)
 				}
-				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
+				var prevOuts map[wire.OutPoint]wire.TxOut
+				if len(watchedSpentKeyIDs) != 0 {
+					var err error
+					prevOuts, err = fetchPrevOuts(m.server,
+						n.tx.MsgTx(), nil)
+					if err != nil {
+						rpcsLog.Warnf("Failed to load the outputs "+
+							"spent by transaction %v: %v",
+							n.tx.Hash(), err)
+					}
+				}
+				m.notifyForTx(watchedOutPoints, watchedAddrs,
+					watchedKeyIDs, watchedSpentKeyIDs, n.tx, nil,
+					prevOuts)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationEventLogged:
+				if len(eventNotifications) != 0 {
+					m.notifyEventLogged(eventNotifications,
+						(*eventlog.Event)(n))
+				}
+
+			case *notificationBroadcastFinished:
+				if len(broadcastNotifications) != 0 {
+					m.notifyBroadcastFinished(broadcastNotifications,
+						(*btcjson.BroadcastStatusResult)(n))
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -349,6 +443,22 @@ out:
 				wsc := (*wsClient)(n)
 				delete(blockNotifications, wsc.quit)
 
+			case *notificationRegisterEvents:
+				wsc := (*wsClient)(n)
+				eventNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterEvents:
+				wsc := (*wsClient)(n)
+				delete(eventNotifications, wsc.quit)
+
+			case *notificationRegisterBroadcasts:
+				wsc := (*wsClient)(n)
+				broadcastNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterBroadcasts:
+				wsc := (*wsClient)(n)
+				delete(broadcastNotifications, wsc.quit)
+
 			case *notificationRegisterClient:
 				wsc := (*wsClient)(n)
 				clients[wsc.quit] = wsc
@@ -358,6 +468,8 @@ out:
 				// Remove any requests made by the client as well as
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
+				delete(eventNotifications, wsc.quit)
+				delete(broadcastNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
@@ -366,6 +478,14 @@ out:
 				for addr := range wsc.addrRequests {
 					m.removeAddrRequest(watchedAddrs, wsc, addr)
 				}
+				for keyID := range wsc.keyIDRequests {
+					m.removeKeyIDRequest(watchedKeyIDs,
+						wsc.keyIDRequests, wsc, keyID)
+				}
+				for keyID := range wsc.spentKeyIDRequests {
+					m.removeKeyIDRequest(watchedSpentKeyIDs,
+						wsc.spentKeyIDRequests, wsc, keyID)
+				}
 				delete(clients, wsc.quit)
 
 			case *notificationRegisterSpent:
@@ -380,6 +500,24 @@ out:
 			case *notificationUnregisterAddr:
 				m.removeAddrRequest(watchedAddrs, n.wsc, n.addr)
 
+			case *notificationRegisterKeyIDs:
+				if n.spent {
+					m.addKeyIDRequests(watchedSpentKeyIDs,
+						n.wsc.spentKeyIDRequests, n.wsc, n.keyIDs)
+				} else {
+					m.addKeyIDRequests(watchedKeyIDs,
+						n.wsc.keyIDRequests, n.wsc, n.keyIDs)
+				}
+
+			case *notificationUnregisterKeyID:
+				if n.spent {
+					m.removeKeyIDRequest(watchedSpentKeyIDs,
+						n.wsc.spentKeyIDRequests, n.wsc, n.keyID)
+				} else {
+					m.removeKeyIDRequest(watchedKeyIDs,
+						n.wsc.keyIDRequests, n.wsc, n.keyID)
+				}
+
 			case *notificationRegisterNewMempoolTxs:
 				wsc := (*wsClient)(n)
 				txNotifications[wsc.quit] = wsc
@@ -426,13 +564,18 @@ type wsClientFilter struct {
 
 	// Outpoints of unspent outputs.
 	unspent map[wire.OutPoint]struct{}
+
+	// KeyIDs co-signing watched outputs.
+	keyIDs map[btcec.KeyID]struct{}
 }
 
 // newWSClientFilter creates a new, empty wsClientFilter struct to be used
 // for a websocket client.
 //
 // NOTE: This extension was ported from github.com/decred/dcrd
-func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint) *wsClientFilter {
+func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint,
+	keyIDs []btcec.KeyID) *wsClientFilter {
+
 	filter := &wsClientFilter{
 		pubKeyHashes:        map[[ripemd160.Size]byte]struct{}{},
 		scriptHashes:        map[[ripemd160.Size]byte]struct{}{},
@@ -440,6 +583,7 @@ func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint) *ws
 		uncompressedPubKeys: map[[65]byte]struct{}{},
 		otherAddresses:      map[string]struct{}{},
 		unspent:             make(map[wire.OutPoint]struct{}, len(unspentOutPoints)),
+		keyIDs:              make(map[btcec.KeyID]struct{}, len(keyIDs)),
 	}
 
 	for _, s := range addresses {
@@ -448,6 +592,9 @@ func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint) *ws
 	for i := range unspentOutPoints {
 		filter.addUnspentOutPoint(&unspentOutPoints[i])
 	}
+	for _, keyID := range keyIDs {
+		filter.addKeyID(keyID)
+	}
 
 	return filter
 }
@@ -457,7 +604,7 @@ func newWSClientFilter(addresses []string, unspentOutPoints []wire.OutPoint) *ws
 //
 // NOTE: This extension was ported from github.com/decred/dcrd
 func (f *wsClientFilter) addAddress(a provautil.Address) {
-	f.otherAddresses[a.EncodeAddress()] = struct{}{}
+	f.otherAddresses[canonicalAddress(a)] = struct{}{}
 }
 
 // addAddressStr parses an address from a string and then adds it to the
@@ -480,7 +627,7 @@ func (f *wsClientFilter) addAddressStr(s string) {
 //
 // NOTE: This extension was ported from github.com/decred/dcrd
 func (f *wsClientFilter) existsAddress(a provautil.Address) bool {
-	_, ok := f.otherAddresses[a.EncodeAddress()]
+	_, ok := f.otherAddresses[canonicalAddress(a)]
 	return ok
 }
 
@@ -489,7 +636,7 @@ func (f *wsClientFilter) existsAddress(a provautil.Address) bool {
 //
 // NOTE: This extension was ported from github.com/decred/dcrd
 func (f *wsClientFilter) removeAddress(a provautil.Address) {
-	delete(f.otherAddresses, a.EncodeAddress())
+	delete(f.otherAddresses, canonicalAddress(a))
 }
 
 // removeAddressStr parses an address from a string and then removes it from the
@@ -529,6 +676,41 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 	delete(f.unspent, *op)
 }
 
+// addKeyID adds a keyID to the wsClientFilter.  Outputs co-signed by the
+// keyID are matched regardless of the holder address they pay to.
+func (f *wsClientFilter) addKeyID(keyID btcec.KeyID) {
+	f.keyIDs[keyID] = struct{}{}
+}
+
+// existsKeyID returns true if any of the passed keyIDs has been added to the
+// wsClientFilter.
+func (f *wsClientFilter) existsKeyID(keyIDs []btcec.KeyID) bool {
+	for _, keyID := range keyIDs {
+		if _, ok := f.keyIDs[keyID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptKeyIDs returns the keyIDs co-signing the passed Prova output script.
+// Nil is returned for any other kind of script.
+func scriptKeyIDs(pkScript []byte) []btcec.KeyID {
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return nil
+	}
+	switch txscript.TypeOfScript(pops) {
+	case txscript.ProvaTy, txscript.ProvaTimeLockTy, txscript.GeneralProvaTy:
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			return nil
+		}
+		return keyIDs
+	}
+	return nil
+}
+
 // NumClients returns the number of clients actively being served.
 func (m *wsNotificationManager) NumClients() (n int) {
 	select {
@@ -550,6 +732,64 @@ func (m *wsNotificationManager) UnregisterBlockUpdates(wsc *wsClient) {
 	m.queueNotification <- (*notificationUnregisterBlocks)(wsc)
 }
 
+// RegisterEventUpdates requests event log notifications to the passed
+// websocket client.
+func (m *wsNotificationManager) RegisterEventUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterEvents)(wsc)
+}
+
+// UnregisterEventUpdates removes event log notifications for the passed
+// websocket client.
+func (m *wsNotificationManager) UnregisterEventUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterEvents)(wsc)
+}
+
+// notifyEventLogged notifies websocket clients that have registered for event
+// log updates when an event has been recorded in the event log.
+func (*wsNotificationManager) notifyEventLogged(clients map[chan struct{}]*wsClient,
+	e *eventlog.Event) {
+
+	ntfn := btcjson.NewEventLoggedNtfn(eventLogEntryResult(e))
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal event logged notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// RegisterBroadcastUpdates requests broadcast campaign notifications to the
+// passed websocket client.
+func (m *wsNotificationManager) RegisterBroadcastUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterBroadcasts)(wsc)
+}
+
+// UnregisterBroadcastUpdates removes broadcast campaign notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterBroadcastUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterBroadcasts)(wsc)
+}
+
+// notifyBroadcastFinished notifies websocket clients that have registered for
+// broadcast campaign updates when a campaign reached its final status.
+func (*wsNotificationManager) notifyBroadcastFinished(clients map[chan struct{}]*wsClient,
+	status *btcjson.BroadcastStatusResult) {
+
+	ntfn := btcjson.NewBroadcastFinishedNtfn(*status)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal broadcast finished "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // subscribedClients returns the set of all websocket client quit channels that
 // are registered to receive notifications regarding tx, either due to tx
 // spending a watched output or outputting to a watched address.  Matching
@@ -587,6 +827,7 @@ func (m *wsNotificationManager) subscribedClients(tx *provautil.Tx,
 			// nonstandard or non-address outputs.
 			continue
 		}
+		keyIDs := scriptKeyIDs(output.PkScript)
 		for quitChan, wsc := range clients {
 			wsc.Lock()
 			filter := wsc.filterData
@@ -595,15 +836,19 @@ func (m *wsNotificationManager) subscribedClients(tx *provautil.Tx,
 				continue
 			}
 			filter.mu.Lock()
+			matched := filter.existsKeyID(keyIDs)
 			for _, a := range addrs {
 				if filter.existsAddress(a) {
-					subscribed[quitChan] = struct{}{}
-					op := wire.OutPoint{
-						Hash:  *tx.Hash(),
-						Index: uint32(i),
-					}
-					filter.addUnspentOutPoint(&op)
+					matched = true
+				}
+			}
+			if matched {
+				subscribed[quitChan] = struct{}{}
+				op := wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(i),
 				}
+				filter.addUnspentOutPoint(&op)
 			}
 			filter.mu.Unlock()
 		}
@@ -895,31 +1140,43 @@ func newRedeemingTxNotification(txHex string, index int, block *provautil.Block)
 
 // notifyForTxOuts examines each transaction output, notifying interested
 // websocket clients of the transaction if an output spends to a watched
-// address.  A spent notification request is automatically registered for
-// the client for each matching output.
+// address or is co-signed by a watched keyID.  A spent notification request
+// is automatically registered for the client for each matching output.
 func (m *wsNotificationManager) notifyForTxOuts(ops map[wire.OutPoint]map[chan struct{}]*wsClient,
-	addrs map[string]map[chan struct{}]*wsClient, tx *provautil.Tx, block *provautil.Block) {
+	addrs map[string]map[chan struct{}]*wsClient,
+	keyIDs map[btcec.KeyID]map[chan struct{}]*wsClient, tx *provautil.Tx,
+	block *provautil.Block) {
 
-	// Nothing to do if nobody is listening for address notifications.
-	if len(addrs) == 0 {
+	// Nothing to do if nobody is listening for address or keyID
+	// notifications.
+	if len(addrs) == 0 && len(keyIDs) == 0 {
 		return
 	}
 
 	txHex := ""
 	wscNotified := make(map[chan struct{}]struct{})
 	for i, txOut := range tx.MsgTx().TxOut {
+		// Collect the sets of clients watching any of the addresses or
+		// keyIDs of the output.
+		var cmaps []map[chan struct{}]*wsClient
 		_, txAddrs, _, err := txscript.ExtractPkScriptAddrs(
 			txOut.PkScript, m.server.server.chainParams)
-		if err != nil {
-			continue
+		if err == nil {
+			for _, txAddr := range txAddrs {
+				if cmap, ok := addrs[txAddr.EncodeAddress()]; ok {
+					cmaps = append(cmaps, cmap)
+				}
+			}
 		}
-
-		for _, txAddr := range txAddrs {
-			cmap, ok := addrs[txAddr.EncodeAddress()]
-			if !ok {
-				continue
+		if len(keyIDs) != 0 {
+			for _, keyID := range scriptKeyIDs(txOut.PkScript) {
+				if cmap, ok := keyIDs[keyID]; ok {
+					cmaps = append(cmaps, cmap)
+				}
 			}
+		}
 
+		for _, cmap := range cmaps {
 			if txHex == "" {
 				txHex = txHexString(tx.MsgTx())
 			}
@@ -969,53 +1226,78 @@ func (m *wsNotificationManager) notifyRelevantTxAccepted(tx *provautil.Tx,
 }
 
 // notifyForTx examines the inputs and outputs of the passed transaction,
-// notifying websocket clients of outputs spending to a watched address
-// and inputs spending a watched outpoint.
+// notifying websocket clients of outputs spending to a watched address or
+// keyID and inputs spending a watched outpoint or an output co-signed by a
+// watched keyID.  The outputs spent by the transaction, keyed by outpoint, are
+// only required when spent keyID requests exist and may be nil otherwise.
 func (m *wsNotificationManager) notifyForTx(ops map[wire.OutPoint]map[chan struct{}]*wsClient,
-	addrs map[string]map[chan struct{}]*wsClient, tx *provautil.Tx, block *provautil.Block) {
+	addrs map[string]map[chan struct{}]*wsClient,
+	keyIDs, spentKeyIDs map[btcec.KeyID]map[chan struct{}]*wsClient,
+	tx *provautil.Tx, block *provautil.Block,
+	prevOuts map[wire.OutPoint]wire.TxOut) {
 
-	if len(ops) != 0 {
-		m.notifyForTxIns(ops, tx, block)
+	if len(ops) != 0 || len(spentKeyIDs) != 0 {
+		m.notifyForTxIns(ops, spentKeyIDs, tx, block, prevOuts)
 	}
-	if len(addrs) != 0 {
-		m.notifyForTxOuts(ops, addrs, tx, block)
+	if len(addrs) != 0 || len(keyIDs) != 0 {
+		m.notifyForTxOuts(ops, addrs, keyIDs, tx, block)
 	}
 }
 
 // notifyForTxIns examines the inputs of the passed transaction and sends
 // interested websocket clients a redeemingtx notification if any inputs
-// spend a watched output.  If block is non-nil, any matching spent
-// requests are removed.
+// spend a watched output or an output co-signed by a watched keyID.  If block
+// is non-nil, any matching spent requests are removed.  Spent keyID requests
+// remain registered until the client removes them.
 func (m *wsNotificationManager) notifyForTxIns(ops map[wire.OutPoint]map[chan struct{}]*wsClient,
-	tx *provautil.Tx, block *provautil.Block) {
+	spentKeyIDs map[btcec.KeyID]map[chan struct{}]*wsClient, tx *provautil.Tx,
+	block *provautil.Block, prevOuts map[wire.OutPoint]wire.TxOut) {
 
-	// Nothing to do if nobody is watching outpoints.
-	if len(ops) == 0 {
+	// Nothing to do if nobody is watching outpoints or keyIDs.
+	if len(ops) == 0 && len(spentKeyIDs) == 0 {
 		return
 	}
 
-	txHex := ""
+	var marshalledJSON []byte
 	wscNotified := make(map[chan struct{}]struct{})
+	notify := func(wscQuit chan struct{}, wsc *wsClient) {
+		if _, ok := wscNotified[wscQuit]; ok {
+			return
+		}
+		if marshalledJSON == nil {
+			var err error
+			marshalledJSON, err = newRedeemingTxNotification(
+				txHexString(tx.MsgTx()), tx.Index(), block)
+			if err != nil {
+				rpcsLog.Warnf("Failed to marshal redeemingtx "+
+					"notification: %v", err)
+				return
+			}
+		}
+		wscNotified[wscQuit] = struct{}{}
+		wsc.QueueNotification(marshalledJSON)
+	}
 	for _, txIn := range tx.MsgTx().TxIn {
 		prevOut := &txIn.PreviousOutPoint
 		if cmap, ok := ops[*prevOut]; ok {
-			if txHex == "" {
-				txHex = txHexString(tx.MsgTx())
-			}
-			marshalledJSON, err := newRedeemingTxNotification(txHex, tx.Index(), block)
-			if err != nil {
-				rpcsLog.Warnf("Failed to marshal redeemingtx notification: %v", err)
-				continue
-			}
 			for wscQuit, wsc := range cmap {
 				if block != nil {
 					m.removeSpentRequest(ops, wsc, prevOut)
 				}
+				notify(wscQuit, wsc)
+			}
+		}
 
-				if _, ok := wscNotified[wscQuit]; !ok {
-					wscNotified[wscQuit] = struct{}{}
-					wsc.QueueNotification(marshalledJSON)
-				}
+		if len(spentKeyIDs) == 0 {
+			continue
+		}
+		txOut, ok := prevOuts[*prevOut]
+		if !ok {
+			continue
+		}
+		for _, keyID := range scriptKeyIDs(txOut.PkScript) {
+			for wscQuit, wsc := range spentKeyIDs[keyID] {
+				notify(wscQuit, wsc)
 			}
 		}
 	}
@@ -1086,6 +1368,90 @@ func (*wsNotificationManager) removeAddrRequest(addrs map[string]map[chan struct
 	}
 }
 
+// RegisterTxOutKeyIDRequests requests notifications to the passed websocket
+// client when a transaction output is co-signed by any of the passed keyIDs.
+func (m *wsNotificationManager) RegisterTxOutKeyIDRequests(wsc *wsClient, keyIDs []btcec.KeyID) {
+	m.queueNotification <- &notificationRegisterKeyIDs{
+		wsc:    wsc,
+		keyIDs: keyIDs,
+	}
+}
+
+// UnregisterTxOutKeyIDRequest removes a request from the passed websocket
+// client to be notified when a transaction output is co-signed by the passed
+// keyID.
+func (m *wsNotificationManager) UnregisterTxOutKeyIDRequest(wsc *wsClient, keyID btcec.KeyID) {
+	m.queueNotification <- &notificationUnregisterKeyID{
+		wsc:   wsc,
+		keyID: keyID,
+	}
+}
+
+// RegisterSpentKeyIDRequests requests notifications to the passed websocket
+// client when a transaction spends an output co-signed by any of the passed
+// keyIDs.  Unlike outpoint requests, the requests are kept after a
+// notification has been sent.
+func (m *wsNotificationManager) RegisterSpentKeyIDRequests(wsc *wsClient, keyIDs []btcec.KeyID) {
+	m.queueNotification <- &notificationRegisterKeyIDs{
+		wsc:    wsc,
+		keyIDs: keyIDs,
+		spent:  true,
+	}
+}
+
+// UnregisterSpentKeyIDRequest removes a request from the passed websocket
+// client to be notified when a transaction spends an output co-signed by the
+// passed keyID.
+func (m *wsNotificationManager) UnregisterSpentKeyIDRequest(wsc *wsClient, keyID btcec.KeyID) {
+	m.queueNotification <- &notificationUnregisterKeyID{
+		wsc:   wsc,
+		keyID: keyID,
+		spent: true,
+	}
+}
+
+// addKeyIDRequests adds the websocket client wsc to the keyID to client set
+// keyIDMap so wsc will be notified for any mempool or block transactions
+// matching any of the keyIDs.  The requests are tracked in the passed client
+// request set as well.
+func (*wsNotificationManager) addKeyIDRequests(keyIDMap map[btcec.KeyID]map[chan struct{}]*wsClient,
+	requests map[btcec.KeyID]struct{}, wsc *wsClient, keyIDs []btcec.KeyID) {
+
+	for _, keyID := range keyIDs {
+		requests[keyID] = struct{}{}
+
+		cmap, ok := keyIDMap[keyID]
+		if !ok {
+			cmap = make(map[chan struct{}]*wsClient)
+			keyIDMap[keyID] = cmap
+		}
+		cmap[wsc.quit] = wsc
+	}
+}
+
+// removeKeyIDRequest removes the websocket client wsc from the keyID to
+// client set keyIDMap and the passed client request set so it will no longer
+// receive notifications for the keyID.
+func (*wsNotificationManager) removeKeyIDRequest(keyIDMap map[btcec.KeyID]map[chan struct{}]*wsClient,
+	requests map[btcec.KeyID]struct{}, wsc *wsClient, keyID btcec.KeyID) {
+
+	delete(requests, keyID)
+
+	cmap, ok := keyIDMap[keyID]
+	if !ok {
+		rpcsLog.Warnf("Attempt to remove nonexistent keyID request "+
+			"<%d> for websocket client %s", keyID, wsc.addr)
+		return
+	}
+	delete(cmap, wsc.quit)
+
+	// Remove the map entry altogether if there are no more clients
+	// interested in it.
+	if len(cmap) == 0 {
+		delete(keyIDMap, keyID)
+	}
+}
+
 // AddClient adds the passed websocket client to the notification manager.
 func (m *wsNotificationManager) AddClient(wsc *wsClient) {
 	m.queueNotification <- (*notificationRegisterClient)(wsc)
@@ -1174,9 +1540,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// user is the user the client authenticated as, which determines the
+	// methods and notifications the client may use.
+	user *rpcUser
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1197,6 +1563,17 @@ type wsClient struct {
 	// Owned by the notification manager.
 	spentRequests map[wire.OutPoint]struct{}
 
+	// keyIDRequests and spentKeyIDRequests are the sets of keyIDs the
+	// caller has requested to be notified about when they co-sign a
+	// transaction output or a spent output respectively.  Owned by the
+	// notification manager.
+	keyIDRequests      map[btcec.KeyID]struct{}
+	spentKeyIDRequests map[btcec.KeyID]struct{}
+
+	// nextWatchID is the ID assigned to the next watch registered by the
+	// client.  Protected by the client mutex.
+	nextWatchID uint64
+
 	// filterData is the new generation transaction filter backported from
 	// github.com/decred/dcrd for the new backported `loadtxfilter` and
 	// `rescanblocks` methods.
@@ -1314,15 +1691,13 @@ out:
 			// Check credentials.
 			login := authCmd.Username + ":" + authCmd.Passphrase
 			auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-			authSha := sha256.Sum256([]byte(auth))
-			cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-			limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-			if cmp != 1 && limitcmp != 1 {
+			user := c.server.authenticate(auth)
+			if user == nil {
 				rpcsLog.Warnf("Auth failure.")
 				break out
 			}
 			c.authenticated = true
-			c.isAdmin = cmp == 1
+			c.user = user
 
 			// Marshal and send response.
 			reply, err := createMarshalledReply(cmd.id, nil, nil)
@@ -1335,25 +1710,52 @@ out:
 			continue
 		}
 
-		// Check if the client is using limited RPC credentials and
-		// error when not authorized to call this RPC.
-		if !c.isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr := &btcjson.RPCError{
-					Code:    btcjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-				// Marshal and send response.
-				reply, err := createMarshalledReply(request.ID, nil, jsonErr)
-				if err != nil {
-					rpcsLog.Errorf("Failed to marshal parse failure "+
-						"reply: %v", err)
-					continue
-				}
-				c.SendMessage(reply, nil)
+		// Check if the user of the client lacks the permission of
+		// the method and error when not authorized to call this RPC.
+		// Since notifications are only sent to clients which
+		// requested them, this also restricts the notifications
+		// available to the user.
+		if !c.user.authorized(request.Method) {
+			jsonErr := rpcUnauthorizedError(request.Method)
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.ID, nil, jsonErr)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal parse failure "+
+					"reply: %v", err)
+				continue
+			}
+			c.SendMessage(reply, nil)
+			continue
+		}
+		if c.server.shuttingDown() {
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.ID, nil,
+				errRPCShuttingDown)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal shutdown "+
+					"reply: %v", err)
+				continue
+			}
+			c.SendMessage(reply, nil)
+			continue
+		}
+		if !c.server.metrics.allow(c.user,
+			rpcMethodPermission(request.Method), request.Method,
+			time.Now()) {
+
+			jsonErr := rpcRateLimitedError(request.Method)
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.ID, nil, jsonErr)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal rate limit "+
+					"reply: %v", err)
 				continue
 			}
+			c.SendMessage(reply, nil)
+			continue
 		}
+		c.server.auditCall(c.user, request.Method, request.Params,
+			c.addr)
 
 		// Asynchronously handle the request.  A semaphore is used to
 		// limit the number of concurrent requests currently being
@@ -1399,12 +1801,14 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 
 	// Lookup the websocket extension for the command and if it doesn't
 	// exist fallback to handling the command as a standard command.
+	callID := c.server.metrics.startCall(c.user, r.method, r.cmd)
 	wsHandler, ok := wsHandlers[r.method]
 	if ok {
 		result, err = wsHandler(c, r.cmd)
 	} else {
 		result, err = c.server.standardCmdResult(r, nil)
 	}
+	c.server.metrics.finishCall(callID, err != nil)
 	reply, err := createMarshalledReply(r.id, result, err)
 	if err != nil {
 		rpcsLog.Errorf("Failed to marshal reply for <%s> "+
@@ -1619,7 +2023,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, error) {
+	remoteAddr string, user *rpcUser) (*wsClient, error) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -1627,18 +2031,20 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	}
 
 	client := &wsClient{
-		conn:              conn,
-		addr:              remoteAddr,
-		authenticated:     authenticated,
-		isAdmin:           isAdmin,
-		sessionID:         sessionID,
-		server:            server,
-		addrRequests:      make(map[string]struct{}),
-		spentRequests:     make(map[wire.OutPoint]struct{}),
-		serviceRequestSem: makeSemaphore(cfg.RPCMaxConcurrentReqs),
-		ntfnChan:          make(chan []byte, 1), // nonblocking sync
-		sendChan:          make(chan wsResponse, websocketSendBufferSize),
-		quit:              make(chan struct{}),
+		conn:               conn,
+		addr:               remoteAddr,
+		authenticated:      user != nil,
+		user:               user,
+		sessionID:          sessionID,
+		server:             server,
+		addrRequests:       make(map[string]struct{}),
+		spentRequests:      make(map[wire.OutPoint]struct{}),
+		keyIDRequests:      make(map[btcec.KeyID]struct{}),
+		spentKeyIDRequests: make(map[btcec.KeyID]struct{}),
+		serviceRequestSem:  makeSemaphore(cfg.RPCMaxConcurrentReqs),
+		ntfnChan:           make(chan []byte, 1), // nonblocking sync
+		sendChan:           make(chan wsResponse, websocketSendBufferSize),
+		quit:               make(chan struct{}),
 	}
 	return client, nil
 }
@@ -1712,9 +2118,12 @@ func handleLoadTxFilter(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		}
 	}
 
+	keyIDs := cmdKeyIDs(cmd.KeyIDs)
+
 	wsc.Lock()
 	if cmd.Reload || wsc.filterData == nil {
-		wsc.filterData = newWSClientFilter(cmd.Addresses, outPoints)
+		wsc.filterData = newWSClientFilter(cmd.Addresses, outPoints,
+			keyIDs)
 		wsc.Unlock()
 	} else {
 		wsc.Unlock()
@@ -1726,6 +2135,9 @@ func handleLoadTxFilter(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		for i := range outPoints {
 			wsc.filterData.addUnspentOutPoint(&outPoints[i])
 		}
+		for _, keyID := range keyIDs {
+			wsc.filterData.addKeyID(keyID)
+		}
 		wsc.filterData.mu.Unlock()
 	}
 
@@ -1739,6 +2151,26 @@ func handleNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// handleNotifyEvents implements the notifyevents command extension for
+// websocket connections.
+func handleNotifyEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	if wsc.server.server.eventLog == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Event log must be enabled (--eventlog)",
+		}
+	}
+	wsc.server.ntfnMgr.RegisterEventUpdates(wsc)
+	return nil, nil
+}
+
+// handleNotifyBroadcasts implements the notifybroadcasts command extension for
+// websocket connections.
+func handleNotifyBroadcasts(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterBroadcastUpdates(wsc)
+	return nil, nil
+}
+
 // handleSession implements the session command extension for websocket
 // connections.
 func handleSession(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -1752,6 +2184,20 @@ func handleStopNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, error
 	return nil, nil
 }
 
+// handleStopNotifyBroadcasts implements the stopnotifybroadcasts command
+// extension for websocket connections.
+func handleStopNotifyBroadcasts(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterBroadcastUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyEvents implements the stopnotifyevents command extension for
+// websocket connections.
+func handleStopNotifyEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterEventUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifySpent implements the notifyspent command extension for
 // websocket connections.
 func handleNotifySpent(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -1766,6 +2212,9 @@ func handleNotifySpent(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	}
 
 	wsc.server.ntfnMgr.RegisterSpentRequests(wsc, outpoints)
+	if keyIDs := cmdKeyIDs(cmd.KeyIDs); len(keyIDs) != 0 {
+		wsc.server.ntfnMgr.RegisterSpentKeyIDRequests(wsc, keyIDs)
+	}
 	return nil, nil
 }
 
@@ -1797,14 +2246,17 @@ func handleNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, error)
 		return nil, btcjson.ErrRPCInternal
 	}
 
-	// Decode addresses to validate input, but the strings slice is used
-	// directly if these are all ok.
-	err := checkAddressValidity(cmd.Addresses)
+	// Decode addresses to validate input and encode them the way addresses
+	// extracted from scripts are.
+	addrs, err := checkAddressValidity(cmd.Addresses)
 	if err != nil {
 		return nil, err
 	}
 
-	wsc.server.ntfnMgr.RegisterTxOutAddressRequests(wsc, cmd.Addresses)
+	wsc.server.ntfnMgr.RegisterTxOutAddressRequests(wsc, addrs)
+	if keyIDs := cmdKeyIDs(cmd.KeyIDs); len(keyIDs) != 0 {
+		wsc.server.ntfnMgr.RegisterTxOutKeyIDRequests(wsc, keyIDs)
+	}
 	return nil, nil
 }
 
@@ -1824,6 +2276,9 @@ func handleStopNotifySpent(wsc *wsClient, icmd interface{}) (interface{}, error)
 	for _, outpoint := range outpoints {
 		wsc.server.ntfnMgr.UnregisterSpentRequest(wsc, outpoint)
 	}
+	for _, keyID := range cmdKeyIDs(cmd.KeyIDs) {
+		wsc.server.ntfnMgr.UnregisterSpentKeyIDRequest(wsc, keyID)
+	}
 
 	return nil, nil
 }
@@ -1836,36 +2291,55 @@ func handleStopNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, err
 		return nil, btcjson.ErrRPCInternal
 	}
 
-	// Decode addresses to validate input, but the strings slice is used
-	// directly if these are all ok.
-	err := checkAddressValidity(cmd.Addresses)
+	// Decode addresses to validate input and encode them the way addresses
+	// extracted from scripts are.
+	addrs, err := checkAddressValidity(cmd.Addresses)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, addr := range cmd.Addresses {
+	for _, addr := range addrs {
 		wsc.server.ntfnMgr.UnregisterTxOutAddressRequest(wsc, addr)
 	}
+	for _, keyID := range cmdKeyIDs(cmd.KeyIDs) {
+		wsc.server.ntfnMgr.UnregisterTxOutKeyIDRequest(wsc, keyID)
+	}
 
 	return nil, nil
 }
 
+// cmdKeyIDs converts the optional keyIDs parameter of the notification
+// commands to the keyIDs matched against transaction scripts.
+func cmdKeyIDs(keyIDs *[]uint32) []btcec.KeyID {
+	if keyIDs == nil {
+		return nil
+	}
+	converted := make([]btcec.KeyID, 0, len(*keyIDs))
+	for _, keyID := range *keyIDs {
+		converted = append(converted, btcec.KeyID(keyID))
+	}
+	return converted
+}
+
 // checkAddressValidity checks the validity of each address in the passed
 // string slice. It does this by attempting to decode each address using the
 // current active network parameters. If any single address fails to decode
-// properly, the function returns an error. Otherwise, nil is returned.
-func checkAddressValidity(addrs []string) error {
+// properly, the function returns an error. Otherwise, the addresses are
+// returned encoded by canonicalAddress.
+func checkAddressValidity(addrs []string) ([]string, error) {
+	encoded := make([]string, 0, len(addrs))
 	for _, addr := range addrs {
-		_, err := provautil.DecodeAddress(addr, activeNetParams.Params)
+		a, err := provautil.DecodeAddress(addr, activeNetParams.Params)
 		if err != nil {
-			return &btcjson.RPCError{
+			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInvalidAddressOrKey,
 				Message: fmt.Sprintf("Invalid address or key: %v",
 					addr),
 			}
 		}
+		encoded = append(encoded, canonicalAddress(a))
 	}
-	return nil
+	return encoded, nil
 }
 
 // deserializeOutpoints deserializes each serialized outpoint.
@@ -1889,9 +2363,27 @@ type rescanKeys struct {
 	scriptHashes        map[[ripemd160.Size]byte]struct{}
 	compressedPubKeys   map[[33]byte]struct{}
 	uncompressedPubKeys map[[65]byte]struct{}
+	keyIDs              map[btcec.KeyID]struct{}
 	unspent             map[wire.OutPoint]struct{}
 }
 
+// matchesPkScript returns whether the passed output script pays to one of the
+// rescanned addresses or is co-signed by one of the rescanned keyIDs.
+func (r *rescanKeys) matchesPkScript(pkScript []byte, params *chaincfg.Params) bool {
+	for _, keyID := range scriptKeyIDs(pkScript) {
+		if _, ok := r.keyIDs[keyID]; ok {
+			return true
+		}
+	}
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+	for _, addr := range addrs {
+		if _, ok := r.fallbacks[canonicalAddress(addr)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // unspentSlice returns a slice of currently-unspent outpoints for the rescan
 // lookup keys.  This is primarily intended to be used to register outpoints
 // for continuous notifications after a rescan has completed.
@@ -1954,48 +2446,41 @@ func rescanBlock(wsc *wsClient, lookups *rescanKeys, blk *provautil.Block) {
 		}
 
 		for txOutIdx, txout := range tx.MsgTx().TxOut {
-			_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
-				txout.PkScript, wsc.server.server.chainParams)
-
-			for _, addr := range addrs {
-				// A new address type must have been added.  Encode as a
-				// payment address string and check the fallback map.
-				addrStr := addr.EncodeAddress()
-				_, ok := lookups.fallbacks[addrStr]
-				if !ok {
-					continue
-				}
+			if !lookups.matchesPkScript(txout.PkScript,
+				wsc.server.server.chainParams) {
 
-				outpoint := wire.OutPoint{
-					Hash:  *tx.Hash(),
-					Index: uint32(txOutIdx),
-				}
-				lookups.unspent[outpoint] = struct{}{}
+				continue
+			}
 
-				if recvNotified {
-					continue
-				}
+			outpoint := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(txOutIdx),
+			}
+			lookups.unspent[outpoint] = struct{}{}
 
-				if txHex == "" {
-					txHex = txHexString(tx.MsgTx())
-				}
-				ntfn := btcjson.NewRecvTxNtfn(txHex,
-					blockDetails(blk, tx.Index()))
+			if recvNotified {
+				continue
+			}
 
-				marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
-				if err != nil {
-					rpcsLog.Errorf("Failed to marshal recvtx notification: %v", err)
-					return
-				}
+			if txHex == "" {
+				txHex = txHexString(tx.MsgTx())
+			}
+			ntfn := btcjson.NewRecvTxNtfn(txHex,
+				blockDetails(blk, tx.Index()))
 
-				err = wsc.QueueNotification(marshalledJSON)
-				// Stop the rescan early if the websocket client
-				// disconnected.
-				if err == ErrClientQuit {
-					return
-				}
-				recvNotified = true
+			marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal recvtx notification: %v", err)
+				return
+			}
+
+			err = wsc.QueueNotification(marshalledJSON)
+			// Stop the rescan early if the websocket client
+			// disconnected.
+			if err == ErrClientQuit {
+				return
 			}
+			recvNotified = true
 		}
 	}
 }
@@ -2039,23 +2524,27 @@ func rescanBlockFilter(filter *wsClientFilter, block *provautil.Block) []string
 			if err != nil {
 				continue
 			}
+			matched := filter.existsKeyID(scriptKeyIDs(output.PkScript))
 			for _, a := range addrs {
-				if !filter.existsAddress(a) {
-					continue
+				if filter.existsAddress(a) {
+					matched = true
 				}
+			}
+			if !matched {
+				continue
+			}
 
-				op := wire.OutPoint{
-					Hash:  *tx.Hash(),
-					Index: uint32(i),
-				}
-				filter.addUnspentOutPoint(&op)
+			op := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(i),
+			}
+			filter.addUnspentOutPoint(&op)
 
-				if !added {
-					transactions = append(
-						transactions,
-						txHexString(msgTx))
-					added = true
-				}
+			if !added {
+				transactions = append(
+					transactions,
+					txHexString(msgTx))
+				added = true
 			}
 		}
 	}
@@ -2222,7 +2711,7 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		unspent:             map[wire.OutPoint]struct{}{},
 	}
 	for _, addrStr := range cmd.Addresses {
-		_, err := provautil.DecodeAddress(addrStr, activeNetParams.Params)
+		addr, err := provautil.DecodeAddress(addrStr, activeNetParams.Params)
 		if err != nil {
 			jsonErr := btcjson.RPCError{
 				Code: btcjson.ErrRPCInvalidAddressOrKey,
@@ -2234,7 +2723,7 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		// A new address type must have been added.  Use encoded
 		// payment address string as a fallback until a fast path
 		// is added.
-		lookups.fallbacks[addrStr] = struct{}{}
+		lookups.fallbacks[canonicalAddress(addr)] = struct{}{}
 	}
 	for _, outpoint := range outpoints {
 		lookups.unspent[*outpoint] = struct{}{}
@@ -0,0 +1,62 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestRescanBlockFilterKeyIDs ensures transaction filters loaded with keyIDs
+// match outputs co-signed by those keyIDs regardless of the holder address,
+// as well as later transactions spending the matched outputs.
+func TestRescanBlockFilterKeyIDs(t *testing.T) {
+	addr, err := provautil.NewAddressProva(bytes.Repeat([]byte{0x42}, 20),
+		[]btcec.KeyID{1, 2}, activeNetParams.Params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	keyIDs := scriptKeyIDs(pkScript)
+	if len(keyIDs) != 2 || keyIDs[0] != 1 || keyIDs[1] != 2 {
+		t.Fatalf("scriptKeyIDs: got %v, want [1 2]", keyIDs)
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(50, pkScript))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: coinbase.TxHash()}, nil))
+	spend.AddTxOut(wire.NewTxOut(50, []byte{txscript.OP_TRUE}))
+	block := provautil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend}})
+
+	tests := []struct {
+		name   string
+		keyIDs []btcec.KeyID
+		want   int
+	}{
+		{"co-signing keyID", []btcec.KeyID{2}, 2},
+		{"unrelated keyID", []btcec.KeyID{7}, 0},
+		{"no keyIDs", nil, 0},
+	}
+	for _, test := range tests {
+		filter := newWSClientFilter(nil, nil, test.keyIDs)
+		txns := rescanBlockFilter(filter, block)
+		if len(txns) != test.want {
+			t.Errorf("%s: got %d matching transactions, want %d",
+				test.name, len(txns), test.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package screening screens the transactions handled by a node against
+watchlists of addresses and keyIDs, for operators under compliance
+obligations.
+
+Overview
+
+The memory pool screens every transaction before accepting it, and the block
+template generator screens every transaction it considers for a block.  A
+screener decides whether a transaction is allowed, flagged or excluded based
+on the addresses and keyIDs of the outputs it spends and creates.  Excluded
+transactions are neither relayed nor mined by the node, while flagged ones are
+only recorded.  Screening is a policy of the node rather than a consensus
+rule, so blocks mined by other nodes are not affected.
+
+Screeners
+
+A list screener matches the subjects of transactions against a local list
+file, which is read again when the node reloads its configuration.  An HTTP
+screener posts the subjects of every transaction as a JSON object to an
+external service and uses the decision it responds with.  Further screeners,
+such as clients of gRPC services, implement the Screener interface.  When a
+screener fails, the transaction is allowed unless the hook fails closed.
+
+Audit Trail
+
+Every transaction which is flagged or excluded, and every screening failure,
+is appended to the audit trail as a JSON object on its own line, along with
+the subjects of the transaction and the reason given by the screener.
+Decisions are cached for a while, so a transaction is not screened again each
+time a block template is generated.
+*/
+package screening
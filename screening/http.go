@@ -0,0 +1,112 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package screening
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bitgo/prova/provautil"
+)
+
+// maxResponseSize is the maximum size in bytes of the responses of screening
+// services which are read.
+const maxResponseSize = 1 << 16
+
+// screenRequest is the JSON object describing a transaction which is posted
+// to screening services.
+type screenRequest struct {
+	TxID      string   `json:"txid"`
+	Hex       string   `json:"hex"`
+	Addresses []string `json:"addresses"`
+	KeyIDs    []uint32 `json:"keyids"`
+}
+
+// screenResponse is the JSON object screening services respond with.
+type screenResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+// httpScreener posts transactions to a screening service.
+type httpScreener struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPScreener returns a screener which posts every transaction along with
+// its subjects as a JSON object to the passed URL, giving up after the passed
+// timeout.  The service responds with a JSON object holding the decision, which
+// is one of allow, flag or exclude, and optionally the reason for it.
+func NewHTTPScreener(url string, timeout time.Duration) Screener {
+	return &httpScreener{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Screen posts the passed transaction to the screening service and returns the
+// decision it responds with.  A response with a status other than 2xx is
+// treated as a failure.
+//
+// This is part of the Screener interface.
+func (s *httpScreener) Screen(tx *provautil.Tx, subjects *Subjects) (*Result, error) {
+	var buf bytes.Buffer
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		return nil, err
+	}
+	req := screenRequest{
+		TxID:      tx.Hash().String(),
+		Hex:       hex.EncodeToString(buf.Bytes()),
+		Addresses: make([]string, 0, len(subjects.Addresses)),
+		KeyIDs:    make([]uint32, 0, len(subjects.KeyIDs)),
+	}
+	for _, addr := range subjects.Addresses {
+		req.Addresses = append(req.Addresses, addr.EncodeAddress())
+	}
+	for _, keyID := range subjects.KeyIDs {
+		req.KeyIDs = append(req.KeyIDs, uint32(keyID))
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("screening service responded with "+
+			"status %s", resp.Status)
+	}
+	var screenResp screenResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).
+		Decode(&screenResp)
+	if err != nil {
+		return nil, fmt.Errorf("malformed response: %v", err)
+	}
+	decision, err := ParseDecision(screenResp.Decision)
+	if err != nil {
+		return nil, fmt.Errorf("malformed response: %v", err)
+	}
+	return &Result{Decision: decision, Reason: screenResp.Reason}, nil
+}
+
+// String returns the URL of the screening service.
+//
+// This is part of the Screener interface.
+func (s *httpScreener) String() string {
+	return "screening service " + s.url
+}
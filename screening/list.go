@@ -0,0 +1,180 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package screening
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// keyIDPrefix prefixes the keyIDs listed in a list file.
+const keyIDPrefix = "keyid:"
+
+// listEntry is the decision about the transactions touching a listed address
+// or keyID.
+type listEntry struct {
+	decision Decision
+	reason   string
+}
+
+// watchlist houses the entries of a list file.  Addresses are keyed by the
+// script paying to them, so they match however they are encoded.
+type watchlist struct {
+	scripts map[string]listEntry
+	keyIDs  map[btcec.KeyID]listEntry
+}
+
+// parseList parses a list file.  Every line which is neither empty nor a
+// comment starting with # lists an address, or a keyID prefixed with keyid:,
+// optionally followed by the decision about the transactions touching it,
+// which defaults to exclude, and the reason for listing it.
+func parseList(r io.Reader, params *chaincfg.Params) (*watchlist, error) {
+	list := &watchlist{
+		scripts: make(map[string]listEntry),
+		keyIDs:  make(map[btcec.KeyID]listEntry),
+	}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		entry := listEntry{decision: Exclude}
+		if len(fields) > 1 {
+			decision, err := ParseDecision(strings.ToLower(fields[1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			entry.decision = decision
+		}
+		if len(fields) > 2 {
+			entry.reason = strings.Join(fields[2:], " ")
+		}
+
+		subject := fields[0]
+		if strings.HasPrefix(strings.ToLower(subject), keyIDPrefix) {
+			keyID, err := strconv.ParseUint(
+				subject[len(keyIDPrefix):], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed keyID %q",
+					lineNum, subject)
+			}
+			list.keyIDs[btcec.KeyID(keyID)] = entry
+			continue
+		}
+		addr, err := provautil.DecodeAddress(subject, params)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: malformed address %q: %v",
+				lineNum, subject, err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unsupported address %q: "+
+				"%v", lineNum, subject, err)
+		}
+		list.scripts[string(pkScript)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListScreener screens transactions against the addresses and keyIDs listed
+// in a local file.
+type ListScreener struct {
+	path   string
+	params *chaincfg.Params
+
+	mtx  sync.RWMutex
+	list *watchlist
+}
+
+// NewListScreener returns a screener using the list file at the passed path,
+// which lists addresses of the passed network.
+func NewListScreener(path string, params *chaincfg.Params) (*ListScreener, error) {
+	s := &ListScreener{path: path, params: params}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the list file again.  The running watchlist is kept when the
+// file is invalid.
+//
+// This is part of the Reloader interface.
+func (s *ListScreener) Reload() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	list, err := parseList(f, s.params)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	s.list = list
+	s.mtx.Unlock()
+	log.Infof("Loaded %d addresses and %d keyIDs from screening list %s",
+		len(list.scripts), len(list.keyIDs), s.path)
+	return nil
+}
+
+// Screen returns the most severe decision of the entries listing the subjects
+// of the passed transaction.
+//
+// This is part of the Screener interface.
+func (s *ListScreener) Screen(tx *provautil.Tx, subjects *Subjects) (*Result, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	result := &Result{Decision: Allow}
+	match := func(entry listEntry, subject string) {
+		if entry.decision <= result.Decision {
+			return
+		}
+		result.Decision = entry.decision
+		result.Reason = subject + " is listed"
+		if entry.reason != "" {
+			result.Reason += ": " + entry.reason
+		}
+	}
+	for _, addr := range subjects.Addresses {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			continue
+		}
+		if entry, ok := s.list.scripts[string(pkScript)]; ok {
+			match(entry, "address "+addr.EncodeAddress())
+		}
+	}
+	for _, keyID := range subjects.KeyIDs {
+		if entry, ok := s.list.keyIDs[keyID]; ok {
+			match(entry, fmt.Sprintf("keyID %d", keyID))
+		}
+	}
+	return result, nil
+}
+
+// String returns the path of the list file.
+//
+// This is part of the Screener interface.
+func (s *ListScreener) String() string {
+	return "screening list " + s.path
+}
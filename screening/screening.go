@@ -0,0 +1,385 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package screening
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// Decision is the outcome of screening a transaction.
+type Decision uint8
+
+// These constants define the decisions a screener makes, from the least to
+// the most severe.
+const (
+	// Allow lets the transaction be relayed and mined.
+	Allow Decision = iota
+
+	// Flag lets the transaction be relayed and mined, and records it in
+	// the audit trail.
+	Flag
+
+	// Exclude refuses to relay and mine the transaction, and records it in
+	// the audit trail.
+	Exclude
+)
+
+// decisionStrings is a map of decisions back to their constant names for
+// pretty printing.
+var decisionStrings = map[Decision]string{
+	Allow:   "allow",
+	Flag:    "flag",
+	Exclude: "exclude",
+}
+
+// String returns the Decision in human-readable form.
+func (d Decision) String() string {
+	if s, ok := decisionStrings[d]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Decision (%d)", uint8(d))
+}
+
+// ParseDecision returns the decision with the passed name.
+func ParseDecision(name string) (Decision, error) {
+	for d, s := range decisionStrings {
+		if s == name {
+			return d, nil
+		}
+	}
+	return Allow, fmt.Errorf("unknown decision %q", name)
+}
+
+// Stage identifies where a transaction is screened.
+type Stage uint8
+
+// These constants define the stages transactions are screened at.
+const (
+	// Mempool is the acceptance of transactions to the memory pool.
+	Mempool Stage = iota
+
+	// Template is the generation of block templates.
+	Template
+)
+
+// String returns the Stage in human-readable form.
+func (s Stage) String() string {
+	switch s {
+	case Mempool:
+		return "mempool"
+	case Template:
+		return "template"
+	}
+	return fmt.Sprintf("Unknown Stage (%d)", uint8(s))
+}
+
+// Subjects are the addresses and keyIDs a transaction touches, which are the
+// ones of the outputs it spends and creates.
+type Subjects struct {
+	Addresses []provautil.Address
+	KeyIDs    []btcec.KeyID
+}
+
+// TxSubjects returns the subjects of the passed transaction.  The outputs it
+// spends are looked up in the passed view, and the ones which are not found
+// are skipped.
+func TxSubjects(tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint, params *chaincfg.Params) *Subjects {
+	subjects := &Subjects{}
+	seenAddrs := make(map[string]struct{})
+	seenKeyIDs := make(map[btcec.KeyID]struct{})
+	addScript := func(pkScript []byte) {
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+		for _, addr := range addrs {
+			encoded := addr.EncodeAddress()
+			if _, ok := seenAddrs[encoded]; ok {
+				continue
+			}
+			seenAddrs[encoded] = struct{}{}
+			subjects.Addresses = append(subjects.Addresses, addr)
+		}
+		pops, err := txscript.ParseScript(pkScript)
+		if err != nil {
+			return
+		}
+		keyIDs, err := txscript.ExtractGeneralKeyIDs(pops)
+		if err != nil {
+			return
+		}
+		for _, keyID := range keyIDs {
+			if _, ok := seenKeyIDs[keyID]; ok {
+				continue
+			}
+			seenKeyIDs[keyID] = struct{}{}
+			subjects.KeyIDs = append(subjects.KeyIDs, keyID)
+		}
+	}
+
+	if !blockchain.IsCoinBase(tx) {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := &txIn.PreviousOutPoint
+			entry := utxoView.LookupEntry(&prevOut.Hash)
+			if entry == nil {
+				continue
+			}
+			addScript(entry.PkScriptByIndex(prevOut.Index))
+		}
+	}
+	for _, txOut := range tx.MsgTx().TxOut {
+		addScript(txOut.PkScript)
+	}
+	return subjects
+}
+
+// Result is the decision a screener made about a transaction.
+type Result struct {
+	Decision Decision
+
+	// Reason explains why the transaction was flagged or excluded.
+	Reason string
+}
+
+// Screener decides whether transactions are allowed, flagged or excluded.
+type Screener interface {
+	// Screen returns the decision about the passed transaction, which
+	// touches the passed subjects.
+	Screen(tx *provautil.Tx, subjects *Subjects) (*Result, error)
+
+	// String returns a description of the screener suitable for logging.
+	String() string
+}
+
+// Reloader is implemented by the screeners which read their watchlists again
+// when the node reloads its configuration.
+type Reloader interface {
+	Reload() error
+}
+
+// Config houses the parameters of a screening hook.
+type Config struct {
+	// Screeners decide about every transaction.  The most severe of their
+	// decisions applies.
+	Screeners []Screener
+
+	// FailClosed excludes the transactions a screener fails to decide
+	// about.  They are allowed otherwise.
+	FailClosed bool
+
+	// CacheTTL is how long the decision about a transaction is reused
+	// before it is screened again.  Zero disables caching.
+	CacheTTL time.Duration
+
+	// AuditFile is the path of the file the audit trail is appended to.
+	// When it is empty, the decisions are only logged.
+	AuditFile string
+
+	// ChainParams identifies the network the addresses are encoded for.
+	ChainParams *chaincfg.Params
+
+	// Now returns the current time.  It defaults to time.Now.
+	Now func() time.Time
+}
+
+// cachedResult is a cached decision along with the time it expires.
+type cachedResult struct {
+	result  *Result
+	expires time.Time
+}
+
+// auditRecord is the JSON object describing a decision in the audit trail.
+type auditRecord struct {
+	Time      int64    `json:"time"`
+	Stage     string   `json:"stage"`
+	TxID      string   `json:"txid"`
+	Decision  string   `json:"decision"`
+	Reason    string   `json:"reason,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Addresses []string `json:"addresses"`
+	KeyIDs    []uint32 `json:"keyids"`
+}
+
+// Hook screens the transactions of the memory pool and block templates with
+// the configured screeners.
+type Hook struct {
+	cfg Config
+
+	cacheMtx sync.Mutex
+	cache    map[chainhash.Hash]cachedResult
+
+	auditMtx sync.Mutex
+	audit    *os.File
+}
+
+// New returns a new screening hook using the passed config, opening its audit
+// trail.
+func New(cfg *Config) (*Hook, error) {
+	h := &Hook{
+		cfg:   *cfg,
+		cache: make(map[chainhash.Hash]cachedResult),
+	}
+	if h.cfg.Now == nil {
+		h.cfg.Now = time.Now
+	}
+	if h.cfg.AuditFile != "" {
+		audit, err := os.OpenFile(h.cfg.AuditFile,
+			os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		h.audit = audit
+	}
+	return h, nil
+}
+
+// Close closes the audit trail.
+func (h *Hook) Close() error {
+	h.auditMtx.Lock()
+	defer h.auditMtx.Unlock()
+
+	if h.audit == nil {
+		return nil
+	}
+	err := h.audit.Close()
+	h.audit = nil
+	return err
+}
+
+// Reload reads the watchlists of the screeners again and forgets the cached
+// decisions, so the transactions which are already in the memory pool are
+// screened against the new watchlists before they are mined.
+//
+// This function is safe for concurrent access.
+func (h *Hook) Reload() error {
+	for _, screener := range h.cfg.Screeners {
+		if reloader, ok := screener.(Reloader); ok {
+			if err := reloader.Reload(); err != nil {
+				return fmt.Errorf("%v: %v", screener, err)
+			}
+		}
+	}
+
+	h.cacheMtx.Lock()
+	h.cache = make(map[chainhash.Hash]cachedResult)
+	h.cacheMtx.Unlock()
+	return nil
+}
+
+// screen returns the most severe decision of the screeners about the passed
+// transaction, along with the errors of the screeners which failed.
+func (h *Hook) screen(tx *provautil.Tx, subjects *Subjects) (*Result, []error) {
+	result := &Result{Decision: Allow}
+	var errs []error
+	for _, screener := range h.cfg.Screeners {
+		r, err := screener.Screen(tx, subjects)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", screener, err))
+			if !h.cfg.FailClosed {
+				continue
+			}
+			r = &Result{
+				Decision: Exclude,
+				Reason:   fmt.Sprintf("%v failed", screener),
+			}
+		}
+		if r.Decision > result.Decision {
+			result = r
+		}
+	}
+	return result, errs
+}
+
+// Check returns the decision about the passed transaction, screening it
+// unless a decision about it is cached.  The outputs it spends are looked up
+// in the passed view.  Flagged and excluded transactions and screening
+// failures are recorded in the audit trail.
+//
+// This function is safe for concurrent access.
+func (h *Hook) Check(stage Stage, tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint) *Result {
+	now := h.cfg.Now()
+	txHash := *tx.Hash()
+	if h.cfg.CacheTTL > 0 {
+		h.cacheMtx.Lock()
+		cached, ok := h.cache[txHash]
+		h.cacheMtx.Unlock()
+		if ok && now.Before(cached.expires) {
+			return cached.result
+		}
+	}
+
+	subjects := TxSubjects(tx, utxoView, h.cfg.ChainParams)
+	result, errs := h.screen(tx, subjects)
+	for _, err := range errs {
+		log.Errorf("Failed to screen transaction %v: %v", txHash, err)
+	}
+	if result.Decision != Allow || len(errs) > 0 {
+		h.record(now, stage, &txHash, subjects, result, errs)
+	}
+
+	if h.cfg.CacheTTL > 0 && len(errs) == 0 {
+		h.cacheMtx.Lock()
+		for hash, cached := range h.cache {
+			if !now.Before(cached.expires) {
+				delete(h.cache, hash)
+			}
+		}
+		h.cache[txHash] = cachedResult{
+			result:  result,
+			expires: now.Add(h.cfg.CacheTTL),
+		}
+		h.cacheMtx.Unlock()
+	}
+	return result
+}
+
+// record logs the passed decision and appends it to the audit trail.
+func (h *Hook) record(now time.Time, stage Stage, txHash *chainhash.Hash, subjects *Subjects, result *Result, errs []error) {
+	rec := auditRecord{
+		Time:      now.Unix(),
+		Stage:     stage.String(),
+		TxID:      txHash.String(),
+		Decision:  result.Decision.String(),
+		Reason:    result.Reason,
+		Addresses: make([]string, 0, len(subjects.Addresses)),
+		KeyIDs:    make([]uint32, 0, len(subjects.KeyIDs)),
+	}
+	for _, addr := range subjects.Addresses {
+		rec.Addresses = append(rec.Addresses, addr.EncodeAddress())
+	}
+	for _, keyID := range subjects.KeyIDs {
+		rec.KeyIDs = append(rec.KeyIDs, uint32(keyID))
+	}
+	if len(errs) > 0 {
+		rec.Error = errs[0].Error()
+	}
+	if result.Decision != Allow {
+		log.Infof("Screening %s transaction %v at %s: %s", result.Decision,
+			txHash, stage, result.Reason)
+	}
+
+	h.auditMtx.Lock()
+	defer h.auditMtx.Unlock()
+
+	if h.audit == nil {
+		return
+	}
+	line, err := json.Marshal(&rec)
+	if err != nil {
+		log.Errorf("Failed to record screening decision: %v", err)
+		return
+	}
+	if _, err := h.audit.Write(append(line, '\n')); err != nil {
+		log.Errorf("Failed to record screening decision: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package screening
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+var params = &chaincfg.RegressionNetParams
+
+// testAddress returns an address of the passed keyIDs whose public key hash is
+// made of the passed byte.
+func testAddress(t *testing.T, b byte, keyIDs ...btcec.KeyID) *provautil.AddressProva {
+	pkHash := make([]byte, 20)
+	pkHash[0] = b
+	addr, err := provautil.NewAddressProva(pkHash, keyIDs, params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	return addr
+}
+
+// testTx returns a transaction paying to the passed address.
+func testTx(t *testing.T, addr provautil.Address) *provautil.Tx {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+	return provautil.NewTx(tx)
+}
+
+// funcScreener is a screener which decides with a function.
+type funcScreener func(tx *provautil.Tx, subjects *Subjects) (*Result, error)
+
+func (f funcScreener) Screen(tx *provautil.Tx, subjects *Subjects) (*Result, error) {
+	return f(tx, subjects)
+}
+func (f funcScreener) String() string { return "func" }
+
+// TestParseList ensures list files are parsed with their default decision and
+// reasons, and malformed lines are rejected.
+func TestParseList(t *testing.T) {
+	addr := testAddress(t, 1, 1, 2)
+	list, err := parseList(strings.NewReader(
+		"# sanctioned\n\n"+
+			addr.EncodeAddress()+" flag  known   mixer\n"+
+			"keyid:7\n"), params)
+	if err != nil {
+		t.Fatalf("parseList: unexpected error: %v", err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	entry, ok := list.scripts[string(pkScript)]
+	if !ok || entry.decision != Flag || entry.reason != "known mixer" {
+		t.Errorf("parseList: got address entry %+v, want flagged as "+
+			"known mixer", entry)
+	}
+	if entry := list.keyIDs[7]; entry.decision != Exclude {
+		t.Errorf("parseList: got keyID entry %+v, want excluded", entry)
+	}
+
+	for _, line := range []string{
+		"keyid:x",
+		"keyid:-1",
+		"notanaddress",
+		addr.EncodeAddress() + " block",
+	} {
+		if _, err := parseList(strings.NewReader(line), params); err == nil {
+			t.Errorf("parseList(%q): no error", line)
+		}
+	}
+}
+
+// TestHook ensures the most severe decision of the screeners applies, flagged
+// and excluded transactions are recorded in the audit trail, decisions are
+// cached, and screening failures follow the fail policy.
+func TestHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "screening")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	flagged := testAddress(t, 1, 1, 2)
+	listPath := filepath.Join(dir, "list")
+	err = ioutil.WriteFile(listPath, []byte(flagged.EncodeAddress()+
+		" flag watched\nkeyid:9 exclude\n"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	list, err := NewListScreener(listPath, params)
+	if err != nil {
+		t.Fatalf("NewListScreener: unexpected error: %v", err)
+	}
+	var calls int
+	var failure error
+	counter := funcScreener(func(*provautil.Tx, *Subjects) (*Result, error) {
+		calls++
+		return &Result{Decision: Allow}, failure
+	})
+
+	now := time.Unix(1500000000, 0)
+	auditPath := filepath.Join(dir, "audit")
+	h, err := New(&Config{
+		Screeners:   []Screener{list, counter},
+		CacheTTL:    time.Minute,
+		AuditFile:   auditPath,
+		ChainParams: params,
+		Now:         func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	view := blockchain.NewUtxoViewpoint()
+
+	tests := []struct {
+		name     string
+		tx       *provautil.Tx
+		decision Decision
+		calls    int
+	}{
+		{"flagged", testTx(t, flagged), Flag, 1},
+		{"cached", testTx(t, flagged), Flag, 1},
+		{"excluded keyID", testTx(t, testAddress(t, 2, 3, 9)), Exclude, 2},
+		{"allowed", testTx(t, testAddress(t, 3, 3, 4)), Allow, 3},
+	}
+	for _, test := range tests {
+		result := h.Check(Mempool, test.tx, view)
+		if result.Decision != test.decision {
+			t.Errorf("%s: got decision %v, want %v", test.name,
+				result.Decision, test.decision)
+		}
+		if calls != test.calls {
+			t.Errorf("%s: got %d screenings, want %d", test.name,
+				calls, test.calls)
+		}
+	}
+
+	// Failures allow transactions unless the hook fails closed, and are
+	// not cached.
+	failure = errors.New("unavailable")
+	tx := testTx(t, testAddress(t, 4, 3, 4))
+	if result := h.Check(Template, tx, view); result.Decision != Allow {
+		t.Errorf("fail open: got decision %v, want allow",
+			result.Decision)
+	}
+	h.cfg.FailClosed = true
+	if result := h.Check(Template, tx, view); result.Decision != Exclude {
+		t.Errorf("fail closed: got decision %v, want exclude",
+			result.Decision)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	f, err := os.Open(auditPath)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer f.Close()
+	var records []auditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Unmarshal: unexpected error: %v", err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 4 {
+		t.Fatalf("audit trail: got %d records, want 4", len(records))
+	}
+	if rec := records[0]; rec.Decision != "flag" || rec.Stage != "mempool" ||
+		rec.TxID != tests[0].tx.Hash().String() ||
+		len(rec.Addresses) != 1 || len(rec.KeyIDs) != 2 ||
+		!strings.Contains(rec.Reason, "watched") {
+
+		t.Errorf("audit trail: unexpected record %+v", rec)
+	}
+	if rec := records[3]; rec.Decision != "exclude" ||
+		rec.Stage != "template" || rec.Error == "" {
+
+		t.Errorf("audit trail: unexpected failure record %+v", rec)
+	}
+}
+
+// TestHTTPScreener ensures transactions are posted along with their subjects
+// and the decisions of the service are used.
+func TestHTTPScreener(t *testing.T) {
+	var received screenRequest
+	response := `{"decision":"exclude","reason":"sanctioned"}`
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &received)
+			w.Write([]byte(response))
+		}))
+	defer server.Close()
+
+	addr := testAddress(t, 1, 1, 2)
+	tx := testTx(t, addr)
+	subjects := &Subjects{
+		Addresses: []provautil.Address{addr},
+		KeyIDs:    []btcec.KeyID{1, 2},
+	}
+	s := NewHTTPScreener(server.URL, time.Second)
+	result, err := s.Screen(tx, subjects)
+	if err != nil {
+		t.Fatalf("Screen: unexpected error: %v", err)
+	}
+	if result.Decision != Exclude || result.Reason != "sanctioned" {
+		t.Errorf("Screen: got %+v, want excluded as sanctioned", result)
+	}
+	if received.TxID != tx.Hash().String() || len(received.Addresses) != 1 ||
+		received.Addresses[0] != addr.EncodeAddress() ||
+		len(received.KeyIDs) != 2 || received.Hex == "" {
+
+		t.Errorf("Screen: unexpected request %+v", received)
+	}
+
+	response = `{"decision":"maybe"}`
+	if _, err := s.Screen(tx, subjects); err == nil {
+		t.Errorf("Screen: no error for an unknown decision")
+	}
+}
@@ -9,12 +9,15 @@ package main
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"net"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,19 +25,27 @@ import (
 	"time"
 
 	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/alert"
+	"github.com/bitgo/prova/auditlog"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/eventlog"
 	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/mining/cpuminer"
 	"github.com/bitgo/prova/peer"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/provautil/bloom"
+	"github.com/bitgo/prova/screening"
+	"github.com/bitgo/prova/signguard"
 	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wallet"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -54,6 +65,26 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// feelerInterval is the interval between feeler connections, which test
+	// whether addresses that were never connected to are reachable.
+	feelerInterval = time.Minute * 2
+
+	// maxAnchors is the maximum number of outbound peers saved on shutdown
+	// and connected to first at next start.
+	maxAnchors = 2
+
+	// screeningAuditFilename is the name of the file in the data directory
+	// the screening decisions are appended to.
+	screeningAuditFilename = "screening_audit.log"
+
+	// auditLogFilename is the name of the file in the data directory the
+	// audit log of privileged operations is kept in.
+	auditLogFilename = "audit.log"
+
+	// signGuardFilename is the name of the file in the data directory the
+	// last block released for each validate key is kept in.
+	signGuardFilename = "signguard.json"
 )
 
 var (
@@ -89,10 +120,13 @@ func (oa *onionAddr) Network() string {
 var _ net.Addr = (*onionAddr)(nil)
 
 // broadcastMsg provides the ability to house a bitcoin message to be broadcast
-// to all connected peers except specified excluded peers.
+// to all connected peers except specified excluded peers.  Peers with a
+// protocol version below minProtocolVersion, which do not support the message,
+// are skipped as well.
 type broadcastMsg struct {
-	message      wire.Message
-	excludePeers []*serverPeer
+	message            wire.Message
+	excludePeers       []*serverPeer
+	minProtocolVersion uint32
 }
 
 // broadcastInventoryAdd is a type used to declare that the InvVect it contains
@@ -103,6 +137,23 @@ type broadcastInventoryAdd relayMsg
 // needs to be removed from the rebroadcast map
 type broadcastInventoryDel *wire.InvVect
 
+// Reasons misbehaving peers are penalized for.  They are logged along with the
+// details of the misbehavior, so the penalties can be told apart and counted.
+const (
+	misbehaviorMsgLimit         = "msglimit"
+	misbehaviorInvalidIndex     = "invalidindex"
+	misbehaviorInvalidHeaders   = "invalidheaders"
+	misbehaviorUnsupported      = "unsupported"
+	misbehaviorInvalidHeartbeat = "invalidheartbeat"
+)
+
+// banPeerMsg packages a misbehaving peer to ban along with the reason it was
+// penalized for.
+type banPeerMsg struct {
+	sp     *serverPeer
+	reason string
+}
+
 // relayMsg packages an inventory vector along with the newly discovered
 // inventory so the relay has access to that information.
 type relayMsg struct {
@@ -123,12 +174,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -165,23 +215,31 @@ type server struct {
 	// Putting the uint64s first makes them 64-bit aligned for 32-bit systems.
 	bytesReceived uint64 // Total bytes received from all peers since start.
 	bytesSent     uint64 // Total bytes sent by all peers since start.
+
+	// shutdownDeadline is the time in unix nanoseconds by which the
+	// shutdown gives up on the subsystems which did not stop, or 0 when it
+	// waits for them indefinitely.
+	shutdownDeadline int64
+
 	started       int32
 	shutdown      int32
 	shutdownSched int32
 
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
+	banManager           *connmgr.BanManager
 	connManager          *connmgr.ConnManager
 	sigCache             *txscript.SigCache
 	hashCache            *txscript.HashCache
 	rpcServer            *rpcServer
+	grpcServer           *grpcServer
 	blockManager         *blockManager
 	txMemPool            *mempool.TxPool
 	cpuMiner             *cpuminer.CPUMiner
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
 	donePeers            chan *serverPeer
-	banPeers             chan *serverPeer
+	banPeers             chan banPeerMsg
 	query                chan interface{}
 	relayInv             chan relayMsg
 	broadcast            chan broadcastMsg
@@ -197,21 +255,254 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
 	addrIndex *indexers.AddrIndex
+	cfIndex   *indexers.CfIndex
+
+	// The remaining optional indexes can be enabled and dropped while the
+	// server is running, so they are protected by the mutex and must be
+	// accessed via the indexes function.
+	indexMtx   sync.RWMutex
+	optIndexes optionalIndexes
+
+	// indexManager maintains the optional indexes and catches them up with
+	// the chain in the background.
+	indexManager *indexers.Manager
+
+	// eventLog records chain and mempool events for clients to replay
+	// when enabled, and is nil otherwise.
+	eventLog *eventlog.Log
+
+	// velocity tracks the volume spent by each keyID to enforce the keyID
+	// velocity limits when configured, and is nil otherwise.
+	velocity *mempool.VelocityTracker
+
+	// screening screens transactions against the watchlists of the
+	// operator when configured, and is nil otherwise.
+	screening *screening.Hook
+
+	// auditLog records the privileged operations performed on the node
+	// when enabled, and is nil otherwise.
+	auditLog *auditlog.Log
+
+	// consistencyChecker periodically checks the chain state for silent
+	// database corruption when enabled.
+	consistencyChecker *consistencyChecker
+
+	// keySigners holds the validate and admin keys kept outside of the
+	// node.
+	keySigners *keySigners
+
+	// heartbeatManager sends heartbeats for the validate keys held by this
+	// node and tracks the heartbeats of the other validators.
+	heartbeatManager *heartbeatManager
+
+	// signGuard refuses to sign blocks competing with the blocks already
+	// signed by the same validate key.  It is nil when --nosignguard is
+	// set.
+	signGuard *signguard.Guard
+
+	// cluster coordinates block production with the other members of the
+	// validator cluster of this node.  It is nil unless --clusterrole is
+	// set.
+	cluster *clusterManager
+
+	// alertMonitor raises alerts about anomalies of the node when alert
+	// targets are configured, and is nil otherwise.
+	alertMonitor *alertMonitor
+
+	// wallet is the built-in wallet when --walletkey is set, and is nil
+	// otherwise.
+	wallet *wallet.Wallet
+
+	// broadcasts tracks the campaigns of transactions broadcast with the
+	// broadcasttransaction RPC.
+	broadcasts *broadcastTracker
+
+	// adminProposals houses the admin transactions proposed for co-signing
+	// by the admin keyholders.
+	adminProposals *adminProposals
+
+	// dnsSeeder answers DNS seed queries received on dnsSeederConns when
+	// this node acts as a DNS seed, and is nil otherwise.
+	dnsSeeder      *connmgr.DNSSeeder
+	dnsSeederConns []net.PacketConn
+
+	// uploadLimiter and downloadLimiter limit the rate data is sent to and
+	// received from all peers combined.
+	uploadLimiter   *peer.RateLimiter
+	downloadLimiter *peer.RateLimiter
+
+	// blockTemplateGenerator generates the block templates mined by the
+	// CPU miner and served to external miners.
+	blockTemplateGenerator *mining.BlkTmplGenerator
+
+	// The options which can be changed by reloading the configuration are
+	// protected by the mutex.  loadedCfg is the configuration which was
+	// loaded last, which a reloaded configuration is compared with.
+	reloadMtx  sync.RWMutex
+	peerPolicy *peerPolicy
+	loadedCfg  *config
+}
+
+// optionalIndexes houses the optional indexes which can be enabled and dropped
+// while the server is running.  A field is nil when the associated index is not
+// enabled.
+type optionalIndexes struct {
+	txIndex           *indexers.TxIndex
+	spentIndex        *indexers.SpentIndex
+	keyIDBalanceIndex *indexers.KeyIDBalanceIndex
+	supplyIndex       *indexers.SupplyIndex
+	timestampIndex    *indexers.TimestampIndex
+	statsIndex        *indexers.StatsIndex
+	utxoCommitIndex   *indexers.UtxoCommitIndex
+}
+
+// indexes returns the optional indexes which are currently enabled.
+//
+// This function is safe for concurrent access.
+func (s *server) indexes() optionalIndexes {
+	s.indexMtx.RLock()
+	defer s.indexMtx.RUnlock()
+	return s.optIndexes
+}
+
+// runtimeIndex returns a new instance of the optional index with the passed
+// option name, such as txindex, along with a function which sets the field of
+// the optional indexes housing it.  The address and committed filter indexes
+// are also used by the memory pool and the peers, so they can only be changed
+// while the server is stopped.
+func (s *server) runtimeIndex(name string) (indexers.Indexer, func(*optionalIndexes, bool), error) {
+	switch name {
+	case "txindex":
+		idx := indexers.NewTxIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.txIndex = nil
+			if enabled {
+				o.txIndex = idx
+			}
+		}, nil
+
+	case "spentindex":
+		idx := indexers.NewSpentIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.spentIndex = nil
+			if enabled {
+				o.spentIndex = idx
+			}
+		}, nil
+
+	case "keyidbalanceindex":
+		idx := indexers.NewKeyIDBalanceIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.keyIDBalanceIndex = nil
+			if enabled {
+				o.keyIDBalanceIndex = idx
+			}
+		}, nil
+
+	case "supplyindex":
+		idx := indexers.NewSupplyIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.supplyIndex = nil
+			if enabled {
+				o.supplyIndex = idx
+			}
+		}, nil
+
+	case "timestampindex":
+		idx := indexers.NewTimestampIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.timestampIndex = nil
+			if enabled {
+				o.timestampIndex = idx
+			}
+		}, nil
+
+	case "statsindex":
+		idx := indexers.NewStatsIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.statsIndex = nil
+			if enabled {
+				o.statsIndex = idx
+			}
+		}, nil
+
+	case "utxocommitindex":
+		idx := indexers.NewUtxoCommitIndex(s.db)
+		return idx, func(o *optionalIndexes, enabled bool) {
+			o.utxoCommitIndex = nil
+			if enabled {
+				o.utxoCommitIndex = idx
+			}
+		}, nil
+
+	case "addrindex", "cfindex":
+		return nil, nil, fmt.Errorf("the %s can only be enabled or "+
+			"dropped with --%s or --drop%s while the node is stopped",
+			name, name, name)
+	}
+
+	return nil, nil, fmt.Errorf("unknown index %q", name)
+}
+
+// EnableIndex enables the optional index with the passed option name, such as
+// txindex, while the server is running.  The index is caught up with the main
+// chain in the background.  It remains enabled until the server is stopped.
+//
+// This function is safe for concurrent access.
+func (s *server) EnableIndex(name string) error {
+	indexer, setIndex, err := s.runtimeIndex(name)
+	if err != nil {
+		return err
+	}
+
+	s.indexMtx.Lock()
+	defer s.indexMtx.Unlock()
+	if err := s.indexManager.AddIndex(indexer); err != nil {
+		return err
+	}
+	setIndex(&s.optIndexes, true)
+	return nil
+}
+
+// DropIndex disables the optional index with the passed option name, such as
+// txindex, while the server is running, and removes it from the database in
+// the background.
+//
+// This function is safe for concurrent access.
+func (s *server) DropIndex(name string) error {
+	indexer, setIndex, err := s.runtimeIndex(name)
+	if err != nil {
+		return err
+	}
+
+	s.indexMtx.Lock()
+	defer s.indexMtx.Unlock()
+	if err := s.indexManager.DropIndex(indexer); err != nil {
+		return err
+	}
+	setIndex(&s.optIndexes, false)
+	return nil
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter      int64
+	blocksRecv     uint64
+	blocksAccepted uint64
+	txsRecv        uint64
+	txsAccepted    uint64
 
 	*peer.Peer
 
 	connReq         *connmgr.ConnReq
 	server          *server
 	persistent      bool
+	fedMember       string
+	isWhitelisted   bool
+	feeler          bool
 	continueHash    *chainhash.Hash
 	relayMtx        sync.Mutex
 	disableRelayTx  bool
@@ -219,6 +510,7 @@ type serverPeer struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+	partialBlock    *partialBlock
 	filter          *bloom.Filter
 	knownAddresses  map[string]struct{}
 	banScore        connmgr.DynamicBanScore
@@ -303,44 +595,85 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 	sp.addKnownAddresses(known)
 }
 
+// isFederationMember returns whether the peer is a fellow federation member
+// which authenticated itself with its pinned certificate.
+func (sp *serverPeer) isFederationMember() bool {
+	return sp.fedMember != ""
+}
+
 // addBanScore increases the persistent and decaying ban score fields by the
-// values passed as parameters. If the resulting score exceeds half of the ban
-// threshold, a warning is logged including the reason provided. Further, if
-// the score is above the ban threshold, the peer will be banned and
+// values passed as parameters.  The reason identifies the kind of misbehavior
+// and the detail describes it.  Every penalty is logged with its reason and
+// detail, as a warning once the score exceeds the warn threshold.  Further,
+// if the score is above the ban threshold, the peer will be banned and
 // disconnected.
-func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
+func (sp *serverPeer) addBanScore(persistent, transient uint32, reason, detail string) {
 	// No warning is logged and no score is calculated if banning is disabled.
-	if cfg.DisableBanning {
+	policy := sp.server.currentPeerPolicy()
+	if policy.disableBanning {
 		return
 	}
-	warnThreshold := cfg.BanThreshold >> 1
-	if transient == 0 && persistent == 0 {
-		// The score is not being increased, but a warning message is still
-		// logged if the score is above the warn threshold.
-		score := sp.banScore.Int()
-		if score > warnThreshold {
-			peerLog.Warnf("Misbehaving peer %s: %s -- ban score is %d, "+
-				"it was not increased this time", sp, reason, score)
-		}
+
+	// Whitelisted peers and fellow federation members, which are
+	// authenticated, are never banned.  Their misbehavior is still logged.
+	if sp.isWhitelisted {
+		peerLog.Debugf("Misbehaving whitelisted peer %s: reason=%s "+
+			"detail=%q", sp, reason, detail)
 		return
 	}
-	score := sp.banScore.Increase(persistent, transient)
-	if score > warnThreshold {
-		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
-			sp, reason, score)
-		if score > cfg.BanThreshold {
-			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
-				sp)
-			sp.server.BanPeer(sp)
-			sp.Disconnect()
-		}
+	if sp.isFederationMember() {
+		peerLog.Warnf("Misbehaving federation member %s (%s): reason=%s "+
+			"detail=%q", sp, sp.fedMember, reason, detail)
+		return
+	}
+
+	// The score is not increased when both increases are zero, but the
+	// misbehavior is still logged.
+	score := sp.banScore.Int()
+	if persistent != 0 || transient != 0 {
+		score = sp.banScore.Increase(persistent, transient)
+	}
+	str := fmt.Sprintf("Misbehaving peer %s: reason=%s detail=%q "+
+		"persistent=+%d transient=+%d score=%d", sp, reason, detail,
+		persistent, transient, score)
+	log := withLogFields(peerLog, logFields{"peer": sp.Addr()})
+	if score > policy.banWarnThreshold {
+		log.Warn(str)
+	} else {
+		log.Debug(str)
+	}
+	if score > policy.banThreshold {
+		log.Warnf("Misbehaving peer %s -- banning and disconnecting",
+			sp)
+		sp.server.BanPeer(sp, reason)
+		sp.Disconnect()
 	}
 }
 
+// OnMessageLimited is invoked when a peer receives a message which is over the
+// limit of its message type.  The peer has already dropped the message.  A
+// decaying ban score increase is applied, so peers which keep flooding are
+// banned while peers which exceed a limit briefly are not.
+func (sp *serverPeer) OnMessageLimited(_ *peer.Peer, msg wire.Message, entries int) {
+	msgLimitBanScore := sp.server.currentPeerPolicy().msgLimitBanScore
+	sp.addBanScore(0, msgLimitBanScore, misbehaviorMsgLimit,
+		fmt.Sprintf("%s message with %d entries over the limit",
+			msg.Command(), entries))
+}
+
 // OnVersion is invoked when a peer receives a version bitcoin message
 // and is used to negotiate the protocol version details as well as kick start
 // the communications.
 func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
+	// Feeler connections only test whether the address is reachable, so
+	// the address is marked good and the connection closed right away.
+	if sp.feeler {
+		srvrLog.Debugf("Feeler connection to %s succeeded", sp)
+		sp.server.addrManager.Good(sp.NA())
+		sp.Disconnect()
+		return
+	}
+
 	// Add the remote peer time as a sample for creating an offset against
 	// the local clock to keep the network time in sync.
 	sp.server.timeSource.AddTimeSample(sp.Addr(), msg.Timestamp)
@@ -361,18 +694,6 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 		addrManager := sp.server.addrManager
 		// Outbound connections.
 		if !sp.Inbound() {
-			// TODO(davec): Only do this if not doing the initial block
-			// download and the local address is routable.
-			if !cfg.DisableListen /* && isCurrent? */ {
-				// Get address that best matches.
-				lna := addrManager.GetBestLocalAddress(sp.NA())
-				if addrmgr.IsRoutable(lna) {
-					// Filter addresses the peer already knows about.
-					addresses := []*wire.NetAddress{lna}
-					sp.pushAddrMsg(addresses)
-				}
-			}
-
 			// Request known addresses if the server address manager needs
 			// more and the peer has a protocol version new enough to
 			// include a timestamp with addresses.
@@ -387,30 +708,72 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 		}
 	}
 
+	// Ask the peer not to announce transactions which pay a lower fee
+	// than the memory pool accepts.  No transactions are relayed in
+	// blocks only mode, so there is nothing to filter then.
+	if !cfg.BlocksOnly && sp.ProtocolVersion() >= wire.FeeFilterVersion {
+		minFee := sp.server.txMemPool.MinFeeFilter()
+		if minFee > 0 {
+			sp.QueueMessage(wire.NewMsgFeeFilter(minFee), nil)
+		}
+	}
+
+	// Ask the peer to announce new blocks with their headers, so the
+	// signature of a block can be checked before the block is requested.
+	if sp.ProtocolVersion() >= wire.SendHeadersVersion {
+		sp.QueueMessage(wire.NewMsgSendHeaders(), nil)
+	}
+
+	// Signal support for compact blocks.  Outbound peers, which were
+	// chosen by this node, are also asked to push new blocks as compact
+	// blocks without announcing them first, which saves a round trip on
+	// every block.
+	if sp.ProtocolVersion() >= wire.CompactBlocksVersion {
+		sp.QueueMessage(wire.NewMsgSendCmpct(!sp.Inbound(),
+			wire.CmpctBlockEncodingVersion), nil)
+	}
+
 	// Add valid peer to the server.
 	sp.server.AddPeer(sp)
 }
 
+// OnVerAck is invoked when a peer receives a verack bitcoin message and is used
+// to advertise the local address which best matches the address of outbound
+// peers.  This waits for the verack message since peers ask for addrv2
+// messages before sending it, and Tor v3 addresses can only be advertised with
+// addrv2 messages.
+func (sp *serverPeer) OnVerAck(_ *peer.Peer, msg *wire.MsgVerAck) {
+	// This is skipped when running on the simulation test network for the
+	// same reasons as in OnVersion.
+	if cfg.SimNet || sp.Inbound() || cfg.DisableListen {
+		return
+	}
+
+	// TODO(davec): Only do this if not doing the initial block download.
+	lna := sp.server.addrManager.GetBestLocalAddress(sp.NA())
+	if addrmgr.IsRoutable(lna) {
+		// Filter addresses the peer already knows about.
+		addresses := []*wire.NetAddress{lna}
+		sp.pushAddrMsg(addresses)
+	}
+}
+
 // OnMemPool is invoked when a peer receives a mempool bitcoin message.
 // It creates and sends an inventory message with the contents of the memory
 // pool up to the maximum inventory allowed per message.  When the peer has a
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// Only allow mempool requests if the server has bloom filtering
-	// enabled.
-	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
+	// enabled, or from fellow federation members, which share their
+	// memory pools when a member of a validator cluster takes over.
+	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom &&
+		!sp.isFederationMember() {
 		peerLog.Debugf("peer %v sent mempool request with bloom "+
 			"filtering disabled -- disconnecting", sp)
 		sp.Disconnect()
 		return
 	}
 
-	// A decaying ban score increase is applied to prevent flooding.
-	// The ban score accumulates and passes the ban threshold if a burst of
-	// mempool messages comes from a peer. The score decays each minute to
-	// half of its value.
-	sp.addBanScore(0, 33, "mempool")
-
 	// Generate inventory message with the available transactions in the
 	// transaction memory pool.  Limit it to the max allowed inventory
 	// per message.  The NewMsgInvSizeHint function automatically limits
@@ -420,7 +783,14 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	txDescs := txMemPool.TxDescs()
 	invMsg := wire.NewMsgInvSizeHint(uint(len(txDescs)))
 
+	feeFilter := atomic.LoadInt64(&sp.feeFilter)
 	for _, txDesc := range txDescs {
+		// Leave out transactions which pay less than the peer's
+		// feefilter.
+		if feeFilter > 0 && txDesc.FeePerKB < feeFilter {
+			continue
+		}
+
 		// Either add all transactions when there is no bloom filter,
 		// or only the transactions that match the filter when there is
 		// one.
@@ -492,6 +862,79 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	<-sp.blockProcessed
 }
 
+// OnHeartbeat is invoked when a peer receives a heartbeat message.  Heartbeats
+// of active validate keys which are newer than the heartbeats known for them
+// are relayed to the other peers, while peers relaying heartbeats with an
+// invalid signature are banned.
+func (sp *serverPeer) OnHeartbeat(_ *peer.Peer, msg *wire.MsgHeartbeat) {
+	relay, err := sp.server.heartbeatManager.ProcessHeartbeat(msg,
+		sp.Addr())
+	if err != nil {
+		sp.addBanScore(100, 0, misbehaviorInvalidHeartbeat,
+			fmt.Sprintf("heartbeat of validate key %v: %v",
+				msg.ValidatingPubKey, err))
+		return
+	}
+	if relay {
+		if sp.server.cluster != nil {
+			sp.server.cluster.ProcessHeartbeat(msg)
+		}
+		sp.server.relayHeartbeat(msg, sp)
+	}
+}
+
+// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.
+// It blocks until the block has been reconstructed and processed, or until the
+// missing transactions have been requested.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Add the block to the known inventory for the peer.
+	blockHash := msg.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	sp.AddKnownInventory(iv)
+
+	sp.server.blockManager.QueueCmpctBlock(msg, sp)
+	<-sp.blockProcessed
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin message.  It
+// blocks until the completed block has been processed.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	sp.server.blockManager.QueueBlockTxn(msg, sp)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin message.
+// It responds with the requested transactions of the block.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	msgBlock, err := sp.server.fetchMsgBlock(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch block %v requested by %v: %v",
+			msg.BlockHash, sp, err)
+		return
+	}
+
+	blockTxn := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, index := range msg.Indexes {
+		if int(index) >= len(msgBlock.Transactions) {
+			sp.addBanScore(100, 0, misbehaviorInvalidIndex,
+				fmt.Sprintf("getblocktxn index %d of block %v with "+
+					"%d transactions", index, msg.BlockHash,
+					len(msgBlock.Transactions)))
+			return
+		}
+		blockTxn.AddTransaction(msgBlock.Transactions[index])
+	}
+	sp.QueueMessage(blockTxn, nil)
+}
+
+// OnHeaders is invoked when a peer receives a headers bitcoin message.  Headers
+// announce new blocks since this node asks its peers to send them with a
+// sendheaders message.  The message is passed down to the block manager which
+// requests the announced blocks.
+func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	sp.server.blockManager.QueueHeaders(msg, sp)
+}
+
 // OnInv is invoked when a peer receives an inv bitcoin message and is
 // used to examine the inventory being advertised by the remote peer and react
 // accordingly.  We pass the message down to blockmanager which will call
@@ -499,6 +942,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	if !cfg.BlocksOnly {
 		if len(msg.InvList) > 0 {
+			sp.server.broadcasts.PeerAnnounced(msg.InvList, sp.ID())
 			sp.server.blockManager.QueueInv(msg, sp)
 		}
 		return
@@ -535,16 +979,9 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 	numAdded := 0
 	notFound := wire.NewMsgNotFound()
 
+	// Unusually large inventory queries and floods of queries are dropped
+	// and penalized by the getdata message limit of the peer.
 	length := len(msg.InvList)
-	// A decaying ban score increase is applied to prevent exhausting resources
-	// with unusually large inventory queries.
-	// Requesting more than the maximum inventory vector length within a short
-	// period of time yields a score above the default ban threshold. Sustained
-	// bursts of small requests are not penalized as that would potentially ban
-	// peers performing IBD.
-	// This incremental score decays each minute to half of its value.
-	sp.addBanScore(0, uint32(length)*99/wire.MaxInvPerMsg, "getdata")
-
 	// We wait on this wait channel periodically to prevent queuing
 	// far more data than we can send in a reasonable time, wasting memory.
 	// The waiting occurs after the database fetch for the next one to
@@ -565,8 +1002,14 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 		switch iv.Type {
 		case wire.InvTypeTx:
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan)
+			if err == nil {
+				sp.server.broadcasts.PeerRequested(&iv.Hash,
+					sp.ID())
+			}
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeFilteredBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan)
 		default:
@@ -770,6 +1213,199 @@ func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
 	sp.QueueMessage(&wire.MsgHeaders{Headers: blockHeaders}, nil)
 }
 
+// cfBlockHashes returns the hashes of the main chain blocks from the passed
+// start height up to the block with the passed stop hash, which must be in the
+// main chain.  An error is returned when the range spans more than maxBlocks
+// blocks.
+func (s *server) cfBlockHashes(startHeight uint32, stopHash *chainhash.Hash, maxBlocks uint32) ([]*chainhash.Hash, error) {
+	chain := s.blockManager.chain
+	stopHeight, err := chain.BlockHeightByHash(stopHash)
+	if err != nil {
+		return nil, fmt.Errorf("stop block %v is not in the main "+
+			"chain", stopHash)
+	}
+	if startHeight > stopHeight {
+		return nil, fmt.Errorf("start height %d is after stop height "+
+			"%d", startHeight, stopHeight)
+	}
+	if stopHeight-startHeight >= maxBlocks {
+		return nil, fmt.Errorf("range of %d blocks exceeds the "+
+			"maximum of %d", stopHeight-startHeight+1, maxBlocks)
+	}
+
+	hashes, err := chain.HeightRange(startHeight, stopHeight+1)
+	if err != nil {
+		return nil, err
+	}
+	hashPtrs := make([]*chainhash.Hash, len(hashes))
+	for i := range hashes {
+		hashPtrs[i] = &hashes[i]
+	}
+	return hashPtrs, nil
+}
+
+// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin message.
+// It responds with a cfilter message for every block of the requested range.
+func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
+	// Ignore getcfilters requests if not in sync or the index is not
+	// maintained.
+	if !sp.server.blockManager.IsCurrent() || sp.server.cfIndex == nil {
+		return
+	}
+	if msg.FilterType != wire.GCSFilterRegular {
+		peerLog.Debugf("Ignoring getcfilters with unsupported filter "+
+			"type %d from %v", msg.FilterType, sp)
+		return
+	}
+
+	hashes, err := sp.server.cfBlockHashes(msg.StartHeight, &msg.StopHash,
+		wire.MaxGetCFiltersReqRange)
+	if err != nil {
+		peerLog.Debugf("Invalid getcfilters request from %v: %v", sp,
+			err)
+		return
+	}
+	filters, err := sp.server.cfIndex.FiltersByBlockHashes(hashes)
+	if err != nil {
+		peerLog.Errorf("Error retrieving cfilters: %v", err)
+		return
+	}
+
+	for i, filter := range filters {
+		// The block may have been disconnected in the meantime.
+		if filter == nil {
+			peerLog.Debugf("No cfilter for block %v requested by %v",
+				hashes[i], sp)
+			return
+		}
+		sp.QueueMessage(wire.NewMsgCFilter(msg.FilterType, hashes[i],
+			filter), nil)
+	}
+}
+
+// OnGetCFHeaders is invoked when a peer receives a getcfheaders bitcoin
+// message.  It responds with the filter hashes of the requested range and the
+// filter header of the block before it.
+func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
+	// Ignore getcfheaders requests if not in sync or the index is not
+	// maintained.
+	if !sp.server.blockManager.IsCurrent() || sp.server.cfIndex == nil {
+		return
+	}
+	if msg.FilterType != wire.GCSFilterRegular {
+		peerLog.Debugf("Ignoring getcfheaders with unsupported filter "+
+			"type %d from %v", msg.FilterType, sp)
+		return
+	}
+
+	hashes, err := sp.server.cfBlockHashes(msg.StartHeight, &msg.StopHash,
+		wire.MaxCFHeadersPerMsg)
+	if err != nil {
+		peerLog.Debugf("Invalid getcfheaders request from %v: %v", sp,
+			err)
+		return
+	}
+
+	// The filter header of the genesis block commits to an all zero
+	// previous filter header.
+	cfHeaders := wire.NewMsgCFHeaders()
+	cfHeaders.FilterType = msg.FilterType
+	cfHeaders.StopHash = msg.StopHash
+	if msg.StartHeight > 0 {
+		chain := sp.server.blockManager.chain
+		prevHash, err := chain.BlockHashByHeight(msg.StartHeight - 1)
+		if err != nil {
+			peerLog.Errorf("Error retrieving block hash: %v", err)
+			return
+		}
+		prevHeader, err := sp.server.cfIndex.FilterHeaderByBlockHash(
+			prevHash)
+		if err != nil {
+			peerLog.Errorf("Error retrieving cfilter header: %v",
+				err)
+			return
+		}
+		if prevHeader == nil {
+			peerLog.Debugf("No cfilter header for block %v "+
+				"requested by %v", prevHash, sp)
+			return
+		}
+		copy(cfHeaders.PrevFilterHeader[:], prevHeader)
+	}
+
+	filterHashes, err := sp.server.cfIndex.FilterHashesByBlockHashes(hashes)
+	if err != nil {
+		peerLog.Errorf("Error retrieving cfilter hashes: %v", err)
+		return
+	}
+	for i, filterHash := range filterHashes {
+		if filterHash == nil {
+			peerLog.Debugf("No cfilter hash for block %v requested "+
+				"by %v", hashes[i], sp)
+			return
+		}
+		var hash chainhash.Hash
+		copy(hash[:], filterHash)
+		cfHeaders.AddCFHash(&hash)
+	}
+	sp.QueueMessage(cfHeaders, nil)
+}
+
+// OnGetCFCheckpt is invoked when a peer receives a getcfcheckpt bitcoin
+// message.  It responds with the filter headers of every
+// wire.CFCheckptInterval-th block up to the requested block.
+func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
+	// Ignore getcfcheckpt requests if not in sync or the index is not
+	// maintained.
+	if !sp.server.blockManager.IsCurrent() || sp.server.cfIndex == nil {
+		return
+	}
+	if msg.FilterType != wire.GCSFilterRegular {
+		peerLog.Debugf("Ignoring getcfcheckpt with unsupported filter "+
+			"type %d from %v", msg.FilterType, sp)
+		return
+	}
+
+	chain := sp.server.blockManager.chain
+	stopHeight, err := chain.BlockHeightByHash(&msg.StopHash)
+	if err != nil {
+		peerLog.Debugf("Invalid getcfcheckpt request from %v: stop "+
+			"block %v is not in the main chain", sp, msg.StopHash)
+		return
+	}
+
+	numCheckpts := int(stopHeight / wire.CFCheckptInterval)
+	hashes := make([]*chainhash.Hash, 0, numCheckpts)
+	for i := 1; i <= numCheckpts; i++ {
+		hash, err := chain.BlockHashByHeight(uint32(i *
+			wire.CFCheckptInterval))
+		if err != nil {
+			peerLog.Errorf("Error retrieving block hash: %v", err)
+			return
+		}
+		hashes = append(hashes, hash)
+	}
+	headers, err := sp.server.cfIndex.FilterHeadersByBlockHashes(hashes)
+	if err != nil {
+		peerLog.Errorf("Error retrieving cfilter headers: %v", err)
+		return
+	}
+
+	cfCheckpt := wire.NewMsgCFCheckpt(msg.FilterType, &msg.StopHash,
+		len(headers))
+	for i, header := range headers {
+		if header == nil {
+			peerLog.Debugf("No cfilter header for block %v "+
+				"requested by %v", hashes[i], sp)
+			return
+		}
+		var hash chainhash.Hash
+		copy(hash[:], header)
+		cfCheckpt.AddCFHeader(&hash)
+	}
+	sp.QueueMessage(cfCheckpt, nil)
+}
+
 // enforceNodeBloomFlag disconnects the peer if the server is not configured to
 // allow bloom filters.  Additionally, if the peer has negotiated to a protocol
 // version  that is high enough to observe the bloom filter service support bit,
@@ -785,11 +1421,12 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 		// to ensure the violation is logged and the peer is
 		// disconnected regardless.
 		if sp.ProtocolVersion() >= wire.BIP0111Version &&
-			!cfg.DisableBanning {
+			!sp.server.currentPeerPolicy().disableBanning {
 
 			// Disonnect the peer regardless of whether it was
 			// banned.
-			sp.addBanScore(100, 0, cmd)
+			sp.addBanScore(100, 0, misbehaviorUnsupported,
+				cmd+" request with bloom filtering disabled")
 			sp.Disconnect()
 			return false
 		}
@@ -919,6 +1556,25 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr bitcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	// Ignore old style addresses which don't include a timestamp.
+	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
+		return
+	}
+
+	sp.addAddresses(msg, msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to notify the server about advertised addresses, including those which
+// can not be relayed with addr messages such as Tor v3 addresses.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.addAddresses(msg, msg.AddrList)
+}
+
+// addAddresses adds the addresses advertised by the peer with the passed
+// addr or addrv2 message to the known addresses of the peer and the address
+// manager.
+func (sp *serverPeer) addAddresses(msg wire.Message, addrList []*wire.NetAddress) {
 	// Ignore addresses when running on the simulation test network.  This
 	// helps prevent the network from becoming another public test network
 	// since it will not be able to learn about other peers that have not
@@ -927,20 +1583,15 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
-	// Ignore old style addresses which don't include a timestamp.
-	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
-		return
-	}
-
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
 			msg.Command(), sp)
 		sp.Disconnect()
 		return
 	}
 
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
@@ -963,7 +1614,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX bitcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrList, sp.NA())
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
@@ -1040,6 +1691,19 @@ func (s *server) AnnounceNewTransactions(newTxs []*mempool.TxDesc) {
 			s.rpcServer.gbtWorkState.NotifyMempoolTx(
 				s.txMemPool.LastUpdated())
 		}
+
+		// Notify gRPC streaming clients about mempool transactions.
+		if s.grpcServer != nil {
+			s.grpcServer.NotifyMempoolTx(txD.Tx)
+		}
+
+		// Record the mempool transactions of the built-in wallet.
+		if s.wallet != nil {
+			if err := s.wallet.AddUnconfirmed(txD.Tx.MsgTx()); err != nil {
+				srvrLog.Errorf("Unable to record transaction %v "+
+					"in the wallet: %v", txD.Tx.Hash(), err)
+			}
+		}
 	}
 }
 
@@ -1134,26 +1798,76 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
-// pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
-// the connected peer.  Since a merkle block requires the peer to have a filter
-// loaded, this call will simply be ignored if there is no filter loaded.  An
-// error is returned if the block hash is not known.
-func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{}, waitChan <-chan struct{}) error {
-	// Do not send a response if the peer doesn't have a filter loaded.
-	if !sp.filter.IsLoaded() {
-		if doneChan != nil {
-			doneChan <- struct{}{}
-		}
-		return nil
+// fetchMsgBlock fetches the block with the provided hash from the database.
+func (s *server) fetchMsgBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	var blockBytes []byte
+	err := s.db.View(func(dbTx database.Tx) error {
+		var err error
+		blockBytes, err = dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Fetch the raw block bytes from the database.
-	blk, err := sp.server.blockManager.chain.BlockByHash(hash)
+	var msgBlock wire.MsgBlock
+	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
 	if err != nil {
-		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
-			hash, err)
-
-		if doneChan != nil {
+		return nil, err
+	}
+	return &msgBlock, nil
+}
+
+// pushCmpctBlockMsg sends a cmpctblock message for the provided block hash to
+// the connected peer.  An error is returned if the block hash is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+	msgBlock, err := s.fetchMsgBlock(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessage(wire.NewMsgCmpctBlock(msgBlock, nonce), doneChan)
+	return nil
+}
+
+// pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
+// the connected peer.  Since a merkle block requires the peer to have a filter
+// loaded, this call will simply be ignored if there is no filter loaded.  An
+// error is returned if the block hash is not known.
+func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+	// Do not send a response if the peer doesn't have a filter loaded.
+	if !sp.filter.IsLoaded() {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return nil
+	}
+
+	// Fetch the raw block bytes from the database.
+	blk, err := sp.server.blockManager.chain.BlockByHash(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
 			doneChan <- struct{}{}
 		}
 		return err
@@ -1241,22 +1955,20 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, banEnd.Sub(time.Now()))
+	if !sp.isWhitelisted && !sp.isFederationMember() {
+		if ban := s.banManager.IsBanned(net.ParseIP(host)); ban != nil {
+			srvrLog.Debugf("Peer %s is banned for another %v - "+
+				"disconnecting", host, ban.Until.Sub(time.Now()))
 			sp.Disconnect()
 			return false
 		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  Fellow federation members are
+	// always accepted.
+	if state.Count() >= cfg.MaxPeers && !sp.isFederationMember() {
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1267,10 +1979,15 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 
 	// Add the new peer and start it.
 	srvrLog.Debugf("New peer %s", sp)
+	if sp.isFederationMember() {
+		withLogFields(srvrLog, logFields{"peer": sp.Addr()}).Infof(
+			"Authenticated federation member %s (%s)",
+			sp.fedMember, sp)
+	}
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1294,7 +2011,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 		if !sp.Inbound() && sp.connReq != nil {
 			s.connManager.Disconnect(sp.connReq.ID())
@@ -1320,36 +2037,80 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 
 // handleBanPeerMsg deals with banning peers.  It is invoked from the
 // peerHandler goroutine.
-func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
+func (s *server) handleBanPeerMsg(state *peerState, bmsg banPeerMsg) {
+	sp := bmsg.sp
 	host, _, err := net.SplitHostPort(sp.Addr())
 	if err != nil {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	subnet, err := connmgr.ParseSubnet(host)
+	if err != nil {
+		srvrLog.Debugf("can't ban peer %s: %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	banDuration := s.currentPeerPolicy().banDuration
+	withLogFields(srvrLog, logFields{"peer": sp.Addr()}).Infof(
+		"Banned peer %s (%s) for %v: reason=%s", host, direction,
+		banDuration, bmsg.reason)
+	err = s.banManager.Ban(subnet, time.Now().Add(banDuration),
+		connmgr.BanReasonMisbehaving)
+	if err != nil {
+		srvrLog.Errorf("Failed to save ban of %s: %v", host, err)
+	}
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
-	state.forAllPeers(func(sp *serverPeer) {
+	// The compact block of a block is only created once and pushed to all
+	// peers which want compact blocks.
+	var cmpctBlock *wire.MsgCmpctBlock
+	relayToPeer := func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// If the inventory is a block and the peer wants compact blocks,
+		// push the block as a compact block unless the peer already
+		// knows it.
+		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsCompactBlocks() {
+			if sp.KnowsInventory(msg.invVect) {
+				return
+			}
+			block, ok := msg.data.(*provautil.Block)
+			if !ok {
+				peerLog.Warnf("Underlying data for compact block" +
+					" is not a block")
+				return
+			}
+			if cmpctBlock == nil {
+				nonce, err := wire.RandomUint64()
+				if err != nil {
+					peerLog.Errorf("Failed to generate compact "+
+						"block nonce: %v", err)
+					return
+				}
+				cmpctBlock = wire.NewMsgCmpctBlock(block.MsgBlock(),
+					nonce)
+			}
+			sp.AddKnownInventory(msg.invVect)
+			sp.QueueMessage(cmpctBlock, nil)
+			return
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
 		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
-			blockHeader, ok := msg.data.(wire.BlockHeader)
+			block, ok := msg.data.(*provautil.Block)
 			if !ok {
 				peerLog.Warnf("Underlying data for headers" +
-					" is not a block header")
+					" is not a block")
 				return
 			}
+			blockHeader := block.MsgBlock().Header
 			msgHeaders := wire.NewMsgHeaders()
 			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
 				peerLog.Errorf("Failed to add block"+
@@ -1395,6 +2156,19 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 		// It will be ignored if the peer is already known to
 		// have the inventory.
 		sp.QueueInventory(msg.invVect)
+	}
+
+	// Relay to fellow federation members before any other peers so the
+	// validators learn about new blocks as soon as possible.
+	state.forAllPeers(func(sp *serverPeer) {
+		if sp.isFederationMember() {
+			relayToPeer(sp)
+		}
+	})
+	state.forAllPeers(func(sp *serverPeer) {
+		if !sp.isFederationMember() {
+			relayToPeer(sp)
+		}
 	})
 }
 
@@ -1411,6 +2185,9 @@ func (s *server) handleBroadcastMsg(state *peerState, bmsg *broadcastMsg) {
 				return
 			}
 		}
+		if sp.ProtocolVersion() < bmsg.minProtocolVersion {
+			return
+		}
 
 		sp.QueueMessage(bmsg.message, nil)
 	})
@@ -1506,7 +2283,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1542,7 +2319,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1550,7 +2327,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -1587,24 +2364,43 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
+	// Whitelisted peers and fellow federation members are trusted not to
+	// flood, so the rate of their messages is not limited.
+	var msgLimits map[string]peer.MessageLimit
+	if !sp.isWhitelisted && !sp.isFederationMember() {
+		msgLimits = cfg.msgLimits
+	}
+
 	return &peer.Config{
 		Listeners: peer.MessageListeners{
-			OnVersion:     sp.OnVersion,
-			OnMemPool:     sp.OnMemPool,
-			OnTx:          sp.OnTx,
-			OnBlock:       sp.OnBlock,
-			OnInv:         sp.OnInv,
-			OnGetData:     sp.OnGetData,
-			OnGetBlocks:   sp.OnGetBlocks,
-			OnGetHeaders:  sp.OnGetHeaders,
-			OnFeeFilter:   sp.OnFeeFilter,
-			OnFilterAdd:   sp.OnFilterAdd,
-			OnFilterClear: sp.OnFilterClear,
-			OnFilterLoad:  sp.OnFilterLoad,
-			OnGetAddr:     sp.OnGetAddr,
-			OnAddr:        sp.OnAddr,
-			OnRead:        sp.OnRead,
-			OnWrite:       sp.OnWrite,
+			OnVersion:      sp.OnVersion,
+			OnVerAck:       sp.OnVerAck,
+			OnMemPool:      sp.OnMemPool,
+			OnTx:           sp.OnTx,
+			OnBlock:        sp.OnBlock,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnHeartbeat:    sp.OnHeartbeat,
+			OnBlockTxn:     sp.OnBlockTxn,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnHeaders:      sp.OnHeaders,
+			OnInv:          sp.OnInv,
+			OnGetData:      sp.OnGetData,
+			OnGetBlocks:    sp.OnGetBlocks,
+			OnGetHeaders:   sp.OnGetHeaders,
+			OnGetCFilters:  sp.OnGetCFilters,
+			OnGetCFHeaders: sp.OnGetCFHeaders,
+			OnGetCFCheckpt: sp.OnGetCFCheckpt,
+			OnFeeFilter:    sp.OnFeeFilter,
+			OnFilterAdd:    sp.OnFilterAdd,
+			OnFilterClear:  sp.OnFilterClear,
+			OnFilterLoad:   sp.OnFilterLoad,
+			OnGetAddr:      sp.OnGetAddr,
+			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
+			OnRead:         sp.OnRead,
+			OnWrite:        sp.OnWrite,
+
+			OnMessageLimited: sp.OnMessageLimited,
 
 			// Note: The reference client currently bans peers that send alerts
 			// not signed with its key.  We could verify against their key, but
@@ -1620,17 +2416,108 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		ChainParams:      sp.server.chainParams,
 		Services:         sp.server.services,
 		DisableRelayTx:   cfg.BlocksOnly,
-		ProtocolVersion:  wire.FeeFilterVersion,
+		ProtocolVersion:  wire.HeartbeatVersion,
+		MessageLimits:    msgLimits,
 	}
 }
 
+// peerRateLimiters returns the limiters of the rate data is received from and
+// sent to the passed peer.  They are limited by the limits of the class of the
+// peer, inbound or outbound, and by the global limits.  Fellow federation
+// members are only limited by the global limits.
+func (s *server) peerRateLimiters(sp *serverPeer, inbound bool) (*peer.RateLimiter, *peer.RateLimiter) {
+	var downloadRate, uploadRate uint32
+	switch {
+	case sp.isFederationMember():
+	case inbound:
+		downloadRate = cfg.InboundDownloadRate
+		uploadRate = cfg.InboundUploadRate
+	default:
+		downloadRate = cfg.OutboundDownloadRate
+		uploadRate = cfg.OutboundUploadRate
+	}
+	return peer.NewRateLimiter(int64(downloadRate)*1000, s.downloadLimiter),
+		peer.NewRateLimiter(int64(uploadRate)*1000, s.uploadLimiter)
+}
+
+// peerPolicy holds the options which decide which peers are whitelisted and
+// how misbehaving peers are banned.  It is replaced as a whole when the
+// configuration is reloaded, so it can be used without holding a lock once
+// obtained with currentPeerPolicy.
+type peerPolicy struct {
+	disableBanning   bool
+	banDuration      time.Duration
+	banThreshold     uint32
+	banWarnThreshold uint32
+	msgLimitBanScore uint32
+	whitelists       []*net.IPNet
+}
+
+// newPeerPolicy returns the peer policy set by the passed configuration.
+func newPeerPolicy(cfg *config) *peerPolicy {
+	return &peerPolicy{
+		disableBanning:   cfg.DisableBanning,
+		banDuration:      cfg.BanDuration,
+		banThreshold:     cfg.BanThreshold,
+		banWarnThreshold: cfg.BanWarnThreshold,
+		msgLimitBanScore: cfg.MsgLimitBanScore,
+		whitelists:       cfg.whitelists,
+	}
+}
+
+// currentPeerPolicy returns the peer policy of the server.
+//
+// This function is safe for concurrent access.
+func (s *server) currentPeerPolicy() *peerPolicy {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+	return s.peerPolicy
+}
+
+// isWhitelisted returns whether the IP address of the passed remote address is
+// included in the whitelisted networks and IPs.
+func (p *peerPolicy) isWhitelisted(addr net.Addr) bool {
+	if len(p.whitelists) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		srvrLog.Warnf("Unable to SplitHostPort on '%s': %v", addr, err)
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		srvrLog.Warnf("Unable to parse IP '%s'", addr)
+		return false
+	}
+
+	for _, ipnet := range p.whitelists {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // inboundPeerConnected is invoked by the connection manager when a new inbound
 // connection is established.  It initializes a new inbound server peer
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
+	fedMember, err := federationConnMember(conn)
+	if err != nil {
+		srvrLog.Warnf("Federation handshake with %s failed: %v",
+			conn.RemoteAddr(), err)
+		return
+	}
+
 	sp := newServerPeer(s, false)
-	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
+	sp.fedMember = fedMember
+	sp.isWhitelisted = s.currentPeerPolicy().isWhitelisted(conn.RemoteAddr())
+	peerCfg := newPeerConfig(sp)
+	peerCfg.ReadLimiter, peerCfg.WriteLimiter = s.peerRateLimiters(sp, true)
+	sp.Peer = peer.NewInboundPeer(peerCfg)
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 }
@@ -1641,8 +2528,20 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // request instance and the connection itself, and finally notifies the address
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	fedMember, err := federationConnMember(conn)
+	if err != nil {
+		srvrLog.Debugf("Federation handshake with %s failed: %v",
+			c.Addr, err)
+		s.connManager.Disconnect(c.ID())
+		return
+	}
+
 	sp := newServerPeer(s, c.Permanent)
-	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
+	sp.fedMember = fedMember
+	sp.isWhitelisted = s.currentPeerPolicy().isWhitelisted(conn.RemoteAddr())
+	peerCfg := newPeerConfig(sp)
+	peerCfg.ReadLimiter, peerCfg.WriteLimiter = s.peerRateLimiters(sp, false)
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		s.connManager.Disconnect(c.ID())
@@ -1661,7 +2560,7 @@ func (s *server) peerDoneHandler(sp *serverPeer) {
 	s.donePeers <- sp
 
 	// Only tell block manager we are gone if we ever told it we existed.
-	if sp.VersionKnown() {
+	if sp.VersionKnown() && !sp.feeler {
 		s.blockManager.DonePeer(sp)
 
 		// Evict any remaining orphans that were sent by the peer.
@@ -1675,6 +2574,113 @@ func (s *server) peerDoneHandler(sp *serverPeer) {
 	close(sp.quit)
 }
 
+// saveAnchors saves the addresses of the longest connected outbound peers as
+// anchors, which are connected to first at next start.  It is invoked from the
+// peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	if cfg.SimNet || len(cfg.ConnectPeers) != 0 || cfg.ReadOnly {
+		return
+	}
+
+	peers := make([]*serverPeer, 0, len(state.outboundPeers))
+	for _, sp := range state.outboundPeers {
+		if sp.Connected() && sp.VerAckReceived() {
+			peers = append(peers, sp)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].TimeConnected().Before(peers[j].TimeConnected())
+	})
+	if len(peers) > maxAnchors {
+		peers = peers[:maxAnchors]
+	}
+
+	anchors := make([]*wire.NetAddress, 0, len(peers))
+	for _, sp := range peers {
+		anchors = append(anchors, sp.NA())
+	}
+	if err := s.addrManager.SaveAnchors(anchors); err != nil {
+		srvrLog.Errorf("Unable to save anchors: %v", err)
+		return
+	}
+	srvrLog.Debugf("Saved %d anchors", len(anchors))
+}
+
+// feelerConnect makes a feeler connection to an address which was never
+// connected to.  Feeler connections are disconnected once the version of the
+// remote peer is received, and move reachable addresses to the tried table.
+// This keeps the tried table filled with fresh addresses, which makes it
+// harder for an attacker to fill the address tables with stale or malicious
+// addresses.
+func (s *server) feelerConnect() {
+	for tries := 0; tries < 100; tries++ {
+		ka := s.addrManager.GetNewAddress()
+		if ka == nil {
+			return
+		}
+
+		// Only test addresses which are not in the group of an outbound
+		// peer, and have not been tried recently.
+		na := ka.NetAddress()
+		if s.OutboundGroupCount(s.addrManager.GroupKey(na)) != 0 ||
+			time.Since(ka.LastAttempt()) < 10*time.Minute {
+
+			continue
+		}
+		addr, err := addrStringToNetAddr(addrmgr.NetAddressKey(na))
+		if err != nil {
+			continue
+		}
+
+		s.addrManager.Attempt(na)
+		srvrLog.Debugf("Making feeler connection to %s", addr)
+		conn, err := btcdDial(addr)
+		if err != nil {
+			srvrLog.Debugf("Feeler connection to %s failed: %v", addr,
+				err)
+			return
+		}
+
+		sp := newServerPeer(s, false)
+		sp.feeler = true
+		peerCfg := newPeerConfig(sp)
+		peerCfg.ReadLimiter, peerCfg.WriteLimiter =
+			s.peerRateLimiters(sp, false)
+		p, err := peer.NewOutboundPeer(peerCfg, addr.String())
+		if err != nil {
+			srvrLog.Debugf("Cannot create feeler peer %s: %v", addr,
+				err)
+			conn.Close()
+			return
+		}
+		sp.Peer = p
+		sp.AssociateConnection(conn)
+		go s.peerDoneHandler(sp)
+		return
+	}
+}
+
+// feelerHandler periodically makes feeler connections.  It must be run as a
+// goroutine.
+func (s *server) feelerHandler() {
+	ticker := time.NewTicker(feelerInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			s.feelerConnect()
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+	srvrLog.Tracef("Feeler handler done")
+}
+
 // peerHandler is used to handle peer operations such as adding and removing
 // peers to and from the server, banning peers, and broadcasting messages to
 // peers.  It must be run in a goroutine.
@@ -1684,7 +2690,13 @@ func (s *server) peerHandler() {
 	// to this handler and rather than adding more channels to sychronize
 	// things, it's easier and slightly faster to simply start and stop them
 	// in this handler.
-	s.addrManager.Start()
+	//
+	// The address manager is not started when the node runs with
+	// --readonly, since it has no peers and would write the known addresses
+	// to the data directory.
+	if !cfg.ReadOnly {
+		s.addrManager.Start()
+	}
 	s.blockManager.Start()
 
 	srvrLog.Tracef("Starting peer handler")
@@ -1693,13 +2705,13 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
 	if !cfg.DisableDNSSeed {
 		// Add peers discovered through DNS to the address manager.
-		connmgr.SeedFromDNS(activeNetParams.Params, defaultRequiredServices,
+		connmgr.SeedFromDNSSeeds(cfg.dnsSeeds,
+			activeNetParams.DefaultPort, cfg.dnsSeedServices,
 			btcdLookup, func(addrs []*wire.NetAddress) {
 				// Bitcoind uses a lookup of the dns seeder here. This
 				// is rather strange since the values looked up by the
@@ -1727,8 +2739,8 @@ out:
 			s.handleUpdatePeerHeights(state, umsg)
 
 		// Peer to ban.
-		case p := <-s.banPeers:
-			s.handleBanPeerMsg(state, p)
+		case bmsg := <-s.banPeers:
+			s.handleBanPeerMsg(state, bmsg)
 
 		// New inventory to potentially be relayed to other peers.
 		case invMsg := <-s.relayInv:
@@ -1743,7 +2755,9 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
-			// Disconnect all peers on server shutdown.
+			// Save the anchors before disconnecting all peers on
+			// server shutdown.
+			s.saveAnchors(state)
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
 				sp.Disconnect()
@@ -1753,6 +2767,9 @@ out:
 	}
 
 	s.connManager.Stop()
+	if cfg.i2pSession != nil {
+		cfg.i2pSession.Close()
+	}
 	s.blockManager.Stop()
 	s.addrManager.Stop()
 
@@ -1781,8 +2798,8 @@ func (s *server) AddPeer(sp *serverPeer) {
 }
 
 // BanPeer bans a peer that has already been connected to the server by ip.
-func (s *server) BanPeer(sp *serverPeer) {
-	s.banPeers <- sp
+func (s *server) BanPeer(sp *serverPeer, reason string) {
+	s.banPeers <- banPeerMsg{sp: sp, reason: reason}
 }
 
 // RelayInventory relays the passed inventory vector to all connected peers
@@ -1800,6 +2817,43 @@ func (s *server) BroadcastMessage(msg wire.Message, exclPeers ...*serverPeer) {
 	s.broadcast <- bmsg
 }
 
+// relayHeartbeat relays the passed heartbeat to all connected peers supporting
+// heartbeats, except the peer it was received from, which is nil for the
+// heartbeats of this node.
+func (s *server) relayHeartbeat(msg *wire.MsgHeartbeat, from *serverPeer) {
+	bmsg := broadcastMsg{
+		message:            msg,
+		minProtocolVersion: wire.HeartbeatVersion,
+	}
+	if from != nil {
+		bmsg.excludePeers = []*serverPeer{from}
+	}
+	s.broadcast <- bmsg
+}
+
+// heartbeatKeys returns the validate keys to send heartbeats for, which are
+// none while a member of a validator cluster stands by, as the heartbeats tell
+// the other members which member is signing blocks.
+func (s *server) heartbeatKeys() []btcec.Signer {
+	if s.cluster != nil && !s.cluster.Active() {
+		return nil
+	}
+	return s.cpuMiner.ValidateKeys()
+}
+
+// clusterActivated announces that this node took over signing blocks to the
+// other members of its validator cluster, and requests the memory pools of
+// the fellow federation members so pending transactions relayed to the
+// previous signer are included in the blocks of this node.
+func (s *server) clusterActivated() {
+	s.heartbeatManager.sendHeartbeats()
+	for _, sp := range s.Peers() {
+		if sp.Connected() && sp.isFederationMember() {
+			sp.QueueMessage(wire.NewMsgMemPool(), nil)
+		}
+	}
+}
+
 // ConnectedCount returns the number of currently connected peers.
 func (s *server) ConnectedCount() int32 {
 	replyChan := make(chan int32)
@@ -1834,6 +2888,30 @@ func (s *server) Peers() []*serverPeer {
 	return <-replyChan
 }
 
+// BanSubnet bans the passed subnet until the passed time and disconnects the
+// connected peers within it, except for whitelisted peers and fellow
+// federation members.
+func (s *server) BanSubnet(subnet *net.IPNet, until time.Time) error {
+	err := s.banManager.Ban(subnet, until, connmgr.BanReasonManual)
+	if err != nil {
+		return err
+	}
+	for _, sp := range s.Peers() {
+		if sp.isWhitelisted || sp.isFederationMember() {
+			continue
+		}
+		host, _, err := net.SplitHostPort(sp.Addr())
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && subnet.Contains(ip) {
+			srvrLog.Infof("Disconnecting banned peer %s", sp)
+			sp.Disconnect()
+		}
+	}
+	return nil
+}
+
 // DisconnectNodeByAddr disconnects a peer by target address. Both outbound and
 // inbound nodes will be searched for the target node. An error message will
 // be returned if the peer was not found.
@@ -1997,6 +3075,11 @@ func (s *server) Start() {
 
 	srvrLog.Trace("Starting server")
 
+	// Restore the transactions of the mempool saved on shutdown.
+	if !cfg.NoPersistMempool && !cfg.ReadOnly {
+		s.loadMempool()
+	}
+
 	// Start the peer handler which in turn starts the address and block
 	// managers.
 	s.wg.Add(1)
@@ -2007,6 +3090,19 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	// Reload the configuration on the reload signals of the platform.
+	if len(reloadSignals) > 0 {
+		s.wg.Add(1)
+		go s.reloadHandler()
+	}
+
+	// Feeler connections are only made when connecting to addresses of
+	// the address manager.
+	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 && !cfg.ReadOnly {
+		s.wg.Add(1)
+		go s.feelerHandler()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2015,17 +3111,42 @@ func (s *server) Start() {
 		go s.rebroadcastHandler()
 
 		s.rpcServer.Start()
+		if s.grpcServer != nil {
+			s.grpcServer.Start()
+		}
 	}
 
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+	s.consistencyChecker.Start()
+	s.heartbeatManager.Start()
+	if s.cluster != nil {
+		s.cluster.Start()
+	}
+	if s.alertMonitor != nil {
+		s.alertMonitor.Start()
+	}
+
+	if s.dnsSeeder != nil {
+		for _, conn := range s.dnsSeederConns {
+			s.dnsSeeder.Serve(conn)
+		}
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
-// peers and the main listener.
+// peers and the main listener.  The CPU miner and the RPC calls in progress
+// are waited for until the shutdown deadline set by --shutdowntimeout.
 func (s *server) Stop() error {
+	// The deadline is set by the first call, before the shutdown starts, so
+	// it is known to WaitForShutdown.
+	if cfg.ShutdownTimeout > 0 {
+		deadline := time.Now().Add(cfg.ShutdownTimeout).UnixNano()
+		atomic.CompareAndSwapInt64(&s.shutdownDeadline, 0, deadline)
+	}
+
 	// Make sure this only happens once.
 	if atomic.AddInt32(&s.shutdown, 1) != 1 {
 		srvrLog.Infof("Server is already in the process of shutting down")
@@ -2033,13 +3154,51 @@ func (s *server) Stop() error {
 	}
 
 	srvrLog.Warnf("Server shutting down")
+	deadline := s.deadline()
+
+	// Stop the CPU miner if needed, which abandons the block template it
+	// is working on.
+	shutdownStage("the CPU miner", deadline, s.cpuMiner.Stop, nil)
+
+	// Stop the consistency checker, aborting any check in progress.
+	shutdownStage("the consistency checker", deadline,
+		s.consistencyChecker.Stop, nil)
+
+	// Stop sending heartbeats before the validate key signers are released.
+	s.heartbeatManager.Stop()
+	if s.cluster != nil {
+		s.cluster.Stop()
+	}
 
-	// Stop the CPU miner if needed
-	s.cpuMiner.Stop()
+	// Stop checking for anomalies and sending alerts.
+	if s.alertMonitor != nil {
+		s.alertMonitor.Stop()
+	}
+
+	// Stop answering DNS seed queries.
+	if s.dnsSeeder != nil {
+		s.dnsSeeder.Stop()
+	}
 
-	// Shutdown the RPC server if it's not disabled.
+	// Release the key signers once the CPU miner is stopped.
+	s.keySigners.Close()
+
+	// Stop catching up and dropping the optional indexes in the background.
+	shutdownStage("the index manager", deadline, s.indexManager.Stop, nil)
+
+	// Shutdown the RPC server if it's not disabled.  The calls in progress
+	// are finished first, while the peers are still connected.
 	if !cfg.DisableRPC {
-		s.rpcServer.Stop()
+		if s.grpcServer != nil {
+			shutdownStage("the gRPC server", deadline,
+				s.grpcServer.Stop, nil)
+		}
+		shutdownStage("the RPC server", deadline, func() {
+			s.rpcServer.Stop()
+		}, func() string {
+			return "calls in progress: " + strings.Join(
+				s.rpcServer.metrics.activeCalls(), ", ")
+		})
 	}
 
 	// Signal the remaining goroutines to quit.
@@ -2047,9 +3206,93 @@ func (s *server) Stop() error {
 	return nil
 }
 
-// WaitForShutdown blocks until the main listener and peer handlers are stopped.
+// deadline returns the shutdown deadline, which is zero when the shutdown
+// waits indefinitely.
+func (s *server) deadline() time.Time {
+	deadline := atomic.LoadInt64(&s.shutdownDeadline)
+	if deadline == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, deadline)
+}
+
+// WaitForShutdown blocks until the main listener and peer handlers are
+// stopped, or the shutdown deadline passes, and then saves the mempool.
 func (s *server) WaitForShutdown() {
-	s.wg.Wait()
+	shutdownStage("the peers and the block manager", s.deadline(),
+		s.wg.Wait, nil)
+
+	if !cfg.NoPersistMempool && !cfg.ReadOnly {
+		if err := s.saveMempool(); err != nil {
+			srvrLog.Errorf("Unable to save the mempool: %v", err)
+		}
+	}
+
+	if s.screening != nil {
+		if err := s.screening.Close(); err != nil {
+			srvrLog.Errorf("Unable to close the screening audit "+
+				"trail: %v", err)
+		}
+	}
+
+	if s.auditLog != nil {
+		if err := s.auditLog.Close(); err != nil {
+			srvrLog.Errorf("Unable to close the audit log: %v", err)
+		}
+	}
+}
+
+// auditBlockSigned records the passed block, which was signed with a validate
+// key of the node, in the audit log when it is enabled.
+func (s *server) auditBlockSigned(block *provautil.Block) {
+	if s.auditLog == nil {
+		return
+	}
+	header := &block.MsgBlock().Header
+	err := s.auditLog.Record(auditlog.BlockSigning, "signblock",
+		map[string]string{
+			"hash":        block.Hash().String(),
+			"height":      strconv.FormatUint(uint64(header.Height), 10),
+			"validatekey": header.ValidatingPubKey.String(),
+		})
+	if err != nil {
+		srvrLog.Errorf("Failed to record the signing of block %v in the "+
+			"audit log: %v", block.Hash(), err)
+	}
+}
+
+// newScreeningHook returns the hook screening transactions with the screeners
+// configured with --screenlist and --screenurl, or nil when neither is set.
+// The decisions are recorded in the screening audit trail of the data
+// directory.
+func newScreeningHook(cfg *config, chainParams *chaincfg.Params) (*screening.Hook, error) {
+	var screeners []screening.Screener
+	if cfg.ScreenList != "" {
+		list, err := screening.NewListScreener(cfg.ScreenList,
+			chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the screening "+
+				"list: %v", err)
+		}
+		screeners = append(screeners, list)
+	}
+	if cfg.ScreenURL != "" {
+		screeners = append(screeners, screening.NewHTTPScreener(
+			cfg.ScreenURL, cfg.ScreenTimeout))
+	}
+	if len(screeners) == 0 {
+		return nil, nil
+	}
+
+	auditFile := filepath.Join(cfg.DataDir, screeningAuditFilename)
+	srvrLog.Infof("Screening transactions (audit trail %s)", auditFile)
+	return screening.New(&screening.Config{
+		Screeners:   screeners,
+		FailClosed:  cfg.ScreenFailClosed,
+		CacheTTL:    cfg.ScreenCacheTTL,
+		AuditFile:   auditFile,
+		ChainParams: chainParams,
+	})
 }
 
 // ScheduleShutdown schedules a server shutdown after the specified duration.
@@ -2204,8 +3447,22 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
 	}
+	if cfg.CfIndex {
+		services |= wire.SFNodeCF
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
+	if cfg.ASMap != "" {
+		asMap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, err
+		}
+		amgr.SetASMap(asMap)
+		srvrLog.Infof("Loaded %d subnets from AS map %s", asMap.Len(),
+			cfg.ASMap)
+	}
+	banManager := connmgr.NewBanManager(filepath.Join(cfg.DataDir,
+		"banlist.json"))
 
 	var listeners []net.Listener
 	var nat NAT
@@ -2331,12 +3588,67 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// Listen for and connect to fellow federation members over
+	// authenticated connections when configured.
+	dial := btcdDial
+	var federationAddrs []net.Addr
+	if cfg.FederationCert != "" {
+		tlsConfig, err := federationTLSConfig(cfg.FederationCert,
+			cfg.FederationKey, cfg.FederationMembers)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range cfg.FederationListeners {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			listeners = append(listeners, tls.NewListener(listener,
+				tlsConfig))
+		}
+
+		addrs := make(map[string]struct{})
+		for _, addr := range cfg.FederationPeers {
+			netAddr, err := addrStringToNetAddr(addr)
+			if err != nil {
+				return nil, err
+			}
+			addrs[netAddr.String()] = struct{}{}
+			federationAddrs = append(federationAddrs, netAddr)
+		}
+		dial = federationDial(btcdDial, tlsConfig, addrs)
+	}
+
+	// Accept connections from I2P peers through the SAM bridge and
+	// advertise the address of the I2P destination when configured.
+	if cfg.I2PListen {
+		srvrLog.Infof("Creating I2P session through SAM bridge %s",
+			cfg.I2PSAM)
+		listener, err := cfg.i2pSession.Listen()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create I2P session: %v",
+				err)
+		}
+		listeners = append(listeners, listener)
+
+		host, _, _ := net.SplitHostPort(listener.Addr().String())
+		na, err := amgr.HostToNetAddress(host, 0, services)
+		if err != nil {
+			return nil, err
+		}
+		if err := amgr.AddLocalAddress(na, addrmgr.ManualPrio); err != nil {
+			srvrLog.Warnf("Skipping I2P address %s: %v", host, err)
+		}
+	}
+
 	s := server{
 		chainParams:          chainParams,
 		addrManager:          amgr,
+		banManager:           banManager,
 		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
-		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
+		banPeers:             make(chan banPeerMsg, cfg.MaxPeers),
 		query:                make(chan interface{}),
 		relayInv:             make(chan relayMsg, cfg.MaxPeers),
 		broadcast:            make(chan broadcastMsg, cfg.MaxPeers),
@@ -2348,7 +3660,17 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
-		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
+		hashCache:            txscript.NewHashCache(cfg.HashCacheMaxSize),
+		uploadLimiter:        peer.NewRateLimiter(int64(cfg.MaxUploadRate)*1000, nil),
+		downloadLimiter:      peer.NewRateLimiter(int64(cfg.MaxDownloadRate)*1000, nil),
+		peerPolicy:           newPeerPolicy(cfg),
+		loadedCfg:            cfg,
+	}
+
+	// Allow the time to be controlled through the setmocktime RPC on the
+	// test networks which support generating blocks.
+	if chainParams.GenerateSupported {
+		s.timeSource = blockchain.NewMockableTime(s.timeSource)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2369,26 +3691,100 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			indxLog.Info("Transaction index is enabled")
 		}
 
-		s.txIndex = indexers.NewTxIndex(db)
-		indexes = append(indexes, s.txIndex)
+		s.optIndexes.txIndex = indexers.NewTxIndex(db)
+		indexes = append(indexes, s.optIndexes.txIndex)
 	}
 	if cfg.AddrIndex {
 		indxLog.Info("Address index is enabled")
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.CfIndex {
+		indxLog.Info("Committed filter index is enabled")
+		s.cfIndex = indexers.NewCfIndex(db)
+		indexes = append(indexes, s.cfIndex)
+	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent index is enabled")
+		s.optIndexes.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.optIndexes.spentIndex)
+	}
+	if cfg.KeyIDBalIndex {
+		indxLog.Info("KeyID balance index is enabled")
+		s.optIndexes.keyIDBalanceIndex = indexers.NewKeyIDBalanceIndex(db)
+		indexes = append(indexes, s.optIndexes.keyIDBalanceIndex)
+	}
+	if cfg.SupplyIndex {
+		indxLog.Info("Supply index is enabled")
+		s.optIndexes.supplyIndex = indexers.NewSupplyIndex(db)
+		indexes = append(indexes, s.optIndexes.supplyIndex)
+	}
+	if cfg.TimestampIndex {
+		indxLog.Info("Timestamp index is enabled")
+		s.optIndexes.timestampIndex = indexers.NewTimestampIndex(db)
+		indexes = append(indexes, s.optIndexes.timestampIndex)
+	}
+	if cfg.StatsIndex {
+		indxLog.Info("Daily statistics index is enabled")
+		s.optIndexes.statsIndex = indexers.NewStatsIndex(db)
+		indexes = append(indexes, s.optIndexes.statsIndex)
+	}
+	if cfg.UtxoCommitIndex {
+		indxLog.Info("Utxo commitment index is enabled")
+		s.optIndexes.utxoCommitIndex = indexers.NewUtxoCommitIndex(db)
+		indexes = append(indexes, s.optIndexes.utxoCommitIndex)
+	}
 
-	// Create an index manager if any of the optional indexes are enabled.
-	var indexManager blockchain.IndexManager
-	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+	if cfg.EventLog {
+		srvrLog.Infof("Event log is enabled (keeping %d events)",
+			cfg.EventLogSize)
+		eventLog, err := eventlog.New(&eventlog.Config{
+			DB:        db,
+			MaxEvents: cfg.EventLogSize,
+			Notify: func(e *eventlog.Event) {
+				if s.rpcServer != nil {
+					s.rpcServer.ntfnMgr.NotifyEvent(e)
+				}
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.eventLog = eventLog
 	}
-	bm, err := newBlockManager(&s, indexManager)
+
+	if cfg.AuditLog {
+		auditLog, err := auditlog.New(&auditlog.Config{
+			Path: filepath.Join(cfg.DataDir, auditLogFilename),
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.auditLog = auditLog
+	}
+
+	// Create an index manager for the optional indexes.  It is created even
+	// when none of them is enabled so they can be enabled at runtime.  The
+	// indexes of a database opened read-only are served as they are.
+	if cfg.ReadOnly {
+		s.indexManager = indexers.NewReadOnlyManager(db, indexes)
+	} else {
+		s.indexManager = indexers.NewManager(db, indexes)
+	}
+	bm, err := newBlockManager(&s, s.indexManager)
 	if err != nil {
 		return nil, err
 	}
 	s.blockManager = bm
 
+	if len(cfg.velocityLimits) > 0 {
+		s.velocity = mempool.NewVelocityTracker(cfg.velocityLimits)
+	}
+	screeningHook, err := newScreeningHook(cfg, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	s.screening = screeningHook
 	txC := mempool.Config{
 		Policy: mempool.Policy{
 			DisableRelayPriority: !cfg.RelayPriority,
@@ -2406,6 +3802,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		LastKeyID:       bm.chain.LastKeyID,
 		TotalSupply:     bm.chain.TotalSupply,
 		GetKeyIDs:       bm.chain.KeyIDs,
+		GetFrozenKeyIDs: bm.chain.FrozenKeyIDs,
 		GetAdminKeySets: bm.chain.AdminKeySets,
 		BestHeight:      func() uint32 { return bm.chain.BestSnapshot().Height },
 		MedianTimePast:  func() time.Time { return bm.chain.BestSnapshot().MedianTime },
@@ -2413,12 +3810,22 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		HashCache:       s.hashCache,
 		TimeSource:      s.timeSource,
 		AddrIndex:       s.addrIndex,
+		EventLog:        s.eventLog,
+		Velocity:        s.velocity,
+		Screening:       s.screening,
 		CalcSequenceLock: func(tx *provautil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
 			return bm.chain.CalcSequenceLock(tx, view, true)
 		},
 	}
 	s.txMemPool = mempool.New(&txC)
 
+	// Check the block database for the damage an unclean shutdown can leave
+	// behind before the chain is used.  This happens once the mempool exists
+	// since repairs reconnect blocks, which notifies the block manager.
+	if err := checkChainIntegrity(bm.chain); err != nil {
+		return nil, err
+	}
+
 	// Create the mining policy and block template generator based on the
 	// configuration options.
 	//
@@ -2433,17 +3840,154 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy, s.chainParams,
 		s.txMemPool, s.blockManager.chain, s.timeSource, s.sigCache, s.hashCache)
+	if s.screening != nil {
+		blockTemplateGenerator.SetScreening(s.screening)
+	}
+	if !cfg.NoSignGuard {
+		signGuard, err := signguard.New(filepath.Join(cfg.DataDir,
+			signGuardFilename))
+		if err != nil {
+			return nil, err
+		}
+		blockTemplateGenerator.SetSignGuard(signGuard)
+		s.signGuard = signGuard
+	}
+	s.blockTemplateGenerator = blockTemplateGenerator
+
+	// Members of a validator cluster only sign blocks while no other member
+	// holding the same validate keys does.
+	var canSign func(uint32) bool
+	if cfg.ClusterRole != "" {
+		s.cluster = newClusterManager(&clusterConfig{
+			Role:    cfg.ClusterRole,
+			Lockout: cfg.ClusterLockout,
+			LocalKeys: func() []btcec.Signer {
+				return s.cpuMiner.ValidateKeys()
+			},
+			BestHeight: func() uint32 {
+				return bm.chain.BestSnapshot().Height
+			},
+			Activated: s.clusterActivated,
+		})
+		canSign = s.cluster.CanSign
+	}
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
-		ChainParams:              chainParams,
-		BlockTemplateGenerator:   blockTemplateGenerator,
-		MiningAddrs:              cfg.miningAddrs,
-		ProcessBlock:             bm.ProcessBlock,
-		ConnectedCount:           s.ConnectedCount,
-		IsCurrent:                bm.IsCurrent,
-		IsValidateKeyRateLimited: bm.chain.IsValidateKeyRateLimited,
-		AdminKeySets:             bm.chain.AdminKeySets,
+		ChainParams:            chainParams,
+		BlockTemplateGenerator: blockTemplateGenerator,
+		MiningAddrs:            cfg.miningAddrs,
+		ProcessBlock:           bm.ProcessBlock,
+		ConnectedCount:         s.ConnectedCount,
+		IsCurrent:              bm.IsCurrent,
+		BlocksUntilEligible:    bm.chain.BlocksUntilEligible,
+		AdminKeySets:           bm.chain.AdminKeySets,
+		BlockSigned:            s.auditBlockSigned,
+		CanSign:                canSign,
+	})
+
+	// Sign generated blocks with the validate keys, and the transactions
+	// created by the admin RPCs with the admin keys, held by a hardware
+	// security module or a remote signing service when configured.
+	ks, err := loadKeySigners(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks.validate) > 0 {
+		s.cpuMiner.SetValidateKeys(ks.validate)
+	}
+	addAdminSigners(cfg, ks.admin)
+	s.keySigners = ks
+
+	s.consistencyChecker = newConsistencyChecker(bm.chain,
+		cfg.ConsistencyInterval, cfg.ConsistencyHalt)
+
+	s.heartbeatManager = newHeartbeatManager(&heartbeatConfig{
+		Interval: cfg.HeartbeatInterval,
+		ValidateKeys: func() btcec.PublicKeySet {
+			return bm.chain.AdminKeySets()[btcec.ValidateKeySet]
+		},
+		LocalKeys:    s.heartbeatKeys,
+		BestSnapshot: bm.chain.BestSnapshot,
+		Relay:        s.relayHeartbeat,
+	})
+
+	s.broadcasts = newBroadcastTracker(&broadcastTrackerConfig{
+		BestHeight: func() uint32 {
+			return bm.chain.BestSnapshot().Height
+		},
+		Finished: func(status *btcjson.BroadcastStatusResult) {
+			srvrLog.Infof("Broadcast campaign %d for transaction %v "+
+				"is %s", status.ID, status.TxID, status.Status)
+			if s.rpcServer != nil {
+				s.rpcServer.ntfnMgr.NotifyBroadcastFinished(status)
+			}
+		},
 	})
 
+	s.adminProposals, err = newAdminProposals(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Raise alerts about anomalies of the node when alert targets are
+	// configured.
+	alertTargets, err := newAlertTargets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(alertTargets) > 0 {
+		noBlock := cfg.AlertNoBlock
+		if noBlock == 0 {
+			noBlock = defaultAlertNoBlockFactor *
+				s.chainParams.TargetTimePerBlock
+		}
+		s.alertMonitor = newAlertMonitor(&alertMonitorConfig{
+			Alerts: alert.New(&alert.Config{
+				Targets:       alertTargets,
+				DedupInterval: cfg.AlertDedup,
+				RateLimit:     cfg.AlertRateLimit,
+			}),
+			Chain:        bm.chain,
+			IndexTips:    s.indexManager.IndexTips,
+			MempoolCount: s.txMemPool.Count,
+			IsCurrent:    bm.IsCurrent,
+			NoBlock:      noBlock,
+			ReorgDepth:   cfg.AlertReorgDepth,
+			MempoolTxs:   cfg.AlertMempoolTxs,
+			IndexLag:     cfg.AlertIndexLag,
+		})
+	}
+
+	// Load the built-in wallet, which catches up with the blocks connected
+	// since it was last synced, before the block manager starts notifying
+	// it of new blocks.
+	if cfg.walletKey != nil {
+		s.wallet, err = newWallet(&s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Act as a DNS seed for the configured zone by answering queries with
+	// the good addresses known to the address manager.
+	if cfg.DNSSeeder != "" {
+		port, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		s.dnsSeeder = connmgr.NewDNSSeeder(&connmgr.SeederConfig{
+			Zone:      cfg.DNSSeeder,
+			Port:      uint16(port),
+			Addresses: amgr.GoodAddresses,
+		})
+		for _, addr := range cfg.DNSSeederListeners {
+			conn, err := net.ListenPacket("udp", addr)
+			if err != nil {
+				return nil, err
+			}
+			s.dnsSeederConns = append(s.dnsSeederConns, conn)
+		}
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation network is always
 	// in connect-only mode since it is only intended to connect to
@@ -2451,7 +3995,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// discovered peers in order to prevent it from becoming a public test
 	// network.
 	var newAddressFunc func() (net.Addr, error)
-	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
+	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 && !cfg.ReadOnly {
 		newAddressFunc = func() (net.Addr, error) {
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
@@ -2465,7 +4009,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
@@ -2500,7 +4044,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		OnAccept:       s.inboundPeerConnected,
 		RetryDuration:  connectionRetryInterval,
 		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
+		Dial:           dial,
 		OnConnection:   s.outboundPeerConnected,
 		GetNewAddress:  newAddressFunc,
 	})
@@ -2525,6 +4069,27 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			Permanent: true,
 		})
 	}
+	for _, netAddr := range federationAddrs {
+		go s.connManager.Connect(&connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		})
+	}
+
+	// Connect to the anchors saved on the last shutdown first, so the
+	// outbound peers which were known to be good are kept across restarts.
+	if newAddressFunc != nil {
+		for _, na := range s.addrManager.LoadAnchors() {
+			netAddr, err := addrStringToNetAddr(addrmgr.NetAddressKey(na))
+			if err != nil {
+				srvrLog.Warnf("Skipping anchor %s: %v",
+					addrmgr.NetAddressKey(na), err)
+				continue
+			}
+			srvrLog.Infof("Connecting to anchor %s", netAddr)
+			go s.connManager.Connect(&connmgr.ConnReq{Addr: netAddr})
+		}
+	}
 
 	if !cfg.DisableRPC {
 		s.rpcServer, err = newRPCServer(cfg.RPCListeners,
@@ -2533,6 +4098,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			return nil, err
 		}
 
+		if len(cfg.GRPCListeners) > 0 {
+			s.grpcServer, err = newGRPCServer(cfg.GRPCListeners,
+				s.rpcServer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// Signal process shutdown when the RPC server requests it.
 		go func() {
 			<-s.rpcServer.RequestedProcessShutdown()
@@ -2576,6 +4149,12 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		return &onionAddr{addr: addr}, nil
 	}
 
+	// I2P addresses are reached through the I2P router, so they are not
+	// resolved either.
+	if addrmgr.HostNetwork(host) == addrmgr.NetworkI2P {
+		return &connmgr.I2PAddr{Host: host}, nil
+	}
+
 	// Attempt to look up an IP address associated with the parsed host.
 	ips, err := btcdLookup(host)
 	if err != nil {
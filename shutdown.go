@@ -0,0 +1,123 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// mempoolFilename is the name of the file in the data directory the
+	// transaction memory pool is saved to on shutdown.
+	mempoolFilename = "mempool.dat"
+
+	// shutdownProgressInterval is the interval at which the subsystems which
+	// are still stopping are logged on shutdown.
+	shutdownProgressInterval = time.Second * 5
+)
+
+// shutdownStage calls the passed function, which stops the named subsystem,
+// and logs the progress of the shutdown until it returns.  The optional
+// progress function describes what the subsystem is still waiting for.  When
+// the passed deadline passes first, the subsystem is given up on and false is
+// returned, so the shutdown can go on to save the state.  A zero deadline
+// waits indefinitely.
+func shutdownStage(name string, deadline time.Time, stop func(),
+	progress func() string) bool {
+
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(deadline.Sub(time.Now()))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(shutdownProgressInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-done:
+			return true
+
+		case <-ticker.C:
+			elapsed := time.Since(start).Truncate(time.Second)
+			if progress == nil {
+				btcdLog.Infof("Waiting for %s to stop (%v)", name,
+					elapsed)
+				continue
+			}
+			btcdLog.Infof("Waiting for %s to stop (%v): %s", name,
+				elapsed, progress())
+
+		case <-timeout:
+			btcdLog.Errorf("Giving up on %s, which did not stop "+
+				"before the shutdown deadline", name)
+			return false
+		}
+	}
+}
+
+// loadMempool restores the transactions saved in the mempool file on the last
+// shutdown and removes the file, so the transactions are not restored again
+// after an unclean shutdown, when they may have been mined long ago.
+func (s *server) loadMempool() {
+	path := filepath.Join(cfg.DataDir, mempoolFilename)
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Errorf("Unable to open the mempool file: %v", err)
+		}
+		return
+	}
+	loaded, err := s.txMemPool.Load(f)
+	f.Close()
+	if err != nil {
+		srvrLog.Errorf("Unable to load the mempool file %s: %v", path,
+			err)
+	} else {
+		srvrLog.Infof("Restored %d transactions to the mempool", loaded)
+	}
+	if err := os.Remove(path); err != nil {
+		srvrLog.Errorf("Unable to remove the mempool file: %v", err)
+	}
+}
+
+// saveMempool saves the transactions of the mempool to the mempool file so
+// they are restored on the next start up.
+func (s *server) saveMempool() error {
+	// Write to a temporary file first so a partially written file is
+	// never loaded.
+	path := filepath.Join(cfg.DataDir, mempoolFilename)
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	saved, err := s.txMemPool.Save(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	srvrLog.Infof("Saved %d mempool transactions", saved)
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestShutdownStage ensures shutdown stages are waited for until they stop or
+// the shutdown deadline passes.
+func TestShutdownStage(t *testing.T) {
+	stopped := false
+	if !shutdownStage("test", time.Time{}, func() { stopped = true }, nil) {
+		t.Fatalf("shutdownStage: stage without deadline given up on")
+	}
+	if !stopped {
+		t.Fatalf("shutdownStage: returned before the stage stopped")
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	deadline := time.Now().Add(50 * time.Millisecond)
+	if shutdownStage("test", deadline, func() { <-block }, nil) {
+		t.Fatalf("shutdownStage: blocked stage reported as stopped")
+	}
+	if time.Now().Before(deadline) {
+		t.Fatalf("shutdownStage: gave up before the deadline")
+	}
+
+	// Stages started after the deadline are given up on right away.
+	if shutdownStage("test", deadline, func() { <-block }, nil) {
+		t.Fatalf("shutdownStage: blocked stage reported as stopped")
+	}
+}
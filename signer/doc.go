@@ -0,0 +1,48 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package signer provides signers for keys which are kept outside of the process
+memory, such as the validate keys signing blocks and the admin keys signing
+admin thread transactions.
+
+Overview
+
+Block headers and transaction inputs are signed through the btcec.Signer
+interface, which an in-memory btcec.PrivateKey implements.  This package
+provides two further implementations so the keys never have to be loaded into
+the node:
+
+ - PKCS11Module signs with keys held by a hardware security module through
+   its PKCS#11 library
+ - RemoteClient signs with keys held by a remote signing service, which is
+   reached over TLS with mutual authentication
+
+PKCS#11
+
+Access to PKCS#11 libraries requires cgo and the github.com/miekg/pkcs11
+package, so it is only compiled in when building with the pkcs11 build tag:
+
+  go build -tags pkcs11
+
+Without the tag, OpenPKCS11 returns ErrPKCS11Unsupported.  Keys are looked up
+by the label of their private key object, and must be secp256k1 keys which
+have a public key object with the same label.  Signatures produced by the
+module are normalized to a low S value.
+
+Remote Signing Service
+
+A remote signing service exposes the Signer service of this package through the
+Go net/rpc protocol on a TLS listener which requires client certificates.
+NewService returns the service for a set of signers, and Serve serves it on a
+listener.  The client verifies every signature it receives against the public
+key it requested it for before returning it, so a faulty or compromised
+service can not cause invalid signatures to be used.
+
+Signers returned by RemoteClient implement wire.HeaderSigner, so blocks are
+signed by sending the whole unsigned header to the service, which computes the
+signed hash itself and refuses headers with a timestamp too far in the future.
+The provasigner command runs the service on an isolated host.
+*/
+package signer
@@ -0,0 +1,197 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build pkcs11
+
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/miekg/pkcs11"
+)
+
+// secp256k1OID is the DER encoding of the object identifier of the secp256k1
+// curve, as found in the CKA_EC_PARAMS attribute of secp256k1 keys.
+var secp256k1OID = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// PKCS11Module provides access to the keys of a hardware security module
+// through its PKCS#11 library.  All operations share a single session, so
+// they are serialized.
+type PKCS11Module struct {
+	mtx     sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// OpenPKCS11 loads the PKCS#11 library described by the passed config, opens
+// a session with the token in the configured slot and logs in with the PIN.
+func OpenPKCS11(cfg *PKCS11Config) (*PKCS11Module, error) {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("unable to load PKCS#11 library %s",
+			cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+	session, err := ctx.OpenSession(cfg.Slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return &PKCS11Module{ctx: ctx, session: session}, nil
+}
+
+// findObject returns the single object of the passed class with the label.
+//
+// This function MUST be called with the module lock held.
+func (m *PKCS11Module) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := m.ctx.FindObjectsInit(m.session, template); err != nil {
+		return 0, err
+	}
+	objects, _, err := m.ctx.FindObjects(m.session, 2)
+	m.ctx.FindObjectsFinal(m.session)
+	if err != nil {
+		return 0, err
+	}
+	switch len(objects) {
+	case 0:
+		return 0, ErrKeyNotFound
+	case 1:
+		return objects[0], nil
+	}
+	return 0, fmt.Errorf("multiple keys labeled %q", label)
+}
+
+// publicKey returns the secp256k1 public key of the passed public key object.
+//
+// This function MUST be called with the module lock held.
+func (m *PKCS11Module) publicKey(object pkcs11.ObjectHandle) (*btcec.PublicKey, error) {
+	attrs, err := m.ctx.GetAttributeValue(m.session, object,
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+	if err != nil {
+		return nil, err
+	}
+	var params, point []byte
+	for _, attr := range attrs {
+		switch attr.Type {
+		case pkcs11.CKA_EC_PARAMS:
+			params = attr.Value
+		case pkcs11.CKA_EC_POINT:
+			point = attr.Value
+		}
+	}
+	if !bytes.Equal(params, secp256k1OID) {
+		return nil, errors.New("key is not a secp256k1 key")
+	}
+
+	// The point is the uncompressed encoding of the key wrapped in a DER
+	// octet string.
+	if len(point) != 2+btcec.PubKeyBytesLenUncompressed || point[0] != 0x04 ||
+		int(point[1]) != btcec.PubKeyBytesLenUncompressed {
+
+		return nil, errors.New("malformed public key point")
+	}
+	return btcec.ParsePubKey(point[2:], btcec.S256())
+}
+
+// Signer returns the signer for the key of the module with the passed label.
+func (m *PKCS11Module) Signer(label string) (btcec.Signer, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	privObject, err := m.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, fmt.Errorf("private key %q: %v", label, err)
+	}
+	pubObject, err := m.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, fmt.Errorf("public key %q: %v", label, err)
+	}
+	pubKey, err := m.publicKey(pubObject)
+	if err != nil {
+		return nil, fmt.Errorf("public key %q: %v", label, err)
+	}
+	return &pkcs11Signer{module: m, object: privObject, pubKey: pubKey}, nil
+}
+
+// sign returns the raw signature of the hash by the passed private key object.
+func (m *PKCS11Module) sign(object pkcs11.ObjectHandle, hash []byte) ([]byte, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA,
+		nil)}
+	if err := m.ctx.SignInit(m.session, mechanism, object); err != nil {
+		return nil, err
+	}
+	return m.ctx.Sign(m.session, hash)
+}
+
+// Close logs out of the token and unloads the PKCS#11 library.  The signers
+// of the module must not be used afterwards.
+func (m *PKCS11Module) Close() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.ctx.Logout(m.session)
+	m.ctx.CloseSession(m.session)
+	err := m.ctx.Finalize()
+	m.ctx.Destroy()
+	return err
+}
+
+// pkcs11Signer signs with a private key object of a PKCS#11 module.
+type pkcs11Signer struct {
+	module *PKCS11Module
+	object pkcs11.ObjectHandle
+	pubKey *btcec.PublicKey
+}
+
+// Enforce pkcs11Signer implements the btcec.Signer interface.
+var _ btcec.Signer = (*pkcs11Signer)(nil)
+
+// PubKey returns the public key of the signer.
+//
+// This is part of the btcec.Signer interface.
+func (s *pkcs11Signer) PubKey() *btcec.PublicKey {
+	return s.pubKey
+}
+
+// Sign returns the signature of the hash by the private key of the signer,
+// normalized to a low S value.
+//
+// This is part of the btcec.Signer interface.
+func (s *pkcs11Signer) Sign(hash []byte) (*btcec.Signature, error) {
+	raw, err := s.module.sign(s.object, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := parseRawSignature(raw)
+	if err != nil {
+		return nil, err
+	}
+	return verifiedSignature(sig, hash, s.pubKey)
+}
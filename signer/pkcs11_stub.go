@@ -0,0 +1,32 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !pkcs11
+
+package signer
+
+import (
+	"github.com/bitgo/prova/btcec"
+)
+
+// PKCS11Module provides access to the keys of a hardware security module.
+// This build lacks PKCS#11 support, so no module can be opened.
+type PKCS11Module struct{}
+
+// OpenPKCS11 always returns ErrPKCS11Unsupported since this build lacks
+// PKCS#11 support.
+func OpenPKCS11(cfg *PKCS11Config) (*PKCS11Module, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Signer always returns ErrPKCS11Unsupported since this build lacks PKCS#11
+// support.
+func (m *PKCS11Module) Signer(label string) (btcec.Signer, error) {
+	return nil, ErrPKCS11Unsupported
+}
+
+// Close does nothing since this build lacks PKCS#11 support.
+func (m *PKCS11Module) Close() error {
+	return nil
+}
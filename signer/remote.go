@@ -0,0 +1,371 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// serviceName is the name of the signing service registered with the
+	// RPC server.
+	serviceName = "Signer"
+
+	// remoteTimeout is the maximum duration of a call to a remote signing
+	// service.
+	remoteTimeout = 30 * time.Second
+
+	// maxHeaderTimeOffset is the maximum duration the timestamp of a block
+	// header signed by a signing service may be ahead of its clock.  This
+	// matches the limit of the consensus rules.
+	maxHeaderTimeOffset = 2 * time.Hour
+)
+
+// PubKeysArgs are the arguments of the PubKeys method of a signing service.
+type PubKeysArgs struct{}
+
+// PubKeysReply is the reply of the PubKeys method of a signing service.
+type PubKeysReply struct {
+	// PubKeys are the compressed public keys of the signers of the
+	// service.
+	PubKeys [][]byte
+}
+
+// SignArgs are the arguments of the Sign method of a signing service.
+type SignArgs struct {
+	// PubKey is the compressed public key of the signer to sign with.
+	PubKey []byte
+
+	// Hash is the hash to sign.
+	Hash []byte
+}
+
+// SignHeaderArgs are the arguments of the SignHeader method of a signing
+// service.
+type SignHeaderArgs struct {
+	// PubKey is the compressed public key of the signer to sign with.
+	PubKey []byte
+
+	// Header is the serialized block header to sign.  Its signature field
+	// is ignored.
+	Header []byte
+}
+
+// SignReply is the reply of the Sign and SignHeader methods of a signing
+// service.
+type SignReply struct {
+	// Signature is the DER encoded signature of the hash.
+	Signature []byte
+}
+
+// Service is a signing service which signs with a set of signers on behalf of
+// remote clients.
+type Service struct {
+	signers map[string]btcec.Signer
+	pubKeys [][]byte
+}
+
+// NewService returns a signing service for the passed signers.
+func NewService(signers ...btcec.Signer) *Service {
+	s := &Service{signers: make(map[string]btcec.Signer)}
+	for _, signer := range signers {
+		pubKey := signer.PubKey().SerializeCompressed()
+		s.signers[string(pubKey)] = signer
+		s.pubKeys = append(s.pubKeys, pubKey)
+	}
+	return s
+}
+
+// PubKeys returns the public keys of the signers of the service.
+func (s *Service) PubKeys(args *PubKeysArgs, reply *PubKeysReply) error {
+	reply.PubKeys = s.pubKeys
+	return nil
+}
+
+// Sign signs the hash with the signer of the requested public key.
+func (s *Service) Sign(args *SignArgs, reply *SignReply) error {
+	signer, ok := s.signers[string(args.PubKey)]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	sig, err := signer.Sign(args.Hash)
+	if err != nil {
+		return err
+	}
+	reply.Signature = sig.Serialize()
+	return nil
+}
+
+// SignHeader signs the block header with the signer of the requested public
+// key.  Unlike Sign, the service computes the signed hash itself, so it only
+// ever signs well-formed headers, and it refuses headers with a timestamp too
+// far ahead of its clock.
+func (s *Service) SignHeader(args *SignHeaderArgs, reply *SignReply) error {
+	signer, ok := s.signers[string(args.PubKey)]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(args.Header)); err != nil {
+		return fmt.Errorf("malformed block header: %v", err)
+	}
+	maxTimestamp := time.Now().Add(maxHeaderTimeOffset)
+	if header.Timestamp.After(maxTimestamp) {
+		return fmt.Errorf("block header timestamp %v is too far in the "+
+			"future", header.Timestamp)
+	}
+	if err := header.Sign(signer); err != nil {
+		return err
+	}
+	sig, err := btcec.ParseSignature(header.Signature[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+	reply.Signature = sig.Serialize()
+	return nil
+}
+
+// Serve serves the signing service on connections accepted from the listener,
+// which should be a TLS listener requiring client certificates, such as one
+// configured with ServerTLSConfig.  It blocks until the listener is closed.
+func Serve(listener net.Listener, service *Service) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName, service); err != nil {
+		return err
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// loadTLSConfig returns a TLS config with the passed certificate and key, and
+// the certificate authorities in the passed file.
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, *x509.CertPool, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, pool, nil
+}
+
+// ClientTLSConfig returns the TLS config of a client of a signing service,
+// which authenticates with the passed certificate and key, and accepts
+// services with certificates signed by the authorities in caFile.
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config, pool, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// ServerTLSConfig returns the TLS config of a signing service, which
+// authenticates with the passed certificate and key, and only accepts clients
+// with certificates signed by the authorities in caFile.
+func ServerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config, pool, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// RemoteClient is a client of a remote signing service.  The connection to the
+// service is established on first use and reestablished after it fails.
+type RemoteClient struct {
+	mtx       sync.Mutex
+	addr      string
+	tlsConfig *tls.Config
+	client    *rpc.Client
+}
+
+// NewRemoteClient returns a client of the signing service at the passed
+// address, which is reached with the TLS config, such as one returned by
+// ClientTLSConfig.
+func NewRemoteClient(addr string, tlsConfig *tls.Config) *RemoteClient {
+	return &RemoteClient{addr: addr, tlsConfig: tlsConfig}
+}
+
+// call calls the passed method of the signing service, connecting to it first
+// when needed.
+func (c *RemoteClient) call(method string, args, reply interface{}) error {
+	c.mtx.Lock()
+	if c.client == nil {
+		dialer := &net.Dialer{Timeout: remoteTimeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", c.addr, c.tlsConfig)
+		if err != nil {
+			c.mtx.Unlock()
+			return err
+		}
+		c.client = rpc.NewClient(conn)
+	}
+	client := c.client
+	c.mtx.Unlock()
+
+	var err error
+	call := client.Go(serviceName+"."+method, args, reply, nil)
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-time.After(remoteTimeout):
+		err = fmt.Errorf("signing service at %s timed out", c.addr)
+	}
+
+	// Drop the connection on failures of the connection rather than of the
+	// call itself, so it is reestablished by the next call.
+	if _, ok := err.(rpc.ServerError); err != nil && !ok {
+		c.mtx.Lock()
+		if c.client == client {
+			c.client = nil
+		}
+		c.mtx.Unlock()
+		client.Close()
+	}
+	return err
+}
+
+// PubKeys returns the public keys the signing service signs for.
+func (c *RemoteClient) PubKeys() ([]*btcec.PublicKey, error) {
+	var reply PubKeysReply
+	if err := c.call("PubKeys", &PubKeysArgs{}, &reply); err != nil {
+		return nil, err
+	}
+	pubKeys := make([]*btcec.PublicKey, 0, len(reply.PubKeys))
+	for _, serialized := range reply.PubKeys {
+		pubKey, err := btcec.ParsePubKey(serialized, btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys, nil
+}
+
+// Signer returns the signer for the passed public key of the signing service.
+func (c *RemoteClient) Signer(pubKey *btcec.PublicKey) (btcec.Signer, error) {
+	pubKeys, err := c.PubKeys()
+	if err != nil {
+		return nil, err
+	}
+	serialized := pubKey.SerializeCompressed()
+	for _, k := range pubKeys {
+		if bytes.Equal(k.SerializeCompressed(), serialized) {
+			return &remoteSigner{client: c, pubKey: pubKey}, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Close closes the connection to the signing service.
+func (c *RemoteClient) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// remoteSigner signs with a key of a remote signing service.
+type remoteSigner struct {
+	client *RemoteClient
+	pubKey *btcec.PublicKey
+}
+
+// Enforce remoteSigner implements the wire.HeaderSigner interface.
+var _ wire.HeaderSigner = (*remoteSigner)(nil)
+
+// PubKey returns the public key of the signer.
+//
+// This is part of the btcec.Signer interface.
+func (s *remoteSigner) PubKey() *btcec.PublicKey {
+	return s.pubKey
+}
+
+// Sign returns the signature of the hash by the signing service, after
+// ensuring it is valid for the public key of the signer.
+//
+// This is part of the btcec.Signer interface.
+func (s *remoteSigner) Sign(hash []byte) (*btcec.Signature, error) {
+	args := SignArgs{PubKey: s.pubKey.SerializeCompressed(), Hash: hash}
+	var reply SignReply
+	if err := s.client.call("Sign", &args, &reply); err != nil {
+		return nil, err
+	}
+	sig, err := btcec.ParseDERSignature(reply.Signature, btcec.S256())
+	if err != nil {
+		return nil, errors.New("signing service returned a malformed " +
+			"signature")
+	}
+	return verifiedSignature(sig, hash, s.pubKey)
+}
+
+// SignHeader sends the block header to the signing service and returns the
+// signature of its signing-hash, after ensuring it is valid for the public key
+// of the signer.
+//
+// This is part of the wire.HeaderSigner interface.
+func (s *remoteSigner) SignHeader(header *wire.BlockHeader) (*btcec.Signature, error) {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	args := SignHeaderArgs{
+		PubKey: s.pubKey.SerializeCompressed(),
+		Header: buf.Bytes(),
+	}
+	var reply SignReply
+	if err := s.client.call("SignHeader", &args, &reply); err != nil {
+		return nil, err
+	}
+	sig, err := btcec.ParseDERSignature(reply.Signature, btcec.S256())
+	if err != nil {
+		return nil, errors.New("signing service returned a malformed " +
+			"signature")
+	}
+
+	// Check the signature against a copy of the header, so the header is
+	// left untouched when it is invalid.
+	signed := *header
+	signed.Signature = wire.BlockSignature{}
+	copy(signed.Signature[:], reply.Signature)
+	if !signed.Verify(s.pubKey) {
+		return nil, ErrInvalidSignature
+	}
+	return sig, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// writeCertPair generates a certificate and key pair and writes them to the
+// passed directory with the passed name prefix.
+func writeCertPair(t *testing.T, dir, name string) (certFile, keyFile string) {
+	cert, key, err := provautil.NewTLSCertPair("prova signer test",
+		time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("NewTLSCertPair: unexpected error: %v", err)
+	}
+	certFile = filepath.Join(dir, name+".cert")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// TestRemoteSigner ensures a remote client signs through a signing service
+// over mutually authenticated TLS, and that the service rejects clients
+// without a trusted certificate.
+func TestRemoteSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert, serverKey := writeCertPair(t, dir, "server")
+	clientCert, clientKey := writeCertPair(t, dir, "client")
+	otherCert, otherKey := writeCertPair(t, dir, "other")
+
+	serverConfig, err := ServerTLSConfig(serverCert, serverKey, clientCert)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: unexpected error: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	go Serve(listener, NewService(privKey))
+
+	clientConfig, err := ClientTLSConfig(clientCert, clientKey, serverCert)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: unexpected error: %v", err)
+	}
+	client := NewRemoteClient(listener.Addr().String(), clientConfig)
+	defer client.Close()
+
+	signer, err := client.Signer(privKey.PubKey())
+	if err != nil {
+		t.Fatalf("Signer: unexpected error: %v", err)
+	}
+	hash := sha256.Sum256([]byte("remote"))
+	sig, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	if !sig.Verify(hash[:], privKey.PubKey()) {
+		t.Fatal("Sign: invalid signature")
+	}
+
+	// Block headers are sent to the service whole.
+	header := wire.BlockHeader{
+		Version:   1,
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Height:    1,
+	}
+	if err := header.Sign(signer); err != nil {
+		t.Fatalf("BlockHeader.Sign: unexpected error: %v", err)
+	}
+	if !header.Verify(privKey.PubKey()) {
+		t.Fatal("BlockHeader.Sign: invalid signature")
+	}
+	header.Timestamp = header.Timestamp.Add(3 * time.Hour)
+	if err := header.Sign(signer); err == nil {
+		t.Fatal("BlockHeader.Sign: future header signed")
+	}
+
+	otherPriv, _ := btcec.NewPrivateKey(btcec.S256())
+	if _, err := client.Signer(otherPriv.PubKey()); err != ErrKeyNotFound {
+		t.Fatalf("Signer: got error %v, want %v", err, ErrKeyNotFound)
+	}
+
+	// A client with an untrusted certificate is rejected.
+	otherConfig, err := ClientTLSConfig(otherCert, otherKey, serverCert)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: unexpected error: %v", err)
+	}
+	otherClient := NewRemoteClient(listener.Addr().String(), otherConfig)
+	defer otherClient.Close()
+	if _, err := otherClient.PubKeys(); err == nil {
+		t.Fatal("PubKeys: untrusted client accepted")
+	}
+}
+
+// TestParseRawSignature ensures raw signatures are parsed and normalized to a
+// low S value.
+func TestParseRawSignature(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	hash := sha256.Sum256([]byte("raw"))
+	sig, _ := privKey.Sign(hash[:])
+
+	// Encode the signature with the high S value.
+	highS := new(big.Int).Sub(btcec.S256().N, sig.S)
+	raw := make([]byte, 64)
+	rBytes, sBytes := sig.R.Bytes(), highS.Bytes()
+	copy(raw[32-len(rBytes):32], rBytes)
+	copy(raw[64-len(sBytes):], sBytes)
+
+	parsed, err := parseRawSignature(raw)
+	if err != nil {
+		t.Fatalf("parseRawSignature: unexpected error: %v", err)
+	}
+	if parsed.R.Cmp(sig.R) != 0 || parsed.S.Cmp(sig.S) != 0 {
+		t.Fatalf("parseRawSignature: got %v, want %v", parsed, sig)
+	}
+	if _, err := parseRawSignature(raw[1:]); err == nil {
+		t.Fatal("parseRawSignature: did not fail on short signature")
+	}
+}
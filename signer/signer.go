@@ -0,0 +1,71 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signer
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/bitgo/prova/btcec"
+)
+
+var (
+	// ErrPKCS11Unsupported describes an error where a PKCS#11 module is
+	// opened by a build without PKCS#11 support.
+	ErrPKCS11Unsupported = errors.New("built without PKCS#11 support, " +
+		"rebuild with the pkcs11 build tag")
+
+	// ErrKeyNotFound describes an error where a signer holds no key with
+	// the requested label or public key.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrInvalidSignature describes an error where a signer returns a
+	// signature which does not verify against its public key.
+	ErrInvalidSignature = errors.New("signer returned an invalid signature")
+)
+
+// PKCS11Config describes how to access the keys of a hardware security module.
+type PKCS11Config struct {
+	// Module is the path to the PKCS#11 library of the module.
+	Module string
+
+	// Slot is the slot holding the token with the keys.
+	Slot uint
+
+	// PIN is the user PIN of the token.
+	PIN string
+}
+
+// halfOrder is used to normalize signatures to a low S value.
+var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+// parseRawSignature parses a signature encoded as the 32-byte big-endian R
+// followed by the 32-byte big-endian S, as returned by PKCS#11 modules, and
+// normalizes it to a low S value.
+func parseRawSignature(raw []byte) (*btcec.Signature, error) {
+	if len(raw) != 64 {
+		return nil, errors.New("malformed raw signature: wrong size")
+	}
+	sig := &btcec.Signature{
+		R: new(big.Int).SetBytes(raw[:32]),
+		S: new(big.Int).SetBytes(raw[32:]),
+	}
+	if sig.R.Sign() == 0 || sig.S.Sign() == 0 {
+		return nil, errors.New("malformed raw signature: zero value")
+	}
+	if sig.S.Cmp(halfOrder) > 0 {
+		sig.S.Sub(btcec.S256().N, sig.S)
+	}
+	return sig, nil
+}
+
+// verifiedSignature returns the passed signature when it is a valid signature
+// of the hash by the public key, and ErrInvalidSignature otherwise.
+func verifiedSignature(sig *btcec.Signature, hash []byte, pubKey *btcec.PublicKey) (*btcec.Signature, error) {
+	if !sig.Verify(hash, pubKey) {
+		return nil, ErrInvalidSignature
+	}
+	return sig, nil
+}
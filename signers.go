@@ -0,0 +1,144 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/signer"
+)
+
+// keySigners houses the signers of the validate and admin keys held outside of
+// the node, along with the resources they require.
+type keySigners struct {
+	validate []btcec.Signer
+	admin    []btcec.Signer
+	module   *signer.PKCS11Module
+	remote   *signer.RemoteClient
+}
+
+// loadKeySigners returns the signers of the validate and admin keys held by the
+// hardware security module and the remote signing service configured by the
+// passed config.  The keys of the remote signing service are validate keys
+// unless they are configured as admin keys.  No signers are returned when
+// neither is configured.
+func loadKeySigners(cfg *config) (*keySigners, error) {
+	ks := &keySigners{}
+	if cfg.PKCS11Module != "" {
+		module, err := signer.OpenPKCS11(&signer.PKCS11Config{
+			Module: cfg.PKCS11Module,
+			Slot:   cfg.PKCS11Slot,
+			PIN:    cfg.PKCS11PIN,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to open PKCS#11 module: %v",
+				err)
+		}
+		ks.module = module
+		for _, label := range cfg.PKCS11Keys {
+			s, err := module.Signer(label)
+			if err != nil {
+				ks.Close()
+				return nil, err
+			}
+			srvrLog.Infof("Using validate key %x from PKCS#11 module",
+				s.PubKey().SerializeCompressed())
+			ks.validate = append(ks.validate, s)
+		}
+		for _, label := range cfg.PKCS11AdminKeys {
+			s, err := module.Signer(label)
+			if err != nil {
+				ks.Close()
+				return nil, err
+			}
+			srvrLog.Infof("Using admin key %x from PKCS#11 module",
+				s.PubKey().SerializeCompressed())
+			ks.admin = append(ks.admin, s)
+		}
+	}
+
+	if cfg.RemoteSigner != "" {
+		tlsConfig, err := signer.ClientTLSConfig(cfg.RemoteSignerCert,
+			cfg.RemoteSignerKey, cfg.RemoteSignerCA)
+		if err != nil {
+			ks.Close()
+			return nil, fmt.Errorf("unable to load remote signer TLS "+
+				"config: %v", err)
+		}
+		ks.remote = signer.NewRemoteClient(cfg.RemoteSigner, tlsConfig)
+		pubKeys, err := ks.remote.PubKeys()
+		if err != nil {
+			ks.Close()
+			return nil, fmt.Errorf("unable to query remote signer %s: %v",
+				cfg.RemoteSigner, err)
+		}
+		numAdmin := 0
+		for _, pubKey := range pubKeys {
+			s, err := ks.remote.Signer(pubKey)
+			if err != nil {
+				ks.Close()
+				return nil, err
+			}
+			serialized := pubKey.SerializeCompressed()
+			if _, ok := cfg.remoteAdminKeys[string(serialized)]; ok {
+				srvrLog.Infof("Using admin key %x from remote "+
+					"signer %s", serialized, cfg.RemoteSigner)
+				ks.admin = append(ks.admin, s)
+				numAdmin++
+				continue
+			}
+			srvrLog.Infof("Using validate key %x from remote signer %s",
+				serialized, cfg.RemoteSigner)
+			ks.validate = append(ks.validate, s)
+		}
+		if numAdmin != len(cfg.remoteAdminKeys) {
+			ks.Close()
+			return nil, fmt.Errorf("remote signer %s does not hold "+
+				"all of the admin keys configured with "+
+				"--remotesigneradminkey", cfg.RemoteSigner)
+		}
+	}
+	return ks, nil
+}
+
+// Close releases the hardware security module and the connection to the
+// remote signing service.
+func (ks *keySigners) Close() {
+	if ks.module != nil {
+		if err := ks.module.Close(); err != nil {
+			srvrLog.Warnf("Unable to close PKCS#11 module: %v", err)
+		}
+	}
+	if ks.remote != nil {
+		ks.remote.Close()
+	}
+}
+
+// addAdminSigners adds the passed signers to the admin keys the transactions
+// created by the admin RPCs are signed with, indexed by the hash160 of their
+// compressed public keys.
+func addAdminSigners(cfg *config, signers []btcec.Signer) {
+	if cfg.adminKeys == nil {
+		cfg.adminKeys = make(map[string]btcec.Signer, len(signers))
+	}
+	for _, s := range signers {
+		keyHash := provautil.Hash160(s.PubKey().SerializeCompressed())
+		cfg.adminKeys[string(keyHash)] = s
+	}
+}
+
+// signerPubKey returns the serialized public key of the passed signer which
+// hashes to the passed key hash.  That is the compressed form, unless the
+// signer was configured with a WIF-encoded key for an uncompressed public key.
+func signerPubKey(s btcec.Signer, keyHash []byte) []byte {
+	pubKey := s.PubKey().SerializeCompressed()
+	if !bytes.Equal(provautil.Hash160(pubKey), keyHash) {
+		pubKey = s.PubKey().SerializeUncompressed()
+	}
+	return pubKey
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/signer"
+)
+
+// TestLoadKeySigners ensures the keys of a remote signing service are used as
+// admin keys when configured as such and as validate keys otherwise, and that
+// the admin keys sign through the service.
+func TestLoadKeySigners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signers")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Serve a validate key and an admin key over mutual TLS.
+	var certFiles, keyFiles []string
+	for _, name := range []string{"server", "client"} {
+		cert, key, err := provautil.NewTLSCertPair("prova signers test",
+			time.Now().Add(time.Hour), nil)
+		if err != nil {
+			t.Fatalf("NewTLSCertPair: unexpected error: %v", err)
+		}
+		certFile := filepath.Join(dir, name+".cert")
+		keyFile := filepath.Join(dir, name+".key")
+		if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+		certFiles = append(certFiles, certFile)
+		keyFiles = append(keyFiles, keyFile)
+	}
+	serverConfig, err := signer.ServerTLSConfig(certFiles[0], keyFiles[0],
+		certFiles[1])
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: unexpected error: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer listener.Close()
+	validateKey, _ := btcec.NewPrivateKey(btcec.S256())
+	adminKey, _ := btcec.NewPrivateKey(btcec.S256())
+	go signer.Serve(listener, signer.NewService(validateKey, adminKey))
+
+	testCfg := &config{
+		RemoteSigner:     listener.Addr().String(),
+		RemoteSignerCert: certFiles[1],
+		RemoteSignerKey:  keyFiles[1],
+		RemoteSignerCA:   certFiles[0],
+		remoteAdminKeys: map[string]struct{}{
+			string(adminKey.PubKey().SerializeCompressed()): {},
+		},
+	}
+	ks, err := loadKeySigners(testCfg)
+	if err != nil {
+		t.Fatalf("loadKeySigners: unexpected error: %v", err)
+	}
+	defer ks.Close()
+	if len(ks.validate) != 1 || !ks.validate[0].PubKey().IsEqual(
+		validateKey.PubKey()) {
+
+		t.Fatalf("loadKeySigners: unexpected validate keys %v",
+			ks.validate)
+	}
+	if len(ks.admin) != 1 || !ks.admin[0].PubKey().IsEqual(
+		adminKey.PubKey()) {
+
+		t.Fatalf("loadKeySigners: unexpected admin keys %v", ks.admin)
+	}
+
+	// The admin key is indexed by the hash of its compressed public key
+	// and signs through the service.
+	addAdminSigners(testCfg, ks.admin)
+	keyHash := provautil.Hash160(adminKey.PubKey().SerializeCompressed())
+	adminSigner, ok := testCfg.adminKeys[string(keyHash)]
+	if !ok {
+		t.Fatal("addAdminSigners: admin key not indexed by its hash")
+	}
+	hash := sha256.Sum256([]byte("admin"))
+	sig, err := adminSigner.Sign(hash[:])
+	if err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	if !sig.Verify(hash[:], adminKey.PubKey()) {
+		t.Fatal("Sign: invalid signature")
+	}
+
+	// Admin keys the service does not hold are rejected.
+	otherKey, _ := btcec.NewPrivateKey(btcec.S256())
+	testCfg.remoteAdminKeys[string(otherKey.PubKey().SerializeCompressed())] =
+		struct{}{}
+	if ks, err := loadKeySigners(testCfg); err == nil {
+		ks.Close()
+		t.Fatal("loadKeySigners: missing admin key accepted")
+	}
+}
+
+// TestSignerPubKey ensures the public key of a signer is serialized in the form
+// which hashes to the key hash it is referenced by.
+func TestSignerPubKey(t *testing.T) {
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	compressed := privKey.PubKey().SerializeCompressed()
+	uncompressed := privKey.PubKey().SerializeUncompressed()
+	tests := []struct {
+		keyHash []byte
+		want    []byte
+	}{
+		{provautil.Hash160(compressed), compressed},
+		{provautil.Hash160(uncompressed), uncompressed},
+	}
+	for i, test := range tests {
+		got := signerPubKey(privKey, test.keyHash)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("signerPubKey #%d: got %x, want %x", i, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package signguard protects validate keys from signing two competing blocks.
+
+Overview
+
+A validate key which signs two different blocks at the same height, such as
+after the block database of a validator was restored from a backup or when a
+second node was started with the same keys by mistake, damages the standing
+of its operator with the federation.  A Guard keeps the height, timestamp and
+hash of the last block released by the node for each validate key in a file,
+and refuses to sign a header at the same or a lower height afterwards.
+
+Headers are signed repeatedly while a block is being solved, as their
+signature covers the timestamp which is updated meanwhile, so a height is only
+considered signed once a block at it is released.  The release is recorded and
+synced to disk before the block is processed and relayed, so a crash can not
+lose it.
+
+Recovery
+
+When the chain legitimately went back, such as after a block of the node was
+reorganized away, the record of a key can be reset to a lower height so the
+key signs blocks again.
+*/
+package signguard
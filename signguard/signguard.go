@@ -0,0 +1,217 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signguard
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// Record describes the last block released by the node for a validate key.
+type Record struct {
+	Height    uint32 `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Hash      string `json:"hash"`
+}
+
+// DoubleSignError is returned when a validate key is about to sign a header at
+// the height of a block it signed already, or below it.
+type DoubleSignError struct {
+	PubKey wire.BlockValidatingPubKey
+	Height uint32
+
+	// Signed is the last block released for the key.
+	Signed Record
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *DoubleSignError) Error() string {
+	return fmt.Sprintf("validate key %v already signed block %s at height "+
+		"%d, refusing to sign at height %d", e.PubKey, e.Signed.Hash,
+		e.Signed.Height, e.Height)
+}
+
+// Guard refuses to sign headers at the heights of blocks already signed by
+// the same validate key, keeping the last block signed by each key in a file.
+type Guard struct {
+	path string
+
+	mtx     sync.Mutex
+	records map[wire.BlockValidatingPubKey]Record
+}
+
+// New returns a guard keeping its records in the file at the passed path.  The
+// file is created once a block is recorded.
+func New(path string) (*Guard, error) {
+	g := &Guard{
+		path:    path,
+		records: make(map[wire.BlockValidatingPubKey]Record),
+	}
+	serialized, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records map[string]Record
+	if err := json.Unmarshal(serialized, &records); err != nil {
+		return nil, fmt.Errorf("signing guard file %s is invalid: %v",
+			path, err)
+	}
+	for encoded, record := range records {
+		var pubKey wire.BlockValidatingPubKey
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil || len(decoded) != len(pubKey) {
+			return nil, fmt.Errorf("signing guard file %s holds the "+
+				"invalid validate key %q", path, encoded)
+		}
+		copy(pubKey[:], decoded)
+		g.records[pubKey] = record
+	}
+	return g, nil
+}
+
+// save writes the records to the file, replacing it only once they are synced
+// to disk.  The guard must be locked.
+func (g *Guard) save() error {
+	records := make(map[string]Record, len(g.records))
+	for pubKey, record := range g.records {
+		records[pubKey.String()] = record
+	}
+	serialized, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := g.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(serialized); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, g.path)
+}
+
+// check returns a DoubleSignError when the passed validate key released a
+// block at the passed height or above.  The guard must be locked.
+func (g *Guard) check(pubKey wire.BlockValidatingPubKey, height uint32) error {
+	record, ok := g.records[pubKey]
+	if ok && height <= record.Height {
+		return &DoubleSignError{
+			PubKey: pubKey,
+			Height: height,
+			Signed: record,
+		}
+	}
+	return nil
+}
+
+// Check returns a DoubleSignError when the passed validate key may not sign a
+// header at the passed height, since it signed a block at it or above already.
+//
+// This function is safe for concurrent access.
+func (g *Guard) Check(pubKey wire.BlockValidatingPubKey, height uint32) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.check(pubKey, height)
+}
+
+// Record records that the block with the passed header, which is signed by its
+// validate key, is about to be released, and syncs the record to disk.  A
+// DoubleSignError is returned instead when the key signed a block at its
+// height or above already, in which case the block must not be released.
+//
+// This function is safe for concurrent access.
+func (g *Guard) Record(header *wire.BlockHeader) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	pubKey := header.ValidatingPubKey
+	if err := g.check(pubKey, header.Height); err != nil {
+		return err
+	}
+	previous, ok := g.records[pubKey]
+	g.records[pubKey] = Record{
+		Height:    header.Height,
+		Timestamp: header.Timestamp.Unix(),
+		Hash:      header.BlockHash().String(),
+	}
+	if err := g.save(); err != nil {
+		// The block is not released, so the previous record still
+		// applies.
+		if ok {
+			g.records[pubKey] = previous
+		} else {
+			delete(g.records, pubKey)
+		}
+		return err
+	}
+	return nil
+}
+
+// Records returns the last block released for each validate key.
+//
+// This function is safe for concurrent access.
+func (g *Guard) Records() map[wire.BlockValidatingPubKey]Record {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	records := make(map[wire.BlockValidatingPubKey]Record, len(g.records))
+	for pubKey, record := range g.records {
+		records[pubKey] = record
+	}
+	return records
+}
+
+// Reset overrides the record of the passed validate key so it signs headers
+// above the passed height again, or at any height when it is zero, and returns
+// the record it replaced, if any.  It is meant for recovery, such as after a
+// block of the key was reorganized away and the key has to sign another block
+// at its height.
+//
+// This function is safe for concurrent access.
+func (g *Guard) Reset(pubKey wire.BlockValidatingPubKey, height uint32) (*Record, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	previous, ok := g.records[pubKey]
+	if height == 0 {
+		delete(g.records, pubKey)
+	} else {
+		g.records[pubKey] = Record{Height: height}
+	}
+	if err := g.save(); err != nil {
+		if ok {
+			g.records[pubKey] = previous
+		} else {
+			delete(g.records, pubKey)
+		}
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &previous, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package signguard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// TestGuard ensures the guard refuses to sign at or below the height of the
+// last block released for a validate key, also after being reopened, and that
+// resetting the record of a key lets it sign again.
+func TestGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signguard")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "signguard.json")
+
+	g, err := New(path)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	key := wire.BlockValidatingPubKey{0x02, 0x01}
+	otherKey := wire.BlockValidatingPubKey{0x03, 0x02}
+	header := func(pubKey wire.BlockValidatingPubKey, height uint32, nonce uint64) *wire.BlockHeader {
+		return &wire.BlockHeader{
+			Height:           height,
+			Timestamp:        time.Unix(1500000000+int64(height), 0),
+			Nonce:            nonce,
+			ValidatingPubKey: pubKey,
+		}
+	}
+
+	if err := g.Check(key, 10); err != nil {
+		t.Fatalf("Check: unexpected error for a new key: %v", err)
+	}
+	signed := header(key, 10, 1)
+	if err := g.Record(signed); err != nil {
+		t.Fatalf("Record: unexpected error: %v", err)
+	}
+
+	// Competing blocks at the same or a lower height are refused, while
+	// other keys and greater heights are not concerned.
+	g, err = New(path)
+	if err != nil {
+		t.Fatalf("New: unexpected error reopening: %v", err)
+	}
+	for _, height := range []uint32{9, 10} {
+		err := g.Check(key, height)
+		dsErr, ok := err.(*DoubleSignError)
+		if !ok || dsErr.Signed.Height != 10 ||
+			dsErr.Signed.Hash != signed.BlockHash().String() {
+
+			t.Errorf("Check: got %v at height %d", err, height)
+		}
+	}
+	if err := g.Record(header(key, 10, 2)); err == nil {
+		t.Error("Record: competing block at the same height recorded")
+	}
+	if err := g.Check(key, 11); err != nil {
+		t.Errorf("Check: unexpected error at a greater height: %v", err)
+	}
+	if err := g.Check(otherKey, 10); err != nil {
+		t.Errorf("Check: unexpected error for another key: %v", err)
+	}
+
+	// Resetting the record lets the key sign above the passed height.
+	previous, err := g.Reset(key, 8)
+	if err != nil {
+		t.Fatalf("Reset: unexpected error: %v", err)
+	}
+	if previous == nil || previous.Height != 10 ||
+		previous.Timestamp != signed.Timestamp.Unix() {
+
+		t.Errorf("Reset: got previous record %+v", previous)
+	}
+	if err := g.Check(key, 8); err == nil {
+		t.Error("Check: no error at the reset height")
+	}
+	if err := g.Record(header(key, 9, 3)); err != nil {
+		t.Errorf("Record: unexpected error after reset: %v", err)
+	}
+	if _, err := g.Reset(key, 0); err != nil {
+		t.Fatalf("Reset: unexpected error: %v", err)
+	}
+	g, err = New(path)
+	if err != nil {
+		t.Fatalf("New: unexpected error reopening: %v", err)
+	}
+	if records := g.Records(); len(records) != 0 {
+		t.Errorf("Records: got %v after clearing the only record",
+			records)
+	}
+}
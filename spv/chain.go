@@ -0,0 +1,947 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package spv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// medianTimeBlocks is the number of previous blocks which are used to
+// calculate the median time of a block, as in the blockchain package.
+const medianTimeBlocks = 11
+
+// Config is a descriptor which specifies the light client chain instance
+// configuration.
+type Config struct {
+	// DB defines the database which houses the headers, the admin
+	// transactions and the data of the watched addresses.
+	//
+	// This field is required.
+	DB database.DB
+
+	// ChainParams identifies which chain parameters the chain is associated
+	// with.
+	//
+	// This field is required.
+	ChainParams *chaincfg.Params
+
+	// TimeSource defines the median time source to use for things such as
+	// header validation.
+	//
+	// This field is required.
+	TimeSource blockchain.MedianTimeSource
+
+	// Addresses are the addresses whose outputs and transactions are
+	// tracked.
+	Addresses []provautil.Address
+}
+
+// WatchedAddress is an address watched by the chain along with the height
+// from which it is watched.
+type WatchedAddress struct {
+	Address provautil.Address
+	Height  uint32
+}
+
+// watchedAddress houses a watched address along with its public key script
+// and the hash of the script keying its transactions in the database.
+type watchedAddress struct {
+	WatchedAddress
+	pkScript   []byte
+	scriptHash chainhash.Hash
+}
+
+// Credit is an output paying to a watched address.
+type Credit struct {
+	OutPoint wire.OutPoint
+	Height   uint32
+	Amount   int64
+	PkScript []byte
+
+	// SpentBy is the hash of the transaction spending the output and
+	// SpentHeight the height of its block, or nil while it is unspent.
+	SpentBy     *chainhash.Hash
+	SpentHeight uint32
+}
+
+// TxRef identifies a transaction of the main chain by its hash and the height
+// of its block.
+type TxRef struct {
+	Hash   chainhash.Hash
+	Height uint32
+}
+
+// Chain provides functions for a light client to validate the headers of a
+// Prova chain, verify its blocks against the validate key set and track the
+// watched addresses.
+type Chain struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+	timeSource  blockchain.MedianTimeSource
+
+	// watched and watchedScripts hold the watched addresses keyed by their
+	// encoding and their public key script respectively.
+	watched        map[string]*watchedAddress
+	watchedScripts map[string]*watchedAddress
+
+	// mtx protects the following fields.
+	//
+	// hashes holds the hashes of the headers of the main chain by height,
+	// and the blocks up to verified have been verified by ConnectBlock.
+	// keyView holds the admin key sets as of the verified block and credits
+	// the outputs paying to the watched addresses.  invalid holds the
+	// hashes of the blocks found to be signed by an invalid validate key.
+	mtx      sync.RWMutex
+	hashes   []chainhash.Hash
+	verified uint32
+	keyView  *blockchain.KeyViewpoint
+	credits  map[wire.OutPoint]*Credit
+	invalid  map[chainhash.Hash]struct{}
+}
+
+// ruleError creates a blockchain.RuleError given a set of arguments.
+func ruleError(c blockchain.ErrorCode, desc string) blockchain.RuleError {
+	return blockchain.RuleError{ErrorCode: c, Description: desc}
+}
+
+// New returns a light client chain instance using the provided configuration
+// details, loading its state from the database or initializing it with the
+// genesis block.
+func New(config *Config) (*Chain, error) {
+	if config.DB == nil {
+		return nil, errors.New("spv.New database is nil")
+	}
+	if config.ChainParams == nil {
+		return nil, errors.New("spv.New chain parameters nil")
+	}
+
+	c := &Chain{
+		db:             config.DB,
+		chainParams:    config.ChainParams,
+		timeSource:     config.TimeSource,
+		watched:        make(map[string]*watchedAddress),
+		watchedScripts: make(map[string]*watchedAddress),
+		invalid:        make(map[chainhash.Hash]struct{}),
+	}
+	for _, addr := range config.Addresses {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to watch address %v: %v",
+				addr, err)
+		}
+		w := &watchedAddress{
+			WatchedAddress: WatchedAddress{Address: addr},
+			pkScript:       pkScript,
+			scriptHash:     chainhash.DoubleHashH(pkScript),
+		}
+		c.watched[addr.EncodeAddress()] = w
+		c.watchedScripts[string(pkScript)] = w
+	}
+
+	err := c.db.Update(func(dbTx database.Tx) error {
+		return c.initChainState(dbTx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Light client chain state (height %d, hash %v, verified %d)",
+		len(c.hashes)-1, c.hashes[len(c.hashes)-1], c.verified)
+	return c, nil
+}
+
+// initChainState creates the buckets of the chain and stores the genesis
+// header when the database does not contain them yet, then loads the state of
+// the chain.
+func (c *Chain) initChainState(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	for _, name := range [][]byte{headersBucketName, heightIdxBucketName,
+		stateBucketName, adminBucketName, creditsBucketName,
+		addrTxsBucketName} {
+
+		if _, err := meta.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	if meta.Bucket(headersBucketName).Get(heightKey(0)) == nil {
+		genesis := &c.chainParams.GenesisBlock.Header
+		if hash := genesis.BlockHash(); hash != *c.chainParams.GenesisHash {
+			return fmt.Errorf("the genesis block hashes to %v "+
+				"instead of the genesis hash %v of the network",
+				hash, c.chainParams.GenesisHash)
+		}
+		err := dbPutHeader(dbTx, genesis, 0)
+		if err != nil {
+			return err
+		}
+		if err := dbPutHeight(dbTx, verifiedKeyName, 0); err != nil {
+			return err
+		}
+	}
+
+	// Load the hashes of the main chain.
+	cursor := meta.Bucket(headersBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		height := uint32(len(c.hashes))
+		header, err := dbFetchHeader(dbTx, height)
+		if err != nil {
+			return err
+		}
+		c.hashes = append(c.hashes, header.BlockHash())
+	}
+	if c.hashes[0] != *c.chainParams.GenesisHash {
+		return fmt.Errorf("the database holds the chain of genesis "+
+			"block %v instead of %v", c.hashes[0],
+			c.chainParams.GenesisHash)
+	}
+	verified, ok := dbFetchStateHeight(dbTx, verifiedKeyName)
+	if !ok || verified >= uint32(len(c.hashes)) {
+		return database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt verified height",
+		}
+	}
+	c.verified = verified
+
+	// Addresses which were not watched before are watched from the next
+	// block to verify.
+	for encoded, w := range c.watched {
+		key := append(append([]byte(nil), watchedKeyPrefix...), encoded...)
+		height, ok := dbFetchStateHeight(dbTx, key)
+		if !ok {
+			height = c.verified + 1
+			if err := dbPutHeight(dbTx, key, height); err != nil {
+				return err
+			}
+		}
+		w.Height = height
+	}
+
+	keyView, err := c.loadKeyView(dbTx)
+	if err != nil {
+		return err
+	}
+	credits, err := loadCredits(dbTx)
+	if err != nil {
+		return err
+	}
+	c.keyView = keyView
+	c.credits = credits
+	return nil
+}
+
+// loadKeyView returns the admin key sets as of the verified block by applying
+// the stored admin transactions to the key sets of the chain parameters.
+func (c *Chain) loadKeyView(dbTx database.Tx) (*blockchain.KeyViewpoint, error) {
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeys(c.chainParams.AdminKeySets)
+	keyView.SetAdminThresholds(c.chainParams.AdminThresholds)
+	keyView.SetKeyIDs(c.chainParams.ASPKeyIdMap)
+
+	cursor := dbTx.Metadata().Bucket(adminBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if len(key) != 4 {
+			return nil, database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt admin transactions key",
+			}
+		}
+		height := binary.BigEndian.Uint32(key)
+		txs, err := deserializeAdminTxs(cursor.Value())
+		if err != nil || height > c.verified {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt admin "+
+					"transactions at height %d", height),
+			}
+		}
+		for _, tx := range txs {
+			keyView.ProcessAdminOuts(provautil.NewTx(tx), height)
+		}
+	}
+	return keyView, nil
+}
+
+// loadCredits loads the outputs paying to the watched addresses from the
+// database.
+func loadCredits(dbTx database.Tx) (map[wire.OutPoint]*Credit, error) {
+	credits := make(map[wire.OutPoint]*Credit)
+	err := dbTx.Metadata().Bucket(creditsBucketName).ForEach(func(k, v []byte) error {
+		if len(k) != chainhash.HashSize+4 {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt credit key",
+			}
+		}
+		var op wire.OutPoint
+		copy(op.Hash[:], k)
+		op.Index = binary.LittleEndian.Uint32(k[chainhash.HashSize:])
+		credit, err := deserializeCredit(op, v)
+		if err != nil {
+			return err
+		}
+		credits[op] = credit
+		return nil
+	})
+	return credits, err
+}
+
+// fetchContext returns the headers of the main chain ending at the passed
+// height which are needed to validate the header after it.
+func (c *Chain) fetchContext(dbTx database.Tx, height uint32) ([]*wire.BlockHeader, error) {
+	start := uint32(0)
+	if n := uint32(c.chainParams.PowAveragingWindow + medianTimeBlocks); height >= n {
+		start = height - n + 1
+	}
+	headers := make([]*wire.BlockHeader, 0, height-start+1)
+	for h := start; h <= height; h++ {
+		header, err := dbFetchHeader(dbTx, h)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// checkHeader validates the passed header given the consecutive headers of the
+// chain ending with its parent.
+func (c *Chain) checkHeader(header *wire.BlockHeader, prevHeaders []*wire.BlockHeader) error {
+	err := blockchain.CheckBlockHeaderSanity(header, c.chainParams.PowLimit,
+		c.timeSource)
+	if err != nil {
+		return err
+	}
+
+	parent := prevHeaders[len(prevHeaders)-1]
+	if header.PrevBlock != parent.BlockHash() {
+		str := fmt.Sprintf("header %v does not connect to the previous "+
+			"header %v", header.BlockHash(), parent.BlockHash())
+		return ruleError(blockchain.ErrBadHeight, str)
+	}
+	if header.Height != parent.Height+1 {
+		str := fmt.Sprintf("block height of %d is not the expected "+
+			"value of %d", header.Height, parent.Height+1)
+		return ruleError(blockchain.ErrBadHeight, str)
+	}
+
+	expectedDifficulty := blockchain.CalcNextRequiredDifficultyFromHeaders(
+		prevHeaders, c.chainParams)
+	if header.Bits != expectedDifficulty {
+		str := fmt.Sprintf("block difficulty of %d is not the expected "+
+			"value of %d", header.Bits, expectedDifficulty)
+		return ruleError(blockchain.ErrUnexpectedDifficulty, str)
+	}
+
+	medianTime := blockchain.PastMedianTime(prevHeaders)
+	if !header.Timestamp.After(medianTime) {
+		str := fmt.Sprintf("block timestamp of %v is not after "+
+			"expected %v", header.Timestamp, medianTime)
+		return ruleError(blockchain.ErrTimeTooOld, str)
+	}
+
+	pubKey, err := btcec.ParsePubKey(header.ValidatingPubKey[:], btcec.S256())
+	if err != nil {
+		str := fmt.Sprintf("invalid validating public key %v: %v",
+			header.ValidatingPubKey, err)
+		return ruleError(blockchain.ErrBadBlockSignature, str)
+	}
+	if !header.Verify(pubKey) {
+		return ruleError(blockchain.ErrBadBlockSignature,
+			"unable to validate block signature")
+	}
+
+	hash := header.BlockHash()
+	for _, checkpoint := range c.chainParams.Checkpoints {
+		if checkpoint.Height == header.Height && *checkpoint.Hash != hash {
+			str := fmt.Sprintf("block at height %d does not match "+
+				"checkpoint hash", header.Height)
+			return ruleError(blockchain.ErrBadCheckpoint, str)
+		}
+	}
+
+	// The previous validating keys are those of the blocks in the
+	// averaging window before the block, from the most recent one.
+	window := c.chainParams.PowAveragingWindow
+	prevPubKeys := make([]wire.BlockValidatingPubKey, 0, window)
+	for i := len(prevHeaders) - 1; i >= 0 && len(prevPubKeys) < window; i-- {
+		prevPubKeys = append(prevPubKeys, prevHeaders[i].ValidatingPubKey)
+	}
+	if blockchain.IsGenerationTrailingRateLimited(header.ValidatingPubKey,
+		prevPubKeys, c.chainParams.ChainTrailingSigKeyLimit) {
+
+		str := fmt.Sprintf("validate key rate limited %v",
+			header.ValidatingPubKey)
+		return ruleError(blockchain.ErrExcessiveTrailing, str)
+	}
+	if blockchain.IsGenerationShareRateLimited(header.ValidatingPubKey,
+		prevPubKeys, c.chainParams.ChainWindowShareLimit) {
+
+		str := fmt.Sprintf("validate key rate limited %v",
+			header.ValidatingPubKey)
+		return ruleError(blockchain.ErrExcessiveChainShare, str)
+	}
+	return nil
+}
+
+// latestCheckpointHeight returns the height of the latest checkpoint of the
+// chain parameters which is not above the passed height, or zero.
+func (c *Chain) latestCheckpointHeight(height uint32) uint32 {
+	var latest uint32
+	for _, checkpoint := range c.chainParams.Checkpoints {
+		if checkpoint.Height <= height && checkpoint.Height > latest {
+			latest = checkpoint.Height
+		}
+	}
+	return latest
+}
+
+// ProcessHeaders validates the passed consecutive headers and makes them part
+// of the main chain when they extend it, or when they fork it with more work
+// than the headers of the main chain they replace.  Headers which are already
+// part of the main chain are skipped.  It returns the number of headers added
+// to the main chain.
+//
+// This function is safe for concurrent access.
+func (c *Chain) ProcessHeaders(headers []*wire.BlockHeader) (int, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for len(headers) > 0 {
+		hash := headers[0].BlockHash()
+		height := headers[0].Height
+		if height >= uint32(len(c.hashes)) || c.hashes[height] != hash {
+			break
+		}
+		headers = headers[1:]
+	}
+	if len(headers) == 0 {
+		return 0, nil
+	}
+
+	// The headers must fork the main chain at the parent of the first one.
+	forkHeight := headers[0].Height - 1
+	if headers[0].Height == 0 || forkHeight >= uint32(len(c.hashes)) ||
+		c.hashes[forkHeight] != headers[0].PrevBlock {
+
+		return 0, fmt.Errorf("header %v does not connect to the main "+
+			"chain", headers[0].BlockHash())
+	}
+	tip := uint32(len(c.hashes)) - 1
+	if checkpoint := c.latestCheckpointHeight(tip); forkHeight < checkpoint {
+		str := fmt.Sprintf("block at height %d forks the main chain "+
+			"before the previous checkpoint at height %d",
+			forkHeight+1, checkpoint)
+		return 0, ruleError(blockchain.ErrForkTooOld, str)
+	}
+
+	var prevHeaders []*wire.BlockHeader
+	var replacedWork *big.Int
+	err := c.db.View(func(dbTx database.Tx) error {
+		var err error
+		prevHeaders, err = c.fetchContext(dbTx, forkHeight)
+		if err != nil {
+			return err
+		}
+		replacedWork = big.NewInt(0)
+		for height := forkHeight + 1; height <= tip; height++ {
+			header, err := dbFetchHeader(dbTx, height)
+			if err != nil {
+				return err
+			}
+			replacedWork.Add(replacedWork, blockchain.CalcWork(header.Bits))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	work := big.NewInt(0)
+	for _, header := range headers {
+		hash := header.BlockHash()
+		if _, ok := c.invalid[hash]; ok {
+			str := fmt.Sprintf("block %v is signed by an invalid "+
+				"validate key", hash)
+			return 0, ruleError(blockchain.ErrInvalidValidateKey, str)
+		}
+		if _, ok := c.invalid[header.PrevBlock]; ok {
+			str := fmt.Sprintf("block %v builds on a block signed "+
+				"by an invalid validate key", hash)
+			return 0, ruleError(blockchain.ErrInvalidValidateKey, str)
+		}
+		if err := c.checkHeader(header, prevHeaders); err != nil {
+			return 0, err
+		}
+		prevHeaders = append(prevHeaders, header)
+		work.Add(work, blockchain.CalcWork(header.Bits))
+	}
+
+	// Side chains are not kept, so a fork only replaces the main chain once
+	// it has more work.
+	if work.Cmp(replacedWork) <= 0 {
+		log.Debugf("Ignoring %d headers forking the main chain at "+
+			"height %d with less work", len(headers), forkHeight)
+		return 0, nil
+	}
+	if forkHeight < tip {
+		log.Infof("Reorganizing the main chain from height %d to %v "+
+			"(height %d)", forkHeight+1, headers[len(headers)-1].BlockHash(),
+			headers[len(headers)-1].Height)
+	}
+
+	var keyView *blockchain.KeyViewpoint
+	var credits map[wire.OutPoint]*Credit
+	err = c.db.Update(func(dbTx database.Tx) error {
+		if err := c.removeHeaders(dbTx, forkHeight+1); err != nil {
+			return err
+		}
+		for _, header := range headers {
+			if err := dbPutHeader(dbTx, header, header.Height); err != nil {
+				return err
+			}
+		}
+		if c.verified <= forkHeight {
+			return nil
+		}
+		if err := c.rewindVerified(dbTx, forkHeight); err != nil {
+			return err
+		}
+		var err error
+		verified := c.verified
+		c.verified = forkHeight
+		keyView, err = c.loadKeyView(dbTx)
+		c.verified = verified
+		if err != nil {
+			return err
+		}
+		credits, err = loadCredits(dbTx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	c.hashes = c.hashes[:forkHeight+1]
+	for _, header := range headers {
+		c.hashes = append(c.hashes, header.BlockHash())
+	}
+	if keyView != nil {
+		c.verified = forkHeight
+		c.keyView = keyView
+		c.credits = credits
+	}
+	return len(headers), nil
+}
+
+// removeHeaders removes the headers of the main chain from the passed height
+// on from the database.
+func (c *Chain) removeHeaders(dbTx database.Tx, height uint32) error {
+	for h := uint32(len(c.hashes)) - 1; h >= height; h-- {
+		header, err := dbFetchHeader(dbTx, h)
+		if err != nil {
+			return err
+		}
+		if err := dbRemoveHeader(dbTx, header, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewindVerified removes the admin transactions and undoes the changes to the
+// watched addresses of the verified blocks above the passed height, which
+// becomes the verified height.
+func (c *Chain) rewindVerified(dbTx database.Tx, height uint32) error {
+	meta := dbTx.Metadata()
+	adminBucket := meta.Bucket(adminBucketName)
+	for h := height + 1; h <= c.verified; h++ {
+		if err := adminBucket.Delete(heightKey(h)); err != nil {
+			return err
+		}
+	}
+
+	creditsBucket := meta.Bucket(creditsBucketName)
+	for op, credit := range c.credits {
+		op := op
+		switch {
+		case credit.Height > height:
+			if err := creditsBucket.Delete(outPointKey(&op)); err != nil {
+				return err
+			}
+
+		case credit.SpentBy != nil && credit.SpentHeight > height:
+			unspent := *credit
+			unspent.SpentBy = nil
+			unspent.SpentHeight = 0
+			err := creditsBucket.Put(outPointKey(&op),
+				serializeCredit(&unspent))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	addrTxsBucket := meta.Bucket(addrTxsBucketName)
+	var removed [][]byte
+	err := addrTxsBucket.ForEach(func(k, v []byte) error {
+		if len(k) == 2*chainhash.HashSize+4 &&
+			binary.BigEndian.Uint32(k[chainhash.HashSize:]) > height {
+
+			removed = append(removed, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range removed {
+		if err := addrTxsBucket.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return dbPutHeight(dbTx, verifiedKeyName, height)
+}
+
+// ConnectBlock verifies the block with the passed hash, which must be the
+// block after the verified one, given the block itself when its committed
+// filter matches the items returned by FilterItems and nil otherwise.  The
+// block must be signed by a key of the validate key set, after the admin
+// transactions of the block are applied to it.  Otherwise the block and the
+// headers after it are removed from the main chain and
+// blockchain.ErrInvalidValidateKey is returned.
+//
+// This function is safe for concurrent access.
+func (c *Chain) ConnectBlock(hash *chainhash.Hash, block *wire.MsgBlock) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	height := c.verified + 1
+	if height >= uint32(len(c.hashes)) || c.hashes[height] != *hash {
+		return fmt.Errorf("block %v is not the next block to verify",
+			hash)
+	}
+
+	var header *wire.BlockHeader
+	err := c.db.View(func(dbTx database.Tx) error {
+		var err error
+		header, err = dbFetchHeader(dbTx, height)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	keyView := c.keyView
+	var adminTxs []*wire.MsgTx
+	if block != nil {
+		if block.BlockHash() != *hash {
+			return fmt.Errorf("block %v does not match the header %v",
+				block.BlockHash(), hash)
+		}
+		if err := checkBlockContent(header, block); err != nil {
+			return err
+		}
+		for _, tx := range block.Transactions {
+			if threadInt, _ := txscript.GetAdminDetailsMsgTx(tx); threadInt >= 0 {
+				adminTxs = append(adminTxs, tx)
+			}
+		}
+	}
+	if len(adminTxs) > 0 {
+		// Apply the admin transactions to a copy of the key sets, so
+		// they are left untouched when the block is invalid.
+		err := c.db.View(func(dbTx database.Tx) error {
+			var err error
+			keyView, err = c.loadKeyView(dbTx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, tx := range adminTxs {
+			keyView.ProcessAdminOuts(provautil.NewTx(tx), height)
+		}
+	}
+
+	validateKeySet := keyView.Keys()[btcec.ValidateKeySet]
+	pubKey, err := btcec.ParsePubKey(header.ValidatingPubKey[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+	if len(validateKeySet) > 0 && validateKeySet.Pos(pubKey) == -1 {
+		err := c.db.Update(func(dbTx database.Tx) error {
+			return c.removeHeaders(dbTx, height)
+		})
+		if err != nil {
+			return err
+		}
+		c.invalid[*hash] = struct{}{}
+		c.hashes = c.hashes[:height]
+		str := fmt.Sprintf("invalid validate key %v",
+			pubKey.SerializeCompressed())
+		return ruleError(blockchain.ErrInvalidValidateKey, str)
+	}
+
+	var changes []*Credit
+	var addrTxKeys [][]byte
+	if block != nil {
+		changes, addrTxKeys = c.watchedChanges(block, height)
+	}
+	err = c.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if len(adminTxs) > 0 {
+			serialized, err := serializeAdminTxs(adminTxs)
+			if err != nil {
+				return err
+			}
+			err = meta.Bucket(adminBucketName).Put(heightKey(height),
+				serialized)
+			if err != nil {
+				return err
+			}
+		}
+		creditsBucket := meta.Bucket(creditsBucketName)
+		for _, credit := range changes {
+			err := creditsBucket.Put(outPointKey(&credit.OutPoint),
+				serializeCredit(credit))
+			if err != nil {
+				return err
+			}
+		}
+		addrTxsBucket := meta.Bucket(addrTxsBucketName)
+		for _, key := range addrTxKeys {
+			if err := addrTxsBucket.Put(key, nil); err != nil {
+				return err
+			}
+		}
+		return dbPutHeight(dbTx, verifiedKeyName, height)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.verified = height
+	c.keyView = keyView
+	for _, credit := range changes {
+		c.credits[credit.OutPoint] = credit
+	}
+	return nil
+}
+
+// checkBlockContent ensures the transactions of the passed block are those
+// committed to by its header.
+func checkBlockContent(header *wire.BlockHeader, block *wire.MsgBlock) error {
+	if len(block.Transactions) == 0 {
+		return ruleError(blockchain.ErrNoTransactions,
+			"block does not contain any transactions")
+	}
+	merkles := blockchain.BuildMerkleTreeStore(
+		provautil.NewBlock(block).Transactions())
+	calculatedMerkleRoot := merkles[len(merkles)-1]
+	if !header.MerkleRoot.IsEqual(calculatedMerkleRoot) {
+		str := fmt.Sprintf("block merkle root is invalid - block "+
+			"header indicates %v, but calculated value is %v",
+			header.MerkleRoot, calculatedMerkleRoot)
+		return ruleError(blockchain.ErrBadMerkleRoot, str)
+	}
+	if size := uint32(block.SerializeSize()); header.Size != size {
+		str := fmt.Sprintf("block size of %d does not match the size "+
+			"of %d in its header", size, header.Size)
+		return ruleError(blockchain.ErrInconsistentBlkSize, str)
+	}
+	return nil
+}
+
+// watchedChanges returns the outputs of the passed block paying to the watched
+// addresses and the watched outputs it spends, along with the keys recording
+// the transactions of the watched addresses in the database.
+//
+// This function MUST be called with the chain lock held (for reads).
+func (c *Chain) watchedChanges(block *wire.MsgBlock, height uint32) ([]*Credit, [][]byte) {
+	changed := make(map[wire.OutPoint]*Credit)
+	var changes []*Credit
+	var addrTxKeys [][]byte
+	addTx := func(pkScript []byte, txHash *chainhash.Hash) {
+		scriptHash := chainhash.DoubleHashH(pkScript)
+		addrTxKeys = append(addrTxKeys, addrTxKey(&scriptHash, height,
+			txHash))
+	}
+
+	for i, tx := range block.Transactions {
+		txHash := tx.TxHash()
+		if i != 0 {
+			for _, txIn := range tx.TxIn {
+				op := txIn.PreviousOutPoint
+				credit, ok := changed[op]
+				if ok {
+					// The output was created earlier in the
+					// block.
+					if credit.SpentBy != nil {
+						continue
+					}
+					credit.SpentBy = &txHash
+					credit.SpentHeight = height
+				} else {
+					stored, ok := c.credits[op]
+					if !ok || stored.SpentBy != nil {
+						continue
+					}
+					spent := *stored
+					spent.SpentBy = &txHash
+					spent.SpentHeight = height
+					credit = &spent
+					changed[op] = credit
+					changes = append(changes, credit)
+				}
+				addTx(credit.PkScript, &txHash)
+			}
+		}
+		for index, txOut := range tx.TxOut {
+			if _, ok := c.watchedScripts[string(txOut.PkScript)]; !ok {
+				continue
+			}
+			credit := &Credit{
+				OutPoint: wire.OutPoint{Hash: txHash, Index: uint32(index)},
+				Height:   height,
+				Amount:   txOut.Value,
+				PkScript: txOut.PkScript,
+			}
+			changed[credit.OutPoint] = credit
+			changes = append(changes, credit)
+			addTx(txOut.PkScript, &txHash)
+		}
+	}
+	return changes, addrTxKeys
+}
+
+// Tip returns the hash and height of the last header of the main chain.
+//
+// This function is safe for concurrent access.
+func (c *Chain) Tip() (*chainhash.Hash, uint32) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	height := uint32(len(c.hashes)) - 1
+	hash := c.hashes[height]
+	return &hash, height
+}
+
+// VerifiedTip returns the hash and height of the last verified block of the
+// main chain.
+//
+// This function is safe for concurrent access.
+func (c *Chain) VerifiedTip() (*chainhash.Hash, uint32) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	hash := c.hashes[c.verified]
+	return &hash, c.verified
+}
+
+// HashByHeight returns the hash of the block of the main chain at the passed
+// height.
+//
+// This function is safe for concurrent access.
+func (c *Chain) HashByHeight(height uint32) (*chainhash.Hash, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if height >= uint32(len(c.hashes)) {
+		return nil, fmt.Errorf("no block at height %d exists", height)
+	}
+	hash := c.hashes[height]
+	return &hash, nil
+}
+
+// HeaderByHeight returns the header of the block of the main chain at the
+// passed height.
+//
+// This function is safe for concurrent access.
+func (c *Chain) HeaderByHeight(height uint32) (*wire.BlockHeader, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if height >= uint32(len(c.hashes)) {
+		return nil, fmt.Errorf("no block at height %d exists", height)
+	}
+	var header *wire.BlockHeader
+	err := c.db.View(func(dbTx database.Tx) error {
+		var err error
+		header, err = dbFetchHeader(dbTx, height)
+		return err
+	})
+	return header, err
+}
+
+// HeaderByHash returns the header of the block of the main chain with the
+// passed hash.
+//
+// This function is safe for concurrent access.
+func (c *Chain) HeaderByHash(hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var header *wire.BlockHeader
+	err := c.db.View(func(dbTx database.Tx) error {
+		height, ok := dbFetchHeight(dbTx, hash)
+		if !ok {
+			return fmt.Errorf("block %v is not in the main chain", hash)
+		}
+		var err error
+		header, err = dbFetchHeader(dbTx, height)
+		return err
+	})
+	return header, err
+}
+
+// BlockLocator returns a block locator for the last header of the main chain.
+// See blockchain.BlockLocator for details on the algorithm used to create it.
+//
+// This function is safe for concurrent access.
+func (c *Chain) BlockLocator() blockchain.BlockLocator {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	locator := make(blockchain.BlockLocator, 0, wire.MaxBlockLocatorsPerMsg)
+	step := 1
+	for height := len(c.hashes) - 1; height > 0; height -= step {
+		hash := c.hashes[height]
+		locator = append(locator, &hash)
+
+		// Once 11 entries have been included, start doubling the
+		// distance between included hashes.
+		if len(locator) > 10 {
+			step *= 2
+		}
+	}
+	genesis := c.hashes[0]
+	return append(locator, &genesis)
+}
+
+// ValidateKeys returns the validate key set as of the last verified block.
+//
+// This function is safe for concurrent access.
+func (c *Chain) ValidateKeys() btcec.PublicKeySet {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.keyView.Keys()[btcec.ValidateKeySet]
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package spv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/spv"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// createDB creates a new database with the passed name for the tests.
+func createDB(t *testing.T, name string) database.DB {
+	dbPath := filepath.Join("testdbs", name)
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	return db
+}
+
+// generatedParams returns a deep copy of the regression test network
+// parameters with the genesis block the full block tests are generated on.
+// fullblocktests.Generate signs the genesis block of the shared parameters in
+// place, so its hash no longer matches their genesis hash, and the copy keeps
+// the chains of the test from sharing the mutated block.
+func generatedParams() chaincfg.Params {
+	params := chaincfg.RegressionNetParams
+	genesis := &wire.MsgBlock{Header: params.GenesisBlock.Header}
+	for _, tx := range params.GenesisBlock.Transactions {
+		genesis.Transactions = append(genesis.Transactions, tx.Copy())
+	}
+	genesisHash := genesis.BlockHash()
+	params.GenesisBlock = genesis
+	params.GenesisHash = &genesisHash
+	return params
+}
+
+// TestChain ensures a light client chain fed with the headers and the matching
+// blocks of the full block tests ends up with the same main chain and validate
+// key set as a full chain, and tracks the outputs of the watched addresses.
+func TestChain(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+	defer os.RemoveAll("testdbs")
+
+	params := generatedParams()
+	fullDB := createDB(t, "full")
+	defer fullDB.Close()
+	fullChain, err := blockchain.New(&blockchain.Config{
+		DB:          fullDB,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+
+	// Watch the addresses the coinbases of the accepted blocks pay to.
+	var accepted []*wire.MsgBlock
+	blocks := make(map[chainhash.Hash]*wire.MsgBlock)
+	var addrs []provautil.Address
+	watchedCoinbases := make(map[chainhash.Hash]struct{})
+	for _, test := range tests {
+		for _, item := range test {
+			b, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(b.Block)
+			block.SetHeight(b.Height)
+			_, _, err := fullChain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("block %q was not accepted: %v", b.Name, err)
+			}
+			accepted = append(accepted, b.Block)
+			blocks[b.Block.BlockHash()] = b.Block
+
+			pkScript := b.Block.Transactions[0].TxOut[0].PkScript
+			_, outAddrs, _, err := txscript.ExtractPkScriptAddrs(
+				pkScript, &params)
+			if err != nil || len(outAddrs) != 1 {
+				continue
+			}
+			addrs = append(addrs, outAddrs[0])
+			watchedCoinbases[b.Block.Transactions[0].TxHash()] = struct{}{}
+		}
+	}
+
+	db := createDB(t, "spv")
+	defer db.Close()
+	config := &spv.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+		Addresses:   addrs,
+	}
+	chain, err := spv.New(config)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	// Feed the headers of every accepted block from the point where its
+	// chain forks the main chain of the light client, which reorganizes it
+	// when the fork has more work.
+	for _, block := range accepted {
+		var headers []*wire.BlockHeader
+		hash := block.BlockHash()
+		for {
+			if _, err := chain.HeaderByHash(&hash); err == nil {
+				break
+			}
+			b, ok := blocks[hash]
+			if !ok {
+				// The block is an orphan.
+				headers = nil
+				break
+			}
+			headers = append([]*wire.BlockHeader{&b.Header}, headers...)
+			hash = b.Header.PrevBlock
+		}
+		if _, err := chain.ProcessHeaders(headers); err != nil {
+			t.Fatalf("ProcessHeaders: block %v: unexpected error: %v",
+				block.BlockHash(), err)
+		}
+	}
+	best := fullChain.BestSnapshot()
+	tipHash, tipHeight := chain.Tip()
+	if *tipHash != *best.Hash || tipHeight != best.Height {
+		t.Fatalf("Tip: got %v (%d), want %v (%d)", tipHash, tipHeight,
+			best.Hash, best.Height)
+	}
+
+	// Verify the main chain, passing the blocks whose filters match.
+	var fetched int
+	for height := uint32(1); height <= tipHeight; height++ {
+		hash, err := chain.HashByHeight(height)
+		if err != nil {
+			t.Fatalf("HashByHeight: unexpected error: %v", err)
+		}
+		block := blocks[*hash]
+		filter, err := builder.BuildBasicFilter(block)
+		if err != nil {
+			t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+		}
+		serialized, err := filter.NBytes()
+		if err != nil {
+			t.Fatalf("NBytes: unexpected error: %v", err)
+		}
+		match, err := chain.MatchFilter(hash, serialized)
+		if err != nil {
+			t.Fatalf("MatchFilter: unexpected error: %v", err)
+		}
+		if !match {
+			block = nil
+		} else {
+			fetched++
+		}
+		if err := chain.ConnectBlock(hash, block); err != nil {
+			t.Fatalf("ConnectBlock: block %v at height %d: unexpected "+
+				"error: %v", hash, height, err)
+		}
+	}
+	if fetched == 0 {
+		t.Errorf("MatchFilter: no block matched")
+	}
+	if _, verified := chain.VerifiedTip(); verified != tipHeight {
+		t.Errorf("VerifiedTip: got height %d, want %d", verified,
+			tipHeight)
+	}
+	gotKeys := chain.ValidateKeys()
+	wantKeys := fullChain.AdminKeySets()[btcec.ValidateKeySet]
+	if len(gotKeys) != len(wantKeys) {
+		t.Fatalf("ValidateKeys: got %d keys, want %d", len(gotKeys),
+			len(wantKeys))
+	}
+	for _, key := range wantKeys {
+		key := key
+		if gotKeys.Pos(&key) == -1 {
+			t.Errorf("ValidateKeys: missing key %x",
+				key.SerializeCompressed())
+		}
+	}
+
+	// The unspent outputs of the watched addresses are those of the
+	// coinbases of the main chain the full chain has not spent.
+	var wantUtxos int
+	for height := uint32(1); height <= tipHeight; height++ {
+		hash, _ := chain.HashByHeight(height)
+		coinbaseHash := blocks[*hash].Transactions[0].TxHash()
+		if _, ok := watchedCoinbases[coinbaseHash]; !ok {
+			continue
+		}
+		entry, err := fullChain.FetchUtxoEntry(&coinbaseHash)
+		if err != nil {
+			t.Fatalf("FetchUtxoEntry: unexpected error: %v", err)
+		}
+		if entry != nil && !entry.IsOutputSpent(0) {
+			wantUtxos++
+		}
+	}
+	checkWatched := func(chain *spv.Chain) {
+		var gotUtxos int
+		for _, addr := range addrs {
+			utxos, err := chain.Utxos(addr)
+			if err != nil {
+				t.Fatalf("Utxos: unexpected error: %v", err)
+			}
+			var amount int64
+			for _, utxo := range utxos {
+				entry, err := fullChain.FetchUtxoEntry(&utxo.OutPoint.Hash)
+				if err != nil || entry == nil ||
+					entry.IsOutputSpent(utxo.OutPoint.Index) {
+
+					t.Errorf("Utxos: %v is not an unspent output",
+						utxo.OutPoint)
+				}
+				amount += utxo.Amount
+			}
+			gotUtxos += len(utxos)
+
+			balance, received, err := chain.Balance(addr)
+			if err != nil {
+				t.Fatalf("Balance: unexpected error: %v", err)
+			}
+			if balance != amount || received < balance {
+				t.Errorf("Balance: got %d (received %d), want %d",
+					balance, received, amount)
+			}
+			if received == 0 {
+				continue
+			}
+			txs, err := chain.Transactions(addr, 0, tipHeight)
+			if err != nil {
+				t.Fatalf("Transactions: unexpected error: %v", err)
+			}
+			if len(txs) == 0 {
+				t.Errorf("Transactions: no transaction for %v", addr)
+			}
+		}
+		if gotUtxos != wantUtxos {
+			t.Errorf("Utxos: got %d unspent outputs, want %d", gotUtxos,
+				wantUtxos)
+		}
+	}
+	checkWatched(chain)
+
+	// The state of the chain is loaded from the database.
+	reloaded, err := spv.New(config)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	reloadedHash, reloadedHeight := reloaded.VerifiedTip()
+	if *reloadedHash != *tipHash || reloadedHeight != tipHeight {
+		t.Errorf("VerifiedTip: got %v (%d) after reloading, want %v "+
+			"(%d)", reloadedHash, reloadedHeight, tipHash, tipHeight)
+	}
+	if len(reloaded.ValidateKeys()) != len(wantKeys) {
+		t.Errorf("ValidateKeys: got %d keys after reloading, want %d",
+			len(reloaded.ValidateKeys()), len(wantKeys))
+	}
+	checkWatched(reloaded)
+
+	// Headers which do not extend the main chain are rejected.
+	genesis := params.GenesisBlock.Header
+	if _, err := reloaded.ProcessHeaders([]*wire.BlockHeader{&genesis}); err != nil {
+		t.Errorf("ProcessHeaders: unexpected error for a known header: %v",
+			err)
+	}
+	hash, _ := reloaded.HashByHeight(1)
+	bad := blocks[*hash].Header
+	bad.Height = 2
+	bad.PrevBlock = chainhash.Hash{0x01}
+	if _, err := reloaded.ProcessHeaders([]*wire.BlockHeader{&bad}); err == nil {
+		t.Errorf("ProcessHeaders: no error for a header which does not " +
+			"connect")
+	}
+}
+
+// TestNewGenesisMismatch ensures a chain is not created from parameters whose
+// genesis block does not hash to their genesis hash.
+func TestNewGenesisMismatch(t *testing.T) {
+	defer os.RemoveAll("testdbs")
+
+	params := generatedParams()
+	genesisHash := chainhash.Hash{0x01}
+	params.GenesisHash = &genesisHash
+	db := createDB(t, "mismatch")
+	defer db.Close()
+	_, err := spv.New(&spv.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err == nil {
+		t.Fatal("New: no error for a genesis block which does not " +
+			"match the genesis hash")
+	}
+}
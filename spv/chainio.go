@@ -0,0 +1,257 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package spv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+var (
+	// headersBucketName is the name of the bucket holding the headers of
+	// the main chain keyed by their height.
+	headersBucketName = []byte("spvheaders")
+
+	// heightIdxBucketName is the name of the bucket mapping the hashes of
+	// the headers of the main chain to their height.
+	heightIdxBucketName = []byte("spvheightidx")
+
+	// stateBucketName is the name of the bucket holding the verified
+	// height and the heights the watched addresses are watched from.
+	stateBucketName = []byte("spvstate")
+
+	// adminBucketName is the name of the bucket holding the admin
+	// transactions of the verified blocks keyed by the height of their
+	// block.
+	adminBucketName = []byte("spvadmin")
+
+	// creditsBucketName is the name of the bucket holding the outputs
+	// paying to the watched addresses keyed by their outpoint.
+	creditsBucketName = []byte("spvcredits")
+
+	// addrTxsBucketName is the name of the bucket holding the
+	// transactions paying to or spending from the watched addresses.
+	addrTxsBucketName = []byte("spvaddrtxs")
+
+	// verifiedKeyName is the key of the verified height in the state
+	// bucket.
+	verifiedKeyName = []byte("verified")
+
+	// watchedKeyPrefix prefixes the encoded watched addresses in the state
+	// bucket.
+	watchedKeyPrefix = []byte("watched-")
+)
+
+// -----------------------------------------------------------------------------
+// The light client chain is stored in the following buckets of the metadata
+// of its database:
+//
+//   spvheaders:   <height> = <header>
+//   spvheightidx: <block hash> = <height>
+//   spvstate:     verified = <height>
+//                 watched-<address> = <height>
+//   spvadmin:     <height> = <num txs><tx>...
+//   spvcredits:   <tx hash><index> = <credit>
+//   spvaddrtxs:   <script hash><height><tx hash> = <empty>
+//
+// Heights are big-endian uint32s in keys, so the cursors of the buckets keyed
+// by height iterate in the order of the chain, and little-endian uint32s in
+// values.  The script hash is the double SHA-256 of the public key script of
+// a watched address.
+//
+// The serialized format of a credit is:
+//
+//   Field          Type              Size
+//   height         uint32            4 bytes
+//   amount         int64             8 bytes
+//   spent height   uint32            4 bytes (0 while unspent)
+//   spent by       chainhash.Hash    32 bytes (all zero while unspent)
+//   pkscript       []byte            variable
+// -----------------------------------------------------------------------------
+
+// creditHeaderSize is the size of a serialized credit without its public key
+// script.
+const creditHeaderSize = 4 + 8 + 4 + chainhash.HashSize
+
+// heightKey returns the key of the passed height in the buckets keyed by
+// height.
+func heightKey(height uint32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], height)
+	return key[:]
+}
+
+// outPointKey returns the key of the passed outpoint in the credits bucket.
+func outPointKey(op *wire.OutPoint) []byte {
+	key := make([]byte, chainhash.HashSize+4)
+	copy(key, op.Hash[:])
+	binary.LittleEndian.PutUint32(key[chainhash.HashSize:], op.Index)
+	return key
+}
+
+// addrTxKey returns the key recording that the transaction with the passed
+// hash confirmed at the passed height pays to or spends from the address with
+// the passed script hash.
+func addrTxKey(scriptHash *chainhash.Hash, height uint32, txHash *chainhash.Hash) []byte {
+	key := make([]byte, 2*chainhash.HashSize+4)
+	copy(key, scriptHash[:])
+	binary.BigEndian.PutUint32(key[chainhash.HashSize:], height)
+	copy(key[chainhash.HashSize+4:], txHash[:])
+	return key
+}
+
+// dbPutHeader stores the passed header of the main chain at the passed height.
+func dbPutHeader(dbTx database.Tx, header *wire.BlockHeader, height uint32) error {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return err
+	}
+	meta := dbTx.Metadata()
+	err := meta.Bucket(headersBucketName).Put(heightKey(height), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	hash := header.BlockHash()
+	var serializedHeight [4]byte
+	binary.LittleEndian.PutUint32(serializedHeight[:], height)
+	return meta.Bucket(heightIdxBucketName).Put(hash[:], serializedHeight[:])
+}
+
+// dbFetchHeader fetches the header of the main chain at the passed height.
+func dbFetchHeader(dbTx database.Tx, height uint32) (*wire.BlockHeader, error) {
+	serialized := dbTx.Metadata().Bucket(headersBucketName).Get(heightKey(height))
+	if serialized == nil {
+		return nil, fmt.Errorf("no header at height %d", height)
+	}
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(serialized)); err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt header at height "+
+				"%d: %v", height, err),
+		}
+	}
+	return &header, nil
+}
+
+// dbFetchHeight fetches the height of the header of the main chain with the
+// passed hash.  It returns false when the header is not part of the main
+// chain.
+func dbFetchHeight(dbTx database.Tx, hash *chainhash.Hash) (uint32, bool) {
+	serialized := dbTx.Metadata().Bucket(heightIdxBucketName).Get(hash[:])
+	if len(serialized) != 4 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(serialized), true
+}
+
+// dbRemoveHeader removes the passed header of the main chain at the passed
+// height.
+func dbRemoveHeader(dbTx database.Tx, header *wire.BlockHeader, height uint32) error {
+	meta := dbTx.Metadata()
+	if err := meta.Bucket(headersBucketName).Delete(heightKey(height)); err != nil {
+		return err
+	}
+	hash := header.BlockHash()
+	return meta.Bucket(heightIdxBucketName).Delete(hash[:])
+}
+
+// dbPutHeight stores the passed height under the passed key of the state
+// bucket.
+func dbPutHeight(dbTx database.Tx, key []byte, height uint32) error {
+	var serialized [4]byte
+	binary.LittleEndian.PutUint32(serialized[:], height)
+	return dbTx.Metadata().Bucket(stateBucketName).Put(key, serialized[:])
+}
+
+// dbFetchStateHeight fetches the height stored under the passed key of the state
+// bucket.  It returns false when there is none.
+func dbFetchStateHeight(dbTx database.Tx, key []byte) (uint32, bool) {
+	serialized := dbTx.Metadata().Bucket(stateBucketName).Get(key)
+	if len(serialized) != 4 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(serialized), true
+}
+
+// serializeAdminTxs returns the serialization of the passed admin transactions
+// of a block.
+func serializeAdminTxs(txs []*wire.MsgTx) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(txs))); err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		if err := tx.Serialize(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializeAdminTxs decodes admin transactions serialized by
+// serializeAdminTxs.
+func deserializeAdminTxs(serialized []byte) ([]*wire.MsgTx, error) {
+	r := bytes.NewReader(serialized)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Every transaction takes more than one byte.
+	if count > uint64(len(serialized)) {
+		return nil, fmt.Errorf("too many admin transactions: %d", count)
+	}
+	txs := make([]*wire.MsgTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var tx wire.MsgTx
+		if err := tx.Deserialize(r); err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
+
+// serializeCredit returns the serialization of the passed credit.
+func serializeCredit(c *Credit) []byte {
+	serialized := make([]byte, creditHeaderSize+len(c.PkScript))
+	binary.LittleEndian.PutUint32(serialized[0:4], c.Height)
+	binary.LittleEndian.PutUint64(serialized[4:12], uint64(c.Amount))
+	if c.SpentBy != nil {
+		binary.LittleEndian.PutUint32(serialized[12:16], c.SpentHeight)
+		copy(serialized[16:creditHeaderSize], c.SpentBy[:])
+	}
+	copy(serialized[creditHeaderSize:], c.PkScript)
+	return serialized
+}
+
+// deserializeCredit decodes the credit of the passed outpoint serialized by
+// serializeCredit.
+func deserializeCredit(op wire.OutPoint, serialized []byte) (*Credit, error) {
+	if len(serialized) < creditHeaderSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt credit for %v", op),
+		}
+	}
+	c := &Credit{
+		OutPoint: op,
+		Height:   binary.LittleEndian.Uint32(serialized[0:4]),
+		Amount:   int64(binary.LittleEndian.Uint64(serialized[4:12])),
+		PkScript: append([]byte(nil), serialized[creditHeaderSize:]...),
+	}
+	if spentHeight := binary.LittleEndian.Uint32(serialized[12:16]); spentHeight != 0 {
+		var spentBy chainhash.Hash
+		copy(spentBy[:], serialized[16:creditHeaderSize])
+		c.SpentBy = &spentBy
+		c.SpentHeight = spentHeight
+	}
+	return c, nil
+}
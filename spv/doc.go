@@ -0,0 +1,46 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package spv implements the chain of a light client of a Prova network, which
+validates the signed header chain and the validator set rules without storing
+the utxo set.
+
+Overview
+
+A Prova block is valid when it is signed by an active validate key, so a light
+client which trusts the validators to enforce the other rules only needs the
+headers of the blocks and the admin transactions changing the validate key
+set.  The chain stores the headers of the main chain and checks every header
+like a full node does, except for the rules which need the transactions of
+the block: the header must extend the chain with the required difficulty and a
+timestamp after the median time of the previous blocks, match the checkpoints,
+be signed by its validating public key and not exceed the rate limits of that
+key.
+
+Verification
+
+Whether the validating key of a block is part of the validate key set depends
+on the admin transactions of the blocks before it.  Blocks are therefore
+verified one after the other with ConnectBlock, given the block when its
+committed filter (BIP0158) served by a full peer matches one of the items
+returned by FilterItems, and nil otherwise.  The items are the output scripts
+of the root and provision admin threads along with the scripts of the watched
+addresses and the outputs paying to them, so the blocks fetched are those
+changing the admin key sets or the watched addresses.  The admin transactions
+of the fetched blocks are kept so the key sets can be recomputed when blocks
+are disconnected.
+
+Note the filters are not committed to by the headers, so a light client
+trusts the full peers it fetches them from to serve the filters of the blocks
+it asks for.
+
+Watched Addresses
+
+The outputs paying to the watched addresses are tracked along with the
+transactions paying to and spending from them, from the height the addresses
+are first watched.  Balance, Utxos and Transactions return them for the
+verified part of the chain.
+*/
+package spv
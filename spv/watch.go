@@ -0,0 +1,184 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package spv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/txscript"
+)
+
+// FilterItems returns the committed filter items matched by the blocks which
+// must be fetched to verify them: the output scripts of the root and provision
+// admin threads, which change the validate key set, along with the scripts of
+// the watched addresses and their unspent outputs.
+//
+// This function is safe for concurrent access.
+func (c *Chain) FilterItems() [][]byte {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	items := make([][]byte, 0, 2+len(c.watched)+len(c.credits))
+	for _, threadID := range []provautil.ThreadID{provautil.RootThread,
+		provautil.ProvisionThread} {
+
+		script, err := txscript.ProvaThreadScript(threadID)
+		if err != nil {
+			log.Errorf("Unable to create the script of thread %d: %v",
+				threadID, err)
+			continue
+		}
+		items = append(items, script)
+	}
+	for _, w := range c.watched {
+		items = append(items, w.pkScript)
+	}
+	for op, credit := range c.credits {
+		if credit.SpentBy == nil {
+			op := op
+			items = append(items, builder.OutPointItem(&op))
+		}
+	}
+	return items
+}
+
+// MatchFilter returns whether the block with the passed hash must be fetched
+// to verify it, given its serialized committed filter.
+//
+// This function is safe for concurrent access.
+func (c *Chain) MatchFilter(hash *chainhash.Hash, filter []byte) (bool, error) {
+	f, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, filter)
+	if err != nil {
+		return false, err
+	}
+	return f.MatchAny(builder.DeriveKey(hash), c.FilterItems())
+}
+
+// Addresses returns the watched addresses sorted by their encoding.
+//
+// This function is safe for concurrent access.
+func (c *Chain) Addresses() []WatchedAddress {
+	addrs := make([]WatchedAddress, 0, len(c.watched))
+	for _, w := range c.watched {
+		addrs = append(addrs, w.WatchedAddress)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Address.EncodeAddress() <
+			addrs[j].Address.EncodeAddress()
+	})
+	return addrs
+}
+
+// watchedAddr returns the passed address when it is watched.
+func (c *Chain) watchedAddr(addr provautil.Address) (*watchedAddress, error) {
+	w, ok := c.watched[addr.EncodeAddress()]
+	if !ok {
+		return nil, fmt.Errorf("address %v is not watched", addr)
+	}
+	return w, nil
+}
+
+// Balance returns the balance of the passed watched address along with the
+// total amount it received as of the last verified block.
+//
+// This function is safe for concurrent access.
+func (c *Chain) Balance(addr provautil.Address) (int64, int64, error) {
+	w, err := c.watchedAddr(addr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var balance, received int64
+	for _, credit := range c.credits {
+		if string(credit.PkScript) != string(w.pkScript) {
+			continue
+		}
+		received += credit.Amount
+		if credit.SpentBy == nil {
+			balance += credit.Amount
+		}
+	}
+	return balance, received, nil
+}
+
+// Utxos returns the unspent outputs paying to the passed watched address as of
+// the last verified block, sorted by height.
+//
+// This function is safe for concurrent access.
+func (c *Chain) Utxos(addr provautil.Address) ([]*Credit, error) {
+	w, err := c.watchedAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var utxos []*Credit
+	for _, credit := range c.credits {
+		if credit.SpentBy == nil &&
+			string(credit.PkScript) == string(w.pkScript) {
+
+			utxo := *credit
+			utxos = append(utxos, &utxo)
+		}
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Height != utxos[j].Height {
+			return utxos[i].Height < utxos[j].Height
+		}
+		return utxos[i].OutPoint.String() < utxos[j].OutPoint.String()
+	})
+	return utxos, nil
+}
+
+// Transactions returns the transactions paying to or spending from the passed
+// watched address in the blocks from the start to the end height, both
+// inclusive, sorted by height.
+//
+// This function is safe for concurrent access.
+func (c *Chain) Transactions(addr provautil.Address, start, end uint32) ([]TxRef, error) {
+	w, err := c.watchedAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	var txs []TxRef
+	err = c.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrTxsBucketName).Cursor()
+		seek := addrTxKey(&w.scriptHash, start, &chainhash.Hash{})
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(key) != 2*chainhash.HashSize+4 ||
+				string(key[:chainhash.HashSize]) != string(w.scriptHash[:]) {
+
+				break
+			}
+			height := binary.BigEndian.Uint32(key[chainhash.HashSize:])
+			if height > end {
+				break
+			}
+			var txRef TxRef
+			copy(txRef.Hash[:], key[chainhash.HashSize+4:])
+			txRef.Height = height
+			txs = append(txs, txRef)
+		}
+		return nil
+	})
+	return txs, err
+}
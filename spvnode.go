@@ -0,0 +1,660 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/connmgr"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/spv"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// spvDbNamePrefix is the prefix for the light client database name.
+	// The database type is appended to this value to form the full
+	// database name.
+	spvDbNamePrefix = "spv"
+
+	// spvStallTimeout is the duration after which the sync peer is
+	// disconnected when it does not answer the outstanding request for
+	// headers, filters or a block.
+	spvStallTimeout = 2 * time.Minute
+
+	// spvStallTickInterval is the interval at which the outstanding
+	// requests to the sync peer are checked for a stall.
+	spvStallTickInterval = 15 * time.Second
+)
+
+// spvPeerMsg signals a peer which completed the handshake or disconnected.
+type spvPeerMsg struct {
+	peer *peer.Peer
+	done bool
+}
+
+// spvHeadersMsg packages a headers message along with the peer it came from.
+type spvHeadersMsg struct {
+	peer    *peer.Peer
+	headers *wire.MsgHeaders
+}
+
+// spvInvMsg packages an inv message along with the peer it came from.
+type spvInvMsg struct {
+	peer *peer.Peer
+	inv  *wire.MsgInv
+}
+
+// spvCFilterMsg packages a cfilter message along with the peer it came from.
+type spvCFilterMsg struct {
+	peer   *peer.Peer
+	filter *wire.MsgCFilter
+}
+
+// spvBlockMsg packages a block message along with the peer it came from.
+type spvBlockMsg struct {
+	peer  *peer.Peer
+	block *wire.MsgBlock
+}
+
+// spvNode runs the node as a light client.  It syncs the headers of the chain
+// from full peers, fetches the committed filters of the blocks after the last
+// verified one and the blocks whose filters match, and verifies them with the
+// light client chain.  Its RPC server serves the wallet oriented RPCs from the
+// chain.
+type spvNode struct {
+	started  int32
+	shutdown int32
+
+	chain       *spv.Chain
+	connManager *connmgr.ConnManager
+	rpcServer   *rpcServer
+
+	// peers holds the peers which completed the handshake.
+	peersMtx sync.RWMutex
+	peers    map[*peer.Peer]struct{}
+
+	msgChan chan interface{}
+	wg      sync.WaitGroup
+	quit    chan struct{}
+}
+
+// syncState houses the state of the sync with the sync peer.  It is only
+// accessed by the sync handler.
+type syncState struct {
+	peer *peer.Peer
+
+	// headersRequested is set while a getheaders request is outstanding.
+	headersRequested bool
+
+	// filtersStop is the hash of the last block of the outstanding
+	// getcfilters request, if any.
+	filtersStop *chainhash.Hash
+
+	// filters holds the received filters of the blocks to verify, and
+	// blocks the requested blocks, which are nil until they are received.
+	filters map[chainhash.Hash][]byte
+	blocks  map[chainhash.Hash]*wire.MsgBlock
+
+	// lastProgress is when the sync peer last answered a request.
+	lastProgress time.Time
+}
+
+// spvDbPath returns the path to the light client database given a database
+// type.
+func spvDbPath(dbType string) string {
+	return filepath.Join(cfg.DataDir, spvDbNamePrefix+"_"+dbType)
+}
+
+// loadSPVDB loads (or creates when needed) the light client database taking
+// into account the selected database backend and returns a handle to it.
+func loadSPVDB() (database.DB, error) {
+	if cfg.DbType == "memdb" {
+		btcdLog.Infof("Creating light client database in memory.")
+		return database.Create(cfg.DbType)
+	}
+
+	dbPath := spvDbPath(cfg.DbType)
+	btcdLog.Infof("Loading light client database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		// Return the error if it's not because the database doesn't
+		// exist.
+		if dbErr, ok := err.(database.Error); !ok || dbErr.ErrorCode !=
+			database.ErrDbDoesNotExist {
+
+			return nil, err
+		}
+
+		// Create the db if it does not exist.
+		err = os.MkdirAll(cfg.DataDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	btcdLog.Info("Light client database loaded")
+	return db, nil
+}
+
+// spvMain runs the node as a light client until the passed channel is closed.
+func spvMain(interruptedChan <-chan struct{}) error {
+	db, err := loadSPVDB()
+	if err != nil {
+		btcdLog.Errorf("%v", err)
+		return err
+	}
+	defer func() {
+		btcdLog.Infof("Gracefully shutting down the database...")
+		if err := db.Close(); err != nil {
+			btcdLog.Errorf("Unable to close the database: %v", err)
+		}
+	}()
+
+	node, err := newSPVNode(db)
+	if err != nil {
+		btcdLog.Errorf("Unable to start the light client: %v", err)
+		return err
+	}
+	node.Start()
+	defer func() {
+		btcdLog.Infof("Gracefully shutting down the light client...")
+		node.Stop()
+		srvrLog.Infof("Light client shutdown complete")
+	}()
+
+	<-interruptedChan
+	return nil
+}
+
+// newSPVNode returns a light client node syncing the chain stored in the
+// passed database from the peers specified with --connect or --addpeer.
+func newSPVNode(db database.DB) (*spvNode, error) {
+	chain, err := spv.New(&spv.Config{
+		DB:          db,
+		ChainParams: activeNetParams.Params,
+		TimeSource:  blockchain.NewMedianTime(),
+		Addresses:   cfg.spvWatchAddrs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n := &spvNode{
+		chain:   chain,
+		peers:   make(map[*peer.Peer]struct{}),
+		msgChan: make(chan interface{}, cfg.MaxPeers),
+		quit:    make(chan struct{}),
+	}
+
+	cmgr, err := connmgr.New(&connmgr.Config{
+		RetryDuration: connectionRetryInterval,
+		Dial:          btcdDial,
+		OnConnection:  n.outboundPeerConnected,
+	})
+	if err != nil {
+		return nil, err
+	}
+	n.connManager = cmgr
+
+	peers := cfg.ConnectPeers
+	if len(peers) == 0 {
+		peers = cfg.AddPeers
+	}
+	for _, addr := range peers {
+		netAddr, err := addrStringToNetAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		go n.connManager.Connect(&connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		})
+	}
+
+	if !cfg.DisableRPC {
+		n.rpcServer, err = newSPVRPCServer(cfg.RPCListeners, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// Start begins syncing the chain and serving RPCs.
+func (n *spvNode) Start() {
+	if atomic.AddInt32(&n.started, 1) != 1 {
+		return
+	}
+
+	srvrLog.Trace("Starting light client")
+	n.wg.Add(1)
+	go n.syncHandler()
+	n.connManager.Start()
+
+	if n.rpcServer != nil {
+		n.rpcServer.Start()
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			select {
+			case <-n.rpcServer.RequestedProcessShutdown():
+				shutdownRequestChannel <- struct{}{}
+			case <-n.quit:
+			}
+		}()
+	}
+}
+
+// Stop stops the RPC server, disconnects the peers and waits for the sync
+// handler to finish.
+func (n *spvNode) Stop() {
+	if atomic.AddInt32(&n.shutdown, 1) != 1 {
+		return
+	}
+
+	if n.rpcServer != nil {
+		n.rpcServer.Stop()
+	}
+	n.connManager.Stop()
+	n.peersMtx.RLock()
+	for p := range n.peers {
+		p.Disconnect()
+	}
+	n.peersMtx.RUnlock()
+	close(n.quit)
+	n.wg.Wait()
+}
+
+// outboundPeerConnected is invoked by the connection manager when a new
+// outbound connection is established.  It creates the peer and associates it
+// with the connection.
+func (n *spvNode) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				n.queue(&spvPeerMsg{peer: p})
+			},
+			OnHeaders: func(p *peer.Peer, msg *wire.MsgHeaders) {
+				n.queue(&spvHeadersMsg{peer: p, headers: msg})
+			},
+			OnInv: func(p *peer.Peer, msg *wire.MsgInv) {
+				n.queue(&spvInvMsg{peer: p, inv: msg})
+			},
+			OnCFilter: func(p *peer.Peer, msg *wire.MsgCFilter) {
+				n.queue(&spvCFilterMsg{peer: p, filter: msg})
+			},
+			OnBlock: func(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+				n.queue(&spvBlockMsg{peer: p, block: msg})
+			},
+		},
+		NewestBlock: func() (*chainhash.Hash, uint32, error) {
+			hash, height := n.chain.VerifiedTip()
+			return hash, height, nil
+		},
+		Proxy:            cfg.Proxy,
+		UserAgentName:    userAgentName,
+		UserAgentVersion: userAgentVersion,
+		ChainParams:      activeNetParams.Params,
+		DisableRelayTx:   true,
+		ProtocolVersion:  wire.HeartbeatVersion,
+	}
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
+	if err != nil {
+		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
+		n.connManager.Disconnect(c.ID())
+		return
+	}
+	p.AssociateConnection(conn)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		p.WaitForDisconnect()
+		n.queue(&spvPeerMsg{peer: p, done: true})
+		n.connManager.Disconnect(c.ID())
+	}()
+}
+
+// queue passes the passed message to the sync handler unless the node is
+// shutting down.
+func (n *spvNode) queue(msg interface{}) {
+	select {
+	case n.msgChan <- msg:
+	case <-n.quit:
+	}
+}
+
+// ConnectedCount returns the number of peers which completed the handshake.
+func (n *spvNode) ConnectedCount() int32 {
+	n.peersMtx.RLock()
+	defer n.peersMtx.RUnlock()
+
+	return int32(len(n.peers))
+}
+
+// RelayTransaction sends the passed transaction to every connected peer.  It
+// returns the number of peers it was sent to.
+func (n *spvNode) RelayTransaction(tx *wire.MsgTx) (int, error) {
+	n.peersMtx.RLock()
+	defer n.peersMtx.RUnlock()
+
+	if len(n.peers) == 0 {
+		return 0, errors.New("no peer is connected")
+	}
+	for p := range n.peers {
+		p.QueueMessage(tx, nil)
+	}
+	return len(n.peers), nil
+}
+
+// syncHandler handles the messages of the peers, keeping the chain in sync
+// with the sync peer.  It must be run as a goroutine.
+func (n *spvNode) syncHandler() {
+	defer n.wg.Done()
+
+	state := &syncState{
+		filters: make(map[chainhash.Hash][]byte),
+		blocks:  make(map[chainhash.Hash]*wire.MsgBlock),
+	}
+	ticker := time.NewTicker(spvStallTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-n.msgChan:
+			switch msg := m.(type) {
+			case *spvPeerMsg:
+				n.handlePeerMsg(state, msg)
+
+			case *spvHeadersMsg:
+				n.handleHeadersMsg(state, msg)
+
+			case *spvInvMsg:
+				n.handleInvMsg(state, msg)
+
+			case *spvCFilterMsg:
+				n.handleCFilterMsg(state, msg)
+
+			case *spvBlockMsg:
+				n.handleBlockMsg(state, msg)
+			}
+
+		case <-ticker.C:
+			pending := state.headersRequested ||
+				state.filtersStop != nil || len(state.blocks) > 0
+			if state.peer != nil && pending &&
+				time.Since(state.lastProgress) > spvStallTimeout {
+
+				srvrLog.Infof("Sync peer %v stalled, disconnecting",
+					state.peer)
+				state.peer.Disconnect()
+			}
+
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// handlePeerMsg keeps track of the peers which completed the handshake or
+// disconnected, and picks a new sync peer when needed.
+func (n *spvNode) handlePeerMsg(state *syncState, msg *spvPeerMsg) {
+	if msg.done {
+		n.peersMtx.Lock()
+		delete(n.peers, msg.peer)
+		n.peersMtx.Unlock()
+		if state.peer == msg.peer {
+			n.resetSync(state)
+			n.pickSyncPeer(state)
+		}
+		return
+	}
+
+	// Full peers must serve the committed filters.
+	if msg.peer.Services()&wire.SFNodeCF != wire.SFNodeCF {
+		srvrLog.Infof("Peer %v does not serve committed filters, "+
+			"disconnecting", msg.peer)
+		msg.peer.Disconnect()
+		return
+	}
+	srvrLog.Infof("New peer %v", msg.peer)
+	n.peersMtx.Lock()
+	n.peers[msg.peer] = struct{}{}
+	n.peersMtx.Unlock()
+	if state.peer == nil {
+		n.pickSyncPeer(state)
+	}
+}
+
+// resetSync drops the sync peer along with the outstanding requests to it.
+func (n *spvNode) resetSync(state *syncState) {
+	state.peer = nil
+	state.headersRequested = false
+	state.filtersStop = nil
+	state.filters = make(map[chainhash.Hash][]byte)
+	state.blocks = make(map[chainhash.Hash]*wire.MsgBlock)
+}
+
+// pickSyncPeer picks a connected peer as the sync peer, if any, and requests
+// the headers after the tip of the chain from it.
+func (n *spvNode) pickSyncPeer(state *syncState) {
+	n.peersMtx.RLock()
+	for p := range n.peers {
+		if p.Connected() {
+			state.peer = p
+			break
+		}
+	}
+	n.peersMtx.RUnlock()
+	if state.peer == nil {
+		return
+	}
+	srvrLog.Infof("Syncing headers from %v", state.peer)
+	n.requestHeaders(state)
+}
+
+// requestHeaders requests the headers after the tip of the chain from the sync
+// peer.
+func (n *spvNode) requestHeaders(state *syncState) {
+	err := state.peer.PushGetHeadersMsg(n.chain.BlockLocator(),
+		&zeroHash)
+	if err != nil {
+		srvrLog.Warnf("Failed to request headers from %v: %v",
+			state.peer, err)
+		return
+	}
+	state.headersRequested = true
+	state.lastProgress = time.Now()
+}
+
+// handleHeadersMsg adds the headers received from the sync peer to the chain,
+// then requests either more headers or the filters of the blocks to verify.
+func (n *spvNode) handleHeadersMsg(state *syncState, msg *spvHeadersMsg) {
+	if msg.peer != state.peer {
+		return
+	}
+	state.headersRequested = false
+	state.lastProgress = time.Now()
+
+	_, verifiedBefore := n.chain.VerifiedTip()
+	added, err := n.chain.ProcessHeaders(msg.headers.Headers)
+	if err != nil {
+		srvrLog.Warnf("Rejected headers from %v: %v", msg.peer, err)
+		msg.peer.Disconnect()
+		return
+	}
+	if _, verified := n.chain.VerifiedTip(); verified < verifiedBefore {
+		// The filters and blocks received are those of disconnected
+		// blocks.
+		state.filtersStop = nil
+		state.filters = make(map[chainhash.Hash][]byte)
+		state.blocks = make(map[chainhash.Hash]*wire.MsgBlock)
+	}
+	if added > 0 {
+		_, height := n.chain.Tip()
+		srvrLog.Infof("Added %d headers (height %d)", added, height)
+	}
+
+	if len(msg.headers.Headers) == wire.MaxBlockHeadersPerMsg {
+		n.requestHeaders(state)
+		return
+	}
+	n.verifyBlocks(state)
+}
+
+// handleInvMsg requests the headers after the tip of the chain when a peer
+// announces a block.
+func (n *spvNode) handleInvMsg(state *syncState, msg *spvInvMsg) {
+	if state.peer == nil || state.headersRequested {
+		return
+	}
+	for _, iv := range msg.inv.InvList {
+		if iv.Type == wire.InvTypeBlock {
+			n.requestHeaders(state)
+			return
+		}
+	}
+}
+
+// handleCFilterMsg stores the filter received from the sync peer, requests its
+// block right away when it matches, and verifies the blocks which can be.
+func (n *spvNode) handleCFilterMsg(state *syncState, msg *spvCFilterMsg) {
+	if msg.peer != state.peer ||
+		msg.filter.FilterType != wire.GCSFilterRegular {
+
+		return
+	}
+	state.lastProgress = time.Now()
+
+	hash := msg.filter.BlockHash
+	state.filters[hash] = msg.filter.Data
+	if state.filtersStop != nil && *state.filtersStop == hash {
+		state.filtersStop = nil
+	}
+	match, err := n.chain.MatchFilter(&hash, msg.filter.Data)
+	if err != nil {
+		srvrLog.Warnf("Invalid filter of block %v from %v: %v", hash,
+			msg.peer, err)
+		msg.peer.Disconnect()
+		return
+	}
+	if match {
+		n.requestBlock(state, &hash)
+	}
+	n.verifyBlocks(state)
+}
+
+// requestBlock requests the block with the passed hash from the sync peer
+// unless it was already requested.
+func (n *spvNode) requestBlock(state *syncState, hash *chainhash.Hash) {
+	if _, ok := state.blocks[*hash]; ok {
+		return
+	}
+	getData := wire.NewMsgGetData()
+	getData.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+	state.peer.QueueMessage(getData, nil)
+	state.blocks[*hash] = nil
+	state.lastProgress = time.Now()
+}
+
+// handleBlockMsg stores the block received from the sync peer and verifies the
+// blocks which can be.
+func (n *spvNode) handleBlockMsg(state *syncState, msg *spvBlockMsg) {
+	hash := msg.block.BlockHash()
+	if block, ok := state.blocks[hash]; msg.peer != state.peer || !ok ||
+		block != nil {
+
+		return
+	}
+	state.blocks[hash] = msg.block
+	state.lastProgress = time.Now()
+	n.verifyBlocks(state)
+}
+
+// verifyBlocks verifies the blocks after the last verified one in order as
+// long as their filters, and their blocks when the filters match, have been
+// received, then requests the filters of the next blocks to verify.
+func (n *spvNode) verifyBlocks(state *syncState) {
+	if state.peer == nil || state.headersRequested {
+		return
+	}
+	for {
+		_, verified := n.chain.VerifiedTip()
+		hash, err := n.chain.HashByHeight(verified + 1)
+		if err != nil {
+			// All the headers are verified.
+			return
+		}
+		filter, ok := state.filters[*hash]
+		if !ok {
+			n.requestFilters(state, verified+1)
+			return
+		}
+
+		// The filter is matched again since the items it is matched
+		// against change as blocks are verified.
+		match, err := n.chain.MatchFilter(hash, filter)
+		if err != nil {
+			return
+		}
+		var block *wire.MsgBlock
+		if match {
+			block = state.blocks[*hash]
+			if block == nil {
+				n.requestBlock(state, hash)
+				return
+			}
+		}
+		err = n.chain.ConnectBlock(hash, block)
+		if err != nil {
+			srvrLog.Warnf("Failed to verify block %v (height %d) from "+
+				"%v: %v", hash, verified+1, state.peer, err)
+			state.peer.Disconnect()
+			return
+		}
+		delete(state.filters, *hash)
+		delete(state.blocks, *hash)
+		if block != nil {
+			srvrLog.Infof("Verified block %v (height %d)", hash,
+				verified+1)
+		}
+	}
+}
+
+// requestFilters requests the filters of the blocks from the passed height on
+// from the sync peer unless a request is outstanding.
+func (n *spvNode) requestFilters(state *syncState, height uint32) {
+	if state.filtersStop != nil {
+		return
+	}
+	_, tip := n.chain.Tip()
+	stopHeight := height + wire.MaxGetCFiltersReqRange - 1
+	if stopHeight > tip {
+		stopHeight = tip
+	}
+	stopHash, err := n.chain.HashByHeight(stopHeight)
+	if err != nil {
+		return
+	}
+	state.peer.QueueMessage(wire.NewMsgGetCFilters(wire.GCSFilterRegular,
+		height, stopHash), nil)
+	state.filtersStop = stopHash
+	state.lastProgress = time.Now()
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminbuilder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+var (
+	// ErrNoOperations describes an error where a transaction is built
+	// without any operations to perform.
+	ErrNoOperations = errors.New("admin transaction has no operations")
+
+	// ErrMixedThreads describes an error where the key operations of a
+	// transaction belong to different admin threads.
+	ErrMixedThreads = errors.New("key operations belong to different " +
+		"admin threads")
+
+	// ErrInvalidOutput describes an error where an output issuing funds
+	// or receiving the change of a destruction does not pay a positive
+	// amount to a Prova script.
+	ErrInvalidOutput = errors.New("output must pay a positive amount to " +
+		"a Prova script")
+
+	// ErrInvalidAmount describes an error where the amount to destroy is
+	// out of range.
+	ErrInvalidAmount = errors.New("invalid destruction amount")
+)
+
+// KeyOp describes an operation on a key of an admin key set.
+type KeyOp struct {
+	// KeySet is the key set the key is added to or revoked from.
+	KeySet btcec.KeySetType
+
+	// PubKey is the key which is added or revoked.
+	PubKey *btcec.PublicKey
+
+	// KeyID is the keyID of an ASP key.  It is ignored for the other key
+	// sets.  When adding ASP keys with NewProvisionTx, it may be left zero
+	// to have the next keyID in sequence assigned to the key.
+	KeyID btcec.KeyID
+}
+
+// KeySetThread returns the admin thread which governs the passed key set.  The
+// root key set is fixed, so it is not governed by any thread.
+func KeySetThread(keySet btcec.KeySetType) (provautil.ThreadID, error) {
+	switch keySet {
+	case btcec.ProvisionKeySet, btcec.IssueKeySet:
+		return provautil.RootThread, nil
+	case btcec.ValidateKeySet, btcec.ASPKeySet:
+		return provautil.ProvisionThread, nil
+	}
+	return 0, fmt.Errorf("key set %v can not be changed by admin "+
+		"transactions", keySet)
+}
+
+// OpScript returns the nulldata script which encodes adding the key of the
+// passed operation to its key set when isAdd is true, or revoking it
+// otherwise.
+func OpScript(isAdd bool, op *KeyOp) ([]byte, error) {
+	var addOp, revokeOp byte
+	switch op.KeySet {
+	case btcec.IssueKeySet:
+		addOp, revokeOp = txscript.AdminOpIssueKeyAdd,
+			txscript.AdminOpIssueKeyRevoke
+	case btcec.ProvisionKeySet:
+		addOp, revokeOp = txscript.AdminOpProvisionKeyAdd,
+			txscript.AdminOpProvisionKeyRevoke
+	case btcec.ValidateKeySet:
+		addOp, revokeOp = txscript.AdminOpValidateKeyAdd,
+			txscript.AdminOpValidateKeyRevoke
+	case btcec.ASPKeySet:
+		addOp, revokeOp = txscript.AdminOpASPKeyAdd,
+			txscript.AdminOpASPKeyRevoke
+	default:
+		return nil, fmt.Errorf("key set %v can not be changed by "+
+			"admin transactions", op.KeySet)
+	}
+	if op.PubKey == nil {
+		return nil, errors.New("key operation has no public key")
+	}
+
+	// The data is encoded as:
+	// <operation (1 byte)> <compressed public key (33 bytes)> [<keyID (4 bytes)>]
+	size := 1 + btcec.PubKeyBytesLenCompressed
+	if op.KeySet == btcec.ASPKeySet {
+		size += btcec.KeyIDSize
+	}
+	data := make([]byte, size)
+	data[0] = revokeOp
+	if isAdd {
+		data[0] = addOp
+	}
+	copy(data[1:], op.PubKey.SerializeCompressed())
+	if op.KeySet == btcec.ASPKeySet {
+		op.KeyID.ToAddressFormat(data[1+btcec.PubKeyBytesLenCompressed:])
+	}
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// newThreadTx returns a transaction which spends the tip of the passed thread
+// as its first input and recreates the thread as its first output.
+func newThreadTx(threadTips map[provautil.ThreadID]*wire.OutPoint, threadID provautil.ThreadID) (*wire.MsgTx, error) {
+	tip := threadTips[threadID]
+	if tip == nil {
+		return nil, fmt.Errorf("no tip known for thread %v", threadID)
+	}
+	threadScript, err := txscript.ProvaThreadScript(threadID)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(tip, nil))
+	tx.AddTxOut(wire.NewTxOut(0, threadScript))
+	return tx, nil
+}
+
+// newKeyTx returns a transaction which performs the passed key operations on
+// the thread they belong to.
+func newKeyTx(threadTips map[provautil.ThreadID]*wire.OutPoint, isAdd bool, ops []KeyOp) (*wire.MsgTx, error) {
+	if len(ops) == 0 {
+		return nil, ErrNoOperations
+	}
+	threadID, err := KeySetThread(ops[0].KeySet)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(ops); i++ {
+		opThread, err := KeySetThread(ops[i].KeySet)
+		if err != nil {
+			return nil, err
+		}
+		if opThread != threadID {
+			return nil, ErrMixedThreads
+		}
+	}
+
+	tx, err := newThreadTx(threadTips, threadID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ops {
+		script, err := OpScript(isAdd, &ops[i])
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(0, script))
+	}
+	return tx, nil
+}
+
+// NewProvisionTx returns an unsigned transaction which spends the tip of the
+// admin thread governing the key sets of the passed operations in order to add
+// their keys to those key sets.  All of the operations must belong to the same
+// thread.
+//
+// ASP keys are assigned the keyIDs following the passed last keyID of the
+// chain in the order they are added.  An error is returned when an ASP key
+// operation specifies a keyID other than the one it is assigned.
+func NewProvisionTx(threadTips map[provautil.ThreadID]*wire.OutPoint, lastKeyID btcec.KeyID, ops []KeyOp) (*wire.MsgTx, error) {
+	assigned := make([]KeyOp, len(ops))
+	copy(assigned, ops)
+	for i := range assigned {
+		op := &assigned[i]
+		if op.KeySet != btcec.ASPKeySet {
+			continue
+		}
+		lastKeyID++
+		if op.KeyID != 0 && op.KeyID != lastKeyID {
+			return nil, fmt.Errorf("ASP key %d must be assigned "+
+				"keyID %v, not %v", i, lastKeyID, op.KeyID)
+		}
+		op.KeyID = lastKeyID
+	}
+	return newKeyTx(threadTips, true, assigned)
+}
+
+// NewKeyRevokeTx returns an unsigned transaction which spends the tip of the
+// admin thread governing the key sets of the passed operations in order to
+// revoke their keys.  All of the operations must belong to the same thread,
+// and ASP key operations must specify the keyID of the key.
+func NewKeyRevokeTx(threadTips map[provautil.ThreadID]*wire.OutPoint, ops []KeyOp) (*wire.MsgTx, error) {
+	for i := range ops {
+		if ops[i].KeySet == btcec.ASPKeySet && ops[i].KeyID == 0 {
+			return nil, fmt.Errorf("ASP key %d has no keyID", i)
+		}
+	}
+	return newKeyTx(threadTips, false, ops)
+}
+
+// FreezeOpScript returns the nulldata script which encodes freezing the keyID
+// of the passed ASP key operation when isFreeze is true, or unfreezing it
+// otherwise.
+func FreezeOpScript(isFreeze bool, op *KeyOp) ([]byte, error) {
+	if op.KeySet != btcec.ASPKeySet {
+		return nil, fmt.Errorf("keyIDs of key set %v can not be frozen",
+			op.KeySet)
+	}
+	if op.PubKey == nil {
+		return nil, errors.New("key operation has no public key")
+	}
+	if op.KeyID == 0 {
+		return nil, errors.New("key operation has no keyID")
+	}
+
+	// The data is encoded as:
+	// <operation (1 byte)> <compressed public key (33 bytes)> <keyID (4 bytes)>
+	data := make([]byte, 1+btcec.PubKeyBytesLenCompressed+btcec.KeyIDSize)
+	data[0] = txscript.AdminOpKeyIDUnfreeze
+	if isFreeze {
+		data[0] = txscript.AdminOpKeyIDFreeze
+	}
+	copy(data[1:], op.PubKey.SerializeCompressed())
+	op.KeyID.ToAddressFormat(data[1+btcec.PubKeyBytesLenCompressed:])
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// NewKeyIDFreezeTx returns an unsigned transaction which spends the tip of the
+// issue thread in order to freeze the keyIDs of the passed ASP key operations
+// when isFreeze is true, or unfreeze them otherwise.  Each operation must
+// specify the keyID along with the ASP key it refers to.  Funds of frozen
+// keyIDs are recovered with destruction transactions built by NewDestroyTx.
+func NewKeyIDFreezeTx(threadTips map[provautil.ThreadID]*wire.OutPoint, isFreeze bool, ops []KeyOp) (*wire.MsgTx, error) {
+	if len(ops) == 0 {
+		return nil, ErrNoOperations
+	}
+	tx, err := newThreadTx(threadTips, provautil.IssueThread)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ops {
+		script, err := FreezeOpScript(isFreeze, &ops[i])
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(0, script))
+	}
+	return tx, nil
+}
+
+// checkProvaOutput returns an error when the passed output does not pay a
+// positive amount to a Prova script.
+func checkProvaOutput(txOut *wire.TxOut) error {
+	if txOut.Value <= 0 || txOut.Value > provautil.MaxAtoms {
+		return ErrInvalidOutput
+	}
+	switch txscript.GetScriptClass(txOut.PkScript) {
+	case txscript.ProvaTy, txscript.GeneralProvaTy:
+		return nil
+	}
+	return ErrInvalidOutput
+}
+
+// NewIssueTx returns an unsigned transaction which spends the tip of the issue
+// thread in order to issue new funds to the passed outputs.  Each output must
+// pay a positive amount to a Prova script.
+func NewIssueTx(threadTips map[provautil.ThreadID]*wire.OutPoint, outputs []*wire.TxOut) (*wire.MsgTx, error) {
+	if len(outputs) == 0 {
+		return nil, ErrNoOperations
+	}
+	tx, err := newThreadTx(threadTips, provautil.IssueThread)
+	if err != nil {
+		return nil, err
+	}
+	for _, txOut := range outputs {
+		if err := checkProvaOutput(txOut); err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(txOut)
+	}
+	return tx, nil
+}
+
+// NewDestroyTx returns an unsigned transaction which spends the tip of the
+// issue thread along with the passed inputs in order to destroy the passed
+// amount.  The destroyed amount is bound in a nulldata output, and the passed
+// change outputs, which must each pay a positive amount to a Prova script,
+// follow it.  The caller is responsible for ensuring the value of the inputs
+// covers the destroyed amount and the change.
+func NewDestroyTx(threadTips map[provautil.ThreadID]*wire.OutPoint, inputs []*wire.OutPoint, amount int64, change []*wire.TxOut) (*wire.MsgTx, error) {
+	if len(inputs) == 0 {
+		return nil, ErrNoOperations
+	}
+	if amount <= 0 || amount > provautil.MaxAtoms {
+		return nil, ErrInvalidAmount
+	}
+	tx, err := newThreadTx(threadTips, provautil.IssueThread)
+	if err != nil {
+		return nil, err
+	}
+	for _, prevOut := range inputs {
+		tx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(amount, []byte{txscript.OP_RETURN}))
+	for _, txOut := range change {
+		if err := checkProvaOutput(txOut); err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(txOut)
+	}
+	return tx, nil
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminbuilder_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txrules/adminbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// threadTips returns a distinct tip for each admin thread.
+func threadTips() map[provautil.ThreadID]*wire.OutPoint {
+	return map[provautil.ThreadID]*wire.OutPoint{
+		provautil.RootThread:      wire.NewOutPoint(&chainhash.Hash{1}, 0),
+		provautil.ProvisionThread: wire.NewOutPoint(&chainhash.Hash{2}, 0),
+		provautil.IssueThread:     wire.NewOutPoint(&chainhash.Hash{3}, 0),
+	}
+}
+
+// checkAdminTx ensures the passed transaction passes the sanity checks and
+// spends the tip of the expected thread.
+func checkAdminTx(t *testing.T, name string, tx *wire.MsgTx, threadID provautil.ThreadID) {
+	if err := blockchain.CheckTransactionSanity(provautil.NewTx(tx)); err != nil {
+		t.Fatalf("%s: transaction failed sanity checks: %v", name, err)
+	}
+	if thread, _ := txscript.GetAdminDetailsMsgTx(tx); thread != int(threadID) {
+		t.Fatalf("%s: got thread %d, want %v", name, thread, threadID)
+	}
+	if tx.TxIn[0].PreviousOutPoint != *threadTips()[threadID] {
+		t.Fatalf("%s: transaction does not spend the thread tip", name)
+	}
+}
+
+// TestKeyTx ensures key operations are encoded on the thread governing their
+// key sets and that ASP keys are assigned keyIDs in sequence.
+func TestKeyTx(t *testing.T) {
+	key1, _ := btcec.NewPrivateKey(btcec.S256())
+	key2, _ := btcec.NewPrivateKey(btcec.S256())
+
+	tx, err := adminbuilder.NewProvisionTx(threadTips(), 0, []adminbuilder.KeyOp{
+		{KeySet: btcec.IssueKeySet, PubKey: key1.PubKey()},
+		{KeySet: btcec.ProvisionKeySet, PubKey: key2.PubKey()},
+	})
+	if err != nil {
+		t.Fatalf("NewProvisionTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "root thread", tx, provautil.RootThread)
+
+	tx, err = adminbuilder.NewProvisionTx(threadTips(), 5, []adminbuilder.KeyOp{
+		{KeySet: btcec.ASPKeySet, PubKey: key1.PubKey()},
+		{KeySet: btcec.ValidateKeySet, PubKey: key1.PubKey()},
+		{KeySet: btcec.ASPKeySet, PubKey: key2.PubKey(), KeyID: 7},
+	})
+	if err != nil {
+		t.Fatalf("NewProvisionTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "provision thread", tx, provautil.ProvisionThread)
+
+	// Apply the operations to a key view to ensure the ASP keys were
+	// assigned the expected keyIDs.
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetLastKeyID(5)
+	keyView.SetKeyIDs(make(btcec.KeyIdMap))
+	keyView.SetKeys(make(map[btcec.KeySetType]btcec.PublicKeySet))
+	keyView.SetThreadTips(threadTips())
+	if err := blockchain.CheckTransactionOutputs(provautil.NewTx(tx), 1, keyView,
+		&chaincfg.RegressionNetParams); err != nil {
+		t.Fatalf("CheckTransactionOutputs: unexpected error: %v", err)
+	}
+	keyView.ProcessAdminOuts(provautil.NewTx(tx), 1)
+	keyIDs := keyView.KeyIDs()
+	if !keyIDs[6].IsEqual(key1.PubKey()) || !keyIDs[7].IsEqual(key2.PubKey()) {
+		t.Fatalf("ProcessAdminOuts: unexpected keyIDs %v", keyIDs)
+	}
+
+	// Revoking the ASP keys requires their keyIDs.
+	tx, err = adminbuilder.NewKeyRevokeTx(threadTips(), []adminbuilder.KeyOp{
+		{KeySet: btcec.ASPKeySet, PubKey: key1.PubKey(), KeyID: 6},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyRevokeTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "revoke", tx, provautil.ProvisionThread)
+	if err := blockchain.CheckTransactionOutputs(provautil.NewTx(tx), 1, keyView,
+		&chaincfg.RegressionNetParams); err != nil {
+		t.Fatalf("CheckTransactionOutputs: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fn   func() (*wire.MsgTx, error)
+	}{
+		{"no operations", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewProvisionTx(threadTips(), 0, nil)
+		}},
+		{"mixed threads", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewProvisionTx(threadTips(), 0, []adminbuilder.KeyOp{
+				{KeySet: btcec.IssueKeySet, PubKey: key1.PubKey()},
+				{KeySet: btcec.ValidateKeySet, PubKey: key1.PubKey()},
+			})
+		}},
+		{"root key set", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewProvisionTx(threadTips(), 0, []adminbuilder.KeyOp{
+				{KeySet: btcec.RootKeySet, PubKey: key1.PubKey()},
+			})
+		}},
+		{"out of sequence keyID", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewProvisionTx(threadTips(), 0, []adminbuilder.KeyOp{
+				{KeySet: btcec.ASPKeySet, PubKey: key1.PubKey(), KeyID: 2},
+			})
+		}},
+		{"revoke without keyID", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewKeyRevokeTx(threadTips(), []adminbuilder.KeyOp{
+				{KeySet: btcec.ASPKeySet, PubKey: key1.PubKey()},
+			})
+		}},
+		{"unknown thread tip", func() (*wire.MsgTx, error) {
+			return adminbuilder.NewKeyRevokeTx(nil, []adminbuilder.KeyOp{
+				{KeySet: btcec.IssueKeySet, PubKey: key1.PubKey()},
+			})
+		}},
+	}
+	for _, test := range tests {
+		if _, err := test.fn(); err == nil {
+			t.Errorf("%s: did not fail", test.name)
+		}
+	}
+}
+
+// TestIssueTx ensures issuance and destruction transactions are well formed.
+func TestIssueTx(t *testing.T) {
+	key, _ := btcec.NewPrivateKey(btcec.S256())
+	addr, err := provautil.NewAddressProva(
+		provautil.Hash160(key.PubKey().SerializeCompressed()),
+		[]btcec.KeyID{1, 2}, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	tx, err := adminbuilder.NewIssueTx(threadTips(), []*wire.TxOut{
+		wire.NewTxOut(1000, pkScript),
+		wire.NewTxOut(2000, pkScript),
+	})
+	if err != nil {
+		t.Fatalf("NewIssueTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "issue", tx, provautil.IssueThread)
+	if len(tx.TxIn) != 1 || len(tx.TxOut) != 3 {
+		t.Fatalf("NewIssueTx: unexpected transaction %v", tx)
+	}
+
+	inputs := []*wire.OutPoint{wire.NewOutPoint(&chainhash.Hash{4}, 1)}
+	tx, err = adminbuilder.NewDestroyTx(threadTips(), inputs, 500,
+		[]*wire.TxOut{wire.NewTxOut(500, pkScript)})
+	if err != nil {
+		t.Fatalf("NewDestroyTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "destroy", tx, provautil.IssueThread)
+	if len(tx.TxIn) != 2 || tx.TxOut[1].Value != 500 ||
+		txscript.GetScriptClass(tx.TxOut[1].PkScript) != txscript.NullDataTy {
+
+		t.Fatalf("NewDestroyTx: unexpected transaction %v", tx)
+	}
+
+	if _, err := adminbuilder.NewIssueTx(threadTips(), []*wire.TxOut{
+		wire.NewTxOut(0, pkScript),
+	}); err != adminbuilder.ErrInvalidOutput {
+		t.Fatalf("NewIssueTx: got %v, want %v", err,
+			adminbuilder.ErrInvalidOutput)
+	}
+	if _, err := adminbuilder.NewIssueTx(threadTips(), []*wire.TxOut{
+		wire.NewTxOut(1000, []byte{txscript.OP_RETURN}),
+	}); err != adminbuilder.ErrInvalidOutput {
+		t.Fatalf("NewIssueTx: got %v, want %v", err,
+			adminbuilder.ErrInvalidOutput)
+	}
+	if _, err := adminbuilder.NewDestroyTx(threadTips(), inputs, 0, nil); err != adminbuilder.ErrInvalidAmount {
+		t.Fatalf("NewDestroyTx: got %v, want %v", err,
+			adminbuilder.ErrInvalidAmount)
+	}
+	if _, err := adminbuilder.NewDestroyTx(threadTips(), nil, 500, nil); err != adminbuilder.ErrNoOperations {
+		t.Fatalf("NewDestroyTx: got %v, want %v", err,
+			adminbuilder.ErrNoOperations)
+	}
+}
+
+// TestKeyIDFreezeTx ensures keyID freeze transactions are encoded on the issue
+// thread and are applied to the frozen keyIDs of a key view.
+func TestKeyIDFreezeTx(t *testing.T) {
+	key, _ := btcec.NewPrivateKey(btcec.S256())
+	ops := []adminbuilder.KeyOp{
+		{KeySet: btcec.ASPKeySet, PubKey: key.PubKey(), KeyID: 3},
+	}
+
+	tx, err := adminbuilder.NewKeyIDFreezeTx(threadTips(), true, ops)
+	if err != nil {
+		t.Fatalf("NewKeyIDFreezeTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "freeze", tx, provautil.IssueThread)
+
+	keyView := blockchain.NewKeyViewpoint()
+	keyView.SetKeyIDs(btcec.KeyIdMap{3: key.PubKey()})
+	keyView.SetThreadTips(threadTips())
+	if err := blockchain.CheckTransactionOutputs(provautil.NewTx(tx), 1, keyView,
+		&chaincfg.RegressionNetParams); err != nil {
+		t.Fatalf("CheckTransactionOutputs: unexpected error: %v", err)
+	}
+	keyView.ProcessAdminOuts(provautil.NewTx(tx), 1)
+	if !keyView.FrozenKeyIDs()[3].IsEqual(key.PubKey()) {
+		t.Fatalf("ProcessAdminOuts: keyID 3 was not frozen")
+	}
+
+	tx, err = adminbuilder.NewKeyIDFreezeTx(threadTips(), false, ops)
+	if err != nil {
+		t.Fatalf("NewKeyIDFreezeTx: unexpected error: %v", err)
+	}
+	checkAdminTx(t, "unfreeze", tx, provautil.IssueThread)
+	if err := blockchain.CheckTransactionOutputs(provautil.NewTx(tx), 1, keyView,
+		&chaincfg.RegressionNetParams); err != nil {
+		t.Fatalf("CheckTransactionOutputs: unexpected error: %v", err)
+	}
+	keyView.ProcessAdminOuts(provautil.NewTx(tx), 2)
+	if len(keyView.FrozenKeyIDs()) != 0 {
+		t.Fatalf("ProcessAdminOuts: keyID 3 was not unfrozen")
+	}
+
+	if _, err := adminbuilder.NewKeyIDFreezeTx(threadTips(), true, nil); err != adminbuilder.ErrNoOperations {
+		t.Fatalf("NewKeyIDFreezeTx: got %v, want %v", err,
+			adminbuilder.ErrNoOperations)
+	}
+	if _, err := adminbuilder.NewKeyIDFreezeTx(threadTips(), true, []adminbuilder.KeyOp{
+		{KeySet: btcec.ASPKeySet, PubKey: key.PubKey()},
+	}); err == nil {
+		t.Fatalf("NewKeyIDFreezeTx: did not fail without keyID")
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package adminbuilder constructs admin thread transactions.
+
+Overview
+
+The admin keys of the chain govern it through transactions which spend the tip
+of one of the admin threads.  Each such transaction spends the thread output
+as its first input, recreates the thread as its first output, and encodes the
+operations it performs in the outputs which follow.  Which operations are valid
+on which thread, and how they are encoded, is fixed by the consensus rules:
+
+ - The root thread adds and revokes provision and issue keys
+ - The provision thread adds and revokes validate and ASP keys
+ - The issue thread issues new funds to Prova outputs, or destroys funds
+   spent by additional inputs
+
+Key operations are encoded as nulldata outputs of zero value holding the
+operation byte followed by the compressed public key, and for ASP keys the
+keyID the key is assigned.  ASP keys are assigned keyIDs strictly in sequence.
+
+The transactions returned by this package are unsigned.  The thread input has
+to be signed by the admin keys of the thread, and any additional inputs of a
+destruction by the keys of the outputs they spend, before the transaction can
+be relayed.
+*/
+package adminbuilder
@@ -0,0 +1,277 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// referenceFlags are the script flags understood by the reference
+// interpreter.  Scripts validated with any other flag are not supported.
+const referenceFlags = ScriptBip16 | ScriptVerifyCheckLockTimeVerify |
+	ScriptVerifyCleanStack | ScriptVerifyDERSignatures | ScriptVerifyLowS |
+	ScriptVerifySchnorr | ScriptVerifyStrictEncoding
+
+// refPushData returns the data the passed opcode pushes to the stack, and
+// whether it is a push at all.
+func refPushData(pop *parsedOpcode) ([]byte, bool) {
+	switch {
+	case pop.opcode.value == OP_0:
+		return nil, true
+	case pop.opcode.value == OP_1NEGATE:
+		return []byte{0x81}, true
+	case pop.opcode.value >= OP_1 && pop.opcode.value <= OP_16:
+		return []byte{pop.opcode.value - (OP_1 - 1)}, true
+	case pop.opcode.value <= OP_PUSHDATA4:
+		return pop.data, true
+	}
+	return nil, false
+}
+
+// refLockTime decodes the passed lock time pushed by a timelocked script.  Up
+// to 5 bytes are allowed so lock times beyond 2^31-1 can be expressed.
+func refLockTime(data []byte) (int64, error) {
+	if len(data) > 5 {
+		return 0, fmt.Errorf("lock time of %d bytes is too long", len(data))
+	}
+	var lockTime int64
+	for i, b := range data {
+		lockTime |= int64(b) << uint(8*i)
+	}
+	if len(data) > 0 && data[len(data)-1]&0x80 != 0 {
+		// The most significant bit is the sign.
+		lockTime &^= int64(0x80) << uint(8*(len(data)-1))
+		lockTime = -lockTime
+	}
+	return lockTime, nil
+}
+
+// ReferenceVerify validates the input at the passed index of the transaction
+// against the passed resolved public key script with a simplified interpreter
+// which is independent of the script engine.  It is meant to cross-check the
+// engine while connecting blocks, and only understands the resolved Prova,
+// timelocked Prova and admin thread scripts spent by signature scripts which
+// only push data.
+//
+// The first return value reports whether the script pair and flags are
+// supported.  When they are, the returned error is nil when the input is
+// valid, and describes why it is invalid otherwise.
+func ReferenceVerify(pkScript []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags, inputAmount int64) (bool, error) {
+	if flags&^referenceFlags != 0 {
+		return false, nil
+	}
+	if txIdx < 0 || txIdx >= len(tx.TxIn) {
+		return true, fmt.Errorf("input index %d out of range", txIdx)
+	}
+	txIn := tx.TxIn[txIdx]
+
+	// Both scripts must parse, and the signature script must only push
+	// data.
+	if len(pkScript) > MaxScriptSize || len(txIn.SignatureScript) > MaxScriptSize {
+		return true, errors.New("script too big")
+	}
+	pkPops, err := ParseScript(pkScript)
+	if err != nil {
+		return true, err
+	}
+	sigPops, err := ParseScript(txIn.SignatureScript)
+	if err != nil {
+		return true, err
+	}
+	if !isPushOnly(sigPops) {
+		return false, nil
+	}
+
+	// Match the public key script against the templates:
+	// [<locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP] OP_m <hashes> OP_n OP_CHECKSAFEMULTISIG
+	// OP_m <hashes> OP_n OP_CHECKTHREAD
+	var lockTimeData []byte
+	hasLockTime := len(pkPops) > 3 &&
+		pkPops[1].opcode.value == OP_CHECKLOCKTIMEVERIFY &&
+		pkPops[2].opcode.value == OP_DROP
+	if hasLockTime {
+		data, ok := refPushData(&pkPops[0])
+		if !ok {
+			return false, nil
+		}
+		lockTimeData = data
+		pkPops = pkPops[3:]
+	}
+	if len(pkPops) < 3 || !isSmallInt(pkPops[0].opcode) ||
+		!isSmallInt(pkPops[len(pkPops)-2].opcode) {
+
+		return false, nil
+	}
+	switch pkPops[len(pkPops)-1].opcode.value {
+	case OP_CHECKSAFEMULTISIG:
+	case OP_CHECKTHREAD:
+		if hasLockTime {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+	numSigs := asSmallInt(pkPops[0].opcode)
+	numKeyHashes := asSmallInt(pkPops[len(pkPops)-2].opcode)
+	hashPops := pkPops[1 : len(pkPops)-2]
+	if len(hashPops) != numKeyHashes {
+		return false, nil
+	}
+	keyHashes := make([][]byte, 0, numKeyHashes)
+	for i := range hashPops {
+		data, ok := refPushData(&hashPops[i])
+		if !ok {
+			return false, nil
+		}
+		keyHashes = append(keyHashes, data)
+	}
+
+	// Collect the items pushed by the signature script.
+	var items [][]byte
+	for i := range sigPops {
+		data, ok := refPushData(&sigPops[i])
+		if !ok {
+			return true, errors.New("signature script executes a " +
+				"reserved opcode")
+		}
+		if len(data) > MaxScriptElementSize {
+			return true, errors.New("signature script element too big")
+		}
+		items = append(items, data)
+	}
+	if len(items)+numKeyHashes+2 > MaxStackSize {
+		return true, errors.New("stack size exceeded")
+	}
+
+	// Verify the lock time.
+	if hasLockTime && flags&ScriptVerifyCheckLockTimeVerify != 0 {
+		lockTime, err := refLockTime(lockTimeData)
+		if err != nil {
+			return true, err
+		}
+		if lockTime < 0 {
+			return true, errors.New("negative lock time")
+		}
+		txLockTime := int64(tx.LockTime)
+		if (txLockTime < LockTimeThreshold) != (lockTime < LockTimeThreshold) {
+			return true, errors.New("mismatched lock time types")
+		}
+		if lockTime > txLockTime {
+			return true, errors.New("lock time not reached")
+		}
+		if txIn.Sequence == wire.MaxTxInSequenceNum {
+			return true, errors.New("input is finalized")
+		}
+	}
+
+	// Verify the signatures.
+	for _, keyHash := range keyHashes {
+		if len(keyHash) != 20 {
+			return true, errors.New("invalid key hash")
+		}
+	}
+	if numSigs > numKeyHashes {
+		return true, errors.New("more signatures than key hashes")
+	}
+	if 2*numSigs > len(items) {
+		return true, errors.New("too few signatures")
+	}
+	var sigHashes *TxSigHashes
+	matched := make([]bool, len(keyHashes))
+	for i := 0; i < numSigs; i++ {
+		// Pairs are consumed from the top of the stack, each with the
+		// signature above the public key.
+		rawSig := items[len(items)-1-2*i]
+		pubKey := items[len(items)-2-2*i]
+		if len(rawSig) == 0 {
+			return true, errors.New("empty signature")
+		}
+
+		// Each public key must match a distinct key hash.
+		keyHash := provautil.Hash160(pubKey)
+		found := false
+		for j := range keyHashes {
+			if !matched[j] && bytes.Equal(keyHash, keyHashes[j]) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true, fmt.Errorf("public key %x does not match a "+
+				"key hash", pubKey)
+		}
+
+		// The encoding rules are shared with the engine.
+		hashType := SigHashType(rawSig[len(rawSig)-1])
+		sig := rawSig[:len(rawSig)-1]
+		isSchnorr := flags&ScriptVerifySchnorr != 0 &&
+			len(sig) == btcec.SchnorrSigLen
+		encoding := Engine{flags: flags}
+		if err := encoding.checkHashTypeEncoding(hashType); err != nil {
+			return true, err
+		}
+		if !isSchnorr {
+			if err := encoding.checkSignatureEncoding(sig); err != nil {
+				return true, err
+			}
+		}
+		if err := encoding.checkPubKeyEncoding(pubKey); err != nil {
+			return true, err
+		}
+		parsedPubKey, err := btcec.ParsePubKey(pubKey, btcec.S256())
+		if err != nil {
+			return true, err
+		}
+
+		if sigHashes == nil {
+			sigHashes = NewTxSigHashes(tx)
+		}
+		hash, err := CalcSignatureHashNew(tx, txIdx, sigHashes, hashType,
+			inputAmount)
+		if err != nil {
+			return true, err
+		}
+
+		var valid bool
+		switch {
+		case isSchnorr:
+			schnorrSig, err := btcec.ParseSchnorrSignature(sig)
+			if err != nil {
+				return true, err
+			}
+			valid = schnorrSig.Verify(hash, parsedPubKey)
+		case flags&(ScriptVerifyStrictEncoding|ScriptVerifyDERSignatures) != 0:
+			parsedSig, err := btcec.ParseDERSignature(sig, btcec.S256())
+			if err != nil {
+				return true, err
+			}
+			valid = parsedSig.Verify(hash, parsedPubKey)
+		default:
+			parsedSig, err := btcec.ParseSignature(sig, btcec.S256())
+			if err != nil {
+				return true, err
+			}
+			valid = parsedSig.Verify(hash, parsedPubKey)
+		}
+		if !valid {
+			return true, fmt.Errorf("invalid signature for public "+
+				"key %x", pubKey)
+		}
+	}
+
+	// The signature script items which were not consumed remain below the
+	// successful result.
+	if flags&ScriptVerifyCleanStack != 0 && len(items) != 2*numSigs {
+		return true, errors.New("stack not clean")
+	}
+	return true, nil
+}
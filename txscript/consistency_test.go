@@ -0,0 +1,144 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestReferenceVerify ensures the reference interpreter agrees with the
+// engine on valid and invalid spends of resolved Prova scripts.
+func TestReferenceVerify(t *testing.T) {
+	key1, _ := btcec.NewPrivateKey(btcec.S256())
+	key2, _ := btcec.NewPrivateKey(btcec.S256())
+	key3, _ := btcec.NewPrivateKey(btcec.S256())
+	pubKey1 := key1.PubKey().SerializeCompressed()
+	pubKey2 := key2.PubKey().SerializeCompressed()
+	const amount = 1000
+	const flags = ScriptBip16 | ScriptVerifyDERSignatures |
+		ScriptVerifyCheckLockTimeVerify
+
+	multiSig := func(b *ScriptBuilder) ([]byte, error) {
+		return b.AddOp(OP_2).AddData(provautil.Hash160(pubKey1)).
+			AddData(provautil.Hash160(pubKey2)).AddOp(OP_2).
+			AddOp(OP_CHECKSAFEMULTISIG).Script()
+	}
+	pkScript, err := multiSig(NewScriptBuilder())
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	lockScript, err := multiSig(NewScriptBuilder().AddInt64(100).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP))
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+
+	// spend returns a transaction spending an output with the passed
+	// script, signed by the passed keys.
+	spend := func(script []byte, lockTime uint32, keys ...*btcec.PrivateKey) *wire.MsgTx {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+		tx.TxIn[0].Sequence = 0
+		tx.AddTxOut(wire.NewTxOut(amount-100, []byte{OP_TRUE}))
+		tx.LockTime = lockTime
+		builder := NewScriptBuilder()
+		for _, key := range keys {
+			sig, err := RawTxInSignatureNew(tx, 0, NewTxSigHashes(tx),
+				amount, script, SigHashAll, key)
+			if err != nil {
+				t.Fatalf("RawTxInSignatureNew: unexpected error: %v", err)
+			}
+			builder.AddData(key.PubKey().SerializeCompressed()).AddData(sig)
+		}
+		tx.TxIn[0].SignatureScript, _ = builder.Script()
+		return tx
+	}
+
+	tampered := spend(pkScript, 0, key1, key2)
+	tampered.TxOut[0].Value--
+	tests := []struct {
+		name   string
+		script []byte
+		tx     *wire.MsgTx
+		valid  bool
+	}{
+		{"valid", pkScript, spend(pkScript, 0, key1, key2), true},
+		{"swapped keys", pkScript, spend(pkScript, 0, key2, key1), true},
+		{"same key twice", pkScript, spend(pkScript, 0, key1, key1), false},
+		{"unknown key", pkScript, spend(pkScript, 0, key1, key3), false},
+		{"too few signatures", pkScript, spend(pkScript, 0, key1), false},
+		{"tampered", pkScript, tampered, false},
+		{"lock time reached", lockScript, spend(lockScript, 100, key1, key2), true},
+		{"lock time not reached", lockScript, spend(lockScript, 99, key1, key2), false},
+		{"lock time type", lockScript, spend(lockScript, LockTimeThreshold, key1, key2), false},
+	}
+	for _, test := range tests {
+		supported, refErr := ReferenceVerify(test.script, test.tx, 0,
+			flags, amount)
+		if !supported {
+			t.Errorf("%s: script not supported", test.name)
+			continue
+		}
+		if (refErr == nil) != test.valid {
+			t.Errorf("%s: reference got %v, want valid %v", test.name,
+				refErr, test.valid)
+		}
+
+		vm, err := NewEngine(test.script, test.tx, 0, flags, nil, nil,
+			amount)
+		if err != nil {
+			t.Fatalf("%s: NewEngine: unexpected error: %v", test.name, err)
+		}
+		if err := vm.Execute(); (err == nil) != test.valid {
+			t.Errorf("%s: engine got %v, want valid %v", test.name,
+				err, test.valid)
+		}
+	}
+
+	// Schnorr signatures are only valid with the Schnorr flag set.
+	schnorrTx := spend(pkScript, 0)
+	builder := NewScriptBuilder()
+	for _, key := range []*btcec.PrivateKey{key1, key2} {
+		sig, err := RawTxInSchnorrSignature(schnorrTx, 0,
+			NewTxSigHashes(schnorrTx), amount, SigHashAll, key)
+		if err != nil {
+			t.Fatalf("RawTxInSchnorrSignature: unexpected error: %v", err)
+		}
+		builder.AddData(key.PubKey().SerializeCompressed()).AddData(sig)
+	}
+	schnorrTx.TxIn[0].SignatureScript, _ = builder.Script()
+	for _, testFlags := range []ScriptFlags{flags, flags | ScriptVerifySchnorr} {
+		wantValid := testFlags&ScriptVerifySchnorr != 0
+		_, refErr := ReferenceVerify(pkScript, schnorrTx, 0, testFlags,
+			amount)
+		if (refErr == nil) != wantValid {
+			t.Errorf("schnorr: reference got %v, want valid %v", refErr,
+				wantValid)
+		}
+		vm, err := NewEngine(pkScript, schnorrTx, 0, testFlags, nil, nil,
+			amount)
+		if err != nil {
+			t.Fatalf("schnorr: NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); (err == nil) != wantValid {
+			t.Errorf("schnorr: engine got %v, want valid %v", err,
+				wantValid)
+		}
+	}
+
+	// Scripts outside the templates and unknown flags are not supported.
+	tx := spend(pkScript, 0, key1, key2)
+	if supported, _ := ReferenceVerify([]byte{OP_TRUE}, tx, 0, flags, amount); supported {
+		t.Error("ReferenceVerify: supported non-Prova script")
+	}
+	if supported, _ := ReferenceVerify(pkScript, tx, 0, ScriptVerifyNullFail, amount); supported {
+		t.Error("ReferenceVerify: supported unknown flags")
+	}
+}
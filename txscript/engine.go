@@ -10,6 +10,7 @@ import (
 	"math/big"
 
 	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -74,6 +75,18 @@ const (
 	// ScriptVerifyStrictEncoding defines that signature scripts and
 	// public keys must follow the strict encoding requirements.
 	ScriptVerifyStrictEncoding
+
+	// ScriptVerifySchnorr defines that signatures checked by
+	// OP_CHECKSAFEMULTISIG and OP_CHECKTHREAD which are exactly 64 bytes,
+	// excluding the hash type, are Schnorr signatures rather than ECDSA
+	// signatures.
+	ScriptVerifySchnorr
+
+	// ScriptVerifyGeneralProva defines that the keyIDs of generalized m-of-n
+	// Prova scripts are resolved to the hashes of the ASP keys they refer to
+	// before the scripts are executed, so outputs to these scripts can be
+	// spent.
+	ScriptVerifyGeneralProva
 )
 
 const (
@@ -83,6 +96,18 @@ const (
 
 	// MaxScriptSize is the maximum allowed length of a raw script.
 	MaxScriptSize = 10000
+
+	// MaxTraceSize is the maximum number of bytes the stacks recorded by
+	// the steps of a trace may hold in total.  Every step records a copy
+	// of both stacks, so without a limit the trace of a script which keeps
+	// large elements on the stack takes up far more memory than executing
+	// it.  Each stack element is counted along with the size of a slice
+	// header, so stacks of many empty elements are limited as well.
+	MaxTraceSize = 16 * 1024 * 1024
+
+	// traceElementOverhead is the number of bytes every stack element
+	// recorded by a trace step is counted with in addition to its data.
+	traceElementOverhead = 24
 )
 
 // halforder is used to tame ECDSA malleability (see BIP0062).
@@ -106,6 +131,46 @@ type Engine struct {
 	bip16           bool     // treat execution as pay-to-script-hash
 	savedFirstStack [][]byte // stack from first script for bip16 scripts
 	inputAmount     int64
+	tracing         bool
+	traceSteps      []TraceStep
+	traceSize       int
+	sigBatch        *SigBatch
+	digestCache     *HashCache
+	txHash          *chainhash.Hash
+}
+
+// TraceStep houses the state of the script engine after stepping through a
+// single opcode while tracing is enabled.
+type TraceStep struct {
+	// ScriptIdx and ScriptOff identify the opcode which was stepped
+	// through.  Index 0 is the signature script and 1 is the public key
+	// script.
+	ScriptIdx int
+	ScriptOff int
+
+	// Opcode is the disassembly of the opcode.
+	Opcode string
+
+	// Executed indicates whether the opcode was stepped through in an
+	// executing branch, as opposed to being skipped due to an enclosing
+	// conditional.
+	Executed bool
+
+	// Stack and AltStack are the contents of the data and alternate stacks
+	// after the opcode was stepped through, where the last item in each is
+	// the top of the stack.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// CondStack is the conditional execution state after the opcode was
+	// stepped through, where the last item refers to the innermost
+	// conditional.  Each item is one of OpCondFalse, OpCondTrue or
+	// OpCondSkip.
+	CondStack []int
+
+	// Err is the error which caused execution to fail at the opcode, if
+	// any.
+	Err error
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -291,12 +356,16 @@ func (vm *Engine) Step() (done bool, err error) {
 		return true, err
 	}
 	opcode := &vm.scripts[vm.scriptIdx][vm.scriptOff]
+	executing := vm.isBranchExecuting()
 
 	// Execute the opcode while taking into account several things such as
 	// disabled opcodes, illegal opcodes, maximum allowed operations per
 	// script, maximum script element sizes, and conditionals.
 	err = vm.executeOpcode(opcode)
 	if err != nil {
+		// The error of the opcode takes precedence over the trace
+		// exceeding its maximum size.
+		_ = vm.traceStep(executing, err)
 		return true, err
 	}
 
@@ -306,7 +375,12 @@ func (vm *Engine) Step() (done bool, err error) {
 	if combinedStackSize > MaxStackSize {
 		str := fmt.Sprintf("combined stack size %d > max allowed %d",
 			combinedStackSize, MaxStackSize)
-		return false, scriptError(ErrStackOverflow, str)
+		err := scriptError(ErrStackOverflow, str)
+		_ = vm.traceStep(executing, err)
+		return false, err
+	}
+	if err := vm.traceStep(executing, nil); err != nil {
+		return true, err
 	}
 
 	// Prepare for next instruction.
@@ -603,6 +677,76 @@ func setStack(stack *stack, data [][]byte) {
 	}
 }
 
+// traceStep records the state of the engine after stepping through the opcode
+// at the current program counter when tracing is enabled.  It returns
+// ErrTraceTooLarge without recording the step when the stacks recorded by the
+// trace would exceed MaxTraceSize.
+func (vm *Engine) traceStep(executed bool, err error) error {
+	if !vm.tracing {
+		return nil
+	}
+
+	size := 0
+	for _, stk := range [][][]byte{vm.dstack.stk, vm.astack.stk} {
+		for _, data := range stk {
+			size += len(data) + traceElementOverhead
+		}
+	}
+	if vm.traceSize+size > MaxTraceSize {
+		str := fmt.Sprintf("trace size exceeds max allowed %d bytes",
+			MaxTraceSize)
+		return scriptError(ErrTraceTooLarge, str)
+	}
+	vm.traceSize += size
+
+	condStack := make([]int, len(vm.condStack))
+	copy(condStack, vm.condStack)
+	vm.traceSteps = append(vm.traceSteps, TraceStep{
+		ScriptIdx: vm.scriptIdx,
+		ScriptOff: vm.scriptOff,
+		Opcode:    vm.scripts[vm.scriptIdx][vm.scriptOff].print(false),
+		Executed:  executed,
+		Stack:     vm.GetStack(),
+		AltStack:  vm.GetAltStack(),
+		CondStack: condStack,
+		Err:       err,
+	})
+	return nil
+}
+
+// EnableTrace causes the state of the engine to be recorded each time Step
+// steps through an opcode.  This is intended for debugging scripts and should
+// not be used when validating scripts due to the overhead involved.  Execution
+// fails with ErrTraceTooLarge once the recorded stacks exceed MaxTraceSize.
+func (vm *Engine) EnableTrace() {
+	vm.tracing = true
+}
+
+// Trace returns the steps recorded since tracing was enabled in the order they
+// were stepped through.
+func (vm *Engine) Trace() []TraceStep {
+	return vm.traceSteps
+}
+
+// DeferSignatures causes the signatures checked by OP_CHECKSAFEMULTISIG which
+// are not already in the signature cache to be treated as valid and queued in
+// the passed batch instead of being verified immediately.  The caller must
+// verify the batch once the script has executed, and it must only be used for
+// scripts whose outcome is decided by their final signature check.  See
+// SigBatch for details.
+func (vm *Engine) DeferSignatures(batch *SigBatch) {
+	vm.sigBatch = batch
+}
+
+// UseHashCache causes the signature hash digests calculated for the input to be
+// looked up in and added to the passed cache under the passed hash of the
+// transaction, which the caller typically has at hand already.  The digests are
+// only added when the partial sighashes of the transaction are cached.
+func (vm *Engine) UseHashCache(cache *HashCache, txHash *chainhash.Hash) {
+	vm.digestCache = cache
+	vm.txHash = txHash
+}
+
 // GetStack returns the contents of the primary stack as an array. where the
 // last item in the array is the top of the stack.
 func (vm *Engine) GetStack() [][]byte {
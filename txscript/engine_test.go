@@ -6,6 +6,7 @@
 package txscript
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
@@ -433,3 +434,111 @@ func TestCheckSignatureEncoding(t *testing.T) {
 		}
 	}
 }
+
+// TestTrace ensures the engine records the state after every opcode when
+// tracing is enabled, including opcodes in non-executing branches and the
+// opcode which caused execution to fail.
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			SignatureScript:  mustParseShortForm("1 0"),
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+	pkScript := mustParseShortForm("IF 2 ELSE TOALTSTACK ENDIF VERIFY")
+
+	vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	vm.EnableTrace()
+	err = vm.Execute()
+	if !IsErrorCode(err, ErrInvalidStackOperation) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []TraceStep{
+		{0, 0, "OP_1", true, [][]byte{{1}}, [][]byte{}, []int{}, nil},
+		{0, 1, "OP_0", true, [][]byte{{1}, nil}, [][]byte{}, []int{}, nil},
+		{1, 0, "OP_IF", true, [][]byte{{1}}, [][]byte{},
+			[]int{OpCondFalse}, nil},
+		{1, 1, "OP_2", false, [][]byte{{1}}, [][]byte{},
+			[]int{OpCondFalse}, nil},
+		{1, 2, "OP_ELSE", false, [][]byte{{1}}, [][]byte{},
+			[]int{OpCondTrue}, nil},
+		{1, 3, "OP_TOALTSTACK", true, [][]byte{}, [][]byte{{1}},
+			[]int{OpCondTrue}, nil},
+		{1, 4, "OP_ENDIF", true, [][]byte{}, [][]byte{{1}}, []int{},
+			nil},
+		{1, 5, "OP_VERIFY", true, [][]byte{}, [][]byte{{1}}, []int{},
+			err},
+	}
+	trace := vm.Trace()
+	if len(trace) != len(want) {
+		t.Fatalf("unexpected number of steps - got %d, want %d",
+			len(trace), len(want))
+	}
+	for i, step := range trace {
+		if !reflect.DeepEqual(step, want[i]) {
+			t.Errorf("step #%d: got %+v, want %+v", i, step, want[i])
+		}
+	}
+}
+
+// TestTraceTooLarge ensures executing a script with tracing enabled fails once
+// the stacks recorded by the trace exceed the maximum allowed size, and that
+// the steps up to that point are kept.
+func TestTraceTooLarge(t *testing.T) {
+	t.Parallel()
+
+	// Keep duplicating large stack elements, so every step records more
+	// data than the one before it.
+	builder := NewScriptBuilder()
+	for i := 0; i < 18; i++ {
+		builder.AddData(make([]byte, MaxScriptElementSize))
+	}
+	for i := 0; i < MaxOpsPerScript-1; i++ {
+		builder.AddOp(OP_3DUP)
+	}
+	pkScript, err := builder.Script()
+	if err != nil {
+		t.Fatalf("failed to build script: %v", err)
+	}
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+
+	vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	vm.EnableTrace()
+	err = vm.Execute()
+	if !IsErrorCode(err, ErrTraceTooLarge) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	trace := vm.Trace()
+	if len(trace) == 0 || len(trace) >= 18+MaxOpsPerScript-1 {
+		t.Fatalf("unexpected number of steps %d", len(trace))
+	}
+	size := 0
+	for _, step := range trace {
+		for _, data := range step.Stack {
+			size += len(data) + traceElementOverhead
+		}
+	}
+	if size > MaxTraceSize {
+		t.Fatalf("trace size %d exceeds max allowed %d", size,
+			MaxTraceSize)
+	}
+}
@@ -208,6 +208,14 @@ const (
 	// reached.
 	ErrUnsatisfiedLockTime
 
+	// ErrSigBatchVerify is returned when a signature whose verification was
+	// deferred to a signature batch is invalid.
+	ErrSigBatchVerify
+
+	// ErrTraceTooLarge is returned when the stacks recorded by the steps of
+	// a trace exceed MaxTraceSize.
+	ErrTraceTooLarge
+
 	// numErrorCodes is the maximum error code number used in tests.  This
 	// entry MUST be the last entry in the enum.
 	numErrorCodes
@@ -257,6 +265,8 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrDiscourageUpgradableNOPs: "ErrDiscourageUpgradableNOPs",
 	ErrNegativeLockTime:         "ErrNegativeLockTime",
 	ErrUnsatisfiedLockTime:      "ErrUnsatisfiedLockTime",
+	ErrSigBatchVerify:           "ErrSigBatchVerify",
+	ErrTraceTooLarge:            "ErrTraceTooLarge",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -58,6 +58,8 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrDiscourageUpgradableNOPs, "ErrDiscourageUpgradableNOPs"},
 		{ErrNegativeLockTime, "ErrNegativeLockTime"},
 		{ErrUnsatisfiedLockTime, "ErrUnsatisfiedLockTime"},
+		{ErrSigBatchVerify, "ErrSigBatchVerify"},
+		{ErrTraceTooLarge, "ErrTraceTooLarge"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
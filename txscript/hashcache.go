@@ -7,6 +7,7 @@ package txscript
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/wire"
@@ -32,33 +33,121 @@ func NewTxSigHashes(tx *wire.MsgTx) *TxSigHashes {
 	}
 }
 
+// sigDigestKey identifies a signature hash digest of a transaction within a
+// hashCacheEntry.
+type sigDigestKey struct {
+	hashType SigHashType
+	idx      int
+}
+
+// sigDigest houses a cached signature hash digest along with the input amount
+// it commits to.
+type sigDigest struct {
+	amount int64
+	hash   chainhash.Hash
+}
+
+// hashCacheEntry houses the partial sighashes of a transaction along with the
+// final signature hash digests which have been calculated for its inputs.
+type hashCacheEntry struct {
+	sigHashes *TxSigHashes
+	digests   map[sigDigestKey]sigDigest
+}
+
+// HashCacheStats houses statistics about the usage of a HashCache.
+type HashCacheStats struct {
+	// Entries is the number of transactions with cached partial sighashes
+	// and MaxEntries is the maximum number allowed.
+	Entries    uint
+	MaxEntries uint
+
+	// Digests is the number of cached signature hash digests.
+	Digests uint
+
+	// Hits and Misses count the signature hash digests which were found
+	// in the cache and those which had to be calculated respectively.
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the fraction of signature hash digests which were found in
+// the cache.
+func (s *HashCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
 // HashCache houses a set of partial sighashes keyed by txid. The set of partial
 // sighashes are those introduced within BIP0143 by the new more efficient
 // sighash digest calculation algorithm. Using this threadsafe shared cache,
 // multiple goroutines can safely re-use the pre-computed partial sighashes
 // speeding up validation time amongst all inputs found within a block.
+//
+// The final signature hash digests of the inputs of a cached transaction are
+// cached along with its partial sighashes keyed by hash type and input index,
+// so the digests calculated while accepting a transaction to the mempool are
+// re-used when generating block templates and connecting the block which
+// includes it.  Since the txid does not commit to the signature scripts, the
+// digests remain valid for any signatures of the transaction.
+//
+// The number of cached transactions is limited with a randomized eviction
+// policy, which bounds the memory used by the cache since a transaction has
+// at most one digest per input for each hash type.
 type HashCache struct {
-	sigHashes map[chainhash.Hash]*TxSigHashes
+	// The following variables must only be used atomically.
+	hits   uint64
+	misses uint64
+
+	sigHashes  map[chainhash.Hash]*hashCacheEntry
+	numDigests uint
+	maxEntries uint
 
 	sync.RWMutex
 }
 
 // NewHashCache returns a new instance of the HashCache given a maximum number
-// of entries which may exist within it at anytime.
+// of entries which may exist within it at anytime.  Random entries are evicted
+// to make room for new entries that would cause the number of entries in the
+// cache to exceed the max.
 func NewHashCache(maxSize uint) *HashCache {
 	return &HashCache{
-		sigHashes: make(map[chainhash.Hash]*TxSigHashes, maxSize),
+		sigHashes:  make(map[chainhash.Hash]*hashCacheEntry, maxSize),
+		maxEntries: maxSize,
 	}
 }
 
 // AddSigHashes computes, then adds the partial sighashes for the passed
-// transaction.
+// transaction.  In the event that the HashCache is 'full', an existing entry
+// is randomly chosen to be evicted in order to make space for the new entry.
 func (h *HashCache) AddSigHashes(tx *wire.MsgTx) {
-	h.Lock()
-	defer h.Unlock()
 	sigHashes := NewTxSigHashes(tx)
 	txid := tx.TxHash()
-	h.sigHashes[txid] = sigHashes
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.maxEntries == 0 {
+		return
+	}
+	if _, exists := h.sigHashes[txid]; !exists &&
+		uint(len(h.sigHashes)+1) > h.maxEntries {
+
+		// Remove a random entry from the map relying on the random
+		// starting point of Go's map iteration as done by the
+		// SigCache.
+		for evictTxid, entry := range h.sigHashes {
+			h.numDigests -= uint(len(entry.digests))
+			delete(h.sigHashes, evictTxid)
+			break
+		}
+	}
+	if entry := h.sigHashes[txid]; entry != nil {
+		h.numDigests -= uint(len(entry.digests))
+	}
+	h.sigHashes[txid] = &hashCacheEntry{sigHashes: sigHashes}
 }
 
 // ContainsHashes returns true if the partial sighashes for the passed
@@ -79,15 +168,79 @@ func (h *HashCache) GetSigHashes(txid *chainhash.Hash) (*TxSigHashes, bool) {
 	h.RLock()
 	defer h.RUnlock()
 
-	item, found := h.sigHashes[*txid]
-	return item, found
+	entry, found := h.sigHashes[*txid]
+	if !found {
+		return nil, false
+	}
+	return entry.sigHashes, true
 }
 
-// PurgeSigHashes removes all partial sighashes from the HashCache belonging to
-// the passed transaction.
+// PurgeSigHashes removes all partial sighashes and signature hash digests from
+// the HashCache belonging to the passed transaction.
 func (h *HashCache) PurgeSigHashes(txid *chainhash.Hash) {
 	h.Lock()
 	defer h.Unlock()
 
-	delete(h.sigHashes, *txid)
+	if entry := h.sigHashes[*txid]; entry != nil {
+		h.numDigests -= uint(len(entry.digests))
+		delete(h.sigHashes, *txid)
+	}
+}
+
+// sigHash returns the signature hash digest of the passed input of the
+// transaction with the passed txid.  The digest is looked up in the cache, and
+// otherwise calculated from the passed partial sighashes and added to the
+// cache when the partial sighashes of the transaction are cached.
+func (h *HashCache) sigHash(txid *chainhash.Hash, sigHashes *TxSigHashes,
+	hashType SigHashType, tx *wire.MsgTx, idx int, amt int64) []byte {
+
+	key := sigDigestKey{hashType: hashType, idx: idx}
+	h.RLock()
+	var digest sigDigest
+	var found bool
+	if entry := h.sigHashes[*txid]; entry != nil {
+		digest, found = entry.digests[key]
+	}
+	h.RUnlock()
+	if found && digest.amount == amt {
+		atomic.AddUint64(&h.hits, 1)
+		return digest.hash[:]
+	}
+	atomic.AddUint64(&h.misses, 1)
+
+	hash := calcSignatureHashNew(nil, sigHashes, hashType, tx, idx, amt)
+	if len(hash) != chainhash.HashSize {
+		return hash
+	}
+
+	h.Lock()
+	if entry := h.sigHashes[*txid]; entry != nil {
+		if entry.digests == nil {
+			entry.digests = make(map[sigDigestKey]sigDigest)
+		}
+		if _, exists := entry.digests[key]; !exists {
+			h.numDigests++
+		}
+		digest := sigDigest{amount: amt}
+		copy(digest.hash[:], hash)
+		entry.digests[key] = digest
+	}
+	h.Unlock()
+	return hash
+}
+
+// Stats returns statistics about the usage of the HashCache.
+//
+// This function is safe for concurrent access.
+func (h *HashCache) Stats() HashCacheStats {
+	h.RLock()
+	stats := HashCacheStats{
+		Entries:    uint(len(h.sigHashes)),
+		MaxEntries: h.maxEntries,
+		Digests:    h.numDigests,
+	}
+	h.RUnlock()
+	stats.Hits = atomic.LoadUint64(&h.hits)
+	stats.Misses = atomic.LoadUint64(&h.misses)
+	return stats
 }
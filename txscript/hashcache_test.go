@@ -0,0 +1,100 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// genTestTx returns a transaction with two inputs which is distinct for each
+// passed value.
+func genTestTx(value int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{2}, 1), nil))
+	tx.AddTxOut(wire.NewTxOut(value, []byte{OP_TRUE}))
+	return tx
+}
+
+// TestHashCacheDigests ensures signature hash digests are cached for the
+// inputs of cached transactions and that the hits and misses are counted.
+func TestHashCacheDigests(t *testing.T) {
+	hashCache := NewHashCache(10)
+	tx := genTestTx(1)
+	txid := tx.TxHash()
+	sigHashes := NewTxSigHashes(tx)
+	want := calcSignatureHashNew(nil, sigHashes, SigHashAll, tx, 1, 1000)
+
+	// Digests of transactions which are not cached are calculated, but
+	// not added.
+	hash := hashCache.sigHash(&txid, sigHashes, SigHashAll, tx, 1, 1000)
+	if !bytes.Equal(hash, want) {
+		t.Fatalf("sigHash: got %x, want %x", hash, want)
+	}
+	if stats := hashCache.Stats(); stats.Digests != 0 || stats.Misses != 1 {
+		t.Fatalf("Stats: unexpected stats %+v", stats)
+	}
+
+	hashCache.AddSigHashes(tx)
+	for i := 0; i < 3; i++ {
+		hash := hashCache.sigHash(&txid, sigHashes, SigHashAll, tx, 1, 1000)
+		if !bytes.Equal(hash, want) {
+			t.Fatalf("sigHash: got %x, want %x", hash, want)
+		}
+	}
+	stats := hashCache.Stats()
+	if stats.Entries != 1 || stats.Digests != 1 || stats.Hits != 2 ||
+		stats.Misses != 2 || stats.HitRate() != 0.5 {
+
+		t.Fatalf("Stats: unexpected stats %+v", stats)
+	}
+
+	// A digest cached for a different input amount must not be used.
+	want = calcSignatureHashNew(nil, sigHashes, SigHashAll, tx, 1, 2000)
+	hash = hashCache.sigHash(&txid, sigHashes, SigHashAll, tx, 1, 2000)
+	if !bytes.Equal(hash, want) {
+		t.Fatalf("sigHash: got %x, want %x", hash, want)
+	}
+	if stats := hashCache.Stats(); stats.Hits != 2 || stats.Digests != 1 {
+		t.Fatalf("Stats: unexpected stats %+v", stats)
+	}
+
+	hashCache.PurgeSigHashes(&txid)
+	if stats := hashCache.Stats(); stats.Entries != 0 || stats.Digests != 0 {
+		t.Fatalf("Stats: unexpected stats after purge %+v", stats)
+	}
+}
+
+// TestHashCacheEviction ensures the number of cached transactions never
+// exceeds the maximum, and that a cache with a maximum of zero caches nothing.
+func TestHashCacheEviction(t *testing.T) {
+	const maxEntries = 5
+	hashCache := NewHashCache(maxEntries)
+	for i := int64(0); i < maxEntries*2; i++ {
+		tx := genTestTx(i)
+		txid := tx.TxHash()
+		hashCache.AddSigHashes(tx)
+		hashCache.sigHash(&txid, NewTxSigHashes(tx), SigHashAll, tx, 0, 1)
+		if !hashCache.ContainsHashes(&txid) {
+			t.Fatalf("AddSigHashes: transaction %d not cached", i)
+		}
+	}
+	stats := hashCache.Stats()
+	if stats.Entries != maxEntries || stats.Digests != maxEntries {
+		t.Fatalf("Stats: unexpected stats %+v", stats)
+	}
+
+	hashCache = NewHashCache(0)
+	tx := genTestTx(1)
+	txid := tx.TxHash()
+	hashCache.AddSigHashes(tx)
+	if hashCache.ContainsHashes(&txid) {
+		t.Fatal("AddSigHashes: transaction cached with max size zero")
+	}
+}
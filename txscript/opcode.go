@@ -306,6 +306,8 @@ const (
 	AdminOpValidateKeyRevoke  = 0x12 // 18
 	AdminOpASPKeyAdd          = 0x13 // 19
 	AdminOpASPKeyRevoke       = 0x14 // 20
+	AdminOpKeyIDFreeze        = 0x21 // 33
+	AdminOpKeyIDUnfreeze      = 0x22 // 34
 )
 
 // Conditional execution constants.
@@ -2277,6 +2279,20 @@ func opcodeCheckSafeMultiSig(op *parsedOpcode, vm *Engine) error {
 		hashType := SigHashType(rawSig[len(rawSig)-1])
 		signature := rawSig[:len(rawSig)-1]
 
+		if vm.hasFlag(ScriptVerifySchnorr) &&
+			len(signature) == btcec.SchnorrSigLen {
+
+			valid, err := vm.verifySchnorrSig(signature, hashType, pubKey)
+			if err != nil {
+				return err
+			}
+			if valid {
+				signatureIdx++
+				numSignatures--
+			}
+			continue
+		}
+
 		// Only parse and check the signature encoding once.
 		var parsedSig *btcec.Signature
 		if !sigInfo.parsed {
@@ -2323,26 +2339,33 @@ func opcodeCheckSafeMultiSig(op *parsedOpcode, vm *Engine) error {
 			return err
 		}
 
-		// Create a new HashCache adding the intermediate sigHashes of this
-		// tx to it.
-		sigHashes := vm.hashCache
-		if sigHashes == nil {
-			sigHashes = NewTxSigHashes(&vm.tx)
-		}
 		// Generate the signature hash based on the signature hash type.
-		hash := calcSignatureHashNew(script, sigHashes, hashType, &vm.tx, vm.txIdx, vm.inputAmount)
+		hash := vm.calcSigHash(script, hashType)
 		var valid bool
 		if vm.sigCache != nil {
 			var sigHash chainhash.Hash
 			copy(sigHash[:], hash)
 
 			valid = vm.sigCache.Exists(sigHash, parsedSig, parsedPubKey)
-			if !valid && parsedSig.Verify(hash, parsedPubKey) {
+		}
+		if !valid && vm.sigBatch != nil {
+			// Defer the verification to the batch, which is
+			// responsible for adding it to the signature cache.
+			txHash := vm.txHash
+			if txHash == nil {
+				txid := vm.tx.TxHash()
+				txHash = &txid
+			}
+			vm.sigBatch.add(hash, parsedSig, parsedPubKey, txHash,
+				vm.txIdx)
+			valid = true
+		} else if !valid && parsedSig.Verify(hash, parsedPubKey) {
+			if vm.sigCache != nil {
+				var sigHash chainhash.Hash
+				copy(sigHash[:], hash)
 				vm.sigCache.Add(sigHash, parsedSig, parsedPubKey)
-				valid = true
 			}
-		} else {
-			valid = parsedSig.Verify(hash, parsedPubKey)
+			valid = true
 		}
 
 		if valid {
@@ -2365,6 +2388,46 @@ func opcodeCheckSafeMultiSig(op *parsedOpcode, vm *Engine) error {
 	return nil
 }
 
+// calcSigHash returns the signature hash for the input being validated using
+// the passed hash type.
+func (vm *Engine) calcSigHash(script []parsedOpcode, hashType SigHashType) []byte {
+	// Create a new HashCache adding the intermediate sigHashes of this
+	// tx to it.
+	sigHashes := vm.hashCache
+	if sigHashes == nil {
+		sigHashes = NewTxSigHashes(&vm.tx)
+	}
+	if vm.digestCache != nil {
+		return vm.digestCache.sigHash(vm.txHash, sigHashes, hashType,
+			&vm.tx, vm.txIdx, vm.inputAmount)
+	}
+	return calcSignatureHashNew(script, sigHashes, hashType, &vm.tx,
+		vm.txIdx, vm.inputAmount)
+}
+
+// verifySchnorrSig returns whether the passed Schnorr signature, with the hash
+// type already split off, is a valid signature of the input being validated by
+// the passed public key.  Malformed signatures are invalid, while encoding
+// errors of the hash type or public key are returned.
+func (vm *Engine) verifySchnorrSig(signature []byte, hashType SigHashType, pubKey []byte) (bool, error) {
+	if err := vm.checkHashTypeEncoding(hashType); err != nil {
+		return false, err
+	}
+	if err := vm.checkPubKeyEncoding(pubKey); err != nil {
+		return false, err
+	}
+	sig, err := btcec.ParseSchnorrSignature(signature)
+	if err != nil {
+		return false, nil
+	}
+	parsedPubKey, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return false, err
+	}
+	return sig.Verify(vm.calcSigHash(vm.subScript(), hashType),
+		parsedPubKey), nil
+}
+
 // opcodeCheckMultiSig treats the top item on the stack as an integer number of
 // public keys, followed by that many entries as raw data representing the public
 // keys, followed by the integer number of signatures, followed by that many
@@ -14,6 +14,7 @@ import (
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/golangcrypto/ripemd160"
 	"time"
 )
 
@@ -267,7 +268,12 @@ func asInt32(pop parsedOpcode) (int32, error) {
 // We assume a Prova address structure like this:
 // basic: <2 hash keyID1 keyID2 3 OP_CHECKSAFEMULTISIG>
 // general: <x hash/keyID hash/keyID y OP_CHECKSAFEMULTISIG>
+// timelocked: <locktime OP_CHECKLOCKTIMEVERIFY OP_DROP> followed by basic
+//
+// Generalized scripts with more than one pubkey hash are not supported.  Use
+// ExtractGeneralKeyIDs for them.
 func ExtractKeyIDs(pkScript []parsedOpcode) ([]btcec.KeyID, error) {
+	pkScript = stripTimeLock(pkScript)
 	// the basic structure has 6 elements, as described above
 	if len(pkScript) < 6 || !isSmallInt(pkScript[len(pkScript)-2].opcode) {
 		return nil, fmt.Errorf("unable to extract keyIDs from script, "+
@@ -293,7 +299,12 @@ func ExtractKeyIDs(pkScript []parsedOpcode) ([]btcec.KeyID, error) {
 // We assume a Prova address structure like this:
 // basic: <2 hash keyID1 keyID2 3 OP_CHECKSAFEMULTISIG>
 // general: <x hash/keyID hash/keyID y OP_CHECKSAFEMULTISIG>
+// timelocked: <locktime OP_CHECKLOCKTIMEVERIFY OP_DROP> followed by basic
+//
+// Generalized scripts with more than one pubkey hash are not supported.  Use
+// ReplaceGeneralKeyIDs for them.
 func ReplaceKeyIDs(pkScript []parsedOpcode, keyIdMap map[btcec.KeyID][]byte) error {
+	pkScript = stripTimeLock(pkScript)
 	// the basic structure has 6 elements, as described above
 	if len(pkScript) < 6 || !isSmallInt(pkScript[len(pkScript)-2].opcode) {
 		return fmt.Errorf("unable to extract keyIDs from script, "+
@@ -323,6 +334,80 @@ func ReplaceKeyIDs(pkScript []parsedOpcode, keyIdMap map[btcec.KeyID][]byte) err
 	return nil
 }
 
+// generalKeyOps returns the opcodes of the passed generalized m-of-n Prova
+// pkScript which hold its pubkey hashes and keyIDs:
+// <m hash... keyID... n OP_CHECKSAFEMULTISIG>
+// It returns false when the script is of any other class, and an error when
+// the script holds anything other than pubkey hashes and keyIDs.
+func generalKeyOps(pkScript []parsedOpcode) ([]parsedOpcode, bool, error) {
+	if typeOfScript(pkScript) != GeneralProvaTy {
+		return nil, false, nil
+	}
+	if !isStrictGeneralProva(pkScript) {
+		return nil, true, fmt.Errorf("unable to extract keyIDs from "+
+			"script, unexpected script structure %v", pkScript)
+	}
+	return pkScript[1 : len(pkScript)-2], true, nil
+}
+
+// ExtractGeneralKeyIDs takes a Prova pkScript and extracts the keyIDs from it
+// like ExtractKeyIDs, except that the keyIDs of generalized m-of-n scripts are
+// extracted however many pubkey hashes precede them.
+func ExtractGeneralKeyIDs(pkScript []parsedOpcode) ([]btcec.KeyID, error) {
+	keyOps, ok, err := generalKeyOps(pkScript)
+	if !ok {
+		return ExtractKeyIDs(pkScript)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyIDs := make([]btcec.KeyID, 0, len(keyOps))
+	for _, pop := range keyOps {
+		if len(pop.data) == ripemd160.Size {
+			continue
+		}
+		keyID, err := asInt32(pop)
+		if err != nil {
+			return nil, err
+		}
+		keyIDs = append(keyIDs, btcec.KeyID(keyID))
+	}
+	return keyIDs, nil
+}
+
+// ReplaceGeneralKeyIDs replaces keyIDs in a pkScript with pubKeyHashes like
+// ReplaceKeyIDs, except that the keyIDs of generalized m-of-n scripts are
+// replaced however many pubkey hashes precede them.
+func ReplaceGeneralKeyIDs(pkScript []parsedOpcode, keyIdMap map[btcec.KeyID][]byte) error {
+	keyOps, ok, err := generalKeyOps(pkScript)
+	if !ok {
+		return ReplaceKeyIDs(pkScript, keyIdMap)
+	}
+	if err != nil {
+		return err
+	}
+	// no work to be done
+	if len(keyIdMap) == 0 {
+		return fmt.Errorf("no keyHashes provided to replace keyIDs")
+	}
+	for i := range keyOps {
+		pop := &keyOps[i]
+		if len(pop.data) == ripemd160.Size {
+			continue
+		}
+		keyID, err := asInt32(*pop)
+		if err != nil {
+			return fmt.Errorf("unable to parse keyIDs from opcode %v",
+				*pop)
+		}
+		if val, ok := keyIdMap[btcec.KeyID(keyID)]; ok {
+			pop.data = val
+			pop.opcode = &opcodeArray[OP_DATA_20]
+		}
+	}
+	return nil
+}
+
 // ExtractThreadID takes an Prova admin pkScript and extracts the threadID from it.
 // We assume an Prova admin pkScript structure like this:
 // <threadID> OP_CHECKTHREAD
@@ -335,15 +420,16 @@ func ExtractThreadID(pkScript []parsedOpcode) (provautil.ThreadID, error) {
 	return provautil.ThreadID(asSmallInt(pkScript[0].opcode)), nil
 }
 
-// ThreadPkScript creates a new pkScript with all keyHashes.
-// 2 <pkHash> ... <pkHash> X OP_CHECKTHREAD
-func ThreadPkScript(keyHashes [][]byte) ([]byte, error) {
-	if len(keyHashes) < 2 {
-		return nil, fmt.Errorf("invalid chain state, at least 2 keys required" +
-			" for thread.")
+// ThreadPkScript creates a new pkScript requiring threshold signatures from
+// the keys of all keyHashes.
+// m <pkHash> ... <pkHash> X OP_CHECKTHREAD
+func ThreadPkScript(threshold int, keyHashes [][]byte) ([]byte, error) {
+	if threshold < 1 || len(keyHashes) < threshold {
+		return nil, fmt.Errorf("invalid chain state, at least %d keys "+
+			"required for thread.", threshold)
 	}
-	// build the new pkScript with 2 of x multi-sig
-	pkScript := NewScriptBuilder().AddOp(OP_2)
+	// build the new pkScript with m of x multi-sig
+	pkScript := NewScriptBuilder().AddInt64(int64(threshold))
 	for i := range keyHashes {
 		pkScript.AddData(keyHashes[i])
 	}
@@ -419,6 +505,15 @@ func ExtractAdminOpData(pkScript []parsedOpcode) (bool, btcec.KeySetType, *btcec
 	return isAddOp, keySetType, pubKey, keyID
 }
 
+// ExtractKeyIDFreezeOpData extracts whether the passed admin operation freezes
+// or unfreezes a keyID, along with the ASP key of the keyID and the keyID.
+// The function assumes previous validation of the passed opcodes with
+// IsKeyIDFreezeOp.
+func ExtractKeyIDFreezeOpData(pkScript []parsedOpcode) (bool, *btcec.PublicKey, btcec.KeyID) {
+	op, pubKey, keyID, _ := ExtractASPData(pkScript)
+	return op == AdminOpKeyIDFreeze, pubKey, keyID
+}
+
 // AdminOpString gives a human-readable version of an admin op script.
 // The function assumes previous validation as an actual valid admin op script.
 func AdminOpString(buf []byte) string {
@@ -426,6 +521,16 @@ func AdminOpString(buf []byte) string {
 	if err != nil {
 		return ""
 	}
+	if IsKeyIDFreezeOp(opcodes) {
+		isFreeze, pubKey, keyID := ExtractKeyIDFreezeOpData(opcodes)
+		op := "UNFREEZE_KEYID"
+		if isFreeze {
+			op = "FREEZE_KEYID"
+		}
+		return fmt.Sprintf("%s %s %d", op,
+			hex.EncodeToString(pubKey.SerializeCompressed()),
+			uint32(keyID))
+	}
 	isAddOp, keySetType, pubKey, keyID := ExtractAdminOpData(opcodes)
 	op := "REVOKE_KEY"
 	if isAddOp {
@@ -705,6 +810,24 @@ func calcSignatureHashNew(subScript []parsedOpcode, sigHashes *TxSigHashes,
 	return chainhash.DoubleHashB(sigHash.Bytes())
 }
 
+// CalcSignatureHashNew returns the signature hash which the signatures of the
+// input at the passed index of the transaction commit to when spending an
+// output worth the passed amount.  The intermediate hashes are calculated from
+// the transaction when sigHashes is nil.
+func CalcSignatureHashNew(tx *wire.MsgTx, idx int, sigHashes *TxSigHashes,
+	hashType SigHashType, amt int64) ([]byte, error) {
+
+	if idx < 0 || idx >= len(tx.TxIn) {
+		str := fmt.Sprintf("transaction input index %d is out of range "+
+			"for %d inputs", idx, len(tx.TxIn))
+		return nil, scriptError(ErrInvalidIndex, str)
+	}
+	if sigHashes == nil {
+		sigHashes = NewTxSigHashes(tx)
+	}
+	return calcSignatureHashNew(nil, sigHashes, hashType, tx, idx, amt), nil
+}
+
 // asSmallInt returns the passed opcode, which must be true according to
 // isSmallInt(), as an integer.
 func asSmallInt(op *opcode) int {
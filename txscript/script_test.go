@@ -4163,3 +4163,31 @@ func TestIsUnspendable(t *testing.T) {
 		}
 	}
 }
+
+// TestThreadPkScript ensures ThreadPkScript builds scripts requiring the passed
+// threshold of signatures and rejects thresholds the keys can't reach.
+func TestThreadPkScript(t *testing.T) {
+	t.Parallel()
+
+	keyHashes := make([][]byte, 5)
+	for i := range keyHashes {
+		keyHashes[i] = bytes.Repeat([]byte{byte(i + 1)}, 20)
+	}
+	pkScript, err := ThreadPkScript(3, keyHashes)
+	if err != nil {
+		t.Fatalf("ThreadPkScript: unexpected error: %v", err)
+	}
+	if pkScript[0] != OP_3 || pkScript[len(pkScript)-2] != OP_5 ||
+		pkScript[len(pkScript)-1] != OP_CHECKTHREAD {
+
+		t.Errorf("ThreadPkScript: got script %x, want a 3 of 5 thread "+
+			"script", pkScript)
+	}
+
+	for _, threshold := range []int{0, 6} {
+		if _, err := ThreadPkScript(threshold, keyHashes); err == nil {
+			t.Errorf("ThreadPkScript: no error for a threshold of %d "+
+				"with 5 keys", threshold)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// batchedSig houses a signature whose verification was deferred to a SigBatch
+// along with the transaction input which it was checked for.
+type batchedSig struct {
+	sigHash chainhash.Hash
+	sig     *btcec.Signature
+	pubKey  *btcec.PublicKey
+	txHash  chainhash.Hash
+	txIdx   int
+}
+
+// SigBatch collects the signatures checked by script engines which have
+// deferred signature verification to it so they can all be verified together
+// in a batch once the scripts have been executed.  Verifying the signatures in
+// a batch is considerably faster than verifying them one at a time.
+//
+// Deferring signature verification treats every signature as valid while the
+// script executes, so it must only be used for scripts whose outcome is
+// decided by the result of their final signature check, such as the standard
+// Prova scripts.  The script pair is then valid exactly when both the script
+// executes successfully and the batch verifies.
+type SigBatch struct {
+	mtx      sync.Mutex
+	verifier *btcec.BatchVerifier
+	sigs     []batchedSig
+	sigCache *SigCache
+}
+
+// NewSigBatch returns a new empty signature batch.  Signatures which are
+// verified by the batch are added to the passed signature cache, which can be
+// nil.
+func NewSigBatch(sigCache *SigCache) *SigBatch {
+	return &SigBatch{
+		verifier: btcec.NewBatchVerifier(),
+		sigCache: sigCache,
+	}
+}
+
+// add queues the signature checked for the passed transaction input for
+// verification.
+//
+// This function is safe for concurrent access.
+func (b *SigBatch) add(sigHash []byte, sig *btcec.Signature, pubKey *btcec.PublicKey, txHash *chainhash.Hash, txIdx int) {
+	entry := batchedSig{sig: sig, pubKey: pubKey, txHash: *txHash, txIdx: txIdx}
+	copy(entry.sigHash[:], sigHash)
+
+	b.mtx.Lock()
+	b.verifier.Add(sig, sigHash, pubKey)
+	b.sigs = append(b.sigs, entry)
+	b.mtx.Unlock()
+}
+
+// Len returns the number of signatures which have been deferred to the batch.
+//
+// This function is safe for concurrent access.
+func (b *SigBatch) Len() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.sigs)
+}
+
+// Verify verifies all of the signatures which have been deferred to the batch.
+// An error identifying the transaction input of the first invalid signature is
+// returned when any of them are invalid.  Otherwise, the signatures are added
+// to the signature cache.
+//
+// This function is safe for concurrent access.
+func (b *SigBatch) Verify() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if invalid := b.verifier.Verify(); len(invalid) > 0 {
+		sig := &b.sigs[invalid[0]]
+		str := fmt.Sprintf("signature %x for input %d of transaction %v "+
+			"is invalid (%d invalid signatures in batch)",
+			sig.sig.Serialize(), sig.txIdx, sig.txHash, len(invalid))
+		return scriptError(ErrSigBatchVerify, str)
+	}
+
+	if b.sigCache != nil {
+		for i := range b.sigs {
+			sig := &b.sigs[i]
+			b.sigCache.Add(sig.sigHash, sig.sig, sig.pubKey)
+		}
+	}
+	return nil
+}
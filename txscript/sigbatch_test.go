@@ -0,0 +1,100 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSigBatch ensures signatures checked by OP_CHECKSAFEMULTISIG are deferred
+// to a signature batch, and that the batch detects invalid signatures which the
+// script engine treated as valid.
+func TestSigBatch(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000}},
+	}
+	const inputAmt = 5000
+
+	// Create a 2 of 3 script requiring signatures from new keys.
+	var keys []PrivateKey
+	builder := NewScriptBuilder().AddOp(OP_2)
+	for i := 0; i < 3; i++ {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("failed to make privKey: %v", err)
+		}
+		keys = append(keys, PrivateKey{key, true})
+		builder.AddData(provautil.Hash160(key.PubKey().SerializeCompressed()))
+	}
+	pkScript, err := builder.AddOp(OP_3).AddOp(OP_CHECKSAFEMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("failed to make pkScript: %v", err)
+	}
+
+	// execute runs the script pair with signature verification deferred to
+	// the returned batch.
+	sigCache := NewSigCache(10)
+	execute := func(sigScript []byte) *SigBatch {
+		tx.TxIn[0].SignatureScript = sigScript
+		batch := NewSigBatch(sigCache)
+		vm, err := NewEngine(pkScript, tx, 0, StandardVerifyFlags,
+			sigCache, nil, inputAmt)
+		if err != nil {
+			t.Fatalf("failed to make script engine: %v", err)
+		}
+		vm.DeferSignatures(batch)
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("failed to execute script: %v", err)
+		}
+		return batch
+	}
+
+	// The signatures are deferred to the batch and added to the signature
+	// cache once it has been verified.
+	sigScript, ok := signSafeMultiSig(tx, 0, NewTxSigHashes(tx), inputAmt,
+		pkScript, SigHashAll, keys, 2, nil)
+	if !ok {
+		t.Fatal("failed to sign")
+	}
+	batch := execute(sigScript)
+	if batch.Len() != 2 {
+		t.Fatalf("Len: got %d, want 2", batch.Len())
+	}
+	if err := batch.Verify(); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+
+	// Signatures in the signature cache are not deferred.  The cache only
+	// holds a single signature per signature hash, so only one of the two
+	// signatures over the same input is found.
+	if batch := execute(sigScript); batch.Len() != 1 {
+		t.Fatalf("Len: got %d, want 1", batch.Len())
+	}
+
+	// Signatures committing to the wrong input amount are invalid, which
+	// is only detected once the batch is verified.
+	sigScript, ok = signSafeMultiSig(tx, 0, NewTxSigHashes(tx), inputAmt+1,
+		pkScript, SigHashAll, keys, 2, nil)
+	if !ok {
+		t.Fatal("failed to sign")
+	}
+	batch = execute(sigScript)
+	err = batch.Verify()
+	if err := tstCheckScriptError(err, scriptError(ErrSigBatchVerify, "")); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
@@ -34,10 +34,11 @@ func RawTxInSignature(tx *wire.MsgTx, idx int, subScript []byte,
 }
 
 // RawTxInSignatureNew returns the serialized ECDSA signature for the input idx of
-// the given transaction, with hashType appended to it.
+// the given transaction, with hashType appended to it.  The key may be held by
+// any signer, such as a hardware security module.
 // TODO(prova): need to cleanup the old/new versions
 func RawTxInSignatureNew(tx *wire.MsgTx, idx int, txSigHashes *TxSigHashes, amt int64, subScript []byte,
-	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
+	hashType SigHashType, key btcec.Signer) ([]byte, error) {
 
 	parsedScript, err := ParseScript(subScript)
 	if err != nil {
@@ -53,6 +54,24 @@ func RawTxInSignatureNew(tx *wire.MsgTx, idx int, txSigHashes *TxSigHashes, amt
 	return append(signature.Serialize(), byte(hashType)), nil
 }
 
+// RawTxInSchnorrSignature returns the serialized Schnorr signature for the input
+// idx of the given transaction, with hashType appended to it.  The signature is
+// only accepted by scripts validated with the ScriptVerifySchnorr flag.
+func RawTxInSchnorrSignature(tx *wire.MsgTx, idx int, txSigHashes *TxSigHashes, amt int64,
+	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
+
+	hash, err := CalcSignatureHashNew(tx, idx, txSigHashes, hashType, amt)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := key.SignSchnorr(hash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign tx input: %s", err)
+	}
+
+	return append(signature.Serialize(), byte(hashType)), nil
+}
+
 // SignatureScript creates an input signature script for tx to spend RMG sent
 // from a previous output to the owner of privKey. tx must include all
 // transaction inputs and outputs, however txin scripts are allowed to be filled
@@ -133,7 +152,7 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int, inputAmt int64,
 	}
 
 	switch class {
-	case ProvaTy:
+	case ProvaTy, ProvaTimeLockTy, GeneralProvaTy:
 		// We use the keysDb lookup to get a list of privKeys
 		// that are needed for signing.
 		keys, err := kdb.GetKey(addresses[0])
@@ -175,7 +194,7 @@ func mergeScripts(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 	nRequired int, sigScript, prevScript []byte) []byte {
 
 	switch class {
-	case ProvaTy:
+	case ProvaTy, ProvaTimeLockTy, GeneralProvaTy:
 		return mergeProvaSig(tx, idx, addresses, nRequired, pkScript,
 			sigScript, prevScript)
 	case ProvaAdminTy:
@@ -75,6 +75,15 @@ func (view *keyViewpoint) GetAdminKeyHashes(threadID provautil.ThreadID) [][]byt
 	return hashes
 }
 
+// keyID3PrivKey is the private key of the ASP key registered for the third
+// keyID used in the tests.
+var keyID3PrivKey = []byte{
+	0x9e, 0x06, 0x99, 0xc9, 0x1c, 0xa1, 0xe3, 0xb7,
+	0xe3, 0xc9, 0xba, 0x71, 0xeb, 0x71, 0xc8, 0x98,
+	0x90, 0x87, 0x2b, 0xe9, 0x75, 0x76, 0x01, 0x0f,
+	0xe5, 0x93, 0xfb, 0xf3, 0xfd, 0x57, 0xe6, 0x6d,
+}
+
 type addressToKey struct {
 	key        *btcec.PrivateKey
 	compressed bool
@@ -111,7 +120,11 @@ func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript
 	keyId2 := btcec.KeyIDFromAddressBuffer([]byte{1, 0, 0, 0})
 	pubKey2, _ := btcec.ParsePubKey(hexToBytes("038ef4a121bcaf1b1f175557a12896f8bc93b095e84817f90e9a901cd2113a8202"), btcec.S256())
 
-	keyView.SetKeyIDs(map[btcec.KeyID]*btcec.PublicKey{keyId1: pubKey1, keyId2: pubKey2})
+	keyId3 := btcec.KeyIDFromAddressBuffer([]byte{2, 0, 0, 0})
+	_, pubKey3 := btcec.PrivKeyFromBytes(btcec.S256(), keyID3PrivKey)
+
+	keyView.SetKeyIDs(map[btcec.KeyID]*btcec.PublicKey{keyId1: pubKey1,
+		keyId2: pubKey2, keyId3: pubKey3})
 
 	//admin key sets
 	keySets := make(map[btcec.KeySetType]btcec.PublicKeySet)
@@ -125,10 +138,12 @@ func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript
 
 	keyView.SetKeys(keySets)
 	// If script is Prova script, we replace all keyIDs with pubKeyHashes.
-	if TypeOfScript(pops) == ProvaTy {
-		keyIDs, err := ExtractKeyIDs(pops)
+	if class := TypeOfScript(pops); class == ProvaTy ||
+		class == ProvaTimeLockTy || class == GeneralProvaTy {
+
+		keyIDs, err := ExtractGeneralKeyIDs(pops)
 		keyIdMap := keyView.LookupKeyIDs(keyIDs)
-		ReplaceKeyIDs(pops, keyIdMap)
+		ReplaceGeneralKeyIDs(pops, keyIdMap)
 		pkScript, err = UnparseScript(pops)
 		if err != nil {
 			return err
@@ -139,14 +154,15 @@ func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript
 	if TypeOfScript(pops) == ProvaAdminTy {
 		threadID, err := ExtractThreadID(pops)
 		keyHashes := keyView.GetAdminKeyHashes(threadID)
-		pkScript, err = ThreadPkScript(keyHashes)
+		pkScript, err = ThreadPkScript(2, keyHashes)
 		if err != nil {
 			return err
 		}
 	}
 
-	vm, err := NewEngine(pkScript, tx, idx,
-		ScriptBip16|ScriptVerifyDERSignatures, nil, nil, inputAmt)
+	vm, err := NewEngine(pkScript, tx, idx, ScriptBip16|
+		ScriptVerifyDERSignatures|ScriptVerifyCheckLockTimeVerify, nil,
+		nil, inputAmt)
 	if err != nil {
 		return fmt.Errorf("failed to make script engine for %s: %v",
 			msg, err)
@@ -286,6 +302,128 @@ func TestSignTxOutput(t *testing.T) {
 		}
 	}
 
+	// Timelocked Prova Multisig, only valid once the lock time of the
+	// spending transaction reaches the lock time of the script.
+	timeLockTx := tx.Copy()
+	for _, txIn := range timeLockTx.TxIn {
+		txIn.Sequence = 0
+	}
+	for i := range timeLockTx.TxIn {
+		msg := fmt.Sprintf("%d:%d", hashType, i)
+
+		key3, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Errorf("failed to make privKey for %s: %v",
+				msg, err)
+			break
+		}
+		pk3 := (*btcec.PublicKey)(&key3.PublicKey)
+		pkHash := provautil.Hash160(pk3.SerializeCompressed())
+
+		addr, err := provautil.NewAddressProva(pkHash,
+			[]btcec.KeyID{keyId1, keyId2}, &chaincfg.TestNetParams)
+		if err != nil {
+			t.Errorf("failed to make Prova address for %s: %v",
+				msg, err)
+			break
+		}
+
+		lockTime := uint32(1000 + i)
+		scriptPkScript, err := PayToAddrTimeLockScript(addr, lockTime)
+		if err != nil {
+			t.Errorf("failed to make script pkscript for "+
+				"%s: %v", msg, err)
+			break
+		}
+
+		lookupKey := func(a provautil.Address) ([]PrivateKey, error) {
+			return []PrivateKey{
+				PrivateKey{key1, true},
+				PrivateKey{key2, true},
+				PrivateKey{key3, true},
+			}, nil
+		}
+
+		timeLockTx.LockTime = lockTime
+		if err := signAndCheck(msg, timeLockTx, i, inputAmounts[i],
+			scriptPkScript, hashType, KeyClosure(lookupKey),
+			nil); err != nil {
+			t.Error(err)
+			break
+		}
+
+		// A lock time before the one committed to by the script
+		// *should* fail.
+		timeLockTx.LockTime = lockTime - 1
+		if err := signAndCheck(msg, timeLockTx, i, inputAmounts[i],
+			scriptPkScript, hashType, KeyClosure(lookupKey),
+			nil); err == nil {
+			t.Errorf("timelocked script valid before lock time "+
+				"for %s", msg)
+			break
+		}
+	}
+
+	// Generalized 3 of 4 Prova Multisig with a pubkey hash and three
+	// keyIDs.
+	keyId3 := btcec.KeyIDFromAddressBuffer([]byte{2, 0, 0, 0})
+	key4, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyID3PrivKey)
+	for i := range tx.TxIn {
+		msg := fmt.Sprintf("%d:%d", hashType, i)
+
+		key3, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Errorf("failed to make privKey for %s: %v",
+				msg, err)
+			break
+		}
+		pkHash := provautil.Hash160(key3.PubKey().SerializeCompressed())
+
+		addr, err := provautil.NewAddressGeneralProva(3, [][]byte{pkHash},
+			[]btcec.KeyID{keyId1, keyId2, keyId3},
+			&chaincfg.TestNetParams)
+		if err != nil {
+			t.Errorf("failed to make generalized Prova address "+
+				"for %s: %v", msg, err)
+			break
+		}
+
+		scriptPkScript, err := PayToAddrScript(addr)
+		if err != nil {
+			t.Errorf("failed to make script pkscript for "+
+				"%s: %v", msg, err)
+			break
+		}
+
+		lookupKey := func(a provautil.Address) ([]PrivateKey, error) {
+			return []PrivateKey{
+				PrivateKey{key3, true},
+				PrivateKey{key1, true},
+				PrivateKey{key4, true},
+			}, nil
+		}
+
+		if err := signAndCheck(msg, tx, i, inputAmounts[i], scriptPkScript,
+			hashType, KeyClosure(lookupKey), nil); err != nil {
+			t.Error(err)
+			break
+		}
+
+		// Only two of the three required signatures *should* fail.
+		lookupTwoKeys := func(a provautil.Address) ([]PrivateKey, error) {
+			return []PrivateKey{
+				PrivateKey{key3, true},
+				PrivateKey{key1, true},
+			}, nil
+		}
+		if err := signAndCheck(msg, tx, i, inputAmounts[i], scriptPkScript,
+			hashType, KeyClosure(lookupTwoKeys), nil); err == nil {
+			t.Errorf("generalized script valid with too few "+
+				"signatures for %s", msg)
+			break
+		}
+	}
+
 	// Two part Prova Multisig, sign with one key then the other.
 	for i := range tx.TxIn {
 		msg := fmt.Sprintf("%d:%d", hashType, i)
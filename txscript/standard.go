@@ -7,11 +7,13 @@ package txscript
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/golangcrypto/ripemd160"
 )
 
 const (
@@ -47,26 +49,28 @@ type ScriptClass byte
 
 // Classes of script payment known about in the blockchain.
 const (
-	NonStandardTy  ScriptClass = iota // None of the recognized forms.
-	PubKeyTy                          // Pay pubkey.
-	PubKeyHashTy                      // Pay pubkey hash.
-	ScriptHashTy                      // Pay to script hash.
-	MultiSigTy                        // Multi signature.
-	NullDataTy                        // Empty data-only (provably prunable).
-	ProvaTy                           // Prova standard 2-of-3 type (subset of GeneralProvaTy)
-	GeneralProvaTy                    // Prova (generalized m-of-n) script
-	ProvaAdminTy                      // Prova Admin Operations
+	NonStandardTy   ScriptClass = iota // None of the recognized forms.
+	PubKeyTy                           // Pay pubkey.
+	PubKeyHashTy                       // Pay pubkey hash.
+	ScriptHashTy                       // Pay to script hash.
+	MultiSigTy                         // Multi signature.
+	NullDataTy                         // Empty data-only (provably prunable).
+	ProvaTy                            // Prova standard 2-of-3 type (subset of GeneralProvaTy)
+	GeneralProvaTy                     // Prova (generalized m-of-n) script
+	ProvaAdminTy                       // Prova Admin Operations
+	ProvaTimeLockTy                    // Prova 2-of-3 type with absolute timelock
 )
 
 // scriptClassToName houses the human-readable strings which describe each
 // script class.
 var scriptClassToName = []string{
 	// TODO(prova): clean up non-used types
-	NonStandardTy:  "nonstandard",
-	NullDataTy:     "nulldata",
-	ProvaTy:        "safe_multisig",
-	GeneralProvaTy: "safe_multisig",
-	ProvaAdminTy:   "admin",
+	NonStandardTy:   "nonstandard",
+	NullDataTy:      "nulldata",
+	ProvaTy:         "safe_multisig",
+	GeneralProvaTy:  "safe_multisig",
+	ProvaAdminTy:    "admin",
+	ProvaTimeLockTy: "timelock_safe_multisig",
 }
 
 // String implements the Stringer interface by returning the name of
@@ -155,6 +159,21 @@ func isGeneralProva(pops []parsedOpcode) bool {
 	return true
 }
 
+// isStrictGeneralProva returns true if the passed script is a generalized
+// m-of-n Prova script which holds nothing but its pubkey hashes and keyIDs.
+// isGeneralProva skips over any other data pushed between them.
+func isStrictGeneralProva(pops []parsedOpcode) bool {
+	if !isGeneralProva(pops) {
+		return false
+	}
+	for _, pop := range pops[1 : len(pops)-2] {
+		if len(pop.data) != ripemd160.Size && !isUint32(pop.opcode) {
+			return false
+		}
+	}
+	return true
+}
+
 // isProva returns true if the passed script is a 2 of 3 prova transaction.
 func isProva(pops []parsedOpcode) bool {
 	return len(pops) == 6 &&
@@ -163,9 +182,61 @@ func isProva(pops []parsedOpcode) bool {
 		isGeneralProva(pops)
 }
 
+// timeLockPrefixLen is the number of opcodes in the
+// <locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP prefix of a timelocked prova script.
+const timeLockPrefixLen = 3
+
+// extractTimeLock returns the absolute lock time enforced by the passed script
+// and whether or not the script begins with the timelock prefix.  The lock time
+// must be a positive, minimally encoded number which fits in the lock time
+// field of a transaction.
+func extractTimeLock(pops []parsedOpcode) (uint32, bool) {
+	if len(pops) < timeLockPrefixLen ||
+		pops[1].opcode.value != OP_CHECKLOCKTIMEVERIFY ||
+		pops[2].opcode.value != OP_DROP {
+		return 0, false
+	}
+
+	var lockTime int64
+	switch {
+	case isSmallInt(pops[0].opcode):
+		lockTime = int64(asSmallInt(pops[0].opcode))
+	case pops[0].opcode.value <= OP_PUSHDATA4 && canonicalPush(pops[0]):
+		num, err := makeScriptNum(pops[0].data, true, 5)
+		if err != nil {
+			return 0, false
+		}
+		lockTime = int64(num)
+	default:
+		return 0, false
+	}
+	if lockTime <= 0 || lockTime > math.MaxUint32 {
+		return 0, false
+	}
+	return uint32(lockTime), true
+}
+
+// stripTimeLock returns the prova script which follows the timelock prefix of
+// the passed script, or the passed script itself when there is no prefix.
+func stripTimeLock(pops []parsedOpcode) []parsedOpcode {
+	if _, ok := extractTimeLock(pops); ok {
+		return pops[timeLockPrefixLen:]
+	}
+	return pops
+}
+
+// isProvaTimeLock returns true if the passed script is a 2 of 3 prova script
+// which may only be spent once the absolute lock time it commits to has been
+// reached:
+// <locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP <2 of 3 prova script>
+func isProvaTimeLock(pops []parsedOpcode) bool {
+	_, ok := extractTimeLock(pops)
+	return ok && isProva(pops[timeLockPrefixLen:])
+}
+
 // IsProvaTx determines if a transaction is a standard prova transaction
-// consisting of only outputs to standard prova scripts and 0-value nulldata
-// scripts.
+// consisting of only outputs to standard prova scripts, timelocked prova
+// scripts and 0-value nulldata scripts.
 func IsProvaTx(tx *provautil.Tx) bool {
 	msgTx := tx.MsgTx()
 
@@ -190,7 +261,7 @@ func IsProvaTx(tx *provautil.Tx) bool {
 			if atoms != 0 {
 				return false
 			}
-		} else if !isGeneralProva(pops) {
+		} else if !isGeneralProva(pops) && !isProvaTimeLock(pops) {
 			return false
 		}
 	}
@@ -289,11 +360,32 @@ func IsValidAdminOp(pops []parsedOpcode, threadID provautil.ThreadID) bool {
 			}
 		}
 	case provautil.IssueThread:
-		return false
+		if op == AdminOpKeyIDFreeze ||
+			op == AdminOpKeyIDUnfreeze {
+			// freeze ops refer to the ASP key of the keyID
+			if len(pops[1].data) == 1+btcec.PubKeyBytesLenCompressed+btcec.KeyIDSize {
+				return true
+			}
+		}
 	}
 	return false
 }
 
+// IsKeyIDFreezeOp returns true if the passed script is a valid admin operation
+// freezing or unfreezing a keyID on the issue thread.
+func IsKeyIDFreezeOp(pops []parsedOpcode) bool {
+	return IsValidAdminOp(pops, provautil.IssueThread)
+}
+
+// IsKeyIDFreezeTx returns true if the passed transaction is an issue thread
+// transaction freezing or unfreezing keyIDs, which is the case when its first
+// admin operation is a keyID freeze operation.
+func IsKeyIDFreezeTx(tx *provautil.Tx) bool {
+	threadInt, adminOutputs := GetAdminDetails(tx)
+	return threadInt == int(provautil.IssueThread) &&
+		len(adminOutputs) > 0 && IsKeyIDFreezeOp(adminOutputs[0])
+}
+
 // isNullData returns true if the passed script is a null data transaction,
 // false otherwise.
 func isNullData(pops []parsedOpcode) bool {
@@ -329,6 +421,8 @@ func typeOfScript(pops []parsedOpcode) ScriptClass {
 		return GeneralProvaTy
 	} else if isProvaAdmin(pops) {
 		return ProvaAdminTy
+	} else if isProvaTimeLock(pops) {
+		return ProvaTimeLockTy
 	}
 	return NonStandardTy
 }
@@ -405,6 +499,36 @@ func payToProvaScript(pubKeyHash []byte, keyIDs []btcec.KeyID) ([]byte, error) {
 		Script()
 }
 
+// payToGeneralProvaScript creates a new script to pay a transaction output to
+// a generalized m-of-n Prova address.  The pubkey hashes precede the keyIDs.
+func payToGeneralProvaScript(nRequired int, pkHashes [][]byte, keyIDs []btcec.KeyID) ([]byte, error) {
+	builder := NewScriptBuilder().AddInt64(int64(nRequired))
+	for _, pkHash := range pkHashes {
+		builder.AddData(pkHash)
+	}
+	for _, keyID := range keyIDs {
+		builder.AddInt64(int64(keyID))
+	}
+	script, err := builder.AddInt64(int64(len(pkHashes) + len(keyIDs))).
+		AddOp(OP_CHECKSAFEMULTISIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+
+	pops, err := ParseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	if !isStrictGeneralProva(pops) {
+		str := fmt.Sprintf("unable to generate %d of %d prova script "+
+			"with %d key ids", nRequired, len(pkHashes)+len(keyIDs),
+			len(keyIDs))
+		return nil, scriptError(ErrInvalidNumberOfKeyIds, str)
+	}
+	return script, nil
+}
+
 // PayToAddrScript creates a new script to pay a transaction output to a the
 // specified address.
 func PayToAddrScript(addr provautil.Address) ([]byte, error) {
@@ -414,11 +538,55 @@ func PayToAddrScript(addr provautil.Address) ([]byte, error) {
 			return nil, scriptError(ErrUnsupportedAddress, "address is nil")
 		}
 		return payToProvaScript(addr.ScriptAddress(), addr.ScriptKeyIDs())
+
+	case *provautil.AddressGeneralProva:
+		if addr == nil {
+			return nil, scriptError(ErrUnsupportedAddress, "address is nil")
+		}
+		return payToGeneralProvaScript(addr.RequiredSigs(),
+			addr.PubKeyHashes(), addr.ScriptKeyIDs())
 	}
 
 	return nil, scriptError(ErrUnsupportedAddress, "unsupported address type")
 }
 
+// PayToAddrTimeLockScript creates a new script to pay a transaction output to
+// the specified address which may only be spent by a transaction with a lock
+// time at or after the passed lock time.  As with transaction lock times, values
+// below LockTimeThreshold are interpreted as block heights and others as unix
+// timestamps.
+func PayToAddrTimeLockScript(addr provautil.Address, lockTime uint32) ([]byte, error) {
+	if lockTime == 0 {
+		return nil, scriptError(ErrUnsatisfiedLockTime,
+			"timelocked script must have a non-zero lock time")
+	}
+	script, err := PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewScriptBuilder().
+		AddInt64(int64(lockTime)).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddOps(script).
+		Script()
+}
+
+// ExtractTimeLock takes a timelocked prova pkScript and extracts the absolute
+// lock time it commits to.
+func ExtractTimeLock(pkScript []byte) (uint32, error) {
+	pops, err := ParseScript(pkScript)
+	if err != nil {
+		return 0, err
+	}
+	if !isProvaTimeLock(pops) {
+		return 0, fmt.Errorf("unable to extract lock time from script, "+
+			"unexpected script structure %v", pops)
+	}
+	lockTime, _ := extractTimeLock(pops)
+	return lockTime, nil
+}
+
 // ProvaThreadScript creates a new script to pay a transaction output to an
 // Prova Admin Thread.
 func ProvaThreadScript(threadID provautil.ThreadID) ([]byte, error) {
@@ -499,7 +667,9 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 	scriptClass := typeOfScript(pops)
 	switch scriptClass {
 
-	case ProvaTy:
+	case ProvaTy, ProvaTimeLockTy:
+		// The timelock prefix does not affect who may spend the output.
+		pops = stripTimeLock(pops)
 		requiredSigs = 2
 		key0, err0 := asInt32(pops[2])
 		key1, err1 := asInt32(pops[3])
@@ -513,7 +683,21 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 		}
 
 	case GeneralProvaTy:
-		// TODO(prova): define what to do for generalized prova scripts
+		requiredSigs = asSmallInt(pops[0].opcode)
+		var pkHashes [][]byte
+		keyIDs, err := ExtractGeneralKeyIDs(pops)
+		if err == nil {
+			for _, pop := range pops[1 : len(pops)-2] {
+				if len(pop.data) == ripemd160.Size {
+					pkHashes = append(pkHashes, pop.data)
+				}
+			}
+			addr, err := provautil.NewAddressGeneralProva(requiredSigs,
+				pkHashes, keyIDs, chainParams)
+			if err == nil {
+				addrs = append(addrs, addr)
+			}
+		}
 
 	case ProvaAdminTy:
 		requiredSigs = 2
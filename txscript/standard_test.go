@@ -52,6 +52,16 @@ func newAddressProva(pkHash []byte, keyIDs []btcec.KeyID) provautil.Address {
 	return addr
 }
 
+func newAddressGeneralProva(nRequired int, pkHashes [][]byte, keyIDs []btcec.KeyID) provautil.Address {
+	addr, err := provautil.NewAddressGeneralProva(nRequired, pkHashes,
+		keyIDs, &chaincfg.MainNetParams)
+	if err != nil {
+		panic("invalid generalized prova address in test source")
+	}
+
+	return addr
+}
+
 // TestExtractPkScriptAddrs ensures that extracting the type, addresses, and
 // number of required signatures from PkScripts works as intended.
 func TestExtractPkScriptAddrs(t *testing.T) {
@@ -75,6 +85,20 @@ func TestExtractPkScriptAddrs(t *testing.T) {
 			reqSigs: 2,
 			class:   ProvaTy,
 		},
+		{
+			name: "generalized prova",
+			script: decodeHex("531435dbbf04bca061e49dace08f858d87" +
+				"75c0a57c8e14433ec2ac1ffa1b7b7d027f564529c57197f9" +
+				"ae880300000151525456ba"),
+			addrs: []provautil.Address{
+				newAddressGeneralProva(3, [][]byte{
+					decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e"),
+					decodeHex("433ec2ac1ffa1b7b7d027f564529c57197f9ae88"),
+				}, []btcec.KeyID{0x10000, 1, 2, 4}),
+			},
+			reqSigs: 3,
+			class:   GeneralProvaTy,
+		},
 		{
 			name:    "empty script",
 			script:  []byte{},
@@ -149,6 +173,15 @@ func TestIsValidAdminOp(t *testing.T) {
 		Value:    0,
 		PkScript: provOpPkScript,
 	}
+	// keyID freeze
+	freezeData := make([]byte, len(aspData))
+	copy(freezeData, aspData)
+	freezeData[0] = AdminOpKeyIDFreeze
+	freezeOpPkScript, _ := NewScriptBuilder().AddOp(OP_RETURN).AddData(freezeData).Script()
+	freezeOpTxOut := wire.TxOut{
+		Value:    0,
+		PkScript: freezeOpPkScript,
+	}
 	// create root tx out
 	rootPkScript, _ := ProvaThreadScript(provautil.RootThread)
 	rootTxOut := wire.TxOut{
@@ -161,6 +194,12 @@ func TestIsValidAdminOp(t *testing.T) {
 		Value:    0, // 0 RMG
 		PkScript: provisionPkScript,
 	}
+	// create issue tx out
+	issuePkScript, _ := ProvaThreadScript(provautil.IssueThread)
+	issueTxOut := wire.TxOut{
+		Value:    0,
+		PkScript: issuePkScript,
+	}
 
 	tests := []struct {
 		name    string
@@ -185,6 +224,24 @@ func TestIsValidAdminOp(t *testing.T) {
 				TxOut: []*wire.TxOut{&provisionTxOut, &adminOpTxOut},
 			},
 			isValid: false,
+		}, {
+			name: "Issue transaction freezing keyID",
+			tx: wire.MsgTx{
+				TxOut: []*wire.TxOut{&issueTxOut, &freezeOpTxOut},
+			},
+			isValid: true,
+		}, {
+			name: "Freeze operation on provision thread",
+			tx: wire.MsgTx{
+				TxOut: []*wire.TxOut{&provisionTxOut, &freezeOpTxOut},
+			},
+			isValid: false,
+		}, {
+			name: "Issue transaction adding asp",
+			tx: wire.MsgTx{
+				TxOut: []*wire.TxOut{&issueTxOut, &provOpTxOut},
+			},
+			isValid: false,
 		},
 	}
 
@@ -260,6 +317,14 @@ func TestPayToAddrScript(t *testing.T) {
 		t.Fatalf("Unable to create prova address: %v", err)
 	}
 
+	generalTest, err := provautil.NewAddressGeneralProva(3, [][]byte{
+		decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e"),
+		decodeHex("433ec2ac1ffa1b7b7d027f564529c57197f9ae88"),
+	}, []btcec.KeyID{0x10000, 1, 2, 4}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("Unable to create generalized prova address: %v", err)
+	}
+
 	errUnsupportedAddress := scriptError(ErrUnsupportedAddress, "")
 
 	tests := []struct {
@@ -274,8 +339,30 @@ func TestPayToAddrScript(t *testing.T) {
 			nil,
 		},
 
+		// 3 of 6 generalized prova address
+		{
+			generalTest,
+			"531435dbbf04bca061e49dace08f858d8775c0a57c8e14433ec2" +
+				"ac1ffa1b7b7d027f564529c57197f9ae880300000151525456ba",
+			nil,
+		},
+
+		// Bech32 encoded addresses pay to the same scripts.
+		{
+			provaTest.Bech32(&chaincfg.TestNetParams),
+			"521435dbbf04bca061e49dace08f858d8775c0a57c8e030000015153ba",
+			nil,
+		},
+		{
+			generalTest.Bech32(&chaincfg.TestNetParams),
+			"531435dbbf04bca061e49dace08f858d8775c0a57c8e14433ec2" +
+				"ac1ffa1b7b7d027f564529c57197f9ae880300000151525456ba",
+			nil,
+		},
+
 		// Supported address types with nil pointers.
 		{(*provautil.AddressProva)(nil), "", errUnsupportedAddress},
+		{(*provautil.AddressGeneralProva)(nil), "", errUnsupportedAddress},
 
 		// Unsupported address type.
 		{&bogusAddress{}, "", errUnsupportedAddress},
@@ -295,6 +382,127 @@ func TestPayToAddrScript(t *testing.T) {
 				i, pkScript, expected)
 		}
 	}
+
+	// Addresses decoded from their Bech32 encoding round trip through
+	// their scripts.
+	for _, addr := range []provautil.Address{
+		provaTest.Bech32(&chaincfg.TestNetParams),
+		generalTest.Bech32(&chaincfg.TestNetParams),
+	} {
+		decoded, err := provautil.DecodeAddress(addr.EncodeAddress(),
+			&chaincfg.TestNetParams)
+		if err != nil {
+			t.Fatalf("DecodeAddress %v: unexpected error: %v", addr,
+				err)
+		}
+		pkScript, err := PayToAddrScript(decoded)
+		if err != nil {
+			t.Fatalf("PayToAddrScript %v: unexpected error: %v", addr,
+				err)
+		}
+		_, addrs, _, err := ExtractPkScriptAddrs(pkScript,
+			&chaincfg.TestNetParams)
+		if err != nil || len(addrs) != 1 {
+			t.Fatalf("ExtractPkScriptAddrs %v: got %v, %v", addr,
+				addrs, err)
+		}
+		if !bytes.Equal(addrs[0].ScriptAddress(), decoded.ScriptAddress()) {
+			t.Fatalf("ExtractPkScriptAddrs %v: got %v", addr, addrs[0])
+		}
+	}
+}
+
+// TestPayToAddrTimeLockScript ensures the PayToAddrTimeLockScript function
+// generates the correct scripts and the lock time can be extracted from them.
+func TestPayToAddrTimeLockScript(t *testing.T) {
+	t.Parallel()
+
+	// TCq7ZvyjTugZ3xDY8m1Mdgm95v4QmMpMfm3Fg8GCeE1uf
+	provaTest, err := provautil.NewAddressProva(
+		decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e"),
+		[]btcec.KeyID{0x10000, 1}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("Unable to create prova address: %v", err)
+	}
+
+	errUnsatisfiedLockTime := scriptError(ErrUnsatisfiedLockTime, "")
+	errUnsupportedAddress := scriptError(ErrUnsupportedAddress, "")
+
+	tests := []struct {
+		in       provautil.Address
+		lockTime uint32
+		expected string
+		err      error
+	}{
+		// block height lock time
+		{
+			provaTest,
+			1000000,
+			"0340420fb175521435dbbf04bca061e49dace08f858d8775c0a57c" +
+				"8e030000015153ba",
+			nil,
+		},
+
+		// unix timestamp lock time
+		{
+			provaTest,
+			0x80000000,
+			"050000008000b175521435dbbf04bca061e49dace08f858d8775c0" +
+				"a57c8e030000015153ba",
+			nil,
+		},
+
+		// Zero lock time.
+		{provaTest, 0, "", errUnsatisfiedLockTime},
+
+		// Unsupported address type.
+		{&bogusAddress{}, 1000000, "", errUnsupportedAddress},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		pkScript, err := PayToAddrTimeLockScript(test.in, test.lockTime)
+		if e := tstCheckScriptError(err, test.err); e != nil {
+			t.Fatalf("PayToAddrTimeLockScript #%d unexpected error "+
+				"- got %v, want %v", i, err, test.err)
+		}
+
+		expected := decodeHex(test.expected)
+		if !bytes.Equal(pkScript, expected) {
+			t.Fatalf("PayToAddrTimeLockScript #%d got: %x\nwant: %x",
+				i, pkScript, expected)
+		}
+		if test.err != nil {
+			continue
+		}
+
+		if class := GetScriptClass(pkScript); class != ProvaTimeLockTy {
+			t.Fatalf("PayToAddrTimeLockScript #%d unexpected class "+
+				"%v", i, class)
+		}
+		lockTime, err := ExtractTimeLock(pkScript)
+		if err != nil || lockTime != test.lockTime {
+			t.Fatalf("ExtractTimeLock #%d got: %d (err %v), want: "+
+				"%d", i, lockTime, err, test.lockTime)
+		}
+		_, addrs, reqSigs, err := ExtractPkScriptAddrs(pkScript,
+			&chaincfg.TestNetParams)
+		if err != nil || reqSigs != 2 || len(addrs) != 1 ||
+			addrs[0].String() != provaTest.String() {
+
+			t.Fatalf("ExtractPkScriptAddrs #%d got: %v %d (err %v), "+
+				"want: %v", i, addrs, reqSigs, err, provaTest)
+		}
+	}
+
+	payToAddr, err := PayToAddrScript(provaTest)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	if _, err := ExtractTimeLock(payToAddr); err == nil {
+		t.Fatal("ExtractTimeLock: did not fail on script without " +
+			"lock time")
+	}
 }
 
 // TestMultiSigScript ensures the MultiSigScript function returns the expected
@@ -628,11 +836,89 @@ var scriptClassTests = []struct {
 			"9ae88 1 2 3 4 5 CHECKSAFEMULTISIG",
 		class: GeneralProvaTy,
 	},
+	{
+		name: "3 of 5 prova script with two key hashes",
+		script: "3 DATA_20 0x433ec2ac1ffa1b7b7d027f564529c57197f" +
+			"9ae88 DATA_20 0x35dbbf04bca061e49dace08f858d8775c0a5" +
+			"7c8e 1 2 3 5 CHECKSAFEMULTISIG",
+		class: GeneralProvaTy,
+	},
+	{
+		name: "prova script with key hashes after key ids",
+		script: "3 1 2 3 DATA_20 0x433ec2ac1ffa1b7b7d027f564529c5" +
+			"7197f9ae88 DATA_20 0x35dbbf04bca061e49dace08f858d877" +
+			"5c0a57c8e 5 CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "prova script with as many key hashes as signatures",
+		script: "2 DATA_20 0x433ec2ac1ffa1b7b7d027f564529c57197f" +
+			"9ae88 DATA_20 0x35dbbf04bca061e49dace08f858d8775c0a5" +
+			"7c8e 1 2 4 CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		// The size of the pubkey hash of a basic prova script is
+		// not checked.
+		name: "prova script with a public key",
+		script: "2 DATA_33 0x0232abdc893e7f0631364d7fd01cb33d24da45" +
+			"329a00357b3a7886211ab414d55a 1 2 3 CHECKSAFEMULTISIG",
+		class: ProvaTy,
+	},
 	{
 		name:   "prova admin script",
 		script: "0 CHECKTHREAD",
 		class:  ProvaAdminTy,
 	},
+	{
+		name: "timelocked prova script",
+		script: "DATA_3 0x40420f CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: ProvaTimeLockTy,
+	},
+	{
+		name: "timelocked prova script with small int lock time",
+		script: "16 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: ProvaTimeLockTy,
+	},
+	{
+		name: "timelocked prova script with zero lock time",
+		script: "0 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "timelocked prova script with negative lock time",
+		script: "DATA_3 0x40428f CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "timelocked prova script with non-minimal lock time",
+		script: "DATA_4 0x40420f00 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "timelocked prova script without drop",
+		script: "DATA_3 0x40420f CHECKLOCKTIMEVERIFY 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 " +
+			"CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "timelocked prova script with additional key ids",
+		script: "DATA_3 0x40420f CHECKLOCKTIMEVERIFY DROP 2 DATA_20 " +
+			"0x433ec2ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 4 5 " +
+			"CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
 }
 
 // TestScriptClass ensures all the scripts in scriptClassTests have the expected
@@ -671,6 +957,11 @@ func TestStringifyClass(t *testing.T) {
 			class:    NullDataTy,
 			stringed: "nulldata",
 		},
+		{
+			name:     "provatimelockty",
+			class:    ProvaTimeLockTy,
+			stringed: "timelock_safe_multisig",
+		},
 		{
 			name:     "broken",
 			class:    ScriptClass(255),
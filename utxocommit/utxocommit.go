@@ -0,0 +1,464 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package utxocommit implements an incremental commitment to the utxo set of a
+prova chain, and proofs checked against it that an output is or is not unspent
+as of a block, which auditors use to reconcile custody holdings without a full
+node.
+
+The unspent outputs are split into 4096 bins by the first 12 bits of the hash
+of their transaction.  Each bin is summarized by an elliptic curve multiset
+hash of its outputs, which is updated as outputs are created and spent without
+visiting the rest of the bin.  The commitment is the root of a Merkle tree over
+the digests of the bins, so a block only changes the branches of the bins it
+touches.  Outputs are serialized the same way as for the utxo set hash of
+blockchain.UtxoSetStats.
+
+A proof lists every unspent output of the bin of an outpoint along with the
+branch of the tree from the bin to the root.  Since it covers the whole bin, it
+shows that an output is spent or never existed just as well as that it is
+unspent.
+*/
+package utxocommit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// BinBits is the number of leading bits of the transaction hash which
+	// select the bin of an output, and also the height of the tree over
+	// the bins.
+	BinBits = 12
+
+	// NumBins is the number of bins the utxo set is split into.
+	NumBins = 1 << BinBits
+
+	// maxPkScriptSize is the maximum size of a public key script in a
+	// proof, which is the maximum size of a script.
+	maxPkScriptSize = 10000
+)
+
+// hashToCurveMagic is prepended to outputs which are mapped to curve points.
+var hashToCurveMagic = []byte("Prova utxo commitment:\n")
+
+// Bin returns the bin of the outputs of the transaction with the passed hash.
+func Bin(txHash *chainhash.Hash) uint32 {
+	return uint32(txHash[0])<<4 | uint32(txHash[1]>>4)
+}
+
+// BinRange returns the smallest transaction hash in the passed bin, and the
+// smallest one in the next bin, as the raw bytes the utxo set is ordered by.
+// The end is nil for the last bin.
+func BinRange(bin uint32) (start, end []byte) {
+	start = make([]byte, chainhash.HashSize)
+	start[0], start[1] = byte(bin>>4), byte(bin<<4)
+	if bin+1 < NumBins {
+		end = make([]byte, chainhash.HashSize)
+		end[0], end[1] = byte((bin+1)>>4), byte((bin+1)<<4)
+	}
+	return start, end
+}
+
+// Output is an unspent transaction output.
+type Output struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	PkScript []byte
+}
+
+// serialize writes the output to w as the transaction hash, the index as a
+// little-endian uint32, the amount as a little-endian int64, and the public
+// key script prefixed with its length as a varint.
+func (o *Output) serialize(w io.Writer) error {
+	var buf [chainhash.HashSize + 12]byte
+	copy(buf[:], o.OutPoint.Hash[:])
+	binary.LittleEndian.PutUint32(buf[chainhash.HashSize:], o.OutPoint.Index)
+	binary.LittleEndian.PutUint64(buf[chainhash.HashSize+4:],
+		uint64(o.Amount))
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, o.PkScript)
+}
+
+// deserialize decodes an output written by serialize from r into the
+// receiver.
+func (o *Output) deserialize(r io.Reader) error {
+	var buf [chainhash.HashSize + 12]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return err
+	}
+	copy(o.OutPoint.Hash[:], buf[:])
+	o.OutPoint.Index = binary.LittleEndian.Uint32(buf[chainhash.HashSize:])
+	o.Amount = int64(binary.LittleEndian.Uint64(buf[chainhash.HashSize+4:]))
+	pkScript, err := wire.ReadVarBytes(r, 0, maxPkScriptSize, "pkScript")
+	if err != nil {
+		return err
+	}
+	o.PkScript = pkScript
+	return nil
+}
+
+// less returns whether the output comes before the passed one in the order of
+// the utxo set, by the hash of their transaction and then their index.
+func (o *Output) less(other *Output) bool {
+	cmp := bytes.Compare(o.OutPoint.Hash[:], other.OutPoint.Hash[:])
+	return cmp < 0 || cmp == 0 && o.OutPoint.Index < other.OutPoint.Index
+}
+
+// hashToCurve maps the passed output to a point on the secp256k1 curve by
+// hashing it with an increasing counter until the hash is the x coordinate of
+// a point.  Nobody knows the discrete logarithm of the points, so the sum of
+// the points of a set of outputs can't be matched by a different set.
+func hashToCurve(o *Output) (*big.Int, *big.Int) {
+	hasher := sha256.New()
+	hasher.Write(hashToCurveMagic)
+	// Writing to a hash never fails.
+	_ = o.serialize(hasher)
+	digest := hasher.Sum(nil)
+
+	var buf [4 + sha256.Size]byte
+	copy(buf[4:], digest)
+	pubKey := make([]byte, 1+sha256.Size)
+	pubKey[0] = 0x02
+	for counter := uint32(0); ; counter++ {
+		binary.LittleEndian.PutUint32(buf[:4], counter)
+		hash := sha256.Sum256(buf[:])
+		copy(pubKey[1:], hash[:])
+		point, err := btcec.ParsePubKey(pubKey, btcec.S256())
+		if err == nil {
+			return point.X, point.Y
+		}
+	}
+}
+
+// Multiset is an elliptic curve multiset hash of a set of outputs, which is
+// the sum of the points the outputs map to.  Outputs can be added and removed
+// in any order.  The zero value is the hash of the empty set.
+type Multiset struct {
+	x, y *big.Int
+}
+
+// isEmpty returns whether the sum is the point at infinity, which is the hash
+// of the empty set.
+func (m *Multiset) isEmpty() bool {
+	return m.x == nil || m.x.Sign() == 0 && m.y.Sign() == 0
+}
+
+// add adds the passed point to the sum.
+func (m *Multiset) add(x, y *big.Int) {
+	if m.isEmpty() {
+		m.x, m.y = x, y
+		return
+	}
+	m.x, m.y = btcec.S256().Add(m.x, m.y, x, y)
+}
+
+// Add adds the passed output to the set.
+func (m *Multiset) Add(o *Output) {
+	m.add(hashToCurve(o))
+}
+
+// Remove removes the passed output from the set.
+func (m *Multiset) Remove(o *Output) {
+	x, y := hashToCurve(o)
+	m.add(x, new(big.Int).Sub(btcec.S256().P, y))
+}
+
+// Digest returns the digest of the set, which is the SHA-256 of the
+// compressed sum, or all zeros for the empty set.
+func (m *Multiset) Digest() chainhash.Hash {
+	if m.isEmpty() {
+		return chainhash.Hash{}
+	}
+	pubKey := btcec.PublicKey{Curve: btcec.S256(), X: m.x, Y: m.y}
+	return chainhash.Hash(sha256.Sum256(pubKey.SerializeCompressed()))
+}
+
+// Serialize returns the sum as the 32-byte big-endian x and y coordinates,
+// which are all zeros for the empty set.
+func (m *Multiset) Serialize() []byte {
+	serialized := make([]byte, 64)
+	if !m.isEmpty() {
+		xBytes, yBytes := m.x.Bytes(), m.y.Bytes()
+		copy(serialized[32-len(xBytes):32], xBytes)
+		copy(serialized[64-len(yBytes):], yBytes)
+	}
+	return serialized
+}
+
+// DeserializeMultiset decodes a multiset hash returned by Serialize.
+func DeserializeMultiset(serialized []byte) (*Multiset, error) {
+	if len(serialized) != 64 {
+		return nil, fmt.Errorf("multiset hash has %d bytes instead "+
+			"of 64", len(serialized))
+	}
+	x := new(big.Int).SetBytes(serialized[:32])
+	y := new(big.Int).SetBytes(serialized[32:])
+	m := &Multiset{x: x, y: y}
+	if !m.isEmpty() && !btcec.S256().IsOnCurve(x, y) {
+		return nil, errors.New("multiset hash is not on the curve")
+	}
+	return m, nil
+}
+
+// BinDigest returns the digest of the bin holding the passed outputs.
+func BinDigest(outputs []Output) chainhash.Hash {
+	var m Multiset
+	for i := range outputs {
+		m.Add(&outputs[i])
+	}
+	return m.Digest()
+}
+
+// hashNodes returns the parent of the passed nodes of the tree, which is the
+// double SHA-256 of their concatenation.
+func hashNodes(left, right *chainhash.Hash) chainhash.Hash {
+	var buf [chainhash.HashSize * 2]byte
+	copy(buf[:], left[:])
+	copy(buf[chainhash.HashSize:], right[:])
+	return chainhash.DoubleHashH(buf[:])
+}
+
+// emptyNodes holds the nodes of subtrees over empty bins by level.
+var emptyNodes = func() [BinBits + 1]chainhash.Hash {
+	var nodes [BinBits + 1]chainhash.Hash
+	for level := 1; level <= BinBits; level++ {
+		nodes[level] = hashNodes(&nodes[level-1], &nodes[level-1])
+	}
+	return nodes
+}()
+
+// NodeStore stores the nodes of the tree over the bins.  Level 0 holds the
+// digests of the bins, and level BinBits holds the root.
+type NodeStore interface {
+	// FetchNode returns the node at the passed level and position, or nil
+	// when it was never stored, which means its subtree is empty.
+	FetchNode(level uint8, pos uint32) (*chainhash.Hash, error)
+
+	// PutNode stores the node at the passed level and position.  The
+	// passed hash is not modified afterwards.
+	PutNode(level uint8, pos uint32, hash *chainhash.Hash) error
+}
+
+// fetchNode returns the node at the passed level and position from the store.
+func fetchNode(store NodeStore, level uint8, pos uint32) (*chainhash.Hash, error) {
+	node, err := store.FetchNode(level, pos)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return &emptyNodes[level], nil
+	}
+	return node, nil
+}
+
+// SetBinDigest sets the digest of the passed bin in the store and updates its
+// branch up to the root.
+func SetBinDigest(store NodeStore, bin uint32, digest *chainhash.Hash) error {
+	// The stored nodes must not be modified, so every node is a new hash.
+	node := new(chainhash.Hash)
+	*node = *digest
+	pos := bin
+	for level := uint8(0); ; level++ {
+		if err := store.PutNode(level, pos, node); err != nil {
+			return err
+		}
+		if level == BinBits {
+			return nil
+		}
+		sibling, err := fetchNode(store, level, pos^1)
+		if err != nil {
+			return err
+		}
+		var parent chainhash.Hash
+		if pos&1 == 0 {
+			parent = hashNodes(node, sibling)
+		} else {
+			parent = hashNodes(sibling, node)
+		}
+		node = &parent
+		pos >>= 1
+	}
+}
+
+// Root returns the root of the tree in the store.
+func Root(store NodeStore) (chainhash.Hash, error) {
+	root, err := fetchNode(store, BinBits, 0)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return *root, nil
+}
+
+// Branch returns the siblings of the branch of the passed bin in the store,
+// from the bin up to the children of the root.
+func Branch(store NodeStore, bin uint32) ([]chainhash.Hash, error) {
+	branch := make([]chainhash.Hash, 0, BinBits)
+	pos := bin
+	for level := uint8(0); level < BinBits; level++ {
+		sibling, err := fetchNode(store, level, pos^1)
+		if err != nil {
+			return nil, err
+		}
+		branch = append(branch, *sibling)
+		pos >>= 1
+	}
+	return branch, nil
+}
+
+// Proof proves which outputs of a bin are unspent as of a block.
+type Proof struct {
+	// Height and BlockHash identify the block the proof is as of, and
+	// Root is the commitment to the utxo set as of the block.
+	Height    uint32
+	BlockHash chainhash.Hash
+	Root      chainhash.Hash
+
+	// Bin is the bin the proof covers, and Outputs are all of its unspent
+	// outputs in the order of the utxo set.
+	Bin     uint32
+	Outputs []Output
+
+	// Branch holds the siblings of the branch of the bin, from the bin up
+	// to the children of the root.
+	Branch []chainhash.Hash
+}
+
+// Verify returns an error unless the outputs of the proof belong to its bin
+// and the bin is committed to by the root of the proof.
+func (p *Proof) Verify() error {
+	if p.Bin >= NumBins {
+		return fmt.Errorf("bin %d is out of range", p.Bin)
+	}
+	if len(p.Branch) != BinBits {
+		return fmt.Errorf("branch has %d hashes instead of %d",
+			len(p.Branch), BinBits)
+	}
+	for i := range p.Outputs {
+		output := &p.Outputs[i]
+		if Bin(&output.OutPoint.Hash) != p.Bin {
+			return fmt.Errorf("output %v does not belong to bin %d",
+				output.OutPoint, p.Bin)
+		}
+		if i > 0 && !p.Outputs[i-1].less(output) {
+			return fmt.Errorf("output %v is out of order",
+				output.OutPoint)
+		}
+	}
+
+	node := BinDigest(p.Outputs)
+	pos := p.Bin
+	for i := range p.Branch {
+		if pos&1 == 0 {
+			node = hashNodes(&node, &p.Branch[i])
+		} else {
+			node = hashNodes(&p.Branch[i], &node)
+		}
+		pos >>= 1
+	}
+	if node != p.Root {
+		return fmt.Errorf("proof commits to %v instead of root %v",
+			node, p.Root)
+	}
+	return nil
+}
+
+// Unspent returns the passed outpoint when the proof shows it is unspent, or
+// nil when the proof shows it is spent or never existed.  An error is returned
+// when the outpoint does not belong to the bin of the proof.  The proof must
+// have been verified with Verify.
+func (p *Proof) Unspent(outPoint *wire.OutPoint) (*Output, error) {
+	if Bin(&outPoint.Hash) != p.Bin {
+		return nil, fmt.Errorf("outpoint %v does not belong to bin %d",
+			outPoint, p.Bin)
+	}
+	for i := range p.Outputs {
+		if p.Outputs[i].OutPoint == *outPoint {
+			return &p.Outputs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Serialize encodes the proof to w.
+func (p *Proof) Serialize(w io.Writer) error {
+	fields := []interface{}{p.Height, p.BlockHash, p.Root, p.Bin}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	err := wire.WriteVarInt(w, 0, uint64(len(p.Outputs)))
+	if err != nil {
+		return err
+	}
+	for i := range p.Outputs {
+		if err := p.Outputs[i].serialize(w); err != nil {
+			return err
+		}
+	}
+	if err := wire.WriteVarInt(w, 0, uint64(len(p.Branch))); err != nil {
+		return err
+	}
+	for i := range p.Branch {
+		if _, err := w.Write(p.Branch[i][:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize decodes a proof encoded by Serialize from r into the receiver.
+func (p *Proof) Deserialize(r io.Reader) error {
+	fields := []interface{}{&p.Height, &p.BlockHash, &p.Root, &p.Bin}
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	// The number of outputs is only limited by the size of the input, so
+	// they are not preallocated.
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	p.Outputs = nil
+	for i := uint64(0); i < count; i++ {
+		var output Output
+		if err := output.deserialize(r); err != nil {
+			return err
+		}
+		p.Outputs = append(p.Outputs, output)
+	}
+
+	count, err = wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count != BinBits {
+		return fmt.Errorf("branch has %d hashes instead of %d", count,
+			BinBits)
+	}
+	p.Branch = make([]chainhash.Hash, count)
+	for i := range p.Branch {
+		if _, err := io.ReadFull(r, p.Branch[i][:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package utxocommit
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// memNodeStore is a NodeStore which keeps the nodes in memory.
+type memNodeStore map[[2]uint32]chainhash.Hash
+
+func (s memNodeStore) FetchNode(level uint8, pos uint32) (*chainhash.Hash, error) {
+	node, ok := s[[2]uint32{uint32(level), pos}]
+	if !ok {
+		return nil, nil
+	}
+	return &node, nil
+}
+
+func (s memNodeStore) PutNode(level uint8, pos uint32, hash *chainhash.Hash) error {
+	s[[2]uint32{uint32(level), pos}] = *hash
+	return nil
+}
+
+// testOutput returns an output of a transaction whose hash starts with the
+// passed bytes.
+func testOutput(prefix []byte, index uint32, amount int64) Output {
+	var hash chainhash.Hash
+	copy(hash[:], prefix)
+	return Output{
+		OutPoint: wire.OutPoint{Hash: hash, Index: index},
+		Amount:   amount,
+		PkScript: []byte{0x51, byte(index)},
+	}
+}
+
+// TestMultiset ensures the multiset hash does not depend on the order outputs
+// are added and removed in, and survives serialization.
+func TestMultiset(t *testing.T) {
+	a := testOutput([]byte{0x01}, 0, 100)
+	b := testOutput([]byte{0x02}, 1, 200)
+	c := testOutput([]byte{0x03}, 2, 300)
+
+	var empty Multiset
+	if digest := empty.Digest(); digest != (chainhash.Hash{}) {
+		t.Errorf("Digest: got %v for the empty set", digest)
+	}
+
+	var m1, m2 Multiset
+	m1.Add(&a)
+	m1.Add(&b)
+	m2.Add(&c)
+	m2.Add(&b)
+	m2.Add(&a)
+	m2.Remove(&c)
+	if m1.Digest() != m2.Digest() {
+		t.Errorf("Digest: got %v and %v for the same set", m1.Digest(),
+			m2.Digest())
+	}
+	if m1.Digest() != BinDigest([]Output{b, a}) {
+		t.Errorf("BinDigest: got %v, want %v",
+			BinDigest([]Output{b, a}), m1.Digest())
+	}
+
+	m2.Remove(&a)
+	m2.Remove(&b)
+	if digest := m2.Digest(); digest != (chainhash.Hash{}) {
+		t.Errorf("Digest: got %v after removing all outputs", digest)
+	}
+
+	decoded, err := DeserializeMultiset(m1.Serialize())
+	if err != nil {
+		t.Fatalf("DeserializeMultiset: unexpected error: %v", err)
+	}
+	if decoded.Digest() != m1.Digest() {
+		t.Errorf("DeserializeMultiset: got digest %v, want %v",
+			decoded.Digest(), m1.Digest())
+	}
+	decoded, err = DeserializeMultiset(m2.Serialize())
+	if err != nil {
+		t.Fatalf("DeserializeMultiset: unexpected error: %v", err)
+	}
+	if digest := decoded.Digest(); digest != (chainhash.Hash{}) {
+		t.Errorf("DeserializeMultiset: got digest %v for the empty set",
+			digest)
+	}
+}
+
+// TestProof ensures proofs built from the tree in a store verify against its
+// root, survive serialization, and tell unspent outputs apart from others.
+func TestProof(t *testing.T) {
+	// Outputs in bins 0x123, 0x124 and 0xfff.
+	bin := []Output{
+		testOutput([]byte{0x12, 0x30}, 0, 100),
+		testOutput([]byte{0x12, 0x30}, 1, 200),
+		testOutput([]byte{0x12, 0x3f}, 0, 300),
+	}
+	other := testOutput([]byte{0x12, 0x40}, 0, 400)
+	last := testOutput([]byte{0xff, 0xf0}, 0, 500)
+
+	store := make(memNodeStore)
+	root, err := Root(store)
+	if err != nil {
+		t.Fatalf("Root: unexpected error: %v", err)
+	}
+	if root != emptyNodes[BinBits] {
+		t.Errorf("Root: got %v for the empty store, want %v", root,
+			emptyNodes[BinBits])
+	}
+	for _, outputs := range [][]Output{bin, {other}, {last}} {
+		digest := BinDigest(outputs)
+		err := SetBinDigest(store, Bin(&outputs[0].OutPoint.Hash),
+			&digest)
+		if err != nil {
+			t.Fatalf("SetBinDigest: unexpected error: %v", err)
+		}
+	}
+	root, err = Root(store)
+	if err != nil {
+		t.Fatalf("Root: unexpected error: %v", err)
+	}
+
+	if got := Bin(&bin[0].OutPoint.Hash); got != 0x123 {
+		t.Fatalf("Bin: got %x, want 123", got)
+	}
+	start, end := BinRange(0x123)
+	if !bytes.Equal(start[:2], []byte{0x12, 0x30}) ||
+		!bytes.Equal(end[:2], []byte{0x12, 0x40}) {
+		t.Errorf("BinRange: got %x-%x", start, end)
+	}
+	if _, end := BinRange(NumBins - 1); end != nil {
+		t.Errorf("BinRange: got end %x for the last bin", end)
+	}
+
+	branch, err := Branch(store, 0x123)
+	if err != nil {
+		t.Fatalf("Branch: unexpected error: %v", err)
+	}
+	proof := &Proof{
+		Height:    10,
+		BlockHash: chainhash.Hash{0x01},
+		Root:      root,
+		Bin:       0x123,
+		Outputs:   bin,
+		Branch:    branch,
+	}
+	if err := proof.Verify(); err != nil {
+		t.Fatalf("Verify: unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := proof.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	var decoded Proof
+	if err := decoded.Deserialize(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, proof) {
+		t.Fatalf("Deserialize: got %+v, want %+v", decoded, proof)
+	}
+
+	output, err := proof.Unspent(&bin[1].OutPoint)
+	if err != nil || output == nil || output.Amount != 200 {
+		t.Errorf("Unspent: got %+v, %v for an unspent output", output,
+			err)
+	}
+	spent := wire.OutPoint{Hash: bin[1].OutPoint.Hash, Index: 5}
+	if output, err := proof.Unspent(&spent); err != nil || output != nil {
+		t.Errorf("Unspent: got %+v, %v for a spent output", output, err)
+	}
+	if _, err := proof.Unspent(&other.OutPoint); err == nil {
+		t.Errorf("Unspent: no error for an output of another bin")
+	}
+
+	// The proof of the last bin, which is alone in its half of the tree,
+	// verifies as well.
+	branch, err = Branch(store, NumBins-1)
+	if err != nil {
+		t.Fatalf("Branch: unexpected error: %v", err)
+	}
+	lastProof := &Proof{Root: root, Bin: NumBins - 1,
+		Outputs: []Output{last}, Branch: branch}
+	if err := lastProof.Verify(); err != nil {
+		t.Errorf("Verify: unexpected error for the last bin: %v", err)
+	}
+
+	// Omitting, altering, reordering or adding outputs, or altering the
+	// branch, breaks the proof.
+	tampered := []func(p *Proof){
+		func(p *Proof) { p.Outputs = p.Outputs[1:] },
+		func(p *Proof) { p.Outputs[0].Amount++ },
+		func(p *Proof) {
+			p.Outputs[0], p.Outputs[1] = p.Outputs[1], p.Outputs[0]
+		},
+		func(p *Proof) { p.Outputs = append(p.Outputs, other) },
+		func(p *Proof) { p.Branch[3][0] ^= 0x01 },
+		func(p *Proof) { p.Bin = 0x122 },
+	}
+	for i, tamper := range tampered {
+		var p Proof
+		if err := p.Deserialize(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("Deserialize: unexpected error: %v", err)
+		}
+		tamper(&p)
+		if err := p.Verify(); err == nil {
+			t.Errorf("Verify #%d: tampered proof verified", i)
+		}
+	}
+}
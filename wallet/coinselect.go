@@ -0,0 +1,279 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// The coin selection strategies.
+const (
+	// StrategyBranchAndBound searches for a set of coins which funds the
+	// transaction without change, so that no change output is created and
+	// later spent.  It falls back to StrategyLargestFirst when there is no
+	// such set.
+	StrategyBranchAndBound = "branchandbound"
+
+	// StrategyLargestFirst selects the largest coins first, which keeps
+	// the number of inputs and thereby the fee low.
+	StrategyLargestFirst = "largestfirst"
+
+	// StrategyPrivacy spends the coins of as few addresses as possible,
+	// and every coin of the addresses it spends from, so that transactions
+	// link as few addresses together as possible and no address is left
+	// with coins after one of its coins was revealed as spent with it.
+	StrategyPrivacy = "privacy"
+)
+
+// maxBranchAndBoundTries is the maximum number of sets of coins considered by
+// the branch and bound strategy.
+const maxBranchAndBoundTries = 100000
+
+// ErrUnknownStrategy is returned when selecting coins with an unknown
+// strategy.
+var ErrUnknownStrategy = errors.New("unknown coin selection strategy")
+
+// Coin is an unspent output which may fund a transaction.
+type Coin struct {
+	OutPoint wire.OutPoint
+	Amount   provautil.Amount
+	PkScript []byte
+}
+
+// CoinSelectionRequest describes a transaction to fund by selecting coins.
+type CoinSelectionRequest struct {
+	// Strategy is the strategy of the selection.
+	Strategy string
+
+	// Coins are the coins which may be selected.
+	Coins []Coin
+
+	// Inputs are the coins already spent by the transaction.
+	Inputs []Coin
+
+	// Outputs are the outputs of the transaction, without change.
+	Outputs []*wire.TxOut
+
+	// ChangeScript is the public key script of the change output, or a
+	// script of the same size.
+	ChangeScript []byte
+
+	// FeePerKB is the fee rate of the transaction in atoms per kilobyte.
+	FeePerKB provautil.Amount
+}
+
+// CoinSelection is the result of a coin selection.
+type CoinSelection struct {
+	// Coins are the selected coins, to be spent along with the inputs of
+	// the request.
+	Coins []Coin
+
+	// Fee is the fee paid by the transaction, which includes the change
+	// when it is dust.
+	Fee provautil.Amount
+
+	// Change is the amount of the change output, or zero when the
+	// transaction has no change output.
+	Change provautil.Amount
+}
+
+// SelectCoins selects coins funding the transaction described by the passed
+// request.  The remainder is paid to a change output unless it is dust, in
+// which case it is added to the fee.  ErrInsufficientFunds is returned when
+// the coins do not cover the outputs along with the fee.
+func SelectCoins(r *CoinSelectionRequest) (*CoinSelection, error) {
+	var selection *CoinSelection
+	switch r.Strategy {
+	case StrategyBranchAndBound:
+		selection = r.branchAndBound()
+		if selection == nil {
+			selection = r.largestFirst()
+		}
+	case StrategyLargestFirst:
+		selection = r.largestFirst()
+	case StrategyPrivacy:
+		selection = r.privacy()
+	default:
+		return nil, ErrUnknownStrategy
+	}
+	if selection == nil {
+		return nil, ErrInsufficientFunds
+	}
+	return selection, nil
+}
+
+// target returns the amount the selected coins must cover before fees.
+func (r *CoinSelectionRequest) target() provautil.Amount {
+	var target provautil.Amount
+	for _, txOut := range r.Outputs {
+		target += provautil.Amount(txOut.Value)
+	}
+	for _, c := range r.Inputs {
+		target -= c.Amount
+	}
+	return target
+}
+
+// complete returns the selection spending the passed coins, or nil when they
+// do not cover the outputs along with the fee.
+func (r *CoinSelectionRequest) complete(coins []Coin) *CoinSelection {
+	total := -r.target()
+	for _, c := range coins {
+		total += c.Amount
+	}
+	numInputs := len(r.Inputs) + len(coins)
+
+	changeOut := &wire.TxOut{PkScript: r.ChangeScript}
+	withChange := append(append([]*wire.TxOut(nil), r.Outputs...), changeOut)
+	fee := FeeForSize(r.FeePerKB, EstimateSize(numInputs, withChange))
+	if change := total - fee; change >= 0 &&
+		!isDust(change, changeOut, r.FeePerKB) {
+
+		return &CoinSelection{Coins: coins, Fee: fee, Change: change}
+	}
+	fee = FeeForSize(r.FeePerKB, EstimateSize(numInputs, r.Outputs))
+	if total >= fee {
+		return &CoinSelection{Coins: coins, Fee: total}
+	}
+	return nil
+}
+
+// largestFirst returns the selection of the largest coins first, or nil when
+// they do not cover the transaction.
+func (r *CoinSelectionRequest) largestFirst() *CoinSelection {
+	coins := append([]Coin(nil), r.Coins...)
+	sort.SliceStable(coins, func(i, j int) bool {
+		return coins[i].Amount > coins[j].Amount
+	})
+	for n := 0; n <= len(coins); n++ {
+		if selection := r.complete(coins[:n]); selection != nil {
+			return selection
+		}
+	}
+	return nil
+}
+
+// branchAndBound returns the selection of the coins whose value net of the fee
+// for spending them exceeds what the transaction without change needs by the
+// least, as long as the excess is lower than the cost of creating and later
+// spending a change output.  It returns nil when no such set of coins is found
+// within the maximum number of tries.
+func (r *CoinSelectionRequest) branchAndBound() *CoinSelection {
+	if selection := r.complete(nil); selection != nil {
+		return selection
+	}
+
+	// The coins are searched by decreasing effective value, their amount
+	// net of the fee for spending them, and coins which cost more to spend
+	// than they are worth are never selected.
+	inputFee := r.FeePerKB * ProvaInputSize / 1000
+	var coins []Coin
+	var remaining provautil.Amount
+	for _, c := range r.Coins {
+		if c.Amount > inputFee {
+			coins = append(coins, c)
+			remaining += c.Amount - inputFee
+		}
+	}
+	sort.SliceStable(coins, func(i, j int) bool {
+		return coins[i].Amount > coins[j].Amount
+	})
+
+	changeOut := &wire.TxOut{PkScript: r.ChangeScript}
+	changeCost := r.FeePerKB *
+		provautil.Amount(changeOut.SerializeSize()) / 1000
+	needed := r.target() + FeeForSize(r.FeePerKB,
+		EstimateSize(len(r.Inputs), r.Outputs))
+	window := changeCost + inputFee
+
+	var best *CoinSelection
+	var bestExcess provautil.Amount
+	var selected []Coin
+	tries := 0
+	var search func(i int, value, remaining provautil.Amount)
+	search = func(i int, value, remaining provautil.Amount) {
+		tries++
+		if tries > maxBranchAndBoundTries || (best != nil && bestExcess == 0) ||
+			value > needed+window {
+
+			return
+		}
+		if value >= needed {
+			excess := value - needed
+			if best != nil && excess >= bestExcess {
+				return
+			}
+			coins := append([]Coin(nil), selected...)
+			if selection := r.complete(coins); selection != nil &&
+				selection.Change == 0 {
+
+				best, bestExcess = selection, excess
+			}
+			return
+		}
+		if i == len(coins) || value+remaining < needed {
+			return
+		}
+		effective := coins[i].Amount - inputFee
+		selected = append(selected, coins[i])
+		search(i+1, value+effective, remaining-effective)
+		selected = selected[:len(selected)-1]
+		search(i+1, value, remaining-effective)
+	}
+	search(0, 0, remaining)
+	return best
+}
+
+// privacy returns the selection of every coin of the address with the lowest
+// total which covers the transaction by itself, or else of every coin of the
+// addresses with the highest totals until they cover it.  It returns nil when
+// all the coins do not cover the transaction.
+func (r *CoinSelectionRequest) privacy() *CoinSelection {
+	type group struct {
+		pkScript []byte
+		coins    []Coin
+		total    provautil.Amount
+	}
+	var groups []*group
+	byScript := make(map[string]*group)
+	for _, c := range r.Coins {
+		g, ok := byScript[string(c.PkScript)]
+		if !ok {
+			g = &group{pkScript: c.PkScript}
+			byScript[string(c.PkScript)] = g
+			groups = append(groups, g)
+		}
+		g.coins = append(g.coins, c)
+		g.total += c.Amount
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].total != groups[j].total {
+			return groups[i].total > groups[j].total
+		}
+		return bytes.Compare(groups[i].pkScript, groups[j].pkScript) < 0
+	})
+
+	if selection := r.complete(nil); selection != nil {
+		return selection
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		if selection := r.complete(groups[i].coins); selection != nil {
+			return selection
+		}
+	}
+	var coins []Coin
+	for _, g := range groups {
+		coins = append(coins, g.coins...)
+		if selection := r.complete(coins); selection != nil {
+			return selection
+		}
+	}
+	return nil
+}
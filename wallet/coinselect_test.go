@@ -0,0 +1,136 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSelectCoins ensures each coin selection strategy selects the expected
+// coins, and that selections pay their fee and change.
+func TestSelectCoins(t *testing.T) {
+	// Scripts of the size of Prova scripts for two addresses.
+	scriptA := bytes.Repeat([]byte{0x0a}, 31)
+	scriptB := bytes.Repeat([]byte{0x0b}, 31)
+	coin := func(n byte, amount provautil.Amount, pkScript []byte) Coin {
+		return Coin{
+			OutPoint: wire.OutPoint{Hash: chainhash.Hash{n}},
+			Amount:   amount,
+			PkScript: pkScript,
+		}
+	}
+	coins := []Coin{
+		coin(1, 500000, scriptA),
+		coin(2, 300000, scriptB),
+		coin(3, 200000, scriptA),
+		coin(4, 100000, scriptB),
+	}
+	const feePerKB = 1000
+	outputs := func(amount int64) []*wire.TxOut {
+		return []*wire.TxOut{wire.NewTxOut(amount, scriptA)}
+	}
+	// Fee for a transaction spending n inputs to one output without
+	// change.
+	fee := func(n int) provautil.Amount {
+		return FeeForSize(feePerKB, EstimateSize(n, outputs(0)))
+	}
+
+	tests := []struct {
+		name      string
+		strategy  string
+		outputs   []*wire.TxOut
+		want      []byte
+		hasChange bool
+	}{
+		// Coins 2 and 4 cover the outputs exactly, without change.
+		{"branch and bound", StrategyBranchAndBound,
+			outputs(int64(400000 - fee(2))), []byte{2, 4}, false},
+		// No set of coins covers the outputs closely enough, so the
+		// largest coins are selected.
+		{"branch and bound fallback", StrategyBranchAndBound,
+			outputs(250000), []byte{1}, true},
+		{"largest first", StrategyLargestFirst, outputs(600000),
+			[]byte{1, 2}, true},
+		// The coins of address B cover the outputs by themselves, and
+		// are both spent.
+		{"privacy single address", StrategyPrivacy, outputs(350000),
+			[]byte{2, 4}, true},
+		// The coins of both addresses are needed.
+		{"privacy every address", StrategyPrivacy, outputs(800000),
+			[]byte{1, 3, 2, 4}, true},
+	}
+	for _, test := range tests {
+		selection, err := SelectCoins(&CoinSelectionRequest{
+			Strategy:     test.strategy,
+			Coins:        coins,
+			Outputs:      test.outputs,
+			ChangeScript: scriptB,
+			FeePerKB:     feePerKB,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		var got []byte
+		var total provautil.Amount
+		for _, c := range selection.Coins {
+			got = append(got, c.OutPoint.Hash[0])
+			total += c.Amount
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got coins %v, want %v", test.name, got,
+				test.want)
+			continue
+		}
+		if (selection.Change != 0) != test.hasChange {
+			t.Errorf("%s: got change %v", test.name, selection.Change)
+		}
+		if total != provautil.Amount(test.outputs[0].Value)+
+			selection.Fee+selection.Change {
+
+			t.Errorf("%s: got fee %v and change %v for %v of coins",
+				test.name, selection.Fee, selection.Change, total)
+		}
+		if selection.Fee < fee(len(got)) {
+			t.Errorf("%s: got fee %v, want at least %v", test.name,
+				selection.Fee, fee(len(got)))
+		}
+	}
+
+	// Inputs already spent by the transaction are accounted for.
+	selection, err := SelectCoins(&CoinSelectionRequest{
+		Strategy:     StrategyLargestFirst,
+		Coins:        coins,
+		Inputs:       []Coin{coin(5, 1000000, scriptA)},
+		Outputs:      outputs(600000),
+		ChangeScript: scriptB,
+		FeePerKB:     feePerKB,
+	})
+	if err != nil || len(selection.Coins) != 0 {
+		t.Errorf("preset inputs: got selection %+v (%v), want none",
+			selection, err)
+	}
+
+	_, err = SelectCoins(&CoinSelectionRequest{
+		Strategy: StrategyLargestFirst,
+		Coins:    coins,
+		Outputs:  outputs(1100000),
+		FeePerKB: feePerKB,
+	})
+	if err != ErrInsufficientFunds {
+		t.Errorf("insufficient funds: got %v, want %v", err,
+			ErrInsufficientFunds)
+	}
+	_, err = SelectCoins(&CoinSelectionRequest{Strategy: "random"})
+	if err != ErrUnknownStrategy {
+		t.Errorf("unknown strategy: got %v, want %v", err,
+			ErrUnknownStrategy)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package wallet implements a wallet of Prova accounts which is run inside of
+the node.
+
+Overview
+
+Prova outputs are paid to 2-of-3 addresses made of the hash of a holder key
+and the keyIDs of two ASP keys, so spending them needs a signature of the
+holder key and a co-signature of one of the ASP keys.  The wallet derives its
+holder keys from an extended private key: the keys of the addresses handed
+out to receive funds are derived from its first child and the keys of change
+addresses from its second child.  Every address of the wallet uses the same
+two keyIDs.
+
+The wallet follows the main chain through ConnectBlock and DisconnectBlock
+and the memory pool through AddUnconfirmed, and keeps the outputs paying to
+its addresses along with the transactions which created and spent them.  Its
+state is persisted in the metadata of the block database, together with the
+block it is synced to, so it catches up with the blocks connected while the
+node was not running when it is loaded.
+
+Spending
+
+CreateTx selects outputs of the wallet to fund the passed outputs, largest
+first, adds a change output when the remainder is not dust, and signs every
+input with the holder key of its address and an ASP key of one of its keyIDs.
+The ASP keys are passed to the wallet, so it can only complete spends of the
+keyIDs whose ASP keys it holds.
+*/
+package wallet
@@ -0,0 +1,265 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// txOverheadSize is the size of a transaction without its inputs and
+	// outputs: the version, the input and output counts of up to 252
+	// entries and the lock time.
+	txOverheadSize = 4 + 1 + 1 + 4
+
+	// ProvaInputSize is the maximum size of an input spending a 2-of-3
+	// Prova output: the outpoint, the length of the signature script, the
+	// holder and ASP public keys and signatures along with their pushes,
+	// and the sequence.
+	ProvaInputSize = 36 + 1 + 2*(1+33+1+73) + 4
+)
+
+var (
+	// ErrInsufficientFunds is returned when the spendable outputs of the
+	// wallet do not cover the amount to send along with the fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrNoASPKey is returned when the wallet holds none of the ASP keys
+	// assigned to its keyIDs, so it can not co-sign its spends.
+	ErrNoASPKey = errors.New("the wallet holds none of the ASP keys " +
+		"assigned to its keyIDs")
+)
+
+// EstimateSize returns the maximum size of a transaction spending the passed
+// number of Prova outputs to the passed outputs.
+func EstimateSize(numInputs int, outputs []*wire.TxOut) int {
+	size := txOverheadSize + numInputs*ProvaInputSize
+	for _, txOut := range outputs {
+		size += txOut.SerializeSize()
+	}
+	return size
+}
+
+// FeeForSize returns the fee paid at the passed rate in atoms per kilobyte by
+// a transaction of the passed size.  Like the minimum relay fee of the memory
+// pool, a non-zero rate results in a fee of at least one kilobyte.
+func FeeForSize(feePerKB provautil.Amount, size int) provautil.Amount {
+	fee := feePerKB * provautil.Amount(size) / 1000
+	if fee == 0 && feePerKB > 0 {
+		fee = feePerKB
+	}
+	return fee
+}
+
+// isDust returns whether spending an output of the passed value, paying to a
+// script of the passed size, costs more than a third of its value at the
+// passed fee rate.
+func isDust(value provautil.Amount, txOut *wire.TxOut, feePerKB provautil.Amount) bool {
+	if value <= 0 {
+		return true
+	}
+	size := int64(txOut.SerializeSize() + ProvaInputSize)
+	return int64(value)*1000/(3*size) < int64(feePerKB)
+}
+
+// signingKeys returns the holder key of the address the passed public key
+// script pays to along with the ASP key of one of its keyIDs held by the
+// wallet.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) signingKeys(pkScript []byte) ([]txscript.PrivateKey, error) {
+	idx, ok := w.scripts[string(pkScript)]
+	if !ok {
+		return nil, errors.New("output does not pay to the wallet")
+	}
+	child, err := w.branches[idx.branch].Child(idx.index)
+	if err != nil {
+		return nil, err
+	}
+	holderKey, err := child.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aspKey := w.aspKey()
+	if aspKey == nil {
+		return nil, ErrNoASPKey
+	}
+	return []txscript.PrivateKey{
+		{Key: holderKey, Compressed: true},
+		{Key: aspKey, Compressed: true},
+	}, nil
+}
+
+// aspKey returns the first of the ASP keys held by the wallet which is
+// currently assigned to one of the keyIDs of the wallet, or nil when there is
+// none.
+func (w *Wallet) aspKey() *btcec.PrivateKey {
+	keyIDMap := w.cfg.KeyIDMap()
+	for _, keyID := range w.cfg.KeyIDs {
+		pubKey, ok := keyIDMap[keyID]
+		if !ok {
+			continue
+		}
+		for _, aspKey := range w.cfg.ASPKeys {
+			if aspKey.PubKey().IsEqual(pubKey) {
+				return aspKey
+			}
+		}
+	}
+	return nil
+}
+
+// CanSign returns whether the wallet holds an ASP key assigned to one of its
+// keyIDs, which it needs to co-sign its spends.
+func (w *Wallet) CanSign() bool {
+	return w.aspKey() != nil
+}
+
+// CreateTx returns a signed transaction paying the passed outputs, funded by
+// the spendable outputs of the wallet with at least one confirmation and
+// paying a fee at the passed rate in atoms per kilobyte.  The remainder is
+// paid to a new change address unless it is dust, in which case it is added
+// to the fee.
+//
+// The spent outputs are reserved, so they are not selected again, until the
+// transaction is passed to AddUnconfirmed once it has been broadcast, or to
+// Release when it is not going to be broadcast.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) CreateTx(outputs []*wire.TxOut, feePerKB provautil.Amount) (*wire.MsgTx, error) {
+	var target provautil.Amount
+	for _, txOut := range outputs {
+		if txOut.Value <= 0 {
+			return nil, errors.New("output amounts must be positive")
+		}
+		target += provautil.Amount(txOut.Value)
+	}
+	bestHeight := w.cfg.BestSnapshot().Height
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// Select the largest outputs first, which keeps the number of inputs
+	// and thereby the fee low.
+	var eligible []*Credit
+	for _, c := range w.credits {
+		_, reserved := w.reserved[c.OutPoint]
+		if c.SpentBy != nil || reserved || c.Height < 0 ||
+			!w.spendable(c, bestHeight) {
+
+			continue
+		}
+		eligible = append(eligible, c)
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		return eligible[i].Amount > eligible[j].Amount
+	})
+
+	// Every change address has a script of the same size, so the size of
+	// the change output is known before the address is derived.
+	changeOut := &wire.TxOut{PkScript: w.changeScriptTemplate()}
+	withChange := append(append([]*wire.TxOut(nil), outputs...), changeOut)
+
+	var selected []*Credit
+	var total, change provautil.Amount
+	funded := false
+	for _, c := range eligible {
+		selected = append(selected, c)
+		total += c.Amount
+
+		fee := FeeForSize(feePerKB, EstimateSize(len(selected),
+			withChange))
+		if total >= target+fee {
+			change = total - target - fee
+			if isDust(change, changeOut, feePerKB) {
+				change = 0
+			}
+			funded = true
+			break
+		}
+		fee = FeeForSize(feePerKB, EstimateSize(len(selected), outputs))
+		if total >= target+fee {
+			funded = true
+			break
+		}
+	}
+	if !funded {
+		return nil, ErrInsufficientFunds
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, c := range selected {
+		tx.AddTxIn(wire.NewTxIn(&c.OutPoint, nil))
+	}
+	for _, txOut := range outputs {
+		tx.AddTxOut(txOut)
+	}
+	if change > 0 {
+		addr, err := w.nextAddress(internalBranch)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(change), pkScript))
+	}
+
+	for i, c := range selected {
+		keys, err := w.signingKeys(c.PkScript)
+		if err != nil {
+			return nil, err
+		}
+		sigScript, err := txscript.SignTxOutput(w.cfg.ChainParams, tx, i,
+			int64(c.Amount), c.PkScript, txscript.SigHashAll,
+			txscript.KeyClosure(func(provautil.Address) ([]txscript.PrivateKey, error) {
+				return keys, nil
+			}), nil)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	for _, c := range selected {
+		w.reserved[c.OutPoint] = struct{}{}
+	}
+	return tx, nil
+}
+
+// changeScriptTemplate returns a public key script of the size of the scripts
+// of the change addresses of the wallet.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) changeScriptTemplate() []byte {
+	addr, err := provautil.NewAddressProva(make([]byte, 20), w.cfg.KeyIDs,
+		w.cfg.ChainParams)
+	if err != nil {
+		return nil
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	return pkScript
+}
+
+// Release returns the outputs spent by the passed transaction, which was
+// created by CreateTx but is not going to be broadcast, to the outputs which
+// may be selected by further transactions.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Release(tx *wire.MsgTx) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for _, txIn := range tx.TxIn {
+		delete(w.reserved, txIn.PreviousOutPoint)
+	}
+}
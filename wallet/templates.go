@@ -0,0 +1,148 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// MaxTemplateSize is the maximum estimated size of the transactions built from
+// templates, which is the maximum size of the standard transactions relayed by
+// the memory pool.
+const MaxTemplateSize = 100000
+
+// ErrTxTooLarge is returned when the transaction built from a template would
+// exceed MaxTemplateSize once signed.
+var ErrTxTooLarge = errors.New("transaction exceeds the maximum standard size")
+
+// checkTemplateSize returns ErrTxTooLarge when a transaction spending the
+// passed number of Prova outputs to the passed outputs exceeds the maximum
+// size of transactions built from templates once signed.
+func checkTemplateSize(numInputs int, outputs []*wire.TxOut) error {
+	if EstimateSize(numInputs, outputs) > MaxTemplateSize {
+		return ErrTxTooLarge
+	}
+	return nil
+}
+
+// NewSweepTx returns an unsigned transaction spending the passed coins to a
+// single output paying to the passed script, along with the fee it pays at the
+// passed rate in atoms per kilobyte.  ErrInsufficientFunds is returned when
+// the coins do not cover more than the fee and a dust output.
+func NewSweepTx(coins []Coin, pkScript []byte, feePerKB provautil.Amount) (*wire.MsgTx, provautil.Amount, error) {
+	txOut := wire.NewTxOut(0, pkScript)
+	outputs := []*wire.TxOut{txOut}
+	if err := checkTemplateSize(len(coins), outputs); err != nil {
+		return nil, 0, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	var total provautil.Amount
+	for _, c := range coins {
+		tx.AddTxIn(wire.NewTxIn(&c.OutPoint, nil))
+		total += c.Amount
+	}
+	fee := FeeForSize(feePerKB, EstimateSize(len(coins), outputs))
+	if len(coins) == 0 || isDust(total-fee, txOut, feePerKB) {
+		return nil, 0, ErrInsufficientFunds
+	}
+	txOut.Value = int64(total - fee)
+	tx.AddTxOut(txOut)
+	return tx, fee, nil
+}
+
+// MaxSweepInputs returns the maximum number of coins a transaction built by
+// NewSweepTx to the passed script may spend without exceeding MaxTemplateSize.
+func MaxSweepInputs(pkScript []byte) int {
+	outputs := []*wire.TxOut{wire.NewTxOut(0, pkScript)}
+	return (MaxTemplateSize - EstimateSize(0, outputs)) / ProvaInputSize
+}
+
+// ConsolidationCoins returns the coins with an amount below the passed
+// threshold which are worth spending at the passed fee rate, smallest first
+// and up to the passed number of coins.
+func ConsolidationCoins(coins []Coin, threshold provautil.Amount, maxCoins int, feePerKB provautil.Amount) []Coin {
+	inputFee := feePerKB * ProvaInputSize / 1000
+	var small []Coin
+	for _, c := range coins {
+		if c.Amount < threshold && c.Amount > inputFee {
+			small = append(small, c)
+		}
+	}
+	sort.SliceStable(small, func(i, j int) bool {
+		return small[i].Amount < small[j].Amount
+	})
+	if len(small) > maxCoins {
+		small = small[:maxCoins]
+	}
+	return small
+}
+
+// NewSplitTx returns an unsigned transaction spending the passed coin to the
+// passed number of outputs paying to the passed script, along with the fee it
+// pays at the passed rate in atoms per kilobyte.  When amount is zero, the
+// coin is split into outputs of equal amounts net of the fee.  Otherwise every
+// output pays the amount and the remainder is paid back to the script as an
+// additional output unless it is dust, in which case it is added to the fee.
+// ErrInsufficientFunds is returned when the coin does not cover the outputs
+// along with the fee.
+func NewSplitTx(coin Coin, pkScript []byte, count int, amount provautil.Amount,
+	feePerKB provautil.Amount) (*wire.MsgTx, provautil.Amount, error) {
+
+	if count < 1 || amount < 0 {
+		return nil, 0, errors.New("invalid split")
+	}
+	outputs := make([]*wire.TxOut, count)
+	for i := range outputs {
+		outputs[i] = wire.NewTxOut(int64(amount), pkScript)
+	}
+	if err := checkTemplateSize(1, append(outputs,
+		wire.NewTxOut(0, pkScript))); err != nil {
+
+		return nil, 0, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&coin.OutPoint, nil))
+	var fee provautil.Amount
+	if amount == 0 {
+		fee = FeeForSize(feePerKB, EstimateSize(1, outputs))
+		share := (coin.Amount - fee) / provautil.Amount(count)
+		if isDust(share, outputs[0], feePerKB) {
+			return nil, 0, ErrInsufficientFunds
+		}
+		for _, txOut := range outputs {
+			txOut.Value = int64(share)
+		}
+		fee = coin.Amount - share*provautil.Amount(count)
+	} else {
+		if isDust(amount, outputs[0], feePerKB) {
+			return nil, 0, ErrInsufficientFunds
+		}
+		r := &CoinSelectionRequest{
+			Inputs:       []Coin{coin},
+			Outputs:      outputs,
+			ChangeScript: pkScript,
+			FeePerKB:     feePerKB,
+		}
+		selection := r.complete(nil)
+		if selection == nil {
+			return nil, 0, ErrInsufficientFunds
+		}
+		if selection.Change > 0 {
+			outputs = append(outputs, wire.NewTxOut(
+				int64(selection.Change), pkScript))
+		}
+		fee = selection.Fee
+	}
+	for _, txOut := range outputs {
+		tx.AddTxOut(txOut)
+	}
+	return tx, fee, nil
+}
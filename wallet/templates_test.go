@@ -0,0 +1,112 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestTemplates ensures the sweep, consolidation and split templates build
+// transactions which spend their coins entirely and pay the fee for the size
+// of the signed transaction.
+func TestTemplates(t *testing.T) {
+	pkScript := bytes.Repeat([]byte{0x0a}, 31)
+	coin := func(n byte, amount provautil.Amount) Coin {
+		return Coin{
+			OutPoint: wire.OutPoint{Hash: chainhash.Hash{n}},
+			Amount:   amount,
+			PkScript: pkScript,
+		}
+	}
+	const feePerKB = 1000
+	// checkTx ensures the passed transaction spends the passed amount to
+	// its outputs and fee, which is at least the fee for its size.
+	checkTx := func(name string, tx *wire.MsgTx, fee, total provautil.Amount) {
+		var out provautil.Amount
+		for _, txOut := range tx.TxOut {
+			out += provautil.Amount(txOut.Value)
+		}
+		if out+fee != total {
+			t.Errorf("%s: got outputs of %v and fee %v, want a total "+
+				"of %v", name, out, fee, total)
+		}
+		minFee := FeeForSize(feePerKB, EstimateSize(len(tx.TxIn),
+			tx.TxOut))
+		if fee < minFee {
+			t.Errorf("%s: got fee %v, want at least %v", name, fee,
+				minFee)
+		}
+	}
+
+	coins := []Coin{coin(1, 100), coin(2, 5000), coin(3, 20000),
+		coin(4, 3000000)}
+	tx, fee, err := NewSweepTx(coins, pkScript, feePerKB)
+	if err != nil {
+		t.Fatalf("NewSweepTx: unexpected error: %v", err)
+	}
+	if len(tx.TxIn) != 4 || len(tx.TxOut) != 1 {
+		t.Fatalf("NewSweepTx: got %d inputs and %d outputs, want 4 and 1",
+			len(tx.TxIn), len(tx.TxOut))
+	}
+	checkTx("NewSweepTx", tx, fee, 3025100)
+	maxInputs := MaxSweepInputs(pkScript)
+	if err := checkTemplateSize(maxInputs, tx.TxOut); err != nil {
+		t.Errorf("MaxSweepInputs: %d inputs exceed the maximum size",
+			maxInputs)
+	}
+	if err := checkTemplateSize(maxInputs+1, tx.TxOut); err != ErrTxTooLarge {
+		t.Errorf("MaxSweepInputs: %d inputs are not the maximum",
+			maxInputs)
+	}
+	if _, _, err := NewSweepTx(coins[:1], pkScript, feePerKB); err !=
+		ErrInsufficientFunds {
+
+		t.Errorf("NewSweepTx: got %v sweeping dust, want %v", err,
+			ErrInsufficientFunds)
+	}
+
+	// Coins which cost more to spend than they are worth are not
+	// consolidated, and the smallest ones are consolidated first.
+	small := ConsolidationCoins(coins, 1000000, 1, feePerKB)
+	if len(small) != 1 || small[0].Amount != 5000 {
+		t.Errorf("ConsolidationCoins: got %v, want the 5000 atoms coin",
+			small)
+	}
+
+	tx, fee, err = NewSplitTx(coins[3], pkScript, 3, 0, feePerKB)
+	if err != nil {
+		t.Fatalf("NewSplitTx: unexpected error: %v", err)
+	}
+	if len(tx.TxOut) != 3 || tx.TxOut[0].Value != tx.TxOut[2].Value {
+		t.Errorf("NewSplitTx: got unequal outputs %v", tx.TxOut)
+	}
+	checkTx("NewSplitTx equal", tx, fee, 3000000)
+	tx, fee, err = NewSplitTx(coins[3], pkScript, 2, 1000000, feePerKB)
+	if err != nil {
+		t.Fatalf("NewSplitTx: unexpected error: %v", err)
+	}
+	if len(tx.TxOut) != 3 || tx.TxOut[0].Value != 1000000 {
+		t.Errorf("NewSplitTx: got outputs %v, want two denominations "+
+			"and change", tx.TxOut)
+	}
+	checkTx("NewSplitTx denominations", tx, fee, 3000000)
+	if _, _, err := NewSplitTx(coins[3], pkScript, 3, 1000000,
+		feePerKB); err != ErrInsufficientFunds {
+
+		t.Errorf("NewSplitTx: got %v splitting beyond the amount, "+
+			"want %v", err, ErrInsufficientFunds)
+	}
+	if _, _, err := NewSplitTx(coins[3], pkScript, 5000, 0,
+		feePerKB); err != ErrTxTooLarge {
+
+		t.Errorf("NewSplitTx: got %v for too many outputs, want %v",
+			err, ErrTxTooLarge)
+	}
+}
@@ -0,0 +1,1025 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/hdkeychain"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// externalBranch and internalBranch are the children of the holder
+	// extended key from which the keys of receiving and change addresses
+	// are derived.
+	externalBranch = 0
+	internalBranch = 1
+
+	// rollbackDepth is the number of blocks the wallet is rolled back by
+	// when the block it is synced to is no longer part of the main chain
+	// on load.
+	rollbackDepth = 100
+)
+
+var (
+	// bucketName is the name of the metadata bucket which houses the state
+	// of the wallet.
+	bucketName = []byte("wallet")
+
+	// creditsBucketName and txsBucketName are the names of the buckets
+	// nested in the wallet bucket which house the outputs of the wallet
+	// keyed by their outpoints and the transactions of the wallet keyed by
+	// their hashes.
+	creditsBucketName = []byte("credits")
+	txsBucketName     = []byte("txs")
+
+	// stateKey is the key of the derivation indexes and the sync tip of
+	// the wallet in the wallet bucket.
+	stateKey = []byte("state")
+
+	// ErrKeyMismatch is returned when the wallet in the database was
+	// created for a different holder key or different keyIDs.
+	ErrKeyMismatch = errors.New("the wallet in the database was created " +
+		"for a different holder key or keyIDs")
+)
+
+// Config houses the parameters of a wallet.
+type Config struct {
+	// DB is the database the wallet is persisted in.
+	DB database.DB
+
+	// ChainParams identifies the network of the wallet.
+	ChainParams *chaincfg.Params
+
+	// HolderKey is the extended private key the holder keys of the
+	// addresses of the wallet are derived from.
+	HolderKey *hdkeychain.ExtendedKey
+
+	// KeyIDs are the keyIDs of the two ASP keys of every address of the
+	// wallet.
+	KeyIDs []btcec.KeyID
+
+	// ASPKeys are the ASP keys the wallet co-signs its spends with.
+	ASPKeys []*btcec.PrivateKey
+
+	// KeyIDMap returns the ASP keys currently assigned to keyIDs.
+	KeyIDMap func() btcec.KeyIdMap
+
+	// BestSnapshot returns the best block of the main chain.
+	BestSnapshot func() *blockchain.BestState
+
+	// BlockByHeight returns the block of the main chain at the passed
+	// height.  It is used to catch up with the main chain on load.
+	BlockByHeight func(height uint32) (*provautil.Block, error)
+}
+
+// Credit is an output paying to an address of the wallet.
+type Credit struct {
+	// OutPoint identifies the output.
+	OutPoint wire.OutPoint
+
+	// Amount is the value of the output.
+	Amount provautil.Amount
+
+	// PkScript is the public key script of the output.
+	PkScript []byte
+
+	// Height is the height of the block containing the output, or -1 when
+	// it is unconfirmed.
+	Height int32
+
+	// Coinbase is whether the output was created by a coinbase, which
+	// may only be spent once it has matured.
+	Coinbase bool
+
+	// SpentBy is the hash of the transaction spending the output, and nil
+	// while it is unspent.
+	SpentBy *chainhash.Hash
+
+	// SpentHeight is the height of the block containing the spending
+	// transaction, or -1 when the spending transaction is unconfirmed.
+	SpentHeight int32
+}
+
+// TxRecord is a transaction which pays to or spends from the wallet.
+type TxRecord struct {
+	// Tx is the transaction.
+	Tx *wire.MsgTx
+
+	// Hash is the hash of the transaction.
+	Hash chainhash.Hash
+
+	// Height is the height of the block containing the transaction, or -1
+	// when it is unconfirmed.
+	Height int32
+
+	// BlockHash and BlockTime describe the block containing the
+	// transaction, and are only set when it is confirmed.
+	BlockHash chainhash.Hash
+	BlockTime time.Time
+
+	// Received is the time the wallet learned of the transaction.
+	Received time.Time
+}
+
+// addrIndex identifies the derivation of the holder key of an address.
+type addrIndex struct {
+	branch uint32
+	index  uint32
+}
+
+// Wallet keeps track of the outputs paying to the addresses derived from its
+// holder key and creates transactions spending them.
+type Wallet struct {
+	cfg Config
+
+	// branches are the extended keys the holder keys of the receiving and
+	// change addresses are derived from.
+	branches [2]*hdkeychain.ExtendedKey
+
+	mtx       sync.Mutex
+	nextIndex [2]uint32
+	scripts   map[string]addrIndex
+	credits   map[wire.OutPoint]*Credit
+	reserved  map[wire.OutPoint]struct{}
+	txs       map[chainhash.Hash]*TxRecord
+	tipHeight uint32
+	tipHash   chainhash.Hash
+	keyHash   [4]byte
+}
+
+// New returns the wallet persisted in the configured database, creating it
+// synced to the current best block when it does not exist yet, and catches it
+// up with the blocks connected to the main chain since it was last synced.
+func New(cfg *Config) (*Wallet, error) {
+	if !cfg.HolderKey.IsPrivate() {
+		return nil, errors.New("the holder key of a wallet must be " +
+			"an extended private key")
+	}
+	if len(cfg.KeyIDs) != 2 {
+		return nil, errors.New("a wallet needs exactly two keyIDs")
+	}
+
+	w := &Wallet{
+		cfg:      *cfg,
+		scripts:  make(map[string]addrIndex),
+		credits:  make(map[wire.OutPoint]*Credit),
+		reserved: make(map[wire.OutPoint]struct{}),
+		txs:      make(map[chainhash.Hash]*TxRecord),
+		keyHash:  walletKeyHash(cfg.HolderKey, cfg.KeyIDs),
+	}
+	for _, branch := range []uint32{externalBranch, internalBranch} {
+		key, err := cfg.HolderKey.Child(branch)
+		if err != nil {
+			return nil, err
+		}
+		w.branches[branch] = key
+	}
+
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	if err := w.catchUp(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// walletKeyHash returns a short fingerprint of the passed holder key and
+// keyIDs, which ensures a wallet in the database is only loaded with the keys
+// it was created for.
+func walletKeyHash(holderKey *hdkeychain.ExtendedKey, keyIDs []btcec.KeyID) [4]byte {
+	var buf bytes.Buffer
+	pubKey, _ := holderKey.ECPubKey()
+	buf.Write(pubKey.SerializeCompressed())
+	for _, keyID := range keyIDs {
+		binary.Write(&buf, binary.LittleEndian, uint32(keyID))
+	}
+	var keyHash [4]byte
+	copy(keyHash[:], chainhash.DoubleHashB(buf.Bytes()))
+	return keyHash
+}
+
+// serializeState returns the serialized derivation indexes and sync tip of the
+// wallet, which are:
+//
+//   <key hash><next external index><next internal index><tip height><tip hash>
+//
+//   Field           Type       Size
+//   key hash        [4]byte    4
+//   next indexes    uint32     4 each
+//   tip height      uint32     4
+//   tip hash        [32]byte   32
+func (w *Wallet) serializeState() []byte {
+	buf := make([]byte, 16+chainhash.HashSize)
+	copy(buf, w.keyHash[:])
+	binary.LittleEndian.PutUint32(buf[4:], w.nextIndex[externalBranch])
+	binary.LittleEndian.PutUint32(buf[8:], w.nextIndex[internalBranch])
+	binary.LittleEndian.PutUint32(buf[12:], w.tipHeight)
+	copy(buf[16:], w.tipHash[:])
+	return buf
+}
+
+// serializeCredit returns the serialized credit, which is:
+//
+//   <amount><height><flags><spent height><spent by><script>
+//
+//   Field          Type       Size
+//   amount         int64      8
+//   height         int32      4
+//   flags          uint8      1 (bit 0 coinbase, bit 1 spent)
+//   spent height   int32      4
+//   spent by       [32]byte   32
+//   script         []byte     variable
+func serializeCredit(c *Credit) []byte {
+	buf := make([]byte, 49+len(c.PkScript))
+	binary.LittleEndian.PutUint64(buf, uint64(c.Amount))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(c.Height))
+	if c.Coinbase {
+		buf[12] |= 1
+	}
+	if c.SpentBy != nil {
+		buf[12] |= 2
+		copy(buf[17:], c.SpentBy[:])
+	}
+	binary.LittleEndian.PutUint32(buf[13:], uint32(c.SpentHeight))
+	copy(buf[49:], c.PkScript)
+	return buf
+}
+
+// deserializeCredit decodes the passed serialized credit of the passed
+// outpoint.
+func deserializeCredit(op wire.OutPoint, serialized []byte) (*Credit, error) {
+	if len(serialized) < 49 {
+		return nil, fmt.Errorf("corrupt wallet credit %v: %d bytes", op,
+			len(serialized))
+	}
+	c := &Credit{
+		OutPoint:    op,
+		Amount:      provautil.Amount(binary.LittleEndian.Uint64(serialized)),
+		Height:      int32(binary.LittleEndian.Uint32(serialized[8:])),
+		Coinbase:    serialized[12]&1 != 0,
+		SpentHeight: int32(binary.LittleEndian.Uint32(serialized[13:])),
+		PkScript:    append([]byte(nil), serialized[49:]...),
+	}
+	if serialized[12]&2 != 0 {
+		var spentBy chainhash.Hash
+		copy(spentBy[:], serialized[17:49])
+		c.SpentBy = &spentBy
+	}
+	return c, nil
+}
+
+// outPointKey returns the database key of the credit of the passed outpoint.
+func outPointKey(op *wire.OutPoint) []byte {
+	key := make([]byte, chainhash.HashSize+4)
+	copy(key, op.Hash[:])
+	binary.LittleEndian.PutUint32(key[chainhash.HashSize:], op.Index)
+	return key
+}
+
+// serializeTxRecord returns the serialized transaction record, which is:
+//
+//   <height><block hash><block time><received><tx>
+//
+//   Field        Type       Size
+//   height       int32      4
+//   block hash   [32]byte   32
+//   block time   int64      8
+//   received     int64      8
+//   tx           []byte     variable
+func serializeTxRecord(r *TxRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	var header [52]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(r.Height))
+	copy(header[4:], r.BlockHash[:])
+	if r.Height >= 0 {
+		binary.LittleEndian.PutUint64(header[36:],
+			uint64(r.BlockTime.Unix()))
+	}
+	binary.LittleEndian.PutUint64(header[44:], uint64(r.Received.Unix()))
+	buf.Write(header[:])
+	if err := r.Tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializeTxRecord decodes the passed serialized transaction record.
+func deserializeTxRecord(serialized []byte) (*TxRecord, error) {
+	if len(serialized) < 52 {
+		return nil, fmt.Errorf("corrupt wallet transaction: %d bytes",
+			len(serialized))
+	}
+	r := &TxRecord{
+		Tx:     new(wire.MsgTx),
+		Height: int32(binary.LittleEndian.Uint32(serialized)),
+		Received: time.Unix(int64(binary.LittleEndian.Uint64(
+			serialized[44:])), 0),
+	}
+	copy(r.BlockHash[:], serialized[4:36])
+	if r.Height >= 0 {
+		r.BlockTime = time.Unix(int64(binary.LittleEndian.Uint64(
+			serialized[36:])), 0)
+	}
+	if err := r.Tx.Deserialize(bytes.NewReader(serialized[52:])); err != nil {
+		return nil, fmt.Errorf("corrupt wallet transaction: %v", err)
+	}
+	r.Hash = r.Tx.TxHash()
+	return r, nil
+}
+
+// load reads the state of the wallet from the database, creating it synced to
+// the current best block when it does not exist yet.
+func (w *Wallet) load() error {
+	return w.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		credits, err := bucket.CreateBucketIfNotExists(creditsBucketName)
+		if err != nil {
+			return err
+		}
+		txs, err := bucket.CreateBucketIfNotExists(txsBucketName)
+		if err != nil {
+			return err
+		}
+
+		state := bucket.Get(stateKey)
+		if state == nil {
+			best := w.cfg.BestSnapshot()
+			w.tipHeight = best.Height
+			w.tipHash = *best.Hash
+			log.Infof("Created wallet synced to block %v (height %d)",
+				best.Hash, best.Height)
+			return bucket.Put(stateKey, w.serializeState())
+		}
+		if len(state) != 16+chainhash.HashSize {
+			return fmt.Errorf("corrupt wallet state: %d bytes",
+				len(state))
+		}
+		if !bytes.Equal(state[:4], w.keyHash[:]) {
+			return ErrKeyMismatch
+		}
+		w.nextIndex[externalBranch] = binary.LittleEndian.Uint32(state[4:])
+		w.nextIndex[internalBranch] = binary.LittleEndian.Uint32(state[8:])
+		w.tipHeight = binary.LittleEndian.Uint32(state[12:])
+		copy(w.tipHash[:], state[16:])
+
+		for _, branch := range []uint32{externalBranch, internalBranch} {
+			for i := uint32(0); i < w.nextIndex[branch]; i++ {
+				_, err := w.deriveAddress(branch, i)
+				if err != nil && err != hdkeychain.ErrInvalidChild {
+					return err
+				}
+			}
+		}
+
+		err = credits.ForEach(func(k, v []byte) error {
+			var op wire.OutPoint
+			copy(op.Hash[:], k)
+			op.Index = binary.LittleEndian.Uint32(k[chainhash.HashSize:])
+			c, err := deserializeCredit(op, v)
+			if err != nil {
+				return err
+			}
+			w.credits[op] = c
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return txs.ForEach(func(k, v []byte) error {
+			r, err := deserializeTxRecord(v)
+			if err != nil {
+				return err
+			}
+			w.txs[r.Hash] = r
+			return nil
+		})
+	})
+}
+
+// tipInMainChain returns whether the block the wallet is synced to is part of
+// the main chain.
+func (w *Wallet) tipInMainChain(best *blockchain.BestState) (bool, error) {
+	if w.tipHeight > best.Height {
+		return false, nil
+	}
+	block, err := w.cfg.BlockByHeight(w.tipHeight)
+	if err != nil {
+		return false, err
+	}
+	return *block.Hash() == w.tipHash, nil
+}
+
+// catchUp connects the blocks of the main chain following the block the
+// wallet is synced to.  When that block is no longer part of the main chain,
+// the wallet is rolled back first.
+func (w *Wallet) catchUp() error {
+	best := w.cfg.BestSnapshot()
+	inMainChain, err := w.tipInMainChain(best)
+	if err != nil {
+		return err
+	}
+	if !inMainChain {
+		// The block the wallet is synced to has been disconnected
+		// while the wallet was not following the chain, so forget
+		// about everything which might have been confirmed by the
+		// disconnected blocks.  The genesis block is never
+		// disconnected.
+		from := int64(w.tipHeight) - rollbackDepth
+		if from > int64(best.Height) {
+			from = int64(best.Height)
+		}
+		if from < 1 {
+			from = 1
+		}
+		log.Warnf("Wallet block %v (height %d) is not in the main "+
+			"chain -- rescanning from height %d", w.tipHash,
+			w.tipHeight, from)
+		w.mtx.Lock()
+		err := w.unconfirmFrom(int32(from))
+		w.mtx.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.tipHeight < best.Height {
+		log.Infof("Catching up wallet from height %d to %d",
+			w.tipHeight, best.Height)
+	}
+	for height := w.tipHeight + 1; height <= best.Height; height++ {
+		block, err := w.cfg.BlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		if err := w.ConnectBlock(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deriveAddress derives the address at the passed index of the passed branch
+// and records its public key script.
+//
+// This function MUST be called with the wallet lock held (for writes), or
+// before the wallet is shared.
+func (w *Wallet) deriveAddress(branch, index uint32) (*provautil.AddressProva, error) {
+	child, err := w.branches[branch].Child(index)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := child.Address(w.cfg.KeyIDs, w.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	w.scripts[string(pkScript)] = addrIndex{branch: branch, index: index}
+	return addr, nil
+}
+
+// nextAddress derives the next unused address of the passed branch and
+// persists the advanced derivation index.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) nextAddress(branch uint32) (*provautil.AddressProva, error) {
+	// Indexes yielding invalid keys are skipped, as with any HD wallet.
+	for {
+		index := w.nextIndex[branch]
+		addr, err := w.deriveAddress(branch, index)
+		w.nextIndex[branch]++
+		if err == hdkeychain.ErrInvalidChild {
+			continue
+		}
+		if err != nil {
+			w.nextIndex[branch]--
+			return nil, err
+		}
+		err = w.cfg.DB.Update(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket(bucketName)
+			return bucket.Put(stateKey, w.serializeState())
+		})
+		if err != nil {
+			return nil, err
+		}
+		return addr, nil
+	}
+}
+
+// NewAddress returns a new address to receive funds with.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) NewAddress() (*provautil.AddressProva, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.nextAddress(externalBranch)
+}
+
+// KeyIDs returns the keyIDs of the addresses of the wallet.
+func (w *Wallet) KeyIDs() []btcec.KeyID {
+	return append([]btcec.KeyID(nil), w.cfg.KeyIDs...)
+}
+
+// Tip returns the hash and height of the block the wallet is synced to.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Tip() (chainhash.Hash, uint32) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.tipHash, w.tipHeight
+}
+
+// walletChanges collects the credits and transaction records modified while
+// processing a block or transaction, so they are persisted at once.
+type walletChanges struct {
+	credits []*Credit
+	txs     []*TxRecord
+	removed []*TxRecord
+}
+
+// persist writes the passed changes along with the state of the wallet to the
+// database.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) persist(changes *walletChanges) error {
+	return w.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		credits := bucket.Bucket(creditsBucketName)
+		txs := bucket.Bucket(txsBucketName)
+		for _, r := range changes.removed {
+			if err := txs.Delete(r.Hash[:]); err != nil {
+				return err
+			}
+			for i := range r.Tx.TxOut {
+				op := wire.OutPoint{Hash: r.Hash, Index: uint32(i)}
+				if err := credits.Delete(outPointKey(&op)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range changes.credits {
+			err := credits.Put(outPointKey(&c.OutPoint),
+				serializeCredit(c))
+			if err != nil {
+				return err
+			}
+		}
+		for _, r := range changes.txs {
+			serialized, err := serializeTxRecord(r)
+			if err != nil {
+				return err
+			}
+			if err := txs.Put(r.Hash[:], serialized); err != nil {
+				return err
+			}
+		}
+		return bucket.Put(stateKey, w.serializeState())
+	})
+}
+
+// removeTx forgets about the passed unconfirmed transaction, which conflicts
+// with a confirmed one, along with the transactions spending its outputs.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) removeTx(r *TxRecord, changes *walletChanges) {
+	delete(w.txs, r.Hash)
+	changes.removed = append(changes.removed, r)
+	for i := range r.Tx.TxOut {
+		op := wire.OutPoint{Hash: r.Hash, Index: uint32(i)}
+		c, ok := w.credits[op]
+		if !ok {
+			continue
+		}
+		delete(w.credits, op)
+		if c.SpentBy != nil {
+			if spender, ok := w.txs[*c.SpentBy]; ok {
+				w.removeTx(spender, changes)
+			}
+		}
+	}
+	for _, txIn := range r.Tx.TxIn {
+		c, ok := w.credits[txIn.PreviousOutPoint]
+		if ok && c.SpentBy != nil && *c.SpentBy == r.Hash {
+			c.SpentBy = nil
+			c.SpentHeight = -1
+			changes.credits = append(changes.credits, c)
+		}
+	}
+}
+
+// processTx records the passed transaction when it pays to or spends from the
+// wallet.  A height of -1 marks the transaction as unconfirmed.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) processTx(tx *wire.MsgTx, coinbase bool, height int32,
+	blockHash *chainhash.Hash, blockTime time.Time, changes *walletChanges) {
+
+	hash := tx.TxHash()
+	relevant := false
+	for _, txIn := range tx.TxIn {
+		if _, ok := w.credits[txIn.PreviousOutPoint]; ok {
+			relevant = true
+			break
+		}
+	}
+	for _, txOut := range tx.TxOut {
+		if _, ok := w.scripts[string(txOut.PkScript)]; ok {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return
+	}
+
+	r, ok := w.txs[hash]
+	if !ok {
+		r = &TxRecord{
+			Tx:       tx,
+			Hash:     hash,
+			Height:   -1,
+			Received: time.Now(),
+		}
+		w.txs[hash] = r
+	}
+	if height >= 0 {
+		r.Height = height
+		r.BlockHash = *blockHash
+		r.BlockTime = blockTime
+	}
+	changes.txs = append(changes.txs, r)
+
+	for _, txIn := range tx.TxIn {
+		c, ok := w.credits[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+
+		// A confirmed transaction spending an output which is spent
+		// by an unconfirmed transaction replaces the latter.
+		if c.SpentBy != nil && *c.SpentBy != hash {
+			if other, ok := w.txs[*c.SpentBy]; ok && other.Height < 0 {
+				if height < 0 {
+					continue
+				}
+				log.Infof("Removing wallet transaction %v, which "+
+					"conflicts with %v", other.Hash, hash)
+				w.removeTx(other, changes)
+			}
+		}
+		c.SpentBy = &hash
+		c.SpentHeight = height
+		delete(w.reserved, c.OutPoint)
+		changes.credits = append(changes.credits, c)
+	}
+
+	for i, txOut := range tx.TxOut {
+		if _, ok := w.scripts[string(txOut.PkScript)]; !ok {
+			continue
+		}
+		op := wire.OutPoint{Hash: hash, Index: uint32(i)}
+		c, ok := w.credits[op]
+		if !ok {
+			c = &Credit{
+				OutPoint:    op,
+				Amount:      provautil.Amount(txOut.Value),
+				PkScript:    txOut.PkScript,
+				Coinbase:    coinbase,
+				SpentHeight: -1,
+			}
+			w.credits[op] = c
+		}
+		c.Height = height
+		changes.credits = append(changes.credits, c)
+	}
+}
+
+// ConnectBlock records the transactions of the passed block, which was
+// connected to the main chain, which pay to or spend from the wallet.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) ConnectBlock(block *provautil.Block) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	var changes walletChanges
+	height := int32(block.Height())
+	header := &block.MsgBlock().Header
+	for i, tx := range block.Transactions() {
+		w.processTx(tx.MsgTx(), i == 0, height, block.Hash(),
+			header.Timestamp, &changes)
+	}
+	w.tipHeight = block.Height()
+	w.tipHash = *block.Hash()
+	return w.persist(&changes)
+}
+
+// unconfirmFrom marks the transactions and outputs confirmed at or above the
+// passed height, which must be positive, as unconfirmed, and syncs the wallet
+// to the block before.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *Wallet) unconfirmFrom(height int32) error {
+	var changes walletChanges
+	for _, r := range w.txs {
+		if r.Height >= height {
+			r.Height = -1
+			r.BlockHash = chainhash.Hash{}
+			r.BlockTime = time.Time{}
+			changes.txs = append(changes.txs, r)
+		}
+	}
+	for _, c := range w.credits {
+		modified := false
+		if c.Height >= height {
+			c.Height = -1
+			modified = true
+		}
+		if c.SpentBy != nil && c.SpentHeight >= height {
+			c.SpentHeight = -1
+			modified = true
+		}
+		if modified {
+			changes.credits = append(changes.credits, c)
+		}
+	}
+	block, err := w.cfg.BlockByHeight(uint32(height - 1))
+	if err != nil {
+		return err
+	}
+	w.tipHeight = uint32(height - 1)
+	w.tipHash = *block.Hash()
+	return w.persist(&changes)
+}
+
+// DisconnectBlock marks the transactions of the passed block, which was
+// disconnected from the main chain, as unconfirmed.  Coinbase transactions are
+// forgotten about, since they can never be confirmed by another block.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) DisconnectBlock(block *provautil.Block) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	var changes walletChanges
+	height := int32(block.Height())
+	for _, r := range w.txs {
+		if r.Height != height {
+			continue
+		}
+		if blockchain.IsCoinBaseTx(r.Tx) {
+			w.removeTx(r, &changes)
+			continue
+		}
+		r.Height = -1
+		r.BlockHash = chainhash.Hash{}
+		r.BlockTime = time.Time{}
+		changes.txs = append(changes.txs, r)
+	}
+	for _, c := range w.credits {
+		modified := false
+		if c.Height == height {
+			c.Height = -1
+			modified = true
+		}
+		if c.SpentBy != nil && c.SpentHeight == height {
+			c.SpentHeight = -1
+			modified = true
+		}
+		if modified {
+			changes.credits = append(changes.credits, c)
+		}
+	}
+	w.tipHeight = block.Height() - 1
+	w.tipHash = block.MsgBlock().Header.PrevBlock
+	return w.persist(&changes)
+}
+
+// AddUnconfirmed records the passed transaction, which was accepted into the
+// memory pool, when it pays to or spends from the wallet.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) AddUnconfirmed(tx *wire.MsgTx) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	var changes walletChanges
+	w.processTx(tx, false, -1, nil, time.Time{}, &changes)
+	if len(changes.txs) == 0 {
+		return nil
+	}
+	return w.persist(&changes)
+}
+
+// confirmations returns the number of confirmations of a transaction at the
+// passed height when the best block is at the passed best height.
+func confirmations(height int32, bestHeight uint32) int32 {
+	if height < 0 {
+		return 0
+	}
+	return int32(bestHeight) - height + 1
+}
+
+// spendable returns whether the passed credit may be spent in the block after
+// the passed best height, which is not the case for immature coinbases.
+func (w *Wallet) spendable(c *Credit, bestHeight uint32) bool {
+	if !c.Coinbase {
+		return true
+	}
+	return confirmations(c.Height, bestHeight) >=
+		int32(w.cfg.ChainParams.CoinbaseMaturity)
+}
+
+// UnspentOutput is an unspent output of the wallet.
+type UnspentOutput struct {
+	*Credit
+
+	// Address is the address the output pays to.
+	Address provautil.Address
+
+	// Confirmations is the number of confirmations of the output.
+	Confirmations int32
+
+	// Spendable is whether the output may be spent, which is not the case
+	// for immature coinbases.
+	Spendable bool
+}
+
+// Unspent returns the unspent outputs of the wallet with a number of
+// confirmations within the passed range, ordered by their outpoints.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Unspent(minConf, maxConf int32) []*UnspentOutput {
+	bestHeight := w.cfg.BestSnapshot().Height
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	var unspent []*UnspentOutput
+	for _, c := range w.credits {
+		if c.SpentBy != nil {
+			continue
+		}
+		confs := confirmations(c.Height, bestHeight)
+		if confs < minConf || confs > maxConf {
+			continue
+		}
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(c.PkScript,
+			w.cfg.ChainParams)
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		unspent = append(unspent, &UnspentOutput{
+			Credit:        c,
+			Address:       addrs[0],
+			Confirmations: confs,
+			Spendable:     w.spendable(c, bestHeight),
+		})
+	}
+	sort.Slice(unspent, func(i, j int) bool {
+		a, b := unspent[i].OutPoint, unspent[j].OutPoint
+		if a.Hash != b.Hash {
+			return bytes.Compare(a.Hash[:], b.Hash[:]) < 0
+		}
+		return a.Index < b.Index
+	})
+	return unspent
+}
+
+// Balance returns the sum of the spendable unspent outputs of the wallet with
+// at least the passed number of confirmations.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Balance(minConf int32) provautil.Amount {
+	var balance provautil.Amount
+	for _, u := range w.Unspent(minConf, int32(^uint32(0)>>1)) {
+		if u.Spendable {
+			balance += u.Amount
+		}
+	}
+	return balance
+}
+
+// TxDetails describes a transaction of the wallet from the point of view of
+// the wallet.
+type TxDetails struct {
+	*TxRecord
+
+	// Confirmations is the number of confirmations of the transaction.
+	Confirmations int32
+
+	// Debit is the sum of the outputs of the wallet spent by the
+	// transaction.
+	Debit provautil.Amount
+
+	// Credits are the indexes of the outputs of the transaction which pay
+	// to the wallet.
+	Credits []uint32
+
+	// Change are the indexes of the outputs of the transaction which pay
+	// to change addresses of the wallet.
+	Change []uint32
+
+	// Fee is the fee paid by the transaction, which is only known when
+	// all of its inputs spend outputs of the wallet.
+	Fee *provautil.Amount
+}
+
+// Transactions returns the details of up to count transactions of the wallet,
+// skipping the from most recent ones, ordered from the oldest to the most
+// recent one.
+//
+// This function is safe for concurrent access.
+func (w *Wallet) Transactions(count, from int) []*TxDetails {
+	bestHeight := w.cfg.BestSnapshot().Height
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	// Order the transactions by the height of their block, with the
+	// unconfirmed ones last, and then by the time they were received.
+	records := make([]*TxRecord, 0, len(w.txs))
+	for _, r := range w.txs {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		hi, hj := uint32(records[i].Height), uint32(records[j].Height)
+		if hi != hj {
+			return hi < hj
+		}
+		return records[i].Received.Before(records[j].Received)
+	})
+
+	end := len(records) - from
+	if end < 0 {
+		end = 0
+	}
+	start := end - count
+	if start < 0 {
+		start = 0
+	}
+	details := make([]*TxDetails, 0, end-start)
+	for _, r := range records[start:end] {
+		details = append(details, w.txDetails(r, bestHeight))
+	}
+	return details
+}
+
+// txDetails returns the details of the passed transaction record.
+//
+// This function MUST be called with the wallet lock held (for reads).
+func (w *Wallet) txDetails(r *TxRecord, bestHeight uint32) *TxDetails {
+	d := &TxDetails{
+		TxRecord:      r,
+		Confirmations: confirmations(r.Height, bestHeight),
+	}
+	allInputs := !blockchain.IsCoinBaseTx(r.Tx)
+	for _, txIn := range r.Tx.TxIn {
+		if c, ok := w.credits[txIn.PreviousOutPoint]; ok {
+			d.Debit += c.Amount
+		} else {
+			allInputs = false
+		}
+	}
+	var totalOut provautil.Amount
+	for i, txOut := range r.Tx.TxOut {
+		totalOut += provautil.Amount(txOut.Value)
+		idx, ok := w.scripts[string(txOut.PkScript)]
+		if !ok {
+			continue
+		}
+		if idx.branch == internalBranch && d.Debit > 0 {
+			d.Change = append(d.Change, uint32(i))
+		} else {
+			d.Credits = append(d.Credits, uint32(i))
+		}
+	}
+	if allInputs {
+		fee := d.Debit - totalOut
+		d.Fee = &fee
+	}
+	return d
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/hdkeychain"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// testChain is a main chain of blocks the wallet under test follows.
+type testChain []*provautil.Block
+
+func (c *testChain) bestSnapshot() *blockchain.BestState {
+	tip := (*c)[len(*c)-1]
+	return &blockchain.BestState{Hash: tip.Hash(), Height: tip.Height()}
+}
+
+func (c *testChain) blockByHeight(height uint32) (*provautil.Block, error) {
+	return (*c)[height], nil
+}
+
+// addBlock appends a block with a coinbase and the passed transactions to the
+// chain.
+func (c *testChain) addBlock(txs ...*wire.MsgTx) *provautil.Block {
+	tip := (*c)[len(*c)-1]
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_TRUE}))
+
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: *tip.Hash(),
+			Timestamp: time.Unix(1500000000+int64(len(*c)), 0),
+		},
+		Transactions: append([]*wire.MsgTx{coinbase}, txs...),
+	}
+	block := provautil.NewBlock(msgBlock)
+	block.SetHeight(tip.Height() + 1)
+	*c = append(*c, block)
+	return block
+}
+
+// TestWallet ensures the wallet tracks the outputs paying to its addresses
+// through connected and disconnected blocks and unconfirmed transactions,
+// creates signed spends reserving their inputs, and is persisted.
+func TestWallet(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.RegressionNetParams
+	genesis := provautil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	chain := testChain{genesis}
+
+	seed := make([]byte, hdkeychain.RecommendedSeedLen)
+	holderKey, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		t.Fatalf("NewMaster: unexpected error: %v", err)
+	}
+	aspKey, _ := btcec.NewPrivateKey(btcec.S256())
+	keyIDs := []btcec.KeyID{1, 2}
+	cfg := &Config{
+		DB:          db,
+		ChainParams: params,
+		HolderKey:   holderKey,
+		KeyIDs:      keyIDs,
+		ASPKeys:     []*btcec.PrivateKey{aspKey},
+		KeyIDMap: func() btcec.KeyIdMap {
+			return btcec.KeyIdMap{keyIDs[0]: aspKey.PubKey()}
+		},
+		BestSnapshot:  chain.bestSnapshot,
+		BlockByHeight: chain.blockByHeight,
+	}
+	w, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if !w.CanSign() {
+		t.Fatalf("CanSign: wallet holding an ASP key can not sign")
+	}
+
+	// Fund a new address of the wallet in the next block.
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatalf("NewAddress: unexpected error: %v", err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x01},
+		0), nil))
+	fundingTx.AddTxOut(wire.NewTxOut(10000000, pkScript))
+	block1 := chain.addBlock(fundingTx)
+	if err := w.ConnectBlock(block1); err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+	if got := w.Balance(1); got != 10000000 {
+		t.Fatalf("Balance: got %v, want %v", got, 10000000)
+	}
+	unspent := w.Unspent(1, 9999999)
+	if len(unspent) != 1 || unspent[0].Address.String() != addr.String() ||
+		unspent[0].Confirmations != 1 {
+
+		t.Fatalf("Unspent: unexpected outputs %v", unspent)
+	}
+
+	// Spend part of the output, which leaves change, and ensure the spent
+	// output is reserved until released.
+	otherAddr, _ := provautil.NewAddressProva(make([]byte, 20), keyIDs,
+		params)
+	otherScript, _ := txscript.PayToAddrScript(otherAddr)
+	outputs := []*wire.TxOut{wire.NewTxOut(4000000, otherScript)}
+	tx, err := w.CreateTx(outputs, 1000)
+	if err != nil {
+		t.Fatalf("CreateTx: unexpected error: %v", err)
+	}
+	if len(tx.TxIn) != 1 || len(tx.TxOut) != 2 {
+		t.Fatalf("CreateTx: got %d inputs and %d outputs, want 1 and 2",
+			len(tx.TxIn), len(tx.TxOut))
+	}
+	pushes, err := txscript.PushedData(tx.TxIn[0].SignatureScript)
+	if err != nil || len(pushes) != 4 {
+		t.Fatalf("CreateTx: got %d signature script pushes, want 4",
+			len(pushes))
+	}
+	if _, err := w.CreateTx(outputs, 1000); err != ErrInsufficientFunds {
+		t.Fatalf("CreateTx: reserved output was selected again: %v", err)
+	}
+	w.Release(tx)
+	if tx, err = w.CreateTx(outputs, 1000); err != nil {
+		t.Fatalf("CreateTx: released output was not selected: %v", err)
+	}
+	change := provautil.Amount(tx.TxOut[1].Value)
+
+	// Once the spend is accepted into the memory pool, only the change
+	// remains, and it is unconfirmed.
+	if err := w.AddUnconfirmed(tx); err != nil {
+		t.Fatalf("AddUnconfirmed: unexpected error: %v", err)
+	}
+	if got := w.Balance(1); got != 0 {
+		t.Fatalf("Balance: got %v confirmed, want 0", got)
+	}
+	if got := w.Balance(0); got != change {
+		t.Fatalf("Balance: got %v unconfirmed, want %v", got, change)
+	}
+	details := w.Transactions(10, 0)
+	if len(details) != 2 {
+		t.Fatalf("Transactions: got %d transactions, want 2",
+			len(details))
+	}
+	spend := details[1]
+	if spend.Hash != tx.TxHash() || spend.Fee == nil ||
+		*spend.Fee != 10000000-4000000-change || len(spend.Change) != 1 {
+
+		t.Fatalf("Transactions: unexpected spend details %+v", spend)
+	}
+
+	// The wallet is persisted, and refuses to be loaded with other keyIDs.
+	w, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New: unexpected error reloading wallet: %v", err)
+	}
+	if got := w.Balance(0); got != change {
+		t.Fatalf("Balance: got %v after reload, want %v", got, change)
+	}
+	otherCfg := *cfg
+	otherCfg.KeyIDs = []btcec.KeyID{1, 3}
+	if _, err := New(&otherCfg); err != ErrKeyMismatch {
+		t.Fatalf("New: got %v for other keyIDs, want %v", err,
+			ErrKeyMismatch)
+	}
+
+	// Disconnecting the funding block leaves the funding transaction
+	// unconfirmed.
+	if err := w.DisconnectBlock(block1); err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+	if hash, height := w.Tip(); hash != *genesis.Hash() || height != 0 {
+		t.Fatalf("Tip: got %v (height %d), want genesis", hash, height)
+	}
+	details = w.Transactions(10, 0)
+	if len(details) != 2 || details[0].Confirmations != 0 {
+		t.Fatalf("Transactions: funding transaction still confirmed")
+	}
+}
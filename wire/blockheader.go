@@ -143,14 +143,37 @@ func (h *BlockHeader) hashForSigning() []byte {
 	return chainhash.PowHashB(buf.Bytes())
 }
 
-// Sign uses the supplied private key to sign the signing-hash of the block
-// header, and sets it in the Signature field.
-func (h *BlockHeader) Sign(key *btcec.PrivateKey) error {
+// HeaderSigner is implemented by signers which sign whole block headers rather
+// than their signing-hash, such as remote signing services, so the holder of
+// the validate key can inspect the header it signs.
+type HeaderSigner interface {
+	btcec.Signer
+
+	// SignHeader returns the signature of the signing-hash of the passed
+	// block header.
+	SignHeader(header *BlockHeader) (*btcec.Signature, error)
+}
+
+// Sign uses the supplied signer to sign the signing-hash of the block header,
+// and sets it in the Signature field.  Signers implementing HeaderSigner are
+// passed the whole header.  The signature is verified before it is set, so a
+// faulty signer can not produce an invalid block.
+func (h *BlockHeader) Sign(key btcec.Signer) error {
 	hash := h.hashForSigning()
-	signature, err := key.Sign(hash)
+	var signature *btcec.Signature
+	var err error
+	if headerSigner, ok := key.(HeaderSigner); ok {
+		signature, err = headerSigner.SignHeader(h)
+	} else {
+		signature, err = key.Sign(hash)
+	}
 	if err != nil {
 		return err
 	}
+	if !signature.Verify(hash, key.PubKey()) {
+		return messageError("BlockHeader.Sign", "signature does not "+
+			"verify against the validating public key")
+	}
 	serialized := signature.Serialize()
 	// TODO(prova): Remove commented code.
 	// log.Printf("SIGNED hash=%v sig=%v prevblock=%v merkle=%v ",
@@ -164,6 +187,7 @@ func (h *BlockHeader) Sign(key *btcec.PrivateKey) error {
 	pubKey := key.PubKey().SerializeCompressed()[:BlockValidatingPubKeySize]
 	copy(h.ValidatingPubKey[:BlockValidatingPubKeySize], pubKey[:BlockValidatingPubKeySize])
 
+	h.Signature = BlockSignature{}
 	copy(h.Signature[:], serialized)
 	return nil
 }
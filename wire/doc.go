@@ -48,13 +48,21 @@ interactions in no particular order.
 
 	Peer A Sends                          Peer B Responds
 	----------------------------------------------------------------------------
-	getaddr message (MsgGetAddr)          addr message (MsgAddr)
+	getaddr message (MsgGetAddr)          addr message (MsgAddr) -or-
+	                                      addrv2 message (MsgAddrV2)
 	getblocks message (MsgGetBlocks)      inv message (MsgInv)
 	inv message (MsgInv)                  getdata message (MsgGetData)
 	getdata message (MsgGetData)          block message (MsgBlock) -or-
+	                                      cmpctblock message (MsgCmpctBlock) -or-
 	                                      tx message (MsgTx) -or-
 	                                      notfound message (MsgNotFound)
+	getblocktxn message (MsgGetBlockTxn)  blocktxn message (MsgBlockTxn)
 	getheaders message (MsgGetHeaders)    headers message (MsgHeaders)
+	getcfilters message (MsgGetCFilters)  cfilter message (MsgCFilter)
+	getcfheaders message                  cfheaders message (MsgCFHeaders)
+	(MsgGetCFHeaders)
+	getcfcheckpt message                  cfcheckpt message (MsgCFCheckpt)
+	(MsgGetCFCheckpt)
 	ping message (MsgPing)                pong message (MsgHeaders)* -or-
 	                                      (none -- Ability to send message is enough)
 
@@ -62,6 +70,23 @@ interactions in no particular order.
 	* The pong message was not added until later protocol versions as defined
 	  in BIP0031.  The BIP0031Version constant can be used to detect a recent
 	  enough protocol version for this purpose (version > BIP0031Version).
+	* The cmpctblock, getblocktxn and blocktxn messages were not added until
+	  protocol version CompactBlocksVersion.  A cmpctblock message is only
+	  sent in response to a getdata message for an InvTypeCmpctBlock inventory
+	  vector, or unsolicited to peers which requested it with a sendcmpct
+	  message (MsgSendCmpct).
+	* The getcfilters, getcfheaders and getcfcheckpt messages are only
+	  answered by peers which advertise the SFNodeCF service flag, as defined
+	  in BIP0157.  One cfilter message is sent for every block of the
+	  requested range.
+	* The addrv2 message was not added until protocol version AddrV2Version
+	  and is only sent to peers which requested it with a sendaddrv2 message
+	  (MsgSendAddrV2) before their verack message, as defined in BIP0155.
+	  It carries the addresses which do not fit in an addr message, such as
+	  Tor v3 onion service addresses.
+	* The heartbeat message (MsgHeartbeat) was not added until protocol
+	  version HeartbeatVersion.  It is not a response to any message, but is
+	  sent periodically by validators and relayed to peers which support it.
 
 Common Parameters
 
@@ -70,6 +70,20 @@ func TestMessage(t *testing.T) {
 	bh := NewBlockHeader(&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)
 	msgMerkleBlock := NewMsgMerkleBlock(bh)
 	msgReject := NewMsgReject("block", RejectDuplicate, "duplicate block")
+	msgSendCmpct := NewMsgSendCmpct(true, CmpctBlockEncodingVersion)
+	msgCmpctBlock := NewMsgCmpctBlock(&blockOne, 123123)
+	msgGetBlockTxn := NewMsgGetBlockTxn(&chainhash.Hash{}, []uint32{1})
+	msgBlockTxn := NewMsgBlockTxn(&chainhash.Hash{})
+	msgGetCFilters := NewMsgGetCFilters(GCSFilterRegular, 0, &chainhash.Hash{})
+	msgCFilter := NewMsgCFilter(GCSFilterRegular, &chainhash.Hash{},
+		[]byte("payload"))
+	msgGetCFHeaders := NewMsgGetCFHeaders(GCSFilterRegular, 0, &chainhash.Hash{})
+	msgCFHeaders := NewMsgCFHeaders()
+	msgGetCFCheckpt := NewMsgGetCFCheckpt(GCSFilterRegular, &chainhash.Hash{})
+	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgSendAddrV2 := NewMsgSendAddrV2()
+	msgAddrV2 := NewMsgAddrV2()
+	msgHeartbeat := NewMsgHeartbeat(0, &chainhash.Hash{})
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -99,6 +113,19 @@ func TestMessage(t *testing.T) {
 		{msgFilterLoad, msgFilterLoad, pver, MainNet, 35},
 		{msgMerkleBlock, msgMerkleBlock, pver, MainNet, 239},
 		{msgReject, msgReject, pver, MainNet, 79},
+		{msgSendCmpct, msgSendCmpct, pver, MainNet, 33},
+		{msgCmpctBlock, msgCmpctBlock, pver, MainNet, 378},
+		{msgGetBlockTxn, msgGetBlockTxn, pver, MainNet, 58},
+		{msgBlockTxn, msgBlockTxn, pver, MainNet, 57},
+		{msgGetCFilters, msgGetCFilters, pver, MainNet, 61},
+		{msgCFilter, msgCFilter, pver, MainNet, 65},
+		{msgGetCFHeaders, msgGetCFHeaders, pver, MainNet, 61},
+		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
+		{msgGetCFCheckpt, msgGetCFCheckpt, pver, MainNet, 57},
+		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgHeartbeat, msgHeartbeat, pver, MainNet, 181},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,224 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode of addresses of
+// the various networks.
+func TestAddrV2Wire(t *testing.T) {
+	pver := ProtocolVersion
+	timestamp := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+
+	torV3Key := bytes.Repeat([]byte{0xaa}, 32)
+	msg := NewMsgAddrV2()
+	msg.AddAddresses(
+		&NetAddress{
+			Timestamp: timestamp,
+			Services:  SFNodeNetwork,
+			IP:        net.ParseIP("127.0.0.1"),
+			Port:      8333,
+		},
+		&NetAddress{
+			Timestamp: timestamp,
+			Services:  SFNodeNetwork | SFNodeBloom,
+			IP:        net.ParseIP("fd87:d87e:eb43:102:304:506:708:90a"),
+			Port:      8334,
+		},
+		&NetAddress{
+			Timestamp: timestamp,
+			Services:  SFNodeNetwork,
+			Port:      8335,
+			NetworkID: NetTorV3,
+			Addr:      torV3Key,
+		},
+		&NetAddress{
+			Timestamp: timestamp,
+			Port:      8336,
+			NetworkID: 0x10,
+			Addr:      []byte{0x01, 0x02, 0x03},
+		},
+	)
+	encoded := []byte{
+		0x04,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                         // Varint for SFNodeNetwork
+		0x01,                         // NetIPv4
+		0x04, 0x7f, 0x00, 0x00, 0x01, // IP 127.0.0.1
+		0x20, 0x8d, // Port 8333 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x05, // Varint for SFNodeNetwork|SFNodeBloom
+		0x03, // NetTorV2
+		0x0a, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+		0x20, 0x8e, // Port 8334 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01, // Varint for SFNodeNetwork
+		0x04, // NetTorV3
+		0x20, // Varint for address length
+	}
+	encoded = append(encoded, torV3Key...)
+	encoded = append(encoded, []byte{
+		0x20, 0x8f, // Port 8335 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x00,                   // Varint for no services
+		0x10,                   // Unknown network
+		0x03, 0x01, 0x02, 0x03, // Address
+		0x20, 0x90, // Port 8336 in big-endian
+	}...)
+
+	// Encode the message to wire format.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), encoded) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(encoded))
+	}
+
+	// Decode the message from wire format.
+	var readMsg MsgAddrV2
+	if err := readMsg.BtcDecode(bytes.NewReader(encoded), pver); err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BtcDecode\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+	if readMsg.AddrList[1].IsAddrV2Only() ||
+		!readMsg.AddrList[2].IsAddrV2Only() {
+		t.Fatal("IsAddrV2Only: Tor v2 addresses are relayed in addr " +
+			"messages while Tor v3 addresses are not")
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against wire encode and decode
+// of MsgAddrV2 to confirm error paths work correctly.
+func TestAddrV2WireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	na := &NetAddress{
+		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Services:  SFNodeNetwork,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      8333,
+	}
+	baseAddr := NewMsgAddrV2()
+	baseAddr.AddAddress(na)
+	baseAddrEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                         // Varint for SFNodeNetwork
+		0x01,                         // NetIPv4
+		0x04, 0x7f, 0x00, 0x00, 0x01, // IP 127.0.0.1
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+
+	// Message that forces an error by having more than the max allowed
+	// addresses.
+	maxAddr := NewMsgAddrV2()
+	for i := 0; i < MaxAddrPerMsg; i++ {
+		maxAddr.AddAddress(na)
+	}
+	maxAddr.AddrList = append(maxAddr.AddrList, na)
+	maxAddrEncoded := []byte{
+		0xfd, 0x03, 0xe9, // Varint for number of addresses (1001)
+	}
+
+	// Message that forces an error by having a Tor v3 address of the
+	// wrong size.
+	badSizeAddr := NewMsgAddrV2()
+	badSizeAddr.AddAddress(NewNetAddressNetwork(NetTorV3, []byte{0x01},
+		8333, 0))
+	badSizeEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x00,       // Varint for no services
+		0x04,       // NetTorV3
+		0x01, 0x01, // Address
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+
+	tests := []struct {
+		in       *MsgAddrV2 // Value to encode
+		buf      []byte     // Wire encoding
+		pver     uint32     // Protocol version for wire encoding
+		max      int        // Max size of fixed buffer to induce errors
+		writeErr error      // Expected write error
+		readErr  error      // Expected read error
+	}{
+		// Force error in addresses count.
+		{baseAddr, baseAddrEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in address list.
+		{baseAddr, baseAddrEncoded, pver, 1, io.ErrShortWrite, io.EOF},
+		// Force error in network id.
+		{baseAddr, baseAddrEncoded, pver, 6, io.ErrShortWrite, io.EOF},
+		// Force error in port.
+		{baseAddr, baseAddrEncoded, pver, 12, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max addresses.
+		{maxAddr, maxAddrEncoded, pver, 3, wireErr, wireErr},
+		// Force error for protocol versions before addrv2 was added.
+		{baseAddr, baseAddrEncoded, AddrV2Version - 1, 100, wireErr,
+			wireErr},
+		// Force error with an address of the wrong size.  The address
+		// is encoded, but it is rejected when decoded.
+		{badSizeAddr, badSizeEncoded, pver, 0, io.ErrShortWrite,
+			io.EOF},
+		{badSizeAddr, badSizeEncoded, pver, len(badSizeEncoded) + 1,
+			nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgAddrV2
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxnWire tests the MsgBlockTxn wire encode and decode.
+func TestBlockTxnWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := blockOne.BlockHash()
+	msg := NewMsgBlockTxn(&hash)
+	msg.AddTransaction(multiTx)
+	msg.AddTransaction(stripTx)
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	var buf bytes.Buffer
+	if err := stripTx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	wantBuf := append(append([]byte{}, hash[:]...), 0x02)
+	wantBuf = append(wantBuf, multiTxEncoded...)
+	wantBuf = append(wantBuf, buf.Bytes()...)
+	buf.Reset()
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), wantBuf) {
+		t.Fatalf("BtcEncode:\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(wantBuf))
+	}
+
+	var readMsg MsgBlockTxn
+	if err := readMsg.BtcDecode(bytes.NewReader(wantBuf), pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode:\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Truncated messages fail to decode.
+	err := readMsg.BtcDecode(bytes.NewReader(wantBuf[:len(wantBuf)-1]), pver)
+	if err != io.ErrUnexpectedEOF && err != io.EOF {
+		t.Errorf("BtcDecode: wrong error for truncated message - got %v",
+			err)
+	}
+
+	// The message is not supported by older protocol versions.
+	err = msg.BtcEncode(&buf, CompactBlocksVersion-1)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for old protocol version - "+
+			"got %v", err)
+	}
+}
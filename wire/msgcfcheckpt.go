@@ -0,0 +1,132 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+const (
+	// CFCheckptInterval is the gap between the blocks whose filter headers
+	// are delivered in a cfcheckpt message.
+	CFCheckptInterval = 1000
+
+	// maxCFHeadersLen is the maximum number of filter headers a cfcheckpt
+	// message may contain, which is limited by the maximum payload size.
+	maxCFHeadersLen = 100000
+)
+
+// MsgCFCheckpt implements the Message interface and represents a bitcoin
+// cfcheckpt message.  It is used to deliver the committed filter headers of
+// every CFCheckptInterval-th block in response to a getcfcheckpt
+// (MsgGetCFCheckpt) message.
+type MsgCFCheckpt struct {
+	FilterType    FilterType
+	StopHash      chainhash.Hash
+	FilterHeaders []*chainhash.Hash
+}
+
+// AddCFHeader adds a new committed filter header to the message.
+func (msg *MsgCFCheckpt) AddCFHeader(header *chainhash.Hash) error {
+	if len(msg.FilterHeaders)+1 > maxCFHeadersLen {
+		str := fmt.Sprintf("too many committed filter headers in "+
+			"message [max %v]", maxCFHeadersLen)
+		return messageError("MsgCFCheckpt.AddCFHeader", str)
+	}
+
+	msg.FilterHeaders = append(msg.FilterHeaders, header)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) BtcDecode(r io.Reader, pver uint32) error {
+	err := readElements(r, &msg.FilterType, &msg.StopHash)
+	if err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to decode an insane number of filter headers.
+	if count > maxCFHeadersLen {
+		str := fmt.Sprintf("too many committed filter headers for "+
+			"message [count %v, max %v]", count, maxCFHeadersLen)
+		return messageError("MsgCFCheckpt.BtcDecode", str)
+	}
+
+	// Create a contiguous slice of hashes to deserialize into in order to
+	// reduce the number of allocations.
+	msg.FilterHeaders = make([]*chainhash.Hash, 0, count)
+	headers := make([]chainhash.Hash, count)
+	for i := uint64(0); i < count; i++ {
+		header := &headers[i]
+		if err := readElement(r, header); err != nil {
+			return err
+		}
+		msg.AddCFHeader(header)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) BtcEncode(w io.Writer, pver uint32) error {
+	count := len(msg.FilterHeaders)
+	if count > maxCFHeadersLen {
+		str := fmt.Sprintf("too many committed filter headers for "+
+			"message [count %v, max %v]", count, maxCFHeadersLen)
+		return messageError("MsgCFCheckpt.BtcEncode", str)
+	}
+
+	err := writeElements(w, msg.FilterType, &msg.StopHash)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	for _, header := range msg.FilterHeaders {
+		if err := writeElement(w, header); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCFCheckpt) Command() string {
+	return CmdCFCheckpt
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCFCheckpt) MaxPayloadLength(pver uint32) uint32 {
+	// Message size depends on the blockchain height, so return general
+	// limit for all messages.
+	return MaxMessagePayload
+}
+
+// NewMsgCFCheckpt returns a new bitcoin cfcheckpt message that conforms to the
+// Message interface.  See MsgCFCheckpt for details.
+func NewMsgCFCheckpt(filterType FilterType, stopHash *chainhash.Hash,
+	headersCount int) *MsgCFCheckpt {
+	return &MsgCFCheckpt{
+		FilterType:    filterType,
+		StopHash:      *stopHash,
+		FilterHeaders: make([]*chainhash.Hash, 0, headersCount),
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFHeadersWire tests the MsgCFHeaders and MsgCFCheckpt wire encode and
+// decode.
+func TestCFHeadersWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	stopHash := chainhash.Hash{0x01}
+	prevHeader := chainhash.Hash{0x02}
+	hashes := []chainhash.Hash{{0x03}, {0x04}}
+
+	cfHeaders := NewMsgCFHeaders()
+	cfHeaders.StopHash = stopHash
+	cfHeaders.PrevFilterHeader = prevHeader
+	cfCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &stopHash, len(hashes))
+	for i := range hashes {
+		if err := cfHeaders.AddCFHash(&hashes[i]); err != nil {
+			t.Fatalf("AddCFHash: unexpected error: %v", err)
+		}
+		if err := cfCheckpt.AddCFHeader(&hashes[i]); err != nil {
+			t.Fatalf("AddCFHeader: unexpected error: %v", err)
+		}
+	}
+
+	wantCFHeaders := append([]byte{0x00}, stopHash[:]...)
+	wantCFHeaders = append(wantCFHeaders, prevHeader[:]...)
+	wantCFHeaders = append(wantCFHeaders, 0x02)
+	wantCFHeaders = append(wantCFHeaders, hashes[0][:]...)
+	wantCFHeaders = append(wantCFHeaders, hashes[1][:]...)
+
+	wantCFCheckpt := append([]byte{0x00}, stopHash[:]...)
+	wantCFCheckpt = append(wantCFCheckpt, 0x02)
+	wantCFCheckpt = append(wantCFCheckpt, hashes[0][:]...)
+	wantCFCheckpt = append(wantCFCheckpt, hashes[1][:]...)
+
+	tests := []struct {
+		in   Message
+		out  Message
+		cmd  string
+		want []byte
+	}{
+		{cfHeaders, &MsgCFHeaders{}, "cfheaders", wantCFHeaders},
+		{cfCheckpt, &MsgCFCheckpt{}, "cfcheckpt", wantCFCheckpt},
+	}
+
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: wrong command - got %v want %v",
+				i, cmd, test.cmd)
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.BtcEncode(&buf, pver); err != nil {
+			t.Errorf("BtcEncode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("BtcEncode #%d:\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.want))
+			continue
+		}
+
+		err := test.out.BtcDecode(bytes.NewReader(test.want), pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, test.in) {
+			t.Errorf("BtcDecode #%d:\n got: %s want: %s", i,
+				spew.Sdump(test.out), spew.Sdump(test.in))
+		}
+	}
+}
+
+// TestCFHeadersWireErrors ensures messages with too many filter hashes are
+// rejected.
+func TestCFHeadersWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgCFHeaders()
+	hash := chainhash.Hash{}
+	for i := 0; i < MaxCFHeadersPerMsg; i++ {
+		if err := msg.AddCFHash(&hash); err != nil {
+			t.Fatalf("AddCFHash: unexpected error: %v", err)
+		}
+	}
+	if err := msg.AddCFHash(&hash); err == nil {
+		t.Fatal("AddCFHash: no error exceeding the maximum")
+	}
+
+	// Decoding a message with too many filter hashes fails.
+	tooMany := make([]byte, 1+2*chainhash.HashSize)
+	tooMany = append(tooMany, 0xfd, 0xd1, 0x07) // 2001 hashes
+	var readMsg MsgCFHeaders
+	err := readMsg.BtcDecode(bytes.NewReader(tooMany), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for too many hashes - got %v",
+			err)
+	}
+}
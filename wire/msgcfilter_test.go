@@ -0,0 +1,57 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFilterWire tests the MsgCFilter wire encode and decode.
+func TestCFilterWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := chainhash.Hash{0x01, 0x02}
+	msg := NewMsgCFilter(GCSFilterRegular, &hash, []byte{0x03, 0x04})
+
+	// Ensure the command is expected value.
+	wantCmd := "cfilter"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCFilter: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	wantBuf := append([]byte{0x00}, hash[:]...)
+	wantBuf = append(wantBuf, 0x02, 0x03, 0x04)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), wantBuf) {
+		t.Fatalf("BtcEncode:\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(wantBuf))
+	}
+
+	var readMsg MsgCFilter
+	if err := readMsg.BtcDecode(bytes.NewReader(wantBuf), pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode:\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+
+	// Filters larger than the maximum size are rejected.
+	msg.Data = make([]byte, MaxCFilterDataSize+1)
+	err := msg.BtcEncode(&buf, pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for oversized filter - got %v",
+			err)
+	}
+}
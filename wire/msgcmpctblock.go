@@ -0,0 +1,307 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+const (
+	// ShortTxIDSize is the number of bytes of a short transaction id.
+	ShortTxIDSize = 6
+
+	// shortTxIDMask masks the bits of a short transaction id.
+	shortTxIDMask = 1<<(ShortTxIDSize*8) - 1
+)
+
+// PrefilledTx defines a transaction which is sent in full as part of a compact
+// block, along with its index in the block.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a bitcoin
+// cmpctblock message.  It is used to relay a block as its header along with
+// short ids of its transactions, so a peer can reconstruct the block from the
+// transactions in its memory pool.  Transactions the peer is unlikely to have,
+// such as the coinbase, are sent in full as prefilled transactions.  Missing
+// transactions are requested with a getblocktxn message (MsgGetBlockTxn).
+//
+// The short ids are listed in the order of the transactions in the block,
+// leaving out the prefilled transactions.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgCmpctBlock struct {
+	Header       BlockHeader
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+// BlockHash computes the block identifier hash for the compact block.
+func (msg *MsgCmpctBlock) BlockHash() chainhash.Hash {
+	return msg.Header.BlockHash()
+}
+
+// TxCount returns the number of transactions of the block.
+func (msg *MsgCmpctBlock) TxCount() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+// ShortIDKeys returns the SipHash keys of the short transaction ids of the
+// compact block, which are derived from the block header and the nonce.
+func (msg *MsgCmpctBlock) ShortIDKeys() (uint64, uint64) {
+	var buf bytes.Buffer
+	buf.Grow(MaxBlockHeaderPayload + 8)
+	_ = writeBlockHeader(&buf, 0, &msg.Header)
+	_ = binarySerializer.PutUint64(&buf, littleEndian, msg.Nonce)
+	hash := sha256.Sum256(buf.Bytes())
+	return binary.LittleEndian.Uint64(hash[0:8]),
+		binary.LittleEndian.Uint64(hash[8:16])
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more short ids than could possibly fit into a block.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	var buf [8]byte
+	msg.ShortIDs = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		_, err := io.ReadFull(r, buf[:ShortTxIDSize])
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs,
+			binary.LittleEndian.Uint64(buf[:]))
+	}
+
+	prefilledCount, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	txCount := count + prefilledCount
+	if prefilledCount > maxTxPerBlock || txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", txCount, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	// The indexes of prefilled transactions are encoded as the difference
+	// to the index following the previous prefilled transaction.
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, prefilledCount)
+	var next uint64
+	for i := uint64(0); i < prefilledCount; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if diff >= txCount-next {
+			str := fmt.Sprintf("prefilled transaction index out "+
+				"of range [index %d, count %d]", next+diff,
+				txCount)
+			return messageError("MsgCmpctBlock.BtcDecode", str)
+		}
+		index := next + diff
+
+		tx := MsgTx{}
+		err = tx.BtcDecode(r, pver)
+		if err != nil {
+			return err
+		}
+		msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+			Index: uint32(index),
+			Tx:    &tx,
+		})
+		next = index + 1
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, shortID := range msg.ShortIDs {
+		binary.LittleEndian.PutUint64(buf[:], shortID)
+		_, err := w.Write(buf[:ShortTxIDSize])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs)))
+	if err != nil {
+		return err
+	}
+	var next uint64
+	for _, ptx := range msg.PrefilledTxs {
+		index := uint64(ptx.Index)
+		if index < next {
+			str := fmt.Sprintf("prefilled transaction index %d "+
+				"is not in ascending order", index)
+			return messageError("MsgCmpctBlock.BtcEncode", str)
+		}
+		err = WriteVarInt(w, pver, index-next)
+		if err != nil {
+			return err
+		}
+		err = ptx.Tx.BtcEncode(w, pver)
+		if err != nil {
+			return err
+		}
+		next = index + 1
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// A compact block is never larger than the block it stands for.
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new bitcoin cmpctblock message for the passed
+// block that conforms to the Message interface.  The coinbase transaction is
+// prefilled and all other transactions are sent as short ids keyed with the
+// passed nonce.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(block *MsgBlock, nonce uint64) *MsgCmpctBlock {
+	msg := &MsgCmpctBlock{
+		Header:       block.Header,
+		Nonce:        nonce,
+		ShortIDs:     make([]uint64, 0, len(block.Transactions)),
+		PrefilledTxs: make([]*PrefilledTx, 0, 1),
+	}
+	k0, k1 := msg.ShortIDKeys()
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			msg.PrefilledTxs = append(msg.PrefilledTxs,
+				&PrefilledTx{Index: 0, Tx: tx})
+			continue
+		}
+		txHash := tx.TxHashWithSig()
+		msg.ShortIDs = append(msg.ShortIDs, ShortTxID(k0, k1, &txHash))
+	}
+	return msg
+}
+
+// ShortTxID returns the short transaction id of the passed transaction hash,
+// including signatures, for the SipHash keys returned by ShortIDKeys.
+func ShortTxID(k0, k1 uint64, txHash *chainhash.Hash) uint64 {
+	return SipHash24(k0, k1, txHash[:]) & shortTxIDMask
+}
+
+// SipHash24 returns the SipHash-2-4 of the passed data with the key k0, k1.
+func SipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	compress := func(m uint64) {
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+
+	length := len(data)
+	for ; len(data) >= 8; data = data[8:] {
+		compress(binary.LittleEndian.Uint64(data))
+	}
+
+	// The final block holds the remaining bytes and the length of the data
+	// in its most significant byte.
+	last := uint64(length&math.MaxUint8) << 56
+	for i := len(data) - 1; i >= 0; i-- {
+		last |= uint64(data[i]) << uint(8*i)
+	}
+	compress(last)
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
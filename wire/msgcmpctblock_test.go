@@ -0,0 +1,166 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSipHash ensures the SipHash-2-4 implementation matches the test vectors
+// of the reference implementation.
+func TestSipHash(t *testing.T) {
+	// The key is the bytes 0x00 to 0x0f.
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+
+	tests := []struct {
+		len  int
+		want uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{1, 0x74f839c593dc67fd},
+		{8, 0x93f5f5799a932462},
+		{15, 0xa129ca6149be45e5},
+	}
+
+	for i, test := range tests {
+		data := make([]byte, test.len)
+		for j := range data {
+			data[j] = byte(j)
+		}
+		if got := SipHash24(k0, k1, data); got != test.want {
+			t.Errorf("SipHash24 #%d: got %x, want %x", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestCmpctBlock tests the MsgCmpctBlock API and ensures a compact block
+// survives a round trip through the wire encoding.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	block := NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(multiTx)
+	block.AddTransaction(stripTx)
+
+	msg := NewMsgCmpctBlock(block, 0x0123456789abcdef)
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	if msg.BlockHash() != block.BlockHash() {
+		t.Errorf("BlockHash: got %v, want %v", msg.BlockHash(),
+			block.BlockHash())
+	}
+	if msg.TxCount() != len(block.Transactions) {
+		t.Errorf("TxCount: got %d, want %d", msg.TxCount(),
+			len(block.Transactions))
+	}
+
+	// The coinbase is prefilled and all other transactions are sent as
+	// short ids.
+	if len(msg.PrefilledTxs) != 1 || msg.PrefilledTxs[0].Index != 0 ||
+		msg.PrefilledTxs[0].Tx != block.Transactions[0] {
+		t.Fatalf("NewMsgCmpctBlock: wrong prefilled transactions %v",
+			spew.Sdump(msg.PrefilledTxs))
+	}
+	k0, k1 := msg.ShortIDKeys()
+	for i, tx := range block.Transactions[1:] {
+		txHash := tx.TxHashWithSig()
+		want := ShortTxID(k0, k1, &txHash)
+		if want>>(ShortTxIDSize*8) != 0 {
+			t.Errorf("ShortTxID #%d: %x exceeds %d bytes", i, want,
+				ShortTxIDSize)
+		}
+		if msg.ShortIDs[i] != want {
+			t.Errorf("NewMsgCmpctBlock: short id #%d got %x, "+
+				"want %x", i, msg.ShortIDs[i], want)
+		}
+	}
+
+	// A different nonce yields different short ids.
+	other := NewMsgCmpctBlock(block, 0)
+	if reflect.DeepEqual(msg.ShortIDs, other.ShortIDs) {
+		t.Errorf("NewMsgCmpctBlock: short ids do not depend on nonce")
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	var readMsg MsgCmpctBlock
+	if err := readMsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode:\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestCmpctBlockWireErrors performs negative tests against wire encode and
+// decode of MsgCmpctBlock to confirm error paths work correctly.
+func TestCmpctBlockWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Prefilled transactions must be in ascending order.
+	msg := &MsgCmpctBlock{
+		Header: blockOne.Header,
+		PrefilledTxs: []*PrefilledTx{
+			{Index: 1, Tx: multiTx},
+			{Index: 0, Tx: stripTx},
+		},
+	}
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for unordered prefilled "+
+			"transactions - got %v", err)
+	}
+
+	// Prefilled transactions must be within the block.
+	msg.PrefilledTxs = []*PrefilledTx{{Index: 1, Tx: multiTx}}
+	buf.Reset()
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	var readMsg MsgCmpctBlock
+	err = readMsg.BtcDecode(&buf, pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for out of range prefilled "+
+			"transaction - got %v", err)
+	}
+
+	// Compact blocks are not supported by older protocol versions.
+	buf.Reset()
+	err = msg.BtcEncode(&buf, CompactBlocksVersion-1)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for old protocol version - "+
+			"got %v", err)
+	}
+	err = readMsg.BtcDecode(&buf, CompactBlocksVersion-1)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for old protocol version - "+
+			"got %v", err)
+	}
+}
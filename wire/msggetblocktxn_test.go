@@ -0,0 +1,96 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxnWire tests the MsgGetBlockTxn wire encode and decode, which
+// encodes the indexes differentially.
+func TestGetBlockTxnWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := chainhash.Hash{0x01, 0x02}
+	msg := NewMsgGetBlockTxn(&hash, []uint32{1, 2, 5, 300})
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	wantBuf := append(append([]byte{}, hash[:]...),
+		0x04,             // Num indexes
+		0x01,             // Index 1
+		0x00,             // Index 2
+		0x02,             // Index 5
+		0xfd, 0x26, 0x01, // Index 300
+	)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), wantBuf) {
+		t.Fatalf("BtcEncode:\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(wantBuf))
+	}
+
+	var readMsg MsgGetBlockTxn
+	if err := readMsg.BtcDecode(bytes.NewReader(wantBuf), pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode:\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestGetBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgGetBlockTxn to confirm error paths work correctly.
+func TestGetBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	hash := chainhash.Hash{}
+
+	// Indexes must be in ascending order.
+	msg := NewMsgGetBlockTxn(&hash, []uint32{2, 2})
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for unordered indexes - got %v",
+			err)
+	}
+
+	// Indexes must not exceed the transactions of a block.
+	tooLarge := append(append([]byte{}, hash[:]...),
+		0x01,                         // Num indexes
+		0xfe, 0xff, 0xff, 0xff, 0xff, // Index
+	)
+	var readMsg MsgGetBlockTxn
+	err = readMsg.BtcDecode(bytes.NewReader(tooLarge), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for out of range index - "+
+			"got %v", err)
+	}
+
+	// The message is not supported by older protocol versions.
+	msg = NewMsgGetBlockTxn(&hash, []uint32{0})
+	err = msg.BtcEncode(&buf, CompactBlocksVersion-1)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error for old protocol version - "+
+			"got %v", err)
+	}
+	err = readMsg.BtcDecode(&buf, CompactBlocksVersion-1)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: wrong error for old protocol version - "+
+			"got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetCFiltersWire tests the MsgGetCFilters, MsgGetCFHeaders and
+// MsgGetCFCheckpt wire encode and decode.
+func TestGetCFiltersWire(t *testing.T) {
+	pver := ProtocolVersion
+	hash := chainhash.Hash{0x01, 0x02}
+	height := []byte{0x10, 0x27, 0x00, 0x00} // 10000
+
+	tests := []struct {
+		in   Message
+		out  Message
+		cmd  string
+		want []byte
+	}{
+		{
+			NewMsgGetCFilters(GCSFilterRegular, 10000, &hash),
+			&MsgGetCFilters{},
+			"getcfilters",
+			append(append([]byte{0x00}, height...), hash[:]...),
+		},
+		{
+			NewMsgGetCFHeaders(GCSFilterRegular, 10000, &hash),
+			&MsgGetCFHeaders{},
+			"getcfheaders",
+			append(append([]byte{0x00}, height...), hash[:]...),
+		},
+		{
+			NewMsgGetCFCheckpt(GCSFilterRegular, &hash),
+			&MsgGetCFCheckpt{},
+			"getcfcheckpt",
+			append([]byte{0x00}, hash[:]...),
+		},
+	}
+
+	for i, test := range tests {
+		if cmd := test.in.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d: wrong command - got %v want %v",
+				i, cmd, test.cmd)
+		}
+		if uint32(len(test.want)) != test.in.MaxPayloadLength(pver) {
+			t.Errorf("MaxPayloadLength #%d: got %d, want %d", i,
+				test.in.MaxPayloadLength(pver), len(test.want))
+		}
+
+		var buf bytes.Buffer
+		if err := test.in.BtcEncode(&buf, pver); err != nil {
+			t.Errorf("BtcEncode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("BtcEncode #%d:\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.want))
+			continue
+		}
+
+		err := test.out.BtcDecode(bytes.NewReader(test.want), pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, test.in) {
+			t.Errorf("BtcDecode #%d:\n got: %s want: %s", i,
+				spew.Sdump(test.out), spew.Sdump(test.in))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// heartbeatPayloadSize is the size of the payload of a heartbeat message,
+// which is the height, block hash, timestamp, validating public key and
+// signature.
+const heartbeatPayloadSize = 4 + chainhash.HashSize + 8 +
+	BlockValidatingPubKeySize + BlockSignatureSize
+
+// heartbeatSigningMagic is prepended to the signed fields of heartbeat
+// messages, so a signature of a heartbeat can never be passed off as the
+// signature of anything else signed by a validate key, such as a block.
+var heartbeatSigningMagic = []byte("Prova validator heartbeat:\n")
+
+// MsgHeartbeat implements the Message interface and represents a prova
+// heartbeat message.  It is used by validators to periodically announce that
+// they are alive, along with the best block they know of, so the federation
+// can detect a stalled signer before gaps in block production appear.  The
+// message is signed by the validate key of the validator and relayed through
+// the network.
+//
+// This message was not added until protocol versions starting with
+// HeartbeatVersion.
+type MsgHeartbeat struct {
+	// Height and BlockHash identify the best block of the validator.
+	Height    uint32
+	BlockHash chainhash.Hash
+
+	// Timestamp is the time the heartbeat was created (encoded as an int64
+	// on the wire).
+	Timestamp time.Time
+
+	// ValidatingPubKey is the validate key of the validator, which signed
+	// the heartbeat.
+	ValidatingPubKey BlockValidatingPubKey
+	Signature        BlockSignature
+}
+
+// writeSigned writes the fields of the message covered by its signature to w.
+func (msg *MsgHeartbeat) writeSigned(w io.Writer) error {
+	err := writeElements(w, msg.Height, &msg.BlockHash,
+		msg.Timestamp.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg.ValidatingPubKey[:])
+	return err
+}
+
+// SigningHash returns the hash of the message which is signed by the validate
+// key of the validator.
+func (msg *MsgHeartbeat) SigningHash() chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Write(heartbeatSigningMagic)
+	// Writing to a bytes.Buffer never fails.
+	_ = msg.writeSigned(&buf)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Hash returns the hash of the whole message, which identifies it when it is
+// relayed.
+func (msg *MsgHeartbeat) Hash() chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(heartbeatPayloadSize)
+	// Writing to a bytes.Buffer never fails.
+	_ = msg.BtcEncode(&buf, HeartbeatVersion)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Sign sets the validating public key of the message to the public key of the
+// passed signer and signs the message with it.
+func (msg *MsgHeartbeat) Sign(key btcec.Signer) error {
+	pubKey := key.PubKey().SerializeCompressed()
+	copy(msg.ValidatingPubKey[:], pubKey)
+
+	hash := msg.SigningHash()
+	signature, err := key.Sign(hash[:])
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(hash[:], key.PubKey()) {
+		return messageError("MsgHeartbeat.Sign", "signature does not "+
+			"verify against the validating public key")
+	}
+	msg.Signature = BlockSignature{}
+	copy(msg.Signature[:], signature.Serialize())
+	return nil
+}
+
+// Verify returns whether the message is signed by its validating public key.
+func (msg *MsgHeartbeat) Verify() bool {
+	pubKey, err := btcec.ParsePubKey(msg.ValidatingPubKey[:], btcec.S256())
+	if err != nil {
+		return false
+	}
+	sig, err := btcec.ParseDERSignature(msg.Signature[:], btcec.S256())
+	if err != nil {
+		return false
+	}
+	hash := msg.SigningHash()
+	return sig.Verify(hash[:], pubKey)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgHeartbeat) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < HeartbeatVersion {
+		str := fmt.Sprintf("heartbeat message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgHeartbeat.BtcDecode", str)
+	}
+
+	err := readElements(r, &msg.Height, &msg.BlockHash,
+		(*int64Time)(&msg.Timestamp))
+	if err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, msg.ValidatingPubKey[:]); err != nil {
+		return err
+	}
+	_, err = io.ReadFull(r, msg.Signature[:])
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgHeartbeat) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < HeartbeatVersion {
+		str := fmt.Sprintf("heartbeat message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgHeartbeat.BtcEncode", str)
+	}
+
+	if err := msg.writeSigned(w); err != nil {
+		return err
+	}
+	_, err := w.Write(msg.Signature[:])
+	return err
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgHeartbeat) Command() string {
+	return CmdHeartbeat
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgHeartbeat) MaxPayloadLength(pver uint32) uint32 {
+	return heartbeatPayloadSize
+}
+
+// NewMsgHeartbeat returns a new, unsigned prova heartbeat message for the
+// passed best block that conforms to the Message interface.  The timestamp is
+// set to the current time with a precision of one second.  See MsgHeartbeat
+// for details.
+func NewMsgHeartbeat(height uint32, blockHash *chainhash.Hash) *MsgHeartbeat {
+	return &MsgHeartbeat{
+		Height:    height,
+		BlockHash: *blockHash,
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+	}
+}
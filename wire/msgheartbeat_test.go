@@ -0,0 +1,112 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestHeartbeat tests the MsgHeartbeat API against the latest protocol
+// version, including signing and verifying it.
+func TestHeartbeat(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := chainhash.Hash{0x01, 0x02}
+	msg := NewMsgHeartbeat(1234, &hash)
+	if msg.Height != 1234 || msg.BlockHash != hash {
+		t.Errorf("NewMsgHeartbeat: wrong best block - got %d %v, "+
+			"want 1234 %v", msg.Height, msg.BlockHash, hash)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "heartbeat"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgHeartbeat: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(157)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Unsigned messages do not verify.
+	if msg.Verify() {
+		t.Error("Verify: unsigned message verified")
+	}
+
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	if err := msg.Sign(key); err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	if !bytes.Equal(msg.ValidatingPubKey[:], key.PubKey().SerializeCompressed()) {
+		t.Errorf("Sign: wrong validating public key - got %v, want %x",
+			msg.ValidatingPubKey, key.PubKey().SerializeCompressed())
+	}
+	if !msg.Verify() {
+		t.Error("Verify: signed message did not verify")
+	}
+
+	// Test encode and decode with latest protocol version.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("encode of MsgHeartbeat failed %v err <%v>", msg, err)
+	}
+	if buf.Len() != int(wantPayload) {
+		t.Errorf("encode of MsgHeartbeat: wrong size - got %d, want %d",
+			buf.Len(), wantPayload)
+	}
+	var readmsg MsgHeartbeat
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("decode of MsgHeartbeat failed [%v] err <%v>", buf, err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("decode of MsgHeartbeat: got %v, want %v",
+			spew.Sdump(&readmsg), spew.Sdump(msg))
+	}
+	if !readmsg.Verify() || readmsg.Hash() != msg.Hash() {
+		t.Error("decode of MsgHeartbeat: decoded message differs")
+	}
+
+	// Any change of a signed field invalidates the signature.
+	readmsg.Height++
+	if readmsg.Verify() {
+		t.Error("Verify: modified message verified")
+	}
+
+	// Truncated messages fail to decode.
+	buf.Reset()
+	msg.BtcEncode(&buf, pver)
+	truncated := bytes.NewReader(buf.Bytes()[:100])
+	if err := readmsg.BtcDecode(truncated, pver); err != io.ErrUnexpectedEOF {
+		t.Errorf("decode of truncated MsgHeartbeat: got error %v, "+
+			"want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// The message is invalid before HeartbeatVersion.
+	oldPver := HeartbeatVersion - 1
+	if err := msg.BtcEncode(&buf, oldPver); err == nil {
+		t.Errorf("encode of MsgHeartbeat succeeded for protocol "+
+			"version %d", oldPver)
+	}
+	if err := readmsg.BtcDecode(&buf, oldPver); err == nil {
+		t.Errorf("decode of MsgHeartbeat succeeded for protocol "+
+			"version %d", oldPver)
+	}
+}
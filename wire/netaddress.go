@@ -6,12 +6,78 @@
 package wire
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"net"
 	"time"
 )
 
+// NetworkID identifies the network of an address in an addrv2 message as
+// defined by BIP0155.
+type NetworkID uint8
+
+// These constants define the networks of addresses in addrv2 messages.
+const (
+	// NetIPv4 identifies IPv4 addresses.
+	NetIPv4 NetworkID = 1
+
+	// NetIPv6 identifies IPv6 addresses.
+	NetIPv6 NetworkID = 2
+
+	// NetTorV2 identifies Tor v2 onion service addresses.
+	NetTorV2 NetworkID = 3
+
+	// NetTorV3 identifies Tor v3 onion service addresses.
+	NetTorV3 NetworkID = 4
+
+	// NetI2P identifies I2P addresses.
+	NetI2P NetworkID = 5
+
+	// NetCJDNS identifies CJDNS addresses.
+	NetCJDNS NetworkID = 6
+)
+
+// Map of network IDs back to their constant names for pretty printing.
+var networkIDStrings = map[NetworkID]string{
+	NetIPv4:  "NetIPv4",
+	NetIPv6:  "NetIPv6",
+	NetTorV2: "NetTorV2",
+	NetTorV3: "NetTorV3",
+	NetI2P:   "NetI2P",
+	NetCJDNS: "NetCJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := networkIDStrings[id]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+// networkAddrSizes maps the networks of BIP0155 to the size of their
+// addresses.  Addresses of these networks with any other size are invalid,
+// while addresses of unknown networks may have any size up to
+// MaxAddrV2Size.
+var networkAddrSizes = map[NetworkID]int{
+	NetIPv4:  4,
+	NetIPv6:  16,
+	NetTorV2: 10,
+	NetTorV3: 32,
+	NetI2P:   32,
+	NetCJDNS: 16,
+}
+
+// MaxAddrV2Size is the maximum size of an address in an addrv2 message.
+const MaxAddrV2Size = 512
+
+// onionCatPrefix is the prefix of the IPv6 addresses which encode Tor v2
+// onion service addresses (fd87:d87e:eb43::/48).
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
 // maxNetAddressPayload returns the max payload size for a bitcoin NetAddress
 // based on the protocol version.
 func maxNetAddressPayload(pver uint32) uint32 {
@@ -27,6 +93,14 @@ func maxNetAddressPayload(pver uint32) uint32 {
 	return plen
 }
 
+// maxNetAddressV2Payload returns the max payload size for a NetAddress in an
+// addrv2 message.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services varint + network id 1 byte + address
+	// length varint + address + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload + MaxAddrV2Size + 2
+}
+
 // NetAddress defines information about a peer on the network including the time
 // it was last seen, the services it supports, its IP address, and port.
 type NetAddress struct {
@@ -45,6 +119,21 @@ type NetAddress struct {
 	// Port the peer is using.  This is encoded in big endian on the wire
 	// which differs from most everything else.
 	Port uint16
+
+	// NetworkID and Addr hold the address of a peer on a network whose
+	// addresses can not be encoded as an IPv6 address, such as a Tor v3
+	// onion service.  Addr is nil for IPv4, IPv6 and Tor v2 addresses,
+	// which are held by IP, and IP is nil otherwise.  Since only addrv2
+	// messages are able to carry such addresses, they are not sent to
+	// peers which did not ask for addrv2 messages.
+	NetworkID NetworkID
+	Addr      []byte
+}
+
+// IsAddrV2Only returns whether the address can only be encoded in addrv2
+// messages, so it can not be relayed in addr messages.
+func (na *NetAddress) IsAddrV2Only() bool {
+	return na.Addr != nil
 }
 
 // HasService returns whether the specified service is supported by the address.
@@ -80,6 +169,20 @@ func NewNetAddressTimestamp(
 	return &na
 }
 
+// NewNetAddressNetwork returns a new NetAddress using the provided network,
+// address, port, and supported services with defaults for the remaining fields.
+// It is used for addresses which can not be held by an IP, such as Tor v3
+// onion service addresses.
+func NewNetAddressNetwork(networkID NetworkID, addr []byte, port uint16, services ServiceFlag) *NetAddress {
+	return &NetAddress{
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Services:  services,
+		Port:      port,
+		NetworkID: networkID,
+		Addr:      addr,
+	}
+}
+
 // NewNetAddress returns a new NetAddress using the provided TCP address and
 // supported services with defaults for the remaining fields.
 func NewNetAddress(addr *net.TCPAddr, services ServiceFlag) *NetAddress {
@@ -148,3 +251,97 @@ func writeNetAddress(w io.Writer, pver uint32, na *NetAddress, ts bool) error {
 	// Sigh.  Bitcoin protocol mixes little and big endian.
 	return binary.Write(w, bigEndian, na.Port)
 }
+
+// readNetAddressV2 reads an encoded NetAddress of an addrv2 message from r.
+// IPv4, IPv6 and Tor v2 addresses are converted to an IP, while the addresses
+// of other networks are held by the NetworkID and Addr fields.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddress) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	var networkID NetworkID
+	err = readElement(r, &networkID)
+	if err != nil {
+		return err
+	}
+	addr, err := ReadVarBytes(r, pver, MaxAddrV2Size, "address")
+	if err != nil {
+		return err
+	}
+	if size, ok := networkAddrSizes[networkID]; ok && len(addr) != size {
+		str := fmt.Sprintf("invalid %v address size [size %d, want %d]",
+			networkID, len(addr), size)
+		return messageError("readNetAddressV2", str)
+	}
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  ServiceFlag(services),
+		Port:      port,
+	}
+	switch networkID {
+	case NetIPv4:
+		na.IP = net.IPv4(addr[0], addr[1], addr[2], addr[3])
+	case NetIPv6:
+		na.IP = net.IP(addr)
+	case NetTorV2:
+		na.IP = net.IP(append(append([]byte{}, onionCatPrefix...),
+			addr...))
+	default:
+		na.NetworkID = networkID
+		na.Addr = addr
+	}
+	return nil
+}
+
+// writeNetAddressV2 serializes a NetAddress of an addrv2 message to w.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddress) error {
+	// NOTE: The bitcoin protocol uses a uint32 for the timestamp so it will
+	// stop working somewhere around 2106.
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+
+	networkID, addr := na.NetworkID, na.Addr
+	if addr == nil {
+		// Ensure to always write 16 bytes even if the ip is nil.
+		var ip [16]byte
+		if na.IP != nil {
+			copy(ip[:], na.IP.To16())
+		}
+		switch {
+		case na.IP.To4() != nil:
+			networkID, addr = NetIPv4, na.IP.To4()
+		case bytes.HasPrefix(ip[:], onionCatPrefix):
+			networkID, addr = NetTorV2, ip[len(onionCatPrefix):]
+		default:
+			networkID, addr = NetIPv6, ip[:]
+		}
+	}
+	err = writeElement(w, networkID)
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
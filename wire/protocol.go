@@ -13,7 +13,7 @@ import (
 
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 70013
+	ProtocolVersion uint32 = 70016
 
 	// MultipleAddressVersion is the protocol version which added multiple
 	// addresses per message (pver >= MultipleAddressVersion).
@@ -51,6 +51,18 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
+
+	// CompactBlocksVersion is the protocol version which added the
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages.
+	CompactBlocksVersion uint32 = 70014
+
+	// AddrV2Version is the protocol version which added the sendaddrv2
+	// and addrv2 messages.
+	AddrV2Version uint32 = 70015
+
+	// HeartbeatVersion is the protocol version which added the heartbeat
+	// message.
+	HeartbeatVersion uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -67,6 +79,10 @@ const (
 	// SFNodeBloom is a flag used to indiciate a peer supports bloom
 	// filtering.
 	SFNodeBloom
+
+	// SFNodeCF is a flag used to indicate a peer supports serving
+	// compact block filters (BIP0157).
+	SFNodeCF ServiceFlag = 1 << 6
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -74,6 +90,7 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeNetwork: "SFNodeNetwork",
 	SFNodeGetUTXO: "SFNodeGetUTXO",
 	SFNodeBloom:   "SFNodeBloom",
+	SFNodeCF:      "SFNodeCF",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -82,6 +99,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeNetwork,
 	SFNodeGetUTXO,
 	SFNodeBloom,
+	SFNodeCF,
 }
 
 // String returns the ServiceFlag in human-readable form.